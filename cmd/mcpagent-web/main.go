@@ -5,13 +5,14 @@
 // The application supports:
 // - Server-Sent Events (SSE) connections for real-time task execution
 // - HTTP API endpoints for task execution (config provided by frontend)
-// - Static file serving for the web UI
+// - The web UI embedded in the binary, or served from -static-dir
 // - CORS support for development
 // - Graceful shutdown on interrupt signals
 package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -20,10 +21,13 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/LubyRuffy/einomcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/audit"
+	"github.com/LubyRuffy/mcpagent/pkg/config"
 	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
 	"github.com/LubyRuffy/mcpagent/pkg/services"
 	"github.com/LubyRuffy/mcpagent/pkg/webserver"
+	"github.com/LubyRuffy/mcpagent/pkg/webui"
 )
 
 // Exit code constants
@@ -37,21 +41,53 @@ const (
 // Error message constants
 const (
 	errMsgServerStartFailed = "启动Web服务器失败: %w"
+	errMsgAuditOpenFailed   = "打开审计日志失败: %w"
+	errMsgDBNotWritable     = "%v。只读环境中可以使用 -no-db 以无数据库模式运行"
+	errMsgDBLocked          = "%v。请先停止使用该数据库的实例，或通过 -db 使用另一个数据库文件"
 )
 
 // CommandLineArgs holds all command line arguments for the web server
 type CommandLineArgs struct {
-	Port   *string // Server port
-	Host   *string // Server host
-	DBPath *string // Database file path
+	Port            *string        // Server port
+	Host            *string        // Server host
+	DBPath          *string        // Database file path
+	DBKeyFile       *string        // Key file of the encrypted database columns
+	NoDB            *bool          // Whether to run without a database, with all configuration supplied per request
+	HealthInterval  *time.Duration // Interval of MCP server health checks, 0 disables them
+	HealthThreshold *int           // Consecutive failures before a server alert is sent
+	AuditPath       *string        // Audit log file path, empty disables auditing
+	AuditMaxSize    *int           // Size of an audit log file in MB before it is rotated
+	AuditMaxFiles   *int           // Number of rotated audit log files kept
+	AuditCompress   *bool          // Whether rotated audit log files are compressed
+	Checkpoints     *int           // Model calls between task checkpoints, 0 disables them
+	StaticDir       *string        // Frontend directory overriding the embedded web UI
+	ReplayDir       *string        // Directory of the replay files task configs may record and play
+	ETAPercentile   *float64       // Percentile of task durations above which tasks are ignored by the queue ETA
+	TaskRetention   *time.Duration // How long finished tasks stay in the in-memory task list
+	ToolResultLimit *int           // Maximum size in bytes of the tool results sent to the browser
 }
 
 // parseCommandLineArgs parses and returns command line arguments
 func parseCommandLineArgs() *CommandLineArgs {
+	defaultHealth := webserver.DefaultHealthCheckConfig()
 	args := &CommandLineArgs{
-		Port:   flag.String("port", "8081", "服务器端口"),
-		Host:   flag.String("host", "", "服务器主机地址"),
-		DBPath: flag.String("db", "./data/mcpagent.db", "数据库文件路径"),
+		Port:            flag.String("port", "8081", "服务器端口"),
+		Host:            flag.String("host", "", "服务器主机地址"),
+		DBPath:          flag.String("db", "./data/mcpagent.db", "数据库文件路径"),
+		NoDB:            flag.Bool("no-db", false, "不使用数据库运行，配置由每个任务请求提供，配置管理接口返回501"),
+		DBKeyFile:       flag.String("db-key-file", "", "数据库敏感字段的加密密钥文件，为空时使用环境变量MCPAGENT_SECRET_KEY或数据库目录下的secret.key"),
+		HealthInterval:  flag.Duration("health-interval", defaultHealth.Interval, "MCP服务器健康检查间隔，0表示禁用"),
+		HealthThreshold: flag.Int("health-threshold", defaultHealth.FailureThreshold, "MCP服务器连续失败多少次后发送告警"),
+		AuditPath:       flag.String("audit-log", "", "审计日志文件路径，为空时不记录"),
+		AuditMaxSize:    flag.Int("audit-max-size", 0, "单个审计日志文件轮转前的最大大小（MB），0表示使用默认值（100）"),
+		AuditMaxFiles:   flag.Int("audit-max-files", 0, "保留的审计日志轮转文件数量，0表示使用默认值（5）"),
+		AuditCompress:   flag.Bool("audit-compress", false, "是否使用gzip压缩轮转后的审计日志"),
+		Checkpoints:     flag.Int("checkpoint-interval", webserver.DefaultCheckpointInterval, "任务每执行多少次模型调用保存一次检查点，0表示禁用"),
+		StaticDir:       flag.String("static-dir", "", "前端静态文件目录（如 web/dist），为空时使用嵌入的前端页面"),
+		ReplayDir:       flag.String("replay-dir", "", "任务配置中replay录制和回放文件所在的目录，为空时忽略任务的replay配置"),
+		ETAPercentile:   flag.Float64("eta-percentile", webserver.DefaultETAPercentile, "估计排队任务开始时间时忽略执行时间超过该百分位的任务，0或1表示不忽略"),
+		TaskRetention:   flag.Duration("task-retention", webserver.DefaultTaskRetention, "结束的任务在内存任务列表（GET /api/tasks/running）中保留的时间"),
+		ToolResultLimit: flag.Int("tool-result-limit", webserver.DefaultToolResultEventLimit, "发送给前端的tool_result事件中工具结果的最大字节数，超过的部分被截断，0表示不截断"),
 	}
 
 	flag.Parse()
@@ -70,9 +106,20 @@ func setupSignalHandling(cancel context.CancelFunc) {
 	}()
 }
 
-// startWebServer starts the web server
-func startWebServer(ctx context.Context, addr string) error {
+// startWebServer starts the web server, auditLogger may be nil, an empty
+// staticDir serves the embedded web UI and an empty replayDir disables replay
+func startWebServer(ctx context.Context, addr string, healthConfig webserver.HealthCheckConfig, auditLogger *audit.Logger, checkpointInterval int, staticDir string, replayDir string, etaPercentile float64, taskRetention time.Duration, toolResultLimit int) error {
 	server := webserver.NewServer(addr)
+	server.SetHealthCheckConfig(healthConfig)
+	server.SetAuditLogger(auditLogger)
+	server.SetCheckpointInterval(checkpointInterval)
+	server.SetReplayDir(replayDir)
+	server.SetETAPercentile(etaPercentile)
+	server.SetTaskRetention(taskRetention)
+	server.SetToolResultEventLimit(toolResultLimit)
+	if err := server.SetStaticDir(staticDir); err != nil {
+		return fmt.Errorf(errMsgServerStartFailed, err)
+	}
 
 	// Start server in a goroutine
 	serverErr := make(chan error, 1)
@@ -112,26 +159,53 @@ func printStartupInfo(addr string) {
 }
 
 // runServer runs the web server
-// it will use the provided context for cancellation and signal handling
-func runServer(ctx context.Context, addr string, dbPath string) error {
-	// Initialize database
-	if err := database.InitDatabase(dbPath); err != nil {
-		log.Fatalf("数据库初始化失败: %v", err)
+// it will use the provided context for cancellation and signal handling,
+// an empty dbPath runs the server without a database
+func runServer(ctx context.Context, addr string, dbPath string, dbKeyFile string, healthConfig webserver.HealthCheckConfig, auditConfig config.AuditConfig, checkpointInterval int, staticDir string, replayDir string, etaPercentile float64, taskRetention time.Duration, toolResultLimit int) error {
+	if err := auditConfig.Validate(); err != nil {
+		return fmt.Errorf(errMsgAuditOpenFailed, err)
+	}
+	auditLogger, err := auditConfig.Open()
+	if err != nil {
+		return fmt.Errorf(errMsgAuditOpenFailed, err)
+	}
+	if auditLogger != nil {
+		log.Printf("审计日志: %s", auditConfig.Path)
 	}
-	log.Printf("数据库初始化成功: %s", dbPath)
 
-	// 同步内置工具到数据库
-	log.Println("开始同步内置工具到数据库...")
-	if err := services.SyncInternalToolsWithDatabase(context.Background()); err != nil {
-		log.Printf("警告: 同步内置工具失败: %v", err)
+	if dbPath == "" {
+		log.Println("无数据库模式: 配置管理接口不可用，任务请求需要提供完整配置")
 	} else {
-		log.Println("内置工具同步成功")
+		// Initialize database
+		if err := database.InitDatabaseWithKeyFile(dbPath, dbKeyFile); err != nil {
+			if errors.Is(err, database.ErrNotWritable) {
+				log.Fatalf(errMsgDBNotWritable, err)
+			}
+			if errors.Is(err, database.ErrDatabaseLocked) {
+				log.Fatalf(errMsgDBLocked, err)
+			}
+			log.Fatalf("数据库初始化失败: %v", err)
+		}
+		log.Printf("数据库初始化成功: %s", dbPath)
+
+		// 同步内置工具到数据库
+		log.Println("开始同步内置工具到数据库...")
+		if err := services.SyncInternalToolsWithDatabase(context.Background()); err != nil {
+			log.Printf("警告: 同步内置工具失败: %v", err)
+		} else {
+			log.Println("内置工具同步成功")
+		}
 	}
 
 	log.Println("Web服务器启动成功，配置将由前端页面提供")
 
 	// Start web server
-	if err := startWebServer(ctx, addr); err != nil {
+	if staticDir != "" {
+		log.Printf("前端静态文件目录: %s", staticDir)
+	} else if !webui.HasIndex(webui.Assets()) {
+		log.Println("警告: 未嵌入前端页面，请执行 scripts/build-web.sh 构建或使用 -static-dir 指定前端构建目录")
+	}
+	if err := startWebServer(ctx, addr, healthConfig, auditLogger, checkpointInterval, staticDir, replayDir, etaPercentile, taskRetention, toolResultLimit); err != nil {
 		log.Fatalf("Web服务器错误: %v", err)
 	}
 
@@ -139,7 +213,7 @@ func runServer(ctx context.Context, addr string, dbPath string) error {
 	log.Println("正在清理资源...")
 
 	// 清理MCP连接池
-	pool := einomcphost.GetConnectionPool()
+	pool := mcppool.Default()
 	if errs := pool.Shutdown(); len(errs) > 0 {
 		for _, err := range errs {
 			log.Printf("关闭MCP连接池时出错: %v", err)
@@ -173,7 +247,22 @@ func main() {
 	// Print startup information
 	printStartupInfo(addr)
 
-	if err := runServer(context.Background(), addr, *args.DBPath); err != nil {
+	healthConfig := webserver.DefaultHealthCheckConfig()
+	healthConfig.Interval = *args.HealthInterval
+	healthConfig.FailureThreshold = *args.HealthThreshold
+
+	auditConfig := config.AuditConfig{
+		Path:      *args.AuditPath,
+		MaxSizeMB: *args.AuditMaxSize,
+		MaxFiles:  *args.AuditMaxFiles,
+		Compress:  *args.AuditCompress,
+	}
+
+	dbPath := *args.DBPath
+	if *args.NoDB {
+		dbPath = ""
+	}
+	if err := runServer(context.Background(), addr, dbPath, *args.DBKeyFile, healthConfig, auditConfig, *args.Checkpoints, *args.StaticDir, *args.ReplayDir, *args.ETAPercentile, *args.TaskRetention, *args.ToolResultLimit); err != nil {
 		log.Fatalf("Web服务器运行失败: %v", err)
 	}
 }
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// programName is the name of the binary used in help output and completion scripts
+const programName = "mcpagent"
+
+// toolsListTimeout limits the time the tools command spends listing the tools of all servers
+const toolsListTimeout = 60 * time.Second
+
+// usageError reports invalid usage, execute prints the usage of the command after the error
+type usageError struct {
+	error
+}
+
+// runTaskFunc executes a task with the parsed run flags, replaced in tests
+var runTaskFunc = runTask
+
+// usageTemplate is the Chinese version of the cobra usage template
+const usageTemplate = `用法:{{if and .Runnable (not .HasAvailableSubCommands)}}
+  {{.UseLine}}{{end}}{{if .HasAvailableSubCommands}}
+  {{.CommandPath}} <命令> [参数]{{end}}{{if .HasExample}}
+
+示例:
+{{.Example}}{{end}}{{if .HasAvailableSubCommands}}
+
+可用命令:{{range .Commands}}{{if (or .IsAvailableCommand (eq .Name "help"))}}
+  {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
+
+参数:
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableSubCommands}}
+
+使用 "{{.CommandPath}} <命令> --help" 查看命令的详细帮助。{{end}}
+`
+
+// newRootCommand builds the command tree of the CLI
+func newRootCommand(stdout, stderr io.Writer) *cobra.Command {
+	root := &cobra.Command{
+		Use:   programName,
+		Short: "使用MCP工具和大模型执行任务的命令行工具",
+		Example: examples(
+			programName+` run --task "分析网络安全领域的最新研究趋势"`,
+			programName+` -task "分析网络安全领域的最新研究趋势"    # 省略run，兼容旧的用法`,
+			programName+` tools --mcp-config mcpservers.json`,
+			programName+` config validate --config config.yaml`,
+			`source <(`+programName+` completion bash)`,
+		),
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return usageError{fmt.Errorf("未知命令 %q", args[0])}
+			}
+			return cmd.Help()
+		},
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+	}
+	root.SetOut(stdout)
+	root.SetErr(stderr)
+	root.SetUsageTemplate(usageTemplate)
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+
+	root.AddCommand(newRunCommand(), newToolsCommand(), newConfigCommand(), newCompletionCommand(root))
+	root.SetHelpCommand(&cobra.Command{
+		Use:   "help [命令]",
+		Short: "显示命令的帮助",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, rest, err := root.Find(args)
+			if err != nil || len(rest) > 0 {
+				return fmt.Errorf("未知命令: %s", strings.Join(args, " "))
+			}
+			return target.Help()
+		},
+	})
+	root.InitDefaultHelpCmd()
+	setHelpFlags(root)
+	return root
+}
+
+// newRunCommand builds the run command, its flags are the flags of earlier
+// versions without subcommands
+func newRunCommand() *cobra.Command {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	runArgs := registerRunFlags(fs)
+
+	cmd := &cobra.Command{
+		Use:   "run --task <任务> [参数]",
+		Short: "执行任务",
+		Example: examples(
+			programName+` run --task "分析网络安全领域的最新研究趋势"`,
+			programName+` run --config news_config.yaml --mcp-tools "fetch:fetch,ddg-search:search" --task "总结今天的科技新闻"`,
+			programName+` run --attach access.log --task "分析日志中的异常访问"`,
+			programName+` run --db ./data/mcpagent.db --llm-config-name "默认Ollama配置" --task "分析example.com的攻击面"`,
+			programName+` run --template "域名侦察" --param domain=example.com --param depth=2`,
+		),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateTaskArgs(runArgs); err != nil {
+				return usageError{err}
+			}
+			return runTaskFunc(runArgs)
+		},
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().AddGoFlagSet(fs)
+	markFileFlags(cmd, "config", "mcp-config", "db", "db-key-file", "attach")
+
+	_ = cmd.RegisterFlagCompletionFunc("mcp-tools", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		specs := toolSpecsForCompletion(*runArgs.ConfigFile, *runArgs.MCPConfigFile)
+		return completeToolList(toComplete, specs), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	})
+	return cmd
+}
+
+// newToolsCommand builds the tools command listing the tools accepted by --mcp-tools
+func newToolsCommand() *cobra.Command {
+	var configFile, mcpConfigFile string
+
+	cmd := &cobra.Command{
+		Use:   "tools [参数]",
+		Short: "列出可用的工具",
+		Example: examples(
+			programName+` tools`,
+			programName+` tools --mcp-config mcpservers.json`,
+		),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfigOrDefault(configFile)
+			if strings.TrimSpace(mcpConfigFile) != "" {
+				cfg.MCP.ConfigFile = mcpConfigFile
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), toolsListTimeout)
+			defer cancel()
+
+			entries, errs := enumerateTools(ctx, cfg)
+			for _, err := range errs {
+				fmt.Fprintf(cmd.ErrOrStderr(), "警告: %v\n", err)
+			}
+			return printTools(cmd.OutOrStdout(), entries)
+		},
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().StringVar(&configFile, "config", "default_config.yaml", "配置文件路径")
+	cmd.Flags().StringVar(&mcpConfigFile, "mcp-config", "", "MCP服务器配置文件路径")
+	markFileFlags(cmd, "config", "mcp-config")
+	return cmd
+}
+
+// newConfigCommand builds the config command and its validate subcommand
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "config <命令> [参数]",
+		Short:                 "管理配置文件",
+		DisableFlagsInUseLine: true,
+	}
+
+	var configFile string
+	validate := &cobra.Command{
+		Use:   "validate [参数]",
+		Short: "检查配置文件是否有效",
+		Example: examples(
+			programName + ` config validate --config config.yaml`,
+		),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validateConfigFile(cmd.OutOrStdout(), configFile)
+		},
+		DisableFlagsInUseLine: true,
+	}
+	validate.Flags().StringVar(&configFile, "config", "default_config.yaml", "配置文件路径")
+	markFileFlags(validate, "config")
+
+	cmd.AddCommand(validate)
+	return cmd
+}
+
+// newCompletionCommand builds the completion command printing the completion script of root
+func newCompletionCommand(root *cobra.Command) *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion <bash|zsh|fish>",
+		Short: "生成shell自动补全脚本",
+		Example: examples(
+			`source <(`+programName+` completion bash)`,
+			programName+` completion zsh > "${fpath[1]}/_`+programName+`"`,
+			programName+` completion fish > ~/.config/fish/completions/`+programName+`.fish`,
+		),
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return usageError{errors.New("需要指定一个shell")}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return writeCompletionScript(root, cmd.OutOrStdout(), args[0])
+		},
+		DisableFlagsInUseLine: true,
+	}
+}
+
+// examples formats usage examples for the Example field of a command
+func examples(lines ...string) string {
+	return "  " + strings.Join(lines, "\n  ")
+}
+
+// markFileFlags tells the completion scripts to complete file paths for the flags
+func markFileFlags(cmd *cobra.Command, names ...string) {
+	for _, name := range names {
+		_ = cmd.MarkFlagFilename(name)
+	}
+}
+
+// setHelpFlags adds the -h/--help flag with a Chinese description to cmd and its subcommands
+func setHelpFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("help", "h", false, "显示帮助")
+	for _, child := range cmd.Commands() {
+		setHelpFlags(child)
+	}
+}
+
+// normalizeArgs prepares argv for cobra. Arguments starting with a flag are
+// passed to the run command, so the command lines of earlier versions without
+// subcommands keep working, and single dash long flags such as -task are
+// rewritten to --task for the command they belong to.
+func normalizeArgs(root *cobra.Command, argv []string) []string {
+	args := append([]string(nil), argv...)
+	var prefix []string
+	if len(args) > 0 && args[0] == cobra.ShellCompRequestCmd {
+		prefix, args = args[:1], args[1:]
+	}
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && !isHelpFlag(args[0]) {
+		args = append([]string{"run"}, args...)
+	}
+
+	// 定位参数所属的命令
+	cmd := root
+	for _, arg := range args {
+		next := findSubcommand(cmd, arg)
+		if next == nil {
+			break
+		}
+		cmd = next
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+			continue
+		}
+		name, _, hasValue := strings.Cut(arg[1:], "=")
+		f := cmd.Flags().Lookup(name)
+		if len(name) < 2 || f == nil {
+			continue
+		}
+		args[i] = "-" + arg
+		if !hasValue && f.NoOptDefVal == "" {
+			// 跳过参数的值，值本身可能以 - 开头
+			i++
+		}
+	}
+	return append(prefix, args...)
+}
+
+// findSubcommand returns the subcommand of cmd named name
+func findSubcommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, child := range cmd.Commands() {
+		if child.Name() == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// execute runs the command line and returns the exit code
+func execute(argv []string, stdout, stderr io.Writer) int {
+	root := newRootCommand(stdout, stderr)
+	if len(argv) == 0 {
+		root.SetOut(stderr)
+		_ = root.Help()
+		return ExitCodeError
+	}
+
+	root.SetArgs(normalizeArgs(root, argv))
+	cmd, err := root.ExecuteC()
+	if err == nil {
+		return ExitCodeSuccess
+	}
+
+	fmt.Fprintf(stderr, "错误: %v\n", err)
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintln(stderr)
+		cmd.SetOut(stderr)
+		_ = cmd.Usage()
+	}
+	return ExitCodeError
+}
+
+// isHelpFlag reports whether arg asks for help
+func isHelpFlag(arg string) bool {
+	switch arg {
+	case "-h", "-help", "--help":
+		return true
+	}
+	return false
+}
+
+// loadConfigOrDefault loads the configuration file, falling back to the default configuration
+func loadConfigOrDefault(configFile string) *config.Config {
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		return config.NewDefaultConfig()
+	}
+	return cfg
+}
+
+// printTools prints tools as a table of tool specs accepted by -mcp-tools and descriptions
+func printTools(w io.Writer, entries []toolEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Spec() < entries[j].Spec()
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "工具\t说明\n")
+	for _, entry := range entries {
+		desc, _, _ := strings.Cut(strings.TrimSpace(entry.Desc), "\n")
+		fmt.Fprintf(tw, "%s\t%s\n", entry.Spec(), desc)
+	}
+	return tw.Flush()
+}
+
+// maxExplainedValueLength is the number of characters of a value printed by -explain-config
+const maxExplainedValueLength = 60
+
+// printConfigSources prints the effective value and the source of every
+// tracked field of cfg as a table, secrets are redacted
+func printConfigSources(w io.Writer, cfg *config.Config, sources config.ConfigSource) error {
+	fields, err := sources.Explain(cfg)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "字段\t来源\t值\n")
+	for _, field := range fields {
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return err
+		}
+		text := []rune(string(value))
+		if len(text) > maxExplainedValueLength {
+			text = append(text[:maxExplainedValueLength], []rune("...")...)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", field.Field, field.Source, string(text))
+	}
+	return tw.Flush()
+}
+
+// validateConfigFile validates a configuration file against the configuration
+// schema and the checks of the configuration, and prints every problem with its line
+func validateConfigFile(w io.Writer, configFile string) error {
+	if _, err := os.Stat(configFile); err != nil {
+		return fmt.Errorf("配置文件不存在: %s", configFile)
+	}
+
+	cfg, problems, err := config.CheckConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			if problem.Line > 0 {
+				fmt.Fprintf(w, "%s:%d: %s: %s\n", configFile, problem.Line, problem.Field, problem.Message)
+			} else {
+				fmt.Fprintf(w, "%s: %s: %s\n", configFile, problem.Field, problem.Message)
+			}
+		}
+		return fmt.Errorf("配置无效，共%d个问题", len(problems))
+	}
+
+	_, warnings, err := cfg.MCP.ResolveServers()
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "警告: %s\n", warning)
+	}
+
+	fmt.Fprintf(w, "配置有效: %s\n", configFile)
+	return nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandTree(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	root := newRootCommand(&stdout, &stderr)
+
+	tests := []struct {
+		args     []string
+		expected string
+		rest     []string
+	}{
+		{args: []string{"run", "--task", "x"}, expected: "mcpagent run", rest: []string{"--task", "x"}},
+		{args: []string{"tools"}, expected: "mcpagent tools"},
+		{args: []string{"config", "validate"}, expected: "mcpagent config validate"},
+		{args: []string{"completion", "bash"}, expected: "mcpagent completion", rest: []string{"bash"}},
+		{args: []string{"help", "run"}, expected: "mcpagent help", rest: []string{"run"}},
+		{args: []string{"unknown"}, expected: "mcpagent", rest: []string{"unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			cmd, rest, err := root.Find(tt.args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cmd.CommandPath())
+			assert.ElementsMatch(t, tt.rest, rest)
+		})
+	}
+
+	// 旧版本的参数都是run命令的参数
+	run, _, err := root.Find([]string{"run"})
+	require.NoError(t, err)
+	for _, name := range []string{"task", "config", "mcp-config", "mcp-tools", "attach", "param", "save-config", "explain-config", "output"} {
+		assert.NotNil(t, run.Flags().Lookup(name), name)
+	}
+}
+
+func TestNormalizeArgs(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	root := newRootCommand(&stdout, &stderr)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{name: "省略run", args: []string{"-task", "x"}, expected: []string{"run", "--task", "x"}},
+		{name: "参数值以-开头", args: []string{"run", "-task", "-x", "-max-step=3"}, expected: []string{"run", "--task", "-x", "--max-step=3"}},
+		{name: "布尔参数不带值", args: []string{"-save-config", "-task", "x"}, expected: []string{"run", "--save-config", "--task", "x"}},
+		{name: "短参数和未知参数不变", args: []string{"run", "-h", "-unknown"}, expected: []string{"run", "-h", "-unknown"}},
+		{name: "其他命令的参数", args: []string{"config", "validate", "-config", "c.yaml"}, expected: []string{"config", "validate", "--config", "c.yaml"}},
+		{name: "--之后不变", args: []string{"run", "--", "-task"}, expected: []string{"run", "--", "-task"}},
+		{name: "补全请求", args: []string{"__complete", "-mcp-tools", ""}, expected: []string{"__complete", "run", "--mcp-tools", ""}},
+		{name: "帮助", args: []string{"-help"}, expected: []string{"--help"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeArgs(root, tt.args))
+		})
+	}
+}
+
+// stubRunTask replaces the task runner and returns the captured arguments
+func stubRunTask(t *testing.T) **CommandLineArgs {
+	original := runTaskFunc
+	t.Cleanup(func() { runTaskFunc = original })
+
+	var captured *CommandLineArgs
+	runTaskFunc = func(args *CommandLineArgs) error {
+		captured = args
+		return nil
+	}
+	return &captured
+}
+
+func TestExecuteRun(t *testing.T) {
+	t.Run("run子命令", func(t *testing.T) {
+		captured := stubRunTask(t)
+		var stdout, stderr bytes.Buffer
+		code := execute([]string{"run", "--task", "x", "-mcp-tools", "fetch:fetch", "--save-config"}, &stdout, &stderr)
+		assert.Equal(t, ExitCodeSuccess, code, stderr.String())
+		require.NotNil(t, *captured)
+		assert.Equal(t, "x", *(*captured).Task)
+		assert.Equal(t, "fetch:fetch", *(*captured).MCPTools)
+		assert.True(t, (*captured).SaveConfig.set)
+	})
+
+	t.Run("省略run的旧用法", func(t *testing.T) {
+		captured := stubRunTask(t)
+		var stdout, stderr bytes.Buffer
+		code := execute([]string{"-task", "x", "-max-step", "3", "-attach", "a.txt", "-attach", "b.txt"}, &stdout, &stderr)
+		assert.Equal(t, ExitCodeSuccess, code, stderr.String())
+		require.NotNil(t, *captured)
+		assert.Equal(t, "x", *(*captured).Task)
+		assert.Equal(t, 3, *(*captured).MaxStep)
+		assert.Equal(t, []string{"a.txt", "b.txt"}, []string(*(*captured).Attachments))
+	})
+
+	t.Run("缺少任务", func(t *testing.T) {
+		captured := stubRunTask(t)
+		var stdout, stderr bytes.Buffer
+		code := execute([]string{"run"}, &stdout, &stderr)
+		assert.Equal(t, ExitCodeError, code)
+		assert.Nil(t, *captured)
+		assert.Contains(t, stderr.String(), "用法:")
+	})
+
+	t.Run("未知参数", func(t *testing.T) {
+		captured := stubRunTask(t)
+		var stdout, stderr bytes.Buffer
+		code := execute([]string{"run", "--unknown"}, &stdout, &stderr)
+		assert.Equal(t, ExitCodeError, code)
+		assert.Nil(t, *captured)
+		assert.Contains(t, stderr.String(), "unknown")
+		assert.Contains(t, stderr.String(), "用法:")
+	})
+}
+
+func TestExecuteHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, ExitCodeSuccess, execute([]string{"help", "run"}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "mcpagent run --task <任务> [参数]")
+	assert.Contains(t, stdout.String(), "示例:")
+	assert.Contains(t, stdout.String(), "--mcp-tools")
+
+	stdout.Reset()
+	assert.Equal(t, ExitCodeSuccess, execute([]string{"--help"}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "可用命令:")
+	assert.Contains(t, stdout.String(), "completion")
+	assert.NotContains(t, stdout.String(), cobra.ShellCompRequestCmd)
+
+	stdout.Reset()
+	assert.Equal(t, ExitCodeSuccess, execute([]string{"config"}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "validate")
+
+	stderr.Reset()
+	assert.Equal(t, ExitCodeError, execute([]string{"unknown"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "未知命令")
+
+	assert.Equal(t, ExitCodeError, execute(nil, &stdout, &stderr))
+}
+
+func TestExecuteConfigValidate(t *testing.T) {
+	tempDir := t.TempDir()
+	mcpConfig := filepath.Join(tempDir, "mcpservers.json")
+	require.NoError(t, os.WriteFile(mcpConfig, []byte(`{"mcpServers":{}}`), 0644))
+	configFile := filepath.Join(tempDir, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("mcp:\n  config_file: "+mcpConfig+"\n"), 0644))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, ExitCodeSuccess, execute([]string{"config", "validate", "-config", configFile}, &stdout, &stderr), stderr.String())
+	assert.Contains(t, stdout.String(), "配置有效")
+
+	stderr.Reset()
+	missing := filepath.Join(tempDir, "missing.yaml")
+	assert.Equal(t, ExitCodeError, execute([]string{"config", "validate", "-config", missing}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "配置文件不存在")
+
+	// 列出所有问题及其行号
+	stdout.Reset()
+	stderr.Reset()
+	invalid := filepath.Join(tempDir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("mcp:\n  config_file: "+mcpConfig+"\nmax_step: 0\nlanguage: fr\n"), 0644))
+	assert.Equal(t, ExitCodeError, execute([]string{"config", "validate", "-config", invalid}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), invalid+":3: max_step: ")
+	assert.Contains(t, stdout.String(), invalid+":4: language: ")
+	assert.Contains(t, stderr.String(), "共2个问题")
+}
+
+func TestExecuteCompletionScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		t.Run(shell, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, ExitCodeSuccess, execute([]string{"completion", shell}, &stdout, &stderr))
+			assert.Contains(t, stdout.String(), "__complete")
+			assert.Contains(t, stdout.String(), programName)
+		})
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, ExitCodeError, execute([]string{"completion", "powershell"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "不支持的shell")
+}
+
+// setupCompletionServers writes a temp MCP config file and stubs the tool listing of its servers
+func setupCompletionServers(t *testing.T) string {
+	mcpConfig := filepath.Join(t.TempDir(), "mcpservers.json")
+	require.NoError(t, os.WriteFile(mcpConfig, []byte(`{
+  "mcpServers": {
+    "fetch": {"command": "uvx", "args": ["mcp-server-fetch"]},
+    "ddg": {"command": "uvx", "args": ["duckduckgo-mcp-server"]},
+    "off": {"command": "uvx", "disabled": true}
+  }
+}`), 0644))
+
+	original := serverToolLister
+	t.Cleanup(func() { serverToolLister = original })
+	serverToolLister = func(ctx context.Context, name string, server *mcphost.ServerConfig) ([]*schema.ToolInfo, error) {
+		switch name {
+		case "fetch":
+			return []*schema.ToolInfo{{Name: "fetch", Desc: "获取网页内容"}}, nil
+		case "ddg":
+			return []*schema.ToolInfo{{Name: "search", Desc: "搜索"}, {Name: "fetch_content", Desc: "获取内容"}}, nil
+		}
+		t.Errorf("不应列出服务器 %s 的工具", name)
+		return nil, nil
+	}
+	return mcpConfig
+}
+
+// complete runs the __complete command and returns the candidates without
+// descriptions and the directive line
+func complete(t *testing.T, args ...string) ([]string, string) {
+	var stdout, stderr bytes.Buffer
+	code := execute(append([]string{cobra.ShellCompRequestCmd}, args...), &stdout, &stderr)
+	require.Equal(t, ExitCodeSuccess, code, stderr.String())
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	require.NotEmpty(t, lines)
+	candidates := make([]string, 0, len(lines)-1)
+	for _, line := range lines[:len(lines)-1] {
+		candidate, _, _ := strings.Cut(line, "\t")
+		candidates = append(candidates, candidate)
+	}
+	return candidates, lines[len(lines)-1]
+}
+
+func TestEnumerateTools(t *testing.T) {
+	mcpConfig := setupCompletionServers(t)
+
+	cfg := loadConfigOrDefault(filepath.Join(t.TempDir(), "missing.yaml"))
+	cfg.MCP.ConfigFile = mcpConfig
+	entries, errs := enumerateTools(context.Background(), cfg)
+	assert.Empty(t, errs)
+
+	specs := make(map[string]bool)
+	for _, entry := range entries {
+		specs[entry.Spec()] = true
+	}
+	assert.True(t, specs["fetch:fetch"])
+	assert.True(t, specs["ddg:search"])
+	assert.True(t, specs["ddg:fetch_content"])
+	assert.True(t, specs["sequentialthinking"], "内置工具不带服务器名称")
+	for spec := range specs {
+		assert.False(t, strings.HasPrefix(spec, "off:"), "禁用的服务器不应列出")
+	}
+}
+
+func TestCompleteMCPTools(t *testing.T) {
+	mcpConfig := setupCompletionServers(t)
+	toolsDirective := fmt.Sprintf(":%d", cobra.ShellCompDirectiveNoFileComp|cobra.ShellCompDirectiveNoSpace)
+
+	candidates, directive := complete(t, "run", "--mcp-config", mcpConfig, "--mcp-tools", "")
+	assert.Equal(t, toolsDirective, directive)
+	assert.Contains(t, candidates, "fetch:fetch")
+	assert.Contains(t, candidates, "ddg:search")
+	assert.Contains(t, candidates, "sequentialthinking")
+
+	candidates, _ = complete(t, "run", "-mcp-config", mcpConfig, "-mcp-tools", "fetch:fetch,ddg:")
+	assert.Equal(t, []string{"fetch:fetch,ddg:fetch_content", "fetch:fetch,ddg:search"}, candidates)
+
+	// 已选择的工具不再提示，省略run时同样补全
+	candidates, _ = complete(t, "-mcp-config", mcpConfig, "-mcp-tools", "ddg:search,ddg:")
+	assert.Equal(t, []string{"ddg:search,ddg:fetch_content"}, candidates)
+
+	candidates, _ = complete(t, "run", "--mcp-config="+mcpConfig, "--mcp-tools=fe")
+	assert.Equal(t, []string{"fetch:fetch"}, candidates)
+}
+
+func TestCompleteFlagsAndCommands(t *testing.T) {
+	candidates, _ := complete(t, "run", "--mcp-t")
+	assert.Equal(t, []string{"--mcp-tools"}, candidates)
+
+	candidates, _ = complete(t, "")
+	assert.Equal(t, []string{"completion", "config", "help", "run", "tools"}, candidates)
+
+	candidates, _ = complete(t, "config", "")
+	assert.Equal(t, []string{"validate"}, candidates)
+
+	candidates, _ = complete(t, "completion", "")
+	assert.Equal(t, []string{"bash", "zsh", "fish"}, candidates)
+
+	// 文件参数补全文件路径
+	candidates, directive := complete(t, "run", "--config", "")
+	assert.Empty(t, candidates)
+	assert.Equal(t, fmt.Sprintf(":%d", cobra.ShellCompDirectiveDefault), directive)
+}
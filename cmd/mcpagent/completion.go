@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/cloudwego/eino/schema"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout limits the time spent listing MCP tools for a completion
+const completionTimeout = 10 * time.Second
+
+// toolEntry is a tool that can be passed to -mcp-tools
+type toolEntry struct {
+	Server string // MCP服务器名称，内置工具为空
+	Name   string // 工具名称
+	Desc   string // 工具说明
+}
+
+// Spec returns the tool in the format accepted by -mcp-tools
+func (e toolEntry) Spec() string {
+	if e.Server == "" || e.Server == config.InnerServerName {
+		return e.Name
+	}
+	return e.Server + ":" + e.Name
+}
+
+// serverToolLister lists the tools of one MCP server, replaced in tests
+var serverToolLister = func(ctx context.Context, name string, server *mcphost.ServerConfig) ([]*schema.ToolInfo, error) {
+	hub, err := mcphost.NewMCPHubFromSettings(ctx, &mcphost.MCPSettings{
+		MCPServers: map[string]*mcphost.ServerConfig{name: server},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer hub.CloseServers()
+
+	toolsMap, err := hub.GetToolsMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]*schema.ToolInfo, 0, len(toolsMap))
+	for _, info := range toolsMap {
+		tools = append(tools, info)
+	}
+	return tools, nil
+}
+
+// enumerateTools lists the inner tools and the tools of all enabled MCP servers.
+// Servers that cannot be listed are reported in the returned errors.
+func enumerateTools(ctx context.Context, cfg *config.Config) ([]toolEntry, []error) {
+	var entries []toolEntry
+	var errs []error
+
+	innerTools, err := config.GetInternalTools(ctx, cfg.Proxy, cfg.Integrations)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("获取内置工具失败: %w", err))
+	}
+	for _, t := range innerTools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, toolEntry{Name: info.Name, Desc: info.Desc})
+	}
+
+	servers, _, err := cfg.MCP.ResolveServers()
+	if err != nil {
+		return entries, append(errs, err)
+	}
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if servers[name] == nil || servers[name].Disabled {
+			continue
+		}
+		tools, err := serverToolLister(ctx, name, servers[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("获取服务器 %s 的工具失败: %w", name, err))
+			continue
+		}
+		for _, info := range tools {
+			entries = append(entries, toolEntry{Server: name, Name: info.Name, Desc: info.Desc})
+		}
+	}
+	return entries, errs
+}
+
+// completeToolList completes the comma separated tool list cur.
+// The tools before the last comma are kept and not offered again.
+func completeToolList(cur string, specs []string) []string {
+	head, partial := "", cur
+	if i := strings.LastIndex(cur, defaultToolsSeparator); i >= 0 {
+		head, partial = cur[:i+1], cur[i+1:]
+	}
+
+	used := make(map[string]bool)
+	for _, spec := range strings.Split(head, defaultToolsSeparator) {
+		used[strings.TrimSpace(spec)] = true
+	}
+
+	var candidates []string
+	for _, spec := range specs {
+		if strings.HasPrefix(spec, partial) && !used[spec] {
+			candidates = append(candidates, head+spec)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// toolSpecsForCompletion lists the tool specs for the configuration selected by the
+// --config and --mcp-config flags typed so far on the command line
+func toolSpecsForCompletion(configFile, mcpConfigFile string) []string {
+	cfg := loadConfigOrDefault(configFile)
+	if strings.TrimSpace(mcpConfigFile) != "" {
+		cfg.MCP.ConfigFile = mcpConfigFile
+		cfg.MCP.MCPServers = nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	entries, _ := enumerateTools(ctx, cfg)
+	specs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		specs = append(specs, entry.Spec())
+	}
+	return specs
+}
+
+// writeCompletionScript prints the completion script of root for shell.
+// The scripts call the hidden __complete command, so --mcp-tools completes
+// the tools of the servers configured when the completion is requested.
+func writeCompletionScript(root *cobra.Command, w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(w, true)
+	case "zsh":
+		return root.GenZshCompletion(w)
+	case "fish":
+		return root.GenFishCompletion(w, true)
+	default:
+		return fmt.Errorf("不支持的shell: %s，仅支持 bash、zsh 和 fish", shell)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/childproc"
+)
+
+// shutdownGracePeriod bounds how long an interrupted task may take to close its
+// MCP servers before the remaining child processes are killed
+const shutdownGracePeriod = 5 * time.Second
+
+// errShutdownTimeout is returned when the task does not return even after its
+// child processes have been killed
+var errShutdownTimeout = errors.New("任务在中断后未能及时退出")
+
+// interruptHandler cancels the running task on the first SIGINT or SIGTERM and
+// exits immediately on the second one. The child processes present when the
+// first signal arrives are remembered, since cancelling kills the stdio MCP
+// servers but leaves the processes they started orphaned.
+type interruptHandler struct {
+	cancel      context.CancelFunc
+	signals     chan os.Signal
+	interrupted chan struct{} // 收到第一个信号后关闭
+	grace       time.Duration
+	exit        func(code int) // 第二个信号时调用，测试中替换os.Exit
+
+	mu       sync.Mutex
+	count    int
+	children []int // 收到第一个信号时的子进程
+	stopOnce sync.Once
+}
+
+// setupSignalHandling sets up graceful shutdown on interrupt signals
+func setupSignalHandling(cancel context.CancelFunc) *interruptHandler {
+	h := newInterruptHandler(cancel)
+	signal.Notify(h.signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for sig := range h.signals {
+			h.handle(sig)
+		}
+	}()
+	return h
+}
+
+// newInterruptHandler creates a handler that is not yet subscribed to signals
+func newInterruptHandler(cancel context.CancelFunc) *interruptHandler {
+	return &interruptHandler{
+		cancel:      cancel,
+		signals:     make(chan os.Signal, 2),
+		interrupted: make(chan struct{}),
+		grace:       shutdownGracePeriod,
+		exit:        os.Exit,
+	}
+}
+
+// handle reacts to a received signal
+func (h *interruptHandler) handle(sig os.Signal) {
+	h.mu.Lock()
+	h.count++
+	count := h.count
+	if count == 1 {
+		// 在取消之前记录子进程，取消会结束直接子进程，使其启动的进程脱离进程树
+		h.children = childproc.Own()
+	}
+	h.mu.Unlock()
+
+	if count == 1 {
+		log.Printf("收到信号 %v，正在优雅关闭...（再次按下Ctrl+C立即退出）", sig)
+		close(h.interrupted)
+		h.cancel()
+		return
+	}
+
+	log.Printf("再次收到信号 %v，立即退出", sig)
+	h.killChildren()
+	h.exit(ExitCodeInterrupted)
+}
+
+// killChildren kills the remembered and the current child processes that are still running
+func (h *interruptHandler) killChildren() {
+	h.mu.Lock()
+	pids := append(childproc.Own(), h.children...)
+	h.mu.Unlock()
+	if killed := childproc.Kill(pids); killed > 0 {
+		log.Printf("已强制结束 %d 个子进程", killed)
+	}
+}
+
+// run executes fn and returns its error. After an interrupt fn gets the grace
+// period to return, e.g. to close its MCP servers, then the remaining child
+// processes are killed, which also unblocks closing stdio servers stuck in
+// shutdown. Leftover child processes are killed in any case when fn returns.
+//
+// Parameters:
+//   - fn: Task to execute, it should return once its context is cancelled
+//
+// Returns:
+//   - error: Error of fn, or errShutdownTimeout if fn does not return after an interrupt
+func (h *interruptHandler) run(fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		h.killChildren()
+		return err
+	case <-h.interrupted:
+	}
+
+	select {
+	case err := <-done:
+		h.killChildren()
+		return err
+	case <-time.After(h.grace):
+		log.Printf("任务未在 %v 内退出，强制结束子进程", h.grace)
+		h.killChildren()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(h.grace):
+		return errShutdownTimeout
+	}
+}
+
+// stop unsubscribes the handler from signals
+func (h *interruptHandler) stop() {
+	h.stopOnce.Do(func() {
+		signal.Stop(h.signals)
+	})
+}
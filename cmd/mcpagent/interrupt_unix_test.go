@@ -0,0 +1,105 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/childproc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInterruptKillsMCPChildren starts a fake stdio MCP server the way the MCP
+// client does, with exec.CommandContext on the task context. Its launcher starts
+// a sleeping process and never answers, like uvx starting a server. Cancelling
+// kills the launcher only, so interrupting the task must kill the sleeping
+// process that was left behind.
+func TestInterruptKillsMCPChildren(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "server.pid")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newInterruptHandler(cancel)
+	h.grace = 2 * time.Second
+	h.exit = func(code int) { t.Errorf("第一个信号不应退出进程，退出码: %d", code) }
+
+	serverPID := make(chan int, 1)
+	go func() {
+		defer close(serverPID)
+		for i := 0; i < 500; i++ {
+			if data, err := os.ReadFile(pidFile); err == nil {
+				if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+					serverPID <- pid
+					h.handle(syscall.SIGINT)
+					return
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	err := h.run(func() error {
+		server := exec.CommandContext(ctx, "sh", "-c", `sleep 300 & echo $! > "$0"; wait`, pidFile)
+		if err := server.Start(); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		server.Wait()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled, "第一个信号应取消任务")
+
+	pid, ok := <-serverPID
+	require.True(t, ok, "假的MCP服务器没有启动")
+	assert.Eventually(t, func() bool { return !childproc.Alive(pid) }, 5*time.Second, 20*time.Millisecond,
+		"MCP服务器启动的进程 %d 在中断后仍在运行", pid)
+}
+
+// TestInterruptKillsStuckTask checks that a task which does not return after the
+// interrupt, such as a stdio server that ignores its closed stdin, has its child
+// processes killed once the grace period is over
+func TestInterruptKillsStuckTask(t *testing.T) {
+	h := newInterruptHandler(func() {})
+	h.grace = 200 * time.Millisecond
+
+	// 不随上下文结束的子进程，只有被强制结束后任务才会返回
+	server := exec.Command("sleep", "300")
+	require.NoError(t, server.Start())
+	h.handle(syscall.SIGINT)
+
+	err := h.run(func() error {
+		return server.Wait()
+	})
+	assert.Error(t, err)
+	assert.False(t, childproc.Alive(server.Process.Pid))
+}
+
+func TestInterruptHandlerSecondSignalExits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newInterruptHandler(cancel)
+	exitCode := -1
+	h.exit = func(code int) { exitCode = code }
+
+	h.handle(syscall.SIGINT)
+	assert.Error(t, ctx.Err())
+	assert.Equal(t, -1, exitCode)
+
+	h.handle(syscall.SIGINT)
+	assert.Equal(t, ExitCodeInterrupted, exitCode)
+}
+
+func TestInterruptHandlerRunWithoutInterrupt(t *testing.T) {
+	h := newInterruptHandler(func() {})
+	assert.NoError(t, h.run(func() error { return nil }))
+	assert.ErrorIs(t, h.run(func() error { return context.Canceled }), context.Canceled)
+}
@@ -11,17 +11,22 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
+	"time"
 
+	"github.com/LubyRuffy/mcpagent/pkg/attachment"
 	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/database"
 	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
 )
 
 // Exit code constants
@@ -30,11 +35,14 @@ const (
 	ExitCodeSuccess = 0
 	// ExitCodeError represents error during execution
 	ExitCodeError = 1
+	// ExitCodeInterrupted represents an exit forced by a second interrupt signal
+	ExitCodeInterrupted = 130
 )
 
 // Default values for command-line parsing
 const (
 	defaultToolsSeparator = ","
+	defaultDBPath         = "./data/mcpagent.db"
 )
 
 // Error message constants
@@ -44,155 +52,427 @@ const (
 	errMsgConfigValidation = "配置验证失败: %w"
 	errMsgSaveConfigFailed = "保存配置失败: %w"
 	errMsgExecutionFailed  = "执行任务失败: %w"
+	errMsgAttachmentFailed = "处理附件失败: %w"
+	errMsgDatabaseNotFound = "数据库文件不存在: %s"
+	errMsgOpenDBFailed     = "打开数据库失败: %w"
+	errMsgLLMConfigName    = "未找到名为 %q 的LLM配置，可用的配置: %s"
+	errMsgSystemPromptName = "未找到名为 %q 的系统提示词，可用的提示词: %s"
+	errMsgToolsInvalid     = "-mcp-tools 参数无效:\n%w"
+	errMsgTemplateName     = "未找到名为 %q 的任务模板，可用的模板: %s"
+	errMsgTemplateAndTask  = "-task 和 -template 不能同时使用"
+	errMsgParamNoTemplate  = "-param 仅能与 -template 一起使用"
+	errMsgParamInvalid     = "-param 参数 %q 无效，格式应为 key=value"
+	errMsgAuditOpenFailed  = "打开审计日志失败: %w"
+	errMsgParamDuplicate   = "-param 参数 %s 重复"
+	errMsgTemplateRender   = "渲染任务模板 %q 失败: %w"
+	errMsgSaveConfigChange = "配置文件 %s 与加载的配置文件内容不同，未保存配置，使用 -force 覆盖"
+	errMsgOutputInvalid    = "-output 参数无效: %w"
 )
 
 // CommandLineArgs holds all command line arguments in a structured format.
 // This struct provides type safety and clear documentation for all available options.
 type CommandLineArgs struct {
-	ConfigFile    *string // Path to configuration file
-	Proxy         *string // Proxy server address for HTTP requests
-	MCPConfigFile *string // Path to MCP server configuration file
-	MCPTools      *string // Comma-separated list of tools to use
-	LLMType       *string // LLM provider type (openai or ollama)
-	LLMBaseURL    *string // Base URL for LLM API
-	LLMModel      *string // LLM model name
-	LLMAPIKey     *string // API key for LLM provider
-	SystemPrompt  *string // System prompt for the agent
-	MaxStep       *int    // Maximum number of reasoning steps
-	Task          *string // Task description to execute
+	ConfigFile       *string          // Path to configuration file
+	Proxy            *string          // Proxy server address for HTTP requests
+	MCPConfigFile    *string          // Path to MCP server configuration file
+	MCPTools         *string          // Comma-separated list of tools to use
+	LLMType          *string          // LLM provider type (openai or ollama)
+	LLMBaseURL       *string          // Base URL for LLM API
+	LLMModel         *string          // LLM model name
+	LLMAPIKey        *string          // API key for LLM provider
+	SystemPrompt     *string          // System prompt for the agent
+	MaxStep          *int             // Maximum number of reasoning steps
+	Task             *string          // Task description to execute
+	Attachments      *stringSliceFlag // Files attached to the task, repeatable
+	DBPath           *string          // Path to the sqlite database shared with the web server
+	DBKeyFile        *string          // Key file of the encrypted database columns
+	LLMConfigName    *string          // Name of a stored LLM configuration
+	SystemPromptName *string          // Name of a stored system prompt
+	TaskTimeout      *int             // Overall task deadline in seconds
+	MaxDuration      *int             // Time budget of the task in seconds
+	Template         *string          // Name of a stored task template, used instead of Task
+	Params           *stringSliceFlag // Parameters of the task template as key=value, repeatable
+	SaveConfig       *saveConfigFlag  // Saves the merged configuration, to the given path or to ConfigFile
+	Force            *bool            // Overwrites a saved configuration file that differs from the loaded one
+	SaveSecrets      *bool            // Keeps the API keys in the saved configuration
+	Output           *string          // Output format of the task: plain, markdown or json
+	ExplainConfig    *bool            // Prints the effective configuration and its sources instead of running the task
 }
 
-// fatalError handles fatal errors by logging and exiting with error code.
-// This provides a consistent way to handle unrecoverable errors.
-func fatalError(err error) {
-	if err != nil {
-		log.Fatalf("致命错误: %v", err)
+// saveConfigFlag implements -save-config. Given alone it saves to the file
+// of -config, -save-config=path saves to path.
+type saveConfigFlag struct {
+	set  bool
+	path string
+}
+
+// String implements flag.Value
+func (f *saveConfigFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.path
+}
+
+// Set implements flag.Value
+func (f *saveConfigFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.set, f.path = true, ""
+	case "false":
+		f.set, f.path = false, ""
+	default:
+		f.set, f.path = true, value
+	}
+	return nil
+}
+
+// IsBoolFlag allows the flag to be given without a value
+func (f *saveConfigFlag) IsBoolFlag() bool {
+	return true
+}
+
+// target returns the file to save to, empty if the flag is not given
+func (f *saveConfigFlag) target(configFile string) string {
+	if f == nil || !f.set {
+		return ""
 	}
+	if strings.TrimSpace(f.path) != "" {
+		return strings.TrimSpace(f.path)
+	}
+	return configFile
+}
+
+// stringSliceFlag implements flag.Value for flags that can be given multiple times
+type stringSliceFlag []string
+
+// String implements flag.Value
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set implements flag.Value
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Type implements pflag.Value, it names the value in the help of the run command
+func (s *stringSliceFlag) Type() string {
+	return "stringArray"
 }
 
 // parseCommandLineArgs parses and returns command line arguments.
 // It sets up all available flags with appropriate descriptions and default values.
 func parseCommandLineArgs() *CommandLineArgs {
+	args := registerRunFlags(flag.CommandLine)
+	flag.Parse()
+	return args
+}
+
+// registerRunFlags registers the flags of the run command on fs.
+// The same flags are accepted without a subcommand for backward compatibility.
+func registerRunFlags(fs *flag.FlagSet) *CommandLineArgs {
 	args := &CommandLineArgs{
-		ConfigFile:    flag.String("config", "default_config.yaml", "配置文件路径"),
-		Proxy:         flag.String("proxy", "", "代理服务器地址"),
-		MCPConfigFile: flag.String("mcp-config", "", "MCP服务器配置文件路径"),
-		MCPTools:      flag.String("mcp-tools", "", "工具列表（用逗号分隔）"),
-		LLMType:       flag.String("llm-type", "", "LLM类型 (openai 或 ollama)"),
-		LLMBaseURL:    flag.String("llm-base-url", "", "LLM API基础URL"),
-		LLMModel:      flag.String("llm-model", "", "LLM模型名称"),
-		LLMAPIKey:     flag.String("llm-api-key", "", "LLM API密钥"),
-		SystemPrompt:  flag.String("system-prompt", "", "系统提示词"),
-		MaxStep:       flag.Int("max-step", 0, "最大步骤数"),
-		Task:          flag.String("task", "", "要执行的任务"),
+		ConfigFile:       fs.String("config", "default_config.yaml", "配置文件路径"),
+		Proxy:            fs.String("proxy", "", "代理服务器地址"),
+		MCPConfigFile:    fs.String("mcp-config", "", "MCP服务器配置文件路径"),
+		MCPTools:         fs.String("mcp-tools", "", "工具列表（用逗号分隔，格式为 server:tool，内置工具可省略server）"),
+		LLMType:          fs.String("llm-type", "", "LLM类型 (openai 或 ollama)"),
+		LLMBaseURL:       fs.String("llm-base-url", "", "LLM API基础URL"),
+		LLMModel:         fs.String("llm-model", "", "LLM模型名称"),
+		LLMAPIKey:        fs.String("llm-api-key", "", "LLM API密钥"),
+		SystemPrompt:     fs.String("system-prompt", "", "系统提示词"),
+		MaxStep:          fs.Int("max-step", 0, "最大步骤数"),
+		Task:             fs.String("task", "", "要执行的任务"),
+		Attachments:      &stringSliceFlag{},
+		DBPath:           fs.String("db", defaultDBPath, "数据库文件路径（与Web服务器共用）"),
+		DBKeyFile:        fs.String("db-key-file", "", "数据库敏感字段的加密密钥文件（与Web服务器共用）"),
+		LLMConfigName:    fs.String("llm-config-name", "", "使用数据库中指定名称的LLM配置"),
+		SystemPromptName: fs.String("system-prompt-name", "", "使用数据库中指定名称的系统提示词"),
+		TaskTimeout:      fs.Int("task-timeout", 0, "任务整体超时时间（秒）"),
+		MaxDuration:      fs.Int("max-duration", 0, "任务的时间预算（秒），剩余时间不足时要求大模型尽快给出答案"),
+		Template:         fs.String("template", "", "使用数据库中指定名称的任务模板代替 -task"),
+		Params:           &stringSliceFlag{},
+		SaveConfig:       &saveConfigFlag{},
+		Force:            fs.Bool("force", false, "-save-config 的目标文件与加载的配置文件内容不同时仍然覆盖"),
+		SaveSecrets:      fs.Bool("save-secrets", false, "-save-config 保存的配置中包含API密钥"),
+		Output:           fs.String("output", "plain", "输出格式：plain（文本）、markdown（在终端渲染结果）或 json（结束时输出一个JSON对象）"),
+		ExplainConfig:    fs.Bool("explain-config", false, "输出生效的配置及每个字段的来源，不执行任务"),
 	}
-
-	flag.Parse()
+	fs.Var(args.Attachments, "attach", "任务附件文件路径，可重复使用")
+	fs.Var(args.Params, "param", "任务模板参数，格式为 key=value，可重复使用")
+	fs.Var(args.SaveConfig, "save-config", "保存合并后的配置，-save-config=`file` 保存到指定文件，否则保存到 -config 文件")
 	return args
 }
 
 // loadAndMergeConfig loads configuration from file and merges with command line arguments.
 // Command line arguments take precedence over configuration file values.
 // This allows for flexible configuration management with override capabilities.
-func loadAndMergeConfig(args *CommandLineArgs) (*config.Config, error) {
+// The returned sources record which layer supplied each field, see -explain-config.
+func loadAndMergeConfig(args *CommandLineArgs) (*config.Config, config.ConfigSource, error) {
 	// Load base configuration from file
-	cfg, err := config.LoadConfig(*args.ConfigFile)
+	cfg, sources, err := config.LoadConfigWithSources(*args.ConfigFile)
 	if err != nil {
-		return nil, fmt.Errorf(errMsgLoadConfigFailed, err)
+		return nil, nil, fmt.Errorf(errMsgLoadConfigFailed, err)
+	}
+
+	// Apply configurations selected by name from the database
+	if err := applyDatabaseSelections(cfg, sources, args); err != nil {
+		return nil, nil, err
 	}
 
 	// Merge command line arguments (they take precedence)
-	mergeCommandLineArgs(cfg, args)
+	if err := mergeCommandLineArgs(cfg, sources, args); err != nil {
+		return nil, nil, err
+	}
 
 	// Validate the final configuration
 	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf(errMsgConfigValidation, err)
+		return nil, nil, fmt.Errorf(errMsgConfigValidation, err)
+	}
+
+	return cfg, sources, nil
+}
+
+// applyDatabaseSelections loads the LLM configuration, system prompt and task
+// template selected by name from the sqlite database and merges them into cfg.
+// A task template is rendered into args.Task, its tools and LLM configuration
+// are used unless selected otherwise. The database is only opened when a name
+// is given, and it must already exist. The applied fields are recorded in sources.
+func applyDatabaseSelections(cfg *config.Config, sources config.ConfigSource, args *CommandLineArgs) error {
+	llmConfigName := stringValue(args.LLMConfigName)
+	systemPromptName := stringValue(args.SystemPromptName)
+	templateName := stringValue(args.Template)
+	if llmConfigName == "" && systemPromptName == "" && templateName == "" {
+		return nil
+	}
+
+	dbPath := stringValue(args.DBPath)
+	if dbPath == "" {
+		dbPath = defaultDBPath
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf(errMsgDatabaseNotFound, dbPath)
+	}
+	if err := database.InitDatabaseWithKeyFile(dbPath, stringValue(args.DBKeyFile)); err != nil {
+		return fmt.Errorf(errMsgOpenDBFailed, err)
+	}
+	defer database.CloseDatabase()
+
+	if llmConfigName != "" {
+		llmService := services.NewLLMConfigService()
+		llmConfig, err := llmService.GetConfigByName(llmConfigName)
+		if err != nil {
+			if !errors.Is(err, models.ErrLLMConfigNotFound) {
+				return err
+			}
+			var names []string
+			configs, _ := llmService.ListConfigs()
+			for _, c := range configs {
+				names = append(names, c.Name)
+			}
+			return fmt.Errorf(errMsgLLMConfigName, llmConfigName, strings.Join(names, ", "))
+		}
+		config.SetField(sources, "llm", config.SourceDatabase, &cfg.LLM, services.LLMConfigToConfig(llmConfig))
+	}
+
+	if systemPromptName != "" {
+		promptService := services.NewSystemPromptService()
+		prompt, err := promptService.GetPromptByName(systemPromptName)
+		if err != nil {
+			if !errors.Is(err, models.ErrSystemPromptNotFound) {
+				return err
+			}
+			var names []string
+			prompts, _ := promptService.ListPrompts()
+			for _, p := range prompts {
+				names = append(names, p.Name)
+			}
+			return fmt.Errorf(errMsgSystemPromptName, systemPromptName, strings.Join(names, ", "))
+		}
+		config.SetField(sources, "system_prompt", config.SourceDatabase, &cfg.SystemPrompt, prompt.Content)
 	}
 
-	return cfg, nil
+	if templateName != "" {
+		if err := applyTaskTemplate(cfg, sources, args, templateName, llmConfigName == ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyTaskTemplate renders the stored task template into args.Task and applies
+// its tools, and its LLM configuration if useLLMConfig is set
+func applyTaskTemplate(cfg *config.Config, sources config.ConfigSource, args *CommandLineArgs, name string, useLLMConfig bool) error {
+	templateService := services.NewTaskTemplateService()
+	template, err := templateService.GetTemplateByName(name)
+	if err != nil {
+		if !errors.Is(err, models.ErrTaskTemplateNotFound) {
+			return err
+		}
+		var names []string
+		templates, _ := templateService.ListTemplates()
+		for _, t := range templates {
+			names = append(names, t.Name)
+		}
+		return fmt.Errorf(errMsgTemplateName, name, strings.Join(names, ", "))
+	}
+
+	var rawParams []string
+	if args.Params != nil {
+		rawParams = *args.Params
+	}
+	params, err := parseTemplateParams(rawParams)
+	if err != nil {
+		return err
+	}
+	task, err := template.Render(params)
+	if err != nil {
+		return fmt.Errorf(errMsgTemplateRender, name, err)
+	}
+	if args.Task == nil {
+		args.Task = new(string)
+	}
+	*args.Task = task
+
+	if useLLMConfig && template.LLMConfigID != nil {
+		llmConfig, err := services.NewLLMConfigService().GetConfig(*template.LLMConfigID)
+		if err != nil {
+			return fmt.Errorf("获取任务模板 %q 的LLM配置失败: %w", name, err)
+		}
+		config.SetField(sources, "llm", config.SourceDatabase, &cfg.LLM, services.LLMConfigToConfig(llmConfig))
+	}
+
+	tools, err := template.GetTools()
+	if err != nil {
+		return fmt.Errorf("%w: %v", models.ErrTaskTemplateToolsInvalid, err)
+	}
+	if len(tools) > 0 {
+		toolConfigs := make([]config.MCPToolConfig, 0, len(tools))
+		for _, tool := range tools {
+			toolConfigs = append(toolConfigs, config.MCPToolConfig{Server: tool.Server, Name: tool.Name})
+		}
+		config.SetField(sources, "mcp.tools", config.SourceDatabase, &cfg.MCP.Tools, cfg.MCP.InheritDescriptions(cfg.MCP.InheritResultFilters(toolConfigs)))
+	}
+	return nil
+}
+
+// parseTemplateParams parses the -param values given as key=value.
+// Values stay strings, the template converts them to the declared types.
+func parseTemplateParams(values []string) (map[string]any, error) {
+	params := make(map[string]any, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf(errMsgParamInvalid, value)
+		}
+		if _, exists := params[key]; exists {
+			return nil, fmt.Errorf(errMsgParamDuplicate, key)
+		}
+		params[key] = val
+	}
+	return params, nil
+}
+
+// stringValue returns the value of an optional string flag
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(*s)
 }
 
 // mergeCommandLineArgs merges command line arguments into configuration.
 // Only non-empty command line values override configuration file values.
 // This preserves the configuration file defaults when command line args are not provided.
-func mergeCommandLineArgs(cfg *config.Config, args *CommandLineArgs) {
+// The merged fields are recorded in sources.
+func mergeCommandLineArgs(cfg *config.Config, sources config.ConfigSource, args *CommandLineArgs) error {
 	if strings.TrimSpace(*args.Proxy) != "" {
-		cfg.Proxy = *args.Proxy
+		config.SetField(sources, "proxy", config.SourceFlag, &cfg.Proxy, *args.Proxy)
 	}
 	if strings.TrimSpace(*args.MCPConfigFile) != "" {
-		cfg.MCP.ConfigFile = *args.MCPConfigFile
+		config.SetField(sources, "mcp.config_file", config.SourceFlag, &cfg.MCP.ConfigFile, *args.MCPConfigFile)
 	}
 	if strings.TrimSpace(*args.MCPTools) != "" {
-		cfg.MCP.Tools = parseToolsList(*args.MCPTools)
+		tools, err := parseToolsList(*args.MCPTools)
+		if err != nil {
+			return err
+		}
+		// 命令行只能选择工具，结果过滤表达式和本地化描述沿用配置文件中的设置
+		config.SetField(sources, "mcp.tools", config.SourceFlag, &cfg.MCP.Tools, cfg.MCP.InheritDescriptions(cfg.MCP.InheritResultFilters(tools)))
 	}
 	if strings.TrimSpace(*args.LLMType) != "" {
-		cfg.LLM.Type = *args.LLMType
+		config.SetField(sources, "llm.type", config.SourceFlag, &cfg.LLM.Type, *args.LLMType)
 	}
 	if strings.TrimSpace(*args.LLMBaseURL) != "" {
-		cfg.LLM.BaseURL = *args.LLMBaseURL
+		config.SetField(sources, "llm.base_url", config.SourceFlag, &cfg.LLM.BaseURL, *args.LLMBaseURL)
 	}
 	if strings.TrimSpace(*args.LLMModel) != "" {
-		cfg.LLM.Model = *args.LLMModel
+		config.SetField(sources, "llm.model", config.SourceFlag, &cfg.LLM.Model, *args.LLMModel)
 	}
 	if strings.TrimSpace(*args.LLMAPIKey) != "" {
-		cfg.LLM.APIKey = *args.LLMAPIKey
+		// 命令行指定的密钥优先于配置文件中的api_key_file和api_key_cmd
+		config.SetField(sources, "llm.api_key", config.SourceFlag, &cfg.LLM.APIKey, *args.LLMAPIKey)
+		config.SetField(sources, "llm.api_key_file", config.SourceFlag, &cfg.LLM.APIKeyFile, "")
+		config.SetField(sources, "llm.api_key_cmd", config.SourceFlag, &cfg.LLM.APIKeyCmd, "")
 	}
 	if strings.TrimSpace(*args.SystemPrompt) != "" {
-		cfg.SystemPrompt = *args.SystemPrompt
+		config.SetField(sources, "system_prompt", config.SourceFlag, &cfg.SystemPrompt, *args.SystemPrompt)
 	}
 	if *args.MaxStep != 0 {
-		cfg.MaxStep = *args.MaxStep
+		config.SetField(sources, "max_step", config.SourceFlag, &cfg.MaxStep, *args.MaxStep)
+	}
+	if args.TaskTimeout != nil && *args.TaskTimeout != 0 {
+		config.SetField(sources, "task_timeout", config.SourceFlag, &cfg.TaskTimeout, *args.TaskTimeout)
 	}
+	if args.MaxDuration != nil && *args.MaxDuration != 0 {
+		config.SetField(sources, "max_duration", config.SourceFlag, &cfg.MaxDuration, *args.MaxDuration)
+	}
+	return nil
 }
 
 // parseToolsList parses comma-separated tools list into a slice of MCPToolConfig.
-// It handles whitespace trimming and empty string filtering.
-// 工具格式为 "server:tool_name"，如果不包含冒号，则默认使用内置服务器
-func parseToolsList(toolsStr string) []config.MCPToolConfig {
-	if strings.TrimSpace(toolsStr) == "" {
-		return []config.MCPToolConfig{}
+// 工具格式为 "server:tool_name"，如果不包含冒号，则默认使用内置服务器。
+// Malformed entries are reported with their position, duplicates are dropped with a warning.
+func parseToolsList(toolsStr string) ([]config.MCPToolConfig, error) {
+	tools, warnings, err := config.ParseToolSpecs(toolsStr)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgToolsInvalid, err)
 	}
-
-	toolSpecs := strings.Split(toolsStr, defaultToolsSeparator)
-
-	// 构建工具配置列表
-	var toolConfigs []config.MCPToolConfig
-	for _, toolSpec := range toolSpecs {
-		toolSpec = strings.TrimSpace(toolSpec)
-		if toolSpec == "" {
-			continue
-		}
-
-		// 解析服务器和工具名称
-		parts := strings.SplitN(toolSpec, ":", 2)
-		if len(parts) == 2 {
-			// 格式为 "server:tool_name"
-			server := strings.TrimSpace(parts[0])
-			toolName := strings.TrimSpace(parts[1])
-			if server != "" && toolName != "" {
-				toolConfigs = append(toolConfigs, config.MCPToolConfig{
-					Server: server,
-					Name:   toolName,
-				})
-			}
-		} else {
-			// 仅提供工具名称，使用内置服务器
-			toolConfigs = append(toolConfigs, config.MCPToolConfig{
-				Server: config.InnerServerName,
-				Name:   toolSpec,
-			})
-		}
+	for _, warning := range warnings {
+		log.Printf("警告: %s", warning)
 	}
+	return tools, nil
+}
 
-	return toolConfigs
+// saveConfigOptions controls how saveConfigIfNeeded writes the configuration
+type saveConfigOptions struct {
+	Loaded      []byte // 加载时配置文件的内容，文件不存在时为nil
+	Force       bool   // 目标文件与加载的内容不同时仍然覆盖
+	SaveSecrets bool   // 保存API密钥
 }
 
-// saveConfigIfNeeded saves configuration to file if a config file path is provided
-func saveConfigIfNeeded(cfg *config.Config, configFile string) error {
+// saveConfigIfNeeded saves configuration to file if a config file path is provided.
+// An existing file whose content differs from the loaded configuration file,
+// such as another file or one edited in the meantime, is only overwritten
+// with opts.Force. API keys are left out unless opts.SaveSecrets is set.
+func saveConfigIfNeeded(cfg *config.Config, configFile string, opts saveConfigOptions) error {
 	if configFile == "" {
 		return nil
 	}
 
+	if !opts.Force {
+		if current, err := os.ReadFile(configFile); err == nil && !bytes.Equal(current, opts.Loaded) {
+			return fmt.Errorf(errMsgSaveConfigChange, configFile)
+		}
+	}
+	if !opts.SaveSecrets {
+		cfg = cfg.WithoutSecrets()
+	}
+
 	if err := cfg.SaveConfig(configFile); err != nil {
 		return fmt.Errorf(errMsgSaveConfigFailed, err)
 	}
@@ -201,16 +481,9 @@ func saveConfigIfNeeded(cfg *config.Config, configFile string) error {
 	return nil
 }
 
-// setupSignalHandling sets up graceful shutdown on interrupt signals
-func setupSignalHandling(cancel context.CancelFunc) {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		sig := <-sigChan
-		log.Printf("收到信号 %v，正在优雅关闭...", sig)
-		cancel()
-	}()
+// boolValue returns the value of an optional bool flag
+func boolValue(b *bool) bool {
+	return b != nil && *b
 }
 
 // validateTask validates that a task is provided
@@ -221,13 +494,92 @@ func validateTask(task string) error {
 	return nil
 }
 
+// validateTaskArgs validates that either a task or a task template is provided.
+// The template is rendered later, when the database is opened.
+func validateTaskArgs(args *CommandLineArgs) error {
+	task := stringValue(args.Task)
+	template := stringValue(args.Template)
+	hasParams := args.Params != nil && len(*args.Params) > 0
+	switch {
+	case task != "" && template != "":
+		return errors.New(errMsgTemplateAndTask)
+	case template != "":
+		return nil
+	case hasParams:
+		return errors.New(errMsgParamNoTemplate)
+	case task == "" && boolValue(args.ExplainConfig):
+		// 只输出配置时不需要任务
+		return nil
+	}
+	return validateTask(task)
+}
+
+// prepareAttachments copies the attachment files into a temporary directory.
+// It returns an empty directory path if no attachments are given.
+func prepareAttachments(files []string) (string, error) {
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	dir, err := os.MkdirTemp("", "mcpagent-attachments-")
+	if err != nil {
+		return "", fmt.Errorf(errMsgAttachmentFailed, err)
+	}
+	for _, file := range files {
+		if _, err := attachment.CopyFile(dir, file); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf(errMsgAttachmentFailed, err)
+		}
+	}
+	return dir, nil
+}
+
 // runAgent executes the MCP agent with the given configuration and task
-func runAgent(ctx context.Context, cfg *config.Config, task string) error {
-	notify := &mcpagent.CliNotifier{}
+// When an audit log is configured, the events of the task are also written to it,
+// the configured notification sinks receive them as well.
+// The task is printed to stdout in format, a JSON report also when the task fails.
+func runAgent(ctx context.Context, cfg *config.Config, task string, format mcpagent.OutputFormat) (err error) {
+	cli := mcpagent.NewFormattedCliNotifier(format, os.Stdout, os.Stderr)
+	var result *mcpagent.RunResult
+	defer func() {
+		if finishErr := cli.Finish(result, err); finishErr != nil {
+			log.Printf("警告: 输出结果失败: %v", finishErr)
+		}
+	}()
+	taskID := fmt.Sprintf("cli_%d", time.Now().UnixNano())
+	sinks := mcpagent.NewNotificationSinks(cfg.Notifications, taskID)
+	defer sinks.Close()
+	defer func() {
+		// 超时错误已经由mcpagent.Run通知
+		if err != nil && !errors.Is(err, mcpagent.ErrTaskTimeout) {
+			sinks.OnError(err)
+		}
+	}()
+	notify := sinks.Attach(cli)
+
+	logger, err := cfg.Audit.Open()
+	if err != nil {
+		return fmt.Errorf(errMsgAuditOpenFailed, err)
+	}
+	if logger != nil {
+		defer func() {
+			if err := logger.Close(); err != nil {
+				log.Printf("警告: 关闭审计日志失败: %v", err)
+			}
+		}()
+		audited := mcpagent.NewAuditNotify(notify, logger, taskID)
+		notify = audited
+		audited.OnTaskStart(task)
+		defer func() { audited.OnTaskEnd(err) }()
+	}
+
+	if cfg.PlanMode && cfg.PlanApproval {
+		ctx = mcpagent.WithPlanApprover(ctx, terminalPlanApprover(os.Stdin, os.Stderr))
+	}
 
 	log.Printf("开始执行任务: %s", task)
 
-	if err := mcpagent.Run(ctx, cfg, task, notify); err != nil {
+	if result, err = mcpagent.RunWithResult(ctx, cfg, task, notify); err != nil {
 		return fmt.Errorf(errMsgExecutionFailed, err)
 	}
 
@@ -235,26 +587,40 @@ func runAgent(ctx context.Context, cfg *config.Config, task string) error {
 	return nil
 }
 
-// main is the entry point of the application
-func main() {
-	// 解析命令行参数
-	args := parseCommandLineArgs()
-
+// runTask loads the configuration and executes the task given by args
+func runTask(args *CommandLineArgs) error {
 	// 验证任务参数
-	if err := validateTask(*args.Task); err != nil {
-		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
-		flag.Usage()
-		os.Exit(ExitCodeError)
+	if err := validateTaskArgs(args); err != nil {
+		return err
+	}
+	format, err := mcpagent.ParseOutputFormat(stringValue(args.Output))
+	if err != nil {
+		return fmt.Errorf(errMsgOutputInvalid, err)
+	}
+
+	// 在加载配置和连接服务器之前检查工具参数，警告在合并参数时输出
+	if _, _, err := config.ParseToolSpecs(*args.MCPTools); err != nil {
+		return fmt.Errorf(errMsgToolsInvalid, err)
 	}
 
-	// 加载和合并配置
-	cfg, err := loadAndMergeConfig(args)
+	// 加载和合并配置，保存配置时用加载的内容判断文件是否被修改
+	loaded, _ := os.ReadFile(*args.ConfigFile)
+	cfg, sources, err := loadAndMergeConfig(args)
 	if err != nil {
-		log.Fatalf("配置错误: %v", err)
+		return fmt.Errorf("配置错误: %w", err)
 	}
 
-	// 保存配置（如果需要）
-	if err := saveConfigIfNeeded(cfg, "config.yaml"); err != nil {
+	// -explain-config 只输出生效的配置，不保存配置也不执行任务
+	if boolValue(args.ExplainConfig) {
+		return printConfigSources(os.Stdout, cfg, sources)
+	}
+
+	// 仅在指定 -save-config 时保存配置
+	if err := saveConfigIfNeeded(cfg, args.SaveConfig.target(*args.ConfigFile), saveConfigOptions{
+		Loaded:      loaded,
+		Force:       boolValue(args.Force),
+		SaveSecrets: boolValue(args.SaveSecrets),
+	}); err != nil {
 		log.Printf("警告: %v", err)
 	}
 
@@ -262,10 +628,31 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	setupSignalHandling(cancel)
+	interrupts := setupSignalHandling(cancel)
+	defer interrupts.stop()
 
-	// 执行任务
-	if err := runAgent(ctx, cfg, *args.Task); err != nil {
-		log.Fatalf("执行失败: %v", err)
+	// 准备附件
+	attachmentDir, err := prepareAttachments(*args.Attachments)
+	if err != nil {
+		return fmt.Errorf("附件错误: %w", err)
 	}
+	cfg.Attachments.Dir = attachmentDir
+
+	// 执行任务，中断后限时等待MCP服务器关闭，再结束残留的子进程
+	err = interrupts.run(func() error {
+		return runAgent(ctx, cfg, *args.Task, format)
+	})
+	attachment.ScheduleCleanup(attachmentDir, 0)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("任务已中断: %w", err)
+		}
+		return fmt.Errorf("执行失败: %w", err)
+	}
+	return nil
+}
+
+// main is the entry point of the application
+func main() {
+	os.Exit(execute(os.Args[1:], os.Stdout, os.Stderr))
 }
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -72,12 +81,57 @@ func TestParseToolsList(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := parseToolsList(tt.input)
+			result, err := parseToolsList(tt.input)
+			require.NoError(t, err)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
 }
 
+func TestParseToolsListMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "冒号前为空", input: "fetch,:search", want: `第2个工具 ":search" 无效: 冒号前缺少服务器名称`},
+		{name: "缺少工具名称", input: "ddg:", want: `第1个工具 "ddg:" 无效: 缺少工具名称`},
+		{name: "多个冒号", input: "a:b:c", want: `第1个工具 "a:b:c" 无效: 包含多个冒号`},
+		{name: "名称中包含空格", input: "my server:tool", want: `第1个工具 "my server:tool" 无效: 名称中不能包含空白或不可见字符`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseToolsList(tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "-mcp-tools 参数无效")
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestMergeCommandLineArgsInvalidTools(t *testing.T) {
+	cfg := &config.Config{}
+	empty := ""
+	tools := "fetch:fetch,:search"
+	maxStep := 0
+	args := &CommandLineArgs{
+		Proxy:         &empty,
+		MCPConfigFile: &empty,
+		MCPTools:      &tools,
+		LLMType:       &empty,
+		LLMBaseURL:    &empty,
+		LLMModel:      &empty,
+		LLMAPIKey:     &empty,
+		SystemPrompt:  &empty,
+		MaxStep:       &maxStep,
+	}
+	err := mergeCommandLineArgs(cfg, nil, args)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "第2个工具")
+	assert.Empty(t, cfg.MCP.Tools)
+}
+
 func TestValidateTask(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -156,7 +210,7 @@ func TestMergeCommandLineArgs(t *testing.T) {
 	}
 
 	// 合并配置
-	mergeCommandLineArgs(cfg, args)
+	require.NoError(t, mergeCommandLineArgs(cfg, nil, args))
 
 	// 验证结果
 	assert.Equal(t, "new-proxy", cfg.Proxy)
@@ -177,6 +231,12 @@ func TestMergeCommandLineArgs(t *testing.T) {
 	assert.Equal(t, "new-key", cfg.LLM.APIKey)
 	assert.Equal(t, "new-prompt", cfg.SystemPrompt)
 	assert.Equal(t, 20, cfg.MaxStep)
+
+	// 命令行指定的密钥优先于配置文件中的密钥命令
+	cfg.LLM.APIKeyCmd = "pass show llm"
+	require.NoError(t, mergeCommandLineArgs(cfg, nil, args))
+	assert.Equal(t, "new-key", cfg.LLM.APIKey)
+	assert.Empty(t, cfg.LLM.APIKeyCmd)
 }
 
 func TestMergeCommandLineArgsWithEmptyValues(t *testing.T) {
@@ -226,7 +286,7 @@ func TestMergeCommandLineArgsWithEmptyValues(t *testing.T) {
 	}
 
 	// 合并配置
-	mergeCommandLineArgs(cfg, args)
+	require.NoError(t, mergeCommandLineArgs(cfg, nil, args))
 
 	// 验证原始值保持不变
 	assert.Equal(t, "original-proxy", cfg.Proxy)
@@ -273,7 +333,7 @@ func TestSaveConfigIfNeeded(t *testing.T) {
 	}
 
 	// 测试保存配置
-	err := saveConfigIfNeeded(cfg, configFile)
+	err := saveConfigIfNeeded(cfg, configFile, saveConfigOptions{})
 	assert.NoError(t, err)
 
 	// 验证文件是否存在
@@ -281,7 +341,7 @@ func TestSaveConfigIfNeeded(t *testing.T) {
 	assert.NoError(t, err)
 
 	// 测试空配置文件路径
-	err = saveConfigIfNeeded(cfg, "")
+	err = saveConfigIfNeeded(cfg, "", saveConfigOptions{})
 	assert.NoError(t, err)
 }
 
@@ -324,7 +384,7 @@ max_step: 20
 	}
 
 	// 测试加载和合并配置
-	cfg, err := loadAndMergeConfig(args)
+	cfg, _, err := loadAndMergeConfig(args)
 	assert.NoError(t, err)
 	assert.NotNil(t, cfg)
 	assert.Equal(t, "test-proxy", cfg.Proxy) // 命令行参数应该覆盖配置文件
@@ -347,7 +407,7 @@ func TestLoadAndMergeConfigWithInvalidFile(t *testing.T) {
 	}
 
 	// 应该使用默认配置，不会出错
-	cfg, err := loadAndMergeConfig(args)
+	cfg, _, err := loadAndMergeConfig(args)
 	assert.NoError(t, err)
 	assert.NotNil(t, cfg)
 }
@@ -446,3 +506,398 @@ func TestGetTools(t *testing.T) {
 		t.Logf("Tool #%d: %s", i+1, info.Name)
 	}
 }
+
+// TestParseAttachFlag tests that -attach can be repeated
+func TestParseAttachFlag(t *testing.T) {
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	os.Args = []string{"mcphost", "-task", "t", "-attach", "a.txt", "-attach", "b.log"}
+
+	args := parseCommandLineArgs()
+	assert.Equal(t, []string{"a.txt", "b.log"}, []string(*args.Attachments))
+}
+
+// TestPrepareAttachments tests copying attachment files into a temporary directory
+func TestPrepareAttachments(t *testing.T) {
+	dir, err := prepareAttachments(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, dir)
+
+	src := filepath.Join(t.TempDir(), "targets.txt")
+	require.NoError(t, os.WriteFile(src, []byte("1.1.1.1"), 0644))
+
+	dir, err = prepareAttachments([]string{src})
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	data, err := os.ReadFile(filepath.Join(dir, "targets.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1", string(data))
+
+	_, err = prepareAttachments([]string{filepath.Join(t.TempDir(), "missing.txt")})
+	assert.Error(t, err)
+}
+
+// setupSelectionDB creates a sqlite database seeded with the default data and
+// returns its path. The connection is closed so the CLI can open it again.
+func setupSelectionDB(t *testing.T) string {
+	dbPath := filepath.Join(t.TempDir(), "mcpagent.db")
+	require.NoError(t, database.InitDatabase(dbPath))
+	require.NoError(t, database.CloseDatabase())
+	database.DB = nil
+	t.Cleanup(func() { database.DB = nil })
+	return dbPath
+}
+
+func TestApplyDatabaseSelections(t *testing.T) {
+	dbPath := setupSelectionDB(t)
+	llmName := "默认Ollama配置"
+	promptName := "网络安全专家"
+
+	cfg := config.NewDefaultConfig()
+	cfg.SystemPrompt = "original"
+	args := &CommandLineArgs{DBPath: &dbPath, LLMConfigName: &llmName, SystemPromptName: &promptName}
+	require.NoError(t, applyDatabaseSelections(cfg, nil, args))
+	assert.Equal(t, "ollama", cfg.LLM.Type)
+	assert.NotEmpty(t, cfg.LLM.Model)
+	assert.NotEqual(t, "original", cfg.SystemPrompt)
+	assert.NotEmpty(t, cfg.SystemPrompt)
+
+	// 未指定名称时不打开数据库
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	assert.NoError(t, applyDatabaseSelections(config.NewDefaultConfig(), nil, &CommandLineArgs{DBPath: &missing}))
+	assert.NoError(t, applyDatabaseSelections(config.NewDefaultConfig(), nil, &CommandLineArgs{}))
+}
+
+func TestApplyDatabaseSelectionsNotFound(t *testing.T) {
+	dbPath := setupSelectionDB(t)
+	unknown := "不存在的配置"
+
+	err := applyDatabaseSelections(config.NewDefaultConfig(), nil, &CommandLineArgs{DBPath: &dbPath, LLMConfigName: &unknown})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), unknown)
+	assert.Contains(t, err.Error(), "默认Ollama配置")
+
+	err = applyDatabaseSelections(config.NewDefaultConfig(), nil, &CommandLineArgs{DBPath: &dbPath, SystemPromptName: &unknown})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), unknown)
+	assert.Contains(t, err.Error(), "学术研究写作系统提示词")
+	assert.Contains(t, err.Error(), "网络安全专家")
+
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	err = applyDatabaseSelections(config.NewDefaultConfig(), nil, &CommandLineArgs{DBPath: &missing, LLMConfigName: &unknown})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), missing)
+}
+
+func TestLoadAndMergeConfigFlagsOverrideDatabase(t *testing.T) {
+	dbPath := setupSelectionDB(t)
+	llmName := "默认Ollama配置"
+	model := "flag-model"
+	args := &CommandLineArgs{
+		ConfigFile:    new(string),
+		Proxy:         new(string),
+		MCPConfigFile: new(string),
+		MCPTools:      new(string),
+		LLMType:       new(string),
+		LLMBaseURL:    new(string),
+		LLMModel:      &model,
+		LLMAPIKey:     new(string),
+		SystemPrompt:  new(string),
+		MaxStep:       new(int),
+		DBPath:        &dbPath,
+		LLMConfigName: &llmName,
+	}
+
+	cfg, _, err := loadAndMergeConfig(args)
+	require.NoError(t, err)
+	assert.Equal(t, "ollama", cfg.LLM.Type)
+	assert.Equal(t, "flag-model", cfg.LLM.Model)
+}
+
+func TestLoadAndMergeConfigSources(t *testing.T) {
+	dbPath := setupSelectionDB(t)
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("max_step: 12\nllm:\n  type: openai\n  model: file-model\n  api_key: file-secret\n"), 0644))
+	llmName := "默认Ollama配置"
+	model := "flag-model"
+	args := &CommandLineArgs{
+		ConfigFile:    &configPath,
+		Proxy:         new(string),
+		MCPConfigFile: new(string),
+		MCPTools:      new(string),
+		LLMType:       new(string),
+		LLMBaseURL:    new(string),
+		LLMModel:      &model,
+		LLMAPIKey:     new(string),
+		SystemPrompt:  new(string),
+		MaxStep:       new(int),
+		DBPath:        &dbPath,
+		LLMConfigName: &llmName,
+	}
+
+	// 配置文件、数据库和命令行依次覆盖llm.model
+	cfg, sources, err := loadAndMergeConfig(args)
+	require.NoError(t, err)
+	assert.Equal(t, "flag-model", cfg.LLM.Model)
+	assert.Equal(t, config.SourceFlag, sources.Of("llm.model"))
+	assert.Equal(t, "ollama", cfg.LLM.Type)
+	assert.Equal(t, config.SourceDatabase, sources.Of("llm.type"))
+	assert.Equal(t, 12, cfg.MaxStep)
+	assert.Equal(t, config.SourceFile, sources.Of("max_step"))
+	assert.Equal(t, config.SourceDefault, sources.Of("task_timeout"))
+
+	var out bytes.Buffer
+	require.NoError(t, printConfigSources(&out, cfg, sources))
+	assert.Regexp(t, `llm\.model\s+flag\s+"flag-model"`, out.String())
+	assert.Regexp(t, `max_step\s+file\s+12`, out.String())
+	assert.NotContains(t, out.String(), "file-secret")
+}
+
+func TestMergeCommandLineArgsTaskTimeout(t *testing.T) {
+	cfg := &config.Config{TaskTimeout: 60}
+	args := &CommandLineArgs{
+		Proxy:         new(string),
+		MCPConfigFile: new(string),
+		MCPTools:      new(string),
+		LLMType:       new(string),
+		LLMBaseURL:    new(string),
+		LLMModel:      new(string),
+		LLMAPIKey:     new(string),
+		SystemPrompt:  new(string),
+		MaxStep:       new(int),
+	}
+
+	// 未指定-task-timeout时保留配置文件的值
+	require.NoError(t, mergeCommandLineArgs(cfg, nil, args))
+	assert.Equal(t, 60, cfg.TaskTimeout)
+
+	taskTimeout := 600
+	args.TaskTimeout = &taskTimeout
+	require.NoError(t, mergeCommandLineArgs(cfg, nil, args))
+	assert.Equal(t, 600, cfg.TaskTimeout)
+
+	// -max-duration设置时间预算
+	maxDuration := 300
+	args.MaxDuration = &maxDuration
+	require.NoError(t, mergeCommandLineArgs(cfg, nil, args))
+	assert.Equal(t, 300, cfg.MaxDuration)
+}
+
+func TestRunTaskWithoutTools(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+		writeChatCompletion(w, body, "你好")
+	}))
+	defer server.Close()
+
+	// 配置中没有MCP服务器和工具，运行目录中也没有mcpservers.json
+	dir := t.TempDir()
+	t.Chdir(dir)
+	configFile := filepath.Join(dir, "task.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+llm:
+  type: openai
+  base_url: `+server.URL+`
+  model: test-model
+  api_key: test-key
+max_step: 5
+`), 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := execute([]string{"run", "-config", configFile, "-task", "你好"}, &stdout, &stderr)
+	assert.Equal(t, ExitCodeSuccess, code, stderr.String())
+
+	// 纯对话任务不向模型发送工具
+	require.Len(t, requests, 1)
+	assert.NotContains(t, requests[0], "tools")
+}
+
+// seedSelectionTemplate stores a task template in the database at dbPath
+func seedSelectionTemplate(t *testing.T, dbPath string) {
+	require.NoError(t, database.InitDatabase(dbPath))
+	defer func() {
+		require.NoError(t, database.CloseDatabase())
+		database.DB = nil
+	}()
+
+	template := &models.TaskTemplateModel{Name: "域名侦察", Template: "收集{domain}的子域名，深度{depth}"}
+	require.NoError(t, template.SetParameters([]models.TaskTemplateParam{
+		{Name: "domain", Required: true},
+		{Name: "depth", Type: models.TemplateParamTypeInteger, Default: 1},
+	}))
+	require.NoError(t, template.SetTools([]models.MCPToolConfig{{Server: "fetch", Name: "fetch"}}))
+	require.NoError(t, services.NewTaskTemplateService().CreateTemplate(template))
+}
+
+func TestApplyDatabaseSelectionsTemplate(t *testing.T) {
+	dbPath := setupSelectionDB(t)
+	seedSelectionTemplate(t, dbPath)
+	name := "域名侦察"
+
+	cfg := config.NewDefaultConfig()
+	args := &CommandLineArgs{DBPath: &dbPath, Template: &name, Params: &stringSliceFlag{"domain=example.com", "depth=3"}}
+	require.NoError(t, applyDatabaseSelections(cfg, nil, args))
+	assert.Equal(t, "收集example.com的子域名，深度3", *args.Task)
+	assert.Equal(t, []config.MCPToolConfig{{Server: "fetch", Name: "fetch"}}, cfg.MCP.Tools)
+
+	// 缺少和多余的参数都会列出
+	args = &CommandLineArgs{DBPath: &dbPath, Template: &name, Params: &stringSliceFlag{"target=example.com"}}
+	err := applyDatabaseSelections(config.NewDefaultConfig(), nil, args)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "缺少参数 domain")
+	assert.Contains(t, err.Error(), "多余参数 target")
+
+	unknown := "不存在的模板"
+	err = applyDatabaseSelections(config.NewDefaultConfig(), nil, &CommandLineArgs{DBPath: &dbPath, Template: &unknown})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), unknown)
+	assert.Contains(t, err.Error(), name)
+}
+
+func TestParseTemplateParams(t *testing.T) {
+	params, err := parseTemplateParams([]string{"domain=example.com", "query=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"domain": "example.com", "query": "a=b", "empty": ""}, params)
+
+	_, err = parseTemplateParams([]string{"domain"})
+	assert.Error(t, err)
+	_, err = parseTemplateParams([]string{"=value"})
+	assert.Error(t, err)
+	_, err = parseTemplateParams([]string{"a=1", "a=2"})
+	assert.Error(t, err)
+}
+
+func TestValidateTaskArgs(t *testing.T) {
+	task, template := "测试任务", "域名侦察"
+	empty := ""
+
+	assert.NoError(t, validateTaskArgs(&CommandLineArgs{Task: &task}))
+	assert.NoError(t, validateTaskArgs(&CommandLineArgs{Task: &empty, Template: &template, Params: &stringSliceFlag{"a=1"}}))
+	assert.Error(t, validateTaskArgs(&CommandLineArgs{Task: &task, Template: &template}))
+	assert.Error(t, validateTaskArgs(&CommandLineArgs{Task: &task, Params: &stringSliceFlag{"a=1"}}))
+	assert.Error(t, validateTaskArgs(&CommandLineArgs{Task: &empty}))
+}
+
+// writeChatCompletion answers an OpenAI compatible chat request with content,
+// as a stream of chunks if the request asks for streaming
+func writeChatCompletion(w http.ResponseWriter, body map[string]any, content string) {
+	if stream, _ := body["stream"].(bool); stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []map[string]any{
+			{"index": 0, "delta": map[string]any{"role": "assistant", "content": content}},
+			{"index": 0, "delta": map[string]any{}, "finish_reason": "stop"},
+		} {
+			data, _ := json.Marshal(map[string]any{"id": "chatcmpl-test", "object": "chat.completion.chunk", "choices": []map[string]any{chunk}})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"id":     "chatcmpl-test",
+		"object": "chat.completion",
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       map[string]any{"role": "assistant", "content": content},
+			"finish_reason": "stop",
+		}},
+	})
+}
+
+// fakeChatServer returns an OpenAI compatible server answering every request with "你好"
+func fakeChatServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		writeChatCompletion(w, body, "你好")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunTaskOutputInvalid(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := execute([]string{"run", "-task", "你好", "-output", "html"}, &stdout, &stderr)
+	assert.Equal(t, ExitCodeError, code)
+	assert.Contains(t, stderr.String(), "-output 参数无效")
+}
+
+func TestRunTaskSaveConfig(t *testing.T) {
+	server := fakeChatServer(t)
+	dir := t.TempDir()
+	t.Chdir(dir)
+	configFile := filepath.Join(dir, "task.yaml")
+	content := []byte(`# 用户的注释
+llm:
+  type: openai
+  base_url: ` + server.URL + `
+  model: test-model
+max_step: 5
+`)
+	require.NoError(t, os.WriteFile(configFile, content, 0640))
+
+	run := func(args ...string) {
+		var stdout, stderr bytes.Buffer
+		code := execute(append([]string{"run", "-config", configFile, "-llm-api-key", "cli-key", "-task", "你好"}, args...), &stdout, &stderr)
+		require.Equal(t, ExitCodeSuccess, code, stderr.String())
+	}
+
+	// 不指定 -save-config 时不写入任何文件
+	run()
+	saved, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, content, saved)
+	assert.NoFileExists(t, filepath.Join(dir, "config.yaml"))
+
+	// 保存到指定文件，不包含命令行提供的API密钥
+	outFile := filepath.Join(dir, "out.yaml")
+	run("-save-config=" + outFile)
+	saved, err = os.ReadFile(outFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(saved), "test-model")
+	assert.NotContains(t, string(saved), "cli-key")
+	info, err := os.Stat(outFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// 目标文件与加载的配置文件不同时不覆盖
+	require.NoError(t, os.WriteFile(outFile, []byte("max_step: 3\n"), 0644))
+	run("-save-config=" + outFile)
+	saved, err = os.ReadFile(outFile)
+	require.NoError(t, err)
+	assert.Equal(t, "max_step: 3\n", string(saved))
+	run("-save-config="+outFile, "-force")
+	saved, err = os.ReadFile(outFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(saved), "test-model")
+
+	// 不带路径时保存到 -config 文件，保留文件权限；-save-secrets 保存API密钥
+	run("-save-config", "-save-secrets")
+	saved, err = os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(saved), "cli-key")
+	info, err = os.Stat(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestSaveConfigFlag(t *testing.T) {
+	parse := func(arguments ...string) *CommandLineArgs {
+		fs := flag.NewFlagSet("run", flag.ContinueOnError)
+		args := registerRunFlags(fs)
+		require.NoError(t, fs.Parse(arguments))
+		return args
+	}
+
+	assert.Empty(t, parse().SaveConfig.target("config.yaml"))
+	assert.Equal(t, "config.yaml", parse("-save-config").SaveConfig.target("config.yaml"))
+	assert.Equal(t, "out.yaml", parse("-save-config=out.yaml").SaveConfig.target("config.yaml"))
+	assert.Empty(t, parse("-save-config=false").SaveConfig.target("config.yaml"))
+
+	var missing *saveConfigFlag
+	assert.Empty(t, missing.target("config.yaml"))
+}
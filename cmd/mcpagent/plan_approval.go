@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+)
+
+// planApprovalPrompt is printed after the execution plan when plan_approval is enabled
+const planApprovalPrompt = "是否按此计划执行？[y/N] "
+
+// terminalPlanApprover asks on out whether to execute the plan, which the
+// notifier has already printed, and reads the answer from in. Anything but
+// y or yes rejects the plan, the reason may follow the answer, e.g.
+// "n 不要扫描端口". The read is abandoned when ctx is done.
+func terminalPlanApprover(in io.Reader, out io.Writer) mcpagent.PlanApprover {
+	reader := bufio.NewReader(in)
+	return func(ctx context.Context, plan string) (mcpagent.PlanDecision, error) {
+		fmt.Fprint(out, planApprovalPrompt)
+
+		lines := make(chan string, 1)
+		errs := make(chan error, 1)
+		go func() {
+			line, err := reader.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				errs <- err
+				return
+			}
+			lines <- line
+		}()
+
+		select {
+		case <-ctx.Done():
+			return mcpagent.PlanDecision{}, ctx.Err()
+		case err := <-errs:
+			return mcpagent.PlanDecision{}, fmt.Errorf("读取批准结果失败: %w", err)
+		case line := <-lines:
+			return parsePlanAnswer(line), nil
+		}
+	}
+}
+
+// parsePlanAnswer parses a line such as "y", "yes 先查DNS" or "n 不要扫描端口"
+func parsePlanAnswer(line string) mcpagent.PlanDecision {
+	answer, feedback, _ := strings.Cut(strings.TrimSpace(line), " ")
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return mcpagent.PlanDecision{Approved: true, Feedback: strings.TrimSpace(feedback)}
+	case "n", "no":
+		return mcpagent.PlanDecision{Feedback: strings.TrimSpace(feedback)}
+	default:
+		return mcpagent.PlanDecision{}
+	}
+}
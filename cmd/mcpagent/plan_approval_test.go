@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlanAnswer(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected mcpagent.PlanDecision
+	}{
+		{"y\n", mcpagent.PlanDecision{Approved: true}},
+		{" YES  先查DNS \n", mcpagent.PlanDecision{Approved: true, Feedback: "先查DNS"}},
+		{"n 不要扫描端口", mcpagent.PlanDecision{Feedback: "不要扫描端口"}},
+		{"\n", mcpagent.PlanDecision{}},
+		{"好的", mcpagent.PlanDecision{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, parsePlanAnswer(tt.line), tt.line)
+	}
+}
+
+func TestTerminalPlanApprover(t *testing.T) {
+	t.Run("读取回答", func(t *testing.T) {
+		var out bytes.Buffer
+		approver := terminalPlanApprover(strings.NewReader("y 用中文回答"), &out)
+
+		decision, err := approver(context.Background(), "1. 回答问题")
+		require.NoError(t, err)
+		assert.Equal(t, mcpagent.PlanDecision{Approved: true, Feedback: "用中文回答"}, decision)
+		assert.Equal(t, planApprovalPrompt, out.String())
+	})
+
+	t.Run("输入结束时失败", func(t *testing.T) {
+		approver := terminalPlanApprover(strings.NewReader(""), io.Discard)
+		_, err := approver(context.Background(), "1. 回答问题")
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("任务取消时不再等待", func(t *testing.T) {
+		reader, writer := io.Pipe()
+		defer writer.Close()
+		approver := terminalPlanApprover(reader, io.Discard)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := approver(ctx, "1. 回答问题")
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,133 @@
+// Package main is a minimal MCP server speaking stdio, used by the
+// integration tests through the testmcp package.
+//
+// The server provides two tools:
+//   - echo: returns its arguments as JSON
+//   - pid: returns the process ID, so tests can tell whether a connection was reused
+//
+// Flags simulate misbehaving servers:
+//   - -start-delay: wait before answering the initialize request
+//   - -crash-after: exit after answering that many tool calls
+//   - -stderr-noise: write that many lines to stderr at start and for every call
+//   - -slow-pings, -ping-delay: answer the first pings late, like a busy server
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// crashExitCode is the exit code of a simulated crash
+const crashExitCode = 3
+
+// crashDelay leaves the transport time to write the last response before the crash
+const crashDelay = 50 * time.Millisecond
+
+var (
+	startDelay  = flag.Duration("start-delay", 0, "启动后等待多久再处理请求")
+	crashAfter  = flag.Int("crash-after", 0, "完成多少次工具调用后退出进程，0表示不退出")
+	stderrNoise = flag.Int("stderr-noise", 0, "启动时和每次工具调用时向stderr输出的行数")
+	slowPings   = flag.Int("slow-pings", 0, "前多少次ping请求延迟响应")
+	pingDelay   = flag.Duration("ping-delay", time.Second, "延迟响应的ping请求等待的时间")
+)
+
+// callCounter counts tool calls and simulates the crash
+type callCounter struct {
+	mu    sync.Mutex
+	calls int
+}
+
+// done records a finished call and exits the process once crash-after calls have been answered
+func (c *callCounter) done() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if *crashAfter > 0 && c.calls >= *crashAfter {
+		time.AfterFunc(crashDelay, func() { os.Exit(crashExitCode) })
+	}
+}
+
+// pingCounter delays the first slow-pings pings. stdio servers handle pings
+// in the read loop, so the requests after a slow ping wait for it as well.
+type pingCounter struct {
+	mu    sync.Mutex
+	pings int
+}
+
+// before waits ping-delay for the first slow-pings pings
+func (p *pingCounter) before(ctx context.Context, id any, message *mcp.PingRequest) {
+	p.mu.Lock()
+	p.pings++
+	slow := p.pings <= *slowPings
+	p.mu.Unlock()
+	if slow {
+		time.Sleep(*pingDelay)
+	}
+}
+
+// noise writes n diagnostic lines to stderr
+func noise(n int, event string) {
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(os.Stderr, "fakeserver %s noise %d\n", event, i)
+	}
+}
+
+func main() {
+	flag.Parse()
+	noise(*stderrNoise, "start")
+	if *startDelay > 0 {
+		time.Sleep(*startDelay)
+	}
+
+	counter := &callCounter{}
+	hooks := &server.Hooks{}
+	hooks.AddBeforePing((&pingCounter{}).before)
+	s := server.NewMCPServer("fakeserver", "1.0.0", server.WithToolCapabilities(false), server.WithHooks(hooks))
+
+	s.AddTool(mcp.NewTool("echo",
+		mcp.WithDescription("返回调用参数"),
+		mcp.WithString("message", mcp.Description("要返回的消息")),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		defer counter.done()
+		noise(*stderrNoise, "echo")
+		data, err := json.Marshal(request.GetArguments())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(string(data)), nil
+	})
+
+	s.AddTool(mcp.NewTool("pid",
+		mcp.WithDescription("返回服务器进程ID"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		defer counter.done()
+		noise(*stderrNoise, "pid")
+		return mcp.NewToolResultText(strconv.Itoa(os.Getpid())), nil
+	})
+
+	s.AddTool(mcp.NewTool("cwd",
+		mcp.WithDescription("返回服务器进程的工作目录"),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		defer counter.done()
+		noise(*stderrNoise, "cwd")
+		dir, err := os.Getwd()
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(dir), nil
+	})
+
+	if err := server.ServeStdio(s); err != nil {
+		log.Fatalf("MCP服务器运行失败: %v", err)
+	}
+}
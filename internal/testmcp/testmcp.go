@@ -0,0 +1,98 @@
+// Package testmcp builds the fake MCP stdio server of fakeserver for
+// integration tests, so that hubs, pools and tool invocations can be tested
+// against a real MCP process instead of mocks.
+//
+// The tests using it start processes and compile a binary, they are skipped
+// with go test -short.
+package testmcp
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+// fakeServerPackage is the import path of the fake MCP server
+const fakeServerPackage = "github.com/LubyRuffy/mcpagent/internal/testmcp/fakeserver"
+
+// Options configures the behaviour of a fake server process
+type Options struct {
+	StartDelay  string // 启动延迟，如 "500ms"
+	CrashAfter  int    // 完成多少次工具调用后退出，0表示不退出
+	StderrNoise int    // 启动时和每次调用时向stderr输出的行数
+	SlowPings   int    // 前多少次ping请求延迟响应
+	PingDelay   string // 延迟响应的ping请求等待的时间，默认1s
+}
+
+// args returns the command line flags of the options
+func (o Options) args() []string {
+	var args []string
+	if o.StartDelay != "" {
+		args = append(args, "-start-delay", o.StartDelay)
+	}
+	if o.CrashAfter > 0 {
+		args = append(args, "-crash-after", strconv.Itoa(o.CrashAfter))
+	}
+	if o.StderrNoise > 0 {
+		args = append(args, "-stderr-noise", strconv.Itoa(o.StderrNoise))
+	}
+	if o.SlowPings > 0 {
+		args = append(args, "-slow-pings", strconv.Itoa(o.SlowPings))
+	}
+	if o.PingDelay != "" {
+		args = append(args, "-ping-delay", o.PingDelay)
+	}
+	return args
+}
+
+// Build compiles the fake server into a temporary directory of t and returns
+// the path of the binary. Call it once in the top level test and share the
+// binary between subtests, compiling takes a few seconds.
+//
+// The test is skipped with -short or when the go command is not available.
+//
+// Parameters:
+//   - t: Test owning the binary, which is removed after it
+//
+// Returns:
+//   - string: Path of the fake server binary
+func Build(t testing.TB) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("跳过集成测试: -short")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("跳过集成测试: 找不到go命令")
+	}
+
+	binary := filepath.Join(t.TempDir(), "fakeserver")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	output, err := exec.Command(goCmd, "build", "-o", binary, fakeServerPackage).CombinedOutput()
+	if err != nil {
+		t.Fatalf("编译模拟MCP服务器失败: %v\n%s", err, output)
+	}
+	return binary
+}
+
+// Server returns the stdio configuration starting binary with opts
+func Server(binary string, opts Options) *mcphost.ServerConfig {
+	return &mcphost.ServerConfig{
+		TransportType: mcphost.TransportTypeStdio,
+		Command:       binary,
+		Args:          opts.args(),
+	}
+}
+
+// Settings returns hub settings with a single fake server called name
+func Settings(name, binary string, opts Options) *mcphost.MCPSettings {
+	return &mcphost.MCPSettings{
+		MCPServers: map[string]*mcphost.ServerConfig{name: Server(binary, opts)},
+	}
+}
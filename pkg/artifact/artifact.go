@@ -0,0 +1,161 @@
+// Package artifact persists binary content returned by tools, such as images
+// and embedded resources. Artifacts are stored flat inside a per-task directory
+// and the agent only sees a short artifact://<id> placeholder instead of the blob.
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// URIScheme prefixes the placeholders returned to the agent
+	URIScheme = "artifact://"
+
+	// DefaultMaxSize is the default maximum size of a single artifact
+	DefaultMaxSize = 50 * 1024 * 1024
+
+	// DefaultMimeType is used when a tool does not report the MIME type of its content
+	DefaultMimeType = "application/octet-stream"
+)
+
+var (
+	ErrInvalidID    = errors.New("产物ID无效")
+	ErrNotFound     = errors.New("产物不存在")
+	ErrFileTooLarge = errors.New("产物大小超过限制")
+)
+
+// idPattern matches the IDs generated by Store.Save
+var idPattern = regexp.MustCompile(`^artifact_[0-9]+_[0-9]+$`)
+
+// idCounter keeps IDs unique when several artifacts are saved in the same nanosecond
+var idCounter atomic.Uint64
+
+// Artifact describes a stored tool output
+type Artifact struct {
+	ID        string    // 产物ID，同时是文件名
+	TaskID    string    // 所属任务ID
+	ToolName  string    // 产生该产物的工具
+	MimeType  string    // 内容类型
+	Size      int64     // 大小（字节）
+	Path      string    // 文件路径
+	CreatedAt time.Time // 创建时间
+}
+
+// Recorder persists the metadata of saved artifacts, e.g. as database rows
+type Recorder interface {
+	RecordArtifact(a *Artifact) error
+}
+
+// Store saves the artifacts of one task into its directory
+type Store struct {
+	dir      string
+	taskID   string
+	maxSize  int
+	recorder Recorder
+}
+
+// NewStore creates a store for the artifacts of a task.
+//
+// Parameters:
+//   - dir: Task artifact directory, created on the first save
+//   - taskID: ID of the task the artifacts belong to
+//   - maxSize: Maximum size of a single artifact, DefaultMaxSize if <= 0
+//   - recorder: Metadata recorder, may be nil
+//
+// Returns:
+//   - *Store: Store ready for use
+func NewStore(dir, taskID string, maxSize int, recorder Recorder) *Store {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Store{dir: dir, taskID: taskID, maxSize: maxSize, recorder: recorder}
+}
+
+// Dir returns the task artifact directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save writes data as a new artifact and records its metadata.
+// A failing recorder is logged but does not fail the save, so the agent still
+// receives the placeholder.
+//
+// Parameters:
+//   - toolName: Name of the tool that returned the content
+//   - mimeType: MIME type of the content, DefaultMimeType if empty
+//   - data: Decoded content
+//
+// Returns:
+//   - *Artifact: Stored artifact
+//   - error: Error if the content is too large or writing fails
+func (s *Store) Save(toolName, mimeType string, data []byte) (*Artifact, error) {
+	if len(data) > s.maxSize {
+		return nil, fmt.Errorf("%w: %d 字节", ErrFileTooLarge, len(data))
+	}
+	if mimeType == "" {
+		mimeType = DefaultMimeType
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建产物目录失败: %w", err)
+	}
+
+	id := fmt.Sprintf("artifact_%d_%d", time.Now().UnixNano(), idCounter.Add(1))
+	a := &Artifact{
+		ID:        id,
+		TaskID:    s.taskID,
+		ToolName:  toolName,
+		MimeType:  mimeType,
+		Size:      int64(len(data)),
+		Path:      filepath.Join(s.dir, id),
+		CreatedAt: time.Now(),
+	}
+	if err := os.WriteFile(a.Path, data, 0644); err != nil {
+		return nil, fmt.Errorf("保存产物失败: %w", err)
+	}
+
+	if s.recorder != nil {
+		if err := s.recorder.RecordArtifact(a); err != nil {
+			log.Printf("记录产物元数据失败 %s: %v", id, err)
+		}
+	}
+	return a, nil
+}
+
+// ValidateID checks that id was generated by Store.Save, which also prevents path traversal
+func ValidateID(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("%w: %s", ErrInvalidID, id)
+	}
+	return nil
+}
+
+// Path returns the file of an artifact inside dir.
+//
+// Returns:
+//   - string: Artifact file path
+//   - error: ErrInvalidID if id is not valid, ErrNotFound if the file does not exist
+func Path(dir, id string) (string, error) {
+	if err := ValidateID(id); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, id)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return "", fmt.Errorf("读取产物失败: %w", err)
+	}
+	return path, nil
+}
+
+// Placeholder returns the text the agent receives instead of the artifact content
+func Placeholder(a *Artifact) string {
+	return fmt.Sprintf("%s%s (%s, %d 字节)", URIScheme, a.ID, a.MimeType, a.Size)
+}
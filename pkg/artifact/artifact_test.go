@@ -0,0 +1,124 @@
+package artifact
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCaller returns a fixed tool result and records the request
+type fakeCaller struct {
+	result  *mcp.CallToolResult
+	request mcp.CallToolRequest
+}
+
+func (c *fakeCaller) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	c.request = request
+	return c.result, nil
+}
+
+// infoTool provides the tool info of a wrapped tool
+type infoTool struct{}
+
+func (infoTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "screenshot", Desc: "截图"}, nil
+}
+
+// recorderFunc adapts a function to Recorder
+type recorderFunc func(a *Artifact) error
+
+func (f recorderFunc) RecordArtifact(a *Artifact) error {
+	return f(a)
+}
+
+var pngData = []byte("\x89PNG\r\n\x1a\nfake image")
+
+func TestWrapToolStoresImageContent(t *testing.T) {
+	var recorded []*Artifact
+	dir := filepath.Join(t.TempDir(), "task_1")
+	store := NewStore(dir, "task_1", 0, recorderFunc(func(a *Artifact) error {
+		recorded = append(recorded, a)
+		return nil
+	}))
+
+	caller := &fakeCaller{result: &mcp.CallToolResult{Content: []mcp.Content{
+		mcp.NewTextContent("页面截图如下"),
+		mcp.NewImageContent(base64.StdEncoding.EncodeToString(pngData), "image/png"),
+		mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "file:///report.txt", Text: "长文本"}),
+	}}}
+	wrapped := WrapTool(infoTool{}, caller, "screenshot", store)
+
+	info, err := wrapped.Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "screenshot", info.Name)
+
+	out, err := wrapped.InvokableRun(context.Background(), `{"url":"https://example.com"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "screenshot", caller.request.Params.Name)
+	assert.Equal(t, map[string]any{"url": "https://example.com"}, caller.request.Params.Arguments)
+
+	require.Len(t, recorded, 2)
+	image, resource := recorded[0], recorded[1]
+	assert.Equal(t, "task_1", image.TaskID)
+	assert.Equal(t, "screenshot", image.ToolName)
+	assert.Equal(t, "image/png", image.MimeType)
+	assert.Equal(t, int64(len(pngData)), image.Size)
+	assert.Equal(t, "text/plain", resource.MimeType)
+
+	lines := strings.Split(out, "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "页面截图如下", lines[0])
+	assert.Equal(t, "artifact://"+image.ID+" (image/png, 18 字节)", lines[1])
+	assert.NotContains(t, out, base64.StdEncoding.EncodeToString(pngData), "图片内容不进入对话")
+
+	path, err := Path(dir, image.ID)
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, pngData, data)
+}
+
+func TestWrapToolErrors(t *testing.T) {
+	store := NewStore(t.TempDir(), "task_1", 4, nil)
+
+	caller := &fakeCaller{result: &mcp.CallToolResult{Content: []mcp.Content{
+		mcp.NewImageContent(base64.StdEncoding.EncodeToString(pngData), "image/png"),
+	}}}
+	_, err := WrapTool(infoTool{}, caller, "screenshot", store).InvokableRun(context.Background(), "")
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+
+	caller.result = &mcp.CallToolResult{Content: []mcp.Content{mcp.NewImageContent("不是base64", "image/png")}}
+	_, err = WrapTool(infoTool{}, caller, "screenshot", store).InvokableRun(context.Background(), "")
+	assert.ErrorContains(t, err, "base64")
+
+	caller.result = &mcp.CallToolResult{IsError: true, Content: []mcp.Content{mcp.NewTextContent("页面不存在")}}
+	_, err = WrapTool(infoTool{}, caller, "screenshot", store).InvokableRun(context.Background(), "")
+	assert.ErrorContains(t, err, "工具调用错误")
+}
+
+func TestWrapToolWithoutStoreRejectsImages(t *testing.T) {
+	caller := &fakeCaller{result: &mcp.CallToolResult{Content: []mcp.Content{
+		mcp.NewImageContent(base64.StdEncoding.EncodeToString(pngData), "image/png"),
+	}}}
+	_, err := WrapTool(infoTool{}, caller, "screenshot", nil).InvokableRun(context.Background(), "")
+	assert.ErrorContains(t, err, "不支持的内容类型")
+}
+
+func TestPathValidatesID(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"", "../secret", "artifact_1_2/../x", "other"} {
+		_, err := Path(dir, id)
+		assert.ErrorIs(t, err, ErrInvalidID, id)
+	}
+
+	_, err := Path(dir, "artifact_1_2")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,139 @@
+package artifact
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultTextMimeType is used for text resources without a MIME type
+	defaultTextMimeType = "text/plain"
+)
+
+// ToolCaller calls tools on an MCP server; client.MCPClient implements it
+type ToolCaller interface {
+	CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+}
+
+// artifactTool calls an MCP tool directly so that image and resource contents
+// can be stored as artifacts instead of being rejected
+type artifactTool struct {
+	tool.BaseTool
+	caller   ToolCaller
+	toolName string
+	store    *Store
+}
+
+// WrapTool returns a tool with the schema of t that calls toolName through caller
+// and stores image, audio and embedded resource contents in store.
+//
+// Parameters:
+//   - t: MCP tool providing the tool info
+//   - caller: Caller of the MCP server the tool belongs to, applying the call timeout
+//   - toolName: Name of the tool on the MCP server
+//   - store: Artifact store of the current task, nil to reject non-text contents
+//
+// Returns:
+//   - tool.InvokableTool: Tool returning text with artifact placeholders
+func WrapTool(t tool.BaseTool, caller ToolCaller, toolName string, store *Store) tool.InvokableTool {
+	return &artifactTool{BaseTool: t, caller: caller, toolName: toolName, store: store}
+}
+
+// Info returns the info of the wrapped tool
+func (t *artifactTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.BaseTool.Info(ctx)
+}
+
+// InvokableRun calls the MCP tool and converts its contents to text
+func (t *artifactTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var params map[string]any
+	if strings.TrimSpace(argumentsInJSON) != "" {
+		if err := json.Unmarshal([]byte(argumentsInJSON), &params); err != nil {
+			return "", fmt.Errorf("解析工具参数失败: %w", err)
+		}
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = t.toolName
+	req.Params.Arguments = params
+
+	result, err := t.caller.CallTool(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("调用工具 %s 失败: %w", t.toolName, err)
+	}
+	if result.IsError {
+		errMsg := "未知错误"
+		if len(result.Content) > 0 {
+			errMsg = fmt.Sprintf("%v", result.Content[0])
+		}
+		return "", fmt.Errorf("MCP: 工具调用错误: %s", errMsg)
+	}
+	if len(result.Content) == 0 {
+		return "", fmt.Errorf("MCP: 工具调用 %s 返回空内容", t.toolName)
+	}
+
+	parts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		text, err := t.contentText(content)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// contentText returns the text of a content item, storing binary contents as
+// artifacts; they are rejected without a store
+func (t *artifactTool) contentText(content mcp.Content) (string, error) {
+	if c, ok := content.(mcp.TextContent); ok {
+		return c.Text, nil
+	}
+	if t.store == nil {
+		return "", fmt.Errorf("MCP: 工具调用 %s 返回不支持的内容类型: %T", t.toolName, content)
+	}
+	switch c := content.(type) {
+	case mcp.ImageContent:
+		return t.saveBase64(c.MIMEType, c.Data)
+	case mcp.AudioContent:
+		return t.saveBase64(c.MIMEType, c.Data)
+	case mcp.EmbeddedResource:
+		switch r := c.Resource.(type) {
+		case mcp.BlobResourceContents:
+			return t.saveBase64(r.MIMEType, r.Blob)
+		case mcp.TextResourceContents:
+			mimeType := r.MIMEType
+			if mimeType == "" {
+				mimeType = defaultTextMimeType
+			}
+			return t.save(mimeType, []byte(r.Text))
+		}
+		return "", fmt.Errorf("MCP: 工具调用 %s 返回不支持的资源类型: %T", t.toolName, c.Resource)
+	}
+	return "", fmt.Errorf("MCP: 工具调用 %s 返回不支持的内容类型: %T", t.toolName, content)
+}
+
+// saveBase64 decodes base64 data and stores it as an artifact
+func (t *artifactTool) saveBase64(mimeType, data string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("MCP: 工具调用 %s 返回的内容不是有效的base64: %w", t.toolName, err)
+	}
+	return t.save(mimeType, decoded)
+}
+
+// save stores data as an artifact and returns its placeholder
+func (t *artifactTool) save(mimeType string, data []byte) (string, error) {
+	a, err := t.store.Save(t.toolName, mimeType, data)
+	if err != nil {
+		return "", err
+	}
+	return Placeholder(a), nil
+}
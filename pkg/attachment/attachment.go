@@ -0,0 +1,220 @@
+// Package attachment manages files attached to an agent task.
+// Attachments are stored flat inside a per-task directory and exposed to the
+// agent through the {attachments} placeholder and the read_attachment tool.
+package attachment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxReadSize is the default number of bytes returned by one read_attachment call
+	DefaultMaxReadSize = 64 * 1024
+
+	// MaxFileSize is the maximum size of a single attachment
+	MaxFileSize = 10 * 1024 * 1024
+
+	// NoAttachments is the {attachments} placeholder value when a task has no attachments
+	NoAttachments = "无"
+
+	// truncatedNotice is appended when read_attachment cuts the content
+	truncatedNotice = "\n...[附件内容过长，已截断，总大小 %d 字节]"
+)
+
+var (
+	ErrInvalidName  = errors.New("附件名称无效")
+	ErrNotFound     = errors.New("附件不存在")
+	ErrFileTooLarge = errors.New("附件大小超过限制")
+)
+
+// ValidateName checks that name is a plain file name. Names containing path
+// separators, "..", or that are absolute are rejected to prevent path traversal.
+//
+// Parameters:
+//   - name: Attachment name provided by the user
+//
+// Returns:
+//   - error: ErrInvalidName wrapped with details if the name is not acceptable
+func ValidateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: 名称为空", ErrInvalidName)
+	}
+	if name == "." || name == ".." || strings.Contains(name, "..") {
+		return fmt.Errorf("%w: %s", ErrInvalidName, name)
+	}
+	if strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, 0) {
+		return fmt.Errorf("%w: %s", ErrInvalidName, name)
+	}
+	if filepath.IsAbs(name) || filepath.Base(name) != name || filepath.VolumeName(name) != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// Save writes an attachment into dir, creating dir if necessary.
+//
+// Parameters:
+//   - dir: Task attachment directory
+//   - name: Attachment name, validated with ValidateName
+//   - data: Attachment content
+//
+// Returns:
+//   - error: Error if the name is invalid, the file is too large or writing fails
+func Save(dir, name string, data []byte) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if len(data) > MaxFileSize {
+		return fmt.Errorf("%w: %s", ErrFileTooLarge, name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建附件目录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return fmt.Errorf("保存附件失败: %w", err)
+	}
+	return nil
+}
+
+// SaveFrom copies at most MaxFileSize bytes from r into dir under name.
+func SaveFrom(dir, name string, r io.Reader) error {
+	// 多读一个字节用于判断是否超出限制
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return fmt.Errorf("读取附件失败: %w", err)
+	}
+	return Save(dir, name, data)
+}
+
+// CopyFile copies a local file into dir using its base name as attachment name.
+//
+// Returns:
+//   - string: Attachment name
+//   - error: Error if the source cannot be read or saved
+func CopyFile(dir, srcPath string) (string, error) {
+	name := filepath.Base(srcPath)
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("打开附件文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if err := SaveFrom(dir, name, f); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// List returns the sorted attachment names in dir.
+// A missing directory is treated as no attachments.
+func List(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Placeholder returns the value of the {attachments} placeholder for dir.
+func Placeholder(dir string) string {
+	names, err := List(dir)
+	if err != nil {
+		log.Printf("列出附件失败: %v", err)
+		return NoAttachments
+	}
+	if len(names) == 0 {
+		return NoAttachments
+	}
+	return strings.Join(names, ", ")
+}
+
+// Read returns the content of an attachment, truncated to maxSize bytes.
+//
+// Parameters:
+//   - dir: Task attachment directory
+//   - name: Attachment name
+//   - maxSize: Maximum number of bytes to return, DefaultMaxReadSize if <= 0
+//
+// Returns:
+//   - string: Attachment content, with a notice appended if truncated
+//   - error: Error if the name is invalid or the attachment does not exist
+func Read(dir, name string, maxSize int) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxReadSize
+	}
+
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return "", fmt.Errorf("读取附件失败: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("读取附件失败: %w", err)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, int64(maxSize)))
+	if err != nil {
+		return "", fmt.Errorf("读取附件失败: %w", err)
+	}
+	if info.Size() <= int64(maxSize) {
+		return string(data), nil
+	}
+
+	// 避免在多字节字符中间截断
+	for i := 0; i < utf8.UTFMax-1 && len(data) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size != 1 {
+			break
+		}
+		data = data[:len(data)-1]
+	}
+	return string(data) + fmt.Sprintf(truncatedNotice, info.Size()), nil
+}
+
+// ScheduleCleanup removes dir after retention has elapsed.
+// A retention of zero or less removes the directory immediately.
+func ScheduleCleanup(dir string, retention time.Duration) {
+	if dir == "" {
+		return
+	}
+	remove := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("清理附件目录失败 %s: %v", dir, err)
+		}
+	}
+	if retention <= 0 {
+		remove()
+		return
+	}
+	time.AfterFunc(retention, remove)
+}
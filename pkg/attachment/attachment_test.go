@@ -0,0 +1,127 @@
+package attachment
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateName(t *testing.T) {
+	valid := []string{"log.txt", "targets.csv", "报告.md"}
+	for _, name := range valid {
+		assert.NoError(t, ValidateName(name), name)
+	}
+
+	invalid := []string{"", " ", ".", "..", "../etc/passwd", "a/b.txt", `a\b.txt`, "/etc/passwd", "a..b", "x\x00y"}
+	for _, name := range invalid {
+		err := ValidateName(name)
+		assert.ErrorIs(t, err, ErrInvalidName, name)
+	}
+}
+
+func TestSaveListRead(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "task")
+
+	require.NoError(t, Save(dir, "b.txt", []byte("second")))
+	require.NoError(t, Save(dir, "a.txt", []byte("first")))
+
+	names, err := List(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, names)
+	assert.Equal(t, "a.txt, b.txt", Placeholder(dir))
+
+	content, err := Read(dir, "a.txt", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "first", content)
+
+	_, err = Read(dir, "missing.txt", 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = Read(dir, "../a.txt", 0)
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	assert.ErrorIs(t, Save(dir, "../escape.txt", []byte("x")), ErrInvalidName)
+	_, err = os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadTruncates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, Save(dir, "big.txt", []byte(strings.Repeat("x", 100))))
+
+	content, err := Read(dir, "big.txt", 10)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(content, strings.Repeat("x", 10)))
+	assert.Contains(t, content, "已截断")
+	assert.Contains(t, content, "100")
+}
+
+func TestSaveRejectsLargeFile(t *testing.T) {
+	err := Save(t.TempDir(), "huge.bin", make([]byte, MaxFileSize+1))
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+}
+
+func TestPlaceholderEmpty(t *testing.T) {
+	assert.Equal(t, NoAttachments, Placeholder(""))
+	assert.Equal(t, NoAttachments, Placeholder(filepath.Join(t.TempDir(), "none")))
+}
+
+func TestCopyFile(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "input.log")
+	require.NoError(t, os.WriteFile(src, []byte("line1\nline2"), 0644))
+
+	dir := t.TempDir()
+	name, err := CopyFile(dir, src)
+	require.NoError(t, err)
+	assert.Equal(t, "input.log", name)
+
+	content, err := Read(dir, name, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "line1\nline2", content)
+}
+
+func TestScheduleCleanup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "task")
+	require.NoError(t, Save(dir, "a.txt", []byte("a")))
+
+	ScheduleCleanup(dir, 0)
+	_, err := os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	dir2 := filepath.Join(t.TempDir(), "task2")
+	require.NoError(t, Save(dir2, "a.txt", []byte("a")))
+	ScheduleCleanup(dir2, 20*time.Millisecond)
+	_, err = os.Stat(dir2)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(dir2)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestReadTool(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, Save(dir, "targets.txt", []byte("1.1.1.1\n8.8.8.8")))
+
+	readTool := NewReadTool(dir, 0)
+	info, err := readTool.Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ReadToolName, info.Name)
+
+	out, err := readTool.InvokableRun(ctx, `{"name":"targets.txt"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1\n8.8.8.8", out)
+
+	_, err = readTool.InvokableRun(ctx, `{"name":"../../etc/passwd"}`)
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	_, err = readTool.InvokableRun(ctx, `not json`)
+	assert.Error(t, err)
+}
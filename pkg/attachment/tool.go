@@ -0,0 +1,63 @@
+package attachment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ReadToolName is the name of the inner tool that reads task attachments
+const ReadToolName = "read_attachment"
+
+// readToolArgs holds the arguments of read_attachment
+type readToolArgs struct {
+	Name string `json:"name"`
+}
+
+// readTool implements tool.InvokableTool for reading attachments of one task
+type readTool struct {
+	dir     string
+	maxSize int
+}
+
+// NewReadTool creates the read_attachment tool bound to a task attachment directory.
+//
+// Parameters:
+//   - dir: Task attachment directory
+//   - maxSize: Maximum bytes returned per call, DefaultMaxReadSize if <= 0
+//
+// Returns:
+//   - tool.InvokableTool: Tool ready to be passed to the agent
+func NewReadTool(dir string, maxSize int) tool.InvokableTool {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxReadSize
+	}
+	return &readTool{dir: dir, maxSize: maxSize}
+}
+
+// Info implements tool.BaseTool
+func (t *readTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: ReadToolName,
+		Desc: fmt.Sprintf("read the content of a file attached to the current task, at most %d bytes are returned", t.maxSize),
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"name": {
+				Type:     schema.String,
+				Desc:     "attachment file name, as listed in the task attachments",
+				Required: true,
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun implements tool.InvokableTool
+func (t *readTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args readToolArgs
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+	return Read(t.dir, args.Name, t.maxSize)
+}
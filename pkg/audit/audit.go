@@ -0,0 +1,179 @@
+// Package audit writes agent events to a local JSON Lines file, one event per
+// line, for later review of what a task did. Events are queued and written by
+// a single goroutine so that auditing never slows down the agent, and the file
+// is rotated by size.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMaxSize is the default size of an audit log file before it is rotated
+	DefaultMaxSize = 100 * 1024 * 1024
+
+	// DefaultMaxFiles is the default number of rotated files that are kept
+	DefaultMaxFiles = 5
+
+	// defaultQueueSize is the number of events buffered before new events are dropped
+	defaultQueueSize = 1024
+)
+
+// Types of audit events
+const (
+	EventTaskStart  = "task_start"
+	EventTaskEnd    = "task_end"
+	EventToolCall   = "tool_call"
+	EventToolResult = "tool_result"
+	EventResult     = "result"
+	EventError      = "error"
+)
+
+// ErrPathEmpty is returned by New when no file is configured
+var ErrPathEmpty = errors.New("审计日志路径不能为空")
+
+// Event is a single line of the audit log
+type Event struct {
+	Time       time.Time `json:"time"`                  // 事件时间
+	TaskID     string    `json:"task_id"`               // 所属任务ID
+	Seq        uint64    `json:"seq"`                   // 任务内的事件序号，从1开始
+	Type       string    `json:"type"`                  // 事件类型
+	Task       string    `json:"task,omitempty"`        // 任务内容，仅task_start
+	Tool       string    `json:"tool,omitempty"`        // 工具名称
+	CallID     string    `json:"call_id,omitempty"`     // 工具调用ID
+	Params     any       `json:"params,omitempty"`      // 工具参数
+	Result     string    `json:"result,omitempty"`      // 工具结果或最终结果
+	Error      string    `json:"error,omitempty"`       // 错误信息
+	DurationMs int64     `json:"duration_ms,omitempty"` // 任务或工具调用的耗时（毫秒），仅task_end和tool_result
+}
+
+// Options configures a Logger
+type Options struct {
+	Path      string // 审计日志文件路径
+	MaxSize   int64  // 单个文件的最大大小（字节），0表示使用DefaultMaxSize
+	MaxFiles  int    // 保留的轮转文件数量，0表示使用DefaultMaxFiles
+	Compress  bool   // 是否使用gzip压缩轮转后的文件
+	QueueSize int    // 等待写入的事件数量上限，0表示使用默认值
+}
+
+// Logger appends events to an audit log file. Log never blocks: when the
+// queue is full the event is dropped and counted. A nil *Logger discards
+// all events.
+type Logger struct {
+	file    *rotatingFile
+	queue   chan Event
+	done    chan struct{}
+	mutex   sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+	err     error // 关闭文件时的错误
+}
+
+// New opens the audit log and starts its writer.
+//
+// Parameters:
+//   - opts: Location, rotation and queue settings
+//
+// Returns:
+//   - *Logger: Logger ready for use, Close it to flush the queued events
+//   - error: Error if the path is empty or the file cannot be opened
+func New(opts Options) (*Logger, error) {
+	if opts.Path == "" {
+		return nil, ErrPathEmpty
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = DefaultMaxFiles
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+
+	file, err := openRotatingFile(opts.Path, opts.MaxSize, opts.MaxFiles, opts.Compress)
+	if err != nil {
+		return nil, err
+	}
+	l := &Logger{
+		file:  file,
+		queue: make(chan Event, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go l.run()
+	return l, nil
+}
+
+// Log queues event for writing, setting its time if it is zero.
+// Events are written in the order they are queued.
+func (l *Logger) Log(event Event) {
+	if l == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if l.closed {
+		return
+	}
+
+	select {
+	case l.queue <- event:
+	default:
+		if l.dropped.Add(1) == 1 {
+			log.Printf("审计日志队列已满，开始丢弃事件: %s %s", event.TaskID, event.Type)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full
+func (l *Logger) Dropped() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.dropped.Load()
+}
+
+// Close stops accepting events, waits until all queued events are written
+// and closes the file. It is safe to call Close more than once.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.mutex.Unlock()
+	<-l.done
+
+	if dropped := l.Dropped(); dropped > 0 {
+		log.Printf("审计日志共丢弃 %d 个事件", dropped)
+	}
+	return l.err
+}
+
+// run writes queued events until the queue is closed
+func (l *Logger) run() {
+	defer close(l.done)
+	for event := range l.queue {
+		line, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("序列化审计事件失败 %s %s: %v", event.TaskID, event.Type, err)
+			continue
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			log.Printf("写入审计日志失败: %v", err)
+		}
+	}
+	l.err = l.file.Close()
+}
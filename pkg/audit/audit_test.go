@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents returns the events of a plain or gzip compressed audit log file
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var scanner *bufio.Scanner
+	if strings.HasSuffix(path, compressSuffix) {
+		zr, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		defer zr.Close()
+		scanner = bufio.NewScanner(zr)
+	} else {
+		scanner = bufio.NewScanner(file)
+	}
+
+	var events []Event
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestNewRequiresPath(t *testing.T) {
+	_, err := New(Options{})
+	assert.ErrorIs(t, err, ErrPathEmpty)
+}
+
+func TestLoggerWritesEventsInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+	logger, err := New(Options{Path: path})
+	require.NoError(t, err)
+
+	for i := 1; i <= 100; i++ {
+		logger.Log(Event{TaskID: "task_1", Seq: uint64(i), Type: EventToolCall, Tool: "fetch", Params: map[string]any{"n": i}})
+	}
+	require.NoError(t, logger.Close())
+
+	events := readEvents(t, path)
+	require.Len(t, events, 100)
+	for i, event := range events {
+		assert.Equal(t, uint64(i+1), event.Seq)
+		assert.Equal(t, "task_1", event.TaskID)
+		assert.False(t, event.Time.IsZero())
+	}
+	assert.Equal(t, map[string]any{"n": float64(1)}, events[0].Params)
+	assert.Zero(t, logger.Dropped())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+}
+
+func TestLoggerRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := New(Options{Path: path, MaxSize: 200, MaxFiles: 2})
+	require.NoError(t, err)
+
+	for i := 1; i <= 20; i++ {
+		logger.Log(Event{TaskID: "task_1", Seq: uint64(i), Type: EventResult, Result: strings.Repeat("x", 40)})
+	}
+	require.NoError(t, logger.Close())
+
+	// 只保留当前文件和两个备份，每个文件都不超过大小限制
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+		info, err := entry.Info()
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(200), entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"audit.jsonl", "audit.jsonl.1", "audit.jsonl.2"}, names)
+
+	// 备份从旧到新排列后事件序号连续，并以最后一个事件结束
+	var events []Event
+	for _, name := range []string{"audit.jsonl.2", "audit.jsonl.1", "audit.jsonl"} {
+		events = append(events, readEvents(t, filepath.Join(filepath.Dir(path), name))...)
+	}
+	require.NotEmpty(t, events)
+	for i := 1; i < len(events); i++ {
+		assert.Equal(t, events[i-1].Seq+1, events[i].Seq)
+	}
+	assert.Equal(t, uint64(20), events[len(events)-1].Seq)
+}
+
+func TestLoggerCompressesBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := New(Options{Path: path, MaxSize: 150, MaxFiles: 3, Compress: true})
+	require.NoError(t, err)
+
+	// 每个事件都超过大小限制，各自占用一个文件
+	for i := 1; i <= 6; i++ {
+		logger.Log(Event{TaskID: "task_1", Seq: uint64(i), Type: EventResult, Result: strings.Repeat("y", 150)})
+	}
+	require.NoError(t, logger.Close())
+
+	var seqs []uint64
+	for _, name := range []string{path + ".3.gz", path + ".2.gz", path + ".1.gz", path} {
+		for _, event := range readEvents(t, name) {
+			seqs = append(seqs, event.Seq)
+		}
+	}
+	assert.Equal(t, []uint64{3, 4, 5, 6}, seqs)
+	_, err = os.Stat(path + ".1")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoggerAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for run := 1; run <= 2; run++ {
+		logger, err := New(Options{Path: path})
+		require.NoError(t, err)
+		logger.Log(Event{TaskID: fmt.Sprintf("task_%d", run), Seq: 1, Type: EventTaskStart})
+		require.NoError(t, logger.Close())
+	}
+
+	events := readEvents(t, path)
+	require.Len(t, events, 2)
+	assert.Equal(t, "task_1", events[0].TaskID)
+	assert.Equal(t, "task_2", events[1].TaskID)
+}
+
+func TestLoggerDropsWhenQueueIsFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := New(Options{Path: path, QueueSize: 1})
+	require.NoError(t, err)
+
+	// 写入速度跟不上时事件被丢弃而不是阻塞，写入和丢弃的事件数之和不变
+	for i := 0; i < 10000; i++ {
+		logger.Log(Event{TaskID: "task_1", Seq: uint64(i + 1), Type: EventResult, Result: strings.Repeat("z", 100)})
+	}
+	require.NoError(t, logger.Close())
+
+	written := uint64(len(readEvents(t, path)))
+	assert.Equal(t, uint64(10000), written+logger.Dropped())
+	assert.Positive(t, logger.Dropped())
+}
+
+func TestClosedAndNilLoggerDiscardEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := New(Options{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, logger.Close())
+	require.NoError(t, logger.Close())
+	logger.Log(Event{TaskID: "task_1", Type: EventTaskStart})
+	assert.Empty(t, readEvents(t, path))
+
+	var nilLogger *Logger
+	nilLogger.Log(Event{TaskID: "task_1", Type: EventTaskStart})
+	assert.Zero(t, nilLogger.Dropped())
+	assert.NoError(t, nilLogger.Close())
+}
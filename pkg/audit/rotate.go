@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// compressSuffix is appended to backups compressed with gzip
+const compressSuffix = ".gz"
+
+// rotatingFile is an append-only file that is rotated once it would grow
+// beyond maxSize. Backups are named path.1 (newest) to path.<maxFiles>
+// (oldest), with a .gz suffix when compressed, and older backups are removed.
+// It is not safe for concurrent use, Logger writes from a single goroutine.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	compress bool
+
+	file *os.File
+	size int64
+}
+
+// openRotatingFile opens path for appending, creating it and its directory if needed
+func openRotatingFile(path string, maxSize int64, maxFiles int, compress bool) (*rotatingFile, error) {
+	w := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles, compress: compress}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the current file and records its size
+func (w *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o700); err != nil {
+		return fmt.Errorf("创建审计日志目录失败: %w", err)
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取审计日志信息失败: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p does not fit into the current file.
+// A p larger than maxSize is written to a file of its own.
+func (w *rotatingFile) Write(p []byte) (int, error) {
+	if w.file == nil {
+		// 上次轮转失败后重新打开
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (w *rotatingFile) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// backupPath returns the path of the n-th backup without compression suffix
+func (w *rotatingFile) backupPath(n int) string {
+	return w.path + "." + strconv.Itoa(n)
+}
+
+// rotate moves the current file to the first backup, shifting the existing
+// backups by one and removing the oldest, and opens a new current file
+func (w *rotatingFile) rotate() error {
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("关闭审计日志失败: %w", err)
+	}
+
+	// 备份可能在修改compress配置前后产生，压缩和未压缩的都要处理
+	for _, suffix := range []string{"", compressSuffix} {
+		if err := os.Remove(w.backupPath(w.maxFiles) + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("删除过期审计日志失败: %w", err)
+		}
+	}
+	for n := w.maxFiles - 1; n >= 1; n-- {
+		for _, suffix := range []string{"", compressSuffix} {
+			err := os.Rename(w.backupPath(n)+suffix, w.backupPath(n+1)+suffix)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("轮转审计日志失败: %w", err)
+			}
+		}
+	}
+
+	backup := w.backupPath(1)
+	if err := os.Rename(w.path, backup); err != nil {
+		return fmt.Errorf("轮转审计日志失败: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.compress {
+		if err := compressFile(backup); err != nil {
+			return fmt.Errorf("压缩审计日志失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// compressFile replaces path with a gzip compressed path.gz
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		os.Remove(path + compressSuffix)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + compressSuffix)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + compressSuffix)
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,95 @@
+// Package childproc finds and kills the processes started by the current process.
+//
+// Launchers such as uvx and npx start the actual MCP server as a grandchild.
+// mcphost kills the process group of a stdio server when closing it, but a
+// process that moved to a group of its own survives that, so the CLI collects
+// the whole tree up front and kills what is left after an interrupt.
+package childproc
+
+import (
+	"os"
+	"sort"
+)
+
+// Descendants returns the processes started by pid and, recursively, by its
+// children. Deeper processes come first, so killing in order never leaves a
+// parent that restarts its children. An empty result is returned if the
+// process table cannot be read.
+//
+// Parameters:
+//   - pid: Process whose descendants are listed
+//
+// Returns:
+//   - []int: IDs of the descendant processes, never including pid
+func Descendants(pid int) []int {
+	parents, err := processTable()
+	if err != nil {
+		return nil
+	}
+	return descendantsOf(pid, parents)
+}
+
+// Own returns the descendants of the current process
+func Own() []int {
+	return Descendants(os.Getpid())
+}
+
+// Kill forcibly terminates the processes that are still alive.
+// Processes that already exited are skipped.
+//
+// Parameters:
+//   - pids: IDs of the processes, usually returned by Descendants
+//
+// Returns:
+//   - int: Number of processes that were killed
+func Kill(pids []int) int {
+	killed := 0
+	self := os.Getpid()
+	for _, pid := range pids {
+		if pid <= 0 || pid == self || !Alive(pid) {
+			continue
+		}
+		if kill(pid) == nil {
+			killed++
+		}
+	}
+	return killed
+}
+
+// Alive reports whether the process pid exists and has not exited yet.
+// Zombie processes waiting to be reaped count as exited.
+func Alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	return alive(pid)
+}
+
+// descendantsOf walks the parent table breadth first and returns the deepest processes first
+func descendantsOf(pid int, parents map[int]int) []int {
+	children := make(map[int][]int)
+	for child, parent := range parents {
+		if child != parent {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	var result []int
+	seen := map[int]bool{pid: true}
+	level := []int{pid}
+	for len(level) > 0 {
+		var next []int
+		for _, p := range level {
+			sort.Ints(children[p])
+			for _, child := range children[p] {
+				if !seen[child] {
+					seen[child] = true
+					next = append(next, child)
+				}
+			}
+		}
+		result = append(next, result...)
+		level = next
+	}
+	return result
+}
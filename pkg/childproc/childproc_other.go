@@ -0,0 +1,23 @@
+//go:build !unix && !windows
+
+package childproc
+
+import "errors"
+
+// errUnsupported is returned on platforms without a process table
+var errUnsupported = errors.New("当前平台不支持列出子进程")
+
+// processTable is not available on this platform
+func processTable() (map[int]int, error) {
+	return nil, errUnsupported
+}
+
+// kill is not available on this platform
+func kill(pid int) error {
+	return errUnsupported
+}
+
+// alive always reports false on this platform
+func alive(pid int) bool {
+	return false
+}
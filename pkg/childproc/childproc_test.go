@@ -0,0 +1,23 @@
+package childproc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescendantsOf(t *testing.T) {
+	parents := map[int]int{
+		1:  0,
+		10: 1,
+		11: 10,
+		12: 10,
+		13: 11,
+		20: 1,
+		30: 2, // 其他进程树
+	}
+
+	assert.Equal(t, []int{13, 11, 12}, descendantsOf(10, parents))
+	assert.Equal(t, []int{13, 11, 12, 10, 20}, descendantsOf(1, parents))
+	assert.Empty(t, descendantsOf(13, parents))
+}
@@ -0,0 +1,97 @@
+//go:build unix
+
+package childproc
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processTable returns the parent of every process, read from /proc where
+// available and from ps otherwise
+func processTable() (map[int]int, error) {
+	if entries, err := os.ReadDir("/proc"); err == nil {
+		parents := make(map[int]int, len(entries))
+		for _, entry := range entries {
+			pid, err := strconv.Atoi(entry.Name())
+			if err != nil {
+				continue
+			}
+			if _, ppid, ok := procStat(pid); ok {
+				parents[pid] = ppid
+			}
+		}
+		if len(parents) > 0 {
+			return parents, nil
+		}
+	}
+
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[int]int)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			parents[pid] = ppid
+		}
+	}
+	return parents, nil
+}
+
+// procStat returns the state and parent of pid from /proc/<pid>/stat
+func procStat(pid int) (state byte, ppid int, ok bool) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, 0, false
+	}
+	// 进程名可能包含空格和括号，从最后一个右括号之后解析
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return 0, 0, false
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 2 {
+		return 0, 0, false
+	}
+	ppid, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return fields[0][0], ppid, true
+}
+
+// kill sends SIGKILL to pid, and to its process group if pid leads a group of its own
+func kill(pid int) error {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid && pgid != syscall.Getpgrp() {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
+
+// alive reports whether pid exists and is not a zombie
+func alive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+	if state, _, ok := procStat(pid); ok {
+		return state != 'Z' && state != 'X'
+	}
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return false
+	}
+	state := strings.TrimSpace(string(out))
+	return state != "" && !strings.HasPrefix(state, "Z")
+}
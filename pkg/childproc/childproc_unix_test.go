@@ -0,0 +1,59 @@
+//go:build unix
+
+package childproc
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startSleepingTree starts a shell that starts a sleeping grandchild and
+// returns the shell and the pid of the grandchild. The shell blocks reading
+// its stdin, so it keeps running when the grandchild is killed first.
+func startSleepingTree(t *testing.T) (*exec.Cmd, int) {
+	pidFile := filepath.Join(t.TempDir(), "sleep.pid")
+	cmd := exec.Command("sh", "-c", `sleep 300 & echo $! > "$0"; read line`, pidFile)
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		stdin.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	var pid int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return cmd, pid
+}
+
+func TestKillDescendants(t *testing.T) {
+	cmd, sleepPID := startSleepingTree(t)
+
+	descendants := Own()
+	assert.Contains(t, descendants, cmd.Process.Pid)
+	assert.Contains(t, descendants, sleepPID)
+	assert.True(t, Alive(sleepPID))
+
+	assert.GreaterOrEqual(t, Kill(descendants), 2)
+	cmd.Wait()
+	assert.Eventually(t, func() bool { return !Alive(sleepPID) }, 5*time.Second, 10*time.Millisecond)
+
+	// 已退出的进程不会再次结束
+	assert.Zero(t, Kill([]int{cmd.Process.Pid}))
+}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package childproc
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Access rights and wait results of the Windows process API
+const (
+	processTerminate        = 0x0001
+	processQueryLimitedInfo = 0x1000
+	synchronize             = 0x00100000
+	waitTimeout             = 0x00000102
+)
+
+// processTable returns the parent of every process from a toolhelp snapshot
+func processTable() (map[int]int, error) {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(snapshot)
+
+	parents := make(map[int]int)
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = syscall.Process32First(snapshot, &entry); err == nil; err = syscall.Process32Next(snapshot, &entry) {
+		parents[int(entry.ProcessID)] = int(entry.ParentProcessID)
+	}
+	return parents, nil
+}
+
+// kill terminates pid
+func kill(pid int) error {
+	handle, err := syscall.OpenProcess(processTerminate, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(handle)
+	return syscall.TerminateProcess(handle, 1)
+}
+
+// alive reports whether pid exists and has not exited
+func alive(pid int) bool {
+	handle, err := syscall.OpenProcess(synchronize|processQueryLimitedInfo, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+	event, err := syscall.WaitForSingleObject(handle, 0)
+	return err == nil && event == waitTimeout
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Modes of AgentConfig.RequireTools
+const (
+	// RequireToolsNever runs tasks without tools unchecked, the default
+	RequireToolsNever = "never"
+	// RequireToolsAuto asks the model before a task without tools whether
+	// the task needs external information, and fails the task if it does
+	RequireToolsAuto = "auto"
+	// RequireToolsAlways fails every task without tools
+	RequireToolsAlways = "always"
+)
+
+const (
+	errMsgFirstToolEmpty           = "first_tool不能只包含空白字符"
+	errMsgAllowedToolsPerStepRange = "allowed_tools_per_step不能为负数: %d"
+	errMsgMaxNotesSizeRange        = "max_notes_size不能为负数: %d"
+	errMsgRequireToolsInvalid      = "不支持的工具检查方式: %s，可选值为 auto、always 和 never"
+)
+
+// AgentConfig configures how the agent presents tools to the model
+type AgentConfig struct {
+	// RequireThink adds a required think parameter to every tool that defines
+	// neither think nor thought, so that models which do not emit reasoning
+	// explain each tool call. The parameter is removed before the tool runs.
+	RequireThink bool `mapstructure:"require_think" json:"require_think,omitempty" yaml:"require_think,omitempty"` // 为每个工具增加必填的think参数，要求模型说明调用原因
+
+	// VerifyResult checks the final answer against the tool results of the
+	// task with an extra model call before the result is sent, and reports
+	// the claims the tool results do not support.
+	VerifyResult bool `mapstructure:"verify_result" json:"verify_result,omitempty" yaml:"verify_result,omitempty"` // 发送结果前对照工具结果校验最终回答
+
+	// FirstTool is the name of the tool, as presented to the model, that the
+	// first step of the task must call. The model is told so in the first
+	// request, and OpenAI-compatible providers also receive it as tool_choice.
+	FirstTool string `mapstructure:"first_tool" json:"first_tool,omitempty" yaml:"first_tool,omitempty"` // 第一步必须调用的工具
+
+	// AllowedToolsPerStep limits the number of distinct tools the model may
+	// call in a single step, 0 means no limit. Calls of further tools are
+	// answered with an error message instead of being run.
+	AllowedToolsPerStep int `mapstructure:"allowed_tools_per_step" json:"allowed_tools_per_step,omitempty" yaml:"allowed_tools_per_step,omitempty"` // 每一步最多调用的不同工具数，0表示不限制
+
+	// ProbeToolSupport sends a short request with a trivial tool to
+	// OpenAI-compatible providers when the agent is created, so a model
+	// without tool calling fails before the task starts instead of after the
+	// first request of the task.
+	ProbeToolSupport bool `mapstructure:"probe_tool_support" json:"probe_tool_support,omitempty" yaml:"probe_tool_support,omitempty"` // 创建agent时发送一次带测试工具的请求，检查模型是否支持工具调用
+
+	// EnableNotes adds the save_note, list_notes and get_note tools backed by
+	// a note store of the task, and lists the keys of the saved notes in the
+	// system prompt of every step. The notes are part of the task report.
+	EnableNotes bool `mapstructure:"enable_notes" json:"enable_notes,omitempty" yaml:"enable_notes,omitempty"` // 启用任务内的笔记工具
+
+	// MaxNotesSize caps the bytes of keys and contents of the notes of a
+	// task, 0 means notes.DefaultMaxTotalSize. The least recently used notes
+	// are evicted when a new note does not fit.
+	MaxNotesSize int `mapstructure:"max_notes_size" json:"max_notes_size,omitempty" yaml:"max_notes_size,omitempty"` // 笔记总大小上限（字节），0表示使用默认值
+
+	// RequireTools checks a task without tools before it starts, so that a
+	// task needing external information fails instead of being answered from
+	// the knowledge of the model: never (the default), auto or always, see
+	// the RequireTools constants.
+	RequireTools string `mapstructure:"require_tools" json:"require_tools,omitempty" yaml:"require_tools,omitempty"` // 没有工具时的检查方式：never（默认）、auto 或 always
+}
+
+// EffectiveRequireTools returns the configured RequireTools mode, defaulting to never
+func (a *AgentConfig) EffectiveRequireTools() string {
+	if strings.TrimSpace(a.RequireTools) == "" {
+		return RequireToolsNever
+	}
+	return a.RequireTools
+}
+
+// validateAgent adds invalid agent settings to errs
+func (c *Config) validateAgent(errs *FieldErrors) {
+	if c.Agent.FirstTool != "" && strings.TrimSpace(c.Agent.FirstTool) == "" {
+		errs.add("agent.first_tool", errors.New(errMsgFirstToolEmpty))
+	}
+	if c.Agent.AllowedToolsPerStep < 0 {
+		errs.add("agent.allowed_tools_per_step", fmt.Errorf(errMsgAllowedToolsPerStepRange, c.Agent.AllowedToolsPerStep))
+	}
+	if c.Agent.MaxNotesSize < 0 {
+		errs.add("agent.max_notes_size", fmt.Errorf(errMsgMaxNotesSizeRange, c.Agent.MaxNotesSize))
+	}
+	switch c.Agent.EffectiveRequireTools() {
+	case RequireToolsNever, RequireToolsAuto, RequireToolsAlways:
+	default:
+		errs.add("agent.require_tools", fmt.Errorf(errMsgRequireToolsInvalid, c.Agent.RequireTools))
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyCmdTimeout limits how long api_key_cmd may run
+	apiKeyCmdTimeout = 10 * time.Second
+
+	errMsgLLMAPIKeySources = "api_key、api_key_file和api_key_cmd只能设置一个"
+	errMsgReadAPIKeyFile   = "读取API密钥文件 %s 失败: %w"
+	errMsgAPIKeyFileEmpty  = "API密钥文件 %s 为空"
+	errMsgAPIKeyCmdFailed  = "执行API密钥命令失败: %w"
+	errMsgAPIKeyCmdEmpty   = "API密钥命令没有输出"
+)
+
+// HasExternalAPIKey reports whether the API key is read from api_key_file or api_key_cmd
+func (l *LLMConfig) HasExternalAPIKey() bool {
+	return strings.TrimSpace(l.APIKeyFile) != "" || strings.TrimSpace(l.APIKeyCmd) != ""
+}
+
+// validateAPIKeySources ensures at most one API key source is configured
+func (l *LLMConfig) validateAPIKeySources() error {
+	sources := 0
+	for _, source := range []string{l.APIKey, l.APIKeyFile, l.APIKeyCmd} {
+		if strings.TrimSpace(source) != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New(errMsgLLMAPIKeySources)
+	}
+	return nil
+}
+
+// ResolveAPIKey returns the API key from the configured source. The key read
+// from api_key_file or api_key_cmd is only returned and never stored in the
+// configuration, so it cannot be saved or exposed afterwards.
+//
+// Parameters:
+//   - ctx: Context for the operation, cancels a running api_key_cmd
+//
+// Returns:
+//   - string: API key, empty if no source is configured
+//   - error: Error if the file cannot be read or the command fails
+func (l *LLMConfig) ResolveAPIKey(ctx context.Context) (string, error) {
+	if path := strings.TrimSpace(l.APIKeyFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf(errMsgReadAPIKeyFile, path, err)
+		}
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", fmt.Errorf(errMsgAPIKeyFileEmpty, path)
+		}
+		return key, nil
+	}
+
+	if command := strings.TrimSpace(l.APIKeyCmd); command != "" {
+		return runAPIKeyCmd(ctx, command)
+	}
+
+	return l.APIKey, nil
+}
+
+// runAPIKeyCmd runs command in the system shell and returns its trimmed stdout
+func runAPIKeyCmd(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiKeyCmdTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("超时（%v）", apiKeyCmdTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", fmt.Errorf(errMsgAPIKeyCmdFailed, err)
+	}
+
+	key := strings.TrimSpace(stdout.String())
+	if key == "" {
+		return "", errors.New(errMsgAPIKeyCmdEmpty)
+	}
+	return key, nil
+}
+
+// bearerTransport adds an Authorization header to every request
+type bearerTransport struct {
+	base  http.RoundTripper
+	token string
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+// withBearerToken returns a copy of httpClient sending token as bearer token
+func withBearerToken(httpClient *http.Client, token string) *http.Client {
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	copied := *httpClient
+	copied.Transport = &bearerTransport{base: base, token: token}
+	return &copied
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authRecorder is an LLM endpoint recording the Authorization headers it receives
+type authRecorder struct {
+	mu      sync.Mutex
+	headers []string
+}
+
+func (a *authRecorder) server(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		a.headers = append(a.headers, r.Header.Get("Authorization"))
+		a.mu.Unlock()
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (a *authRecorder) last() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.headers) == 0 {
+		return ""
+	}
+	return a.headers[len(a.headers)-1]
+}
+
+// generateOnce creates the model of cfg and sends one request
+func generateOnce(t *testing.T, cfg *Config) {
+	t.Helper()
+	m, err := cfg.GetModel(context.Background())
+	require.NoError(t, err)
+	_, _ = m.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+}
+
+func writeKeyFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "api_key")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestAPIKeyFromFile(t *testing.T) {
+	recorder := &authRecorder{}
+	srv := recorder.server(t)
+	keyFile := writeKeyFile(t, "  file-secret\n")
+
+	for _, llmType := range []string{LLMProviderOpenAI, LLMProviderOllama} {
+		cfg := &Config{LLM: LLMConfig{Type: llmType, BaseURL: srv.URL, Model: "m", APIKeyFile: keyFile}}
+		require.NoError(t, cfg.LLM.Validate())
+		generateOnce(t, cfg)
+		assert.Equal(t, "Bearer file-secret", recorder.last(), llmType)
+	}
+
+	// 文件不存在或为空
+	missing := &LLMConfig{APIKeyFile: filepath.Join(t.TempDir(), "missing")}
+	_, err := missing.ResolveAPIKey(context.Background())
+	assert.ErrorContains(t, err, "读取API密钥文件")
+	empty := &LLMConfig{APIKeyFile: writeKeyFile(t, " \n")}
+	_, err = empty.ResolveAPIKey(context.Background())
+	assert.ErrorContains(t, err, "为空")
+
+	cfg := &Config{LLM: LLMConfig{Type: LLMProviderOpenAI, BaseURL: srv.URL, Model: "m", APIKeyFile: missing.APIKeyFile}}
+	_, err = cfg.GetModel(context.Background())
+	assert.ErrorContains(t, err, "获取LLM API密钥失败")
+}
+
+func TestAPIKeyFromCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试命令依赖sh")
+	}
+	recorder := &authRecorder{}
+	srv := recorder.server(t)
+
+	cfg := &Config{LLM: LLMConfig{Type: LLMProviderOpenAI, BaseURL: srv.URL, Model: "m", APIKeyCmd: "printf 'cmd-secret\\n'"}}
+	generateOnce(t, cfg)
+	assert.Equal(t, "Bearer cmd-secret", recorder.last())
+
+	failing := &LLMConfig{APIKeyCmd: "echo denied >&2; exit 3"}
+	_, err := failing.ResolveAPIKey(context.Background())
+	assert.ErrorContains(t, err, "执行API密钥命令失败")
+	assert.ErrorContains(t, err, "denied")
+
+	silent := &LLMConfig{APIKeyCmd: "true"}
+	_, err = silent.ResolveAPIKey(context.Background())
+	assert.ErrorContains(t, err, errMsgAPIKeyCmdEmpty)
+}
+
+func TestAPIKeySourcePrecedence(t *testing.T) {
+	keyFile := writeKeyFile(t, "file-secret")
+
+	// 只能设置一个密钥来源
+	both := LLMConfig{Type: LLMProviderOpenAI, BaseURL: "http://localhost", Model: "m", APIKey: "inline", APIKeyFile: keyFile}
+	assert.EqualError(t, both.Validate(), errMsgLLMAPIKeySources)
+	both = LLMConfig{Type: LLMProviderOpenAI, BaseURL: "http://localhost", Model: "m", APIKeyFile: keyFile, APIKeyCmd: "echo x"}
+	assert.EqualError(t, both.Validate(), errMsgLLMAPIKeySources)
+
+	// 配置文件只设置api_key_file时不使用默认的api_key
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("llm:\n  api_key_file: "+keyFile+"\n"), 0644))
+	cfg, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.LLM.APIKey)
+	key, err := cfg.LLM.ResolveAPIKey(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "file-secret", key)
+
+	// 同时设置api_key和api_key_file的配置文件无法加载
+	require.NoError(t, os.WriteFile(configPath, []byte("llm:\n  api_key: inline\n  api_key_file: "+keyFile+"\n"), 0644))
+	_, err = LoadConfig(configPath)
+	assert.ErrorContains(t, err, errMsgLLMAPIKeySources)
+}
+
+func TestResolvedAPIKeyIsNeverExposed(t *testing.T) {
+	keyFile := writeKeyFile(t, "file-secret")
+	cfg := NewDefaultConfig()
+	cfg.LLM = LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:1", Model: "m", APIKeyFile: keyFile}
+
+	_, err := cfg.GetModel(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, cfg.LLM.APIKey, "解析出的密钥不写回配置")
+
+	// 保存的配置只包含密钥来源
+	savedPath := filepath.Join(t.TempDir(), "saved.yaml")
+	require.NoError(t, cfg.SaveConfig(savedPath))
+	saved, err := os.ReadFile(savedPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(saved), "file-secret")
+	assert.Contains(t, string(saved), "api_key_file: "+keyFile)
+
+	// Web接口既不返回也不接受文件和命令来源
+	data, err := json.Marshal(cfg.LLM)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "file-secret")
+	assert.NotContains(t, string(data), keyFile)
+
+	var decoded LLMConfig
+	require.NoError(t, json.Unmarshal([]byte(`{"api_key_cmd":"cat /etc/passwd","api_key_file":"/etc/shadow"}`), &decoded))
+	assert.False(t, decoded.HasExternalAPIKey())
+}
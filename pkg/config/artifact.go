@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/artifact"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/mark3labs/mcp-go/client"
+)
+
+const (
+	errMsgArtifactMaxSize   = "产物最大大小不能为负数"
+	errMsgArtifactRetention = "产物保留时间不能为负数"
+
+	errMsgToolConfigsMismatch    = "MCP工具数量 %d 与工具配置数量 %d 不一致"
+	errMsgToolTimeoutUnavailable = "MCP服务器 %s 的工具 %s 无法使用tool_timeout设置的超时时间 %s: %w"
+
+	// defaultArtifactRetention is how long artifacts are kept when RetentionMinutes is 0
+	defaultArtifactRetention = 24 * time.Hour
+)
+
+// mcpClientProvider is implemented by MCP hubs that expose the client of a
+// server, such as *mcphost.MCPHub. It is needed to read non-text tool contents.
+type mcpClientProvider interface {
+	GetClient(serverName string) (client.MCPClient, error)
+}
+
+var _ mcpClientProvider = (*mcphost.MCPHub)(nil)
+
+// errNoMCPClients is the error of hubs that do not implement mcpClientProvider
+var errNoMCPClients = errors.New("MCP服务器管理器不提供服务器的客户端")
+
+// ArtifactConfig configures how binary tool outputs such as images are stored.
+// Store is set at runtime for each task and is never read from or written to
+// configuration files or the web API.
+type ArtifactConfig struct {
+	Store            *artifact.Store `mapstructure:"-" json:"-" yaml:"-"`                                                 // 当前任务的产物存储，运行时设置，为nil时不保存产物
+	MaxSize          int             `mapstructure:"max_size" json:"max_size" yaml:"max_size"`                            // 单个产物的最大字节数，0表示使用默认值
+	RetentionMinutes int             `mapstructure:"retention_minutes" json:"retention_minutes" yaml:"retention_minutes"` // 任务结束后产物的保留时间（分钟），0表示使用默认值（24小时）
+}
+
+// Retention returns how long artifacts are kept after a task completes
+func (a *ArtifactConfig) Retention() time.Duration {
+	if a.RetentionMinutes == 0 {
+		return defaultArtifactRetention
+	}
+	return time.Duration(a.RetentionMinutes) * time.Minute
+}
+
+// Validate validates the artifact configuration.
+//
+// Returns:
+//   - error: validation error if configuration is invalid, nil otherwise
+func (a *ArtifactConfig) Validate() error {
+	if a.MaxSize < 0 {
+		return errors.New(errMsgArtifactMaxSize)
+	}
+	if a.RetentionMinutes < 0 {
+		return errors.New(errMsgArtifactRetention)
+	}
+	return nil
+}
+
+// wrapTools makes MCP tools call their servers directly through
+// mcphost.NewToolCaller when Store is set, storing image and resource
+// contents in Store, or when tool_timeout replaces the timeout the hub
+// applies to the calls of the server. timeout returns the call timeout of a
+// server and whether it replaces the one of the hub, see
+// MCPConfig.toolTimeouts. tools must be in the order of toolConfigs.
+//
+// A tool needing the replaced timeout is an error when the hub does not
+// expose the client of its server; one only needing Store is returned
+// unchanged with a warning.
+func (a *ArtifactConfig) wrapTools(hub MCPHubInterface, tools []tool.BaseTool, toolConfigs []MCPToolConfig, timeout func(server string) (time.Duration, bool)) ([]tool.BaseTool, error) {
+	if len(tools) != len(toolConfigs) {
+		return nil, fmt.Errorf(errMsgToolConfigsMismatch, len(tools), len(toolConfigs))
+	}
+	provider, _ := hub.(mcpClientProvider)
+
+	result := make([]tool.BaseTool, len(tools))
+	for i, t := range tools {
+		result[i] = t
+		toolConfig := toolConfigs[i]
+		callTimeout, replaced := timeout(toolConfig.Server)
+		if a.Store == nil && !replaced {
+			continue
+		}
+		var cli client.MCPClient
+		err := errNoMCPClients
+		if provider != nil {
+			cli, err = provider.GetClient(toolConfig.Server)
+		}
+		if err != nil {
+			if replaced {
+				return nil, fmt.Errorf(errMsgToolTimeoutUnavailable, toolConfig.Server, toolConfig.Name, callTimeout, err)
+			}
+			log.Printf("警告: 获取MCP服务器 %s 的客户端失败: %v，工具 %s 不保存产物", toolConfig.Server, err, toolConfig.Name)
+			continue
+		}
+		result[i] = artifact.WrapTool(t, mcphost.NewToolCaller(toolConfig.Server, cli, callTimeout), toolConfig.Name, a.Store)
+	}
+	return result, nil
+}
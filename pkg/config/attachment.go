@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	errMsgAttachmentMaxReadSize = "附件最大读取长度不能为负数"
+	errMsgAttachmentRetention   = "附件保留时间不能为负数"
+)
+
+// AttachmentConfig configures the files attached to a task.
+// Dir is set at runtime for each task and is never read from or written to
+// configuration files or the web API.
+type AttachmentConfig struct {
+	Dir              string `mapstructure:"-" json:"-" yaml:"-"`                                                 // 当前任务的附件目录，运行时设置
+	MaxReadSize      int    `mapstructure:"max_read_size" json:"max_read_size" yaml:"max_read_size"`             // read_attachment单次返回的最大字节数，0表示使用默认值
+	RetentionMinutes int    `mapstructure:"retention_minutes" json:"retention_minutes" yaml:"retention_minutes"` // 任务结束后附件目录的保留时间（分钟），0表示立即清理
+}
+
+// Retention returns how long attachment directories are kept after a task completes
+func (a *AttachmentConfig) Retention() time.Duration {
+	return time.Duration(a.RetentionMinutes) * time.Minute
+}
+
+// Validate validates the attachment configuration.
+//
+// Returns:
+//   - error: validation error if configuration is invalid, nil otherwise
+func (a *AttachmentConfig) Validate() error {
+	if a.MaxReadSize < 0 {
+		return errors.New(errMsgAttachmentMaxReadSize)
+	}
+	if a.RetentionMinutes < 0 {
+		return errors.New(errMsgAttachmentRetention)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+
+	"github.com/LubyRuffy/mcpagent/pkg/audit"
+)
+
+const (
+	errMsgAuditMaxSize  = "审计日志文件大小不能为负数"
+	errMsgAuditMaxFiles = "审计日志保留文件数不能为负数"
+)
+
+// AuditConfig configures the local audit log, a JSON Lines file recording
+// the start and end of every task together with its tool calls, tool results
+// and errors. Auditing is disabled when Path is empty.
+type AuditConfig struct {
+	Path      string `mapstructure:"path" json:"path" yaml:"path"`                      // 审计日志文件路径，为空时不记录
+	MaxSizeMB int    `mapstructure:"max_size_mb" json:"max_size_mb" yaml:"max_size_mb"` // 单个文件轮转前的最大大小（MB），0表示使用默认值（100）
+	MaxFiles  int    `mapstructure:"max_files" json:"max_files" yaml:"max_files"`       // 保留的轮转文件数量，0表示使用默认值（5）
+	Compress  bool   `mapstructure:"compress" json:"compress" yaml:"compress"`          // 是否使用gzip压缩轮转后的文件
+}
+
+// Enabled reports whether an audit log is configured
+func (a *AuditConfig) Enabled() bool {
+	return a.Path != ""
+}
+
+// Validate validates the audit log configuration.
+//
+// Returns:
+//   - error: validation error if configuration is invalid, nil otherwise
+func (a *AuditConfig) Validate() error {
+	if a.MaxSizeMB < 0 {
+		return errors.New(errMsgAuditMaxSize)
+	}
+	if a.MaxFiles < 0 {
+		return errors.New(errMsgAuditMaxFiles)
+	}
+	return nil
+}
+
+// Open opens the configured audit log.
+//
+// Returns:
+//   - *audit.Logger: Logger writing to Path, nil when auditing is disabled
+//   - error: Error if the file cannot be opened
+func (a *AuditConfig) Open() (*audit.Logger, error) {
+	if !a.Enabled() {
+		return nil, nil
+	}
+	return audit.New(audit.Options{
+		Path:     a.Path,
+		MaxSize:  int64(a.MaxSizeMB) * 1024 * 1024,
+		MaxFiles: a.MaxFiles,
+		Compress: a.Compress,
+	})
+}
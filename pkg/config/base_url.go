@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+const (
+	errMsgLLMBaseURLInvalid = "LLM BaseURL无效: %s，应为 http:// 或 https:// 开头的地址"
+
+	warnMsgBaseURLEndpoint = "BaseURL %s 包含接口路径 %s，已去除，只需填写API基础地址"
+	warnMsgBaseURLV1Added  = "BaseURL %s 缺少 /v1，OpenAI兼容接口通常位于 /v1 下，已自动补全"
+	warnMsgBaseURLV1Strip  = "BaseURL %s 以 /v1 结尾，这是Ollama的OpenAI兼容接口，Ollama类型使用原生接口，已去除"
+)
+
+// endpointSuffixes are the paths of single endpoints users paste instead of
+// the base URL, per provider. The clients append them themselves.
+var endpointSuffixes = map[string][]string{
+	LLMProviderOpenAI: {"/chat/completions", "/completions"},
+	LLMProviderOllama: {"/api/chat", "/api/generate"},
+}
+
+// AutoFixesBaseURL reports whether NormalizeBaseURL may change the path of
+// BaseURL beyond removing endpoint paths: auto_fix_base_url, default on
+func (l *LLMConfig) AutoFixesBaseURL() bool {
+	return l.AutoFixBaseURL == nil || *l.AutoFixBaseURL
+}
+
+// NormalizeBaseURL returns the BaseURL the model client is created with.
+// Trailing slashes are removed, and so are the endpoint paths users copy
+// from API docs such as /chat/completions, since the clients append them and
+// the request would otherwise 404. With AutoFixesBaseURL, an OpenAI-compatible
+// URL without a path gets /v1, where these servers serve their API, and an
+// Ollama URL ending in /v1, its OpenAI-compatible API, has it removed.
+//
+// Returns:
+//   - string: Normalized URL
+//   - []string: Warnings describing every change
+//   - error: Error if BaseURL is not an http or https URL
+func (l *LLMConfig) NormalizeBaseURL() (string, []string, error) {
+	raw := strings.TrimSpace(l.BaseURL)
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		shown := redactURL(raw)
+		if i := strings.LastIndex(raw, "@"); err != nil && i >= 0 {
+			// 无法解析时redactURL不会隐藏凭据，去掉@之前的部分
+			shown = RedactedValue + raw[i:]
+		}
+		return "", nil, fmt.Errorf(errMsgLLMBaseURLInvalid, shown)
+	}
+
+	var warnings []string
+	shown := redactURL(raw)
+	path := strings.TrimRight(parsed.Path, "/")
+	for _, suffix := range endpointSuffixes[l.Type] {
+		if strings.HasSuffix(path, suffix) {
+			warnings = append(warnings, fmt.Sprintf(warnMsgBaseURLEndpoint, shown, suffix))
+			path = strings.TrimRight(strings.TrimSuffix(path, suffix), "/")
+			break
+		}
+	}
+	if l.AutoFixesBaseURL() {
+		switch {
+		case l.Type == LLMProviderOpenAI && path == "":
+			warnings = append(warnings, fmt.Sprintf(warnMsgBaseURLV1Added, shown))
+			path = "/v1"
+		case l.Type == LLMProviderOllama && strings.HasSuffix(path, "/v1"):
+			warnings = append(warnings, fmt.Sprintf(warnMsgBaseURLV1Strip, shown))
+			path = strings.TrimSuffix(path, "/v1")
+		}
+	}
+
+	parsed.Path = path
+	parsed.RawPath = ""
+	return parsed.String(), warnings, nil
+}
+
+// normalizedBaseURL returns NormalizeBaseURL and logs its warnings with the
+// URL actually used
+func (l *LLMConfig) normalizedBaseURL() (string, error) {
+	baseURL, warnings, err := l.NormalizeBaseURL()
+	if err != nil {
+		return "", err
+	}
+	for _, warning := range warnings {
+		log.Printf("警告: %s", warning)
+	}
+	if len(warnings) > 0 {
+		log.Printf("LLM BaseURL已规范化为: %s", redactURL(baseURL))
+	}
+	return baseURL, nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name     string
+		llm      LLMConfig
+		expected string
+		warnings int
+	}{
+		{"openai完整地址", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://api.openai.com/v1"}, "https://api.openai.com/v1", 0},
+		{"openai去除末尾斜杠", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://api.openai.com/v1/"}, "https://api.openai.com/v1", 0},
+		{"openai去除多个斜杠和空白", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "  https://api.openai.com/v1// "}, "https://api.openai.com/v1", 0},
+		{"openai补全v1", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://api.deepseek.com"}, "https://api.deepseek.com/v1", 1},
+		{"openai补全v1并去除斜杠", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "http://127.0.0.1:11434/"}, "http://127.0.0.1:11434/v1", 1},
+		{"openai去除chat/completions", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://api.openai.com/v1/chat/completions"}, "https://api.openai.com/v1", 1},
+		{"openai去除chat/completions后补全v1", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "http://localhost:8000/chat/completions/"}, "http://localhost:8000/v1", 2},
+		{"openai保留其他路径", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://dashscope.aliyuncs.com/compatible-mode/v1"}, "https://dashscope.aliyuncs.com/compatible-mode/v1", 0},
+		{"openai保留非v1版本", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://open.bigmodel.cn/api/paas/v4/"}, "https://open.bigmodel.cn/api/paas/v4", 0},
+		{"openai保留查询参数", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://example.openai.azure.com/openai/deployments/gpt?api-version=2024-06-01"}, "https://example.openai.azure.com/openai/deployments/gpt?api-version=2024-06-01", 0},
+		{"openai关闭自动补全", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://api.deepseek.com/", AutoFixBaseURL: &disabled}, "https://api.deepseek.com", 0},
+		{"openai关闭自动补全仍去除接口路径", LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://api.openai.com/v1/chat/completions", AutoFixBaseURL: &disabled}, "https://api.openai.com/v1", 1},
+		{"ollama原生地址", LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434"}, "http://127.0.0.1:11434", 0},
+		{"ollama去除末尾斜杠", LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434/"}, "http://127.0.0.1:11434", 0},
+		{"ollama去除v1", LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434/v1/"}, "http://127.0.0.1:11434", 1},
+		{"ollama去除api/chat", LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434/api/chat"}, "http://127.0.0.1:11434", 1},
+		{"ollama保留代理路径", LLMConfig{Type: LLMProviderOllama, BaseURL: "https://gateway.example.com/ollama/"}, "https://gateway.example.com/ollama", 0},
+		{"ollama关闭自动补全保留v1", LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434/v1", AutoFixBaseURL: &disabled}, "http://127.0.0.1:11434/v1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL, warnings, err := tt.llm.NormalizeBaseURL()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, baseURL)
+			assert.Len(t, warnings, tt.warnings, "%v", warnings)
+		})
+	}
+}
+
+func TestNormalizeBaseURLInvalid(t *testing.T) {
+	for _, baseURL := range []string{
+		"api.openai.com/v1",
+		"ftp://api.example.com",
+		"localhost:11434",
+		"http://",
+		"https://user:secret@:bad",
+	} {
+		t.Run(baseURL, func(t *testing.T) {
+			llm := LLMConfig{Type: LLMProviderOpenAI, BaseURL: baseURL, Model: "gpt-4o-mini", APIKey: "key"}
+			_, _, err := llm.NormalizeBaseURL()
+			require.Error(t, err)
+			assert.NotContains(t, err.Error(), "secret")
+
+			errs := llm.ValidateDetailed()
+			require.Len(t, errs, 1)
+			assert.Equal(t, "base_url", errs[0].Field)
+		})
+	}
+}
+
+func TestGetModelUsesNormalizedBaseURL(t *testing.T) {
+	cfg := &Config{LLM: LLMConfig{Type: LLMProviderOpenAI, BaseURL: "ftp://api.example.com", Model: "gpt-4o-mini", APIKey: "key"}}
+	_, err := cfg.getSingleModel(t.Context())
+	assert.ErrorContains(t, err, "LLM BaseURL无效")
+}
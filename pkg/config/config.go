@@ -37,15 +37,20 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 
-	"github.com/LubyRuffy/einomcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/attachment"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
 	"github.com/LubyRuffy/mcpagent/pkg/models"
 	"github.com/cloudwego/eino-ext/components/model/ollama"
 	"github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 // LLM provider type constants define supported LLM providers
@@ -65,6 +70,17 @@ const (
 	defaultOllamaAPIKey  = "ollama"
 	defaultMaxStep       = 20
 	defaultSystemPrompt  = `你是精通互联网的信息收集专家，你可以多次调用提供的工具进行信息收集。当前时间是：{date}。`
+
+	defaultEnglishSystemPrompt = `You are an expert at gathering information from the internet. You may call the provided tools as many times as needed to collect information. The current date is {date}.`
+)
+
+// Language constants select the language of agent-facing strings such as
+// progress messages, truncation markers and the default system prompt
+const (
+	// LanguageZh is Simplified Chinese, the default language
+	LanguageZh = "zh"
+	// LanguageEn is English
+	LanguageEn = "en"
 )
 
 // Environment variable configuration
@@ -81,6 +97,8 @@ const (
 	errMsgLLMModelEmpty      = "LLM模型名称不能为空"
 	errMsgMaxStepInvalid     = "最大步骤数必须大于0"
 	errMsgConfigFileEmpty    = "配置文件路径不能为空"
+	errMsgLanguageInvalid    = "不支持的语言: %s，可选值为 zh 和 en"
+	errMsgToolsInvalid       = "工具列表无效: %w"
 )
 
 // MCPHubInterface defines the interface for MCP hub operations.
@@ -97,57 +115,144 @@ type MCPHubInterface interface {
 // This variable allows for dependency injection during testing by replacing
 // the factory function with a mock implementation.
 var mcpHubFactory = func(ctx context.Context, configFile string) (MCPHubInterface, error) {
-	return einomcphost.NewMCPHub(ctx, configFile)
+	return mcphost.NewMCPHub(ctx, configFile)
 }
 
 // mcpHubFromSettingsFactory is a factory function for creating MCPHub instances from settings.
 // This variable allows for dependency injection during testing by replacing
 // the factory function with a mock implementation.
-var mcpHubFromSettingsFactory = func(ctx context.Context, settings *einomcphost.MCPSettings) (MCPHubInterface, error) {
-	return einomcphost.NewMCPHubFromSettings(ctx, settings)
+var mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
+	return mcphost.NewMCPHubFromSettings(ctx, settings)
 }
 
 // MCPConfig represents MCP (Model Context Protocol) server configuration settings.
-// It contains either the path to MCP server configuration file or direct MCPServers configuration,
-// along with the list of tools to use.
+// It contains the path to MCP server configuration file and/or direct MCPServers configuration,
+// combined according to MergeStrategy, along with the list of tools to use.
 type MCPConfig struct {
-	ConfigFile string                               `mapstructure:"config_file" json:"config_file" yaml:"config_file"` // MCP服务器配置文件路径
-	MCPServers map[string]*einomcphost.ServerConfig `mapstructure:"mcp_servers" json:"mcp_servers" yaml:"mcp_servers"` // MCP服务器直接配置
-	Tools      []MCPToolConfig                      `mapstructure:"tools" json:"tools" yaml:"tools"`                   // 工具配置列表
+	ConfigFile string                           `mapstructure:"config_file" json:"config_file" yaml:"config_file"` // MCP服务器配置文件路径
+	MCPServers map[string]*mcphost.ServerConfig `mapstructure:"mcp_servers" json:"mcp_servers" yaml:"mcp_servers"` // MCP服务器直接配置
+	Tools      []MCPToolConfig                  `mapstructure:"tools" json:"tools" yaml:"tools"`                   // 工具配置列表
+
+	PrefixToolNames bool              `mapstructure:"prefix_tool_names" json:"prefix_tool_names" yaml:"prefix_tool_names"` // 是否以"<服务器>__<工具>"的名称向大模型展示MCP工具
+	NamePrefixes    map[string]string `mapstructure:"name_prefixes" json:"name_prefixes" yaml:"name_prefixes"`             // 服务器名称到工具名前缀的映射，设置后总是使用该前缀
+
+	MergeStrategy string `mapstructure:"merge_strategy" json:"merge_strategy,omitempty" yaml:"merge_strategy,omitempty"` // 配置文件与mcp_servers的合并策略：inline_only、file_only 或 merge（默认）
+
+	MissingToolPolicy string `mapstructure:"missing_tool_policy" json:"missing_tool_policy,omitempty" yaml:"missing_tool_policy,omitempty"` // 请求的工具不存在时的处理方式：fail（默认）、warn 或 ignore
+
+	MaxConcurrency map[string]int `mapstructure:"max_concurrency" json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"` // 服务器名称到同时执行的工具调用数的映射，未设置时stdio为1、sse/http不限制，负数表示不限制
+
+	ConnectTimeout int `mapstructure:"connect_timeout" json:"connect_timeout,omitempty" yaml:"connect_timeout,omitempty"` // 连接单个MCP服务器（启动、初始化并列出工具）的超时时间（秒），服务器设置了timeout时使用其timeout；0表示默认60秒
+
+	ToolTimeout *int `mapstructure:"tool_timeout" json:"tool_timeout,omitempty" yaml:"tool_timeout,omitempty"` // 单次MCP工具调用的超时时间（秒），覆盖所有服务器的timeout；未设置时使用服务器的timeout（默认30秒），0表示不限制
+
+	ToolDescriptionLanguage string `mapstructure:"tool_description_language" json:"tool_description_language,omitempty" yaml:"tool_description_language,omitempty"` // 向大模型提供该语言的工具描述（见工具的descriptions），未设置该语言描述的工具使用原描述
+
+	ToolsAutoSelect         bool    `mapstructure:"tools_auto_select" json:"tools_auto_select,omitempty" yaml:"tools_auto_select,omitempty"`                               // 没有选择工具时按任务内容自动选择相关的工具
+	ToolsAutoSelectMax      int     `mapstructure:"tools_auto_select_max" json:"tools_auto_select_max,omitempty" yaml:"tools_auto_select_max,omitempty"`                   // 自动选择的最大工具数，0表示使用默认值（5）
+	ToolsAutoSelectMinScore float64 `mapstructure:"tools_auto_select_min_score" json:"tools_auto_select_min_score,omitempty" yaml:"tools_auto_select_min_score,omitempty"` // 自动选择的工具的最低相关性得分，0表示使用默认值（0.5）
+
+	Credentials models.CredentialLookup `mapstructure:"-" json:"-" yaml:"-"` // 解析服务器配置中{{credential:NAME}}引用的方法，运行时设置
 }
 
 // MCPToolConfig 表示一个MCP工具的配置，包括服务器名称和工具名称
 type MCPToolConfig struct {
-	Server string `mapstructure:"server" json:"server" yaml:"server"` // 服务器名称
-	Name   string `mapstructure:"name" json:"name" yaml:"name"`       // 工具名称
+	Server       string `mapstructure:"server" json:"server" yaml:"server"`                                          // 服务器名称
+	Name         string `mapstructure:"name" json:"name" yaml:"name"`                                                // 工具名称
+	ResultFilter string `mapstructure:"result_filter" json:"result_filter,omitempty" yaml:"result_filter,omitempty"` // 应用于JSON结果的jq表达式，如 ".results[] | {ip, port}"
+
+	DiffAgainstPrevious bool `mapstructure:"diff_against_previous" json:"diff_against_previous,omitempty" yaml:"diff_against_previous,omitempty"` // 在结果前附加与相同参数上次结果的差异摘要
+
+	Descriptions map[string]string `mapstructure:"descriptions" json:"descriptions,omitempty" yaml:"descriptions,omitempty"` // 按语言的工具描述，如 {"zh": "..."}，tool_description_language 选择使用的语言
 }
 
 // Validate validates the MCP configuration.
-// It ensures that either the configuration file path is not empty or MCPServers is provided.
+// It ensures that the merge strategy is supported and that either the configuration
+// file path is not empty or MCPServers is provided. The file_only strategy always
+// requires the configuration file path.
 // Note: File existence is not checked here as it's more appropriate to check at runtime.
 //
 // Returns:
-//   - error: validation error if configuration is invalid, nil otherwise
+//   - error: validation error of the first invalid field, nil otherwise
 func (m *MCPConfig) Validate() error {
-	// 如果MCPServers不为nil，则优先使用MCPServers配置（即使为空）
-	if m.MCPServers != nil {
-		return nil
+	return m.ValidateDetailed().First()
+}
+
+// ValidateDetailed validates the MCP configuration like Validate, but reports
+// every invalid field instead of only the first. Every malformed tool is
+// reported as its own field, such as "tools[1]".
+//
+// Returns:
+//   - FieldErrors: All invalid fields, empty if the configuration is valid
+func (m *MCPConfig) ValidateDetailed() FieldErrors {
+	var errs FieldErrors
+	if err := m.validateMergeStrategy(); err != nil {
+		errs.add("merge_strategy", err)
+	}
+	if err := m.validateMissingToolPolicy(); err != nil {
+		errs.add("missing_tool_policy", err)
+	}
+	if err := m.validateConnectTimeout(); err != nil {
+		errs.add("connect_timeout", err)
+	}
+	if err := m.validateToolTimeout(); err != nil {
+		errs.add("tool_timeout", err)
+	}
+	if err := m.validateToolsAutoSelectMax(); err != nil {
+		errs.add("tools_auto_select_max", err)
+	}
+	if err := m.validateToolsAutoSelectMinScore(); err != nil {
+		errs.add("tools_auto_select_min_score", err)
+	}
+	errs = append(errs, ToolFieldErrors("tools", m.Tools)...)
+
+	// 不使用任何工具时不需要配置文件
+	if m.NoToolsRequested() {
+		return errs
+	}
+
+	// 只使用mcp_servers时不需要配置文件；合并时MCPServers不为nil即可（即使为空）
+	switch m.EffectiveMergeStrategy() {
+	case MergeStrategyInlineOnly:
+		return errs
+	case MergeStrategyMerge:
+		if m.MCPServers != nil {
+			return errs
+		}
 	}
 
 	// 否则检查ConfigFile是否为空
 	if strings.TrimSpace(m.ConfigFile) == "" {
-		return errors.New(errMsgMCPConfigFileEmpty)
+		errs.add("config_file", errors.New(errMsgMCPConfigFileEmpty))
 	}
-	return nil
+	return errs
 }
 
 // LLMConfig represents Large Language Model configuration settings.
 // It supports both OpenAI-compatible and Ollama providers with their respective settings.
+//
+// Fallbacks lists the models used in order when the model is unavailable,
+// see GetModel.
+//
+// The API key comes from exactly one of APIKey, APIKeyFile and APIKeyCmd.
+// APIKeyFile and APIKeyCmd are only read from configuration files and are
+// ignored by the web API, which must not be able to read files or run commands.
 type LLMConfig struct {
-	Type    string `mapstructure:"type" json:"type" yaml:"type"`             // 大模型类型，openai 或 ollama
-	BaseURL string `mapstructure:"base_url" json:"base_url" yaml:"base_url"` // 大模型API基础URL
-	Model   string `mapstructure:"model" json:"model" yaml:"model"`          // 大模型名称
-	APIKey  string `mapstructure:"api_key" json:"api_key" yaml:"api_key"`    // 大模型API密钥
+	Type       string `mapstructure:"type" json:"type" yaml:"type"`                        // 大模型类型，openai 或 ollama
+	BaseURL    string `mapstructure:"base_url" json:"base_url" yaml:"base_url"`            // 大模型API基础URL
+	Model      string `mapstructure:"model" json:"model" yaml:"model"`                     // 大模型名称
+	APIKey     string `mapstructure:"api_key" json:"api_key" yaml:"api_key"`               // 大模型API密钥
+	APIKeyFile string `mapstructure:"api_key_file" json:"-" yaml:"api_key_file,omitempty"` // 保存API密钥的文件，使用去除首尾空白后的内容
+	APIKeyCmd  string `mapstructure:"api_key_cmd" json:"-" yaml:"api_key_cmd,omitempty"`   // 创建模型时执行的命令，标准输出作为API密钥
+
+	CACertFile         string `mapstructure:"ca_cert_file" json:"ca_cert_file,omitempty" yaml:"ca_cert_file,omitempty"`                         // 额外信任的CA证书（PEM）
+	ClientCertFile     string `mapstructure:"client_cert_file" json:"client_cert_file,omitempty" yaml:"client_cert_file,omitempty"`             // mTLS客户端证书（PEM），需同时设置client_key_file
+	ClientKeyFile      string `mapstructure:"client_key_file" json:"client_key_file,omitempty" yaml:"client_key_file,omitempty"`                // mTLS客户端私钥（PEM）
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"` // 不校验服务端证书，仅用于测试
+
+	AutoFixBaseURL *bool `mapstructure:"auto_fix_base_url" json:"auto_fix_base_url,omitempty" yaml:"auto_fix_base_url,omitempty"` // 是否自动补全或去除BaseURL中的/v1，默认开启，见NormalizeBaseURL
+
+	Fallbacks []LLMConfig `mapstructure:"fallbacks" json:"fallbacks,omitempty" yaml:"fallbacks,omitempty"` // 备用模型，主模型不可用时按顺序改用
 }
 
 // Validate validates the LLM configuration.
@@ -157,19 +262,37 @@ type LLMConfig struct {
 // Returns:
 //   - error: validation error if configuration is invalid, nil otherwise
 func (l *LLMConfig) Validate() error {
+	return l.ValidateDetailed().First()
+}
+
+// ValidateDetailed validates the LLM configuration like Validate, but reports
+// every invalid field instead of only the first.
+//
+// Returns:
+//   - FieldErrors: All invalid fields, empty if the configuration is valid
+func (l *LLMConfig) ValidateDetailed() FieldErrors {
+	var errs FieldErrors
 	if strings.TrimSpace(l.Type) == "" {
-		return errors.New(errMsgLLMTypeEmpty)
-	}
-	if l.Type != LLMProviderOpenAI && l.Type != LLMProviderOllama {
-		return fmt.Errorf(errMsgLLMTypeUnsupported, l.Type)
+		errs.add("type", errors.New(errMsgLLMTypeEmpty))
+	} else if l.Type != LLMProviderOpenAI && l.Type != LLMProviderOllama {
+		errs.add("type", fmt.Errorf(errMsgLLMTypeUnsupported, l.Type))
 	}
 	if strings.TrimSpace(l.BaseURL) == "" {
-		return errors.New(errMsgLLMBaseURLEmpty)
+		errs.add("base_url", errors.New(errMsgLLMBaseURLEmpty))
+	} else if _, _, err := l.NormalizeBaseURL(); err != nil {
+		errs.add("base_url", err)
 	}
 	if strings.TrimSpace(l.Model) == "" {
-		return errors.New(errMsgLLMModelEmpty)
+		errs.add("model", errors.New(errMsgLLMModelEmpty))
 	}
-	return nil
+	if err := l.validateTLS(); err != nil {
+		errs.add("client_cert_file", err)
+	}
+	if err := l.validateAPIKeySources(); err != nil {
+		errs.add("api_key", err)
+	}
+	l.validateFallbacks(&errs)
+	return errs
 }
 
 // Config represents the main application configuration structure.
@@ -182,12 +305,41 @@ func (l *LLMConfig) Validate() error {
 //  3. Configuration file
 //  4. Default values (lowest priority)
 type Config struct {
-	Proxy        string         `mapstructure:"proxy" json:"proxy" yaml:"proxy"`                         // 代理配置，用于调试查看大模型的请求和响应
-	MCP          MCPConfig      `mapstructure:"mcp" json:"mcp" yaml:"mcp"`                               // MCP服务器配置
-	LLM          LLMConfig      `mapstructure:"llm" json:"llm" yaml:"llm"`                               // 大模型配置
-	SystemPrompt string         `mapstructure:"system_prompt" json:"system_prompt" yaml:"system_prompt"` // 系统提示词
-	MaxStep      int            `mapstructure:"max_step" json:"max_step" yaml:"max_step"`                // 领域
-	PlaceHolders map[string]any `mapstructure:"placeholders" json:"placeholders" yaml:"placeholders"`    // 占位符
+	Proxy         string              `mapstructure:"proxy" json:"proxy" yaml:"proxy"`                         // 代理配置，用于调试查看大模型的请求和响应
+	MCP           MCPConfig           `mapstructure:"mcp" json:"mcp" yaml:"mcp"`                               // MCP服务器配置
+	LLM           LLMConfig           `mapstructure:"llm" json:"llm" yaml:"llm"`                               // 大模型配置
+	SystemPrompt  string              `mapstructure:"system_prompt" json:"system_prompt" yaml:"system_prompt"` // 系统提示词
+	Language      string              `mapstructure:"language" json:"language" yaml:"language"`                // 面向用户的提示语言：zh（默认）或 en
+	MaxStep       int                 `mapstructure:"max_step" json:"max_step" yaml:"max_step"`                // 领域
+	PlaceHolders  map[string]any      `mapstructure:"placeholders" json:"placeholders" yaml:"placeholders"`    // 占位符
+	Output        OutputConfig        `mapstructure:"output" json:"output" yaml:"output"`                      // 结果后处理配置
+	Attachments   AttachmentConfig    `mapstructure:"attachments" json:"attachments" yaml:"attachments"`       // 任务附件配置
+	Artifacts     ArtifactConfig      `mapstructure:"artifacts" json:"artifacts" yaml:"artifacts"`             // 工具产物（图片、资源等）存储配置
+	TaskDir       TaskDirConfig       `mapstructure:"task_dir" json:"task_dir" yaml:"task_dir"`                // 每次运行的临时工作目录配置
+	Integrations  IntegrationsConfig  `mapstructure:"integrations" json:"integrations" yaml:"integrations"`    // 第三方服务集成配置
+	Audit         AuditConfig         `mapstructure:"audit" json:"audit" yaml:"audit"`                         // 本地审计日志配置
+	Notifications NotificationsConfig `mapstructure:"notifications" json:"notifications" yaml:"notifications"` // 任务事件的Webhook和文件通知
+	Agent         AgentConfig         `mapstructure:"agent" json:"agent" yaml:"agent"`                         // agent行为配置
+	Security      SecurityConfig      `mapstructure:"security" json:"security" yaml:"security"`                // 工具结果净化等安全配置
+	Replay        ReplayConfig        `mapstructure:"replay" json:"replay,omitempty" yaml:"replay,omitempty"`  // 录制和回放大模型请求及工具调用
+
+	TaskTimeout       int `mapstructure:"task_timeout" json:"task_timeout" yaml:"task_timeout"`                      // 任务整体超时时间（秒），0表示不限制
+	LLMRequestTimeout int `mapstructure:"llm_request_timeout" json:"llm_request_timeout" yaml:"llm_request_timeout"` // 单次大模型请求超时时间（秒），0表示不限制
+
+	MaxDuration       int     `mapstructure:"max_duration" json:"max_duration,omitempty" yaml:"max_duration,omitempty"`                         // 任务的时间预算（秒），与max_step先达到者为准，0表示不限制
+	MaxDurationWrapUp float64 `mapstructure:"max_duration_wrap_up" json:"max_duration_wrap_up,omitempty" yaml:"max_duration_wrap_up,omitempty"` // 剩余时间少于时间预算的该比例时要求大模型尽快给出答案，0表示使用默认值（0.15）
+
+	OutputSchema        string `mapstructure:"output_schema" json:"output_schema,omitempty" yaml:"output_schema,omitempty"`     // 最终输出需满足的JSON Schema（JSON文本），为空时不限制
+	OutputSchemaRepairs int    `mapstructure:"output_schema_repairs" json:"output_schema_repairs" yaml:"output_schema_repairs"` // 输出不符合Schema时要求大模型修复的最大轮数，0表示使用默认值（2）
+
+	PlanMode     bool `mapstructure:"plan_mode" json:"plan_mode,omitempty" yaml:"plan_mode,omitempty"`             // 执行前先不带工具生成执行计划并推送，再按计划执行
+	PlanApproval bool `mapstructure:"plan_approval" json:"plan_approval,omitempty" yaml:"plan_approval,omitempty"` // 计划模式下推送计划后等待用户批准再执行，未批准时任务失败
+
+	GuardPrompt  string `mapstructure:"guard_prompt" json:"guard_prompt,omitempty" yaml:"guard_prompt,omitempty"` // 始终位于系统提示词之前的约束，Web任务请求无法修改
+	Instructions string `mapstructure:"instructions" json:"instructions,omitempty" yaml:"instructions,omitempty"` // 系统提示词之后的任务说明，作为第二条系统消息发送
+
+	ContextCompression          string `mapstructure:"context_compression" json:"context_compression,omitempty" yaml:"context_compression,omitempty"`                               // 长工具结果的处理方式：为空时原样保留，summarize 时替换为摘要
+	ContextCompressionThreshold int    `mapstructure:"context_compression_threshold" json:"context_compression_threshold,omitempty" yaml:"context_compression_threshold,omitempty"` // 超过该字符数的工具结果会被压缩，0表示使用默认值（4000）
 }
 
 // Validate validates the entire configuration.
@@ -195,18 +347,61 @@ type Config struct {
 // that all interdependent settings are consistent.
 //
 // Returns:
-//   - error: validation error if any configuration section is invalid, nil otherwise
+//   - error: validation error of the first invalid field, nil otherwise
 func (c *Config) Validate() error {
-	if err := c.MCP.Validate(); err != nil {
-		return fmt.Errorf("MCP配置验证失败: %w", err)
+	return c.ValidateDetailed().First()
+}
+
+// ValidateDetailed validates the configuration like Validate, but reports
+// every invalid field instead of only the first, so that forms can show
+// all problems at once.
+//
+// Returns:
+//   - FieldErrors: All invalid fields, empty if the configuration is valid
+func (c *Config) ValidateDetailed() FieldErrors {
+	var errs FieldErrors
+	errs.nest("mcp", "MCP配置验证失败: %w", c.MCP.ValidateDetailed())
+	errs.nest("llm", "LLM配置验证失败: %w", c.LLM.ValidateDetailed())
+	if c.MaxStep <= 0 {
+		errs.add("max_step", errors.New(errMsgMaxStepInvalid))
 	}
-	if err := c.LLM.Validate(); err != nil {
-		return fmt.Errorf("LLM配置验证失败: %w", err)
+	switch c.EffectiveLanguage() {
+	case LanguageZh, LanguageEn:
+	default:
+		errs.add("language", fmt.Errorf(errMsgLanguageInvalid, c.Language))
 	}
-	if c.MaxStep <= 0 {
-		return errors.New(errMsgMaxStepInvalid)
+	if err := c.Output.Validate(); err != nil {
+		errs.add("output", fmt.Errorf("输出配置验证失败: %w", err))
 	}
-	return nil
+	if _, err := c.CompileOutputSchema(); err != nil {
+		errs.add("output_schema", err)
+	}
+	if c.OutputSchemaRepairs < 0 {
+		errs.add("output_schema_repairs", errors.New(errMsgOutputSchemaRepairs))
+	}
+	if err := c.Attachments.Validate(); err != nil {
+		errs.add("attachments", fmt.Errorf("附件配置验证失败: %w", err))
+	}
+	if err := c.Artifacts.Validate(); err != nil {
+		errs.add("artifacts", fmt.Errorf("产物配置验证失败: %w", err))
+	}
+	if err := c.TaskDir.Validate(); err != nil {
+		errs.add("task_dir", fmt.Errorf("任务目录配置验证失败: %w", err))
+	}
+	if err := c.Integrations.Validate(); err != nil {
+		errs.add("integrations", fmt.Errorf("集成配置验证失败: %w", err))
+	}
+	if err := c.Audit.Validate(); err != nil {
+		errs.add("audit", fmt.Errorf("审计日志配置验证失败: %w", err))
+	}
+	errs.nest("notifications", "通知配置验证失败: %w", c.Notifications.ValidateDetailed())
+	c.validateTimeouts(&errs)
+	c.validateContextCompression(&errs)
+	c.validatePromptLayers(&errs)
+	c.validateSecurity(&errs)
+	c.validateAgent(&errs)
+	c.validateReplay(&errs)
+	return errs
 }
 
 // GetModel creates and returns a configured LLM model instance.
@@ -216,6 +411,14 @@ func (c *Config) Validate() error {
 // The method handles provider-specific configuration and returns a model
 // that implements the ToolCallingChatModel interface for use with the agent framework.
 //
+// If fallback models are configured, the returned model switches to the next
+// fallback when a model cannot be reached, times out or fails with a server
+// error. Requests made with a context from WithModelTracker keep using the
+// model switched to and report the switches. With a context from
+// WithModelCache the model is taken from the cache when possible. With
+// replay.play_path the recorded responses are returned instead, with
+// replay.record_path the requests of the model are recorded.
+//
 // Parameters:
 //   - ctx: Context for the operation, used for cancellation and timeouts
 //
@@ -223,43 +426,103 @@ func (c *Config) Validate() error {
 //   - model.ToolCallingChatModel: Configured model instance ready for use
 //   - error: Error if model creation fails due to configuration or network issues
 func (c *Config) GetModel(ctx context.Context) (model.ToolCallingChatModel, error) {
+	if c.Replay.Plays() {
+		// 回放的模型有自己的进度，不放入缓存
+		player, err := c.Replay.player()
+		if err != nil {
+			return nil, err
+		}
+		return player.Model(), nil
+	}
+
+	var m model.ToolCallingChatModel
+	var err error
+	if cache := modelCacheFrom(ctx); cache != nil {
+		m, err = cache.GetModel(ctx, c, c.buildModel)
+	} else {
+		m, err = c.buildModel(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.Replay.recordModel(m), nil
+}
+
+// buildModel creates the model of the LLM configuration with its fallbacks
+func (c *Config) buildModel(ctx context.Context) (model.ToolCallingChatModel, error) {
+	primary, err := c.getSingleModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.LLM.Fallbacks) == 0 {
+		return primary, nil
+	}
+	return c.newFallbackModel(ctx, primary)
+}
+
+// getSingleModel creates the model of the LLM configuration without its fallbacks
+func (c *Config) getSingleModel(ctx context.Context) (model.ToolCallingChatModel, error) {
 	httpClient, err := c.createHTTPClient()
 	if err != nil {
 		return nil, fmt.Errorf("创建HTTP客户端失败: %w", err)
 	}
 
+	apiKey, err := c.LLM.ResolveAPIKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取LLM API密钥失败: %w", err)
+	}
+
 	switch c.LLM.Type {
 	case LLMProviderOpenAI:
-		return c.createOpenAIModel(ctx, httpClient)
+		return c.createOpenAIModel(ctx, httpClient, apiKey)
 	case LLMProviderOllama:
-		return c.createOllamaModel(ctx, httpClient)
+		return c.createOllamaModel(ctx, httpClient, apiKey)
 	default:
 		return nil, fmt.Errorf(errMsgLLMTypeUnsupported, c.LLM.Type)
 	}
 }
 
-// createHTTPClient creates an HTTP client with optional proxy configuration.
-// If proxy is configured and valid, it creates a client with proxy transport.
-// Otherwise, it returns the default HTTP client.
+// createHTTPClient creates an HTTP client with optional proxy and TLS configuration.
+// If proxy or TLS options are configured, it creates a client with a custom transport
+// combining both. If a model request timeout is configured, the client enforces it
+// on every request. Otherwise, it returns the default HTTP client.
 //
 // Returns:
-//   - *http.Client: HTTP client configured with proxy if specified
-//   - error: Error if proxy URL parsing fails
+//   - *http.Client: HTTP client configured with proxy, TLS and timeout if specified
+//   - error: Error if proxy URL parsing or loading the certificates fails
 func (c *Config) createHTTPClient() (*http.Client, error) {
 	proxyStr := strings.TrimSpace(c.Proxy)
-	if proxyStr == "" {
-		return http.DefaultClient, nil
-	}
+	timeout := c.LLMRequestTimeoutDuration()
 
-	proxyURL, err := url.Parse(proxyStr)
+	tlsConfig, err := c.LLM.TLSClientConfig()
 	if err != nil {
-		return nil, fmt.Errorf("解析代理URL错误: %w", err)
+		return nil, fmt.Errorf("加载TLS配置失败: %w", err)
+	}
+
+	if proxyStr == "" && tlsConfig == nil {
+		if timeout <= 0 {
+			return http.DefaultClient, nil
+		}
+		// 不能修改http.DefaultClient，创建新的客户端设置超时
+		return &http.Client{Timeout: timeout}, nil
+	}
+
+	// 基于默认传输层，保留连接池和环境变量中的代理设置
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyStr != "" {
+		proxyURL, err := url.Parse(proxyStr)
+		if err != nil {
+			return nil, fmt.Errorf("解析代理URL错误: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
 	}
 
 	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
-		},
+		Transport: transport,
+		Timeout:   timeout,
 	}, nil
 }
 
@@ -269,32 +532,47 @@ func (c *Config) createHTTPClient() (*http.Client, error) {
 // Parameters:
 //   - ctx: Context for the operation
 //   - httpClient: HTTP client to use for API requests
+//   - apiKey: Resolved API key
 //
 // Returns:
 //   - model.ToolCallingChatModel: Configured OpenAI model
 //   - error: Error if model creation fails
-func (c *Config) createOpenAIModel(ctx context.Context, httpClient *http.Client) (model.ToolCallingChatModel, error) {
+func (c *Config) createOpenAIModel(ctx context.Context, httpClient *http.Client, apiKey string) (model.ToolCallingChatModel, error) {
+	baseURL, err := c.LLM.normalizedBaseURL()
+	if err != nil {
+		return nil, err
+	}
 	return openai.NewChatModel(ctx, &openai.ChatModelConfig{
-		BaseURL:    c.LLM.BaseURL,
+		BaseURL:    baseURL,
 		Model:      c.LLM.Model,
-		APIKey:     c.LLM.APIKey,
+		APIKey:     apiKey,
 		HTTPClient: httpClient,
 	})
 }
 
 // createOllamaModel creates an Ollama model instance.
 // It configures the model with the provided HTTP client and LLM settings.
+// Ollama itself ignores API keys, a key from api_key_file or api_key_cmd is
+// sent as bearer token for Ollama instances behind an authenticating proxy.
 //
 // Parameters:
 //   - ctx: Context for the operation
 //   - httpClient: HTTP client to use for API requests
+//   - apiKey: Resolved API key
 //
 // Returns:
 //   - model.ToolCallingChatModel: Configured Ollama model
 //   - error: Error if model creation fails
-func (c *Config) createOllamaModel(ctx context.Context, httpClient *http.Client) (model.ToolCallingChatModel, error) {
+func (c *Config) createOllamaModel(ctx context.Context, httpClient *http.Client, apiKey string) (model.ToolCallingChatModel, error) {
+	baseURL, err := c.LLM.normalizedBaseURL()
+	if err != nil {
+		return nil, err
+	}
+	if c.LLM.HasExternalAPIKey() {
+		httpClient = withBearerToken(httpClient, apiKey)
+	}
 	return ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
-		BaseURL:    c.LLM.BaseURL,
+		BaseURL:    baseURL,
 		Model:      c.LLM.Model,
 		HTTPClient: httpClient,
 	})
@@ -306,6 +584,14 @@ func (c *Config) createOllamaModel(ctx context.Context, httpClient *http.Client)
 //
 // The method returns a cleanup function that MUST be called when the tools are no longer
 // needed to properly close MCP server connections and free resources.
+// When neither tools nor inline servers are configured the list is empty and
+// no MCP server is connected, see MCPConfig.NoToolsRequested.
+// Requested tools that no server provides are handled by MissingToolPolicy;
+// under the warn policy they are reported to the handler set with
+// WithMissingToolsHandler.
+//
+// With replay.play_path no MCP server is connected and the recorded tools
+// are returned, with replay.record_path the calls of the tools are recorded.
 //
 // Parameters:
 //   - ctx: Context for the operation, used for cancellation and timeouts
@@ -313,7 +599,7 @@ func (c *Config) createOllamaModel(ctx context.Context, httpClient *http.Client)
 // Returns:
 //   - []tool.BaseTool: List of available tools from all connected MCP servers
 //   - func(): Cleanup function to close MCP connections (must be called)
-//   - error: Error if tool retrieval fails
+//   - error: Error if tool retrieval fails, *MissingToolsError if requested tools do not exist
 //
 // Example:
 //
@@ -323,41 +609,77 @@ func (c *Config) createOllamaModel(ctx context.Context, httpClient *http.Client)
 //	}
 //	defer cleanup() // Important: always call cleanup
 func (c *Config) GetTools(ctx context.Context) ([]tool.BaseTool, func(), error) {
-	var einoTools []tool.BaseTool
+	if c.Replay.Plays() {
+		player, err := c.Replay.player()
+		if err != nil {
+			return nil, nil, err
+		}
+		return player.Tools(), func() {}, nil
+	}
+
+	tools, cleanup, err := c.connectTools(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.Replay.recordTools(ctx, tools), cleanup, nil
+}
+
+// connectTools returns the internal tools and the tools of the MCP servers, see GetTools
+func (c *Config) connectTools(ctx context.Context) ([]tool.BaseTool, func(), error) {
+	einoTools := []tool.BaseTool{}
 	var cleanupFuncs []func()
 
 	log.Printf("【工具调试】开始获取工具，工具配置: %+v", c.MCP.Tools)
 
-	// 1. 获取内置工具
-	internalTools, err := GetInternalTools(ctx, c.Proxy)
-	if err != nil {
-		return nil, nil, fmt.Errorf("获取内置工具失败: %w", err)
+	// 没有选择工具也没有配置服务器时仅由模型回答，不加载内置工具也不连接MCP服务器
+	noTools := c.MCP.NoToolsRequested()
+	if !noTools {
+		// 1. 获取内置工具
+		internalTools, err := GetInternalTools(ctx, c.Proxy, c.Integrations)
+		if err != nil {
+			return nil, nil, fmt.Errorf("获取内置工具失败: %w", err)
+		}
+
+		// 将内置工具添加到工具列表
+		einoTools = append(einoTools, c.MCP.describeInternalTools(ctx, c.MCP.filterInternalTools(ctx, internalTools))...)
+		log.Printf("【工具调试】添加了 %d 个内置工具", len(internalTools))
 	}
 
-	// 将内置工具添加到工具列表
-	einoTools = append(einoTools, internalTools...)
-	log.Printf("【工具调试】添加了 %d 个内置工具", len(internalTools))
+	// 任务带有附件时提供读取附件的工具
+	if c.Attachments.Dir != "" {
+		einoTools = append(einoTools, attachment.NewReadTool(c.Attachments.Dir, c.Attachments.MaxReadSize))
+	}
 
 	// 2. 连接MCP服务器并获取工具（如果配置了）
-	if len(c.MCP.Tools) > 0 || len(c.MCP.MCPServers) > 0 || c.MCP.ConfigFile != "" {
+	if !noTools {
 		// 连接mcp服务器
 		var mcpHub MCPHubInterface
+		var servers map[string]*mcphost.ServerConfig
 		var err error
 
-		// 如果MCPServers不为nil，则优先使用MCPServers配置（即使为空）
-		if c.MCP.MCPServers != nil {
-			// 创建MCPSettings
-			settings := &einomcphost.MCPSettings{
-				MCPServers: c.MCP.MCPServers,
-			}
-
-			// 使用MCPSettings创建MCPHub
-			mcpHub, err = mcpHubFromSettingsFactory(ctx, settings)
-			log.Printf("【工具调试】使用MCPSettings创建Hub，服务器数量: %d", len(c.MCP.MCPServers))
-		} else {
-			// 否则使用ConfigFile
+		if c.MCP.usesConfigFileOnly() && c.TaskDir.Dir == "" && c.MCP.Credentials == nil && c.MCP.ConnectTimeout == 0 {
+			// 服务器全部来自配置文件时直接使用ConfigFile
 			mcpHub, err = mcpHubFactory(ctx, c.MCP.ConfigFile)
 			log.Printf("【工具调试】使用ConfigFile创建Hub: %s", c.MCP.ConfigFile)
+		} else {
+			// 否则按合并策略合并配置文件和MCPServers
+			var warnings []string
+			servers, warnings, err = c.MCP.ResolveServers()
+			for _, warning := range warnings {
+				log.Printf("警告: %s", warning)
+			}
+			if err == nil && c.TaskDir.Dir != "" {
+				// 替换stdio服务器参数和环境变量中的{task_dir}
+				servers = substituteTaskDir(servers, c.TaskDir.Dir)
+			}
+			if err == nil {
+				// 连接前才解析{{credential:NAME}}，配置快照中只保存引用
+				servers, err = c.MCP.resolveCredentials(servers)
+			}
+			if err == nil {
+				// 使用MCPSettings创建MCPHub
+				mcpHub, err = mcpHubFromSettingsFactory(ctx, c.MCP.settings(servers))
+			}
 		}
 
 		if err != nil {
@@ -374,6 +696,7 @@ func (c *Config) GetTools(ctx context.Context) ([]tool.BaseTool, func(), error)
 			// 从配置中提取工具名称列表
 			var toolNameList []string
 			var nonInnerToolNameList []string
+			var nonInnerTools []MCPToolConfig
 			log.Printf("【工具调试】开始处理工具列表，工具数量: %d", len(c.MCP.Tools))
 
 			for i, toolConfig := range c.MCP.Tools {
@@ -386,6 +709,7 @@ func (c *Config) GetTools(ctx context.Context) ([]tool.BaseTool, func(), error)
 				// 过滤掉inner服务器的工具，因为它们已经通过GetInternalTools获取
 				if toolConfig.Server != "inner" && !strings.HasPrefix(toolKey, "inner_") {
 					nonInnerToolNameList = append(nonInnerToolNameList, toolKey)
+					nonInnerTools = append(nonInnerTools, toolConfig)
 				}
 
 				log.Printf("【工具调试】生成工具键: %s", toolKey)
@@ -396,13 +720,30 @@ func (c *Config) GetTools(ctx context.Context) ([]tool.BaseTool, func(), error)
 
 			// 仅获取非inner工具
 			if len(nonInnerToolNameList) > 0 {
-				// 获取MCP工具
-				mcpTools, err := mcpHub.GetEinoTools(ctx, nonInnerToolNameList)
+				// 获取MCP工具，不存在的工具按missing_tool_policy处理
+				mcpTools, foundTools, missing, err := getMCPTools(ctx, mcpHub, nonInnerTools)
+				if policyErr := c.MCP.applyMissingToolPolicy(ctx, missing, len(foundTools)); policyErr != nil {
+					for _, fn := range cleanupFuncs {
+						fn()
+					}
+					return nil, nil, policyErr
+				}
 				if err != nil {
 					log.Printf("获取MCP工具失败: %v，将仅使用内置工具", err)
 				} else {
-					// 将MCP工具添加到工具列表
-					einoTools = append(einoTools, mcpTools...)
+					nonInnerTools = foundTools
+					// 将MCP工具添加到工具列表，返回图片等内容的工具结果保存为产物，并使用配置的调用超时
+					mcpTools, err = c.Artifacts.wrapTools(mcpHub, mcpTools, nonInnerTools, c.MCP.toolTimeouts(servers))
+					if err != nil {
+						for _, fn := range cleanupFuncs {
+							fn()
+						}
+						return nil, nil, err
+					}
+					mcpTools = c.MCP.filterTools(mcpTools, nonInnerTools)
+					mcpTools = c.MCP.limitConcurrency(mcpTools, nonInnerTools, servers)
+					mcpTools = c.MCP.describeTools(mcpTools, nonInnerTools)
+					einoTools = append(einoTools, c.MCP.presentTools(mcpTools, nonInnerTools)...)
 					log.Printf("【工具调试】添加了 %d 个MCP工具", len(mcpTools))
 				}
 			}
@@ -421,45 +762,57 @@ func (c *Config) GetTools(ctx context.Context) ([]tool.BaseTool, func(), error)
 }
 
 // SaveConfig saves the current configuration to the specified file.
-// It uses viper to serialize the configuration to YAML format with proper formatting.
-// The configuration is validated before saving to ensure consistency.
+// Only the Config struct itself is serialized to YAML, so the file never contains
+// keys from other configuration sources. It is safe for concurrent use.
+//
+// The file is written to a temporary file in the same directory and renamed
+// over the target, so that an interrupted save never leaves a truncated
+// file. The permissions of an existing file are kept; a new file is only
+// readable by its owner, since the configuration may contain API keys.
 //
 // Parameters:
 //   - configFile: Path to the configuration file to save (must not be empty)
 //
 // Returns:
-//   - error: Error if saving fails due to validation, file system issues, or serialization problems
+//   - error: Error if saving fails due to file system issues or serialization problems
 func (c *Config) SaveConfig(configFile string) error {
 	if strings.TrimSpace(configFile) == "" {
 		return errors.New(errMsgConfigFileEmpty)
 	}
 
-	// 将配置结构体的值设置到viper中
-	c.setViperValues()
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("序列化配置失败: %w", err)
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(configFile); err == nil {
+		mode = info.Mode().Perm()
+	}
 
-	if err := viper.WriteConfigAs(configFile); err != nil {
+	tmp, err := os.CreateTemp(filepath.Dir(configFile), "."+filepath.Base(configFile)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("保存配置文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存配置文件失败: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存配置文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存配置文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), configFile); err != nil {
 		return fmt.Errorf("保存配置文件失败: %w", err)
 	}
 
 	return nil
 }
 
-// setViperValues sets configuration values in viper for serialization.
-// This method maps the configuration struct fields to viper keys for proper YAML output.
-func (c *Config) setViperValues() {
-	viper.Set("proxy", c.Proxy)
-	viper.Set("mcp.config_file", c.MCP.ConfigFile)
-	viper.Set("mcp.mcp_servers", c.MCP.MCPServers)
-	viper.Set("mcp.tools", c.MCP.Tools)
-	viper.Set("llm.type", c.LLM.Type)
-	viper.Set("llm.base_url", c.LLM.BaseURL)
-	viper.Set("llm.model", c.LLM.Model)
-	viper.Set("llm.api_key", c.LLM.APIKey)
-	viper.Set("system_prompt", c.SystemPrompt)
-	viper.Set("max_step", c.MaxStep)
-	viper.Set("placeholders", c.PlaceHolders)
-}
-
 // NewDefaultConfig returns a default configuration with sensible defaults.
 // This function creates a configuration that can be used as a starting point
 // or fallback when no configuration file is available.
@@ -476,7 +829,7 @@ func (c *Config) setViperValues() {
 func NewDefaultConfig() *Config {
 	return &Config{
 		MCP: MCPConfig{
-			MCPServers: make(map[string]*einomcphost.ServerConfig),
+			MCPServers: make(map[string]*mcphost.ServerConfig),
 			Tools:      []MCPToolConfig{},
 		},
 		LLM: LLMConfig{
@@ -491,6 +844,29 @@ func NewDefaultConfig() *Config {
 	}
 }
 
+// EffectiveLanguage returns the configured language, defaulting to zh
+func (c *Config) EffectiveLanguage() string {
+	if strings.TrimSpace(c.Language) == "" {
+		return LanguageZh
+	}
+	return c.Language
+}
+
+// DefaultSystemPrompt returns the default system prompt for a language.
+// Unknown or empty languages fall back to the Chinese prompt.
+//
+// Parameters:
+//   - language: LanguageZh or LanguageEn
+//
+// Returns:
+//   - string: System prompt template containing the {date} placeholder
+func DefaultSystemPrompt(language string) string {
+	if language == LanguageEn {
+		return defaultEnglishSystemPrompt
+	}
+	return defaultSystemPrompt
+}
+
 // LoadConfig loads configuration from file or creates default configuration.
 // It supports both specified config file path and automatic discovery of config files.
 // The function follows this priority order:
@@ -517,67 +893,126 @@ func NewDefaultConfig() *Config {
 //	// Auto-discover config file
 //	cfg, err := LoadConfig("")
 func LoadConfig(configFile string) (*Config, error) {
+	config, _, err := LoadConfigWithSources(configFile)
+	return config, err
+}
+
+// LoadConfigWithSources loads a configuration like LoadConfig and also
+// returns which fields were read from the file and which from environment
+// variables. The sources can be extended with SetField by the layers merged
+// over the configuration, such as command line flags.
+//
+// Parameters:
+//   - configFile: Path to the configuration file, or empty for auto-discovery
+//
+// Returns:
+//   - *Config: Loaded and validated configuration
+//   - ConfigSource: Sources of the fields, never nil
+//   - error: Error if loading or validation fails
+func LoadConfigWithSources(configFile string) (*Config, ConfigSource, error) {
+	config, sources, err := decodeConfigWithSources(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 验证配置
+	if err := config.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	return config, sources, nil
+}
+
+// decodeConfig reads a configuration file over the default configuration without validating it
+func decodeConfig(configFile string) (*Config, error) {
+	config, _, err := decodeConfigWithSources(configFile)
+	return config, err
+}
+
+// decodeConfigWithSources reads a configuration file like decodeConfig and returns the sources of its fields
+func decodeConfigWithSources(configFile string) (*Config, ConfigSource, error) {
 	config := NewDefaultConfig()
 
-	if err := setupViper(configFile); err != nil {
-		return nil, fmt.Errorf("设置viper失败: %w", err)
+	// 每次调用使用独立的viper实例，避免并发调用之间共享全局状态
+	v, err := setupViper(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("设置viper失败: %w", err)
 	}
 
-	if err := readConfigFile(); err != nil {
+	if err := readConfigFile(v); err != nil {
 		log.Println("未找到配置文件，使用默认配置")
 	}
 
-	// 将配置文件内容解析到结构体
-	if err := viper.Unmarshal(config); err != nil {
-		return nil, fmt.Errorf("解析配置文件错误: %w", err)
+	// 将配置文件内容解析到结构体，工具可以写成 "server:tool" 字符串
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		toolSpecHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+	if err := v.Unmarshal(config, hook); err != nil {
+		return nil, nil, fmt.Errorf("解析配置文件错误: %w", err)
 	}
 
-	// 验证配置
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("配置验证失败: %w", err)
+	// 从文件或命令获取密钥时不使用默认的api_key
+	if !v.IsSet("llm.api_key") && config.LLM.HasExternalAPIKey() {
+		config.LLM.APIKey = ""
+	}
+
+	// 未配置系统提示词时使用所选语言的默认提示词
+	if !v.IsSet("system_prompt") {
+		config.SystemPrompt = DefaultSystemPrompt(config.Language)
 	}
 
-	return config, nil
+	return config, viperSources(v), nil
 }
 
-// setupViper configures viper for config file reading.
+// setupViper creates a viper instance configured for config file reading.
 // It sets up file paths, environment variable handling, and other viper settings
 // based on whether a specific config file is provided or auto-discovery is needed.
+// Environment variables use the MCPHOST prefix, nested keys are joined with "_",
+// e.g. MCPHOST_LLM_API_KEY overrides llm.api_key.
 //
 // Parameters:
 //   - configFile: Specific config file path, or empty for auto-discovery
 //
 // Returns:
+//   - *viper.Viper: Private viper instance for a single load
 //   - error: Error if viper setup fails
-func setupViper(configFile string) error {
+func setupViper(configFile string) (*viper.Viper, error) {
+	v := viper.New()
+
 	configFileStr := strings.TrimSpace(configFile)
 	if configFileStr != "" {
-		viper.SetConfigFile(configFileStr)
+		v.SetConfigFile(configFileStr)
 	} else {
-		viper.SetConfigName(defaultConfigName)
-		viper.SetConfigType(defaultConfigType)
+		v.SetConfigName(defaultConfigName)
+		v.SetConfigType(defaultConfigType)
 
 		// 设置查找配置文件的路径
-		viper.AddConfigPath(".")
-		viper.AddConfigPath("./config")
-		viper.AddConfigPath("$HOME/.mcphost")
+		v.AddConfigPath(".")
+		v.AddConfigPath("./config")
+		v.AddConfigPath("$HOME/.mcphost")
 	}
 
 	// 读取环境变量
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix(envPrefix)
+	v.AutomaticEnv()
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
-	return nil
+	return v, nil
 }
 
 // readConfigFile attempts to read the configuration file.
 // It handles common file reading errors gracefully and distinguishes between
 // missing files (which is acceptable) and actual parsing errors.
 //
+// Parameters:
+//   - v: Viper instance created by setupViper
+//
 // Returns:
 //   - error: Error if file reading fails for reasons other than file not found
-func readConfigFile() error {
-	if err := viper.ReadInConfig(); err != nil {
+func readConfigFile(v *viper.Viper) error {
+	if err := v.ReadInConfig(); err != nil {
 		// 如果找不到配置文件，返回错误但不是致命错误
 		var configFileNotFoundError viper.ConfigFileNotFoundError
 		var pathError *fs.PathError
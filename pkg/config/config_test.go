@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/LubyRuffy/einomcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/golang/mock/gomock"
@@ -167,15 +171,11 @@ func TestLoadConfigWithDefaultPath(t *testing.T) {
 	err = os.Chdir(tempDir)
 	require.NoError(t, err)
 
-	// 测试结束后恢复工作目录并重置viper状态
+	// 测试结束后恢复工作目录
 	defer func() {
 		_ = os.Chdir(currentDir)
-		viper.Reset()
 	}()
 
-	// 重置viper状态
-	viper.Reset()
-
 	// 在临时目录中创建配置文件
 	configContent := `
 llm:
@@ -217,13 +217,8 @@ func TestLoadConfigDefault(t *testing.T) {
 	defer func() {
 		// 恢复原始工作目录
 		_ = os.Chdir(originalDir)
-		// 重置viper状态
-		viper.Reset()
 	}()
 
-	// 重置viper状态
-	viper.Reset()
-
 	// 测试通过 LoadConfig 获取默认配置（在没有任何配置文件的目录下）
 	cfg, err := LoadConfig("")
 	require.NoError(t, err)
@@ -296,7 +291,7 @@ func TestSaveConfig(t *testing.T) {
 		Proxy: "http://save-test-proxy.com",
 		MCP: MCPConfig{
 			ConfigFile: "", // 空的ConfigFile
-			MCPServers: map[string]*einomcphost.ServerConfig{
+			MCPServers: map[string]*mcphost.ServerConfig{
 				"server1": {
 					TransportType: "stdio",
 					Command:       "echo",
@@ -465,13 +460,8 @@ func TestLoadConfigErrors(t *testing.T) {
 	defer func() {
 		// 恢复原始工作目录
 		_ = os.Chdir(originalDir)
-		// 重置viper状态
-		viper.Reset()
 	}()
 
-	// 重置viper状态
-	viper.Reset()
-
 	// 测试配置文件解析错误
 	configPath := filepath.Join(tempDir, "invalid_config.yaml")
 
@@ -497,14 +487,12 @@ max_step: 0
 	assert.Contains(t, err.Error(), "配置验证失败")
 
 	// 测试文件不存在的情况 - 应该使用默认配置
-	viper.Reset()
 	nonExistentPath := filepath.Join(tempDir, "non_existent.yaml")
 	cfg, err = LoadConfig(nonExistentPath)
 	assert.NoError(t, err) // 应该返回默认配置，而不是错误
 	assert.NotNil(t, cfg)
 
 	// 测试路径错误的情况（例如，目录而不是文件）- 应该使用默认配置
-	viper.Reset()
 	err = os.Mkdir(filepath.Join(tempDir, "config_dir"), 0755)
 	require.NoError(t, err)
 	dirPath := filepath.Join(tempDir, "config_dir")
@@ -566,7 +554,7 @@ func TestGetTools(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, tools)
 	assert.NotNil(t, cleanup)
-	assert.Len(t, tools, 4) // 2个内置工具 + 2个MCP工具
+	assert.Len(t, tools, 4) // 2个内置工具（未配置FOFA Key） + 2个MCP工具
 
 	// 执行清理函数
 	cleanup()
@@ -581,13 +569,13 @@ func TestGetTools(t *testing.T) {
 	mockMCPHub.EXPECT().CloseServers().Return(nil)
 
 	// 替换 mcpHubFromSettingsFactory 函数
-	mcpHubFromSettingsFactory = func(ctx context.Context, settings *einomcphost.MCPSettings) (MCPHubInterface, error) {
+	mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
 		return mockMCPHub, nil
 	}
 
 	cfgWithServers := &Config{
 		MCP: MCPConfig{
-			MCPServers: map[string]*einomcphost.ServerConfig{
+			MCPServers: map[string]*mcphost.ServerConfig{
 				"server1": {
 					TransportType: "stdio",
 					Command:       "echo",
@@ -610,7 +598,7 @@ func TestGetTools(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, tools)
 	assert.NotNil(t, cleanup)
-	assert.Len(t, tools, 4) // 2个内置工具 + 2个MCP工具
+	assert.Len(t, tools, 4) // 2个内置工具（未配置FOFA Key） + 2个MCP工具
 
 	// 执行清理函数
 	cleanup()
@@ -641,19 +629,19 @@ func TestGetTools(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, tools)
 	assert.NotNil(t, cleanup)
-	assert.Len(t, tools, 2) // 仅内置工具
+	assert.Len(t, tools, 2) // 仅内置工具，未配置FOFA Key时不提供fofa_search
 	if cleanup != nil {
 		cleanup()
 	}
 
 	// 测试 NewMCPHubFromSettings 失败的情况
-	mcpHubFromSettingsFactory = func(ctx context.Context, settings *einomcphost.MCPSettings) (MCPHubInterface, error) {
+	mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
 		return nil, fmt.Errorf("模拟 NewMCPHubFromSettings 失败")
 	}
 
 	cfgWithServers = &Config{
 		MCP: MCPConfig{
-			MCPServers: map[string]*einomcphost.ServerConfig{
+			MCPServers: map[string]*mcphost.ServerConfig{
 				"server1": {
 					TransportType: "stdio",
 					Command:       "echo",
@@ -677,7 +665,7 @@ func TestGetTools(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, tools)
 	assert.NotNil(t, cleanup)
-	assert.Len(t, tools, 2) // 仅内置工具
+	assert.Len(t, tools, 2) // 仅内置工具，未配置FOFA Key时不提供fofa_search
 	if cleanup != nil {
 		cleanup()
 	}
@@ -712,7 +700,7 @@ func TestGetTools(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, tools)
 	assert.NotNil(t, cleanup)
-	assert.Len(t, tools, 2) // 仅内置工具
+	assert.Len(t, tools, 2) // 仅内置工具，未配置FOFA Key时不提供fofa_search
 	if cleanup != nil {
 		cleanup()
 	}
@@ -740,7 +728,7 @@ func TestMCPConfigValidate(t *testing.T) {
 	// 测试有效配置 - 使用MCPServers
 	validMCPWithServers := MCPConfig{
 		ConfigFile: "", // 空的ConfigFile
-		MCPServers: map[string]*einomcphost.ServerConfig{
+		MCPServers: map[string]*mcphost.ServerConfig{
 			"server1": {
 				TransportType: "stdio",
 				Command:       "echo",
@@ -774,7 +762,7 @@ func TestMCPConfigValidate(t *testing.T) {
 	// 测试有效配置 - 空的MCPServers map（但不是nil）
 	validEmptyMCP := MCPConfig{
 		ConfigFile: "",
-		MCPServers: map[string]*einomcphost.ServerConfig{}, // 空但不是nil
+		MCPServers: map[string]*mcphost.ServerConfig{}, // 空但不是nil
 		Tools: []MCPToolConfig{
 			{
 				Server: "server1",
@@ -877,7 +865,7 @@ func TestConfigValidate(t *testing.T) {
 	validConfigWithServers := Config{
 		MCP: MCPConfig{
 			ConfigFile: "", // 空的ConfigFile
-			MCPServers: map[string]*einomcphost.ServerConfig{
+			MCPServers: map[string]*mcphost.ServerConfig{
 				"server1": {
 					TransportType: "stdio",
 					Command:       "echo",
@@ -904,7 +892,8 @@ func TestConfigValidate(t *testing.T) {
 	// 测试 MCP 配置无效
 	invalidMCPConfig := Config{
 		MCP: MCPConfig{
-			ConfigFile: "", // 无效
+			ConfigFile: "", // 选择了工具时无效
+			Tools:      []MCPToolConfig{{Server: "server1", Name: "tool1"}},
 		},
 		LLM: LLMConfig{
 			Type:    LLMProviderOpenAI,
@@ -1015,13 +1004,13 @@ func TestCreateModels(t *testing.T) {
 	httpClient := http.DefaultClient
 
 	// 测试创建 OpenAI 模型
-	model, err := cfg.createOpenAIModel(ctx, httpClient)
+	model, err := cfg.createOpenAIModel(ctx, httpClient, cfg.LLM.APIKey)
 	assert.NoError(t, err)
 	assert.NotNil(t, model)
 
 	// 测试创建 Ollama 模型
 	cfg.LLM.Type = LLMProviderOllama
-	model, err = cfg.createOllamaModel(ctx, httpClient)
+	model, err = cfg.createOllamaModel(ctx, httpClient, cfg.LLM.APIKey)
 	assert.NoError(t, err)
 	assert.NotNil(t, model)
 }
@@ -1045,24 +1034,21 @@ func TestGetDefaultConfig(t *testing.T) {
 
 // TestSetupViper 测试 viper 配置
 func TestSetupViper(t *testing.T) {
-	// 重置 viper 状态
-	viper.Reset()
-
 	// 测试指定配置文件
 	configFile := "test_config.yaml"
-	err := setupViper(configFile)
+	v, err := setupViper(configFile)
 	assert.NoError(t, err)
+	assert.NotNil(t, v)
 
 	// 测试不指定配置文件
-	err = setupViper("")
+	other, err := setupViper("")
 	assert.NoError(t, err)
+	// 每次调用返回独立的实例
+	assert.NotSame(t, v, other)
 }
 
 // TestReadConfigFile 测试配置文件读取
 func TestReadConfigFile(t *testing.T) {
-	// 重置 viper 状态
-	viper.Reset()
-
 	// 创建临时配置文件
 	tempDir := t.TempDir()
 	configPath := filepath.Join(tempDir, "test_config.yaml")
@@ -1078,16 +1064,17 @@ max_step: 10
 	require.NoError(t, err)
 
 	// 设置 viper 来读取这个文件
-	viper.SetConfigFile(configPath)
+	v := viper.New()
+	v.SetConfigFile(configPath)
 
 	// 测试成功读取配置文件
-	err = readConfigFile()
+	err = readConfigFile(v)
 	assert.NoError(t, err)
 
 	// 测试配置文件不存在的情况
-	viper.Reset()
-	viper.SetConfigFile("/non/existent/path/config.yaml")
-	err = readConfigFile()
+	v = viper.New()
+	v.SetConfigFile("/non/existent/path/config.yaml")
+	err = readConfigFile(v)
 	assert.Error(t, err) // 应该返回错误，但是错误类型是已知的
 }
 
@@ -1148,7 +1135,7 @@ func TestMCPConfigValidateWithWhitespace(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			config := &MCPConfig{
 				ConfigFile: tt.configFile,
-				Tools:      []MCPToolConfig{},
+				Tools:      []MCPToolConfig{{Server: "server1", Name: "tool1"}},
 			}
 
 			err := config.Validate()
@@ -1303,7 +1290,7 @@ func TestConfigValidateMaxStep(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			config := &Config{
 				MCP: MCPConfig{
-					MCPServers: make(map[string]*einomcphost.ServerConfig),
+					MCPServers: make(map[string]*mcphost.ServerConfig),
 					Tools:      []MCPToolConfig{},
 				},
 				LLM: LLMConfig{
@@ -1326,6 +1313,55 @@ func TestConfigValidateMaxStep(t *testing.T) {
 	}
 }
 
+// TestConfigLanguage tests language validation and the default system prompt of each language
+func TestConfigLanguage(t *testing.T) {
+	cfg := NewDefaultConfig()
+	assert.Equal(t, LanguageZh, cfg.EffectiveLanguage())
+
+	cfg.Language = LanguageEn
+	assert.NoError(t, cfg.Validate())
+	cfg.Language = "fr"
+	assert.ErrorContains(t, cfg.Validate(), "不支持的语言")
+
+	assert.Equal(t, defaultSystemPrompt, DefaultSystemPrompt(""))
+	assert.Contains(t, DefaultSystemPrompt(LanguageEn), "The current date is {date}")
+
+	// 未配置system_prompt时使用所选语言的默认提示词，配置后保持不变
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("language: en\nmcp:\n  config_file: mcpservers.json\n"), 0644))
+	loaded, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, LanguageEn, loaded.Language)
+	assert.Equal(t, defaultEnglishSystemPrompt, loaded.SystemPrompt)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("language: en\nsystem_prompt: 自定义提示词\nmcp:\n  config_file: mcpservers.json\n"), 0644))
+	loaded, err = LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "自定义提示词", loaded.SystemPrompt)
+}
+
+func TestConfigOutputSchema(t *testing.T) {
+	cfg := NewDefaultConfig()
+	assert.False(t, cfg.HasOutputSchema())
+	assert.Equal(t, defaultOutputSchemaRepairs, cfg.EffectiveOutputSchemaRepairs())
+
+	cfg.OutputSchema = `{"type": "object", "properties": {"firstName": {"type": "string"}}}`
+	require.NoError(t, cfg.Validate())
+	schema, err := cfg.CompileOutputSchema()
+	require.NoError(t, err)
+	assert.Contains(t, schema.Properties, "firstName")
+
+	cfg.OutputSchema = `{"type": "object"`
+	assert.ErrorContains(t, cfg.Validate(), "输出JSON Schema无效")
+	cfg.OutputSchema = `{"type": "unknown"}`
+	assert.ErrorContains(t, cfg.Validate(), "输出JSON Schema无效")
+
+	cfg.OutputSchema = `{"type": "object"}`
+	cfg.OutputSchemaRepairs = -1
+	assert.ErrorContains(t, cfg.Validate(), errMsgOutputSchemaRepairs)
+}
+
 // TestCreateHTTPClientWithEmptyProxy tests HTTP client creation with empty proxy
 func TestCreateHTTPClientWithEmptyProxy(t *testing.T) {
 	config := &Config{Proxy: ""}
@@ -1379,18 +1415,328 @@ func TestSaveConfigWithWhitespacePath(t *testing.T) {
 
 // TestSetupViperWithEmptyConfigFile tests setupViper with empty config file
 func TestSetupViperWithEmptyConfigFile(t *testing.T) {
-	err := setupViper("")
+	_, err := setupViper("")
 	assert.NoError(t, err)
 }
 
 // TestSetupViperWithWhitespaceConfigFile tests setupViper with whitespace config file
 func TestSetupViperWithWhitespaceConfigFile(t *testing.T) {
-	err := setupViper("   ")
+	_, err := setupViper("   ")
 	assert.NoError(t, err)
 }
 
 // TestSetupViperWithValidConfigFile tests setupViper with valid config file
 func TestSetupViperWithValidConfigFile(t *testing.T) {
-	err := setupViper("test-config.yaml")
+	_, err := setupViper("test-config.yaml")
 	assert.NoError(t, err)
 }
+
+// TestCreateHTTPClientWithTimeout tests that the model request timeout is set on the HTTP client
+func TestCreateHTTPClientWithTimeout(t *testing.T) {
+	cfg := &Config{LLMRequestTimeout: 5}
+	client, err := cfg.createHTTPClient()
+	require.NoError(t, err)
+	assert.NotSame(t, http.DefaultClient, client, "不能修改默认HTTP客户端")
+	assert.Equal(t, 5*time.Second, client.Timeout)
+	assert.Zero(t, http.DefaultClient.Timeout)
+
+	cfg = &Config{Proxy: "http://proxy.example.com:8080", LLMRequestTimeout: 5}
+	client, err = cfg.createHTTPClient()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, client.Timeout)
+	assert.NotNil(t, client.Transport)
+
+	cfg = &Config{}
+	client, err = cfg.createHTTPClient()
+	require.NoError(t, err)
+	assert.Same(t, http.DefaultClient, client)
+}
+
+// TestValidateTimeouts tests validation of the task and model request timeouts
+func TestValidateTimeouts(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.MCP.ConfigFile = "mcpservers.json"
+	cfg.TaskTimeout = 60
+	cfg.LLMRequestTimeout = 30
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, time.Minute, cfg.TaskTimeoutDuration())
+	assert.Equal(t, 30*time.Second, cfg.LLMRequestTimeoutDuration())
+
+	cfg.TaskTimeout = -1
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errMsgTaskTimeoutInvalid)
+
+	cfg.TaskTimeout = 0
+	cfg.LLMRequestTimeout = -1
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errMsgLLMRequestTimeoutInvalid)
+
+	// 时间预算
+	cfg.LLMRequestTimeout = 0
+	cfg.MaxDuration = 120
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, 2*time.Minute, cfg.MaxDurationDuration())
+	assert.Equal(t, 0.15, cfg.EffectiveMaxDurationWrapUp())
+
+	cfg.MaxDuration = -1
+	cfg.MaxDurationWrapUp = 1
+	errs := cfg.ValidateDetailed()
+	require.Len(t, errs, 2)
+	assert.Equal(t, "max_duration", errs[0].Field)
+	assert.Equal(t, "max_duration_wrap_up", errs[1].Field)
+}
+
+// TestLLMRequestTimeout tests that a single slow model request is aborted after LLMRequestTimeout
+func TestLLMRequestTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	for _, llmType := range []string{LLMProviderOpenAI, LLMProviderOllama} {
+		t.Run(llmType, func(t *testing.T) {
+			cfg := &Config{
+				LLM: LLMConfig{
+					Type:    llmType,
+					BaseURL: server.URL,
+					Model:   "slow-model",
+					APIKey:  "test-key",
+				},
+				LLMRequestTimeout: 1,
+			}
+			chatModel, err := cfg.GetModel(context.Background())
+			require.NoError(t, err)
+
+			start := time.Now()
+			_, err = chatModel.Generate(context.Background(), []*schema.Message{schema.UserMessage("hello")})
+			assert.Error(t, err)
+			assert.Less(t, time.Since(start), 5*time.Second, "请求应在超时后立即返回")
+		})
+	}
+}
+
+// TestSaveConfigOnlyWritesConfig tests that SaveConfig ignores unrelated global viper keys
+func TestSaveConfigOnlyWritesConfig(t *testing.T) {
+	viper.Set("unrelated_key", "should not be saved")
+	defer viper.Set("unrelated_key", nil)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := NewDefaultConfig()
+	require.NoError(t, cfg.SaveConfig(configPath))
+
+	data, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "unrelated_key")
+	assert.Contains(t, string(data), "max_step: 20")
+}
+
+// TestLoadConfigEnvOverride tests that environment variables override the config file
+func TestLoadConfigEnvOverride(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, NewDefaultConfig().SaveConfig(configPath))
+
+	t.Setenv("MCPHOST_MAX_STEP", "30")
+	t.Setenv("MCPHOST_LLM_MODEL", "env-model")
+
+	cfg, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, 30, cfg.MaxStep)
+	assert.Equal(t, "env-model", cfg.LLM.Model)
+}
+
+// TestLoadSaveConfigConcurrent tests loading and saving different configs in parallel.
+// Run with -race to detect shared state.
+func TestLoadSaveConfigConcurrent(t *testing.T) {
+	tempDir := t.TempDir()
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			configPath := filepath.Join(tempDir, fmt.Sprintf("config_%d.yaml", i))
+			cfg := NewDefaultConfig()
+			cfg.MaxStep = i + 1
+			cfg.LLM.Model = fmt.Sprintf("model-%d", i)
+			cfg.MCP.Tools = []MCPToolConfig{{Server: fmt.Sprintf("server%d", i), Name: "tool"}}
+
+			for j := 0; j < 5; j++ {
+				if err := cfg.SaveConfig(configPath); err != nil {
+					errs <- err
+					return
+				}
+				loaded, err := LoadConfig(configPath)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if loaded.MaxStep != cfg.MaxStep || loaded.LLM.Model != cfg.LLM.Model || !reflect.DeepEqual(loaded.MCP.Tools, cfg.MCP.Tools) {
+					errs <- fmt.Errorf("配置 %d 被其他协程修改: %+v", i, loaded)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestConfigValidateDetailed tests that all invalid fields are reported
+func TestConfigValidateDetailed(t *testing.T) {
+	cfg := &Config{
+		MCP:     MCPConfig{Tools: []MCPToolConfig{{Server: "fetch", Name: "fetch"}, {Server: "ddg", Name: ""}}},
+		LLM:     LLMConfig{Type: "unknown", Model: "m"},
+		MaxStep: 0,
+	}
+
+	errs := cfg.ValidateDetailed()
+	fields := make([]string, 0, len(errs))
+	for _, fieldErr := range errs {
+		fields = append(fields, fieldErr.Field)
+	}
+	assert.Equal(t, []string{"mcp.tools[1]", "mcp.config_file", "llm.type", "llm.base_url", "max_step"}, fields)
+	assert.Equal(t, "不支持的LLM类型: unknown", errs[2].Message)
+
+	// Validate返回第一个错误，保留各部分的前缀
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Equal(t, errs.First(), err)
+	assert.Regexp(t, "^MCP配置验证失败: 工具列表无效: 第2个工具", err.Error())
+
+	valid := &Config{
+		MCP:     MCPConfig{ConfigFile: "mcpservers.json"},
+		LLM:     LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434", Model: "qwen3"},
+		MaxStep: 10,
+	}
+	assert.Empty(t, valid.ValidateDetailed())
+	assert.NoError(t, valid.Validate())
+}
+
+// TestConfigValidateContextCompression tests the context compression settings
+func TestConfigValidateContextCompression(t *testing.T) {
+	cfg := &Config{
+		MCP:                         MCPConfig{ConfigFile: "mcpservers.json"},
+		LLM:                         LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434", Model: "qwen3"},
+		MaxStep:                     10,
+		ContextCompression:          "truncate",
+		ContextCompressionThreshold: -1,
+	}
+	errs := cfg.ValidateDetailed()
+	require.Len(t, errs, 2)
+	assert.Equal(t, "context_compression", errs[0].Field)
+	assert.Equal(t, "context_compression_threshold", errs[1].Field)
+
+	cfg.ContextCompression = ContextCompressionSummarize
+	cfg.ContextCompressionThreshold = 0
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.SummarizesToolResults())
+	assert.Equal(t, DefaultContextCompressionThreshold, cfg.EffectiveContextCompressionThreshold())
+}
+
+// TestConfigValidateSecurity tests the tool output sanitizer settings
+func TestConfigValidateSecurity(t *testing.T) {
+	cfg := &Config{
+		MCP:     MCPConfig{ConfigFile: "mcpservers.json"},
+		LLM:     LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434", Model: "qwen3"},
+		MaxStep: 10,
+		Security: SecurityConfig{
+			SanitizeToolOutput: "block",
+			InjectionPatterns:  []string{`ignore (previous`, " ", `(?i)ignore previous`},
+		},
+	}
+	errs := cfg.ValidateDetailed()
+	require.Len(t, errs, 3)
+	assert.Equal(t, "security.sanitize_tool_output", errs[0].Field)
+	assert.Equal(t, "security.injection_patterns[0]", errs[1].Field)
+	assert.Equal(t, "security.injection_patterns[1]", errs[2].Field)
+
+	cfg.Security = SecurityConfig{SanitizeToolOutput: SanitizeToolOutputMark}
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Security.MarksToolOutput())
+	assert.False(t, cfg.Security.StripsToolOutput())
+
+	// 未配置规则时使用内置规则
+	cfg.Security.SanitizeToolOutput = SanitizeToolOutputStrip
+	patterns, err := cfg.Security.CompileInjectionPatterns()
+	require.NoError(t, err)
+	assert.Len(t, patterns, len(DefaultInjectionPatterns))
+	assert.True(t, cfg.Security.MarksToolOutput())
+}
+
+func TestConfigValidateAgent(t *testing.T) {
+	cfg := &Config{
+		MCP:     MCPConfig{ConfigFile: "mcpservers.json"},
+		LLM:     LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434", Model: "qwen3"},
+		MaxStep: 10,
+		Agent:   AgentConfig{FirstTool: " ", AllowedToolsPerStep: -1, MaxNotesSize: -1, RequireTools: "sometimes"},
+	}
+	errs := cfg.ValidateDetailed()
+	require.Len(t, errs, 4)
+	assert.Equal(t, "agent.first_tool", errs[0].Field)
+	assert.Equal(t, "agent.allowed_tools_per_step", errs[1].Field)
+	assert.Equal(t, "agent.max_notes_size", errs[2].Field)
+	assert.Equal(t, "agent.require_tools", errs[3].Field)
+
+	cfg.Agent = AgentConfig{FirstTool: "fofa_search", AllowedToolsPerStep: 1, EnableNotes: true, MaxNotesSize: 1024, RequireTools: RequireToolsAuto}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestConfigValidateReplay(t *testing.T) {
+	cfg := &Config{
+		MCP:     MCPConfig{ConfigFile: "mcpservers.json"},
+		LLM:     LLMConfig{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434", Model: "qwen3"},
+		MaxStep: 10,
+		Replay:  ReplayConfig{RecordPath: "record.json", PlayPath: "play.json", Match: "exact"},
+	}
+	errs := cfg.ValidateDetailed()
+	require.Len(t, errs, 2)
+	assert.Equal(t, "replay", errs[0].Field)
+	assert.Equal(t, "replay.match", errs[1].Field)
+
+	cfg.Replay = ReplayConfig{PlayPath: filepath.Join(t.TempDir(), "missing.json"), Match: "fuzzy"}
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Replay.Plays())
+
+	// 回放时不连接大模型，录制文件不存在时返回错误
+	_, err := cfg.GetModel(context.Background())
+	assert.ErrorContains(t, err, "读取回放文件失败")
+}
+
+func TestConfigAudit(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.MCP.ConfigFile = "mcpservers.json"
+	assert.NoError(t, cfg.Validate())
+	assert.False(t, cfg.Audit.Enabled())
+	logger, err := cfg.Audit.Open()
+	require.NoError(t, err)
+	assert.Nil(t, logger)
+
+	cfg.Audit = AuditConfig{Path: filepath.Join(t.TempDir(), "audit.jsonl"), MaxSizeMB: -1, MaxFiles: -1}
+	errs := cfg.ValidateDetailed()
+	require.Len(t, errs, 1)
+	assert.Equal(t, "audit", errs[0].Field)
+	assert.Contains(t, errs[0].Error(), errMsgAuditMaxSize)
+
+	cfg.Audit.MaxSizeMB = 1
+	cfg.Audit.MaxFiles = 0
+	require.NoError(t, cfg.Validate())
+	logger, err = cfg.Audit.Open()
+	require.NoError(t, err)
+	require.NotNil(t, logger)
+	assert.NoError(t, logger.Close())
+	assert.FileExists(t, cfg.Audit.Path)
+}
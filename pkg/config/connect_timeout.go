@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+const errMsgConnectTimeoutInvalid = "连接MCP服务器的超时时间不能小于 %d 秒: %d"
+
+// validateConnectTimeout checks that ConnectTimeout is 0 or at least mcphost.MinMCPTimeoutSeconds
+func (m *MCPConfig) validateConnectTimeout() error {
+	if m.ConnectTimeout != 0 && m.ConnectTimeout < mcphost.MinMCPTimeoutSeconds {
+		return fmt.Errorf(errMsgConnectTimeoutInvalid, mcphost.MinMCPTimeoutSeconds, m.ConnectTimeout)
+	}
+	return nil
+}
+
+// settings returns the hub settings of servers with the configured connect timeout
+func (m *MCPConfig) settings(servers map[string]*mcphost.ServerConfig) *mcphost.MCPSettings {
+	return &mcphost.MCPSettings{
+		MCPServers:     servers,
+		ConnectTimeout: time.Duration(m.ConnectTimeout) * time.Second,
+	}
+}
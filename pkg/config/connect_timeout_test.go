@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConnectTimeout(t *testing.T) {
+	m := &MCPConfig{MCPServers: map[string]*mcphost.ServerConfig{}}
+	assert.NoError(t, m.Validate())
+	m.ConnectTimeout = mcphost.MinMCPTimeoutSeconds
+	assert.NoError(t, m.Validate())
+
+	for _, timeout := range []int{-1, mcphost.MinMCPTimeoutSeconds - 1} {
+		m.ConnectTimeout = timeout
+		errs := m.ValidateDetailed()
+		require.Len(t, errs, 1)
+		assert.Equal(t, "connect_timeout", errs[0].Field)
+	}
+}
+
+func TestConnectTimeoutSettings(t *testing.T) {
+	servers := map[string]*mcphost.ServerConfig{"fetch": {Command: "uvx"}}
+	m := &MCPConfig{ConnectTimeout: 120}
+	settings := m.settings(servers)
+	assert.Equal(t, servers, settings.MCPServers)
+	assert.Equal(t, 2*time.Minute, settings.ConnectTimeout)
+	assert.Equal(t, 2*time.Minute, settings.MCPServers["fetch"].GetConnectTimeout(settings.ConnectTimeout))
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// ContextCompressionSummarize replaces long tool results in the conversation by a
+	// summary generated by the model, the full text stays readable through expand_result
+	ContextCompressionSummarize = "summarize"
+
+	// DefaultContextCompressionThreshold is the number of characters above which
+	// a tool result is summarized when ContextCompressionThreshold is 0
+	DefaultContextCompressionThreshold = 4000
+
+	errMsgContextCompressionInvalid   = "不支持的上下文压缩方式: %s，可选值为 summarize"
+	errMsgContextCompressionThreshold = "上下文压缩阈值不能为负数"
+)
+
+// SummarizesToolResults reports whether long tool results are replaced by summaries
+func (c *Config) SummarizesToolResults() bool {
+	return c.ContextCompression == ContextCompressionSummarize
+}
+
+// EffectiveContextCompressionThreshold returns the number of characters above which tool results are summarized
+func (c *Config) EffectiveContextCompressionThreshold() int {
+	if c.ContextCompressionThreshold == 0 {
+		return DefaultContextCompressionThreshold
+	}
+	return c.ContextCompressionThreshold
+}
+
+// validateContextCompression adds invalid context compression settings to errs
+func (c *Config) validateContextCompression(errs *FieldErrors) {
+	switch c.ContextCompression {
+	case "", ContextCompressionSummarize:
+	default:
+		errs.add("context_compression", fmt.Errorf(errMsgContextCompressionInvalid, c.ContextCompression))
+	}
+	if c.ContextCompressionThreshold < 0 {
+		errs.add("context_compression_threshold", errors.New(errMsgContextCompressionThreshold))
+	}
+}
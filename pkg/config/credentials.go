@@ -0,0 +1,37 @@
+package config
+
+import (
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+)
+
+// resolveCredentials returns copies of the servers with the {{credential:NAME}}
+// references of their args, env values and URL replaced by the values of
+// Credentials. Servers without references are returned as they are.
+func (m *MCPConfig) resolveCredentials(servers map[string]*mcphost.ServerConfig) (map[string]*mcphost.ServerConfig, error) {
+	result := make(map[string]*mcphost.ServerConfig, len(servers))
+	for name, server := range servers {
+		if server == nil || !serverUsesCredentials(server) {
+			result[name] = server
+			continue
+		}
+		resolved, err := models.ResolveServerCredentials(name, *server, m.Credentials)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = &resolved
+	}
+	return result, nil
+}
+
+// serverUsesCredentials reports whether a server references a credential
+func serverUsesCredentials(server *mcphost.ServerConfig) bool {
+	values := append([]string{server.URL}, server.Args...)
+	for _, v := range server.Env {
+		values = append(values, v)
+	}
+	for _, v := range server.Headers {
+		values = append(values, v)
+	}
+	return len(models.CredentialReferences(values...)) > 0
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetToolsResolvesCredentials(t *testing.T) {
+	originalSettingsFactory := mcpHubFromSettingsFactory
+	defer func() { mcpHubFromSettingsFactory = originalSettingsFactory }()
+
+	var gotServers map[string]*mcphost.ServerConfig
+	mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
+		gotServers = settings.MCPServers
+		return nil, assert.AnError
+	}
+
+	scanner := &mcphost.ServerConfig{
+		Command: "scanner",
+		Args:    []string{"--key", "{{credential:shodan}}"},
+		Env:     map[string]string{"SHODAN_KEY": "{{credential:shodan}}"},
+	}
+	cfg := NewDefaultConfig()
+	cfg.MCP.MCPServers["scanner"] = scanner
+	cfg.MCP.Tools = []MCPToolConfig{{Server: "scanner", Name: "scan"}}
+	cfg.MCP.Credentials = func(name string) (string, error) {
+		if name == "shodan" {
+			return "s3cret", nil
+		}
+		return "", models.ErrCredentialNotFound
+	}
+
+	_, cleanup, err := cfg.GetTools(context.Background())
+	require.NoError(t, err)
+	cleanup()
+	require.Contains(t, gotServers, "scanner")
+	assert.Equal(t, []string{"--key", "s3cret"}, gotServers["scanner"].Args)
+	assert.Equal(t, "s3cret", gotServers["scanner"].Env["SHODAN_KEY"])
+	// 配置中仍然只保存引用
+	assert.Equal(t, "{{credential:shodan}}", scanner.Args[1])
+
+	// 引用的凭据不存在时不连接服务器
+	gotServers = nil
+	scanner.Env["TOKEN"] = "{{credential:missing}}"
+	_, cleanup, err = cfg.GetTools(context.Background())
+	require.NoError(t, err)
+	cleanup()
+	assert.Nil(t, gotServers)
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes a configuration field that failed validation.
+// Field is the path of the field as written in configuration files, such as "llm.base_url".
+type FieldError struct {
+	Field   string `json:"field"`          // 字段路径
+	Message string `json:"message"`        // 面向用户的错误描述
+	Line    int    `json:"line,omitempty"` // 配置文件中的行号，仅检查配置文件时设置
+
+	err error // Validate返回的错误
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// FieldErrors collects every invalid field of a configuration, in the order
+// the fields are checked. Validate returns the first of them.
+type FieldErrors []FieldError
+
+// Error implements the error interface
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// First returns the error Validate reports for the first invalid field, nil if there is none
+func (e FieldErrors) First() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e[0].err
+}
+
+// add records err for field
+func (e *FieldErrors) add(field string, err error) {
+	*e = append(*e, FieldError{Field: field, Message: err.Error(), err: err})
+}
+
+// nest adds the errors of a configuration section under prefix. The errors
+// reported by Validate are wrapped with format, which must contain one %w.
+func (e *FieldErrors) nest(prefix, format string, nested FieldErrors) {
+	for _, fieldErr := range nested {
+		fieldErr.Field = prefix + "." + fieldErr.Field
+		fieldErr.err = fmt.Errorf(format, fieldErr.err)
+		*e = append(*e, fieldErr)
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	errMsgFOFAMaxSizeInvalid = "FOFA最大结果数不能为负数"
+	errMsgFOFABaseURLInvalid = "FOFA API地址必须以http://或https://开头"
+)
+
+// IntegrationsConfig holds the credentials of third-party services used by inner tools
+type IntegrationsConfig struct {
+	FOFA FOFAConfig `mapstructure:"fofa" json:"fofa" yaml:"fofa"` // FOFA搜索引擎
+}
+
+// FOFAConfig configures the fofa_search inner tool
+type FOFAConfig struct {
+	Email   string `mapstructure:"email" json:"email" yaml:"email"`          // FOFA账号邮箱
+	Key     string `mapstructure:"key" json:"key" yaml:"key"`                // FOFA API Key
+	BaseURL string `mapstructure:"base_url" json:"base_url" yaml:"base_url"` // FOFA API地址，为空时使用 https://fofa.info
+	MaxSize int    `mapstructure:"max_size" json:"max_size" yaml:"max_size"` // 单次查询最多返回的结果数，0表示使用默认值
+}
+
+// Validate validates the integrations configuration.
+//
+// Returns:
+//   - error: validation error if configuration is invalid, nil otherwise
+func (i *IntegrationsConfig) Validate() error {
+	if i.FOFA.MaxSize < 0 {
+		return errors.New(errMsgFOFAMaxSizeInvalid)
+	}
+	if baseURL := strings.TrimSpace(i.FOFA.BaseURL); baseURL != "" &&
+		!strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return errors.New(errMsgFOFABaseURLInvalid)
+	}
+	return nil
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/LubyRuffy/mcpagent/pkg/fofa"
 	"github.com/cloudwego/eino-ext/components/tool/duckduckgo/v2"
 	"github.com/cloudwego/eino-ext/components/tool/sequentialthinking"
 	"github.com/cloudwego/eino/components/tool"
@@ -18,6 +22,9 @@ const (
 
 	// SequentialThinkingToolName 是顺序思考工具的名称
 	SequentialThinkingToolName = "sequentialthinking"
+
+	// fofaRequestTimeout 是单次FOFA API请求的超时时间
+	fofaRequestTimeout = 30 * time.Second
 )
 
 // Result defines a search query result type.
@@ -27,10 +34,41 @@ type Result struct {
 	Ref   string `json:"ref"`
 }
 
-// GetInternalTools 返回所有内置工具列表
-func GetInternalTools(ctx context.Context, proxy string) ([]tool.BaseTool, error) {
-	var tools []tool.BaseTool
+// GetInternalTools 返回可以执行的内置工具列表
+// integrations 提供内置工具使用的第三方服务凭据，未配置凭据的工具（如未配置FOFA Key时的fofa_search）不会提供给大模型
+func GetInternalTools(ctx context.Context, proxy string, integrations IntegrationsConfig) ([]tool.BaseTool, error) {
+	tools, err := getCommonInternalTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(integrations.FOFA.Key) == "" {
+		log.Printf("未配置FOFA Key，不提供%s工具", fofa.SearchToolName)
+		return tools, nil
+	}
+	fofaTool, err := newFOFATool(proxy, integrations.FOFA)
+	if err != nil {
+		return nil, err
+	}
+	return append(tools, fofaTool), nil
+}
+
+// GetInternalToolCatalog 返回所有内置工具，包括未配置凭据的工具，用于向数据库同步工具列表等只需要工具信息的场景
+// 未配置凭据的工具调用时返回错误，不能提供给大模型
+func GetInternalToolCatalog(ctx context.Context) ([]tool.BaseTool, error) {
+	tools, err := getCommonInternalTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fofaTool, err := newFOFATool("", FOFAConfig{})
+	if err != nil {
+		return nil, err
+	}
+	return append(tools, fofaTool), nil
+}
 
+// getCommonInternalTools 返回不需要第三方服务凭据的内置工具
+func getCommonInternalTools(ctx context.Context) ([]tool.BaseTool, error) {
 	// 创建顺序思考工具并进行自定义
 	seqThinking, err := sequentialthinking.NewTool()
 	if err != nil {
@@ -57,15 +95,38 @@ func GetInternalTools(ctx context.Context, proxy string) ([]tool.BaseTool, error
 	// 由于我们不能直接修改 eino 工具的名称，我们需要在生成 toolKey 时使用自定义名称
 	// 在 SyncInternalTools 中使用工具信息时会自动使用我们定义的常量
 
-	tools = append(tools, seqThinking, searchTool)
 	// 这里可以继续添加其他内置工具...
+	return []tool.BaseTool{seqThinking, searchTool}, nil
+}
 
-	return tools, nil
+// newFOFATool 创建FOFA搜索工具，配置了代理时通过代理访问FOFA
+func newFOFATool(proxy string, fofaCfg FOFAConfig) (tool.BaseTool, error) {
+	fofaClient, err := newIntegrationHTTPClient(proxy, fofaRequestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("创建FOFA搜索工具失败: %w", err)
+	}
+	return fofa.NewSearchTool(fofaCfg.BaseURL, fofaCfg.Email, fofaCfg.Key, fofaCfg.MaxSize, fofaClient), nil
+}
+
+// newIntegrationHTTPClient 创建访问第三方服务的HTTP客户端，配置了代理时通过代理访问
+// 代理使用http.DefaultTransport的副本，保留其连接超时、HTTP/2和空闲连接等设置
+func newIntegrationHTTPClient(proxy string, timeout time.Duration) (*http.Client, error) {
+	client := &http.Client{Timeout: timeout}
+	if proxyStr := strings.TrimSpace(proxy); proxyStr != "" {
+		proxyURL, err := url.Parse(proxyStr)
+		if err != nil {
+			return nil, fmt.Errorf("解析代理URL错误: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		client.Transport = transport
+	}
+	return client, nil
 }
 
 // GetInternalToolMap 返回内置工具的映射表，键为工具名称，值为工具实例
-func GetInternalToolMap(ctx context.Context, proxy string) (map[string]tool.BaseTool, error) {
-	tools, err := GetInternalTools(ctx, proxy)
+func GetInternalToolMap(ctx context.Context, proxy string, integrations IntegrationsConfig) (map[string]tool.BaseTool, error) {
+	tools, err := GetInternalTools(ctx, proxy, integrations)
 	if err != nil {
 		return nil, err
 	}
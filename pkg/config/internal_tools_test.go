@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/fofa"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolNames returns the names of tools
+func toolNames(t *testing.T, tools []tool.BaseTool) []string {
+	t.Helper()
+	var names []string
+	for _, tl := range tools {
+		info, err := tl.Info(context.Background())
+		require.NoError(t, err)
+		names = append(names, info.Name)
+	}
+	return names
+}
+
+func TestGetInternalToolsFOFAKey(t *testing.T) {
+	ctx := context.Background()
+
+	// 未配置FOFA Key时不提供fofa_search
+	tools, err := GetInternalTools(ctx, "", IntegrationsConfig{FOFA: FOFAConfig{Email: "a@example.com"}})
+	require.NoError(t, err)
+	assert.NotContains(t, toolNames(t, tools), fofa.SearchToolName)
+
+	tools, err = GetInternalTools(ctx, "", IntegrationsConfig{FOFA: FOFAConfig{Key: "fofa-key"}})
+	require.NoError(t, err)
+	assert.Contains(t, toolNames(t, tools), fofa.SearchToolName)
+
+	// 同步到数据库的工具列表总是包含fofa_search
+	catalog, err := GetInternalToolCatalog(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, toolNames(t, catalog), fofa.SearchToolName)
+}
+
+func TestNewIntegrationHTTPClient(t *testing.T) {
+	client, err := newIntegrationHTTPClient("", fofaRequestTimeout)
+	require.NoError(t, err)
+	assert.Nil(t, client.Transport)
+	assert.Equal(t, fofaRequestTimeout, client.Timeout)
+
+	client, err = newIntegrationHTTPClient("http://127.0.0.1:8080", fofaRequestTimeout)
+	require.NoError(t, err)
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+	// 设置代理的同时保留默认Transport的超时和HTTP/2设置
+	defaults := http.DefaultTransport.(*http.Transport)
+	assert.Equal(t, defaults.TLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	assert.Equal(t, defaults.IdleConnTimeout, transport.IdleConnTimeout)
+	assert.Equal(t, defaults.ForceAttemptHTTP2, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.DialContext)
+	proxyURL, err := transport.Proxy(&http.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8080", proxyURL.String())
+	assert.NotSame(t, defaults, transport)
+
+	_, err = newIntegrationHTTPClient("://bad", fofaRequestTimeout)
+	assert.Error(t, err)
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	openaiapi "github.com/meguminnnnnnnnn/go-openai"
+	ollamaapi "github.com/ollama/ollama/api"
+)
+
+const errMsgLLMFallbackNested = "备用模型不能再配置备用模型"
+
+// Label returns the name of the model used in notifications and task results
+func (l *LLMConfig) Label() string {
+	if l.Type == "" {
+		return l.Model
+	}
+	return l.Type + "/" + l.Model
+}
+
+// validateFallbacks adds the invalid fallback models to errs
+func (l *LLMConfig) validateFallbacks(errs *FieldErrors) {
+	for i := range l.Fallbacks {
+		fallback := &l.Fallbacks[i]
+		prefix := fmt.Sprintf("fallbacks[%d]", i)
+		if len(fallback.Fallbacks) > 0 {
+			errs.add(prefix+".fallbacks", errors.New(errMsgLLMFallbackNested))
+		}
+		errs.nest(prefix, "备用模型配置验证失败: %w", fallback.ValidateDetailed())
+	}
+}
+
+// ModelSwitch describes a switch to the next model of the fallback chain
+type ModelSwitch struct {
+	From string // 不可用的模型
+	To   string // 改用的模型
+	Err  error  // 导致切换的错误
+}
+
+// ModelTracker follows the fallback chain of a task. Once a model is
+// unavailable, the following requests of the task start with the model it
+// switched to. Attach it to the context of the task with WithModelTracker.
+type ModelTracker struct {
+	mutex    sync.Mutex
+	current  int
+	answered string
+	onSwitch func(ModelSwitch)
+}
+
+// NewModelTracker creates a tracker starting with the primary model.
+//
+// Parameters:
+//   - onSwitch: Called after each switch to the next model, may be nil
+//
+// Returns:
+//   - *ModelTracker: Tracker to attach to the context of a task
+func NewModelTracker(onSwitch func(ModelSwitch)) *ModelTracker {
+	return &ModelTracker{onSwitch: onSwitch}
+}
+
+// Model returns the label of the model that produced the last response,
+// empty if no model with fallbacks has responded yet
+func (t *ModelTracker) Model() string {
+	if t == nil {
+		return ""
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.answered
+}
+
+// start returns the index of the model the next request starts with
+func (t *ModelTracker) start() int {
+	if t == nil {
+		return 0
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.current
+}
+
+// switchTo makes next the model of the following requests and reports the switch
+func (t *ModelTracker) switchTo(next int, modelSwitch ModelSwitch) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	if next > t.current {
+		t.current = next
+	}
+	onSwitch := t.onSwitch
+	t.mutex.Unlock()
+	if onSwitch != nil {
+		onSwitch(modelSwitch)
+	}
+}
+
+// answer records the model that produced a response
+func (t *ModelTracker) answer(label string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.answered = label
+}
+
+// modelTrackerKey is the context key of the ModelTracker of a task
+type modelTrackerKey struct{}
+
+// WithModelTracker returns a context whose model requests follow tracker
+func WithModelTracker(ctx context.Context, tracker *ModelTracker) context.Context {
+	return context.WithValue(ctx, modelTrackerKey{}, tracker)
+}
+
+// modelTrackerFrom returns the tracker of ctx, nil if there is none
+func modelTrackerFrom(ctx context.Context) *ModelTracker {
+	tracker, _ := ctx.Value(modelTrackerKey{}).(*ModelTracker)
+	return tracker
+}
+
+// fallbackChatModel sends each request to the first available model of a
+// chain. A model is skipped when it cannot be reached, times out or answers
+// with a server error; client errors such as an invalid API key or request
+// are returned, another model would reject the request as well.
+type fallbackChatModel struct {
+	models []model.ToolCallingChatModel
+	labels []string
+}
+
+// newFallbackModel returns a model trying the primary model and then the
+// fallbacks of the LLM configuration in order.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - primary: Model created from the LLM configuration itself
+//
+// Returns:
+//   - model.ToolCallingChatModel: Model with fallbacks
+//   - error: Error if a fallback model cannot be created
+func (c *Config) newFallbackModel(ctx context.Context, primary model.ToolCallingChatModel) (model.ToolCallingChatModel, error) {
+	m := &fallbackChatModel{
+		models: []model.ToolCallingChatModel{primary},
+		labels: []string{c.LLM.Label()},
+	}
+	for _, fallback := range c.LLM.Fallbacks {
+		// 备用模型使用同样的代理和超时设置
+		fallbackCfg := *c
+		fallbackCfg.LLM = fallback
+		fallbackModel, err := fallbackCfg.getSingleModel(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("创建备用模型 %s 失败: %w", fallback.Label(), err)
+		}
+		m.models = append(m.models, fallbackModel)
+		m.labels = append(m.labels, fallback.Label())
+	}
+	return m, nil
+}
+
+// Generate returns the response of the first available model
+func (m *fallbackChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	tracker := modelTrackerFrom(ctx)
+	for i := tracker.start(); ; i++ {
+		msg, err := m.models[i].Generate(ctx, input, opts...)
+		if err == nil {
+			tracker.answer(m.labels[i])
+			return msg, nil
+		}
+		if !m.shouldFallback(ctx, i, err) {
+			return nil, err
+		}
+		tracker.switchTo(i+1, ModelSwitch{From: m.labels[i], To: m.labels[i+1], Err: err})
+	}
+}
+
+// Stream returns the response stream of the first available model. Only
+// errors starting the stream switch models, a stream failing midway is
+// returned as is because part of the response has been consumed.
+func (m *fallbackChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	tracker := modelTrackerFrom(ctx)
+	for i := tracker.start(); ; i++ {
+		stream, err := m.models[i].Stream(ctx, input, opts...)
+		if err == nil {
+			tracker.answer(m.labels[i])
+			return stream, nil
+		}
+		if !m.shouldFallback(ctx, i, err) {
+			return nil, err
+		}
+		tracker.switchTo(i+1, ModelSwitch{From: m.labels[i], To: m.labels[i+1], Err: err})
+	}
+}
+
+// WithTools binds tools to every model of the chain
+func (m *fallbackChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound := &fallbackChatModel{
+		models: make([]model.ToolCallingChatModel, len(m.models)),
+		labels: m.labels,
+	}
+	for i, chatModel := range m.models {
+		withTools, err := chatModel.WithTools(tools)
+		if err != nil {
+			return nil, fmt.Errorf("模型 %s 绑定工具失败: %w", m.labels[i], err)
+		}
+		bound.models[i] = withTools
+	}
+	return bound, nil
+}
+
+// shouldFallback reports whether the request failing with err on model i is retried with the next model
+func (m *fallbackChatModel) shouldFallback(ctx context.Context, i int, err error) bool {
+	if i+1 >= len(m.models) || ctx.Err() != nil {
+		return false
+	}
+	return IsModelOutage(err)
+}
+
+// IsModelOutage reports whether err means the model is unavailable rather
+// than the request invalid: the server refused or dropped the connection,
+// did not answer in time or answered with a 5xx status.
+//
+// Parameters:
+//   - err: Error returned by a model request
+//
+// Returns:
+//   - bool: true if another model may succeed with the same request
+func IsModelOutage(err error) bool {
+	if err == nil {
+		return false
+	}
+	if status, ok := modelErrorStatus(err); ok {
+		return status >= http.StatusInternalServerError
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// modelErrorStatus returns the HTTP status of a model API error
+func modelErrorStatus(err error) (int, bool) {
+	var apiErr *openaiapi.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode > 0 {
+		return apiErr.HTTPStatusCode, true
+	}
+	var requestErr *openaiapi.RequestError
+	if errors.As(err, &requestErr) && requestErr.HTTPStatusCode > 0 {
+		return requestErr.HTTPStatusCode, true
+	}
+	var statusErr ollamaapi.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode > 0 {
+		return statusErr.StatusCode, true
+	}
+	var statusErrPtr *ollamaapi.StatusError
+	if errors.As(err, &statusErrPtr) && statusErrPtr.StatusCode > 0 {
+		return statusErrPtr.StatusCode, true
+	}
+	return 0, false
+}
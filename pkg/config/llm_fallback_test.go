@@ -0,0 +1,222 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	openaiapi "github.com/meguminnnnnnnnn/go-openai"
+	ollamaapi "github.com/ollama/ollama/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChatModel 返回固定错误或以自身名称回答的模拟模型
+type fakeChatModel struct {
+	name string
+	err  error
+
+	mu        sync.Mutex
+	calls     int
+	boundWith []*schema.ToolInfo
+}
+
+func (m *fakeChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return schema.AssistantMessage(m.name, nil), nil
+}
+
+func (m *fakeChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *fakeChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.boundWith = tools
+	return m, nil
+}
+
+// newTestFallbackModel combines the models into a fallback chain labeled with their names
+func newTestFallbackModel(models ...*fakeChatModel) *fallbackChatModel {
+	m := &fallbackChatModel{}
+	for _, chatModel := range models {
+		m.models = append(m.models, chatModel)
+		m.labels = append(m.labels, chatModel.name)
+	}
+	return m
+}
+
+func TestFallbackModelSwitchesForTheRestOfTheTask(t *testing.T) {
+	outage := &openaiapi.APIError{HTTPStatusCode: http.StatusServiceUnavailable, Message: "overloaded"}
+	primary := &fakeChatModel{name: "primary", err: fmt.Errorf("请求失败: %w", outage)}
+	backup := &fakeChatModel{name: "backup"}
+	chain := newTestFallbackModel(primary, backup)
+
+	var switches []ModelSwitch
+	tracker := NewModelTracker(func(modelSwitch ModelSwitch) { switches = append(switches, modelSwitch) })
+	ctx := WithModelTracker(context.Background(), tracker)
+
+	msg, err := chain.Generate(ctx, []*schema.Message{schema.UserMessage("hi")})
+	require.NoError(t, err)
+	assert.Equal(t, "backup", msg.Content)
+	require.Len(t, switches, 1)
+	assert.Equal(t, "primary", switches[0].From)
+	assert.Equal(t, "backup", switches[0].To)
+	assert.ErrorIs(t, switches[0].Err, outage)
+
+	// 同一任务的后续请求不再尝试主模型
+	stream, err := chain.Stream(ctx, []*schema.Message{schema.UserMessage("again")})
+	require.NoError(t, err)
+	stream.Close()
+	assert.Equal(t, 1, primary.calls)
+	assert.Equal(t, 2, backup.calls)
+	assert.Len(t, switches, 1)
+	assert.Equal(t, "backup", tracker.Model())
+
+	// 新任务重新从主模型开始
+	_, err = chain.Generate(WithModelTracker(context.Background(), NewModelTracker(nil)), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, primary.calls)
+}
+
+func TestFallbackModelReturnsClientErrors(t *testing.T) {
+	rejected := &openaiapi.APIError{HTTPStatusCode: http.StatusUnauthorized, Message: "invalid api key"}
+	primary := &fakeChatModel{name: "primary", err: rejected}
+	backup := &fakeChatModel{name: "backup"}
+	chain := newTestFallbackModel(primary, backup)
+
+	tracker := NewModelTracker(func(ModelSwitch) { t.Error("客户端错误不应切换模型") })
+	_, err := chain.Generate(WithModelTracker(context.Background(), tracker), nil)
+	assert.ErrorIs(t, err, rejected)
+	assert.Zero(t, backup.calls)
+	assert.Empty(t, tracker.Model())
+
+	// 最后一个模型的错误原样返回
+	backup.err = syscall.ECONNREFUSED
+	primary.err = syscall.ECONNREFUSED
+	_, err = chain.Generate(context.Background(), nil)
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	assert.Equal(t, 1, backup.calls)
+
+	// 任务取消后不再尝试备用模型
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = chain.Generate(ctx, nil)
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	assert.Equal(t, 1, backup.calls)
+}
+
+func TestFallbackModelWithToolsBindsEveryModel(t *testing.T) {
+	primary := &fakeChatModel{name: "primary"}
+	backup := &fakeChatModel{name: "backup"}
+	tools := []*schema.ToolInfo{{Name: "fetch", Desc: "fetch a url"}}
+
+	bound, err := newTestFallbackModel(primary, backup).WithTools(tools)
+	require.NoError(t, err)
+	assert.Equal(t, tools, primary.boundWith)
+	assert.Equal(t, tools, backup.boundWith)
+	assert.IsType(t, &fallbackChatModel{}, bound)
+}
+
+func TestIsModelOutage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "openai 500", err: &openaiapi.APIError{HTTPStatusCode: 500}, want: true},
+		{name: "openai 429", err: &openaiapi.APIError{HTTPStatusCode: 429}, want: false},
+		{name: "openai request 502", err: fmt.Errorf("wrap: %w", &openaiapi.RequestError{HTTPStatusCode: 502}), want: true},
+		{name: "openai request 400", err: &openaiapi.RequestError{HTTPStatusCode: 400}, want: false},
+		{name: "ollama 503", err: fmt.Errorf("wrap: %w", ollamaapi.StatusError{StatusCode: 503}), want: true},
+		{name: "ollama 404", err: ollamaapi.StatusError{StatusCode: 404}, want: false},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "deadline", err: fmt.Errorf("wrap: %w", context.DeadlineExceeded), want: true},
+		{name: "other", err: errors.New("invalid request"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsModelOutage(tt.err))
+		})
+	}
+}
+
+func TestGetModelWithFallbacks(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":{"message":"overloaded","type":"server_error"}}`)
+	}))
+	defer unavailable.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"backup",`+
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"from backup"},"finish_reason":"stop"}]}`)
+	}))
+	defer backup.Close()
+
+	cfg := &Config{LLM: LLMConfig{
+		Type: LLMProviderOpenAI, BaseURL: unavailable.URL, Model: "primary", APIKey: "test",
+		Fallbacks: []LLMConfig{{Type: LLMProviderOpenAI, BaseURL: backup.URL, Model: "backup", APIKey: "test"}},
+	}}
+	chatModel, err := cfg.GetModel(context.Background())
+	require.NoError(t, err)
+
+	var switches []ModelSwitch
+	tracker := NewModelTracker(func(modelSwitch ModelSwitch) { switches = append(switches, modelSwitch) })
+	msg, err := chatModel.Generate(WithModelTracker(context.Background(), tracker), []*schema.Message{schema.UserMessage("hi")})
+	require.NoError(t, err)
+	assert.Equal(t, "from backup", msg.Content)
+	require.Len(t, switches, 1)
+	assert.Equal(t, "openai/primary", switches[0].From)
+	assert.Equal(t, "openai/backup", switches[0].To)
+	assert.Equal(t, "openai/backup", tracker.Model())
+
+	// 没有备用模型时返回原始模型
+	cfg.LLM.Fallbacks = nil
+	chatModel, err = cfg.GetModel(context.Background())
+	require.NoError(t, err)
+	_, isFallback := chatModel.(*fallbackChatModel)
+	assert.False(t, isFallback)
+}
+
+func TestLLMConfigValidateFallbacks(t *testing.T) {
+	llm := LLMConfig{
+		Type: LLMProviderOpenAI, BaseURL: "https://api.example.com/v1", Model: "primary",
+		Fallbacks: []LLMConfig{
+			{Type: LLMProviderOllama, BaseURL: "http://127.0.0.1:11434", Model: "qwen3"},
+			{Type: "gpt", BaseURL: "https://api.example.com/v1", Model: "backup",
+				Fallbacks: []LLMConfig{{Type: LLMProviderOpenAI, BaseURL: "https://y.example.com/v1", Model: "y"}}},
+		},
+	}
+
+	errs := llm.ValidateDetailed()
+	fields := make([]string, len(errs))
+	for i, fieldErr := range errs {
+		fields[i] = fieldErr.Field
+	}
+	assert.ElementsMatch(t, []string{"fallbacks[1].fallbacks", "fallbacks[1].type"}, fields)
+	assert.ErrorContains(t, llm.Validate(), errMsgLLMFallbackNested)
+
+	llm.Fallbacks = llm.Fallbacks[:1]
+	assert.NoError(t, llm.Validate())
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"testing"
 
-	"github.com/LubyRuffy/einomcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -15,7 +15,7 @@ func TestMCPConfigIntegration(t *testing.T) {
 		// 创建包含mcp_servers的配置
 		cfg := &Config{
 			MCP: MCPConfig{
-				MCPServers: map[string]*einomcphost.ServerConfig{
+				MCPServers: map[string]*mcphost.ServerConfig{
 					"test-server": {
 						TransportType: "stdio",
 						Command:       "echo",
@@ -62,7 +62,7 @@ func TestMCPConfigIntegration(t *testing.T) {
 		// 创建空的mcp_servers配置
 		cfg := &Config{
 			MCP: MCPConfig{
-				MCPServers: make(map[string]*einomcphost.ServerConfig),
+				MCPServers: make(map[string]*mcphost.ServerConfig),
 				Tools:      []MCPToolConfig{},
 			},
 			LLM: LLMConfig{
@@ -89,7 +89,7 @@ func TestMCPConfigIntegration(t *testing.T) {
 			MCP: MCPConfig{
 				MCPServers: nil,
 				ConfigFile: "", // 空的ConfigFile
-				Tools:      []MCPToolConfig{},
+				Tools:      []MCPToolConfig{{Server: "test-server", Name: "test-tool"}},
 			},
 			LLM: LLMConfig{
 				Type:    LLMProviderOllama,
@@ -128,8 +128,8 @@ func TestMCPConfigIntegration(t *testing.T) {
 		}()
 
 		// 创建模拟函数
-		var capturedSettings *einomcphost.MCPSettings
-		mcpHubFromSettingsFactory = func(ctx context.Context, settings *einomcphost.MCPSettings) (MCPHubInterface, error) {
+		var capturedSettings *mcphost.MCPSettings
+		mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
 			capturedSettings = settings
 			// 返回错误而不是nil，避免空指针
 			return nil, assert.AnError
@@ -137,7 +137,7 @@ func TestMCPConfigIntegration(t *testing.T) {
 
 		cfg := &Config{
 			MCP: MCPConfig{
-				MCPServers: map[string]*einomcphost.ServerConfig{
+				MCPServers: map[string]*mcphost.ServerConfig{
 					"test-server": {
 						TransportType: "stdio",
 						Command:       "echo",
@@ -212,7 +212,7 @@ func TestMCPConfigBackwardCompatibility(t *testing.T) {
 		settingsFactoryCalled := false
 		fileFactoryCalled := false
 
-		mcpHubFromSettingsFactory = func(ctx context.Context, settings *einomcphost.MCPSettings) (MCPHubInterface, error) {
+		mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
 			settingsFactoryCalled = true
 			return nil, assert.AnError
 		}
@@ -225,7 +225,7 @@ func TestMCPConfigBackwardCompatibility(t *testing.T) {
 		cfg := &Config{
 			MCP: MCPConfig{
 				ConfigFile: "test_mcpservers.json", // 有config_file
-				MCPServers: map[string]*einomcphost.ServerConfig{ // 也有mcp_servers
+				MCPServers: map[string]*mcphost.ServerConfig{ // 也有mcp_servers
 					"test-server": {
 						TransportType: "stdio",
 						Command:       "echo",
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+// MCP server merge strategies decide how ConfigFile and inline MCPServers are combined
+const (
+	MergeStrategyInlineOnly = "inline_only" // 只使用 mcp_servers 中的服务器
+	MergeStrategyFileOnly   = "file_only"   // 只使用配置文件中的服务器
+	MergeStrategyMerge      = "merge"       // 合并两者，同名服务器以 mcp_servers 为准
+)
+
+// Error messages for MCP server resolution
+const (
+	errMsgMergeStrategyInvalid = "不支持的MCP服务器合并策略: %s，可选值为 inline_only、file_only 和 merge"
+	errMsgLoadMCPConfigFile    = "读取MCP服务器配置文件失败: %w"
+	warnMsgServerConflict      = "MCP服务器 %s 在配置文件 %s 和 mcp_servers 中的定义不同，使用 mcp_servers 中的定义"
+	warnMsgConfigFileSkipped   = "读取MCP服务器配置文件失败: %v，仅使用 mcp_servers 中的服务器"
+)
+
+// EffectiveMergeStrategy returns the configured merge strategy, defaulting to merge
+func (m *MCPConfig) EffectiveMergeStrategy() string {
+	if strings.TrimSpace(m.MergeStrategy) == "" {
+		return MergeStrategyMerge
+	}
+	return m.MergeStrategy
+}
+
+// validateMergeStrategy checks that MergeStrategy is one of the supported values
+func (m *MCPConfig) validateMergeStrategy() error {
+	switch m.EffectiveMergeStrategy() {
+	case MergeStrategyInlineOnly, MergeStrategyFileOnly, MergeStrategyMerge:
+		return nil
+	}
+	return fmt.Errorf(errMsgMergeStrategyInvalid, m.MergeStrategy)
+}
+
+// NoToolsRequested reports whether neither tools nor inline servers are configured.
+// Such a task is answered by the model alone, without a configuration file.
+func (m *MCPConfig) NoToolsRequested() bool {
+	return len(m.Tools) == 0 && len(m.MCPServers) == 0
+}
+
+// usesConfigFileOnly reports whether the servers come from ConfigFile alone,
+// in which case the hub can be created directly from the file.
+func (m *MCPConfig) usesConfigFileOnly() bool {
+	switch m.EffectiveMergeStrategy() {
+	case MergeStrategyFileOnly:
+		return true
+	case MergeStrategyMerge:
+		return len(m.MCPServers) == 0 && strings.TrimSpace(m.ConfigFile) != ""
+	}
+	return false
+}
+
+// ResolveServers returns the MCP servers selected by the merge strategy.
+// With the merge strategy the servers of ConfigFile are loaded first and inline
+// MCPServers are overlaid per server name. Servers defined differently in both
+// places are reported as warnings; identical definitions are merged silently.
+// A configuration file that cannot be loaded is only a warning when inline
+// servers are available to fall back to.
+//
+// Returns:
+//   - map[string]*mcphost.ServerConfig: Merged servers keyed by server name
+//   - []string: Warnings about skipped files and conflicting server definitions
+//   - error: Error if the strategy is invalid or the configuration file cannot be loaded
+func (m *MCPConfig) ResolveServers() (map[string]*mcphost.ServerConfig, []string, error) {
+	if err := m.validateMergeStrategy(); err != nil {
+		return nil, nil, err
+	}
+
+	strategy := m.EffectiveMergeStrategy()
+	servers := make(map[string]*mcphost.ServerConfig)
+	var warnings []string
+
+	if strategy != MergeStrategyInlineOnly && strings.TrimSpace(m.ConfigFile) != "" {
+		settings, err := mcphost.LoadSettings(m.ConfigFile)
+		switch {
+		case err == nil:
+			for name, server := range settings.MCPServers {
+				servers[name] = server
+			}
+		case strategy == MergeStrategyFileOnly || len(m.MCPServers) == 0:
+			return nil, nil, fmt.Errorf(errMsgLoadMCPConfigFile, err)
+		default:
+			// 配置文件不可用时仍然使用mcp_servers中的服务器
+			warnings = append(warnings, fmt.Sprintf(warnMsgConfigFileSkipped, err))
+		}
+	}
+	if strategy == MergeStrategyFileOnly {
+		return servers, nil, nil
+	}
+
+	var conflicts []string
+	for name, server := range m.MCPServers {
+		if fileServer, ok := servers[name]; ok && !sameServerConfig(fileServer, server) {
+			conflicts = append(conflicts, name)
+		}
+		servers[name] = server
+	}
+	sort.Strings(conflicts)
+
+	for _, name := range conflicts {
+		warnings = append(warnings, fmt.Sprintf(warnMsgServerConflict, name, m.ConfigFile))
+	}
+	return servers, warnings, nil
+}
+
+// MergeWarnings returns the warnings of merging ConfigFile and MCPServers.
+// It returns nil unless both sources are merged; load errors are left to GetTools.
+func (m *MCPConfig) MergeWarnings() []string {
+	if m.EffectiveMergeStrategy() != MergeStrategyMerge || len(m.MCPServers) == 0 || strings.TrimSpace(m.ConfigFile) == "" {
+		return nil
+	}
+	_, warnings, err := m.ResolveServers()
+	if err != nil {
+		return nil
+	}
+	return warnings
+}
+
+// sameServerConfig reports whether two server definitions are equivalent.
+// The transport type inferred when loading the configuration file and empty
+// versus missing lists are not treated as differences.
+func sameServerConfig(a, b *mcphost.ServerConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return reflect.DeepEqual(normalizeServerConfig(*a), normalizeServerConfig(*b))
+}
+
+// normalizeServerConfig returns a copy of server in a canonical form for comparison
+func normalizeServerConfig(server mcphost.ServerConfig) mcphost.ServerConfig {
+	if server.TransportType == "" {
+		if strings.TrimSpace(server.URL) != "" {
+			server.TransportType = mcphost.TransportTypeSSE
+		} else {
+			server.TransportType = mcphost.TransportTypeStdio
+		}
+	}
+	if len(server.Args) == 0 {
+		server.Args = nil
+	}
+	if len(server.AutoApprove) == 0 {
+		server.AutoApprove = nil
+	}
+	if len(server.Env) == 0 {
+		server.Env = nil
+	}
+	if len(server.Headers) == 0 {
+		server.Headers = nil
+	}
+	return server
+}
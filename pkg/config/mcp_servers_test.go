@@ -0,0 +1,224 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeMCPServersFile writes a MCP server configuration file with a fetch and a search server
+func writeMCPServersFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "mcpservers.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "mcpServers": {
+    "fetch": {"command": "uvx", "args": ["mcp-server-fetch"]},
+    "search": {"command": "uvx", "args": ["duckduckgo-mcp-server"]}
+  }
+}`), 0644))
+	return path
+}
+
+func TestResolveServersStrategies(t *testing.T) {
+	configFile := writeMCPServersFile(t)
+	inline := map[string]*mcphost.ServerConfig{
+		"search": {TransportType: "stdio", Command: "npx", Args: []string{"search-server"}},
+		"db":     {TransportType: "sse", URL: "http://127.0.0.1:8080/sse"},
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		m := &MCPConfig{ConfigFile: configFile, MCPServers: inline}
+		servers, warnings, err := m.ResolveServers()
+		require.NoError(t, err)
+		assert.Len(t, servers, 3)
+		assert.Equal(t, "uvx", servers["fetch"].Command)
+		assert.Equal(t, "npx", servers["search"].Command, "同名服务器以mcp_servers为准")
+		assert.Contains(t, servers, "db")
+
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "search")
+		assert.Equal(t, warnings, m.MergeWarnings())
+	})
+
+	t.Run("inline_only", func(t *testing.T) {
+		m := &MCPConfig{ConfigFile: configFile, MCPServers: inline, MergeStrategy: MergeStrategyInlineOnly}
+		servers, warnings, err := m.ResolveServers()
+		require.NoError(t, err)
+		assert.Len(t, servers, 2)
+		assert.NotContains(t, servers, "fetch")
+		assert.Empty(t, warnings)
+		assert.Empty(t, m.MergeWarnings())
+	})
+
+	t.Run("file_only", func(t *testing.T) {
+		m := &MCPConfig{ConfigFile: configFile, MCPServers: inline, MergeStrategy: MergeStrategyFileOnly}
+		servers, warnings, err := m.ResolveServers()
+		require.NoError(t, err)
+		assert.Len(t, servers, 2)
+		assert.Equal(t, "uvx", servers["search"].Command)
+		assert.NotContains(t, servers, "db")
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("相同的定义不产生警告", func(t *testing.T) {
+		m := &MCPConfig{
+			ConfigFile: configFile,
+			MCPServers: map[string]*mcphost.ServerConfig{
+				"fetch": {Command: "uvx", Args: []string{"mcp-server-fetch"}, Env: map[string]string{}},
+			},
+		}
+		servers, warnings, err := m.ResolveServers()
+		require.NoError(t, err)
+		assert.Len(t, servers, 2)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("配置文件不可用", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing.json")
+
+		m := &MCPConfig{ConfigFile: missing, MCPServers: inline}
+		servers, warnings, err := m.ResolveServers()
+		require.NoError(t, err, "有mcp_servers时仍然可以使用")
+		assert.Len(t, servers, 2)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "读取MCP服务器配置文件失败")
+
+		m = &MCPConfig{ConfigFile: missing}
+		_, _, err = m.ResolveServers()
+		assert.Error(t, err)
+
+		m = &MCPConfig{ConfigFile: missing, MCPServers: inline, MergeStrategy: MergeStrategyFileOnly}
+		_, _, err = m.ResolveServers()
+		assert.Error(t, err)
+	})
+}
+
+func TestMCPConfigValidateMergeStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  MCPConfig
+		wantErr bool
+	}{
+		{name: "默认merge", config: MCPConfig{ConfigFile: "mcpservers.json"}},
+		{name: "merge只有mcp_servers", config: MCPConfig{MCPServers: map[string]*mcphost.ServerConfig{}, MergeStrategy: MergeStrategyMerge}},
+		{name: "inline_only不需要配置文件", config: MCPConfig{MergeStrategy: MergeStrategyInlineOnly}},
+		{name: "file_only缺少配置文件", config: MCPConfig{MCPServers: map[string]*mcphost.ServerConfig{}, Tools: []MCPToolConfig{{Server: "fetch", Name: "fetch"}}, MergeStrategy: MergeStrategyFileOnly}, wantErr: true},
+		{name: "file_only", config: MCPConfig{ConfigFile: "mcpservers.json", MergeStrategy: MergeStrategyFileOnly}},
+		{name: "无效的策略", config: MCPConfig{ConfigFile: "mcpservers.json", MergeStrategy: "override"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetToolsUsesMergedServers(t *testing.T) {
+	originalSettingsFactory := mcpHubFromSettingsFactory
+	originalFileFactory := mcpHubFactory
+	defer func() {
+		mcpHubFromSettingsFactory = originalSettingsFactory
+		mcpHubFactory = originalFileFactory
+	}()
+
+	var gotServers map[string]*mcphost.ServerConfig
+	mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
+		gotServers = settings.MCPServers
+		return nil, assert.AnError
+	}
+	fileFactoryCalled := false
+	mcpHubFactory = func(ctx context.Context, configFile string) (MCPHubInterface, error) {
+		fileFactoryCalled = true
+		return nil, assert.AnError
+	}
+
+	cfg := &Config{
+		MCP: MCPConfig{
+			ConfigFile: writeMCPServersFile(t),
+			MCPServers: map[string]*mcphost.ServerConfig{
+				"db": {TransportType: "sse", URL: "http://127.0.0.1:8080/sse"},
+			},
+		},
+	}
+	_, cleanup, err := cfg.GetTools(context.Background())
+	require.NoError(t, err)
+	cleanup()
+
+	assert.False(t, fileFactoryCalled)
+	assert.Len(t, gotServers, 3)
+	assert.Contains(t, gotServers, "fetch")
+	assert.Contains(t, gotServers, "db")
+
+	// 默认配置的mcp_servers为空时直接使用配置文件
+	cfg = NewDefaultConfig()
+	cfg.MCP.ConfigFile = "mcpservers.json"
+	cfg.MCP.Tools = []MCPToolConfig{{Server: "fetch", Name: "fetch"}}
+	_, cleanup, err = cfg.GetTools(context.Background())
+	require.NoError(t, err)
+	cleanup()
+	assert.True(t, fileFactoryCalled)
+}
+
+func TestGetToolsWithoutTools(t *testing.T) {
+	originalSettingsFactory := mcpHubFromSettingsFactory
+	originalFileFactory := mcpHubFactory
+	defer func() {
+		mcpHubFromSettingsFactory = originalSettingsFactory
+		mcpHubFactory = originalFileFactory
+	}()
+
+	hubCreated := false
+	mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
+		hubCreated = true
+		return nil, assert.AnError
+	}
+	mcpHubFactory = func(ctx context.Context, configFile string) (MCPHubInterface, error) {
+		hubCreated = true
+		return nil, assert.AnError
+	}
+
+	// 配置文件不存在也不影响只需要模型回答的任务
+	cfg := NewDefaultConfig()
+	cfg.MCP.ConfigFile = filepath.Join(t.TempDir(), "missing.json")
+	require.NoError(t, cfg.MCP.Validate())
+
+	tools, cleanup, err := cfg.GetTools(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, cleanup)
+	cleanup()
+	assert.NotNil(t, tools)
+	assert.Empty(t, tools)
+	assert.False(t, hubCreated)
+
+	// 附件仍然可以读取
+	cfg.Attachments.Dir = t.TempDir()
+	tools, cleanup, err = cfg.GetTools(context.Background())
+	require.NoError(t, err)
+	cleanup()
+	assert.Len(t, tools, 1)
+	assert.False(t, hubCreated)
+}
+
+func TestMCPConfigValidateWithoutTools(t *testing.T) {
+	// 没有工具和服务器时不需要配置文件
+	assert.NoError(t, (&MCPConfig{}).Validate())
+	assert.True(t, (&MCPConfig{}).NoToolsRequested())
+
+	// 选择了工具时仍然需要服务器来源
+	withTools := &MCPConfig{Tools: []MCPToolConfig{{Server: "fetch", Name: "fetch"}}}
+	assert.False(t, withTools.NoToolsRequested())
+	assert.ErrorContains(t, withTools.Validate(), errMsgMCPConfigFileEmpty)
+
+	// 无效的合并策略仍然报错
+	assert.Error(t, (&MCPConfig{MergeStrategy: "unknown"}).Validate())
+}
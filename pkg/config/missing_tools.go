@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// Policies for requested tools that no MCP server provides
+const (
+	MissingToolPolicyFail   = "fail"   // 任务失败并列出不存在的工具
+	MissingToolPolicyWarn   = "warn"   // 使用找到的工具继续执行，并通知不存在的工具
+	MissingToolPolicyIgnore = "ignore" // 使用找到的工具继续执行，仅记录日志
+)
+
+const (
+	errMsgMissingToolPolicyInvalid = "不支持的缺失工具策略: %s，可选值为 fail、warn 和 ignore"
+
+	// hubToolNotFoundPrefix starts the error mcphost returns for an unknown tool key
+	hubToolNotFoundPrefix = "工具不存在: "
+)
+
+// EffectiveMissingToolPolicy returns the configured missing tool policy, defaulting to fail
+func (m *MCPConfig) EffectiveMissingToolPolicy() string {
+	if strings.TrimSpace(m.MissingToolPolicy) == "" {
+		return MissingToolPolicyFail
+	}
+	return m.MissingToolPolicy
+}
+
+// validateMissingToolPolicy checks that MissingToolPolicy is one of the supported values
+func (m *MCPConfig) validateMissingToolPolicy() error {
+	switch m.EffectiveMissingToolPolicy() {
+	case MissingToolPolicyFail, MissingToolPolicyWarn, MissingToolPolicyIgnore:
+		return nil
+	}
+	return fmt.Errorf(errMsgMissingToolPolicyInvalid, m.MissingToolPolicy)
+}
+
+// MissingTool is a requested tool that no connected MCP server provides
+type MissingTool struct {
+	Server string `json:"server"` // 服务器名称
+	Name   string `json:"name"`   // 工具名称
+}
+
+// String returns the tool in the server:tool format of -mcp-tools
+func (t MissingTool) String() string {
+	return t.Server + toolSpecServerSeparator + t.Name
+}
+
+// MissingToolsError is returned by Config.GetTools when requested tools do
+// not exist and the policy is fail, or when none of the requested MCP tools
+// exist whatever the policy.
+type MissingToolsError struct {
+	Tools []MissingTool
+}
+
+// Error lists the missing tools
+func (e *MissingToolsError) Error() string {
+	return "以下工具不存在: " + JoinMissingTools(e.Tools)
+}
+
+// JoinMissingTools formats tools as a comma separated server:tool list
+func JoinMissingTools(tools []MissingTool) string {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = t.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// missingToolsHandlerKey is the context key of the handler receiving missing tools
+type missingToolsHandlerKey struct{}
+
+// WithMissingToolsHandler returns a context in which Config.GetTools reports
+// the requested tools it skipped under the warn policy to handler
+func WithMissingToolsHandler(ctx context.Context, handler func([]MissingTool)) context.Context {
+	return context.WithValue(ctx, missingToolsHandlerKey{}, handler)
+}
+
+// ReportMissingTools sends missing to the handler of ctx, if any. Tool
+// providers other than Config use it to report the tools they skipped.
+func ReportMissingTools(ctx context.Context, missing []MissingTool) {
+	if handler, ok := ctx.Value(missingToolsHandlerKey{}).(func([]MissingTool)); ok && handler != nil {
+		handler(missing)
+	}
+}
+
+// getMCPTools gets the tools of toolConfigs from hub. Tools the hub does not
+// know are removed from the request one by one, so that all of them can be
+// reported instead of only the first.
+//
+// Returns:
+//   - []tool.BaseTool: Tools found, in the order of the found configs
+//   - []MCPToolConfig: Configs of the tools found
+//   - []MissingTool: Requested tools that do not exist
+//   - error: Error of the hub other than unknown tools
+func getMCPTools(ctx context.Context, hub MCPHubInterface, toolConfigs []MCPToolConfig) ([]tool.BaseTool, []MCPToolConfig, []MissingTool, error) {
+	remaining := toolConfigs
+	var missing []MissingTool
+	for len(remaining) > 0 {
+		keys := make([]string, len(remaining))
+		for i, toolConfig := range remaining {
+			keys[i] = models.GenerateToolKey(toolConfig.Server, toolConfig.Name)
+		}
+
+		tools, err := hub.GetEinoTools(ctx, keys)
+		if err == nil {
+			return tools, remaining, missing, nil
+		}
+		index := unknownToolIndex(err, keys)
+		if index < 0 {
+			return nil, nil, missing, err
+		}
+		missing = append(missing, MissingTool{Server: remaining[index].Server, Name: remaining[index].Name})
+		next := make([]MCPToolConfig, 0, len(remaining)-1)
+		next = append(next, remaining[:index]...)
+		remaining = append(next, remaining[index+1:]...)
+	}
+	return nil, nil, missing, nil
+}
+
+// unknownToolIndex returns the index of the key err reports as unknown, -1 if err is another error
+func unknownToolIndex(err error, keys []string) int {
+	msg := err.Error()
+	if !strings.HasPrefix(msg, hubToolNotFoundPrefix) {
+		return -1
+	}
+	unknown := strings.TrimPrefix(msg, hubToolNotFoundPrefix)
+	for i, key := range keys {
+		if key == unknown {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyMissingToolPolicy decides whether a task continues without the missing tools
+//
+// Parameters:
+//   - ctx: Context whose missing tools handler is notified under the warn policy
+//   - missing: Requested tools that do not exist
+//   - found: Number of requested MCP tools that exist
+//
+// Returns:
+//   - error: *MissingToolsError if the task cannot continue
+func (m *MCPConfig) applyMissingToolPolicy(ctx context.Context, missing []MissingTool, found int) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	policy := m.EffectiveMissingToolPolicy()
+	// 所有请求的MCP工具都不存在时，工具列表显然已失效，任何策略下都失败
+	if policy == MissingToolPolicyFail || found == 0 {
+		return &MissingToolsError{Tools: missing}
+	}
+	log.Printf("以下工具不存在，使用其余 %d 个工具继续执行: %s", found, JoinMissingTools(missing))
+	if policy == MissingToolPolicyWarn {
+		ReportMissingTools(ctx, missing)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectHubTools makes hub answer GetEinoTools like mcphost: the first
+// unknown key fails the whole request
+func expectHubTools(hub *MockMCPHubInterface, available map[string]tool.BaseTool) {
+	hub.EXPECT().GetEinoTools(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, keys []string) ([]tool.BaseTool, error) {
+		var tools []tool.BaseTool
+		for _, key := range keys {
+			t, ok := available[key]
+			if !ok {
+				return nil, fmt.Errorf("工具不存在: %s", key)
+			}
+			tools = append(tools, t)
+		}
+		return tools, nil
+	}).AnyTimes()
+	hub.EXPECT().CloseServers().Return(nil).AnyTimes()
+}
+
+func TestGetToolsMissingToolPolicies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	originalNewMCPHub := mcpHubFactory
+	defer func() { mcpHubFactory = originalNewMCPHub }()
+
+	hub := NewMockMCPHubInterface(ctrl)
+	expectHubTools(hub, map[string]tool.BaseTool{
+		"fofa_search": &invokableMockTool{mockTool: mockTool{name: "search"}},
+		"ddg_search":  &invokableMockTool{mockTool: mockTool{name: "search"}},
+	})
+	mcpHubFactory = func(ctx context.Context, configFile string) (MCPHubInterface, error) {
+		return hub, nil
+	}
+
+	newConfig := func(policy string, tools ...MCPToolConfig) *Config {
+		return &Config{MCP: MCPConfig{ConfigFile: "test_mcpservers.json", MissingToolPolicy: policy, Tools: tools}}
+	}
+	someMissing := []MCPToolConfig{
+		{Server: "fofa", Name: "search"},
+		{Server: "fofa", Name: "host"},
+		{Server: "ddg", Name: "search"},
+		{Server: "old", Name: "lookup"},
+	}
+	wantMissing := []MissingTool{{Server: "fofa", Name: "host"}, {Server: "old", Name: "lookup"}}
+	internalTools, err := GetInternalTools(context.Background(), "", IntegrationsConfig{})
+	require.NoError(t, err)
+
+	t.Run("fail", func(t *testing.T) {
+		for _, policy := range []string{"", MissingToolPolicyFail} {
+			_, _, err := newConfig(policy, someMissing...).GetTools(context.Background())
+			var missingErr *MissingToolsError
+			require.ErrorAs(t, err, &missingErr)
+			assert.Equal(t, wantMissing, missingErr.Tools)
+			assert.Contains(t, err.Error(), "fofa:host, old:lookup")
+		}
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		var reported []MissingTool
+		ctx := WithMissingToolsHandler(context.Background(), func(missing []MissingTool) { reported = missing })
+		tools, cleanup, err := newConfig(MissingToolPolicyWarn, someMissing...).GetTools(ctx)
+		require.NoError(t, err)
+		defer cleanup()
+		assert.Len(t, tools, len(internalTools)+2)
+		assert.Equal(t, wantMissing, reported)
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		ctx := WithMissingToolsHandler(context.Background(), func([]MissingTool) { t.Error("ignore策略不应通知缺失的工具") })
+		tools, cleanup, err := newConfig(MissingToolPolicyIgnore, someMissing...).GetTools(ctx)
+		require.NoError(t, err)
+		defer cleanup()
+		assert.Len(t, tools, len(internalTools)+2)
+	})
+
+	t.Run("all missing", func(t *testing.T) {
+		for _, policy := range []string{MissingToolPolicyWarn, MissingToolPolicyIgnore} {
+			_, _, err := newConfig(policy, MCPToolConfig{Server: "fofa", Name: "host"}, MCPToolConfig{Server: "old", Name: "lookup"}).GetTools(context.Background())
+			var missingErr *MissingToolsError
+			require.ErrorAs(t, err, &missingErr, policy)
+			assert.Equal(t, wantMissing, missingErr.Tools)
+		}
+	})
+}
+
+func TestMCPConfigValidateMissingToolPolicy(t *testing.T) {
+	m := MCPConfig{ConfigFile: "test_mcpservers.json", MissingToolPolicy: "skip"}
+	errs := m.ValidateDetailed()
+	require.Len(t, errs, 1)
+	assert.Equal(t, "missing_tool_policy", errs[0].Field)
+
+	m.MissingToolPolicy = MissingToolPolicyWarn
+	assert.NoError(t, m.Validate())
+	assert.Equal(t, MissingToolPolicyFail, (&MCPConfig{}).EffectiveMissingToolPolicy())
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"context"
+
+	"github.com/cloudwego/eino/components/model"
+)
+
+// ModelBuilder creates the chat model of a configuration
+type ModelBuilder func(ctx context.Context) (model.ToolCallingChatModel, error)
+
+// ModelCache shares chat models between tasks with the same LLM settings,
+// so that their HTTP connections are reused. Attach it to the context of a
+// task with WithModelCache.
+type ModelCache interface {
+	// GetModel returns the model cached for the settings of c, calling build
+	// on a miss
+	GetModel(ctx context.Context, c *Config, build ModelBuilder) (model.ToolCallingChatModel, error)
+}
+
+// modelCacheKey is the context key of the ModelCache of a task
+type modelCacheKey struct{}
+
+// WithModelCache returns a context in which Config.GetModel uses cache
+func WithModelCache(ctx context.Context, cache ModelCache) context.Context {
+	return context.WithValue(ctx, modelCacheKey{}, cache)
+}
+
+// modelCacheFrom returns the cache of ctx, nil if there is none
+func modelCacheFrom(ctx context.Context) ModelCache {
+	cache, _ := ctx.Value(modelCacheKey{}).(ModelCache)
+	return cache
+}
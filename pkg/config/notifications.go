@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Event types of notifications, the types of the notify events of the web server
+const (
+	NotificationEventMessage    = "message"
+	NotificationEventThinking   = "thinking"
+	NotificationEventToolCall   = "tool_call"
+	NotificationEventToolResult = "tool_result"
+	NotificationEventPlan       = "plan"
+	NotificationEventResult     = "result"
+	NotificationEventError      = "error"
+)
+
+// NotificationEvents lists the event types a notification sink can select
+var NotificationEvents = []string{
+	NotificationEventMessage,
+	NotificationEventThinking,
+	NotificationEventToolCall,
+	NotificationEventToolResult,
+	NotificationEventPlan,
+	NotificationEventResult,
+	NotificationEventError,
+}
+
+// DefaultWebhookEvents are the events posted by webhooks that select none
+var DefaultWebhookEvents = []string{NotificationEventResult, NotificationEventError}
+
+const (
+	// DefaultWebhookTimeout is the timeout of a webhook request that configures none
+	DefaultWebhookTimeout = 10 * time.Second
+
+	// MaxWebhookRetries is the largest number of retries of a webhook request
+	MaxWebhookRetries = 10
+
+	errMsgWebhookURLEmpty      = "Webhook URL不能为空"
+	errMsgWebhookURLInvalid    = "Webhook URL %q 无效，必须是http或https地址"
+	errMsgWebhookTimeout       = "Webhook超时时间不能为负数"
+	errMsgWebhookRetries       = "Webhook重试次数必须在0到%d之间"
+	errMsgWebhookHeaderEmpty   = "Webhook请求头名称不能为空"
+	errMsgNotificationEvent    = "不支持的通知事件类型: %s，可选值为 %s"
+	errMsgNotificationFilePath = "通知文件路径不能为空"
+)
+
+// NotificationsConfig configures extra sinks receiving the notifications of
+// every task in addition to the notifier of the caller, such as a webhook
+// posting the results to a chat channel. Failed deliveries never fail a task.
+type NotificationsConfig struct {
+	Webhooks []WebhookConfig  `mapstructure:"webhooks" json:"webhooks,omitempty" yaml:"webhooks,omitempty"` // 以HTTP POST推送事件的Webhook
+	Files    []FileSinkConfig `mapstructure:"files" json:"files,omitempty" yaml:"files,omitempty"`          // 以JSON Lines追加事件的文件
+}
+
+// WebhookConfig configures a webhook receiving the selected events of a task as JSON
+type WebhookConfig struct {
+	URL     string            `mapstructure:"url" json:"url" yaml:"url"`                                 // 接收事件的地址
+	Events  []string          `mapstructure:"events" json:"events,omitempty" yaml:"events,omitempty"`    // 推送的事件类型，为空时推送result和error
+	Headers map[string]string `mapstructure:"headers" json:"headers,omitempty" yaml:"headers,omitempty"` // 附加的请求头，如Authorization
+	Timeout int               `mapstructure:"timeout" json:"timeout" yaml:"timeout"`                     // 单次请求超时时间（秒），0表示使用默认值（10）
+	Retries int               `mapstructure:"retries" json:"retries" yaml:"retries"`                     // 请求失败后的重试次数，0表示不重试
+}
+
+// FileSinkConfig configures a file the selected events of a task are appended to as JSON Lines
+type FileSinkConfig struct {
+	Path   string   `mapstructure:"path" json:"path" yaml:"path"`                           // 追加事件的文件路径
+	Events []string `mapstructure:"events" json:"events,omitempty" yaml:"events,omitempty"` // 记录的事件类型，为空时记录全部事件
+}
+
+// Enabled reports whether any sink is configured
+func (n *NotificationsConfig) Enabled() bool {
+	return len(n.Webhooks) > 0 || len(n.Files) > 0
+}
+
+// Validate validates the notification sinks.
+//
+// Returns:
+//   - error: validation error if configuration is invalid, nil otherwise
+func (n *NotificationsConfig) Validate() error {
+	return n.ValidateDetailed().First()
+}
+
+// ValidateDetailed validates the notification sinks and reports every invalid field.
+//
+// Returns:
+//   - FieldErrors: All invalid fields, empty if the configuration is valid
+func (n *NotificationsConfig) ValidateDetailed() FieldErrors {
+	var errs FieldErrors
+	for i, webhook := range n.Webhooks {
+		prefix := fmt.Sprintf("webhooks[%d]", i)
+		if err := validateWebhookURL(webhook.URL); err != nil {
+			errs.add(prefix+".url", err)
+		}
+		if err := validateNotificationEvents(webhook.Events); err != nil {
+			errs.add(prefix+".events", err)
+		}
+		for name := range webhook.Headers {
+			if strings.TrimSpace(name) == "" {
+				errs.add(prefix+".headers", errors.New(errMsgWebhookHeaderEmpty))
+				break
+			}
+		}
+		if webhook.Timeout < 0 {
+			errs.add(prefix+".timeout", errors.New(errMsgWebhookTimeout))
+		}
+		if webhook.Retries < 0 || webhook.Retries > MaxWebhookRetries {
+			errs.add(prefix+".retries", fmt.Errorf(errMsgWebhookRetries, MaxWebhookRetries))
+		}
+	}
+	for i, file := range n.Files {
+		prefix := fmt.Sprintf("files[%d]", i)
+		if strings.TrimSpace(file.Path) == "" {
+			errs.add(prefix+".path", errors.New(errMsgNotificationFilePath))
+		}
+		if err := validateNotificationEvents(file.Events); err != nil {
+			errs.add(prefix+".events", err)
+		}
+	}
+	return errs
+}
+
+// TimeoutDuration returns the timeout of a single request, DefaultWebhookTimeout when none is configured
+func (w *WebhookConfig) TimeoutDuration() time.Duration {
+	if w.Timeout <= 0 {
+		return DefaultWebhookTimeout
+	}
+	return time.Duration(w.Timeout) * time.Second
+}
+
+// SelectedEvents returns the events posted by the webhook
+func (w *WebhookConfig) SelectedEvents() []string {
+	if len(w.Events) == 0 {
+		return DefaultWebhookEvents
+	}
+	return w.Events
+}
+
+// validateWebhookURL ensures raw is an absolute http or https URL
+func validateWebhookURL(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return errors.New(errMsgWebhookURLEmpty)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf(errMsgWebhookURLInvalid, raw)
+	}
+	return nil
+}
+
+// validateNotificationEvents ensures every event is one of NotificationEvents
+func validateNotificationEvents(events []string) error {
+	for _, event := range events {
+		if !slices.Contains(NotificationEvents, event) {
+			return fmt.Errorf(errMsgNotificationEvent, event, strings.Join(NotificationEvents, "、"))
+		}
+	}
+	return nil
+}
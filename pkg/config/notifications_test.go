@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    NotificationsConfig
+		fields []string
+	}{
+		{"未配置", NotificationsConfig{}, nil},
+		{"有效配置", NotificationsConfig{
+			Webhooks: []WebhookConfig{{URL: "https://hooks.slack.com/services/T000/B000/XXX", Events: []string{NotificationEventResult, NotificationEventToolCall}, Timeout: 5, Retries: 3}},
+			Files:    []FileSinkConfig{{Path: "./data/notify.jsonl"}},
+		}, nil},
+		{"缺少URL", NotificationsConfig{Webhooks: []WebhookConfig{{}}}, []string{"webhooks[0].url"}},
+		{"不支持的协议", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "ftp://example.com"}}}, []string{"webhooks[0].url"}},
+		{"相对地址", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "/hook"}}}, []string{"webhooks[0].url"}},
+		{"未知事件", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "http://localhost/hook", Events: []string{"done"}}}}, []string{"webhooks[0].events"}},
+		{"空请求头", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "http://localhost/hook", Headers: map[string]string{" ": "x"}}}}, []string{"webhooks[0].headers"}},
+		{"负数超时和重试", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "http://localhost/hook", Timeout: -1, Retries: -1}}}, []string{"webhooks[0].timeout", "webhooks[0].retries"}},
+		{"重试次数过多", NotificationsConfig{Webhooks: []WebhookConfig{{URL: "http://localhost/hook", Retries: MaxWebhookRetries + 1}}}, []string{"webhooks[0].retries"}},
+		{"文件缺少路径", NotificationsConfig{Files: []FileSinkConfig{{}, {Path: "a.jsonl", Events: []string{"unknown"}}}}, []string{"files[0].path", "files[1].events"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.cfg.ValidateDetailed()
+			var fields []string
+			for _, fieldErr := range errs {
+				fields = append(fields, fieldErr.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+			assert.Equal(t, len(tt.fields) > 0, tt.cfg.Validate() != nil)
+		})
+	}
+
+	// 配置整体验证时带有notifications前缀
+	cfg := NewDefaultConfig()
+	cfg.Notifications.Webhooks = []WebhookConfig{{URL: "example.com"}}
+	errs := cfg.ValidateDetailed()
+	require.Len(t, errs, 1)
+	assert.Equal(t, "notifications.webhooks[0].url", errs[0].Field)
+	assert.ErrorContains(t, cfg.Validate(), "通知配置验证失败")
+}
+
+func TestWebhookConfigDefaults(t *testing.T) {
+	webhook := WebhookConfig{URL: "http://localhost/hook"}
+	assert.Equal(t, DefaultWebhookTimeout, webhook.TimeoutDuration())
+	assert.Equal(t, DefaultWebhookEvents, webhook.SelectedEvents())
+
+	webhook.Timeout = 3
+	webhook.Events = []string{NotificationEventMessage}
+	assert.Equal(t, 3*time.Second, webhook.TimeoutDuration())
+	assert.Equal(t, []string{NotificationEventMessage}, webhook.SelectedEvents())
+}
+
+func TestLoadConfigNotifications(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+notifications:
+  webhooks:
+    - url: https://example.com/hook
+      events: [result, error]
+      headers:
+        Authorization: Bearer token
+      timeout: 5
+      retries: 2
+  files:
+    - path: ./data/notify.jsonl
+`), 0o644))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	errs, err := SchemaFieldErrors(data)
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, NotificationsConfig{
+		Webhooks: []WebhookConfig{{
+			URL:     "https://example.com/hook",
+			Events:  []string{NotificationEventResult, NotificationEventError},
+			Headers: map[string]string{"authorization": "Bearer token"},
+			Timeout: 5,
+			Retries: 2,
+		}},
+		Files: []FileSinkConfig{{Path: "./data/notify.jsonl"}},
+	}, cfg.Notifications)
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	errMsgRedactPatternEmpty   = "脱敏规则的正则表达式不能为空"
+	errMsgRedactPatternInvalid = "脱敏规则的正则表达式无效: %s: %w"
+	errMsgOutputMaxLength      = "输出最大长度不能为负数"
+)
+
+// RedactRule describes a single regex based redaction applied to the final result.
+type RedactRule struct {
+	Pattern     string `mapstructure:"pattern" json:"pattern" yaml:"pattern"`             // 正则表达式
+	Replacement string `mapstructure:"replacement" json:"replacement" yaml:"replacement"` // 替换内容，支持$1等分组引用
+}
+
+// OutputConfig configures the post-processing pipeline that runs on the agent's
+// final answer before it is delivered to the notifier.
+//
+// Processors are applied in a fixed order:
+//  1. Redact rules, in the order they are listed
+//  2. Markdown link stripping, if enabled
+//  3. Truncation to MaxLength characters, if MaxLength > 0
+type OutputConfig struct {
+	Redact             []RedactRule `mapstructure:"redact" json:"redact" yaml:"redact"`                                           // 正则脱敏规则
+	StripMarkdownLinks bool         `mapstructure:"strip_markdown_links" json:"strip_markdown_links" yaml:"strip_markdown_links"` // 是否去除Markdown链接，仅保留链接文本
+	MaxLength          int          `mapstructure:"max_length" json:"max_length" yaml:"max_length"`                               // 最大输出长度（字符数），0表示不限制
+}
+
+// IsEmpty reports whether no output processing is configured.
+func (o *OutputConfig) IsEmpty() bool {
+	return len(o.Redact) == 0 && !o.StripMarkdownLinks && o.MaxLength == 0
+}
+
+// Validate validates the output configuration.
+// It ensures every redact pattern compiles and MaxLength is not negative.
+//
+// Returns:
+//   - error: validation error if configuration is invalid, nil otherwise
+func (o *OutputConfig) Validate() error {
+	for _, rule := range o.Redact {
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return errors.New(errMsgRedactPatternEmpty)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf(errMsgRedactPatternInvalid, rule.Pattern, err)
+		}
+	}
+	if o.MaxLength < 0 {
+		return errors.New(errMsgOutputMaxLength)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	errMsgOutputSchemaInvalid  = "输出JSON Schema无效: %w"
+	errMsgOutputSchemaRepairs  = "输出修复轮数不能为负数"
+	defaultOutputSchemaRepairs = 2
+)
+
+// HasOutputSchema reports whether the final answer must be JSON matching OutputSchema
+func (c *Config) HasOutputSchema() bool {
+	return strings.TrimSpace(c.OutputSchema) != ""
+}
+
+// EffectiveOutputSchemaRepairs returns how many times the model is asked to fix
+// an output that does not match OutputSchema. 0 falls back to the default of 2.
+func (c *Config) EffectiveOutputSchemaRepairs() int {
+	if c.OutputSchemaRepairs == 0 {
+		return defaultOutputSchemaRepairs
+	}
+	return c.OutputSchemaRepairs
+}
+
+// CompileOutputSchema parses OutputSchema into a schema that can validate values.
+// OutputSchema is JSON text rather than a YAML mapping because configuration
+// keys are case-insensitive, which would lowercase property names.
+// The schema uses the JSON Schema subset supported by OpenAPI 3.
+//
+// Returns:
+//   - *openapi3.Schema: Compiled schema, nil when no output schema is configured
+//   - error: Error if the schema is malformed
+func (c *Config) CompileOutputSchema() (*openapi3.Schema, error) {
+	if !c.HasOutputSchema() {
+		return nil, nil
+	}
+
+	var schema openapi3.Schema
+	if err := json.Unmarshal([]byte(c.OutputSchema), &schema); err != nil {
+		return nil, fmt.Errorf(errMsgOutputSchemaInvalid, err)
+	}
+	if err := schema.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf(errMsgOutputSchemaInvalid, err)
+	}
+	return &schema, nil
+}
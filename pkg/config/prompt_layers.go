@@ -0,0 +1,18 @@
+package config
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+)
+
+const errMsgGuardPromptTooLong = "安全约束提示词不能超过 %d 个字符，当前 %d 个字符"
+
+// validatePromptLayers adds invalid prompt layers to errs, the guard prompt
+// is sent with every model call so its size is limited
+func (c *Config) validatePromptLayers(errs *FieldErrors) {
+	if n := utf8.RuneCountInString(c.GuardPrompt); n > models.MaxGuardPromptLength {
+		errs.add("guard_prompt", fmt.Errorf(errMsgGuardPromptTooLong, models.MaxGuardPromptLength, n))
+	}
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Configuration layers recorded by ConfigSource, from the lowest to the
+// highest precedence
+const (
+	SourceDefault  = "default"  // NewDefaultConfig的默认值
+	SourceFile     = "file"     // 配置文件
+	SourceEnv      = "env"      // MCPHOST_开头的环境变量
+	SourceDatabase = "database" // 数据库中保存的配置、LLM配置、系统提示词或任务模板
+	SourceFlag     = "flag"     // 命令行参数
+	SourceRequest  = "request"  // Web任务请求
+)
+
+// expandedSections are the sections of Config whose fields are tracked one by one
+var expandedSections = map[string]bool{"llm": true, "mcp": true}
+
+// ConfigSource records which layer supplied the effective value of each
+// configuration field, keyed by the path of the field as in configuration
+// files: the top-level fields such as "max_step" and the fields of the llm
+// and mcp sections such as "llm.model". Fields without an entry keep their
+// default value. A nil ConfigSource records nothing, so code assembling a
+// configuration can always record.
+type ConfigSource map[string]string
+
+// FieldSource is the effective value of a configuration field and the layer that supplied it
+type FieldSource struct {
+	Field  string `json:"field"`  // 字段路径
+	Source string `json:"source"` // 提供该值的配置层
+	Value  any    `json:"value"`  // 生效的值，密钥已隐藏
+}
+
+// ConfigFields returns the paths of the fields tracked by ConfigSource in
+// the order of Config
+func ConfigFields() []string {
+	var fields []string
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		key := fieldKey(field)
+		if key == "" {
+			continue
+		}
+		if !expandedSections[key] {
+			fields = append(fields, key)
+			continue
+		}
+		for j := 0; j < field.Type.NumField(); j++ {
+			if nested := fieldKey(field.Type.Field(j)); nested != "" {
+				fields = append(fields, key+"."+nested)
+			}
+		}
+	}
+	return fields
+}
+
+// fieldKey returns the configuration file key of a struct field, empty for fields not read from files
+func fieldKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+	if key == "-" || !field.IsExported() {
+		return ""
+	}
+	return key
+}
+
+// Set records that source supplied the field at path. A section such as
+// "llm" records all of its tracked fields.
+//
+// Parameters:
+//   - path: Field path, see ConfigFields
+//   - source: One of the Source constants
+func (s ConfigSource) Set(path, source string) {
+	if s == nil {
+		return
+	}
+	if !expandedSections[path] {
+		s[path] = source
+		return
+	}
+	for _, field := range ConfigFields() {
+		if strings.HasPrefix(field, path+".") {
+			s[field] = source
+		}
+	}
+}
+
+// Of returns the layer that supplied the field at path, SourceDefault if no layer set it
+func (s ConfigSource) Of(path string) string {
+	if source, ok := s[path]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// SetField assigns value to field and records that source supplied the
+// field at path. Code that merges configuration layers uses it instead of
+// plain assignments, so the effective configuration can be explained.
+//
+// Parameters:
+//   - sources: Sources of the configuration, may be nil
+//   - path: Field path, see ConfigFields
+//   - source: One of the Source constants
+//   - field: Pointer to the field of the configuration
+//   - value: New value of the field
+func SetField[T any](sources ConfigSource, path, source string, field *T, value T) {
+	*field = value
+	sources.Set(path, source)
+}
+
+// Explain returns the effective value and the source of every tracked field
+// of cfg, in the order of ConfigFields. Secrets are redacted as in Redact.
+//
+// Parameters:
+//   - cfg: Effective configuration assembled with s
+//
+// Returns:
+//   - []FieldSource: Value and source of every tracked field
+//   - error: Error copying the configuration
+func (s ConfigSource) Explain(cfg *Config) ([]FieldSource, error) {
+	// 通过JSON复制配置，隐藏密钥时不修改cfg
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var copied Config
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	copied.LLM.APIKeyFile = cfg.LLM.APIKeyFile
+	copied.LLM.APIKeyCmd = cfg.LLM.APIKeyCmd
+	copied.Redact()
+
+	fields := ConfigFields()
+	explained := make([]FieldSource, 0, len(fields))
+	for _, field := range fields {
+		explained = append(explained, FieldSource{Field: field, Source: s.Of(field), Value: fieldValue(&copied, field)})
+	}
+	return explained, nil
+}
+
+// fieldValue returns the value of the field at path of cfg
+func fieldValue(cfg *Config, path string) any {
+	value := reflect.ValueOf(cfg).Elem()
+	for _, key := range strings.Split(path, ".") {
+		found := false
+		for i := 0; i < value.NumField(); i++ {
+			if fieldKey(value.Type().Field(i)) == key {
+				value = value.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return value.Interface()
+}
+
+// viperSources returns the sources of the fields read by v: SourceEnv for
+// fields with a key overridden by an environment variable, SourceFile for
+// the other fields of the configuration file. Like Unmarshal, only keys of
+// the file are considered.
+func viperSources(v *viper.Viper) ConfigSource {
+	sources := ConfigSource{}
+	fields := ConfigFields()
+	for _, key := range v.AllKeys() {
+		source := SourceFile
+		if _, ok := os.LookupEnv(envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))); ok {
+			source = SourceEnv
+		}
+		for _, field := range fields {
+			if key != field && !strings.HasPrefix(key, field+".") {
+				continue
+			}
+			if sources[field] != SourceEnv {
+				sources[field] = source
+			}
+			break
+		}
+	}
+	return sources
+}
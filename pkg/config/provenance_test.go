@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSourceLayers(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+max_step: 10
+system_prompt: "文件中的提示词"
+llm:
+  type: "ollama"
+  base_url: "http://127.0.0.1:11434"
+  model: "qwen3:14b"
+  api_key: "file-secret"
+`
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+	t.Setenv("MCPHOST_MAX_STEP", "15")
+	t.Setenv("MCPHOST_LLM_MODEL", "qwen3:32b")
+
+	cfg, sources, err := LoadConfigWithSources(configPath)
+	require.NoError(t, err)
+
+	// 环境变量覆盖配置文件，未出现的字段使用默认值
+	assert.Equal(t, 15, cfg.MaxStep)
+	assert.Equal(t, SourceEnv, sources.Of("max_step"))
+	assert.Equal(t, SourceEnv, sources.Of("llm.model"))
+	assert.Equal(t, SourceFile, sources.Of("system_prompt"))
+	assert.Equal(t, SourceFile, sources.Of("llm.base_url"))
+	assert.Equal(t, SourceDefault, sources.Of("task_timeout"))
+
+	// 命令行参数覆盖环境变量，数据库中的LLM配置覆盖整个llm段
+	SetField(sources, "max_step", SourceFlag, &cfg.MaxStep, 25)
+	SetField(sources, "llm", SourceDatabase, &cfg.LLM, LLMConfig{Type: "openai", Model: "gpt-4o", APIKey: "db-secret"})
+	assert.Equal(t, 25, cfg.MaxStep)
+	assert.Equal(t, SourceFlag, sources.Of("max_step"))
+	assert.Equal(t, SourceDatabase, sources.Of("llm.model"))
+	assert.Equal(t, SourceDatabase, sources.Of("llm.base_url"))
+
+	explained, err := sources.Explain(cfg)
+	require.NoError(t, err)
+	require.Len(t, explained, len(ConfigFields()))
+	fields := make(map[string]FieldSource, len(explained))
+	for _, field := range explained {
+		fields[field.Field] = field
+	}
+	assert.Equal(t, FieldSource{Field: "max_step", Source: SourceFlag, Value: 25}, fields["max_step"])
+	assert.Equal(t, "gpt-4o", fields["llm.model"].Value)
+	// 密钥已隐藏，原配置不受影响
+	assert.Equal(t, RedactedValue, fields["llm.api_key"].Value)
+	assert.Equal(t, "db-secret", cfg.LLM.APIKey)
+}
+
+func TestConfigSourceNil(t *testing.T) {
+	// nil不记录来源，但仍然赋值
+	var sources ConfigSource
+	cfg := NewDefaultConfig()
+	SetField(sources, "max_step", SourceFlag, &cfg.MaxStep, 3)
+	assert.Equal(t, 3, cfg.MaxStep)
+	assert.Equal(t, SourceDefault, sources.Of("max_step"))
+
+	assert.Contains(t, ConfigFields(), "llm.api_key")
+	assert.Contains(t, ConfigFields(), "mcp.tools")
+	assert.NotContains(t, ConfigFields(), "llm")
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/LubyRuffy/mcpagent/pkg/replay"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+const (
+	errMsgReplayBothPaths    = "record_path 和 play_path 不能同时设置"
+	errMsgReplayMatchInvalid = "不支持的回放匹配方式: %s，可选值为 strict 和 fuzzy"
+)
+
+// ReplayConfig records a task into a replay file or plays one back.
+// Playing back replaces the model and the MCP servers by the recording, so
+// a task runs deterministically without network access. Placeholders such
+// as {date} should be fixed with placeholders for strict matching, since the
+// messages sent to the model must equal the recorded ones.
+type ReplayConfig struct {
+	RecordPath string `mapstructure:"record_path" json:"record_path,omitempty" yaml:"record_path,omitempty"` // 录制大模型请求和工具调用的文件，为空时不录制
+	PlayPath   string `mapstructure:"play_path" json:"play_path,omitempty" yaml:"play_path,omitempty"`       // 回放的录制文件，设置后不连接大模型和MCP服务器
+	Match      string `mapstructure:"match" json:"match,omitempty" yaml:"match,omitempty"`                   // 回放时的匹配方式：strict（默认）或 fuzzy
+}
+
+// Plays reports whether the model and the tools are played back from a recording
+func (r *ReplayConfig) Plays() bool {
+	return r.PlayPath != ""
+}
+
+// validateReplay adds invalid replay settings to errs
+func (c *Config) validateReplay(errs *FieldErrors) {
+	if c.Replay.RecordPath != "" && c.Replay.PlayPath != "" {
+		errs.add("replay", errors.New(errMsgReplayBothPaths))
+	}
+	switch c.Replay.Match {
+	case "", replay.MatchStrict, replay.MatchFuzzy:
+	default:
+		errs.add("replay.match", fmt.Errorf(errMsgReplayMatchInvalid, c.Replay.Match))
+	}
+}
+
+// player loads the recording of PlayPath, every call returns a player
+// starting with the first recorded call
+func (r *ReplayConfig) player() (*replay.Player, error) {
+	session, err := replay.LoadSession(r.PlayPath)
+	if err != nil {
+		return nil, err
+	}
+	return replay.NewPlayer(session, r.Match), nil
+}
+
+// recordModel returns m recording into RecordPath, m itself without a RecordPath
+func (r *ReplayConfig) recordModel(m model.ToolCallingChatModel) model.ToolCallingChatModel {
+	if r.RecordPath == "" {
+		return m
+	}
+	return replay.RecorderFor(r.RecordPath).WrapModel(m)
+}
+
+// recordTools returns tools recording into RecordPath, tools themselves without a RecordPath
+func (r *ReplayConfig) recordTools(ctx context.Context, tools []tool.BaseTool) []tool.BaseTool {
+	if r.RecordPath == "" {
+		return tools
+	}
+	return replay.RecorderFor(r.RecordPath).WrapTools(ctx, tools)
+}
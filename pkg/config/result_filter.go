@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/resultdiff"
+	"github.com/LubyRuffy/mcpagent/pkg/resultfilter"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+const errMsgResultFilterInvalid = "工具 %s 的结果过滤表达式无效: %w"
+
+// resultFilterFieldErrors reports the tools whose result_filter does not compile,
+// such as "tools[1].result_filter" for the second tool
+func resultFilterFieldErrors(field string, tools []MCPToolConfig) FieldErrors {
+	var errs FieldErrors
+	for i, toolConfig := range tools {
+		if toolConfig.ResultFilter == "" {
+			continue
+		}
+		if _, err := resultfilter.Compile(toolConfig.ResultFilter); err != nil {
+			errs.add(fmt.Sprintf("%s[%d].result_filter", field, i), fmt.Errorf(errMsgResultFilterInvalid, toolConfig.Name, err))
+		}
+	}
+	return errs
+}
+
+// compileResultFilter compiles the result filter of a tool, nil if it has
+// none or it does not compile. Validation reports invalid filters, a tool
+// whose filter still fails here returns unfiltered results.
+func compileResultFilter(toolConfig MCPToolConfig) *resultfilter.Filter {
+	if toolConfig.ResultFilter == "" {
+		return nil
+	}
+	filter, err := resultfilter.Compile(toolConfig.ResultFilter)
+	if err != nil {
+		log.Printf("工具 %s:%s 的结果过滤表达式无效，不过滤结果: %v", toolConfig.Server, toolConfig.Name, err)
+		return nil
+	}
+	return filter
+}
+
+// postProcessTool applies the result filter of toolConfig to t and, when
+// diff_against_previous is set, prepends the diff to the previous result of
+// the filtered result
+func postProcessTool(t tool.BaseTool, toolConfig MCPToolConfig) tool.BaseTool {
+	t = resultfilter.WrapTool(t, compileResultFilter(toolConfig))
+	if toolConfig.DiffAgainstPrevious {
+		t = resultdiff.WrapTool(t, models.GenerateToolKey(toolConfig.Server, toolConfig.Name))
+	}
+	return t
+}
+
+// filterTools applies the result filters and result diffing of toolConfigs to MCP tools.
+// tools must be in the order of toolConfigs, as returned by MCPHubInterface.GetEinoTools.
+func (m *MCPConfig) filterTools(tools []tool.BaseTool, toolConfigs []MCPToolConfig) []tool.BaseTool {
+	if len(tools) != len(toolConfigs) {
+		return tools
+	}
+	result := make([]tool.BaseTool, len(tools))
+	for i, t := range tools {
+		result[i] = postProcessTool(t, toolConfigs[i])
+	}
+	return result
+}
+
+// filterInternalTools applies the result filters and result diffing configured
+// for tools of the inner server to the internal tools with the same name
+func (m *MCPConfig) filterInternalTools(ctx context.Context, tools []tool.BaseTool) []tool.BaseTool {
+	configs := make(map[string]MCPToolConfig)
+	for _, toolConfig := range m.Tools {
+		if toolConfig.Server != InnerServerName && toolConfig.Server != "" {
+			continue
+		}
+		if toolConfig.ResultFilter != "" || toolConfig.DiffAgainstPrevious {
+			toolConfig.Server = InnerServerName
+			configs[toolConfig.Name] = toolConfig
+		}
+	}
+	if len(configs) == 0 {
+		return tools
+	}
+
+	result := make([]tool.BaseTool, len(tools))
+	for i, t := range tools {
+		result[i] = t
+		info, err := t.Info(ctx)
+		if err != nil || info == nil {
+			continue
+		}
+		if toolConfig, ok := configs[info.Name]; ok {
+			result[i] = postProcessTool(t, toolConfig)
+		}
+	}
+	return result
+}
+
+// InheritResultFilters returns tools with the result filters and result
+// diffing configured for the same tools in m, so that a tool list given on
+// the command line keeps the settings of the configuration file. Filters set
+// in tools are kept.
+//
+// Parameters:
+//   - tools: Tools replacing the configured tool list
+//
+// Returns:
+//   - []MCPToolConfig: Copy of tools with inherited filters
+func (m *MCPConfig) InheritResultFilters(tools []MCPToolConfig) []MCPToolConfig {
+	configured := make(map[string]MCPToolConfig)
+	for _, toolConfig := range m.Tools {
+		configured[toolConfigKey(toolConfig)] = toolConfig
+	}
+	result := make([]MCPToolConfig, len(tools))
+	for i, toolConfig := range tools {
+		inherited := configured[toolConfigKey(toolConfig)]
+		if toolConfig.ResultFilter == "" {
+			toolConfig.ResultFilter = inherited.ResultFilter
+		}
+		toolConfig.DiffAgainstPrevious = toolConfig.DiffAgainstPrevious || inherited.DiffAgainstPrevious
+		result[i] = toolConfig
+	}
+	return result
+}
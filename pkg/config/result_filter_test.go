@@ -0,0 +1,107 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/resultdiff"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonMockTool 是一个返回固定JSON结果的模拟工具
+type jsonMockTool struct {
+	mockTool
+	result string
+}
+
+func (m *jsonMockTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return m.result, nil
+}
+
+func TestToolFieldErrorsReportsInvalidResultFilters(t *testing.T) {
+	tools := []MCPToolConfig{
+		{Server: "fofa", Name: "search", ResultFilter: ".results[] | {ip, port}"},
+		{Server: "fofa", Name: "host", ResultFilter: ".results[] | {"},
+	}
+
+	errs := ToolFieldErrors("tools", tools)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "tools[1].result_filter", errs[0].Field)
+	assert.Contains(t, errs[0].Message, "host")
+
+	tools[1].ResultFilter = ""
+	assert.Empty(t, ToolFieldErrors("tools", tools))
+}
+
+func TestFilterToolsAppliesConfiguredFilters(t *testing.T) {
+	m := &MCPConfig{}
+	toolConfigs := []MCPToolConfig{
+		{Server: "fofa", Name: "search", ResultFilter: ".results[].ip"},
+		{Server: "fofa", Name: "host"},
+	}
+	search := &jsonMockTool{mockTool: mockTool{name: "search"}, result: `{"results":[{"ip":"1.1.1.1"},{"ip":"8.8.8.8"}]}`}
+	host := &jsonMockTool{mockTool: mockTool{name: "host"}, result: `{"ip":"1.1.1.1"}`}
+
+	tools := m.filterTools([]tool.BaseTool{search, host}, toolConfigs)
+	require.Len(t, tools, 2)
+
+	result, err := tools[0].(tool.InvokableTool).InvokableRun(context.Background(), "{}")
+	require.NoError(t, err)
+	assert.Equal(t, "\"1.1.1.1\"\n\"8.8.8.8\"", result)
+	assert.Same(t, host, tools[1])
+}
+
+func TestFilterToolsDiffsFilteredResults(t *testing.T) {
+	m := &MCPConfig{}
+	ctx := resultdiff.WithStore(context.Background(), resultdiff.NewFileStore(t.TempDir()))
+	search := &jsonMockTool{mockTool: mockTool{name: "search"}, result: `{"results":[{"ip":"1.1.1.1"}],"took":3}`}
+	tools := m.filterTools([]tool.BaseTool{search}, []MCPToolConfig{
+		{Server: "fofa", Name: "search", ResultFilter: ".results[].ip", DiffAgainstPrevious: true},
+	})
+
+	result, err := tools[0].(tool.InvokableTool).InvokableRun(ctx, "{}")
+	require.NoError(t, err)
+	assert.Equal(t, resultdiff.NoPreviousSummary+"\n\"1.1.1.1\"", result)
+
+	// 比较的是过滤后的结果，过滤掉的字段变化不算差异
+	search.result = `{"results":[{"ip":"1.1.1.1"}],"took":5}`
+	result, err = tools[0].(tool.InvokableTool).InvokableRun(ctx, "{}")
+	require.NoError(t, err)
+	assert.Contains(t, result, "(+0 -0)")
+}
+
+func TestFilterInternalToolsMatchesInnerTools(t *testing.T) {
+	m := &MCPConfig{Tools: []MCPToolConfig{
+		{Server: InnerServerName, Name: "sequentialthinking", ResultFilter: ".thought"},
+		{Server: "fofa", Name: "search", ResultFilter: ".results"},
+	}}
+	thinking := &jsonMockTool{mockTool: mockTool{name: "sequentialthinking"}, result: `{"thought":"done"}`}
+	other := &jsonMockTool{mockTool: mockTool{name: "search"}, result: `{"results":[]}`}
+
+	tools := m.filterInternalTools(context.Background(), []tool.BaseTool{thinking, other})
+	result, err := tools[0].(tool.InvokableTool).InvokableRun(context.Background(), "{}")
+	require.NoError(t, err)
+	assert.Equal(t, `"done"`, result)
+	// 其他服务器的同名配置不影响内置工具
+	assert.Same(t, other, tools[1])
+}
+
+func TestInheritResultFilters(t *testing.T) {
+	m := &MCPConfig{Tools: []MCPToolConfig{
+		{Server: "fofa", Name: "search", ResultFilter: ".results", DiffAgainstPrevious: true},
+		{Name: "sequentialthinking", ResultFilter: ".thought"},
+	}}
+
+	tools := m.InheritResultFilters([]MCPToolConfig{
+		{Server: "fofa", Name: "search"},
+		{Server: InnerServerName, Name: "sequentialthinking", ResultFilter: "."},
+		{Server: "ddg", Name: "search"},
+	})
+	assert.Equal(t, []MCPToolConfig{
+		{Server: "fofa", Name: "search", ResultFilter: ".results", DiffAgainstPrevious: true},
+		{Server: InnerServerName, Name: "sequentialthinking", ResultFilter: "."},
+		{Server: "ddg", Name: "search"},
+	}, tools)
+}
@@ -0,0 +1,525 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/replay"
+	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// schemaTitle is the title of the generated configuration schema
+	schemaTitle = "mcpagent configuration file"
+
+	errMsgSchemaType    = "类型应为%s"
+	errMsgSchemaEnum    = "取值 %v 无效，可选值为 %s"
+	errMsgSchemaMinimum = "不能小于 %v"
+	errMsgSchemaFormat  = "格式无效"
+	errMsgSchemaUnknown = "未知的配置项"
+	errMsgSchemaYAML    = "解析YAML失败: %w"
+)
+
+// schemaConstraint adds the constraints Go types cannot express to a field of the schema
+type schemaConstraint struct {
+	Enum        []any    // 可选值
+	Min         *float64 // 最小值
+	Description string   // 字段说明
+}
+
+// schemaConstraints lists the constraints of configuration fields by path.
+// "*" stands for any key of a map, "[]" for the items of a list.
+// Keep it in sync with the checks of ValidateDetailed.
+var schemaConstraints = map[string]schemaConstraint{
+	"llm.type":                          {Enum: []any{LLMProviderOpenAI, LLMProviderOllama}, Description: "大模型类型"},
+	"llm.fallbacks":                     {Description: "备用模型，主模型不可用时按顺序改用"},
+	"llm.fallbacks[].type":              {Enum: []any{LLMProviderOpenAI, LLMProviderOllama}, Description: "大模型类型"},
+	"language":                          {Enum: []any{"", LanguageZh, LanguageEn}, Description: "面向用户的提示语言，为空时使用zh"},
+	"max_step":                          {Min: openapi3.Float64Ptr(1), Description: "最大推理步数"},
+	"task_timeout":                      {Min: openapi3.Float64Ptr(0), Description: "任务整体超时时间（秒），0表示不限制"},
+	"llm_request_timeout":               {Min: openapi3.Float64Ptr(0), Description: "单次大模型请求超时时间（秒），0表示不限制"},
+	"max_duration":                      {Min: openapi3.Float64Ptr(0), Description: "任务的时间预算（秒），与max_step先达到者为准，0表示不限制"},
+	"max_duration_wrap_up":              {Min: openapi3.Float64Ptr(0), Description: "剩余时间少于时间预算的该比例时要求大模型尽快给出答案，0表示0.15"},
+	"output_schema_repairs":             {Min: openapi3.Float64Ptr(0)},
+	"context_compression":               {Enum: []any{"", ContextCompressionSummarize}, Description: "长工具结果的处理方式"},
+	"context_compression_threshold":     {Min: openapi3.Float64Ptr(0)},
+	"output.max_length":                 {Min: openapi3.Float64Ptr(0)},
+	"attachments.max_read_size":         {Min: openapi3.Float64Ptr(0)},
+	"attachments.retention_minutes":     {Min: openapi3.Float64Ptr(0)},
+	"artifacts.max_size":                {Min: openapi3.Float64Ptr(0)},
+	"artifacts.retention_minutes":       {Min: openapi3.Float64Ptr(0)},
+	"task_dir.retention_minutes":        {Min: openapi3.Float64Ptr(0)},
+	"integrations.fofa.max_size":        {Min: openapi3.Float64Ptr(0)},
+	"audit.max_size_mb":                 {Min: openapi3.Float64Ptr(0)},
+	"audit.max_files":                   {Min: openapi3.Float64Ptr(0)},
+	"notifications.webhooks[].events[]": {Enum: notificationEventsEnum(), Description: "推送的事件类型，为空时推送result和error"},
+	"notifications.webhooks[].timeout":  {Min: openapi3.Float64Ptr(0), Description: "单次请求超时时间（秒），0表示默认10秒"},
+	"notifications.webhooks[].retries":  {Min: openapi3.Float64Ptr(0), Description: "请求失败后的重试次数"},
+	"notifications.files[].events[]":    {Enum: notificationEventsEnum(), Description: "记录的事件类型，为空时记录全部事件"},
+	"mcp.merge_strategy": {
+		Enum:        []any{"", MergeStrategyInlineOnly, MergeStrategyFileOnly, MergeStrategyMerge},
+		Description: "配置文件与mcp_servers的合并策略，为空时使用merge",
+	},
+	"mcp.missing_tool_policy": {
+		Enum:        []any{"", MissingToolPolicyFail, MissingToolPolicyWarn, MissingToolPolicyIgnore},
+		Description: "请求的工具不存在时的处理方式，为空时使用fail",
+	},
+	"replay.match": {
+		Enum:        []any{"", replay.MatchStrict, replay.MatchFuzzy},
+		Description: "回放时的匹配方式，为空时使用strict",
+	},
+	"security.sanitize_tool_output": {
+		Enum:        []any{"", SanitizeToolOutputOff, SanitizeToolOutputMark, SanitizeToolOutputStrip},
+		Description: "工具结果的净化方式，为空时使用off",
+	},
+	"mcp.tools_auto_select_max":       {Min: openapi3.Float64Ptr(0), Description: "自动选择的最大工具数，0表示5"},
+	"mcp.tools_auto_select_min_score": {Min: openapi3.Float64Ptr(0), Description: "自动选择的工具的最低相关性得分，0表示0.5"},
+	"mcp.connect_timeout":             {Min: openapi3.Float64Ptr(0), Description: "连接单个MCP服务器的超时时间（秒），服务器设置了timeout时使用其timeout，0表示60秒"},
+	"mcp.tool_timeout":                {Min: openapi3.Float64Ptr(0), Description: "单次MCP工具调用的超时时间（秒），未设置时使用服务器的timeout（默认30秒），0表示不限制"},
+	"mcp.mcp_servers":                 {Description: "MCP服务器配置，键为服务器名称"},
+	"mcp.mcp_servers.*.transport_type": {
+		Enum:        []any{"", mcphost.TransportTypeStdio, mcphost.TransportTypeSSE, mcphost.TransportTypeHTTP},
+		Description: "传输方式，为空时使用stdio",
+	},
+	"mcp.mcp_servers.*.timeout": {Description: "操作超时时间，如30s，整数表示纳秒"},
+	"mcp.mcp_servers.*.headers": {Description: "SSE和HTTP服务器每个请求附带的HTTP头部，如Authorization"},
+	"mcp.mcp_servers.*.workdir": {Description: "stdio服务器进程的工作目录，可以使用{task_dir}，为空时使用mcpagent的当前目录"},
+}
+
+// durationType is decoded from strings such as "30s" by viper
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// configSchema is generated once, the configuration types do not change at runtime
+var configSchema = sync.OnceValue(func() *openapi3.Schema {
+	schema := typeSchema(reflect.TypeOf(Config{}), "", map[reflect.Type]int{})
+	schema.Title = schemaTitle
+	return schema
+})
+
+// ConfigSchema returns the JSON Schema of configuration files. It is generated
+// from the mapstructure tags of Config, so it lists every key LoadConfig reads,
+// and uses the JSON Schema subset supported by OpenAPI 3. Unknown keys are not allowed.
+// The returned schema is shared and must not be modified.
+//
+// Returns:
+//   - *openapi3.Schema: Schema of configuration files
+func ConfigSchema() *openapi3.Schema {
+	return configSchema()
+}
+
+// maxSchemaNesting is the number of times a struct may contain itself in the
+// schema, such as the fallbacks of LLMConfig, which cannot have fallbacks in turn
+const maxSchemaNesting = 2
+
+// typeSchema generates the schema of a configuration type at path.
+// expanding counts the structs being generated on the way to path.
+func typeSchema(t reflect.Type, path string, expanding map[reflect.Type]int) *openapi3.Schema {
+	var schema *openapi3.Schema
+	switch {
+	case t == durationType:
+		schema = openapi3.NewAnyOfSchema(openapi3.NewStringSchema(), openapi3.NewIntegerSchema())
+	case t.Kind() == reflect.Pointer:
+		return typeSchema(t.Elem(), path, expanding)
+	case t.Kind() == reflect.Struct:
+		schema = openapi3.NewObjectSchema()
+		schema.AdditionalProperties = openapi3.AdditionalProperties{Has: openapi3.BoolPtr(false)}
+		expanding[t]++
+		for _, field := range schemaFields(t) {
+			if expanding[structType(field.typ)] >= maxSchemaNesting {
+				continue
+			}
+			schema.WithProperty(field.key, typeSchema(field.typ, joinSchemaPath(path, field.key), expanding))
+		}
+		expanding[t]--
+		if t == mcpToolConfigType {
+			// 工具也可以写成 "server:tool" 字符串
+			schema = openapi3.NewAnyOfSchema(openapi3.NewStringSchema(), schema)
+		}
+	case t.Kind() == reflect.Map:
+		schema = openapi3.NewObjectSchema().WithAdditionalProperties(typeSchema(t.Elem(), path+".*", expanding))
+	case t.Kind() == reflect.Slice:
+		schema = openapi3.NewArraySchema().WithItems(typeSchema(t.Elem(), path+"[]", expanding))
+	case t.Kind() == reflect.String:
+		schema = openapi3.NewStringSchema()
+	case t.Kind() == reflect.Bool:
+		schema = openapi3.NewBoolSchema()
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema = openapi3.NewIntegerSchema()
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = openapi3.NewFloat64Schema()
+	default:
+		// 任意值，如占位符
+		schema = openapi3.NewSchema()
+	}
+
+	if constraint, ok := schemaConstraints[path]; ok {
+		schema.Enum = constraint.Enum
+		schema.Min = constraint.Min
+		schema.Description = constraint.Description
+	}
+	return schema
+}
+
+// structType returns the struct type of the values or items of t, nil if they are no structs
+func structType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == durationType {
+		return nil
+	}
+	return t
+}
+
+// schemaField is a configuration key of a struct
+type schemaField struct {
+	key string
+	typ reflect.Type
+}
+
+// schemaFields returns the keys of the exported fields of t read from configuration
+// files, fields tagged mapstructure:"-" are set at runtime and skipped
+func schemaFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		fields = append(fields, schemaField{key: key, typ: field.Type})
+	}
+	return fields
+}
+
+// notificationEventsEnum returns NotificationEvents as the values of an enum
+func notificationEventsEnum() []any {
+	values := make([]any, len(NotificationEvents))
+	for i, event := range NotificationEvents {
+		values[i] = event
+	}
+	return values
+}
+
+// joinSchemaPath appends key to a dotted field path
+func joinSchemaPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// SchemaFieldErrors validates the YAML of a configuration file against ConfigSchema.
+// Keys are compared case-insensitively like LoadConfig does, and every error
+// carries the line of the offending key or value.
+//
+// Parameters:
+//   - data: Content of the configuration file
+//
+// Returns:
+//   - FieldErrors: Fields violating the schema, empty if the file matches it
+//   - error: Error if data is not valid YAML
+func SchemaFieldErrors(data []byte) (FieldErrors, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf(errMsgSchemaYAML, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+
+	value := yamlValue(root)
+	err := ConfigSchema().VisitJSON(value, openapi3.MultiErrors())
+	if err == nil {
+		return nil, nil
+	}
+
+	var errs FieldErrors
+	seen := make(map[string]bool)
+	for _, schemaErr := range unwrapSchemaErrors(err) {
+		for _, fieldErr := range schemaFieldErrors(value, nil, schemaErr) {
+			if seen[fieldErr.Field] {
+				continue
+			}
+			seen[fieldErr.Field] = true
+			fieldErr.Line = yamlLine(root, fieldErr.Field)
+			errs = append(errs, fieldErr)
+		}
+	}
+	return errs, nil
+}
+
+// unwrapSchemaErrors flattens the errors returned by VisitJSON
+func unwrapSchemaErrors(err error) []*openapi3.SchemaError {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		var schemaErrs []*openapi3.SchemaError
+		for _, e := range multi {
+			schemaErrs = append(schemaErrs, unwrapSchemaErrors(e)...)
+		}
+		return schemaErrs
+	}
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return []*openapi3.SchemaError{schemaErr}
+	}
+	return nil
+}
+
+// schemaFieldErrors converts a schema violation into field errors with Chinese
+// messages. pointer locates the value validated by the schema of schemaErr in root.
+func schemaFieldErrors(root any, pointer []string, schemaErr *openapi3.SchemaError) FieldErrors {
+	pointer = append(append([]string(nil), pointer...), schemaErr.JSONPointer()...)
+	field := schemaFieldPath(root, pointer)
+	schema := schemaErr.Schema
+
+	newErr := func(field, message string) FieldError {
+		return FieldError{Field: field, Message: message, err: errors.New(field + ": " + message)}
+	}
+
+	switch schemaErr.SchemaField {
+	case "properties", "additionalProperties":
+		// 对象中不允许的键，逐个报告
+		object, _ := schemaErr.Value.(map[string]any)
+		var unknown []string
+		for key := range object {
+			if _, ok := schema.Properties[key]; !ok {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		var errs FieldErrors
+		for _, key := range unknown {
+			errs = append(errs, newErr(joinSchemaPath(field, key), errMsgSchemaUnknown))
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+	case "type":
+		return FieldErrors{newErr(field, fmt.Sprintf(errMsgSchemaType, schema.Type))}
+	case "enum":
+		options := make([]string, 0, len(schema.Enum))
+		for _, option := range schema.Enum {
+			if option != "" {
+				options = append(options, fmt.Sprint(option))
+			}
+		}
+		return FieldErrors{newErr(field, fmt.Sprintf(errMsgSchemaEnum, schemaErr.Value, strings.Join(options, "、")))}
+	case "anyOf":
+		// 对象不符合对象形式时报告其中的字段，如写成映射的工具
+		if object, ok := schemaErr.Value.(map[string]any); ok {
+			if errs := objectAlternativeFieldErrors(root, pointer, object, schema); len(errs) > 0 {
+				return errs
+			}
+		}
+		message := errMsgSchemaFormat
+		if schema.Description != "" {
+			message += "，" + schema.Description
+		}
+		return FieldErrors{newErr(field, message)}
+	case "minimum":
+		return FieldErrors{newErr(field, fmt.Sprintf(errMsgSchemaMinimum, *schema.Min))}
+	}
+	return FieldErrors{newErr(field, schemaErr.Reason)}
+}
+
+// objectAlternativeFieldErrors validates object against the object alternative
+// of an anyOf schema and returns its violations, pointer locates object in root
+func objectAlternativeFieldErrors(root any, pointer []string, object map[string]any, schema *openapi3.Schema) FieldErrors {
+	for _, alternative := range schema.AnyOf {
+		if alternative.Value == nil || alternative.Value.Type != openapi3.TypeObject {
+			continue
+		}
+		var errs FieldErrors
+		for _, schemaErr := range unwrapSchemaErrors(alternative.Value.VisitJSON(object, openapi3.MultiErrors())) {
+			errs = append(errs, schemaFieldErrors(root, pointer, schemaErr)...)
+		}
+		return errs
+	}
+	return nil
+}
+
+// schemaFieldPath converts a JSON pointer into a field path of FieldError such as "mcp.tools[1].name"
+func schemaFieldPath(root any, pointer []string) string {
+	var path strings.Builder
+	value := root
+	for _, token := range pointer {
+		switch current := value.(type) {
+		case []any:
+			path.WriteString("[" + token + "]")
+			if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(current) {
+				value = current[i]
+			} else {
+				value = nil
+			}
+		default:
+			if path.Len() > 0 {
+				path.WriteString(".")
+			}
+			path.WriteString(token)
+			object, _ := current.(map[string]any)
+			value = object[token]
+		}
+	}
+	return path.String()
+}
+
+// yamlValue converts a YAML node into the value VisitJSON expects.
+// Keys are lowercased because viper reads configuration keys case-insensitively.
+func yamlValue(node *yaml.Node) any {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.MappingNode:
+		object := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				// 合并键，展开被引用的映射
+				if merged, ok := yamlValue(value).(map[string]any); ok {
+					for k, v := range merged {
+						object[k] = v
+					}
+				}
+				continue
+			}
+			object[strings.ToLower(key.Value)] = yamlValue(value)
+		}
+		return object
+	case yaml.SequenceNode:
+		items := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			items = append(items, yamlValue(item))
+		}
+		return items
+	case yaml.ScalarNode:
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return node.Value
+		}
+		switch value.(type) {
+		case nil, bool, int, int64, uint64, float64, string:
+			return value
+		}
+		// 时间戳等其他类型按原文处理
+		return node.Value
+	}
+	return nil
+}
+
+// yamlLine returns the line of the key or item at a field path such as "mcp.tools[1]",
+// or of its deepest existing parent, 0 if nothing of the path is found
+func yamlLine(root *yaml.Node, field string) int {
+	line := 0
+	node := root
+	for _, segment := range splitFieldPath(field) {
+		for node != nil && node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		if node == nil {
+			break
+		}
+
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if strings.EqualFold(node.Content[i].Value, segment) {
+					line = node.Content[i].Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(segment); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return line
+}
+
+// splitFieldPath splits "mcp.tools[1].name" into "mcp", "tools", "1" and "name"
+func splitFieldPath(field string) []string {
+	field = strings.NewReplacer("[", ".", "]", "").Replace(field)
+	return strings.Split(field, ".")
+}
+
+// CheckConfigFile reports every problem of a configuration file instead of the
+// first one like LoadConfig. The file is validated against ConfigSchema and,
+// if it can be decoded, with ValidateDetailed. A field reported by both is
+// listed once with the message of ValidateDetailed. Problems carry the line of
+// the field where it can be found.
+//
+// Parameters:
+//   - configFile: Path to the configuration file
+//
+// Returns:
+//   - *Config: Decoded configuration, nil if it cannot be decoded
+//   - FieldErrors: Problems of the file, empty if it is valid
+//   - error: Error if the file cannot be read or is not valid YAML
+func CheckConfigFile(configFile string) (*Config, FieldErrors, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	schemaErrs, err := SchemaFieldErrors(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := decodeConfig(configFile)
+	if err != nil {
+		if len(schemaErrs) > 0 {
+			// 类型错误导致无法解析，结构校验的问题已由Schema报告
+			return nil, schemaErrs, nil
+		}
+		return nil, nil, err
+	}
+
+	var root *yaml.Node
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) == nil && len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+
+	var errs FieldErrors
+	reported := make(map[string]bool)
+	for _, fieldErr := range cfg.ValidateDetailed() {
+		if root != nil {
+			fieldErr.Line = yamlLine(root, fieldErr.Field)
+		}
+		reported[fieldErr.Field] = true
+		errs = append(errs, fieldErr)
+	}
+	for _, fieldErr := range schemaErrs {
+		if !reported[fieldErr.Field] {
+			errs = append(errs, fieldErr)
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Line != 0 && (errs[j].Line == 0 || errs[i].Line < errs[j].Line)
+	})
+	return cfg, errs, nil
+}
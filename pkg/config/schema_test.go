@@ -0,0 +1,196 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaAt returns the schema of a field path using the syntax of schemaConstraints
+func schemaAt(schema *openapi3.Schema, path string) *openapi3.Schema {
+	for _, segment := range strings.Split(strings.ReplaceAll(path, "[]", ".[]"), ".") {
+		switch {
+		case schema == nil:
+			return nil
+		case segment == "[]":
+			if schema.Items == nil {
+				return nil
+			}
+			schema = schema.Items.Value
+		case segment == "*":
+			if schema.AdditionalProperties.Schema == nil {
+				return nil
+			}
+			schema = schema.AdditionalProperties.Schema.Value
+		default:
+			ref, ok := objectSchema(schema).Properties[segment]
+			if !ok {
+				return nil
+			}
+			schema = ref.Value
+		}
+	}
+	return schema
+}
+
+// objectSchema returns the object alternative of an anyOf schema such as the tools, otherwise schema itself
+func objectSchema(schema *openapi3.Schema) *openapi3.Schema {
+	for _, alternative := range schema.AnyOf {
+		if alternative.Value.Type == openapi3.TypeObject {
+			return alternative.Value
+		}
+	}
+	return schema
+}
+
+// assertSchemaCovers checks that every exported configuration field of t appears in schema
+func assertSchemaCovers(t *testing.T, typ reflect.Type, schema *openapi3.Schema, path string) {
+	switch typ.Kind() {
+	case reflect.Pointer:
+		assertSchemaCovers(t, typ.Elem(), schema, path)
+	case reflect.Map:
+		require.NotNil(t, schema.AdditionalProperties.Schema, path)
+		assertSchemaCovers(t, typ.Elem(), schema.AdditionalProperties.Schema.Value, path+".*")
+	case reflect.Slice:
+		require.NotNil(t, schema.Items, path)
+		assertSchemaCovers(t, typ.Elem(), schema.Items.Value, path+"[]")
+	case reflect.Struct:
+		if typ == durationType {
+			return
+		}
+		schema = objectSchema(schema)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			key := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+			if !field.IsExported() || key == "-" {
+				continue
+			}
+			if key == "fallbacks" && strings.HasSuffix(path, ".fallbacks[]") {
+				// 备用模型不能再配置备用模型
+				continue
+			}
+			ref, ok := schema.Properties[key]
+			if assert.True(t, ok, "配置项 %s.%s 不在Schema中", path, key) {
+				assertSchemaCovers(t, field.Type, ref.Value, joinSchemaPath(path, key))
+			}
+		}
+	}
+}
+
+func TestConfigSchemaCoversConfig(t *testing.T) {
+	schema := ConfigSchema()
+	assertSchemaCovers(t, reflect.TypeOf(Config{}), schema, "")
+
+	// 运行时设置的字段不出现在Schema中
+	assert.NotContains(t, schema.Properties["artifacts"].Value.Properties, "store")
+	assert.NotContains(t, schema.Properties["task_dir"].Value.Properties, "dir")
+
+	// 所有约束都对应Schema中的字段
+	for path := range schemaConstraints {
+		assert.NotNil(t, schemaAt(schema, path), "约束 %s 没有对应的配置项", path)
+	}
+
+	assert.Equal(t, []any{LLMProviderOpenAI, LLMProviderOllama}, schemaAt(schema, "llm.type").Enum)
+	assert.Equal(t, []any{LLMProviderOpenAI, LLMProviderOllama}, schemaAt(schema, "llm.fallbacks[].type").Enum)
+	assert.Nil(t, schemaAt(schema, "llm.fallbacks[].fallbacks"), "备用模型只展开一层")
+	server := schemaAt(schema, "mcp.mcp_servers.*")
+	require.NotNil(t, server)
+	assert.Contains(t, server.Properties, "command")
+	assert.Contains(t, server.Properties, "url")
+	assert.Contains(t, server.Properties, "transport_type")
+	assert.NoError(t, schema.Validate(t.Context()))
+}
+
+func TestSchemaFieldErrors(t *testing.T) {
+	data := []byte(`llm:
+  type: gpt
+  Model: qwen3
+  unknown_key: 1
+max_step: -1
+mcp:
+  mcp_servers:
+    fetch:
+      command: uvx
+      transport_type: websocket
+      timeout: 30s
+  tools:
+    - server: fetch
+      name: [fetch]
+placeholders:
+  anything: [1, 2]
+`)
+
+	errs, err := SchemaFieldErrors(data)
+	require.NoError(t, err)
+
+	found := make(map[string]FieldError)
+	for _, fieldErr := range errs {
+		found[fieldErr.Field] = fieldErr
+	}
+	assert.Len(t, found, 5, errs.Error())
+	assert.Equal(t, 2, found["llm.type"].Line)
+	assert.Contains(t, found["llm.type"].Message, "openai、ollama")
+	assert.Equal(t, 4, found["llm.unknown_key"].Line)
+	assert.Equal(t, errMsgSchemaUnknown, found["llm.unknown_key"].Message)
+	assert.Equal(t, 5, found["max_step"].Line)
+	assert.Equal(t, 10, found["mcp.mcp_servers.fetch.transport_type"].Line)
+	assert.Equal(t, 14, found["mcp.tools[0].name"].Line)
+	assert.Equal(t, "类型应为string", found["mcp.tools[0].name"].Message)
+
+	_, err = SchemaFieldErrors([]byte("llm: [\n"))
+	assert.Error(t, err)
+
+	errs, err = SchemaFieldErrors(nil)
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestDefaultConfigFileMatchesSchema(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "default_config.yaml"))
+	require.NoError(t, err)
+	errs, err := SchemaFieldErrors(data)
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`mcp:
+  config_file: mcpservers.json
+llm:
+  type: gpt
+  base_url: http://127.0.0.1:11434
+  model: qwen3
+max_step: 10
+typo: true
+`), 0644))
+
+	cfg, errs, err := CheckConfigFile(configFile)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	// llm.type同时违反Schema和配置校验，只报告一次
+	require.Len(t, errs, 2, errs.Error())
+	assert.Equal(t, "llm.type", errs[0].Field)
+	assert.Equal(t, "不支持的LLM类型: gpt", errs[0].Message)
+	assert.Equal(t, 4, errs[0].Line)
+	assert.Equal(t, "typo", errs[1].Field)
+	assert.Equal(t, 8, errs[1].Line)
+
+	// 类型错误导致无法解析时仍报告Schema的问题
+	require.NoError(t, os.WriteFile(configFile, []byte("max_step: many\n"), 0644))
+	cfg, errs, err = CheckConfigFile(configFile)
+	require.NoError(t, err)
+	assert.Nil(t, cfg)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "max_step", errs[0].Field)
+
+	_, _, err = CheckConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Tool output sanitizer modes of SecurityConfig.SanitizeToolOutput
+const (
+	// SanitizeToolOutputOff passes tool results to the model unchanged, the default
+	SanitizeToolOutputOff = "off"
+	// SanitizeToolOutputMark wraps tool results in delimiters and reminds the
+	// model that the delimited content is untrusted data
+	SanitizeToolOutputMark = "mark"
+	// SanitizeToolOutputStrip marks tool results and removes the text matching
+	// the injection patterns
+	SanitizeToolOutputStrip = "strip"
+
+	errMsgSanitizeModeInvalid     = "不支持的工具结果净化方式: %s，可选值为 off、mark 和 strip"
+	errMsgInjectionPatternEmpty   = "提示词注入规则的正则表达式不能为空"
+	errMsgInjectionPatternInvalid = "提示词注入规则的正则表达式无效: %s: %w"
+)
+
+// DefaultInjectionPatterns are removed from tool results in strip mode when
+// SecurityConfig.InjectionPatterns is empty. They match the phrases seen in
+// web pages that try to take over the agent, not every possible injection.
+var DefaultInjectionPatterns = []string{
+	`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+|my\s+)?(previous|prior|above|earlier|preceding|system)\s+(instructions?|prompts?|messages|rules|directions)`,
+	`(?i)\b(ignore|disregard|forget)\s+(all\s+|everything\s+)?(you\s+were\s+told|your\s+instructions)`,
+	`(?i)\byou\s+are\s+now\s+(a|an|in|the)\s+[^.\n]{0,80}`,
+	`(?i)\b(new|updated)\s+(system\s+)?instructions?\s*:`,
+	`(?i)</?\s*(system|assistant|im_start|im_end)\s*>`,
+	`(?i)\[/?(system|inst)\]`,
+	`(忽略|无视|忘记)(掉)?(之前|以上|上面|前面|上述|先前|所有)(的)?(所有)?(指令|指示|提示词?|要求|规则|设定)`,
+}
+
+// SecurityConfig configures the defenses against untrusted tool output
+type SecurityConfig struct {
+	SanitizeToolOutput string   `mapstructure:"sanitize_tool_output" json:"sanitize_tool_output,omitempty" yaml:"sanitize_tool_output,omitempty"` // 工具结果的净化方式：off（默认）、mark 或 strip
+	InjectionPatterns  []string `mapstructure:"injection_patterns" json:"injection_patterns,omitempty" yaml:"injection_patterns,omitempty"`       // strip 时从工具结果中移除的正则表达式，为空时使用内置规则
+}
+
+// MarksToolOutput reports whether tool results are delimited as untrusted data
+func (s *SecurityConfig) MarksToolOutput() bool {
+	return s.SanitizeToolOutput == SanitizeToolOutputMark || s.StripsToolOutput()
+}
+
+// StripsToolOutput reports whether injection patterns are removed from tool results
+func (s *SecurityConfig) StripsToolOutput() bool {
+	return s.SanitizeToolOutput == SanitizeToolOutputStrip
+}
+
+// CompileInjectionPatterns compiles the patterns removed in strip mode
+//
+// Returns:
+//   - []*regexp.Regexp: InjectionPatterns, or DefaultInjectionPatterns if there are none
+//   - error: Error of the first pattern that does not compile
+func (s *SecurityConfig) CompileInjectionPatterns() ([]*regexp.Regexp, error) {
+	patterns := s.InjectionPatterns
+	if len(patterns) == 0 {
+		patterns = DefaultInjectionPatterns
+	}
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return nil, errors.New(errMsgInjectionPatternEmpty)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf(errMsgInjectionPatternInvalid, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// validateSecurity adds invalid security settings to errs
+func (c *Config) validateSecurity(errs *FieldErrors) {
+	switch c.Security.SanitizeToolOutput {
+	case "", SanitizeToolOutputOff, SanitizeToolOutputMark, SanitizeToolOutputStrip:
+	default:
+		errs.add("security.sanitize_tool_output", fmt.Errorf(errMsgSanitizeModeInvalid, c.Security.SanitizeToolOutput))
+	}
+	for i, pattern := range c.Security.InjectionPatterns {
+		field := fmt.Sprintf("security.injection_patterns[%d]", i)
+		if strings.TrimSpace(pattern) == "" {
+			errs.add(field, errors.New(errMsgInjectionPatternEmpty))
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs.add(field, fmt.Errorf(errMsgInjectionPatternInvalid, pattern, err))
+		}
+	}
+}
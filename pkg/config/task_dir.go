@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/attachment"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+const (
+	// TaskDirPlaceholder is replaced with the working directory of the current
+	// run in the args, env values and workdir of stdio MCP servers
+	TaskDirPlaceholder = "{task_dir}"
+
+	errMsgTaskDirRetention = "任务目录保留时间不能为负数"
+	errMsgCreateTaskDir    = "创建任务目录失败: %w"
+)
+
+// TaskDirConfig configures the temporary working directory created for each
+// agent run when stdio MCP servers reference TaskDirPlaceholder, so tools of
+// concurrent tasks do not share files. Dir is set at runtime and is never read
+// from or written to configuration files or the web API.
+type TaskDirConfig struct {
+	Dir              string `mapstructure:"-" json:"-" yaml:"-"`                                                 // 当前运行的任务目录，运行时设置
+	BaseDir          string `mapstructure:"base_dir" json:"base_dir" yaml:"base_dir"`                            // 创建任务目录的父目录，为空时使用系统临时目录
+	RetentionMinutes int    `mapstructure:"retention_minutes" json:"retention_minutes" yaml:"retention_minutes"` // 运行结束后任务目录的保留时间（分钟），0表示立即清理
+}
+
+// Retention returns how long task directories are kept after a run completes
+func (t *TaskDirConfig) Retention() time.Duration {
+	return time.Duration(t.RetentionMinutes) * time.Minute
+}
+
+// Validate validates the task directory configuration.
+//
+// Returns:
+//   - error: validation error if configuration is invalid, nil otherwise
+func (t *TaskDirConfig) Validate() error {
+	if t.RetentionMinutes < 0 {
+		return errors.New(errMsgTaskDirRetention)
+	}
+	return nil
+}
+
+// UsesTaskDir reports whether any stdio server references TaskDirPlaceholder
+// in its args, env values or workdir. Servers that cannot be resolved count as unused.
+func (m *MCPConfig) UsesTaskDir() bool {
+	servers, _, err := m.ResolveServers()
+	if err != nil {
+		return false
+	}
+	for _, server := range servers {
+		if serverUsesTaskDir(server) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrepareTaskDir creates a fresh task directory when the MCP servers reference
+// TaskDirPlaceholder and no directory has been set yet.
+//
+// Returns:
+//   - *Config: Copy of the configuration with TaskDir.Dir set, or the configuration itself when no directory is needed
+//   - func(): Releases the directory according to the retention, safe to call when no directory was created
+//   - error: Error if the directory cannot be created
+func (c *Config) PrepareTaskDir() (*Config, func(), error) {
+	if c.TaskDir.Dir != "" || !c.MCP.UsesTaskDir() {
+		return c, func() {}, nil
+	}
+
+	base := c.TaskDir.BaseDir
+	if base == "" {
+		base = filepath.Join(os.TempDir(), "mcpagent", "tasks")
+	}
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, nil, fmt.Errorf(errMsgCreateTaskDir, err)
+	}
+	dir, err := os.MkdirTemp(base, "task_")
+	if err != nil {
+		return nil, nil, fmt.Errorf(errMsgCreateTaskDir, err)
+	}
+
+	copied := *c
+	copied.TaskDir.Dir = dir
+	retention := c.TaskDir.Retention()
+	return &copied, func() { attachment.ScheduleCleanup(dir, retention) }, nil
+}
+
+// substituteTaskDir returns copies of the servers with TaskDirPlaceholder in
+// the args, env values and workdir of stdio servers replaced by dir
+func substituteTaskDir(servers map[string]*mcphost.ServerConfig, dir string) map[string]*mcphost.ServerConfig {
+	result := make(map[string]*mcphost.ServerConfig, len(servers))
+	for name, server := range servers {
+		if !serverUsesTaskDir(server) {
+			result[name] = server
+			continue
+		}
+
+		copied := *server
+		copied.Args = make([]string, len(server.Args))
+		for i, arg := range server.Args {
+			copied.Args[i] = strings.ReplaceAll(arg, TaskDirPlaceholder, dir)
+		}
+		if server.Env != nil {
+			copied.Env = make(map[string]string, len(server.Env))
+			for k, v := range server.Env {
+				copied.Env[k] = strings.ReplaceAll(v, TaskDirPlaceholder, dir)
+			}
+		}
+		copied.Workdir = strings.ReplaceAll(server.Workdir, TaskDirPlaceholder, dir)
+		result[name] = &copied
+	}
+	return result
+}
+
+// serverUsesTaskDir reports whether a stdio server references TaskDirPlaceholder
+func serverUsesTaskDir(server *mcphost.ServerConfig) bool {
+	if server == nil || server.IsSSETransport() || server.IsHTTPTransport() {
+		return false
+	}
+	if strings.Contains(server.Workdir, TaskDirPlaceholder) {
+		return true
+	}
+	for _, arg := range server.Args {
+		if strings.Contains(arg, TaskDirPlaceholder) {
+			return true
+		}
+	}
+	for _, v := range server.Env {
+		if strings.Contains(v, TaskDirPlaceholder) {
+			return true
+		}
+	}
+	return false
+}
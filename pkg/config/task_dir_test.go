@@ -0,0 +1,146 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/internal/testmcp"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTaskDirConfig creates a config with a filesystem server restricted to {task_dir}
+func newTaskDirConfig(t *testing.T) *Config {
+	return &Config{
+		MCP: MCPConfig{
+			MCPServers: map[string]*mcphost.ServerConfig{
+				"filesystem": {
+					TransportType: mcphost.TransportTypeStdio,
+					Command:       "npx",
+					Args:          []string{"-y", "@modelcontextprotocol/server-filesystem", "{task_dir}"},
+					Env:           map[string]string{"OUTPUT_DIR": "{task_dir}/out", "MODE": "rw"},
+				},
+				"remote": {TransportType: mcphost.TransportTypeSSE, URL: "http://127.0.0.1:1/sse"},
+			},
+		},
+		TaskDir: TaskDirConfig{BaseDir: t.TempDir()},
+	}
+}
+
+func TestSubstituteTaskDir(t *testing.T) {
+	cfg := newTaskDirConfig(t)
+	assert.True(t, cfg.MCP.UsesTaskDir())
+
+	servers := substituteTaskDir(cfg.MCP.MCPServers, "/tmp/task_1")
+	assert.Equal(t, []string{"-y", "@modelcontextprotocol/server-filesystem", "/tmp/task_1"}, servers["filesystem"].Args)
+	assert.Equal(t, map[string]string{"OUTPUT_DIR": "/tmp/task_1/out", "MODE": "rw"}, servers["filesystem"].Env)
+	assert.Same(t, cfg.MCP.MCPServers["remote"], servers["remote"])
+
+	// 原始配置不被修改
+	assert.Equal(t, "{task_dir}", cfg.MCP.MCPServers["filesystem"].Args[2])
+
+	// 只在工作目录中引用{task_dir}
+	workdir := map[string]*mcphost.ServerConfig{"scanner": {Command: "scanner", Workdir: "{task_dir}/work"}}
+	assert.True(t, (&MCPConfig{MCPServers: workdir}).UsesTaskDir())
+	assert.Equal(t, "/tmp/task_1/work", substituteTaskDir(workdir, "/tmp/task_1")["scanner"].Workdir)
+	assert.Equal(t, "{task_dir}/work", workdir["scanner"].Workdir)
+
+	// 没有引用{task_dir}时不创建任务目录
+	plain := &Config{MCP: MCPConfig{MCPServers: map[string]*mcphost.ServerConfig{"fetch": {Command: "uvx", Args: []string{"mcp-server-fetch"}}}}}
+	assert.False(t, plain.MCP.UsesTaskDir())
+	prepared, release, err := plain.PrepareTaskDir()
+	require.NoError(t, err)
+	assert.Same(t, plain, prepared)
+	release()
+}
+
+func TestTaskDirIsolationAndCleanup(t *testing.T) {
+	originalFactory := mcpHubFromSettingsFactory
+	defer func() {
+		mcpHubFromSettingsFactory = originalFactory
+	}()
+
+	var mu sync.Mutex
+	var capturedArgs []string
+	mcpHubFromSettingsFactory = func(ctx context.Context, settings *mcphost.MCPSettings) (MCPHubInterface, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		capturedArgs = append(capturedArgs, settings.MCPServers["filesystem"].Args[2])
+		return nil, assert.AnError
+	}
+
+	cfg := newTaskDirConfig(t)
+
+	// 两个并发运行各自获得独立的任务目录
+	var wg sync.WaitGroup
+	dirs := make([]string, 2)
+	releases := make([]func(), 2)
+	for i := range dirs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			prepared, release, err := cfg.PrepareTaskDir()
+			if !assert.NoError(t, err) {
+				return
+			}
+			dirs[i], releases[i] = prepared.TaskDir.Dir, release
+
+			_, cleanup, err := prepared.GetTools(context.Background())
+			if assert.NoError(t, err) {
+				cleanup()
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.NotContains(t, dirs, "")
+
+	assert.NotEqual(t, dirs[0], dirs[1])
+	assert.ElementsMatch(t, dirs, capturedArgs)
+	assert.Empty(t, cfg.TaskDir.Dir, "原始配置不被修改")
+	for _, dir := range dirs {
+		assert.DirExists(t, dir)
+	}
+
+	// 保留时间为0时立即清理
+	for i, release := range releases {
+		release()
+		_, err := os.Stat(dirs[i])
+		assert.True(t, os.IsNotExist(err), dirs[i])
+	}
+}
+
+// TestTaskDirWorkdirWithFakeServer 验证stdio服务器进程在替换后的任务目录中运行，-short时跳过
+func TestTaskDirWorkdirWithFakeServer(t *testing.T) {
+	binary := testmcp.Build(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	server := testmcp.Server(binary, testmcp.Options{})
+	server.Workdir = TaskDirPlaceholder
+	cfg := &Config{
+		MCP:     MCPConfig{MCPServers: map[string]*mcphost.ServerConfig{"fake": server}},
+		TaskDir: TaskDirConfig{BaseDir: t.TempDir()},
+	}
+	prepared, release, err := cfg.PrepareTaskDir()
+	require.NoError(t, err)
+	defer release()
+
+	hub, err := mcphost.NewMCPHubFromSettings(ctx, &mcphost.MCPSettings{
+		MCPServers: substituteTaskDir(prepared.MCP.MCPServers, prepared.TaskDir.Dir),
+	})
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	cwd, err := hub.InvokeTool(ctx, "fake_cwd", map[string]any{})
+	require.NoError(t, err)
+	want, err := filepath.EvalSymlinks(prepared.TaskDir.Dir)
+	require.NoError(t, err)
+	got, err := filepath.EvalSymlinks(cwd)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
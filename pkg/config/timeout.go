@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// defaultMaxDurationWrapUp is the share of max_duration left when the model is asked to wrap up
+const defaultMaxDurationWrapUp = 0.15
+
+const (
+	errMsgTaskTimeoutInvalid       = "任务超时时间不能为负数"
+	errMsgLLMRequestTimeoutInvalid = "大模型请求超时时间不能为负数"
+	errMsgMaxDurationInvalid       = "任务的时间预算不能为负数"
+	errMsgMaxDurationWrapUpInvalid = "max_duration_wrap_up必须在0到1之间"
+)
+
+// TaskTimeoutDuration returns the overall deadline of a task, 0 means no deadline
+func (c *Config) TaskTimeoutDuration() time.Duration {
+	return time.Duration(c.TaskTimeout) * time.Second
+}
+
+// LLMRequestTimeoutDuration returns the timeout of a single model request, 0 means no timeout
+func (c *Config) LLMRequestTimeoutDuration() time.Duration {
+	return time.Duration(c.LLMRequestTimeout) * time.Second
+}
+
+// MaxDurationDuration returns the time budget of a task, 0 means no budget
+func (c *Config) MaxDurationDuration() time.Duration {
+	return time.Duration(c.MaxDuration) * time.Second
+}
+
+// EffectiveMaxDurationWrapUp returns the share of the time budget left when
+// the model is asked to produce its answer, defaulting to 0.15
+func (c *Config) EffectiveMaxDurationWrapUp() float64 {
+	if c.MaxDurationWrapUp <= 0 {
+		return defaultMaxDurationWrapUp
+	}
+	return c.MaxDurationWrapUp
+}
+
+// validateTimeouts adds the invalid task and model request timeouts and time budgets to errs
+func (c *Config) validateTimeouts(errs *FieldErrors) {
+	if c.TaskTimeout < 0 {
+		errs.add("task_timeout", errors.New(errMsgTaskTimeoutInvalid))
+	}
+	if c.LLMRequestTimeout < 0 {
+		errs.add("llm_request_timeout", errors.New(errMsgLLMRequestTimeoutInvalid))
+	}
+	if c.MaxDuration < 0 {
+		errs.add("max_duration", errors.New(errMsgMaxDurationInvalid))
+	}
+	if c.MaxDurationWrapUp < 0 || c.MaxDurationWrapUp >= 1 {
+		errs.add("max_duration_wrap_up", errors.New(errMsgMaxDurationWrapUpInvalid))
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"crypto/tls"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+// tlsOptions returns the custom TLS settings of the LLM endpoint
+func (l *LLMConfig) tlsOptions() mcphost.TLSOptions {
+	return mcphost.TLSOptions{
+		CACertFile:         l.CACertFile,
+		ClientCertFile:     l.ClientCertFile,
+		ClientKeyFile:      l.ClientKeyFile,
+		InsecureSkipVerify: l.InsecureSkipVerify,
+	}
+}
+
+// HasTLSOptions reports whether any custom TLS option is configured
+func (l *LLMConfig) HasTLSOptions() bool {
+	return l.tlsOptions().IsSet()
+}
+
+// validateTLS ensures the client certificate and key are configured together
+func (l *LLMConfig) validateTLS() error {
+	return l.tlsOptions().Validate()
+}
+
+// TLSClientConfig builds the TLS configuration for connections to the LLM endpoint.
+// The CA certificates of ca_cert_file are trusted in addition to the system pool,
+// client_cert_file and client_key_file are presented for mutual TLS.
+// SSE MCP servers use the same options, see mcphost.TLSOptions.
+//
+// Returns:
+//   - *tls.Config: TLS configuration, nil if no TLS option is configured
+//   - error: Error if a certificate file cannot be read or parsed
+func (l *LLMConfig) TLSClientConfig() (*tls.Config, error) {
+	return l.tlsOptions().ClientConfig("LLM服务 " + l.BaseURL)
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePEM 将PEM块写入临时目录中的文件并返回路径
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// testCA 是测试用的CA，可以签发客户端证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mcpagent test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+// issueClientCert 签发客户端证书，返回证书和私钥文件路径
+func (ca *testCA) issueClientCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "mcpagent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)
+}
+
+func newTLSTestHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+}
+
+// getWithConfig 使用cfg创建的HTTP客户端请求url
+func getWithConfig(t *testing.T, cfg *Config, url string) error {
+	client, err := cfg.createHTTPClient()
+	require.NoError(t, err)
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+	return nil
+}
+
+func TestCreateHTTPClientWithCACert(t *testing.T) {
+	server := httptest.NewTLSServer(newTLSTestHandler())
+	defer server.Close()
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	// 未配置CA时无法校验服务端证书
+	err := getWithConfig(t, &Config{}, server.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate signed by unknown authority")
+
+	// 配置CA后连接成功
+	require.NoError(t, getWithConfig(t, &Config{LLM: LLMConfig{CACertFile: caFile}}, server.URL))
+
+	// 与代理设置组合时同时生效
+	cfg := &Config{Proxy: "http://proxy.example.com:8080", LLM: LLMConfig{CACertFile: caFile}}
+	client, err := cfg.createHTTPClient()
+	require.NoError(t, err)
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.NotNil(t, transport.Proxy)
+	require.NotNil(t, transport.TLSClientConfig)
+	assert.NotNil(t, transport.TLSClientConfig.RootCAs)
+}
+
+func TestCreateHTTPClientInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(newTLSTestHandler())
+	defer server.Close()
+
+	require.NoError(t, getWithConfig(t, &Config{LLM: LLMConfig{InsecureSkipVerify: true}}, server.URL))
+}
+
+func TestCreateHTTPClientWithClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(newTLSTestHandler())
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	// 服务端要求客户端证书
+	err := getWithConfig(t, &Config{LLM: LLMConfig{CACertFile: caFile}}, server.URL)
+	require.Error(t, err)
+
+	certFile, keyFile := ca.issueClientCert(t)
+	cfg := &Config{LLM: LLMConfig{CACertFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile}}
+	require.NoError(t, getWithConfig(t, cfg, server.URL))
+}
+
+func TestLLMConfigTLSClientConfigErrors(t *testing.T) {
+	// 未配置TLS选项时使用默认客户端
+	tlsConfig, err := (&LLMConfig{}).TLSClientConfig()
+	require.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	_, err = (&LLMConfig{ClientCertFile: "client.pem"}).TLSClientConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "必须同时设置")
+
+	_, err = (&LLMConfig{CACertFile: filepath.Join(t.TempDir(), "missing.pem")}).TLSClientConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "读取CA证书文件")
+
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a certificate"), 0600))
+	_, err = (&LLMConfig{CACertFile: invalid}).TLSClientConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "没有有效的PEM证书")
+
+	_, err = (&LLMConfig{ClientCertFile: invalid, ClientKeyFile: invalid}).TLSClientConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "加载客户端证书")
+
+	cfg := &Config{LLM: LLMConfig{CACertFile: invalid}}
+	_, err = cfg.createHTTPClient()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "加载TLS配置失败")
+}
+
+func TestLLMConfigValidateTLS(t *testing.T) {
+	llm := LLMConfig{Type: LLMProviderOpenAI, BaseURL: "https://llm.internal", Model: "m", ClientKeyFile: "client.key"}
+	err := llm.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "client_cert_file和client_key_file必须同时设置")
+
+	llm.ClientCertFile = "client.pem"
+	assert.NoError(t, llm.Validate())
+}
@@ -0,0 +1,52 @@
+package config
+
+import "fmt"
+
+// Defaults of tools_auto_select
+const (
+	DefaultToolsAutoSelectMax      = 5
+	DefaultToolsAutoSelectMinScore = 0.5
+)
+
+const (
+	errMsgToolsAutoSelectMaxInvalid      = "自动选择的最大工具数不能为负数: %d"
+	errMsgToolsAutoSelectMinScoreInvalid = "自动选择的最低得分不能为负数: %v"
+)
+
+// AutoSelectsTools reports whether the tools of the task are chosen by
+// tools_auto_select: it is enabled and no tools are selected
+func (m *MCPConfig) AutoSelectsTools() bool {
+	return m.ToolsAutoSelect && len(m.Tools) == 0
+}
+
+// EffectiveToolsAutoSelectMax returns the maximum number of automatically selected tools, defaulting to DefaultToolsAutoSelectMax
+func (m *MCPConfig) EffectiveToolsAutoSelectMax() int {
+	if m.ToolsAutoSelectMax <= 0 {
+		return DefaultToolsAutoSelectMax
+	}
+	return m.ToolsAutoSelectMax
+}
+
+// EffectiveToolsAutoSelectMinScore returns the minimum score of automatically selected tools, defaulting to DefaultToolsAutoSelectMinScore
+func (m *MCPConfig) EffectiveToolsAutoSelectMinScore() float64 {
+	if m.ToolsAutoSelectMinScore <= 0 {
+		return DefaultToolsAutoSelectMinScore
+	}
+	return m.ToolsAutoSelectMinScore
+}
+
+// validateToolsAutoSelectMax checks that ToolsAutoSelectMax is not negative
+func (m *MCPConfig) validateToolsAutoSelectMax() error {
+	if m.ToolsAutoSelectMax < 0 {
+		return fmt.Errorf(errMsgToolsAutoSelectMaxInvalid, m.ToolsAutoSelectMax)
+	}
+	return nil
+}
+
+// validateToolsAutoSelectMinScore checks that ToolsAutoSelectMinScore is not negative
+func (m *MCPConfig) validateToolsAutoSelectMinScore() error {
+	if m.ToolsAutoSelectMinScore < 0 {
+		return fmt.Errorf(errMsgToolsAutoSelectMinScoreInvalid, m.ToolsAutoSelectMinScore)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolsAutoSelectConfig(t *testing.T) {
+	m := MCPConfig{ToolsAutoSelect: true}
+	assert.True(t, m.AutoSelectsTools())
+	assert.Equal(t, DefaultToolsAutoSelectMax, m.EffectiveToolsAutoSelectMax())
+	assert.Equal(t, DefaultToolsAutoSelectMinScore, m.EffectiveToolsAutoSelectMinScore())
+
+	// 已经选择了工具时不自动选择
+	m.Tools = []MCPToolConfig{{Server: "fofa", Name: "search"}}
+	assert.False(t, m.AutoSelectsTools())
+
+	m = MCPConfig{ToolsAutoSelect: true, ToolsAutoSelectMax: -1, ToolsAutoSelectMinScore: -0.5}
+	errs := m.ValidateDetailed()
+	require.Len(t, errs, 2)
+	assert.Equal(t, "tools_auto_select_max", errs[0].Field)
+	assert.Equal(t, "tools_auto_select_min_score", errs[1].Field)
+}
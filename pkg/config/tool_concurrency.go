@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// DefaultStdioMaxConcurrency is the number of concurrent tool calls of a stdio
+// server without max_concurrency, many stdio servers handle one request at a time
+const DefaultStdioMaxConcurrency = 1
+
+// EffectiveMaxConcurrency returns the number of tool calls of a server that
+// may run at the same time, 0 when they are not limited. A MaxConcurrency
+// above 0 is used as is and a negative one removes the limit; without a value
+// stdio servers run one call at a time and sse/http servers are not limited.
+//
+// Parameters:
+//   - server: Server name
+//   - serverConfig: Definition of the server, nil if unknown
+//
+// Returns:
+//   - int: Maximum number of concurrent calls, 0 for no limit
+func (m *MCPConfig) EffectiveMaxConcurrency(server string, serverConfig *mcphost.ServerConfig) int {
+	if limit, ok := m.MaxConcurrency[server]; ok && limit != 0 {
+		if limit < 0 {
+			return 0
+		}
+		return limit
+	}
+	// 无法确定传输方式时按stdio处理，宁可串行也不破坏服务器
+	if serverConfig != nil && normalizeServerConfig(*serverConfig).TransportType != mcphost.TransportTypeStdio {
+		return 0
+	}
+	return DefaultStdioMaxConcurrency
+}
+
+// limitConcurrency wraps MCP tools so that the calls to one server wait for
+// a free slot of its max concurrency. The slots are shared by the tools of a
+// server within one hub, that is one connection; calls to different servers
+// run in parallel. tools must be in the order of toolConfigs.
+//
+// Parameters:
+//   - tools: MCP tools returned by the hub
+//   - toolConfigs: Configurations of tools, in the same order
+//   - servers: Server definitions, nil to load them from ConfigFile
+//
+// Returns:
+//   - []tool.BaseTool: Tools waiting for a slot before running
+func (m *MCPConfig) limitConcurrency(tools []tool.BaseTool, toolConfigs []MCPToolConfig, servers map[string]*mcphost.ServerConfig) []tool.BaseTool {
+	if len(tools) != len(toolConfigs) {
+		return tools
+	}
+	if servers == nil && strings.TrimSpace(m.ConfigFile) != "" {
+		// 配置文件无法加载时服务器按stdio处理
+		servers, _, _ = m.ResolveServers()
+	}
+
+	slots := make(map[string]chan struct{})
+	result := make([]tool.BaseTool, len(tools))
+	for i, t := range tools {
+		result[i] = t
+		server := toolConfigs[i].Server
+		invokable, ok := t.(tool.InvokableTool)
+		if !ok {
+			continue
+		}
+		sem, ok := slots[server]
+		if !ok {
+			if limit := m.EffectiveMaxConcurrency(server, servers[server]); limit > 0 {
+				sem = make(chan struct{}, limit)
+			}
+			slots[server] = sem
+		}
+		if sem != nil {
+			result[i] = &limitedTool{InvokableTool: invokable, slots: sem}
+		}
+	}
+	return result
+}
+
+// limitedTool runs the wrapped tool once a slot of its server is free
+type limitedTool struct {
+	tool.InvokableTool
+	slots chan struct{}
+}
+
+// InvokableRun waits for a slot and runs the wrapped tool. The tool call
+// timeout of the MCP client starts once the slot is acquired, the time spent
+// waiting is only limited by ctx.
+func (t *limitedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	select {
+	case t.slots <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-t.slots }()
+	return t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+}
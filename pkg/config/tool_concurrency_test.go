@@ -0,0 +1,138 @@
+package config
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowTool is a tool taking delay to run that records its peak concurrency
+type slowTool struct {
+	name    string
+	delay   time.Duration
+	running *atomic.Int32
+	peak    *atomic.Int32
+}
+
+func (t *slowTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: t.name}, nil
+}
+
+func (t *slowTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	n := t.running.Add(1)
+	defer t.running.Add(-1)
+	for {
+		peak := t.peak.Load()
+		if n <= peak || t.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(t.delay)
+	return "ok", nil
+}
+
+// runConcurrently calls every tool at the same time and waits for the calls to finish
+func runConcurrently(t *testing.T, tools []tool.BaseTool) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for _, bt := range tools {
+		wg.Add(1)
+		go func(invokable tool.InvokableTool) {
+			defer wg.Done()
+			_, err := invokable.InvokableRun(context.Background(), "{}")
+			assert.NoError(t, err)
+		}(bt.(tool.InvokableTool))
+	}
+	wg.Wait()
+}
+
+// newSlowTools creates count slow tools of server sharing their counters
+func newSlowTools(server string, count int, running, peak *atomic.Int32) ([]tool.BaseTool, []MCPToolConfig) {
+	tools := make([]tool.BaseTool, count)
+	configs := make([]MCPToolConfig, count)
+	for i := range tools {
+		tools[i] = &slowTool{name: "scan", delay: 50 * time.Millisecond, running: running, peak: peak}
+		configs[i] = MCPToolConfig{Server: server, Name: "scan"}
+	}
+	return tools, configs
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	stdio := map[string]*mcphost.ServerConfig{"scanner": {TransportType: mcphost.TransportTypeStdio, Command: "scanner"}}
+
+	tests := []struct {
+		name  string
+		limit map[string]int
+		peak  int32
+	}{
+		{"stdio默认串行", nil, 1},
+		{"并发数为2", map[string]int{"scanner": 2}, 2},
+		{"负数不限制", map[string]int{"scanner": -1}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, peak atomic.Int32
+			tools, configs := newSlowTools("scanner", 4, &running, &peak)
+			m := &MCPConfig{MaxConcurrency: tt.limit}
+			runConcurrently(t, m.limitConcurrency(tools, configs, stdio))
+			assert.Equal(t, tt.peak, peak.Load())
+		})
+	}
+}
+
+func TestLimitConcurrencyPerServer(t *testing.T) {
+	servers := map[string]*mcphost.ServerConfig{
+		"a":   {Command: "a"},
+		"b":   {Command: "b"},
+		"web": {TransportType: mcphost.TransportTypeSSE, URL: "http://127.0.0.1:1/sse"},
+	}
+
+	// 不同服务器的调用并行执行
+	var running, peak atomic.Int32
+	toolsA, configsA := newSlowTools("a", 1, &running, &peak)
+	toolsB, configsB := newSlowTools("b", 1, &running, &peak)
+	m := &MCPConfig{}
+	runConcurrently(t, m.limitConcurrency(append(toolsA, toolsB...), append(configsA, configsB...), servers))
+	assert.Equal(t, int32(2), peak.Load())
+
+	// sse服务器默认不限制
+	var webRunning, webPeak atomic.Int32
+	tools, configs := newSlowTools("web", 3, &webRunning, &webPeak)
+	runConcurrently(t, m.limitConcurrency(tools, configs, servers))
+	assert.Equal(t, int32(3), webPeak.Load())
+}
+
+func TestLimitConcurrencyCanceledWhileWaiting(t *testing.T) {
+	var running, peak atomic.Int32
+	tools, configs := newSlowTools("scanner", 2, &running, &peak)
+	tools[0].(*slowTool).delay = time.Second
+	limited := (&MCPConfig{}).limitConcurrency(tools, configs, nil)
+
+	go limited[0].(tool.InvokableTool).InvokableRun(context.Background(), "{}")
+	require.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, time.Millisecond)
+
+	// 等待空闲名额时响应ctx取消
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := limited[1].(tool.InvokableTool).InvokableRun(ctx, "{}")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestEffectiveMaxConcurrency(t *testing.T) {
+	m := &MCPConfig{MaxConcurrency: map[string]int{"fast": 4, "free": -1}}
+	assert.Equal(t, DefaultStdioMaxConcurrency, m.EffectiveMaxConcurrency("local", &mcphost.ServerConfig{Command: "python"}))
+	assert.Equal(t, DefaultStdioMaxConcurrency, m.EffectiveMaxConcurrency("unknown", nil))
+	assert.Equal(t, 0, m.EffectiveMaxConcurrency("remote", &mcphost.ServerConfig{URL: "http://127.0.0.1/sse"}))
+	assert.Equal(t, 4, m.EffectiveMaxConcurrency("fast", nil))
+	assert.Equal(t, 0, m.EffectiveMaxConcurrency("free", &mcphost.ServerConfig{Command: "python"}))
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// LocalizedDescription returns the description of a tool in the language of
+// ToolDescriptionLanguage, empty if no language is set or the tool has no
+// description in that language so that the original description is used.
+//
+// Parameters:
+//   - toolConfig: Tool whose localized descriptions are looked up
+//
+// Returns:
+//   - string: Localized description, empty to keep the original description
+func (m *MCPConfig) LocalizedDescription(toolConfig MCPToolConfig) string {
+	language := strings.TrimSpace(m.ToolDescriptionLanguage)
+	if language == "" {
+		return ""
+	}
+	return strings.TrimSpace(toolConfig.Descriptions[language])
+}
+
+// describedTool presents an MCP tool to the LLM with a localized description.
+// Invocations are delegated unchanged to the wrapped tool.
+type describedTool struct {
+	tool.InvokableTool
+	description string
+}
+
+// newDescribedTool wraps t so its ToolInfo.Desc is description.
+// Tools that cannot be invoked directly are returned unchanged.
+func newDescribedTool(t tool.BaseTool, description string) tool.BaseTool {
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok {
+		return t
+	}
+	return &describedTool{InvokableTool: invokable, description: description}
+}
+
+// Info implements tool.BaseTool, only the description of the wrapped tool is replaced
+func (t *describedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	info, err := t.InvokableTool.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	described := *info
+	described.Desc = t.description
+	return &described, nil
+}
+
+// describeTools replaces the descriptions of MCP tools by their localized descriptions.
+// tools must be in the order of toolConfigs, as returned by MCPHubInterface.GetEinoTools.
+func (m *MCPConfig) describeTools(tools []tool.BaseTool, toolConfigs []MCPToolConfig) []tool.BaseTool {
+	if len(tools) != len(toolConfigs) {
+		return tools
+	}
+	result := make([]tool.BaseTool, len(tools))
+	for i, t := range tools {
+		result[i] = t
+		if description := m.LocalizedDescription(toolConfigs[i]); description != "" {
+			result[i] = newDescribedTool(t, description)
+		}
+	}
+	return result
+}
+
+// describeInternalTools replaces the descriptions of the internal tools by
+// the localized descriptions configured for the tools of the inner server
+func (m *MCPConfig) describeInternalTools(ctx context.Context, tools []tool.BaseTool) []tool.BaseTool {
+	descriptions := make(map[string]string)
+	for _, toolConfig := range m.Tools {
+		if toolConfig.Server != InnerServerName && toolConfig.Server != "" {
+			continue
+		}
+		if description := m.LocalizedDescription(toolConfig); description != "" {
+			descriptions[toolConfig.Name] = description
+		}
+	}
+	if len(descriptions) == 0 {
+		return tools
+	}
+
+	result := make([]tool.BaseTool, len(tools))
+	for i, t := range tools {
+		result[i] = t
+		info, err := t.Info(ctx)
+		if err != nil || info == nil {
+			continue
+		}
+		if description, ok := descriptions[info.Name]; ok {
+			result[i] = newDescribedTool(t, description)
+		}
+	}
+	return result
+}
+
+// InheritDescriptions returns tools with the localized descriptions
+// configured for the same tools in m, so that a tool list given on the
+// command line keeps the descriptions of the configuration file.
+// Descriptions set in tools are kept.
+//
+// Parameters:
+//   - tools: Tools replacing the configured tool list
+//
+// Returns:
+//   - []MCPToolConfig: Copy of tools with inherited descriptions
+func (m *MCPConfig) InheritDescriptions(tools []MCPToolConfig) []MCPToolConfig {
+	descriptions := make(map[string]map[string]string)
+	for _, toolConfig := range m.Tools {
+		if len(toolConfig.Descriptions) > 0 {
+			descriptions[toolConfigKey(toolConfig)] = toolConfig.Descriptions
+		}
+	}
+	result := make([]MCPToolConfig, len(tools))
+	for i, toolConfig := range tools {
+		if len(toolConfig.Descriptions) == 0 {
+			toolConfig.Descriptions = descriptions[toolConfigKey(toolConfig)]
+		}
+		result[i] = toolConfig
+	}
+	return result
+}
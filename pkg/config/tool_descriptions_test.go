@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalizedDescription(t *testing.T) {
+	toolConfig := MCPToolConfig{Server: "web", Name: "search", Descriptions: map[string]string{"zh": " 搜索网页 ", "en": ""}}
+	m := &MCPConfig{}
+	assert.Empty(t, m.LocalizedDescription(toolConfig))
+
+	m.ToolDescriptionLanguage = "zh"
+	assert.Equal(t, "搜索网页", m.LocalizedDescription(toolConfig))
+
+	// 没有该语言的描述时使用原描述
+	m.ToolDescriptionLanguage = "en"
+	assert.Empty(t, m.LocalizedDescription(toolConfig))
+	m.ToolDescriptionLanguage = "ja"
+	assert.Empty(t, m.LocalizedDescription(toolConfig))
+}
+
+func TestGetToolsPresentsLocalizedDescriptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	originalNewMCPHub := mcpHubFactory
+	defer func() { mcpHubFactory = originalNewMCPHub }()
+
+	mockMCPHub := NewMockMCPHubInterface(ctrl)
+	webSearch := &invokableMockTool{mockTool: mockTool{name: "search"}}
+	mockTools := []tool.BaseTool{
+		webSearch,
+		&invokableMockTool{mockTool: mockTool{name: "fetch"}},
+	}
+	mockMCPHub.EXPECT().GetEinoTools(gomock.Any(), gomock.Eq([]string{"web_search", "web_fetch"})).Return(mockTools, nil)
+	mockMCPHub.EXPECT().CloseServers().Return(nil)
+	mcpHubFactory = func(ctx context.Context, configFile string) (MCPHubInterface, error) {
+		return mockMCPHub, nil
+	}
+
+	cfg := &Config{
+		MCP: MCPConfig{
+			ConfigFile:              "test_mcpservers.json",
+			PrefixToolNames:         true,
+			ToolDescriptionLanguage: "zh",
+			Tools: []MCPToolConfig{
+				{Server: "web", Name: "search", Descriptions: map[string]string{"zh": "用关键词搜索网页"}},
+				{Server: "web", Name: "fetch", Descriptions: map[string]string{"en": "Fetch a page"}},
+				{Server: InnerServerName, Name: SequentialThinkingToolName, Descriptions: map[string]string{"zh": "逐步思考复杂问题"}},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	tools, cleanup, err := cfg.GetTools(ctx)
+	require.NoError(t, err)
+	defer cleanup()
+
+	descriptions := make(map[string]string)
+	for _, tl := range tools {
+		info, err := tl.Info(ctx)
+		require.NoError(t, err)
+		descriptions[info.Name] = info.Desc
+	}
+	// 本地化描述与展示名称同时生效
+	assert.Equal(t, "用关键词搜索网页", descriptions["web__search"])
+	// 没有该语言的描述时使用原描述
+	assert.Equal(t, "Mock tool for testing", descriptions["web__fetch"])
+	assert.Equal(t, "逐步思考复杂问题", descriptions[SequentialThinkingToolName])
+
+	// 调用委托给原始工具
+	result, err := tools[len(tools)-2].(tool.InvokableTool).InvokableRun(ctx, "{}")
+	require.NoError(t, err)
+	assert.Equal(t, "Mock tool result", result)
+	assert.Equal(t, 1, webSearch.calls)
+}
+
+func TestDescribeToolsWithoutLanguage(t *testing.T) {
+	m := &MCPConfig{}
+	toolConfigs := []MCPToolConfig{{Server: "web", Name: "search", Descriptions: map[string]string{"zh": "搜索网页"}}}
+	search := &invokableMockTool{mockTool: mockTool{name: "search"}}
+
+	tools := m.describeTools([]tool.BaseTool{search}, toolConfigs)
+	assert.Same(t, search, tools[0])
+	assert.Equal(t, []tool.BaseTool{search}, m.describeInternalTools(context.Background(), []tool.BaseTool{search}))
+}
+
+func TestInheritDescriptions(t *testing.T) {
+	m := &MCPConfig{Tools: []MCPToolConfig{
+		{Server: "fofa", Name: "search", Descriptions: map[string]string{"zh": "FOFA搜索"}},
+		{Name: "now", Descriptions: map[string]string{"zh": "当前时间"}},
+	}}
+
+	tools := m.InheritDescriptions([]MCPToolConfig{
+		{Server: "fofa", Name: "search"},
+		{Server: InnerServerName, Name: "now", Descriptions: map[string]string{"zh": "现在的时间"}},
+		{Server: "ddg", Name: "search"},
+	})
+	require.Len(t, tools, 3)
+	assert.Equal(t, "FOFA搜索", tools[0].Descriptions["zh"])
+	assert.Equal(t, "现在的时间", tools[1].Descriptions["zh"])
+	assert.Empty(t, tools[2].Descriptions)
+}
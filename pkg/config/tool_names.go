@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ToolNameSeparator separates the server prefix from the tool name in presented tool names
+const ToolNameSeparator = "__"
+
+// PresentedToolName returns the tool name shown to the LLM for a tool of a server.
+// A server with a name prefix always uses "<prefix>__<tool>"; otherwise the name is
+// "<server>__<tool>" when PrefixToolNames is enabled. Inner tools are never prefixed.
+//
+// Parameters:
+//   - server: MCP server name
+//   - toolName: Original MCP tool name
+//
+// Returns:
+//   - string: Name presented to the LLM
+func (m *MCPConfig) PresentedToolName(server, toolName string) string {
+	if server == "" || server == InnerServerName {
+		return toolName
+	}
+	if prefix := strings.TrimSpace(m.NamePrefixes[server]); prefix != "" {
+		return prefix + ToolNameSeparator + toolName
+	}
+	if m.PrefixToolNames {
+		return server + ToolNameSeparator + toolName
+	}
+	return toolName
+}
+
+// ResolveToolName maps a tool name in either form back to its server and original name.
+// Bare names that do not belong to a configured server are returned with an empty server.
+//
+// Parameters:
+//   - name: Presented or original tool name
+//
+// Returns:
+//   - string: MCP server name, empty if unknown
+//   - string: Original MCP tool name
+func (m *MCPConfig) ResolveToolName(name string) (string, string) {
+	prefix, toolName, found := strings.Cut(name, ToolNameSeparator)
+	if !found || prefix == "" || toolName == "" {
+		return "", name
+	}
+	for server, serverPrefix := range m.NamePrefixes {
+		if strings.TrimSpace(serverPrefix) == prefix {
+			return server, toolName
+		}
+	}
+	if _, ok := m.MCPServers[prefix]; ok {
+		return prefix, toolName
+	}
+	for _, t := range m.Tools {
+		if t.Server == prefix {
+			return prefix, toolName
+		}
+	}
+	return "", name
+}
+
+// presentedTool presents an MCP tool to the LLM under another name.
+// Invocations are delegated unchanged to the wrapped tool, which calls the MCP
+// server with the original tool name.
+type presentedTool struct {
+	tool.InvokableTool
+	name string
+}
+
+// newPresentedTool wraps t so its ToolInfo.Name is name.
+// Tools that cannot be invoked directly are returned unchanged.
+func newPresentedTool(t tool.BaseTool, name string) tool.BaseTool {
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok {
+		return t
+	}
+	return &presentedTool{InvokableTool: invokable, name: name}
+}
+
+// Info implements tool.BaseTool, the parameter schema of the wrapped tool is kept as is
+func (t *presentedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	info, err := t.InvokableTool.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	presented := *info
+	presented.Name = t.name
+	return &presented, nil
+}
+
+// presentTools renames MCP tools to their presented names.
+// tools must be in the order of toolConfigs, as returned by MCPHubInterface.GetEinoTools.
+func (m *MCPConfig) presentTools(tools []tool.BaseTool, toolConfigs []MCPToolConfig) []tool.BaseTool {
+	if len(tools) != len(toolConfigs) {
+		return tools
+	}
+
+	result := make([]tool.BaseTool, len(tools))
+	for i, t := range tools {
+		name := m.PresentedToolName(toolConfigs[i].Server, toolConfigs[i].Name)
+		if name == toolConfigs[i].Name {
+			result[i] = t
+			continue
+		}
+		result[i] = newPresentedTool(t, name)
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresentedToolName(t *testing.T) {
+	m := &MCPConfig{}
+	assert.Equal(t, "tool1", m.PresentedToolName("server1", "tool1"))
+
+	m.PrefixToolNames = true
+	assert.Equal(t, "server1__tool1", m.PresentedToolName("server1", "tool1"))
+	assert.Equal(t, "now", m.PresentedToolName(InnerServerName, "now"))
+	assert.Equal(t, "tool1", m.PresentedToolName("", "tool1"))
+
+	// 服务器前缀优先，且不依赖PrefixToolNames
+	m.NamePrefixes = map[string]string{"server1": "s1"}
+	assert.Equal(t, "s1__tool1", m.PresentedToolName("server1", "tool1"))
+	m.PrefixToolNames = false
+	assert.Equal(t, "s1__tool1", m.PresentedToolName("server1", "tool1"))
+	assert.Equal(t, "tool2", m.PresentedToolName("server2", "tool2"))
+}
+
+func TestResolveToolName(t *testing.T) {
+	m := &MCPConfig{
+		NamePrefixes: map[string]string{"server1": "s1"},
+		Tools: []MCPToolConfig{
+			{Server: "server1", Name: "tool1"},
+			{Server: "server2", Name: "get__item"},
+		},
+	}
+
+	server, name := m.ResolveToolName("s1__tool1")
+	assert.Equal(t, "server1", server)
+	assert.Equal(t, "tool1", name)
+
+	server, name = m.ResolveToolName("server2__get__item")
+	assert.Equal(t, "server2", server)
+	assert.Equal(t, "get__item", name)
+
+	server, name = m.ResolveToolName("tool1")
+	assert.Empty(t, server)
+	assert.Equal(t, "tool1", name)
+
+	server, name = m.ResolveToolName("unknown__tool1")
+	assert.Empty(t, server)
+	assert.Equal(t, "unknown__tool1", name)
+}
+
+// invokableMockTool 是一个可调用的模拟MCP工具
+type invokableMockTool struct {
+	mockTool
+	calls int
+}
+
+func (m *invokableMockTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	m.calls++
+	return "Mock tool result", nil
+}
+
+func TestGetToolsPresentsPrefixedNames(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	originalNewMCPHub := mcpHubFactory
+	defer func() { mcpHubFactory = originalNewMCPHub }()
+
+	mockMCPHub := NewMockMCPHubInterface(ctrl)
+	webSearch := &invokableMockTool{mockTool: mockTool{name: "search"}}
+	mockTools := []tool.BaseTool{
+		webSearch,
+		&invokableMockTool{mockTool: mockTool{name: "search"}},
+	}
+	mockMCPHub.EXPECT().GetEinoTools(gomock.Any(), gomock.Eq([]string{"web_search", "docs_search"})).Return(mockTools, nil)
+	mockMCPHub.EXPECT().CloseServers().Return(nil)
+	mcpHubFactory = func(ctx context.Context, configFile string) (MCPHubInterface, error) {
+		return mockMCPHub, nil
+	}
+
+	cfg := &Config{
+		MCP: MCPConfig{
+			ConfigFile:      "test_mcpservers.json",
+			PrefixToolNames: true,
+			NamePrefixes:    map[string]string{"docs": "kb"},
+			Tools: []MCPToolConfig{
+				{Server: "web", Name: "search"},
+				{Server: "docs", Name: "search"},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	tools, cleanup, err := cfg.GetTools(ctx)
+	require.NoError(t, err)
+	defer cleanup()
+
+	names := make(map[string]tool.BaseTool)
+	for _, tl := range tools {
+		info, err := tl.Info(ctx)
+		require.NoError(t, err)
+		names[info.Name] = tl
+	}
+	require.Contains(t, names, "web__search")
+	require.Contains(t, names, "kb__search")
+	// MCP工具不再与内置的search工具重名
+	assert.Len(t, names, len(tools))
+
+	// 内置工具不加前缀
+	innerTools, err := GetInternalTools(ctx, "", IntegrationsConfig{})
+	require.NoError(t, err)
+	for _, innerTool := range innerTools {
+		info, err := innerTool.Info(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, names, info.Name)
+	}
+
+	// 调用委托给原始工具，即使用原始MCP名称调用服务器
+	invokable, ok := names["web__search"].(tool.InvokableTool)
+	require.True(t, ok)
+	result, err := invokable.InvokableRun(ctx, "{}")
+	require.NoError(t, err)
+	assert.Equal(t, "Mock tool result", result)
+	assert.Equal(t, 1, webSearch.calls)
+
+	// 原始工具的信息不受影响
+	info, err := mockTools[0].Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "search", info.Name)
+}
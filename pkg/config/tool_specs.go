@@ -0,0 +1,318 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ToolSpecSeparator separates the entries of a tool list such as "fetch:fetch,ddg-search:search"
+	ToolSpecSeparator = ","
+
+	// toolSpecServerSeparator separates the server from the tool name in a tool spec
+	toolSpecServerSeparator = ":"
+
+	// toolKeySeparator separates the server from the tool name in the keys of
+	// the hub, see models.GenerateToolKey
+	toolKeySeparator = "_"
+)
+
+// Reasons of malformed tool specs
+const (
+	toolSpecReasonNoServer   = "冒号前缺少服务器名称"
+	toolSpecReasonNoTool     = "缺少工具名称"
+	toolSpecReasonColons     = "包含多个冒号，格式应为 server:tool"
+	toolSpecReasonSpace      = "名称中不能包含空白或不可见字符"
+	toolSpecReasonToolColon  = "服务器和工具名称中不能包含冒号"
+	toolSpecDuplicateWarning = "第%d个工具 %s 与第%d个重复，已忽略"
+)
+
+// toolSpecReplacer maps the full-width separators of Chinese input methods to
+// their ASCII form, so "fetch：fetch，ddg-search：search" is accepted
+var toolSpecReplacer = strings.NewReplacer("，", ToolSpecSeparator, "：", toolSpecServerSeparator)
+
+// ToolSpecError describes a malformed entry of a tool list
+type ToolSpecError struct {
+	Position int    // 条目在列表中的位置，从1开始，0表示单独解析的条目
+	Spec     string // 条目的原始内容
+	Reason   string // 无效的原因
+}
+
+// Error implements the error interface
+func (e *ToolSpecError) Error() string {
+	if e.Position == 0 {
+		return fmt.Sprintf("工具 %q 无效: %s", e.Spec, e.Reason)
+	}
+	return fmt.Sprintf("第%d个工具 %q 无效: %s", e.Position, e.Spec, e.Reason)
+}
+
+// isToolSpecSpace reports whether r is whitespace or an invisible character
+// that is usually copied along with tool names, such as a zero-width space
+func isToolSpecSpace(r rune) bool {
+	switch r {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return true
+	}
+	return unicode.IsSpace(r)
+}
+
+// trimToolSpec removes surrounding whitespace and invisible characters
+func trimToolSpec(s string) string {
+	return strings.TrimFunc(s, isToolSpecSpace)
+}
+
+// ParseToolSpecs parses a comma-separated tool list such as the -mcp-tools flag.
+// Every entry is "server:tool", or only "tool" for the tools of the inner server.
+// Empty entries are skipped, duplicate entries are dropped with a warning.
+//
+// Parameters:
+//   - specs: Comma-separated tool specs
+//
+// Returns:
+//   - []MCPToolConfig: Parsed tools in the order of the list, never nil
+//   - []string: Warnings about dropped duplicate entries
+//   - error: Joined *ToolSpecError for every malformed entry, nil if all entries are valid
+func ParseToolSpecs(specs string) ([]MCPToolConfig, []string, error) {
+	tools := []MCPToolConfig{}
+	if trimToolSpec(specs) == "" {
+		return tools, nil, nil
+	}
+
+	var errs []error
+	var warnings []string
+	seen := make(map[string]int)
+	for i, raw := range strings.Split(toolSpecReplacer.Replace(specs), ToolSpecSeparator) {
+		position := i + 1
+		spec := trimToolSpec(raw)
+		if spec == "" {
+			continue
+		}
+
+		tool, reason := parseToolSpec(spec)
+		if reason != "" {
+			errs = append(errs, &ToolSpecError{Position: position, Spec: spec, Reason: reason})
+			continue
+		}
+
+		key := toolConfigKey(tool)
+		if first, ok := seen[key]; ok {
+			warnings = append(warnings, fmt.Sprintf(toolSpecDuplicateWarning, position, spec, first))
+			continue
+		}
+		seen[key] = position
+		tools = append(tools, tool)
+	}
+	return tools, warnings, errors.Join(errs...)
+}
+
+// parseToolSpec parses a single trimmed tool spec and returns the reason if it is malformed
+func parseToolSpec(spec string) (MCPToolConfig, string) {
+	parts := strings.Split(spec, toolSpecServerSeparator)
+	switch len(parts) {
+	case 1:
+		// 仅提供工具名称，使用内置服务器
+		if strings.IndexFunc(spec, isToolSpecSpace) >= 0 {
+			return MCPToolConfig{}, toolSpecReasonSpace
+		}
+		return MCPToolConfig{Server: InnerServerName, Name: spec}, ""
+	case 2:
+		server, name := trimToolSpec(parts[0]), trimToolSpec(parts[1])
+		if server == "" {
+			return MCPToolConfig{}, toolSpecReasonNoServer
+		}
+		if name == "" {
+			return MCPToolConfig{}, toolSpecReasonNoTool
+		}
+		if strings.IndexFunc(server, isToolSpecSpace) >= 0 || strings.IndexFunc(name, isToolSpecSpace) >= 0 {
+			return MCPToolConfig{}, toolSpecReasonSpace
+		}
+		return MCPToolConfig{Server: server, Name: name}, ""
+	default:
+		return MCPToolConfig{}, toolSpecReasonColons
+	}
+}
+
+// mcpToolConfigType is the type decoded from tool specs by toolSpecHook
+var mcpToolConfigType = reflect.TypeOf(MCPToolConfig{})
+
+// setSpec sets t to the tool of a string entry of a tool list: a tool spec
+// such as "fetch:fetch", or a key of the hub such as "fetch_fetch" as the
+// tools of older configuration files are written. A key is split at its
+// first "_", a name without "_" is a tool of the inner server, and tools of
+// the inner server with "_" in their names are written "inner_save_note".
+func (t *MCPToolConfig) setSpec(spec string) error {
+	trimmed := trimToolSpec(toolSpecReplacer.Replace(spec))
+	if trimmed == "" {
+		return &ToolSpecError{Spec: spec, Reason: toolSpecReasonNoTool}
+	}
+	if !strings.Contains(trimmed, toolSpecServerSeparator) {
+		if server, name, ok := strings.Cut(trimmed, toolKeySeparator); ok && server != "" && name != "" {
+			trimmed = server + toolSpecServerSeparator + name
+		}
+	}
+	tool, reason := parseToolSpec(trimmed)
+	if reason != "" {
+		return &ToolSpecError{Spec: spec, Reason: reason}
+	}
+	*t = tool
+	return nil
+}
+
+// UnmarshalJSON accepts a tool given as an object or as a string such as
+// "fetch:fetch" or "fetch_fetch", see setSpec
+func (t *MCPToolConfig) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '"' {
+		var spec string
+		if err := json.Unmarshal(trimmed, &spec); err != nil {
+			return err
+		}
+		return t.setSpec(spec)
+	}
+	type plain MCPToolConfig
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+// UnmarshalYAML accepts a tool given as a mapping or as a tool spec string, see UnmarshalJSON
+func (t *MCPToolConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return t.setSpec(value.Value)
+	}
+	type plain MCPToolConfig
+	return value.Decode((*plain)(t))
+}
+
+// toolSpecHook is the mapstructure decode hook reading tool strings of
+// configuration files as MCPToolConfig, and the comma-separated tool list of
+// the MCPHOST_MCP_TOOLS environment variable as a list of them
+func toolSpecHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+	switch to {
+	case mcpToolConfigType:
+		var tool MCPToolConfig
+		if err := tool.setSpec(data.(string)); err != nil {
+			return nil, err
+		}
+		return map[string]any{"server": tool.Server, "name": tool.Name}, nil
+	case reflect.SliceOf(mcpToolConfigType):
+		var specs []any
+		for _, spec := range strings.Split(toolSpecReplacer.Replace(data.(string)), ToolSpecSeparator) {
+			if trimToolSpec(spec) != "" {
+				specs = append(specs, spec)
+			}
+		}
+		return specs, nil
+	}
+	return data, nil
+}
+
+// toolConfigKey identifies a tool for duplicate detection, an empty server is the inner server
+func toolConfigKey(tool MCPToolConfig) string {
+	server := tool.Server
+	if server == "" {
+		server = InnerServerName
+	}
+	return server + toolSpecServerSeparator + tool.Name
+}
+
+// ValidateToolConfigs checks tools given as structured entries, such as the
+// tools of a configuration file or of a web request. An empty server selects
+// the inner server. Unlike ParseToolSpecs nothing is trimmed, since surrounding
+// whitespace in these values is always a mistake.
+//
+// Parameters:
+//   - tools: Tools to check
+//
+// Returns:
+//   - []string: Warnings about duplicate entries
+//   - error: Joined *ToolSpecError for every malformed entry, nil if all entries are valid
+func ValidateToolConfigs(tools []MCPToolConfig) ([]string, error) {
+	var errs []error
+	var warnings []string
+	seen := make(map[string]int)
+	for i, tool := range tools {
+		position := i + 1
+		spec := tool.Name
+		if tool.Server != "" {
+			spec = tool.Server + toolSpecServerSeparator + tool.Name
+		}
+
+		var reason string
+		switch {
+		case tool.Name == "":
+			reason = toolSpecReasonNoTool
+		case strings.IndexFunc(tool.Server, isToolSpecSpace) >= 0 || strings.IndexFunc(tool.Name, isToolSpecSpace) >= 0:
+			reason = toolSpecReasonSpace
+		case strings.Contains(tool.Server, toolSpecServerSeparator) || strings.Contains(tool.Name, toolSpecServerSeparator):
+			reason = toolSpecReasonToolColon
+		}
+		if reason != "" {
+			errs = append(errs, &ToolSpecError{Position: position, Spec: spec, Reason: reason})
+			continue
+		}
+
+		key := toolConfigKey(tool)
+		if first, ok := seen[key]; ok {
+			warnings = append(warnings, fmt.Sprintf(toolSpecDuplicateWarning, position, spec, first))
+			continue
+		}
+		seen[key] = position
+	}
+	return warnings, errors.Join(errs...)
+}
+
+// ToolFieldErrors validates tools with ValidateToolConfigs and reports every
+// malformed tool as its own field, such as "tools[1]" for the second tool,
+// and every result filter that does not compile, such as "tools[1].result_filter".
+// Warnings about duplicate tools are logged.
+//
+// Parameters:
+//   - field: Field path of the tool list
+//   - tools: Tools to check
+//
+// Returns:
+//   - FieldErrors: One entry per malformed tool, empty if all tools are valid
+func ToolFieldErrors(field string, tools []MCPToolConfig) FieldErrors {
+	warnings, err := ValidateToolConfigs(tools)
+	for _, warning := range warnings {
+		log.Printf("警告: %s", warning)
+	}
+	if err == nil {
+		return resultFilterFieldErrors(field, tools)
+	}
+
+	var errs FieldErrors
+	for _, toolErr := range unwrapToolSpecErrors(err) {
+		errs = append(errs, FieldError{
+			Field:   fmt.Sprintf("%s[%d]", field, toolErr.Position-1),
+			Message: toolErr.Error(),
+			err:     fmt.Errorf(errMsgToolsInvalid, toolErr),
+		})
+	}
+	return append(errs, resultFilterFieldErrors(field, tools)...)
+}
+
+// unwrapToolSpecErrors returns the *ToolSpecError joined in err
+func unwrapToolSpecErrors(err error) []*ToolSpecError {
+	var specErrs []*ToolSpecError
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			specErrs = append(specErrs, unwrapToolSpecErrors(e)...)
+		}
+		return specErrs
+	}
+	var specErr *ToolSpecError
+	if errors.As(err, &specErr) {
+		specErrs = append(specErrs, specErr)
+	}
+	return specErrs
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseToolSpecs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []MCPToolConfig
+		warnings int
+		errs     []string
+	}{
+		{
+			name:     "空字符串",
+			input:    "",
+			expected: []MCPToolConfig{},
+		},
+		{
+			name:     "只有分隔符",
+			input:    " , ,, ",
+			expected: []MCPToolConfig{},
+		},
+		{
+			name:  "服务器和内置工具",
+			input: "fetch:fetch, now",
+			expected: []MCPToolConfig{
+				{Server: "fetch", Name: "fetch"},
+				{Server: InnerServerName, Name: "now"},
+			},
+		},
+		{
+			name:  "冒号两侧的空格",
+			input: "ddg-search : search",
+			expected: []MCPToolConfig{
+				{Server: "ddg-search", Name: "search"},
+			},
+		},
+		{
+			name:  "全角分隔符",
+			input: "fetch：fetch，ddg-search：search",
+			expected: []MCPToolConfig{
+				{Server: "fetch", Name: "fetch"},
+				{Server: "ddg-search", Name: "search"},
+			},
+		},
+		{
+			name:  "零宽空格和不间断空格",
+			input: "\u200bfetch:fetch\u00a0,\ufeffnow",
+			expected: []MCPToolConfig{
+				{Server: "fetch", Name: "fetch"},
+				{Server: InnerServerName, Name: "now"},
+			},
+		},
+		{
+			name:  "重复工具",
+			input: "fetch:fetch,fetch:fetch,now,inner:now",
+			expected: []MCPToolConfig{
+				{Server: "fetch", Name: "fetch"},
+				{Server: InnerServerName, Name: "now"},
+			},
+			warnings: 2,
+		},
+		{
+			name:  "缺少服务器名称",
+			input: "fetch:fetch,:search",
+			expected: []MCPToolConfig{
+				{Server: "fetch", Name: "fetch"},
+			},
+			errs: []string{`第2个工具 ":search" 无效: 冒号前缺少服务器名称`},
+		},
+		{
+			name:     "缺少工具名称",
+			input:    "ddg-search:",
+			expected: []MCPToolConfig{},
+			errs:     []string{`第1个工具 "ddg-search:" 无效: 缺少工具名称`},
+		},
+		{
+			name:     "多个冒号",
+			input:    "a:b:c",
+			expected: []MCPToolConfig{},
+			errs:     []string{`第1个工具 "a:b:c" 无效: 包含多个冒号，格式应为 server:tool`},
+		},
+		{
+			name:     "名称中的空格",
+			input:    "my server:tool,now\u200bx",
+			expected: []MCPToolConfig{},
+			errs: []string{
+				`第1个工具 "my server:tool" 无效: 名称中不能包含空白或不可见字符`,
+				`第2个工具 "now\u200bx" 无效: 名称中不能包含空白或不可见字符`,
+			},
+		},
+		{
+			name:     "位置包含空条目",
+			input:    "now,,:x",
+			expected: []MCPToolConfig{{Server: InnerServerName, Name: "now"}},
+			errs:     []string{`第3个工具 ":x" 无效`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools, warnings, err := ParseToolSpecs(tt.input)
+			assert.Equal(t, tt.expected, tools)
+			assert.Len(t, warnings, tt.warnings)
+			if len(tt.errs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.errs {
+				assert.Contains(t, err.Error(), want)
+			}
+
+			var specErr *ToolSpecError
+			require.True(t, errors.As(err, &specErr))
+		})
+	}
+}
+
+func TestParseToolSpecsDuplicateWarning(t *testing.T) {
+	_, warnings, err := ParseToolSpecs("fetch:fetch, now, fetch:fetch")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"第3个工具 fetch:fetch 与第1个重复，已忽略"}, warnings)
+}
+
+func TestValidateToolConfigs(t *testing.T) {
+	warnings, err := ValidateToolConfigs([]MCPToolConfig{
+		{Server: "fetch", Name: "fetch"},
+		{Name: "now"},
+		{Server: InnerServerName, Name: "now"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"第3个工具 inner:now 与第2个重复，已忽略"}, warnings)
+
+	_, err = ValidateToolConfigs([]MCPToolConfig{
+		{Server: "fetch", Name: ""},
+		{Server: "fetch ", Name: "fetch"},
+		{Server: "a", Name: "b:c"},
+		{Server: "ok", Name: "ok"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `第1个工具 "fetch:" 无效: 缺少工具名称`)
+	assert.Contains(t, err.Error(), `第2个工具 "fetch :fetch" 无效: 名称中不能包含空白或不可见字符`)
+	assert.Contains(t, err.Error(), `第3个工具 "a:b:c" 无效: 服务器和工具名称中不能包含冒号`)
+	assert.NotContains(t, err.Error(), "第4个")
+}
+
+func TestMCPConfigValidateTools(t *testing.T) {
+	m := &MCPConfig{ConfigFile: "mcpservers.json", Tools: []MCPToolConfig{{Server: "fetch", Name: " fetch"}}}
+	err := m.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "工具列表无效")
+	assert.Contains(t, err.Error(), "第1个工具")
+}
+
+// legacyToolsConfig 旧版配置文件的工具列表，工具写成字符串，可以与对象混用
+const legacyToolsConfig = `
+llm:
+  type: openai
+  base_url: http://test-url.com
+  model: test-model
+  api_key: test-api-key
+mcp:
+  config_file: mcpservers.json
+  tools:
+    - fetch_fetch
+    - sequential-thinking_sequentialthinking
+    - ddg-search:search
+    - sequentialthinking
+    - inner_save_note
+    - server: fofa
+      name: search
+      result_filter: .results[]
+`
+
+// legacyTools legacyToolsConfig中的工具
+var legacyTools = []MCPToolConfig{
+	{Server: "fetch", Name: "fetch"},
+	{Server: "sequential-thinking", Name: "sequentialthinking"},
+	{Server: "ddg-search", Name: "search"},
+	{Server: InnerServerName, Name: "sequentialthinking"},
+	{Server: InnerServerName, Name: "save_note"},
+	{Server: "fofa", Name: "search", ResultFilter: ".results[]"},
+}
+
+func TestLoadConfigLegacyToolStrings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(legacyToolsConfig), 0644))
+
+	cfg, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, legacyTools, cfg.MCP.Tools)
+
+	// 旧版的配置文件也符合Schema
+	errs, err := SchemaFieldErrors([]byte(legacyToolsConfig))
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	// 保存时写成对象，重新加载后不变
+	savedPath := filepath.Join(t.TempDir(), "saved.yaml")
+	require.NoError(t, cfg.SaveConfig(savedPath))
+	data, err := os.ReadFile(savedPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "server: fetch")
+	assert.NotContains(t, string(data), "fetch_fetch")
+	saved, err := LoadConfig(savedPath)
+	require.NoError(t, err)
+	assert.Equal(t, legacyTools, saved.MCP.Tools)
+}
+
+func TestLoadConfigToolsEnv(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(legacyToolsConfig), 0644))
+	t.Setenv("MCPHOST_MCP_TOOLS", "fetch:fetch， inner_save_note")
+
+	cfg, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, []MCPToolConfig{
+		{Server: "fetch", Name: "fetch"},
+		{Server: InnerServerName, Name: "save_note"},
+	}, cfg.MCP.Tools)
+}
+
+func TestLoadConfigInvalidToolString(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "mcp:\n  tools:\n    - \"fetch:a:b\"\n"
+	require.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+
+	_, err := LoadConfig(configPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `工具 "fetch:a:b" 无效: `+toolSpecReasonColons)
+}
+
+func TestMCPToolConfigUnmarshalJSON(t *testing.T) {
+	var tools []MCPToolConfig
+	data := `["fetch_fetch", "ddg-search:search", "sequentialthinking", {"server": "fofa", "name": "search", "diff_against_previous": true}]`
+	require.NoError(t, json.Unmarshal([]byte(data), &tools))
+	assert.Equal(t, []MCPToolConfig{
+		{Server: "fetch", Name: "fetch"},
+		{Server: "ddg-search", Name: "search"},
+		{Server: InnerServerName, Name: "sequentialthinking"},
+		{Server: "fofa", Name: "search", DiffAgainstPrevious: true},
+	}, tools)
+
+	// 对象形式编码后不变
+	encoded, err := json.Marshal(tools)
+	require.NoError(t, err)
+	var decoded []MCPToolConfig
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, tools, decoded)
+
+	err = json.Unmarshal([]byte(`[" "]`), &tools)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), toolSpecReasonNoTool)
+	assert.Error(t, json.Unmarshal([]byte(`[1]`), &tools))
+}
+
+func TestMCPToolConfigUnmarshalYAML(t *testing.T) {
+	var file struct {
+		MCP struct {
+			Tools []MCPToolConfig `yaml:"tools"`
+		} `yaml:"mcp"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(legacyToolsConfig), &file))
+	assert.Equal(t, legacyTools, file.MCP.Tools)
+
+	var tools []MCPToolConfig
+	err := yaml.Unmarshal([]byte(`- "fetch: "`), &tools)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), toolSpecReasonNoTool)
+}
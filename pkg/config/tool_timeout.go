@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+const errMsgToolTimeoutInvalid = "单次工具调用的超时时间不能为负数: %d"
+
+// DefaultToolTimeout is the timeout of one MCP tool call when neither
+// tool_timeout nor the timeout of the server is set.
+const DefaultToolTimeout = time.Duration(mcphost.DefaultMCPTimeoutSeconds) * time.Second
+
+// EffectiveToolTimeout returns the timeout of one tool call of a server, 0
+// when calls only end with the context of the caller. A ToolTimeout of 0
+// removes the per-call timeout and one above 0 applies to every server;
+// without it the timeout of the server is used, DefaultToolTimeout by default.
+//
+// Parameters:
+//   - serverConfig: Definition of the server, nil if unknown
+//
+// Returns:
+//   - time.Duration: Timeout of one call, 0 for no timeout
+func (m *MCPConfig) EffectiveToolTimeout(serverConfig *mcphost.ServerConfig) time.Duration {
+	switch {
+	case m.ToolTimeout != nil:
+		return time.Duration(*m.ToolTimeout) * time.Second
+	case serverConfig != nil:
+		return serverConfig.GetTimeoutDuration()
+	}
+	return DefaultToolTimeout
+}
+
+// validateToolTimeout checks that ToolTimeout is not negative
+func (m *MCPConfig) validateToolTimeout() error {
+	if m.ToolTimeout != nil && *m.ToolTimeout < 0 {
+		return fmt.Errorf(errMsgToolTimeoutInvalid, *m.ToolTimeout)
+	}
+	return nil
+}
+
+// toolTimeouts returns the timeout of the tool calls of every server, see
+// EffectiveToolTimeout, and whether it replaces the timeout of the server
+// that the MCP hub applies, which is only the case when ToolTimeout is set
+// to another value. servers are loaded from ConfigFile when nil.
+func (m *MCPConfig) toolTimeouts(servers map[string]*mcphost.ServerConfig) func(server string) (time.Duration, bool) {
+	if servers == nil && m.ToolTimeout != nil && strings.TrimSpace(m.ConfigFile) != "" {
+		// 配置文件无法加载时按服务器未知处理
+		servers, _, _ = m.ResolveServers()
+	}
+	return func(server string) (time.Duration, bool) {
+		serverConfig := servers[server]
+		timeout := m.EffectiveToolTimeout(serverConfig)
+		if m.ToolTimeout == nil {
+			return timeout, false
+		}
+		return timeout, serverConfig == nil || serverConfig.GetTimeoutDuration() != timeout
+	}
+}
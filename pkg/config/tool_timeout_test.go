@@ -0,0 +1,144 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/artifact"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestEffectiveToolTimeout(t *testing.T) {
+	crawler := &mcphost.ServerConfig{Command: "crawler", Timeout: 10 * time.Minute}
+
+	tests := []struct {
+		name        string
+		toolTimeout *int
+		server      *mcphost.ServerConfig
+		want        time.Duration
+	}{
+		{"默认30秒", nil, &mcphost.ServerConfig{Command: "fetch"}, DefaultToolTimeout},
+		{"未知服务器", nil, nil, DefaultToolTimeout},
+		{"服务器的timeout", nil, crawler, 10 * time.Minute},
+		{"tool_timeout覆盖服务器", intPtr(120), crawler, 2 * time.Minute},
+		{"tool_timeout为0不限制", intPtr(0), crawler, 0},
+		{"tool_timeout为0时未知服务器也不限制", intPtr(0), nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MCPConfig{ToolTimeout: tt.toolTimeout}
+			assert.Equal(t, tt.want, m.EffectiveToolTimeout(tt.server))
+		})
+	}
+}
+
+func TestValidateToolTimeout(t *testing.T) {
+	m := &MCPConfig{MCPServers: map[string]*mcphost.ServerConfig{}, ToolTimeout: intPtr(0)}
+	assert.NoError(t, m.Validate())
+
+	m.ToolTimeout = intPtr(-1)
+	errs := m.ValidateDetailed()
+	require.Len(t, errs, 1)
+	assert.Equal(t, "tool_timeout", errs[0].Field)
+}
+
+// deadlineClient records whether the tool calls have a deadline
+type deadlineClient struct {
+	client.MCPClient
+	remaining []time.Duration // 每次调用剩余的时间，没有截止时间时为0
+}
+
+func (c *deadlineClient) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var remaining time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining = time.Until(deadline)
+	}
+	c.remaining = append(c.remaining, remaining)
+	return &mcp.CallToolResult{Content: []mcp.Content{mcp.NewTextContent("ok")}}, nil
+}
+
+// clientHub is a hub exposing the clients of its servers
+type clientHub struct {
+	MCPHubInterface
+	clients map[string]client.MCPClient
+}
+
+func (h *clientHub) GetClient(serverName string) (client.MCPClient, error) {
+	cli, ok := h.clients[serverName]
+	if !ok {
+		return nil, errors.New("服务器不存在")
+	}
+	return cli, nil
+}
+
+func TestWrapToolsAppliesToolTimeout(t *testing.T) {
+	crawler := &deadlineClient{}
+	fetch := &deadlineClient{}
+	hub := &clientHub{clients: map[string]client.MCPClient{"crawler": crawler, "fetch": fetch}}
+	servers := map[string]*mcphost.ServerConfig{
+		"crawler": {Command: "crawler", Timeout: 10 * time.Minute},
+		"fetch":   {Command: "fetch"},
+	}
+	hubTool := &slowTool{name: "hub"}
+	tools := []tool.BaseTool{hubTool, hubTool}
+	toolConfigs := []MCPToolConfig{{Server: "crawler", Name: "crawl"}, {Server: "fetch", Name: "fetch"}}
+
+	// 没有tool_timeout时hub使用服务器的超时，工具仍由hub调用
+	m := &MCPConfig{}
+	wrapped, err := (&ArtifactConfig{}).wrapTools(hub, tools, toolConfigs, m.toolTimeouts(servers))
+	require.NoError(t, err)
+	assert.Same(t, hubTool, wrapped[0])
+	assert.Same(t, hubTool, wrapped[1])
+
+	// tool_timeout与服务器的超时相同时仍由hub调用，其他工具直接调用服务器
+	m = &MCPConfig{ToolTimeout: intPtr(600)}
+	wrapped, err = (&ArtifactConfig{}).wrapTools(hub, tools, toolConfigs, m.toolTimeouts(servers))
+	require.NoError(t, err)
+	assert.Same(t, hubTool, wrapped[0])
+	_, err = wrapped[1].(tool.InvokableTool).InvokableRun(context.Background(), "{}")
+	require.NoError(t, err)
+	require.Len(t, fetch.remaining, 1)
+	assert.Greater(t, fetch.remaining[0], 9*time.Minute)
+
+	// tool_timeout为0时工具调用只受任务上下文限制
+	m = &MCPConfig{ToolTimeout: intPtr(0)}
+	wrapped, err = (&ArtifactConfig{}).wrapTools(hub, tools, toolConfigs, m.toolTimeouts(servers))
+	require.NoError(t, err)
+	_, err = wrapped[0].(tool.InvokableTool).InvokableRun(context.Background(), "{}")
+	require.NoError(t, err)
+	require.Len(t, crawler.remaining, 1)
+	assert.Zero(t, crawler.remaining[0])
+}
+
+func TestWrapToolsFailsWithoutClients(t *testing.T) {
+	servers := map[string]*mcphost.ServerConfig{"fetch": {Command: "fetch"}}
+	hubTool := &slowTool{name: "hub"}
+	toolConfigs := []MCPToolConfig{{Server: "fetch", Name: "fetch"}}
+	m := &MCPConfig{ToolTimeout: intPtr(600)}
+
+	// hub不提供客户端时无法使用tool_timeout
+	_, err := (&ArtifactConfig{}).wrapTools(&clientHub{}, []tool.BaseTool{hubTool}, toolConfigs, m.toolTimeouts(servers))
+	assert.ErrorContains(t, err, "无法使用tool_timeout设置的超时时间 10m0s")
+	_, err = (&ArtifactConfig{}).wrapTools(&MockMCPHubInterface{}, []tool.BaseTool{hubTool}, toolConfigs, m.toolTimeouts(servers))
+	assert.ErrorContains(t, err, "MCP服务器管理器不提供服务器的客户端")
+
+	// 只需要保存产物时返回原来的工具
+	store := artifact.NewStore(t.TempDir(), "task_1", 0, nil)
+	wrapped, err := (&ArtifactConfig{Store: store}).wrapTools(&MockMCPHubInterface{}, []tool.BaseTool{hubTool}, toolConfigs, (&MCPConfig{}).toolTimeouts(servers))
+	require.NoError(t, err)
+	assert.Same(t, hubTool, wrapped[0])
+
+	_, err = (&ArtifactConfig{}).wrapTools(&clientHub{}, []tool.BaseTool{hubTool}, nil, m.toolTimeouts(servers))
+	assert.ErrorContains(t, err, "不一致")
+}
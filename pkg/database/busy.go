@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrDatabaseLocked is returned when another connection, typically a second
+// mcpagent-web process using the same database file, keeps the database
+// locked for longer than the busy timeout and the retries
+var ErrDatabaseLocked = errors.New("数据库被另一个进程锁定")
+
+var (
+	// busyTimeout is how long SQLite waits for a lock before a statement fails with SQLITE_BUSY
+	busyTimeout = 5 * time.Second
+
+	// busyRetryAttempts is the number of times RetryOnBusy runs an operation
+	busyRetryAttempts = 5
+
+	// busyRetryDelay is the wait before the first retry, it doubles with every retry
+	busyRetryDelay = 100 * time.Millisecond
+)
+
+// busyMessages are the SQLite messages of SQLITE_BUSY and SQLITE_LOCKED
+var busyMessages = []string{"database is locked", "database table is locked"}
+
+// dataSourceName returns the DSN of the database at dbPath: every connection
+// waits busyTimeout for locks, the file uses WAL so readers do not block the
+// writer, and transactions take the write lock when they begin so that the
+// busy timeout applies to them instead of failing when a read is upgraded.
+func dataSourceName(dbPath string) string {
+	if dbPath == memoryPath {
+		return dbPath
+	}
+	return fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=WAL&_txlock=immediate", dbPath, busyTimeout.Milliseconds())
+}
+
+// IsBusy reports whether err is a transient SQLITE_BUSY or SQLITE_LOCKED error.
+// The error is recognized by its SQLite message rather than the driver's
+// error type, which only exists in cgo builds.
+//
+// Parameters:
+//   - err: Error returned by a database operation
+//
+// Returns:
+//   - bool: True if the operation may succeed when it is retried
+func IsBusy(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	for _, busy := range busyMessages {
+		if strings.Contains(message, busy) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryOnBusy runs op and retries it with exponential backoff while it fails
+// with a busy error, see IsBusy. op must be safe to run more than once, such
+// as a single statement or a transaction. Other errors are returned as they are.
+//
+// Parameters:
+//   - op: Database write to run
+//
+// Returns:
+//   - error: Error of op, wrapping ErrDatabaseLocked if it was still busy after the last retry
+func RetryOnBusy(op func() error) error {
+	delay := busyRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if !IsBusy(err) {
+			return err
+		}
+		if attempt >= busyRetryAttempts {
+			return fmt.Errorf("%w，重试%d次后仍然失败，请确认没有其他mcpagent-web实例在使用同一个数据库文件: %v", ErrDatabaseLocked, attempt, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// checkNotLocked takes and releases the write lock of the database at
+// dbPath, so that a database held by another process is reported at startup
+// instead of as errors of later requests
+func checkNotLocked(db *gorm.DB, dbPath string) error {
+	if dbPath == memoryPath {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return wrapLocked(err, dbPath)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return wrapLocked(err, dbPath)
+	}
+	_, err = conn.ExecContext(ctx, "ROLLBACK")
+	return err
+}
+
+// wrapLocked returns err unchanged unless it is a busy error, which is
+// replaced by ErrDatabaseLocked naming the processes holding the database
+func wrapLocked(err error, dbPath string) error {
+	if !IsBusy(err) {
+		return err
+	}
+	message := fmt.Sprintf("%s 在%v内没有释放锁，可能有另一个mcpagent-web实例正在使用同一个数据库文件（例如systemd重启时旧进程尚未退出）", dbPath, busyTimeout)
+	if holders := lockHolders(dbPath); len(holders) > 0 {
+		message += "，占用数据库的进程: " + strings.Join(holders, ", ")
+	} else if hint := lockHint(dbPath); hint != "" {
+		message += "，" + hint
+	}
+	return fmt.Errorf("%w: %s: %v", ErrDatabaseLocked, message, err)
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// shortBusyWait 缩短等待锁和重试的时间，测试结束后恢复
+func shortBusyWait(t *testing.T) {
+	timeout, attempts, delay := busyTimeout, busyRetryAttempts, busyRetryDelay
+	busyTimeout, busyRetryAttempts, busyRetryDelay = 20*time.Millisecond, 4, 20*time.Millisecond
+	t.Cleanup(func() {
+		busyTimeout, busyRetryAttempts, busyRetryDelay = timeout, attempts, delay
+	})
+}
+
+// holdWriteLock 通过另一个连接开启写事务，返回释放锁的函数
+func holdWriteLock(t *testing.T, dbPath string) func() {
+	t.Helper()
+	other, err := gorm.Open(sqlite.Open(dataSourceName(dbPath)), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := other.DB()
+	require.NoError(t, err)
+	conn, err := sqlDB.Conn(context.Background())
+	require.NoError(t, err)
+	_, err = conn.ExecContext(context.Background(), "BEGIN IMMEDIATE")
+	require.NoError(t, err)
+
+	var released bool
+	release := func() {
+		if released {
+			return
+		}
+		released = true
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		conn.Close()
+		sqlDB.Close()
+	}
+	t.Cleanup(release)
+	return release
+}
+
+// initBusyTestDatabase 初始化临时数据库，返回数据库文件路径
+func initBusyTestDatabase(t *testing.T) string {
+	t.Helper()
+	t.Setenv(secret.EnvKey, "")
+	t.Cleanup(func() { secret.SetDefault(nil) })
+	dbPath := filepath.Join(t.TempDir(), "mcpagent.db")
+	require.NoError(t, InitDatabase(dbPath))
+	t.Cleanup(func() { CloseDatabase() })
+	return dbPath
+}
+
+func TestRetryOnBusySucceedsAfterRelease(t *testing.T) {
+	shortBusyWait(t)
+	dbPath := initBusyTestDatabase(t)
+	release := holdWriteLock(t, dbPath)
+
+	// 另一个连接在第一次重试后释放写锁
+	time.AfterFunc(50*time.Millisecond, release)
+	attempts := 0
+	err := RetryOnBusy(func() error {
+		attempts++
+		return DB.Create(&models.TaskHistoryModel{TaskID: "task_busy", Task: "扫描"}).Error
+	})
+	require.NoError(t, err)
+	assert.Greater(t, attempts, 1)
+
+	var count int64
+	require.NoError(t, DB.Model(&models.TaskHistoryModel{}).Where("task_id = ?", "task_busy").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRetryOnBusyFriendlyError(t *testing.T) {
+	shortBusyWait(t)
+	dbPath := initBusyTestDatabase(t)
+	holdWriteLock(t, dbPath)
+
+	// 写锁一直没有释放时返回ErrDatabaseLocked和说明原因的错误
+	attempts := 0
+	err := RetryOnBusy(func() error {
+		attempts++
+		return DB.Create(&models.TaskHistoryModel{TaskID: "task_busy", Task: "扫描"}).Error
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrDatabaseLocked)
+	assert.Contains(t, err.Error(), "重试4次后仍然失败，请确认没有其他mcpagent-web实例在使用同一个数据库文件")
+	assert.Equal(t, busyRetryAttempts, attempts)
+
+	// 其他错误不重试
+	attempts = 0
+	err = RetryOnBusy(func() error {
+		attempts++
+		return sql.ErrConnDone
+	})
+	assert.Equal(t, sql.ErrConnDone, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestInitDatabaseLocked(t *testing.T) {
+	shortBusyWait(t)
+	dbPath := initBusyTestDatabase(t)
+	require.NoError(t, CloseDatabase())
+	release := holdWriteLock(t, dbPath)
+
+	// 另一个进程占用数据库时启动失败并给出提示
+	err := InitDatabase(dbPath)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrDatabaseLocked)
+	assert.Contains(t, err.Error(), "可能有另一个mcpagent-web实例正在使用同一个数据库文件")
+
+	release()
+	require.NoError(t, InitDatabase(dbPath))
+}
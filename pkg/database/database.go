@@ -3,12 +3,14 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -17,13 +19,86 @@ import (
 // DB is the global database instance
 var DB *gorm.DB
 
+// secretKeyFile is the name of the key file created next to the database
+// when the encryption key is not given through secret.EnvKey
+const secretKeyFile = "secret.key"
+
+// secretColumns are the columns holding encrypted values, plaintext values
+// written by older versions are encrypted when the database is opened
+var secretColumns = []struct {
+	table  string
+	column string
+}{
+	{"llm_configs", "api_key"},
+	{"mcp_server_configs", "env"},
+	{"mcp_server_configs", "headers"},
+	{"app_configs", "fofa_key"},
+	{"app_configs", "notifications"},
+	{"task_histories", "config"},
+}
+
+// ErrNotWritable is returned when the database file or its directory cannot
+// be written, typically in a read-only container
+var ErrNotWritable = errors.New("数据库路径不可写")
+
+// memoryPath is the path of an in-memory database
+const memoryPath = ":memory:"
+
+// CheckWritable checks that the database at dbPath can be created or written.
+// SQLite only reports a read-only file at the first write, after the
+// connection was opened successfully.
+//
+// Returns:
+//   - error: Error wrapping ErrNotWritable if the file or its directory is read-only
+func CheckWritable(dbPath string) error {
+	if dbPath == memoryPath {
+		return nil
+	}
+	dbDir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrNotWritable, dbDir, err)
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		file, err := os.OpenFile(dbPath, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrNotWritable, dbPath, err)
+		}
+		file.Close()
+	}
+	// SQLite需要在数据库目录中创建日志文件
+	probe, err := os.CreateTemp(dbDir, ".mcpagent-write-check-*")
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrNotWritable, dbDir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
 // InitDatabase initializes the database connection and performs migrations.
 // It creates the database file if it doesn't exist and runs auto-migrations.
+// The encryption key is read from secret.EnvKey or the key file next to the database.
+// The database uses WAL mode and waits for locks held by other connections;
+// a database another process keeps locked fails with ErrDatabaseLocked.
 func InitDatabase(dbPath string) error {
-	// 确保数据库目录存在
-	dbDir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return fmt.Errorf("创建数据库目录失败: %w", err)
+	return InitDatabaseWithKeyFile(dbPath, "")
+}
+
+// InitDatabaseWithKeyFile initializes the database like InitDatabase, with
+// the encryption key of sensitive columns read from keyFile.
+//
+// Parameters:
+//   - dbPath: Path of the database file, ":memory:" for an in-memory database
+//   - keyFile: Path of the key file, which takes precedence over secret.EnvKey;
+//     empty uses secret.EnvKey or secret.key next to the database.
+//     A missing key file is created unless the database already holds encrypted values.
+//
+// Returns:
+//   - error: Error if the key is missing or wrong for the encrypted values, or the database cannot be initialized
+func InitDatabaseWithKeyFile(dbPath, keyFile string) error {
+	// 确保数据库目录存在并且可写
+	if err := CheckWritable(dbPath); err != nil {
+		return err
 	}
 
 	// 配置GORM日志级别
@@ -33,16 +108,29 @@ func InitDatabase(dbPath string) error {
 	}
 
 	// 连接数据库
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	db, err := gorm.Open(sqlite.Open(dataSourceName(dbPath)), &gorm.Config{
 		Logger: logger.Default.LogMode(logLevel),
 	})
 	if err != nil {
-		return fmt.Errorf("连接数据库失败: %w", err)
+		return fmt.Errorf("连接数据库失败: %w", wrapLocked(err, dbPath))
+	}
+
+	// 另一个进程占用数据库时在启动时报错，而不是在之后的请求中
+	if err := checkNotLocked(db, dbPath); err != nil {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			sqlDB.Close()
+		}
+		return err
 	}
 
 	// 设置全局数据库实例
 	DB = db
 
+	// 初始化敏感字段的加密密钥
+	if err := initSecret(dbPath, keyFile); err != nil {
+		return fmt.Errorf("初始化加密密钥失败: %w", err)
+	}
+
 	// 执行自动迁移
 	if err := autoMigrate(); err != nil {
 		return fmt.Errorf("数据库迁移失败: %w", err)
@@ -62,6 +150,88 @@ func InitDatabase(dbPath string) error {
 	return nil
 }
 
+// initSecret sets up the cipher for sensitive columns.
+// In-memory databases use a random key since their data does not outlive the process.
+func initSecret(dbPath, keyFile string) error {
+	var key []byte
+	var err error
+	if dbPath == memoryPath && keyFile == "" {
+		key, err = secret.RandomKey()
+	} else {
+		key, err = loadSecretKey(dbPath, keyFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := secret.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	if err := verifySecretKey(c); err != nil {
+		return err
+	}
+	secret.SetDefault(c)
+	return nil
+}
+
+// loadSecretKey returns the key from keyFile, secret.EnvKey or the default key file.
+// A new key is only generated when no encrypted values exist, otherwise they
+// could never be decrypted again.
+func loadSecretKey(dbPath, keyFile string) ([]byte, error) {
+	if keyFile == "" {
+		if key := secret.EnvValue(); key != nil {
+			return key, nil
+		}
+		keyFile = filepath.Join(filepath.Dir(dbPath), secretKeyFile)
+	}
+
+	key, err := secret.ReadKeyFile(keyFile)
+	if !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+
+	encrypted, err := findEncryptedValue()
+	if err != nil {
+		return nil, err
+	}
+	if encrypted != "" {
+		return nil, fmt.Errorf("%w: 数据库中已有加密数据，但密钥文件 %s 不存在，请通过 -db-key-file 或环境变量 %s 提供原来的密钥", secret.ErrNoKey, keyFile, secret.EnvKey)
+	}
+	log.Printf("已生成新的加密密钥: %s", keyFile)
+	return secret.CreateKeyFile(keyFile)
+}
+
+// verifySecretKey checks that c decrypts the values already in the database
+func verifySecretKey(c *secret.Cipher) error {
+	encrypted, err := findEncryptedValue()
+	if err != nil || encrypted == "" {
+		return err
+	}
+	if _, err := c.Decrypt(encrypted); err != nil {
+		return fmt.Errorf("%w: 无法解密数据库中已有的数据，请确认 -db-key-file 或环境变量 %s 提供的是原来的密钥", err, secret.EnvKey)
+	}
+	return nil
+}
+
+// findEncryptedValue returns any encrypted value stored in the database, empty if there is none
+func findEncryptedValue() (string, error) {
+	for _, c := range secretColumns {
+		if !DB.Migrator().HasTable(c.table) || !DB.Migrator().HasColumn(c.table, c.column) {
+			continue
+		}
+		var values []string
+		err := DB.Table(c.table).Where(c.column+" LIKE ?", secret.EncryptedPrefix+"%").Limit(1).Pluck(c.column, &values).Error
+		if err != nil {
+			return "", fmt.Errorf("检查%s表的加密数据失败: %w", c.table, err)
+		}
+		if len(values) > 0 {
+			return values[0], nil
+		}
+	}
+	return "", nil
+}
+
 // autoMigrate performs automatic database migrations
 func autoMigrate() error {
 	return DB.AutoMigrate(
@@ -70,6 +240,13 @@ func autoMigrate() error {
 		&models.MCPToolModel{},
 		&models.SystemPromptModel{},
 		&models.AppConfigModel{},
+		&models.ToolUsageModel{},
+		&models.MCPServerHealthModel{},
+		&models.ArtifactModel{},
+		&models.TaskHistoryModel{},
+		&models.TaskTemplateModel{},
+		&models.CredentialModel{},
+		&models.ToolResultSnapshotModel{},
 	)
 }
 
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawColumn returns the stored value of a column without decryption
+func rawColumn(t *testing.T, table, column string, id uint) string {
+	var values []string
+	require.NoError(t, DB.Table(table).Where("id = ?", id).Pluck(column, &values).Error)
+	require.Len(t, values, 1)
+	return values[0]
+}
+
+func TestInitDatabaseEncryptsLegacyRows(t *testing.T) {
+	t.Setenv(secret.EnvKey, "")
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "mcpagent.db")
+	defer secret.SetDefault(nil)
+
+	require.NoError(t, InitDatabase(dbPath))
+	keyFile := filepath.Join(dir, secretKeyFile)
+	require.FileExists(t, keyFile)
+
+	// 模拟旧版本以明文保存的数据
+	var llm models.LLMConfigModel
+	require.NoError(t, DB.First(&llm).Error)
+	server := &models.MCPServerConfigModel{Name: "legacy", TransportType: "sse", URL: "http://127.0.0.1/sse"}
+	require.NoError(t, DB.Create(server).Error)
+	require.NoError(t, DB.Table("llm_configs").Where("id = ?", llm.ID).UpdateColumn("api_key", "sk-legacy").Error)
+	require.NoError(t, DB.Table("mcp_server_configs").Where("id = ?", server.ID).
+		UpdateColumn("headers", `{"Authorization":"Bearer legacy"}`).Error)
+	require.NoError(t, CloseDatabase())
+
+	require.NoError(t, InitDatabase(dbPath))
+	assert.True(t, secret.IsEncrypted(rawColumn(t, "llm_configs", "api_key", llm.ID)))
+	assert.True(t, secret.IsEncrypted(rawColumn(t, "mcp_server_configs", "headers", server.ID)))
+	require.NoError(t, DB.First(&llm, llm.ID).Error)
+	assert.Equal(t, "sk-legacy", llm.APIKey)
+	var loaded models.MCPServerConfigModel
+	require.NoError(t, DB.First(&loaded, server.ID).Error)
+	assert.Equal(t, `{"Authorization":"Bearer legacy"}`, loaded.Headers)
+	require.NoError(t, CloseDatabase())
+
+	// 已有加密数据时不会为丢失的密钥文件生成新密钥
+	key, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(keyFile))
+	err = InitDatabase(dbPath)
+	assert.ErrorIs(t, err, secret.ErrNoKey)
+	assert.NoFileExists(t, keyFile)
+	CloseDatabase()
+
+	// 错误的密钥无法启动
+	wrongKeyFile := filepath.Join(dir, "wrong.key")
+	require.NoError(t, os.WriteFile(wrongKeyFile, []byte("wrong-key"), 0600))
+	err = InitDatabaseWithKeyFile(dbPath, wrongKeyFile)
+	assert.ErrorIs(t, err, secret.ErrDecrypt)
+	CloseDatabase()
+
+	// 通过 -db-key-file 或环境变量提供原来的密钥
+	movedKeyFile := filepath.Join(dir, "moved.key")
+	require.NoError(t, os.WriteFile(movedKeyFile, key, 0600))
+	require.NoError(t, InitDatabaseWithKeyFile(dbPath, movedKeyFile))
+	require.NoError(t, DB.First(&llm, llm.ID).Error)
+	assert.Equal(t, "sk-legacy", llm.APIKey)
+	require.NoError(t, CloseDatabase())
+
+	t.Setenv(secret.EnvKey, string(key))
+	require.NoError(t, InitDatabase(dbPath))
+	require.NoError(t, CloseDatabase())
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, CheckWritable(filepath.Join(dir, "data", "mcpagent.db")), "目录不存在时创建")
+	require.NoError(t, CheckWritable(memoryPath))
+	entries, err := os.ReadDir(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "检查后不留下文件")
+
+	if os.Geteuid() == 0 {
+		t.Skip("root用户不受文件权限限制")
+	}
+	readOnly := filepath.Join(dir, "readonly")
+	require.NoError(t, os.Mkdir(readOnly, 0555))
+	err = CheckWritable(filepath.Join(readOnly, "mcpagent.db"))
+	assert.ErrorIs(t, err, ErrNotWritable)
+	assert.ErrorIs(t, InitDatabase(filepath.Join(readOnly, "mcpagent.db")), ErrNotWritable)
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package database
+
+// lockHolders is not available on this platform
+func lockHolders(dbPath string) []string {
+	return nil
+}
+
+// lockHint has no hint on this platform
+func lockHint(dbPath string) string {
+	return ""
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lockHolders returns the other processes that have the database at dbPath
+// or its WAL files open, as "PID (command)". It reads /proc where available
+// and returns nil otherwise or when the processes cannot be inspected.
+func lockHolders(dbPath string) []string {
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return nil
+	}
+	files := map[string]bool{absPath: true, absPath + "-wal": true, absPath + "-shm": true}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var holders []string
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err == nil && files[target] {
+				holders = append(holders, describeProcess(pid))
+				break
+			}
+		}
+	}
+	return holders
+}
+
+// describeProcess returns "PID (command)" of pid, only the PID if the command is unknown
+func describeProcess(pid int) string {
+	comm, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return strconv.Itoa(pid)
+	}
+	return fmt.Sprintf("%d (%s)", pid, strings.TrimSpace(string(comm)))
+}
+
+// lockHint tells how to find the processes holding the database at dbPath
+func lockHint(dbPath string) string {
+	return fmt.Sprintf("可以使用 lsof %s 查看占用数据库的进程", dbPath)
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
+	"gorm.io/gorm"
 )
 
 // 执行所有迁移脚本
@@ -25,6 +27,11 @@ func RunMigrations() error {
 		return fmt.Errorf("迁移mcp_server_configs表添加SSE支持字段失败: %w", err)
 	}
 
+	// 加密旧版本以明文保存的敏感字段
+	if err := migrateEncryptSecretColumns(); err != nil {
+		return fmt.Errorf("加密敏感字段失败: %w", err)
+	}
+
 	return nil
 }
 
@@ -137,3 +144,45 @@ func migrateAppConfigAddMCPSettings() error {
 
 	return nil
 }
+
+// 加密旧版本以明文保存的敏感字段，已加密的值保持不变
+func migrateEncryptSecretColumns() error {
+	for _, c := range secretColumns {
+		if !DB.Migrator().HasTable(c.table) || !DB.Migrator().HasColumn(c.table, c.column) {
+			continue
+		}
+
+		var rows []struct {
+			ID    uint
+			Value string
+		}
+		err := DB.Table(c.table).
+			Select("id, "+c.column+" AS value").
+			Where(c.column+" <> '' AND "+c.column+" NOT LIKE ?", secret.EncryptedPrefix+"%").
+			Scan(&rows).Error
+		if err != nil {
+			return fmt.Errorf("查询%s表的明文数据失败: %w", c.table, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				encrypted, err := secret.Encrypt(row.Value)
+				if err != nil {
+					return err
+				}
+				if err := tx.Table(c.table).Where("id = ?", row.ID).UpdateColumn(c.column, encrypted).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("加密%s表的%s字段失败: %w", c.table, c.column, err)
+		}
+		log.Printf("已加密%s表中 %d 条记录的%s字段", c.table, len(rows), c.column)
+	}
+	return nil
+}
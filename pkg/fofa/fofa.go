@@ -0,0 +1,256 @@
+// Package fofa implements a client for the FOFA search engine API and the
+// fofa_search inner tool built on it.
+package fofa
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultBaseURL is the FOFA API address used when none is configured
+	DefaultBaseURL = "https://fofa.info"
+
+	// DefaultFields are the fields returned when a query does not name any
+	DefaultFields = "host,ip,port,title"
+
+	// DefaultMaxSize is the default number of results returned by one search
+	DefaultMaxSize = 100
+
+	// MaxSizeLimit is the upper bound of results returned by one search
+	MaxSizeLimit = 10000
+
+	// DefaultPageSize is the number of results requested per API call
+	DefaultPageSize = 100
+
+	// searchPath is the FOFA search API path
+	searchPath = "/api/v1/search/all"
+
+	// maxCellLen is the number of characters kept per result value
+	maxCellLen = 256
+
+	// maxErrorBodyLen limits the response body included in error messages
+	maxErrorBodyLen = 200
+)
+
+var (
+	ErrNotConfigured = errors.New("未配置FOFA账号，请在integrations.fofa中设置email和key")
+	ErrQueryEmpty    = errors.New("FOFA查询语句不能为空")
+	ErrAuth          = errors.New("FOFA认证失败，请检查email和key是否正确")
+	ErrQuota         = errors.New("FOFA查询额度不足或请求过于频繁")
+)
+
+// Client queries the FOFA search API
+type Client struct {
+	BaseURL    string
+	Email      string
+	Key        string
+	PageSize   int          // 每次请求的结果数，<=0时使用DefaultPageSize
+	HTTPClient *http.Client // 为空时使用http.DefaultClient
+}
+
+// Result is a normalized search result: one row per asset, one column per field
+type Result struct {
+	Query  string     `json:"query"`
+	Total  int        `json:"total"`
+	Fields []string   `json:"fields"`
+	Rows   [][]string `json:"rows"`
+}
+
+// apiResponse is the response of the FOFA search API
+type apiResponse struct {
+	Error   bool              `json:"error"`
+	ErrMsg  string            `json:"errmsg"`
+	Size    int               `json:"size"`
+	Results []json.RawMessage `json:"results"`
+}
+
+// Search runs a query and collects up to size results, fetching as many pages as needed.
+//
+// Parameters:
+//   - ctx: Context for cancellation
+//   - query: FOFA query, for example `domain="example.com"`
+//   - size: Maximum number of results, capped at MaxSizeLimit
+//   - fields: Fields to return, DefaultFields if empty
+//
+// Returns:
+//   - *Result: Normalized search result
+//   - error: ErrNotConfigured, ErrAuth or ErrQuota wrapped with details, or a request error
+func (c *Client) Search(ctx context.Context, query string, size int, fields []string) (*Result, error) {
+	if strings.TrimSpace(c.Email) == "" || strings.TrimSpace(c.Key) == "" {
+		return nil, ErrNotConfigured
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrQueryEmpty
+	}
+	if len(fields) == 0 {
+		fields = strings.Split(DefaultFields, ",")
+	}
+	if size <= 0 {
+		size = DefaultMaxSize
+	}
+	if size > MaxSizeLimit {
+		size = MaxSizeLimit
+	}
+
+	// 每页大小固定，保证分页偏移正确
+	pageSize := c.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > size {
+		pageSize = size
+	}
+
+	result := &Result{Query: query, Fields: fields, Rows: [][]string{}}
+	for page := 1; len(result.Rows) < size; page++ {
+		resp, err := c.fetchPage(ctx, query, page, pageSize, fields)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = resp.Size
+
+		for _, raw := range resp.Results {
+			if len(result.Rows) >= size {
+				break
+			}
+			result.Rows = append(result.Rows, normalizeRow(raw, len(fields)))
+		}
+
+		if len(resp.Results) < pageSize || len(result.Rows) >= result.Total {
+			break
+		}
+	}
+	return result, nil
+}
+
+// fetchPage requests one page of search results
+func (c *Client) fetchPage(ctx context.Context, query string, page, pageSize int, fields []string) (*apiResponse, error) {
+	baseURL := strings.TrimRight(strings.TrimSpace(c.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	params := url.Values{}
+	params.Set("email", c.Email)
+	params.Set("key", c.Key)
+	params.Set("qbase64", base64.StdEncoding.EncodeToString([]byte(query)))
+	params.Set("page", strconv.Itoa(page))
+	params.Set("size", strconv.Itoa(pageSize))
+	params.Set("fields", strings.Join(fields, ","))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+searchPath+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建FOFA请求失败: %w", err)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// 避免在错误信息中泄露key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("请求FOFA失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取FOFA响应失败: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("%w: HTTP %d", ErrAuth, resp.StatusCode)
+	case http.StatusTooManyRequests, http.StatusPaymentRequired:
+		return nil, fmt.Errorf("%w: HTTP %d", ErrQuota, resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("FOFA返回错误状态 HTTP %d: %s", resp.StatusCode, truncate(string(body), maxErrorBodyLen))
+	}
+
+	var apiResp apiResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("解析FOFA响应失败: %w", err)
+	}
+	if apiResp.Error {
+		return nil, classifyError(apiResp.ErrMsg)
+	}
+	return &apiResp, nil
+}
+
+// classifyError maps a FOFA error message to ErrAuth, ErrQuota or a generic error
+func classifyError(msg string) error {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "-700"), strings.Contains(lower, "account invalid"),
+		strings.Contains(lower, "401"), strings.Contains(lower, "unauthorized"),
+		strings.Contains(lower, "key invalid"), strings.Contains(lower, "账号无效"):
+		return fmt.Errorf("%w: %s", ErrAuth, msg)
+	case strings.Contains(lower, "820031"), strings.Contains(lower, "f点"),
+		strings.Contains(lower, "余额不足"), strings.Contains(lower, "limit"),
+		strings.Contains(lower, "exceeded"), strings.Contains(lower, "too many"):
+		return fmt.Errorf("%w: %s", ErrQuota, msg)
+	default:
+		return fmt.Errorf("FOFA查询失败: %s", msg)
+	}
+}
+
+// normalizeRow converts one result to a row of strings.
+// FOFA returns a plain value instead of an array when only one field is requested.
+func normalizeRow(raw json.RawMessage, fieldCount int) []string {
+	var values []any
+	if err := json.Unmarshal(raw, &values); err != nil {
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		values = []any{value}
+	}
+
+	row := make([]string, fieldCount)
+	for i := 0; i < fieldCount && i < len(values); i++ {
+		row[i] = truncate(formatValue(values[i]), maxCellLen)
+	}
+	return row
+}
+
+// formatValue formats a result value as a string
+func formatValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(data)
+	}
+}
+
+// truncate cuts s to at most n characters
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "..."
+}
@@ -0,0 +1,165 @@
+package fofa
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fofaStub is an httptest FOFA API serving total results of two fields
+type fofaStub struct {
+	total    int
+	requests []string // 每次请求的 page/size
+	errmsg   string   // 非空时返回FOFA错误
+	status   int      // 非0时返回该HTTP状态码
+}
+
+func (s *fofaStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.requests = append(s.requests, q.Get("page")+"/"+q.Get("size"))
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if q.Get("email") != "user@example.com" || q.Get("key") != "secret" {
+		json.NewEncoder(w).Encode(map[string]any{"error": true, "errmsg": "[-700] Account Invalid"})
+		return
+	}
+	if s.errmsg != "" {
+		json.NewEncoder(w).Encode(map[string]any{"error": true, "errmsg": s.errmsg})
+		return
+	}
+
+	query, _ := base64.StdEncoding.DecodeString(q.Get("qbase64"))
+	page, _ := strconv.Atoi(q.Get("page"))
+	size, _ := strconv.Atoi(q.Get("size"))
+	fields := strings.Split(q.Get("fields"), ",")
+
+	var results []any
+	for i := (page - 1) * size; i < page*size && i < s.total; i++ {
+		if len(fields) == 1 {
+			results = append(results, fmt.Sprintf("host%d", i))
+			continue
+		}
+		results = append(results, []any{fmt.Sprintf("host%d", i), 8000 + i})
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":   false,
+		"query":   string(query),
+		"page":    page,
+		"size":    s.total,
+		"results": results,
+	})
+}
+
+func newStubClient(t *testing.T, stub *fofaStub) *Client {
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return &Client{BaseURL: server.URL, Email: "user@example.com", Key: "secret", PageSize: 2}
+}
+
+func TestSearchPagination(t *testing.T) {
+	stub := &fofaStub{total: 5}
+	client := newStubClient(t, stub)
+
+	result, err := client.Search(context.Background(), `domain="example.com"`, 10, []string{"host", "port"})
+	require.NoError(t, err)
+	assert.Equal(t, `domain="example.com"`, result.Query)
+	assert.Equal(t, 5, result.Total)
+	assert.Equal(t, []string{"host", "port"}, result.Fields)
+	require.Len(t, result.Rows, 5)
+	assert.Equal(t, []string{"host0", "8000"}, result.Rows[0])
+	assert.Equal(t, []string{"host4", "8004"}, result.Rows[4])
+	assert.Equal(t, []string{"1/2", "2/2", "3/2"}, stub.requests)
+}
+
+func TestSearchStopsAtSize(t *testing.T) {
+	stub := &fofaStub{total: 50}
+	client := newStubClient(t, stub)
+
+	result, err := client.Search(context.Background(), "port=80", 3, []string{"host", "port"})
+	require.NoError(t, err)
+	assert.Equal(t, 50, result.Total)
+	require.Len(t, result.Rows, 3)
+	assert.Equal(t, "host2", result.Rows[2][0])
+	assert.Equal(t, []string{"1/2", "2/2"}, stub.requests)
+}
+
+func TestSearchSingleField(t *testing.T) {
+	client := newStubClient(t, &fofaStub{total: 2})
+
+	result, err := client.Search(context.Background(), "port=80", 10, []string{"host"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"host0"}, {"host1"}}, result.Rows)
+}
+
+func TestSearchErrors(t *testing.T) {
+	ctx := context.Background()
+
+	client := newStubClient(t, &fofaStub{total: 1})
+	client.Key = "wrong"
+	_, err := client.Search(ctx, "port=80", 10, nil)
+	assert.ErrorIs(t, err, ErrAuth)
+	assert.Contains(t, err.Error(), "Account Invalid")
+
+	client = newStubClient(t, &fofaStub{errmsg: "[820031] F点余额不足"})
+	_, err = client.Search(ctx, "port=80", 10, nil)
+	assert.ErrorIs(t, err, ErrQuota)
+
+	client = newStubClient(t, &fofaStub{status: http.StatusUnauthorized})
+	_, err = client.Search(ctx, "port=80", 10, nil)
+	assert.ErrorIs(t, err, ErrAuth)
+
+	client = newStubClient(t, &fofaStub{status: http.StatusTooManyRequests})
+	_, err = client.Search(ctx, "port=80", 10, nil)
+	assert.ErrorIs(t, err, ErrQuota)
+
+	client = newStubClient(t, &fofaStub{errmsg: "[820000] 查询语法错误"})
+	_, err = client.Search(ctx, "port=", 10, nil)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrAuth)
+	assert.NotErrorIs(t, err, ErrQuota)
+	assert.Contains(t, err.Error(), "查询语法错误")
+
+	_, err = (&Client{}).Search(ctx, "port=80", 10, nil)
+	assert.ErrorIs(t, err, ErrNotConfigured)
+
+	_, err = newStubClient(t, &fofaStub{}).Search(ctx, " ", 10, nil)
+	assert.ErrorIs(t, err, ErrQueryEmpty)
+}
+
+func TestSearchTool(t *testing.T) {
+	stub := &fofaStub{total: 20}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	searchTool := NewSearchTool(server.URL, "user@example.com", "secret", 4, nil)
+	info, err := searchTool.Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SearchToolName, info.Name)
+
+	// size超过上限时按上限返回
+	output, err := searchTool.InvokableRun(context.Background(), `{"query":"title=\"<login>\"","size":100,"fields":"host, port"}`)
+	require.NoError(t, err)
+	assert.Contains(t, output, `title=\"<login>\"`, "结果中的HTML字符不应被转义")
+
+	var result Result
+	require.NoError(t, json.Unmarshal([]byte(output), &result))
+	assert.Equal(t, 20, result.Total)
+	assert.Equal(t, []string{"host", "port"}, result.Fields)
+	assert.Len(t, result.Rows, 4)
+
+	unconfigured := NewSearchTool(server.URL, "", "", 0, nil)
+	_, err = unconfigured.InvokableRun(context.Background(), `{"query":"port=80"}`)
+	assert.ErrorIs(t, err, ErrNotConfigured)
+}
@@ -0,0 +1,121 @@
+package fofa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// SearchToolName is the name of the inner tool that queries FOFA
+const SearchToolName = "fofa_search"
+
+// searchToolArgs holds the arguments of fofa_search
+type searchToolArgs struct {
+	Query  string `json:"query"`
+	Size   int    `json:"size"`
+	Fields string `json:"fields"`
+}
+
+// searchTool implements tool.InvokableTool for FOFA searches
+type searchTool struct {
+	client  *Client
+	maxSize int
+}
+
+// NewSearchTool creates the fofa_search tool.
+// The tool is always available so it can be listed and selected; searches fail
+// with ErrNotConfigured until the email and key are set.
+//
+// Parameters:
+//   - baseURL: FOFA API address, DefaultBaseURL if empty
+//   - email: FOFA account email
+//   - key: FOFA API key
+//   - maxSize: Maximum results per search, DefaultMaxSize if <= 0, capped at MaxSizeLimit
+//   - httpClient: HTTP client for API requests, http.DefaultClient if nil
+//
+// Returns:
+//   - tool.InvokableTool: Tool ready to be passed to the agent
+func NewSearchTool(baseURL, email, key string, maxSize int, httpClient *http.Client) tool.InvokableTool {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxSize > MaxSizeLimit {
+		maxSize = MaxSizeLimit
+	}
+	return &searchTool{
+		client: &Client{
+			BaseURL:    baseURL,
+			Email:      email,
+			Key:        key,
+			HTTPClient: httpClient,
+		},
+		maxSize: maxSize,
+	}
+}
+
+// Info implements tool.BaseTool
+func (t *searchTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: SearchToolName,
+		Desc: "search internet assets with the FOFA search engine, returns a JSON table with the total count, the fields and one row per asset",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"query": {
+				Type:     schema.String,
+				Desc:     `FOFA query, for example domain="example.com" or title="login" && country="CN"`,
+				Required: true,
+			},
+			"size": {
+				Type: schema.Integer,
+				Desc: fmt.Sprintf("maximum number of results, default and maximum %d", t.maxSize),
+			},
+			"fields": {
+				Type: schema.String,
+				Desc: fmt.Sprintf("comma separated fields to return, default %s", DefaultFields),
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun implements tool.InvokableTool
+func (t *searchTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args searchToolArgs
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+
+	size := args.Size
+	if size <= 0 || size > t.maxSize {
+		size = t.maxSize
+	}
+
+	result, err := t.client.Search(ctx, args.Query, size, parseFields(args.Fields))
+	if err != nil {
+		return "", err
+	}
+
+	// 标题等字段常包含HTML字符，不做转义以保持结果紧凑
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(result); err != nil {
+		return "", fmt.Errorf("序列化FOFA结果失败: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// parseFields splits a comma separated field list, ignoring empty entries
+func parseFields(fields string) []string {
+	var result []string
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			result = append(result, field)
+		}
+	}
+	return result
+}
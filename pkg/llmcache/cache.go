@@ -0,0 +1,223 @@
+// Package llmcache caches the chat models created from LLM configurations,
+// so that tasks with the same settings share one model and its HTTP
+// connections instead of creating a client for every task.
+//
+// Models are keyed by a hash of the LLM configuration, its fallbacks, the
+// proxy and the request timeout. Configurations reading their API key from
+// a file or a command are never cached, the key may change between tasks.
+package llmcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+)
+
+// DefaultCapacity is the number of models a cache created with capacity 0 keeps
+const DefaultCapacity = 32
+
+// Stats counts the lookups of a cache
+type Stats struct {
+	Hits      uint64 `json:"hits"`      // 命中缓存的次数
+	Misses    uint64 `json:"misses"`    // 创建新模型的次数
+	Evictions uint64 `json:"evictions"` // 因超出容量淘汰的模型数
+	Size      int    `json:"size"`      // 当前缓存的模型数
+}
+
+// entry is a cached model
+type entry struct {
+	key    string
+	llmIDs []string // 主模型和备用模型的配置标识，用于按LLM配置失效
+	model  model.ToolCallingChatModel
+}
+
+// call is a model being built, waited on by concurrent lookups of the same key
+type call struct {
+	done  chan struct{}
+	model model.ToolCallingChatModel
+	err   error
+}
+
+// Cache is a size-limited LRU cache of chat models. It implements
+// config.ModelCache and is safe for concurrent use.
+type Cache struct {
+	capacity int
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List // 最近使用的在前
+	building map[string]*call
+	stats    Stats
+}
+
+// New creates an empty cache.
+//
+// Parameters:
+//   - capacity: Maximum number of models, 0 or less uses DefaultCapacity
+//
+// Returns:
+//   - *Cache: Cache without models
+func New(capacity int) *Cache {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Cache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		building: make(map[string]*call),
+	}
+}
+
+// GetModel returns the model cached for the LLM settings of c. On a miss the
+// model is created with build and cached; concurrent lookups of the same
+// settings wait for that model instead of creating their own. Errors are not
+// cached, the next lookup builds again.
+func (c *Cache) GetModel(ctx context.Context, cfg *config.Config, build config.ModelBuilder) (model.ToolCallingChatModel, error) {
+	key := Key(cfg)
+	if key == "" {
+		return build(ctx)
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		c.stats.Hits++
+		c.mu.Unlock()
+		log.Printf("大模型缓存命中: %s", cfg.LLM.Label())
+		return elem.Value.(*entry).model, nil
+	}
+	if pending, ok := c.building[key]; ok {
+		c.stats.Hits++
+		c.mu.Unlock()
+		<-pending.done
+		return pending.model, pending.err
+	}
+	pending := &call{done: make(chan struct{})}
+	c.building[key] = pending
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	log.Printf("大模型缓存未命中，创建模型: %s", cfg.LLM.Label())
+	pending.model, pending.err = build(ctx)
+
+	c.mu.Lock()
+	delete(c.building, key)
+	if pending.err == nil {
+		c.add(&entry{key: key, llmIDs: llmIDs(cfg.LLM), model: pending.model})
+	}
+	c.mu.Unlock()
+	close(pending.done)
+	return pending.model, pending.err
+}
+
+// add caches e and evicts the least recently used models beyond the capacity
+func (c *Cache) add(e *entry) {
+	c.entries[e.key] = c.order.PushFront(e)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+		c.stats.Evictions++
+	}
+}
+
+// InvalidateLLM removes the models using llm as primary or fallback model,
+// call it when a stored LLM configuration is updated or deleted.
+//
+// Parameters:
+//   - llm: LLM configuration before the change
+//
+// Returns:
+//   - int: Number of models removed
+func (c *Cache) InvalidateLLM(llm config.LLMConfig) int {
+	id := llmID(llm)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		e := elem.Value.(*entry)
+		for _, entryID := range e.llmIDs {
+			if entryID == id {
+				c.order.Remove(elem)
+				delete(c.entries, e.key)
+				removed++
+				break
+			}
+		}
+		elem = next
+	}
+	if removed > 0 {
+		log.Printf("LLM配置已变更，移除 %d 个缓存的模型: %s", removed, llm.Label())
+	}
+	return removed
+}
+
+// Purge removes all models
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Stats returns the lookup counters and the number of cached models
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
+
+// cacheKey holds the settings a model is created from
+type cacheKey struct {
+	LLM               config.LLMConfig `json:"llm"`
+	Proxy             string           `json:"proxy"`
+	LLMRequestTimeout int              `json:"llm_request_timeout"`
+}
+
+// Key returns the cache key of the model of cfg, empty if it must not be
+// cached because an API key is read from a file or a command.
+func Key(cfg *config.Config) string {
+	if cfg.LLM.HasExternalAPIKey() {
+		return ""
+	}
+	for i := range cfg.LLM.Fallbacks {
+		if cfg.LLM.Fallbacks[i].HasExternalAPIKey() {
+			return ""
+		}
+	}
+	return hash(cacheKey{LLM: cfg.LLM, Proxy: cfg.Proxy, LLMRequestTimeout: cfg.LLMRequestTimeout})
+}
+
+// llmIDs returns the identifiers of the primary and fallback models of llm
+func llmIDs(llm config.LLMConfig) []string {
+	ids := []string{llmID(llm)}
+	for _, fallback := range llm.Fallbacks {
+		ids = append(ids, llmID(fallback))
+	}
+	return ids
+}
+
+// llmID identifies a single model configuration, without its fallbacks
+func llmID(llm config.LLMConfig) string {
+	llm.Fallbacks = nil
+	return hash(llm)
+}
+
+// hash returns a hex SHA-256 of the JSON encoding of v, API keys are not kept in the clear
+func hash(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
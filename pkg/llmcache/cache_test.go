@@ -0,0 +1,164 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubModel 是仅用于区分实例的模拟模型
+type stubModel struct {
+	id int64
+}
+
+func (m *stubModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return schema.AssistantMessage("ok", nil), nil
+}
+
+func (m *stubModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{schema.AssistantMessage("ok", nil)}), nil
+}
+
+func (m *stubModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// countingBuilder 返回每次调用都创建新实例的构建函数
+func countingBuilder(builds *int64) config.ModelBuilder {
+	return func(ctx context.Context) (model.ToolCallingChatModel, error) {
+		return &stubModel{id: atomic.AddInt64(builds, 1)}, nil
+	}
+}
+
+func testConfig(apiKey string) *config.Config {
+	return &config.Config{LLM: config.LLMConfig{
+		Type: config.LLMProviderOpenAI, BaseURL: "https://api.example.com/v1", Model: "gpt", APIKey: apiKey,
+	}}
+}
+
+func TestCacheSharesModelsOfTheSameConfig(t *testing.T) {
+	cache := New(0)
+	ctx := context.Background()
+	var builds int64
+
+	first, err := cache.GetModel(ctx, testConfig("key-1"), countingBuilder(&builds))
+	require.NoError(t, err)
+	second, err := cache.GetModel(ctx, testConfig("key-1"), countingBuilder(&builds))
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	// 其他设置相同但API密钥不同时创建新模型
+	rotated, err := cache.GetModel(ctx, testConfig("key-2"), countingBuilder(&builds))
+	require.NoError(t, err)
+	assert.NotSame(t, first, rotated)
+
+	proxied := testConfig("key-1")
+	proxied.Proxy = "http://127.0.0.1:8080"
+	_, err = cache.GetModel(ctx, proxied, countingBuilder(&builds))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), builds)
+	assert.Equal(t, Stats{Hits: 1, Misses: 3, Size: 3}, cache.Stats())
+}
+
+func TestCacheBuildsOnceForConcurrentLookups(t *testing.T) {
+	cache := New(0)
+	var builds int64
+	release := make(chan struct{})
+	build := func(ctx context.Context) (model.ToolCallingChatModel, error) {
+		<-release
+		return &stubModel{id: atomic.AddInt64(&builds, 1)}, nil
+	}
+
+	const lookups = 8
+	models := make([]model.ToolCallingChatModel, lookups)
+	var wg sync.WaitGroup
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m, err := cache.GetModel(context.Background(), testConfig("key"), build)
+			assert.NoError(t, err)
+			models[i] = m
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), builds)
+	for _, m := range models {
+		assert.Same(t, models[0], m)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := New(2)
+	ctx := context.Background()
+	var builds int64
+
+	a, _ := cache.GetModel(ctx, testConfig("a"), countingBuilder(&builds))
+	_, _ = cache.GetModel(ctx, testConfig("b"), countingBuilder(&builds))
+	// a 最近被使用，新模型淘汰 b
+	_, _ = cache.GetModel(ctx, testConfig("a"), countingBuilder(&builds))
+	_, _ = cache.GetModel(ctx, testConfig("c"), countingBuilder(&builds))
+
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, uint64(1), stats.Evictions)
+
+	again, _ := cache.GetModel(ctx, testConfig("a"), countingBuilder(&builds))
+	assert.Same(t, a, again)
+	_, _ = cache.GetModel(ctx, testConfig("b"), countingBuilder(&builds))
+	assert.Equal(t, int64(4), builds)
+}
+
+func TestCacheInvalidateLLM(t *testing.T) {
+	cache := New(0)
+	ctx := context.Background()
+	var builds int64
+
+	withFallback := testConfig("primary")
+	withFallback.LLM.Fallbacks = []config.LLMConfig{testConfig("backup").LLM}
+	_, _ = cache.GetModel(ctx, withFallback, countingBuilder(&builds))
+	_, _ = cache.GetModel(ctx, testConfig("backup"), countingBuilder(&builds))
+	_, _ = cache.GetModel(ctx, testConfig("other"), countingBuilder(&builds))
+
+	// 作为备用模型使用的配置也会失效
+	assert.Equal(t, 2, cache.InvalidateLLM(testConfig("backup").LLM))
+	assert.Equal(t, 1, cache.Stats().Size)
+
+	cache.Purge()
+	assert.Zero(t, cache.Stats().Size)
+}
+
+func TestCacheSkipsExternalAPIKeysAndErrors(t *testing.T) {
+	cache := New(0)
+	ctx := context.Background()
+	var builds int64
+
+	external := testConfig("")
+	external.LLM.APIKeyCmd = "print-key"
+	first, _ := cache.GetModel(ctx, external, countingBuilder(&builds))
+	second, _ := cache.GetModel(ctx, external, countingBuilder(&builds))
+	assert.NotSame(t, first, second)
+	assert.Zero(t, cache.Stats().Size)
+
+	failure := errors.New("无法连接")
+	_, err := cache.GetModel(ctx, testConfig("key"), func(ctx context.Context) (model.ToolCallingChatModel, error) {
+		return nil, failure
+	})
+	assert.ErrorIs(t, err, failure)
+	m, err := cache.GetModel(ctx, testConfig("key"), countingBuilder(&builds))
+	require.NoError(t, err)
+	assert.NotNil(t, m)
+}
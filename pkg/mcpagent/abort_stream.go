@@ -0,0 +1,104 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// abortableModel ends the streams of the wrapped model as soon as the context
+// of the request is done. Each request runs under its own CancelFunc, which
+// aborts the HTTP request of the provider when the task is cancelled or the
+// reader is closed early, and the returned reader fails with the context
+// error without waiting for the provider to notice.
+type abortableModel struct {
+	model.ToolCallingChatModel
+}
+
+// withAbortableStream wraps chatModel with abortableModel
+func withAbortableStream(chatModel model.ToolCallingChatModel) model.ToolCallingChatModel {
+	if _, ok := chatModel.(*abortableModel); ok {
+		return chatModel
+	}
+	return &abortableModel{ToolCallingChatModel: chatModel}
+}
+
+// WithTools binds tools to the wrapped model
+func (m *abortableModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.ToolCallingChatModel.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &abortableModel{ToolCallingChatModel: bound}, nil
+}
+
+// Stream sends the request under a cancellable context, see abortableStream
+func (m *abortableModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	upstream, err := m.ToolCallingChatModel.Stream(streamCtx, input, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return abortableStream(ctx, upstream, cancel), nil
+}
+
+// streamItem is a chunk or error received from a stream
+type streamItem[T any] struct {
+	chunk T
+	err   error
+}
+
+// abortableStream forwards upstream to the returned reader until ctx is done.
+// Then the reader fails with ctx.Err() right away, while the upstream reader
+// is closed and cancel is called so the sender of upstream stops. cancel is
+// also called when upstream ends or the returned reader is closed.
+func abortableStream[T any](ctx context.Context, upstream *schema.StreamReader[T], cancel context.CancelFunc) *schema.StreamReader[T] {
+	reader, writer := schema.Pipe[T](1)
+	closeUpstream := sync.OnceFunc(upstream.Close)
+	items := make(chan streamItem[T])
+	done := make(chan struct{})
+
+	// Recv不响应ctx，在单独的goroutine中读取，取消时不必等待它返回
+	go func() {
+		defer closeUpstream()
+		for {
+			chunk, err := upstream.Recv()
+			select {
+			case items <- streamItem[T]{chunk, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer writer.Close()
+		defer closeUpstream()
+		defer cancel()
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				var zero T
+				writer.Send(zero, ctx.Err())
+				return
+			case item := <-items:
+				if errors.Is(item.err, io.EOF) {
+					return
+				}
+				if closed := writer.Send(item.chunk, item.err); closed || item.err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return reader
+}
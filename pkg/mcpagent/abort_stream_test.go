@@ -0,0 +1,259 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingStreamModel 返回一个分块后停住的流，模拟不响应ctx的服务商
+type blockingStreamModel struct {
+	stepRecordingModel
+	streamCtx chan context.Context // 每次请求的ctx
+	release   chan struct{}        // 关闭后发送方尝试继续发送
+	closed    chan bool            // 继续发送时读取方是否已关闭
+}
+
+func newBlockingStreamModel() *blockingStreamModel {
+	return &blockingStreamModel{
+		streamCtx: make(chan context.Context, 1),
+		release:   make(chan struct{}),
+		closed:    make(chan bool, 1),
+	}
+}
+
+func (m *blockingStreamModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	m.streamCtx <- ctx
+	reader, writer := schema.Pipe[*schema.Message](0)
+	go func() {
+		defer writer.Close()
+		writer.Send(schema.AssistantMessage("first", nil), nil)
+		<-m.release
+		m.closed <- writer.Send(schema.AssistantMessage("second", nil), nil)
+	}()
+	return reader, nil
+}
+
+func (m *blockingStreamModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestAbortableStreamCancel(t *testing.T) {
+	blocking := newBlockingStreamModel()
+	chatModel := withAbortableStream(blocking)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := chatModel.Stream(ctx, []*schema.Message{schema.UserMessage("hi")})
+	require.NoError(t, err)
+	defer stream.Close()
+	streamCtx := <-blocking.streamCtx
+
+	chunk, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "first", chunk.Content)
+
+	// 取消后立即返回ctx的错误，不等待服务商
+	cancel()
+	received := make(chan error, 1)
+	go func() {
+		_, err := stream.Recv()
+		received <- err
+	}()
+	select {
+	case err := <-received:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("取消后流没有结束")
+	}
+	assert.Error(t, streamCtx.Err())
+
+	// 服务商的流已被关闭，继续发送时立即失败
+	close(blocking.release)
+	select {
+	case closed := <-blocking.closed:
+		assert.True(t, closed)
+	case <-time.After(time.Second):
+		t.Fatal("服务商的流没有关闭")
+	}
+}
+
+func TestAbortableStreamCloseCancelsRequest(t *testing.T) {
+	blocking := newBlockingStreamModel()
+	bound, err := withAbortableStream(blocking).WithTools(nil)
+	require.NoError(t, err)
+
+	stream, err := bound.Stream(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	require.NoError(t, err)
+	streamCtx := <-blocking.streamCtx
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	// 读取方提前关闭时取消服务商的请求
+	stream.Close()
+	close(blocking.release)
+	<-blocking.closed
+	select {
+	case <-streamCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("关闭流后请求没有取消")
+	}
+}
+
+// streamRecordingNotify 记录收到的流式片段
+type streamRecordingNotify struct {
+	*MockNotify
+	mu       sync.Mutex
+	received []string
+}
+
+func (n *streamRecordingNotify) OnStreamResult(chunk string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.received = append(n.received, chunk)
+}
+
+func (n *streamRecordingNotify) chunks() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.received...)
+}
+
+func TestHandleStreamOutputStopsOnCancel(t *testing.T) {
+	notify := &streamRecordingNotify{MockNotify: new(MockNotify)}
+	callback := &LoggerCallback{notify: notify}
+	reader, writer := schema.Pipe[callbacks.CallbackOutput](1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		callback.handleStreamOutput(ctx, &callbacks.RunInfo{Name: "model"}, reader)
+		close(done)
+	}()
+	require.False(t, writer.Send(schema.AssistantMessage("first", nil), nil))
+
+	// 取消后不再读取
+	cancel()
+	writer.Send(schema.AssistantMessage("second", nil), nil)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("取消后handleStreamOutput没有返回")
+	}
+	// 回调不转发片段，片段只从agent的输出流发送一次
+	assert.Empty(t, notify.chunks())
+	// 读取方已关闭，发送方不会被阻塞
+	assert.True(t, writer.Send(schema.AssistantMessage("third", nil), nil))
+}
+
+func TestAgentStreamsAnswerChunks(t *testing.T) {
+	ctx := context.Background()
+	chatModel := &scriptedChatModel{replies: []string{"hello world"}}
+	mockConfig := newLifecycleMockConfig(chatModel, func() {})
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	notify := &streamRecordingNotify{MockNotify: new(MockNotify)}
+	notify.On("OnMessage", mock.Anything).Maybe()
+	var beforeResult []string
+	notify.On("OnResult", "hello world").Run(func(mock.Arguments) {
+		beforeResult = notify.chunks()
+	}).Once()
+	require.NoError(t, agent.Execute(ctx, "task", notify))
+
+	// 最终回答的每个片段只发送一次，都在OnResult之前
+	notify.AssertExpectations(t)
+	assert.Equal(t, []string{"hello", " world"}, beforeResult)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"hello", " world"}, notify.chunks())
+}
+
+// TestAgentWithholdsChunksWithOutputProcessing 验证配置了脱敏规则时，未脱敏的片段不会发送给OnStreamResult
+func TestAgentWithholdsChunksWithOutputProcessing(t *testing.T) {
+	ctx := context.Background()
+	chatModel := &scriptedChatModel{replies: []string{"token: sk-abc123def"}}
+	mockConfig := newLifecycleMockConfig(chatModel, func() {})
+	mockConfig.Config.Output.Redact = []config.RedactRule{{Pattern: `sk-[a-z0-9]+`, Replacement: "***"}}
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	notify := &streamRecordingNotify{MockNotify: new(MockNotify)}
+	notify.On("OnMessage", mock.Anything).Maybe()
+	notify.On("OnResult", "token: ***").Once()
+	require.NoError(t, agent.Execute(ctx, "task", notify))
+
+	notify.AssertExpectations(t)
+	for _, chunk := range notify.chunks() {
+		assert.NotContains(t, chunk, "sk-")
+		assert.NotContains(t, chunk, "abc123def")
+	}
+	assert.Empty(t, notify.chunks())
+}
+
+// TestAgentWithholdsChunksWithOutputSchema 验证配置了输出Schema时，修复轮次的回答不会作为片段发送
+func TestAgentWithholdsChunksWithOutputSchema(t *testing.T) {
+	chatModel := &scriptedChatModel{replies: []string{`{"name": "Bob"}`, `{"name": "Bob", "score": 7}`}}
+	notify := &streamRecordingNotify{MockNotify: newResultNotify()}
+	require.NoError(t, runWithOutputSchema(t, chatModel, 0, notify))
+
+	notify.AssertCalled(t, "OnResult", `{"name": "Bob", "score": 7}`)
+	assert.Empty(t, notify.chunks())
+}
+
+// TestProviderStreamsAbortOnCancel 验证openai和ollama组件在ctx取消时中止HTTP请求
+func TestProviderStreamsAbortOnCancel(t *testing.T) {
+	tests := []struct {
+		provider string
+		chunk    string
+	}{
+		{config.LLMProviderOpenAI, `data: {"id":"1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"first"}}]}` + "\n\n"},
+		{config.LLMProviderOllama, `{"model":"m","message":{"role":"assistant","content":"first"},"done":false}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			aborted := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.chunk)
+				w.(http.Flusher).Flush()
+				// 不再发送，直到客户端中止请求
+				<-r.Context().Done()
+				close(aborted)
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{LLM: config.LLMConfig{Type: tt.provider, BaseURL: server.URL, Model: "m", APIKey: "test"}}
+			chatModel, err := cfg.GetModel(context.Background())
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := withAbortableStream(chatModel).Stream(ctx, []*schema.Message{schema.UserMessage("hi")})
+			require.NoError(t, err)
+			defer stream.Close()
+			chunk, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, "first", chunk.Content)
+
+			cancel()
+			_, err = stream.Recv()
+			assert.ErrorIs(t, err, context.Canceled)
+			select {
+			case <-aborted:
+			case <-time.After(2 * time.Second):
+				t.Fatal("取消后HTTP请求没有中止")
+			}
+		})
+	}
+}
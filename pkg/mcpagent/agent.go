@@ -15,6 +15,9 @@
 //	if err != nil {
 //		log.Fatal(err)
 //	}
+//
+// To execute many tasks without reconnecting MCP servers for each of them,
+// create an Agent with New and call Execute for every task.
 package mcpagent
 
 import (
@@ -27,8 +30,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/LubyRuffy/mcpagent/pkg/attachment"
 	"github.com/LubyRuffy/mcpagent/pkg/config"
 	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/prompt"
 	"github.com/cloudwego/eino/components/tool"
@@ -69,8 +74,19 @@ const (
 	errMsgGenerateOutFailed = "生成输出失败: %w"
 	errMsgSerializeFrame    = "序列化流帧失败: %w"
 	errMsgStreamFailed      = "流处理失败: %w"
+	errMsgTaskTimeout       = "%w（%v）: %v"
+	errMsgHistoryInvalid    = "历史消息[%d]无效：只能是用户或助手消息，或之前工具调用的结果"
+	errMsgHistoryNoResult   = "历史消息[%d]无效：工具调用 %s 没有结果"
+	errMsgConversationTurn  = "对话的最后一条消息必须是用户消息"
+	errMsgPlanFailed        = "生成执行计划失败: %w"
+	errMsgPlanEmpty         = "生成执行计划失败: 大模型返回了空计划"
+	errMsgPlanNoApprover    = "plan_approval要求批准执行计划，但当前的运行方式无法请求批准"
+	errMsgPlanApproval      = "等待批准执行计划失败: %w"
 )
 
+// ErrTaskTimeout is returned by Run when the task exceeds config.Config.TaskTimeout
+var ErrTaskTimeout = errors.New("任务执行超时")
+
 // Notify defines the interface for handling various types of notifications
 // during agent execution. Implementations should handle these notifications
 // appropriately for their context (CLI, web UI, etc.).
@@ -92,6 +108,11 @@ type Notify interface {
 	// OnToolCall sends a tool call notification during execution
 	OnToolCall(toolName string, params any)
 
+	// OnToolCallResult sends the result of a tool call when the tool returns,
+	// with the duration of the call. err is non-nil if the tool failed, result
+	// is empty in that case.
+	OnToolCallResult(toolName string, result string, err error, duration time.Duration)
+
 	// OnResult sends a result notification when the agent completes successfully
 	// This contains the final output from the agent
 	OnResult(msg string)
@@ -103,11 +124,13 @@ type Notify interface {
 
 // StreamingNotify extends Notify interface with streaming capabilities
 // allowing handlers to process streaming data chunks as they arrive.
+// Tasks of a StreamingNotify use the streaming API of the model.
 type StreamingNotify interface {
 	Notify
 
-	// OnStreamResult receives a chunk of the result stream during execution
-	// This is called for each chunk of the streaming response
+	// OnStreamResult receives a chunk of the final answer while it is generated
+	// This is called once for each chunk, OnResult still receives the whole
+	// result after the last chunk
 	OnStreamResult(chunk string)
 }
 
@@ -121,9 +144,21 @@ type StreamingNotify interface {
 //
 // This callback is designed to be thread-safe and can handle concurrent
 // operations from the agent framework.
+// Without a notification handler the callback does nothing, and a panic of the
+// handler is logged and recovered so it cannot abort the task.
 type LoggerCallback struct {
-	notify                   Notify // Notification handler for user feedback
-	callbacks.HandlerBuilder        // Embedded handler builder for callback implementation
+	notify                   Notify      // Notification handler for user feedback
+	messages                 *Messages   // Catalog of user-facing strings, Chinese when nil
+	calls                    callTracker // Pending tool calls used to correlate results
+	callbacks.HandlerBuilder             // Embedded handler builder for callback implementation
+}
+
+// catalog returns the message catalog of the callback
+func (cb *LoggerCallback) catalog() *Messages {
+	if cb.messages == nil {
+		return MessagesFor("")
+	}
+	return cb.messages
 }
 
 // OnStart is called when a callback operation starts. It processes tool calls
@@ -133,6 +168,8 @@ type LoggerCallback struct {
 //
 // The method filters messages to only process assistant messages with tool calls,
 // ensuring that only relevant tool execution events trigger notifications.
+// When a tool starts, its start time is kept in the context so that the
+// result can report the duration of the call.
 //
 // Parameters:
 //   - ctx: Context for the operation
@@ -140,8 +177,17 @@ type LoggerCallback struct {
 //   - input: Input data for the callback (expected to be a schema.Message)
 //
 // Returns:
-//   - context.Context: The same context (no modifications)
+//   - context.Context: The context, with the start time for tools
 func (cb *LoggerCallback) OnStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
+	defer recoverCallback("OnStart")
+	if cb.notify == nil {
+		return ctx
+	}
+	if info != nil && info.Component == components.ComponentOfTool {
+		// 工具调用的开始时间，OnEnd和OnError据此计算耗时
+		return context.WithValue(ctx, toolStartKey{}, time.Now())
+	}
+
 	message, ok := input.(*schema.Message)
 	if !ok {
 		return ctx
@@ -185,6 +231,12 @@ func (cb *LoggerCallback) handleSingleToolCall(toolCall schema.ToolCall) error {
 		return fmt.Errorf(errMsgParseArgsFailed, err)
 	}
 
+	if timelineNotify, ok := cb.notify.(TimelineNotify); ok {
+		callID := cb.calls.start(toolCall)
+		cb.handleTimelineTool(timelineNotify, callID, toolCall.Function.Name, arguments)
+		return nil
+	}
+
 	cb.handleGenericTool(toolCall.Function.Name, arguments)
 
 	return nil
@@ -192,7 +244,7 @@ func (cb *LoggerCallback) handleSingleToolCall(toolCall schema.ToolCall) error {
 
 // parseToolArguments parses the JSON arguments of a tool call.
 // It converts the JSON string to a map for easier processing and handles
-// empty or malformed JSON gracefully.
+// empty or malformed JSON gracefully. Numbers are kept as json.Number.
 //
 // Parameters:
 //   - arguments: JSON string containing tool arguments
@@ -206,11 +258,7 @@ func (cb *LoggerCallback) parseToolArguments(arguments string) (map[string]any,
 		return make(map[string]interface{}), nil
 	}
 
-	var parsedArgs map[string]interface{}
-	if err := json.Unmarshal([]byte(argStr), &parsedArgs); err != nil {
-		return nil, err
-	}
-	return parsedArgs, nil
+	return decodeArguments(argStr)
 }
 
 // handleThinkingTool handles the sequential thinking tool.
@@ -241,11 +289,11 @@ func (cb *LoggerCallback) handleGenericTool(toolName string, arguments map[strin
 	cb.handleThinkingTool(arguments)
 
 	// Then notify about the tool execution
-	cb.notify.OnToolCall(toolName, arguments)
+	cb.notify.OnToolCall(toolName, cb.catalog().CanonicalizeArguments(arguments))
 }
 
 // OnEnd is called when a callback operation ends successfully.
-// Currently, no specific handling is needed for successful completion.
+// Tool results are reported to TimelineNotify handlers.
 //
 // Parameters:
 //   - ctx: Context for the operation
@@ -255,13 +303,12 @@ func (cb *LoggerCallback) handleGenericTool(toolName string, arguments map[strin
 // Returns:
 //   - context.Context: The same context (no modifications)
 func (cb *LoggerCallback) OnEnd(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
-	// For message output, notify with content
-	if message, ok := output.(*schema.Message); ok && message.Role == schema.Assistant && message.Content != "" {
-		// Check if we have a streaming notify interface
-		if streamNotify, ok := cb.notify.(StreamingNotify); ok {
-			streamNotify.OnStreamResult(message.Content)
-		}
+	defer recoverCallback("OnEnd")
+	if cb.notify == nil {
+		return ctx
 	}
+
+	cb.reportToolResult(ctx, info, output, nil)
 	return ctx
 }
 
@@ -276,13 +323,19 @@ func (cb *LoggerCallback) OnEnd(ctx context.Context, info *callbacks.RunInfo, ou
 // Returns:
 //   - context.Context: The same context (no modifications)
 func (cb *LoggerCallback) OnError(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+	defer recoverCallback("OnError")
+	if cb.notify == nil {
+		return ctx
+	}
+
+	cb.reportToolResult(ctx, info, nil, err)
 	cb.notify.OnError(err)
 	return ctx
 }
 
 // OnEndWithStreamOutput handles the end of streaming output operations.
 // It processes streaming output in a separate goroutine to avoid blocking
-// the main execution flow.
+// the main execution flow, until the output ends or ctx is done.
 //
 // Parameters:
 //   - ctx: Context for the operation
@@ -294,33 +347,40 @@ func (cb *LoggerCallback) OnError(ctx context.Context, info *callbacks.RunInfo,
 func (cb *LoggerCallback) OnEndWithStreamOutput(ctx context.Context, info *callbacks.RunInfo,
 	output *schema.StreamReader[callbacks.CallbackOutput]) context.Context {
 
-	go cb.handleStreamOutput(info, output)
+	go cb.handleStreamOutput(ctx, info, output)
 	return ctx
 }
 
 // handleStreamOutput processes streaming output in a separate goroutine.
-// It reads from the stream until EOF and processes each frame.
-// The method includes panic recovery to ensure stability.
+// It reads from the stream until EOF and processes each frame. When ctx is
+// done, e.g. the task was cancelled, it stops and closes the stream without
+// forwarding the remaining frames. The method includes panic recovery to
+// ensure stability.
 //
 // Parameters:
+//   - ctx: Context of the operation
 //   - info: Runtime information about the callback
 //   - output: Stream reader for callback output
-func (cb *LoggerCallback) handleStreamOutput(info *callbacks.RunInfo, output *schema.StreamReader[callbacks.CallbackOutput]) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("[StreamOutput] 恢复从panic: %v", err)
-		}
-	}()
+func (cb *LoggerCallback) handleStreamOutput(ctx context.Context, info *callbacks.RunInfo, output *schema.StreamReader[callbacks.CallbackOutput]) {
+	defer recoverCallback("OnEndWithStreamOutput")
 
 	defer output.Close()
 
 	for {
+		if ctx.Err() != nil {
+			return
+		}
 		frame, err := output.Recv()
 		if errors.Is(err, io.EOF) {
 			break
 		}
 		if err != nil {
-			log.Printf("流输出内部错误: %v", err)
+			if ctx.Err() == nil {
+				log.Printf("流输出内部错误: %v", err)
+			}
+			return
+		}
+		if ctx.Err() != nil {
 			return
 		}
 
@@ -331,8 +391,10 @@ func (cb *LoggerCallback) handleStreamOutput(info *callbacks.RunInfo, output *sc
 }
 
 // processStreamFrame processes a single frame from the stream output.
-// It handles different types of frames including messages and tool calls.
-// For messages, it extracts the content and notifies through the streaming interface.
+// Frames of the agent graph are logged for debugging. The chunks of the
+// final answer reach StreamingNotify handlers from the stream of the agent
+// instead, see generateAgentOutput, so every chunk is sent once and before
+// the final result.
 //
 // Parameters:
 //   - info: Runtime information about the callback
@@ -341,24 +403,13 @@ func (cb *LoggerCallback) handleStreamOutput(info *callbacks.RunInfo, output *sc
 // Returns:
 //   - error: Error if frame processing fails
 func (cb *LoggerCallback) processStreamFrame(info *callbacks.RunInfo, frame callbacks.CallbackOutput) error {
-	// Check if we have a streaming notify interface for more granular notifications
-	streamNotify, isStreamingNotify := cb.notify.(StreamingNotify)
-
-	// Handle message streaming
-	if message, ok := frame.(*schema.Message); ok && message.Role == schema.Assistant {
-		// Send stream chunks to the streaming notifier if available
-		if isStreamingNotify && message.Content != "" {
-			streamNotify.OnStreamResult(message.Content)
-		}
-	}
-
 	// Debug logging
 	if info.Name == react.GraphName {
 		frameData, err := json.Marshal(frame)
 		if err != nil {
 			return fmt.Errorf(errMsgSerializeFrame, err)
 		}
-		fmt.Printf("%s: %s\n", info.Name, string(frameData))
+		log.Printf("%s: %s", info.Name, string(frameData))
 	}
 
 	return nil
@@ -376,7 +427,9 @@ func (cb *LoggerCallback) processStreamFrame(info *callbacks.RunInfo, frame call
 //   - context.Context: The same context (no modifications)
 func (cb *LoggerCallback) OnStartWithStreamInput(ctx context.Context, info *callbacks.RunInfo,
 	input *schema.StreamReader[callbacks.CallbackInput]) context.Context {
-	defer input.Close()
+	if input != nil {
+		input.Close()
+	}
 	return ctx
 }
 
@@ -390,7 +443,22 @@ func (cb *LoggerCallback) OnStartWithStreamInput(ctx context.Context, info *call
 //  4. Task execution with proper error handling
 //
 // The function ensures proper resource cleanup and provides comprehensive error
-// reporting through the notification interface.
+// reporting through the notification interface. It is equivalent to New,
+// Agent.Execute and Agent.Close, so every call connects the MCP servers again.
+//
+// If cfg.TaskTimeout is set, the whole task runs under that deadline. A task
+// that exceeds it returns an error wrapping ErrTaskTimeout, which is also sent
+// to notify.OnError as the final notification.
+//
+// If cfg.MaxDuration is set, the task also has a time budget that applies
+// together with cfg.MaxStep. The time left is checked before every step:
+// below cfg.MaxDurationWrapUp of the budget the model is asked to answer
+// with what it has, and without time left the task stops with an error
+// wrapping ErrMaxDurationExceeded. The steps and time used are sent as a
+// message when the task ends.
+//
+// If cfg.MCP.ToolsAutoSelect is set and no tools are selected, the tools are
+// chosen from the task description first, see AutoSelectTools.
 //
 // Parameters:
 //   - ctx: Context for controlling execution flow and cancellation
@@ -414,27 +482,162 @@ func Run(ctx context.Context, cfg *config.Config, task string, notify Notify) er
 		return err
 	}
 
-	// 获取工具
-	einoTools, cleanup, err := cfg.GetTools(ctx)
-	if err != nil {
-		return fmt.Errorf(errMsgGetToolsFailed, err)
+	cfg, _ = AutoSelectTools(ctx, cfg, task, notify)
+	return runWithTaskTimeout(ctx, cfg, notify, func(ctx context.Context) error {
+		agent, err := New(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer agent.Close()
+
+		return agent.execute(ctx, task, notify)
+	})
+}
+
+// RunWithHistory executes task as a follow-up of an earlier conversation.
+// It behaves like Run, but the messages of history are sent to the model
+// between the system prompt and task, so the model can refer to earlier
+// questions and answers. History may contain the tool calls and results of
+// a Checkpoint, so that an interrupted task can be resumed.
+//
+// Parameters:
+//   - ctx: Context for controlling execution flow and cancellation
+//   - cfg: Configuration containing model, tool, and system settings
+//   - history: Earlier messages, oldest first, see validateHistory
+//   - task: Follow-up instruction to execute (must not be empty)
+//   - notify: Notification handler for progress updates and results
+//
+// Returns:
+//   - error: Error if the history is invalid or execution fails
+func RunWithHistory(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify Notify) error {
+	if err := validateRunParameters(cfg, task, notify); err != nil {
+		return err
+	}
+	if err := validateHistory(history); err != nil {
+		return err
 	}
-	defer cleanup()
 
-	// 获取模型
-	toolableChatModel, err := cfg.GetModel(ctx)
-	if err != nil {
-		return fmt.Errorf(errMsgGetModelFailed, err)
+	cfg, _ = AutoSelectTools(ctx, cfg, task, notify)
+	return runWithTaskTimeout(ctx, cfg, notify, func(ctx context.Context) error {
+		agent, err := New(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer agent.Close()
+
+		_, err = agent.executeConversation(ctx, nil, history, task, notify)
+		return err
+	})
+}
+
+// RunConversation answers the last message of messages as the next turn of
+// a chat. It behaves like RunWithHistory with the earlier messages as the
+// history and the content of the last message, which must be a user
+// message, as the task. The returned assistant message holds the answer sent
+// to notify.OnResult; append it to messages for the next turn. See Session
+// to keep the history and the agent between turns.
+//
+// Parameters:
+//   - ctx: Context for controlling execution flow and cancellation
+//   - cfg: Configuration containing model, tool, and system settings
+//   - messages: Earlier messages, oldest first, followed by the new user message
+//   - notify: Notification handler for progress updates and results
+//
+// Returns:
+//   - *schema.Message: Assistant message with the answer
+//   - error: Error if the messages are invalid or execution fails
+func RunConversation(ctx context.Context, cfg *config.Config, messages []*schema.Message, notify Notify) (*schema.Message, error) {
+	last := len(messages) - 1
+	if last < 0 || messages[last] == nil || messages[last].Role != schema.User {
+		return nil, errors.New(errMsgConversationTurn)
+	}
+	history, task := messages[:last], messages[last].Content
+	if err := validateRunParameters(cfg, task, notify); err != nil {
+		return nil, err
+	}
+	if err := validateHistory(history); err != nil {
+		return nil, err
 	}
 
-	// 创建agent
-	ragent, err := createReActAgent(ctx, cfg, einoTools, toolableChatModel)
+	cfg, _ = AutoSelectTools(ctx, cfg, task, notify)
+	var answer *schema.Message
+	err := runWithTaskTimeout(ctx, cfg, notify, func(ctx context.Context) error {
+		agent, err := New(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer agent.Close()
+
+		answer, err = agent.executeConversation(ctx, nil, history, task, notify)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf(errMsgCreateAgentFailed, err)
+		return nil, err
+	}
+	return answer, nil
+}
+
+// validateHistory ensures the history only contains user and assistant
+// messages, and tool results answering the tool calls of the assistant message
+// before them. Every tool call must be answered before the next message, as
+// in the checkpoints of an interrupted task.
+func validateHistory(history []*schema.Message) error {
+	pending := make(map[string]bool)
+	for i, msg := range history {
+		if msg == nil {
+			return fmt.Errorf(errMsgHistoryInvalid, i)
+		}
+		if msg.Role == schema.Tool {
+			if !pending[msg.ToolCallID] {
+				return fmt.Errorf(errMsgHistoryInvalid, i)
+			}
+			delete(pending, msg.ToolCallID)
+			continue
+		}
+		if msg.Role != schema.User && msg.Role != schema.Assistant {
+			return fmt.Errorf(errMsgHistoryInvalid, i)
+		}
+		for id := range pending {
+			return fmt.Errorf(errMsgHistoryNoResult, i, id)
+		}
+		if msg.Role == schema.Assistant {
+			for _, call := range msg.ToolCalls {
+				pending[call.ID] = true
+			}
+		}
+	}
+	for id := range pending {
+		return fmt.Errorf(errMsgHistoryNoResult, len(history), id)
+	}
+	return nil
+}
+
+// runWithTaskTimeout runs fn under cfg.TaskTimeout. When the deadline is exceeded
+// the error wraps ErrTaskTimeout and is sent to notify.OnError.
+func runWithTaskTimeout(ctx context.Context, cfg *config.Config, notify Notify, fn func(ctx context.Context) error) error {
+	timeout := cfg.TaskTimeoutDuration()
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	// 设置任务整体超时
+	taskCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(taskCtx)
+	if err != nil && errors.Is(taskCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf(errMsgTaskTimeout, ErrTaskTimeout, timeout, err)
+		notify.OnError(err)
 	}
+	return err
+}
 
-	// 执行任务
-	return executeAgentTask(ctx, cfg, ragent, task, notify)
+// notifyMCPMergeWarnings reports MCP servers defined differently in the config file
+// and inline mcp_servers, so users editing the file learn which definition is used
+func notifyMCPMergeWarnings(cfg *config.Config, notify Notify) {
+	for _, warning := range cfg.MCP.MergeWarnings() {
+		notify.OnMessage(messagesOf(cfg).WarningPrefix + warning)
+	}
 }
 
 // RunStream executes an MCP Agent task with streaming response capabilities.
@@ -481,8 +684,13 @@ func RunStream(ctx context.Context, cfg *config.Config, task string, notify Stre
 		return nil, err
 	}
 
+	notifyMCPMergeWarnings(cfg, notify)
+	cfg, _ = AutoSelectTools(ctx, cfg, task, notify)
+
 	// 获取工具
-	einoTools, cleanup, err := cfg.GetTools(ctx)
+	einoTools, cleanup, err := cfg.GetTools(config.WithMissingToolsHandler(ctx, func(missing []config.MissingTool) {
+		notifyMissingTools(cfg, notify, missing)
+	}))
 	if err != nil {
 		return nil, fmt.Errorf(errMsgGetToolsFailed, err)
 	}
@@ -497,12 +705,25 @@ func RunStream(ctx context.Context, cfg *config.Config, task string, notify Stre
 		}
 	}
 
-	// 获取模型
+	// 获取模型，流式输出期间切换备用模型时通过notify发送消息
+	ctx, _ = trackModels(ctx, cfg, notify)
+	ctx = withResultFilterMessages(ctx, cfg, notify)
+	ctx = withSanitizerMessages(ctx, cfg, notify)
+	ctx = withNotes(ctx, cfg, notify)
+	ctx, _ = withTimeBudget(ctx, cfg, notify)
 	toolableChatModel, err := cfg.GetModel(ctx)
 	if err != nil {
 		cleanup() // Ensure cleanup if we fail here
 		return nil, fmt.Errorf(errMsgGetModelFailed, err)
 	}
+	if err := checkToolSupport(ctx, cfg, toolableChatModel, einoTools); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := checkToolsRequired(ctx, cfg, toolableChatModel, einoTools, task, notify); err != nil {
+		cleanup()
+		return nil, err
+	}
 
 	// 创建agent
 	ragent, err := createReActAgent(ctx, cfg, einoTools, toolableChatModel)
@@ -512,40 +733,31 @@ func RunStream(ctx context.Context, cfg *config.Config, task string, notify Stre
 	}
 
 	// 创建并格式化消息
-	chatTemplate := prompt.FromMessages(schema.FString,
-		&schema.Message{
-			Role:    schema.System,
-			Content: cfg.SystemPrompt,
-		},
-		&schema.Message{
-			Role:    schema.User,
-			Content: task,
-		})
-
-	// 格式化消息
-	placeHolders := map[string]any{
-		"date": time.Now().Format("2006-01-02"),
-	}
-	for k, v := range cfg.PlaceHolders {
-		placeHolders[k] = v
-	}
-
-	msg, err := chatTemplate.Format(ctx, placeHolders)
+	msg, err := formatConversation(ctx, cfg, nil, task)
 	if err != nil {
 		cleanup() // Ensure cleanup if we fail here
-		return nil, fmt.Errorf(errMsgFormatMsgFailed, err)
+		return nil, err
 	}
 
 	// 生成流输出
 	streamOutput, err := ragent.Stream(ctx, msg, agent.WithComposeOptions(
-		compose.WithCallbacks(&LoggerCallback{
-			notify: notify,
-		})))
+		compose.WithCallbacks(buildCallbackHandlers(cfg, notify)...)))
 	if err != nil {
 		cleanup() // Ensure cleanup if we fail here
 		return nil, fmt.Errorf(errMsgStreamFailed, err)
 	}
 
+	// Chunks are sent to notify as the caller reads them, unless output
+	// processing could still change the answer
+	if streamsAnswerChunks(cfg) {
+		streamOutput = schema.StreamReaderWithConvert(streamOutput, func(chunk *schema.Message) (*schema.Message, error) {
+			if chunk.Content != "" {
+				notify.OnStreamResult(chunk.Content)
+			}
+			return chunk, nil
+		})
+	}
+
 	// Simply return the stream output with deferred cleanup handling
 	// The caller is responsible for closing the stream
 	go func() {
@@ -590,22 +802,42 @@ func validateRunParameters(cfg *config.Config, task string, notify Notify) error
 // The agent is configured with a step multiplier to allow for complex reasoning
 // that may require multiple iterations.
 //
+// An empty tool list is supported: eino then uses the model without binding
+// tools, so the model answers directly after a single call.
+//
+// With summarize context compression, long tool results are replaced by
+// summaries and the expand_result tool is added. With agent.require_think
+// every tool asks the model for a think parameter. A panicking tool returns a
+// result describing the failure instead of aborting the task. With
+// security.sanitize_tool_output the results of all tools are marked as
+// untrusted data before anything else sees them. With agent.verify_result
+// the results are also collected to check the final answer. With
+// agent.first_tool the first request asks for that tool, and with
+// agent.allowed_tools_per_step tool calls beyond the limit of a step are
+// rejected. With agent.enable_notes the note tools of the store in ctx are
+// added and every request lists the keys of the saved notes. With the time
+// budget of max_duration in ctx every request checks the time left.
+//
 // Parameters:
 //   - ctx: Context for the operation
 //   - cfg: Configuration containing agent settings
-//   - einoTools: List of tools available to the agent
+//   - einoTools: List of tools available to the agent, may be empty
 //   - chatModel: Chat model for the agent to use
 //
 // Returns:
 //   - *react.Agent: Configured ReAct agent ready for task execution
 //   - error: Error if agent creation fails
 func createReActAgent(ctx context.Context, cfg *config.Config, einoTools []tool.BaseTool, chatModel model.ToolCallingChatModel) (*react.Agent, error) {
+	state := &stepState{}
+	presented := withRequiredThink(ctx, cfg, withNotesTools(ctx, cfg, withResultSummaries(cfg, withEvidenceCapture(cfg, withSanitizedResults(cfg, einoTools)), chatModel)))
 	tools := compose.ToolsNodeConfig{
-		Tools: einoTools,
+		Tools: withPanicRecovery(cfg, withStepLimit(cfg, presented, state)),
+		// 按调用顺序执行，超出每步工具数上限的总是靠后的调用
+		ExecuteSequentially: cfg.Agent.AllowedToolsPerStep > 0,
 	}
 
 	agentConfig := &react.AgentConfig{
-		ToolCallingModel: chatModel,
+		ToolCallingModel: withTimeBudgetModel(ctx, withNotesContext(ctx, cfg, withStepControl(ctx, cfg, withAbortableStream(chatModel), presented, state))),
 		ToolsConfig:      tools,
 		MaxStep:          cfg.MaxStep * 5, // Allow more steps for complex reasoning
 	}
@@ -630,74 +862,211 @@ func createReActAgent(ctx context.Context, cfg *config.Config, einoTools []tool.
 // Returns:
 //   - error: Error if task execution fails
 func executeAgentTask(ctx context.Context, cfg *config.Config, ragent *react.Agent, task string, notify Notify) error {
-	// 创建聊天模板
-	chatTemplate := prompt.FromMessages(schema.FString,
-		&schema.Message{
-			Role:    schema.System,
-			Content: cfg.SystemPrompt,
-		},
-		&schema.Message{
-			Role:    schema.User,
-			Content: task,
-		})
+	msg, err := formatConversation(ctx, cfg, nil, task)
+	if err != nil {
+		return err
+	}
+	_, err = executeAgentConversation(ctx, cfg, ragent, msg, "", notify)
+	return err
+}
 
-	// 格式化消息
-	placeHolders := map[string]any{
-		"date": time.Now().Format("2006-01-02"),
+// executeAgentConversation generates the answer to the messages of a
+// conversation, as assembled by formatConversation, and sends it to
+// notify.OnResult. The first message is copied before the plan and the
+// output schema are appended, so the messages of the caller are unchanged.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - cfg: Configuration containing system prompt and other settings
+//   - ragent: Configured ReAct agent to execute the task
+//   - messages: System prompt, earlier messages and the task of the new turn
+//   - plan: Execution plan added to the system prompt, empty without plan mode
+//   - notify: Notification handler for results
+//
+// Returns:
+//   - *schema.Message: Assistant message with the answer, to be appended to the history of the next turn
+//   - error: Error if task execution fails
+func executeAgentConversation(ctx context.Context, cfg *config.Config, ragent *react.Agent, messages []*schema.Message, plan string, notify Notify) (*schema.Message, error) {
+	system := *messages[0]
+	msg := append([]*schema.Message{&system}, messages[1:]...)
+
+	// 计划在格式化后追加，避免其中的大括号被当作占位符
+	if plan != "" {
+		msg[0].Content += planContext(cfg, plan)
 	}
-	for k, v := range cfg.PlaceHolders {
-		placeHolders[k] = v
+
+	// 要求结构化输出时，在格式化后追加说明，避免Schema中的大括号被当作占位符
+	outputSchema, err := cfg.CompileOutputSchema()
+	if err != nil {
+		return nil, err
+	}
+	if outputSchema != nil {
+		msg[0].Content += outputSchemaInstruction(cfg)
 	}
 
-	msg, err := chatTemplate.Format(ctx, placeHolders)
+	generate := func(ctx context.Context, messages []*schema.Message) (*schema.Message, error) {
+		return generateAgentOutput(ctx, cfg, ragent, messages, notify)
+	}
+	output, err := generate(ctx, msg)
 	if err != nil {
-		return fmt.Errorf(errMsgFormatMsgFailed, err)
+		return nil, err
 	}
 
+	var result string
+	if outputSchema == nil {
+		result = newOutputPipeline(cfg.Output, messagesOf(cfg)).Process(output.Content)
+	} else {
+		// 结构化输出不经过后处理，避免破坏JSON
+		result, err = generateStructuredOutput(ctx, cfg, outputSchema, msg, output, generate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	verifyAnswer(ctx, cfg, result, notify)
+	notify.OnResult(result)
+	return schema.AssistantMessage(result, nil), nil
+}
+
+// formatConversation returns the system prompt, history and task as the
+// messages sent to the model, see formatSystemMessages and assembleConversation.
+func formatConversation(ctx context.Context, cfg *config.Config, history []*schema.Message, task string) ([]*schema.Message, error) {
+	system, err := formatSystemMessages(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return assembleConversation(ctx, cfg, system, history, task)
+}
+
+// formatSystemMessages returns the system messages of a conversation. The
+// first message is always the system prompt, preceded by the guard prompt
+// when one is configured. The instructions of the task follow as a second
+// system message. Placeholders are expanded in each layer separately.
+func formatSystemMessages(ctx context.Context, cfg *config.Config) ([]*schema.Message, error) {
+	// 创建聊天模板：安全约束、系统提示词、任务说明
+	templates := []schema.MessagesTemplate{schema.SystemMessage(cfg.SystemPrompt)}
+	if cfg.GuardPrompt != "" {
+		templates = append([]schema.MessagesTemplate{schema.SystemMessage(cfg.GuardPrompt)}, templates...)
+	}
+	if cfg.Instructions != "" {
+		templates = append(templates, schema.SystemMessage(cfg.Instructions))
+	}
+	msg, err := prompt.FromMessages(schema.FString, templates...).Format(ctx, buildPlaceHolders(cfg))
+	if err != nil {
+		return nil, fmt.Errorf(errMsgFormatMsgFailed, err)
+	}
+
+	// 安全约束与系统提示词合并为第一条消息，计划等内容仍追加到第一条消息
+	if cfg.GuardPrompt != "" {
+		msg[1].Content = joinPromptLayers(msg[0].Content, msg[1].Content)
+		msg = msg[1:]
+	}
+	msg[0].Content += untrustedOutputReminder(cfg)
+	return msg, nil
+}
+
+// assembleConversation returns the messages of the next turn of a
+// conversation: the system messages formatted by formatSystemMessages, the
+// history and the task. Only the task is formatted as a template, the
+// history is inserted unchanged so braces in earlier answers are kept.
+func assembleConversation(ctx context.Context, cfg *config.Config, system []*schema.Message, history []*schema.Message, task string) ([]*schema.Message, error) {
+	formatted, err := prompt.FromMessages(schema.FString, schema.UserMessage(task)).Format(ctx, buildPlaceHolders(cfg))
+	if err != nil {
+		return nil, fmt.Errorf(errMsgFormatMsgFailed, err)
+	}
+
+	msg := make([]*schema.Message, 0, len(system)+len(history)+1)
+	msg = append(msg, system...)
+	msg = append(msg, history...)
+	return append(msg, formatted...), nil
+}
+
+// joinPromptLayers places the guard prompt before the system prompt, an
+// empty system prompt leaves only the guard prompt
+func joinPromptLayers(guard, system string) string {
+	if strings.TrimSpace(system) == "" {
+		return guard
+	}
+	return guard + "\n\n" + system
+}
+
+// streamsAnswerChunks reports whether chunks of the final answer may be sent
+// to OnStreamResult as they arrive. Raw chunks are withheld when the output
+// pipeline or an output schema is configured: redaction only runs on the
+// whole answer, and invalid structured-output rounds must not be shown.
+func streamsAnswerChunks(cfg *config.Config) bool {
+	return cfg.Output.IsEmpty() && !cfg.HasOutputSchema()
+}
+
+// generateAgentOutput runs the agent on messages and returns its final message.
+// Streaming notifiers are served through the streaming API: every chunk of
+// the final answer is sent to OnStreamResult as it arrives, before the caller
+// sends the whole answer to OnResult. See streamsAnswerChunks for when
+// chunks are withheld.
+func generateAgentOutput(ctx context.Context, cfg *config.Config, ragent *react.Agent, messages []*schema.Message, notify Notify) (*schema.Message, error) {
 	// Check if we're dealing with a streaming notifier
-	if _, isStreamingNotify := notify.(StreamingNotify); isStreamingNotify {
+	if streamNotify, isStreamingNotify := notify.(StreamingNotify); isStreamingNotify && streamsAnswerChunks(cfg) {
 		// Use streaming API for StreamingNotify implementations
-		streamOutput, err := ragent.Stream(ctx, msg, agent.WithComposeOptions(
-			compose.WithCallbacks(&LoggerCallback{
-				notify: notify,
-			})))
+		streamOutput, err := ragent.Stream(ctx, messages, agent.WithComposeOptions(
+			compose.WithCallbacks(buildCallbackHandlers(cfg, notify)...)))
 		if err != nil {
-			return fmt.Errorf(errMsgStreamFailed, err)
+			return nil, fmt.Errorf(errMsgStreamFailed, err)
 		}
 		defer streamOutput.Close()
 
-		// Collect the final complete output
-		var finalOutput *schema.Message
+		// Collect the chunks of the final output, the model stream ends with
+		// the context error as soon as the task is cancelled
+		var chunks []*schema.Message
 		for {
 			chunk, err := streamOutput.Recv()
 			if errors.Is(err, io.EOF) {
 				break
 			}
 			if err != nil {
-				return fmt.Errorf(errMsgStreamFailed, err)
+				return nil, fmt.Errorf(errMsgStreamFailed, err)
 			}
-
-			// Keep the latest message
-			finalOutput = chunk
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf(errMsgStreamFailed, err)
+			}
+			if chunk.Content != "" {
+				streamNotify.OnStreamResult(chunk.Content)
+			}
+			chunks = append(chunks, chunk)
 		}
-
-		// Notify with the final complete output
-		if finalOutput != nil {
-			notify.OnResult(finalOutput.Content)
+		if len(chunks) == 0 {
+			return &schema.Message{Role: schema.Assistant}, nil
 		}
 
-		return nil
-	} else {
-		// For non-streaming notifiers, use the regular Generate method
-		output, err := ragent.Generate(ctx, msg, agent.WithComposeOptions(
-			compose.WithCallbacks(&LoggerCallback{
-				notify: notify,
-			})))
+		finalOutput, err := schema.ConcatMessages(chunks)
 		if err != nil {
-			return fmt.Errorf(errMsgGenerateOutFailed, err)
+			return nil, fmt.Errorf(errMsgStreamFailed, err)
 		}
+		return finalOutput, nil
+	}
 
-		notify.OnResult(output.Content)
-		return nil
+	// For non-streaming notifiers, use the regular Generate method
+	output, err := ragent.Generate(ctx, messages, agent.WithComposeOptions(
+		compose.WithCallbacks(buildCallbackHandlers(cfg, notify)...)))
+	if err != nil {
+		return nil, fmt.Errorf(errMsgGenerateOutFailed, err)
+	}
+	return output, nil
+}
+
+// buildPlaceHolders returns the template variables used to format the prompt.
+// Built-in variables such as {date}, {attachments} and {notes} can be
+// overridden by the placeholders configured in cfg.
+func buildPlaceHolders(cfg *config.Config) map[string]any {
+	placeHolders := map[string]any{
+		"date":        time.Now().Format(messagesOf(cfg).DateLayout),
+		"attachments": attachment.Placeholder(cfg.Attachments.Dir),
+		"notes":       "",
+	}
+	if cfg.Agent.EnableNotes {
+		// 每次请求前替换为当前笔记的key，见withNoteKeys
+		placeHolders["notes"] = notesPlaceholder
+	}
+	for k, v := range cfg.PlaceHolders {
+		placeHolders[k] = v
 	}
+	return placeHolders
 }
@@ -2,15 +2,25 @@ package mcpagent
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
 	"github.com/cloudwego/eino/callbacks"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // 创建一个模拟的通知接口实现
@@ -30,6 +40,10 @@ func (m *MockNotify) OnToolCall(toolName string, params any) {
 	m.Called(toolName, params)
 }
 
+func (m *MockNotify) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	m.Called(toolName, result, err, duration)
+}
+
 func (m *MockNotify) OnResult(msg string) {
 	m.Called(msg)
 }
@@ -927,28 +941,11 @@ func TestCreateReActAgent(t *testing.T) {
 	// 创建模拟模型
 	mockModel := new(MockToolCallingChatModel)
 
-	// 设置WithTools方法的期望
-	mockModel.On("WithTools", mock.Anything).Return(mockModel, nil)
-
-	// 测试createReActAgent函数
-	// 注意：这个测试可能会失败，因为react.NewAgent需要真实的依赖
+	// 没有工具时eino不绑定工具，模型直接回答
 	agent, err := createReActAgent(ctx, cfg, einoTools, mockModel)
-
-	// 由于我们无法完全模拟所有依赖，这个测试主要是为了覆盖代码
-	// 在实际环境中，这个函数可能会因为缺少依赖而失败
-	if err != nil {
-		// 如果失败，我们验证错误不是nil，这也算是覆盖了代码
-		assert.Error(t, err)
-		assert.Nil(t, agent)
-		t.Logf("createReActAgent failed as expected: %v", err)
-	} else {
-		// 如果成功，我们验证agent不是nil
-		assert.NotNil(t, agent)
-		t.Logf("createReActAgent succeeded unexpectedly")
-	}
-
-	// 验证WithTools方法被调用
-	mockModel.AssertExpectations(t)
+	require.NoError(t, err)
+	assert.NotNil(t, agent)
+	mockModel.AssertNotCalled(t, "WithTools", mock.Anything)
 }
 
 // 测试executeAgentTask函数
@@ -978,27 +975,34 @@ func TestExecuteAgentTask(t *testing.T) {
 // 测试Run函数的更多分支
 func TestRunWithValidConfigButNoTools(t *testing.T) {
 	ctx := context.Background()
-	mockNotify := new(MockNotify)
+	server, requests := newAnswerModelServer(t, "你好，我是助手")
 
-	// 创建一个有效的配置，但没有工具
+	// 没有工具和服务器，配置文件也不存在
 	validConfig := &config.Config{
 		MCP: config.MCPConfig{
-			ConfigFile: "non_existent_file.json", // 这会导致GetTools失败
+			ConfigFile: "non_existent_file.json",
 		},
 		LLM: config.LLMConfig{
-			Type:    "ollama",
-			BaseURL: "http://localhost:11434",
+			Type:    config.LLMProviderOpenAI,
+			BaseURL: server.URL,
 			Model:   "test-model",
 			APIKey:  "test-key",
 		},
 		SystemPrompt: "test prompt",
 		MaxStep:      10,
 	}
+	require.NoError(t, validConfig.Validate())
 
-	// 运行函数，应该在GetTools阶段失败
-	err := Run(ctx, validConfig, "test task", mockNotify)
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "获取工具失败")
+	notify := newResultNotify()
+	err := Run(ctx, validConfig, "你好", notify)
+	require.NoError(t, err)
+	notify.AssertCalled(t, "OnResult", "你好，我是助手")
+
+	// 模型请求中没有工具定义
+	require.NotEmpty(t, *requests)
+	for _, req := range *requests {
+		assert.NotContains(t, req, "tools")
+	}
 }
 
 // 测试Run函数的更多错误分支
@@ -1154,7 +1158,7 @@ func TestHandleStreamOutputWithDifferentInputs(t *testing.T) {
 		}
 	}()
 
-	callback.handleStreamOutput(info, nil)
+	callback.handleStreamOutput(context.Background(), info, nil)
 }
 
 // TestProcessStreamFrameEdgeCases 测试processStreamFrame的边界情况
@@ -1203,10 +1207,10 @@ func TestRunWithMockConfigSuccess(t *testing.T) {
 	task := "简单的测试任务"
 	notify := new(MockNotify)
 
-	// 测试参数验证成功但工具获取失败的情况
+	// 测试参数验证成功，没有工具，但模型创建失败的情况
 	err := Run(ctx, mockConfig, task, notify)
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "获取工具失败")
+	assert.Contains(t, err.Error(), "获取模型失败")
 }
 
 // TestValidateRunParametersAllCases 测试validateRunParameters的所有情况
@@ -1324,5 +1328,171 @@ func TestHandleStreamOutputRecovery(t *testing.T) {
 	}()
 
 	// 直接传递nil会触发panic恢复机制
-	callback.handleStreamOutput(info, nil)
+	callback.handleStreamOutput(context.Background(), info, nil)
+}
+
+// newSlowModelServer returns a model API server that never answers before the client gives up
+func newSlowModelServer(t *testing.T) *httptest.Server {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(done) })
+	return server
+}
+
+// newAnswerModelServer returns an OpenAI compatible model API server answering every
+// request with answer. The decoded request bodies are recorded.
+func newAnswerModelServer(t *testing.T, answer string) (*httptest.Server, *[]map[string]any) {
+	var mu sync.Mutex
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, body)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":     "chatcmpl-test",
+			"object": "chat.completion",
+			"model":  body["model"],
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": answer},
+				"finish_reason": "stop",
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// newSlowModelConfig returns a configuration using the slow model server without MCP tools
+func newSlowModelConfig(baseURL string) *config.Config {
+	return &config.Config{
+		MCP: config.MCPConfig{
+			MCPServers: map[string]*mcphost.ServerConfig{},
+		},
+		LLM: config.LLMConfig{
+			Type:    config.LLMProviderOpenAI,
+			BaseURL: baseURL,
+			Model:   "slow-model",
+			APIKey:  "test-key",
+		},
+		SystemPrompt: "test prompt",
+		MaxStep:      5,
+	}
+}
+
+// 测试任务整体超时
+func TestRunTaskTimeout(t *testing.T) {
+	server := newSlowModelServer(t)
+	cfg := newSlowModelConfig(server.URL)
+	cfg.TaskTimeout = 1
+
+	mockNotify := new(MockNotify)
+	mockNotify.On("OnError", mock.Anything).Return()
+
+	start := time.Now()
+	err := Run(context.Background(), cfg, "test task", mockNotify)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrTaskTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// 最后一个通知是超时错误
+	calls := mockNotify.Calls
+	require.NotEmpty(t, calls)
+	last := calls[len(calls)-1]
+	assert.Equal(t, "OnError", last.Method)
+	assert.ErrorIs(t, last.Arguments.Get(0).(error), ErrTaskTimeout)
+}
+
+// 测试单次模型请求超时不会被当作任务超时
+func TestRunLLMRequestTimeout(t *testing.T) {
+	server := newSlowModelServer(t)
+	cfg := newSlowModelConfig(server.URL)
+	cfg.LLMRequestTimeout = 1
+	cfg.TaskTimeout = 30
+
+	mockNotify := new(MockNotify)
+	mockNotify.On("OnError", mock.Anything).Return()
+
+	start := time.Now()
+	err := Run(context.Background(), cfg, "test task", mockNotify)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrTaskTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// 测试合并MCP服务器配置时的冲突警告通过Notify发送
+func TestNotifyMCPMergeWarnings(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "mcpservers.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{"mcpServers": {"fetch": {"command": "uvx", "args": ["mcp-server-fetch"]}}}`), 0644))
+
+	cfg := config.NewDefaultConfig()
+	cfg.MCP.ConfigFile = configFile
+	cfg.MCP.MCPServers = map[string]*mcphost.ServerConfig{
+		"fetch": {Command: "npx", Args: []string{"fetch-server"}},
+	}
+
+	mockNotify := new(MockNotify)
+	mockNotify.On("OnMessage", mock.MatchedBy(func(msg string) bool {
+		return strings.HasPrefix(msg, "警告: ") && strings.Contains(msg, "fetch")
+	})).Once()
+	notifyMCPMergeWarnings(cfg, mockNotify)
+	mockNotify.AssertExpectations(t)
+
+	// 只使用mcp_servers时没有冲突
+	cfg.MCP.MergeStrategy = config.MergeStrategyInlineOnly
+	notifyMCPMergeWarnings(cfg, mockNotify)
+	mockNotify.AssertExpectations(t)
+}
+
+func TestFormatConversationPromptLayers(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{
+		GuardPrompt:  "不得扫描{scope}以外的目标",
+		SystemPrompt: "你是{field}专家",
+		Instructions: "只输出{format}",
+		PlaceHolders: map[string]any{"scope": "授权范围", "field": "网络安全", "format": "表格"},
+	}
+	history := []*schema.Message{schema.UserMessage("上一个任务"), schema.AssistantMessage("上一个回答", nil)}
+
+	msg, err := formatConversation(ctx, cfg, history, "扫描example.com")
+	require.NoError(t, err)
+	require.Len(t, msg, 5)
+
+	// 安全约束在系统提示词之前，任务说明是第二条系统消息，三层都展开占位符
+	assert.Equal(t, schema.System, msg[0].Role)
+	assert.Equal(t, "不得扫描授权范围以外的目标\n\n你是网络安全专家", msg[0].Content)
+	assert.Equal(t, schema.System, msg[1].Role)
+	assert.Equal(t, "只输出表格", msg[1].Content)
+	assert.Equal(t, "上一个任务", msg[2].Content)
+	assert.Equal(t, "上一个回答", msg[3].Content)
+	assert.Equal(t, schema.User, msg[4].Role)
+	assert.Equal(t, "扫描example.com", msg[4].Content)
+
+	// 系统提示词为空时仍保留安全约束
+	cfg.SystemPrompt = ""
+	cfg.Instructions = ""
+	msg, err = formatConversation(ctx, cfg, nil, "扫描example.com")
+	require.NoError(t, err)
+	require.Len(t, msg, 2)
+	assert.Equal(t, "不得扫描授权范围以外的目标", msg[0].Content)
+
+	// 没有安全约束和任务说明时与之前相同
+	msg, err = formatConversation(ctx, &config.Config{SystemPrompt: "系统提示词"}, nil, "任务")
+	require.NoError(t, err)
+	require.Len(t, msg, 2)
+	assert.Equal(t, "系统提示词", msg[0].Content)
+	assert.Equal(t, "任务", msg[1].Content)
 }
@@ -0,0 +1,125 @@
+package mcpagent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxArgumentDepth is the nesting depth kept when canonicalizing tool arguments
+	maxArgumentDepth = 10
+
+	// maxArgumentItems is the number of array items or object keys kept per level
+	maxArgumentItems = 100
+
+	// maxArgumentStringLen is the number of characters kept per string value
+	maxArgumentStringLen = 2000
+
+	// truncatedMarker replaces the parts of tool arguments that were cut off
+	truncatedMarker = "...(已截断)"
+)
+
+// decodeArguments parses a JSON object keeping numbers as json.Number,
+// so large integers and decimals keep their original literal form.
+func decodeArguments(data string) (map[string]any, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+
+	var parsedArgs map[string]any
+	if err := decoder.Decode(&parsedArgs); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return parsedArgs, nil
+}
+
+// CanonicalizeArguments returns a copy of tool arguments that serializes
+// deterministically: object keys are sorted by encoding/json, numbers keep their
+// literal form, and very deep or large structures are cut off with a marker.
+// It is safe to call on arguments that are already canonical.
+// The markers are in Chinese; use Messages.CanonicalizeArguments for other languages.
+func CanonicalizeArguments(v any) any {
+	return MessagesFor("").CanonicalizeArguments(v)
+}
+
+// CanonicalizeArguments is like the package-level CanonicalizeArguments but
+// marks truncated parts with the strings of this catalog.
+func (m *Messages) CanonicalizeArguments(v any) any {
+	return canonicalizeValue(v, 0, m)
+}
+
+// canonicalizeValue canonicalizes a value at the given nesting depth
+func canonicalizeValue(v any, depth int, m *Messages) any {
+	switch val := v.(type) {
+	case map[string]any:
+		if depth >= maxArgumentDepth {
+			return m.ArgumentsTruncated
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		result := make(map[string]any, len(val))
+		for i, k := range keys {
+			if i >= maxArgumentItems {
+				result[m.ArgumentsTruncated] = fmt.Sprintf(m.FieldsOmitted, len(keys)-maxArgumentItems)
+				break
+			}
+			result[k] = canonicalizeValue(val[k], depth+1, m)
+		}
+		return result
+	case []any:
+		if depth >= maxArgumentDepth {
+			return m.ArgumentsTruncated
+		}
+		n := len(val)
+		if n > maxArgumentItems {
+			n = maxArgumentItems
+		}
+		result := make([]any, 0, n+1)
+		for _, item := range val[:n] {
+			result = append(result, canonicalizeValue(item, depth+1, m))
+		}
+		if len(val) > maxArgumentItems {
+			result = append(result, fmt.Sprintf(m.ItemsOmitted, m.ArgumentsTruncated, len(val)-maxArgumentItems))
+		}
+		return result
+	case string:
+		if utf8.RuneCountInString(val) <= maxArgumentStringLen {
+			return val
+		}
+		return string([]rune(val)[:maxArgumentStringLen]) + m.ArgumentsTruncated
+	case float64:
+		// 来自其他解析方式的数字，避免输出1e+06这样的科学计数法
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			return strconv.FormatFloat(val, 'g', -1, 64)
+		}
+		return json.Number(strconv.FormatFloat(val, 'f', -1, 64))
+	default:
+		return v
+	}
+}
+
+// FormatArguments serializes tool arguments as compact, deterministic JSON.
+// Non-ASCII characters are written as is instead of being escaped.
+func FormatArguments(v any) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(CanonicalizeArguments(v)); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
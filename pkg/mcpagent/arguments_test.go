@@ -0,0 +1,74 @@
+package mcpagent
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToolArgumentsKeepsNumberLiterals(t *testing.T) {
+	callback := &LoggerCallback{}
+
+	args, err := callback.parseToolArguments(`{"id": 9007199254740993, "size": 1000000, "ratio": 0.10, "think": "先查询"}`)
+	require.NoError(t, err)
+	assert.Equal(t, json.Number("9007199254740993"), args["id"])
+	assert.Equal(t, `{"id":9007199254740993,"ratio":0.10,"size":1000000,"think":"先查询"}`, FormatArguments(args))
+
+	_, err = callback.parseToolArguments(`{"a": 1} trailing`)
+	assert.Error(t, err)
+}
+
+func TestHandleThinkingToolWithParsedArguments(t *testing.T) {
+	notify := &timelineRecordingNotify{}
+	callback := &LoggerCallback{notify: notify}
+
+	args, err := callback.parseToolArguments(`{"thought": "分析日志", "step": 2}`)
+	require.NoError(t, err)
+	callback.handleThinkingTool(args)
+
+	require.Len(t, notify.events, 1)
+	assert.Equal(t, "分析日志", notify.events[0].content)
+}
+
+func TestFormatArgumentsUnicodeAndNesting(t *testing.T) {
+	args, err := decodeArguments(`{"query": "中文 <b>&</b> 😀", "filters": [[1, 2], {"z": [3.50], "a": null}]}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"filters":[[1,2],{"a":null,"z":[3.50]}],"query":"中文 <b>&</b> 😀"}`, FormatArguments(args))
+	// 重复规范化结果不变
+	assert.Equal(t, FormatArguments(args), FormatArguments(CanonicalizeArguments(args)))
+}
+
+func TestCanonicalizeArgumentsFloats(t *testing.T) {
+	out := FormatArguments(map[string]any{"n": float64(1000000), "f": 0.25})
+	assert.Equal(t, `{"f":0.25,"n":1000000}`, out)
+}
+
+func TestCanonicalizeArgumentsTruncates(t *testing.T) {
+	// 过深的嵌套
+	var deep any = "leaf"
+	for i := 0; i < maxArgumentDepth+5; i++ {
+		deep = []any{deep}
+	}
+	out := FormatArguments(map[string]any{"deep": deep})
+	assert.Contains(t, out, truncatedMarker)
+	assert.NotContains(t, out, "leaf")
+
+	// 过多的数组元素
+	items := make([]any, maxArgumentItems+10)
+	for i := range items {
+		items[i] = json.Number("1")
+	}
+	list := CanonicalizeArguments(items).([]any)
+	assert.Len(t, list, maxArgumentItems+1)
+	assert.Contains(t, list[maxArgumentItems], "省略10项")
+
+	// 过长的字符串按字符截断，不破坏UTF-8
+	long := strings.Repeat("字", maxArgumentStringLen+1)
+	s := CanonicalizeArguments(long).(string)
+	assert.True(t, strings.HasSuffix(s, truncatedMarker))
+	assert.Equal(t, maxArgumentStringLen, len([]rune(strings.TrimSuffix(s, truncatedMarker))))
+}
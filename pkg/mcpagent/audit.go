@@ -0,0 +1,240 @@
+package mcpagent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/audit"
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/notes"
+)
+
+// AuditedNotify is a notification handler that also records the events of
+// a task in an audit log. OnTaskStart and OnTaskEnd are called by the owner
+// of the task around Run, the other events are recorded as Run reports them.
+type AuditedNotify interface {
+	TimelineNotify
+	PlanNotify
+	ToolUsageNotify
+	ModelNotify
+	MissingToolsNotify
+	CheckpointNotify
+	VerificationNotify
+	NotesNotify
+
+	// OnTaskStart records the start of task, call it before Run
+	OnTaskStart(task string)
+
+	// OnTaskEnd records the end of the task, err is the error returned by Run
+	OnTaskEnd(err error)
+}
+
+// auditNotify forwards every notification to notify and records task starts
+// and ends, tool calls, tool results, results and errors with logger.
+// Events are numbered in the order they are reported, so that the events of
+// a task can be ordered even when tools report their results concurrently.
+type auditNotify struct {
+	notify Notify
+	logger *audit.Logger
+	taskID string
+
+	mutex   sync.Mutex
+	seq     uint64
+	startAt time.Time
+}
+
+// auditStreamingNotify is an auditNotify wrapping a StreamingNotify, so that
+// auditing does not change how Run generates the output
+type auditStreamingNotify struct {
+	*auditNotify
+}
+
+// OnStreamResult forwards a chunk of the result stream
+func (n *auditStreamingNotify) OnStreamResult(chunk string) {
+	n.notify.(StreamingNotify).OnStreamResult(chunk)
+}
+
+// NewAuditNotify wraps notify so that the events of the task taskID are also
+// written to logger. The returned handler implements StreamingNotify exactly
+// when notify does. It always reports tool call IDs and results, handlers
+// that do not implement TimelineNotify receive the same notifications as
+// without auditing.
+//
+// Parameters:
+//   - notify: Handler receiving all notifications
+//   - logger: Audit log the events are written to
+//   - taskID: ID of the task, written with every event
+//
+// Returns:
+//   - AuditedNotify: Handler to pass to Run
+func NewAuditNotify(notify Notify, logger *audit.Logger, taskID string) AuditedNotify {
+	n := &auditNotify{notify: notify, logger: logger, taskID: taskID}
+	if _, ok := notify.(StreamingNotify); ok {
+		return &auditStreamingNotify{auditNotify: n}
+	}
+	return n
+}
+
+// record numbers event and queues it. The lock keeps the queue in sequence order.
+func (n *auditNotify) record(event audit.Event) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.seq++
+	event.TaskID = n.taskID
+	event.Seq = n.seq
+	event.Time = time.Now()
+	if event.Type == audit.EventTaskStart {
+		n.startAt = event.Time
+	} else if event.Type == audit.EventTaskEnd && !n.startAt.IsZero() {
+		event.DurationMs = event.Time.Sub(n.startAt).Milliseconds()
+	}
+	n.logger.Log(event)
+}
+
+// OnTaskStart records the start of task
+func (n *auditNotify) OnTaskStart(task string) {
+	n.record(audit.Event{Type: audit.EventTaskStart, Task: task})
+}
+
+// OnTaskEnd records the end of the task and its error, if any
+func (n *auditNotify) OnTaskEnd(err error) {
+	event := audit.Event{Type: audit.EventTaskEnd}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	n.record(event)
+}
+
+// OnMessage forwards a message notification
+func (n *auditNotify) OnMessage(msg string) {
+	n.notify.OnMessage(msg)
+}
+
+// OnThinking forwards a thinking notification
+func (n *auditNotify) OnThinking(msg string) {
+	n.notify.OnThinking(msg)
+}
+
+// OnToolCall records and forwards a tool call without call ID
+func (n *auditNotify) OnToolCall(toolName string, params any) {
+	n.record(audit.Event{Type: audit.EventToolCall, Tool: toolName, Params: params})
+	n.notify.OnToolCall(toolName, params)
+}
+
+// OnToolCallResult records and forwards the result of a tool call without call ID
+func (n *auditNotify) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	n.recordToolResult(ToolCallResult{ToolName: toolName, Result: result, Err: err, Duration: duration})
+	n.notify.OnToolCallResult(toolName, result, err, duration)
+}
+
+// OnResult records and forwards the final result
+func (n *auditNotify) OnResult(msg string) {
+	n.record(audit.Event{Type: audit.EventResult, Result: msg})
+	n.notify.OnResult(msg)
+}
+
+// OnError records and forwards an error
+func (n *auditNotify) OnError(err error) {
+	event := audit.Event{Type: audit.EventError}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	n.record(event)
+	n.notify.OnError(err)
+}
+
+// OnThinkingWithCall forwards a thinking notification, as OnThinking to handlers without timeline
+func (n *auditNotify) OnThinkingWithCall(msg string, relatedCallID string) {
+	if timeline, ok := n.notify.(TimelineNotify); ok {
+		timeline.OnThinkingWithCall(msg, relatedCallID)
+		return
+	}
+	n.notify.OnThinking(msg)
+}
+
+// OnToolCallWithID records and forwards a tool call, as OnToolCall to handlers without timeline
+func (n *auditNotify) OnToolCallWithID(callID string, toolName string, params any) {
+	n.record(audit.Event{Type: audit.EventToolCall, Tool: toolName, CallID: callID, Params: params})
+	if timeline, ok := n.notify.(TimelineNotify); ok {
+		timeline.OnToolCallWithID(callID, toolName, params)
+		return
+	}
+	n.notify.OnToolCall(toolName, params)
+}
+
+// OnToolResult records and forwards the result of a tool call, as OnToolCallResult to handlers without timeline
+func (n *auditNotify) OnToolResult(callID string, toolName string, result string, err error, duration time.Duration) {
+	toolResult := ToolCallResult{CallID: callID, ToolName: toolName, Result: result, Err: err, Duration: duration}
+	n.recordToolResult(toolResult)
+	notifyToolResult(n.notify, toolResult)
+}
+
+// recordToolResult records the result and the duration of a tool call
+func (n *auditNotify) recordToolResult(result ToolCallResult) {
+	event := audit.Event{
+		Type:       audit.EventToolResult,
+		Tool:       result.ToolName,
+		CallID:     result.CallID,
+		Result:     result.Result,
+		DurationMs: result.Duration.Milliseconds(),
+	}
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+	}
+	n.record(event)
+}
+
+// OnPlan forwards the execution plan, as OnMessage to handlers without plan support
+func (n *auditNotify) OnPlan(plan string) {
+	notifyPlan(n.notify, plan)
+}
+
+// OnToolUsage forwards the outcome of a tool invocation to handlers collecting usage
+func (n *auditNotify) OnToolUsage(usage ToolUsage) {
+	if usageNotify, ok := n.notify.(ToolUsageNotify); ok {
+		usageNotify.OnToolUsage(usage)
+	}
+}
+
+// OnAnswerModel forwards the model that produced the final answer to handlers recording it
+func (n *auditNotify) OnAnswerModel(model string) {
+	if modelNotify, ok := n.notify.(ModelNotify); ok {
+		modelNotify.OnAnswerModel(model)
+	}
+}
+
+// OnVerification forwards the verdict on the result to handlers presenting it
+func (n *auditNotify) OnVerification(verification Verification) {
+	if verificationNotify, ok := n.notify.(VerificationNotify); ok {
+		verificationNotify.OnVerification(verification)
+	}
+}
+
+// OnNotes forwards the notes of the task to handlers including them in their report
+func (n *auditNotify) OnNotes(saved []notes.Note) {
+	if notesNotify, ok := n.notify.(NotesNotify); ok {
+		notesNotify.OnNotes(saved)
+	}
+}
+
+// OnMissingTools forwards the requested tools that do not exist to handlers recording them
+func (n *auditNotify) OnMissingTools(tools []config.MissingTool) {
+	if missingNotify, ok := n.notify.(MissingToolsNotify); ok {
+		missingNotify.OnMissingTools(tools)
+	}
+}
+
+// CheckpointInterval returns the checkpoint interval of the wrapped handler, 0 when it saves no checkpoints
+func (n *auditNotify) CheckpointInterval() int {
+	if checkpointNotify, ok := n.notify.(CheckpointNotify); ok {
+		return checkpointNotify.CheckpointInterval()
+	}
+	return 0
+}
+
+// OnCheckpoint forwards a checkpoint to handlers saving them
+func (n *auditNotify) OnCheckpoint(checkpoint Checkpoint) {
+	if checkpointNotify, ok := n.notify.(CheckpointNotify); ok {
+		checkpointNotify.OnCheckpoint(checkpoint)
+	}
+}
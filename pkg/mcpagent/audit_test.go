@@ -0,0 +1,138 @@
+package mcpagent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/audit"
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// readAuditEvents closes logger and returns the events written to path
+func readAuditEvents(t *testing.T, logger *audit.Logger, path string) []audit.Event {
+	t.Helper()
+	require.NoError(t, logger.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var events []audit.Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event audit.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestAuditNotifyRecordsTaskInOrder(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := audit.New(audit.Options{Path: path})
+	require.NoError(t, err)
+
+	toolCallMsg := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_1", Type: "function", Function: schema.FunctionCall{Name: "echo", Arguments: `{"think":"先回显","text":"a"}`}},
+		{ID: "call_2", Type: "function", Function: schema.FunctionCall{Name: "echo", Arguments: `{"text":"b"}`}},
+	})
+	mockModel := new(MockToolCallingChatModel)
+	mockModel.On("WithTools", mock.Anything).Return(mockModel, nil)
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(toolCallMsg, nil).Once()
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(schema.AssistantMessage("done", nil), nil)
+
+	cfg := &config.Config{SystemPrompt: "test prompt", MaxStep: 5}
+	ragent, err := createReActAgent(ctx, cfg, []tool.BaseTool{&echoTool{}}, mockModel)
+	require.NoError(t, err)
+
+	inner := &timelineRecordingNotify{}
+	notify := NewAuditNotify(inner, logger, "task_1")
+	_, streaming := notify.(StreamingNotify)
+	assert.False(t, streaming)
+
+	notify.OnTaskStart("test task")
+	require.NoError(t, executeAgentTask(ctx, cfg, ragent, "test task", notify))
+	notify.OnTaskEnd(nil)
+
+	// 被包装的通知器收到的通知与不记录审计日志时相同
+	var kinds []string
+	for _, e := range inner.events {
+		kinds = append(kinds, e.kind)
+	}
+	assert.Equal(t, []string{"thinking", "tool_call", "tool_call", "tool_result", "tool_result"}, kinds)
+
+	events := readAuditEvents(t, logger, path)
+	var types []string
+	for i, event := range events {
+		types = append(types, event.Type)
+		assert.Equal(t, "task_1", event.TaskID)
+		assert.Equal(t, uint64(i+1), event.Seq)
+		if i > 0 {
+			assert.False(t, event.Time.Before(events[i-1].Time))
+		}
+	}
+	require.Equal(t, []string{
+		audit.EventTaskStart, audit.EventToolCall, audit.EventToolCall,
+		audit.EventToolResult, audit.EventToolResult, audit.EventResult, audit.EventTaskEnd,
+	}, types)
+	assert.Equal(t, "test task", events[0].Task)
+	assert.Equal(t, "call_1", events[1].CallID)
+	assert.Equal(t, map[string]any{"think": "先回显", "text": "a"}, events[1].Params)
+	assert.ElementsMatch(t, []string{"call_1", "call_2"}, []string{events[3].CallID, events[4].CallID})
+	assert.Contains(t, events[3].Result, "echo:")
+	assert.Equal(t, "done", events[5].Result)
+	assert.Empty(t, events[6].Error)
+}
+
+func TestAuditNotifyWithoutTimeline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := audit.New(audit.Options{Path: path})
+	require.NoError(t, err)
+
+	inner := new(MockNotify)
+	inner.On("OnThinking", "思考").Return()
+	inner.On("OnToolCall", "fetch", map[string]any{"url": "x"}).Return()
+	inner.On("OnToolCallResult", "fetch", "", mock.Anything, time.Duration(0)).Return()
+	inner.On("OnMessage", "计划").Return()
+	inner.On("OnError", mock.Anything).Return()
+	notify := NewAuditNotify(inner, logger, "task_2")
+
+	notify.OnTaskStart("task")
+	notify.OnThinkingWithCall("思考", "call_1")
+	notify.OnToolCallWithID("call_1", "fetch", map[string]any{"url": "x"})
+	notify.OnToolResult("call_1", "fetch", "", errors.New("连接失败"), 0)
+	notify.OnPlan("计划")
+	notify.OnToolUsage(ToolUsage{ToolName: "fetch"})
+	notify.OnError(errors.New("任务失败"))
+	notify.OnTaskEnd(errors.New("任务失败"))
+	inner.AssertExpectations(t)
+
+	events := readAuditEvents(t, logger, path)
+	require.Len(t, events, 5)
+	assert.Equal(t, audit.EventToolResult, events[2].Type)
+	assert.Equal(t, "连接失败", events[2].Error)
+	assert.Equal(t, audit.EventError, events[3].Type)
+	assert.Equal(t, audit.EventTaskEnd, events[4].Type)
+	assert.Equal(t, "任务失败", events[4].Error)
+}
+
+func TestAuditNotifyKeepsStreaming(t *testing.T) {
+	notify := NewAuditNotify(&streamResultNotify{MockNotify: new(MockNotify)}, nil, "task_3")
+	streamNotify, ok := notify.(StreamingNotify)
+	require.True(t, ok)
+	streamNotify.OnStreamResult("chunk")
+	notify.OnTaskStart("task")
+}
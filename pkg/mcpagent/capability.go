@@ -0,0 +1,120 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrModelNoToolSupport is returned when the configured model cannot be
+// bound to tools or the provider rejects requests with tools, typically
+// because a completion or embedding model was configured.
+var ErrModelNoToolSupport = errors.New("模型不支持工具调用")
+
+const (
+	errMsgNoToolSupport   = "%w（%s）: %v。请选择支持工具调用的模型，例如 qwen2.5、qwen3、llama3.1、gpt-4o-mini 或 deepseek-chat"
+	errMsgProbeToolFailed = "检查模型工具调用能力失败: %w"
+)
+
+// noToolSupportPatterns are the lower case fragments of provider errors that
+// mean the model cannot be called with tools
+var noToolSupportPatterns = []string{
+	"not support tool",
+	"does not support function",
+	"does not support chat",
+	"does not support generate",
+	"tools are not supported",
+	"tool use is not supported",
+	"tool calling is not supported",
+	"function calling is not supported",
+	"不支持工具",
+	"不支持函数调用",
+}
+
+// probeTool is the trivial tool of the probe request
+var probeTool = &schema.ToolInfo{
+	Name: "ping",
+	Desc: "Returns pong. Call it when asked to ping.",
+}
+
+// isNoToolSupportError reports whether err of a provider means the model cannot use tools
+func isNoToolSupportError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, pattern := range noToolSupportPatterns {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// noToolSupportError wraps ErrModelNoToolSupport with the model and the
+// cause, and suggests models known to support tool calling
+func noToolSupportError(cfg *config.Config, cause error) error {
+	modelName := "unknown"
+	if cfg != nil && cfg.LLM.Model != "" {
+		modelName = cfg.LLM.Model
+	}
+	return fmt.Errorf(errMsgNoToolSupport, ErrModelNoToolSupport, modelName, cause)
+}
+
+// ProbeToolSupport checks that chatModel can be called with tools before a
+// task starts. The tools are bound to the model, and with generate a short
+// request with a trivial tool is sent, because most providers only report
+// missing tool support when a request is made.
+//
+// Parameters:
+//   - ctx: Context of the probe request
+//   - cfg: Configuration of the model, used for the error message
+//   - chatModel: Model to check
+//   - tools: Tools of the task, nil binds only the trivial tool
+//   - generate: Whether to send the probe request
+//
+// Returns:
+//   - error: Error wrapping ErrModelNoToolSupport if the model cannot use tools,
+//     or the error of the probe request for other failures
+func ProbeToolSupport(ctx context.Context, cfg *config.Config, chatModel model.ToolCallingChatModel, tools []tool.BaseTool, generate bool) error {
+	infos := make([]*schema.ToolInfo, 0, len(tools))
+	for _, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return fmt.Errorf(errMsgProbeToolFailed, err)
+		}
+		infos = append(infos, info)
+	}
+	if len(infos) > 0 {
+		if _, err := chatModel.WithTools(infos); err != nil {
+			return noToolSupportError(cfg, err)
+		}
+	}
+	if !generate {
+		return nil
+	}
+
+	bound, err := chatModel.WithTools([]*schema.ToolInfo{probeTool})
+	if err != nil {
+		return noToolSupportError(cfg, err)
+	}
+	_, err = bound.Generate(ctx, []*schema.Message{schema.UserMessage("ping")}, model.WithMaxTokens(32))
+	if err != nil {
+		if isNoToolSupportError(err) {
+			return noToolSupportError(cfg, err)
+		}
+		return fmt.Errorf(errMsgProbeToolFailed, err)
+	}
+	return nil
+}
+
+// checkToolSupport runs ProbeToolSupport for the tools of a task. The probe
+// request is only sent when agent.probe_tool_support is enabled for an
+// OpenAI-compatible provider.
+func checkToolSupport(ctx context.Context, cfg *config.Config, chatModel model.ToolCallingChatModel, tools []tool.BaseTool) error {
+	generate := cfg.Agent.ProbeToolSupport && cfg.LLM.Type == config.LLMProviderOpenAI
+	return ProbeToolSupport(ctx, cfg, chatModel, tools, generate)
+}
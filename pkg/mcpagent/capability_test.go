@@ -0,0 +1,78 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAgentFailsWithoutToolSupport(t *testing.T) {
+	mockModel := new(MockToolCallingChatModel)
+	mockModel.On("WithTools", mock.Anything).Return(nil, errors.New("tools are not supported by this model"))
+	cleanupCalls := 0
+	mockConfig := newLifecycleMockConfig(mockModel, func() { cleanupCalls++ })
+	mockConfig.Config.LLM.Model = "text-embedding-3-small"
+
+	_, err := newAgent(context.Background(), &mockConfig.Config, mockConfig)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrModelNoToolSupport)
+	assert.Contains(t, err.Error(), "text-embedding-3-small")
+	assert.Contains(t, err.Error(), "请选择支持工具调用的模型")
+	assert.Equal(t, 1, cleanupCalls, "失败时释放工具")
+	// 在任务开始前失败，没有发送任何请求
+	mockModel.AssertNotCalled(t, "Generate", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProbeToolSupportRequest(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{LLM: config.LLMConfig{Type: config.LLMProviderOpenAI, Model: "gemma"}}
+
+	mockModel := new(MockToolCallingChatModel)
+	var bound []*schema.ToolInfo
+	mockModel.On("WithTools", mock.Anything).Run(func(args mock.Arguments) {
+		bound = args.Get(0).([]*schema.ToolInfo)
+	}).Return(mockModel, nil)
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New(`registry.ollama.ai/library/gemma:2b does not support tools`)).Once()
+	err := ProbeToolSupport(ctx, cfg, mockModel, []tool.BaseTool{&echoTool{}}, true)
+	assert.ErrorIs(t, err, ErrModelNoToolSupport)
+	require.Len(t, bound, 1)
+	assert.Equal(t, "ping", bound[0].Name, "探测请求只带测试工具")
+
+	// 其他错误不视为不支持工具调用
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused")).Once()
+	err = ProbeToolSupport(ctx, cfg, mockModel, nil, true)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrModelNoToolSupport)
+
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(schema.AssistantMessage("pong", nil), nil).Once()
+	assert.NoError(t, ProbeToolSupport(ctx, cfg, mockModel, nil, true))
+}
+
+func TestCheckToolSupportProbesOnlyWhenEnabled(t *testing.T) {
+	ctx := context.Background()
+	mockModel := new(MockToolCallingChatModel)
+	mockModel.On("WithTools", mock.Anything).Return(mockModel, nil)
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(schema.AssistantMessage("pong", nil), nil)
+	tools := []tool.BaseTool{&echoTool{}}
+
+	cfg := &config.Config{LLM: config.LLMConfig{Type: config.LLMProviderOpenAI}}
+	require.NoError(t, checkToolSupport(ctx, cfg, mockModel, tools))
+	mockModel.AssertNotCalled(t, "Generate", mock.Anything, mock.Anything, mock.Anything)
+
+	cfg.Agent.ProbeToolSupport = true
+	cfg.LLM.Type = config.LLMProviderOllama
+	require.NoError(t, checkToolSupport(ctx, cfg, mockModel, tools))
+	mockModel.AssertNotCalled(t, "Generate", mock.Anything, mock.Anything, mock.Anything)
+
+	cfg.LLM.Type = config.LLMProviderOpenAI
+	require.NoError(t, checkToolSupport(ctx, cfg, mockModel, tools))
+	mockModel.AssertNumberOfCalls(t, "Generate", 1)
+}
@@ -0,0 +1,122 @@
+package mcpagent
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/flow/agent/react"
+	"github.com/cloudwego/eino/schema"
+)
+
+// MaxCheckpointToolResult is the maximum size in bytes of a tool result stored
+// in a checkpoint, longer results are cut and marked as truncated
+const MaxCheckpointToolResult = 16 * 1024
+
+// Checkpoint is the progress of a task before a model call: the messages of
+// the conversation so far, without the system prompt, and the number of model calls
+type Checkpoint struct {
+	Step     int               `json:"step"`     // 本次模型调用的序号，从1开始
+	Messages []*schema.Message `json:"messages"` // 不含系统提示词的对话消息
+	SavedAt  time.Time         `json:"saved_at"` // 检查点的创建时间
+}
+
+// CheckpointNotify is an optional extension of Notify for handlers saving the
+// progress of long-running tasks. OnCheckpoint is called from the agent
+// goroutine every CheckpointInterval model calls, before the model is called.
+type CheckpointNotify interface {
+	Notify
+
+	// CheckpointInterval returns the number of model calls between checkpoints, 0 disables them
+	CheckpointInterval() int
+
+	// OnCheckpoint receives a checkpoint, its messages must not be modified
+	OnCheckpoint(checkpoint Checkpoint)
+}
+
+// checkpointCallback counts the model calls of the agent and creates checkpoints
+type checkpointCallback struct {
+	notify   CheckpointNotify
+	interval int
+	messages *Messages
+
+	mutex    sync.Mutex
+	step     int
+	lastSeen int // 上一次模型调用的消息数，用于忽略同一次调用的重复回调
+}
+
+// newCheckpointHandler creates the callback handler sending checkpoints to notify,
+// nil when notify disables checkpoints
+func newCheckpointHandler(notify CheckpointNotify, messages *Messages) callbacks.Handler {
+	interval := notify.CheckpointInterval()
+	if interval <= 0 {
+		return nil
+	}
+	cb := &checkpointCallback{notify: notify, interval: interval, messages: messages}
+	return callbacks.NewHandlerBuilder().OnStartFn(cb.onStart).Build()
+}
+
+func (cb *checkpointCallback) onStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
+	defer recoverCallback("OnCheckpoint")
+	if info == nil || info.Component != components.ComponentOfChatModel || info.Name != react.ModelNodeName {
+		return ctx
+	}
+	modelInput := model.ConvCallbackInput(input)
+	if modelInput == nil {
+		return ctx
+	}
+
+	cb.mutex.Lock()
+	// 每次模型调用的消息都比上一次多，数量相同的是同一次调用
+	if len(modelInput.Messages) <= cb.lastSeen {
+		cb.mutex.Unlock()
+		return ctx
+	}
+	cb.lastSeen = len(modelInput.Messages)
+	cb.step++
+	step := cb.step
+	cb.mutex.Unlock()
+
+	if step%cb.interval != 0 {
+		return ctx
+	}
+	cb.notify.OnCheckpoint(Checkpoint{
+		Step:     step,
+		Messages: checkpointMessages(modelInput.Messages, cb.messages),
+		SavedAt:  time.Now(),
+	})
+	return ctx
+}
+
+// checkpointMessages copies the messages of a model call for a checkpoint.
+// The system prompt is dropped and tool results longer than
+// MaxCheckpointToolResult are truncated.
+func checkpointMessages(input []*schema.Message, messages *Messages) []*schema.Message {
+	result := make([]*schema.Message, 0, len(input))
+	for _, msg := range input {
+		if msg == nil || msg.Role == schema.System {
+			continue
+		}
+		copied := *msg
+		if copied.Role == schema.Tool && len(copied.Content) > MaxCheckpointToolResult {
+			copied.Content = TruncateUTF8(copied.Content, MaxCheckpointToolResult) + messages.ContentTruncated
+		}
+		result = append(result, &copied)
+	}
+	return result
+}
+
+// TruncateUTF8 cuts s to at most n bytes without splitting a character
+func TruncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,104 @@
+package mcpagent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/flow/agent/react"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkpointRecordingNotify 记录检查点的通知器
+type checkpointRecordingNotify struct {
+	MockNotify
+	interval    int
+	checkpoints []Checkpoint
+}
+
+func (n *checkpointRecordingNotify) CheckpointInterval() int { return n.interval }
+
+func (n *checkpointRecordingNotify) OnCheckpoint(checkpoint Checkpoint) {
+	n.checkpoints = append(n.checkpoints, checkpoint)
+}
+
+func TestCheckpointHandler(t *testing.T) {
+	ctx := context.Background()
+	notify := &checkpointRecordingNotify{interval: 2}
+	handler := newCheckpointHandler(notify, MessagesFor(""))
+	require.NotNil(t, handler)
+
+	modelInfo := &callbacks.RunInfo{Name: react.ModelNodeName, Component: components.ComponentOfChatModel}
+	call := schema.ToolCall{ID: "call_1", Function: schema.FunctionCall{Name: "fetch", Arguments: `{"url":"http://example.com"}`}}
+	messages := []*schema.Message{schema.SystemMessage("系统提示词"), schema.UserMessage("分析example.com")}
+
+	// 第1次模型调用，同一次调用的重复回调不计数
+	handler.OnStart(ctx, modelInfo, &model.CallbackInput{Messages: messages})
+	handler.OnStart(ctx, modelInfo, &model.CallbackInput{Messages: messages})
+	assert.Empty(t, notify.checkpoints)
+
+	// 工具和其他模型不计数
+	handler.OnStart(ctx, &callbacks.RunInfo{Name: "fetch", Component: components.ComponentOfTool}, nil)
+	handler.OnStart(ctx, &callbacks.RunInfo{Name: "summarize", Component: components.ComponentOfChatModel}, &model.CallbackInput{Messages: messages[:1]})
+
+	// 第2次模型调用保存检查点
+	longResult := strings.Repeat("页", MaxCheckpointToolResult)
+	messages = append(messages, schema.AssistantMessage("", []schema.ToolCall{call}), schema.ToolMessage(longResult, "call_1"))
+	handler.OnStart(ctx, modelInfo, &model.CallbackInput{Messages: messages})
+
+	require.Len(t, notify.checkpoints, 1)
+	checkpoint := notify.checkpoints[0]
+	assert.Equal(t, 2, checkpoint.Step)
+	require.Len(t, checkpoint.Messages, 3, "不保存系统提示词")
+	assert.Equal(t, "分析example.com", checkpoint.Messages[0].Content)
+	assert.Equal(t, []schema.ToolCall{call}, checkpoint.Messages[1].ToolCalls)
+
+	result := checkpoint.Messages[2]
+	assert.Equal(t, "call_1", result.ToolCallID)
+	assert.LessOrEqual(t, len(result.Content), MaxCheckpointToolResult+len(truncateSuffix))
+	assert.True(t, strings.HasSuffix(result.Content, truncateSuffix))
+	assert.Equal(t, longResult, messages[3].Content, "原消息不变")
+
+	// 禁用检查点时不创建处理器
+	assert.Nil(t, newCheckpointHandler(&checkpointRecordingNotify{}, MessagesFor("")))
+}
+
+func TestValidateHistoryToolResults(t *testing.T) {
+	call := schema.ToolCall{ID: "call_1", Function: schema.FunctionCall{Name: "fetch"}}
+
+	assert.NoError(t, validateHistory([]*schema.Message{
+		schema.UserMessage("分析example.com"),
+		schema.AssistantMessage("", []schema.ToolCall{call}),
+		schema.ToolMessage("结果", "call_1"),
+		schema.AssistantMessage("完成", nil),
+	}))
+
+	// 工具结果没有对应的调用
+	assert.Error(t, validateHistory([]*schema.Message{
+		schema.UserMessage("分析example.com"),
+		schema.ToolMessage("结果", "call_1"),
+	}))
+	// 工具调用没有结果
+	assert.Error(t, validateHistory([]*schema.Message{
+		schema.UserMessage("分析example.com"),
+		schema.AssistantMessage("", []schema.ToolCall{call}),
+	}))
+	assert.Error(t, validateHistory([]*schema.Message{
+		schema.AssistantMessage("", []schema.ToolCall{call}),
+		schema.UserMessage("继续"),
+	}))
+	assert.Error(t, validateHistory([]*schema.Message{schema.SystemMessage("系统提示词")}))
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "abc", TruncateUTF8("abc", 5))
+	assert.Equal(t, "ab", TruncateUTF8("abc", 2))
+	// 不会截断多字节字符
+	assert.Equal(t, "网", TruncateUTF8("网页", 5))
+	assert.Equal(t, "", TruncateUTF8("网页", 2))
+}
@@ -3,10 +3,52 @@
 package mcpagent
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/notes"
+)
+
+// OutputFormat selects how CliNotifier presents the task
+type OutputFormat string
+
+const (
+	// OutputPlain prints every notification as a line of text
+	OutputPlain OutputFormat = "plain"
+	// OutputMarkdown prints the notifications dimmed and renders the result as markdown
+	OutputMarkdown OutputFormat = "markdown"
+	// OutputJSON prints nothing until Finish writes a single CliReport
+	OutputJSON OutputFormat = "json"
 )
 
+// ParseOutputFormat returns the output format named name, empty means OutputPlain
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch format := OutputFormat(strings.ToLower(strings.TrimSpace(name))); format {
+	case "":
+		return OutputPlain, nil
+	case OutputPlain, OutputMarkdown, OutputJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("不支持的输出格式: %s（可选 plain、markdown、json）", name)
+	}
+}
+
+// CliReport is the JSON object CliNotifier writes in OutputJSON format: the
+// RunResult of the task with the notes and the error of the task
+type CliReport struct {
+	RunResult
+	Notes []notes.Note `json:"notes,omitempty"` // agent.enable_notes时任务保存的笔记
+	Error string       `json:"error,omitempty"`
+}
+
+// cliResultPreviewLength is the number of characters of a tool result CliNotifier prints
+const cliResultPreviewLength = 80
+
 // CliNotifier implements the Notify interface for command-line interface output.
 // It provides simple console-based notifications suitable for CLI applications.
 // All notifications are printed directly to stdout/stderr for immediate user feedback.
@@ -14,11 +56,31 @@ import (
 // This implementation is thread-safe and can be used concurrently from multiple
 // goroutines without additional synchronization.
 //
+// The zero value prints plain text to stdout/stderr. NewFormattedCliNotifier
+// selects another OutputFormat; OutputJSON writes the RunResult passed to
+// Finish.
+//
 // Example usage:
 //
 //	notifier := mcpagent.NewCliNotifier()
 //	err := mcpagent.Run(ctx, cfg, "task description", notifier)
-type CliNotifier struct{}
+//
+// In OutputJSON format run the task with RunWithResult:
+//
+//	notifier := mcpagent.NewFormattedCliNotifier(mcpagent.OutputJSON, os.Stdout, os.Stderr)
+//	result, err := mcpagent.RunWithResult(ctx, cfg, "task description", notifier)
+//	notifier.Finish(result, err)
+type CliNotifier struct {
+	format OutputFormat
+	out    io.Writer // 为nil时使用os.Stdout
+	errOut io.Writer // 为nil时使用os.Stderr
+
+	mu       sync.Mutex
+	notes    []notes.Note // 任务保存的笔记，OutputJSON格式写入报告
+	errMsg   string       // 通知的错误，OutputJSON格式写入报告
+	finished bool
+	streamed strings.Builder // OutputPlain格式已打印、还没有换行的最终回答片段
+}
 
 // NewCliNotifier creates a new CLI notifier instance.
 // This is the preferred way to create a CliNotifier and provides
@@ -35,6 +97,100 @@ func NewCliNotifier() *CliNotifier {
 	return &CliNotifier{}
 }
 
+// NewFormattedCliNotifier creates a CLI notifier presenting the task in format.
+//
+// Parameters:
+//   - format: Output format, empty means OutputPlain
+//   - out: Writer of the notifications and the result
+//   - errOut: Writer of the errors, ignored in OutputJSON format
+//
+// Returns:
+//   - *CliNotifier: A new CLI notifier, call Finish after the task
+func NewFormattedCliNotifier(format OutputFormat, out io.Writer, errOut io.Writer) *CliNotifier {
+	return &CliNotifier{format: format, out: out, errOut: errOut}
+}
+
+// Finish writes the CliReport of the task in OutputJSON format. Other
+// formats have printed everything already. Only the first call writes the
+// report.
+//
+// Parameters:
+//   - result: RunResult returned by RunWithResult, nil reports an empty result
+//   - err: Error returned by RunWithResult
+//
+// Returns:
+//   - error: Error writing the report
+func (n *CliNotifier) Finish(result *RunResult, err error) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.format != OutputJSON || n.finished {
+		return nil
+	}
+	n.finished = true
+
+	report := CliReport{Notes: n.notes, Error: n.errMsg}
+	if result != nil {
+		report.RunResult = *result
+	}
+	if err != nil && report.Error == "" {
+		report.Error = err.Error()
+	}
+	if report.ToolCalls == nil {
+		report.ToolCalls = []ToolCallRecord{}
+	}
+	encoder := json.NewEncoder(n.stdout())
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// stdout returns the writer of the notifications
+func (n *CliNotifier) stdout() io.Writer {
+	if n.out == nil {
+		return os.Stdout
+	}
+	return n.out
+}
+
+// stderr returns the writer of the errors
+func (n *CliNotifier) stderr() io.Writer {
+	if n.errOut == nil {
+		return os.Stderr
+	}
+	return n.errOut
+}
+
+// progress prints an intermediate notification, dimmed in OutputMarkdown
+// format and dropped in OutputJSON format
+func (n *CliNotifier) progress(format string, args ...any) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.endStream()
+	switch n.format {
+	case OutputJSON:
+		return
+	case OutputMarkdown:
+		text := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintln(n.stdout(), ansiDim+line+ansiReset)
+		}
+	default:
+		fmt.Fprintf(n.stdout(), format, args...)
+	}
+}
+
+// endStream ends the line of the streamed answer chunks, if any, and
+// returns the streamed answer. n.mu must be held.
+func (n *CliNotifier) endStream() string {
+	if n.streamed.Len() == 0 {
+		return ""
+	}
+	fmt.Fprintln(n.stdout())
+	streamed := n.streamed.String()
+	n.streamed.Reset()
+	return streamed
+}
+
 // OnMessage prints a message notification to stdout.
 // This is typically used for progress updates and informational messages
 // during agent execution. Messages are printed with a newline for readability.
@@ -49,12 +205,14 @@ func NewCliNotifier() *CliNotifier {
 //	notifier.OnMessage("正在分析网站结构...")
 //	notifier.OnMessage("发现3个潜在安全问题")
 func (n *CliNotifier) OnMessage(msg string) {
-	fmt.Println("消息:", msg)
+	n.progress("消息: %s\n", msg)
 }
 
 // OnResult prints a result notification to stdout.
 // This is used when the agent completes successfully with a final result.
 // The result is printed to stdout to allow for easy redirection and processing.
+// OutputMarkdown renders it as markdown, OutputJSON reports the result passed
+// to Finish instead.
 //
 // Parameters:
 //   - msg: The final result message from the agent
@@ -63,7 +221,47 @@ func (n *CliNotifier) OnMessage(msg string) {
 //
 //	notifier.OnResult("分析完成：网站安全评分为85分")
 func (n *CliNotifier) OnResult(msg string) {
-	fmt.Println("结果:", msg)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch n.format {
+	case OutputJSON:
+		return
+	case OutputMarkdown:
+		fmt.Fprintf(n.stdout(), "%s结果:%s\n%s\n", ansiBold, ansiReset, renderMarkdown(msg))
+	default:
+		// 流式打印过的回答不再重复，后处理改变了回答时打印最终结果
+		if n.endStream() == msg {
+			return
+		}
+		fmt.Fprintln(n.stdout(), "结果:", msg)
+	}
+}
+
+// OnStreamResult prints a chunk of the final answer to stdout as it arrives,
+// without a newline, so that a long answer shows up while it is generated.
+// OnResult ends the line and prints the result again only if it differs from
+// the chunks, e.g. after output post-processing. OutputMarkdown renders and
+// OutputJSON reports only the final result, they ignore the chunks.
+//
+// Parameters:
+//   - chunk: A chunk of the final answer
+//
+// Example:
+//
+//	notifier.OnStreamResult("分析完成：")
+//	notifier.OnStreamResult("网站安全评分为85分")
+//	// Output: 结果: 分析完成：网站安全评分为85分
+func (n *CliNotifier) OnStreamResult(chunk string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.format != "" && n.format != OutputPlain {
+		return
+	}
+	if n.streamed.Len() == 0 {
+		fmt.Fprint(n.stdout(), "结果: ")
+	}
+	fmt.Fprint(n.stdout(), chunk)
+	n.streamed.WriteString(chunk)
 }
 
 // OnError prints an error notification to stderr.
@@ -71,6 +269,7 @@ func (n *CliNotifier) OnResult(msg string) {
 // and allow for proper error handling in shell scripts and pipelines.
 //
 // The error is formatted with a clear "错误:" prefix for easy identification.
+// OutputJSON reports the error in the report instead.
 //
 // Parameters:
 //   - err: The error that occurred during agent execution
@@ -80,7 +279,14 @@ func (n *CliNotifier) OnResult(msg string) {
 //	notifier.OnError(fmt.Errorf("无法连接到目标服务器"))
 //	// Output: 错误: 无法连接到目标服务器
 func (n *CliNotifier) OnError(err error) {
-	fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.format == OutputJSON {
+		n.errMsg = err.Error()
+		return
+	}
+	n.endStream()
+	fmt.Fprintf(n.stderr(), "错误: %v\n", err)
 }
 
 // OnThinking prints a thinking notification to stdout.
@@ -94,7 +300,7 @@ func (n *CliNotifier) OnError(err error) {
 //	notifier.OnThinking("正在思考中...")
 //	// Output: 思考中: 正在思考中...
 func (n *CliNotifier) OnThinking(msg string) {
-	fmt.Println("思考中:", msg)
+	n.progress("思考中: %s\n", msg)
 }
 
 // OnToolCall prints a tool call notification to stdout.
@@ -107,7 +313,131 @@ func (n *CliNotifier) OnThinking(msg string) {
 // Example:
 //
 //	notifier.OnToolCall("web_search", map[string]interface{}{"query": "test query"})
-//	// Output: 正在调用工具: web_search, 参数: map[query:test query]
+//	// Output: 正在调用工具: web_search, 参数: {"query":"test query"}
 func (n *CliNotifier) OnToolCall(toolName string, params any) {
-	fmt.Printf("正在调用工具: %s, 参数: %v\n", toolName, params)
+	n.progress("正在调用工具: %s, 参数: %s\n", toolName, FormatArguments(params))
+}
+
+// OnThinkingWithCall prints a thinking notification with the tool call it led to.
+//
+// Parameters:
+//   - msg: The thinking message from the agent
+//   - relatedCallID: ID of the tool call that follows the thinking
+//
+// Example:
+//
+//	notifier.OnThinkingWithCall("需要先搜索相关资料", "call_1")
+//	// Output: 思考中[call_1]: 需要先搜索相关资料
+func (n *CliNotifier) OnThinkingWithCall(msg string, relatedCallID string) {
+	n.progress("思考中[%s]: %s\n", relatedCallID, msg)
+}
+
+// OnToolCallWithID prints a tool call notification with its call ID.
+//
+// Parameters:
+//   - callID: ID of the tool call
+//   - toolName: The name of the tool being called
+//   - params: The parameters for the tool call
+//
+// Example:
+//
+//	notifier.OnToolCallWithID("call_1", "web_search", map[string]interface{}{"query": "test"})
+//	// Output: 正在调用工具[call_1]: web_search, 参数: {"query":"test"}
+func (n *CliNotifier) OnToolCallWithID(callID string, toolName string, params any) {
+	n.progress("正在调用工具[%s]: %s, 参数: %s\n", callID, toolName, FormatArguments(params))
+}
+
+// OnPlan prints the execution plan generated in plan mode.
+//
+// Parameters:
+//   - plan: The execution plan from the agent
+//
+// Example:
+//
+//	notifier.OnPlan("1. 搜索相关资料\n2. 总结结果")
+//	// Output: 执行计划:
+//	// 1. 搜索相关资料
+//	// 2. 总结结果
+func (n *CliNotifier) OnPlan(plan string) {
+	n.progress("执行计划:\n%s\n", plan)
+}
+
+// OnNotes prints the notes saved by the task after the result, OutputJSON
+// keeps them for the report.
+//
+// Parameters:
+//   - saved: Notes of the task in alphabetical order of their keys
+//
+// Example:
+//
+//	notifier.OnNotes([]notes.Note{{Key: "target", Content: "1.1.1.1"}})
+//	// Output: 笔记:
+//	// - target: 1.1.1.1
+func (n *CliNotifier) OnNotes(saved []notes.Note) {
+	n.mu.Lock()
+	if n.format == OutputJSON {
+		n.notes = saved
+		n.mu.Unlock()
+		return
+	}
+	n.mu.Unlock()
+
+	var b strings.Builder
+	for _, note := range saved {
+		fmt.Fprintf(&b, "- %s: %s\n", note.Key, note.Content)
+	}
+	n.progress("笔记:\n%s", b.String())
+}
+
+// OnToolCallResult prints a short summary of a tool call result: its
+// length, the duration of the call when known and the first
+// cliResultPreviewLength characters of the result on one line.
+//
+// Parameters:
+//   - toolName: The name of the tool that was called
+//   - result: The result returned by the tool
+//   - err: The error returned by the tool, nil on success
+//   - duration: How long the tool call took, zero if unknown
+//
+// Example:
+//
+//	notifier.OnToolCallResult("web_search", "abc", nil, time.Second)
+//	// Output: 工具结果: web_search, 长度: 3, 耗时: 1s, 内容: abc
+func (n *CliNotifier) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	n.printToolResult("", toolName, result, err, duration)
+}
+
+// OnToolResult prints a short summary of a tool call result with its call ID,
+// see OnToolCallResult.
+//
+// Example:
+//
+//	notifier.OnToolResult("call_1", "web_search", "abc", nil, time.Second)
+//	// Output: 工具结果[call_1]: web_search, 长度: 3, 耗时: 1s, 内容: abc
+func (n *CliNotifier) OnToolResult(callID string, toolName string, result string, err error, duration time.Duration) {
+	n.printToolResult("["+callID+"]", toolName, result, err, duration)
+}
+
+// printToolResult prints the summary of a tool call result, label follows
+// the kind of the line
+func (n *CliNotifier) printToolResult(label string, toolName string, result string, err error, duration time.Duration) {
+	var elapsed string
+	if duration > 0 {
+		elapsed = fmt.Sprintf(", 耗时: %s", duration.Round(time.Millisecond))
+	}
+	if err != nil {
+		n.progress("工具失败%s: %s%s, 错误: %v\n", label, toolName, elapsed, err)
+		return
+	}
+	n.progress("工具结果%s: %s, 长度: %d%s, 内容: %s\n", label, toolName, len(result), elapsed, resultPreview(result))
+}
+
+// resultPreview returns the first cliResultPreviewLength characters of a
+// tool result with its whitespace collapsed to single spaces
+func resultPreview(result string) string {
+	preview := []rune(strings.Join(strings.Fields(result), " "))
+	if len(preview) <= cliResultPreviewLength {
+		return string(preview)
+	}
+	return string(preview[:cliResultPreviewLength]) + "..."
 }
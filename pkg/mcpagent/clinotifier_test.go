@@ -0,0 +1,136 @@
+package mcpagent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "更新testdata中的golden文件")
+
+// playCliScript sends a task with two rounds of tool calls and a markdown
+// result to notifier and returns the RunResult recorded from it
+func playCliScript(cli *CliNotifier) *RunResult {
+	recorder := &runRecorder{}
+	notifier := NewMultiNotify(cli, recorder).(*multiStreamingNotify)
+	notifier.OnMessage("警告: 工具 fetch 不存在")
+	notifier.OnPlan("1. 搜索\n2. 总结")
+	notifier.OnThinkingWithCall("先搜索相关资料", "call_1")
+	notifier.OnToolCallWithID("call_1", "search", map[string]any{"query": "mcp"})
+	notifier.OnToolCallWithID("call_2", "search", map[string]any{"query": "agent"})
+	notifier.OnToolResult("call_1", "search", "两条结果：\nmcp\nagent", nil, 1234*time.Millisecond)
+	notifier.OnToolResult("call_2", "search", "", errors.New("超时"), 30*time.Second)
+	notifier.OnToolCallWithID("call_3", "fetch_url", map[string]any{"url": "https://example.com"})
+	notifier.OnToolResult("call_3", "fetch_url", "<html></html>", nil, 0)
+	notifier.OnResult("# 结论\n\n| 名称 | 状态 |\n|---|---|\n| MCP协议 | **可用** |\n| agent | `ok` |\n\n- 第一项\n- 详见 [文档](https://example.com)\n\n```\ncode block\n```")
+	return recorder.runResult()
+}
+
+func TestCliNotifierGolden(t *testing.T) {
+	for _, format := range []OutputFormat{OutputPlain, OutputMarkdown, OutputJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			notifier := NewFormattedCliNotifier(format, &stdout, &stderr)
+			result := playCliScript(notifier)
+			require.NoError(t, notifier.Finish(result, nil))
+			require.NoError(t, notifier.Finish(result, nil), "重复调用Finish不再输出")
+
+			golden := filepath.Join("testdata", "cli_output", string(format)+".golden")
+			if *updateGolden {
+				require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0755))
+				require.NoError(t, os.WriteFile(golden, stdout.Bytes(), 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), stdout.String())
+			assert.Empty(t, stderr.String())
+		})
+	}
+}
+
+func TestCliNotifierToolResultPreview(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	notifier := NewFormattedCliNotifier(OutputPlain, &stdout, &stderr)
+	// 长结果只打印前cliResultPreviewLength个字符
+	notifier.OnToolResult("call_1", "fetch", strings.Repeat("网页", cliResultPreviewLength), nil, 0)
+	assert.Equal(t, "工具结果[call_1]: fetch, 长度: 480, 内容: "+strings.Repeat("网页", cliResultPreviewLength/2)+"...\n", stdout.String())
+}
+
+func TestCliNotifierErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	notifier := NewFormattedCliNotifier(OutputPlain, &stdout, &stderr)
+	notifier.OnError(errors.New("连接失败"))
+	assert.Equal(t, "错误: 连接失败\n", stderr.String())
+	assert.Empty(t, stdout.String())
+
+	// JSON格式的错误只写入报告
+	stdout.Reset()
+	stderr.Reset()
+	notifier = NewFormattedCliNotifier(OutputJSON, &stdout, &stderr)
+	require.NoError(t, notifier.Finish(nil, errors.New("执行任务失败: 超时")))
+	assert.Equal(t, "{\n  \"result\": \"\",\n  \"steps\": 0,\n  \"tool_calls\": [],\n  \"error\": \"执行任务失败: 超时\"\n}\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestCliNotifierReportVerification(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	notifier := NewFormattedCliNotifier(OutputJSON, &stdout, &stderr)
+	notifier.OnResult("评分85")
+	// 报告只来自RunResult，通知中的结果不会重复计入
+	result := &RunResult{Result: "评分85", Steps: 1, Verification: &Verification{Supported: []string{"评分85"}, Unsupported: []string{}}}
+	require.NoError(t, notifier.Finish(result, nil))
+
+	var report CliReport
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, "评分85", report.Result)
+	assert.Equal(t, 1, report.Steps)
+	assert.Equal(t, []ToolCallRecord{}, report.ToolCalls)
+	require.NotNil(t, report.Verification)
+	assert.Equal(t, []string{"评分85"}, report.Verification.Supported)
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	format, err := ParseOutputFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, OutputPlain, format)
+	format, err = ParseOutputFormat(" JSON ")
+	require.NoError(t, err)
+	assert.Equal(t, OutputJSON, format)
+	_, err = ParseOutputFormat("html")
+	assert.Error(t, err)
+}
+
+func TestCliNotifierStreamResult(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	notifier := NewFormattedCliNotifier(OutputPlain, &stdout, &stderr)
+	notifier.OnStreamResult("分析完成：")
+	notifier.OnStreamResult("评分85")
+	notifier.OnResult("分析完成：评分85")
+	// 片段打印在同一行，最终结果不重复打印
+	assert.Equal(t, "结果: 分析完成：评分85\n", stdout.String())
+
+	// 其他通知和后处理改变的结果另起一行
+	stdout.Reset()
+	notifier.OnStreamResult("原始回答")
+	notifier.OnMessage("校验结果")
+	notifier.OnResult("处理后的回答")
+	assert.Equal(t, "结果: 原始回答\n消息: 校验结果\n结果: 处理后的回答\n", stdout.String())
+
+	// markdown和JSON格式只输出最终结果
+	for _, format := range []OutputFormat{OutputMarkdown, OutputJSON} {
+		stdout.Reset()
+		notifier = NewFormattedCliNotifier(format, &stdout, &stderr)
+		notifier.OnStreamResult("片段")
+		assert.Empty(t, stdout.String(), format)
+	}
+	assert.Empty(t, stderr.String())
+}
@@ -0,0 +1,270 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrAgentClosed is returned by Agent.Execute after the agent has been closed
+var ErrAgentClosed = errors.New("agent已关闭")
+
+// resourceProvider creates the tools and chat model of an Agent.
+// *config.Config implements it; tests substitute mocks.
+type resourceProvider interface {
+	GetTools(ctx context.Context) ([]tool.BaseTool, func(), error)
+	GetModel(ctx context.Context) (model.ToolCallingChatModel, error)
+}
+
+// Agent is an MCP agent whose tools and model are created once and reused
+// by every task, so MCP servers are only connected when the agent is created.
+//
+// Execute calls are serialized: an Agent runs one task at a time and concurrent
+// calls wait for the running task to finish. Create one Agent per concurrent
+// task stream if tasks must run in parallel.
+//
+// Example:
+//
+//	agent, err := mcpagent.New(ctx, cfg)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer agent.Close()
+//
+//	for _, task := range tasks {
+//		if err := agent.Execute(ctx, task, notify); err != nil {
+//			log.Printf("任务执行失败: %v", err)
+//		}
+//	}
+type Agent struct {
+	cfg      *config.Config
+	tools    []tool.BaseTool
+	model    model.ToolCallingChatModel
+	cleanup  func()
+	warnings []string             // 创建时发现的MCP服务器配置冲突，每次执行时通知
+	missing  []config.MissingTool // 创建时跳过的不存在的工具，每次执行时通知
+
+	releaseTaskDir func() // 释放创建时准备的任务目录
+
+	mu     sync.Mutex // 串行化Execute，并保护closed
+	closed bool
+}
+
+// New connects the tools and creates the chat model described by cfg.
+// The connections to MCP servers stay open until Close is called, so ctx
+// should live at least as long as the agent.
+//
+// When stdio MCP servers reference config.TaskDirPlaceholder, a fresh task
+// directory is created for the agent and released by Close, so every Run
+// gets its own working directory.
+//
+// Parameters:
+//   - ctx: Context used to connect tools and create the model
+//   - cfg: Configuration containing model, tool, and system settings
+//
+// Returns:
+//   - *Agent: Agent ready to execute tasks
+//   - error: Error if the configuration is nil or the tools or model cannot be created
+func New(ctx context.Context, cfg *config.Config) (*Agent, error) {
+	if cfg == nil {
+		return nil, errors.New(errMsgConfigNil)
+	}
+
+	cfg, releaseTaskDir, err := cfg.PrepareTaskDir()
+	if err != nil {
+		return nil, err
+	}
+	agent, err := newAgent(ctx, cfg, cfg)
+	if err != nil {
+		releaseTaskDir()
+		return nil, err
+	}
+	agent.releaseTaskDir = releaseTaskDir
+	return agent, nil
+}
+
+// newAgent creates an Agent with tools and model from provider
+func newAgent(ctx context.Context, cfg *config.Config, provider resourceProvider) (*Agent, error) {
+	// 获取工具
+	var missing []config.MissingTool
+	einoTools, cleanup, err := provider.GetTools(config.WithMissingToolsHandler(ctx, func(tools []config.MissingTool) {
+		missing = tools
+	}))
+	if err != nil {
+		return nil, fmt.Errorf(errMsgGetToolsFailed, err)
+	}
+
+	// 获取模型
+	toolableChatModel, err := provider.GetModel(ctx)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf(errMsgGetModelFailed, err)
+	}
+	if err := checkToolSupport(ctx, cfg, toolableChatModel, einoTools); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	return &Agent{
+		cfg:      cfg,
+		tools:    einoTools,
+		model:    toolableChatModel,
+		cleanup:  cleanup,
+		warnings: cfg.MCP.MergeWarnings(),
+		missing:  missing,
+	}, nil
+}
+
+// Execute runs a task with the tools and model of the agent.
+// A new ReAct agent is created for every task, so tasks do not share
+// conversation state. cfg.TaskTimeout applies to each task.
+//
+// Parameters:
+//   - ctx: Context for controlling execution flow and cancellation
+//   - task: Task description to execute (must not be empty)
+//   - notify: Notification handler for progress updates and results
+//
+// Returns:
+//   - error: ErrAgentClosed after Close, or an error if execution fails
+func (a *Agent) Execute(ctx context.Context, task string, notify Notify) error {
+	if err := validateRunParameters(a.cfg, task, notify); err != nil {
+		return err
+	}
+	return runWithTaskTimeout(ctx, a.cfg, notify, func(ctx context.Context) error {
+		return a.execute(ctx, task, notify)
+	})
+}
+
+// ExecuteWithHistory runs task as a follow-up of an earlier conversation,
+// see RunWithHistory. cfg.TaskTimeout applies to the task.
+//
+// Parameters:
+//   - ctx: Context for controlling execution flow and cancellation
+//   - history: Earlier user and assistant messages, oldest first
+//   - task: Follow-up instruction to execute (must not be empty)
+//   - notify: Notification handler for progress updates and results
+//
+// Returns:
+//   - error: ErrAgentClosed after Close, or an error if the history is invalid or execution fails
+func (a *Agent) ExecuteWithHistory(ctx context.Context, history []*schema.Message, task string, notify Notify) error {
+	if err := validateRunParameters(a.cfg, task, notify); err != nil {
+		return err
+	}
+	if err := validateHistory(history); err != nil {
+		return err
+	}
+	return runWithTaskTimeout(ctx, a.cfg, notify, func(ctx context.Context) error {
+		_, err := a.executeConversation(ctx, nil, history, task, notify)
+		return err
+	})
+}
+
+// execute runs a task without applying the task timeout
+func (a *Agent) execute(ctx context.Context, task string, notify Notify) error {
+	_, err := a.executeConversation(ctx, nil, nil, task, notify)
+	return err
+}
+
+// executeConversation runs a task after history without applying the task
+// timeout. system are the system messages formatted by formatSystemMessages,
+// nil to format them for this task.
+//
+// Returns:
+//   - *schema.Message: Assistant message with the answer
+//   - error: ErrAgentClosed after Close, or an error if execution fails
+func (a *Agent) executeConversation(ctx context.Context, system []*schema.Message, history []*schema.Message, task string, notify Notify) (*schema.Message, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, ErrAgentClosed
+	}
+
+	messages := messagesOf(a.cfg)
+	for _, warning := range a.warnings {
+		notify.OnMessage(messages.WarningPrefix + warning)
+	}
+	notifyMissingTools(a.cfg, notify, a.missing)
+	if err := checkToolsRequired(ctx, a.cfg, a.model, a.tools, task, notify); err != nil {
+		return nil, err
+	}
+
+	// 每个任务都从主模型开始，切换到备用模型后在本任务内保持
+	ctx, tracker := trackModels(ctx, a.cfg, notify)
+	ctx = withResultFilterMessages(ctx, a.cfg, notify)
+	ctx = withSanitizerMessages(ctx, a.cfg, notify)
+	ctx = withResultVerifier(ctx, a.cfg, a.model)
+	ctx = withNotes(ctx, a.cfg, notify)
+	ctx, budget := withTimeBudget(ctx, a.cfg, notify)
+
+	// 创建agent
+	ragent, err := createReActAgent(ctx, a.cfg, a.tools, a.model)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgCreateAgentFailed, err)
+	}
+
+	if system == nil {
+		if system, err = formatSystemMessages(ctx, a.cfg); err != nil {
+			return nil, err
+		}
+	}
+	msg, err := assembleConversation(ctx, a.cfg, system, history, task)
+	if err != nil {
+		return nil, err
+	}
+
+	// 计划模式下先生成执行计划，再带着计划执行任务
+	var plan string
+	if a.cfg.PlanMode {
+		plan, err = generatePlan(ctx, a.cfg, a.model, a.tools, msg)
+		if err != nil {
+			return nil, err
+		}
+		notifyPlan(notify, plan)
+		if plan, err = approvePlan(ctx, a.cfg, plan, notify); err != nil {
+			return nil, err
+		}
+	}
+
+	// 执行任务
+	answer, err := executeAgentConversation(ctx, a.cfg, ragent, msg, plan, notify)
+	if errors.Is(err, compose.ErrExceedMaxSteps) {
+		notify.OnMessage(fmt.Sprintf(messages.MaxStepsExceeded, a.cfg.MaxStep))
+	}
+	if err == nil {
+		notifyAnswerModel(notify, a.cfg, tracker)
+	}
+	notifyNotes(ctx, a.cfg, notify)
+	notifyTimeBudget(notify, a.cfg, budget)
+	return answer, err
+}
+
+// Close releases the tools of the agent, closes the MCP server connections
+// and releases the task directory.
+// It waits for a running task to finish and is safe to call more than once.
+//
+// Returns:
+//   - error: Always nil, kept for io.Closer compatibility
+func (a *Agent) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	if a.cleanup != nil {
+		a.cleanup()
+	}
+	if a.releaseTaskDir != nil {
+		a.releaseTaskDir()
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// answerChatModel 直接回答任务的模拟模型，记录每次绑定的工具
+type answerChatModel struct {
+	mu        sync.Mutex
+	boundWith [][]*schema.ToolInfo
+	calls     int
+}
+
+func (m *answerChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	return schema.AssistantMessage("done: "+input[len(input)-1].Content, nil), nil
+}
+
+func (m *answerChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *answerChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.boundWith = append(m.boundWith, tools)
+	return m, nil
+}
+
+// newLifecycleMockConfig creates a mock config whose GetTools and GetModel may only be called once
+func newLifecycleMockConfig(chatModel model.ToolCallingChatModel, cleanup func()) *MockConfig {
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{
+		MaxStep:      5,
+		SystemPrompt: "test prompt",
+	}
+	mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{&echoTool{}}, cleanup, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+	return mockConfig
+}
+
+// newResultNotify creates a notify accepting all notifications
+func newResultNotify() *MockNotify {
+	notify := new(MockNotify)
+	notify.On("OnMessage", mock.Anything).Maybe()
+	notify.On("OnThinking", mock.Anything).Maybe()
+	notify.On("OnToolCall", mock.Anything, mock.Anything).Maybe()
+	notify.On("OnToolCallResult", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	notify.On("OnResult", mock.Anything).Maybe()
+	notify.On("OnError", mock.Anything).Maybe()
+	return notify
+}
+
+func TestAgentExecuteReusesTools(t *testing.T) {
+	ctx := context.Background()
+	chatModel := &answerChatModel{}
+	cleanupCalls := 0
+	mockConfig := newLifecycleMockConfig(chatModel, func() { cleanupCalls++ })
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	tools := agent.tools
+
+	notify := newResultNotify()
+	require.NoError(t, agent.Execute(ctx, "task 1", notify))
+	require.NoError(t, agent.Execute(ctx, "task 2", notify))
+
+	// 两次执行只创建一次工具和模型
+	mockConfig.AssertExpectations(t)
+	assert.Same(t, &tools[0], &agent.tools[0], "执行之间复用同一个工具切片")
+	assert.Equal(t, 2, chatModel.calls)
+	require.Len(t, chatModel.boundWith, 3, "创建时检查一次工具调用能力，每次执行创建新的ReAct agent")
+	assert.Equal(t, chatModel.boundWith[0], chatModel.boundWith[1])
+	assert.Equal(t, chatModel.boundWith[1], chatModel.boundWith[2])
+	notify.AssertCalled(t, "OnResult", "done: task 1")
+	notify.AssertCalled(t, "OnResult", "done: task 2")
+	assert.Equal(t, 0, cleanupCalls, "关闭之前不释放工具")
+
+	require.NoError(t, agent.Close())
+	assert.Equal(t, 1, cleanupCalls)
+
+	// 重复关闭不会再次释放，关闭后不能执行任务
+	require.NoError(t, agent.Close())
+	assert.Equal(t, 1, cleanupCalls)
+	assert.ErrorIs(t, agent.Execute(ctx, "task 3", notify), ErrAgentClosed)
+}
+
+func TestAgentExecuteWithoutTools(t *testing.T) {
+	ctx := context.Background()
+	chatModel := &answerChatModel{}
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{MaxStep: 5, SystemPrompt: "test prompt"}
+	mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{}, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	// 纯对话任务不绑定工具
+	notify := newResultNotify()
+	require.NoError(t, agent.Execute(ctx, "你好", notify))
+	notify.AssertCalled(t, "OnResult", "done: 你好")
+	assert.Empty(t, chatModel.boundWith)
+	assert.Equal(t, 1, chatModel.calls)
+}
+
+func TestAgentConcurrentExecute(t *testing.T) {
+	ctx := context.Background()
+	chatModel := &answerChatModel{}
+	mockConfig := newLifecycleMockConfig(chatModel, func() {})
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, agent.Execute(ctx, "task", newResultNotify()))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 4, chatModel.calls)
+}
+
+func TestNewAgentErrors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := New(ctx, nil)
+	assert.EqualError(t, err, errMsgConfigNil)
+
+	// 获取工具失败
+	mockConfig := &MockConfig{}
+	mockConfig.On("GetTools", mock.Anything).Return(nil, nil, errors.New("connect failed"))
+	_, err = newAgent(ctx, &mockConfig.Config, mockConfig)
+	assert.ErrorContains(t, err, "获取工具失败")
+
+	// 获取模型失败时释放已连接的工具
+	cleanupCalls := 0
+	mockConfig = &MockConfig{}
+	mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{&echoTool{}}, func() { cleanupCalls++ }, nil)
+	mockConfig.On("GetModel", mock.Anything).Return(nil, errors.New("model failed"))
+	_, err = newAgent(ctx, &mockConfig.Config, mockConfig)
+	assert.ErrorContains(t, err, "获取模型失败")
+	assert.Equal(t, 1, cleanupCalls)
+}
+
+func TestAgentExecuteValidation(t *testing.T) {
+	ctx := context.Background()
+	mockConfig := newLifecycleMockConfig(&answerChatModel{}, func() {})
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	assert.EqualError(t, agent.Execute(ctx, "", newResultNotify()), errMsgTaskEmpty)
+	assert.EqualError(t, agent.Execute(ctx, "task", nil), errMsgNotifyNil)
+}
+
+func TestAgentExecuteWithHistory(t *testing.T) {
+	ctx := context.Background()
+	chatModel := &scriptedChatModel{replies: []string{"第二个IP开放了22端口"}}
+	mockConfig := newLifecycleMockConfig(chatModel, func() {})
+	mockConfig.Config.SystemPrompt = "today is {date}"
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	history := []*schema.Message{
+		schema.UserMessage("查询这两个IP"),
+		schema.AssistantMessage(`结果: {"ips": ["1.1.1.1", "2.2.2.2"]}`, nil),
+	}
+	notify := newResultNotify()
+	require.NoError(t, agent.ExecuteWithHistory(ctx, history, "再深入分析第二个IP", notify))
+	notify.AssertCalled(t, "OnResult", "第二个IP开放了22端口")
+
+	// 历史消息位于系统提示词和新指令之间，且不作为模板格式化
+	require.Len(t, chatModel.inputs, 1)
+	input := chatModel.inputs[0]
+	require.Len(t, input, 4)
+	assert.Equal(t, schema.System, input[0].Role)
+	assert.NotContains(t, input[0].Content, "{date}")
+	assert.Equal(t, "查询这两个IP", input[1].Content)
+	assert.Equal(t, `结果: {"ips": ["1.1.1.1", "2.2.2.2"]}`, input[2].Content)
+	assert.Equal(t, schema.User, input[3].Role)
+	assert.Equal(t, "再深入分析第二个IP", input[3].Content)
+
+	// 历史中只能包含用户和助手消息
+	err = agent.ExecuteWithHistory(ctx, []*schema.Message{schema.SystemMessage("x")}, "task", notify)
+	assert.ErrorContains(t, err, "历史消息[0]无效")
+}
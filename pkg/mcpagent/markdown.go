@@ -0,0 +1,155 @@
+package mcpagent
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences of the terminal output
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiDim   = "\x1b[2m"
+	ansiCyan  = "\x1b[36m"
+)
+
+var (
+	mdHeadingRe = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdBulletRe  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdRuleRe    = regexp.MustCompile(`^\s*(-\s*){3,}$|^\s*(\*\s*){3,}$|^\s*(_\s*){3,}$`)
+	mdTableSep  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdBoldRe    = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdCodeRe    = regexp.MustCompile("`([^`]+)`")
+	mdLinkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	ansiRe      = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+)
+
+// renderMarkdown renders markdown for a terminal: headings and bold text are
+// bold, inline code is colored, code blocks are indented, list bullets are
+// replaced and tables are aligned in columns. Other lines are kept as they are.
+func renderMarkdown(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			// 代码块原样缩进输出，直到结束标记
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				out = append(out, "    "+lines[i])
+			}
+		case isTableRow(trimmed) && i+1 < len(lines) && mdTableSep.MatchString(lines[i+1]):
+			rows := [][]string{splitTableRow(trimmed)}
+			for i += 2; i < len(lines) && isTableRow(strings.TrimSpace(lines[i])); i++ {
+				rows = append(rows, splitTableRow(strings.TrimSpace(lines[i])))
+			}
+			i--
+			out = append(out, renderTable(rows)...)
+		case mdHeadingRe.MatchString(trimmed):
+			heading := mdHeadingRe.FindStringSubmatch(trimmed)[2]
+			out = append(out, ansiBold+renderInline(heading)+ansiReset)
+		case mdRuleRe.MatchString(line):
+			out = append(out, strings.Repeat("─", 40))
+		case mdBulletRe.MatchString(line):
+			match := mdBulletRe.FindStringSubmatch(line)
+			out = append(out, match[1]+"• "+renderInline(match[2]))
+		case strings.HasPrefix(trimmed, ">"):
+			out = append(out, ansiDim+"│ "+renderInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))+ansiReset)
+		default:
+			out = append(out, renderInline(line))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderInline renders bold text, inline code and links of a line
+func renderInline(line string) string {
+	line = mdCodeRe.ReplaceAllString(line, ansiCyan+"$1"+ansiReset)
+	line = mdBoldRe.ReplaceAllString(line, ansiBold+"$1$2"+ansiReset)
+	return mdLinkRe.ReplaceAllString(line, "$1 ($2)")
+}
+
+// isTableRow reports whether a trimmed line is a row of a markdown table
+func isTableRow(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "|") && strings.Count(trimmed, "|") >= 2
+}
+
+// splitTableRow returns the rendered cells of a table row
+func splitTableRow(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	cells := strings.Split(row, "|")
+	for i, cell := range cells {
+		cells[i] = renderInline(strings.TrimSpace(cell))
+	}
+	return cells
+}
+
+// renderTable aligns the cells of rows in columns, the first row is the header
+func renderTable(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], displayWidth(cell))
+		}
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for r, row := range rows {
+		cells := make([]string, len(widths))
+		for i := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			padded := cell
+			if i < len(widths)-1 {
+				padded += strings.Repeat(" ", widths[i]-displayWidth(cell))
+			}
+			if r == 0 {
+				padded = ansiBold + padded + ansiReset
+			}
+			cells[i] = padded
+		}
+		lines = append(lines, strings.Join(cells, " │ "))
+		if r == 0 {
+			separators := make([]string, len(widths))
+			for i, width := range widths {
+				separators[i] = strings.Repeat("─", width)
+			}
+			lines = append(lines, strings.Join(separators, "─┼─"))
+		}
+	}
+	return lines
+}
+
+// displayWidth returns the number of terminal columns of s without its ANSI
+// sequences, wide east asian characters take two columns
+func displayWidth(s string) int {
+	s = ansiRe.ReplaceAllString(s, "")
+	width := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		if isWideRune(r) {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// isWideRune reports whether r takes two terminal columns
+func isWideRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r) ||
+		(r >= 0x3000 && r <= 0x303f) || // 中文标点
+		(r >= 0xff01 && r <= 0xff60) || (r >= 0xffe0 && r <= 0xffe6) // 全角字符
+}
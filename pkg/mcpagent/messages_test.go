@@ -0,0 +1,106 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessagesFor(t *testing.T) {
+	assert.Same(t, MessagesFor(config.LanguageZh), MessagesFor(""))
+	assert.Same(t, MessagesFor(config.LanguageZh), MessagesFor("fr"), "未知语言使用中文")
+	assert.Equal(t, truncatedMarker, MessagesFor(config.LanguageZh).ArgumentsTruncated)
+	assert.NotEqual(t, MessagesFor(config.LanguageZh).WarningPrefix, MessagesFor(config.LanguageEn).WarningPrefix)
+}
+
+func TestToolCallTruncationMarkerLanguage(t *testing.T) {
+	longQuery := strings.Repeat("a", maxArgumentStringLen+10)
+	tests := []struct {
+		language string
+		marker   string
+	}{
+		{language: config.LanguageZh, marker: "...(已截断)"},
+		{language: config.LanguageEn, marker: "...(truncated)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			cfg := &config.Config{Language: tt.language}
+			cb := buildCallbackHandlers(cfg, nil)[0].(*LoggerCallback)
+
+			notify := new(MockNotify)
+			notify.On("OnToolCall", "search", mock.MatchedBy(func(params any) bool {
+				query, _ := params.(map[string]any)["query"].(string)
+				return strings.HasSuffix(query, tt.marker)
+			})).Once()
+			cb.notify = notify
+
+			cb.processToolCalls([]schema.ToolCall{{
+				Function: schema.FunctionCall{Name: "search", Arguments: fmt.Sprintf(`{"query":%q}`, longQuery)},
+			}})
+			notify.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMaxStepsExceededMessageLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		message  string
+	}{
+		{language: config.LanguageZh, message: "已达到最大步骤数（1），任务未完成"},
+		{language: config.LanguageEn, message: "Reached the maximum number of steps (1) before the task was finished"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			ctx := context.Background()
+
+			// 模型一直调用工具，直到超过最大步骤数
+			toolCallMsg := schema.AssistantMessage("", []schema.ToolCall{
+				{ID: "call_1", Type: "function", Function: schema.FunctionCall{Name: "echo", Arguments: `{}`}},
+			})
+			mockModel := new(MockToolCallingChatModel)
+			mockModel.On("WithTools", mock.Anything).Return(mockModel, nil)
+			mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(toolCallMsg, nil)
+
+			mockConfig := &MockConfig{}
+			mockConfig.Config = config.Config{MaxStep: 1, SystemPrompt: "test prompt", Language: tt.language}
+			mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{&echoTool{}}, func() {}, nil)
+			mockConfig.On("GetModel", mock.Anything).Return(mockModel, nil)
+
+			agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+			require.NoError(t, err)
+			defer agent.Close()
+
+			notify := newResultNotify()
+			err = agent.Execute(ctx, "task", notify)
+			require.ErrorIs(t, err, compose.ErrExceedMaxSteps)
+			assert.Equal(t, ErrorCodeMaxStepsExceeded, ErrorCode(err))
+			notify.AssertCalled(t, "OnMessage", tt.message)
+		})
+	}
+}
+
+func TestBuildPlaceHoldersDateLanguage(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, now.Format("2006-01-02"), buildPlaceHolders(&config.Config{})["date"])
+	assert.Equal(t, now.Format("January 2, 2006"), buildPlaceHolders(&config.Config{Language: config.LanguageEn})["date"])
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "", ErrorCode(nil))
+	assert.Equal(t, ErrorCodeTaskTimeout, ErrorCode(fmt.Errorf("%w: 模型请求被取消", ErrTaskTimeout)))
+	assert.Equal(t, ErrorCodeCanceled, ErrorCode(fmt.Errorf("执行任务失败: %w", context.Canceled)))
+	assert.Equal(t, ErrorCodeAgentClosed, ErrorCode(ErrAgentClosed))
+	assert.Equal(t, ErrorCodeInternal, ErrorCode(errors.New("获取工具失败")))
+}
@@ -0,0 +1,30 @@
+package mcpagent
+
+import (
+	"fmt"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+)
+
+// MissingToolsNotify is an optional extension of Notify for handlers
+// recording the requested tools a task runs without. They are reported
+// under the warn missing_tool_policy, see config.MCPConfig.MissingToolPolicy.
+type MissingToolsNotify interface {
+	Notify
+
+	// OnMissingTools receives the requested tools that do not exist. It is
+	// called before the task starts and only if some tools are missing.
+	OnMissingTools(tools []config.MissingTool)
+}
+
+// notifyMissingTools warns notify about the requested tools that do not exist
+func notifyMissingTools(cfg *config.Config, notify Notify, missing []config.MissingTool) {
+	if len(missing) == 0 {
+		return
+	}
+	messages := messagesOf(cfg)
+	notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.MissingTools, config.JoinMissingTools(missing)))
+	if missingNotify, ok := notify.(MissingToolsNotify); ok {
+		missingNotify.OnMissingTools(missing)
+	}
+}
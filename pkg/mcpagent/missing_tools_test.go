@@ -0,0 +1,58 @@
+package mcpagent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// missingToolsNotify 记录任务跳过的工具
+type missingToolsNotify struct {
+	*MockNotify
+	missing [][]config.MissingTool
+}
+
+func (n *missingToolsNotify) OnMissingTools(tools []config.MissingTool) {
+	n.missing = append(n.missing, tools)
+}
+
+func TestAgentWarnsAboutMissingTools(t *testing.T) {
+	ctx := context.Background()
+	missing := []config.MissingTool{{Server: "fofa", Name: "host"}}
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{MaxStep: 5, SystemPrompt: "test prompt"}
+	mockConfig.On("GetTools", mock.Anything).Run(func(args mock.Arguments) {
+		config.ReportMissingTools(args.Get(0).(context.Context), missing)
+	}).Return([]tool.BaseTool{&echoTool{}}, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(&answerChatModel{}, nil).Once()
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	// 每次执行都在任务开始前通知
+	notify := &missingToolsNotify{MockNotify: newResultNotify()}
+	require.NoError(t, agent.Execute(ctx, "task 1", notify))
+	require.NoError(t, agent.Execute(ctx, "task 2", notify))
+	notify.AssertCalled(t, "OnMessage", "警告: 以下工具不存在，已跳过: fofa:host")
+	assert.Equal(t, [][]config.MissingTool{missing, missing}, notify.missing)
+
+	// 没有缺失的工具时不通知
+	mockConfig = newLifecycleMockConfig(&answerChatModel{}, func() {})
+	agent, err = newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+	notify = &missingToolsNotify{MockNotify: newResultNotify()}
+	require.NoError(t, agent.Execute(ctx, "task", notify))
+	assert.Empty(t, notify.missing)
+}
+
+func TestErrorCodeToolsMissing(t *testing.T) {
+	err := &config.MissingToolsError{Tools: []config.MissingTool{{Server: "fofa", Name: "host"}}}
+	assert.Equal(t, ErrorCodeToolsMissing, ErrorCode(err))
+}
@@ -0,0 +1,44 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+)
+
+// ModelNotify is an optional extension of Notify for handlers recording which
+// model produced the final answer of a task. With fallback models configured
+// this may be another model than the primary one.
+type ModelNotify interface {
+	Notify
+
+	// OnAnswerModel receives the label of the model that produced the final
+	// answer, see config.LLMConfig.Label. It is called before Run returns
+	// and only if the task succeeded.
+	OnAnswerModel(model string)
+}
+
+// trackModels returns a context whose model requests follow the fallback
+// chain of cfg for the whole task, sending a message to notify on each switch.
+func trackModels(ctx context.Context, cfg *config.Config, notify Notify) (context.Context, *config.ModelTracker) {
+	messages := messagesOf(cfg)
+	tracker := config.NewModelTracker(func(modelSwitch config.ModelSwitch) {
+		notify.OnMessage(fmt.Sprintf(messages.ModelFallback, modelSwitch.From, modelSwitch.Err, modelSwitch.To))
+	})
+	return config.WithModelTracker(ctx, tracker), tracker
+}
+
+// notifyAnswerModel reports the model that produced the final answer to handlers supporting it
+func notifyAnswerModel(notify Notify, cfg *config.Config, tracker *config.ModelTracker) {
+	modelNotify, ok := notify.(ModelNotify)
+	if !ok {
+		return
+	}
+	model := tracker.Model()
+	if model == "" {
+		// 没有备用模型时总是由主模型回答
+		model = cfg.LLM.Label()
+	}
+	modelNotify.OnAnswerModel(model)
+}
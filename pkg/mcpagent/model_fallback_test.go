@@ -0,0 +1,90 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// modelNotify 记录产生最终结果的模型
+type modelNotify struct {
+	*MockNotify
+	models []string
+}
+
+func (n *modelNotify) OnAnswerModel(model string) {
+	n.models = append(n.models, model)
+}
+
+func TestAgentSwitchesToFallbackModel(t *testing.T) {
+	primaryCalls := 0
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, `{"error":{"message":"bad gateway","type":"server_error"}}`)
+	}))
+	defer unavailable.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"backup",`+
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"from backup"},"finish_reason":"stop"}]}`)
+	}))
+	defer backup.Close()
+
+	ctx := context.Background()
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{
+		MaxStep:      5,
+		SystemPrompt: "test prompt",
+		LLM: config.LLMConfig{
+			Type: config.LLMProviderOpenAI, BaseURL: unavailable.URL, Model: "primary", APIKey: "test",
+			Fallbacks: []config.LLMConfig{{Type: config.LLMProviderOpenAI, BaseURL: backup.URL, Model: "backup", APIKey: "test"}},
+		},
+	}
+	chatModel, err := mockConfig.Config.GetModel(ctx)
+	require.NoError(t, err)
+	mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{}, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	notify := &modelNotify{MockNotify: newResultNotify()}
+	require.NoError(t, agent.Execute(ctx, "task 1", notify))
+	notify.AssertCalled(t, "OnResult", "from backup")
+	notify.AssertCalled(t, "OnMessage", mock.MatchedBy(func(msg string) bool {
+		return strings.HasPrefix(msg, "模型 openai/primary 不可用") && strings.HasSuffix(msg, "改用 openai/backup")
+	}))
+	assert.Equal(t, []string{"openai/backup"}, notify.models)
+	assert.Equal(t, 1, primaryCalls)
+
+	// 下一个任务重新尝试主模型
+	require.NoError(t, agent.Execute(ctx, "task 2", notify))
+	assert.Equal(t, 2, primaryCalls)
+	assert.Equal(t, []string{"openai/backup", "openai/backup"}, notify.models)
+}
+
+func TestAgentReportsPrimaryModel(t *testing.T) {
+	ctx := context.Background()
+	mockConfig := newLifecycleMockConfig(&answerChatModel{}, func() {})
+	mockConfig.Config.LLM = config.LLMConfig{Type: config.LLMProviderOllama, Model: "qwen3"}
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	notify := &modelNotify{MockNotify: newResultNotify()}
+	require.NoError(t, agent.Execute(ctx, "task", notify))
+	assert.Equal(t, []string{"ollama/qwen3"}, notify.models)
+}
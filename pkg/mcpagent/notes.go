@@ -0,0 +1,156 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/notes"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// notesPlaceholder is the value of the {notes} placeholder with
+// agent.enable_notes. It is replaced by the keys of the saved notes before
+// every model request, so the list is current in every step.
+const notesPlaceholder = "<<<NOTES>>>"
+
+// NotesNotify is an optional extension of Notify for handlers including the
+// notes of a task in its report, see config.AgentConfig.EnableNotes.
+type NotesNotify interface {
+	Notify
+
+	// OnNotes receives the notes saved by the task. It is called when the
+	// task ends, the result or error was sent before, and only if the task
+	// saved notes.
+	OnNotes(notes []notes.Note)
+}
+
+// withNotes returns a context carrying the note store of the task when cfg
+// enables agent.enable_notes. A store already in ctx is used, so the caller
+// can read the notes while the task runs. Evicted notes are reported to
+// notify as a warning.
+func withNotes(ctx context.Context, cfg *config.Config, notify Notify) context.Context {
+	if cfg == nil || !cfg.Agent.EnableNotes {
+		return ctx
+	}
+
+	if notes.FromContext(ctx) == nil {
+		store, err := notes.NewStore(cfg.Agent.MaxNotesSize)
+		if err != nil {
+			log.Printf("创建笔记存储失败，使用默认大小上限: %v", err)
+			store, _ = notes.NewStore(0)
+		}
+		ctx = notes.WithStore(ctx, store)
+	}
+	messages := messagesOf(cfg)
+	return notes.WithEvictionHandler(ctx, func(keys []string) {
+		notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.NotesEvicted, strings.Join(keys, ", ")))
+	})
+}
+
+// notesStore returns the note store of ctx, nil unless cfg enables agent.enable_notes
+func notesStore(ctx context.Context, cfg *config.Config) *notes.Store {
+	if cfg == nil || !cfg.Agent.EnableNotes {
+		return nil
+	}
+	return notes.FromContext(ctx)
+}
+
+// withNotesTools appends the note tools to tools when ctx carries the note
+// store of the task, see withNotes
+func withNotesTools(ctx context.Context, cfg *config.Config, tools []tool.BaseTool) []tool.BaseTool {
+	store := notesStore(ctx, cfg)
+	if store == nil {
+		return tools
+	}
+	return append(append([]tool.BaseTool(nil), tools...), notes.NewTools(store)...)
+}
+
+// notesModel lists the keys of the saved notes in the system prompt of every request
+type notesModel struct {
+	model.ToolCallingChatModel
+	cfg   *config.Config
+	store *notes.Store
+}
+
+// withNotesContext wraps chatModel with notesModel when ctx carries the note store of the task
+func withNotesContext(ctx context.Context, cfg *config.Config, chatModel model.ToolCallingChatModel) model.ToolCallingChatModel {
+	store := notesStore(ctx, cfg)
+	if store == nil {
+		return chatModel
+	}
+	return &notesModel{ToolCallingChatModel: chatModel, cfg: cfg, store: store}
+}
+
+// WithTools binds tools to the wrapped model, the note store is shared
+func (m *notesModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.ToolCallingChatModel.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	withNotes := *m
+	withNotes.ToolCallingChatModel = bound
+	return &withNotes, nil
+}
+
+// Generate sends the request with the current note keys
+func (m *notesModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return m.ToolCallingChatModel.Generate(ctx, withNoteKeys(m.cfg, m.store, input), opts...)
+}
+
+// Stream sends the request with the current note keys
+func (m *notesModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return m.ToolCallingChatModel.Stream(ctx, withNoteKeys(m.cfg, m.store, input), opts...)
+}
+
+// withNoteKeys returns input with the keys of the notes of store in place of
+// the {notes} placeholder of the system messages. Without a placeholder the
+// keys are appended to the first system message. input is not modified.
+func withNoteKeys(cfg *config.Config, store *notes.Store, input []*schema.Message) []*schema.Message {
+	messages := messagesOf(cfg)
+	keys := messages.NotesNone
+	if saved := store.Keys(); len(saved) > 0 {
+		keys = strings.Join(saved, ", ")
+	}
+	listed := fmt.Sprintf(messages.NotesContext, keys)
+
+	output := append([]*schema.Message(nil), input...)
+	replaced := false
+	for i, msg := range output {
+		if msg.Role == schema.System && strings.Contains(msg.Content, notesPlaceholder) {
+			system := *msg
+			system.Content = strings.ReplaceAll(system.Content, notesPlaceholder, listed)
+			output[i] = &system
+			replaced = true
+		}
+	}
+	if replaced {
+		return output
+	}
+	if len(output) > 0 && output[0].Role == schema.System {
+		system := *output[0]
+		system.Content += "\n\n" + listed
+		output[0] = &system
+		return output
+	}
+	return append([]*schema.Message{schema.SystemMessage(listed)}, output...)
+}
+
+// notifyNotes sends the notes of the task to handlers implementing NotesNotify
+func notifyNotes(ctx context.Context, cfg *config.Config, notify Notify) {
+	store := notesStore(ctx, cfg)
+	if store == nil {
+		return
+	}
+	saved := store.List()
+	if len(saved) == 0 {
+		return
+	}
+	if notesNotify, ok := notify.(NotesNotify); ok {
+		notesNotify.OnNotes(saved)
+	}
+}
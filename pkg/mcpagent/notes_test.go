@@ -0,0 +1,101 @@
+package mcpagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/notes"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// saveNoteMessage 返回调用save_note的模型回复
+func saveNoteMessage(id, key, content string) *schema.Message {
+	arguments, _ := json.Marshal(map[string]string{"key": key, "content": content})
+	return schema.AssistantMessage("", []schema.ToolCall{{ID: id, Type: "function",
+		Function: schema.FunctionCall{Name: notes.SaveToolName, Arguments: string(arguments)}}})
+}
+
+// runNotesTask 以启用笔记的配置执行任务，返回CliNotifier的输出
+func runNotesTask(t *testing.T, agentConfig config.AgentConfig, systemPrompt string, format OutputFormat, chatModel *stepRecordingModel) string {
+	t.Helper()
+	ctx := context.Background()
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{MaxStep: 5, SystemPrompt: systemPrompt, Agent: agentConfig}
+	mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{&echoTool{}}, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	var out bytes.Buffer
+	notifier := NewFormattedCliNotifier(format, &out, &out)
+	result, err := agent.ExecuteWithResult(ctx, "扫描example.com", notifier)
+	require.NoError(t, err)
+	require.NoError(t, notifier.Finish(result, err))
+	return out.String()
+}
+
+func TestNotesListedInEveryStep(t *testing.T) {
+	chatModel := &stepRecordingModel{replies: []*schema.Message{
+		saveNoteMessage("call_a", "open_ports", "80, 443"),
+		schema.AssistantMessage("完成", nil),
+	}}
+	output := runNotesTask(t, config.AgentConfig{EnableNotes: true}, "test prompt", OutputJSON, chatModel)
+
+	// 每次请求的系统提示词都列出当前笔记的key
+	require.Len(t, chatModel.requests, 2)
+	assert.True(t, strings.HasSuffix(chatModel.requests[0].input[0].Content, "已保存的笔记（用 get_note 读取，用 save_note 记录新的发现）: （暂无）"))
+	assert.Contains(t, chatModel.requests[1].input[0].Content, "）: open_ports")
+	assert.NotContains(t, chatModel.requests[1].input[0].Content, "（暂无）")
+	second := chatModel.requests[1].input
+	assert.Equal(t, "已保存笔记 open_ports", second[len(second)-1].Content)
+
+	// 笔记包含在任务报告中
+	var report CliReport
+	require.NoError(t, json.Unmarshal([]byte(output), &report))
+	assert.Equal(t, "完成", report.Result)
+	require.Len(t, report.Notes, 1)
+	assert.Equal(t, "open_ports", report.Notes[0].Key)
+	assert.Equal(t, "80, 443", report.Notes[0].Content)
+}
+
+func TestNotesPlaceholder(t *testing.T) {
+	chatModel := &stepRecordingModel{replies: []*schema.Message{schema.AssistantMessage("完成", nil)}}
+	output := runNotesTask(t, config.AgentConfig{EnableNotes: true}, "你的笔记: {notes}\n按笔记继续", OutputJSON, chatModel)
+
+	// 提示词中有{notes}时替换占位符，不再追加到末尾
+	require.Len(t, chatModel.requests, 1)
+	system := chatModel.requests[0].input[0].Content
+	assert.True(t, strings.HasPrefix(system, "你的笔记: 已保存的笔记"), system)
+	assert.True(t, strings.HasSuffix(system, "\n按笔记继续"), system)
+	assert.NotContains(t, system, notesPlaceholder)
+
+	// 没有保存笔记时报告不包含notes字段
+	assert.NotContains(t, output, `"notes"`)
+
+	// 未启用笔记时占位符为空
+	assert.Empty(t, buildPlaceHolders(&config.Config{})["notes"])
+}
+
+func TestNotesEvictionWarning(t *testing.T) {
+	chatModel := &stepRecordingModel{replies: []*schema.Message{
+		saveNoteMessage("call_a", "a", "1234"),
+		saveNoteMessage("call_b", "b", "5678"),
+		schema.AssistantMessage("完成", nil),
+	}}
+	output := runNotesTask(t, config.AgentConfig{EnableNotes: true, MaxNotesSize: 8}, "test prompt", OutputPlain, chatModel)
+
+	// 淘汰笔记时推送警告，结果之后输出剩余的笔记
+	assert.Contains(t, output, "消息: 警告: 笔记总大小超过上限，已淘汰最久未使用的笔记: a\n")
+	assert.Contains(t, output, "结果: 完成\n笔记:\n- b: 5678\n")
+	assert.Contains(t, chatModel.requests[2].input[0].Content, "）: b")
+}
@@ -0,0 +1,460 @@
+package mcpagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+)
+
+const (
+	// webhookQueueSize is the number of events a webhook buffers while a request is pending
+	webhookQueueSize = 256
+
+	// webhookRetryBackoff is the delay before the first retry of a webhook
+	// request, it grows linearly with every further attempt
+	webhookRetryBackoff = 500 * time.Millisecond
+
+	errMsgSinkDeliveryFailed = "通知%s发送失败: %v"
+	errMsgSinkFailures       = "通知%s共有%d条事件发送失败"
+	errMsgWebhookQueueFull   = "发送队列已满，丢弃%s事件"
+	errMsgWebhookClosed      = "通知已关闭，丢弃%s事件"
+	errMsgWebhookStatus      = "服务器返回状态码%d"
+	errMsgWebhookAttempts    = "尝试%d次后仍然失败: %w"
+)
+
+// NotificationSink is a handler delivering the notifications of a task to an
+// external destination configured in the notifications section. Sinks never
+// fail the task, notifications that cannot be delivered are logged and counted.
+type NotificationSink interface {
+	TimelineNotify
+	PlanNotify
+
+	// Name describes the destination in logs, it contains no secrets
+	Name() string
+
+	// Failures returns the number of notifications that could not be delivered
+	Failures() int64
+
+	// Close delivers the pending notifications and releases the sink
+	Close()
+}
+
+// SinkEvent is the JSON document a sink delivers for a notification. It has
+// the fields of the notify events of the web server, Text repeats the main
+// content so that chat webhooks such as Slack display the event.
+type SinkEvent struct {
+	Type       string `json:"type"`
+	Timestamp  int64  `json:"timestamp"`
+	TaskID     string `json:"task_id,omitempty"`
+	Content    string `json:"content,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
+	Parameters any    `json:"parameters,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Result     any    `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ErrorCode  string `json:"error_code,omitempty"`
+	CallID     string `json:"call_id,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"` // 工具调用的耗时（毫秒），仅tool_result
+	Text       string `json:"text,omitempty"`        // 事件的主要内容，兼容Slack等聊天工具的Webhook
+}
+
+// NotificationSinks are the sinks of a notifications section for one task
+type NotificationSinks []NotificationSink
+
+// NewNotificationSinks creates the sinks configured in cfg for the task taskID.
+//
+// Parameters:
+//   - cfg: Notifications section of the task configuration
+//   - taskID: ID of the task, sent with every event
+//
+// Returns:
+//   - NotificationSinks: The configured sinks, empty when none is configured
+func NewNotificationSinks(cfg config.NotificationsConfig, taskID string) NotificationSinks {
+	var sinks NotificationSinks
+	for _, webhook := range cfg.Webhooks {
+		sinks = append(sinks, NewWebhookNotifier(webhook, taskID))
+	}
+	for _, file := range cfg.Files {
+		sinks = append(sinks, NewFileNotifier(file, taskID))
+	}
+	return sinks
+}
+
+// Attach returns a handler forwarding every notification to notify and to the sinks
+func (s NotificationSinks) Attach(notify Notify) Notify {
+	if len(s) == 0 {
+		return notify
+	}
+	notifiers := []Notify{notify}
+	for _, sink := range s {
+		notifiers = append(notifiers, sink)
+	}
+	return NewMultiNotify(notifiers...)
+}
+
+// OnError sends an error to the sinks only, for errors the task fails with
+// that Run does not report itself
+func (s NotificationSinks) OnError(err error) {
+	for _, sink := range s {
+		func() {
+			defer recoverCallback("OnError")
+			sink.OnError(err)
+		}()
+	}
+}
+
+// Close closes the sinks and logs how many events each of them failed to deliver
+func (s NotificationSinks) Close() {
+	for _, sink := range s {
+		sink.Close()
+		if failures := sink.Failures(); failures > 0 {
+			log.Printf(errMsgSinkFailures, sink.Name(), failures)
+		}
+	}
+}
+
+// sinkNotify converts the notifications of a task into SinkEvents of the
+// selected types and passes them to deliver. It is embedded by the sinks.
+type sinkNotify struct {
+	name     string
+	taskID   string
+	events   map[string]bool // 为nil时选择全部事件
+	deliver  func(event SinkEvent)
+	failures atomic.Int64
+}
+
+// init sets up n, events selects the event types to deliver, nil selects all
+func (n *sinkNotify) init(name string, taskID string, events []string, deliver func(event SinkEvent)) {
+	n.name = name
+	n.taskID = taskID
+	n.deliver = deliver
+	if len(events) > 0 {
+		n.events = make(map[string]bool, len(events))
+		for _, event := range events {
+			n.events[event] = true
+		}
+	}
+}
+
+// Name describes the destination in logs
+func (n *sinkNotify) Name() string {
+	return n.name
+}
+
+// Failures returns the number of events that could not be delivered
+func (n *sinkNotify) Failures() int64 {
+	return n.failures.Load()
+}
+
+// fail counts and logs an event that could not be delivered
+func (n *sinkNotify) fail(err error) {
+	n.failures.Add(1)
+	log.Printf(errMsgSinkDeliveryFailed, n.name, err)
+}
+
+// emit completes event and delivers it when its type is selected
+func (n *sinkNotify) emit(event SinkEvent) {
+	if n.events != nil && !n.events[event.Type] {
+		return
+	}
+	event.TaskID = n.taskID
+	event.Timestamp = time.Now().UnixMilli()
+	n.deliver(event)
+}
+
+// OnMessage delivers a message event
+func (n *sinkNotify) OnMessage(msg string) {
+	n.emit(SinkEvent{Type: config.NotificationEventMessage, Content: msg, Text: msg})
+}
+
+// OnThinking delivers a thinking event
+func (n *sinkNotify) OnThinking(msg string) {
+	n.emit(SinkEvent{Type: config.NotificationEventThinking, Content: msg, Text: msg})
+}
+
+// OnThinkingWithCall delivers a thinking event
+func (n *sinkNotify) OnThinkingWithCall(msg string, relatedCallID string) {
+	n.OnThinking(msg)
+}
+
+// OnToolCall delivers a tool call event without call ID
+func (n *sinkNotify) OnToolCall(toolName string, params any) {
+	n.OnToolCallWithID("", toolName, params)
+}
+
+// OnToolCallWithID delivers a tool call event
+func (n *sinkNotify) OnToolCallWithID(callID string, toolName string, params any) {
+	n.emit(SinkEvent{
+		Type:       config.NotificationEventToolCall,
+		ToolName:   toolName,
+		Parameters: CanonicalizeArguments(params),
+		Status:     "calling",
+		CallID:     callID,
+		Text:       toolName,
+	})
+}
+
+// OnToolCallResult delivers the result event of a tool call without call ID
+func (n *sinkNotify) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	n.OnToolResult("", toolName, result, err, duration)
+}
+
+// OnToolResult delivers the result event of a tool call
+func (n *sinkNotify) OnToolResult(callID string, toolName string, result string, err error, duration time.Duration) {
+	event := SinkEvent{
+		Type:       config.NotificationEventToolResult,
+		ToolName:   toolName,
+		Status:     "success",
+		Result:     result,
+		CallID:     callID,
+		DurationMs: duration.Milliseconds(),
+		Text:       result,
+	}
+	if err != nil {
+		event.Status = "error"
+		event.Result = nil
+		event.Error = err.Error()
+		event.ErrorCode = ErrorCodeToolFailed
+		event.Text = event.Error
+	}
+	n.emit(event)
+}
+
+// OnPlan delivers the execution plan
+func (n *sinkNotify) OnPlan(plan string) {
+	n.emit(SinkEvent{Type: config.NotificationEventPlan, Content: plan, Text: plan})
+}
+
+// OnResult delivers the final result
+func (n *sinkNotify) OnResult(msg string) {
+	n.emit(SinkEvent{Type: config.NotificationEventResult, Content: msg, Text: msg})
+}
+
+// OnError delivers an error event with its stable error code
+func (n *sinkNotify) OnError(err error) {
+	if err == nil {
+		return
+	}
+	n.emit(SinkEvent{Type: config.NotificationEventError, Error: err.Error(), ErrorCode: ErrorCode(err), Text: err.Error()})
+}
+
+// WebhookNotifier posts the selected events of a task as JSON to a URL.
+// Events are sent in order by a background goroutine, so a slow webhook
+// does not delay the task. A failed request is retried with a growing delay,
+// an event that still fails, or does not fit into the queue, is counted and
+// logged. Close waits until the queued events are sent.
+type WebhookNotifier struct {
+	sinkNotify
+
+	url     string
+	headers map[string]string
+	retries int
+	backoff time.Duration
+	client  *http.Client
+
+	mutex  sync.RWMutex
+	closed bool
+	queue  chan SinkEvent
+	done   chan struct{}
+}
+
+// NewWebhookNotifier creates a webhook sink for the task taskID and starts its delivery.
+//
+// Parameters:
+//   - cfg: Webhook configuration, validated by config.NotificationsConfig.Validate
+//   - taskID: ID of the task, sent with every event
+//
+// Returns:
+//   - *WebhookNotifier: Sink to attach to the notifier of the task, close it when the task ends
+func NewWebhookNotifier(cfg config.WebhookConfig, taskID string) *WebhookNotifier {
+	n := &WebhookNotifier{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		retries: cfg.Retries,
+		backoff: webhookRetryBackoff,
+		client:  &http.Client{Timeout: cfg.TimeoutDuration()},
+		queue:   make(chan SinkEvent, webhookQueueSize),
+		done:    make(chan struct{}),
+	}
+	n.init("webhook "+webhookHost(cfg.URL), taskID, cfg.SelectedEvents(), n.enqueue)
+	go n.run()
+	return n
+}
+
+// webhookHost returns the host of a webhook URL, the path of chat webhooks often contains a token
+func webhookHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// enqueue queues event for delivery without blocking the task
+func (n *WebhookNotifier) enqueue(event SinkEvent) {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	if n.closed {
+		n.fail(fmt.Errorf(errMsgWebhookClosed, event.Type))
+		return
+	}
+	select {
+	case n.queue <- event:
+	default:
+		n.fail(fmt.Errorf(errMsgWebhookQueueFull, event.Type))
+	}
+}
+
+// run sends the queued events until the queue is closed
+func (n *WebhookNotifier) run() {
+	defer close(n.done)
+	for event := range n.queue {
+		if err := n.send(event); err != nil {
+			n.fail(err)
+		}
+	}
+}
+
+// send posts event, retrying failed requests up to n.retries times
+func (n *WebhookNotifier) send(event SinkEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	attempts := 0
+	for attempts <= n.retries {
+		if attempts > 0 {
+			time.Sleep(time.Duration(attempts) * n.backoff)
+		}
+		attempts++
+		var retry bool
+		if retry, err = n.post(body); err == nil {
+			return nil
+		}
+		if !retry {
+			break
+		}
+	}
+	return fmt.Errorf(errMsgWebhookAttempts, attempts, err)
+}
+
+// webhookStatusError is a response of the webhook with a status other than 2xx
+type webhookStatusError int
+
+// Error implements the error interface
+func (e webhookStatusError) Error() string {
+	return fmt.Sprintf(errMsgWebhookStatus, int(e))
+}
+
+// retryable reports whether the request may succeed later, client errors other than 408 and 429 do not
+func (e webhookStatusError) retryable() bool {
+	return int(e) >= http.StatusInternalServerError || e == http.StatusTooManyRequests || e == http.StatusRequestTimeout
+}
+
+// post sends one request, retry reports whether a failed request should be retried
+func (n *WebhookNotifier) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range n.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	// 读完响应体以便复用连接
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		statusErr := webhookStatusError(resp.StatusCode)
+		return statusErr.retryable(), statusErr
+	}
+	return false, nil
+}
+
+// Close stops accepting events and waits until the queued events are sent
+func (n *WebhookNotifier) Close() {
+	n.mutex.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mutex.Unlock()
+	<-n.done
+}
+
+// fileSinkMutex serializes the writes of all file sinks, sinks of concurrent
+// tasks may append to the same file
+var fileSinkMutex sync.Mutex
+
+// FileNotifier appends the selected events of a task to a file as JSON
+// Lines, one event per line. The file is opened for every event, so it can be
+// rotated or removed while tasks run.
+type FileNotifier struct {
+	sinkNotify
+
+	path string
+}
+
+// NewFileNotifier creates a file sink for the task taskID.
+//
+// Parameters:
+//   - cfg: File sink configuration, all events are appended when it selects none
+//   - taskID: ID of the task, written with every event
+//
+// Returns:
+//   - *FileNotifier: Sink to attach to the notifier of the task
+func NewFileNotifier(cfg config.FileSinkConfig, taskID string) *FileNotifier {
+	n := &FileNotifier{path: cfg.Path}
+	n.init("文件 "+cfg.Path, taskID, cfg.Events, n.write)
+	return n
+}
+
+// write appends event to the file
+func (n *FileNotifier) write(event SinkEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		n.fail(err)
+		return
+	}
+	line = append(line, '\n')
+
+	fileSinkMutex.Lock()
+	defer fileSinkMutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(n.path), 0o755); err != nil {
+		n.fail(err)
+		return
+	}
+	file, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		n.fail(err)
+		return
+	}
+	_, err = file.Write(line)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		n.fail(err)
+	}
+}
+
+// Close releases the sink, events are written as they are reported
+func (n *FileNotifier) Close() {}
@@ -0,0 +1,258 @@
+package mcpagent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookRecorder 记录收到的Webhook请求，前failures次请求返回status
+type webhookRecorder struct {
+	server   *httptest.Server
+	failures int32
+	status   int
+	requests atomic.Int32
+
+	mu      sync.Mutex
+	events  []SinkEvent
+	headers []http.Header
+}
+
+func newWebhookRecorder(t *testing.T, failures int32, status int) *webhookRecorder {
+	t.Helper()
+	r := &webhookRecorder{failures: failures, status: status}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.requests.Add(1) <= r.failures {
+			w.WriteHeader(r.status)
+			return
+		}
+		var event SinkEvent
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, event)
+		r.headers = append(r.headers, req.Header.Clone())
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *webhookRecorder) received() []SinkEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SinkEvent(nil), r.events...)
+}
+
+// newTestWebhook 创建重试间隔很短的Webhook
+func newTestWebhook(cfg config.WebhookConfig) *WebhookNotifier {
+	n := NewWebhookNotifier(cfg, "task_1")
+	n.backoff = time.Millisecond
+	return n
+}
+
+func TestWebhookNotifierDelivery(t *testing.T) {
+	recorder := newWebhookRecorder(t, 0, 0)
+	webhook := newTestWebhook(config.WebhookConfig{
+		URL:     recorder.server.URL + "/hook",
+		Events:  []string{config.NotificationEventToolCall, config.NotificationEventToolResult, config.NotificationEventResult},
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+
+	webhook.OnToolCallWithID("call_1", "search", map[string]any{"q": "mcp"})
+	webhook.OnToolResult("call_1", "search", "", errors.New("连接失败"), 0)
+	webhook.OnResult("完成")
+	webhook.Close()
+
+	events := recorder.received()
+	require.Len(t, events, 3)
+	for i := range events {
+		assert.Equal(t, "task_1", events[i].TaskID)
+		assert.NotZero(t, events[i].Timestamp)
+		events[i].Timestamp = 0
+	}
+	assert.Equal(t, []SinkEvent{
+		{Type: "tool_call", TaskID: "task_1", ToolName: "search", Parameters: map[string]any{"q": "mcp"}, Status: "calling", CallID: "call_1", Text: "search"},
+		{Type: "tool_result", TaskID: "task_1", ToolName: "search", Status: "error", Error: "连接失败", ErrorCode: ErrorCodeToolFailed, CallID: "call_1", Text: "连接失败"},
+		{Type: "result", TaskID: "task_1", Content: "完成", Text: "完成"},
+	}, events)
+	assert.Equal(t, "Bearer token", recorder.headers[0].Get("Authorization"))
+	assert.Equal(t, "application/json", recorder.headers[0].Get("Content-Type"))
+	assert.Zero(t, webhook.Failures())
+	// 日志中只显示主机，路径中可能包含令牌
+	assert.Equal(t, "webhook "+recorder.server.Listener.Addr().String(), webhook.Name())
+}
+
+func TestWebhookNotifierDefaultEvents(t *testing.T) {
+	recorder := newWebhookRecorder(t, 0, 0)
+	webhook := newTestWebhook(config.WebhookConfig{URL: recorder.server.URL})
+
+	// 默认只推送result和error
+	webhook.OnMessage("开始")
+	webhook.OnThinking("思考")
+	webhook.OnToolCall("search", nil)
+	webhook.OnPlan("计划")
+	webhook.OnResult("完成")
+	webhook.OnError(ErrTaskTimeout)
+	webhook.Close()
+
+	events := recorder.received()
+	require.Len(t, events, 2)
+	assert.Equal(t, "result", events[0].Type)
+	assert.Equal(t, "error", events[1].Type)
+	assert.Equal(t, ErrorCodeTaskTimeout, events[1].ErrorCode)
+}
+
+func TestWebhookNotifierRetry(t *testing.T) {
+	t.Run("重试后成功", func(t *testing.T) {
+		recorder := newWebhookRecorder(t, 2, http.StatusBadGateway)
+		webhook := newTestWebhook(config.WebhookConfig{URL: recorder.server.URL, Retries: 2})
+		webhook.OnResult("完成")
+		webhook.Close()
+
+		assert.Len(t, recorder.received(), 1)
+		assert.Equal(t, int32(3), recorder.requests.Load())
+		assert.Zero(t, webhook.Failures())
+	})
+
+	t.Run("重试次数用尽", func(t *testing.T) {
+		recorder := newWebhookRecorder(t, 10, http.StatusInternalServerError)
+		webhook := newTestWebhook(config.WebhookConfig{URL: recorder.server.URL, Retries: 1})
+		webhook.OnResult("完成")
+		webhook.OnError(errors.New("失败"))
+		webhook.Close()
+
+		assert.Empty(t, recorder.received())
+		assert.Equal(t, int32(4), recorder.requests.Load())
+		assert.Equal(t, int64(2), webhook.Failures())
+	})
+
+	t.Run("客户端错误不重试", func(t *testing.T) {
+		recorder := newWebhookRecorder(t, 10, http.StatusUnauthorized)
+		webhook := newTestWebhook(config.WebhookConfig{URL: recorder.server.URL, Retries: 3})
+		webhook.OnResult("完成")
+		webhook.Close()
+
+		assert.Equal(t, int32(1), recorder.requests.Load())
+		assert.Equal(t, int64(1), webhook.Failures())
+	})
+
+	t.Run("连接失败", func(t *testing.T) {
+		recorder := newWebhookRecorder(t, 0, 0)
+		recorder.server.Close()
+		webhook := newTestWebhook(config.WebhookConfig{URL: recorder.server.URL, Retries: 1, Timeout: 1})
+		webhook.OnResult("完成")
+		webhook.Close()
+
+		// 关闭后的事件也只计数，不会panic
+		webhook.OnError(errors.New("失败"))
+		assert.Equal(t, int64(2), webhook.Failures())
+	})
+}
+
+func TestWebhookNotifierDoesNotBlockTask(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	webhook := newTestWebhook(config.WebhookConfig{URL: server.URL})
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < webhookQueueSize+10; i++ {
+			webhook.OnResult("完成")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Webhook响应慢时阻塞了任务")
+	}
+	// 正在发送的一个事件之外，超出队列的事件被丢弃并计数
+	assert.GreaterOrEqual(t, webhook.Failures(), int64(9))
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "notify.jsonl")
+	all := NewFileNotifier(config.FileSinkConfig{Path: path}, "task_1")
+	results := NewFileNotifier(config.FileSinkConfig{Path: path, Events: []string{config.NotificationEventResult}}, "task_2")
+
+	all.OnMessage("开始")
+	results.OnMessage("开始")
+	all.OnToolCallWithID("call_1", "search", map[string]any{"q": "mcp"})
+	all.OnToolResult("call_1", "search", `{"total": 1}`, nil, 1500*time.Millisecond)
+	results.OnResult("完成")
+	all.Close()
+	results.Close()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var events []SinkEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event SinkEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, events, 4)
+	assert.Equal(t, []string{"message", "tool_call", "tool_result", "result"},
+		[]string{events[0].Type, events[1].Type, events[2].Type, events[3].Type})
+	assert.Equal(t, "task_1", events[2].TaskID)
+	assert.Equal(t, `{"total": 1}`, events[2].Result)
+	assert.Equal(t, int64(1500), events[2].DurationMs)
+	assert.Equal(t, "task_2", events[3].TaskID)
+	assert.Zero(t, all.Failures())
+
+	// 无法写入时只计数
+	broken := NewFileNotifier(config.FileSinkConfig{Path: filepath.Join(path, "notify.jsonl")}, "task_3")
+	broken.OnResult("完成")
+	assert.Equal(t, int64(1), broken.Failures())
+}
+
+func TestNotificationSinksAttach(t *testing.T) {
+	recorder := newWebhookRecorder(t, 0, 0)
+	path := filepath.Join(t.TempDir(), "notify.jsonl")
+	sinks := NewNotificationSinks(config.NotificationsConfig{
+		Webhooks: []config.WebhookConfig{{URL: recorder.server.URL}},
+		Files:    []config.FileSinkConfig{{Path: path}},
+	}, "task_1")
+	require.Len(t, sinks, 2)
+
+	primary := newResultNotify()
+	notify := sinks.Attach(primary)
+	notify.OnResult("done")
+	sinks.OnError(errors.New("失败"))
+	sinks.Close()
+
+	primary.AssertCalled(t, "OnResult", "done")
+	events := recorder.received()
+	require.Len(t, events, 2)
+	assert.Equal(t, "done", events[0].Content)
+	assert.Equal(t, "失败", events[1].Error)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"content":"done"`)
+
+	// 没有配置时直接使用原通知器
+	assert.Same(t, primary, NewNotificationSinks(config.NotificationsConfig{}, "task_1").Attach(primary))
+}
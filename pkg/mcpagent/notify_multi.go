@@ -0,0 +1,207 @@
+package mcpagent
+
+import (
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/notes"
+)
+
+// multiNotify forwards every notification to several handlers in order.
+// The first handler is the primary one, it decides whether Run streams the
+// output. A panic of any other handler is recovered, so that an extra sink
+// cannot affect the task or the handlers after it.
+type multiNotify struct {
+	notifiers []Notify
+}
+
+// multiStreamingNotify is a multiNotify whose primary handler is a StreamingNotify
+type multiStreamingNotify struct {
+	*multiNotify
+}
+
+// OnStreamResult forwards a chunk of the result stream to the handlers that stream
+func (n *multiStreamingNotify) OnStreamResult(chunk string) {
+	n.each("OnStreamResult", func(notify Notify) {
+		if streamNotify, ok := notify.(StreamingNotify); ok {
+			streamNotify.OnStreamResult(chunk)
+		}
+	})
+}
+
+// NewMultiNotify returns a handler forwarding every notification to each of
+// notifiers, such as the notifier of the caller and the configured webhooks.
+// Nil handlers are skipped. The returned handler implements StreamingNotify
+// exactly when the first handler does, handlers without an optional interface
+// receive the same fallback notifications as when used alone.
+//
+// Parameters:
+//   - notifiers: Handlers receiving all notifications, the first one is the primary handler
+//
+// Returns:
+//   - Notify: Handler to pass to Run, the only handler when just one is given
+func NewMultiNotify(notifiers ...Notify) Notify {
+	var present []Notify
+	for _, notify := range notifiers {
+		if notify != nil {
+			present = append(present, notify)
+		}
+	}
+	if len(present) == 1 {
+		return present[0]
+	}
+
+	n := &multiNotify{notifiers: present}
+	if len(present) > 0 {
+		if _, ok := present[0].(StreamingNotify); ok {
+			return &multiStreamingNotify{multiNotify: n}
+		}
+	}
+	return n
+}
+
+// each calls fn with every handler, panics of the handlers after the first are recovered
+func (n *multiNotify) each(method string, fn func(notify Notify)) {
+	for i, notify := range n.notifiers {
+		if i == 0 {
+			fn(notify)
+			continue
+		}
+		func() {
+			defer recoverCallback(method)
+			fn(notify)
+		}()
+	}
+}
+
+// OnMessage forwards a message notification
+func (n *multiNotify) OnMessage(msg string) {
+	n.each("OnMessage", func(notify Notify) { notify.OnMessage(msg) })
+}
+
+// OnThinking forwards a thinking notification
+func (n *multiNotify) OnThinking(msg string) {
+	n.each("OnThinking", func(notify Notify) { notify.OnThinking(msg) })
+}
+
+// OnToolCall forwards a tool call without call ID
+func (n *multiNotify) OnToolCall(toolName string, params any) {
+	n.each("OnToolCall", func(notify Notify) { notify.OnToolCall(toolName, params) })
+}
+
+// OnToolCallResult forwards the result of a tool call without call ID
+func (n *multiNotify) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	n.each("OnToolCallResult", func(notify Notify) { notify.OnToolCallResult(toolName, result, err, duration) })
+}
+
+// OnResult forwards the final result
+func (n *multiNotify) OnResult(msg string) {
+	n.each("OnResult", func(notify Notify) { notify.OnResult(msg) })
+}
+
+// OnError forwards an error
+func (n *multiNotify) OnError(err error) {
+	n.each("OnError", func(notify Notify) { notify.OnError(err) })
+}
+
+// OnThinkingWithCall forwards a thinking notification, as OnThinking to handlers without timeline
+func (n *multiNotify) OnThinkingWithCall(msg string, relatedCallID string) {
+	n.each("OnThinkingWithCall", func(notify Notify) {
+		if timeline, ok := notify.(TimelineNotify); ok {
+			timeline.OnThinkingWithCall(msg, relatedCallID)
+			return
+		}
+		notify.OnThinking(msg)
+	})
+}
+
+// OnToolCallWithID forwards a tool call, as OnToolCall to handlers without timeline
+func (n *multiNotify) OnToolCallWithID(callID string, toolName string, params any) {
+	n.each("OnToolCallWithID", func(notify Notify) {
+		if timeline, ok := notify.(TimelineNotify); ok {
+			timeline.OnToolCallWithID(callID, toolName, params)
+			return
+		}
+		notify.OnToolCall(toolName, params)
+	})
+}
+
+// OnToolResult forwards the result of a tool call, as OnToolCallResult to handlers without timeline
+func (n *multiNotify) OnToolResult(callID string, toolName string, result string, err error, duration time.Duration) {
+	toolResult := ToolCallResult{CallID: callID, ToolName: toolName, Result: result, Err: err, Duration: duration}
+	n.each("OnToolResult", func(notify Notify) { notifyToolResult(notify, toolResult) })
+}
+
+// OnPlan forwards the execution plan, as OnMessage to handlers without plan support
+func (n *multiNotify) OnPlan(plan string) {
+	n.each("OnPlan", func(notify Notify) { notifyPlan(notify, plan) })
+}
+
+// OnToolUsage forwards the outcome of a tool invocation to handlers collecting usage
+func (n *multiNotify) OnToolUsage(usage ToolUsage) {
+	n.each("OnToolUsage", func(notify Notify) {
+		if usageNotify, ok := notify.(ToolUsageNotify); ok {
+			usageNotify.OnToolUsage(usage)
+		}
+	})
+}
+
+// OnAnswerModel forwards the model that produced the final answer to handlers recording it
+func (n *multiNotify) OnAnswerModel(model string) {
+	n.each("OnAnswerModel", func(notify Notify) {
+		if modelNotify, ok := notify.(ModelNotify); ok {
+			modelNotify.OnAnswerModel(model)
+		}
+	})
+}
+
+// OnVerification forwards the verdict on the result to handlers presenting it
+func (n *multiNotify) OnVerification(verification Verification) {
+	n.each("OnVerification", func(notify Notify) {
+		if verificationNotify, ok := notify.(VerificationNotify); ok {
+			verificationNotify.OnVerification(verification)
+		}
+	})
+}
+
+// OnNotes forwards the notes of the task to handlers including them in their report
+func (n *multiNotify) OnNotes(saved []notes.Note) {
+	n.each("OnNotes", func(notify Notify) {
+		if notesNotify, ok := notify.(NotesNotify); ok {
+			notesNotify.OnNotes(saved)
+		}
+	})
+}
+
+// OnMissingTools forwards the requested tools that do not exist to handlers recording them
+func (n *multiNotify) OnMissingTools(tools []config.MissingTool) {
+	n.each("OnMissingTools", func(notify Notify) {
+		if missingNotify, ok := notify.(MissingToolsNotify); ok {
+			missingNotify.OnMissingTools(tools)
+		}
+	})
+}
+
+// CheckpointInterval returns the smallest checkpoint interval of the handlers, 0 when none saves checkpoints
+func (n *multiNotify) CheckpointInterval() int {
+	interval := 0
+	for _, notify := range n.notifiers {
+		checkpointNotify, ok := notify.(CheckpointNotify)
+		if !ok {
+			continue
+		}
+		if every := checkpointNotify.CheckpointInterval(); every > 0 && (interval == 0 || every < interval) {
+			interval = every
+		}
+	}
+	return interval
+}
+
+// OnCheckpoint forwards a checkpoint to handlers saving them
+func (n *multiNotify) OnCheckpoint(checkpoint Checkpoint) {
+	n.each("OnCheckpoint", func(notify Notify) {
+		if checkpointNotify, ok := notify.(CheckpointNotify); ok {
+			checkpointNotify.OnCheckpoint(checkpoint)
+		}
+	})
+}
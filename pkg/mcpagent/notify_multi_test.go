@@ -0,0 +1,81 @@
+package mcpagent
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewMultiNotify(t *testing.T) {
+	primary := &timelineRecordingNotify{}
+	plain := new(MockNotify)
+	plain.On("OnThinking", "先搜索").Once()
+	plain.On("OnToolCall", "search", mock.Anything).Once()
+	plain.On("OnToolCallResult", "search", "结果", nil, time.Duration(0)).Once()
+	plain.On("OnMessage", "搜索").Once()
+	plain.On("OnResult", "done").Once()
+	notify := NewMultiNotify(primary, nil, plain)
+
+	// 不是流式的主通知器时不改变输出方式
+	_, streaming := notify.(StreamingNotify)
+	assert.False(t, streaming)
+
+	timeline := notify.(TimelineNotify)
+	timeline.OnThinkingWithCall("先搜索", "call_1")
+	timeline.OnToolCallWithID("call_1", "search", map[string]any{"q": "mcp"})
+	timeline.OnToolResult("call_1", "search", "结果", nil, 0)
+	notify.(PlanNotify).OnPlan("搜索")
+	notify.OnResult("done")
+
+	// 不支持时间线的通知器收到不带ID的通知
+	assert.Equal(t, []timelineEvent{
+		{kind: "thinking", content: "先搜索", relatedCallID: "call_1"},
+		{kind: "tool_call", callID: "call_1", toolName: "search"},
+		{kind: "tool_result", callID: "call_1", toolName: "search", content: "结果"},
+	}, primary.events)
+	plain.AssertExpectations(t)
+
+	// 只有一个通知器时直接返回它
+	assert.Same(t, primary, NewMultiNotify(nil, primary))
+}
+
+func TestMultiNotifyStreaming(t *testing.T) {
+	primary := &streamRecordingNotify{MockNotify: new(MockNotify)}
+	sink := &streamRecordingNotify{MockNotify: new(MockNotify)}
+	notify := NewMultiNotify(primary, sink)
+
+	streamNotify, ok := notify.(StreamingNotify)
+	if assert.True(t, ok, "主通知器是流式时保持流式输出") {
+		streamNotify.OnStreamResult("chunk")
+	}
+	assert.Equal(t, []string{"chunk"}, primary.chunks())
+	assert.Equal(t, []string{"chunk"}, sink.chunks())
+
+	_, streaming := NewMultiNotify(new(MockNotify), sink).(StreamingNotify)
+	assert.False(t, streaming)
+}
+
+func TestMultiNotifyRecoversSinkPanic(t *testing.T) {
+	before := RecoveredPanics()
+	primary := new(MockNotify)
+	primary.On("OnError", mock.Anything).Twice()
+	after := new(MockNotify)
+	after.On("OnError", mock.Anything).Twice()
+	notify := NewMultiNotify(primary, &panicNotify{}, after)
+
+	// 额外的通知器panic时不影响任务和其他通知器
+	notify.OnError(errors.New("失败"))
+	notify.OnError(errors.New("再次失败"))
+	primary.AssertExpectations(t)
+	after.AssertExpectations(t)
+	assert.Equal(t, before+2, RecoveredPanics())
+}
+
+func TestMultiNotifyCheckpointInterval(t *testing.T) {
+	notify := NewMultiNotify(new(MockNotify), &checkpointRecordingNotify{interval: 5}, &checkpointRecordingNotify{interval: 2})
+	assert.Equal(t, 2, notify.(CheckpointNotify).CheckpointInterval())
+	assert.Equal(t, 0, NewMultiNotify(new(MockNotify), new(MockNotify)).(CheckpointNotify).CheckpointInterval())
+}
@@ -0,0 +1,175 @@
+package mcpagent
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+)
+
+const (
+	// truncateSuffix is appended to results cut by the truncate processor
+	truncateSuffix = "\n...[内容已截断]"
+)
+
+// markdownLinkPattern matches inline markdown links and images such as [text](url) or ![alt](url).
+var markdownLinkPattern = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+
+// OutputProcessor transforms the final agent result before it is delivered
+// to the notifier. Implementations must be safe to call repeatedly.
+type OutputProcessor interface {
+	// Name returns a short identifier used in logs
+	Name() string
+	// Process returns the transformed text or an error if processing failed
+	Process(text string) (string, error)
+}
+
+// RedactProcessor replaces every match of a regular expression with a fixed replacement.
+type RedactProcessor struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// NewRedactProcessor compiles the rule and returns a redaction processor.
+//
+// Parameters:
+//   - rule: Redact rule with the pattern and replacement
+//
+// Returns:
+//   - *RedactProcessor: Processor ready for use
+//   - error: Error if the pattern does not compile
+func NewRedactProcessor(rule config.RedactRule) (*RedactProcessor, error) {
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &RedactProcessor{pattern: re, replacement: rule.Replacement}, nil
+}
+
+// Name implements OutputProcessor
+func (p *RedactProcessor) Name() string {
+	return "redact(" + p.pattern.String() + ")"
+}
+
+// Process implements OutputProcessor
+func (p *RedactProcessor) Process(text string) (string, error) {
+	return p.pattern.ReplaceAllString(text, p.replacement), nil
+}
+
+// MarkdownLinkStripProcessor replaces markdown links with their link text.
+type MarkdownLinkStripProcessor struct{}
+
+// Name implements OutputProcessor
+func (p *MarkdownLinkStripProcessor) Name() string {
+	return "strip_markdown_links"
+}
+
+// Process implements OutputProcessor
+func (p *MarkdownLinkStripProcessor) Process(text string) (string, error) {
+	return markdownLinkPattern.ReplaceAllString(text, "$1"), nil
+}
+
+// TruncateProcessor limits the result to a maximum number of characters.
+type TruncateProcessor struct {
+	maxLength int
+	suffix    string
+}
+
+// NewTruncateProcessor returns a processor that keeps at most maxLength characters.
+func NewTruncateProcessor(maxLength int) *TruncateProcessor {
+	return &TruncateProcessor{maxLength: maxLength, suffix: truncateSuffix}
+}
+
+// Name implements OutputProcessor
+func (p *TruncateProcessor) Name() string {
+	return "truncate"
+}
+
+// Process implements OutputProcessor
+func (p *TruncateProcessor) Process(text string) (string, error) {
+	if p.maxLength <= 0 {
+		return "", fmt.Errorf("无效的最大长度: %d", p.maxLength)
+	}
+	runes := []rune(text)
+	if len(runes) <= p.maxLength {
+		return text, nil
+	}
+	return string(runes[:p.maxLength]) + p.suffix, nil
+}
+
+// OutputPipeline applies a chain of OutputProcessor in order.
+type OutputPipeline struct {
+	processors []OutputProcessor
+}
+
+// NewOutputPipeline builds the pipeline described by the output configuration.
+// Redact rules whose pattern fails to compile are logged and skipped so that
+// a bad rule never blocks the task result.
+//
+// Parameters:
+//   - cfg: Output configuration section
+//
+// Returns:
+//   - *OutputPipeline: Pipeline with processors in configuration order
+func NewOutputPipeline(cfg config.OutputConfig) *OutputPipeline {
+	return newOutputPipeline(cfg, MessagesFor(""))
+}
+
+// newOutputPipeline builds the pipeline with the truncation marker of messages
+func newOutputPipeline(cfg config.OutputConfig, messages *Messages) *OutputPipeline {
+	pipeline := &OutputPipeline{}
+	for _, rule := range cfg.Redact {
+		p, err := NewRedactProcessor(rule)
+		if err != nil {
+			log.Printf("跳过无效的脱敏规则 %q: %v", rule.Pattern, err)
+			continue
+		}
+		pipeline.processors = append(pipeline.processors, p)
+	}
+	if cfg.StripMarkdownLinks {
+		pipeline.processors = append(pipeline.processors, &MarkdownLinkStripProcessor{})
+	}
+	if cfg.MaxLength > 0 {
+		pipeline.processors = append(pipeline.processors, &TruncateProcessor{maxLength: cfg.MaxLength, suffix: messages.ContentTruncated})
+	}
+	return pipeline
+}
+
+// Processors returns the processors in the order they are applied
+func (p *OutputPipeline) Processors() []OutputProcessor {
+	return p.processors
+}
+
+// Process runs every processor in order. A processor that fails or panics is
+// logged and skipped, keeping the text it received unchanged, so processing
+// never fails the task.
+//
+// Parameters:
+//   - text: Final agent result
+//
+// Returns:
+//   - string: Processed result
+func (p *OutputPipeline) Process(text string) string {
+	for _, processor := range p.processors {
+		text = runOutputProcessor(processor, text)
+	}
+	return text
+}
+
+// runOutputProcessor runs a single processor and falls back to the input on failure
+func runOutputProcessor(processor OutputProcessor, text string) (result string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("结果后处理器 %s 发生panic: %v", processor.Name(), r)
+			result = text
+		}
+	}()
+
+	out, err := processor.Process(text)
+	if err != nil {
+		log.Printf("结果后处理器 %s 处理失败: %v", processor.Name(), err)
+		return text
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// failingProcessor 总是返回错误的处理器
+type failingProcessor struct{}
+
+func (p *failingProcessor) Name() string { return "failing" }
+func (p *failingProcessor) Process(text string) (string, error) {
+	return "", errors.New("boom")
+}
+
+// panicProcessor 总是panic的处理器
+type panicProcessor struct{}
+
+func (p *panicProcessor) Name() string { return "panic" }
+func (p *panicProcessor) Process(text string) (string, error) {
+	panic("boom")
+}
+
+func TestRedactProcessor(t *testing.T) {
+	p, err := NewRedactProcessor(config.RedactRule{Pattern: `sk-[A-Za-z0-9]+`, Replacement: "[REDACTED]"})
+	require.NoError(t, err)
+
+	out, err := p.Process("key sk-abc123 and sk-XYZ")
+	require.NoError(t, err)
+	assert.Equal(t, "key [REDACTED] and [REDACTED]", out)
+
+	_, err = NewRedactProcessor(config.RedactRule{Pattern: `(`})
+	assert.Error(t, err)
+}
+
+func TestMarkdownLinkStripProcessor(t *testing.T) {
+	p := &MarkdownLinkStripProcessor{}
+	out, err := p.Process("见 [官网](https://example.com) 和 ![图](a.png)")
+	require.NoError(t, err)
+	assert.Equal(t, "见 官网 和 图", out)
+}
+
+func TestTruncateProcessor(t *testing.T) {
+	p := NewTruncateProcessor(3)
+	out, err := p.Process("你好世界")
+	require.NoError(t, err)
+	assert.Equal(t, "你好世"+truncateSuffix, out)
+
+	out, err = p.Process("abc")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", out)
+
+	_, err = NewTruncateProcessor(0).Process("abc")
+	assert.Error(t, err)
+}
+
+func TestOutputPipelineMultiPatternOrder(t *testing.T) {
+	pipeline := NewOutputPipeline(config.OutputConfig{
+		Redact: []config.RedactRule{
+			{Pattern: `token=\w+`, Replacement: "token=SECRET"},
+			// 第二条规则作用于第一条规则的输出，验证按顺序执行
+			{Pattern: `SECRET`, Replacement: "***"},
+			{Pattern: `(\d{3})\d{4}(\d{4})`, Replacement: "$1****$2"},
+		},
+		StripMarkdownLinks: true,
+		MaxLength:          40,
+	})
+
+	require.Len(t, pipeline.Processors(), 5)
+	assert.Equal(t, "strip_markdown_links", pipeline.Processors()[3].Name())
+	assert.Equal(t, "truncate", pipeline.Processors()[4].Name())
+
+	out := pipeline.Process("token=abc 13812345678 [link](http://x)")
+	assert.Equal(t, "token=*** 138****5678 link", out)
+
+	long := pipeline.Process(strings.Repeat("a", 50))
+	assert.Equal(t, strings.Repeat("a", 40)+truncateSuffix, long)
+}
+
+func TestOutputPipelineSkipsInvalidRule(t *testing.T) {
+	pipeline := NewOutputPipeline(config.OutputConfig{
+		Redact: []config.RedactRule{
+			{Pattern: `(`, Replacement: "x"},
+			{Pattern: `secret`, Replacement: "***"},
+		},
+	})
+	require.Len(t, pipeline.Processors(), 1)
+	assert.Equal(t, "a *** b", pipeline.Process("a secret b"))
+}
+
+func TestOutputPipelineFallbackOnFailure(t *testing.T) {
+	redact, err := NewRedactProcessor(config.RedactRule{Pattern: `secret`, Replacement: "***"})
+	require.NoError(t, err)
+
+	pipeline := &OutputPipeline{processors: []OutputProcessor{
+		&failingProcessor{},
+		redact,
+		&panicProcessor{},
+	}}
+	assert.Equal(t, "a *** b", pipeline.Process("a secret b"))
+}
+
+func TestOutputPipelineEmpty(t *testing.T) {
+	pipeline := NewOutputPipeline(config.OutputConfig{})
+	assert.Empty(t, pipeline.Processors())
+	assert.Equal(t, "unchanged", pipeline.Process("unchanged"))
+}
+
+func TestExecuteAgentTaskAppliesOutputPipeline(t *testing.T) {
+	ctx := context.Background()
+
+	mockModel := new(MockToolCallingChatModel)
+	mockModel.On("WithTools", mock.Anything).Return(mockModel, nil)
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).
+		Return(schema.AssistantMessage("api key sk-abc123", nil), nil)
+
+	cfg := &config.Config{
+		SystemPrompt: "test prompt",
+		MaxStep:      5,
+		Output: config.OutputConfig{
+			Redact: []config.RedactRule{{Pattern: `sk-\w+`, Replacement: "[REDACTED]"}},
+		},
+	}
+
+	ragent, err := createReActAgent(ctx, cfg, []tool.BaseTool{}, mockModel)
+	require.NoError(t, err)
+
+	mockNotify := new(MockNotify)
+	mockNotify.On("OnResult", "api key [REDACTED]").Return()
+
+	err = executeAgentTask(ctx, cfg, ragent, "test task", mockNotify)
+	require.NoError(t, err)
+	mockNotify.AssertExpectations(t)
+}
@@ -0,0 +1,122 @@
+package mcpagent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const errMsgInvalidJSON = "不是有效的JSON: %v"
+
+// OutputSchemaError is returned when the final answer still does not match
+// config.Config.OutputSchema after all repair rounds
+type OutputSchemaError struct {
+	Output   string   // 最后一次的输出
+	Failures []string // 校验失败原因
+}
+
+func (e *OutputSchemaError) Error() string {
+	return "最终输出不符合JSON Schema: " + strings.Join(e.Failures, "; ")
+}
+
+// generateFunc runs the agent on a conversation and returns the final message
+type generateFunc func(ctx context.Context, messages []*schema.Message) (*schema.Message, error)
+
+// outputSchemaInstruction returns the formatting instructions appended to the system prompt
+func outputSchemaInstruction(cfg *config.Config) string {
+	return fmt.Sprintf(messagesOf(cfg).OutputSchemaInstruction, strings.TrimSpace(cfg.OutputSchema))
+}
+
+// generateStructuredOutput validates the final answer against outputSchema and asks
+// the model to fix it until it matches or the repair rounds are used up.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - cfg: Configuration containing the repair rounds and language
+//   - outputSchema: Compiled output schema
+//   - messages: Conversation that produced output
+//   - output: Final message of the agent
+//   - generate: Function running the agent on a conversation
+//
+// Returns:
+//   - string: Validated JSON
+//   - error: *OutputSchemaError if the output never matches, or the error of a repair round
+func generateStructuredOutput(ctx context.Context, cfg *config.Config, outputSchema *openapi3.Schema,
+	messages []*schema.Message, output *schema.Message, generate generateFunc) (string, error) {
+	result, failures := validateStructuredOutput(outputSchema, output.Content)
+	for round := 0; len(failures) > 0 && round < cfg.EffectiveOutputSchemaRepairs(); round++ {
+		messages = append(messages, output, schema.UserMessage(
+			fmt.Sprintf(messagesOf(cfg).OutputSchemaRepair, strings.Join(failures, "\n"))))
+
+		var err error
+		output, err = generate(ctx, messages)
+		if err != nil {
+			return "", err
+		}
+		result, failures = validateStructuredOutput(outputSchema, output.Content)
+	}
+
+	if len(failures) > 0 {
+		return "", &OutputSchemaError{Output: output.Content, Failures: failures}
+	}
+	return result, nil
+}
+
+// validateStructuredOutput extracts the JSON of a model answer and validates it.
+// A surrounding Markdown code fence is removed.
+//
+// Returns:
+//   - string: Extracted JSON
+//   - []string: Validation failures, empty when the output is valid
+func validateStructuredOutput(outputSchema *openapi3.Schema, content string) (string, []string) {
+	content = stripCodeFence(content)
+
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return content, []string{fmt.Sprintf(errMsgInvalidJSON, err)}
+	}
+
+	err := outputSchema.VisitJSON(value, openapi3.MultiErrors())
+	if err == nil {
+		return content, nil
+	}
+	return content, schemaFailures(err)
+}
+
+// schemaFailures flattens a validation error into one line per failure
+func schemaFailures(err error) []string {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		var failures []string
+		for _, e := range multi {
+			failures = append(failures, schemaFailures(e)...)
+		}
+		return failures
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return []string{"/" + strings.Join(schemaErr.JSONPointer(), "/") + ": " + schemaErr.Reason}
+	}
+	return []string{err.Error()}
+}
+
+// stripCodeFence removes a Markdown code fence such as ```json ... ``` around content
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") || !strings.HasSuffix(content, "```") || len(content) < 6 {
+		return content
+	}
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```"), "```")
+	// 去掉语言标记
+	if i := strings.IndexByte(content, '\n'); i >= 0 && !strings.ContainsAny(content[:i], "{[\"") {
+		content = content[i+1:]
+	}
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,129 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOutputSchema = `{
+	"type": "object",
+	"required": ["name", "score"],
+	"properties": {
+		"name": {"type": "string"},
+		"score": {"type": "integer", "minimum": 0}
+	}
+}`
+
+// scriptedChatModel 按顺序返回预设回答的模拟模型，记录每次的输入
+type scriptedChatModel struct {
+	mu      sync.Mutex
+	replies []string
+	inputs  [][]*schema.Message
+}
+
+func (m *scriptedChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs = append(m.inputs, input)
+	reply := m.replies[len(m.replies)-1]
+	if len(m.inputs) <= len(m.replies) {
+		reply = m.replies[len(m.inputs)-1]
+	}
+	return schema.AssistantMessage(reply, nil), nil
+}
+
+func (m *scriptedChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// 分多个块返回，验证流式输出会被完整拼接
+	half := len(msg.Content) / 2
+	return schema.StreamReaderFromArray([]*schema.Message{
+		schema.AssistantMessage(msg.Content[:half], nil),
+		schema.AssistantMessage(msg.Content[half:], nil),
+	}), nil
+}
+
+func (m *scriptedChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// streamResultNotify is a notify using the streaming API
+type streamResultNotify struct {
+	*MockNotify
+}
+
+func (n *streamResultNotify) OnStreamResult(chunk string) {}
+
+func runWithOutputSchema(t *testing.T, chatModel *scriptedChatModel, repairs int, notify Notify) error {
+	t.Helper()
+	ctx := context.Background()
+	mockConfig := newLifecycleMockConfig(chatModel, func() {})
+	mockConfig.Config.SystemPrompt = "today is {date}"
+	mockConfig.Config.OutputSchema = testOutputSchema
+	mockConfig.Config.OutputSchemaRepairs = repairs
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+	return agent.Execute(ctx, "task", notify)
+}
+
+func TestOutputSchemaRepairLoop(t *testing.T) {
+	chatModel := &scriptedChatModel{replies: []string{
+		"结果是 Alice 得了 90 分",
+		"```json\n{\"name\": \"Alice\", \"score\": -1}\n```",
+		"```json\n{\"name\": \"Alice\", \"score\": 90}\n```",
+	}}
+	notify := newResultNotify()
+	require.NoError(t, runWithOutputSchema(t, chatModel, 0, notify))
+
+	notify.AssertCalled(t, "OnResult", `{"name": "Alice", "score": 90}`)
+	require.Len(t, chatModel.inputs, 3, "两轮修复后输出通过校验")
+
+	// 系统提示词包含Schema，占位符仍然被替换
+	system := chatModel.inputs[0][0]
+	assert.Equal(t, schema.System, system.Role)
+	assert.NotContains(t, system.Content, "{date}")
+	assert.Contains(t, system.Content, `"required": ["name", "score"]`)
+
+	// 修复请求携带上一次的回答和校验失败原因
+	second := chatModel.inputs[1]
+	assert.Equal(t, "结果是 Alice 得了 90 分", second[len(second)-2].Content)
+	assert.Contains(t, second[len(second)-1].Content, "不是有效的JSON")
+	third := chatModel.inputs[2]
+	assert.Contains(t, third[len(third)-1].Content, "/score")
+}
+
+func TestOutputSchemaRepairLoopStreaming(t *testing.T) {
+	chatModel := &scriptedChatModel{replies: []string{`{"name": "Bob"}`, `{"name": "Bob", "score": 7}`}}
+	notify := &streamResultNotify{MockNotify: newResultNotify()}
+	require.NoError(t, runWithOutputSchema(t, chatModel, 0, notify))
+
+	notify.AssertCalled(t, "OnResult", `{"name": "Bob", "score": 7}`)
+	assert.Len(t, chatModel.inputs, 2)
+}
+
+func TestOutputSchemaRepairsExhausted(t *testing.T) {
+	chatModel := &scriptedChatModel{replies: []string{`{"name": 1, "score": "high"}`}}
+	notify := newResultNotify()
+	err := runWithOutputSchema(t, chatModel, 1, notify)
+
+	var schemaErr *OutputSchemaError
+	require.True(t, errors.As(err, &schemaErr), "err: %v", err)
+	assert.Equal(t, `{"name": 1, "score": "high"}`, schemaErr.Output)
+	assert.Len(t, schemaErr.Failures, 2)
+	assert.True(t, strings.HasPrefix(schemaErr.Failures[0], "/name"), schemaErr.Failures)
+	assert.Equal(t, ErrorCodeOutputInvalid, ErrorCode(err))
+	assert.Len(t, chatModel.inputs, 2, "只修复一轮")
+	notify.AssertNotCalled(t, "OnResult")
+}
@@ -0,0 +1,109 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// recoveredPanics counts the panics recovered since the process started
+var recoveredPanics atomic.Int64
+
+// PanicError replaces a recovered panic. The stack is logged together with ID,
+// so the error shown to users can be matched with the log line.
+type PanicError struct {
+	ID    string // 关联日志的错误ID
+	Where string // 发生panic的位置
+	Value any    // recover返回的值
+}
+
+// Error implements the error interface
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s发生内部错误（错误ID: %s）: %v", e.Where, e.ID, e.Value)
+}
+
+// RecoverPanic records a panic recovered in a deferred function: it increments
+// the panic counter and logs the value and the stack with a new error ID.
+// It must be called from the deferred function, so the stack still contains
+// the frames that panicked.
+//
+// Parameters:
+//   - where: Description of the place that panicked, e.g. "任务task_1"
+//   - recovered: Value returned by recover
+//
+// Returns:
+//   - *PanicError: Error to report instead of the panic
+func RecoverPanic(where string, recovered any) *PanicError {
+	count := recoveredPanics.Add(1)
+	panicErr := &PanicError{
+		ID:    fmt.Sprintf("panic_%d_%d", time.Now().UnixNano(), count),
+		Where: where,
+		Value: recovered,
+	}
+	log.Printf("[%s] %s发生panic: %v\n%s", panicErr.ID, where, recovered, debug.Stack())
+	return panicErr
+}
+
+// RecoveredPanics returns the number of panics recovered since the process started
+func RecoveredPanics() int64 {
+	return recoveredPanics.Load()
+}
+
+// recoveringTool turns a panic of the wrapped tool into a result telling the
+// model that the tool failed, so the agent can continue without it
+type recoveringTool struct {
+	tool.InvokableTool
+	messages *Messages
+}
+
+// withPanicRecovery wraps the invokable tools so that their panics do not abort the task
+func withPanicRecovery(cfg *config.Config, tools []tool.BaseTool) []tool.BaseTool {
+	wrapped := make([]tool.BaseTool, 0, len(tools))
+	for _, t := range tools {
+		if invokable, ok := t.(tool.InvokableTool); ok {
+			t = &recoveringTool{InvokableTool: invokable, messages: messagesOf(cfg)}
+		}
+		wrapped = append(wrapped, t)
+	}
+	return wrapped
+}
+
+// InvokableRun runs the wrapped tool and converts a panic into a tool result
+func (t *recoveringTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (result string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			name := t.name(ctx)
+			panicErr := RecoverPanic(fmt.Sprintf("工具%s", name), recovered)
+			result, err = fmt.Sprintf(t.messages.ToolPanicked, name, panicErr.ID), nil
+		}
+	}()
+	return t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+}
+
+// name returns the name of the wrapped tool, "unknown" if its Info fails as well
+func (t *recoveringTool) name(ctx context.Context) (name string) {
+	name = "unknown"
+	defer func() {
+		if recover() != nil {
+			name = "unknown"
+		}
+	}()
+	if info, err := t.Info(ctx); err == nil && info != nil {
+		name = info.Name
+	}
+	return name
+}
+
+// recoverCallback recovers a panic of a callback method, the callback has no
+// effect then and the agent keeps running
+func recoverCallback(method string) {
+	if recovered := recover(); recovered != nil {
+		RecoverPanic("回调"+method, recovered)
+	}
+}
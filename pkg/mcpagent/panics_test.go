@@ -0,0 +1,108 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// panicTool 调用时panic的工具
+type panicTool struct{}
+
+func (p *panicTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "scan", Desc: "scan a host"}, nil
+}
+
+func (p *panicTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var result map[string]string
+	result["host"] = argumentsInJSON
+	return "", nil
+}
+
+// panicNotify 每个通知都panic的通知处理器
+type panicNotify struct {
+	MockNotify
+}
+
+func (p *panicNotify) OnToolCall(toolName string, params any) { panic("notify failed") }
+func (p *panicNotify) OnError(err error)                      { panic("notify failed") }
+
+func TestPanickingToolDoesNotAbortTask(t *testing.T) {
+	before := RecoveredPanics()
+	chatModel := newSummarizeChatModel()
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{MaxStep: 5, SystemPrompt: "你是助手"}
+	mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{&panicTool{}}, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+
+	agent, err := newAgent(context.Background(), &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	notify := newResultNotify()
+	require.NoError(t, agent.Execute(context.Background(), "扫描example.com", notify))
+	notify.AssertCalled(t, "OnResult", "done")
+
+	// 大模型收到描述失败的工具结果后继续执行
+	observed := *chatModel.observed
+	require.Len(t, observed, 1)
+	assert.Regexp(t, `^工具 scan 执行时发生内部错误（错误ID: panic_\d+_\d+）`, observed[0])
+	assert.Equal(t, before+1, RecoveredPanics())
+}
+
+func TestLoggerCallbackNilNotifyIsNoop(t *testing.T) {
+	callback := &LoggerCallback{}
+	ctx := context.Background()
+	info := &callbacks.RunInfo{Name: "scan"}
+	message := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_1", Function: schema.FunctionCall{Name: "scan", Arguments: `{}`}},
+	})
+
+	before := RecoveredPanics()
+	assert.NotPanics(t, func() {
+		assert.Equal(t, ctx, callback.OnStart(ctx, info, message))
+		assert.Equal(t, ctx, callback.OnEnd(ctx, info, schema.AssistantMessage("结果", nil)))
+		assert.Equal(t, ctx, callback.OnError(ctx, info, errors.New("test error")))
+		assert.Equal(t, ctx, callback.OnStartWithStreamInput(ctx, info, nil))
+	})
+	assert.Equal(t, before, RecoveredPanics())
+}
+
+func TestLoggerCallbackRecoversNotifyPanic(t *testing.T) {
+	callback := &LoggerCallback{notify: &panicNotify{}}
+	ctx := context.Background()
+	message := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_1", Function: schema.FunctionCall{Name: "scan", Arguments: `{}`}},
+	})
+
+	before := RecoveredPanics()
+	assert.NotPanics(t, func() {
+		callback.OnStart(ctx, &callbacks.RunInfo{}, message)
+		callback.OnError(ctx, &callbacks.RunInfo{}, errors.New("test error"))
+	})
+	assert.Equal(t, before+2, RecoveredPanics())
+}
+
+func TestRecoverPanic(t *testing.T) {
+	var err error
+	func() {
+		defer func() {
+			err = RecoverPanic("测试", recover())
+		}()
+		panic("boom")
+	}()
+
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Equal(t, "测试发生内部错误（错误ID: "+panicErr.ID+"）: boom", err.Error())
+	assert.Equal(t, ErrorCodeInternal, ErrorCode(err))
+}
@@ -0,0 +1,162 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// PlanNotify extends Notify for handlers that present the execution plan
+// generated in plan mode separately from other messages.
+//
+// When cfg.PlanMode is enabled and the notify handler does not implement
+// PlanNotify, the plan is sent through OnMessage.
+type PlanNotify interface {
+	Notify
+
+	// OnPlan sends the execution plan before the agent starts acting
+	OnPlan(plan string)
+}
+
+// ErrPlanRejected is returned when the user rejects the execution plan of a
+// task with cfg.PlanApproval
+var ErrPlanRejected = errors.New("执行计划未获批准")
+
+// PlanDecision is the answer of the user to an execution plan
+type PlanDecision struct {
+	Approved bool   `json:"approved"`           // 是否按计划执行
+	Feedback string `json:"feedback,omitempty"` // 对计划的补充说明，批准时追加到计划之后，拒绝时作为原因
+}
+
+// PlanApprover asks the user to approve the execution plan of a task, such as
+// a prompt on the terminal or a request to the web UI. It blocks until the
+// user decides or ctx is done.
+type PlanApprover func(ctx context.Context, plan string) (PlanDecision, error)
+
+// planApproverKey is the context key of the PlanApprover of a task
+type planApproverKey struct{}
+
+// WithPlanApprover returns a context in which tasks with cfg.PlanApproval
+// wait for approver to approve their execution plan before acting. Without
+// an approver such tasks fail after the plan is generated.
+func WithPlanApprover(ctx context.Context, approver PlanApprover) context.Context {
+	return context.WithValue(ctx, planApproverKey{}, approver)
+}
+
+// PlanApproverFromContext returns the PlanApprover set by WithPlanApprover, nil if there is none
+func PlanApproverFromContext(ctx context.Context) PlanApprover {
+	approver, _ := ctx.Value(planApproverKey{}).(PlanApprover)
+	return approver
+}
+
+// generatePlan asks the model for an execution plan of task before the ReAct
+// loop starts. The model is called without tools, the names and descriptions
+// of the tools are listed in the prompt so the plan can refer to them.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - cfg: Configuration containing system prompt and language
+//   - chatModel: Chat model used for planning, tools are not bound
+//   - tools: Tools the agent can use during execution
+//   - messages: Messages of the task as assembled by formatConversation, not modified
+//
+// Returns:
+//   - string: The plan without surrounding whitespace
+//   - error: Error if the model fails or returns an empty plan
+func generatePlan(ctx context.Context, cfg *config.Config, chatModel model.BaseChatModel, tools []tool.BaseTool, messages []*schema.Message) (string, error) {
+	system := *messages[0]
+	msg := append([]*schema.Message{&system}, messages[1:]...)
+	if store := notesStore(ctx, cfg); store != nil {
+		msg = withNoteKeys(cfg, store, msg)
+	}
+	msg[0].Content += fmt.Sprintf(messagesOf(cfg).PlanInstruction, describeTools(ctx, cfg, tools))
+
+	output, err := chatModel.Generate(ctx, msg)
+	if err != nil {
+		return "", fmt.Errorf(errMsgPlanFailed, err)
+	}
+	plan := strings.TrimSpace(output.Content)
+	if plan == "" {
+		return "", errors.New(errMsgPlanEmpty)
+	}
+	return plan, nil
+}
+
+// approvePlan waits for the user to approve plan when cfg.PlanApproval is
+// enabled. The feedback of an approved plan is appended to it.
+//
+// Parameters:
+//   - ctx: Context carrying the PlanApprover, see WithPlanApprover
+//   - cfg: Configuration containing the approval setting and language
+//   - plan: Execution plan sent to the user
+//   - notify: Notification handler told about the approval
+//
+// Returns:
+//   - string: Plan used for the execution
+//   - error: ErrPlanRejected if the user rejects the plan, or an error if no approval can be requested
+func approvePlan(ctx context.Context, cfg *config.Config, plan string, notify Notify) (string, error) {
+	if !cfg.PlanApproval {
+		return plan, nil
+	}
+	approver := PlanApproverFromContext(ctx)
+	if approver == nil {
+		return "", errors.New(errMsgPlanNoApprover)
+	}
+
+	decision, err := approver(ctx, plan)
+	if err != nil {
+		return "", fmt.Errorf(errMsgPlanApproval, err)
+	}
+	feedback := strings.TrimSpace(decision.Feedback)
+	if !decision.Approved {
+		if feedback != "" {
+			return "", fmt.Errorf("%w: %s", ErrPlanRejected, feedback)
+		}
+		return "", ErrPlanRejected
+	}
+
+	messages := messagesOf(cfg)
+	notify.OnMessage(messages.PlanApproved)
+	if feedback != "" {
+		plan += fmt.Sprintf(messages.PlanFeedback, feedback)
+	}
+	return plan, nil
+}
+
+// describeTools lists the tools as "- name: description" lines for the planning prompt
+func describeTools(ctx context.Context, cfg *config.Config, tools []tool.BaseTool) string {
+	var b strings.Builder
+	for _, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			log.Printf("获取工具信息失败: %v", err)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", info.Name, strings.TrimSpace(info.Desc))
+	}
+	if b.Len() == 0 {
+		return messagesOf(cfg).PlanNoTools
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// planContext returns the text appended to the system prompt of the ReAct loop
+func planContext(cfg *config.Config, plan string) string {
+	return fmt.Sprintf(messagesOf(cfg).PlanContext, plan)
+}
+
+// notifyPlan sends plan through OnPlan, or OnMessage if notify does not implement PlanNotify
+func notifyPlan(notify Notify, plan string) {
+	if planNotify, ok := notify.(PlanNotify); ok {
+		planNotify.OnPlan(plan)
+		return
+	}
+	notify.OnMessage(plan)
+}
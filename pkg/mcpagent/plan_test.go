@@ -0,0 +1,204 @@
+package mcpagent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// plannedCall 记录模型的一次调用
+type plannedCall struct {
+	bound  bool // 调用时是否绑定了工具
+	system string
+}
+
+// planChatModel 第一次调用返回计划，之后直接回答任务
+type planChatModel struct {
+	mu    *sync.Mutex
+	calls *[]plannedCall
+	plan  string
+	bound bool
+}
+
+func newPlanChatModel(plan string) *planChatModel {
+	return &planChatModel{mu: &sync.Mutex{}, calls: &[]plannedCall{}, plan: plan}
+}
+
+func (m *planChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*m.calls = append(*m.calls, plannedCall{bound: m.bound, system: input[0].Content})
+	if len(*m.calls) == 1 {
+		return schema.AssistantMessage(m.plan, nil), nil
+	}
+	return schema.AssistantMessage("done: "+input[len(input)-1].Content, nil), nil
+}
+
+func (m *planChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *planChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound := *m
+	bound.bound = true
+	return &bound, nil
+}
+
+// planRecordingNotify 记录执行计划的通知器
+type planRecordingNotify struct {
+	*MockNotify
+	plans []string
+}
+
+func (n *planRecordingNotify) OnPlan(plan string) {
+	n.plans = append(n.plans, plan)
+}
+
+func newPlanAgent(t *testing.T, chatModel model.ToolCallingChatModel) *Agent {
+	return newPlanAgentWithApproval(t, chatModel, false)
+}
+
+func newPlanAgentWithApproval(t *testing.T, chatModel model.ToolCallingChatModel, approval bool) *Agent {
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{MaxStep: 5, SystemPrompt: "你是{field}专家", PlanMode: true, PlanApproval: approval,
+		PlaceHolders: map[string]any{"field": "网络安全"}}
+	mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{&echoTool{}}, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+
+	agent, err := newAgent(context.Background(), &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { agent.Close() })
+	return agent
+}
+
+func TestAgentExecutePlanMode(t *testing.T) {
+	plan := "1. 使用echo回显{target}\n2. 总结结果"
+	chatModel := newPlanChatModel(" " + plan + "\n")
+	agent := newPlanAgent(t, chatModel)
+
+	notify := &planRecordingNotify{MockNotify: newResultNotify()}
+	require.NoError(t, agent.Execute(context.Background(), "分析example.com", notify))
+
+	// 先不带工具生成计划，再带着计划执行任务
+	calls := *chatModel.calls
+	require.Len(t, calls, 2)
+	assert.False(t, calls[0].bound)
+	assert.True(t, strings.HasPrefix(calls[0].system, "你是网络安全专家"))
+	assert.Contains(t, calls[0].system, "先制定执行计划")
+	assert.Contains(t, calls[0].system, "- echo: echo the input")
+
+	assert.True(t, calls[1].bound)
+	assert.NotContains(t, calls[1].system, "先制定执行计划")
+	assert.Contains(t, calls[1].system, "按照以下执行计划完成任务")
+	assert.Contains(t, calls[1].system, plan, "计划中的大括号不会被当作占位符")
+
+	assert.Equal(t, []string{plan}, notify.plans)
+	notify.AssertCalled(t, "OnResult", "done: 分析example.com")
+}
+
+func TestAgentExecutePlanModeWithoutPlanNotify(t *testing.T) {
+	chatModel := newPlanChatModel("1. 回答问题")
+	agent := newPlanAgent(t, chatModel)
+
+	// 不支持OnPlan的通知器通过OnMessage收到计划
+	notify := newResultNotify()
+	require.NoError(t, agent.Execute(context.Background(), "你好", notify))
+	notify.AssertCalled(t, "OnMessage", "1. 回答问题")
+	notify.AssertCalled(t, "OnResult", "done: 你好")
+}
+
+func TestAgentExecutePlanModeEmptyPlan(t *testing.T) {
+	chatModel := newPlanChatModel("  ")
+	agent := newPlanAgent(t, chatModel)
+
+	notify := &planRecordingNotify{MockNotify: newResultNotify()}
+	err := agent.Execute(context.Background(), "你好", notify)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "生成执行计划失败")
+
+	// 没有计划时不执行任务
+	assert.Len(t, *chatModel.calls, 1)
+	assert.Empty(t, notify.plans)
+	notify.AssertNotCalled(t, "OnResult", mock.Anything)
+}
+
+func TestAgentExecutePlanApproval(t *testing.T) {
+	t.Run("批准后带着补充说明执行", func(t *testing.T) {
+		chatModel := newPlanChatModel("1. 回答问题")
+		agent := newPlanAgentWithApproval(t, chatModel, true)
+
+		var asked []string
+		ctx := WithPlanApprover(context.Background(), func(ctx context.Context, plan string) (PlanDecision, error) {
+			asked = append(asked, plan)
+			return PlanDecision{Approved: true, Feedback: " 用中文回答 "}, nil
+		})
+		notify := &planRecordingNotify{MockNotify: newResultNotify()}
+		require.NoError(t, agent.Execute(ctx, "你好", notify))
+
+		assert.Equal(t, []string{"1. 回答问题"}, asked)
+		notify.AssertCalled(t, "OnMessage", "执行计划已批准，开始执行")
+		calls := *chatModel.calls
+		require.Len(t, calls, 2)
+		assert.Contains(t, calls[1].system, "1. 回答问题\n\n用户对计划的补充说明：用中文回答")
+		notify.AssertCalled(t, "OnResult", "done: 你好")
+	})
+
+	t.Run("拒绝后不执行", func(t *testing.T) {
+		chatModel := newPlanChatModel("1. 回答问题")
+		agent := newPlanAgentWithApproval(t, chatModel, true)
+
+		ctx := WithPlanApprover(context.Background(), func(ctx context.Context, plan string) (PlanDecision, error) {
+			return PlanDecision{Feedback: "不要联网"}, nil
+		})
+		notify := &planRecordingNotify{MockNotify: newResultNotify()}
+		err := agent.Execute(ctx, "你好", notify)
+		require.ErrorIs(t, err, ErrPlanRejected)
+		assert.Contains(t, err.Error(), "不要联网")
+
+		assert.Len(t, *chatModel.calls, 1)
+		assert.Len(t, notify.plans, 1, "拒绝前计划已经推送")
+		notify.AssertNotCalled(t, "OnResult", mock.Anything)
+	})
+
+	t.Run("批准出错时失败", func(t *testing.T) {
+		chatModel := newPlanChatModel("1. 回答问题")
+		agent := newPlanAgentWithApproval(t, chatModel, true)
+
+		ctx := WithPlanApprover(context.Background(), func(ctx context.Context, plan string) (PlanDecision, error) {
+			return PlanDecision{}, context.Canceled
+		})
+		err := agent.Execute(ctx, "你好", newResultNotify())
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Contains(t, err.Error(), "等待批准执行计划失败")
+		assert.Len(t, *chatModel.calls, 1)
+	})
+
+	t.Run("没有批准方式时失败", func(t *testing.T) {
+		chatModel := newPlanChatModel("1. 回答问题")
+		agent := newPlanAgentWithApproval(t, chatModel, true)
+
+		err := agent.Execute(context.Background(), "你好", newResultNotify())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "无法请求批准")
+		assert.Len(t, *chatModel.calls, 1)
+	})
+}
+
+func TestDescribeTools(t *testing.T) {
+	cfg := &config.Config{Language: config.LanguageEn}
+	assert.Equal(t, "(no tools available)", describeTools(context.Background(), cfg, nil))
+	assert.Equal(t, "- echo: echo the input", describeTools(context.Background(), cfg, []tool.BaseTool{&echoTool{}}))
+}
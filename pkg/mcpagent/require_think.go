@@ -0,0 +1,121 @@
+package mcpagent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// requiredThinkParam is the parameter added by agent.require_think
+const requiredThinkParam = "think"
+
+// thinkingTool presents the wrapped tool with a required think parameter and
+// removes it from the arguments before the tool runs. The think value reaches
+// the notify handler through the tool call callbacks like the think field of
+// any other tool.
+type thinkingTool struct {
+	tool.InvokableTool
+	description string
+}
+
+// withRequiredThink wraps the invokable tools with thinkingTool when cfg
+// enables agent.require_think. Tools that already define think or thought
+// keep their schema and receive their arguments unchanged.
+func withRequiredThink(ctx context.Context, cfg *config.Config, tools []tool.BaseTool) []tool.BaseTool {
+	if cfg == nil || !cfg.Agent.RequireThink {
+		return tools
+	}
+	description := messagesOf(cfg).ThinkParameter
+	wrapped := make([]tool.BaseTool, 0, len(tools))
+	for _, t := range tools {
+		if invokable, ok := t.(tool.InvokableTool); ok && !definesThink(ctx, invokable) {
+			t = &thinkingTool{InvokableTool: invokable, description: description}
+		}
+		wrapped = append(wrapped, t)
+	}
+	return wrapped
+}
+
+// definesThink reports whether the parameters of t contain one of ThinkFieldName
+func definesThink(ctx context.Context, t tool.BaseTool) bool {
+	info, err := t.Info(ctx)
+	if err != nil || info == nil || info.ParamsOneOf == nil {
+		return false
+	}
+	params, err := info.ParamsOneOf.ToOpenAPIV3()
+	if err != nil || params == nil {
+		return false
+	}
+	for _, fieldName := range ThinkFieldName {
+		if _, exists := params.Properties[fieldName]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// Info returns the information of the wrapped tool with the think parameter
+// added. The schema is copied, MCP tools share their information across hubs.
+func (t *thinkingTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	info, err := t.InvokableTool.Info(ctx)
+	if err != nil || info == nil {
+		return info, err
+	}
+
+	params := openapi3.NewObjectSchema()
+	if info.ParamsOneOf != nil {
+		original, err := info.ParamsOneOf.ToOpenAPIV3()
+		if err != nil {
+			return nil, err
+		}
+		if original != nil {
+			copied := *original
+			params = &copied
+		}
+	}
+	properties := make(openapi3.Schemas, len(params.Properties)+1)
+	for name, property := range params.Properties {
+		properties[name] = property
+	}
+	think := openapi3.NewStringSchema()
+	think.Description = t.description
+	properties[requiredThinkParam] = openapi3.NewSchemaRef("", think)
+	params.Properties = properties
+	params.Required = append([]string{requiredThinkParam}, params.Required...)
+
+	augmented := *info
+	augmented.ParamsOneOf = schema.NewParamsOneOfByOpenAPIV3(params)
+	return &augmented, nil
+}
+
+// InvokableRun removes the think parameter and runs the wrapped tool
+func (t *thinkingTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return t.InvokableTool.InvokableRun(ctx, stripThink(argumentsInJSON), opts...)
+}
+
+// stripThink removes the think parameter from the JSON arguments of a tool
+// call. Arguments that are not a JSON object are returned unchanged, the
+// tool reports them as invalid.
+func stripThink(argumentsInJSON string) string {
+	if strings.TrimSpace(argumentsInJSON) == "" {
+		return argumentsInJSON
+	}
+	arguments, err := decodeArguments(argumentsInJSON)
+	if err != nil {
+		return argumentsInJSON
+	}
+	if _, exists := arguments[requiredThinkParam]; !exists {
+		return argumentsInJSON
+	}
+	delete(arguments, requiredThinkParam)
+	stripped, err := json.Marshal(arguments)
+	if err != nil {
+		return argumentsInJSON
+	}
+	return string(stripped)
+}
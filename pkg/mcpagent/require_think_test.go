@@ -0,0 +1,140 @@
+package mcpagent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/internal/testmcp"
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaTool 记录收到的参数并返回固定结果的测试工具
+type schemaTool struct {
+	name     string
+	params   *openapi3.Schema
+	received []string
+}
+
+func (s *schemaTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: s.name, Desc: "test tool", ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(s.params)}, nil
+}
+
+func (s *schemaTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	s.received = append(s.received, argumentsInJSON)
+	return "ok", nil
+}
+
+func newSearchSchema() *openapi3.Schema {
+	params := openapi3.NewObjectSchema().WithProperty("query", openapi3.NewStringSchema())
+	params.Required = []string{"query"}
+	return params
+}
+
+func TestWithRequiredThinkAugmentsSchemas(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{Agent: config.AgentConfig{RequireThink: true}}
+	search := &schemaTool{name: "search", params: newSearchSchema()}
+	thinking := &schemaTool{name: "sequentialthinking", params: openapi3.NewObjectSchema().WithProperty("thought", openapi3.NewStringSchema())}
+	noParams := &schemaTool{name: "now"}
+
+	tools := withRequiredThink(ctx, cfg, []tool.BaseTool{search, thinking, noParams})
+	require.Len(t, tools, 3)
+	// 已经定义thought的工具保持不变
+	assert.Same(t, thinking, tools[1])
+
+	info, err := tools[0].Info(ctx)
+	require.NoError(t, err)
+	params, err := info.ParamsOneOf.ToOpenAPIV3()
+	require.NoError(t, err)
+	require.Contains(t, params.Properties, "think")
+	assert.Equal(t, "说明调用原因", params.Properties["think"].Value.Description)
+	assert.Contains(t, params.Properties, "query")
+	assert.ElementsMatch(t, []string{"think", "query"}, params.Required)
+	// 原始工具的Schema未被修改
+	assert.NotContains(t, search.params.Properties, "think")
+	assert.Equal(t, []string{"query"}, search.params.Required)
+
+	info, err = tools[2].Info(ctx)
+	require.NoError(t, err)
+	params, err = info.ParamsOneOf.ToOpenAPIV3()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"think"}, params.Required)
+
+	// 未开启时不包装工具
+	assert.Same(t, search, withRequiredThink(ctx, &config.Config{}, []tool.BaseTool{search})[0])
+}
+
+func TestStripThink(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{name: "去除think", args: `{"think":"需要搜索","query":"mcp","limit":10000000000000000001}`, want: `{"limit":10000000000000000001,"query":"mcp"}`},
+		{name: "没有think", args: `{"query":"mcp"}`, want: `{"query":"mcp"}`},
+		{name: "空参数", args: "", want: ""},
+		{name: "无效JSON", args: `{"think":`, want: `{"think":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stripThink(tt.args))
+		})
+	}
+}
+
+func TestRequireThinkNotifiesThinkingAndStripsArguments(t *testing.T) {
+	ctx := context.Background()
+	search := &schemaTool{name: "search", params: newSearchSchema()}
+
+	toolCallMsg := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_1", Type: "function", Function: schema.FunctionCall{Name: "search", Arguments: `{"think":"先搜索资料","query":"mcp"}`}},
+	})
+	mockModel := new(MockToolCallingChatModel)
+	mockModel.On("WithTools", mock.Anything).Return(mockModel, nil)
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(toolCallMsg, nil).Once()
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(schema.AssistantMessage("done", nil), nil)
+
+	cfg := &config.Config{SystemPrompt: "test prompt", MaxStep: 5, Agent: config.AgentConfig{RequireThink: true}}
+	ragent, err := createReActAgent(ctx, cfg, []tool.BaseTool{search}, mockModel)
+	require.NoError(t, err)
+
+	// 大模型看到的工具包含think参数
+	boundTools := mockModel.Calls[0].Arguments.Get(0).([]*schema.ToolInfo)
+	params, err := boundTools[0].ParamsOneOf.ToOpenAPIV3()
+	require.NoError(t, err)
+	assert.Contains(t, params.Required, "think")
+
+	notify := &timelineRecordingNotify{}
+	require.NoError(t, executeAgentTask(ctx, cfg, ragent, "test task", notify))
+
+	require.NotEmpty(t, notify.events)
+	assert.Equal(t, "thinking", notify.events[0].kind)
+	assert.Equal(t, "先搜索资料", notify.events[0].content)
+	assert.Equal(t, []string{`{"query":"mcp"}`}, search.received)
+}
+
+// TestRequireThinkWithFakeServer 确认MCP服务器收不到注入的think参数，-short时跳过
+func TestRequireThinkWithFakeServer(t *testing.T) {
+	binary := testmcp.Build(t)
+	ctx := context.Background()
+	hub, err := mcphost.NewMCPHubFromSettings(ctx, testmcp.Settings("fake-think", binary, testmcp.Options{}))
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	tools, err := hub.GetEinoTools(ctx, []string{"fake-think_echo"})
+	require.NoError(t, err)
+	cfg := &config.Config{Agent: config.AgentConfig{RequireThink: true}}
+	wrapped := withRequiredThink(ctx, cfg, tools)
+
+	result, err := wrapped[0].(tool.InvokableTool).InvokableRun(ctx, `{"think":"回显消息","message":"你好"}`)
+	require.NoError(t, err)
+	// echo工具原样返回收到的参数
+	assert.JSONEq(t, `{"message":"你好"}`, result)
+}
@@ -0,0 +1,248 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxToolSuggestions is the number of tools suggested by ToolsRequiredError
+const maxToolSuggestions = 5
+
+// toolNeededAnswers are the lower case starts of classifier answers meaning
+// the task needs external information
+var toolNeededAnswers = []string{"yes", "是", "需要"}
+
+// toolKeywordStopWords are English words of tasks that match too many tool descriptions
+var toolKeywordStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "what": true, "about": true,
+	"from": true, "that": true, "this": true, "please": true, "all": true,
+}
+
+// ToolSuggestion is a tool that can be selected for a task that needs tools
+type ToolSuggestion struct {
+	Server       string            `json:"server"`                 // 服务器名称
+	Name         string            `json:"name"`                   // 工具名称
+	Description  string            `json:"description,omitempty"`  // 工具描述
+	Descriptions map[string]string `json:"descriptions,omitempty"` // 按语言的工具描述，自动选择工具时也会匹配
+}
+
+// String returns the tool in the server:tool format of -mcp-tools
+func (s ToolSuggestion) String() string {
+	return config.MissingTool{Server: s.Server, Name: s.Name}.String()
+}
+
+// ToolsRequiredError is returned when agent.require_tools rejects a task
+// that has no tools. Suggestions lists the known tools whose descriptions
+// match the task, best match first.
+type ToolsRequiredError struct {
+	Mode        string // config.RequireToolsAuto 或 config.RequireToolsAlways
+	Suggestions []ToolSuggestion
+}
+
+// Error tells the user to select tools and lists the suggestions
+func (e *ToolsRequiredError) Error() string {
+	message := "任务需要使用工具收集外部信息，但没有配置任何工具，请选择要使用的工具后重试"
+	if e.Mode == config.RequireToolsAlways {
+		message = "agent.require_tools为always，没有配置任何工具时不执行任务，请选择要使用的工具后重试"
+	}
+	if len(e.Suggestions) == 0 {
+		return message
+	}
+	names := make([]string, len(e.Suggestions))
+	for i, suggestion := range e.Suggestions {
+		names[i] = suggestion.String()
+	}
+	return message + "。可能有用的工具: " + strings.Join(names, ", ")
+}
+
+// ToolCatalog returns the tools that can be selected for tasks, such as the
+// tools cached in the database of the web server
+type ToolCatalog func(ctx context.Context) ([]ToolSuggestion, error)
+
+// toolCatalogKey is the context key of the ToolCatalog of a task
+type toolCatalogKey struct{}
+
+// WithToolCatalog returns a context in which the suggestions of
+// ToolsRequiredError are taken from catalog. Without a catalog the internal
+// tools are suggested.
+func WithToolCatalog(ctx context.Context, catalog ToolCatalog) context.Context {
+	return context.WithValue(ctx, toolCatalogKey{}, catalog)
+}
+
+// checkToolsRequired applies agent.require_tools to a task without tools.
+// In auto mode the model is asked whether the task needs external
+// information; a failed classification is reported as a warning and does
+// not stop the task.
+//
+// Returns:
+//   - error: *ToolsRequiredError if the task must not run without tools
+func checkToolsRequired(ctx context.Context, cfg *config.Config, chatModel model.BaseChatModel, tools []tool.BaseTool, task string, notify Notify) error {
+	if len(tools) > 0 {
+		return nil
+	}
+	mode := cfg.Agent.EffectiveRequireTools()
+	switch mode {
+	case config.RequireToolsAlways:
+	case config.RequireToolsAuto:
+		needed, err := classifyToolNeed(ctx, cfg, chatModel, task)
+		if err != nil {
+			log.Printf("判断任务是否需要工具失败: %v", err)
+			messages := messagesOf(cfg)
+			notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.RequireToolsFailed, err))
+			return nil
+		}
+		if !needed {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return &ToolsRequiredError{Mode: mode, Suggestions: suggestTools(ctx, cfg, task)}
+}
+
+// classifyToolNeed asks the model without tools whether task needs external information
+func classifyToolNeed(ctx context.Context, cfg *config.Config, chatModel model.BaseChatModel, task string) (bool, error) {
+	output, err := chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(messagesOf(cfg).RequireToolsPrompt),
+		schema.UserMessage(task),
+	})
+	if err != nil {
+		return false, err
+	}
+	answer := strings.ToLower(strings.TrimSpace(stripCodeFence(output.Content)))
+	for _, needed := range toolNeededAnswers {
+		if strings.HasPrefix(answer, needed) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// suggestTools returns the tools of the ToolCatalog of ctx, or the internal
+// tools without a catalog, whose names or descriptions share the most
+// keywords with task
+func suggestTools(ctx context.Context, cfg *config.Config, task string) []ToolSuggestion {
+	candidates, err := toolCandidates(ctx, cfg)
+	if err != nil {
+		log.Printf("获取可推荐的工具失败: %v", err)
+		return nil
+	}
+	keywords := taskKeywords(task)
+
+	type scored struct {
+		suggestion ToolSuggestion
+		score      int
+	}
+	var matches []scored
+	for _, candidate := range candidates {
+		text := strings.ToLower(candidate.Name + " " + candidate.Description)
+		score := 0
+		for _, keyword := range keywords {
+			if strings.Contains(text, keyword) {
+				score++
+			}
+		}
+		if score > 0 {
+			matches = append(matches, scored{suggestion: candidate, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].suggestion.String() < matches[j].suggestion.String()
+	})
+	if len(matches) > maxToolSuggestions {
+		matches = matches[:maxToolSuggestions]
+	}
+	suggestions := make([]ToolSuggestion, len(matches))
+	for i, match := range matches {
+		suggestions[i] = match.suggestion
+	}
+	return suggestions
+}
+
+// toolCandidates returns the tools of the ToolCatalog of ctx, the internal tools without one
+func toolCandidates(ctx context.Context, cfg *config.Config) ([]ToolSuggestion, error) {
+	if catalog, ok := ctx.Value(toolCatalogKey{}).(ToolCatalog); ok && catalog != nil {
+		return catalog(ctx)
+	}
+
+	// 推荐只需要工具信息，不需要第三方服务凭据
+	internalTools, err := config.GetInternalTools(ctx, "", config.IntegrationsConfig{})
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]ToolSuggestion, 0, len(internalTools))
+	for _, t := range internalTools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, ToolSuggestion{Server: config.InnerServerName, Name: info.Name, Description: info.Desc})
+	}
+	return candidates, nil
+}
+
+// taskKeywords splits task into lower case keywords: English words of at
+// least three letters and the character pairs of Chinese text
+func taskKeywords(task string) []string {
+	seen := make(map[string]bool)
+	var keywords []string
+	for _, term := range textTerms(task) {
+		if !seen[term] {
+			seen[term] = true
+			keywords = append(keywords, term)
+		}
+	}
+	return keywords
+}
+
+// textTerms splits text into lower case terms like taskKeywords, a term
+// occurring several times is returned every time
+func textTerms(text string) []string {
+	var terms []string
+	add := func(term string) {
+		if !toolKeywordStopWords[term] {
+			terms = append(terms, term)
+		}
+	}
+
+	var word, han []rune
+	flush := func() {
+		if len(word) >= 3 {
+			add(string(word))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			add(string(han[i : i+2]))
+		}
+		word, han = word[:0], han[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if len(word) > 0 {
+				flush()
+			}
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(han) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
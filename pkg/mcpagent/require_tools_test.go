@@ -0,0 +1,113 @@
+package mcpagent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testToolCatalog 模拟数据库中缓存的工具
+func testToolCatalog(ctx context.Context) ([]ToolSuggestion, error) {
+	return []ToolSuggestion{
+		{Server: "fofa", Name: "fofa_search", Description: "使用FOFA搜索网络空间资产，查询域名和IP"},
+		{Server: "web", Name: "url_markdown", Description: "Fetch a web page and convert it to markdown"},
+		{Server: "calc", Name: "add", Description: "两个数字相加"},
+	}, nil
+}
+
+// runRequireToolsTask 以mode执行任务，返回任务的输出和错误
+func runRequireToolsTask(t *testing.T, mode string, tools []tool.BaseTool, task string, chatModel *stepRecordingModel) (string, error) {
+	t.Helper()
+	ctx := WithToolCatalog(context.Background(), testToolCatalog)
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{MaxStep: 5, SystemPrompt: "test prompt", Agent: config.AgentConfig{RequireTools: mode}}
+	mockConfig.On("GetTools", mock.Anything).Return(tools, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	var out bytes.Buffer
+	notifier := NewFormattedCliNotifier(OutputPlain, &out, &out)
+	err = agent.Execute(ctx, task, notifier)
+	return out.String(), err
+}
+
+func TestRequireToolsAuto(t *testing.T) {
+	// 模型判断需要外部信息时任务失败并推荐工具
+	chatModel := &stepRecordingModel{replies: []*schema.Message{schema.AssistantMessage("yes", nil)}}
+	_, err := runRequireToolsTask(t, config.RequireToolsAuto, []tool.BaseTool{}, "用FOFA搜索example.com的资产", chatModel)
+	var required *ToolsRequiredError
+	require.True(t, errors.As(err, &required), "%v", err)
+	assert.Equal(t, config.RequireToolsAuto, required.Mode)
+	require.NotEmpty(t, required.Suggestions)
+	assert.Equal(t, "fofa:fofa_search", required.Suggestions[0].String())
+	assert.Contains(t, err.Error(), "请选择要使用的工具后重试。可能有用的工具: fofa:fofa_search")
+	assert.Equal(t, ErrorCodeToolsRequired, ErrorCode(err))
+	require.Len(t, chatModel.requests, 1)
+	assert.Equal(t, messagesOf(nil).RequireToolsPrompt, chatModel.requests[0].input[0].Content)
+	assert.Equal(t, "用FOFA搜索example.com的资产", chatModel.requests[0].input[1].Content)
+
+	// 不需要外部信息时正常执行
+	chatModel = &stepRecordingModel{replies: []*schema.Message{
+		schema.AssistantMessage("No", nil),
+		schema.AssistantMessage("完成", nil),
+	}}
+	output, err := runRequireToolsTask(t, config.RequireToolsAuto, []tool.BaseTool{}, "解释什么是TCP三次握手", chatModel)
+	require.NoError(t, err)
+	assert.Contains(t, output, "结果: 完成")
+	assert.Len(t, chatModel.requests, 2)
+
+	// 配置了工具时不判断
+	chatModel = &stepRecordingModel{replies: []*schema.Message{schema.AssistantMessage("完成", nil)}}
+	_, err = runRequireToolsTask(t, config.RequireToolsAuto, []tool.BaseTool{&echoTool{}}, "用FOFA搜索example.com的资产", chatModel)
+	require.NoError(t, err)
+	assert.Len(t, chatModel.requests, 1)
+}
+
+func TestRequireToolsAlways(t *testing.T) {
+	// 没有工具时不请求模型直接失败
+	chatModel := &stepRecordingModel{replies: []*schema.Message{schema.AssistantMessage("完成", nil)}}
+	_, err := runRequireToolsTask(t, config.RequireToolsAlways, []tool.BaseTool{}, "fetch the web page of example.com", chatModel)
+	var required *ToolsRequiredError
+	require.True(t, errors.As(err, &required), "%v", err)
+	assert.Equal(t, config.RequireToolsAlways, required.Mode)
+	assert.Contains(t, err.Error(), "agent.require_tools为always")
+	require.Len(t, required.Suggestions, 1)
+	assert.Equal(t, "web:url_markdown", required.Suggestions[0].String())
+	assert.Empty(t, chatModel.requests)
+
+	// 有工具时正常执行
+	chatModel = &stepRecordingModel{replies: []*schema.Message{schema.AssistantMessage("完成", nil)}}
+	_, err = runRequireToolsTask(t, config.RequireToolsAlways, []tool.BaseTool{&echoTool{}}, "fetch the web page of example.com", chatModel)
+	require.NoError(t, err)
+}
+
+func TestRequireToolsNever(t *testing.T) {
+	// 默认不检查，也不发送判断请求
+	for _, mode := range []string{"", config.RequireToolsNever} {
+		chatModel := &stepRecordingModel{replies: []*schema.Message{schema.AssistantMessage("完成", nil)}}
+		output, err := runRequireToolsTask(t, mode, []tool.BaseTool{}, "用FOFA搜索example.com的资产", chatModel)
+		require.NoError(t, err)
+		assert.Contains(t, output, "结果: 完成")
+		assert.Len(t, chatModel.requests, 1)
+	}
+}
+
+func TestTaskKeywords(t *testing.T) {
+	assert.Equal(t, []string{"搜索", "索网", "网页", "example", "com"}, taskKeywords("搜索网页 example.com the x"))
+
+	// 没有工具目录时推荐内置工具
+	suggestions := suggestTools(context.Background(), &config.Config{}, "sequential thinking")
+	require.NotEmpty(t, suggestions)
+	assert.Equal(t, config.InnerServerName, suggestions[0].Server)
+}
@@ -0,0 +1,23 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/resultfilter"
+)
+
+// withResultFilterMessages returns a context whose tools send a message to
+// notify when their result_filter could not be applied and the raw result is used
+func withResultFilterMessages(ctx context.Context, cfg *config.Config, notify Notify) context.Context {
+	messages := messagesOf(cfg)
+	return resultfilter.WithHandler(ctx, func(toolName string, err error) {
+		if errors.Is(err, resultfilter.ErrNotJSON) {
+			notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.ResultFilterSkipped, toolName))
+			return
+		}
+		notify.OnMessage(fmt.Sprintf(messages.ResultFilterFailed, toolName, err))
+	})
+}
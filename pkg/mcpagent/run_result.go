@@ -0,0 +1,166 @@
+package mcpagent
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+)
+
+// RunResult is the outcome of a task run by RunWithResult
+type RunResult struct {
+	Result       string           `json:"result"`
+	Steps        int              `json:"steps"` // 大模型的回答次数，每轮工具调用和最终回答各算一次
+	ToolCalls    []ToolCallRecord `json:"tool_calls"`
+	Verification *Verification    `json:"verification,omitempty"` // agent.verify_result对最终回答的校验结果
+}
+
+// ToolCallRecord is a tool call of a RunResult
+type ToolCallRecord struct {
+	ID           string          `json:"id,omitempty"`
+	Name         string          `json:"name"`
+	Arguments    json.RawMessage `json:"arguments"`
+	ResultLength int             `json:"result_length"`
+	DurationMs   int64           `json:"duration_ms,omitempty"` // 工具调用的耗时（毫秒）
+	Error        string          `json:"error,omitempty"`
+}
+
+// RunWithResult executes task like Run and also returns what the task did.
+// The result is returned when the task fails as well, holding the steps and
+// tool calls made before the failure.
+//
+// Parameters:
+//   - ctx: Context for controlling execution flow and cancellation
+//   - cfg: Configuration containing model, tool, and system settings
+//   - task: Task description to execute (must not be empty)
+//   - notify: Notification handler for progress updates and results
+//
+// Returns:
+//   - *RunResult: Answer, steps, tool calls and verification of the task, never nil
+//   - error: Error if execution fails at any stage
+//
+// Example:
+//
+//	result, err := mcpagent.RunWithResult(ctx, cfg, "分析这个网站的安全性", mcpagent.NewCliNotifier())
+//	if err == nil {
+//		log.Printf("%d步，%d次工具调用", result.Steps, len(result.ToolCalls))
+//	}
+func RunWithResult(ctx context.Context, cfg *config.Config, task string, notify Notify) (*RunResult, error) {
+	recorder := &runRecorder{}
+	if err := validateRunParameters(cfg, task, notify); err != nil {
+		return recorder.runResult(), err
+	}
+	err := Run(ctx, cfg, task, NewMultiNotify(notify, recorder))
+	return recorder.runResult(), err
+}
+
+// ExecuteWithResult runs a task like Execute and also returns what the task
+// did, see RunWithResult.
+//
+// Parameters:
+//   - ctx: Context for controlling execution flow and cancellation
+//   - task: Task description to execute (must not be empty)
+//   - notify: Notification handler for progress updates and results
+//
+// Returns:
+//   - *RunResult: Answer, steps, tool calls and verification of the task, never nil
+//   - error: ErrAgentClosed after Close, or an error if execution fails
+func (a *Agent) ExecuteWithResult(ctx context.Context, task string, notify Notify) (*RunResult, error) {
+	recorder := &runRecorder{}
+	if err := validateRunParameters(a.cfg, task, notify); err != nil {
+		return recorder.runResult(), err
+	}
+	err := a.Execute(ctx, task, NewMultiNotify(notify, recorder))
+	return recorder.runResult(), err
+}
+
+// runRecorder builds the RunResult of a task from its notifications
+type runRecorder struct {
+	mu      sync.Mutex
+	result  RunResult
+	inRound bool // 当前这轮工具调用是否已计入步骤
+}
+
+// runResult returns a copy of the recorded result
+func (r *runRecorder) runResult() *RunResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := r.result
+	result.ToolCalls = append([]ToolCallRecord{}, r.result.ToolCalls...)
+	return &result
+}
+
+// OnMessage ignores messages, they are not part of the result
+func (r *runRecorder) OnMessage(msg string) {}
+
+// OnThinking ignores the thinking of the model
+func (r *runRecorder) OnThinking(msg string) {}
+
+// OnThinkingWithCall ignores the thinking of the model
+func (r *runRecorder) OnThinkingWithCall(msg string, relatedCallID string) {}
+
+// OnToolCall records a tool call without ID
+func (r *runRecorder) OnToolCall(toolName string, params any) {
+	r.OnToolCallWithID("", toolName, params)
+}
+
+// OnToolCallWithID records a tool call, counting a step for the first call
+// after the results of the previous round
+func (r *runRecorder) OnToolCallWithID(callID string, toolName string, params any) {
+	arguments := json.RawMessage(FormatArguments(params))
+	if !json.Valid(arguments) {
+		arguments, _ = json.Marshal(string(arguments))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.inRound {
+		r.inRound = true
+		r.result.Steps++
+	}
+	r.result.ToolCalls = append(r.result.ToolCalls, ToolCallRecord{ID: callID, Name: toolName, Arguments: arguments})
+}
+
+// OnToolCallResult records the result of a tool call without ID
+func (r *runRecorder) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	r.OnToolResult("", toolName, result, err, duration)
+}
+
+// OnToolResult records the result of the tool call callID
+func (r *runRecorder) OnToolResult(callID string, toolName string, result string, err error, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inRound = false
+	for i := len(r.result.ToolCalls) - 1; i >= 0; i-- {
+		call := &r.result.ToolCalls[i]
+		if call.ID == callID && call.Name == toolName {
+			call.ResultLength = len(result)
+			call.DurationMs = duration.Milliseconds()
+			if err != nil {
+				call.Error = err.Error()
+			}
+			return
+		}
+	}
+}
+
+// OnVerification records the verdict on the result
+func (r *runRecorder) OnVerification(verification Verification) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Verification = &verification
+}
+
+// OnResult records the final answer as the last step
+func (r *runRecorder) OnResult(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Result = msg
+	r.result.Steps++
+	r.inRound = false
+}
+
+// OnError ignores the error, RunWithResult returns it
+func (r *runRecorder) OnError(err error) {}
@@ -0,0 +1,83 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithResult(t *testing.T) {
+	server, _ := newAnswerModelServer(t, "你好，我是助手")
+	cfg := &config.Config{
+		MCP: config.MCPConfig{ConfigFile: "non_existent_file.json"},
+		LLM: config.LLMConfig{
+			Type:    config.LLMProviderOpenAI,
+			BaseURL: server.URL,
+			Model:   "test-model",
+			APIKey:  "test-key",
+		},
+		SystemPrompt: "test prompt",
+		MaxStep:      10,
+	}
+	require.NoError(t, cfg.Validate())
+
+	notify := newResultNotify()
+	result, err := RunWithResult(context.Background(), cfg, "你好", notify)
+	require.NoError(t, err)
+	assert.Equal(t, &RunResult{Result: "你好，我是助手", Steps: 1, ToolCalls: []ToolCallRecord{}}, result)
+	notify.AssertCalled(t, "OnResult", "你好，我是助手")
+
+	// 参数无效时同样返回结果
+	result, err = RunWithResult(context.Background(), cfg, " ", notify)
+	require.Error(t, err)
+	require.NotNil(t, result)
+	assert.Zero(t, result.Steps)
+}
+
+func TestExecuteWithResultVerified(t *testing.T) {
+	var result *RunResult
+	runVerifiedTaskWith(t, `{"supported": ["开放了80端口"], "unsupported": ["运行nginx"]}`, nil, func(agent *Agent) {
+		var err error
+		result, err = agent.ExecuteWithResult(context.Background(), "检查example.com", newResultNotify())
+		require.NoError(t, err)
+	})
+
+	// 一轮工具调用和最终回答各算一步
+	assert.Equal(t, "example.com 开放了80端口，运行nginx", result.Result)
+	assert.Equal(t, 2, result.Steps)
+	require.Len(t, result.ToolCalls, 1)
+	assert.Equal(t, "call_1", result.ToolCalls[0].ID)
+	assert.Equal(t, "echo", result.ToolCalls[0].Name)
+	assert.JSONEq(t, `{"host":"example.com"}`, string(result.ToolCalls[0].Arguments))
+	assert.Equal(t, len(`echo:{"host":"example.com"}`), result.ToolCalls[0].ResultLength)
+	require.NotNil(t, result.Verification)
+	assert.Equal(t, []string{"运行nginx"}, result.Verification.Unsupported)
+}
+
+func TestRunRecorder(t *testing.T) {
+	recorder := &runRecorder{}
+	recorder.OnToolCallWithID("call_1", "search", map[string]any{"query": "mcp"})
+	recorder.OnToolCallWithID("call_2", "search", "不是JSON")
+	recorder.OnToolResult("call_1", "search", "结果", nil, 1500*time.Millisecond)
+	recorder.OnToolResult("call_2", "search", "", errors.New("超时"), 0)
+	recorder.OnToolCall("fetch_url", map[string]any{"url": "https://example.com"})
+	recorder.OnResult("完成")
+
+	result := recorder.runResult()
+	assert.Equal(t, 3, result.Steps, "两轮工具调用和最终回答")
+	require.Len(t, result.ToolCalls, 3)
+	assert.Equal(t, ToolCallRecord{ID: "call_1", Name: "search", Arguments: []byte(`{"query":"mcp"}`), ResultLength: len("结果"), DurationMs: 1500}, result.ToolCalls[0])
+	assert.Equal(t, `"不是JSON"`, string(result.ToolCalls[1].Arguments))
+	assert.Equal(t, "超时", result.ToolCalls[1].Error)
+	assert.Equal(t, "fetch_url", result.ToolCalls[2].Name)
+	assert.Nil(t, result.Verification)
+
+	// 返回的是副本
+	result.ToolCalls[0].Name = "changed"
+	assert.Equal(t, "search", recorder.runResult().ToolCalls[0].Name)
+}
@@ -0,0 +1,132 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+const (
+	// toolOutputBegin starts a tool result marked by security.sanitize_tool_output,
+	// formatted with the tool name
+	toolOutputBegin = "<<<TOOL_OUTPUT tool=%q>>>\n"
+	// toolOutputEnd ends a marked tool result
+	toolOutputEnd = "\n<<<END_TOOL_OUTPUT>>>"
+	// toolOutputDelimiter starts both delimiters, tool results containing it
+	// are changed so that they cannot close the marked block themselves
+	toolOutputDelimiter = "<<<"
+)
+
+// sanitizeHandler receives the number of injection matches removed from a tool result
+type sanitizeHandler func(toolName string, removed int)
+
+// sanitizeHandlerKey is the context key of the sanitizeHandler of a task
+type sanitizeHandlerKey struct{}
+
+// withSanitizerMessages returns a context whose sanitized tools send a
+// warning to notify when they remove text from a tool result
+func withSanitizerMessages(ctx context.Context, cfg *config.Config, notify Notify) context.Context {
+	messages := messagesOf(cfg)
+	return context.WithValue(ctx, sanitizeHandlerKey{}, sanitizeHandler(func(toolName string, removed int) {
+		notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.ToolOutputSanitized, toolName, removed))
+	}))
+}
+
+// sanitizingTool marks the results of the wrapped tool as untrusted data and,
+// in strip mode, removes the text matching the injection patterns first
+type sanitizingTool struct {
+	tool.InvokableTool
+	patterns    []*regexp.Regexp // 为空时只标记不移除
+	replacement string
+}
+
+// withSanitizedResults wraps the invokable tools with sanitizingTool when cfg
+// enables security.sanitize_tool_output. It applies to MCP and internal
+// tools alike. Patterns that do not compile are reported by validation, the
+// tools are then only marked.
+func withSanitizedResults(cfg *config.Config, tools []tool.BaseTool) []tool.BaseTool {
+	if cfg == nil || !cfg.Security.MarksToolOutput() {
+		return tools
+	}
+
+	var patterns []*regexp.Regexp
+	if cfg.Security.StripsToolOutput() {
+		compiled, err := cfg.Security.CompileInjectionPatterns()
+		if err != nil {
+			log.Printf("提示词注入规则无效，只标记工具结果: %v", err)
+		}
+		patterns = compiled
+	}
+
+	replacement := messagesOf(cfg).InjectionRemoved
+	wrapped := make([]tool.BaseTool, 0, len(tools))
+	for _, t := range tools {
+		if invokable, ok := t.(tool.InvokableTool); ok {
+			t = &sanitizingTool{InvokableTool: invokable, patterns: patterns, replacement: replacement}
+		}
+		wrapped = append(wrapped, t)
+	}
+	return wrapped
+}
+
+// InvokableRun runs the wrapped tool and sanitizes its result. Errors are
+// returned unchanged, eino passes them to the model as they are.
+func (t *sanitizingTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	result, err := t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	if err != nil {
+		return result, err
+	}
+
+	toolName := "unknown"
+	if info, infoErr := t.Info(ctx); infoErr == nil && info != nil {
+		toolName = info.Name
+	}
+
+	stripped, removed := stripInjections(result, t.patterns, t.replacement)
+	if len(removed) > 0 {
+		for _, text := range removed {
+			log.Printf("工具 %s 的结果中移除了疑似提示词注入的内容: %q", toolName, text)
+		}
+		if handler, ok := ctx.Value(sanitizeHandlerKey{}).(sanitizeHandler); ok && handler != nil {
+			handler(toolName, len(removed))
+		}
+	}
+	return markToolOutput(toolName, stripped), nil
+}
+
+// stripInjections replaces the text matching patterns in result
+//
+// Returns:
+//   - string: result with every match replaced
+//   - []string: Removed texts in the order of the patterns
+func stripInjections(result string, patterns []*regexp.Regexp, replacement string) (string, []string) {
+	var removed []string
+	for _, pattern := range patterns {
+		result = pattern.ReplaceAllStringFunc(result, func(match string) string {
+			removed = append(removed, match)
+			return replacement
+		})
+	}
+	return result, removed
+}
+
+// markToolOutput wraps result in the delimiters explained by UntrustedToolOutput
+func markToolOutput(toolName string, result string) string {
+	// 结果中的分隔符会提前结束标记块，插入空格使其失效
+	result = strings.ReplaceAll(result, toolOutputDelimiter, "<< <")
+	return fmt.Sprintf(toolOutputBegin, toolName) + result + toolOutputEnd
+}
+
+// untrustedOutputReminder returns the text appended to the system prompt when
+// tool results are marked, empty when they are not
+func untrustedOutputReminder(cfg *config.Config) string {
+	if cfg == nil || !cfg.Security.MarksToolOutput() {
+		return ""
+	}
+	return messagesOf(cfg).UntrustedToolOutput
+}
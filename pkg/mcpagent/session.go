@@ -0,0 +1,88 @@
+package mcpagent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Session is a multi-turn conversation with an agent. Every Ask sends the
+// questions and answers of the earlier turns to the model, so later
+// questions can refer to them. The system prompt is formatted once when the
+// session starts, placeholders such as {date} keep their value for the
+// whole session.
+//
+// A Session is safe for concurrent use, turns run one at a time. Closing the
+// agent ends the session.
+type Session struct {
+	agent  *Agent
+	system []*schema.Message // 会话开始时格式化的系统消息
+
+	mu      sync.Mutex
+	history []*schema.Message
+}
+
+// NewSession starts a conversation with the agent, continuing history.
+//
+// Parameters:
+//   - ctx: Context for formatting the system prompt
+//   - history: Earlier messages, oldest first, may be empty, see RunWithHistory
+//
+// Returns:
+//   - *Session: The conversation
+//   - error: Error if the history is invalid or the system prompt cannot be formatted
+func (a *Agent) NewSession(ctx context.Context, history []*schema.Message) (*Session, error) {
+	if err := validateHistory(history); err != nil {
+		return nil, err
+	}
+	system, err := formatSystemMessages(ctx, a.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		agent:   a,
+		system:  system,
+		history: append([]*schema.Message(nil), history...),
+	}, nil
+}
+
+// Ask answers question as the next turn of the conversation. The question
+// and the answer are added to the history once the turn succeeds, a failed
+// turn leaves the history unchanged. cfg.TaskTimeout applies to each turn.
+//
+// Parameters:
+//   - ctx: Context for controlling execution flow and cancellation
+//   - question: The new user message (must not be empty)
+//   - notify: Notification handler for progress updates and results
+//
+// Returns:
+//   - *schema.Message: Assistant message with the answer
+//   - error: ErrAgentClosed after the agent is closed, or an error if execution fails
+func (s *Session) Ask(ctx context.Context, question string, notify Notify) (*schema.Message, error) {
+	if err := validateRunParameters(s.agent.cfg, question, notify); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var answer *schema.Message
+	err := runWithTaskTimeout(ctx, s.agent.cfg, notify, func(ctx context.Context) error {
+		var err error
+		answer, err = s.agent.executeConversation(ctx, s.system, s.history, question, notify)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.history = append(s.history, schema.UserMessage(question), answer)
+	return answer, nil
+}
+
+// History returns a copy of the messages of the conversation, oldest first
+func (s *Session) History() []*schema.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*schema.Message(nil), s.history...)
+}
@@ -0,0 +1,76 @@
+package mcpagent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionAsk(t *testing.T) {
+	ctx := context.Background()
+	chatModel := &scriptedChatModel{replies: []string{"两个IP都开放了80端口", "第二个IP还开放了22端口"}}
+	mockConfig := newLifecycleMockConfig(chatModel, func() {})
+	mockConfig.Config.SystemPrompt = "你是{role}"
+	mockConfig.Config.PlaceHolders = map[string]any{"role": "安全分析师"}
+	agent, err := newAgent(ctx, &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	session, err := agent.NewSession(ctx, nil)
+	require.NoError(t, err)
+
+	notify := newResultNotify()
+	answer, err := session.Ask(ctx, "查询这两个IP", notify)
+	require.NoError(t, err)
+	assert.Equal(t, schema.Assistant, answer.Role)
+	assert.Equal(t, "两个IP都开放了80端口", answer.Content)
+
+	// 系统提示词只在会话开始时格式化一次
+	mockConfig.Config.PlaceHolders["role"] = "其他角色"
+	answer, err = session.Ask(ctx, "再分析第二个IP", notify)
+	require.NoError(t, err)
+	assert.Equal(t, "第二个IP还开放了22端口", answer.Content)
+
+	// 第二轮包含第一轮的问题和回答
+	require.Len(t, chatModel.inputs, 2)
+	input := chatModel.inputs[1]
+	require.Len(t, input, 4)
+	assert.Equal(t, "你是安全分析师", input[0].Content)
+	assert.Equal(t, "查询这两个IP", input[1].Content)
+	assert.Equal(t, "两个IP都开放了80端口", input[2].Content)
+	assert.Equal(t, "再分析第二个IP", input[3].Content)
+
+	history := session.History()
+	require.Len(t, history, 4)
+	assert.Equal(t, schema.User, history[2].Role)
+	assert.Equal(t, schema.Assistant, history[3].Role)
+	assert.Equal(t, "第二个IP还开放了22端口", history[3].Content)
+
+	// 关闭agent后会话结束，历史不变
+	require.NoError(t, agent.Close())
+	_, err = session.Ask(ctx, "继续", notify)
+	assert.ErrorIs(t, err, ErrAgentClosed)
+	assert.Len(t, session.History(), 4)
+}
+
+func TestNewSessionValidatesHistory(t *testing.T) {
+	agent := &Agent{cfg: &newLifecycleMockConfig(&scriptedChatModel{replies: []string{"ok"}}, func() {}).Config}
+	_, err := agent.NewSession(context.Background(), []*schema.Message{schema.SystemMessage("x")})
+	assert.ErrorContains(t, err, "历史消息[0]无效")
+}
+
+func TestRunConversationRequiresUserTurn(t *testing.T) {
+	cfg := &newLifecycleMockConfig(&scriptedChatModel{replies: []string{"ok"}}, func() {}).Config
+	notify := newResultNotify()
+
+	for _, messages := range [][]*schema.Message{
+		nil,
+		{schema.UserMessage("问题"), schema.AssistantMessage("回答", nil)},
+	} {
+		_, err := RunConversation(context.Background(), cfg, messages, notify)
+		assert.EqualError(t, err, errMsgConversationTurn)
+	}
+}
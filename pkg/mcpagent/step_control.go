@@ -0,0 +1,205 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// stepState tracks the steps of one task for agent.first_tool and
+// agent.allowed_tools_per_step. A step starts with every model request, the
+// tool calls of its answer belong to that step.
+type stepState struct {
+	mu    sync.Mutex
+	steps int             // 已发出的模型请求数
+	tools map[string]bool // 当前步骤已调用的不同工具
+}
+
+// nextStep starts a new step and reports whether it is the first one
+func (s *stepState) nextStep() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.steps++
+	s.tools = nil
+	return s.steps == 1
+}
+
+// admit reports whether toolName may run in the current step, adding it to
+// the tools of the step while fewer than limit distinct tools were called
+func (s *stepState) admit(toolName string, limit int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tools[toolName] {
+		return true
+	}
+	if len(s.tools) >= limit {
+		return false
+	}
+	if s.tools == nil {
+		s.tools = make(map[string]bool)
+	}
+	s.tools[toolName] = true
+	return true
+}
+
+// stepControlModel starts a step with every request. The first request asks
+// the model to call firstTool, and with forceToolChoice it also binds only
+// that tool and forces it with tool_choice.
+type stepControlModel struct {
+	model.ToolCallingChatModel
+	state           *stepState
+	firstTool       *schema.ToolInfo // 为nil时不限制第一步
+	instruction     string           // 第一次请求追加到系统提示词的说明
+	forceToolChoice bool
+}
+
+// withStepControl wraps chatModel with stepControlModel when cfg sets
+// agent.first_tool or agent.allowed_tools_per_step. tools are the tools as
+// presented to the model. An unknown first tool is logged and ignored, so
+// the task runs as without first_tool.
+func withStepControl(ctx context.Context, cfg *config.Config, chatModel model.ToolCallingChatModel, tools []tool.BaseTool, state *stepState) model.ToolCallingChatModel {
+	if cfg == nil || (cfg.Agent.FirstTool == "" && cfg.Agent.AllowedToolsPerStep <= 0) {
+		return chatModel
+	}
+
+	controlled := &stepControlModel{ToolCallingChatModel: chatModel, state: state}
+	if name := strings.TrimSpace(cfg.Agent.FirstTool); name != "" {
+		controlled.firstTool = findToolInfo(ctx, tools, name)
+		if controlled.firstTool == nil {
+			log.Printf("first_tool指定的工具 %s 不存在，不限制第一步调用的工具", name)
+		} else {
+			controlled.instruction = fmt.Sprintf(messagesOf(cfg).FirstToolInstruction, name)
+			controlled.forceToolChoice = supportsToolChoice(cfg)
+		}
+	}
+	return controlled
+}
+
+// supportsToolChoice reports whether the provider of cfg accepts the
+// tool_choice parameter. Ollama ignores it, so only the instruction is sent.
+func supportsToolChoice(cfg *config.Config) bool {
+	return cfg.LLM.Type == config.LLMProviderOpenAI
+}
+
+// findToolInfo returns the info of the tool named name, nil if there is none
+func findToolInfo(ctx context.Context, tools []tool.BaseTool, name string) *schema.ToolInfo {
+	for _, t := range tools {
+		info, err := t.Info(ctx)
+		if err == nil && info != nil && info.Name == name {
+			return info
+		}
+	}
+	return nil
+}
+
+// WithTools binds tools to the wrapped model, the step state is shared
+func (m *stepControlModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.ToolCallingChatModel.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	controlled := *m
+	controlled.ToolCallingChatModel = bound
+	return &controlled, nil
+}
+
+// firstRequest returns the input and options of the first request: the
+// instruction is appended to the system prompt, and with forceToolChoice
+// only the first tool is bound and forced
+func (m *stepControlModel) firstRequest(input []*schema.Message, opts []model.Option) ([]*schema.Message, []model.Option) {
+	input = append([]*schema.Message(nil), input...)
+	if len(input) > 0 && input[0].Role == schema.System {
+		system := *input[0]
+		system.Content += m.instruction
+		input[0] = &system
+	} else {
+		input = append([]*schema.Message{schema.SystemMessage(strings.TrimSpace(m.instruction))}, input...)
+	}
+
+	if !m.forceToolChoice {
+		return input, opts
+	}
+	forced := append(append([]model.Option(nil), opts...),
+		model.WithTools([]*schema.ToolInfo{m.firstTool}), model.WithToolChoice(schema.ToolChoiceForced))
+	return input, forced
+}
+
+// Generate starts a step and sends the request, see firstRequest
+func (m *stepControlModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	if !m.state.nextStep() || m.firstTool == nil {
+		return m.ToolCallingChatModel.Generate(ctx, input, opts...)
+	}
+
+	firstInput, firstOpts := m.firstRequest(input, opts)
+	output, err := m.ToolCallingChatModel.Generate(ctx, firstInput, firstOpts...)
+	if err != nil && m.forceToolChoice && ctx.Err() == nil {
+		// 部分兼容OpenAI接口的服务不支持tool_choice，只保留说明重试
+		log.Printf("强制调用工具 %s 的请求失败，不使用tool_choice重试: %v", m.firstTool.Name, err)
+		return m.ToolCallingChatModel.Generate(ctx, firstInput, opts...)
+	}
+	return output, err
+}
+
+// Stream starts a step and sends the request, see firstRequest
+func (m *stepControlModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	if !m.state.nextStep() || m.firstTool == nil {
+		return m.ToolCallingChatModel.Stream(ctx, input, opts...)
+	}
+
+	firstInput, firstOpts := m.firstRequest(input, opts)
+	output, err := m.ToolCallingChatModel.Stream(ctx, firstInput, firstOpts...)
+	if err != nil && m.forceToolChoice && ctx.Err() == nil {
+		log.Printf("强制调用工具 %s 的请求失败，不使用tool_choice重试: %v", m.firstTool.Name, err)
+		return m.ToolCallingChatModel.Stream(ctx, firstInput, opts...)
+	}
+	return output, err
+}
+
+// stepLimitedTool rejects calls of the wrapped tool when the current step
+// already called agent.allowed_tools_per_step other tools
+type stepLimitedTool struct {
+	tool.InvokableTool
+	state   *stepState
+	limit   int
+	message string // 拒绝调用时返回给模型的说明，格式化参数为工具名和上限
+}
+
+// withStepLimit wraps the invokable tools with stepLimitedTool when cfg
+// sets agent.allowed_tools_per_step
+func withStepLimit(cfg *config.Config, tools []tool.BaseTool, state *stepState) []tool.BaseTool {
+	if cfg == nil || cfg.Agent.AllowedToolsPerStep <= 0 {
+		return tools
+	}
+
+	message := messagesOf(cfg).ToolCallRejected
+	wrapped := make([]tool.BaseTool, 0, len(tools))
+	for _, t := range tools {
+		if invokable, ok := t.(tool.InvokableTool); ok {
+			t = &stepLimitedTool{InvokableTool: invokable, state: state, limit: cfg.Agent.AllowedToolsPerStep, message: message}
+		}
+		wrapped = append(wrapped, t)
+	}
+	return wrapped
+}
+
+// InvokableRun runs the wrapped tool if the step may call it. A rejected call
+// returns the rejection as its result, so the model can call the tool in the
+// next step instead.
+func (t *stepLimitedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	toolName := "unknown"
+	if info, err := t.Info(ctx); err == nil && info != nil {
+		toolName = info.Name
+	}
+	if !t.state.admit(toolName, t.limit) {
+		log.Printf("本步骤调用的工具已达上限（%d），拒绝调用工具 %s", t.limit, toolName)
+		return fmt.Sprintf(t.message, toolName, t.limit), nil
+	}
+	return t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+}
@@ -0,0 +1,162 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stepRequest 记录模型的一次请求
+type stepRequest struct {
+	input   []*schema.Message
+	options *model.Options
+}
+
+// stepRecordingModel 按顺序返回预设消息的模拟模型，记录每次请求的输入和选项
+type stepRecordingModel struct {
+	mu       sync.Mutex
+	replies  []*schema.Message
+	requests []stepRequest
+	// rejectToolChoice 模拟不支持tool_choice的服务
+	rejectToolChoice bool
+}
+
+func (m *stepRecordingModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	options := model.GetCommonOptions(nil, opts...)
+	m.requests = append(m.requests, stepRequest{input: input, options: options})
+	if m.rejectToolChoice && options.ToolChoice != nil {
+		return nil, errors.New("tool_choice is not supported")
+	}
+	reply := m.replies[0]
+	if len(m.replies) > 1 {
+		m.replies = m.replies[1:]
+	}
+	return reply, nil
+}
+
+func (m *stepRecordingModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *stepRecordingModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// upperTool 是第二个测试工具，记录调用次数
+type upperTool struct {
+	calls int
+}
+
+func (u *upperTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "upper", Desc: "upper case the input"}, nil
+}
+
+func (u *upperTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	u.calls++
+	return "UPPER", nil
+}
+
+func toolCallMessage(names ...string) *schema.Message {
+	calls := make([]schema.ToolCall, 0, len(names))
+	for i, name := range names {
+		calls = append(calls, schema.ToolCall{ID: "call_" + string(rune('a'+i)), Type: "function",
+			Function: schema.FunctionCall{Name: name, Arguments: "{}"}})
+	}
+	return schema.AssistantMessage("", calls)
+}
+
+func runStepControlledTask(t *testing.T, cfg *config.Config, chatModel *stepRecordingModel, tools ...tool.BaseTool) {
+	t.Helper()
+	ctx := context.Background()
+	ragent, err := createReActAgent(ctx, cfg, tools, chatModel)
+	require.NoError(t, err)
+	require.NoError(t, executeAgentTask(ctx, cfg, ragent, "检查example.com", newResultNotify()))
+}
+
+func TestFirstToolForcesToolChoice(t *testing.T) {
+	chatModel := &stepRecordingModel{replies: []*schema.Message{toolCallMessage("upper"), schema.AssistantMessage("完成", nil)}}
+	cfg := &config.Config{SystemPrompt: "test prompt", MaxStep: 5,
+		LLM: config.LLMConfig{Type: config.LLMProviderOpenAI}, Agent: config.AgentConfig{FirstTool: "upper"}}
+	runStepControlledTask(t, cfg, chatModel, &echoTool{}, &upperTool{})
+
+	// 第一次请求只绑定指定的工具并强制调用，说明追加到系统提示词
+	require.Len(t, chatModel.requests, 2)
+	first := chatModel.requests[0]
+	require.NotNil(t, first.options.ToolChoice)
+	assert.Equal(t, schema.ToolChoiceForced, *first.options.ToolChoice)
+	require.Len(t, first.options.Tools, 1)
+	assert.Equal(t, "upper", first.options.Tools[0].Name)
+	assert.Contains(t, first.input[0].Content, "第一步必须调用工具 upper")
+
+	// 之后的请求不再限制
+	second := chatModel.requests[1]
+	assert.Nil(t, second.options.ToolChoice)
+	assert.Nil(t, second.options.Tools)
+	assert.NotContains(t, second.input[0].Content, "第一步必须调用工具")
+}
+
+func TestFirstToolWithoutToolChoiceSupport(t *testing.T) {
+	// 服务拒绝tool_choice时只带说明重试
+	chatModel := &stepRecordingModel{rejectToolChoice: true,
+		replies: []*schema.Message{toolCallMessage("upper"), schema.AssistantMessage("完成", nil)}}
+	cfg := &config.Config{SystemPrompt: "test prompt", MaxStep: 5,
+		LLM: config.LLMConfig{Type: config.LLMProviderOpenAI}, Agent: config.AgentConfig{FirstTool: "upper"}}
+	runStepControlledTask(t, cfg, chatModel, &echoTool{}, &upperTool{})
+	require.Len(t, chatModel.requests, 3)
+	assert.Nil(t, chatModel.requests[1].options.ToolChoice)
+	assert.Contains(t, chatModel.requests[1].input[0].Content, "第一步必须调用工具 upper")
+
+	// Ollama不发送tool_choice
+	chatModel = &stepRecordingModel{replies: []*schema.Message{schema.AssistantMessage("完成", nil)}}
+	cfg.LLM.Type = config.LLMProviderOllama
+	runStepControlledTask(t, cfg, chatModel, &echoTool{}, &upperTool{})
+	require.Len(t, chatModel.requests, 1)
+	assert.Nil(t, chatModel.requests[0].options.ToolChoice)
+	assert.Contains(t, chatModel.requests[0].input[0].Content, "第一步必须调用工具 upper")
+
+	// 不存在的工具被忽略
+	chatModel = &stepRecordingModel{replies: []*schema.Message{schema.AssistantMessage("完成", nil)}}
+	cfg.Agent.FirstTool = "missing"
+	runStepControlledTask(t, cfg, chatModel, &echoTool{})
+	assert.NotContains(t, chatModel.requests[0].input[0].Content, "第一步必须调用工具")
+}
+
+func TestAllowedToolsPerStepRejectsExtraTools(t *testing.T) {
+	upper := &upperTool{}
+	chatModel := &stepRecordingModel{replies: []*schema.Message{
+		toolCallMessage("echo", "upper", "echo"),
+		toolCallMessage("upper"),
+		schema.AssistantMessage("完成", nil),
+	}}
+	cfg := &config.Config{SystemPrompt: "test prompt", MaxStep: 5, Agent: config.AgentConfig{AllowedToolsPerStep: 1}}
+	runStepControlledTask(t, cfg, chatModel, &echoTool{}, upper)
+
+	// 第一步中同一个工具可以调用多次，第二个不同的工具被拒绝
+	require.Len(t, chatModel.requests, 3)
+	results := make(map[string]string)
+	for _, msg := range chatModel.requests[1].input {
+		if msg.Role == schema.Tool {
+			results[msg.ToolCallID] = msg.Content
+		}
+	}
+	assert.Equal(t, "echo:{}", results["call_a"])
+	assert.Equal(t, "工具 upper 没有执行：每一步最多调用1个不同的工具。请根据已有结果在下一步再调用它。", results["call_b"])
+	assert.Equal(t, "echo:{}", results["call_c"])
+
+	// 下一步可以调用被拒绝的工具
+	assert.Equal(t, 1, upper.calls)
+}
@@ -0,0 +1,102 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/toolresult"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxSummarizeInput is the number of characters of a tool result sent to the summarizer
+const maxSummarizeInput = 32000
+
+// summarizingTool replaces long results of the wrapped tool by a summary
+// generated by the model. The full result is kept in store and can be read
+// with the expand_result tool.
+type summarizingTool struct {
+	tool.InvokableTool
+	cfg       *config.Config
+	chatModel model.BaseChatModel
+	store     *toolresult.Store
+}
+
+// withResultSummaries wraps tools so that results longer than the configured
+// threshold are summarized, and adds the expand_result tool. Every call creates
+// a new result store, so results are kept per task.
+// Tools are returned unchanged unless cfg enables summarize compression.
+//
+// Parameters:
+//   - cfg: Configuration selecting the compression and its threshold
+//   - tools: Tools available to the agent
+//   - chatModel: Model generating the summaries, tools are not bound
+//
+// Returns:
+//   - []tool.BaseTool: Wrapped tools followed by expand_result
+func withResultSummaries(cfg *config.Config, tools []tool.BaseTool, chatModel model.BaseChatModel) []tool.BaseTool {
+	if !cfg.SummarizesToolResults() || len(tools) == 0 {
+		return tools
+	}
+
+	store := toolresult.NewStore(0, 0)
+	wrapped := make([]tool.BaseTool, 0, len(tools)+1)
+	for _, t := range tools {
+		invokable, ok := t.(tool.InvokableTool)
+		if !ok {
+			wrapped = append(wrapped, t)
+			continue
+		}
+		wrapped = append(wrapped, &summarizingTool{InvokableTool: invokable, cfg: cfg, chatModel: chatModel, store: store})
+	}
+	return append(wrapped, toolresult.NewExpandTool(store))
+}
+
+// InvokableRun runs the wrapped tool and summarizes a long result
+func (t *summarizingTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	result, err := t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	threshold := t.cfg.EffectiveContextCompressionThreshold()
+	if err != nil || utf8.RuneCountInString(result) <= threshold {
+		return result, err
+	}
+
+	toolName := "unknown"
+	if info, infoErr := t.Info(ctx); infoErr == nil {
+		toolName = info.Name
+	}
+	stored := t.store.Save(toolName, result)
+
+	summary, err := summarizeToolResult(ctx, t.cfg, t.chatModel, toolName, result)
+	if err != nil {
+		// 摘要失败时使用结果开头部分，完整内容仍可读取
+		log.Printf("生成工具 %s 结果的摘要失败: %v，使用结果开头部分代替", toolName, err)
+		summary = string([]rune(result)[:threshold])
+	}
+	return fmt.Sprintf(messagesOf(t.cfg).SummarizedResult, stored.Length, stored.ID, summary), nil
+}
+
+// summarizeToolResult asks the model for a compact summary of a tool result
+func summarizeToolResult(ctx context.Context, cfg *config.Config, chatModel model.BaseChatModel, toolName, result string) (string, error) {
+	if runes := []rune(result); len(runes) > maxSummarizeInput {
+		result = string(runes[:maxSummarizeInput])
+	}
+
+	output, err := chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf(messagesOf(cfg).SummarizePrompt, toolName)),
+		schema.UserMessage(result),
+	})
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(output.Content)
+	if summary == "" {
+		return "", errors.New("大模型返回的摘要为空")
+	}
+	return summary, nil
+}
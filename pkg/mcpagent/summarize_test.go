@@ -0,0 +1,152 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/toolresult"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// longTool 返回很长的结果
+type longTool struct {
+	text string
+}
+
+func (l *longTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "scan", Desc: "scan a host"}, nil
+}
+
+func (l *longTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return l.text, nil
+}
+
+// summarizeChatModel 模拟摘要调用和一次工具结果展开
+type summarizeChatModel struct {
+	mu         *sync.Mutex
+	summaryErr error
+	summarized *[]string // 发送给摘要调用的工具结果
+	observed   *[]string // 执行阶段收到的工具消息
+}
+
+func newSummarizeChatModel() *summarizeChatModel {
+	return &summarizeChatModel{mu: &sync.Mutex{}, summarized: &[]string{}, observed: &[]string{}}
+}
+
+var resultIDPattern = regexp.MustCompile(`result_id 为 "([^"]+)"`)
+
+func (m *summarizeChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 摘要调用
+	if strings.Contains(input[0].Content, "负责压缩") {
+		*m.summarized = append(*m.summarized, input[len(input)-1].Content)
+		if m.summaryErr != nil {
+			return nil, m.summaryErr
+		}
+		return schema.AssistantMessage("  端口80和443开放  ", nil), nil
+	}
+
+	last := input[len(input)-1]
+	if last.Role != schema.Tool {
+		return schema.AssistantMessage("", []schema.ToolCall{
+			{ID: "call_1", Type: "function", Function: schema.FunctionCall{Name: "scan", Arguments: `{}`}},
+		}), nil
+	}
+	*m.observed = append(*m.observed, last.Content)
+	if match := resultIDPattern.FindStringSubmatch(last.Content); match != nil {
+		return schema.AssistantMessage("", []schema.ToolCall{
+			{ID: "call_2", Type: "function", Function: schema.FunctionCall{
+				Name: toolresult.ExpandToolName, Arguments: `{"result_id":"` + match[1] + `","start":2,"length":5}`,
+			}},
+		}), nil
+	}
+	return schema.AssistantMessage("done", nil), nil
+}
+
+func (m *summarizeChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *summarizeChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func runSummarizeAgent(t *testing.T, chatModel *summarizeChatModel, text string) {
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{MaxStep: 5, SystemPrompt: "你是助手",
+		ContextCompression: config.ContextCompressionSummarize, ContextCompressionThreshold: 20}
+	mockConfig.On("GetTools", mock.Anything).Return([]tool.BaseTool{&longTool{text: text}}, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+
+	agent, err := newAgent(context.Background(), &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	notify := newResultNotify()
+	require.NoError(t, agent.Execute(context.Background(), "扫描example.com", notify))
+	notify.AssertCalled(t, "OnResult", "done")
+}
+
+func TestSummarizeLongToolResult(t *testing.T) {
+	text := "0123456789" + strings.Repeat("端口扫描结果", 10)
+	chatModel := newSummarizeChatModel()
+	runSummarizeAgent(t, chatModel, text)
+
+	// 长结果发送给摘要调用，对话中只保留摘要和结果ID
+	assert.Equal(t, []string{text}, *chatModel.summarized)
+	observed := *chatModel.observed
+	require.Len(t, observed, 2)
+	assert.Equal(t, `[工具结果共 70 个字符，已替换为摘要。需要原文时调用 expand_result，result_id 为 "result_1"]`+"\n端口80和443开放", observed[0])
+
+	// expand_result读取完整内容中的一段
+	assert.Equal(t, "23456\n...[还有 63 个字符，可以使用 start=7 继续读取]", observed[1])
+}
+
+func TestSummarizeFallsBackToPrefix(t *testing.T) {
+	text := strings.Repeat("a", 30)
+	chatModel := newSummarizeChatModel()
+	chatModel.summaryErr = errors.New("模型不可用")
+	runSummarizeAgent(t, chatModel, text)
+
+	observed := *chatModel.observed
+	require.Len(t, observed, 2)
+	assert.True(t, strings.HasSuffix(observed[0], "\n"+strings.Repeat("a", 20)), observed[0])
+	assert.Equal(t, "aaaaa\n...[还有 23 个字符，可以使用 start=7 继续读取]", observed[1])
+}
+
+func TestWithResultSummaries(t *testing.T) {
+	tools := []tool.BaseTool{&echoTool{}}
+	chatModel := newSummarizeChatModel()
+
+	// 未开启时不改变工具
+	assert.Equal(t, tools, withResultSummaries(&config.Config{}, tools, chatModel))
+
+	cfg := &config.Config{ContextCompression: config.ContextCompressionSummarize}
+	wrapped := withResultSummaries(cfg, tools, chatModel)
+	require.Len(t, wrapped, 2)
+	info, err := wrapped[1].Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, toolresult.ExpandToolName, info.Name)
+
+	// 未超过阈值的结果原样返回，不调用模型
+	result, err := wrapped[0].(tool.InvokableTool).InvokableRun(context.Background(), `{"a":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, `echo:{"a":1}`, result)
+	assert.Empty(t, *chatModel.summarized)
+}
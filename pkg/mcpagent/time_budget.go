@@ -0,0 +1,161 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const errMsgMaxDurationExceeded = "%w（%v），任务未完成"
+
+// ErrMaxDurationExceeded is returned when a task uses up config.Config.MaxDuration before it finished
+var ErrMaxDurationExceeded = errors.New("已用完任务的时间预算")
+
+// timeNow returns the current time of time budgets, tests replace it with a fake clock
+var timeNow = time.Now
+
+// timeBudget tracks the wall-clock budget of one task for max_duration. A
+// step starts with every model request: before it the remaining time is
+// checked, below the wrap-up share the model is asked to answer now, and
+// without time left the task stops.
+type timeBudget struct {
+	mu       sync.Mutex
+	cfg      *config.Config
+	notify   Notify
+	start    time.Time
+	deadline time.Time
+	wrapUp   time.Duration // 剩余时间少于该值时要求模型给出答案
+	steps    int           // 已发出的模型请求数
+	nudged   bool          // 是否已通知剩余时间不足
+}
+
+// timeBudgetKey is the context key of the time budget of a task
+type timeBudgetKey struct{}
+
+// withTimeBudget returns a context carrying the time budget of a task
+// starting now when cfg sets max_duration, and the budget, nil without one.
+// The first wrap-up request is reported to notify as a warning.
+func withTimeBudget(ctx context.Context, cfg *config.Config, notify Notify) (context.Context, *timeBudget) {
+	if cfg == nil || cfg.MaxDurationDuration() <= 0 {
+		return ctx, nil
+	}
+
+	maxDuration := cfg.MaxDurationDuration()
+	start := timeNow()
+	budget := &timeBudget{
+		cfg:      cfg,
+		notify:   notify,
+		start:    start,
+		deadline: start.Add(maxDuration),
+		wrapUp:   time.Duration(float64(maxDuration) * cfg.EffectiveMaxDurationWrapUp()),
+	}
+	return context.WithValue(ctx, timeBudgetKey{}, budget), budget
+}
+
+// nextStep returns the time left before a step, the step is counted while time is left
+func (b *timeBudget) nextStep() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	remaining := b.deadline.Sub(timeNow())
+	if remaining > 0 {
+		b.steps++
+	}
+	return remaining
+}
+
+// usage returns the steps run and the time elapsed since the task started
+func (b *timeBudget) usage() (int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.steps, timeNow().Sub(b.start)
+}
+
+// prepare starts a step and returns the input of its request: with less
+// than the wrap-up share left a system message asking for the final answer
+// is appended. input is not modified.
+//
+// Returns:
+//   - []*schema.Message: Input of the request
+//   - error: Error wrapping ErrMaxDurationExceeded when no time is left
+func (b *timeBudget) prepare(input []*schema.Message) ([]*schema.Message, error) {
+	remaining := b.nextStep()
+	if remaining <= 0 {
+		log.Printf("任务已用完时间预算（%v），停止执行", b.cfg.MaxDurationDuration())
+		return nil, fmt.Errorf(errMsgMaxDurationExceeded, ErrMaxDurationExceeded, b.cfg.MaxDurationDuration())
+	}
+	if remaining >= b.wrapUp {
+		return input, nil
+	}
+
+	messages := messagesOf(b.cfg)
+	left := remaining.Round(time.Second)
+	b.mu.Lock()
+	first := !b.nudged
+	b.nudged = true
+	b.mu.Unlock()
+	if first {
+		b.notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.TimeBudgetLow, left))
+	}
+	nudged := append(append([]*schema.Message(nil), input...), schema.SystemMessage(fmt.Sprintf(messages.WrapUpInstruction, left)))
+	return nudged, nil
+}
+
+// timeBudgetModel checks the time budget of the task before every request
+type timeBudgetModel struct {
+	model.ToolCallingChatModel
+	budget *timeBudget
+}
+
+// withTimeBudgetModel wraps chatModel with timeBudgetModel when ctx carries the time budget of the task
+func withTimeBudgetModel(ctx context.Context, chatModel model.ToolCallingChatModel) model.ToolCallingChatModel {
+	budget, _ := ctx.Value(timeBudgetKey{}).(*timeBudget)
+	if budget == nil {
+		return chatModel
+	}
+	return &timeBudgetModel{ToolCallingChatModel: chatModel, budget: budget}
+}
+
+// WithTools binds tools to the wrapped model, the time budget is shared
+func (m *timeBudgetModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.ToolCallingChatModel.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	budgeted := *m
+	budgeted.ToolCallingChatModel = bound
+	return &budgeted, nil
+}
+
+// Generate starts a step and sends the request, see timeBudget.prepare
+func (m *timeBudgetModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	input, err := m.budget.prepare(input)
+	if err != nil {
+		return nil, err
+	}
+	return m.ToolCallingChatModel.Generate(ctx, input, opts...)
+}
+
+// Stream starts a step and sends the request, see timeBudget.prepare
+func (m *timeBudgetModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	input, err := m.budget.prepare(input)
+	if err != nil {
+		return nil, err
+	}
+	return m.ToolCallingChatModel.Stream(ctx, input, opts...)
+}
+
+// notifyTimeBudget reports the steps and the time used by a task with a time budget
+func notifyTimeBudget(notify Notify, cfg *config.Config, budget *timeBudget) {
+	if budget == nil {
+		return
+	}
+	steps, elapsed := budget.usage()
+	notify.OnMessage(fmt.Sprintf(messagesOf(cfg).TimeBudgetUsed, steps, cfg.MaxStep, elapsed.Round(100*time.Millisecond), cfg.MaxDurationDuration()))
+}
@@ -0,0 +1,123 @@
+package mcpagent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock 代替timeNow的时钟，只在调用advance时前进
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// useFakeClock 让时间预算使用假时钟，测试结束后恢复
+func useFakeClock(t *testing.T) *fakeClock {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	previous := timeNow
+	timeNow = clock.Now
+	t.Cleanup(func() { timeNow = previous })
+	return clock
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// slowTool 每次调用让假时钟前进elapsed
+type slowTool struct {
+	clock   *fakeClock
+	elapsed time.Duration
+}
+
+func (s *slowTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "upper", Desc: "upper case the input"}, nil
+}
+
+func (s *slowTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	s.clock.advance(s.elapsed)
+	return "UPPER", nil
+}
+
+// runTimeBudgetTask 以时间预算执行任务，返回任务的输出和错误
+func runTimeBudgetTask(t *testing.T, chatModel *stepRecordingModel, tools ...tool.BaseTool) (string, error) {
+	t.Helper()
+	mockConfig := &MockConfig{}
+	mockConfig.Config = config.Config{MaxStep: 10, SystemPrompt: "test prompt", MaxDuration: 100}
+	mockConfig.On("GetTools", mock.Anything).Return(tools, func() {}, nil).Once()
+	mockConfig.On("GetModel", mock.Anything).Return(chatModel, nil).Once()
+
+	agent, err := newAgent(context.Background(), &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	var out bytes.Buffer
+	notifier := NewFormattedCliNotifier(OutputPlain, &out, &out)
+	err = agent.Execute(context.Background(), "检查example.com", notifier)
+	return out.String(), err
+}
+
+func TestTimeBudgetWrapUp(t *testing.T) {
+	clock := useFakeClock(t)
+	chatModel := &stepRecordingModel{replies: []*schema.Message{
+		toolCallMessage("upper"),
+		toolCallMessage("upper"),
+		schema.AssistantMessage("目前能给出的结论", nil),
+	}}
+	output, err := runTimeBudgetTask(t, chatModel, &slowTool{clock: clock, elapsed: 45 * time.Second})
+	require.NoError(t, err)
+
+	// 剩余时间充足时不提醒，剩余10秒（少于15%）时追加系统消息要求给出回答
+	require.Len(t, chatModel.requests, 3)
+	for _, request := range chatModel.requests[:2] {
+		assert.NotEqual(t, schema.System, request.input[len(request.input)-1].Role)
+	}
+	last := chatModel.requests[2].input
+	assert.Equal(t, schema.System, last[len(last)-1].Role)
+	assert.Equal(t, "任务的时间预算只剩10s。不要再调用工具，立即根据已经获得的信息给出你能给出的最好的最终回答。", last[len(last)-1].Content)
+
+	assert.Contains(t, output, "警告: 任务的时间预算只剩10s，已要求模型尽快给出回答")
+	assert.Contains(t, output, "结果: 目前能给出的结论")
+	assert.Contains(t, output, "本次任务使用了3步（上限10），用时1m30s（时间预算1m40s）")
+}
+
+func TestTimeBudgetHardStop(t *testing.T) {
+	clock := useFakeClock(t)
+	chatModel := &stepRecordingModel{replies: []*schema.Message{toolCallMessage("upper")}}
+	output, err := runTimeBudgetTask(t, chatModel, &slowTool{clock: clock, elapsed: 60 * time.Second})
+
+	// 第二步前已经没有剩余时间，不再请求模型
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrMaxDurationExceeded), "%v", err)
+	assert.Equal(t, ErrorCodeMaxDuration, ErrorCode(err))
+	assert.Contains(t, err.Error(), "已用完任务的时间预算（1m40s），任务未完成")
+	assert.Len(t, chatModel.requests, 2)
+	assert.Contains(t, output, "本次任务使用了2步（上限10），用时2m0s（时间预算1m40s）")
+}
+
+func TestTimeBudgetDisabled(t *testing.T) {
+	// 未设置max_duration时不检查时间，也不发送用量消息
+	ctx, budget := withTimeBudget(context.Background(), &config.Config{MaxStep: 10}, newResultNotify())
+	assert.Nil(t, budget)
+	chatModel := &stepRecordingModel{}
+	assert.Same(t, chatModel, withTimeBudgetModel(ctx, chatModel))
+}
@@ -0,0 +1,147 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// TimelineNotify extends Notify for handlers that present the agent run as a
+// timeline. Every tool call carries a call ID, the result of the call carries the
+// same ID, and thinking that precedes a tool call references it as relatedCallID.
+//
+// When the notify handler implements TimelineNotify, LoggerCallback uses these
+// methods instead of OnThinking, OnToolCall and OnToolCallResult.
+type TimelineNotify interface {
+	Notify
+
+	// OnThinkingWithCall sends a thinking notification that led to the tool call relatedCallID
+	OnThinkingWithCall(msg string, relatedCallID string)
+
+	// OnToolCallWithID sends a tool call notification carrying its call ID
+	OnToolCallWithID(callID string, toolName string, params any)
+
+	// OnToolResult sends the result and the duration of the tool call identified
+	// by callID. err is non-nil if the tool failed, result is empty in that case.
+	OnToolResult(callID string, toolName string, result string, err error, duration time.Duration)
+}
+
+// ToolCallResult describes the result of a finished tool call
+type ToolCallResult struct {
+	CallID   string        // 工具调用ID，与OnToolCallWithID的callID相同
+	ToolName string        // 工具名称
+	Result   string        // 工具返回的内容，失败时为空
+	Err      error         // 调用错误，成功时为nil
+	Duration time.Duration // 调用耗时
+}
+
+// notifyToolResult sends result through OnToolResult if notify implements
+// TimelineNotify, through OnToolCallResult without the call ID otherwise
+func notifyToolResult(notify Notify, result ToolCallResult) {
+	if timeline, ok := notify.(TimelineNotify); ok {
+		timeline.OnToolResult(result.CallID, result.ToolName, result.Result, result.Err, result.Duration)
+		return
+	}
+	notify.OnToolCallResult(result.ToolName, result.Result, result.Err, result.Duration)
+}
+
+// generatedCallIDSeq is used for tool calls the model sent without an ID
+var generatedCallIDSeq atomic.Uint64
+
+// callTracker remembers the IDs of tool calls that have been announced but have
+// not produced a result yet, so results can be correlated even if the tools node
+// does not expose the call ID. The zero value is ready for use.
+type callTracker struct {
+	mu      sync.Mutex
+	pending map[string][]string // tool name -> call IDs in call order
+}
+
+// start registers a tool call and returns its call ID.
+// The ID sent by the model is used if present, otherwise one is generated.
+func (t *callTracker) start(toolCall schema.ToolCall) string {
+	callID := toolCall.ID
+	if callID == "" {
+		callID = fmt.Sprintf("call_%d", generatedCallIDSeq.Add(1))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending == nil {
+		t.pending = make(map[string][]string)
+	}
+	name := toolCall.Function.Name
+	t.pending[name] = append(t.pending[name], callID)
+	return callID
+}
+
+// finish removes a tool call and returns its call ID.
+// If callID is empty, the oldest pending call of the tool is used.
+func (t *callTracker) finish(toolName string, callID string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ids := t.pending[toolName]
+	if callID == "" {
+		if len(ids) == 0 {
+			return ""
+		}
+		callID = ids[0]
+	}
+	for i, id := range ids {
+		if id == callID {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(t.pending, toolName)
+	} else {
+		t.pending[toolName] = ids
+	}
+	return callID
+}
+
+// handleTimelineTool notifies a tool call and the thinking preceding it with correlation IDs
+func (cb *LoggerCallback) handleTimelineTool(notify TimelineNotify, callID string, toolName string, arguments map[string]any) {
+	for _, fieldName := range ThinkFieldName {
+		if thinkStr, ok := arguments[fieldName].(string); ok && strings.TrimSpace(thinkStr) != "" {
+			notify.OnThinkingWithCall(thinkStr, callID)
+			break
+		}
+	}
+
+	notify.OnToolCallWithID(callID, toolName, cb.catalog().CanonicalizeArguments(arguments))
+}
+
+// reportToolResult sends the result and the duration of a finished tool
+// invocation, see notifyToolResult. The start time of the invocation is kept
+// in ctx by OnStart.
+func (cb *LoggerCallback) reportToolResult(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput, err error) {
+	if info == nil || info.Component != components.ComponentOfTool {
+		return
+	}
+
+	result := ToolCallResult{
+		CallID:   cb.calls.finish(info.Name, compose.GetToolCallID(ctx)),
+		ToolName: info.Name,
+		Err:      err,
+	}
+	if start, ok := ctx.Value(toolStartKey{}).(time.Time); ok {
+		result.Duration = time.Since(start)
+	}
+	if err == nil {
+		if out := tool.ConvCallbackOutput(output); out != nil {
+			result.Result = out.Response
+		}
+	}
+	notifyToolResult(cb.notify, result)
+}
@@ -0,0 +1,177 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// timelineEvent 记录一次时间线通知
+type timelineEvent struct {
+	kind          string
+	callID        string
+	relatedCallID string
+	toolName      string
+	content       string
+	err           error
+	duration      time.Duration
+}
+
+// timelineRecordingNotify 记录时间线通知的通知器
+type timelineRecordingNotify struct {
+	mu     sync.Mutex
+	events []timelineEvent
+}
+
+func (n *timelineRecordingNotify) record(e timelineEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, e)
+}
+
+func (n *timelineRecordingNotify) OnMessage(msg string) {}
+func (n *timelineRecordingNotify) OnThinking(msg string) {
+	n.record(timelineEvent{kind: "thinking", content: msg})
+}
+func (n *timelineRecordingNotify) OnToolCall(toolName string, params any) {
+	n.record(timelineEvent{kind: "tool_call", toolName: toolName})
+}
+func (n *timelineRecordingNotify) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	n.record(timelineEvent{kind: "tool_result", toolName: toolName, content: result, err: err, duration: duration})
+}
+func (n *timelineRecordingNotify) OnResult(msg string) {}
+func (n *timelineRecordingNotify) OnError(err error)   {}
+
+func (n *timelineRecordingNotify) OnThinkingWithCall(msg string, relatedCallID string) {
+	n.record(timelineEvent{kind: "thinking", content: msg, relatedCallID: relatedCallID})
+}
+
+func (n *timelineRecordingNotify) OnToolCallWithID(callID string, toolName string, params any) {
+	n.record(timelineEvent{kind: "tool_call", callID: callID, toolName: toolName})
+}
+
+func (n *timelineRecordingNotify) OnToolResult(callID string, toolName string, result string, err error, duration time.Duration) {
+	n.record(timelineEvent{kind: "tool_result", callID: callID, toolName: toolName, content: result, err: err, duration: duration})
+}
+
+func TestLoggerCallbackTimelineIDs(t *testing.T) {
+	ctx := context.Background()
+	notify := &timelineRecordingNotify{}
+	cb := &LoggerCallback{notify: notify}
+
+	message := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_abc", Function: schema.FunctionCall{Name: "search", Arguments: `{"thought":"先搜索资料","query":"mcp"}`}},
+		{Function: schema.FunctionCall{Name: "fetch", Arguments: `{"url":"https://example.com"}`}},
+	})
+	cb.OnStart(ctx, &callbacks.RunInfo{}, message)
+
+	require.Len(t, notify.events, 3)
+	assert.Equal(t, timelineEvent{kind: "thinking", content: "先搜索资料", relatedCallID: "call_abc"}, notify.events[0])
+	assert.Equal(t, "call_abc", notify.events[1].callID)
+	assert.Equal(t, "search", notify.events[1].toolName)
+	generatedID := notify.events[2].callID
+	assert.NotEmpty(t, generatedID, "tool calls without ID should get a generated one")
+
+	// 工具结果按工具名称关联到对应的调用
+	cb.OnEnd(ctx, &callbacks.RunInfo{Name: "fetch", Component: components.ComponentOfTool}, "<html>")
+	cb.OnError(ctx, &callbacks.RunInfo{Name: "search", Component: components.ComponentOfTool}, errors.New("timeout"))
+
+	require.Len(t, notify.events, 5)
+	assert.Equal(t, timelineEvent{kind: "tool_result", callID: generatedID, toolName: "fetch", content: "<html>"}, notify.events[3])
+	assert.Equal(t, "call_abc", notify.events[4].callID)
+	assert.EqualError(t, notify.events[4].err, "timeout")
+}
+
+func TestLoggerCallbackToolCallDuration(t *testing.T) {
+	notify := &timelineRecordingNotify{}
+	cb := &LoggerCallback{notify: notify}
+	cb.OnStart(context.Background(), &callbacks.RunInfo{}, schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_abc", Function: schema.FunctionCall{Name: "search", Arguments: `{}`}},
+		{ID: "call_def", Function: schema.FunctionCall{Name: "fetch", Arguments: `{}`}},
+	}))
+
+	// 耗时从工具的OnStart开始计算
+	info := &callbacks.RunInfo{Name: "search", Component: components.ComponentOfTool}
+	ctx := cb.OnStart(context.Background(), info, `{}`)
+	time.Sleep(20 * time.Millisecond)
+	cb.OnEnd(ctx, info, "ok")
+
+	info = &callbacks.RunInfo{Name: "fetch", Component: components.ComponentOfTool}
+	ctx = cb.OnStart(context.Background(), info, `{}`)
+	cb.OnError(ctx, info, errors.New("timeout"))
+
+	require.Len(t, notify.events, 4)
+	assert.Equal(t, "call_abc", notify.events[2].callID)
+	assert.Equal(t, "ok", notify.events[2].content)
+	assert.GreaterOrEqual(t, notify.events[2].duration, 20*time.Millisecond)
+	assert.Equal(t, "call_def", notify.events[3].callID)
+	assert.EqualError(t, notify.events[3].err, "timeout")
+}
+
+func TestLoggerCallbackWithoutTimelineNotify(t *testing.T) {
+	mockNotify := new(MockNotify)
+	mockNotify.On("OnToolCall", "search", mock.Anything).Return()
+	// 没有时间线的通知器通过OnToolCallResult收到不带调用ID的结果和耗时
+	mockNotify.On("OnToolCallResult", "search", "ok", nil, mock.MatchedBy(func(d time.Duration) bool { return d >= 0 })).Return()
+	cb := &LoggerCallback{notify: mockNotify}
+
+	message := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_abc", Function: schema.FunctionCall{Name: "search", Arguments: `{}`}},
+	})
+	cb.OnStart(context.Background(), nil, message)
+	info := &callbacks.RunInfo{Name: "search", Component: components.ComponentOfTool}
+	cb.OnEnd(cb.OnStart(context.Background(), info, `{}`), info, "ok")
+
+	mockNotify.AssertExpectations(t)
+}
+
+// echoTool 返回固定结果的测试工具
+type echoTool struct{}
+
+func (e *echoTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "echo", Desc: "echo the input"}, nil
+}
+
+func (e *echoTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return "echo:" + argumentsInJSON, nil
+}
+
+func TestExecuteAgentTaskPropagatesCallID(t *testing.T) {
+	ctx := context.Background()
+
+	toolCallMsg := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_42", Type: "function", Function: schema.FunctionCall{Name: "echo", Arguments: `{"think":"需要回显"}`}},
+	})
+	mockModel := new(MockToolCallingChatModel)
+	mockModel.On("WithTools", mock.Anything).Return(mockModel, nil)
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(toolCallMsg, nil).Once()
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(schema.AssistantMessage("done", nil), nil)
+
+	cfg := &config.Config{SystemPrompt: "test prompt", MaxStep: 5}
+	ragent, err := createReActAgent(ctx, cfg, []tool.BaseTool{&echoTool{}}, mockModel)
+	require.NoError(t, err)
+
+	notify := &timelineRecordingNotify{}
+	require.NoError(t, executeAgentTask(ctx, cfg, ragent, "test task", notify))
+
+	var kinds []string
+	for _, e := range notify.events {
+		kinds = append(kinds, e.kind)
+	}
+	require.Equal(t, []string{"thinking", "tool_call", "tool_result"}, kinds)
+	assert.Equal(t, "call_42", notify.events[0].relatedCallID)
+	assert.Equal(t, "call_42", notify.events[1].callID)
+	assert.Equal(t, "call_42", notify.events[2].callID)
+	assert.Equal(t, `echo:{"think":"需要回显"}`, notify.events[2].content)
+}
@@ -0,0 +1,206 @@
+package mcpagent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+// Parameters of the BM25 scores of tools_auto_select
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// ToolSelection is a tool chosen by tools_auto_select for a task
+type ToolSelection struct {
+	ToolSuggestion
+	Score    float64  `json:"score"`    // 工具与任务的相关性得分
+	Keywords []string `json:"keywords"` // 任务中与工具名称或描述匹配的关键词
+}
+
+// SelectTools scores candidates against task with BM25 over the keywords of
+// their names and descriptions, and returns the best tools scoring at least
+// minScore, at most maxTools of them, best first.
+//
+// Parameters:
+//   - task: Task description
+//   - candidates: Tools to select from
+//   - maxTools: Maximum number of selected tools
+//   - minScore: Minimum score of a selected tool
+//
+// Returns:
+//   - []ToolSelection: Selected tools, empty if none is relevant
+func SelectTools(task string, candidates []ToolSuggestion, maxTools int, minScore float64) []ToolSelection {
+	keywords := taskKeywords(task)
+	if len(keywords) == 0 || len(candidates) == 0 {
+		return nil
+	}
+
+	// 每个工具的名称和描述分词后作为一篇文档
+	documents := make([]map[string]int, len(candidates))
+	lengths := make([]int, len(candidates))
+	frequencies := make(map[string]int)
+	total := 0
+	for i, candidate := range candidates {
+		terms := textTerms(toolText(candidate))
+		counts := make(map[string]int, len(terms))
+		for _, term := range terms {
+			counts[term]++
+		}
+		for term := range counts {
+			frequencies[term]++
+		}
+		documents[i] = counts
+		lengths[i] = len(terms)
+		total += len(terms)
+	}
+	averageLength := float64(total) / float64(len(candidates))
+	if averageLength == 0 {
+		return nil
+	}
+
+	var selections []ToolSelection
+	for i, candidate := range candidates {
+		selection := ToolSelection{ToolSuggestion: candidate}
+		for _, keyword := range keywords {
+			tf := float64(documents[i][keyword])
+			if tf == 0 {
+				continue
+			}
+			df := float64(frequencies[keyword])
+			idf := math.Log(1 + (float64(len(candidates))-df+0.5)/(df+0.5))
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(lengths[i])/averageLength)
+			selection.Score += idf * tf * (bm25K1 + 1) / (tf + norm)
+			selection.Keywords = append(selection.Keywords, keyword)
+		}
+		if selection.Score >= minScore {
+			selections = append(selections, selection)
+		}
+	}
+	sort.SliceStable(selections, func(i, j int) bool {
+		if selections[i].Score != selections[j].Score {
+			return selections[i].Score > selections[j].Score
+		}
+		return selections[i].String() < selections[j].String()
+	})
+	if len(selections) > maxTools {
+		selections = selections[:maxTools]
+	}
+	return selections
+}
+
+// toolText returns the text of a tool matched by SelectTools: its name and all of its descriptions
+func toolText(candidate ToolSuggestion) string {
+	parts := []string{candidate.Name, candidate.Description}
+	languages := make([]string, 0, len(candidate.Descriptions))
+	for language := range candidate.Descriptions {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	for _, language := range languages {
+		parts = append(parts, candidate.Descriptions[language])
+	}
+	return strings.Join(parts, " ")
+}
+
+// AutoSelectTools chooses the tools of a task when cfg enables
+// tools_auto_select and selects no tools. The candidates are the tools of
+// the ToolCatalog of ctx, or the internal tools without one, limited to the
+// internal tools and the servers of cfg.MCP.MCPServers if it is set. The selection
+// and why each tool was chosen are sent to notify. Without relevant tools
+// the task runs without tools.
+//
+// Parameters:
+//   - ctx: Context carrying the ToolCatalog, see WithToolCatalog
+//   - cfg: Configuration of the task, not modified
+//   - task: Task description
+//   - notify: Notification handler for the selection
+//
+// Returns:
+//   - *config.Config: cfg itself when tools are not selected automatically, otherwise a copy using the selected tools
+//   - []ToolSelection: Selected tools
+func AutoSelectTools(ctx context.Context, cfg *config.Config, task string, notify Notify) (*config.Config, []ToolSelection) {
+	if !cfg.MCP.AutoSelectsTools() {
+		return cfg, nil
+	}
+	messages := messagesOf(cfg)
+
+	candidates, err := toolCandidates(ctx, cfg)
+	if err != nil {
+		log.Printf("获取可选择的工具失败: %v", err)
+		notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.ToolsAutoSelectFailed, err))
+		copied := *cfg
+		copied.MCP.ToolsAutoSelect = false
+		return &copied, nil
+	}
+
+	if cfg.MCP.MCPServers != nil {
+		// 只选择已配置的服务器提供的工具
+		var available []ToolSuggestion
+		for _, candidate := range candidates {
+			if _, ok := cfg.MCP.MCPServers[candidate.Server]; ok || candidate.Server == config.InnerServerName {
+				available = append(available, candidate)
+			}
+		}
+		candidates = available
+	}
+
+	selections := SelectTools(task, candidates, cfg.MCP.EffectiveToolsAutoSelectMax(), cfg.MCP.EffectiveToolsAutoSelectMinScore())
+	if len(selections) == 0 {
+		notify.OnMessage(fmt.Sprintf(messages.ToolsAutoSelectNone, cfg.MCP.EffectiveToolsAutoSelectMinScore()))
+	} else {
+		reasons := make([]string, len(selections))
+		for i, selection := range selections {
+			reasons[i] = fmt.Sprintf(messages.ToolsAutoSelectedTool, selection.String(), selection.Score, strings.Join(selection.Keywords, ", "))
+		}
+		notify.OnMessage(fmt.Sprintf(messages.ToolsAutoSelected, len(selections), strings.Join(reasons, "; ")))
+	}
+	log.Printf("自动选择工具: %v", selections)
+	return ApplyToolSelection(cfg, selections), selections
+}
+
+// ApplyToolSelection returns a copy of cfg using the selected tools: only
+// the MCP servers providing them are kept, and without a selection no tool
+// is used. Tools are not selected again for the copy.
+//
+// Parameters:
+//   - cfg: Configuration of the task, not modified
+//   - selections: Tools chosen by SelectTools
+//
+// Returns:
+//   - *config.Config: Configuration using the selected tools
+func ApplyToolSelection(cfg *config.Config, selections []ToolSelection) *config.Config {
+	copied := *cfg
+	copied.MCP.ToolsAutoSelect = false
+
+	tools := make([]config.MCPToolConfig, 0, len(selections))
+	servers := make(map[string]bool)
+	for _, selection := range selections {
+		tools = append(tools, config.MCPToolConfig{Server: selection.Server, Name: selection.Name})
+		servers[selection.Server] = true
+	}
+	copied.MCP.Tools = cfg.MCP.InheritDescriptions(cfg.MCP.InheritResultFilters(tools))
+
+	// 只连接提供所选工具的服务器
+	if len(selections) == 0 || cfg.MCP.MCPServers != nil {
+		mcpServers := make(map[string]*mcphost.ServerConfig)
+		for name, serverConfig := range cfg.MCP.MCPServers {
+			if servers[name] {
+				mcpServers[name] = serverConfig
+			}
+		}
+		copied.MCP.MCPServers = mcpServers
+	}
+	if len(selections) == 0 {
+		// 没有工具也没有服务器时仅由模型回答，不读取配置文件中的服务器
+		copied.MCP.Tools = nil
+	}
+	return &copied
+}
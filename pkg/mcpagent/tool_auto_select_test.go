@@ -0,0 +1,109 @@
+package mcpagent
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// autoSelectCatalog 模拟数据库中缓存的工具表
+var autoSelectCatalog = []ToolSuggestion{
+	{Server: "fofa", Name: "fofa_search", Description: "Search cyberspace assets such as hosts, domains and certificates with FOFA query syntax",
+		Descriptions: map[string]string{"zh": "使用FOFA语法搜索网络空间资产，查询主机、域名和证书"}},
+	{Server: "web", Name: "url_markdown", Description: "Fetch a web page by URL and convert its content to markdown"},
+	{Server: "ddg", Name: "web_search", Description: "Search the web with DuckDuckGo and return the result titles and links",
+		Descriptions: map[string]string{"zh": "使用DuckDuckGo搜索网页"}},
+	{Server: "whois", Name: "whois_lookup", Description: "Query the whois registration record of a domain"},
+	{Server: "nmap", Name: "port_scan", Description: "Scan the open ports of a host and detect the services"},
+	{Server: "calc", Name: "add", Description: "两个数字相加"},
+}
+
+// selectedNames 返回选择的工具，格式为server:tool
+func selectedNames(selections []ToolSelection) []string {
+	names := make([]string, len(selections))
+	for i, selection := range selections {
+		names[i] = selection.String()
+	}
+	return names
+}
+
+func TestSelectTools(t *testing.T) {
+	tests := []struct {
+		task  string
+		first string
+	}{
+		{"用FOFA查询example.com的资产", "fofa:fofa_search"},
+		{"fetch https://example.com/about and summarize the page content", "web:url_markdown"},
+		{"scan the open ports of 10.0.0.1", "nmap:port_scan"},
+		{"who registered the domain example.com? check whois", "whois:whois_lookup"},
+		{"搜索网页上关于Go 1.24的新闻", "ddg:web_search"},
+		{"计算两个数字相加的结果：3和5", "calc:add"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.task, func(t *testing.T) {
+			selections := SelectTools(tt.task, autoSelectCatalog, config.DefaultToolsAutoSelectMax, config.DefaultToolsAutoSelectMinScore)
+			require.NotEmpty(t, selections)
+			assert.Equal(t, tt.first, selections[0].String(), "%v", selectedNames(selections))
+			assert.NotEmpty(t, selections[0].Keywords)
+			for i := 1; i < len(selections); i++ {
+				assert.GreaterOrEqual(t, selections[i-1].Score, selections[i].Score)
+			}
+		})
+	}
+
+	// 与任何工具都无关的任务不选择工具
+	assert.Empty(t, SelectTools("写一首关于春天的诗", autoSelectCatalog, config.DefaultToolsAutoSelectMax, config.DefaultToolsAutoSelectMinScore))
+	// 最多选择maxTools个工具
+	assert.Len(t, SelectTools("search the web and fofa assets, fetch the page, scan ports and whois the domain", autoSelectCatalog, 2, config.DefaultToolsAutoSelectMinScore), 2)
+	// 最低得分过滤弱相关的工具
+	assert.Empty(t, SelectTools("search", autoSelectCatalog, config.DefaultToolsAutoSelectMax, 10))
+}
+
+func TestAutoSelectTools(t *testing.T) {
+	ctx := WithToolCatalog(context.Background(), func(ctx context.Context) ([]ToolSuggestion, error) {
+		return autoSelectCatalog, nil
+	})
+	cfg := &config.Config{MCP: config.MCPConfig{
+		ToolsAutoSelect: true,
+		MCPServers: map[string]*mcphost.ServerConfig{
+			"fofa": {}, "web": {}, "ddg": {}, "whois": {},
+		},
+	}}
+
+	var out bytes.Buffer
+	notify := NewFormattedCliNotifier(OutputPlain, &out, &out)
+	selected, selections := AutoSelectTools(ctx, cfg, "用FOFA查询example.com的资产", notify)
+	require.NotEmpty(t, selections)
+	assert.Equal(t, "fofa:fofa_search", selections[0].String())
+	// 只连接提供所选工具的服务器，原配置不变
+	assert.Equal(t, []config.MCPToolConfig{{Server: "fofa", Name: "fofa_search"}}, selected.MCP.Tools[:1])
+	assert.Contains(t, selected.MCP.MCPServers, "fofa")
+	assert.NotContains(t, selected.MCP.MCPServers, "whois")
+	assert.False(t, selected.MCP.AutoSelectsTools())
+	assert.Len(t, cfg.MCP.MCPServers, 4)
+	assert.Empty(t, cfg.MCP.Tools)
+	assert.Contains(t, out.String(), "根据任务内容自动选择了")
+	assert.Contains(t, out.String(), "fofa:fofa_search（得分")
+
+	// 未配置的服务器的工具不会被选择
+	_, selections = AutoSelectTools(ctx, cfg, "scan the open ports of 10.0.0.1", newResultNotify())
+	assert.NotContains(t, selectedNames(selections), "nmap:port_scan")
+
+	// 没有相关的工具时不使用工具
+	out.Reset()
+	selected, selections = AutoSelectTools(ctx, cfg, "写一首关于春天的诗", notify)
+	assert.Empty(t, selections)
+	assert.True(t, selected.MCP.NoToolsRequested())
+	assert.Contains(t, out.String(), "没有与任务相关的工具（最低得分0.50），不使用工具执行任务")
+
+	// 已经选择了工具时不自动选择
+	cfg.MCP.Tools = []config.MCPToolConfig{{Server: "web", Name: "url_markdown"}}
+	selected, selections = AutoSelectTools(ctx, cfg, "用FOFA查询example.com的资产", newResultNotify())
+	assert.Same(t, cfg, selected)
+	assert.Nil(t, selections)
+}
@@ -0,0 +1,118 @@
+package mcpagent
+
+import (
+	"context"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+)
+
+// ToolUsage describes a single finished tool invocation
+type ToolUsage struct {
+	ToolName string        // 工具名称
+	ToolKey  string        // 工具唯一标识（server_name + "_" + tool_name）
+	Duration time.Duration // 调用耗时
+	Err      error         // 调用错误，成功时为nil
+}
+
+// ToolUsageNotify extends Notify for handlers that collect tool usage statistics.
+// OnToolUsage is called from the agent goroutine after every tool invocation and
+// must return quickly; implementations should hand the usage off asynchronously.
+type ToolUsageNotify interface {
+	Notify
+
+	// OnToolUsage receives the outcome of a tool invocation
+	OnToolUsage(usage ToolUsage)
+}
+
+// toolStartKey is the context key holding the start time of a tool invocation
+type toolStartKey struct{}
+
+// toolUsageCallback reports tool invocations to a ToolUsageNotify
+type toolUsageCallback struct {
+	notify   ToolUsageNotify
+	toolKeys map[string]string
+}
+
+// newToolUsageHandler creates the callback handler reporting tool usage.
+// Tool names, original or as presented to the LLM, are mapped to tool keys using
+// the configured tool list; tools that are not configured explicitly are treated as inner tools.
+func newToolUsageHandler(cfg *config.Config, notify ToolUsageNotify) callbacks.Handler {
+	cb := &toolUsageCallback{
+		notify:   notify,
+		toolKeys: make(map[string]string),
+	}
+	for _, t := range cfg.MCP.Tools {
+		key := models.GenerateToolKey(t.Server, t.Name)
+		cb.toolKeys[t.Name] = key
+		cb.toolKeys[cfg.MCP.PresentedToolName(t.Server, t.Name)] = key
+	}
+
+	return callbacks.NewHandlerBuilder().
+		OnStartFn(cb.onStart).
+		OnEndFn(cb.onEnd).
+		OnErrorFn(cb.onError).
+		Build()
+}
+
+// toolKey returns the tool key for a tool name
+func (cb *toolUsageCallback) toolKey(toolName string) string {
+	if key, ok := cb.toolKeys[toolName]; ok {
+		return key
+	}
+	return models.GenerateToolKey(config.InnerServerName, toolName)
+}
+
+func (cb *toolUsageCallback) onStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
+	if info == nil || info.Component != components.ComponentOfTool {
+		return ctx
+	}
+	return context.WithValue(ctx, toolStartKey{}, time.Now())
+}
+
+func (cb *toolUsageCallback) onEnd(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
+	cb.report(ctx, info, nil)
+	return ctx
+}
+
+func (cb *toolUsageCallback) onError(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+	cb.report(ctx, info, err)
+	return ctx
+}
+
+// report sends the usage of a finished tool invocation
+func (cb *toolUsageCallback) report(ctx context.Context, info *callbacks.RunInfo, err error) {
+	defer recoverCallback("OnToolUsage")
+	if info == nil || info.Component != components.ComponentOfTool {
+		return
+	}
+
+	var duration time.Duration
+	if start, ok := ctx.Value(toolStartKey{}).(time.Time); ok {
+		duration = time.Since(start)
+	}
+
+	cb.notify.OnToolUsage(ToolUsage{
+		ToolName: info.Name,
+		ToolKey:  cb.toolKey(info.Name),
+		Duration: duration,
+		Err:      err,
+	})
+}
+
+// buildCallbackHandlers returns the callback handlers used for an agent run
+func buildCallbackHandlers(cfg *config.Config, notify Notify) []callbacks.Handler {
+	handlers := []callbacks.Handler{&LoggerCallback{notify: notify, messages: messagesOf(cfg)}}
+	if usageNotify, ok := notify.(ToolUsageNotify); ok {
+		handlers = append(handlers, newToolUsageHandler(cfg, usageNotify))
+	}
+	if checkpointNotify, ok := notify.(CheckpointNotify); ok {
+		if handler := newCheckpointHandler(checkpointNotify, messagesOf(cfg)); handler != nil {
+			handlers = append(handlers, handler)
+		}
+	}
+	return handlers
+}
@@ -0,0 +1,76 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usageRecordingNotify 记录工具使用情况的通知器
+type usageRecordingNotify struct {
+	MockNotify
+	usages []ToolUsage
+}
+
+func (n *usageRecordingNotify) OnToolUsage(usage ToolUsage) {
+	n.usages = append(n.usages, usage)
+}
+
+func TestToolUsageHandler(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{
+		MCP: config.MCPConfig{
+			Tools: []config.MCPToolConfig{{Server: "fetch", Name: "fetch"}},
+		},
+	}
+	notify := &usageRecordingNotify{}
+	handler := newToolUsageHandler(cfg, notify)
+
+	toolInfo := &callbacks.RunInfo{Name: "fetch", Component: components.ComponentOfTool}
+	ctx1 := handler.OnStart(ctx, toolInfo, nil)
+	handler.OnEnd(ctx1, toolInfo, nil)
+
+	innerInfo := &callbacks.RunInfo{Name: "search", Component: components.ComponentOfTool}
+	ctx2 := handler.OnStart(ctx, innerInfo, nil)
+	handler.OnError(ctx2, innerInfo, errors.New("timeout"))
+
+	// 非工具组件不应记录
+	modelInfo := &callbacks.RunInfo{Name: "model", Component: components.ComponentOfChatModel}
+	handler.OnEnd(handler.OnStart(ctx, modelInfo, nil), modelInfo, nil)
+
+	require.Len(t, notify.usages, 2)
+	assert.Equal(t, "fetch_fetch", notify.usages[0].ToolKey)
+	assert.NoError(t, notify.usages[0].Err)
+	assert.Equal(t, "inner_search", notify.usages[1].ToolKey)
+	assert.EqualError(t, notify.usages[1].Err, "timeout")
+}
+
+func TestToolUsageHandlerPresentedNames(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{
+		MCP: config.MCPConfig{
+			PrefixToolNames: true,
+			Tools:           []config.MCPToolConfig{{Server: "fetch", Name: "fetch"}},
+		},
+	}
+	notify := &usageRecordingNotify{}
+	handler := newToolUsageHandler(cfg, notify)
+
+	toolInfo := &callbacks.RunInfo{Name: "fetch__fetch", Component: components.ComponentOfTool}
+	handler.OnEnd(handler.OnStart(ctx, toolInfo, nil), toolInfo, nil)
+
+	require.Len(t, notify.usages, 1)
+	assert.Equal(t, "fetch_fetch", notify.usages[0].ToolKey)
+}
+
+func TestBuildCallbackHandlers(t *testing.T) {
+	cfg := &config.Config{}
+	assert.Len(t, buildCallbackHandlers(cfg, new(MockNotify)), 1)
+	assert.Len(t, buildCallbackHandlers(cfg, &usageRecordingNotify{}), 2)
+}
@@ -0,0 +1,226 @@
+package mcpagent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// maxEvidenceResultLen is the number of characters of a single tool result
+	// kept in the evidence digest
+	maxEvidenceResultLen = 1500
+	// maxEvidenceLen is the number of characters of all tool results kept in
+	// the evidence digest, later results are omitted
+	maxEvidenceLen = 12000
+)
+
+// Verification is the verdict of agent.verify_result on the final answer
+type Verification struct {
+	Supported   []string `json:"supported"`   // 有工具结果支持的结论
+	Unsupported []string `json:"unsupported"` // 没有工具结果支持的结论
+}
+
+// Passed reports whether every claim of the answer is supported by the tool results
+func (v Verification) Passed() bool {
+	return len(v.Unsupported) == 0
+}
+
+// VerificationNotify extends Notify for handlers that present the verdict of
+// agent.verify_result together with the result.
+//
+// OnVerification is called right before OnResult and only if the
+// verification succeeded. Unsupported claims are also sent as a warning
+// through OnMessage, so handlers without VerificationNotify learn about them.
+type VerificationNotify interface {
+	Notify
+
+	// OnVerification receives the verdict on the result sent next
+	OnVerification(verification Verification)
+}
+
+// evidence is a tool result gathered for the verification of the answer
+type evidence struct {
+	tool   string
+	result string
+}
+
+// resultVerifier collects the tool results of a task and checks the final
+// answer against them with an extra model call
+type resultVerifier struct {
+	chatModel model.BaseChatModel
+
+	mu       sync.Mutex
+	evidence []evidence
+}
+
+// resultVerifierKey is the context key of the resultVerifier of a task
+type resultVerifierKey struct{}
+
+// withResultVerifier returns a context collecting the tool results of the
+// task for agent.verify_result, ctx itself when verification is disabled
+func withResultVerifier(ctx context.Context, cfg *config.Config, chatModel model.BaseChatModel) context.Context {
+	if cfg == nil || !cfg.Agent.VerifyResult {
+		return ctx
+	}
+	return context.WithValue(ctx, resultVerifierKey{}, &resultVerifier{chatModel: chatModel})
+}
+
+// resultVerifierFrom returns the resultVerifier of the task, nil without verification
+func resultVerifierFrom(ctx context.Context) *resultVerifier {
+	verifier, _ := ctx.Value(resultVerifierKey{}).(*resultVerifier)
+	return verifier
+}
+
+// record adds a tool result to the evidence
+func (v *resultVerifier) record(toolName string, result string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.evidence = append(v.evidence, evidence{tool: toolName, result: result})
+}
+
+// digest returns the tool results as numbered entries, each cut to
+// maxEvidenceResultLen characters and all together to maxEvidenceLen
+func (v *resultVerifier) digest(cfg *config.Config) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	messages := messagesOf(cfg)
+	if len(v.evidence) == 0 {
+		return messages.VerifyNoEvidence
+	}
+
+	var b strings.Builder
+	total := 0
+	for i, item := range v.evidence {
+		result := strings.TrimSpace(item.result)
+		if runes := []rune(result); len(runes) > maxEvidenceResultLen {
+			result = string(runes[:maxEvidenceResultLen]) + messages.ContentTruncated
+		}
+		total += len([]rune(result))
+		if total > maxEvidenceLen && i > 0 {
+			fmt.Fprintf(&b, messages.VerifyEvidenceOmitted, len(v.evidence)-i)
+			break
+		}
+		fmt.Fprintf(&b, "[%d] %s:\n%s\n\n", i+1, item.tool, result)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// verify asks the model which claims of answer are supported by the tool
+// results. The model is called without tools.
+func (v *resultVerifier) verify(ctx context.Context, cfg *config.Config, answer string) (Verification, error) {
+	output, err := v.chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf(messagesOf(cfg).VerifyPrompt, v.digest(cfg))),
+		schema.UserMessage(answer),
+	})
+	if err != nil {
+		return Verification{}, err
+	}
+	return parseVerification(output.Content)
+}
+
+// parseVerification decodes the JSON verdict of the model, which may be
+// wrapped in a code fence or surrounded by text. Empty claims are dropped.
+func parseVerification(content string) (Verification, error) {
+	content = stripCodeFence(content)
+	if start, end := strings.IndexByte(content, '{'), strings.LastIndexByte(content, '}'); start >= 0 && end > start {
+		content = content[start : end+1]
+	}
+
+	var verification Verification
+	if err := json.Unmarshal([]byte(content), &verification); err != nil {
+		return Verification{}, fmt.Errorf("解析校验结果失败: %w", err)
+	}
+	verification.Supported = nonEmptyClaims(verification.Supported)
+	verification.Unsupported = nonEmptyClaims(verification.Unsupported)
+	if verification.Supported == nil && verification.Unsupported == nil {
+		return Verification{}, errors.New("解析校验结果失败: 大模型没有返回任何结论")
+	}
+	return verification, nil
+}
+
+// nonEmptyClaims trims the claims and removes the empty ones
+func nonEmptyClaims(claims []string) []string {
+	var kept []string
+	for _, claim := range claims {
+		if claim = strings.TrimSpace(claim); claim != "" {
+			kept = append(kept, claim)
+		}
+	}
+	return kept
+}
+
+// verifyAnswer checks answer against the tool results of the task when
+// agent.verify_result is enabled and sends the verdict to notify. A failed
+// verification is reported as a warning and does not fail the task.
+func verifyAnswer(ctx context.Context, cfg *config.Config, answer string, notify Notify) {
+	verifier := resultVerifierFrom(ctx)
+	if verifier == nil {
+		return
+	}
+
+	messages := messagesOf(cfg)
+	verification, err := verifier.verify(ctx, cfg, answer)
+	if err != nil {
+		log.Printf("校验最终回答失败: %v", err)
+		notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.VerifyFailed, err))
+		return
+	}
+
+	if !verification.Passed() {
+		notify.OnMessage(messages.WarningPrefix + fmt.Sprintf(messages.UnsupportedClaims, strings.Join(verification.Unsupported, "; ")))
+	}
+	if verificationNotify, ok := notify.(VerificationNotify); ok {
+		verificationNotify.OnVerification(verification)
+	}
+}
+
+// evidenceTool records the results of the wrapped tool for the verification of the answer
+type evidenceTool struct {
+	tool.InvokableTool
+}
+
+// withEvidenceCapture wraps the invokable tools with evidenceTool when cfg
+// enables agent.verify_result
+func withEvidenceCapture(cfg *config.Config, tools []tool.BaseTool) []tool.BaseTool {
+	if cfg == nil || !cfg.Agent.VerifyResult {
+		return tools
+	}
+
+	wrapped := make([]tool.BaseTool, 0, len(tools))
+	for _, t := range tools {
+		if invokable, ok := t.(tool.InvokableTool); ok {
+			t = &evidenceTool{InvokableTool: invokable}
+		}
+		wrapped = append(wrapped, t)
+	}
+	return wrapped
+}
+
+// InvokableRun runs the wrapped tool and records its result in the
+// resultVerifier of the task. Failed calls are not evidence.
+func (t *evidenceTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	result, err := t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	if err != nil {
+		return result, err
+	}
+
+	if verifier := resultVerifierFrom(ctx); verifier != nil {
+		toolName := "unknown"
+		if info, infoErr := t.Info(ctx); infoErr == nil && info != nil {
+			toolName = info.Name
+		}
+		verifier.record(toolName, result)
+	}
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package mcpagent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// verificationRecordingNotify 记录校验结果和结果的通知顺序
+type verificationRecordingNotify struct {
+	*MockNotify
+	order         []string
+	verifications []Verification
+}
+
+func (n *verificationRecordingNotify) OnVerification(verification Verification) {
+	n.order = append(n.order, "verification")
+	n.verifications = append(n.verifications, verification)
+}
+
+func (n *verificationRecordingNotify) OnResult(msg string) {
+	n.order = append(n.order, "result")
+	n.MockNotify.OnResult(msg)
+}
+
+// runVerifiedTask 执行一次调用echo后回答的任务，verdict 是校验模型的回复
+func runVerifiedTask(t *testing.T, verdict string, verifyErr error, notify Notify) []*schema.Message {
+	t.Helper()
+	return runVerifiedTaskWith(t, verdict, verifyErr, func(agent *Agent) {
+		require.NoError(t, agent.Execute(context.Background(), "检查example.com", notify))
+	})
+}
+
+// runVerifiedTaskWith 与runVerifiedTask相同，由execute执行任务
+func runVerifiedTaskWith(t *testing.T, verdict string, verifyErr error, execute func(agent *Agent)) []*schema.Message {
+	t.Helper()
+	toolCallMsg := schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_1", Type: "function", Function: schema.FunctionCall{Name: "echo", Arguments: `{"host":"example.com"}`}},
+	})
+	var verifierInput []*schema.Message
+	mockModel := new(MockToolCallingChatModel)
+	mockModel.On("WithTools", mock.Anything).Return(mockModel, nil)
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(toolCallMsg, nil).Once()
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Return(schema.AssistantMessage("example.com 开放了80端口，运行nginx", nil), nil).Once()
+	mockModel.On("Generate", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		verifierInput = args.Get(1).([]*schema.Message)
+	}).Return(schema.AssistantMessage(verdict, nil), verifyErr).Once()
+
+	mockConfig := newLifecycleMockConfig(mockModel, func() {})
+	mockConfig.Config.Agent.VerifyResult = true
+	agent, err := newAgent(context.Background(), &mockConfig.Config, mockConfig)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	execute(agent)
+	mockModel.AssertExpectations(t)
+	return verifierInput
+}
+
+func TestVerifyResultPassed(t *testing.T) {
+	notify := &verificationRecordingNotify{MockNotify: newResultNotify()}
+	input := runVerifiedTask(t, "```json\n{\"supported\": [\"开放了80端口\", \" \"], \"unsupported\": []}\n```", nil, notify)
+
+	// 校验请求包含最终回答和工具结果
+	require.Len(t, input, 2)
+	assert.Contains(t, input[0].Content, "逐条列出回答中的事实性结论")
+	assert.Contains(t, input[0].Content, `[1] echo:`+"\n"+`echo:{"host":"example.com"}`)
+	assert.Equal(t, "example.com 开放了80端口，运行nginx", input[1].Content)
+
+	assert.Equal(t, []string{"verification", "result"}, notify.order)
+	require.Len(t, notify.verifications, 1)
+	assert.True(t, notify.verifications[0].Passed())
+	assert.Equal(t, []string{"开放了80端口"}, notify.verifications[0].Supported)
+	for _, call := range notify.Calls {
+		if call.Method == "OnMessage" {
+			assert.NotContains(t, call.Arguments.String(0), "没有工具结果支持")
+		}
+	}
+}
+
+func TestVerifyResultFlagsUnsupportedClaims(t *testing.T) {
+	notify := &verificationRecordingNotify{MockNotify: newResultNotify()}
+	runVerifiedTask(t, `核查结果：{"supported": ["开放了80端口"], "unsupported": ["运行nginx"]}`, nil, notify)
+
+	require.Len(t, notify.verifications, 1)
+	assert.False(t, notify.verifications[0].Passed())
+	assert.Equal(t, []string{"运行nginx"}, notify.verifications[0].Unsupported)
+	notify.AssertCalled(t, "OnMessage", "警告: 以下结论没有工具结果支持: 运行nginx")
+	// 校验不改变结果本身
+	notify.AssertCalled(t, "OnResult", "example.com 开放了80端口，运行nginx")
+}
+
+func TestVerifyResultFailureKeepsResult(t *testing.T) {
+	notify := &verificationRecordingNotify{MockNotify: newResultNotify()}
+	runVerifiedTask(t, "", errors.New("模型不可用"), notify)
+
+	assert.Empty(t, notify.verifications)
+	assert.Equal(t, []string{"result"}, notify.order)
+	notify.AssertCalled(t, "OnMessage", "警告: 校验最终回答失败: 模型不可用")
+}
+
+func TestResultVerifierDigest(t *testing.T) {
+	verifier := &resultVerifier{}
+	assert.Equal(t, "（本次任务没有工具结果）", verifier.digest(nil))
+
+	verifier.record("fetch", strings.Repeat("a", maxEvidenceResultLen+10))
+	for i := 0; i < maxEvidenceLen/maxEvidenceResultLen+2; i++ {
+		verifier.record("search", strings.Repeat("b", maxEvidenceResultLen))
+	}
+	digest := verifier.digest(nil)
+	assert.Contains(t, digest, "[1] fetch:\n"+strings.Repeat("a", maxEvidenceResultLen)+truncateSuffix)
+	assert.NotContains(t, digest, "a"+strings.Repeat("a", maxEvidenceResultLen))
+	assert.True(t, strings.HasSuffix(digest, "个工具结果"), digest)
+}
+
+func TestParseVerification(t *testing.T) {
+	_, err := parseVerification("没有JSON")
+	assert.Error(t, err)
+	_, err = parseVerification(`{"supported": [], "unsupported": [""]}`)
+	assert.Error(t, err, "没有任何结论时视为校验失败")
+}
@@ -0,0 +1,318 @@
+// Package mcphost connects to MCP (Model Context Protocol) servers and provides
+// their tools to the Eino framework.
+//
+// The package supports:
+//   - JSON-based configuration files
+//   - Multiple transport types (stdio, SSE, streamable HTTP)
+//   - Server validation and timeout management
+//   - A connect budget per server, so a slow server does not fail the others
+//
+// Example usage:
+//
+//	settings, err := mcphost.LoadSettings("mcpservers.json")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	hub, err := mcphost.NewMCPHubFromSettings(ctx, settings)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer hub.CloseServers()
+package mcphost
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Timeout configuration constants define default and minimum timeout values
+const (
+	// DefaultMCPTimeoutSeconds is the default timeout for MCP operations in seconds
+	DefaultMCPTimeoutSeconds = 30
+	// MinMCPTimeoutSeconds is the minimum allowed timeout for MCP operations in seconds
+	MinMCPTimeoutSeconds = 5
+	// DefaultConnectTimeoutSeconds is the default time to connect to a single server
+	// in seconds. It is longer than DefaultMCPTimeoutSeconds since starting a stdio
+	// server with npx or uvx may download the package first.
+	DefaultConnectTimeoutSeconds = 60
+)
+
+// Transport type constants define supported MCP transport mechanisms
+const (
+	// TransportTypeSSE represents Server-Sent Events transport
+	TransportTypeSSE = "sse"
+	// TransportTypeStdio represents standard input/output transport
+	TransportTypeStdio = "stdio"
+	// TransportTypeHTTP represents streamable HTTP transport
+	TransportTypeHTTP = "http"
+)
+
+// Error message constants provide consistent error reporting
+const (
+	errMsgSettingsNil             = "配置不能为空"
+	errMsgTimeoutNegative         = "服务器 %s: 超时时间不能为负数"
+	errMsgTimeoutTooSmall         = "服务器 %s: 超时时间不能小于 %d 秒"
+	errMsgConnectTimeoutTooSmall  = "连接超时时间不能小于 %d 秒"
+	errMsgURLRequired             = "服务器 %s: SSE传输需要设置URL"
+	errMsgHTTPURLRequired         = "服务器 %s: HTTP传输需要设置URL"
+	errMsgHeaderNameEmpty         = "服务器 %s: 请求头名称不能为空"
+	errMsgCommandRequired         = "服务器 %s: stdio传输需要设置命令"
+	errMsgUnsupportedTransport    = "服务器 %s: 不支持的传输类型: %s"
+	errMsgFailedToParseSettings   = "解析配置失败: %w"
+	errMsgInvalidSettings         = "配置无效: %w"
+	errMsgFailedToReadSettingFile = "读取配置文件失败: %w"
+)
+
+// MCPSettings represents the main configuration structure for MCP servers.
+// It contains a map of server configurations indexed by server name, allowing
+// for multiple MCP servers to be configured and managed simultaneously.
+//
+// ConnectTimeout is the connect budget of the servers without their own
+// timeout, see ServerConfig.GetConnectTimeout.
+type MCPSettings struct {
+	MCPServers     map[string]*ServerConfig `json:"mcpServers"`
+	ConnectTimeout time.Duration            `json:"connectTimeout,omitempty"` // 连接单个服务器的超时时间（默认60s）
+}
+
+// ServerConfig represents configuration for a single MCP server.
+// It supports the SSE, streamable HTTP and stdio transport types with their
+// respective settings.
+// The configuration includes timeout management, auto-approval settings, and
+// environment variable support for stdio-based servers.
+//
+// Transport-specific fields:
+//   - SSE and HTTP transport: Requires URL field, optional Headers and TLS settings
+//   - Stdio transport: Requires Command field, optional Args, Env and Workdir
+type ServerConfig struct {
+	TransportType string        `json:"transportType,omitempty" yaml:"transport_type,omitempty" mapstructure:"transport_type"` // "sse", "http" or "stdio" (defaults to "stdio")
+	AutoApprove   []string      `json:"autoApprove,omitempty" yaml:"auto_approve,omitempty" mapstructure:"auto_approve"`       // List of auto-approved operations
+	Disabled      bool          `json:"disabled,omitempty" yaml:"disabled,omitempty" mapstructure:"disabled"`                  // Whether the server is disabled
+	Timeout       time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" mapstructure:"timeout"`                     // Timeout of connecting, of every tool call and of the HTTP requests of SSE and HTTP servers (defaults to 30s for tool calls)
+
+	// SSE and HTTP specific configuration
+	URL     string            `json:"url,omitempty" yaml:"url,omitempty" mapstructure:"url"`             // Server URL for SSE and HTTP transport
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty" mapstructure:"headers"` // HTTP headers sent with every request, e.g. Authorization
+
+	// TLS configuration of SSE and HTTP servers using HTTPS, see TLSOptions
+	CACertFile         string `json:"caCertFile,omitempty" yaml:"ca_cert_file,omitempty" mapstructure:"ca_cert_file"`                         // CA certificate trusted in addition to the system pool
+	ClientCertFile     string `json:"clientCertFile,omitempty" yaml:"client_cert_file,omitempty" mapstructure:"client_cert_file"`             // Client certificate for mutual TLS, requires ClientKeyFile
+	ClientKeyFile      string `json:"clientKeyFile,omitempty" yaml:"client_key_file,omitempty" mapstructure:"client_key_file"`                // Client key for mutual TLS
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty" yaml:"insecure_skip_verify,omitempty" mapstructure:"insecure_skip_verify"` // Skip verifying the server certificate, for testing only
+
+	// Stdio specific configuration
+	Command string            `json:"command" yaml:"command" mapstructure:"command"`                     // Command to execute for stdio transport
+	Args    []string          `json:"args" yaml:"args" mapstructure:"args"`                              // Command arguments
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty" mapstructure:"env"`             // Environment variables
+	Workdir string            `json:"workdir,omitempty" yaml:"workdir,omitempty" mapstructure:"workdir"` // Working directory of the process, defaults to the current directory
+}
+
+// GetTimeoutDuration returns the timeout duration for the server.
+// If no timeout is configured, it returns the default timeout.
+//
+// Returns:
+//   - time.Duration: The timeout duration, either configured or default
+func (c *ServerConfig) GetTimeoutDuration() time.Duration {
+	if c.Timeout == 0 {
+		return time.Duration(DefaultMCPTimeoutSeconds) * time.Second
+	}
+	return c.Timeout
+}
+
+// GetConnectTimeout returns the time the server may take to start, answer the
+// initialize request and list its tools: the timeout of the server if set,
+// otherwise the connect timeout of the settings, otherwise
+// DefaultConnectTimeoutSeconds.
+//
+// Parameters:
+//   - settingsTimeout: ConnectTimeout of the settings containing the server
+//
+// Returns:
+//   - time.Duration: Connect budget of the server
+func (c *ServerConfig) GetConnectTimeout(settingsTimeout time.Duration) time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	if settingsTimeout > 0 {
+		return settingsTimeout
+	}
+	return time.Duration(DefaultConnectTimeoutSeconds) * time.Second
+}
+
+// IsSSETransport returns true if the server uses SSE transport.
+//
+// Returns:
+//   - bool: true if the server uses SSE transport, false otherwise
+func (c *ServerConfig) IsSSETransport() bool {
+	return c.TransportType == TransportTypeSSE
+}
+
+// IsHTTPTransport returns true if the server uses streamable HTTP transport.
+//
+// Returns:
+//   - bool: true if the server uses streamable HTTP transport, false otherwise
+func (c *ServerConfig) IsHTTPTransport() bool {
+	return c.TransportType == TransportTypeHTTP
+}
+
+// IsStdioTransport returns true if the server uses stdio transport.
+// This includes both explicitly configured stdio transport and the default
+// case where no transport type is specified (defaults to stdio).
+//
+// Returns:
+//   - bool: true if the server uses stdio transport, false otherwise
+func (c *ServerConfig) IsStdioTransport() bool {
+	return c.TransportType == TransportTypeStdio || c.TransportType == ""
+}
+
+// LoadSettingsFromString loads MCP settings from a JSON configuration string.
+// It parses the JSON and validates the resulting configuration to ensure
+// all required fields are present and valid.
+//
+// The function handles empty strings gracefully by returning an empty but
+// valid configuration, making it suitable for optional configuration scenarios.
+//
+// Parameters:
+//   - data: JSON configuration string containing MCP server settings
+//
+// Returns:
+//   - *MCPSettings: Parsed and validated settings ready for use
+//   - error: Error if parsing or validation fails
+func LoadSettingsFromString(data string) (*MCPSettings, error) {
+	dataStr := strings.TrimSpace(data)
+	if dataStr == "" {
+		return &MCPSettings{MCPServers: make(map[string]*ServerConfig)}, nil
+	}
+
+	var settings MCPSettings
+	if err := json.Unmarshal([]byte(dataStr), &settings); err != nil {
+		return nil, fmt.Errorf(errMsgFailedToParseSettings, err)
+	}
+
+	if err := validateSettings(&settings); err != nil {
+		return nil, fmt.Errorf(errMsgInvalidSettings, err)
+	}
+
+	return &settings, nil
+}
+
+// LoadSettings loads MCP settings from a configuration file.
+// It reads the file and delegates to LoadSettingsFromString for parsing.
+//
+// Parameters:
+//   - path: Path to the configuration file (JSON format expected)
+//
+// Returns:
+//   - *MCPSettings: Parsed and validated settings from the file
+//   - error: Error if file reading, parsing, or validation fails
+func LoadSettings(path string) (*MCPSettings, error) {
+	log.Printf("加载MCP配置文件: %s", path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgFailedToReadSettingFile, err)
+	}
+
+	return LoadSettingsFromString(string(data))
+}
+
+// validateSettings validates the MCP settings configuration and every server
+// in it. The function initializes the MCPServers map if it's nil, ensuring
+// the configuration is always in a valid state after validation.
+//
+// Parameters:
+//   - settings: The settings to validate (will be modified if MCPServers is nil)
+//
+// Returns:
+//   - error: Validation error if any configuration is invalid, nil otherwise
+func validateSettings(settings *MCPSettings) error {
+	if settings == nil {
+		return fmt.Errorf(errMsgSettingsNil)
+	}
+
+	if settings.MCPServers == nil {
+		settings.MCPServers = make(map[string]*ServerConfig)
+	}
+
+	if settings.ConnectTimeout > 0 && settings.ConnectTimeout < time.Duration(MinMCPTimeoutSeconds)*time.Second {
+		return fmt.Errorf(errMsgConnectTimeoutTooSmall, MinMCPTimeoutSeconds)
+	}
+
+	for name, server := range settings.MCPServers {
+		if err := validateServerConfig(name, server); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateServerConfig validates a single server configuration.
+// A server without transport type gets sse when it has a URL and stdio when
+// it has a command.
+//
+// Validation rules:
+//   - Timeout must not be negative and at least MinMCPTimeoutSeconds if specified
+//   - SSE and HTTP transport require a non-empty URL
+//   - Header names must not be empty
+//   - The client certificate and key of SSE and HTTP servers are set together
+//   - Stdio transport requires a non-empty Command
+//   - Unknown transport types are rejected
+//
+// Parameters:
+//   - name: Server name for error reporting
+//   - server: Server configuration to validate
+//
+// Returns:
+//   - error: Validation error if configuration is invalid, nil otherwise
+func validateServerConfig(name string, server *ServerConfig) error {
+	if server.Timeout < 0 {
+		return fmt.Errorf(errMsgTimeoutNegative, name)
+	}
+	if server.Timeout > 0 && server.Timeout < time.Duration(MinMCPTimeoutSeconds)*time.Second {
+		return fmt.Errorf(errMsgTimeoutTooSmall, name, MinMCPTimeoutSeconds)
+	}
+
+	for header := range server.Headers {
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf(errMsgHeaderNameEmpty, name)
+		}
+	}
+
+	switch server.TransportType {
+	case TransportTypeSSE:
+		if strings.TrimSpace(server.URL) == "" {
+			return fmt.Errorf(errMsgURLRequired, name)
+		}
+	case TransportTypeHTTP:
+		if strings.TrimSpace(server.URL) == "" {
+			return fmt.Errorf(errMsgHTTPURLRequired, name)
+		}
+	case TransportTypeStdio:
+		if strings.TrimSpace(server.Command) == "" {
+			return fmt.Errorf(errMsgCommandRequired, name)
+		}
+	case "":
+		if strings.TrimSpace(server.URL) != "" {
+			server.TransportType = TransportTypeSSE
+		} else if strings.TrimSpace(server.Command) != "" {
+			server.TransportType = TransportTypeStdio
+		} else {
+			return fmt.Errorf(errMsgUnsupportedTransport, name, server.TransportType)
+		}
+	default:
+		return fmt.Errorf(errMsgUnsupportedTransport, name, server.TransportType)
+	}
+
+	if server.IsSSETransport() || server.IsHTTPTransport() {
+		if err := server.TLSOptions().Validate(); err != nil {
+			return fmt.Errorf(errMsgServerTLS, name, err)
+		}
+	}
+
+	return nil
+}
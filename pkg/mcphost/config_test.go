@@ -0,0 +1,68 @@
+package mcphost
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSettingsFromString(t *testing.T) {
+	settings, err := LoadSettingsFromString("")
+	require.NoError(t, err)
+	assert.Empty(t, settings.MCPServers)
+
+	settings, err = LoadSettingsFromString(`{"mcpServers": {
+		"fetch": {"command": "uvx", "args": ["mcp-server-fetch"]},
+		"remote": {"url": "http://127.0.0.1:3000/sse"}
+	}, "connectTimeout": 120000000000}`)
+	require.NoError(t, err)
+	// 未设置传输类型时按URL或命令推断
+	assert.Equal(t, TransportTypeStdio, settings.MCPServers["fetch"].TransportType)
+	assert.Equal(t, TransportTypeSSE, settings.MCPServers["remote"].TransportType)
+	assert.Equal(t, 2*time.Minute, settings.ConnectTimeout)
+}
+
+func TestLoadSettingsFromStringInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"JSON格式错误", `{"mcpServers": `},
+		{"超时时间过短", `{"mcpServers": {"fetch": {"command": "uvx", "timeout": 1000000000}}}`},
+		{"超时时间为负数", `{"mcpServers": {"fetch": {"command": "uvx", "timeout": -1000000000}}}`},
+		{"连接超时时间过短", `{"mcpServers": {}, "connectTimeout": 1000000000}`},
+		{"SSE缺少URL", `{"mcpServers": {"remote": {"transportType": "sse", "command": ""}}}`},
+		{"HTTP缺少URL", `{"mcpServers": {"remote": {"transportType": "http", "url": " "}}}`},
+		{"stdio缺少命令", `{"mcpServers": {"fetch": {"transportType": "stdio", "command": " "}}}`},
+		{"无法推断传输类型", `{"mcpServers": {"empty": {"command": ""}}}`},
+		{"不支持的传输类型", `{"mcpServers": {"ws": {"transportType": "ws", "url": "ws://127.0.0.1"}}}`},
+		{"请求头名称为空", `{"mcpServers": {"remote": {"url": "http://127.0.0.1/sse", "headers": {" ": "x"}}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSettingsFromString(tt.data)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGetConnectTimeout(t *testing.T) {
+	tests := []struct {
+		name            string
+		timeout         time.Duration
+		settingsTimeout time.Duration
+		want            time.Duration
+	}{
+		{"默认60秒", 0, 0, time.Duration(DefaultConnectTimeoutSeconds) * time.Second},
+		{"使用全局连接超时", 0, 2 * time.Minute, 2 * time.Minute},
+		{"服务器的timeout优先", 10 * time.Second, 2 * time.Minute, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &ServerConfig{Command: "uvx", Timeout: tt.timeout}
+			assert.Equal(t, tt.want, server.GetConnectTimeout(tt.settingsTimeout))
+		})
+	}
+}
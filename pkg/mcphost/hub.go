@@ -0,0 +1,666 @@
+package mcphost
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Error message constants
+const (
+	errMsgUnknownError = "unknown error"
+)
+
+// Tool call constants
+const (
+	// pingTimeout is the time a server has to answer the ping before a tool call
+	pingTimeout = 1 * time.Second
+	// callRetries is the number of retries of a tool call failing with a transport error
+	callRetries = 1
+	// retryDelay is the pause before retrying a tool call
+	retryDelay = 100 * time.Millisecond
+)
+
+// closeGracePeriod is the time a stdio server has to exit after its stdin is
+// closed before its process group is killed. It is shorter than the grace
+// period of an interrupted CLI task, so the hub kills its servers first.
+var closeGracePeriod = 3 * time.Second
+
+// innerServerName is the name of the built-in tools, which are not an MCP server
+const innerServerName = "inner"
+
+// MCPHub manages connections to multiple MCP servers and provides unified tool access.
+// It maintains the connections and provides thread-safe access to their tools
+// through the Eino framework.
+type MCPHub struct {
+	mu          sync.RWMutex                  // Protects concurrent access to connections and tools
+	connections map[string]*Connection        // Active server connections indexed by server name
+	tools       map[string]tool.InvokableTool // Available tools from all servers indexed by tool key
+	config      *MCPSettings                  // Configuration settings for all servers
+}
+
+// Connection represents a connection to a single MCP server.
+// It encapsulates the client instance and its configuration.
+type Connection struct {
+	Client client.MCPClient      // MCP client instance for communication
+	Config *ServerConfig         // Server configuration used to establish the connection
+	Info   *mcp.InitializeResult // Result of the initialize request of the server
+
+	tools   []mcp.Tool         // Tools listed by the server while connecting
+	cancel  context.CancelFunc // Ends the lifetime of the transport, which kills a stdio process group
+	process *stdioProcess      // Process of a stdio server, nil for SSE and HTTP servers
+}
+
+// close closes the client and ends the lifetime context of the transport.
+// A stdio server that does not exit within closeGracePeriod is killed with
+// its process group, the processes left in the group after the server
+// exited are killed as well.
+func (c *Connection) close() error {
+	closed := make(chan error, 1)
+	go func() { closed <- c.Client.Close() }()
+
+	var err error
+	select {
+	case err = <-closed:
+	case <-time.After(closeGracePeriod):
+		log.Printf("MCP服务器在 %s 内没有退出，结束其进程组", closeGracePeriod)
+		c.cancel()
+		err = <-closed
+	}
+	c.cancel()
+	c.process.kill()
+	return err
+}
+
+// NewMCPHub creates a new MCPHub from a configuration file.
+//
+// Parameters:
+//   - ctx: Context bounding the connection of all servers
+//   - configPath: Path to the configuration file
+//
+// Returns:
+//   - *MCPHub: Initialized MCPHub instance
+//   - error: Error if initialization fails
+func NewMCPHub(ctx context.Context, configPath string) (*MCPHub, error) {
+	settings, err := LoadSettings(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置文件失败: %w", err)
+	}
+	return NewMCPHubFromSettings(ctx, settings)
+}
+
+// NewMCPHubFromString creates a new MCPHub from a JSON configuration string.
+//
+// Parameters:
+//   - ctx: Context bounding the connection of all servers
+//   - config: JSON configuration string containing MCP server settings
+//
+// Returns:
+//   - *MCPHub: Initialized MCPHub instance
+//   - error: Error if initialization fails
+func NewMCPHubFromString(ctx context.Context, config string) (*MCPHub, error) {
+	settings, err := LoadSettingsFromString(config)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置字符串失败: %w", err)
+	}
+	return NewMCPHubFromSettings(ctx, settings)
+}
+
+// NewMCPHubFromSettings creates a new MCPHub directly from MCPSettings.
+//
+// The servers are connected in parallel, each with its own connect budget
+// (see ServerConfig.GetConnectTimeout) bounded by ctx, so a server that hangs
+// while starting does not use up the time of the others. When a server
+// fails, the connected servers are closed again and the error names every
+// failed server; a server exceeding its budget fails with an error wrapping
+// context.DeadlineExceeded.
+//
+// Parameters:
+//   - ctx: Context bounding the connection of all servers
+//   - settings: MCPSettings containing server configurations
+//
+// Returns:
+//   - *MCPHub: Initialized MCPHub instance
+//   - error: Error if initialization fails
+func NewMCPHubFromSettings(ctx context.Context, settings *MCPSettings) (*MCPHub, error) {
+	h := &MCPHub{
+		connections: make(map[string]*Connection),
+		tools:       make(map[string]tool.InvokableTool),
+		config:      settings,
+	}
+
+	if err := h.initializeServers(ctx); err != nil {
+		return nil, fmt.Errorf("初始化服务器失败: %w", err)
+	}
+
+	return h, nil
+}
+
+// initializeServers connects all enabled MCP servers in parallel and
+// registers their tools. On failure no connection is left open.
+func (h *MCPHub) initializeServers(ctx context.Context) error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[string]error)
+	)
+
+	for name, config := range h.config.MCPServers {
+		if name == innerServerName {
+			log.Printf("跳过内置工具服务器: %s", name)
+			continue
+		}
+
+		if config.Disabled {
+			log.Printf("跳过已禁用的服务器: %s", name)
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, config *ServerConfig) {
+			defer wg.Done()
+			conn, err := connectToServer(ctx, name, config, h.config.ConnectTimeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[name] = fmt.Errorf("连接服务器 %s 失败: %w", name, err)
+				return
+			}
+			h.connections[name] = conn
+		}(name, config)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 && ctx.Err() == nil {
+		for name, conn := range h.connections {
+			if err := h.registerTools(name, conn); err != nil {
+				errs[name] = fmt.Errorf("发现服务器 %s 的工具失败: %w", name, err)
+				break
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		// 连接期间父context被取消时不保留任何连接
+		if err := ctx.Err(); err != nil {
+			h.closeConnections()
+			return err
+		}
+		return nil
+	}
+
+	h.closeConnections()
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	joined := make([]error, 0, len(names))
+	for _, name := range names {
+		joined = append(joined, errs[name])
+	}
+	return errors.Join(joined...)
+}
+
+// closeConnections closes every connection of the hub and clears its tools
+func (h *MCPHub) closeConnections() []error {
+	var errs []error
+	for name, conn := range h.connections {
+		if err := conn.close(); err != nil {
+			errs = append(errs, fmt.Errorf("关闭服务器 %s 失败: %w", name, err))
+		}
+	}
+	h.connections = make(map[string]*Connection)
+	h.tools = make(map[string]tool.InvokableTool)
+	return errs
+}
+
+// connectToServer establishes the connection to a single MCP server within
+// its connect budget.
+//
+// The transport lives in its own context, which is canceled when the budget
+// runs out or ctx is canceled before the server answered. This aborts a
+// pending SSE request and kills a stdio process that is still starting, so
+// no half-open client is left behind.
+//
+// Parameters:
+//   - ctx: Context of the hub initialization
+//   - serverName: Unique name identifier for the server
+//   - config: Server configuration including transport and connection details
+//   - connectTimeout: ConnectTimeout of the settings, see ServerConfig.GetConnectTimeout
+//
+// Returns:
+//   - *Connection: Initialized connection with the tools of the server
+//   - error: Error if connection establishment fails at any step
+func connectToServer(ctx context.Context, serverName string, config *ServerConfig, connectTimeout time.Duration) (*Connection, error) {
+	log.Printf("正在连接到MCP服务器: %s", serverName)
+
+	budget := config.GetConnectTimeout(connectTimeout)
+	connectCtx, cancelConnect := context.WithTimeout(ctx, budget)
+	defer cancelConnect()
+
+	lifetime, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(connectCtx, cancel)
+
+	process := &stdioProcess{}
+	mcpClient, err := createMCPClient(serverName, config, process)
+	if err != nil {
+		stop()
+		cancel()
+		return nil, fmt.Errorf("创建MCP客户端失败: %w", err)
+	}
+
+	// fail stops the started client and reports the exceeded budget instead of the bare context error
+	fail := func(err error) error {
+		stop()
+		cancel()
+		_ = mcpClient.Close()
+		return budgetError(ctx, connectCtx, budget, err)
+	}
+
+	if err := mcpClient.Start(lifetime); err != nil {
+		stop()
+		cancel()
+		return nil, budgetError(ctx, connectCtx, budget, fmt.Errorf("启动MCP客户端失败: %w", err))
+	}
+
+	setupServerLogging(mcpClient, serverName)
+
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.Capabilities = mcp.ClientCapabilities{}
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    "mcpagent",
+		Version: "0.1.0",
+	}
+
+	initResult, err := mcpClient.Initialize(connectCtx, initRequest)
+	if err != nil {
+		return nil, fail(fmt.Errorf("初始化MCP客户端失败: %w", err))
+	}
+
+	listResults, err := mcpClient.ListTools(connectCtx, mcp.ListToolsRequest{})
+	if err != nil {
+		return nil, fail(fmt.Errorf("列出MCP工具失败: %w", err))
+	}
+
+	// 连接成功后不再随连接超时取消传输
+	if !stop() {
+		return nil, fail(context.Cause(connectCtx))
+	}
+
+	log.Printf("成功连接到MCP服务器: %s", serverName)
+	return &Connection{
+		Client:  mcpClient,
+		Config:  config,
+		Info:    initResult,
+		tools:   listResults.Tools,
+		cancel:  cancel,
+		process: process,
+	}, nil
+}
+
+// budgetError marks err as caused by the connect budget of a server when the
+// budget ran out before the parent context ended
+func budgetError(ctx, connectCtx context.Context, budget time.Duration, err error) error {
+	if errors.Is(connectCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("超过连接超时时间 %s: %w", budget, err)
+		}
+		return fmt.Errorf("超过连接超时时间 %s（%w）: %w", budget, context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+// ProbeServer connects to a single server within its connect budget and
+// closes the connection again, to find out what the server reports about
+// itself.
+//
+// Parameters:
+//   - ctx: Context bounding the connection
+//   - serverName: Name of the server, used in logs
+//   - config: Server configuration
+//
+// Returns:
+//   - *mcp.InitializeResult: Result of the initialize request of the server
+//   - error: Error if the server cannot be connected
+func ProbeServer(ctx context.Context, serverName string, config *ServerConfig) (*mcp.InitializeResult, error) {
+	conn, err := connectToServer(ctx, serverName, config, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.close()
+	return conn.Info, nil
+}
+
+// createMCPClient creates an MCP client based on the transport configuration.
+// The client is not started yet.
+//
+// Parameters:
+//   - name: Server name for error reporting
+//   - config: Server configuration containing transport type and connection details
+//   - process: Records the process of a stdio server once it is started
+//
+// Returns:
+//   - *client.Client: Configured MCP client ready to be started
+//   - error: Error if client creation fails or transport type is unsupported
+func createMCPClient(name string, config *ServerConfig, process *stdioProcess) (*client.Client, error) {
+	switch {
+	case config.IsSSETransport():
+		options := []transport.ClientOption{client.WithHeaders(config.Headers)}
+		httpClient, err := remoteHTTPClient(name, config)
+		if err != nil {
+			return nil, err
+		}
+		if httpClient != nil {
+			options = append(options, client.WithHTTPClient(httpClient))
+		}
+		return client.NewSSEMCPClient(expandURLEnv(config.URL), options...)
+	case config.IsHTTPTransport():
+		options := []transport.StreamableHTTPCOption{transport.WithHTTPHeaders(config.Headers)}
+		httpClient, err := remoteHTTPClient(name, config)
+		if err != nil {
+			return nil, err
+		}
+		if httpClient != nil {
+			options = append(options, transport.WithHTTPBasicClient(httpClient))
+		}
+		if config.Timeout > 0 {
+			// 每个请求都是完整的HTTP请求，超时时间可以限制整个请求
+			options = append(options, transport.WithHTTPTimeout(config.Timeout))
+		}
+		return client.NewStreamableHttpClient(expandURLEnv(config.URL), options...)
+	case config.IsStdioTransport():
+		stdio := transport.NewStdioWithOptions(config.Command, buildEnvironment(config.Env), config.Args,
+			transport.WithCommandFunc(process.command(config.Workdir)))
+		return client.NewClient(stdio), nil
+	default:
+		return nil, fmt.Errorf("不支持的传输类型: %s", config.TransportType)
+	}
+}
+
+// expandURLEnv replaces ${NAME} in the URL of an SSE or HTTP server with the environment variable NAME
+func expandURLEnv(url string) string {
+	for {
+		start := strings.Index(url, "${")
+		if start == -1 {
+			return url
+		}
+		end := strings.Index(url[start:], "}")
+		if end == -1 {
+			return url
+		}
+		key := url[start+2 : start+end]
+		url = url[:start] + os.Getenv(key) + url[start+end+1:]
+	}
+}
+
+// buildEnvironment converts the environment of a stdio server to "KEY=VALUE" entries
+func buildEnvironment(envMap map[string]string) []string {
+	var env []string
+	for k, v := range envMap {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	return env
+}
+
+// setupServerLogging logs the stderr output of a stdio server with the server name as prefix
+func setupServerLogging(mcpClient *client.Client, serverName string) {
+	stderr, ok := client.GetStderr(mcpClient)
+	if !ok || stderr == nil {
+		return
+	}
+
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Printf("[%s] %s", serverName, scanner.Text())
+		}
+	}()
+}
+
+// registerTools registers the tools listed by the server of conn as Eino tools.
+// The tool key is the server name and the tool name joined by "_".
+func (h *MCPHub) registerTools(serverName string, conn *Connection) error {
+	for _, mcpTool := range conn.tools {
+		inputSchema, err := convertToolSchema(mcpTool)
+		if err != nil {
+			return fmt.Errorf("注册工具 %s 失败: %w", mcpTool.Name, err)
+		}
+
+		h.tools[serverName+"_"+mcpTool.Name] = utils.NewTool(
+			&schema.ToolInfo{
+				Name:        mcpTool.Name,
+				Desc:        mcpTool.Description,
+				ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(inputSchema),
+			},
+			createToolInvoker(serverName, mcpTool.Name, conn.Client, conn.Config.GetTimeoutDuration()),
+		)
+	}
+	return nil
+}
+
+// convertToolSchema converts MCP tool input schema to OpenAPI v3 schema, which
+// Eino expects for tool parameters.
+//
+// Parameters:
+//   - mcpTool: MCP tool definition containing the input schema to convert
+//
+// Returns:
+//   - *openapi3.Schema: Converted OpenAPI v3 schema
+//   - error: Error if schema conversion fails
+func convertToolSchema(mcpTool mcp.Tool) (*openapi3.Schema, error) {
+	// fetch mcp 的bug：https://github.com/modelcontextprotocol/servers/issues/1817
+	// 标准中exclusiveMaximum和exclusiveMinimum应该是bool，实际上设置成了integer，导致报错，需要处理
+	for _, v := range mcpTool.InputSchema.Properties {
+		if values, ok := v.(map[string]any); ok {
+			delete(values, "exclusiveMaximum")
+			delete(values, "exclusiveMinimum")
+		}
+	}
+
+	marshaledInputSchema, err := json.Marshal(mcpTool.InputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("序列化工具输入模式失败: %w", err)
+	}
+
+	inputSchema := &openapi3.Schema{}
+	if err := json.Unmarshal(marshaledInputSchema, inputSchema); err != nil {
+		return nil, fmt.Errorf("反序列化工具输入模式失败: %w", err)
+	}
+
+	return inputSchema, nil
+}
+
+// createToolInvoker creates the function calling toolName on the server
+// through NewToolCaller with timeout, the timeout of the server.
+func createToolInvoker(serverName, toolName string, cli client.MCPClient, timeout time.Duration) func(ctx context.Context, params map[string]interface{}) (string, error) {
+	return func(ctx context.Context, params map[string]interface{}) (string, error) {
+		if cli == nil {
+			return "", fmt.Errorf("MCP服务器客户端为空: %s", serverName)
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := cli.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			log.Printf("MCP服务器连接不可用: %s, 错误: %v", serverName, err)
+			return "", fmt.Errorf("MCP服务器连接不可用: %s, 错误: %v", serverName, err)
+		}
+
+		req := mcp.CallToolRequest{}
+		req.Params.Name = toolName
+		req.Params.Arguments = params
+
+		callToolResult, err := NewToolCaller(serverName, cli, timeout).CallTool(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("调用工具 %s/%s 失败: %w", serverName, toolName, err)
+		}
+
+		if callToolResult.IsError {
+			errMsg := errMsgUnknownError
+			if len(callToolResult.Content) > 0 {
+				errMsg = fmt.Sprintf("%v", callToolResult.Content[0])
+			}
+			return "", fmt.Errorf("MCP: 工具调用错误: %s", errMsg)
+		}
+
+		if len(callToolResult.Content) == 0 {
+			return "", fmt.Errorf("MCP: 工具调用 %s 返回空内容", toolName)
+		}
+
+		textContent, ok := callToolResult.Content[0].(mcp.TextContent)
+		if !ok {
+			return "", fmt.Errorf("MCP: 工具调用 %s 返回不支持的内容类型: %T", toolName, callToolResult.Content[0])
+		}
+
+		return textContent.Text, nil
+	}
+}
+
+// isTransportClosed reports whether a tool call failed because the connection was closed
+func isTransportClosed(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "file already closed") ||
+		strings.Contains(msg, "transport error") ||
+		strings.Contains(msg, "failed to write request")
+}
+
+// GetClient returns the client for the specified server.
+//
+// Parameters:
+//   - serverName: Name of the server
+//
+// Returns:
+//   - client.MCPClient: MCP client for the server
+//   - error: Error if server not found or disabled
+func (h *MCPHub) GetClient(serverName string) (client.MCPClient, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	conn, exists := h.connections[serverName]
+	if !exists {
+		return nil, fmt.Errorf("未找到服务器连接: %s", serverName)
+	}
+
+	if conn.Config.Disabled {
+		return nil, fmt.Errorf("服务器已禁用: %s", serverName)
+	}
+
+	return conn.Client, nil
+}
+
+// CloseServers closes all server connections and clears the tools of the hub.
+//
+// Returns:
+//   - error: Aggregated error if any connection fails to close, nil if all succeed
+func (h *MCPHub) CloseServers() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if errs := h.closeConnections(); len(errs) > 0 {
+		return fmt.Errorf("关闭服务器时发生错误: %v", errs)
+	}
+	return nil
+}
+
+// GetEinoTools returns a list of Eino tools based on the provided tool keys.
+// If toolNameList is empty, it returns all available tools from all connected servers.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - toolNameList: List of tool keys to retrieve (empty for all tools)
+//
+// Returns:
+//   - []tool.BaseTool: List of available tools ready for Eino integration
+//   - error: Error if any requested tool is not found
+func (h *MCPHub) GetEinoTools(ctx context.Context, toolNameList []string) ([]tool.BaseTool, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var result []tool.BaseTool
+
+	if len(toolNameList) == 0 {
+		for _, t := range h.tools {
+			result = append(result, t)
+		}
+		return result, nil
+	}
+
+	for _, toolName := range toolNameList {
+		t, exists := h.tools[toolName]
+		if !exists {
+			return nil, fmt.Errorf("工具不存在: %s", toolName)
+		}
+		result = append(result, t)
+	}
+
+	return result, nil
+}
+
+// GetToolsMap returns a map of all available tools with their information.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//
+// Returns:
+//   - map[string]*schema.ToolInfo: Map of tool keys to tool information
+//   - error: Error if tool information retrieval fails
+func (h *MCPHub) GetToolsMap(ctx context.Context) (map[string]*schema.ToolInfo, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make(map[string]*schema.ToolInfo)
+	for toolKey, t := range h.tools {
+		toolInfo, err := t.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("获取工具 %s 信息失败: %w", toolKey, err)
+		}
+		result[toolKey] = toolInfo
+	}
+
+	return result, nil
+}
+
+// InvokeTool invokes a specific tool with the given arguments.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - toolName: Key of the tool to invoke (must exist in the tool registry)
+//   - arguments: Arguments to pass to the tool as key-value pairs
+//
+// Returns:
+//   - string: Tool execution result as returned by the MCP server
+//   - error: Error if tool is not found, argument serialization fails, or invocation fails
+func (h *MCPHub) InvokeTool(ctx context.Context, toolName string, arguments map[string]any) (string, error) {
+	h.mu.RLock()
+	t, exists := h.tools[toolName]
+	h.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("工具不存在: %s", toolName)
+	}
+
+	argsJSON, err := json.Marshal(arguments)
+	if err != nil {
+		return "", fmt.Errorf("序列化参数失败: %w", err)
+	}
+
+	return t.InvokableRun(ctx, string(argsJSON))
+}
@@ -0,0 +1,199 @@
+package mcphost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandURLEnv(t *testing.T) {
+	t.Setenv("MCPHOST_TEST_HOST", "127.0.0.1:3000")
+	t.Setenv("MCPHOST_TEST_KEY", "secret")
+
+	assert.Equal(t, "http://127.0.0.1:3000/sse?key=secret", expandURLEnv("http://${MCPHOST_TEST_HOST}/sse?key=${MCPHOST_TEST_KEY}"))
+	assert.Equal(t, "http://127.0.0.1/sse", expandURLEnv("http://127.0.0.1/sse"))
+	// 没有结束括号时保持原样
+	assert.Equal(t, "http://${MCPHOST_TEST_HOST/sse", expandURLEnv("http://${MCPHOST_TEST_HOST/sse"))
+}
+
+func TestBudgetError(t *testing.T) {
+	cause := fmt.Errorf("初始化MCP客户端失败: %w", errors.New("transport error"))
+
+	expired, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+	err := budgetError(context.Background(), expired, 5*time.Second, cause)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "超过连接超时时间 5s")
+	assert.Contains(t, err.Error(), "transport error")
+
+	// 父context结束时不是服务器的连接超时
+	parent, cancelParent := context.WithCancel(context.Background())
+	cancelParent()
+	assert.Equal(t, cause, budgetError(parent, expired, 5*time.Second, cause))
+}
+
+func TestNewMCPHubFromSettingsSkipsServers(t *testing.T) {
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{MCPServers: map[string]*ServerConfig{
+		"inner":    {Command: "inner"},
+		"disabled": {Command: "does-not-exist", Disabled: true},
+	}})
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	tools, err := hub.GetEinoTools(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, tools)
+
+	_, err = hub.GetEinoTools(context.Background(), []string{"disabled_search"})
+	assert.EqualError(t, err, "工具不存在: disabled_search")
+	_, err = hub.GetClient("disabled")
+	assert.Error(t, err)
+}
+
+func TestNewMCPHubFromSettingsNamesFailedServers(t *testing.T) {
+	_, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{MCPServers: map[string]*ServerConfig{
+		"missing-a": {TransportType: TransportTypeStdio, Command: "/does/not/exist/a"},
+		"missing-b": {TransportType: TransportTypeStdio, Command: "/does/not/exist/b"},
+	}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "连接服务器 missing-a 失败")
+	assert.Contains(t, err.Error(), "连接服务器 missing-b 失败")
+}
+
+// TestSSEServerHeaders 验证SSE服务器配置的请求头随每个请求发送
+func TestSSEServerHeaders(t *testing.T) {
+	mcpServer := server.NewMCPServer("headers", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("echo", mcp.WithDescription("回显")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	var mu sync.Mutex
+	received := map[string][]string{}
+	var sseServer *server.SSEServer
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.Method] = append(received[r.Method], r.Header.Get("Authorization"))
+		mu.Unlock()
+		sseServer.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	sseServer = server.NewSSEServer(mcpServer, server.WithBaseURL(ts.URL))
+
+	ctx := context.Background()
+	hub, err := NewMCPHubFromSettings(ctx, &MCPSettings{MCPServers: map[string]*ServerConfig{
+		"remote": {TransportType: TransportTypeSSE, URL: ts.URL + "/sse", Headers: map[string]string{"Authorization": "Bearer t0ken"}},
+	}})
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	result, err := hub.InvokeTool(ctx, "remote_echo", map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 建立SSE连接的GET请求和发送消息的POST请求都带有请求头
+	require.NotEmpty(t, received[http.MethodGet])
+	require.NotEmpty(t, received[http.MethodPost])
+	for method, values := range received {
+		for _, value := range values {
+			assert.Equal(t, "Bearer t0ken", value, method)
+		}
+	}
+}
+
+// TestToolInvokerTimeout 验证工具调用使用服务器的超时时间
+func TestToolInvokerTimeout(t *testing.T) {
+	mcpServer := server.NewMCPServer("slow", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("sleep", mcp.WithDescription("等待")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+		}
+		return mcp.NewToolResultText("done"), nil
+	})
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config.Handler = server.NewSSEServer(mcpServer, server.WithBaseURL("http://"+ts.Listener.Addr().String()))
+	ts.Start()
+	defer ts.Close()
+
+	ctx := context.Background()
+	hub, err := NewMCPHubFromSettings(ctx, &MCPSettings{MCPServers: map[string]*ServerConfig{
+		"slow": {TransportType: TransportTypeSSE, URL: ts.URL + "/sse", Timeout: 10 * time.Second},
+	}})
+	require.NoError(t, err)
+	defer hub.CloseServers()
+	cli, err := hub.GetClient("slow")
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = createToolInvoker("slow", "sleep", cli, 200*time.Millisecond)(ctx, map[string]any{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestSSEHTTPClientTimeout(t *testing.T) {
+	httpClient, err := remoteHTTPClient("remote", &ServerConfig{URL: "http://127.0.0.1/sse"})
+	require.NoError(t, err)
+	assert.Nil(t, httpClient, "没有TLS设置和超时时间时使用mcp-go的默认客户端")
+
+	httpClient, err = remoteHTTPClient("remote", &ServerConfig{URL: "http://127.0.0.1/sse", Timeout: 10 * time.Second})
+	require.NoError(t, err)
+	require.NotNil(t, httpClient)
+	// 超时时间只限制等待响应头，不会结束事件流
+	assert.Zero(t, httpClient.Timeout)
+	assert.Equal(t, 10*time.Second, httpClient.Transport.(*http.Transport).ResponseHeaderTimeout)
+}
+
+// TestHTTPServer 验证Streamable HTTP服务器可以连接和调用工具，请求头随每个请求发送
+func TestHTTPServer(t *testing.T) {
+	mcpServer := server.NewMCPServer("streamable", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("echo", mcp.WithDescription("回显")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	httpServer := server.NewStreamableHTTPServer(mcpServer)
+
+	var mu sync.Mutex
+	var received []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Get("Authorization"))
+		mu.Unlock()
+		httpServer.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	settings, err := LoadSettingsFromString(fmt.Sprintf(`{"mcpServers": {"remote": {"transportType": "http", "url": %q, "headers": {"Authorization": "Bearer t0ken"}, "timeout": 10000000000}}}`, ts.URL+"/mcp"))
+	require.NoError(t, err)
+	ctx := context.Background()
+	hub, err := NewMCPHubFromSettings(ctx, settings)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	details, err := hub.GetToolsDetailed(ctx)
+	require.NoError(t, err)
+	require.Len(t, details, 1)
+	assert.Equal(t, "remote", details[0].Server)
+	result, err := hub.InvokeTool(ctx, "remote_echo", map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, received)
+	for _, value := range received {
+		assert.Equal(t, "Bearer t0ken", value)
+	}
+}
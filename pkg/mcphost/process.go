@@ -0,0 +1,53 @@
+package mcphost
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+// stdioProcess records the process of a stdio server. The process runs in a
+// process group of its own, so the processes it starts, such as the server
+// started by uvx or npx, are killed together with it.
+type stdioProcess struct {
+	mu  sync.Mutex
+	cmd *exec.Cmd
+}
+
+// command returns the command factory of a stdio server. Like mcp-go it
+// starts the process with the environment of mcpagent plus env, and runs it
+// in workdir when set. Ending ctx kills the process group.
+func (p *stdioProcess) command(workdir string) transport.CommandFunc {
+	return func(ctx context.Context, command string, env []string, args []string) (*exec.Cmd, error) {
+		cmd := exec.CommandContext(ctx, command, args...)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Dir = workdir
+		setProcessGroup(cmd)
+		cmd.Cancel = func() error {
+			return killProcessGroup(cmd.Process.Pid)
+		}
+
+		p.mu.Lock()
+		p.cmd = cmd
+		p.mu.Unlock()
+		return cmd, nil
+	}
+}
+
+// kill kills the process group of the server, including the processes left
+// in it after the server itself exited. It does nothing before the process
+// was started.
+func (p *stdioProcess) kill() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil || p.cmd.Process == nil {
+		return
+	}
+	_ = killProcessGroup(p.cmd.Process.Pid)
+}
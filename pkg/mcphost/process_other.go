@@ -0,0 +1,20 @@
+//go:build !unix && !windows
+
+package mcphost
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing on platforms without process groups
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills pid only, the platform has no process groups
+func killProcessGroup(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
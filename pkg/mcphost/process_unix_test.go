@@ -0,0 +1,72 @@
+//go:build unix
+
+package mcphost
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/childproc"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startIgnoringServer starts a stdio "server" that ignores the end of its
+// stdin and starts a sleeping grandchild, like uvx starting the actual server.
+// It returns the connection and the pids of the server and the grandchild.
+func startIgnoringServer(t *testing.T) (*Connection, int, int) {
+	pidFile := filepath.Join(t.TempDir(), "sleep.pid")
+	process := &stdioProcess{}
+	stdio := transport.NewStdioWithOptions("sh", nil, []string{"-c", `sleep 300 & echo $! > "$0"; exec sleep 300`, pidFile},
+		transport.WithCommandFunc(process.command("")))
+	mcpClient := client.NewClient(stdio)
+	lifetime, cancel := context.WithCancel(context.Background())
+	require.NoError(t, mcpClient.Start(lifetime))
+	conn := &Connection{Client: mcpClient, Config: &ServerConfig{Command: "sh"}, cancel: cancel, process: process}
+	t.Cleanup(func() { cancel(); process.kill() })
+
+	var sleepPID int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			return false
+		}
+		sleepPID, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return conn, process.cmd.Process.Pid, sleepPID
+}
+
+func TestConnectionCloseKillsProcessGroup(t *testing.T) {
+	grace := closeGracePeriod
+	closeGracePeriod = 200 * time.Millisecond
+	defer func() { closeGracePeriod = grace }()
+
+	conn, serverPID, sleepPID := startIgnoringServer(t)
+	require.True(t, childproc.Alive(serverPID))
+	require.True(t, childproc.Alive(sleepPID))
+
+	// 服务器不退出时，等待宽限期后结束整个进程组
+	start := time.Now()
+	conn.close()
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Eventually(t, func() bool { return !childproc.Alive(serverPID) }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return !childproc.Alive(sleepPID) }, 5*time.Second, 10*time.Millisecond, "服务器启动的进程也被结束")
+}
+
+func TestConnectionCancelKillsProcessGroup(t *testing.T) {
+	conn, serverPID, sleepPID := startIgnoringServer(t)
+
+	// 结束传输的生命周期（如连接超时）时结束整个进程组
+	conn.cancel()
+	assert.Eventually(t, func() bool { return !childproc.Alive(serverPID) }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return !childproc.Alive(sleepPID) }, 5*time.Second, 10*time.Millisecond)
+	conn.Client.Close()
+}
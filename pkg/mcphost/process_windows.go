@@ -0,0 +1,23 @@
+//go:build windows
+
+package mcphost
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/LubyRuffy/mcpagent/pkg/childproc"
+)
+
+// setProcessGroup starts the process in a new process group, so console
+// interrupts of mcpagent are not delivered to it
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killProcessGroup terminates pid and the processes it started. Windows
+// cannot kill a process group, so the process tree is killed instead.
+func killProcessGroup(pid int) error {
+	childproc.Kill(append(childproc.Descendants(pid), pid))
+	return nil
+}
@@ -0,0 +1,132 @@
+package mcphost
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	errMsgTLSCertKeyPair    = "client_cert_file和client_key_file必须同时设置"
+	errMsgReadCACertFile    = "读取CA证书文件 %s 失败: %w"
+	errMsgCACertFileInvalid = "CA证书文件 %s 中没有有效的PEM证书"
+	errMsgLoadClientCert    = "加载客户端证书 %s 失败: %w"
+	errMsgServerTLS         = "服务器 %s: %w"
+	errMsgServerTLSConfig   = "服务器 %s: 加载TLS配置失败: %w"
+)
+
+// TLSOptions are the custom TLS settings of an HTTPS connection, used for the
+// SSE and HTTP servers and by pkg/config for the LLM endpoint
+type TLSOptions struct {
+	CACertFile         string // 在系统证书之外额外信任的CA证书（PEM）
+	ClientCertFile     string // mTLS客户端证书（PEM），需同时设置ClientKeyFile
+	ClientKeyFile      string // mTLS客户端私钥（PEM）
+	InsecureSkipVerify bool   // 不校验服务端证书，仅用于测试
+}
+
+// IsSet reports whether any custom TLS option is configured
+func (o TLSOptions) IsSet() bool {
+	return strings.TrimSpace(o.CACertFile) != "" ||
+		strings.TrimSpace(o.ClientCertFile) != "" ||
+		strings.TrimSpace(o.ClientKeyFile) != "" ||
+		o.InsecureSkipVerify
+}
+
+// Validate ensures the client certificate and key are configured together
+func (o TLSOptions) Validate() error {
+	hasCert := strings.TrimSpace(o.ClientCertFile) != ""
+	hasKey := strings.TrimSpace(o.ClientKeyFile) != ""
+	if hasCert != hasKey {
+		return errors.New(errMsgTLSCertKeyPair)
+	}
+	return nil
+}
+
+// ClientConfig builds the TLS configuration of the options. The CA
+// certificates of CACertFile are trusted in addition to the system pool,
+// ClientCertFile and ClientKeyFile are presented for mutual TLS.
+//
+// Parameters:
+//   - target: Description of the endpoint for the insecure_skip_verify warning, e.g. "LLM服务 https://..."
+//
+// Returns:
+//   - *tls.Config: TLS configuration, nil if no TLS option is configured
+//   - error: Error if a certificate file cannot be read or parsed
+func (o TLSOptions) ClientConfig(target string) (*tls.Config, error) {
+	if !o.IsSet() {
+		return nil, nil
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if path := strings.TrimSpace(o.CACertFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf(errMsgReadCACertFile, path, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf(errMsgCACertFileInvalid, path)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile := strings.TrimSpace(o.ClientCertFile); certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, strings.TrimSpace(o.ClientKeyFile))
+		if err != nil {
+			return nil, fmt.Errorf(errMsgLoadClientCert, certFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.InsecureSkipVerify {
+		log.Printf("警告: 已启用insecure_skip_verify，不会校验%s的证书，连接可能被中间人窃听或篡改，请勿在生产环境中使用", target)
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return tlsConfig, nil
+}
+
+// TLSOptions returns the custom TLS settings of an SSE or HTTP server
+func (c *ServerConfig) TLSOptions() TLSOptions {
+	return TLSOptions{
+		CACertFile:         c.CACertFile,
+		ClientCertFile:     c.ClientCertFile,
+		ClientKeyFile:      c.ClientKeyFile,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+}
+
+// remoteHTTPClient returns the HTTP client of an SSE or HTTP server with its TLS
+// settings and timeout, nil when neither is configured so mcp-go uses its
+// default client. The timeout limits the wait for the response headers of
+// every request; it is not a client timeout, which would end the event
+// stream of an SSE server. The transport is cloned from http.DefaultTransport and keeps the
+// proxy of the environment (HTTPS_PROXY etc.).
+//
+// Returns:
+//   - *http.Client: Client applying the settings, nil without TLS settings and timeout
+//   - error: Error if a certificate file cannot be read or parsed
+func remoteHTTPClient(name string, config *ServerConfig) (*http.Client, error) {
+	tlsConfig, err := config.TLSOptions().ClientConfig("MCP服务器 " + name)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgServerTLSConfig, name, err)
+	}
+	if tlsConfig == nil && config.Timeout <= 0 {
+		return nil, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.ResponseHeaderTimeout = config.Timeout
+	return &http.Client{Transport: transport}, nil
+}
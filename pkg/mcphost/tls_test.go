@@ -0,0 +1,139 @@
+package mcphost
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePEM 将PEM块写入临时目录中的文件并返回路径
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// issueClientCert 生成CA并签发客户端证书，返回CA和证书、私钥文件路径
+func issueClientCert(t *testing.T) (*x509.Certificate, string, string) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mcpagent test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "mcpagent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return ca, writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)
+}
+
+// newTLSSSEServer 启动HTTPS的SSE服务器，clientCA不为nil时要求客户端证书
+func newTLSSSEServer(t *testing.T, clientCA *x509.Certificate) (*httptest.Server, string) {
+	mcpServer := server.NewMCPServer("tls", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("echo", mcp.WithDescription("回显")), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	var sseServer *server.SSEServer
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sseServer.ServeHTTP(w, r)
+	}))
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA)
+		ts.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	sseServer = server.NewSSEServer(mcpServer, server.WithBaseURL(ts.URL))
+	return ts, writePEM(t, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+}
+
+func TestSSEServerTLS(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("配置CA后才能连接", func(t *testing.T) {
+		ts, caFile := newTLSSSEServer(t, nil)
+
+		_, err := ProbeServer(ctx, "remote", &ServerConfig{TransportType: TransportTypeSSE, URL: ts.URL + "/sse"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "certificate signed by unknown authority")
+
+		info, err := ProbeServer(ctx, "remote", &ServerConfig{TransportType: TransportTypeSSE, URL: ts.URL + "/sse", CACertFile: caFile})
+		require.NoError(t, err)
+		assert.Equal(t, "tls", info.ServerInfo.Name)
+	})
+
+	t.Run("不校验证书", func(t *testing.T) {
+		ts, _ := newTLSSSEServer(t, nil)
+		_, err := ProbeServer(ctx, "remote", &ServerConfig{TransportType: TransportTypeSSE, URL: ts.URL + "/sse", InsecureSkipVerify: true})
+		require.NoError(t, err)
+	})
+
+	t.Run("双向TLS", func(t *testing.T) {
+		clientCA, certFile, keyFile := issueClientCert(t)
+		ts, caFile := newTLSSSEServer(t, clientCA)
+
+		_, err := ProbeServer(ctx, "remote", &ServerConfig{TransportType: TransportTypeSSE, URL: ts.URL + "/sse", CACertFile: caFile})
+		require.Error(t, err, "没有客户端证书时服务器拒绝连接")
+
+		_, err = ProbeServer(ctx, "remote", &ServerConfig{TransportType: TransportTypeSSE, URL: ts.URL + "/sse",
+			CACertFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile})
+		require.NoError(t, err)
+	})
+
+	t.Run("证书文件无效", func(t *testing.T) {
+		invalid := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(invalid, []byte("not a certificate"), 0600))
+		_, err := ProbeServer(ctx, "remote", &ServerConfig{TransportType: TransportTypeSSE, URL: "https://127.0.0.1:1/sse", CACertFile: invalid})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "服务器 remote: 加载TLS配置失败")
+		assert.Contains(t, err.Error(), "没有有效的PEM证书")
+	})
+}
+
+func TestValidateServerConfigTLS(t *testing.T) {
+	_, err := LoadSettingsFromString(`{"mcpServers": {"remote": {"url": "https://example.com/sse", "clientCertFile": "client.pem"}}}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "服务器 remote: client_cert_file和client_key_file必须同时设置")
+
+	_, err = LoadSettingsFromString(`{"mcpServers": {"remote": {"url": "https://example.com/sse", "clientCertFile": "client.pem", "clientKeyFile": "client.key"}}}`)
+	require.NoError(t, err)
+}
@@ -0,0 +1,61 @@
+package mcphost
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolCaller calls tools on an MCP server; client.MCPClient implements it
+type ToolCaller interface {
+	CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+}
+
+// toolCaller applies the timeout and the retries of the MCP hub to the tool
+// calls of one server
+type toolCaller struct {
+	serverName string
+	caller     ToolCaller
+	timeout    time.Duration
+}
+
+// NewToolCaller returns a ToolCaller calling the tools of the server
+// serverName through caller the way the invoker of the hub does: every
+// attempt may take timeout, and a call failing because the transport was
+// closed is retried once.
+//
+// Parameters:
+//   - serverName: Name of the server, used in log messages
+//   - caller: Client of the server
+//   - timeout: Timeout of one attempt, 0 to only end calls with the context of the caller
+//
+// Returns:
+//   - ToolCaller: Caller applying the timeout and the retries
+func NewToolCaller(serverName string, caller ToolCaller, timeout time.Duration) ToolCaller {
+	return &toolCaller{serverName: serverName, caller: caller, timeout: timeout}
+}
+
+// CallTool calls the tool of request, retrying it once when the transport
+// was closed. The error of the last attempt is returned unwrapped.
+func (c *toolCaller) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	for i := 0; ; i++ {
+		result, err := c.callOnce(ctx, request)
+		if err == nil || i >= callRetries || !isTransportClosed(err) || ctx.Err() != nil {
+			return result, err
+		}
+		log.Printf("工具调用出错 %s/%s: %v, 正在重试...", c.serverName, request.Params.Name, err)
+		time.Sleep(retryDelay)
+	}
+}
+
+// callOnce calls the tool once within the timeout of one attempt
+func (c *toolCaller) callOnce(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return c.caller.CallTool(ctx, request)
+}
@@ -0,0 +1,58 @@
+package mcphost
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyCaller fails the first calls with errs and records the deadline of every call
+type flakyCaller struct {
+	errs      []error
+	deadlines []bool
+	remaining []time.Duration
+}
+
+func (c *flakyCaller) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	deadline, ok := ctx.Deadline()
+	c.deadlines = append(c.deadlines, ok)
+	c.remaining = append(c.remaining, time.Until(deadline))
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return nil, err
+	}
+	return mcp.NewToolResultText("完成"), nil
+}
+
+func TestToolCallerTimeout(t *testing.T) {
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "crawl"
+
+	// 每次调用使用自己的超时，连接断开的调用重试一次
+	caller := &flakyCaller{errs: []error{errors.New("transport error: broken pipe")}}
+	result, err := NewToolCaller("crawler", caller, 10*time.Minute).CallTool(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "完成", result.Content[0].(mcp.TextContent).Text)
+	assert.Equal(t, []bool{true, true}, caller.deadlines)
+	for _, remaining := range caller.remaining {
+		assert.Greater(t, remaining, 9*time.Minute)
+	}
+
+	// 超时为0时只使用调用方的上下文
+	caller = &flakyCaller{}
+	_, err = NewToolCaller("crawler", caller, 0).CallTool(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false}, caller.deadlines)
+
+	// 其他错误不重试
+	caller = &flakyCaller{errs: []error{errors.New("无效参数")}}
+	_, err = NewToolCaller("crawler", caller, time.Minute).CallTool(context.Background(), req)
+	assert.EqualError(t, err, "无效参数")
+	assert.Len(t, caller.deadlines, 1)
+}
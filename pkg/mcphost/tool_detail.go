@@ -0,0 +1,107 @@
+package mcphost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ToolDetail describes a tool together with the MCP server it belongs to.
+// The server name is the name the server is configured with, so it stays
+// correct when server or tool names contain underscores, which the tool keys
+// of GetToolsMap cannot distinguish.
+type ToolDetail struct {
+	Server      string         `json:"server"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema,omitempty"`
+}
+
+// GetToolsDetailed returns the tools of every connected server, built from the
+// tools each server listed while connecting instead of being parsed from the
+// tool keys. The tools are ordered by server and name.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//
+// Returns:
+//   - []ToolDetail: Tools of all connected servers
+//   - error: Error if the input schema of a tool cannot be converted
+func (h *MCPHub) GetToolsDetailed(ctx context.Context) ([]ToolDetail, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	details := make([]ToolDetail, 0, len(h.tools))
+	for serverName, conn := range h.connections {
+		for _, mcpTool := range conn.tools {
+			inputSchema, err := convertToolSchema(mcpTool)
+			if err != nil {
+				return nil, fmt.Errorf("获取工具 %s 信息失败: %w", serverName+"_"+mcpTool.Name, err)
+			}
+			details = append(details, ToolDetail{
+				Server:      serverName,
+				Name:        mcpTool.Name,
+				Description: mcpTool.Description,
+				InputSchema: ToolInputSchema(&schema.ToolInfo{
+					Name:        mcpTool.Name,
+					ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(inputSchema),
+				}),
+			})
+		}
+	}
+	sortToolDetails(details)
+	return details, nil
+}
+
+// NewToolDetails returns the tools listed from the server named server,
+// ordered by name
+func NewToolDetails(server string, tools []*schema.ToolInfo) []ToolDetail {
+	details := make([]ToolDetail, 0, len(tools))
+	for _, info := range tools {
+		if info == nil {
+			continue
+		}
+		details = append(details, ToolDetail{
+			Server:      server,
+			Name:        info.Name,
+			Description: info.Desc,
+			InputSchema: ToolInputSchema(info),
+		})
+	}
+	sortToolDetails(details)
+	return details
+}
+
+// ToolInputSchema returns the input schema of a tool as a JSON Schema map,
+// nil if the tool has no parameters or the schema cannot be converted
+func ToolInputSchema(info *schema.ToolInfo) map[string]any {
+	if info == nil || info.ParamsOneOf == nil {
+		return nil
+	}
+	openAPISchema, err := info.ParamsOneOf.ToOpenAPIV3()
+	if err != nil || openAPISchema == nil {
+		return nil
+	}
+	data, err := json.Marshal(openAPISchema)
+	if err != nil {
+		return nil
+	}
+	var inputSchema map[string]any
+	if err := json.Unmarshal(data, &inputSchema); err != nil {
+		return nil
+	}
+	return inputSchema
+}
+
+// sortToolDetails orders tools by server and name
+func sortToolDetails(details []ToolDetail) {
+	sort.Slice(details, func(i, j int) bool {
+		if details[i].Server != details[j].Server {
+			return details[i].Server < details[j].Server
+		}
+		return details[i].Name < details[j].Name
+	})
+}
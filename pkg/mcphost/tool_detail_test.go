@@ -0,0 +1,73 @@
+package mcphost
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newToolDetailServer 启动一个提供工具b_c的SSE服务器
+func newToolDetailServer(t *testing.T, name string) string {
+	t.Helper()
+	mcpServer := server.NewMCPServer(name, "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("b_c",
+		mcp.WithDescription("tool of "+name),
+		mcp.WithString("query", mcp.Required()),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(name), nil
+	})
+
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config.Handler = server.NewSSEServer(mcpServer, server.WithBaseURL("http://"+ts.Listener.Addr().String()))
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts.URL + "/sse"
+}
+
+func TestGetToolsDetailedServerNamesWithUnderscores(t *testing.T) {
+	// 两个服务器的工具键都是 a_b_b_c，工具所属的服务器来自连接时列出的工具
+	ctx := context.Background()
+	hub, err := NewMCPHubFromSettings(ctx, &MCPSettings{MCPServers: map[string]*ServerConfig{
+		"a_b": {TransportType: TransportTypeSSE, URL: newToolDetailServer(t, "a_b")},
+		"a":   {TransportType: TransportTypeSSE, URL: newToolDetailServer(t, "a")},
+	}})
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	details, err := hub.GetToolsDetailed(ctx)
+	require.NoError(t, err)
+	require.Len(t, details, 2)
+	assert.Equal(t, "a", details[0].Server)
+	assert.Equal(t, "b_c", details[0].Name)
+	assert.Equal(t, "tool of a", details[0].Description)
+	assert.Equal(t, "a_b", details[1].Server)
+	assert.Equal(t, "b_c", details[1].Name)
+	assert.Equal(t, "tool of a_b", details[1].Description)
+	assert.Equal(t, []any{"query"}, details[1].InputSchema["required"])
+}
+
+func TestNewToolDetails(t *testing.T) {
+	details := NewToolDetails("web", []*schema.ToolInfo{
+		{Name: "search", Desc: "search the web", ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"query": {Type: schema.String, Required: true},
+		})},
+		nil,
+		{Name: "fetch"},
+	})
+	require.Len(t, details, 2)
+	assert.Equal(t, "fetch", details[0].Name)
+	assert.Nil(t, details[0].InputSchema)
+	assert.Equal(t, "web", details[1].Server)
+	assert.Equal(t, []any{"query"}, details[1].InputSchema["required"])
+}
+
+func TestToolInputSchema(t *testing.T) {
+	assert.Nil(t, ToolInputSchema(nil))
+	assert.Nil(t, ToolInputSchema(&schema.ToolInfo{Name: "noop"}))
+}
@@ -0,0 +1,301 @@
+package mcppool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/internal/testmcp"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPoolWithFakeServer 使用真实的MCP stdio进程测试连接池，-short时跳过
+func TestPoolWithFakeServer(t *testing.T) {
+	binary := testmcp.Build(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	t.Run("初始化并调用工具", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		settings := testmcp.Settings("fake-init", binary, testmcp.Options{StartDelay: "300ms", StderrNoise: 3})
+
+		hub, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		defer pool.ReleaseHub(settings)
+
+		tools, err := hub.GetToolsMap(ctx)
+		require.NoError(t, err)
+		assert.Contains(t, tools, "fake-init_echo")
+		assert.Contains(t, tools, "fake-init_pid")
+
+		result, err := hub.InvokeTool(ctx, "fake-init_echo", map[string]any{"message": "你好"})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"message":"你好"}`, result)
+
+		entries := pool.Snapshot(ctx)
+		require.Len(t, entries, 1)
+		assert.True(t, entries[0].Healthy)
+	})
+
+	t.Run("复用连接", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		settings := testmcp.Settings("fake-reuse", binary, testmcp.Options{})
+
+		first, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		second, err := pool.GetHub(ctx, testmcp.Settings("fake-reuse", binary, testmcp.Options{}))
+		require.NoError(t, err)
+		assert.Same(t, first, second)
+
+		firstPID, err := first.InvokeTool(ctx, "fake-reuse_pid", map[string]any{})
+		require.NoError(t, err)
+		secondPID, err := second.InvokeTool(ctx, "fake-reuse_pid", map[string]any{})
+		require.NoError(t, err)
+		assert.Equal(t, firstPID, secondPID, "复用的连接应该使用同一个服务器进程")
+
+		entries := pool.Snapshot(ctx)
+		require.Len(t, entries, 1)
+		assert.Equal(t, 2, entries[0].RefCount)
+		pool.ReleaseHub(settings)
+		pool.ReleaseHub(settings)
+	})
+
+	t.Run("忙碌的服务器恢复后复用空闲连接", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		pool.timeout = 200 * time.Millisecond
+		pool.backoff = 500 * time.Millisecond
+		connects := countConnects(pool)
+		settings := testmcp.Settings("fake-busy", binary, testmcp.Options{SlowPings: 1, PingDelay: "300ms"})
+
+		first, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		pool.ReleaseHub(settings)
+
+		// 第一次ping超时，等待后重试成功，继续使用原来的服务器进程
+		second, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		defer pool.ReleaseHub(settings)
+		assert.Same(t, first, second)
+		assert.Equal(t, 1, *connects)
+		_, err = second.InvokeTool(ctx, "fake-busy_pid", map[string]any{})
+		require.NoError(t, err)
+	})
+
+	t.Run("使用中的连接检查失败时不关闭", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		pool.timeout = 200 * time.Millisecond
+		pool.backoff = 10 * time.Millisecond
+		connects := countConnects(pool)
+		settings := testmcp.Settings("fake-referenced", binary, testmcp.Options{SlowPings: 3, PingDelay: "1s"})
+
+		hub, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+
+		// 检查失败的使用中连接只标记为可疑
+		entries := pool.Snapshot(ctx)
+		require.Len(t, entries, 1)
+		assert.False(t, entries[0].Healthy)
+		assert.True(t, entries[0].Suspect)
+
+		// 再次使用前重新检查，两次都失败时仍共享原来的连接
+		second, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		assert.Same(t, hub, second)
+		assert.True(t, pool.entries[entryKey(settings)].suspect)
+
+		// 服务器处理完排队的ping后恢复，下一个调用者重新检查后不再可疑
+		cli, err := hub.GetClient("fake-referenced")
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			pingCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			defer cancel()
+			return cli.Ping(pingCtx) == nil
+		}, 10*time.Second, 100*time.Millisecond)
+		third, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		assert.Same(t, hub, third)
+		assert.False(t, pool.entries[entryKey(settings)].suspect)
+		assert.Equal(t, 1, *connects, "使用中的连接不会被关闭")
+
+		_, err = third.InvokeTool(ctx, "fake-referenced_pid", map[string]any{})
+		require.NoError(t, err)
+		entries = pool.Snapshot(ctx)
+		require.Len(t, entries, 1)
+		assert.Equal(t, 3, entries[0].RefCount)
+		for i := 0; i < 3; i++ {
+			pool.ReleaseHub(settings)
+		}
+	})
+
+	t.Run("空闲连接连续检查失败后重新连接", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		pool.timeout = 200 * time.Millisecond
+		pool.backoff = 10 * time.Millisecond
+		connects := countConnects(pool)
+		settings := testmcp.Settings("fake-unhealthy", binary, testmcp.Options{SlowPings: 2, PingDelay: "1s"})
+
+		first, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		pool.ReleaseHub(settings)
+
+		second, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		defer pool.ReleaseHub(settings)
+		assert.NotSame(t, first, second)
+		assert.Equal(t, 2, *connects)
+		entries := pool.Snapshot(ctx)
+		require.Len(t, entries, 1)
+		assert.Equal(t, 1, entries[0].RefCount)
+	})
+
+	t.Run("同时获取只有env不同的配置时不共享连接", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		settings := make([]*mcphost.MCPSettings, 2)
+		for i := range settings {
+			settings[i] = testmcp.Settings("fake-env", binary, testmcp.Options{})
+			settings[i].MCPServers["fake-env"].Env = map[string]string{"TOKEN": string(rune('a' + i))}
+		}
+
+		hubs := make([]*mcphost.MCPHub, len(settings))
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := range settings {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				hub, err := pool.GetHub(ctx, settings[i])
+				assert.NoError(t, err)
+				hubs[i] = hub
+			}(i)
+		}
+		close(start)
+		wg.Wait()
+		require.NotNil(t, hubs[0])
+		require.NotNil(t, hubs[1])
+		defer pool.ReleaseHub(settings[0])
+		defer pool.ReleaseHub(settings[1])
+
+		// 两个配置各自启动服务器进程
+		firstPID, err := hubs[0].InvokeTool(ctx, "fake-env_pid", map[string]any{})
+		require.NoError(t, err)
+		secondPID, err := hubs[1].InvokeTool(ctx, "fake-env_pid", map[string]any{})
+		require.NoError(t, err)
+		assert.NotEqual(t, firstPID, secondPID)
+		entries := pool.Snapshot(ctx)
+		require.Len(t, entries, 2)
+		for _, e := range entries {
+			assert.Equal(t, 1, e.RefCount)
+		}
+	})
+
+	t.Run("服务器崩溃后重新连接", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		settings := testmcp.Settings("fake-crash", binary, testmcp.Options{CrashAfter: 1})
+
+		hub, err := pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		crashedPID, err := hub.InvokeTool(ctx, "fake-crash_pid", map[string]any{})
+		require.NoError(t, err)
+
+		// 服务器在第一次调用后退出，之后的调用返回错误而不是一直等待
+		assert.Eventually(t, func() bool {
+			_, err := hub.InvokeTool(ctx, "fake-crash_pid", map[string]any{})
+			return err != nil
+		}, 10*time.Second, 100*time.Millisecond)
+		pool.ReleaseHub(settings)
+
+		// 强制关闭失效的连接后重新获取，会启动新的服务器进程
+		err = pool.ForceClose(entryKey(settings))
+		assert.NotErrorIs(t, err, ErrEntryNotFound)
+		hub, err = pool.GetHub(ctx, settings)
+		require.NoError(t, err)
+		defer pool.ReleaseHub(settings)
+		pid, err := hub.InvokeTool(ctx, "fake-crash_pid", map[string]any{})
+		require.NoError(t, err)
+		assert.NotEqual(t, crashedPID, pid)
+	})
+
+	t.Run("获取服务器信息", func(t *testing.T) {
+		info, err := ProbeServerInfo(ctx, "fake-info", testmcp.Server(binary, testmcp.Options{}))
+		require.NoError(t, err)
+		assert.Equal(t, "fakeserver", info.Name)
+		assert.Equal(t, "1.0.0", info.Version)
+		assert.Equal(t, mcp.LATEST_PROTOCOL_VERSION, info.ProtocolVersion)
+		assert.True(t, info.Latest)
+		assert.NotNil(t, info.Capabilities.Tools)
+	})
+
+	t.Run("服务器启动失败", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		settings := &mcphost.MCPSettings{MCPServers: map[string]*mcphost.ServerConfig{
+			"fake-missing": testmcp.Server(binary+"-missing", testmcp.Options{}),
+		}}
+
+		_, err := pool.GetHub(ctx, settings)
+		assert.Error(t, err)
+		assert.Empty(t, pool.Snapshot(ctx))
+	})
+}
+
+// TestConnectBudgetWithFakeServer 使用启动缓慢的MCP进程测试每个服务器独立的连接超时，-short时跳过
+func TestConnectBudgetWithFakeServer(t *testing.T) {
+	binary := testmcp.Build(t)
+
+	t.Run("慢服务器不占用其他服务器的时间", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		slow := testmcp.Server(binary, testmcp.Options{StartDelay: "5s"})
+		slow.Timeout = time.Second
+		// 共享1秒的截止时间时fast也会失败
+		fast := testmcp.Server(binary, testmcp.Options{StartDelay: "1500ms"})
+		settings := &mcphost.MCPSettings{MCPServers: map[string]*mcphost.ServerConfig{"slow": slow, "fast": fast}}
+
+		start := time.Now()
+		_, err := pool.GetHub(context.Background(), settings)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "连接服务器 slow 失败: 超过连接超时时间 1s")
+		assert.NotContains(t, err.Error(), "fast")
+		// 超时的进程被终止，不会等待它启动完成
+		assert.Less(t, time.Since(start), 4*time.Second)
+		assert.Empty(t, pool.Snapshot(context.Background()))
+	})
+
+	t.Run("并行连接多个服务器", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		settings := &mcphost.MCPSettings{
+			MCPServers: map[string]*mcphost.ServerConfig{
+				"fake-parallel-a": testmcp.Server(binary, testmcp.Options{StartDelay: "1200ms"}),
+				"fake-parallel-b": testmcp.Server(binary, testmcp.Options{StartDelay: "1200ms"}),
+			},
+			ConnectTimeout: 2 * time.Second,
+		}
+
+		hub, err := pool.GetHub(context.Background(), settings)
+		require.NoError(t, err)
+		defer pool.ReleaseHub(settings)
+		tools, err := hub.GetToolsMap(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, tools, "fake-parallel-a_pid")
+		assert.Contains(t, tools, "fake-parallel-b_pid")
+	})
+
+	t.Run("取消父context时关闭启动中的服务器", func(t *testing.T) {
+		pool := newTestPool(t, nil)
+		settings := testmcp.Settings("fake-canceled", binary, testmcp.Options{StartDelay: "5s"})
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := pool.GetHub(ctx, settings)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// 父context的截止时间不是服务器的连接超时
+		assert.NotContains(t, err.Error(), "超过连接超时时间")
+		assert.Less(t, time.Since(start), 4*time.Second)
+	})
+}
@@ -0,0 +1,449 @@
+// Package mcppool shares MCP server connections between callers using the
+// same server definitions, so running connections can be inspected and
+// closed by an administrator.
+//
+// Pool owns the hubs it creates: they are keyed by entryKey, a hash of the
+// full server definitions, and closed by Pool itself.
+package mcppool
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+const (
+	// healthCheckTimeout limits one health check of an entry
+	healthCheckTimeout = 2 * time.Second
+
+	// probeRetryBackoff is the wait before a failed health check is repeated,
+	// a stdio server busy with a large request usually answers the second one
+	probeRetryBackoff = 500 * time.Millisecond
+
+	// maxIdleTime is the idle time after which unused connections are closed
+	maxIdleTime = 30 * time.Minute
+
+	// innerServerName is the server mcphost does not connect to, its tools run in process
+	innerServerName = "inner"
+)
+
+// ErrEntryNotFound is returned by ForceClose when no entry has the given key
+var ErrEntryNotFound = errors.New("连接池中不存在该连接")
+
+// HealthProbe checks whether the servers of a hub still respond
+type HealthProbe func(ctx context.Context, hub *mcphost.MCPHub) error
+
+// HubFactory connects the servers of settings
+type HubFactory func(ctx context.Context, settings *mcphost.MCPSettings) (*mcphost.MCPHub, error)
+
+// EntrySnapshot describes one shared connection of the pool
+type EntrySnapshot struct {
+	Key        string    `json:"key"`             // 连接标识，用于强制关闭
+	Servers    []string  `json:"servers"`         // 连接的服务器名称
+	RefCount   int       `json:"ref_count"`       // 当前使用该连接的调用数
+	CreatedAt  time.Time `json:"created_at"`      // 创建时间
+	LastAccess time.Time `json:"last_access"`     // 最后访问时间
+	AgeSeconds int64     `json:"age_seconds"`     // 已存在的时间（秒）
+	Healthy    bool      `json:"healthy"`         // 健康检查是否通过
+	Suspect    bool      `json:"suspect"`         // 健康检查失败后尚未重新验证，被使用时不会关闭
+	Error      string    `json:"error,omitempty"` // 健康检查失败原因
+}
+
+// entry is a connection obtained through the pool
+type entry struct {
+	settings   *mcphost.MCPSettings
+	hub        *mcphost.MCPHub
+	servers    []string
+	refCount   int
+	createdAt  time.Time
+	lastAccess time.Time
+	suspect    bool // 健康检查失败，再次使用前重新检查
+}
+
+// Pool creates and shares the hubs of server definitions.
+//
+// A hub in use is shared as it is, an idle hub is checked before it is
+// reused and replaced when the check fails twice. Hubs idle for longer than
+// maxIdleTime are closed.
+type Pool struct {
+	connect   HubFactory
+	probe     HealthProbe
+	infoProbe InfoProbe
+	timeout   time.Duration // 一次健康检查的超时时间
+	backoff   time.Duration // 健康检查失败后重试前的等待时间
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	acquiring map[string]chan struct{} // 正在检查或创建的连接，完成后关闭
+	infos     map[string]*ServerInfo   // 按服务器名称缓存的ServerInfo
+}
+
+var (
+	defaultPool     *Pool
+	defaultPoolOnce sync.Once
+)
+
+// Default returns the pool shared by the whole process
+func Default() *Pool {
+	defaultPoolOnce.Do(func() {
+		defaultPool = New(nil)
+	})
+	return defaultPool
+}
+
+// New creates a pool connecting servers with mcphost.NewMCPHubFromSettings.
+//
+// Parameters:
+//   - probe: Health check of the hubs, nil pings the servers of the hub
+//
+// Returns:
+//   - *Pool: Pool without entries
+func New(probe HealthProbe) *Pool {
+	return &Pool{
+		connect:   mcphost.NewMCPHubFromSettings,
+		probe:     probe,
+		infoProbe: ProbeServerInfo,
+		timeout:   healthCheckTimeout,
+		backoff:   probeRetryBackoff,
+		entries:   make(map[string]*entry),
+		acquiring: make(map[string]chan struct{}),
+		infos:     make(map[string]*ServerInfo),
+	}
+}
+
+// pingServers checks a hub by pinging each of its servers, which unlike
+// listing the tools does not depend on the size of the tool list
+func pingServers(ctx context.Context, hub *mcphost.MCPHub, servers []string) error {
+	for _, name := range servers {
+		if name == innerServerName {
+			continue
+		}
+		cli, err := hub.GetClient(name)
+		if err != nil {
+			return err
+		}
+		if err := cli.Ping(ctx); err != nil {
+			return fmt.Errorf("服务器 %s 未响应: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// GetHub returns a shared hub for settings, creating the connection when needed.
+// Every successful call must be paired with ReleaseHub.
+//
+// A hub is only shared between settings with the same entryKey, that is
+// the same full server configuration. A hub in use is shared as it is; when a health check failed for it, it is
+// checked again but never closed, so the callers using it can finish. An
+// idle hub is checked before it is reused and reconnected only when the check
+// fails twice.
+func (p *Pool) GetHub(ctx context.Context, settings *mcphost.MCPSettings) (*mcphost.MCPHub, error) {
+	key := entryKey(settings)
+	p.closeExpired()
+	for {
+		p.mu.Lock()
+		if acquiring, ok := p.acquiring[key]; ok {
+			// 等待其他调用者获取的连接，避免重复连接
+			p.mu.Unlock()
+			select {
+			case <-acquiring:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		e, ok := p.entries[key]
+		if ok && e.refCount > 0 {
+			e.refCount++
+			e.lastAccess = time.Now()
+			suspect := e.suspect
+			p.mu.Unlock()
+			if suspect {
+				p.recheck(ctx, key, e)
+			}
+			return e.hub, nil
+		}
+
+		acquiring := make(chan struct{})
+		p.acquiring[key] = acquiring
+		p.mu.Unlock()
+
+		hub, err := p.acquire(ctx, key, settings, e)
+
+		p.mu.Lock()
+		delete(p.acquiring, key)
+		close(acquiring)
+		if err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+		now := time.Now()
+		e, ok = p.entries[key]
+		if !ok || e.hub != hub {
+			// 新连接，或者空闲连接不健康而重新创建了连接
+			e = &entry{settings: settings, hub: hub, servers: serverNames(settings), createdAt: now}
+			p.entries[key] = e
+		}
+		e.refCount = 1
+		e.suspect = false
+		e.lastAccess = now
+		p.mu.Unlock()
+		return hub, nil
+	}
+}
+
+// acquire returns the hub of settings for its first user. An idle entry is
+// checked and reused, it is closed and connected again when the check fails
+// twice.
+func (p *Pool) acquire(ctx context.Context, key string, settings *mcphost.MCPSettings, idle *entry) (*mcphost.MCPHub, error) {
+	if idle != nil {
+		err := p.verify(ctx, key, idle)
+		if err == nil {
+			return idle.hub, nil
+		}
+		p.closeIdle(key, idle, err)
+	}
+	return p.connect(ctx, settings)
+}
+
+// recheck checks a suspect entry that is in use. It is kept open either way,
+// the entry is verified again by the next caller while it is still suspect.
+func (p *Pool) recheck(ctx context.Context, key string, e *entry) {
+	if err := p.verify(ctx, key, e); err != nil {
+		log.Printf("MCP服务器连接仍不健康，正在使用中，暂不关闭: key=%s servers=%s error=%v", key, strings.Join(e.servers, ","), err)
+		return
+	}
+	p.mu.Lock()
+	e.suspect = false
+	p.mu.Unlock()
+	log.Printf("MCP服务器连接已恢复: key=%s servers=%s", key, strings.Join(e.servers, ","))
+}
+
+// check runs one health check of e
+func (p *Pool) check(ctx context.Context, e *entry) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	if p.probe != nil {
+		return p.probe(ctx, e.hub)
+	}
+	return pingServers(ctx, e.hub, e.servers)
+}
+
+// verify checks e and repeats a failed check once after the backoff
+func (p *Pool) verify(ctx context.Context, key string, e *entry) error {
+	err := p.check(ctx, e)
+	if err == nil {
+		return nil
+	}
+	log.Printf("MCP服务器连接健康检查失败，%s后重试: key=%s servers=%s error=%v", p.backoff, key, strings.Join(e.servers, ","), err)
+
+	timer := time.NewTimer(p.backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return err
+	}
+	return p.check(ctx, e)
+}
+
+// closeIdle closes the connection of e after it failed its health check.
+// Entries that are in use or were replaced meanwhile are left alone.
+func (p *Pool) closeIdle(key string, e *entry, cause error) {
+	p.mu.Lock()
+	if p.entries[key] != e || e.refCount > 0 {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.entries, key)
+	p.mu.Unlock()
+
+	err := e.hub.CloseServers()
+	logClose(key, e, "健康检查连续失败", cause, err)
+}
+
+// closeExpired closes the connections that have been idle for longer than maxIdleTime
+func (p *Pool) closeExpired() {
+	now := time.Now()
+	p.mu.Lock()
+	expired := make(map[string]*entry)
+	for key, e := range p.entries {
+		if e.refCount == 0 && now.Sub(e.lastAccess) > maxIdleTime {
+			delete(p.entries, key)
+			expired[key] = e
+		}
+	}
+	p.mu.Unlock()
+
+	for key, e := range expired {
+		err := e.hub.CloseServers()
+		logClose(key, e, "长时间空闲", nil, err)
+	}
+}
+
+// logClose records why the connection of e was closed
+func logClose(key string, e *entry, reason string, cause error, closeErr error) {
+	log.Printf("关闭MCP服务器连接: key=%s servers=%s reason=%s cause=%v ref_count=%d age=%s close_error=%v",
+		key, strings.Join(e.servers, ","), reason, cause, e.refCount, time.Since(e.createdAt).Round(time.Second), closeErr)
+}
+
+// ReleaseHub releases a hub obtained with GetHub. The connection stays open
+// and is closed after it has been idle for maxIdleTime.
+func (p *Pool) ReleaseHub(settings *mcphost.MCPSettings) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[entryKey(settings)]
+	if !ok || e.refCount == 0 {
+		return
+	}
+	e.refCount--
+	e.lastAccess = time.Now()
+}
+
+// Len returns the number of connections of the pool without probing them
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Snapshot returns the connections of the pool, ordered by creation time.
+// The entries are collected under the lock and probed afterwards, so slow
+// servers do not block other callers.
+//
+// Parameters:
+//   - ctx: Context for the health checks
+//
+// Returns:
+//   - []EntrySnapshot: Connections of the pool
+func (p *Pool) Snapshot(ctx context.Context) []EntrySnapshot {
+	p.closeExpired()
+	now := time.Now()
+
+	p.mu.Lock()
+	snapshots := make([]EntrySnapshot, 0, len(p.entries))
+	entries := make([]*entry, 0, len(p.entries))
+	for key, e := range p.entries {
+		snapshots = append(snapshots, EntrySnapshot{
+			Key:        key,
+			Servers:    append([]string(nil), e.servers...),
+			RefCount:   e.refCount,
+			CreatedAt:  e.createdAt,
+			LastAccess: e.lastAccess,
+			AgeSeconds: int64(now.Sub(e.createdAt).Seconds()),
+		})
+		entries = append(entries, e)
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := range snapshots {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 失败时只标记为可疑，由下一个调用者重新检查
+			err := p.check(ctx, entries[i])
+			p.mu.Lock()
+			entries[i].suspect = err != nil
+			p.mu.Unlock()
+			snapshots[i].Suspect = err != nil
+			if err != nil {
+				snapshots[i].Error = err.Error()
+				return
+			}
+			snapshots[i].Healthy = true
+		}(i)
+	}
+	wg.Wait()
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].Key < snapshots[j].Key
+		}
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return snapshots
+}
+
+// ForceClose closes the connection with key regardless of its users and
+// removes it from the pool.
+//
+// Returns:
+//   - error: ErrEntryNotFound if the key is unknown, or the error of closing the servers
+func (p *Pool) ForceClose(key string) error {
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	if ok {
+		delete(p.entries, key)
+	}
+	p.mu.Unlock()
+	if !ok {
+		return ErrEntryNotFound
+	}
+
+	err := e.hub.CloseServers()
+	logClose(key, e, "强制关闭", nil, err)
+	if err != nil {
+		return fmt.Errorf("关闭MCP服务器连接失败: %w", err)
+	}
+	return nil
+}
+
+// Shutdown closes all connections of the pool
+func (p *Pool) Shutdown() []error {
+	p.mu.Lock()
+	entries := p.entries
+	p.entries = make(map[string]*entry)
+	p.infos = make(map[string]*ServerInfo)
+	p.mu.Unlock()
+
+	var errs []error
+	for key, e := range entries {
+		if err := e.hub.CloseServers(); err != nil {
+			errs = append(errs, fmt.Errorf("关闭MCP服务器连接 %s 失败: %w", key, err))
+		}
+	}
+	return errs
+}
+
+// entryKey returns a stable identifier of settings. It hashes the canonical
+// JSON of the whole server definitions, so settings differing only in env,
+// headers, timeout or the disabled flag get their own entry; the definitions
+// are hashed because commands, URLs, env and headers may contain credentials.
+func entryKey(settings *mcphost.MCPSettings) string {
+	var servers map[string]*mcphost.ServerConfig
+	if settings != nil {
+		servers = settings.MCPServers
+	}
+	// encoding/json按键排序输出map，服务器和env的顺序不影响结果
+	data, err := json.Marshal(servers)
+	if err != nil {
+		// ServerConfig只包含可序列化的字段，不会发生
+		data = []byte(fmt.Sprintf("%#v", servers))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// serverNames returns the sorted names of the enabled servers of settings
+func serverNames(settings *mcphost.MCPSettings) []string {
+	names := make([]string, 0, len(settings.MCPServers))
+	for name, server := range settings.MCPServers {
+		if server != nil && !server.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
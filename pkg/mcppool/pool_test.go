@@ -0,0 +1,311 @@
+package mcppool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSettings 创建不会连接任何服务器的配置，mcphost跳过名为inner的服务器
+func fakeSettings(command string) *mcphost.MCPSettings {
+	return &mcphost.MCPSettings{
+		MCPServers: map[string]*mcphost.ServerConfig{
+			"inner": {Command: command},
+		},
+	}
+}
+
+func newTestPool(t *testing.T, probe HealthProbe) *Pool {
+	t.Helper()
+	pool := New(probe)
+	t.Cleanup(func() { pool.Shutdown() })
+	return pool
+}
+
+// countConnects 统计连接池创建连接的次数
+func countConnects(pool *Pool) *int {
+	connects := new(int)
+	connect := pool.connect
+	pool.connect = func(ctx context.Context, settings *mcphost.MCPSettings) (*mcphost.MCPHub, error) {
+		*connects++
+		return connect(ctx, settings)
+	}
+	return connects
+}
+
+func TestPoolRefCount(t *testing.T) {
+	pool := newTestPool(t, func(ctx context.Context, hub *mcphost.MCPHub) error { return nil })
+	ctx := context.Background()
+	settings := fakeSettings("a")
+
+	_, err := pool.GetHub(ctx, settings)
+	require.NoError(t, err)
+	_, err = pool.GetHub(ctx, settings)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, pool.Len())
+	entries := pool.Snapshot(ctx)
+	require.Len(t, entries, 1)
+	assert.Equal(t, entryKey(settings), entries[0].Key)
+	assert.Equal(t, []string{"inner"}, entries[0].Servers)
+	assert.Equal(t, 2, entries[0].RefCount)
+	assert.True(t, entries[0].Healthy)
+
+	pool.ReleaseHub(settings)
+	pool.ReleaseHub(settings)
+	pool.ReleaseHub(settings)
+	entries = pool.Snapshot(ctx)
+	require.Len(t, entries, 1)
+	assert.Equal(t, 0, entries[0].RefCount)
+}
+
+func TestPoolSnapshotHealth(t *testing.T) {
+	broken := fakeSettings("broken")
+	var brokenHub *mcphost.MCPHub
+	pool := newTestPool(t, func(ctx context.Context, hub *mcphost.MCPHub) error {
+		if hub == brokenHub {
+			return errors.New("连接已断开")
+		}
+		return nil
+	})
+	ctx := context.Background()
+
+	_, err := pool.GetHub(ctx, fakeSettings("ok"))
+	require.NoError(t, err)
+	brokenHub, err = pool.GetHub(ctx, broken)
+	require.NoError(t, err)
+
+	entries := pool.Snapshot(ctx)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		if e.Key == entryKey(broken) {
+			assert.False(t, e.Healthy)
+			assert.Equal(t, "连接已断开", e.Error)
+		} else {
+			assert.True(t, e.Healthy)
+			assert.Empty(t, e.Error)
+		}
+	}
+}
+
+func TestPoolForceClose(t *testing.T) {
+	pool := newTestPool(t, nil)
+	ctx := context.Background()
+	settings := fakeSettings("a")
+
+	hub, err := pool.GetHub(ctx, settings)
+	require.NoError(t, err)
+
+	require.NoError(t, pool.ForceClose(entryKey(settings)))
+	assert.Empty(t, pool.Snapshot(ctx))
+	assert.ErrorIs(t, pool.ForceClose(entryKey(settings)), ErrEntryNotFound)
+
+	// 关闭后重新获取时创建新的连接
+	reconnected, err := pool.GetHub(ctx, settings)
+	require.NoError(t, err)
+	assert.NotSame(t, hub, reconnected)
+}
+
+// TestPoolMultiServerSettings 验证多个服务器的配置与map顺序无关地复用、释放和关闭同一个连接
+func TestPoolMultiServerSettings(t *testing.T) {
+	pool := newTestPool(t, nil)
+	connects := countConnects(pool)
+	ctx := context.Background()
+	newSettings := func() *mcphost.MCPSettings {
+		settings := &mcphost.MCPSettings{MCPServers: map[string]*mcphost.ServerConfig{}}
+		for _, name := range []string{"inner", "x", "y", "z", "w"} {
+			settings.MCPServers[name] = &mcphost.ServerConfig{Command: name, Disabled: name != "inner"}
+		}
+		return settings
+	}
+
+	first, err := pool.GetHub(ctx, newSettings())
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		hub, err := pool.GetHub(ctx, newSettings())
+		require.NoError(t, err)
+		assert.Same(t, first, hub)
+	}
+	assert.Equal(t, 1, *connects)
+
+	// 每次释放都找到同一个连接，引用计数归零
+	for i := 0; i < 11; i++ {
+		pool.ReleaseHub(newSettings())
+	}
+	entries := pool.Snapshot(ctx)
+	require.Len(t, entries, 1)
+	assert.Zero(t, entries[0].RefCount)
+
+	require.NoError(t, pool.ForceClose(entryKey(newSettings())))
+	assert.Zero(t, pool.Len())
+}
+
+// TestPoolClosesExpiredHubs 验证长时间空闲的连接被关闭，使用中的连接保留
+func TestPoolClosesExpiredHubs(t *testing.T) {
+	pool := newTestPool(t, nil)
+	ctx := context.Background()
+	idle, busy := fakeSettings("idle"), fakeSettings("busy")
+	_, err := pool.GetHub(ctx, idle)
+	require.NoError(t, err)
+	pool.ReleaseHub(idle)
+	_, err = pool.GetHub(ctx, busy)
+	require.NoError(t, err)
+
+	for _, e := range pool.entries {
+		e.lastAccess = time.Now().Add(-maxIdleTime - time.Second)
+	}
+	entries := pool.Snapshot(ctx)
+	require.Len(t, entries, 1)
+	assert.Equal(t, entryKey(busy), entries[0].Key)
+}
+
+func TestPoolConcurrentGetHub(t *testing.T) {
+	pool := newTestPool(t, nil)
+	ctx := context.Background()
+	settings := fakeSettings("a")
+
+	const callers = 8
+	hubs := make([]*mcphost.MCPHub, callers)
+	var wg sync.WaitGroup
+	for i := range hubs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			hub, err := pool.GetHub(ctx, settings)
+			assert.NoError(t, err)
+			hubs[i] = hub
+		}(i)
+	}
+	wg.Wait()
+
+	// 同时获取的调用者共享同一个连接
+	for _, hub := range hubs {
+		assert.Same(t, hubs[0], hub)
+	}
+	entries := pool.Snapshot(ctx)
+	require.Len(t, entries, 1)
+	assert.Equal(t, callers, entries[0].RefCount)
+}
+
+func TestEntryKey(t *testing.T) {
+	a := fakeSettings("a")
+	assert.Equal(t, entryKey(a), entryKey(fakeSettings("a")))
+	assert.NotEqual(t, entryKey(a), entryKey(fakeSettings("b")))
+	assert.Len(t, entryKey(a), 16)
+}
+
+// TestEntryKeyMapOrder 验证键与服务器和env的map顺序无关
+func TestEntryKeyMapOrder(t *testing.T) {
+	names := []string{"fetch", "search", "crawler", "scanner", "filesystem", "memory"}
+	build := func(order []string) *mcphost.MCPSettings {
+		settings := &mcphost.MCPSettings{MCPServers: map[string]*mcphost.ServerConfig{}}
+		for _, name := range order {
+			env := map[string]string{}
+			for _, k := range order {
+				env["KEY_"+k] = k
+			}
+			settings.MCPServers[name] = &mcphost.ServerConfig{Command: name, Args: []string{"-v"}, Env: env}
+		}
+		return settings
+	}
+	reversed := make([]string, len(names))
+	for i, name := range names {
+		reversed[len(names)-1-i] = name
+	}
+
+	want := entryKey(build(names))
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, want, entryKey(build(names)))
+		assert.Equal(t, want, entryKey(build(reversed)))
+	}
+}
+
+// TestEntryKeyFullConfig 验证只有env、timeout、disabled或headers不同的配置使用不同的连接
+func TestEntryKeyFullConfig(t *testing.T) {
+	withEnv := func(env map[string]string) *mcphost.MCPSettings {
+		settings := fakeSettings("a")
+		settings.MCPServers["inner"].Env = env
+		return settings
+	}
+	a := withEnv(map[string]string{"TOKEN": "a", "REGION": "cn"})
+	assert.Equal(t, entryKey(a), entryKey(withEnv(map[string]string{"REGION": "cn", "TOKEN": "a"})))
+	assert.NotEqual(t, entryKey(a), entryKey(withEnv(map[string]string{"TOKEN": "b", "REGION": "cn"})))
+	assert.NotEqual(t, entryKey(a), entryKey(fakeSettings("a")))
+
+	timeout := fakeSettings("a")
+	timeout.MCPServers["inner"].Timeout = time.Minute
+	assert.NotEqual(t, entryKey(fakeSettings("a")), entryKey(timeout))
+
+	disabled := fakeSettings("a")
+	disabled.MCPServers["other"] = &mcphost.ServerConfig{Command: "b", Disabled: true}
+	assert.NotEqual(t, entryKey(fakeSettings("a")), entryKey(disabled))
+
+	withHeaders := func(token string) *mcphost.MCPSettings {
+		settings := fakeSettings("a")
+		settings.MCPServers["remote"] = &mcphost.ServerConfig{URL: "https://example.com/sse", Headers: map[string]string{"Authorization": token}}
+		return settings
+	}
+	assert.Equal(t, entryKey(withHeaders("Bearer a")), entryKey(withHeaders("Bearer a")))
+	assert.NotEqual(t, entryKey(withHeaders("Bearer a")), entryKey(withHeaders("Bearer b")))
+}
+
+// TestPoolSeparatesEnv 验证只有env不同的配置各自有一个连接
+func TestPoolSeparatesEnv(t *testing.T) {
+	pool := newTestPool(t, func(ctx context.Context, hub *mcphost.MCPHub) error { return nil })
+	ctx := context.Background()
+	a := fakeSettings("a")
+	a.MCPServers["inner"].Env = map[string]string{"TOKEN": "a"}
+	b := fakeSettings("a")
+	b.MCPServers["inner"].Env = map[string]string{"TOKEN": "b"}
+
+	_, err := pool.GetHub(ctx, a)
+	require.NoError(t, err)
+	_, err = pool.GetHub(ctx, b)
+	require.NoError(t, err)
+
+	entries := pool.Snapshot(ctx)
+	require.Len(t, entries, 2)
+	assert.ElementsMatch(t, []string{entryKey(a), entryKey(b)}, []string{entries[0].Key, entries[1].Key})
+	for _, e := range entries {
+		assert.Equal(t, 1, e.RefCount)
+	}
+}
+
+func TestPoolServerInfoCache(t *testing.T) {
+	pool := newTestPool(t, nil)
+	probes := 0
+	pool.infoProbe = func(ctx context.Context, name string, server *mcphost.ServerConfig) (*ServerInfo, error) {
+		probes++
+		if server.Command == "broken" {
+			return nil, errors.New("启动失败")
+		}
+		return &ServerInfo{Name: name, ProtocolVersion: "2024-11-05", CapturedAt: time.Now()}, nil
+	}
+	ctx := context.Background()
+
+	assert.Nil(t, pool.CachedServerInfo("a"))
+	info, err := pool.GetServerInfo(ctx, "a", &mcphost.ServerConfig{Command: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "2024-11-05", info.ProtocolVersion)
+	_, err = pool.GetServerInfo(ctx, "a", &mcphost.ServerConfig{Command: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, probes, "结果被缓存")
+	assert.Same(t, info, pool.CachedServerInfo("a"))
+
+	pool.ForgetServerInfo("a")
+	assert.Nil(t, pool.CachedServerInfo("a"))
+
+	_, err = pool.GetServerInfo(ctx, "b", &mcphost.ServerConfig{Command: "broken"})
+	assert.Error(t, err)
+	assert.Nil(t, pool.CachedServerInfo("b"), "失败不缓存")
+
+	// 过期的结果不再返回
+	pool.infos["c"] = &ServerInfo{Name: "c", CapturedAt: time.Now().Add(-maxIdleTime - time.Second)}
+	assert.Nil(t, pool.CachedServerInfo("c"))
+}
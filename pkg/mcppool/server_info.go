@@ -0,0 +1,110 @@
+package mcppool
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ServerInfo is the InitializeResult of an MCP server: who it is, the
+// negotiated protocol version and the capabilities it declares
+type ServerInfo struct {
+	Name            string                 `json:"name"`                   // 服务器声明的名称
+	Version         string                 `json:"version"`                // 服务器声明的版本
+	ProtocolVersion string                 `json:"protocol_version"`       // 协商的MCP协议版本
+	Latest          bool                   `json:"latest"`                 // 协议版本是否为客户端支持的最新版本
+	Capabilities    mcp.ServerCapabilities `json:"capabilities"`           // 服务器声明的能力
+	Instructions    string                 `json:"instructions,omitempty"` // 服务器提供的使用说明
+	CapturedAt      time.Time              `json:"captured_at"`            // 获取时间
+}
+
+// InfoProbe returns the ServerInfo of the server name
+type InfoProbe func(ctx context.Context, name string, server *mcphost.ServerConfig) (*ServerInfo, error)
+
+// ProbeServerInfo initializes a separate connection to server and returns
+// its InitializeResult, so that servers can be inspected without keeping a
+// pooled connection. A warning is logged
+// when the server negotiates another version than mcp.LATEST_PROTOCOL_VERSION.
+//
+// Parameters:
+//   - ctx: Context of the connection
+//   - name: Name of the server, used in logs
+//   - server: Server to connect to, stdio or sse
+//
+// Returns:
+//   - *ServerInfo: InitializeResult of the server
+//   - error: Error if the server cannot be started or initialized
+func ProbeServerInfo(ctx context.Context, name string, server *mcphost.ServerConfig) (*ServerInfo, error) {
+	result, err := mcphost.ProbeServer(ctx, name, server)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ServerInfo{
+		Name:            result.ServerInfo.Name,
+		Version:         result.ServerInfo.Version,
+		ProtocolVersion: result.ProtocolVersion,
+		Latest:          result.ProtocolVersion == mcp.LATEST_PROTOCOL_VERSION,
+		Capabilities:    result.Capabilities,
+		Instructions:    result.Instructions,
+		CapturedAt:      time.Now(),
+	}
+	if !info.Latest {
+		log.Printf("警告: MCP服务器 %s 使用的协议版本 %s 不是最新版本 %s", name, info.ProtocolVersion, mcp.LATEST_PROTOCOL_VERSION)
+	}
+	return info, nil
+}
+
+// GetServerInfo returns the ServerInfo of the server name. The result is
+// cached until ForgetServerInfo or for maxIdleTime, so repeated calls do not
+// start the server again.
+//
+// Parameters:
+//   - ctx: Context of the connection when the server has to be probed
+//   - name: Name of the server
+//   - server: Definition of the server
+//
+// Returns:
+//   - *ServerInfo: InitializeResult of the server, must not be modified
+//   - error: Error of the probe
+func (p *Pool) GetServerInfo(ctx context.Context, name string, server *mcphost.ServerConfig) (*ServerInfo, error) {
+	if info := p.CachedServerInfo(name); info != nil {
+		return info, nil
+	}
+
+	info, err := p.infoProbe(ctx, name, server)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.infos[name] = info
+	p.mu.Unlock()
+	return info, nil
+}
+
+// CachedServerInfo returns the cached ServerInfo of the server name without
+// probing it, nil if there is none
+func (p *Pool) CachedServerInfo(name string) *ServerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info, ok := p.infos[name]
+	if !ok {
+		return nil
+	}
+	if time.Since(info.CapturedAt) > maxIdleTime {
+		delete(p.infos, name)
+		return nil
+	}
+	return info
+}
+
+// ForgetServerInfo removes the cached ServerInfo of the server name, call it
+// when the definition of the server changes
+func (p *Pool) ForgetServerInfo(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.infos, name)
+}
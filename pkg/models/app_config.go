@@ -4,15 +4,26 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
+	"unicode/utf8"
+
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
 
 	"gorm.io/gorm"
 )
 
+// MaxGuardPromptLength is the maximum number of characters of a guard prompt,
+// it is sent with every model call of every task
+const MaxGuardPromptLength = 8000
+
 // MCPConfig 存储MCP的配置信息
 type MCPConfig struct {
-	ConfigFile string          `json:"config_file"`
-	Tools      []MCPToolConfig `json:"tools"`
+	ConfigFile      string          `json:"config_file"`
+	Tools           []MCPToolConfig `json:"tools"`
+	PrefixToolNames bool            `json:"prefix_tool_names"` // 是否以"<服务器>__<工具>"的名称向大模型展示MCP工具
+
+	ToolDescriptionLanguage string `json:"tool_description_language,omitempty"` // 向大模型提供该语言的工具描述，未设置该语言描述的工具使用原描述
 }
 
 // MCPToolConfig 表示一个MCP工具的配置，包括服务器名称和工具名称
@@ -21,22 +32,74 @@ type MCPToolConfig struct {
 	Name   string `json:"name"`   // 工具名称
 }
 
+// OutputConfig 存储结果后处理的配置信息
+type OutputConfig struct {
+	Redact             []RedactRule `json:"redact"`               // 正则脱敏规则
+	StripMarkdownLinks bool         `json:"strip_markdown_links"` // 是否去除Markdown链接
+	MaxLength          int          `json:"max_length"`           // 最大输出长度，0表示不限制
+}
+
+// RedactRule 表示一条正则脱敏规则
+type RedactRule struct {
+	Pattern     string `json:"pattern"`     // 正则表达式
+	Replacement string `json:"replacement"` // 替换内容
+}
+
+// IntegrationsConfig 存储第三方服务集成的非敏感配置，API Key单独加密存储
+type IntegrationsConfig struct {
+	FOFA FOFAConfig `json:"fofa"`
+}
+
+// FOFAConfig 存储FOFA的非敏感配置
+type FOFAConfig struct {
+	Email   string `json:"email"`    // FOFA账号邮箱
+	BaseURL string `json:"base_url"` // FOFA API地址
+	MaxSize int    `json:"max_size"` // 单次查询最多返回的结果数
+}
+
+// NotificationsConfig 存储任务事件的Webhook和文件通知配置
+type NotificationsConfig struct {
+	Webhooks []WebhookConfig  `json:"webhooks,omitempty"` // 以HTTP POST推送事件的Webhook
+	Files    []FileSinkConfig `json:"files,omitempty"`    // 以JSON Lines追加事件的文件
+}
+
+// WebhookConfig 存储一个Webhook通知的配置
+type WebhookConfig struct {
+	URL     string            `json:"url"`               // 接收事件的地址
+	Events  []string          `json:"events,omitempty"`  // 推送的事件类型，为空时推送result和error
+	Headers map[string]string `json:"headers,omitempty"` // 附加的请求头
+	Timeout int               `json:"timeout"`           // 单次请求超时时间（秒）
+	Retries int               `json:"retries"`           // 请求失败后的重试次数
+}
+
+// FileSinkConfig 存储一个文件通知的配置
+type FileSinkConfig struct {
+	Path   string   `json:"path"`             // 追加事件的文件路径
+	Events []string `json:"events,omitempty"` // 记录的事件类型，为空时记录全部事件
+}
+
 // AppConfigModel represents a saved application configuration in the database.
 // It stores the global application settings for MCP Agent.
 type AppConfigModel struct {
-	ID           uint           `gorm:"primarykey" json:"id"`
-	Name         string         `gorm:"uniqueIndex;not null" json:"name"`           // 配置名称，如 "default"
-	Description  string         `gorm:"type:text" json:"description"`               // 配置描述
-	Proxy        string         `json:"proxy"`                                      // 代理配置
-	SystemPrompt string         `gorm:"type:text" json:"system_prompt"`             // 系统提示词
-	MaxStep      int            `gorm:"default:20" json:"max_step"`                 // 最大步数
-	PlaceHolders string         `gorm:"type:json;default:'{}'" json:"placeholders"` // 占位符，JSON格式存储
-	MCPSettings  string         `gorm:"type:json;default:'{}'" json:"mcp_settings"` // MCP配置，JSON格式存储
-	IsDefault    bool           `gorm:"default:false" json:"is_default"`            // 是否为默认配置
-	IsActive     bool           `gorm:"default:true" json:"is_active"`              // 是否启用
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint           `gorm:"primarykey" json:"id"`
+	Name          string         `gorm:"uniqueIndex;not null" json:"name"`           // 配置名称，如 "default"
+	Description   string         `gorm:"type:text" json:"description"`               // 配置描述
+	Proxy         string         `json:"proxy"`                                      // 代理配置
+	GuardPrompt   string         `gorm:"type:text" json:"guard_prompt"`              // 始终位于系统提示词之前的约束，任务请求无法修改
+	SystemPrompt  string         `gorm:"type:text" json:"system_prompt"`             // 系统提示词
+	MaxStep       int            `gorm:"default:20" json:"max_step"`                 // 最大步数
+	Language      string         `gorm:"default:'zh'" json:"language"`               // 面向用户的提示语言：zh 或 en
+	PlaceHolders  string         `gorm:"type:json;default:'{}'" json:"placeholders"` // 占位符，JSON格式存储
+	MCPSettings   string         `gorm:"type:json;default:'{}'" json:"mcp_settings"` // MCP配置，JSON格式存储
+	Output        string         `gorm:"type:json;default:'{}'" json:"output"`       // 结果后处理配置，JSON格式存储
+	Integrations  string         `gorm:"type:json;default:'{}'" json:"integrations"` // 第三方服务集成配置，JSON格式存储
+	FOFAKey       string         `gorm:"column:fofa_key" json:"-"`                   // FOFA API Key，加密存储
+	Notifications string         `gorm:"type:text;serializer:secret" json:"-"`       // 任务事件通知配置，JSON格式加密存储
+	IsDefault     bool           `gorm:"default:false" json:"is_default"`            // 是否为默认配置
+	IsActive      bool           `gorm:"default:true" json:"is_active"`              // 是否启用
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName returns the table name for AppConfigModel
@@ -53,6 +116,9 @@ func (a *AppConfigModel) Validate() error {
 	if a.MaxStep <= 0 {
 		return ErrAppConfigMaxStepInvalid
 	}
+	if utf8.RuneCountInString(a.GuardPrompt) > MaxGuardPromptLength {
+		return ErrAppConfigGuardPromptTooLong
+	}
 	return nil
 }
 
@@ -113,3 +179,106 @@ func (a *AppConfigModel) SetMCPConfig(mcpConfig *MCPConfig) error {
 	a.MCPSettings = string(data)
 	return nil
 }
+
+// GetOutputConfig returns the output post-processing configuration
+func (a *AppConfigModel) GetOutputConfig() (*OutputConfig, error) {
+	if a.Output == "" {
+		return &OutputConfig{}, nil
+	}
+
+	var result OutputConfig
+	if err := json.Unmarshal([]byte(a.Output), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetOutputConfig sets the output post-processing configuration
+func (a *AppConfigModel) SetOutputConfig(outputConfig *OutputConfig) error {
+	if outputConfig == nil {
+		a.Output = "{}"
+		return nil
+	}
+
+	data, err := json.Marshal(outputConfig)
+	if err != nil {
+		return err
+	}
+	a.Output = string(data)
+	return nil
+}
+
+// GetIntegrationsConfig returns the non-secret integrations configuration
+func (a *AppConfigModel) GetIntegrationsConfig() (*IntegrationsConfig, error) {
+	if a.Integrations == "" {
+		return &IntegrationsConfig{}, nil
+	}
+
+	var result IntegrationsConfig
+	if err := json.Unmarshal([]byte(a.Integrations), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetIntegrationsConfig sets the non-secret integrations configuration
+func (a *AppConfigModel) SetIntegrationsConfig(integrations *IntegrationsConfig) error {
+	if integrations == nil {
+		a.Integrations = "{}"
+		return nil
+	}
+
+	data, err := json.Marshal(integrations)
+	if err != nil {
+		return err
+	}
+	a.Integrations = string(data)
+	return nil
+}
+
+// GetNotificationsConfig returns the notification sinks configuration
+func (a *AppConfigModel) GetNotificationsConfig() (*NotificationsConfig, error) {
+	if a.Notifications == "" {
+		return &NotificationsConfig{}, nil
+	}
+
+	var result NotificationsConfig
+	if err := json.Unmarshal([]byte(a.Notifications), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetNotificationsConfig sets the notification sinks configuration
+func (a *AppConfigModel) SetNotificationsConfig(notifications *NotificationsConfig) error {
+	if notifications == nil {
+		a.Notifications = "{}"
+		return nil
+	}
+
+	data, err := json.Marshal(notifications)
+	if err != nil {
+		return err
+	}
+	a.Notifications = string(data)
+	return nil
+}
+
+// GetFOFAKey returns the decrypted FOFA API key
+func (a *AppConfigModel) GetFOFAKey() (string, error) {
+	key, err := secret.Decrypt(a.FOFAKey)
+	if err != nil {
+		return "", fmt.Errorf("解密FOFA API Key失败: %w", err)
+	}
+	return key, nil
+}
+
+// SetFOFAKey encrypts and sets the FOFA API key
+func (a *AppConfigModel) SetFOFAKey(key string) error {
+	encrypted, err := secret.Encrypt(key)
+	if err != nil {
+		return fmt.Errorf("加密FOFA API Key失败: %w", err)
+	}
+	a.FOFAKey = encrypted
+	return nil
+}
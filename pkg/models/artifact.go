@@ -0,0 +1,24 @@
+// Package models provides database models for the MCP Agent application.
+// It defines the data structures used for persistent storage of tool artifacts.
+package models
+
+import (
+	"time"
+)
+
+// ArtifactModel stores the metadata of a binary tool output saved during a task.
+// The content itself is stored as a file at Path.
+type ArtifactModel struct {
+	ID        string    `gorm:"primarykey" json:"id"`          // 产物ID
+	TaskID    string    `gorm:"index;not null" json:"task_id"` // 所属任务ID
+	ToolName  string    `json:"tool_name"`                     // 产生该产物的工具
+	MimeType  string    `gorm:"not null" json:"mime_type"`     // 内容类型
+	Size      int64     `gorm:"not null" json:"size"`          // 大小（字节）
+	Path      string    `gorm:"not null" json:"-"`             // 文件路径
+	CreatedAt time.Time `gorm:"index" json:"created_at"`       // 创建时间
+}
+
+// TableName returns the table name for ArtifactModel
+func (ArtifactModel) TableName() string {
+	return "artifacts"
+}
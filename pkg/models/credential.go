@@ -0,0 +1,205 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+)
+
+var (
+	// validCredentialName matches the names usable in {{credential:NAME}} references
+	validCredentialName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+	// credentialReference matches the {{credential:NAME}} references of a value
+	credentialReference = regexp.MustCompile(`\{\{\s*credential:([A-Za-z0-9_.-]+)\s*\}\}`)
+)
+
+// CredentialLookup returns the secret value of a stored credential,
+// an error wrapping ErrCredentialNotFound if there is none with that name
+type CredentialLookup func(name string) (string, error)
+
+// CredentialModel represents a named secret in the database. MCP server
+// definitions reference it as {{credential:NAME}} in their args, env values,
+// URL and headers, so the secret is stored once and never returned by the API.
+// Deleting a credential removes the row, no copy of the secret is kept.
+type CredentialModel struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;not null" json:"name"`     // 凭据名称，以{{credential:NAME}}引用
+	Value       string    `gorm:"type:text;serializer:secret" json:"-"` // 凭据值，加密存储，接口只返回掩码
+	Description string    `gorm:"type:text" json:"description"`         // 凭据描述
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for CredentialModel
+func (CredentialModel) TableName() string {
+	return "credentials"
+}
+
+// Validate validates the credential.
+// It ensures the name can be referenced and the value is present.
+func (c *CredentialModel) Validate() error {
+	if c.Name == "" {
+		return ErrCredentialNameEmpty
+	}
+	if !validCredentialName.MatchString(c.Name) {
+		return ErrCredentialNameInvalid
+	}
+	if c.Value == "" {
+		return ErrCredentialValueEmpty
+	}
+	return nil
+}
+
+// MaskedValue returns the value shown in API responses. Only the last four
+// characters of long values are kept so that users can tell credentials apart.
+func (c *CredentialModel) MaskedValue() string {
+	runes := []rune(c.Value)
+	if len(runes) < 12 {
+		return RedactedHeaderValue
+	}
+	return RedactedHeaderValue + string(runes[len(runes)-4:])
+}
+
+// MarshalJSON adds the masked value to the JSON of the credential
+func (c CredentialModel) MarshalJSON() ([]byte, error) {
+	type plain CredentialModel
+	return json.Marshal(struct {
+		plain
+		MaskedValue string `json:"masked_value"`
+	}{plain(c), c.MaskedValue()})
+}
+
+// CredentialReferences returns the sorted names of the credentials referenced in values
+func CredentialReferences(values ...string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, value := range values {
+		for _, match := range credentialReference.FindAllStringSubmatch(value, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				names = append(names, match[1])
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveCredentialReferences replaces the {{credential:NAME}} references of value
+//
+// Parameters:
+//   - value: Text possibly containing references
+//   - lookup: Returns the value of a credential, nil when no credentials are available
+//
+// Returns:
+//   - string: value with every reference replaced by the secret
+//   - error: Error wrapping ErrCredentialNotFound for the first unknown credential
+func ResolveCredentialReferences(value string, lookup CredentialLookup) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	var resolveErr error
+	resolved := credentialReference.ReplaceAllStringFunc(value, func(match string) string {
+		if resolveErr != nil {
+			return match
+		}
+		name := credentialReference.FindStringSubmatch(match)[1]
+		if lookup == nil {
+			resolveErr = fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+			return match
+		}
+		secret, err := lookup(name)
+		if err != nil {
+			resolveErr = err
+			return match
+		}
+		return secret
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+// ResolveServerCredentials returns a copy of server with the credential
+// references of its args, env values and URL resolved. Call it just before
+// connecting and never return the result from the API.
+//
+// Parameters:
+//   - name: Server name included in the error
+//   - server: Server configuration possibly containing references
+//   - lookup: Returns the value of a credential
+//
+// Returns:
+//   - mcphost.ServerConfig: Server configuration with the secrets
+//   - error: Error wrapping ErrCredentialNotFound if a credential does not exist
+func ResolveServerCredentials(name string, server mcphost.ServerConfig, lookup CredentialLookup) (mcphost.ServerConfig, error) {
+	resolved := server
+	wrap := func(err error) error {
+		return fmt.Errorf("MCP服务器 %s 配置无效: %w", name, err)
+	}
+
+	var err error
+	if resolved.URL, err = ResolveCredentialReferences(server.URL, lookup); err != nil {
+		return server, wrap(err)
+	}
+	if server.Args != nil {
+		resolved.Args = make([]string, len(server.Args))
+		for i, arg := range server.Args {
+			if resolved.Args[i], err = ResolveCredentialReferences(arg, lookup); err != nil {
+				return server, wrap(err)
+			}
+		}
+	}
+	if server.Env != nil {
+		resolved.Env = make(map[string]string, len(server.Env))
+		for k, v := range server.Env {
+			if resolved.Env[k], err = ResolveCredentialReferences(v, lookup); err != nil {
+				return server, wrap(err)
+			}
+		}
+	}
+	if server.Headers != nil {
+		resolved.Headers = make(map[string]string, len(server.Headers))
+		for k, v := range server.Headers {
+			if resolved.Headers[k], err = ResolveCredentialReferences(v, lookup); err != nil {
+				return server, wrap(err)
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// CredentialReferences returns the sorted names of the credentials referenced
+// in the args, env values, URL and headers of the server
+func (m *MCPServerConfigModel) CredentialReferences() []string {
+	values := []string{m.URL}
+	if args, err := m.GetArgsSlice(); err == nil {
+		values = append(values, args...)
+	}
+	if env, err := m.GetEnvMap(); err == nil {
+		for _, v := range env {
+			values = append(values, v)
+		}
+	}
+	if headers, err := m.GetHeadersSlice(); err == nil {
+		values = append(values, headers...)
+	}
+	return CredentialReferences(values...)
+}
+
+// ToResolvedServerConfig converts the model like ToServerConfig and resolves
+// its credential references, it is used just before connecting to the server
+func (m *MCPServerConfigModel) ToResolvedServerConfig(lookup CredentialLookup) (mcphost.ServerConfig, error) {
+	config, err := m.ToServerConfig()
+	if err != nil {
+		return config, err
+	}
+	return ResolveServerCredentials(m.Name, config, lookup)
+}
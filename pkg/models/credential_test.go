@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapLookup returns a CredentialLookup reading from credentials
+func mapLookup(credentials map[string]string) CredentialLookup {
+	return func(name string) (string, error) {
+		value, ok := credentials[name]
+		if !ok {
+			return "", ErrCredentialNotFound
+		}
+		return value, nil
+	}
+}
+
+func TestResolveServerCredentials(t *testing.T) {
+	lookup := mapLookup(map[string]string{"shodan": "s3cret", "proxy.pass": "p@ss"})
+	server := mcphost.ServerConfig{
+		Command: "scanner",
+		Args:    []string{"--key", "{{credential:shodan}}", "--proxy=http://u:{{ credential:proxy.pass }}@127.0.0.1"},
+		Env:     map[string]string{"SHODAN_KEY": "{{credential:shodan}}", "MODE": "fast"},
+	}
+
+	resolved, err := ResolveServerCredentials("scanner", server, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--key", "s3cret", "--proxy=http://u:p@ss@127.0.0.1"}, resolved.Args)
+	assert.Equal(t, map[string]string{"SHODAN_KEY": "s3cret", "MODE": "fast"}, resolved.Env)
+	// 原配置保持不变
+	assert.Equal(t, "{{credential:shodan}}", server.Args[1])
+	assert.Equal(t, "{{credential:shodan}}", server.Env["SHODAN_KEY"])
+
+	remote := mcphost.ServerConfig{
+		TransportType: "sse",
+		URL:           "https://example.com/sse?key={{credential:shodan}}",
+		Headers:       map[string]string{"Authorization": "Bearer {{credential:shodan}}"},
+	}
+	resolved, err = ResolveServerCredentials("remote", remote, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/sse?key=s3cret", resolved.URL)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer s3cret"}, resolved.Headers)
+	assert.Equal(t, "Bearer {{credential:shodan}}", remote.Headers["Authorization"])
+
+	server.Env["TOKEN"] = "{{credential:missing}}"
+	_, err = ResolveServerCredentials("scanner", server, lookup)
+	assert.ErrorIs(t, err, ErrCredentialNotFound)
+	assert.Contains(t, err.Error(), "scanner")
+	_, err = ResolveServerCredentials("scanner", server, nil)
+	assert.ErrorIs(t, err, ErrCredentialNotFound)
+}
+
+func TestMCPServerConfigCredentialReferences(t *testing.T) {
+	server := &MCPServerConfigModel{Name: "hosted", TransportType: "sse", URL: "https://{{credential:host}}/sse"}
+	require.NoError(t, server.SetHeaders([]string{"Authorization: Bearer {{credential:token}}"}))
+	assert.Equal(t, []string{"host", "token"}, server.CredentialReferences())
+
+	local := &MCPServerConfigModel{Name: "local", Command: "scanner"}
+	require.NoError(t, local.SetArgs([]string{"{{credential:b}}", "{{credential:a}}"}))
+	require.NoError(t, local.SetEnv(map[string]string{"KEY": "{{credential:a}}"}))
+	assert.Equal(t, []string{"a", "b"}, local.CredentialReferences())
+}
+
+func TestCredentialModel(t *testing.T) {
+	assert.ErrorIs(t, (&CredentialModel{Value: "x"}).Validate(), ErrCredentialNameEmpty)
+	assert.ErrorIs(t, (&CredentialModel{Name: "带空格 的名称", Value: "x"}).Validate(), ErrCredentialNameInvalid)
+	assert.ErrorIs(t, (&CredentialModel{Name: "shodan"}).Validate(), ErrCredentialValueEmpty)
+	assert.NoError(t, (&CredentialModel{Name: "shodan.key-1", Value: "x"}).Validate())
+
+	credential := CredentialModel{ID: 1, Name: "shodan", Value: "sk-1234567890abcd"}
+	assert.Equal(t, RedactedHeaderValue+"abcd", credential.MaskedValue())
+	assert.Equal(t, RedactedHeaderValue, (&CredentialModel{Value: "short"}).MaskedValue())
+
+	data, err := json.Marshal(credential)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk-1234567890abcd")
+	assert.Contains(t, string(data), `"masked_value":"******abcd"`)
+	assert.Contains(t, string(data), `"name":"shodan"`)
+}
@@ -1,7 +1,10 @@
 // Package models provides error definitions for database models.
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // LLM配置相关错误
 var (
@@ -13,6 +16,9 @@ var (
 	ErrLLMConfigAPIKeyEmpty  = errors.New("LLM API Key不能为空")
 	ErrLLMConfigNotFound     = errors.New("LLM配置不存在")
 	ErrLLMConfigNameExists   = errors.New("LLM配置名称已存在")
+
+	ErrLLMConfigDefaultMaxStepInvalid = errors.New("LLM配置的默认最大步数必须大于0")
+	ErrLLMConfigDefaultPromptNotFound = errors.New("LLM配置的默认系统提示词不存在")
 )
 
 // MCP服务器配置相关错误
@@ -23,15 +29,25 @@ var (
 	ErrMCPServerConfigInvalidTransportType = errors.New("MCP服务器传输类型无效，仅支持 stdio、sse 和 http")
 	ErrMCPServerConfigNotFound             = errors.New("MCP服务器配置不存在")
 	ErrMCPServerConfigNameExists           = errors.New("MCP服务器配置名称已存在")
+	ErrMCPServerConfigNamePrefixInvalid    = errors.New("工具名前缀只能包含字母、数字、下划线和连字符，且不能包含连续的下划线")
+	ErrMCPServerConfigHeaderInvalid        = errors.New("HTTP头部格式无效，应为\"名称: 值\"且名称不能为空")
+	ErrMCPServerConfigHeaderRedacted       = errors.New("HTTP头部的值已隐藏，但原配置中不存在该头部")
+	ErrMCPServerConfigEnvRedacted          = errors.New("环境变量的值已隐藏，但原配置中不存在该环境变量")
+	ErrMCPServerConfigTimeoutTooSmall      = errors.New("MCP服务器超时时间过短")
+	ErrMCPServerConfigTimeoutNegative      = errors.New("MCP服务器超时时间不能为负数")
 )
 
 // MCP工具相关错误
 var (
-	ErrMCPToolNameEmpty     = errors.New("MCP工具名称不能为空")
-	ErrMCPToolServerIDEmpty = errors.New("MCP工具服务器ID不能为空")
-	ErrMCPToolKeyEmpty      = errors.New("MCP工具唯一标识不能为空")
-	ErrMCPToolNotFound      = errors.New("MCP工具不存在")
-	ErrMCPToolKeyExists     = errors.New("MCP工具唯一标识已存在")
+	ErrMCPToolNameEmpty           = errors.New("MCP工具名称不能为空")
+	ErrMCPToolServerIDEmpty       = errors.New("MCP工具服务器ID不能为空")
+	ErrMCPToolKeyEmpty            = errors.New("MCP工具唯一标识不能为空")
+	ErrMCPToolNotFound            = errors.New("MCP工具不存在")
+	ErrMCPToolKeyExists           = errors.New("MCP工具唯一标识已存在")
+	ErrMCPToolResultFilterInvalid = errors.New("MCP工具结果过滤表达式无效")
+	ErrMCPToolOutputFormatInvalid = errors.New("MCP工具结果格式无效，应为 plain、markdown 或 json")
+
+	ErrMCPToolDescriptionLanguageEmpty = errors.New("MCP工具描述的语言不能为空")
 )
 
 // 系统提示词相关错误
@@ -48,4 +64,42 @@ var (
 	ErrAppConfigMaxStepInvalid = errors.New("最大步数必须大于0")
 	ErrAppConfigNotFound       = errors.New("全局配置不存在")
 	ErrAppConfigNameExists     = errors.New("全局配置名称已存在")
+
+	ErrAppConfigGuardPromptTooLong = fmt.Errorf("安全约束提示词不能超过 %d 个字符", MaxGuardPromptLength)
+)
+
+// 工具产物相关错误
+var (
+	ErrArtifactNotFound = errors.New("产物不存在")
+)
+
+// 任务历史相关错误
+var (
+	ErrTaskHistoryNotFound = errors.New("任务记录不存在")
+	ErrTaskHistoryRunning  = errors.New("任务正在运行，不能删除任务记录")
+)
+
+// 任务模板相关错误
+var (
+	ErrTaskTemplateNameEmpty     = errors.New("任务模板名称不能为空")
+	ErrTaskTemplateContentEmpty  = errors.New("任务模板内容不能为空")
+	ErrTaskTemplateParamInvalid  = errors.New("任务模板参数声明无效")
+	ErrTaskTemplateToolsInvalid  = errors.New("任务模板工具列表无效")
+	ErrTaskTemplateNotFound      = errors.New("任务模板不存在")
+	ErrTaskTemplateNameExists    = errors.New("任务模板名称已存在")
+	ErrTaskTemplateParamsInvalid = errors.New("任务模板参数无效")
+)
+
+// 凭据相关错误
+var (
+	ErrCredentialNameEmpty   = errors.New("凭据名称不能为空")
+	ErrCredentialNameInvalid = errors.New("凭据名称只能包含字母、数字、下划线、点和连字符，且不超过64个字符")
+	ErrCredentialValueEmpty  = errors.New("凭据值不能为空")
+	ErrCredentialNotFound    = errors.New("凭据不存在")
+	ErrCredentialNameExists  = errors.New("凭据名称已存在")
+)
+
+// 列表查询相关错误
+var (
+	ErrListSortInvalid = errors.New("排序字段无效，仅支持 name、created_at 和 updated_at")
 )
@@ -10,21 +10,26 @@ import (
 
 // LLMConfigModel represents a saved LLM configuration in the database.
 // It extends the basic LLM configuration with metadata for management.
+// DefaultSystemPromptID and DefaultMaxStep apply to the tasks using the
+// configuration that set no system_prompt_id or max_step, and take precedence
+// over the default app config.
 type LLMConfigModel struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	Name        string         `gorm:"uniqueIndex;not null" json:"name"`        // 配置名称，用于用户识别
-	Description string         `gorm:"type:text" json:"description"`            // 配置描述
-	Type        string         `gorm:"not null" json:"type"`                    // LLM类型：openai, ollama
-	BaseURL     string         `gorm:"not null" json:"base_url"`                // API基础URL
-	Model       string         `gorm:"not null" json:"model"`                   // 模型名称
-	APIKey      string         `gorm:"not null" json:"api_key"`                 // API密钥
-	Temperature *float64       `json:"temperature,omitempty"`                   // 温度参数
-	MaxTokens   *int           `json:"max_tokens,omitempty"`                    // 最大token数
-	IsDefault   bool           `gorm:"default:false" json:"is_default"`         // 是否为默认配置
-	IsActive    bool           `gorm:"default:true" json:"is_active"`           // 是否启用
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                    uint           `gorm:"primarykey" json:"id"`
+	Name                  string         `gorm:"uniqueIndex;not null" json:"name"`          // 配置名称，用于用户识别
+	Description           string         `gorm:"type:text" json:"description"`              // 配置描述
+	Type                  string         `gorm:"not null" json:"type"`                      // LLM类型：openai, ollama
+	BaseURL               string         `gorm:"not null" json:"base_url"`                  // API基础URL
+	Model                 string         `gorm:"not null" json:"model"`                     // 模型名称
+	APIKey                string         `gorm:"not null;serializer:secret" json:"api_key"` // API密钥，加密存储
+	Temperature           *float64       `json:"temperature,omitempty"`                     // 温度参数
+	MaxTokens             *int           `json:"max_tokens,omitempty"`                      // 最大token数
+	DefaultSystemPromptID *uint          `json:"default_system_prompt_id,omitempty"`        // 默认系统提示词，删除提示词时清空
+	DefaultMaxStep        *int           `json:"default_max_step,omitempty"`                // 默认最大步数
+	IsDefault             bool           `gorm:"default:false" json:"is_default"`           // 是否为默认配置
+	IsActive              bool           `gorm:"default:true" json:"is_active"`             // 是否启用
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName returns the table name for LLMConfigModel
@@ -53,6 +58,9 @@ func (l *LLMConfigModel) Validate() error {
 	if l.APIKey == "" {
 		return ErrLLMConfigAPIKeyEmpty
 	}
+	if l.DefaultMaxStep != nil && *l.DefaultMaxStep <= 0 {
+		return ErrLLMConfigDefaultMaxStepInvalid
+	}
 	return nil
 }
 
@@ -64,14 +72,14 @@ func (l *LLMConfigModel) ToConfigLLM() map[string]interface{} {
 		"model":    l.Model,
 		"api_key":  l.APIKey,
 	}
-	
+
 	if l.Temperature != nil {
 		config["temperature"] = *l.Temperature
 	}
 	if l.MaxTokens != nil {
 		config["max_tokens"] = *l.MaxTokens
 	}
-	
+
 	return config
 }
 
@@ -79,7 +87,7 @@ func (l *LLMConfigModel) ToConfigLLM() map[string]interface{} {
 func (l *LLMConfigModel) FromConfigLLM(name, description string, config map[string]interface{}) {
 	l.Name = name
 	l.Description = description
-	
+
 	if v, ok := config["type"].(string); ok {
 		l.Type = v
 	}
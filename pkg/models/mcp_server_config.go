@@ -4,30 +4,46 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/LubyRuffy/einomcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
 	"gorm.io/gorm"
 )
 
+// validNamePrefix matches tool name prefixes accepted by LLM providers
+var validNamePrefix = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// RedactedHeaderValue replaces HTTP header values in API responses, since
+// headers of hosted MCP servers usually carry credentials. Updates sending
+// it back keep the stored value of the header.
+const RedactedHeaderValue = "******"
+
 // MCPServerConfigModel represents a saved MCP server configuration in the database.
 // It stores MCP server settings with metadata for management.
 // Supports both STDIO and SSE transport types.
 type MCPServerConfigModel struct {
-	ID            uint           `gorm:"primarykey" json:"id"`
-	Name          string         `gorm:"uniqueIndex;not null" json:"name"`               // 服务器名称，用于用户识别
-	Description   string         `gorm:"type:text" json:"description"`                   // 服务器描述
-	TransportType string         `gorm:"not null;default:'stdio'" json:"transport_type"` // 传输类型：stdio 或 sse
-	Command       string         `json:"command"`                                        // 启动命令（stdio类型必需）
-	Args          string         `gorm:"type:text" json:"args"`                          // 参数列表（JSON格式存储，stdio类型使用）
-	Env           string         `gorm:"type:text" json:"env"`                           // 环境变量（JSON格式存储，stdio类型使用）
-	URL           string         `json:"url"`                                            // SSE服务器URL（sse类型必需）
-	Headers       string         `gorm:"type:text" json:"headers"`                       // HTTP头部（JSON格式存储，sse类型使用）
-	Disabled      bool           `gorm:"default:false" json:"disabled"`                  // 是否禁用
-	IsActive      bool           `gorm:"default:true" json:"is_active"`                  // 是否启用
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              uint           `gorm:"primarykey" json:"id"`
+	Name            string         `gorm:"uniqueIndex;not null" json:"name"`               // 服务器名称，用于用户识别
+	Description     string         `gorm:"type:text" json:"description"`                   // 服务器描述
+	TransportType   string         `gorm:"not null;default:'stdio'" json:"transport_type"` // 传输类型：stdio 或 sse
+	Command         string         `json:"command"`                                        // 启动命令（stdio类型必需）
+	Args            string         `gorm:"type:text" json:"args"`                          // 参数列表（JSON格式存储，stdio类型使用）
+	Env             string         `gorm:"type:text;serializer:secret" json:"env"`         // 环境变量（JSON格式加密存储，stdio类型使用）
+	URL             string         `json:"url"`                                            // SSE服务器URL（sse类型必需）
+	Headers         string         `gorm:"type:text;serializer:secret" json:"headers"`     // HTTP头部（JSON格式加密存储，sse类型使用）
+	NamePrefix      string         `json:"name_prefix"`                                    // 向大模型展示的工具名前缀，为空时不加前缀
+	MaxConcurrency  int            `json:"max_concurrency"`                                // 同时执行的工具调用数，0时stdio为1、sse/http不限制，负数表示不限制
+	Disabled        bool           `gorm:"default:false" json:"disabled"`                  // 是否禁用
+	LastSeenVersion string         `json:"last_seen_version"`                              // 最近一次连接时服务器协商的MCP协议版本
+	LastTestError   string         `gorm:"type:text" json:"last_test_error,omitempty"`     // 保存时连接测试失败的原因，测试成功时为空
+	LastSyncError   string         `gorm:"type:text" json:"last_sync_error,omitempty"`     // 最近一次工具同步失败的原因，同步成功时为空
+	IsActive        bool           `gorm:"default:true" json:"is_active"`                  // 是否启用
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName returns the table name for MCPServerConfigModel
@@ -40,6 +56,9 @@ func (m *MCPServerConfigModel) Validate() error {
 	if m.Name == "" {
 		return ErrMCPServerConfigNameEmpty
 	}
+	if m.NamePrefix != "" && (!validNamePrefix.MatchString(m.NamePrefix) || strings.Contains(m.NamePrefix, "__")) {
+		return ErrMCPServerConfigNamePrefixInvalid
+	}
 
 	// 验证传输类型
 	if m.TransportType == "" {
@@ -55,6 +74,9 @@ func (m *MCPServerConfigModel) Validate() error {
 		if m.URL == "" {
 			return ErrMCPServerConfigURLEmpty
 		}
+		if _, err := m.GetHeadersMap(); err != nil {
+			return err
+		}
 	default:
 		return ErrMCPServerConfigInvalidTransportType
 	}
@@ -63,14 +85,14 @@ func (m *MCPServerConfigModel) Validate() error {
 }
 
 // ToServerConfig converts the database model to mcphost.ServerConfig
-func (m *MCPServerConfigModel) ToServerConfig() (einomcphost.ServerConfig, error) {
+func (m *MCPServerConfigModel) ToServerConfig() (mcphost.ServerConfig, error) {
 	// 设置默认传输类型以保持向后兼容
 	transportType := m.TransportType
 	if transportType == "" {
 		transportType = "stdio"
 	}
 
-	config := einomcphost.ServerConfig{
+	config := mcphost.ServerConfig{
 		TransportType: transportType,
 		Disabled:      m.Disabled,
 	}
@@ -100,19 +122,69 @@ func (m *MCPServerConfigModel) ToServerConfig() (einomcphost.ServerConfig, error
 	case "sse", "http":
 		config.URL = m.URL
 
-		// 注意: einomcphost.ServerConfig 不支持Headers字段
-		// Headers信息目前存储在数据库中但不会传递给einomcphost
-		// 如果未来einomcphost支持Headers，可以在这里添加解析逻辑
+		// 解析HTTP头部
+		headers, err := m.GetHeadersMap()
+		if err != nil {
+			return config, err
+		}
+		if len(headers) > 0 {
+			config.Headers = headers
+		}
 	}
 
+	if err := ValidateServerConfig(m.Name, config); err != nil {
+		return config, err
+	}
 	return config, nil
 }
 
+// ValidateServerConfig checks a server configuration before it is passed to
+// mcphost, which otherwise fails the whole hub initialization with an
+// error that does not point at the invalid server. Disabled servers are
+// checked too, because mcphost.LoadSettings validates every server of the settings.
+//
+// Parameters:
+//   - name: Server name included in the error
+//   - cfg: Server configuration to check
+//
+// Returns:
+//   - error: Validation error wrapping one of the ErrMCPServerConfig errors, nil if valid
+func ValidateServerConfig(name string, cfg mcphost.ServerConfig) error {
+	var err error
+	switch cfg.TransportType {
+	case "", "stdio":
+		if strings.TrimSpace(cfg.Command) == "" {
+			err = ErrMCPServerConfigCommandEmpty
+		}
+	case "sse", "http":
+		if strings.TrimSpace(cfg.URL) == "" {
+			err = ErrMCPServerConfigURLEmpty
+		}
+	default:
+		err = ErrMCPServerConfigInvalidTransportType
+	}
+	if err == nil && cfg.Timeout < 0 {
+		err = ErrMCPServerConfigTimeoutNegative
+	}
+	if err == nil && cfg.Timeout > 0 && cfg.Timeout < time.Duration(mcphost.MinMCPTimeoutSeconds)*time.Second {
+		err = fmt.Errorf("%w，至少为%d秒", ErrMCPServerConfigTimeoutTooSmall, mcphost.MinMCPTimeoutSeconds)
+	}
+	if err != nil {
+		return fmt.Errorf("MCP服务器 %s 配置无效: %w", name, err)
+	}
+	return nil
+}
+
 // FromServerConfig populates the model from mcphost.ServerConfig
-func (m *MCPServerConfigModel) FromServerConfig(name, description string, config einomcphost.ServerConfig) error {
+func (m *MCPServerConfigModel) FromServerConfig(name, description string, config mcphost.ServerConfig) error {
 	m.Name = name
 	m.Description = description
+	m.TransportType = config.TransportType
+	if m.TransportType == "" {
+		m.TransportType = "stdio"
+	}
 	m.Command = config.Command
+	m.URL = config.URL
 	m.Disabled = config.Disabled
 
 	// 序列化参数列表
@@ -219,3 +291,124 @@ func (m *MCPServerConfigModel) SetHeaders(headers []string) error {
 	m.Headers = string(headersJSON)
 	return nil
 }
+
+// GetHeadersMap returns the headers as a map from header name to value.
+// Each stored header has the form "Name: Value".
+func (m *MCPServerConfigModel) GetHeadersMap() (map[string]string, error) {
+	headers, err := m.GetHeadersSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(headers))
+	for _, header := range headers {
+		name, value, err := parseHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// RedactHeaders replaces the values of all headers with RedactedHeaderValue.
+// Call it on a copy of the model before returning it from the API.
+func (m *MCPServerConfigModel) RedactHeaders() {
+	headers, err := m.GetHeadersSlice()
+	if err != nil {
+		// 无法解析的头部整体隐藏
+		m.Headers = ""
+		return
+	}
+
+	for i, header := range headers {
+		name, _, _ := strings.Cut(header, ":")
+		headers[i] = strings.TrimSpace(name) + ": " + RedactedHeaderValue
+	}
+	m.SetHeaders(headers)
+}
+
+// RestoreRedactedHeaders replaces headers whose value is RedactedHeaderValue
+// with the value of the same header in existing, so clients can send back
+// redacted headers without overwriting them.
+func (m *MCPServerConfigModel) RestoreRedactedHeaders(existing *MCPServerConfigModel) error {
+	headers, err := m.GetHeadersSlice()
+	if err != nil || len(headers) == 0 {
+		return err
+	}
+	existingHeaders, err := existing.GetHeadersMap()
+	if err != nil {
+		existingHeaders = map[string]string{}
+	}
+
+	for i, header := range headers {
+		name, value, err := parseHeader(header)
+		if err != nil {
+			return err
+		}
+		if value != RedactedHeaderValue {
+			continue
+		}
+		stored, ok := existingHeaders[name]
+		if !ok {
+			return ErrMCPServerConfigHeaderRedacted
+		}
+		headers[i] = name + ": " + stored
+	}
+	return m.SetHeaders(headers)
+}
+
+// RedactEnv replaces the values of the environment variables with
+// RedactedHeaderValue, except values that only hold credential references and
+// are therefore not secret. Call it on a copy of the model.
+func (m *MCPServerConfigModel) RedactEnv() {
+	env, err := m.GetEnvMap()
+	if err != nil {
+		// 无法解析的环境变量整体隐藏
+		m.Env = ""
+		return
+	}
+
+	for k, v := range env {
+		if credentialReference.ReplaceAllString(v, "") != "" {
+			env[k] = RedactedHeaderValue
+		}
+	}
+	m.SetEnv(env)
+}
+
+// RestoreRedactedEnv replaces environment variables whose value is
+// RedactedHeaderValue with the value of the same variable in existing, like
+// RestoreRedactedHeaders
+func (m *MCPServerConfigModel) RestoreRedactedEnv(existing *MCPServerConfigModel) error {
+	env, err := m.GetEnvMap()
+	if err != nil || len(env) == 0 {
+		return err
+	}
+	existingEnv, err := existing.GetEnvMap()
+	if err != nil {
+		existingEnv = map[string]string{}
+	}
+
+	for k, v := range env {
+		if v != RedactedHeaderValue {
+			continue
+		}
+		stored, ok := existingEnv[k]
+		if !ok {
+			return ErrMCPServerConfigEnvRedacted
+		}
+		env[k] = stored
+	}
+	return m.SetEnv(env)
+}
+
+// parseHeader splits a header of the form "Name: Value"
+func parseHeader(header string) (string, string, error) {
+	name, value, ok := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return "", "", ErrMCPServerConfigHeaderInvalid
+	}
+	return name, strings.TrimSpace(value), nil
+}
@@ -2,8 +2,9 @@ package models
 
 import (
 	"testing"
+	"time"
 
-	"github.com/LubyRuffy/einomcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -77,6 +78,38 @@ func TestMCPServerConfigModel_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "MCP服务器传输类型无效，仅支持 stdio、sse 和 http",
 		},
+		{
+			name: "valid name prefix",
+			config: MCPServerConfigModel{
+				Name:          "test-server",
+				TransportType: "stdio",
+				Command:       "uvx",
+				NamePrefix:    "gh-1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "name prefix with invalid characters",
+			config: MCPServerConfigModel{
+				Name:          "test-server",
+				TransportType: "stdio",
+				Command:       "uvx",
+				NamePrefix:    "git hub",
+			},
+			wantErr: true,
+			errMsg:  "工具名前缀只能包含字母、数字、下划线和连字符，且不能包含连续的下划线",
+		},
+		{
+			name: "name prefix with separator",
+			config: MCPServerConfigModel{
+				Name:          "test-server",
+				TransportType: "stdio",
+				Command:       "uvx",
+				NamePrefix:    "git__hub",
+			},
+			wantErr: true,
+			errMsg:  "工具名前缀只能包含字母、数字、下划线和连字符，且不能包含连续的下划线",
+		},
 		{
 			name: "backward compatibility - empty transport type defaults to stdio",
 			config: MCPServerConfigModel{
@@ -113,7 +146,7 @@ func TestMCPServerConfigModel_ToServerConfig(t *testing.T) {
 	serverConfig, err := config.ToServerConfig()
 	assert.NoError(t, err)
 
-	expected := einomcphost.ServerConfig{
+	expected := mcphost.ServerConfig{
 		TransportType: "stdio",
 		Command:       "uvx",
 		Args:          []string{"duckduckgo-mcp-server"},
@@ -136,9 +169,10 @@ func TestMCPServerConfigModel_ToServerConfig_SSE(t *testing.T) {
 	serverConfig, err := config.ToServerConfig()
 	assert.NoError(t, err)
 
-	expected := einomcphost.ServerConfig{
+	expected := mcphost.ServerConfig{
 		TransportType: "sse",
 		URL:           "http://localhost:8000/sse",
+		Headers:       map[string]string{"Authorization": "Bearer token"},
 		Disabled:      false,
 	}
 
@@ -157,7 +191,7 @@ func TestMCPServerConfigModel_ToServerConfig_DefaultTransportType(t *testing.T)
 	serverConfig, err := config.ToServerConfig()
 	assert.NoError(t, err)
 
-	expected := einomcphost.ServerConfig{
+	expected := mcphost.ServerConfig{
 		TransportType: "stdio",
 		Command:       "uvx",
 		Args:          []string{"test-server"},
@@ -168,7 +202,7 @@ func TestMCPServerConfigModel_ToServerConfig_DefaultTransportType(t *testing.T)
 }
 
 func TestMCPServerConfigModel_FromServerConfig(t *testing.T) {
-	serverConfig := einomcphost.ServerConfig{
+	serverConfig := mcphost.ServerConfig{
 		Command:  "uvx",
 		Args:     []string{"duckduckgo-mcp-server"},
 		Env:      map[string]string{"TEST_VAR": "test_value"},
@@ -187,6 +221,20 @@ func TestMCPServerConfigModel_FromServerConfig(t *testing.T) {
 	assert.Equal(t, false, config.Disabled)
 }
 
+func TestMCPServerConfigModel_HTTPServerConfigRoundTrip(t *testing.T) {
+	serverConfig := mcphost.ServerConfig{TransportType: "http", URL: "http://localhost:8000/mcp"}
+
+	var config MCPServerConfigModel
+	assert.NoError(t, config.FromServerConfig("http-server", "", serverConfig))
+	assert.Equal(t, "http", config.TransportType)
+	assert.Equal(t, "http://localhost:8000/mcp", config.URL)
+
+	// 转换回mcphost配置时保留传输类型和URL
+	converted, err := config.ToServerConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, serverConfig, converted)
+}
+
 func TestMCPServerConfigModel_GetArgsSlice(t *testing.T) {
 	config := MCPServerConfigModel{
 		Args: `["arg1","arg2","arg3"]`,
@@ -291,3 +339,109 @@ func TestMCPServerConfigModel_SetHeaders(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "", config.Headers)
 }
+
+func TestMCPServerConfigModel_GetHeadersMap(t *testing.T) {
+	config := MCPServerConfigModel{Name: "sse-server", TransportType: "sse", URL: "https://example.com/sse"}
+	assert.NoError(t, config.SetHeaders([]string{"Authorization: Bearer token", "X-Trace:  a:b "}))
+
+	headers, err := config.GetHeadersMap()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer token", "X-Trace": "a:b"}, headers)
+	assert.NoError(t, config.Validate())
+
+	// 名称为空或缺少冒号的头部无效
+	for _, header := range []string{": value", "Authorization", "Bad Name: value"} {
+		assert.NoError(t, config.SetHeaders([]string{header}))
+		assert.ErrorIs(t, config.Validate(), ErrMCPServerConfigHeaderInvalid, header)
+	}
+}
+
+func TestMCPServerConfigModel_RedactHeaders(t *testing.T) {
+	stored := MCPServerConfigModel{}
+	assert.NoError(t, stored.SetHeaders([]string{"Authorization: Bearer token", "X-Team: red"}))
+
+	redacted := stored
+	redacted.RedactHeaders()
+	assert.Equal(t, `["Authorization: ******","X-Team: ******"]`, redacted.Headers)
+	assert.NotContains(t, redacted.Headers, "Bearer token")
+
+	// 发回隐藏的值时保留原值，修改过的值被更新
+	updates := MCPServerConfigModel{}
+	assert.NoError(t, updates.SetHeaders([]string{"Authorization: " + RedactedHeaderValue, "X-Team: blue"}))
+	assert.NoError(t, updates.RestoreRedactedHeaders(&stored))
+	headers, err := updates.GetHeadersSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Authorization: Bearer token", "X-Team: blue"}, headers)
+
+	// 原配置中不存在的头部不能使用隐藏的值
+	assert.NoError(t, updates.SetHeaders([]string{"X-New: " + RedactedHeaderValue}))
+	assert.ErrorIs(t, updates.RestoreRedactedHeaders(&stored), ErrMCPServerConfigHeaderRedacted)
+}
+
+func TestMCPServerConfigModel_RedactEnv(t *testing.T) {
+	stored := MCPServerConfigModel{}
+	assert.NoError(t, stored.SetEnv(map[string]string{"API_KEY": "secret", "TOKEN": "{{credential:token}}"}))
+
+	// 只包含凭据引用的值不是密钥，保持不变
+	redacted := stored
+	redacted.RedactEnv()
+	env, err := redacted.GetEnvMap()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_KEY": RedactedHeaderValue, "TOKEN": "{{credential:token}}"}, env)
+
+	updates := MCPServerConfigModel{}
+	assert.NoError(t, updates.SetEnv(map[string]string{"API_KEY": RedactedHeaderValue, "MODE": "fast"}))
+	assert.NoError(t, updates.RestoreRedactedEnv(&stored))
+	env, err = updates.GetEnvMap()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_KEY": "secret", "MODE": "fast"}, env)
+
+	assert.NoError(t, updates.SetEnv(map[string]string{"NEW": RedactedHeaderValue}))
+	assert.ErrorIs(t, updates.RestoreRedactedEnv(&stored), ErrMCPServerConfigEnvRedacted)
+}
+
+func TestMCPServerConfigModel_ToServerConfig_Invalid(t *testing.T) {
+	// 旧版本或直接修改数据库可能产生没有URL的sse配置
+	config := MCPServerConfigModel{
+		Name:          "legacy-sse",
+		TransportType: "sse",
+	}
+
+	_, err := config.ToServerConfig()
+	assert.ErrorIs(t, err, ErrMCPServerConfigURLEmpty)
+	assert.Contains(t, err.Error(), "legacy-sse")
+
+	config = MCPServerConfigModel{Name: "unknown", TransportType: "websocket", URL: "ws://localhost"}
+	_, err = config.ToServerConfig()
+	assert.ErrorIs(t, err, ErrMCPServerConfigInvalidTransportType)
+}
+
+func TestValidateServerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  mcphost.ServerConfig
+		wantErr error
+	}{
+		{name: "stdio", config: mcphost.ServerConfig{TransportType: "stdio", Command: "uvx"}},
+		{name: "default transport", config: mcphost.ServerConfig{Command: "uvx"}},
+		{name: "sse", config: mcphost.ServerConfig{TransportType: "sse", URL: "http://localhost:8000/sse"}},
+		{name: "disabled is still checked", config: mcphost.ServerConfig{TransportType: "sse", Disabled: true}, wantErr: ErrMCPServerConfigURLEmpty},
+		{name: "blank command", config: mcphost.ServerConfig{TransportType: "stdio", Command: "  "}, wantErr: ErrMCPServerConfigCommandEmpty},
+		{name: "blank url", config: mcphost.ServerConfig{TransportType: "http", URL: " "}, wantErr: ErrMCPServerConfigURLEmpty},
+		{name: "unknown transport", config: mcphost.ServerConfig{TransportType: "grpc"}, wantErr: ErrMCPServerConfigInvalidTransportType},
+		{name: "timeout too small", config: mcphost.ServerConfig{Command: "uvx", Timeout: time.Second}, wantErr: ErrMCPServerConfigTimeoutTooSmall},
+		{name: "timeout negative", config: mcphost.ServerConfig{Command: "uvx", Timeout: -time.Second}, wantErr: ErrMCPServerConfigTimeoutNegative},
+		{name: "timeout", config: mcphost.ServerConfig{Command: "uvx", Timeout: time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateServerConfig("test-server", tt.config)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Contains(t, err.Error(), "test-server")
+		})
+	}
+}
@@ -0,0 +1,22 @@
+// Package models provides database models for the MCP Agent application.
+// It defines the data structures used for persistent storage of MCP server health checks.
+package models
+
+import (
+	"time"
+)
+
+// MCPServerHealthModel stores the result of one health check of an MCP server
+type MCPServerHealthModel struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ServerID  uint      `gorm:"index;not null" json:"server_id"`  // 关联的MCP服务器ID
+	OK        bool      `gorm:"not null" json:"ok"`               // 检查是否成功
+	LatencyMs int64     `json:"latency_ms"`                       // 检查耗时（毫秒）
+	Error     string    `gorm:"type:text" json:"error,omitempty"` // 失败原因
+	CheckedAt time.Time `gorm:"index;not null" json:"checked_at"` // 检查时间
+}
+
+// TableName returns the table name for MCPServerHealthModel
+func (MCPServerHealthModel) TableName() string {
+	return "mcp_server_healths"
+}
@@ -14,18 +14,38 @@ import (
 // MCPToolModel represents a MCP tool stored in the database.
 // It stores tool information with metadata for management and caching.
 type MCPToolModel struct {
-	ID          uint                 `gorm:"primarykey" json:"id"`
-	Name        string               `gorm:"not null;index" json:"name"`           // 工具名称
-	Description string               `gorm:"type:text" json:"description"`         // 工具描述
-	ServerID    uint                 `gorm:"not null;index" json:"server_id"`      // 关联的MCP服务器ID
-	Server      MCPServerConfigModel `gorm:"foreignKey:ServerID" json:"server"`    // 关联的MCP服务器
-	InputSchema string               `gorm:"type:text" json:"input_schema"`        // 输入模式（JSON格式存储）
-	ToolKey     string               `gorm:"uniqueIndex;not null" json:"tool_key"` // 工具唯一标识（server_name + "_" + tool_name）
-	IsActive    bool                 `json:"is_active"`                            // 是否启用
-	LastSyncAt  *time.Time           `json:"last_sync_at"`                         // 最后同步时间
-	CreatedAt   time.Time            `json:"created_at"`
-	UpdatedAt   time.Time            `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt       `gorm:"index" json:"-"`
+	ID           uint                 `gorm:"primarykey" json:"id"`
+	Name         string               `gorm:"not null;index" json:"name"`           // 工具名称
+	Description  string               `gorm:"type:text" json:"description"`         // 工具描述
+	ServerID     uint                 `gorm:"not null;index" json:"server_id"`      // 关联的MCP服务器ID
+	Server       MCPServerConfigModel `gorm:"foreignKey:ServerID" json:"server"`    // 关联的MCP服务器
+	InputSchema  string               `gorm:"type:text" json:"input_schema"`        // 输入模式（JSON格式存储）
+	ToolKey      string               `gorm:"uniqueIndex;not null" json:"tool_key"` // 工具唯一标识（server_name + "_" + tool_name）
+	IsActive     bool                 `json:"is_active"`                            // 是否启用
+	LastSyncAt   *time.Time           `json:"last_sync_at"`                         // 最后同步时间
+	ResultFilter string               `gorm:"type:text" json:"result_filter"`       // 应用于JSON结果的jq表达式
+	OutputFormat string               `json:"output_format"`                        // 声明的结果格式，为空时按内容判断
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt       `gorm:"index" json:"-"`
+
+	LocalizedDescriptions string `gorm:"type:text" json:"localized_descriptions"` // 按语言存储的工具描述（JSON格式存储），如 {"zh": "..."}
+}
+
+// Content formats of the text pushed to the web UI, see MCPToolModel.OutputFormat
+const (
+	ContentFormatPlain    = "plain"
+	ContentFormatMarkdown = "markdown"
+	ContentFormatJSON     = "json"
+)
+
+// ValidContentFormat reports whether format is one of the ContentFormat values
+func ValidContentFormat(format string) bool {
+	switch format {
+	case ContentFormatPlain, ContentFormatMarkdown, ContentFormatJSON:
+		return true
+	}
+	return false
 }
 
 // TableName returns the table name for MCPToolModel
@@ -76,6 +96,46 @@ func (m *MCPToolModel) GetInputSchema() (map[string]interface{}, error) {
 	return schema, nil
 }
 
+// SetLocalizedDescriptions sets the localized descriptions from a map keyed
+// by language. Empty descriptions are dropped.
+func (m *MCPToolModel) SetLocalizedDescriptions(descriptions map[string]string) error {
+	cleaned := make(map[string]string, len(descriptions))
+	for language, description := range descriptions {
+		language = strings.TrimSpace(language)
+		description = strings.TrimSpace(description)
+		if language == "" {
+			return ErrMCPToolDescriptionLanguageEmpty
+		}
+		if description != "" {
+			cleaned[language] = description
+		}
+	}
+	if len(cleaned) == 0 {
+		m.LocalizedDescriptions = ""
+		return nil
+	}
+
+	data, err := json.Marshal(cleaned)
+	if err != nil {
+		return err
+	}
+	m.LocalizedDescriptions = string(data)
+	return nil
+}
+
+// GetLocalizedDescriptions returns the localized descriptions keyed by language
+func (m *MCPToolModel) GetLocalizedDescriptions() (map[string]string, error) {
+	if m.LocalizedDescriptions == "" {
+		return nil, nil
+	}
+
+	var descriptions map[string]string
+	if err := json.Unmarshal([]byte(m.LocalizedDescriptions), &descriptions); err != nil {
+		return nil, err
+	}
+	return descriptions, nil
+}
+
 // GenerateToolKey generates the tool key from server name and tool name
 func GenerateToolKey(serverName, toolName string) string {
 	// 检查并处理特殊字符
@@ -105,35 +165,60 @@ func GenerateToolKey(serverName, toolName string) string {
 
 // MCPToolInfo represents tool information for API responses
 type MCPToolInfo struct {
-	ID          uint       `json:"id"`
-	Name        string     `json:"name"`
-	Description string     `json:"description"`
-	Server      string     `json:"server"`
-	ToolKey     string     `json:"tool_key"`
-	IsActive    bool       `json:"is_active"`
-	LastSyncAt  *time.Time `json:"last_sync_at,omitempty"`
+	ID           uint           `json:"id"`
+	Name         string         `json:"name"`
+	Description  string         `json:"description"`
+	Server       string         `json:"server"`
+	ToolKey      string         `json:"tool_key"`
+	IsActive     bool           `json:"is_active"`
+	LastSyncAt   *time.Time     `json:"last_sync_at,omitempty"`
+	UsageCount   int64          `json:"usage_count"`             // 调用次数
+	LastUsedAt   *time.Time     `json:"last_used_at,omitempty"`  // 最后调用时间
+	InputSchema  map[string]any `json:"input_schema,omitempty"`  // 输入参数的JSON Schema
+	ResultFilter string         `json:"result_filter,omitempty"` // 应用于JSON结果的jq表达式
+	OutputFormat string         `json:"output_format,omitempty"` // 声明的结果格式：plain、markdown 或 json
+
+	LocalizedDescriptions map[string]string `json:"localized_descriptions,omitempty"` // 按语言的工具描述，设置 tool_description_language 时代替原描述提供给大模型
+}
+
+// ToolParameter describes an input of a tool as a flat form field.
+// Fields of nested objects are named by their dotted path, such as "options.limit".
+type ToolParameter struct {
+	Name        string `json:"name"`                  // 参数路径，嵌套对象的字段以点分隔
+	Type        string `json:"type"`                  // 参数类型：string、number、integer、boolean、array 或 object
+	ItemType    string `json:"item_type,omitempty"`   // 数组元素的类型，仅array类型
+	Description string `json:"description,omitempty"` // 参数描述
+	Required    bool   `json:"required"`              // 是否必填，嵌套字段仅在其所属对象也必填时必填
+	Enum        []any  `json:"enum,omitempty"`        // 可选值，数组类型时为元素的可选值
+	Default     any    `json:"default,omitempty"`     // 默认值
 }
 
 // ToMCPToolInfo converts MCPToolModel to MCPToolInfo
 func (m *MCPToolModel) ToMCPToolInfo() MCPToolInfo {
-	serverName := ""
-	if m.Server.ID != 0 {
-		serverName = m.Server.Name
-	} else {
-		// 从 ToolKey 中提取服务器名称（格式为 serverName_toolName）
-		parts := strings.SplitN(m.ToolKey, "_", 2)
-		if len(parts) > 0 {
-			serverName = parts[0]
-		}
+	// 服务器名称只取自关联的服务器：名称含下划线时无法从 ToolKey 中正确解析
+	serverName := m.Server.Name
+
+	inputSchema, err := m.GetInputSchema()
+	if err != nil {
+		log.Printf("解析工具 %s 的输入模式失败: %v", m.ToolKey, err)
+	}
+	descriptions, err := m.GetLocalizedDescriptions()
+	if err != nil {
+		log.Printf("解析工具 %s 的本地化描述失败: %v", m.ToolKey, err)
 	}
 
 	return MCPToolInfo{
-		ID:          m.ID,
-		Name:        m.Name,
-		Description: m.Description,
-		Server:      serverName,
-		ToolKey:     m.ToolKey,
-		IsActive:    m.IsActive,
-		LastSyncAt:  m.LastSyncAt,
+		ID:           m.ID,
+		Name:         m.Name,
+		Description:  m.Description,
+		Server:       serverName,
+		ToolKey:      m.ToolKey,
+		IsActive:     m.IsActive,
+		LastSyncAt:   m.LastSyncAt,
+		InputSchema:  inputSchema,
+		ResultFilter: m.ResultFilter,
+		OutputFormat: m.OutputFormat,
+
+		LocalizedDescriptions: descriptions,
 	}
 }
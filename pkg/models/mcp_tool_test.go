@@ -228,6 +228,24 @@ func TestMCPToolModel_ToMCPToolInfo(t *testing.T) {
 	assert.Equal(t, &now, info.LastSyncAt)
 }
 
+func TestMCPToolModel_LocalizedDescriptions(t *testing.T) {
+	var tool MCPToolModel
+	descriptions, err := tool.GetLocalizedDescriptions()
+	assert.NoError(t, err)
+	assert.Nil(t, descriptions)
+
+	assert.NoError(t, tool.SetLocalizedDescriptions(map[string]string{" zh ": " 搜索网页 ", "en": " "}))
+	descriptions, err = tool.GetLocalizedDescriptions()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"zh": "搜索网页"}, descriptions)
+	assert.Equal(t, descriptions, tool.ToMCPToolInfo().LocalizedDescriptions)
+
+	assert.ErrorIs(t, tool.SetLocalizedDescriptions(map[string]string{"": "描述"}), ErrMCPToolDescriptionLanguageEmpty)
+
+	assert.NoError(t, tool.SetLocalizedDescriptions(nil))
+	assert.Empty(t, tool.LocalizedDescriptions)
+}
+
 func TestMCPToolModel_TableName(t *testing.T) {
 	tool := MCPToolModel{}
 	assert.Equal(t, "mcp_tools", tool.TableName())
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
+	"gorm.io/gorm/schema"
+)
+
+// SecretSerializerName is the name of the serializer encrypting string columns,
+// use it as `gorm:"serializer:secret"` on fields holding credentials
+const SecretSerializerName = "secret"
+
+func init() {
+	schema.RegisterSerializer(SecretSerializerName, SecretSerializer{})
+}
+
+// SecretSerializer encrypts string fields with the default cipher of the
+// secret package when they are written and decrypts them when they are read.
+// Plaintext values written before encryption was introduced are read unchanged.
+type SecretSerializer struct{}
+
+// Scan implements schema.SerializerInterface, it decrypts the database value into the field
+func (SecretSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("字段 %s 的类型无效: %T", field.Name, dbValue)
+	}
+
+	plaintext, err := secret.Decrypt(value)
+	if err != nil {
+		return fmt.Errorf("解密字段 %s 失败: %w", field.Name, err)
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+// Value implements schema.SerializerValuerInterface, it returns the encrypted field value
+func (SecretSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("字段 %s 的类型无效: %T", field.Name, fieldValue)
+	}
+	encrypted, err := secret.Encrypt(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("加密字段 %s 失败: %w", field.Name, err)
+	}
+	return encrypted, nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// useTestCipher sets a default cipher for the test and restores the previous one afterwards
+func useTestCipher(t *testing.T, key string) {
+	previous := secret.Default()
+	c, err := secret.NewCipher([]byte(key))
+	require.NoError(t, err)
+	secret.SetDefault(c)
+	t.Cleanup(func() { secret.SetDefault(previous) })
+}
+
+func TestSecretSerializerEncryptsColumns(t *testing.T) {
+	useTestCipher(t, "test-key")
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&LLMConfigModel{}, &MCPServerConfigModel{}))
+
+	llm := &LLMConfigModel{Name: "openai", Type: "openai", BaseURL: "https://api.openai.com/v1", Model: "gpt-4", APIKey: "sk-secret"}
+	require.NoError(t, db.Create(llm).Error)
+	server := &MCPServerConfigModel{Name: "github", TransportType: "sse", URL: "http://127.0.0.1/sse"}
+	require.NoError(t, server.SetEnv(map[string]string{"GITHUB_TOKEN": "ghp-secret"}))
+	require.NoError(t, server.SetHeaders([]string{"Authorization: Bearer token-secret"}))
+	require.NoError(t, db.Create(server).Error)
+
+	// 数据库中保存的是密文
+	var raw []string
+	require.NoError(t, db.Table("llm_configs").Where("id = ?", llm.ID).Pluck("api_key", &raw).Error)
+	for _, column := range []string{"env", "headers"} {
+		var values []string
+		require.NoError(t, db.Table("mcp_server_configs").Where("id = ?", server.ID).Pluck(column, &values).Error)
+		raw = append(raw, values...)
+	}
+	require.Len(t, raw, 3)
+	for _, value := range raw {
+		assert.True(t, secret.IsEncrypted(value), value)
+		assert.NotContains(t, value, "secret")
+	}
+
+	// 读取时自动解密，更新时重新加密
+	var loaded LLMConfigModel
+	require.NoError(t, db.First(&loaded, llm.ID).Error)
+	assert.Equal(t, "sk-secret", loaded.APIKey)
+	require.NoError(t, db.Model(&loaded).Updates(&LLMConfigModel{APIKey: "sk-rotated"}).Error)
+	require.NoError(t, db.First(&loaded, llm.ID).Error)
+	assert.Equal(t, "sk-rotated", loaded.APIKey)
+
+	var loadedServer MCPServerConfigModel
+	require.NoError(t, db.First(&loadedServer, server.ID).Error)
+	env, err := loadedServer.GetEnvMap()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"GITHUB_TOKEN": "ghp-secret"}, env)
+	headers, err := loadedServer.GetHeadersSlice()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Authorization: Bearer token-secret"}, headers)
+
+	// 旧版本保存的明文原样读取
+	require.NoError(t, db.Table("llm_configs").Where("id = ?", llm.ID).UpdateColumn("api_key", "sk-legacy").Error)
+	require.NoError(t, db.First(&loaded, llm.ID).Error)
+	assert.Equal(t, "sk-legacy", loaded.APIKey)
+
+	// 使用错误的密钥读取失败
+	require.NoError(t, db.Model(&loaded).Updates(&LLMConfigModel{APIKey: "sk-secret"}).Error)
+	useTestCipher(t, "wrong-key")
+	err = db.First(&loaded, llm.ID).Error
+	assert.ErrorIs(t, err, secret.ErrDecrypt)
+}
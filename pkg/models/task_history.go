@@ -0,0 +1,75 @@
+// Package models provides database models for the MCP Agent application.
+// It defines the data structures used for persistent storage of task runs.
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
+)
+
+// Task status values stored in TaskHistoryModel.Status
+const (
+	TaskStatusRunning   = "running"
+	TaskStatusCompleted = "completed"
+	TaskStatusError     = "error"
+	TaskStatusTimeout   = "timeout"
+	TaskStatusCanceled  = "canceled"
+
+	// TaskStatusInterrupted marks a task that was still running when the web server stopped
+	TaskStatusInterrupted = "interrupted"
+)
+
+// TaskHistoryModel stores a task run of the web server. A continued or
+// resumed task references the task it follows through ParentTaskID.
+// Checkpoint holds the last mcpagent.Checkpoint of the task as JSON, Notes
+// the notes of a task with agent.enable_notes as a JSON array.
+type TaskHistoryModel struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	TaskID       string     `gorm:"uniqueIndex;not null" json:"task_id"`   // 任务ID
+	ParentTaskID string     `gorm:"index" json:"parent_task_id,omitempty"` // 被继续的任务ID
+	Task         string     `gorm:"type:text;not null" json:"task"`        // 任务描述或追加指令
+	Config       string     `gorm:"type:text" json:"-"`                    // 加密的配置快照JSON，包含API密钥
+	Status       string     `gorm:"index;not null" json:"status"`          // running、completed、error、timeout、canceled 或 interrupted
+	Result       string     `gorm:"type:text" json:"result,omitempty"`     // 最终结果
+	Notes        string     `gorm:"type:text" json:"-"`                    // 任务结束时保存的笔记JSON
+	Error        string     `gorm:"type:text" json:"error,omitempty"`      // 失败原因
+	ErrorCode    string     `json:"error_code,omitempty"`                  // 稳定的错误码
+	Model        string     `json:"model,omitempty"`                       // 产生最终结果的模型，可能是备用模型
+	StartedAt    time.Time  `gorm:"index;not null" json:"started_at"`      // 开始时间
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`                 // 结束时间
+
+	Checkpoint     string     `gorm:"type:text;serializer:secret" json:"-"` // 最近一次检查点的JSON，加密保存
+	CheckpointStep int        `json:"checkpoint_step,omitempty"`            // 最近一次检查点的模型调用序号
+	CheckpointAt   *time.Time `json:"checkpoint_at,omitempty"`              // 最近一次检查点的时间
+}
+
+// TableName returns the table name for TaskHistoryModel
+func (TaskHistoryModel) TableName() string {
+	return "task_histories"
+}
+
+// IsFinished reports whether the task is no longer running
+func (t *TaskHistoryModel) IsFinished() bool {
+	return t.Status != TaskStatusRunning
+}
+
+// GetConfig returns the decrypted configuration snapshot JSON
+func (t *TaskHistoryModel) GetConfig() ([]byte, error) {
+	data, err := secret.Decrypt(t.Config)
+	if err != nil {
+		return nil, fmt.Errorf("解密任务配置失败: %w", err)
+	}
+	return []byte(data), nil
+}
+
+// SetConfig encrypts and sets the configuration snapshot JSON
+func (t *TaskHistoryModel) SetConfig(data []byte) error {
+	encrypted, err := secret.Encrypt(string(data))
+	if err != nil {
+		return fmt.Errorf("加密任务配置失败: %w", err)
+	}
+	t.Config = encrypted
+	return nil
+}
@@ -0,0 +1,309 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Types of task template parameters
+const (
+	TemplateParamTypeString  = "string"
+	TemplateParamTypeNumber  = "number"
+	TemplateParamTypeInteger = "integer"
+	TemplateParamTypeBoolean = "boolean"
+)
+
+var (
+	// validTemplateParamName matches parameter names usable as {name} placeholders
+	validTemplateParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// templatePlaceholder matches the {name} placeholders of a task template
+	templatePlaceholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// TaskTemplateParam declares a parameter of a task template
+type TaskTemplateParam struct {
+	Name        string `json:"name"`                  // 参数名称，在模板中以{name}引用
+	Type        string `json:"type,omitempty"`        // 参数类型：string、number、integer 或 boolean，默认string
+	Description string `json:"description,omitempty"` // 参数描述
+	Required    bool   `json:"required,omitempty"`    // 是否必填，有默认值时可以不提供
+	Default     any    `json:"default,omitempty"`     // 默认值，需与参数类型一致
+}
+
+// paramType returns the type of the parameter, string if not set
+func (p TaskTemplateParam) paramType() string {
+	if p.Type == "" {
+		return TemplateParamTypeString
+	}
+	return p.Type
+}
+
+// TaskTemplateModel represents a named task with {param} placeholders in the database.
+// Rendering it with parameter values produces the task text, optionally together
+// with a preset of tools and an LLM config to run it with.
+type TaskTemplateModel struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	Name        string         `gorm:"uniqueIndex;not null" json:"name"`   // 模板名称，命令行中使用名称引用
+	Description string         `gorm:"type:text" json:"description"`       // 模板描述
+	Template    string         `gorm:"type:text;not null" json:"template"` // 任务内容，参数以{name}引用
+	Parameters  JSON           `gorm:"type:json" json:"parameters"`        // 声明的参数列表，JSON格式存储
+	Tools       JSON           `gorm:"type:json" json:"tools"`             // 默认使用的工具列表，JSON格式存储
+	LLMConfigID *uint          `json:"llm_config_id,omitempty"`            // 默认使用的LLM配置
+	IsActive    bool           `gorm:"default:true" json:"is_active"`      // 是否启用
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName returns the table name for TaskTemplateModel
+func (TaskTemplateModel) TableName() string {
+	return "task_templates"
+}
+
+// Validate validates the task template.
+// It ensures the name and the template are present and every parameter is well
+// formed, unique and has a default matching its type.
+func (t *TaskTemplateModel) Validate() error {
+	if t.Name == "" {
+		return ErrTaskTemplateNameEmpty
+	}
+	if strings.TrimSpace(t.Template) == "" {
+		return ErrTaskTemplateContentEmpty
+	}
+
+	params, err := t.GetParameters()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTaskTemplateParamInvalid, err)
+	}
+	seen := make(map[string]bool, len(params))
+	for i, param := range params {
+		if !validTemplateParamName.MatchString(param.Name) {
+			return fmt.Errorf("%w: 第%d个参数的名称 %q 只能包含字母、数字和下划线，且不能以数字开头", ErrTaskTemplateParamInvalid, i+1, param.Name)
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("%w: 参数 %s 重复", ErrTaskTemplateParamInvalid, param.Name)
+		}
+		seen[param.Name] = true
+
+		switch param.paramType() {
+		case TemplateParamTypeString, TemplateParamTypeNumber, TemplateParamTypeInteger, TemplateParamTypeBoolean:
+		default:
+			return fmt.Errorf("%w: 参数 %s 的类型 %q 无效，仅支持 string、number、integer 和 boolean", ErrTaskTemplateParamInvalid, param.Name, param.Type)
+		}
+		if param.Default != nil {
+			if _, err := formatTemplateValue(param.paramType(), param.Default); err != nil {
+				return fmt.Errorf("%w: 参数 %s 的默认值%v", ErrTaskTemplateParamInvalid, param.Name, err)
+			}
+		}
+	}
+
+	if _, err := t.GetTools(); err != nil {
+		return fmt.Errorf("%w: %v", ErrTaskTemplateToolsInvalid, err)
+	}
+	return nil
+}
+
+// GetParameters returns the declared parameters
+func (t *TaskTemplateModel) GetParameters() ([]TaskTemplateParam, error) {
+	params := []TaskTemplateParam{}
+	if len(t.Parameters) == 0 {
+		return params, nil
+	}
+	if err := json.Unmarshal(t.Parameters, &params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// SetParameters sets the declared parameters
+func (t *TaskTemplateModel) SetParameters(params []TaskTemplateParam) error {
+	if params == nil {
+		params = []TaskTemplateParam{}
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	t.Parameters = JSON(data)
+	return nil
+}
+
+// GetTools returns the preset of tools, empty if the template has none
+func (t *TaskTemplateModel) GetTools() ([]MCPToolConfig, error) {
+	tools := []MCPToolConfig{}
+	if len(t.Tools) == 0 {
+		return tools, nil
+	}
+	if err := json.Unmarshal(t.Tools, &tools); err != nil {
+		return nil, err
+	}
+	return tools, nil
+}
+
+// SetTools sets the preset of tools
+func (t *TaskTemplateModel) SetTools(tools []MCPToolConfig) error {
+	if tools == nil {
+		tools = []MCPToolConfig{}
+	}
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return err
+	}
+	t.Tools = JSON(data)
+	return nil
+}
+
+// TemplateRenderError lists every problem of the parameters given to Render
+type TemplateRenderError struct {
+	Missing []string          // 未提供且没有默认值的必填参数
+	Extra   []string          // 模板未声明的参数
+	Invalid map[string]string // 类型不匹配的参数及原因
+}
+
+// Error implements the error interface
+func (e *TemplateRenderError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "缺少参数 "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Extra) > 0 {
+		parts = append(parts, "多余参数 "+strings.Join(e.Extra, ", "))
+	}
+	for _, name := range e.InvalidNames() {
+		parts = append(parts, fmt.Sprintf("参数 %s %s", name, e.Invalid[name]))
+	}
+	return ErrTaskTemplateParamsInvalid.Error() + ": " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns ErrTaskTemplateParamsInvalid
+func (e *TemplateRenderError) Unwrap() error {
+	return ErrTaskTemplateParamsInvalid
+}
+
+// InvalidNames returns the names of the parameters with invalid values, sorted
+func (e *TemplateRenderError) InvalidNames() []string {
+	names := make([]string, 0, len(e.Invalid))
+	for name := range e.Invalid {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render substitutes the {name} placeholders of the template with params.
+// Absent parameters use their default, optional parameters without a default
+// become empty. String values are converted to the declared type, so values
+// given on the command line are accepted for every type. Placeholders that
+// do not name a declared parameter are kept as they are.
+//
+// Parameters:
+//   - params: Parameter values by name
+//
+// Returns:
+//   - string: Rendered task text
+//   - error: *TemplateRenderError listing missing, extra and invalid parameters
+func (t *TaskTemplateModel) Render(params map[string]any) (string, error) {
+	declared, err := t.GetParameters()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTaskTemplateParamInvalid, err)
+	}
+
+	renderErr := &TemplateRenderError{Invalid: map[string]string{}}
+	values := make(map[string]string, len(declared))
+	known := make(map[string]bool, len(declared))
+	for _, param := range declared {
+		known[param.Name] = true
+		value, ok := params[param.Name]
+		if !ok || value == nil {
+			value = param.Default
+		}
+		if value == nil {
+			if param.Required {
+				renderErr.Missing = append(renderErr.Missing, param.Name)
+			}
+			values[param.Name] = ""
+			continue
+		}
+		text, err := formatTemplateValue(param.paramType(), value)
+		if err != nil {
+			renderErr.Invalid[param.Name] = err.Error()
+			continue
+		}
+		values[param.Name] = text
+	}
+	for name := range params {
+		if !known[name] {
+			renderErr.Extra = append(renderErr.Extra, name)
+		}
+	}
+	sort.Strings(renderErr.Extra)
+
+	if len(renderErr.Missing) > 0 || len(renderErr.Extra) > 0 || len(renderErr.Invalid) > 0 {
+		return "", renderErr
+	}
+
+	return templatePlaceholder.ReplaceAllStringFunc(t.Template, func(match string) string {
+		if text, ok := values[match[1:len(match)-1]]; ok {
+			return text
+		}
+		return match
+	}), nil
+}
+
+// formatTemplateValue checks value against the parameter type and returns its text
+func formatTemplateValue(paramType string, value any) (string, error) {
+	typeErr := fmt.Errorf("类型应为%s，实际为 %v", paramType, value)
+	switch paramType {
+	case TemplateParamTypeString:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+	case TemplateParamTypeNumber:
+		if f, ok := templateNumber(value); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+	case TemplateParamTypeInteger:
+		if f, ok := templateNumber(value); ok && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return strconv.FormatInt(int64(f), 10), nil
+		}
+	case TemplateParamTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return strconv.FormatBool(b), nil
+			}
+		}
+	}
+	return "", typeErr
+}
+
+// templateNumber converts a JSON number, a Go number or a numeric string to float64
+func templateNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil && !math.IsNaN(f)
+	}
+	return 0, false
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTaskTemplate(t *testing.T) *TaskTemplateModel {
+	template := &TaskTemplateModel{
+		Name:     "域名侦察",
+		Template: "收集{domain}的子域名，深度{depth}，包含历史记录: {history}，格式 {\"raw\": true}",
+	}
+	require.NoError(t, template.SetParameters([]TaskTemplateParam{
+		{Name: "domain", Required: true},
+		{Name: "depth", Type: TemplateParamTypeInteger, Default: 1},
+		{Name: "history", Type: TemplateParamTypeBoolean},
+	}))
+	return template
+}
+
+func TestTaskTemplateRender(t *testing.T) {
+	template := newTestTaskTemplate(t)
+	require.NoError(t, template.Validate())
+
+	task, err := template.Render(map[string]any{"domain": "example.com", "depth": float64(3), "history": true})
+	require.NoError(t, err)
+	assert.Equal(t, "收集example.com的子域名，深度3，包含历史记录: true，格式 {\"raw\": true}", task)
+
+	// 命令行传入的字符串按声明的类型转换，未提供的参数使用默认值
+	task, err = template.Render(map[string]any{"domain": "example.com", "history": "false"})
+	require.NoError(t, err)
+	assert.Equal(t, "收集example.com的子域名，深度1，包含历史记录: false，格式 {\"raw\": true}", task)
+}
+
+func TestTaskTemplateRenderErrors(t *testing.T) {
+	template := newTestTaskTemplate(t)
+
+	_, err := template.Render(map[string]any{"depth": "1.5", "target": "x", "mode": "y"})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrTaskTemplateParamsInvalid))
+
+	var renderErr *TemplateRenderError
+	require.True(t, errors.As(err, &renderErr))
+	assert.Equal(t, []string{"domain"}, renderErr.Missing)
+	assert.Equal(t, []string{"mode", "target"}, renderErr.Extra)
+	assert.Equal(t, []string{"depth"}, renderErr.InvalidNames())
+	assert.Contains(t, err.Error(), "缺少参数 domain")
+	assert.Contains(t, err.Error(), "多余参数 mode, target")
+}
+
+func TestTaskTemplateValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []TaskTemplateParam
+		want   error
+	}{
+		{"无效名称", []TaskTemplateParam{{Name: "1st"}}, ErrTaskTemplateParamInvalid},
+		{"重复参数", []TaskTemplateParam{{Name: "a"}, {Name: "a"}}, ErrTaskTemplateParamInvalid},
+		{"无效类型", []TaskTemplateParam{{Name: "a", Type: "date"}}, ErrTaskTemplateParamInvalid},
+		{"默认值类型不匹配", []TaskTemplateParam{{Name: "a", Type: TemplateParamTypeNumber, Default: "many"}}, ErrTaskTemplateParamInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &TaskTemplateModel{Name: "t", Template: "{a}"}
+			require.NoError(t, template.SetParameters(tt.params))
+			assert.ErrorIs(t, template.Validate(), tt.want)
+		})
+	}
+
+	assert.ErrorIs(t, (&TaskTemplateModel{Template: "x"}).Validate(), ErrTaskTemplateNameEmpty)
+	assert.ErrorIs(t, (&TaskTemplateModel{Name: "t", Template: " "}).Validate(), ErrTaskTemplateContentEmpty)
+}
@@ -0,0 +1,24 @@
+// Package models provides database models for the MCP Agent application.
+// It defines the data structures used for persistent storage of previous tool results.
+package models
+
+import (
+	"time"
+)
+
+// ToolResultSnapshotModel stores the latest result of a tool call, so that
+// tools with diff_against_previous can compare the next call with it.
+// Rows are keyed by a hash of the tool and its arguments.
+type ToolResultSnapshotModel struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CallKey   string    `gorm:"uniqueIndex;not null" json:"call_key"` // 工具和参数的哈希
+	ToolKey   string    `gorm:"index;not null" json:"tool_key"`       // 工具唯一标识（server_name + "_" + tool_name）
+	Result    string    `gorm:"type:text" json:"result"`              // 最近一次的工具结果
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for ToolResultSnapshotModel
+func (ToolResultSnapshotModel) TableName() string {
+	return "tool_result_snapshots"
+}
@@ -0,0 +1,41 @@
+// Package models provides database models for the MCP Agent application.
+// It defines the data structures used for persistent storage of tool usage statistics.
+package models
+
+import (
+	"time"
+)
+
+// ToolUsageModel stores aggregated invocation statistics of a tool.
+// Rows are keyed by tool_key and updated with atomic increments.
+type ToolUsageModel struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	ToolKey         string     `gorm:"uniqueIndex;not null" json:"tool_key"`        // 工具唯一标识（server_name + "_" + tool_name）
+	Calls           int64      `gorm:"not null;default:0" json:"calls"`             // 调用次数
+	Errors          int64      `gorm:"not null;default:0" json:"errors"`            // 调用失败次数
+	TotalDurationMs int64      `gorm:"not null;default:0" json:"total_duration_ms"` // 累计耗时（毫秒）
+	LastUsedAt      *time.Time `json:"last_used_at"`                                // 最后调用时间
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table name for ToolUsageModel
+func (ToolUsageModel) TableName() string {
+	return "tool_usages"
+}
+
+// ErrorRate returns the ratio of failed calls, 0 if the tool was never called
+func (u *ToolUsageModel) ErrorRate() float64 {
+	if u.Calls == 0 {
+		return 0
+	}
+	return float64(u.Errors) / float64(u.Calls)
+}
+
+// AvgDurationMs returns the average call duration in milliseconds
+func (u *ToolUsageModel) AvgDurationMs() int64 {
+	if u.Calls == 0 {
+		return 0
+	}
+	return u.TotalDurationMs / u.Calls
+}
@@ -0,0 +1,214 @@
+// Package notes keeps short notes the agent saves for itself during a task,
+// so facts found in early steps stay available through the save_note,
+// list_notes and get_note tools after the conversation has grown long.
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxTotalSize is the number of bytes of keys and contents kept per task
+	DefaultMaxTotalSize = 64 << 10
+
+	// MaxKeyLength is the maximum number of bytes of a note key
+	MaxKeyLength = 128
+
+	errMsgKeyEmpty        = "笔记的key不能为空"
+	errMsgKeyTooLong      = "笔记的key不能超过%d字节"
+	errMsgNoteTooLarge    = "笔记 %s 共%d字节，超过了笔记的总大小上限（%d字节）"
+	errMsgNoteNotFound    = "笔记 %s 不存在或已被淘汰"
+	errMsgMaxTotalSizeNeg = "笔记的总大小上限不能为负数: %d"
+)
+
+// Note is a note kept by a Store
+type Note struct {
+	Key       string    `json:"key"`
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// size returns the bytes a note counts against the total size cap
+func (n *Note) size() int {
+	return len(n.Key) + len(n.Content)
+}
+
+// Store keeps the notes of one task in memory. When the total size cap is
+// exceeded the least recently used notes are evicted. It is safe for
+// concurrent use, since tools may run in parallel.
+type Store struct {
+	maxTotalSize int
+
+	mu        sync.Mutex
+	totalSize int
+	order     []string // 按最近使用时间排列的key，最久未使用的在前
+	notes     map[string]*Note
+}
+
+// NewStore creates an empty store.
+//
+// Parameters:
+//   - maxTotalSize: Maximum bytes of keys and contents kept, DefaultMaxTotalSize if 0
+//
+// Returns:
+//   - *Store: Empty store
+//   - error: Error if maxTotalSize is negative
+func NewStore(maxTotalSize int) (*Store, error) {
+	if maxTotalSize < 0 {
+		return nil, fmt.Errorf(errMsgMaxTotalSizeNeg, maxTotalSize)
+	}
+	if maxTotalSize == 0 {
+		maxTotalSize = DefaultMaxTotalSize
+	}
+	return &Store{maxTotalSize: maxTotalSize, notes: make(map[string]*Note)}, nil
+}
+
+// Save stores content under key, replacing an earlier note of the key. The
+// least recently used notes are evicted until the new note fits.
+//
+// Parameters:
+//   - key: Key of the note, surrounding whitespace is removed
+//   - content: Content of the note
+//
+// Returns:
+//   - []string: Keys of the evicted notes, least recently used first
+//   - error: Error if the key is invalid or the note alone exceeds the cap
+func (s *Store) Save(key, content string) ([]string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errors.New(errMsgKeyEmpty)
+	}
+	if len(key) > MaxKeyLength {
+		return nil, fmt.Errorf(errMsgKeyTooLong, MaxKeyLength)
+	}
+	note := &Note{Key: key, Content: content, UpdatedAt: time.Now()}
+	if note.size() > s.maxTotalSize {
+		return nil, fmt.Errorf(errMsgNoteTooLarge, key, note.size(), s.maxTotalSize)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+	var evicted []string
+	for len(s.order) > 0 && s.totalSize+note.size() > s.maxTotalSize {
+		evicted = append(evicted, s.order[0])
+		s.remove(s.order[0])
+	}
+	s.notes[key] = note
+	s.order = append(s.order, key)
+	s.totalSize += note.size()
+	return evicted, nil
+}
+
+// Get returns the note of key and marks it as recently used.
+//
+// Parameters:
+//   - key: Key of the note
+//
+// Returns:
+//   - Note: Copy of the note
+//   - error: Error if the note does not exist or was evicted
+func (s *Store) Get(key string) (Note, error) {
+	key = strings.TrimSpace(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	note, ok := s.notes[key]
+	if !ok {
+		return Note{}, fmt.Errorf(errMsgNoteNotFound, key)
+	}
+	s.touch(key)
+	return *note, nil
+}
+
+// Keys returns the keys of the stored notes in alphabetical order
+func (s *Store) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.notes))
+	for key := range s.notes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// List returns copies of the stored notes in alphabetical order of their
+// keys, without marking them as used
+func (s *Store) List() []Note {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notes := make([]Note, 0, len(s.notes))
+	for _, note := range s.notes {
+		notes = append(notes, *note)
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Key < notes[j].Key })
+	return notes
+}
+
+// remove deletes the note of key if there is one, s.mu must be held
+func (s *Store) remove(key string) {
+	note, ok := s.notes[key]
+	if !ok {
+		return
+	}
+	s.totalSize -= note.size()
+	delete(s.notes, key)
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// touch moves key to the end of the eviction order, s.mu must be held
+func (s *Store) touch(key string) {
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(append(s.order[:i], s.order[i+1:]...), key)
+			return
+		}
+	}
+}
+
+// storeKey is the context key of the Store of a task
+type storeKey struct{}
+
+// WithStore returns a context carrying the note store of a task
+func WithStore(ctx context.Context, store *Store) context.Context {
+	return context.WithValue(ctx, storeKey{}, store)
+}
+
+// FromContext returns the note store of ctx, nil if there is none
+func FromContext(ctx context.Context) *Store {
+	store, _ := ctx.Value(storeKey{}).(*Store)
+	return store
+}
+
+// EvictionHandler receives the keys of the notes evicted by a save_note call
+type EvictionHandler func(keys []string)
+
+// evictionHandlerKey is the context key of the EvictionHandler of a task
+type evictionHandlerKey struct{}
+
+// WithEvictionHandler returns a context whose save_note calls report evicted notes to handler
+func WithEvictionHandler(ctx context.Context, handler EvictionHandler) context.Context {
+	return context.WithValue(ctx, evictionHandlerKey{}, handler)
+}
+
+// evictionHandlerFrom returns the eviction handler of ctx, nil if there is none
+func evictionHandlerFrom(ctx context.Context) EvictionHandler {
+	handler, _ := ctx.Value(evictionHandlerKey{}).(EvictionHandler)
+	return handler
+}
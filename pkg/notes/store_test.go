@@ -0,0 +1,120 @@
+package notes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreSaveAndGet(t *testing.T) {
+	store, err := NewStore(0)
+	require.NoError(t, err)
+
+	evicted, err := store.Save(" target ", "1.1.1.1:80 开放")
+	require.NoError(t, err)
+	assert.Empty(t, evicted)
+
+	note, err := store.Get("target")
+	require.NoError(t, err)
+	assert.Equal(t, "target", note.Key)
+	assert.Equal(t, "1.1.1.1:80 开放", note.Content)
+	assert.False(t, note.UpdatedAt.IsZero())
+
+	// 相同key覆盖原来的笔记
+	_, err = store.Save("target", "1.1.1.1:443 开放")
+	require.NoError(t, err)
+	note, err = store.Get("target")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1:443 开放", note.Content)
+	assert.Equal(t, []string{"target"}, store.Keys())
+
+	_, err = store.Get("missing")
+	assert.ErrorContains(t, err, "不存在或已被淘汰")
+	_, err = store.Save("  ", "x")
+	assert.Error(t, err)
+	_, err = store.Save(strings.Repeat("k", MaxKeyLength+1), "x")
+	assert.Error(t, err)
+	_, err = NewStore(-1)
+	assert.Error(t, err)
+}
+
+func TestStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	// 每条笔记占用 1 字节key + 4 字节内容
+	store, err := NewStore(15)
+	require.NoError(t, err)
+	for _, key := range []string{"a", "b", "c"} {
+		evicted, err := store.Save(key, "xxxx")
+		require.NoError(t, err)
+		assert.Empty(t, evicted)
+	}
+
+	// 读取a后，最久未使用的是b
+	_, err = store.Get("a")
+	require.NoError(t, err)
+	evicted, err := store.Save("d", "xxxx")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, evicted)
+	assert.Equal(t, []string{"a", "c", "d"}, store.Keys())
+
+	// 较大的笔记淘汰多条
+	evicted, err = store.Save("e", "xxxxxxxxx")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, evicted)
+	assert.Equal(t, []string{"d", "e"}, store.Keys())
+
+	// 单条笔记超过总上限时不保存，也不淘汰其他笔记
+	_, err = store.Save("f", strings.Repeat("x", 15))
+	assert.ErrorContains(t, err, "超过了笔记的总大小上限")
+	assert.Equal(t, []string{"d", "e"}, store.Keys())
+}
+
+func TestTools(t *testing.T) {
+	store, err := NewStore(10)
+	require.NoError(t, err)
+	tools := NewTools(store)
+	require.Len(t, tools, 3)
+	run := func(ctx context.Context, i int, args string) (string, error) {
+		return tools[i].(tool.InvokableTool).InvokableRun(ctx, args)
+	}
+
+	for i, name := range []string{SaveToolName, ListToolName, GetToolName} {
+		info, err := tools[i].Info(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, name, info.Name)
+	}
+
+	result, err := run(context.Background(), 1, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, noNotesNotice, result)
+
+	var evictedKeys []string
+	ctx := WithEvictionHandler(context.Background(), func(keys []string) {
+		evictedKeys = append(evictedKeys, keys...)
+	})
+	result, err = run(ctx, 0, `{"key":"a","content":"你好"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "已保存笔记 a", result)
+
+	result, err = run(ctx, 1, `{}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"key":"a","length":2}]`, result)
+
+	result, err = run(ctx, 2, `{"key":"a"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "你好", result)
+
+	// 淘汰时在结果中说明，并通知ctx中的处理函数
+	result, err = run(ctx, 0, `{"key":"b","content":"12345"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, "已淘汰最久未使用的笔记: a")
+	assert.Equal(t, []string{"a"}, evictedKeys)
+
+	_, err = run(ctx, 2, `{"key":"a"}`)
+	assert.Error(t, err)
+	_, err = run(ctx, 0, `not json`)
+	assert.Error(t, err)
+}
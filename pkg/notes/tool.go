@@ -0,0 +1,167 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// SaveToolName is the name of the inner tool that saves a note
+	SaveToolName = "save_note"
+
+	// ListToolName is the name of the inner tool that lists the saved notes
+	ListToolName = "list_notes"
+
+	// GetToolName is the name of the inner tool that reads a note
+	GetToolName = "get_note"
+
+	savedNotice   = "已保存笔记 %s"
+	evictedNotice = "\n笔记总大小超过上限，已淘汰最久未使用的笔记: %s"
+	noNotesNotice = "还没有保存任何笔记"
+)
+
+// saveToolArgs holds the arguments of save_note
+type saveToolArgs struct {
+	Key     string `json:"key"`
+	Content string `json:"content"`
+}
+
+// getToolArgs holds the arguments of get_note
+type getToolArgs struct {
+	Key string `json:"key"`
+}
+
+// listEntry is an entry of the list_notes result
+type listEntry struct {
+	Key    string `json:"key"`
+	Length int    `json:"length"`
+}
+
+// saveTool implements save_note for one Store
+type saveTool struct {
+	store *Store
+}
+
+// listTool implements list_notes for one Store
+type listTool struct {
+	store *Store
+}
+
+// getTool implements get_note for one Store
+type getTool struct {
+	store *Store
+}
+
+// NewTools creates the save_note, list_notes and get_note tools of store.
+// save_note reports evicted notes to the EvictionHandler of the context of
+// the call.
+//
+// Parameters:
+//   - store: Note store of the current task
+//
+// Returns:
+//   - []tool.BaseTool: Tools ready to be passed to the agent
+func NewTools(store *Store) []tool.BaseTool {
+	return []tool.BaseTool{&saveTool{store: store}, &listTool{store: store}, &getTool{store: store}}
+}
+
+// Info implements tool.BaseTool
+func (t *saveTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: SaveToolName,
+		Desc: "save a short note for later steps of this task, such as a finding or an intermediate result; saving an existing key replaces the note. " +
+			"The keys of the saved notes are listed in the system prompt of every step",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"key": {
+				Type:     schema.String,
+				Desc:     fmt.Sprintf("short name of the note, at most %d bytes", MaxKeyLength),
+				Required: true,
+			},
+			"content": {
+				Type:     schema.String,
+				Desc:     "content of the note",
+				Required: true,
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun implements tool.InvokableTool
+func (t *saveTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args saveToolArgs
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+	evicted, err := t.store.Save(args.Key, args.Content)
+	if err != nil {
+		return "", err
+	}
+
+	result := fmt.Sprintf(savedNotice, strings.TrimSpace(args.Key))
+	if len(evicted) > 0 {
+		result += fmt.Sprintf(evictedNotice, strings.Join(evicted, ", "))
+		if handler := evictionHandlerFrom(ctx); handler != nil {
+			handler(evicted)
+		}
+	}
+	return result, nil
+}
+
+// Info implements tool.BaseTool
+func (t *listTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name:        ListToolName,
+		Desc:        "list the keys and content lengths of the notes saved in this task",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{}),
+	}, nil
+}
+
+// InvokableRun implements tool.InvokableTool
+func (t *listTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	notes := t.store.List()
+	if len(notes) == 0 {
+		return noNotesNotice, nil
+	}
+	entries := make([]listEntry, 0, len(notes))
+	for _, note := range notes {
+		entries = append(entries, listEntry{Key: note.Key, Length: len([]rune(note.Content))})
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("序列化笔记列表失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// Info implements tool.BaseTool
+func (t *getTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: GetToolName,
+		Desc: "read a note saved in this task",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"key": {
+				Type:     schema.String,
+				Desc:     "key of the note",
+				Required: true,
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun implements tool.InvokableTool
+func (t *getTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args getToolArgs
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+	note, err := t.store.Get(args.Key)
+	if err != nil {
+		return "", err
+	}
+	return note.Content, nil
+}
@@ -0,0 +1,162 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Player returns the recorded responses of a Session instead of calling a
+// model or MCP server. Model requests are answered in the recorded order,
+// tool calls by the first unused recording that matches them, so that tools
+// running concurrently may finish in another order than when recorded.
+type Player struct {
+	session *Session
+	match   string
+
+	mu        sync.Mutex
+	nextModel int
+	toolUsed  []bool
+}
+
+// NewPlayer creates a player for a recorded session
+//
+// Parameters:
+//   - session: Recorded session, see LoadSession
+//   - match: MatchStrict or MatchFuzzy, empty means MatchStrict
+//
+// Returns:
+//   - *Player: Player starting with the first recorded call
+func NewPlayer(session *Session, match string) *Player {
+	if match == "" {
+		match = MatchStrict
+	}
+	return &Player{session: session, match: match, toolUsed: make([]bool, len(session.ToolCalls))}
+}
+
+// Model returns the model answering with the recorded responses. Binding
+// tools returns a model sharing the position of the player.
+func (p *Player) Model() model.ToolCallingChatModel {
+	return &playerModel{player: p}
+}
+
+// Tools returns the recorded tools, they answer with the recorded results
+func (p *Player) Tools() []tool.BaseTool {
+	tools := make([]tool.BaseTool, 0, len(p.session.Tools))
+	for _, info := range p.session.Tools {
+		tools = append(tools, &playerTool{info: info, player: p})
+	}
+	return tools
+}
+
+// nextModelCall returns the recorded response of the next model request
+func (p *Player) nextModelCall(input []*schema.Message) (*schema.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nextModel >= len(p.session.ModelCalls) {
+		return nil, fmt.Errorf("%w: 大模型只录制了%d次请求", ErrExhausted, len(p.session.ModelCalls))
+	}
+	call := p.session.ModelCalls[p.nextModel]
+	if p.match == MatchStrict && !sameMessages(call.Input, input) {
+		return nil, fmt.Errorf("%w: 第%d次大模型请求的消息与录制的不同", ErrMismatch, p.nextModel+1)
+	}
+	p.nextModel++
+	if call.Error != "" {
+		return nil, errors.New(call.Error)
+	}
+	if call.Output == nil {
+		return &schema.Message{Role: schema.Assistant}, nil
+	}
+	output := *call.Output
+	return &output, nil
+}
+
+// nextToolCall returns the recorded result of a tool call. The first unused
+// call with the same name and arguments is used; in fuzzy mode the first
+// unused call of the tool otherwise.
+func (p *Player) nextToolCall(name string, arguments string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	found := -1
+	for i, call := range p.session.ToolCalls {
+		if !p.toolUsed[i] && call.Tool == name && sameArguments(call.Arguments, arguments) {
+			found = i
+			break
+		}
+	}
+	if found < 0 && p.match == MatchFuzzy {
+		for i, call := range p.session.ToolCalls {
+			if !p.toolUsed[i] && call.Tool == name {
+				found = i
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return "", fmt.Errorf("%w: 没有与工具 %s 的参数 %s 对应的录制结果", ErrMismatch, name, arguments)
+	}
+
+	p.toolUsed[found] = true
+	call := p.session.ToolCalls[found]
+	if call.Error != "" {
+		return call.Result, errors.New(call.Error)
+	}
+	return call.Result, nil
+}
+
+// playerModel implements model.ToolCallingChatModel with the recorded responses
+type playerModel struct {
+	player *Player
+}
+
+// Generate returns the recorded response of the next request
+func (m *playerModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.player.nextModelCall(input)
+}
+
+// Stream returns the recorded response of the next request as a single chunk
+func (m *playerModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	output, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{output}), nil
+}
+
+// WithTools ignores the tools, the recorded responses already contain the tool calls
+func (m *playerModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// playerTool implements a recorded tool
+type playerTool struct {
+	info   ToolInfo
+	player *Player
+}
+
+// Info returns the recorded description of the tool
+func (t *playerTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	info := &schema.ToolInfo{Name: t.info.Name, Desc: t.info.Description}
+	if t.info.Parameters != nil {
+		info.ParamsOneOf = schema.NewParamsOneOfByOpenAPIV3(t.info.Parameters)
+	}
+	return info, nil
+}
+
+// InvokableRun returns the recorded result of the call
+func (t *playerTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return t.player.nextToolCall(t.info.Name, argumentsInJSON)
+}
@@ -0,0 +1,213 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// recorders holds the Recorder of every path, so that the model and the
+// tools of a task created separately record into the same session
+var (
+	recorders   = make(map[string]*Recorder)
+	recordersMu sync.Mutex
+)
+
+// Recorder writes the model requests and tool calls of the wrapped model and
+// tools to a replay file. The file is rewritten after every call, it is
+// complete even if the process stops in the middle of a task. The calls of
+// concurrent tasks recording into the same file are interleaved, record one
+// task at a time.
+type Recorder struct {
+	path    string
+	mu      sync.Mutex
+	session Session
+}
+
+// RecorderFor returns the Recorder writing to path. The first call of the
+// process starts a new session and replaces an existing file, later calls
+// return the same Recorder.
+func RecorderFor(path string) *Recorder {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	if recorder, ok := recorders[path]; ok {
+		return recorder
+	}
+	recorder := &Recorder{path: path, session: Session{Version: SessionVersion}}
+	recorders[path] = recorder
+	return recorder
+}
+
+// Session returns a copy of the calls recorded so far
+func (r *Recorder) Session() Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session := r.session
+	session.Tools = append([]ToolInfo(nil), r.session.Tools...)
+	session.ModelCalls = append([]ModelCall(nil), r.session.ModelCalls...)
+	session.ToolCalls = append([]ToolCall(nil), r.session.ToolCalls...)
+	return session
+}
+
+// update changes the session and writes it, a failed write is logged and
+// the task continues
+func (r *Recorder) update(change func(session *Session)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(&r.session)
+	if err := r.session.Save(r.path); err != nil {
+		log.Printf("保存回放录制失败: %v", err)
+	}
+}
+
+// WrapModel returns m recording every request and its response
+func (r *Recorder) WrapModel(m model.ToolCallingChatModel) model.ToolCallingChatModel {
+	return &recordingModel{model: m, recorder: r}
+}
+
+// WrapTools returns tools recording every call and its result, the
+// descriptions of the tools are recorded for the Player. Tools that cannot
+// be invoked are returned unchanged and are not recorded.
+func (r *Recorder) WrapTools(ctx context.Context, tools []tool.BaseTool) []tool.BaseTool {
+	var infos []ToolInfo
+	wrapped := make([]tool.BaseTool, 0, len(tools))
+	for _, t := range tools {
+		invokable, ok := t.(tool.InvokableTool)
+		if !ok {
+			wrapped = append(wrapped, t)
+			continue
+		}
+		info, err := t.Info(ctx)
+		if err != nil || info == nil {
+			log.Printf("获取工具信息失败，不录制该工具: %v", err)
+			wrapped = append(wrapped, t)
+			continue
+		}
+		recorded := ToolInfo{Name: info.Name, Description: info.Desc}
+		if info.ParamsOneOf != nil {
+			if params, err := info.ParamsOneOf.ToOpenAPIV3(); err == nil {
+				recorded.Parameters = params
+			}
+		}
+		infos = append(infos, recorded)
+		wrapped = append(wrapped, &recordingTool{InvokableTool: invokable, name: info.Name, recorder: r})
+	}
+
+	r.update(func(session *Session) {
+		// 同名工具以最后一次录制的描述为准
+		for _, info := range infos {
+			replaced := false
+			for i := range session.Tools {
+				if session.Tools[i].Name == info.Name {
+					session.Tools[i] = info
+					replaced = true
+				}
+			}
+			if !replaced {
+				session.Tools = append(session.Tools, info)
+			}
+		}
+	})
+	return wrapped
+}
+
+// recordingModel records the requests of the wrapped model
+type recordingModel struct {
+	model    model.ToolCallingChatModel
+	recorder *Recorder
+}
+
+// Generate calls the wrapped model and records the request and the response
+func (m *recordingModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	output, err := m.model.Generate(ctx, input, opts...)
+	m.record(input, output, err)
+	return output, err
+}
+
+// Stream calls the wrapped model and records the concatenated response. The
+// response is read completely before it is returned, so a recorded task
+// receives it as a single chunk.
+func (m *recordingModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	stream, err := m.model.Stream(ctx, input, opts...)
+	if err != nil {
+		m.record(input, nil, err)
+		return nil, err
+	}
+	defer stream.Close()
+
+	var chunks []*schema.Message
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			m.record(input, nil, err)
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	output := &schema.Message{Role: schema.Assistant}
+	if len(chunks) > 0 {
+		if output, err = schema.ConcatMessages(chunks); err != nil {
+			return nil, err
+		}
+	}
+	m.record(input, output, nil)
+	return schema.StreamReaderFromArray([]*schema.Message{output}), nil
+}
+
+// WithTools binds tools to the wrapped model, the result records into the same session
+func (m *recordingModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.model.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingModel{model: bound, recorder: m.recorder}, nil
+}
+
+// record appends a model call to the session
+func (m *recordingModel) record(input []*schema.Message, output *schema.Message, err error) {
+	// 复制消息，之后对请求的修改不影响录制内容
+	call := ModelCall{Input: make([]*schema.Message, len(input)), Output: output}
+	for i, msg := range input {
+		copied := *msg
+		call.Input[i] = &copied
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	m.recorder.update(func(session *Session) {
+		session.ModelCalls = append(session.ModelCalls, call)
+	})
+}
+
+// recordingTool records the calls of the wrapped tool
+type recordingTool struct {
+	tool.InvokableTool
+	name     string
+	recorder *Recorder
+}
+
+// InvokableRun runs the wrapped tool and records the arguments and the result
+func (t *recordingTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	result, err := t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	call := ToolCall{Tool: t.name, Arguments: argumentsInJSON, Result: result}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	t.recorder.update(func(session *Session) {
+		session.ToolCalls = append(session.ToolCalls, call)
+	})
+	return result, err
+}
@@ -0,0 +1,206 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedModel 按顺序返回预设回复的测试模型
+type scriptedModel struct {
+	outputs []*schema.Message
+	calls   int
+}
+
+func (m *scriptedModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	if m.calls >= len(m.outputs) {
+		return nil, errors.New("模型不可用")
+	}
+	m.calls++
+	return m.outputs[m.calls-1], nil
+}
+
+func (m *scriptedModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	output, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// 拆成两段，录制时应拼接为一条消息
+	half := len(output.Content) / 2
+	return schema.StreamReaderFromArray([]*schema.Message{
+		{Role: schema.Assistant, Content: output.Content[:half]},
+		{Role: schema.Assistant, Content: output.Content[half:]},
+	}), nil
+}
+
+func (m *scriptedModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// searchTool 返回带查询词结果的测试工具
+type searchTool struct{}
+
+func (s *searchTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	params := openapi3.NewObjectSchema().WithProperty("query", openapi3.NewStringSchema())
+	return &schema.ToolInfo{Name: "search", Desc: "search the web", ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(params)}, nil
+}
+
+func (s *searchTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	if argumentsInJSON == `{"query":"fail"}` {
+		return "", errors.New("搜索失败")
+	}
+	return "results for " + argumentsInJSON, nil
+}
+
+func toolCallMessage() *schema.Message {
+	return schema.AssistantMessage("", []schema.ToolCall{
+		{ID: "call_1", Type: "function", Function: schema.FunctionCall{Name: "search", Arguments: `{"query":"mcp"}`}},
+	})
+}
+
+// recordSession 录制一次包含模型请求、流式请求和工具调用的会话
+func recordSession(t *testing.T) (string, []*schema.Message) {
+	t.Helper()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+	recorder := RecorderFor(path)
+	require.Same(t, recorder, RecorderFor(path))
+
+	m, err := recorder.WrapModel(&scriptedModel{outputs: []*schema.Message{
+		toolCallMessage(),
+		schema.AssistantMessage("MCP是模型上下文协议", nil),
+	}}).WithTools(nil)
+	require.NoError(t, err)
+	tools := recorder.WrapTools(ctx, []tool.BaseTool{&searchTool{}})
+
+	input := []*schema.Message{schema.SystemMessage("prompt"), schema.UserMessage("什么是MCP")}
+	output, err := m.Generate(ctx, input)
+	require.NoError(t, err)
+	result, err := tools[0].(tool.InvokableTool).InvokableRun(ctx, `{"query":"mcp"}`)
+	require.NoError(t, err)
+	_, err = tools[0].(tool.InvokableTool).InvokableRun(ctx, `{"query":"fail"}`)
+	require.Error(t, err)
+
+	input = append(input, output, schema.ToolMessage(result, "call_1"))
+	stream, err := m.Stream(ctx, input)
+	require.NoError(t, err)
+	defer stream.Close()
+	chunk, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "MCP是模型上下文协议", chunk.Content)
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+	return path, input
+}
+
+func TestRecorderWritesSession(t *testing.T) {
+	path, _ := recordSession(t)
+
+	session, err := LoadSession(path)
+	require.NoError(t, err)
+	assert.Equal(t, SessionVersion, session.Version)
+	require.Len(t, session.Tools, 1)
+	assert.Equal(t, "search", session.Tools[0].Name)
+	assert.Contains(t, session.Tools[0].Parameters.Properties, "query")
+
+	require.Len(t, session.ModelCalls, 2)
+	assert.Len(t, session.ModelCalls[0].Input, 2)
+	assert.Equal(t, "call_1", session.ModelCalls[0].Output.ToolCalls[0].ID)
+	assert.Equal(t, "MCP是模型上下文协议", session.ModelCalls[1].Output.Content)
+
+	require.Len(t, session.ToolCalls, 2)
+	assert.Equal(t, ToolCall{Tool: "search", Arguments: `{"query":"mcp"}`, Result: `results for {"query":"mcp"}`}, session.ToolCalls[0])
+	assert.Equal(t, "搜索失败", session.ToolCalls[1].Error)
+}
+
+func TestPlayerStrict(t *testing.T) {
+	ctx := context.Background()
+	path, input := recordSession(t)
+	session, err := LoadSession(path)
+	require.NoError(t, err)
+	player := NewPlayer(session, "")
+
+	tools := player.Tools()
+	require.Len(t, tools, 1)
+	info, err := tools[0].Info(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "search", info.Name)
+	params, err := info.ParamsOneOf.ToOpenAPIV3()
+	require.NoError(t, err)
+	assert.Contains(t, params.Properties, "query")
+
+	m, err := player.Model().WithTools(nil)
+	require.NoError(t, err)
+
+	// 消息与录制的不同时返回错误，不消耗录制内容
+	_, err = m.Generate(ctx, []*schema.Message{schema.SystemMessage("prompt"), schema.UserMessage("其他问题")})
+	assert.ErrorIs(t, err, ErrMismatch)
+
+	output, err := m.Generate(ctx, input[:2])
+	require.NoError(t, err)
+	assert.Equal(t, "call_1", output.ToolCalls[0].ID)
+
+	invokable := tools[0].(tool.InvokableTool)
+	_, err = invokable.InvokableRun(ctx, `{"query":"other"}`)
+	assert.ErrorIs(t, err, ErrMismatch)
+	// 参数按JSON比较，键的顺序和空白不影响匹配
+	_, err = invokable.InvokableRun(ctx, `{ "query": "fail" }`)
+	assert.EqualError(t, err, "搜索失败")
+	result, err := invokable.InvokableRun(ctx, `{"query":"mcp"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `results for {"query":"mcp"}`, result)
+	_, err = invokable.InvokableRun(ctx, `{"query":"mcp"}`)
+	assert.ErrorIs(t, err, ErrMismatch)
+
+	stream, err := m.Stream(ctx, input)
+	require.NoError(t, err)
+	chunk, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "MCP是模型上下文协议", chunk.Content)
+
+	_, err = m.Generate(ctx, input)
+	assert.ErrorIs(t, err, ErrExhausted)
+}
+
+func TestPlayerFuzzy(t *testing.T) {
+	ctx := context.Background()
+	path, _ := recordSession(t)
+	session, err := LoadSession(path)
+	require.NoError(t, err)
+	player := NewPlayer(session, MatchFuzzy)
+
+	// 模糊匹配按顺序返回回复，不比较消息
+	output, err := player.Model().Generate(ctx, []*schema.Message{schema.UserMessage("随便问问")})
+	require.NoError(t, err)
+	assert.Len(t, output.ToolCalls, 1)
+
+	// 参数不同时使用同名工具的下一条录制结果
+	invokable := player.Tools()[0].(tool.InvokableTool)
+	result, err := invokable.InvokableRun(ctx, `{"query":"other"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `results for {"query":"mcp"}`, result)
+	_, err = invokable.InvokableRun(ctx, `{"query":"other"}`)
+	assert.EqualError(t, err, "搜索失败")
+	_, err = invokable.InvokableRun(ctx, `{"query":"other"}`)
+	assert.ErrorIs(t, err, ErrMismatch)
+}
+
+func TestLoadSessionErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadSession(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	path := filepath.Join(dir, "future.json")
+	require.NoError(t, (&Session{Version: SessionVersion + 1}).Save(path))
+	_, err = LoadSession(path)
+	assert.ErrorContains(t, err, "不支持的回放文件版本")
+}
@@ -0,0 +1,149 @@
+// Package replay records the model requests and tool calls of a task into a
+// file and plays them back, so that demos and end-to-end tests run without
+// a reachable model or MCP server.
+//
+// A Recorder wraps the model and the tools of a task and writes every model
+// request with its response and every tool call with its result to a
+// Session file. A Player loads the file and implements the model and the
+// tools by returning the recorded responses, which makes a task fully
+// deterministic offline.
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// SessionVersion is the version of the replay file format written by Recorder
+const SessionVersion = 1
+
+// Match modes of a Player
+const (
+	// MatchStrict requires every model request to equal the recorded request
+	// and every tool call to have the recorded name and arguments
+	MatchStrict = "strict"
+	// MatchFuzzy returns the recorded model responses in order whatever the
+	// request and matches tool calls by name when the arguments differ
+	MatchFuzzy = "fuzzy"
+)
+
+var (
+	// ErrMismatch is returned when a request does not match the recording
+	ErrMismatch = errors.New("回放请求与录制内容不一致")
+	// ErrExhausted is returned when more requests are made than were recorded
+	ErrExhausted = errors.New("录制内容已全部回放")
+)
+
+// Session is the content of a replay file
+type Session struct {
+	Version    int         `json:"version"`
+	Tools      []ToolInfo  `json:"tools"`       // 录制时提供给大模型的工具
+	ModelCalls []ModelCall `json:"model_calls"` // 按顺序记录的大模型请求
+	ToolCalls  []ToolCall  `json:"tool_calls"`  // 按完成顺序记录的工具调用
+}
+
+// ToolInfo describes a recorded tool, the parameters are kept as JSON Schema
+type ToolInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Parameters  *openapi3.Schema `json:"parameters,omitempty"`
+}
+
+// ModelCall is a recorded model request with its response
+type ModelCall struct {
+	Input  []*schema.Message `json:"input"`
+	Output *schema.Message   `json:"output,omitempty"`
+	Error  string            `json:"error,omitempty"` // 大模型返回的错误，回放时原样返回
+}
+
+// ToolCall is a recorded tool call with its result
+type ToolCall struct {
+	Tool      string `json:"tool"`
+	Arguments string `json:"arguments"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"` // 工具返回的错误，回放时原样返回
+}
+
+// LoadSession reads a replay file
+//
+// Parameters:
+//   - path: Replay file written by a Recorder
+//
+// Returns:
+//   - *Session: Recorded session
+//   - error: Error if the file cannot be read or has an unsupported version
+func LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取回放文件失败: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("解析回放文件 %s 失败: %w", path, err)
+	}
+	if session.Version != SessionVersion {
+		return nil, fmt.Errorf("不支持的回放文件版本: %d", session.Version)
+	}
+	return &session, nil
+}
+
+// Save writes the session to path, replacing the file atomically so that a
+// crash never leaves a truncated recording
+func (s *Session) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化回放文件失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建回放文件目录失败: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入回放文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入回放文件失败: %w", err)
+	}
+	return nil
+}
+
+// sameArguments reports whether two JSON arguments are equal regardless of
+// key order and formatting, arguments that are not JSON must be identical
+func sameArguments(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// sameMessages reports whether a request contains the recorded messages,
+// only the fields sent to the model are compared
+func sameMessages(recorded, actual []*schema.Message) bool {
+	if len(recorded) != len(actual) {
+		return false
+	}
+	for i := range recorded {
+		r, a := recorded[i], actual[i]
+		if r.Role != a.Role || r.Content != a.Content || r.ToolCallID != a.ToolCallID || len(r.ToolCalls) != len(a.ToolCalls) {
+			return false
+		}
+		for j := range r.ToolCalls {
+			rc, ac := r.ToolCalls[j], a.ToolCalls[j]
+			if rc.ID != ac.ID || rc.Function.Name != ac.Function.Name || !sameArguments(rc.Function.Arguments, ac.Function.Arguments) {
+				return false
+			}
+		}
+	}
+	return true
+}
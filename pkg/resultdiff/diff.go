@@ -0,0 +1,247 @@
+// Package resultdiff compares the result of a tool call with the result of
+// the previous call of the same tool with the same arguments, so that an
+// agent polling a resource sees at a glance what changed since the last run.
+//
+// Previous results are kept in a Store, a directory of files for the
+// command line and a database table for the web server.
+package resultdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxDiffLines is the number of changed lines shown in a diff summary
+	MaxDiffLines = 50
+
+	// maxDiffLineLen is the number of characters kept per shown line
+	maxDiffLineLen = 200
+
+	// maxDiffCells bounds the size of the table used to compare the changed
+	// parts of two results, larger changes are shown as replacing every line
+	maxDiffCells = 4_000_000
+
+	// diffHeader starts the summary prepended to a tool result
+	diffHeader = "[result diff] "
+
+	// NoPreviousSummary is prepended to the result of the first call with the given arguments
+	NoPreviousSummary = diffHeader + "no_previous_result: true\n"
+)
+
+// Diff is the line based difference between two tool results
+type Diff struct {
+	Added   int      // 新增的行数
+	Removed int      // 删除的行数
+	Lines   []string // 统一diff格式的变更行，包含 @@ 分段标记
+}
+
+// Empty reports whether the results are identical after normalization
+func (d *Diff) Empty() bool {
+	return d.Added == 0 && d.Removed == 0
+}
+
+// Normalize returns the form of a result that is compared line by line.
+// JSON results are re-indented with sorted object keys, so that a single
+// line JSON document differs only in the fields that changed and the key
+// order chosen by the server does not matter. Other results are returned
+// unchanged.
+//
+// Parameters:
+//   - result: Tool result
+//
+// Returns:
+//   - string: Normalized result
+func Normalize(result string) string {
+	trimmed := strings.TrimSpace(result)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return result
+	}
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return result
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return result
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Compare returns the difference between two results after normalizing both.
+//
+// Parameters:
+//   - previous: Result of the previous call
+//   - current: Result of this call
+//
+// Returns:
+//   - Diff: Changed lines with the counts of added and removed lines
+func Compare(previous, current string) Diff {
+	return diffLines(splitLines(Normalize(previous)), splitLines(Normalize(current)))
+}
+
+// splitLines splits text into lines, an empty text has no lines
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines compares two line lists. The common prefix and suffix are skipped,
+// the rest is compared with a longest common subsequence table.
+func diffLines(a, b []string) Diff {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	var diff Diff
+	if len(a) == 0 && len(b) == 0 {
+		return diff
+	}
+
+	// ops 中 ' ' 表示相同的行，'-' 表示删除，'+' 表示新增
+	type op struct {
+		kind byte
+		line string
+	}
+	var ops []op
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			ops = append(ops, op{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, op{'+', line})
+		}
+	} else {
+		// lcs[i][j] 是 a[i:] 和 b[j:] 的最长公共子序列长度
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && a[i] == b[j]:
+				ops = append(ops, op{' ', a[i]})
+				i++
+				j++
+			case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+				ops = append(ops, op{'+', b[j]})
+				j++
+			default:
+				ops = append(ops, op{'-', a[i]})
+				i++
+			}
+		}
+	}
+
+	// 每段连续的变更输出一个不带上下文的 @@ 分段，行号从1开始
+	oldLine, newLine := prefix+1, prefix+1
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			oldLine++
+			newLine++
+			k++
+			continue
+		}
+		end := k
+		removed, added := 0, 0
+		for end < len(ops) && ops[end].kind != ' ' {
+			if ops[end].kind == '-' {
+				removed++
+			} else {
+				added++
+			}
+			end++
+		}
+		diff.Lines = append(diff.Lines, fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldLine, removed), hunkRange(newLine, added)))
+		for _, o := range ops[k:end] {
+			if o.kind == '-' {
+				diff.Lines = append(diff.Lines, "-"+o.line)
+			}
+		}
+		for _, o := range ops[k:end] {
+			if o.kind == '+' {
+				diff.Lines = append(diff.Lines, "+"+o.line)
+			}
+		}
+		diff.Removed += removed
+		diff.Added += added
+		oldLine += removed
+		newLine += added
+		k = end
+	}
+	return diff
+}
+
+// hunkRange formats the line range of a hunk like diff -U0
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
+
+// Summary returns the text prepended to the current result: the line counts
+// and at most MaxDiffLines changed lines.
+//
+// Returns:
+//   - string: Summary ending with a newline
+func (d *Diff) Summary() string {
+	if d.Empty() {
+		return diffHeader + "unchanged since the previous call with the same arguments (+0 -0)\n"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%schanged since the previous call with the same arguments (+%d -%d lines)\n", diffHeader, d.Added, d.Removed)
+	shown := d.Lines
+	if len(shown) > MaxDiffLines {
+		shown = shown[:MaxDiffLines]
+	}
+	for _, line := range shown {
+		sb.WriteString(truncateLine(line))
+		sb.WriteByte('\n')
+	}
+	if omitted := len(d.Lines) - len(shown); omitted > 0 {
+		fmt.Fprintf(&sb, "[result diff truncated, %d more lines]\n", omitted)
+	}
+	return sb.String()
+}
+
+// truncateLine shortens a diff line to maxDiffLineLen characters
+func truncateLine(line string) string {
+	if utf8.RuneCountInString(line) <= maxDiffLineLen {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:maxDiffLineLen]) + "..."
+}
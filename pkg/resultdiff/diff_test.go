@@ -0,0 +1,53 @@
+package resultdiff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "{\n  \"a\": 1,\n  \"b\": [\n    \"<x>\"\n  ]\n}", Normalize(`{"b":["<x>"],"a":1}`))
+	// 大整数保持原样，非JSON结果不变
+	assert.Equal(t, "[\n  12345678901234567890\n]", Normalize(`[12345678901234567890]`))
+	assert.Equal(t, "plain text", Normalize("plain text"))
+	assert.Equal(t, `{"a":1} trailing`, Normalize(`{"a":1} trailing`))
+}
+
+func TestCompare(t *testing.T) {
+	t.Run("相同", func(t *testing.T) {
+		diff := Compare("a\nb\n", "a\nb")
+		assert.True(t, diff.Empty())
+		assert.Empty(t, diff.Lines)
+	})
+
+	t.Run("新增和删除", func(t *testing.T) {
+		diff := Compare("a\nb\nc\nd", "a\nc\nd\ne\nf")
+		assert.Equal(t, 2, diff.Added)
+		assert.Equal(t, 1, diff.Removed)
+		assert.Equal(t, []string{"@@ -2 +1,0 @@", "-b", "@@ -4,0 +4,2 @@", "+e", "+f"}, diff.Lines)
+	})
+
+	t.Run("首次内容为空", func(t *testing.T) {
+		diff := Compare("", "a")
+		assert.Equal(t, []string{"@@ -0,0 +1 @@", "+a"}, diff.Lines)
+	})
+}
+
+func TestDiffSummaryTruncates(t *testing.T) {
+	var previous, current []string
+	for i := 0; i < MaxDiffLines*2; i++ {
+		previous = append(previous, fmt.Sprintf("old %d %s", i, strings.Repeat("x", maxDiffLineLen)))
+		current = append(current, fmt.Sprintf("new %d", i))
+	}
+	diff := Compare(strings.Join(previous, "\n"), strings.Join(current, "\n"))
+	summary := diff.Summary()
+
+	lines := strings.Split(strings.TrimSuffix(summary, "\n"), "\n")
+	assert.Len(t, lines, MaxDiffLines+2)
+	assert.Equal(t, "[result diff] changed since the previous call with the same arguments (+100 -100 lines)", lines[0])
+	assert.Equal(t, fmt.Sprintf("[result diff truncated, %d more lines]", len(diff.Lines)-MaxDiffLines), lines[len(lines)-1])
+	assert.True(t, strings.HasSuffix(lines[len(lines)-2], "..."))
+}
@@ -0,0 +1,148 @@
+package resultdiff
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultStoreDirName is the directory below the user cache directory used by DefaultFileStore
+const defaultStoreDirName = "mcpagent/tool_results"
+
+// Store keeps the latest result of each tool call key. Implementations must
+// be safe for concurrent use, since tools may run in parallel.
+type Store interface {
+	// PreviousResult returns the result saved for key, false if there is none
+	PreviousResult(key string) (string, bool, error)
+	// SaveResult replaces the result saved for key
+	SaveResult(key string, toolKey string, result string) error
+}
+
+// storeKey is the context key of the Store of a task
+type storeKey struct{}
+
+// WithStore returns a context whose diffing tools keep their results in store
+func WithStore(ctx context.Context, store Store) context.Context {
+	return context.WithValue(ctx, storeKey{}, store)
+}
+
+// storeFrom returns the store of ctx, DefaultFileStore if there is none
+func storeFrom(ctx context.Context) (Store, error) {
+	if store, ok := ctx.Value(storeKey{}).(Store); ok && store != nil {
+		return store, nil
+	}
+	return DefaultFileStore()
+}
+
+// Key returns the key of a tool call: a hash of the tool and its arguments
+// with sorted object keys, so the order of the arguments does not matter.
+//
+// Parameters:
+//   - toolKey: Tool identifier, such as "fofa_search"
+//   - argumentsInJSON: Arguments of the call
+//
+// Returns:
+//   - string: Hex encoded SHA-256 hash
+func Key(toolKey string, argumentsInJSON string) string {
+	arguments := argumentsInJSON
+	var value any
+	if err := json.Unmarshal([]byte(argumentsInJSON), &value); err == nil {
+		if data, err := json.Marshal(value); err == nil {
+			arguments = string(data)
+		}
+	}
+	sum := sha256.Sum256([]byte(toolKey + "\x00" + arguments))
+	return hex.EncodeToString(sum[:])
+}
+
+// FileStore keeps results as files of a directory, one file per key
+type FileStore struct {
+	dir string
+}
+
+// fileRecord is the content of a FileStore file
+type fileRecord struct {
+	ToolKey string `json:"tool_key"`
+	Result  string `json:"result"`
+}
+
+// NewFileStore creates a store keeping results in dir, the directory is
+// created on the first save.
+//
+// Parameters:
+//   - dir: Directory of the result files
+//
+// Returns:
+//   - *FileStore: Store using dir
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+// DefaultFileStore returns the store used when the context has none, keeping
+// results below the user cache directory.
+//
+// Returns:
+//   - *FileStore: Store below the user cache directory
+//   - error: Error if the user cache directory is unknown
+func DefaultFileStore() (*FileStore, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("获取用户缓存目录失败: %w", err)
+	}
+	return NewFileStore(filepath.Join(cacheDir, defaultStoreDirName)), nil
+}
+
+// path returns the file of key
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// PreviousResult implements Store
+func (s *FileStore) PreviousResult(key string) (string, bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("读取上次的工具结果失败: %w", err)
+	}
+	var record fileRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return "", false, fmt.Errorf("解析上次的工具结果失败: %w", err)
+	}
+	return record.Result, true, nil
+}
+
+// SaveResult implements Store. The file is written to a temporary file and
+// renamed, so that a concurrent PreviousResult never reads a partial result.
+func (s *FileStore) SaveResult(key string, toolKey string, result string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("创建工具结果目录失败: %w", err)
+	}
+	data, err := json.Marshal(fileRecord{ToolKey: toolKey, Result: result})
+	if err != nil {
+		return fmt.Errorf("序列化工具结果失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("保存工具结果失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存工具结果失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存工具结果失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("保存工具结果失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package resultdiff
+
+import (
+	"context"
+	"log"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// diffingTool prepends the difference to the previous result to the results of the wrapped tool
+type diffingTool struct {
+	tool.InvokableTool
+	toolKey string
+}
+
+// WrapTool returns t prepending to each result a summary of the difference
+// to the previous result of the same arguments, or NoPreviousSummary on the
+// first call. Results are kept in the Store of the context of the call.
+// Tools that cannot be invoked are returned unchanged.
+//
+// Parameters:
+//   - t: Tool to wrap
+//   - toolKey: Tool identifier the results are kept under, such as "fofa_search"
+//
+// Returns:
+//   - tool.BaseTool: Tool returning results with a diff summary
+func WrapTool(t tool.BaseTool, toolKey string) tool.BaseTool {
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok {
+		return t
+	}
+	return &diffingTool{InvokableTool: invokable, toolKey: toolKey}
+}
+
+// InvokableRun runs the wrapped tool and prepends the diff summary. Failed
+// calls are neither compared nor saved, and a store that fails only loses
+// the summary, the result is returned in any case.
+func (t *diffingTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	result, err := t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	if err != nil {
+		return result, err
+	}
+
+	store, storeErr := storeFrom(ctx)
+	if storeErr != nil {
+		log.Printf("工具 %s 的上次结果存储不可用，不比较结果: %v", t.toolKey, storeErr)
+		return result, nil
+	}
+	key := Key(t.toolKey, argumentsInJSON)
+	previous, found, loadErr := store.PreviousResult(key)
+	if loadErr != nil {
+		log.Printf("读取工具 %s 的上次结果失败，不比较结果: %v", t.toolKey, loadErr)
+		return result, nil
+	}
+
+	if saveErr := store.SaveResult(key, t.toolKey, result); saveErr != nil {
+		log.Printf("保存工具 %s 的结果失败: %v", t.toolKey, saveErr)
+	}
+
+	if !found {
+		return NoPreviousSummary + "\n" + result, nil
+	}
+	diff := Compare(previous, result)
+	return diff.Summary() + "\n" + result, nil
+}
@@ -0,0 +1,93 @@
+package resultdiff
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedTool 返回result字段的内容作为工具结果
+type fixedTool struct {
+	result string
+	calls  int
+}
+
+func (t *fixedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "search"}, nil
+}
+
+func (t *fixedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	t.calls++
+	return t.result, nil
+}
+
+func TestWrapTool(t *testing.T) {
+	ctx := WithStore(context.Background(), NewFileStore(t.TempDir()))
+	inner := &fixedTool{result: `{"total":2,"results":["1.1.1.1","8.8.8.8"]}`}
+	wrapped := WrapTool(inner, "fofa_search").(tool.InvokableTool)
+
+	t.Run("首次调用", func(t *testing.T) {
+		result, err := wrapped.InvokableRun(ctx, `{"query":"port=80","size":10}`)
+		require.NoError(t, err)
+		assert.Equal(t, NoPreviousSummary+"\n"+inner.result, result)
+	})
+
+	t.Run("结果相同", func(t *testing.T) {
+		// 参数顺序不同也视为同一次调用，JSON键的顺序不影响比较
+		inner.result = `{"results":["1.1.1.1","8.8.8.8"],"total":2}`
+		result, err := wrapped.InvokableRun(ctx, `{"size":10,"query":"port=80"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "[result diff] unchanged since the previous call with the same arguments (+0 -0)\n\n"+inner.result, result)
+	})
+
+	t.Run("结果变化", func(t *testing.T) {
+		inner.result = `{"results":["1.1.1.1","9.9.9.9"],"total":2}`
+		result, err := wrapped.InvokableRun(ctx, `{"query":"port=80","size":10}`)
+		require.NoError(t, err)
+		summary, rest, ok := strings.Cut(result, "\n\n")
+		require.True(t, ok)
+		assert.Equal(t, inner.result, rest)
+		assert.Equal(t, strings.Join([]string{
+			"[result diff] changed since the previous call with the same arguments (+1 -1 lines)",
+			"@@ -4 +4 @@",
+			`-    "8.8.8.8"`,
+			`+    "9.9.9.9"`,
+		}, "\n"), summary)
+	})
+
+	t.Run("参数不同", func(t *testing.T) {
+		result, err := wrapped.InvokableRun(ctx, `{"query":"port=443"}`)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(result, NoPreviousSummary))
+	})
+
+	t.Run("不同工具", func(t *testing.T) {
+		other := WrapTool(inner, "fofa_host").(tool.InvokableTool)
+		result, err := other.InvokableRun(ctx, `{"query":"port=80","size":10}`)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(result, NoPreviousSummary))
+	})
+}
+
+func TestFileStorePersistsResults(t *testing.T) {
+	dir := t.TempDir()
+	key := Key("fofa_search", `{"query":"port=80"}`)
+
+	_, found, err := NewFileStore(dir).PreviousResult(key)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, NewFileStore(dir).SaveResult(key, "fofa_search", "第一次"))
+	require.NoError(t, NewFileStore(dir).SaveResult(key, "fofa_search", "第二次"))
+
+	// 新建的存储读取同一目录中保存的结果
+	previous, found, err := NewFileStore(dir).PreviousResult(key)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "第二次", previous)
+}
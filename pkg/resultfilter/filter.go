@@ -0,0 +1,121 @@
+// Package resultfilter slims down JSON tool results with jq expressions
+// before they are returned to the agent, such as ".results[] | {ip, port, title}".
+// Expressions use the gojq dialect of jq. They run sandboxed: env and $ENV
+// are empty and input, inputs and module imports are not available, so a
+// filter can only see the tool result. Evaluation is bounded by a timeout.
+package resultfilter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// DefaultTimeout bounds the evaluation of a filter on a single tool result
+const DefaultTimeout = time.Second
+
+var (
+	// ErrNotJSON is returned by Apply when the tool result is not JSON, the result is kept
+	ErrNotJSON = errors.New("工具结果不是JSON，跳过结果过滤")
+
+	// ErrTimeout is returned by Apply when the evaluation exceeds the timeout
+	ErrTimeout = errors.New("结果过滤超时")
+)
+
+// Filter is a compiled jq expression. It is safe for concurrent use.
+type Filter struct {
+	expr    string
+	code    *gojq.Code
+	timeout time.Duration
+}
+
+// Compile parses and compiles a jq expression.
+//
+// Parameters:
+//   - expr: jq expression, such as ".results[] | {ip, port}"
+//
+// Returns:
+//   - *Filter: Filter using DefaultTimeout
+//   - error: Syntax or compile error of the expression
+func Compile(expr string) (*Filter, error) {
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("解析结果过滤表达式失败: %w", err)
+	}
+	// 不提供环境变量和外部输入，表达式只能读取工具结果
+	code, err := gojq.Compile(query, gojq.WithEnvironLoader(func() []string { return nil }))
+	if err != nil {
+		return nil, fmt.Errorf("编译结果过滤表达式失败: %w", err)
+	}
+	return &Filter{expr: expr, code: code, timeout: DefaultTimeout}, nil
+}
+
+// String returns the expression of the filter
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// WithTimeout returns a copy of the filter evaluated with timeout,
+// values below or equal 0 keep the current timeout
+func (f *Filter) WithTimeout(timeout time.Duration) *Filter {
+	copied := *f
+	if timeout > 0 {
+		copied.timeout = timeout
+	}
+	return &copied
+}
+
+// Apply evaluates the filter on a JSON tool result. A result that is a JSON
+// string containing JSON, as returned by tools encoding their output twice,
+// is decoded once more. Every value the expression produces is written as
+// compact JSON on a line of its own, like jq -c prints it.
+//
+// Parameters:
+//   - ctx: Context of the tool call, the evaluation stops when it is done
+//   - output: Tool result
+//
+// Returns:
+//   - string: Filtered result
+//   - error: ErrNotJSON, ErrTimeout or the evaluation error, the caller should keep output then
+func (f *Filter) Apply(ctx context.Context, output string) (string, error) {
+	var input any
+	if err := json.Unmarshal([]byte(output), &input); err != nil {
+		return "", ErrNotJSON
+	}
+	if text, ok := input.(string); ok {
+		var nested any
+		trimmed := strings.TrimSpace(text)
+		if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Unmarshal([]byte(trimmed), &nested) == nil {
+			input = nested
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	var lines []string
+	iter := f.code.RunWithContext(ctx, input)
+	for {
+		value, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, isErr := value.(error); isErr {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return "", fmt.Errorf("%w（%s）", ErrTimeout, f.timeout)
+			}
+			return "", fmt.Errorf("执行结果过滤表达式失败: %w", err)
+		}
+		line, err := gojq.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("序列化过滤结果失败: %w", err)
+		}
+		lines = append(lines, string(line))
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,152 @@
+package resultfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fofaResult = `{"error":false,"size":2,"results":[` +
+	`{"ip":"1.1.1.1","port":443,"title":"one","header":"HTTP/1.1 200 OK"},` +
+	`{"ip":"2.2.2.2","port":80,"title":"two","header":"HTTP/1.1 301 Moved"}]}`
+
+func TestFilterApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		output string
+		want   string
+	}{
+		{
+			name:   "projection",
+			expr:   ".results[] | {ip, port, title}",
+			output: fofaResult,
+			want:   `{"ip":"1.1.1.1","port":443,"title":"one"}` + "\n" + `{"ip":"2.2.2.2","port":80,"title":"two"}`,
+		},
+		{
+			name:   "array",
+			expr:   "[.results[] | select(.port == 443) | .ip]",
+			output: fofaResult,
+			want:   `["1.1.1.1"]`,
+		},
+		{
+			name:   "json encoded twice",
+			expr:   ".size",
+			output: `"{\"size\": 2}"`,
+			want:   `2`,
+		},
+		{
+			name:   "no values",
+			expr:   ".results[] | select(.port == 22)",
+			output: fofaResult,
+			want:   ``,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := Compile(tt.expr)
+			require.NoError(t, err)
+			got, err := filter.Apply(context.Background(), tt.output)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompileRejectsInvalidExpressions(t *testing.T) {
+	_, err := Compile(".results[] | {ip,")
+	assert.ErrorContains(t, err, "解析结果过滤表达式失败")
+
+	_, err = Compile("undefined_function(1)")
+	assert.ErrorContains(t, err, "编译结果过滤表达式失败")
+
+	_, err = Compile("$secret")
+	assert.Error(t, err)
+}
+
+func TestFilterIsSandboxed(t *testing.T) {
+	t.Setenv("RESULT_FILTER_SECRET", "secret")
+	filter, err := Compile("$ENV.RESULT_FILTER_SECRET, env.RESULT_FILTER_SECRET")
+	require.NoError(t, err)
+	got, err := filter.Apply(context.Background(), `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, "null\nnull", got)
+
+	_, err = Compile("input")
+	assert.Error(t, err)
+}
+
+func TestFilterApplyErrors(t *testing.T) {
+	filter, err := Compile(".results[] | {ip}")
+	require.NoError(t, err)
+	_, err = filter.Apply(context.Background(), "查询结果：1.1.1.1")
+	assert.ErrorIs(t, err, ErrNotJSON)
+
+	// 类型错误等运行时错误
+	_, err = filter.Apply(context.Background(), `{"results": 1}`)
+	assert.ErrorContains(t, err, "执行结果过滤表达式失败")
+
+	// 死循环在超时后停止
+	filter, err = Compile("reduce range(1e15) as $i (0; . + 1)")
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = filter.WithTimeout(50*time.Millisecond).Apply(context.Background(), `{}`)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// stubTool 返回固定结果的工具
+type stubTool struct {
+	result string
+	err    error
+}
+
+func (t *stubTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "fofa"}, nil
+}
+
+func (t *stubTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return t.result, t.err
+}
+
+func TestWrapTool(t *testing.T) {
+	filter, err := Compile(".results[0].ip")
+	require.NoError(t, err)
+
+	type failure struct {
+		tool string
+		err  error
+	}
+	var failures []failure
+	ctx := WithHandler(context.Background(), func(toolName string, err error) {
+		failures = append(failures, failure{toolName, err})
+	})
+
+	wrapped := WrapTool(&stubTool{result: fofaResult}, filter).(tool.InvokableTool)
+	got, err := wrapped.InvokableRun(ctx, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, `"1.1.1.1"`, got)
+	assert.Empty(t, failures)
+
+	// 非JSON结果原样返回并报告
+	wrapped = WrapTool(&stubTool{result: "plain text"}, filter).(tool.InvokableTool)
+	got, err = wrapped.InvokableRun(ctx, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, "plain text", got)
+	require.Len(t, failures, 1)
+	assert.Equal(t, "fofa", failures[0].tool)
+	assert.ErrorIs(t, failures[0].err, ErrNotJSON)
+
+	// 工具错误不经过过滤
+	toolErr := errors.New("rate limited")
+	wrapped = WrapTool(&stubTool{err: toolErr}, filter).(tool.InvokableTool)
+	_, err = wrapped.InvokableRun(context.Background(), `{}`)
+	assert.ErrorIs(t, err, toolErr)
+	assert.Len(t, failures, 1)
+}
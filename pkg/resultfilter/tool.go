@@ -0,0 +1,68 @@
+package resultfilter
+
+import (
+	"context"
+	"log"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// Handler receives the tool results a filter was not applied to, err is
+// ErrNotJSON, ErrTimeout or the evaluation error. The raw result is returned
+// to the agent in that case.
+type Handler func(toolName string, err error)
+
+// handlerKey is the context key of the Handler of a task
+type handlerKey struct{}
+
+// WithHandler returns a context whose filtered tool calls report failures to handler
+func WithHandler(ctx context.Context, handler Handler) context.Context {
+	return context.WithValue(ctx, handlerKey{}, handler)
+}
+
+// filteringTool applies a filter to the results of the wrapped tool
+type filteringTool struct {
+	tool.InvokableTool
+	filter *Filter
+}
+
+// WrapTool returns t with filter applied to its results. Tools that cannot
+// be invoked are returned unchanged.
+//
+// Parameters:
+//   - t: Tool to wrap
+//   - filter: Compiled filter
+//
+// Returns:
+//   - tool.BaseTool: Tool returning filtered results
+func WrapTool(t tool.BaseTool, filter *Filter) tool.BaseTool {
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok || filter == nil {
+		return t
+	}
+	return &filteringTool{InvokableTool: invokable, filter: filter}
+}
+
+// InvokableRun runs the wrapped tool and filters its result, falling back to
+// the raw result when the filter cannot be applied
+func (t *filteringTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	result, err := t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	if err != nil {
+		return result, err
+	}
+
+	filtered, filterErr := t.filter.Apply(ctx, result)
+	if filterErr == nil {
+		return filtered, nil
+	}
+
+	toolName := "unknown"
+	if info, infoErr := t.Info(ctx); infoErr == nil && info != nil {
+		toolName = info.Name
+	}
+	log.Printf("工具 %s 的结果过滤失败: %v，使用原始结果", toolName, filterErr)
+	if handler, ok := ctx.Value(handlerKey{}).(Handler); ok && handler != nil {
+		handler(toolName, filterErr)
+	}
+	return result, nil
+}
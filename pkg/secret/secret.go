@@ -0,0 +1,214 @@
+// Package secret encrypts sensitive values before they are stored in the database.
+// Values are encrypted with AES-GCM and stored as "enc:v1:" followed by the
+// base64 encoded nonce and ciphertext, so encrypted and plaintext values can be told apart.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// EnvKey is the environment variable holding the encryption key
+	EnvKey = "MCPAGENT_SECRET_KEY"
+
+	// EncryptedPrefix marks a value encrypted by this package
+	EncryptedPrefix = "enc:v1:"
+)
+
+var (
+	ErrNoKey   = errors.New("未设置加密密钥")
+	ErrDecrypt = errors.New("解密失败，密钥可能不正确")
+)
+
+var (
+	defaultMu     sync.RWMutex
+	defaultCipher *Cipher
+)
+
+// Cipher encrypts and decrypts values with one key
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a key of any length, the key is hashed to 32 bytes.
+//
+// Parameters:
+//   - key: Encryption key, must not be empty
+//
+// Returns:
+//   - *Cipher: Cipher ready for use
+//   - error: ErrNoKey if the key is empty
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt encrypts a value, an empty value stays empty
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt.
+// Values that are not encrypted are returned unchanged, so rows written before
+// encryption was introduced keep working.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether a value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
+
+// LoadKey returns the encryption key from EnvKey, or from keyFile if the
+// variable is not set. A missing key file is created with a random key.
+//
+// Parameters:
+//   - keyFile: Path of the key file used when EnvKey is not set
+//
+// Returns:
+//   - []byte: Encryption key
+//   - error: Error if the key file cannot be read or created
+func LoadKey(keyFile string) ([]byte, error) {
+	if key := EnvValue(); key != nil {
+		return key, nil
+	}
+
+	key, err := ReadKeyFile(keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return CreateKeyFile(keyFile)
+	}
+	return key, err
+}
+
+// EnvValue returns the encryption key set in EnvKey, nil if it is not set
+func EnvValue() []byte {
+	if key := strings.TrimSpace(os.Getenv(EnvKey)); key != "" {
+		return []byte(key)
+	}
+	return nil
+}
+
+// ReadKeyFile reads the encryption key from a key file.
+//
+// Parameters:
+//   - keyFile: Path of the key file
+//
+// Returns:
+//   - []byte: Encryption key
+//   - error: Error wrapping os.ErrNotExist if the file does not exist, ErrNoKey if it is empty
+func ReadKeyFile(keyFile string) ([]byte, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("密钥文件不存在: %s: %w", keyFile, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return nil, fmt.Errorf("%w: 密钥文件为空 %s", ErrNoKey, keyFile)
+	}
+	return []byte(key), nil
+}
+
+// CreateKeyFile writes a random key to a new key file readable only by the owner
+func CreateKeyFile(keyFile string) ([]byte, error) {
+	key, err := RandomKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		return nil, fmt.Errorf("创建密钥文件失败: %w", err)
+	}
+	return key, nil
+}
+
+// RandomKey generates a random hex encoded key
+func RandomKey() ([]byte, error) {
+	raw := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %w", err)
+	}
+	return []byte(hex.EncodeToString(raw)), nil
+}
+
+// SetDefault sets the cipher used by Encrypt and Decrypt
+func SetDefault(c *Cipher) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultCipher = c
+}
+
+// Default returns the cipher set with SetDefault, nil if none was set
+func Default() *Cipher {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultCipher
+}
+
+// Encrypt encrypts a value with the default cipher
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	c := Default()
+	if c == nil {
+		return "", ErrNoKey
+	}
+	return c.Encrypt(plaintext)
+}
+
+// Decrypt decrypts a value with the default cipher
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	c := Default()
+	if c == nil {
+		return "", ErrNoKey
+	}
+	return c.Decrypt(value)
+}
@@ -0,0 +1,97 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	c, err := NewCipher([]byte("test-key"))
+	require.NoError(t, err)
+
+	encrypted, err := c.Encrypt("fofa-api-key")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "fofa-api-key")
+
+	decrypted, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "fofa-api-key", decrypted)
+
+	// 空值和未加密的旧数据保持不变
+	empty, err := c.Encrypt("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+	plain, err := c.Decrypt("legacy-value")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-value", plain)
+
+	other, err := NewCipher([]byte("other-key"))
+	require.NoError(t, err)
+	_, err = other.Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = NewCipher(nil)
+	assert.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestLoadKey(t *testing.T) {
+	t.Setenv(EnvKey, "")
+	keyFile := filepath.Join(t.TempDir(), "secret.key")
+
+	key, err := LoadKey(keyFile)
+	require.NoError(t, err)
+	assert.NotEmpty(t, key)
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// 再次加载得到相同的密钥
+	again, err := LoadKey(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+
+	t.Setenv(EnvKey, "from-env")
+	key, err = LoadKey(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from-env"), key)
+}
+
+func TestDefaultCipher(t *testing.T) {
+	SetDefault(nil)
+	_, err := Encrypt("value")
+	assert.ErrorIs(t, err, ErrNoKey)
+
+	c, err := NewCipher([]byte("test-key"))
+	require.NoError(t, err)
+	SetDefault(c)
+	defer SetDefault(nil)
+
+	encrypted, err := Encrypt("value")
+	require.NoError(t, err)
+	decrypted, err := Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "value", decrypted)
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	_, err := ReadKeyFile(filepath.Join(dir, "missing.key"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	empty := filepath.Join(dir, "empty.key")
+	require.NoError(t, os.WriteFile(empty, []byte("\n"), 0600))
+	_, err = ReadKeyFile(empty)
+	assert.ErrorIs(t, err, ErrNoKey)
+
+	created, err := CreateKeyFile(filepath.Join(dir, "new.key"))
+	require.NoError(t, err)
+	read, err := ReadKeyFile(filepath.Join(dir, "new.key"))
+	require.NoError(t, err)
+	assert.Equal(t, created, read)
+}
@@ -179,6 +179,13 @@ func (s *AppConfigService) SetDefaultConfig(id uint) error {
 	return tx.Commit().Error
 }
 
+// AppConfigFields are the paths of the configuration fields set by SaveToConfig, see config.ConfigSource
+var AppConfigFields = []string{
+	"proxy", "guard_prompt", "system_prompt", "max_step", "language", "placeholders",
+	"mcp.config_file", "mcp.prefix_tool_names", "mcp.tool_description_language", "mcp.tools",
+	"output", "integrations", "notifications",
+}
+
 // SaveToConfig converts the database model to a config.Config
 func (s *AppConfigService) SaveToConfig(appConfig *models.AppConfigModel, targetConfig *config.Config) error {
 	if appConfig == nil {
@@ -187,8 +194,10 @@ func (s *AppConfigService) SaveToConfig(appConfig *models.AppConfigModel, target
 
 	// 设置基本配置
 	targetConfig.Proxy = appConfig.Proxy
+	targetConfig.GuardPrompt = appConfig.GuardPrompt
 	targetConfig.SystemPrompt = appConfig.SystemPrompt
 	targetConfig.MaxStep = appConfig.MaxStep
+	targetConfig.Language = appConfig.Language
 
 	// 获取并设置占位符
 	placeholders, err := appConfig.GetPlaceHoldersAsMap()
@@ -202,6 +211,8 @@ func (s *AppConfigService) SaveToConfig(appConfig *models.AppConfigModel, target
 	if err == nil && mcpConfig != nil {
 		// 设置MCP配置
 		targetConfig.MCP.ConfigFile = mcpConfig.ConfigFile
+		targetConfig.MCP.PrefixToolNames = mcpConfig.PrefixToolNames
+		targetConfig.MCP.ToolDescriptionLanguage = mcpConfig.ToolDescriptionLanguage
 
 		// 转换工具列表
 		var configTools []config.MCPToolConfig
@@ -217,6 +228,45 @@ func (s *AppConfigService) SaveToConfig(appConfig *models.AppConfigModel, target
 		// 但会保留tools的选择
 	}
 
+	// 获取结果后处理配置
+	outputConfig, err := appConfig.GetOutputConfig()
+	if err != nil {
+		return err
+	}
+	targetConfig.Output = config.OutputConfig{
+		StripMarkdownLinks: outputConfig.StripMarkdownLinks,
+		MaxLength:          outputConfig.MaxLength,
+	}
+	for _, rule := range outputConfig.Redact {
+		targetConfig.Output.Redact = append(targetConfig.Output.Redact, config.RedactRule{
+			Pattern:     rule.Pattern,
+			Replacement: rule.Replacement,
+		})
+	}
+
+	// 获取第三方服务集成配置
+	integrations, err := appConfig.GetIntegrationsConfig()
+	if err != nil {
+		return err
+	}
+	fofaKey, err := appConfig.GetFOFAKey()
+	if err != nil {
+		return err
+	}
+	targetConfig.Integrations.FOFA = config.FOFAConfig{
+		Email:   integrations.FOFA.Email,
+		Key:     fofaKey,
+		BaseURL: integrations.FOFA.BaseURL,
+		MaxSize: integrations.FOFA.MaxSize,
+	}
+
+	// 获取任务事件通知配置
+	notifications, err := appConfig.GetNotificationsConfig()
+	if err != nil {
+		return err
+	}
+	targetConfig.Notifications = notificationsToConfig(notifications)
+
 	return nil
 }
 
@@ -228,8 +278,10 @@ func (s *AppConfigService) LoadFromConfig(sourceConfig *config.Config, appConfig
 
 	// 设置基本配置
 	appConfig.Proxy = sourceConfig.Proxy
+	appConfig.GuardPrompt = sourceConfig.GuardPrompt
 	appConfig.SystemPrompt = sourceConfig.SystemPrompt
 	appConfig.MaxStep = sourceConfig.MaxStep
+	appConfig.Language = sourceConfig.Language
 
 	// 设置占位符
 	if err := appConfig.SetPlaceHoldersFromMap(sourceConfig.PlaceHolders); err != nil {
@@ -247,16 +299,113 @@ func (s *AppConfigService) LoadFromConfig(sourceConfig *config.Config, appConfig
 
 	// 设置MCP配置
 	mcpConfig := &models.MCPConfig{
-		ConfigFile: sourceConfig.MCP.ConfigFile,
-		Tools:      modelTools,
+		ConfigFile:      sourceConfig.MCP.ConfigFile,
+		Tools:           modelTools,
+		PrefixToolNames: sourceConfig.MCP.PrefixToolNames,
+
+		ToolDescriptionLanguage: sourceConfig.MCP.ToolDescriptionLanguage,
 	}
 	if err := appConfig.SetMCPConfig(mcpConfig); err != nil {
 		return err
 	}
 
+	// 设置结果后处理配置
+	outputConfig := &models.OutputConfig{
+		StripMarkdownLinks: sourceConfig.Output.StripMarkdownLinks,
+		MaxLength:          sourceConfig.Output.MaxLength,
+	}
+	for _, rule := range sourceConfig.Output.Redact {
+		outputConfig.Redact = append(outputConfig.Redact, models.RedactRule{
+			Pattern:     rule.Pattern,
+			Replacement: rule.Replacement,
+		})
+	}
+	if err := appConfig.SetOutputConfig(outputConfig); err != nil {
+		return err
+	}
+
+	// 设置第三方服务集成配置，API Key加密后单独存储
+	fofaCfg := sourceConfig.Integrations.FOFA
+	if err := appConfig.SetIntegrationsConfig(&models.IntegrationsConfig{
+		FOFA: models.FOFAConfig{
+			Email:   fofaCfg.Email,
+			BaseURL: fofaCfg.BaseURL,
+			MaxSize: fofaCfg.MaxSize,
+		},
+	}); err != nil {
+		return err
+	}
+	if err := appConfig.SetFOFAKey(fofaCfg.Key); err != nil {
+		return err
+	}
+
+	// 设置任务事件通知配置
+	if err := appConfig.SetNotificationsConfig(notificationsToModel(sourceConfig.Notifications)); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// GetNotificationsConfig returns the notification sinks stored with the
+// default configuration, none when there is no default configuration
+func (s *AppConfigService) GetNotificationsConfig() (config.NotificationsConfig, error) {
+	appConfig, err := s.GetDefaultConfig()
+	if err != nil {
+		if err == models.ErrAppConfigNotFound {
+			return config.NotificationsConfig{}, nil
+		}
+		return config.NotificationsConfig{}, err
+	}
+	notifications, err := appConfig.GetNotificationsConfig()
+	if err != nil {
+		return config.NotificationsConfig{}, err
+	}
+	return notificationsToConfig(notifications), nil
+}
+
+// notificationsToConfig converts the stored notification sinks to their configuration
+func notificationsToConfig(notifications *models.NotificationsConfig) config.NotificationsConfig {
+	var result config.NotificationsConfig
+	for _, webhook := range notifications.Webhooks {
+		result.Webhooks = append(result.Webhooks, config.WebhookConfig{
+			URL:     webhook.URL,
+			Events:  webhook.Events,
+			Headers: webhook.Headers,
+			Timeout: webhook.Timeout,
+			Retries: webhook.Retries,
+		})
+	}
+	for _, file := range notifications.Files {
+		result.Files = append(result.Files, config.FileSinkConfig{
+			Path:   file.Path,
+			Events: file.Events,
+		})
+	}
+	return result
+}
+
+// notificationsToModel converts the notification sinks of a configuration for storage
+func notificationsToModel(notifications config.NotificationsConfig) *models.NotificationsConfig {
+	result := &models.NotificationsConfig{}
+	for _, webhook := range notifications.Webhooks {
+		result.Webhooks = append(result.Webhooks, models.WebhookConfig{
+			URL:     webhook.URL,
+			Events:  webhook.Events,
+			Headers: webhook.Headers,
+			Timeout: webhook.Timeout,
+			Retries: webhook.Retries,
+		})
+	}
+	for _, file := range notifications.Files {
+		result.Files = append(result.Files, models.FileSinkConfig{
+			Path:   file.Path,
+			Events: file.Events,
+		})
+	}
+	return result
+}
+
 // clearDefaultConfigs removes default flag from all configurations
 func (s *AppConfigService) clearDefaultConfigs() error {
 	return s.db.Model(&models.AppConfigModel{}).Where("is_active = ?", true).Update("is_default", false).Error
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppConfigServiceFOFAKeyEncrypted(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewAppConfigService()
+	source := config.NewDefaultConfig()
+	source.Integrations.FOFA = config.FOFAConfig{
+		Email:   "user@example.com",
+		Key:     "fofa-secret-key",
+		MaxSize: 50,
+	}
+
+	appConfig := &models.AppConfigModel{Name: "fofa", MaxStep: 20}
+	require.NoError(t, service.LoadFromConfig(source, appConfig))
+	require.NoError(t, service.CreateConfig(appConfig))
+
+	// 数据库中保存的是密文
+	var stored models.AppConfigModel
+	require.NoError(t, service.db.First(&stored, appConfig.ID).Error)
+	assert.True(t, secret.IsEncrypted(stored.FOFAKey))
+	assert.NotContains(t, stored.FOFAKey, "fofa-secret-key")
+	assert.NotContains(t, stored.Integrations, "fofa-secret-key")
+
+	target := config.NewDefaultConfig()
+	require.NoError(t, service.SaveToConfig(&stored, target))
+	assert.Equal(t, source.Integrations.FOFA, target.Integrations.FOFA)
+}
+
+func TestAppConfigServiceNotifications(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewAppConfigService()
+	notifications, err := service.GetNotificationsConfig()
+	require.NoError(t, err)
+	assert.False(t, notifications.Enabled(), "没有默认配置时不推送通知")
+
+	source := config.NewDefaultConfig()
+	source.Notifications = config.NotificationsConfig{
+		Webhooks: []config.WebhookConfig{{
+			URL:     "https://hooks.slack.com/services/T000/B000/XXX",
+			Events:  []string{config.NotificationEventResult},
+			Headers: map[string]string{"Authorization": "Bearer webhook-token"},
+			Timeout: 5,
+			Retries: 2,
+		}},
+		Files: []config.FileSinkConfig{{Path: "./data/notify.jsonl"}},
+	}
+
+	appConfig := &models.AppConfigModel{Name: "notifications", MaxStep: 20, IsDefault: true, IsActive: true}
+	require.NoError(t, service.LoadFromConfig(source, appConfig))
+	require.NoError(t, service.CreateConfig(appConfig))
+
+	// Webhook地址和请求头加密保存
+	var stored string
+	require.NoError(t, service.db.Table("app_configs").Where("id = ?", appConfig.ID).Pluck("notifications", &stored).Error)
+	assert.True(t, secret.IsEncrypted(stored))
+	assert.NotContains(t, stored, "webhook-token")
+
+	target := config.NewDefaultConfig()
+	loaded, err := service.GetDefaultConfig()
+	require.NoError(t, err)
+	require.NoError(t, service.SaveToConfig(loaded, target))
+	assert.Equal(t, source.Notifications, target.Notifications)
+
+	notifications, err = service.GetNotificationsConfig()
+	require.NoError(t, err)
+	assert.Equal(t, source.Notifications, notifications)
+}
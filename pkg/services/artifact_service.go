@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/LubyRuffy/mcpagent/pkg/artifact"
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"gorm.io/gorm"
+)
+
+// ArtifactService provides business logic for the metadata of tool artifacts
+type ArtifactService struct {
+	db *gorm.DB
+}
+
+// NewArtifactService creates a new artifact service instance
+func NewArtifactService() *ArtifactService {
+	return &ArtifactService{
+		db: database.GetDB(),
+	}
+}
+
+// RecordArtifact stores the metadata of a saved artifact, implementing artifact.Recorder
+func (s *ArtifactService) RecordArtifact(a *artifact.Artifact) error {
+	record := &models.ArtifactModel{
+		ID:        a.ID,
+		TaskID:    a.TaskID,
+		ToolName:  a.ToolName,
+		MimeType:  a.MimeType,
+		Size:      a.Size,
+		Path:      a.Path,
+		CreatedAt: a.CreatedAt,
+	}
+	return database.RetryOnBusy(func() error {
+		return s.db.Create(record).Error
+	})
+}
+
+// GetArtifact returns an artifact of a task
+func (s *ArtifactService) GetArtifact(taskID, id string) (*models.ArtifactModel, error) {
+	var record models.ArtifactModel
+	if err := s.db.Where("id = ? AND task_id = ?", id, taskID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrArtifactNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ListTaskArtifacts returns the artifacts of a task, oldest first
+func (s *ArtifactService) ListTaskArtifacts(taskID string) ([]models.ArtifactModel, error) {
+	var records []models.ArtifactModel
+	err := s.db.Where("task_id = ?", taskID).Order("created_at ASC, id ASC").Find(&records).Error
+	return records, err
+}
+
+// DeleteTaskArtifacts removes the metadata of all artifacts of a task
+func (s *ArtifactService) DeleteTaskArtifacts(taskID string) error {
+	return s.db.Where("task_id = ?", taskID).Delete(&models.ArtifactModel{}).Error
+}
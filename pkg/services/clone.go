@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// cloneNameStyle describes how the names of cloned records are built
+type cloneNameStyle struct {
+	first  string         // 第一个副本的名称格式，如 "%s (copy)"
+	nth    string         // 后续副本的名称格式，如 "%s (copy %d)"
+	suffix *regexp.Regexp // 匹配已有的副本后缀，克隆副本时先去掉
+}
+
+var (
+	// displayCloneName is used for records whose names are only shown to users
+	displayCloneName = cloneNameStyle{
+		first:  "%s (copy)",
+		nth:    "%s (copy %d)",
+		suffix: regexp.MustCompile(` \(copy(?: \d+)?\)$`),
+	}
+
+	// identifierCloneName is used for MCP servers, whose names are part of tool keys
+	// and presented tool names and therefore must not contain spaces or brackets
+	identifierCloneName = cloneNameStyle{
+		first:  "%s-copy",
+		nth:    "%s-copy-%d",
+		suffix: regexp.MustCompile(`-copy(?:-\d+)?$`),
+	}
+)
+
+// uniqueCloneName returns the first copy name of name that is not used in the table of model.
+// Inactive records are included because the name column has a unique index.
+func uniqueCloneName(db *gorm.DB, model interface{}, name string, style cloneNameStyle) (string, error) {
+	base := style.suffix.ReplaceAllString(name, "")
+	candidate := fmt.Sprintf(style.first, base)
+	for n := 2; ; n++ {
+		var count int64
+		if err := db.Unscoped().Model(model).Where("name = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf(style.nth, base, n)
+	}
+}
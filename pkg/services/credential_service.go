@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"gorm.io/gorm"
+)
+
+// CredentialService provides business logic for the credentials referenced by MCP servers
+type CredentialService struct {
+	db *gorm.DB
+}
+
+// NewCredentialService creates a new credential service instance
+func NewCredentialService() *CredentialService {
+	return &CredentialService{
+		db: database.GetDB(),
+	}
+}
+
+// ListCredentials returns all credentials ordered by name
+func (s *CredentialService) ListCredentials() ([]models.CredentialModel, error) {
+	var credentials []models.CredentialModel
+	err := s.db.Order("name ASC").Find(&credentials).Error
+	return credentials, err
+}
+
+// GetCredential returns a specific credential by ID
+func (s *CredentialService) GetCredential(id uint) (*models.CredentialModel, error) {
+	var credential models.CredentialModel
+	err := s.db.Where("id = ?", id).First(&credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrCredentialNotFound
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// CreateCredential creates a new credential
+func (s *CredentialService) CreateCredential(credential *models.CredentialModel) error {
+	if err := credential.Validate(); err != nil {
+		return err
+	}
+
+	// 检查名称是否已存在
+	var count int64
+	err := s.db.Model(&models.CredentialModel{}).Where("name = ?", credential.Name).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return models.ErrCredentialNameExists
+	}
+
+	return s.db.Create(credential).Error
+}
+
+// UpdateCredential updates the name, description and value of a credential.
+// An empty value keeps the stored one, since clients never receive it.
+func (s *CredentialService) UpdateCredential(id uint, updates *models.CredentialModel) error {
+	existing, err := s.GetCredential(id)
+	if err != nil {
+		return err
+	}
+	if updates.Value == "" {
+		updates.Value = existing.Value
+	}
+	if err := updates.Validate(); err != nil {
+		return err
+	}
+
+	// 检查名称是否与其他凭据冲突
+	if updates.Name != existing.Name {
+		var count int64
+		err := s.db.Model(&models.CredentialModel{}).Where("name = ? AND id != ?", updates.Name, id).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return models.ErrCredentialNameExists
+		}
+	}
+
+	updates.ID = id
+	return s.db.Model(existing).Select("name", "description", "value").Updates(updates).Error
+}
+
+// DeleteCredential deletes a credential together with its value
+func (s *CredentialService) DeleteCredential(id uint) error {
+	credential, err := s.GetCredential(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(credential).Error
+}
+
+// Lookup returns the value of the credential with the given name, it is a models.CredentialLookup
+func (s *CredentialService) Lookup(name string) (string, error) {
+	var credential models.CredentialModel
+	err := s.db.Where("name = ?", name).First(&credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("%w: %s", models.ErrCredentialNotFound, name)
+		}
+		return "", fmt.Errorf("读取凭据 %s 失败: %w", name, err)
+	}
+	return credential.Value, nil
+}
+
+// MissingReferences returns the credentials referenced by a server that do not exist
+//
+// Returns:
+//   - []string: Sorted names of the missing credentials, empty if all exist
+//   - error: Error if the credentials cannot be read
+func (s *CredentialService) MissingReferences(server *models.MCPServerConfigModel) ([]string, error) {
+	names := server.CredentialReferences()
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var existing []string
+	if err := s.db.Model(&models.CredentialModel{}).Where("name IN ?", names).Pluck("name", &existing).Error; err != nil {
+		return nil, fmt.Errorf("读取凭据失败: %w", err)
+	}
+	found := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		found[name] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// ResolveServerConfig converts a server to the configuration used to connect
+// to it, with its credential references resolved
+func ResolveServerConfig(server *models.MCPServerConfigModel) (mcphost.ServerConfig, error) {
+	var lookup models.CredentialLookup
+	if database.GetDB() != nil {
+		lookup = NewCredentialService().Lookup
+	}
+	return server.ToResolvedServerConfig(lookup)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialService_CRUD(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewCredentialService()
+	credential := &models.CredentialModel{Name: "shodan", Value: "s3cret", Description: "Shodan API Key"}
+	require.NoError(t, service.CreateCredential(credential))
+	assert.NotZero(t, credential.ID)
+	assert.ErrorIs(t, service.CreateCredential(&models.CredentialModel{Name: "shodan", Value: "x"}), models.ErrCredentialNameExists)
+
+	value, err := service.Lookup("shodan")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+	_, err = service.Lookup("missing")
+	assert.ErrorIs(t, err, models.ErrCredentialNotFound)
+
+	// 更新时值为空则保留原值
+	require.NoError(t, service.UpdateCredential(credential.ID, &models.CredentialModel{Name: "shodan", Description: "新描述"}))
+	updated, err := service.GetCredential(credential.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "新描述", updated.Description)
+	assert.Equal(t, "s3cret", updated.Value)
+
+	require.NoError(t, service.UpdateCredential(credential.ID, &models.CredentialModel{Name: "shodan", Value: "rotated"}))
+	value, err = service.Lookup("shodan")
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", value)
+
+	server := &models.MCPServerConfigModel{Name: "scanner", Command: "scanner"}
+	require.NoError(t, server.SetArgs([]string{"{{credential:shodan}}", "{{credential:fofa}}"}))
+	missing, err := service.MissingReferences(server)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fofa"}, missing)
+
+	// 删除后可以重新创建同名凭据
+	require.NoError(t, service.DeleteCredential(credential.ID))
+	_, err = service.GetCredential(credential.ID)
+	assert.ErrorIs(t, err, models.ErrCredentialNotFound)
+	require.NoError(t, service.CreateCredential(&models.CredentialModel{Name: "shodan", Value: "again"}))
+}
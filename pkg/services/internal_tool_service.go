@@ -33,7 +33,8 @@ func NewInternalToolService() *InternalToolService {
 // This function should be called during application startup.
 func (s *InternalToolService) SyncInternalTools(ctx context.Context) error {
 	// 获取内置工具
-	internalTools, err := config.GetInternalTools(ctx, "")
+	// 同步只需要工具信息，未配置凭据的工具也同步，以便在界面中选择
+	internalTools, err := config.GetInternalToolCatalog(ctx)
 	if err != nil {
 		return fmt.Errorf("获取内置工具失败: %w", err)
 	}
@@ -50,6 +51,20 @@ func (s *InternalToolService) SyncInternalTools(ctx context.Context) error {
 		return fmt.Errorf("确保内置服务器记录存在失败: %w", err)
 	}
 
+	// 另一个连接正在写入时重试
+	if err := database.RetryOnBusy(func() error {
+		return s.saveInternalTools(ctx, internalServer, internalTools)
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("成功同步 %d 个内置工具", len(internalTools))
+	return nil
+}
+
+// saveInternalTools updates the stored internal tools in one transaction,
+// tools that are no longer internal tools are disabled
+func (s *InternalToolService) saveInternalTools(ctx context.Context, internalServer *models.MCPServerConfigModel, internalTools []tool.BaseTool) error {
 	// 开始事务
 	tx := s.db.Begin()
 	defer func() {
@@ -159,8 +174,6 @@ func (s *InternalToolService) SyncInternalTools(ctx context.Context) error {
 	if err := tx.Commit().Error; err != nil {
 		return fmt.Errorf("提交事务失败: %w", err)
 	}
-
-	log.Printf("成功同步 %d 个内置工具", len(internalTools))
 	return nil
 }
 
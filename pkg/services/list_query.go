@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPageSize is the page size of a paginated list that sets only a page
+	DefaultPageSize = 20
+
+	// MaxPageSize is the largest page size, larger sizes are reduced to it
+	MaxPageSize = 200
+
+	// defaultListSort orders lists without an explicit sort
+	defaultListSort = "name"
+)
+
+// listSortColumns maps the sort fields of list queries to their columns
+var listSortColumns = map[string]string{
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// ListQuery selects a page of a list. The zero value lists all items ordered by name.
+type ListQuery struct {
+	Page     int    // 页码，从1开始，0表示不分页
+	PageSize int    // 每页数量，0表示不分页，超过MaxPageSize时按MaxPageSize处理
+	Sort     string // 排序字段：name、created_at、updated_at，以 - 开头表示降序
+	Q        string // 按名称或描述过滤的子串，不区分大小写
+}
+
+// Pagination describes the page returned for a ListQuery
+type Pagination struct {
+	Total    int64 `json:"total"`     // 满足过滤条件的总数
+	Page     int   `json:"page"`      // 当前页码
+	PageSize int   `json:"page_size"` // 每页数量，0表示未分页
+}
+
+// normalize returns the query with defaults applied: a query with only a
+// page uses DefaultPageSize, a query with only a page size starts at page 1
+func (q ListQuery) normalize() ListQuery {
+	if q.Page <= 0 && q.PageSize <= 0 {
+		return ListQuery{Sort: q.Sort, Q: q.Q}
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+	return q
+}
+
+// order returns the ORDER BY clause of the sort field. The ID breaks ties,
+// so that pages do not overlap when many rows share a value.
+func (q ListQuery) order() (string, error) {
+	sort := strings.TrimSpace(q.Sort)
+	if sort == "" {
+		sort = defaultListSort
+	}
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+		sort = sort[1:]
+	}
+	column, ok := listSortColumns[sort]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", models.ErrListSortInvalid, q.Sort)
+	}
+	return fmt.Sprintf("%s %s, id %s", column, direction, direction), nil
+}
+
+// paginate filters, counts, orders and pages db, which must select the
+// table of dest, and stores the rows of the page in dest.
+//
+// Parameters:
+//   - db: Query with the conditions of the list, e.g. is_active
+//   - q: Page, sort and filter of the request
+//   - dest: Pointer to the slice receiving the rows
+//
+// Returns:
+//   - Pagination: Total number of rows and the page returned
+//   - error: models.ErrListSortInvalid for an unknown sort field, or the database error
+func paginate(db *gorm.DB, q ListQuery, dest any) (Pagination, error) {
+	q = q.normalize()
+	order, err := q.order()
+	if err != nil {
+		return Pagination{}, err
+	}
+
+	if filter := strings.TrimSpace(q.Q); filter != "" {
+		pattern := "%" + escapeLike(strings.ToLower(filter)) + "%"
+		db = db.Where("(LOWER(name) LIKE ? ESCAPE '\\' OR LOWER(description) LIKE ? ESCAPE '\\')", pattern, pattern)
+	}
+
+	pagination := Pagination{Page: 1, PageSize: q.PageSize}
+	if err := db.Session(&gorm.Session{}).Count(&pagination.Total).Error; err != nil {
+		return Pagination{}, err
+	}
+
+	db = db.Order(order)
+	if q.PageSize > 0 {
+		pagination.Page = q.Page
+		db = db.Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize)
+	}
+	if err := db.Find(dest).Error; err != nil {
+		return Pagination{}, err
+	}
+	return pagination, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -5,6 +5,7 @@ package services
 import (
 	"fmt"
 
+	"github.com/LubyRuffy/mcpagent/pkg/config"
 	"github.com/LubyRuffy/mcpagent/pkg/database"
 	"github.com/LubyRuffy/mcpagent/pkg/models"
 	"gorm.io/gorm"
@@ -29,6 +30,14 @@ func (s *LLMConfigService) ListConfigs() ([]models.LLMConfigModel, error) {
 	return configs, err
 }
 
+// QueryConfigs returns a page of the active LLM configurations, filtered
+// and ordered by q. Without a sort the configurations are ordered by name.
+func (s *LLMConfigService) QueryConfigs(q ListQuery) ([]models.LLMConfigModel, Pagination, error) {
+	configs := []models.LLMConfigModel{}
+	pagination, err := paginate(s.db.Model(&models.LLMConfigModel{}).Where("is_active = ?", true), q, &configs)
+	return configs, pagination, err
+}
+
 // GetConfig returns a specific LLM configuration by ID
 func (s *LLMConfigService) GetConfig(id uint) (*models.LLMConfigModel, error) {
 	var config models.LLMConfigModel
@@ -42,6 +51,19 @@ func (s *LLMConfigService) GetConfig(id uint) (*models.LLMConfigModel, error) {
 	return &config, nil
 }
 
+// GetConfigByName returns a specific LLM configuration by name
+func (s *LLMConfigService) GetConfigByName(name string) (*models.LLMConfigModel, error) {
+	var config models.LLMConfigModel
+	err := s.db.Where("name = ? AND is_active = ?", name, true).First(&config).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, models.ErrLLMConfigNotFound
+		}
+		return nil, err
+	}
+	return &config, nil
+}
+
 // GetDefaultConfig returns the default LLM configuration
 func (s *LLMConfigService) GetDefaultConfig() (*models.LLMConfigModel, error) {
 	var config models.LLMConfigModel
@@ -71,6 +93,9 @@ func (s *LLMConfigService) CreateConfig(config *models.LLMConfigModel) error {
 	if count > 0 {
 		return models.ErrLLMConfigNameExists
 	}
+	if err := s.checkDefaultPrompt(config); err != nil {
+		return err
+	}
 
 	// 如果设置为默认配置，需要先取消其他默认配置
 	if config.IsDefault {
@@ -83,7 +108,8 @@ func (s *LLMConfigService) CreateConfig(config *models.LLMConfigModel) error {
 	return s.db.Create(config).Error
 }
 
-// UpdateConfig updates an existing LLM configuration
+// UpdateConfig updates an existing LLM configuration. Unlike the other
+// fields, a nil DefaultSystemPromptID or DefaultMaxStep clears the stored value.
 func (s *LLMConfigService) UpdateConfig(id uint, updates *models.LLMConfigModel) error {
 	// 验证更新数据
 	if err := updates.Validate(); err != nil {
@@ -111,6 +137,9 @@ func (s *LLMConfigService) UpdateConfig(id uint, updates *models.LLMConfigModel)
 			return models.ErrLLMConfigNameExists
 		}
 	}
+	if err := s.checkDefaultPrompt(updates); err != nil {
+		return err
+	}
 
 	// 如果设置为默认配置，需要先取消其他默认配置
 	if updates.IsDefault && !existingConfig.IsDefault {
@@ -119,9 +148,32 @@ func (s *LLMConfigService) UpdateConfig(id uint, updates *models.LLMConfigModel)
 		}
 	}
 
-	// 更新配置
+	// 更新配置，Updates会跳过nil字段，默认提示词和步数单独更新以便清空
 	updates.ID = id
-	return s.db.Model(&existingConfig).Updates(updates).Error
+	if err := s.db.Model(&existingConfig).Updates(updates).Error; err != nil {
+		return err
+	}
+	return s.db.Model(&existingConfig).Updates(map[string]interface{}{
+		"default_system_prompt_id": updates.DefaultSystemPromptID,
+		"default_max_step":         updates.DefaultMaxStep,
+	}).Error
+}
+
+// checkDefaultPrompt returns models.ErrLLMConfigDefaultPromptNotFound when
+// the default system prompt of config does not exist
+func (s *LLMConfigService) checkDefaultPrompt(config *models.LLMConfigModel) error {
+	if config.DefaultSystemPromptID == nil {
+		return nil
+	}
+	var count int64
+	err := s.db.Model(&models.SystemPromptModel{}).Where("id = ? AND is_active = ?", *config.DefaultSystemPromptID, true).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return models.ErrLLMConfigDefaultPromptNotFound
+	}
+	return nil
 }
 
 // DeleteConfig soft deletes an LLM configuration
@@ -180,7 +232,51 @@ func (s *LLMConfigService) SetDefaultConfig(id uint) error {
 	return tx.Commit().Error
 }
 
+// CloneConfig copies an LLM configuration under a new unique name.
+// The copy is never the default configuration; the original is not modified.
+func (s *LLMConfigService) CloneConfig(id uint) (*models.LLMConfigModel, error) {
+	original, err := s.GetConfig(id)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := uniqueCloneName(s.db, &models.LLMConfigModel{}, original.Name, displayCloneName)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := &models.LLMConfigModel{
+		Name:        name,
+		Description: original.Description,
+		Type:        original.Type,
+		BaseURL:     original.BaseURL,
+		Model:       original.Model,
+		APIKey:      original.APIKey,
+		Temperature: original.Temperature,
+		MaxTokens:   original.MaxTokens,
+		IsDefault:   false,
+		IsActive:    true,
+
+		DefaultSystemPromptID: original.DefaultSystemPromptID,
+		DefaultMaxStep:        original.DefaultMaxStep,
+	}
+	if err := s.CreateConfig(clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
 // clearDefaultConfigs removes default flag from all configurations
 func (s *LLMConfigService) clearDefaultConfigs() error {
 	return s.db.Model(&models.LLMConfigModel{}).Where("is_active = ?", true).Update("is_default", false).Error
 }
+
+// LLMConfigToConfig converts a stored LLM configuration to config.LLMConfig
+func LLMConfigToConfig(m *models.LLMConfigModel) config.LLMConfig {
+	return config.LLMConfig{
+		Type:    m.Type,
+		BaseURL: m.BaseURL,
+		Model:   m.Model,
+		APIKey:  m.APIKey,
+	}
+}
@@ -278,3 +278,157 @@ func TestMain(m *testing.M) {
 	code := m.Run()
 	os.Exit(code)
 }
+
+func TestLLMConfigService_CloneConfig(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewLLMConfigService()
+
+	temperature := 0.2
+	original := &models.LLMConfigModel{
+		Name:        "Clone Source",
+		Description: "Test description",
+		Type:        "openai",
+		BaseURL:     "https://api.example.com/v1",
+		Model:       "gpt-4o",
+		APIKey:      "sk-test",
+		Temperature: &temperature,
+		IsDefault:   true,
+		IsActive:    true,
+	}
+	require.NoError(t, service.CreateConfig(original))
+
+	clone, err := service.CloneConfig(original.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, original.ID, clone.ID)
+	assert.Equal(t, "Clone Source (copy)", clone.Name)
+	assert.Equal(t, original.Model, clone.Model)
+	assert.Equal(t, original.APIKey, clone.APIKey)
+	require.NotNil(t, clone.Temperature)
+	assert.Equal(t, 0.2, *clone.Temperature)
+	assert.False(t, clone.IsDefault)
+
+	// 名称已被占用时递增编号，克隆副本也基于原名称
+	second, err := service.CloneConfig(original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Clone Source (copy 2)", second.Name)
+	third, err := service.CloneConfig(clone.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Clone Source (copy 3)", third.Name)
+
+	// 原配置不受影响
+	retrieved, err := service.GetConfig(original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Clone Source", retrieved.Name)
+	assert.True(t, retrieved.IsDefault)
+
+	_, err = service.CloneConfig(9999)
+	assert.ErrorIs(t, err, models.ErrLLMConfigNotFound)
+}
+
+func TestLLMConfigService_QueryConfigs(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewLLMConfigService()
+	for _, name := range []string{"Zeta", "alpha", "Beta 100%"} {
+		require.NoError(t, service.CreateConfig(&models.LLMConfigModel{
+			Name: name, Description: "查询测试", Type: "ollama", BaseURL: "http://localhost:11434", Model: "qwen3:14b", APIKey: "ollama", IsActive: true,
+		}))
+	}
+	all, err := service.ListConfigs()
+	require.NoError(t, err)
+	total := int64(len(all))
+
+	// 不带参数时按名称返回全部配置
+	list, pagination, err := service.QueryConfigs(ListQuery{})
+	require.NoError(t, err)
+	assert.Len(t, list, len(all))
+	assert.Equal(t, Pagination{Total: total, Page: 1, PageSize: 0}, pagination)
+	for i := 1; i < len(list); i++ {
+		assert.LessOrEqual(t, list[i-1].Name, list[i].Name)
+	}
+
+	// 过滤名称和描述，不区分大小写，通配符按字面匹配
+	list, pagination, err = service.QueryConfigs(ListQuery{Q: "ALPHA"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "alpha", list[0].Name)
+	assert.Equal(t, int64(1), pagination.Total)
+
+	list, _, err = service.QueryConfigs(ListQuery{Q: "100%"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Beta 100%", list[0].Name)
+
+	list, pagination, err = service.QueryConfigs(ListQuery{Q: "查询", Sort: "-name", Page: 1, PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, []string{"alpha", "Zeta"}, []string{list[0].Name, list[1].Name})
+	assert.Equal(t, Pagination{Total: 3, Page: 1, PageSize: 2}, pagination)
+
+	list, pagination, err = service.QueryConfigs(ListQuery{Q: "查询", Sort: "-name", Page: 2, PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Beta 100%", list[0].Name)
+	assert.Equal(t, int64(3), pagination.Total)
+
+	// 超出上限的每页数量按上限处理，只设置页码时使用默认数量
+	_, pagination, err = service.QueryConfigs(ListQuery{PageSize: MaxPageSize + 1})
+	require.NoError(t, err)
+	assert.Equal(t, Pagination{Total: total, Page: 1, PageSize: MaxPageSize}, pagination)
+	_, pagination, err = service.QueryConfigs(ListQuery{Page: 3})
+	require.NoError(t, err)
+	assert.Equal(t, Pagination{Total: total, Page: 3, PageSize: DefaultPageSize}, pagination)
+
+	_, _, err = service.QueryConfigs(ListQuery{Sort: "api_key"})
+	assert.ErrorIs(t, err, models.ErrListSortInvalid)
+}
+
+func TestLLMConfigService_DefaultBindings(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewLLMConfigService()
+	promptService := NewSystemPromptService()
+	prompt := &models.SystemPromptModel{Name: "terse", Content: "简短回答", IsActive: true}
+	require.NoError(t, promptService.CreatePrompt(prompt))
+
+	maxStep := 5
+	missing := uint(9999)
+	config := &models.LLMConfigModel{
+		Name: "small", Type: "ollama", BaseURL: "http://localhost:11434", Model: "qwen3:4b", APIKey: "ollama", IsActive: true,
+		DefaultSystemPromptID: &missing, DefaultMaxStep: &maxStep,
+	}
+	// 引用不存在的提示词
+	assert.ErrorIs(t, service.CreateConfig(config), models.ErrLLMConfigDefaultPromptNotFound)
+
+	config.DefaultSystemPromptID = &prompt.ID
+	require.NoError(t, service.CreateConfig(config))
+	stored, err := service.GetConfig(config.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.DefaultSystemPromptID)
+	assert.Equal(t, prompt.ID, *stored.DefaultSystemPromptID)
+	assert.Equal(t, 5, *stored.DefaultMaxStep)
+
+	// 最大步数必须大于0
+	zero := 0
+	updates := *stored
+	updates.DefaultMaxStep = &zero
+	assert.ErrorIs(t, service.UpdateConfig(stored.ID, &updates), models.ErrLLMConfigDefaultMaxStepInvalid)
+
+	// 更新时为nil的字段被清空
+	updates.DefaultMaxStep = nil
+	require.NoError(t, service.UpdateConfig(stored.ID, &updates))
+	stored, err = service.GetConfig(config.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.DefaultMaxStep)
+	assert.NotNil(t, stored.DefaultSystemPromptID)
+
+	// 删除提示词时清空引用
+	require.NoError(t, promptService.DeletePrompt(prompt.ID))
+	stored, err = service.GetConfig(config.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.DefaultSystemPromptID)
+}
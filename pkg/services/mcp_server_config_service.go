@@ -3,6 +3,10 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+
 	"github.com/LubyRuffy/mcpagent/pkg/database"
 	"github.com/LubyRuffy/mcpagent/pkg/models"
 	"gorm.io/gorm"
@@ -27,6 +31,14 @@ func (s *MCPServerConfigService) ListConfigs() ([]models.MCPServerConfigModel, e
 	return configs, err
 }
 
+// QueryConfigs returns a page of the active MCP server configurations,
+// filtered and ordered by q. Without a sort the servers are ordered by name.
+func (s *MCPServerConfigService) QueryConfigs(q ListQuery) ([]models.MCPServerConfigModel, Pagination, error) {
+	configs := []models.MCPServerConfigModel{}
+	pagination, err := paginate(s.db.Model(&models.MCPServerConfigModel{}).Where("is_active = ?", true), q, &configs)
+	return configs, pagination, err
+}
+
 // GetConfig returns a specific MCP server configuration by ID
 func (s *MCPServerConfigService) GetConfig(id uint) (*models.MCPServerConfigModel, error) {
 	var config models.MCPServerConfigModel
@@ -74,7 +86,8 @@ func (s *MCPServerConfigService) CreateConfig(config *models.MCPServerConfigMode
 	return s.db.Create(config).Error
 }
 
-// UpdateConfig updates an existing MCP server configuration
+// UpdateConfig updates an existing MCP server configuration.
+// Headers sent back with models.RedactedHeaderValue keep their stored values.
 func (s *MCPServerConfigService) UpdateConfig(id uint, updates *models.MCPServerConfigModel) error {
 	// 验证更新数据
 	if err := updates.Validate(); err != nil {
@@ -91,6 +104,11 @@ func (s *MCPServerConfigService) UpdateConfig(id uint, updates *models.MCPServer
 		return err
 	}
 
+	// 保留客户端以隐藏形式发回的HTTP头部
+	if err := updates.RestoreRedactedHeaders(&existingConfig); err != nil {
+		return err
+	}
+
 	// 检查名称是否与其他配置冲突
 	if updates.Name != existingConfig.Name {
 		var count int64
@@ -108,6 +126,18 @@ func (s *MCPServerConfigService) UpdateConfig(id uint, updates *models.MCPServer
 	return s.db.Model(&existingConfig).Updates(updates).Error
 }
 
+// UpdateLastSeenVersion records the MCP protocol version the server
+// negotiated when it was last connected, without touching updated_at
+func (s *MCPServerConfigService) UpdateLastSeenVersion(id uint, version string) error {
+	return s.db.Model(&models.MCPServerConfigModel{}).Where("id = ?", id).UpdateColumn("last_seen_version", version).Error
+}
+
+// UpdateLastTestError records the outcome of the connection test of a saved
+// server definition, an empty message clears the error. updated_at is not changed.
+func (s *MCPServerConfigService) UpdateLastTestError(id uint, message string) error {
+	return s.db.Model(&models.MCPServerConfigModel{}).Where("id = ?", id).UpdateColumn("last_test_error", message).Error
+}
+
 // DeleteConfig soft deletes an MCP server configuration
 func (s *MCPServerConfigService) DeleteConfig(id uint) error {
 	// 检查配置是否存在
@@ -124,6 +154,153 @@ func (s *MCPServerConfigService) DeleteConfig(id uint) error {
 	return s.db.Model(&config).Update("is_active", false).Error
 }
 
+// CloneConfig copies an MCP server configuration under a new unique name.
+// Args, Env and Headers are decoded and encoded again so a corrupt column is
+// reported instead of copied. The name prefix is not copied because it must
+// identify a single server. The original is not modified.
+func (s *MCPServerConfigService) CloneConfig(id uint) (*models.MCPServerConfigModel, error) {
+	original, err := s.GetConfig(id)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := uniqueCloneName(s.db, &models.MCPServerConfigModel{}, original.Name, identifierCloneName)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := &models.MCPServerConfigModel{
+		Name:          name,
+		Description:   original.Description,
+		TransportType: original.TransportType,
+		Command:       original.Command,
+		URL:           original.URL,
+		Disabled:      original.Disabled,
+		IsActive:      true,
+	}
+
+	args, err := original.GetArgsSlice()
+	if err != nil {
+		return nil, fmt.Errorf("解析参数失败: %w", err)
+	}
+	env, err := original.GetEnvMap()
+	if err != nil {
+		return nil, fmt.Errorf("解析环境变量失败: %w", err)
+	}
+	headers, err := original.GetHeadersSlice()
+	if err != nil {
+		return nil, fmt.Errorf("解析HTTP头部失败: %w", err)
+	}
+	if err := clone.SetArgs(args); err != nil {
+		return nil, err
+	}
+	if err := clone.SetEnv(env); err != nil {
+		return nil, err
+	}
+	if err := clone.SetHeaders(headers); err != nil {
+		return nil, err
+	}
+
+	if err := s.CreateConfig(clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// MCPServerImportResult lists the names of the servers an import created,
+// updated and left unchanged
+type MCPServerImportResult struct {
+	DryRun    bool     `json:"dry_run"`
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// importedColumns are the columns an import updates, the fields an
+// mcpservers.json entry defines
+var importedColumns = []string{"transport_type", "command", "args", "env", "url", "disabled", "updated_at"}
+
+// ImportConfigs creates the servers of configs whose name does not exist and
+// updates the definition of the others, as defined in mcpservers.json:
+// transport, command, args, env, URL and disabled flag. The description, name
+// prefix, concurrency and headers of existing servers are kept. Env values
+// sent back with models.RedactedHeaderValue keep their stored values. The
+// import runs in one transaction; with dryRun nothing is written.
+//
+// Parameters:
+//   - configs: Servers to import, replaced by the saved rows
+//   - dryRun: Only report the changes
+//
+// Returns:
+//   - *MCPServerImportResult: Names of the created, updated and unchanged servers
+//   - error: Error of the first invalid server, nothing is imported then
+func (s *MCPServerConfigService) ImportConfigs(configs []models.MCPServerConfigModel, dryRun bool) (*MCPServerImportResult, error) {
+	result := &MCPServerImportResult{DryRun: dryRun, Created: []string{}, Updated: []string{}, Unchanged: []string{}}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range configs {
+			config := &configs[i]
+			if err := config.Validate(); err != nil {
+				return fmt.Errorf("MCP服务器 %s 配置无效: %w", config.Name, err)
+			}
+
+			var existing models.MCPServerConfigModel
+			err := tx.Where("name = ? AND is_active = ?", config.Name, true).First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if err := config.RestoreRedactedEnv(&existing); err != nil {
+					return fmt.Errorf("MCP服务器 %s 配置无效: %w", config.Name, err)
+				}
+				config.IsActive = true
+				result.Created = append(result.Created, config.Name)
+				if !dryRun {
+					if err := tx.Create(config).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := config.RestoreRedactedEnv(&existing); err != nil {
+				return fmt.Errorf("MCP服务器 %s 配置无效: %w", config.Name, err)
+			}
+			if sameServerDefinition(&existing, config) {
+				result.Unchanged = append(result.Unchanged, config.Name)
+				*config = existing
+				continue
+			}
+			result.Updated = append(result.Updated, config.Name)
+			if !dryRun {
+				if err := tx.Model(&existing).Select(importedColumns).Updates(config).Error; err != nil {
+					return err
+				}
+			}
+			existing.TransportType = config.TransportType
+			existing.Command = config.Command
+			existing.Args = config.Args
+			existing.Env = config.Env
+			existing.URL = config.URL
+			existing.Disabled = config.Disabled
+			*config = existing
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sameServerDefinition reports whether both servers connect the same way.
+// The decoded configurations are compared, since equal args and env may be
+// stored as different JSON.
+func sameServerDefinition(a, b *models.MCPServerConfigModel) bool {
+	configA, errA := a.ToServerConfig()
+	configB, errB := b.ToServerConfig()
+	return errA == nil && errB == nil && reflect.DeepEqual(configA, configB)
+}
+
 // GetAllActiveConfigs returns all active MCP server configurations as a map
 func (s *MCPServerConfigService) GetAllActiveConfigs() (map[string]models.MCPServerConfigModel, error) {
 	configs, err := s.ListConfigs()
@@ -259,3 +259,102 @@ func TestMCPServerConfigService_GetAllActiveConfigs(t *testing.T) {
 	assert.Contains(t, configMap, "active-server")
 	assert.NotContains(t, configMap, "disabled-server")
 }
+
+func TestMCPServerConfigService_CloneConfig(t *testing.T) {
+	setupMCPTestDB(t)
+	defer teardownMCPTestDB(t)
+
+	service := NewMCPServerConfigService()
+
+	original := &models.MCPServerConfigModel{
+		Name:          "fetch",
+		TransportType: "stdio",
+		Command:       "uvx",
+		NamePrefix:    "web",
+		IsActive:      true,
+	}
+	require.NoError(t, original.SetArgs([]string{"mcp-server-fetch", "--ignore-robots-txt"}))
+	require.NoError(t, original.SetEnv(map[string]string{"PROXY": "http://127.0.0.1:8080"}))
+	require.NoError(t, service.CreateConfig(original))
+
+	clone, err := service.CloneConfig(original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "fetch-copy", clone.Name)
+	assert.Empty(t, clone.NamePrefix)
+
+	args, err := clone.GetArgsSlice()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mcp-server-fetch", "--ignore-robots-txt"}, args)
+	env, err := clone.GetEnvMap()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"PROXY": "http://127.0.0.1:8080"}, env)
+
+	// 已删除配置的名称同样被跳过，因为名称列有唯一索引
+	second, err := service.CloneConfig(original.ID)
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteConfig(second.ID))
+	third, err := service.CloneConfig(original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "fetch-copy-2", second.Name)
+	assert.Equal(t, "fetch-copy-3", third.Name)
+
+	retrieved, err := service.GetConfig(original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "fetch", retrieved.Name)
+	assert.Equal(t, "web", retrieved.NamePrefix)
+	assert.Equal(t, original.Args, retrieved.Args)
+
+	// 损坏的JSON列不会被复制
+	require.NoError(t, database.GetDB().Model(retrieved).Update("headers", "not json").Error)
+	_, err = service.CloneConfig(original.ID)
+	assert.Error(t, err)
+}
+
+func TestMCPServerConfigService_QueryConfigs(t *testing.T) {
+	setupMCPTestDB(t)
+	defer teardownMCPTestDB(t)
+
+	service := NewMCPServerConfigService()
+	for _, server := range []struct{ name, description string }{
+		{"github", "代码仓库"},
+		{"filesystem", "本地文件"},
+		{"fetch", "抓取网页文件"},
+	} {
+		config := &models.MCPServerConfigModel{Name: server.name, Description: server.description, Command: "npx", IsActive: true}
+		require.NoError(t, service.CreateConfig(config))
+	}
+	// 按更新时间排序时最近更新的在前
+	database.GetDB().Model(&models.MCPServerConfigModel{}).Where("name = ?", "github").Update("description", "代码仓库和文件")
+
+	list, pagination, err := service.QueryConfigs(ListQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fetch", "filesystem", "github"}, serverNames(list))
+	assert.Equal(t, int64(3), pagination.Total)
+
+	list, pagination, err = service.QueryConfigs(ListQuery{Q: "文件", Sort: "-updated_at"})
+	require.NoError(t, err)
+	assert.Equal(t, "github", list[0].Name)
+	assert.ElementsMatch(t, []string{"fetch", "filesystem", "github"}, serverNames(list))
+
+	list, pagination, err = service.QueryConfigs(ListQuery{Q: "f", Sort: "name", PageSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fetch"}, serverNames(list))
+	assert.Equal(t, Pagination{Total: 2, Page: 1, PageSize: 1}, pagination)
+
+	// 已删除的服务器不计入总数
+	filesystem, err := service.GetConfigByName("filesystem")
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteConfig(filesystem.ID))
+	_, pagination, err = service.QueryConfigs(ListQuery{Q: "f"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pagination.Total)
+}
+
+// serverNames returns the names of the servers in order
+func serverNames(configs []models.MCPServerConfigModel) []string {
+	names := make([]string, 0, len(configs))
+	for _, c := range configs {
+		names = append(names, c.Name)
+	}
+	return names
+}
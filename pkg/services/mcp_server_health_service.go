@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"gorm.io/gorm"
+)
+
+// MCPServerHealthService provides business logic for MCP server health history
+type MCPServerHealthService struct {
+	db *gorm.DB
+}
+
+// NewMCPServerHealthService creates a new MCP server health service instance
+func NewMCPServerHealthService() *MCPServerHealthService {
+	return &MCPServerHealthService{
+		db: database.GetDB(),
+	}
+}
+
+// RecordCheck stores the result of a health check
+func (s *MCPServerHealthService) RecordCheck(serverID uint, latency time.Duration, checkErr error) (*models.MCPServerHealthModel, error) {
+	record := &models.MCPServerHealthModel{
+		ServerID:  serverID,
+		OK:        checkErr == nil,
+		LatencyMs: latency.Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	if checkErr != nil {
+		record.Error = checkErr.Error()
+	}
+
+	if err := database.RetryOnBusy(func() error {
+		return s.db.Create(record).Error
+	}); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetHistory returns the health checks of a server since the given time, oldest first
+func (s *MCPServerHealthService) GetHistory(serverID uint, since time.Time) ([]models.MCPServerHealthModel, error) {
+	var records []models.MCPServerHealthModel
+	err := s.db.Where("server_id = ? AND checked_at >= ?", serverID, since).
+		Order("checked_at ASC, id ASC").Find(&records).Error
+	return records, err
+}
+
+// ConsecutiveFailures returns the number of failed checks since the last successful one
+func (s *MCPServerHealthService) ConsecutiveFailures(serverID uint) (int, error) {
+	query := s.db.Model(&models.MCPServerHealthModel{}).Where("server_id = ? AND ok = ?", serverID, false)
+
+	var lastOK models.MCPServerHealthModel
+	err := s.db.Where("server_id = ? AND ok = ?", serverID, true).Order("id DESC").First(&lastOK).Error
+	if err == nil {
+		query = query.Where("id > ?", lastOK.ID)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// ConsecutiveFailuresMap returns the consecutive failures of the given servers keyed by server ID
+func (s *MCPServerHealthService) ConsecutiveFailuresMap(serverIDs []uint) (map[uint]int, error) {
+	result := make(map[uint]int, len(serverIDs))
+	for _, id := range serverIDs {
+		failures, err := s.ConsecutiveFailures(id)
+		if err != nil {
+			return nil, err
+		}
+		result[id] = failures
+	}
+	return result, nil
+}
+
+// Prune deletes health checks older than the given time and returns the number of deleted rows
+func (s *MCPServerHealthService) Prune(before time.Time) (int64, error) {
+	result := s.db.Where("checked_at < ?", before).Delete(&models.MCPServerHealthModel{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMCPServerHealthService_ConsecutiveFailures(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewMCPServerHealthService()
+
+	failures, err := service.ConsecutiveFailures(1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, failures)
+
+	// 从未成功过的服务器
+	for i := 0; i < 2; i++ {
+		_, err := service.RecordCheck(1, time.Millisecond, errors.New("502"))
+		require.NoError(t, err)
+	}
+	failures, err = service.ConsecutiveFailures(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, failures)
+
+	// 成功后重新计数
+	record, err := service.RecordCheck(1, 20*time.Millisecond, nil)
+	require.NoError(t, err)
+	assert.True(t, record.OK)
+	assert.Equal(t, int64(20), record.LatencyMs)
+
+	_, err = service.RecordCheck(1, time.Millisecond, errors.New("timeout"))
+	require.NoError(t, err)
+	_, err = service.RecordCheck(2, time.Millisecond, errors.New("other server"))
+	require.NoError(t, err)
+
+	counts, err := service.ConsecutiveFailuresMap([]uint{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint]int{1: 1, 2: 1, 3: 0}, counts)
+
+	history, err := service.GetHistory(1, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	assert.Equal(t, "502", history[0].Error)
+	assert.Equal(t, "timeout", history[3].Error)
+}
+
+func TestMCPServerHealthService_Prune(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewMCPServerHealthService()
+	_, err := service.RecordCheck(1, time.Millisecond, nil)
+	require.NoError(t, err)
+
+	deleted, err := service.Prune(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	deleted, err = service.Prune(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	history, err := service.GetHistory(1, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
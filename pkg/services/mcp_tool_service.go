@@ -4,13 +4,17 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	"github.com/LubyRuffy/einomcphost"
 	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
 	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/resultfilter"
 	"gorm.io/gorm"
 )
 
@@ -150,28 +154,58 @@ func (s *MCPToolService) DeleteToolsByServerID(serverID uint) error {
 	return s.db.Model(&models.MCPToolModel{}).Where("server_id = ? AND is_active = ?", serverID, true).Update("is_active", false).Error
 }
 
-// SyncToolsForServer synchronizes tools for a specific server by connecting to it
-func (s *MCPToolService) SyncToolsForServer(ctx context.Context, serverConfig *models.MCPServerConfigModel) error {
-	// 将数据库配置转换为mcphost.ServerConfig格式
-	mcpServerConfig, err := serverConfig.ToServerConfig()
+// SyncToolsForServer synchronizes tools for a specific server by connecting to it.
+// Only the differences to the stored tools are written, see SyncReport, and
+// the progress of the writes is sent to the handler of WithSyncProgress.
+// The error is stored as the last_sync_error of the server, a successful sync
+// clears it, so that the server list shows servers whose tools cannot be synced.
+//
+// Parameters:
+//   - ctx: Context of the connection, may carry a progress handler
+//   - serverConfig: Server to synchronize
+//
+// Returns:
+//   - *SyncReport: Added, updated, removed and unchanged tools
+//   - error: Error connecting to the server or storing its tools
+func (s *MCPToolService) SyncToolsForServer(ctx context.Context, serverConfig *models.MCPServerConfigModel) (*SyncReport, error) {
+	report, err := s.syncToolsForServer(ctx, serverConfig)
+	if serverConfig.ID != 0 {
+		lastSyncError := ""
+		if err != nil {
+			lastSyncError = err.Error()
+		}
+		recordErr := database.RetryOnBusy(func() error {
+			return s.db.Model(&models.MCPServerConfigModel{}).Where("id = ?", serverConfig.ID).UpdateColumn("last_sync_error", lastSyncError).Error
+		})
+		if recordErr != nil {
+			log.Printf("保存服务器 %s 的同步结果失败: %v", serverConfig.Name, recordErr)
+		}
+	}
+	return report, err
+}
+
+// syncToolsForServer connects to a server and applies its tools to the stored ones
+func (s *MCPToolService) syncToolsForServer(ctx context.Context, serverConfig *models.MCPServerConfigModel) (*SyncReport, error) {
+	// 将数据库配置转换为mcphost.ServerConfig格式，连接前解析引用的凭据
+	mcpServerConfig, err := serverConfig.ToResolvedServerConfig((&CredentialService{db: s.db}).Lookup)
 	if err != nil {
-		return fmt.Errorf("转换服务器配置失败: %w", err)
+		return nil, fmt.Errorf("转换服务器配置失败: %w", err)
 	}
 
 	// 创建MCPSettings
-	settings := &einomcphost.MCPSettings{
-		MCPServers: map[string]*einomcphost.ServerConfig{
+	settings := &mcphost.MCPSettings{
+		MCPServers: map[string]*mcphost.ServerConfig{
 			serverConfig.Name: &mcpServerConfig,
 		},
 	}
 
 	// 使用连接池获取MCP服务器连接
-	pool := einomcphost.GetConnectionPool()
+	pool := mcppool.Default()
 
 	// 获取或创建连接
 	hub, err := pool.GetHub(ctx, settings)
 	if err != nil {
-		return fmt.Errorf("连接MCP服务器失败: %w", err)
+		return nil, fmt.Errorf("连接MCP服务器失败: %w", err)
 	}
 	// 注意：不再直接调用hub.CloseServers()，而是在使用完后释放引用
 	defer pool.ReleaseHub(settings)
@@ -179,88 +213,171 @@ func (s *MCPToolService) SyncToolsForServer(ctx context.Context, serverConfig *m
 	// 获取工具列表
 	toolsMap, err := hub.GetToolsMap(ctx)
 	if err != nil {
-		return fmt.Errorf("获取工具列表失败: %w", err)
+		return nil, fmt.Errorf("获取工具列表失败: %w", err)
 	}
 
-	// 开始事务
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	report, err := s.applyServerTools(ctx, serverConfig, toolsMap)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("成功同步服务器 %s 的 %d 个工具: %s", serverConfig.Name, len(toolsMap), report)
+	return report, nil
+}
+
+// SetResultFilter sets the jq expression applied to the JSON results of a tool.
+// An empty filter removes it. Tasks started afterwards use the new filter.
+//
+// Parameters:
+//   - toolKey: Unique key of the tool, as returned by models.GenerateToolKey
+//   - filter: jq expression, validated before it is stored
+//
+// Returns:
+//   - error: ErrMCPToolResultFilterInvalid if filter does not compile, ErrMCPToolNotFound if the tool does not exist
+func (s *MCPToolService) SetResultFilter(toolKey string, filter string) error {
+	filter = strings.TrimSpace(filter)
+	if filter != "" {
+		if _, err := resultfilter.Compile(filter); err != nil {
+			return fmt.Errorf("%w: %v", models.ErrMCPToolResultFilterInvalid, err)
 		}
-	}()
+	}
 
-	// 先删除该服务器的所有现有工具
-	if err := tx.Model(&models.MCPToolModel{}).Where("server_id = ?", serverConfig.ID).Update("is_active", false).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("删除现有工具失败: %w", err)
+	result := s.db.Model(&models.MCPToolModel{}).Where("tool_key = ? AND is_active = ?", toolKey, true).Update("result_filter", filter)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrMCPToolNotFound
 	}
+	return nil
+}
 
-	// 添加新工具
-	now := time.Now()
-	for toolKey, toolInfo := range toolsMap {
-		// 解析工具键获取工具名称
-		toolName := toolInfo.Name
-		if toolName == "" {
-			// 如果工具名称为空，从工具键中提取
-			if len(toolKey) > len(serverConfig.Name)+1 {
-				toolName = toolKey[len(serverConfig.Name)+1:]
-			} else {
-				toolName = toolKey
-			}
-		}
+// ResultFilters returns the result filters of the active tools by tool key
+func (s *MCPToolService) ResultFilters() (map[string]string, error) {
+	var tools []models.MCPToolModel
+	if err := s.db.Select("tool_key", "result_filter").Where("is_active = ? AND result_filter <> ?", true, "").Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	filters := make(map[string]string, len(tools))
+	for _, tool := range tools {
+		filters[tool.ToolKey] = tool.ResultFilter
+	}
+	return filters, nil
+}
 
-		tool := &models.MCPToolModel{
-			Name:        toolName,
-			Description: toolInfo.Desc,
-			ServerID:    serverConfig.ID,
-			ToolKey:     toolKey,
-			IsActive:    true,
-			LastSyncAt:  &now,
-		}
+// SetOutputFormat declares the format of the results of a tool, which the web
+// UI renders them by instead of guessing it from the content. An empty format
+// removes the declaration. Tasks started afterwards use the new format.
+//
+// Parameters:
+//   - toolKey: Unique key of the tool, as returned by models.GenerateToolKey
+//   - format: models.ContentFormatPlain, ContentFormatMarkdown or ContentFormatJSON
+//
+// Returns:
+//   - error: ErrMCPToolOutputFormatInvalid if format is unknown, ErrMCPToolNotFound if the tool does not exist
+func (s *MCPToolService) SetOutputFormat(toolKey string, format string) error {
+	format = strings.TrimSpace(format)
+	if format != "" && !models.ValidContentFormat(format) {
+		return models.ErrMCPToolOutputFormatInvalid
+	}
 
-		// 设置输入模式（如果有的话）
-		if toolInfo.ParamsOneOf != nil {
-			// 尝试将参数模式转换为OpenAPI v3格式
-			if openAPISchema, err := toolInfo.ParamsOneOf.ToOpenAPIV3(); err == nil && openAPISchema != nil {
-				// 将OpenAPI模式转换为简单的map格式存储
-				schemaMap := make(map[string]interface{})
-				schemaMap["type"] = openAPISchema.Type
-				if openAPISchema.Properties != nil {
-					schemaMap["properties"] = openAPISchema.Properties
-				}
-				if openAPISchema.Required != nil {
-					schemaMap["required"] = openAPISchema.Required
-				}
-				if err := tool.SetInputSchema(schemaMap); err != nil {
-					log.Printf("设置工具 %s 的输入模式失败: %v", toolKey, err)
-				}
-			} else {
-				// 如果转换失败，设置一个基本的对象模式
-				schemaMap := map[string]interface{}{
-					"type": "object",
-				}
-				if err := tool.SetInputSchema(schemaMap); err != nil {
-					log.Printf("设置工具 %s 的基本输入模式失败: %v", toolKey, err)
-				}
-			}
-		}
+	result := s.db.Model(&models.MCPToolModel{}).Where("tool_key = ? AND is_active = ?", toolKey, true).Update("output_format", format)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrMCPToolNotFound
+	}
+	return nil
+}
 
-		if err := tx.Create(tool).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("创建工具 %s 失败: %w", toolKey, err)
-		}
+// OutputFormats returns the declared output formats of the active tools by tool key
+func (s *MCPToolService) OutputFormats() (map[string]string, error) {
+	var tools []models.MCPToolModel
+	if err := s.db.Select("tool_key", "output_format").Where("is_active = ? AND output_format <> ?", true, "").Find(&tools).Error; err != nil {
+		return nil, err
 	}
+	formats := make(map[string]string, len(tools))
+	for _, tool := range tools {
+		formats[tool.ToolKey] = tool.OutputFormat
+	}
+	return formats, nil
+}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("提交事务失败: %w", err)
+// SetLocalizedDescriptions replaces the localized descriptions of a tool.
+// Empty descriptions are removed. Tasks started afterwards present the new
+// descriptions to the model.
+//
+// Parameters:
+//   - toolKey: Unique key of the tool, as returned by models.GenerateToolKey
+//   - descriptions: Descriptions keyed by language, such as "zh"
+//
+// Returns:
+//   - error: ErrMCPToolDescriptionLanguageEmpty if a language is empty, ErrMCPToolNotFound if the tool does not exist
+func (s *MCPToolService) SetLocalizedDescriptions(toolKey string, descriptions map[string]string) error {
+	var tool models.MCPToolModel
+	if err := tool.SetLocalizedDescriptions(descriptions); err != nil {
+		return err
 	}
 
-	log.Printf("成功同步服务器 %s 的 %d 个工具", serverConfig.Name, len(toolsMap))
+	result := s.db.Model(&models.MCPToolModel{}).Where("tool_key = ? AND is_active = ?", toolKey, true).Update("localized_descriptions", tool.LocalizedDescriptions)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrMCPToolNotFound
+	}
 	return nil
 }
 
+// SetLocalizedDescription sets the description of a tool in one language,
+// the descriptions in other languages are kept
+//
+// Parameters:
+//   - toolKey: Unique key of the tool, as returned by models.GenerateToolKey
+//   - language: Language of the description, such as "zh"
+//   - description: Localized description, empty removes it
+//
+// Returns:
+//   - error: ErrMCPToolDescriptionLanguageEmpty if language is empty, ErrMCPToolNotFound if the tool does not exist
+func (s *MCPToolService) SetLocalizedDescription(toolKey string, language string, description string) error {
+	var tool models.MCPToolModel
+	if err := s.db.Where("tool_key = ? AND is_active = ?", toolKey, true).First(&tool).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ErrMCPToolNotFound
+		}
+		return err
+	}
+	descriptions, err := tool.GetLocalizedDescriptions()
+	if err != nil {
+		// 无法解析的内容直接覆盖
+		log.Printf("解析工具 %s 的本地化描述失败: %v", toolKey, err)
+	}
+	if descriptions == nil {
+		descriptions = make(map[string]string)
+	}
+	descriptions[strings.TrimSpace(language)] = description
+	return s.SetLocalizedDescriptions(toolKey, descriptions)
+}
+
+// LocalizedDescriptions returns the localized descriptions of the active tools by tool key
+func (s *MCPToolService) LocalizedDescriptions() (map[string]map[string]string, error) {
+	var tools []models.MCPToolModel
+	if err := s.db.Select("tool_key", "localized_descriptions").Where("is_active = ? AND localized_descriptions <> ?", true, "").Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]string, len(tools))
+	for _, tool := range tools {
+		descriptions, err := tool.GetLocalizedDescriptions()
+		if err != nil {
+			log.Printf("解析工具 %s 的本地化描述失败: %v", tool.ToolKey, err)
+			continue
+		}
+		result[tool.ToolKey] = descriptions
+	}
+	return result, nil
+}
+
 // GetToolsInfo returns tool information for API responses
 func (s *MCPToolService) GetToolsInfo() ([]models.MCPToolInfo, error) {
 	tools, err := s.GetAllActiveTools()
@@ -268,10 +385,58 @@ func (s *MCPToolService) GetToolsInfo() ([]models.MCPToolInfo, error) {
 		return nil, err
 	}
 
+	// 合并工具调用统计，便于前端按使用频率排序
+	usageService := &ToolUsageService{db: s.db}
+	usageMap, err := usageService.GetUsageMap()
+	if err != nil {
+		return nil, err
+	}
+
 	var toolsInfo []models.MCPToolInfo
 	for _, tool := range tools {
-		toolsInfo = append(toolsInfo, tool.ToMCPToolInfo())
+		info := tool.ToMCPToolInfo()
+		if usage, ok := usageMap[tool.ToolKey]; ok {
+			info.UsageCount = usage.Calls
+			info.LastUsedAt = usage.LastUsedAt
+		}
+		toolsInfo = append(toolsInfo, info)
 	}
 
 	return toolsInfo, nil
 }
+
+// QueryToolsInfo returns a page of the cached tools of active servers with
+// their usage statistics, filtered and ordered by q. Without a sort the
+// tools are ordered by name.
+func (s *MCPToolService) QueryToolsInfo(q ListQuery) ([]models.MCPToolInfo, Pagination, error) {
+	var tools []models.MCPToolModel
+	pagination, err := paginate(s.db.Model(&models.MCPToolModel{}).Where("is_active = ?", true), q, &tools)
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+
+	servers := make(map[uint]models.MCPServerConfigModel)
+	usageService := &ToolUsageService{db: s.db}
+	usageMap, err := usageService.GetUsageMap()
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+
+	toolsInfo := make([]models.MCPToolInfo, 0, len(tools))
+	for _, tool := range tools {
+		server, ok := servers[tool.ServerID]
+		if !ok {
+			if err := s.db.Where("id = ?", tool.ServerID).First(&server).Error; err == nil {
+				servers[tool.ServerID] = server
+			}
+		}
+		tool.Server = server
+		info := tool.ToMCPToolInfo()
+		if usage, ok := usageMap[tool.ToolKey]; ok {
+			info.UsageCount = usage.Calls
+			info.LastUsedAt = usage.LastUsedAt
+		}
+		toolsInfo = append(toolsInfo, info)
+	}
+	return toolsInfo, pagination, nil
+}
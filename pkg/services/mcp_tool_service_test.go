@@ -236,6 +236,87 @@ func TestMCPToolService_GetToolByKey(t *testing.T) {
 	assert.Equal(t, models.ErrMCPToolNotFound, err)
 }
 
+func TestMCPToolService_SetResultFilter(t *testing.T) {
+	setupMCPToolTestDB(t)
+	defer teardownMCPToolTestDB(t)
+
+	service := NewMCPToolService()
+	server := createTestMCPServer(t)
+
+	tool := &models.MCPToolModel{
+		Name:     "search",
+		ServerID: server.ID,
+		ToolKey:  models.GenerateToolKey(server.Name, "search"),
+		IsActive: true,
+	}
+	require.NoError(t, service.CreateTool(tool))
+
+	require.NoError(t, service.SetResultFilter(tool.ToolKey, " .results[] | {ip, port} "))
+	foundTool, err := service.GetToolByKey(tool.ToolKey)
+	require.NoError(t, err)
+	assert.Equal(t, ".results[] | {ip, port}", foundTool.ResultFilter)
+	assert.Equal(t, ".results[] | {ip, port}", foundTool.ToMCPToolInfo().ResultFilter)
+
+	filters, err := service.ResultFilters()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{tool.ToolKey: ".results[] | {ip, port}"}, filters)
+
+	// 无效的表达式不会保存
+	err = service.SetResultFilter(tool.ToolKey, ".results[] | {")
+	assert.ErrorIs(t, err, models.ErrMCPToolResultFilterInvalid)
+	foundTool, err = service.GetToolByKey(tool.ToolKey)
+	require.NoError(t, err)
+	assert.Equal(t, ".results[] | {ip, port}", foundTool.ResultFilter)
+
+	// 空表达式移除过滤
+	require.NoError(t, service.SetResultFilter(tool.ToolKey, ""))
+	filters, err = service.ResultFilters()
+	require.NoError(t, err)
+	assert.Empty(t, filters)
+
+	assert.ErrorIs(t, service.SetResultFilter("nonexistent_key", "."), models.ErrMCPToolNotFound)
+}
+
+func TestMCPToolService_SetOutputFormat(t *testing.T) {
+	setupMCPToolTestDB(t)
+	defer teardownMCPToolTestDB(t)
+
+	service := NewMCPToolService()
+	server := createTestMCPServer(t)
+
+	tool := &models.MCPToolModel{
+		Name:     "search",
+		ServerID: server.ID,
+		ToolKey:  models.GenerateToolKey(server.Name, "search"),
+		IsActive: true,
+	}
+	require.NoError(t, service.CreateTool(tool))
+
+	require.NoError(t, service.SetOutputFormat(tool.ToolKey, " markdown "))
+	foundTool, err := service.GetToolByKey(tool.ToolKey)
+	require.NoError(t, err)
+	assert.Equal(t, models.ContentFormatMarkdown, foundTool.OutputFormat)
+	assert.Equal(t, models.ContentFormatMarkdown, foundTool.ToMCPToolInfo().OutputFormat)
+
+	formats, err := service.OutputFormats()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{tool.ToolKey: models.ContentFormatMarkdown}, formats)
+
+	// 未知的格式不会保存
+	assert.ErrorIs(t, service.SetOutputFormat(tool.ToolKey, "html"), models.ErrMCPToolOutputFormatInvalid)
+	foundTool, err = service.GetToolByKey(tool.ToolKey)
+	require.NoError(t, err)
+	assert.Equal(t, models.ContentFormatMarkdown, foundTool.OutputFormat)
+
+	// 空格式移除声明
+	require.NoError(t, service.SetOutputFormat(tool.ToolKey, ""))
+	formats, err = service.OutputFormats()
+	require.NoError(t, err)
+	assert.Empty(t, formats)
+
+	assert.ErrorIs(t, service.SetOutputFormat("nonexistent_key", "json"), models.ErrMCPToolNotFound)
+}
+
 func TestMCPToolService_UpdateTool(t *testing.T) {
 	setupMCPToolTestDB(t)
 	defer teardownMCPToolTestDB(t)
@@ -380,3 +461,39 @@ func TestGenerateToolKey(t *testing.T) {
 	result := models.GenerateToolKey(serverName, toolName)
 	assert.Equal(t, expected, result)
 }
+
+func TestMCPToolService_QueryToolsInfo(t *testing.T) {
+	setupMCPToolTestDB(t)
+	defer teardownMCPToolTestDB(t)
+	require.NoError(t, database.GetDB().AutoMigrate(&models.ToolUsageModel{}))
+
+	service := NewMCPToolService()
+	server := createTestMCPServer(t)
+	for _, tool := range []struct{ name, description string }{
+		{"search", "Search the web"},
+		{"read_file", "Read a file"},
+		{"write_file", "Write a file"},
+	} {
+		require.NoError(t, service.CreateTool(&models.MCPToolModel{
+			Name: tool.name, Description: tool.description, ServerID: server.ID,
+			ToolKey: models.GenerateToolKey(server.Name, tool.name), IsActive: true,
+		}))
+	}
+	usageService := &ToolUsageService{db: database.GetDB()}
+	require.NoError(t, usageService.RecordUsage(models.GenerateToolKey(server.Name, "write_file"), 0, false))
+
+	tools, pagination, err := service.QueryToolsInfo(ListQuery{Q: "FILE", Sort: "-name"})
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+	assert.Equal(t, "write_file", tools[0].Name)
+	assert.Equal(t, "test-server", tools[0].Server)
+	assert.Equal(t, int64(1), tools[0].UsageCount)
+	assert.Equal(t, "read_file", tools[1].Name)
+	assert.Equal(t, int64(2), pagination.Total)
+
+	// 下划线按字面匹配，不作为通配符
+	tools, _, err = service.QueryToolsInfo(ListQuery{Q: "d_f"})
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "read_file", tools[0].Name)
+}
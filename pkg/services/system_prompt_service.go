@@ -29,6 +29,14 @@ func (s *SystemPromptService) ListPrompts() ([]models.SystemPromptModel, error)
 	return prompts, err
 }
 
+// QueryPrompts returns a page of the active system prompts, filtered and
+// ordered by q. Without a sort the prompts are ordered by name.
+func (s *SystemPromptService) QueryPrompts(q ListQuery) ([]models.SystemPromptModel, Pagination, error) {
+	prompts := []models.SystemPromptModel{}
+	pagination, err := paginate(s.db.Model(&models.SystemPromptModel{}).Where("is_active = ?", true), q, &prompts)
+	return prompts, pagination, err
+}
+
 // GetPrompt returns a specific system prompt configuration by ID
 func (s *SystemPromptService) GetPrompt(id uint) (*models.SystemPromptModel, error) {
 	var prompt models.SystemPromptModel
@@ -42,6 +50,19 @@ func (s *SystemPromptService) GetPrompt(id uint) (*models.SystemPromptModel, err
 	return &prompt, nil
 }
 
+// GetPromptByName returns a specific system prompt by name
+func (s *SystemPromptService) GetPromptByName(name string) (*models.SystemPromptModel, error) {
+	var prompt models.SystemPromptModel
+	err := s.db.Where("name = ? AND is_active = ?", name, true).First(&prompt).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, models.ErrSystemPromptNotFound
+		}
+		return nil, err
+	}
+	return &prompt, nil
+}
+
 // GetDefaultPrompt returns the default system prompt configuration
 func (s *SystemPromptService) GetDefaultPrompt() (*models.SystemPromptModel, error) {
 	var prompt models.SystemPromptModel
@@ -124,7 +145,8 @@ func (s *SystemPromptService) UpdatePrompt(id uint, updates *models.SystemPrompt
 	return s.db.Model(&existingPrompt).Updates(updates).Error
 }
 
-// DeletePrompt soft deletes a system prompt configuration
+// DeletePrompt soft deletes a system prompt configuration. LLM configurations
+// using it as their default system prompt no longer have one.
 func (s *SystemPromptService) DeletePrompt(id uint) error {
 	// 检查配置是否存在
 	var prompt models.SystemPromptModel
@@ -141,8 +163,14 @@ func (s *SystemPromptService) DeletePrompt(id uint) error {
 		return fmt.Errorf("不能删除默认配置")
 	}
 
-	// 软删除配置
-	return s.db.Model(&prompt).Update("is_active", false).Error
+	// 软删除配置，并清空以它为默认提示词的LLM配置，这些配置的任务改用全局默认提示词
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&prompt).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.LLMConfigModel{}).Where("default_system_prompt_id = ?", id).
+			Update("default_system_prompt_id", nil).Error
+	})
 }
 
 // SetDefaultPrompt sets a configuration as the default one
@@ -180,6 +208,39 @@ func (s *SystemPromptService) SetDefaultPrompt(id uint) error {
 	return tx.Commit().Error
 }
 
+// ClonePrompt copies a system prompt under a new unique name.
+// The copy is never the default prompt; the original is not modified.
+func (s *SystemPromptService) ClonePrompt(id uint) (*models.SystemPromptModel, error) {
+	original, err := s.GetPrompt(id)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := uniqueCloneName(s.db, &models.SystemPromptModel{}, original.Name, displayCloneName)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholders, err := original.GetPlaceholdersAsStringSlice()
+	if err != nil {
+		return nil, err
+	}
+	clone := &models.SystemPromptModel{
+		Name:        name,
+		Description: original.Description,
+		Content:     original.Content,
+		IsDefault:   false,
+		IsActive:    true,
+	}
+	if err := clone.SetPlaceholdersFromStringSlice(placeholders); err != nil {
+		return nil, err
+	}
+	if err := s.CreatePrompt(clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
 // clearDefaultPrompts removes default flag from all configurations
 func (s *SystemPromptService) clearDefaultPrompts() error {
 	return s.db.Model(&models.SystemPromptModel{}).Where("is_active = ?", true).Update("is_default", false).Error
@@ -14,8 +14,8 @@ func setupSystemPromptTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
 	assert.NoError(t, err)
 
-	// 自动迁移表结构
-	err = db.AutoMigrate(&models.SystemPromptModel{})
+	// 自动迁移表结构，删除提示词时会更新引用它的LLM配置
+	err = db.AutoMigrate(&models.SystemPromptModel{}, &models.LLMConfigModel{})
 	assert.NoError(t, err)
 
 	return db
@@ -236,3 +236,67 @@ func TestSystemPromptService_SetDefaultPrompt(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, updatedPrompt2.IsDefault, "prompt2应该成为默认配置")
 }
+
+func TestSystemPromptService_ClonePrompt(t *testing.T) {
+	db := setupSystemPromptTestDB(t)
+	service := &SystemPromptService{
+		db: db,
+	}
+
+	original := &models.SystemPromptModel{
+		Name:      "克隆测试提示词",
+		Content:   "你是{field}专家。",
+		IsDefault: true,
+		IsActive:  true,
+	}
+	assert.NoError(t, original.SetPlaceholdersFromStringSlice([]string{"field"}))
+	assert.NoError(t, db.Create(original).Error)
+
+	clone, err := service.ClonePrompt(original.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "克隆测试提示词 (copy)", clone.Name)
+	assert.Equal(t, original.Content, clone.Content)
+	assert.False(t, clone.IsDefault)
+	placeholders, err := clone.GetPlaceholdersAsStringSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"field"}, placeholders)
+
+	second, err := service.ClonePrompt(original.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "克隆测试提示词 (copy 2)", second.Name)
+
+	var retrieved models.SystemPromptModel
+	assert.NoError(t, db.First(&retrieved, original.ID).Error)
+	assert.Equal(t, "克隆测试提示词", retrieved.Name)
+	assert.True(t, retrieved.IsDefault)
+}
+
+func TestSystemPromptService_QueryPrompts(t *testing.T) {
+	// 使用独立的数据库，不受其他测试创建的提示词影响
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&models.SystemPromptModel{}))
+	service := &SystemPromptService{db: db}
+
+	for _, name := range []string{"翻译助手", "代码审查", "翻译校对"} {
+		assert.NoError(t, service.CreatePrompt(&models.SystemPromptModel{Name: name, Content: "你是" + name, IsActive: true}))
+	}
+
+	prompts, pagination, err := service.QueryPrompts(ListQuery{Q: "翻译"})
+	assert.NoError(t, err)
+	assert.Len(t, prompts, 2)
+	assert.Equal(t, int64(2), pagination.Total)
+
+	prompts, pagination, err = service.QueryPrompts(ListQuery{Sort: "-created_at", Page: 1, PageSize: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, prompts, 1) {
+		assert.Equal(t, "翻译校对", prompts[0].Name)
+	}
+	assert.Equal(t, Pagination{Total: 3, Page: 1, PageSize: 1}, pagination)
+
+	prompts, _, err = service.QueryPrompts(ListQuery{Sort: "created_at", Page: 2, PageSize: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, prompts, 1) {
+		assert.Equal(t, "代码审查", prompts[0].Name)
+	}
+}
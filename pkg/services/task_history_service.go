@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"gorm.io/gorm"
+)
+
+// maxTaskChainLength limits how many ancestors GetChain follows, guarding against cycles
+const maxTaskChainLength = 100
+
+// TaskHistoryService provides business logic for the history of web tasks
+type TaskHistoryService struct {
+	db *gorm.DB
+}
+
+// NewTaskHistoryService creates a new task history service instance
+func NewTaskHistoryService() *TaskHistoryService {
+	return &TaskHistoryService{
+		db: database.GetDB(),
+	}
+}
+
+// CreateTask stores a task that has just started
+func (s *TaskHistoryService) CreateTask(record *models.TaskHistoryModel) error {
+	if record.Status == "" {
+		record.Status = models.TaskStatusRunning
+	}
+	if record.StartedAt.IsZero() {
+		record.StartedAt = time.Now()
+	}
+	return database.RetryOnBusy(func() error {
+		return s.db.Create(record).Error
+	})
+}
+
+// FinishTask stores the outcome of a task, model is the model that produced the result
+func (s *TaskHistoryService) FinishTask(taskID, status, result, model string, taskErr error, errorCode string) error {
+	updates := map[string]interface{}{
+		"status":      status,
+		"result":      result,
+		"model":       model,
+		"error_code":  errorCode,
+		"finished_at": time.Now(),
+	}
+	if taskErr != nil {
+		updates["error"] = taskErr.Error()
+	}
+	return database.RetryOnBusy(func() error {
+		return s.db.Model(&models.TaskHistoryModel{}).Where("task_id = ?", taskID).Updates(updates).Error
+	})
+}
+
+// SaveCheckpoint stores the checkpoint of a running task, replacing the previous one
+func (s *TaskHistoryService) SaveCheckpoint(taskID string, step int, checkpoint string) error {
+	// 使用结构体更新，检查点才会经过加密序列化
+	now := time.Now()
+	return database.RetryOnBusy(func() error {
+		return s.db.Model(&models.TaskHistoryModel{}).
+			Where("task_id = ? AND status = ?", taskID, models.TaskStatusRunning).
+			Select("checkpoint", "checkpoint_step", "checkpoint_at").
+			Updates(&models.TaskHistoryModel{Checkpoint: checkpoint, CheckpointStep: step, CheckpointAt: &now}).Error
+	})
+}
+
+// SaveNotes stores the notes of a task as JSON, replacing the stored notes
+func (s *TaskHistoryService) SaveNotes(taskID string, notes string) error {
+	return database.RetryOnBusy(func() error {
+		return s.db.Model(&models.TaskHistoryModel{}).Where("task_id = ?", taskID).UpdateColumn("notes", notes).Error
+	})
+}
+
+// MarkInterrupted marks the tasks still recorded as running as interrupted.
+// It is called when the web server starts, no task of an earlier process is running anymore.
+//
+// Parameters:
+//   - reason: Error stored with the interrupted tasks
+//
+// Returns:
+//   - int64: Number of interrupted tasks
+//   - error: Error if the records cannot be updated
+func (s *TaskHistoryService) MarkInterrupted(reason string) (int64, error) {
+	result := s.db.Model(&models.TaskHistoryModel{}).
+		Where("status = ?", models.TaskStatusRunning).
+		Updates(map[string]interface{}{
+			"status":      models.TaskStatusInterrupted,
+			"error":       reason,
+			"error_code":  models.TaskStatusInterrupted,
+			"finished_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// ListTasks returns the most recent tasks, newest first.
+//
+// Parameters:
+//   - status: Only return tasks with this status, empty for all tasks
+//   - limit: Maximum number of tasks
+//
+// Returns:
+//   - []models.TaskHistoryModel: Tasks, never nil
+//   - error: Error if the query fails
+func (s *TaskHistoryService) ListTasks(status string, limit int) ([]models.TaskHistoryModel, error) {
+	records := []models.TaskHistoryModel{}
+	query := s.db.Order("started_at DESC, id DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&records).Error
+	return records, err
+}
+
+// ListHistory returns a page of the task history, the most recently started
+// task first. A query without a page returns the first DefaultPageSize tasks,
+// the history is not listed at once because it grows with every task.
+//
+// Parameters:
+//   - status: Only return tasks with this status, empty for all tasks
+//   - q: Page and page size, Q filters the task descriptions; sorting is not supported
+//
+// Returns:
+//   - []models.TaskHistoryModel: Tasks of the page, never nil
+//   - Pagination: Total number of matching tasks and the page returned
+//   - error: models.ErrListSortInvalid if q has a sort, or the database error
+func (s *TaskHistoryService) ListHistory(status string, q ListQuery) ([]models.TaskHistoryModel, Pagination, error) {
+	if strings.TrimSpace(q.Sort) != "" {
+		return nil, Pagination{}, fmt.Errorf("%w: %s", models.ErrListSortInvalid, q.Sort)
+	}
+	if q.Page <= 0 && q.PageSize <= 0 {
+		q.Page = 1
+	}
+	q = q.normalize()
+
+	query := s.db.Model(&models.TaskHistoryModel{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if filter := strings.TrimSpace(q.Q); filter != "" {
+		query = query.Where("LOWER(task) LIKE ? ESCAPE '\\'", "%"+escapeLike(strings.ToLower(filter))+"%")
+	}
+
+	pagination := Pagination{Page: q.Page, PageSize: q.PageSize}
+	if err := query.Session(&gorm.Session{}).Count(&pagination.Total).Error; err != nil {
+		return nil, Pagination{}, err
+	}
+	records := []models.TaskHistoryModel{}
+	err := query.Order("started_at DESC, id DESC").Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).Find(&records).Error
+	if err != nil {
+		return nil, Pagination{}, err
+	}
+	return records, pagination, nil
+}
+
+// DeleteHistory deletes the record of a finished task. Tasks continuing it
+// keep their records, their chains start after the deleted task.
+//
+// Parameters:
+//   - id: Database ID of the record
+//
+// Returns:
+//   - error: models.ErrTaskHistoryNotFound if the record does not exist, models.ErrTaskHistoryRunning if the task is running
+func (s *TaskHistoryService) DeleteHistory(id uint) error {
+	var record models.TaskHistoryModel
+	if err := s.db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ErrTaskHistoryNotFound
+		}
+		return err
+	}
+	if record.Status == models.TaskStatusRunning {
+		return models.ErrTaskHistoryRunning
+	}
+	return database.RetryOnBusy(func() error {
+		return s.db.Delete(&models.TaskHistoryModel{}, id).Error
+	})
+}
+
+// GetTask returns the record of a task
+func (s *TaskHistoryService) GetTask(taskID string) (*models.TaskHistoryModel, error) {
+	var record models.TaskHistoryModel
+	if err := s.db.Where("task_id = ?", taskID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrTaskHistoryNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetChain returns a task and the tasks it continues, the first task of the conversation first
+func (s *TaskHistoryService) GetChain(taskID string) ([]models.TaskHistoryModel, error) {
+	var chain []models.TaskHistoryModel
+	for id := taskID; id != "" && len(chain) < maxTaskChainLength; {
+		record, err := s.GetTask(id)
+		if err != nil {
+			if len(chain) > 0 && errors.Is(err, models.ErrTaskHistoryNotFound) {
+				// 被继续的任务已删除，保留剩余部分
+				break
+			}
+			return nil, err
+		}
+		chain = append([]models.TaskHistoryModel{*record}, chain...)
+		id = record.ParentTaskID
+	}
+	return chain, nil
+}
+
+// ListContinuations returns the tasks continuing a task, oldest first
+func (s *TaskHistoryService) ListContinuations(taskID string) ([]models.TaskHistoryModel, error) {
+	var records []models.TaskHistoryModel
+	err := s.db.Where("parent_task_id = ?", taskID).Order("started_at ASC, id ASC").Find(&records).Error
+	return records, err
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskHistoryService_Chain(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewTaskHistoryService()
+
+	first := &models.TaskHistoryModel{TaskID: "task_1", Task: "查询IP"}
+	require.NoError(t, first.SetConfig([]byte(`{"llm":{"api_key":"sk-secret"}}`)))
+	require.NoError(t, service.CreateTask(first))
+	assert.Equal(t, models.TaskStatusRunning, first.Status)
+	assert.NotContains(t, first.Config, "sk-secret", "配置快照加密保存")
+
+	require.NoError(t, service.FinishTask("task_1", models.TaskStatusError, "", "", errors.New("模型不可用"), "model_unavailable"))
+	require.NoError(t, service.CreateTask(&models.TaskHistoryModel{TaskID: "task_2", ParentTaskID: "task_1", Task: "继续"}))
+	require.NoError(t, service.CreateTask(&models.TaskHistoryModel{TaskID: "task_3", ParentTaskID: "task_2", Task: "总结"}))
+
+	chain, err := service.GetChain("task_3")
+	require.NoError(t, err)
+	require.Len(t, chain, 3)
+	assert.Equal(t, []string{"task_1", "task_2", "task_3"}, []string{chain[0].TaskID, chain[1].TaskID, chain[2].TaskID})
+	assert.Equal(t, "模型不可用", chain[0].Error)
+	assert.Equal(t, "model_unavailable", chain[0].ErrorCode)
+	assert.NotNil(t, chain[0].FinishedAt)
+
+	snapshot, err := chain[0].GetConfig()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"llm":{"api_key":"sk-secret"}}`, string(snapshot))
+
+	continuations, err := service.ListContinuations("task_1")
+	require.NoError(t, err)
+	require.Len(t, continuations, 1)
+	assert.Equal(t, "task_2", continuations[0].TaskID)
+
+	_, err = service.GetChain("task_unknown")
+	assert.ErrorIs(t, err, models.ErrTaskHistoryNotFound)
+}
+
+func TestTaskHistoryService_Checkpoints(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewTaskHistoryService()
+	require.NoError(t, service.CreateTask(&models.TaskHistoryModel{TaskID: "task_1", Task: "扫描"}))
+	require.NoError(t, service.CreateTask(&models.TaskHistoryModel{TaskID: "task_2", Task: "查询"}))
+	require.NoError(t, service.FinishTask("task_2", models.TaskStatusCompleted, "完成", "", nil, ""))
+
+	require.NoError(t, service.SaveCheckpoint("task_1", 5, `{"step":5,"messages":[]}`))
+	// 已结束的任务不再保存检查点
+	require.NoError(t, service.SaveCheckpoint("task_2", 5, `{"step":5}`))
+
+	var raw string
+	require.NoError(t, service.db.Model(&models.TaskHistoryModel{}).Where("task_id = ?", "task_1").Pluck("checkpoint", &raw).Error)
+	assert.NotContains(t, raw, "messages", "检查点加密保存")
+
+	count, err := service.MarkInterrupted("服务器重启")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	interrupted, err := service.ListTasks(models.TaskStatusInterrupted, 10)
+	require.NoError(t, err)
+	require.Len(t, interrupted, 1)
+	assert.Equal(t, "task_1", interrupted[0].TaskID)
+	assert.Equal(t, `{"step":5,"messages":[]}`, interrupted[0].Checkpoint)
+	assert.Equal(t, 5, interrupted[0].CheckpointStep)
+	assert.NotNil(t, interrupted[0].CheckpointAt)
+	assert.Equal(t, "服务器重启", interrupted[0].Error)
+	assert.True(t, interrupted[0].IsFinished())
+
+	completed, err := service.GetTask("task_2")
+	require.NoError(t, err)
+	assert.Empty(t, completed.Checkpoint)
+
+	all, err := service.ListTasks("", 10)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestTaskHistoryService_ListAndDeleteHistory(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewTaskHistoryService()
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, service.CreateTask(&models.TaskHistoryModel{TaskID: fmt.Sprintf("task_%d", i), Task: fmt.Sprintf("扫描主机%d", i)}))
+	}
+	require.NoError(t, service.CreateTask(&models.TaskHistoryModel{TaskID: "task_6", Task: "查询证书"}))
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, service.FinishTask(fmt.Sprintf("task_%d", i), models.TaskStatusCompleted, "完成", "", nil, ""))
+	}
+
+	// 最近开始的任务在前，未指定分页时返回第一页
+	records, pagination, err := service.ListHistory("", ListQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, Pagination{Total: 6, Page: 1, PageSize: DefaultPageSize}, pagination)
+	require.Len(t, records, 6)
+	assert.Equal(t, "task_6", records[0].TaskID)
+
+	records, pagination, err = service.ListHistory("", ListQuery{Page: 2, PageSize: 4})
+	require.NoError(t, err)
+	assert.Equal(t, Pagination{Total: 6, Page: 2, PageSize: 4}, pagination)
+	assert.Equal(t, []string{"task_2", "task_1"}, []string{records[0].TaskID, records[1].TaskID})
+
+	// 按状态和任务描述过滤
+	records, pagination, err = service.ListHistory(models.TaskStatusRunning, ListQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pagination.Total)
+	assert.Equal(t, "task_6", records[0].TaskID)
+	_, pagination, err = service.ListHistory("", ListQuery{Q: "主机"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), pagination.Total)
+
+	_, _, err = service.ListHistory("", ListQuery{Sort: "name"})
+	assert.ErrorIs(t, err, models.ErrListSortInvalid)
+
+	// 运行中的任务不能删除
+	running, err := service.GetTask("task_6")
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.DeleteHistory(running.ID), models.ErrTaskHistoryRunning)
+
+	finished, err := service.GetTask("task_1")
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteHistory(finished.ID))
+	_, err = service.GetTask("task_1")
+	assert.ErrorIs(t, err, models.ErrTaskHistoryNotFound)
+	assert.ErrorIs(t, service.DeleteHistory(finished.ID), models.ErrTaskHistoryNotFound)
+}
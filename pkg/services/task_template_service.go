@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"gorm.io/gorm"
+)
+
+// TaskTemplateService provides business logic for task template management
+type TaskTemplateService struct {
+	db *gorm.DB
+}
+
+// NewTaskTemplateService creates a new task template service instance
+func NewTaskTemplateService() *TaskTemplateService {
+	return &TaskTemplateService{
+		db: database.GetDB(),
+	}
+}
+
+// ListTemplates returns all active task templates ordered by name
+func (s *TaskTemplateService) ListTemplates() ([]models.TaskTemplateModel, error) {
+	var templates []models.TaskTemplateModel
+	err := s.db.Where("is_active = ?", true).Order("name ASC").Find(&templates).Error
+	return templates, err
+}
+
+// GetTemplate returns a specific task template by ID
+func (s *TaskTemplateService) GetTemplate(id uint) (*models.TaskTemplateModel, error) {
+	var template models.TaskTemplateModel
+	err := s.db.Where("id = ? AND is_active = ?", id, true).First(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrTaskTemplateNotFound
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetTemplateByName returns a specific task template by name
+func (s *TaskTemplateService) GetTemplateByName(name string) (*models.TaskTemplateModel, error) {
+	var template models.TaskTemplateModel
+	err := s.db.Where("name = ? AND is_active = ?", name, true).First(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrTaskTemplateNotFound
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// CreateTemplate creates a new task template
+func (s *TaskTemplateService) CreateTemplate(template *models.TaskTemplateModel) error {
+	if err := s.validate(template); err != nil {
+		return err
+	}
+
+	// 检查名称是否已存在
+	var count int64
+	err := s.db.Model(&models.TaskTemplateModel{}).Where("name = ? AND is_active = ?", template.Name, true).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return models.ErrTaskTemplateNameExists
+	}
+
+	template.IsActive = true
+	return s.db.Create(template).Error
+}
+
+// UpdateTemplate replaces the content of an existing task template
+func (s *TaskTemplateService) UpdateTemplate(id uint, updates *models.TaskTemplateModel) error {
+	if err := s.validate(updates); err != nil {
+		return err
+	}
+
+	existing, err := s.GetTemplate(id)
+	if err != nil {
+		return err
+	}
+
+	// 检查名称是否与其他模板冲突
+	if updates.Name != existing.Name {
+		var count int64
+		err := s.db.Model(&models.TaskTemplateModel{}).Where("name = ? AND id != ? AND is_active = ?", updates.Name, id, true).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return models.ErrTaskTemplateNameExists
+		}
+	}
+
+	// 使用Select更新全部字段，使清空的描述、工具和LLM配置同样生效
+	updates.ID = id
+	return s.db.Model(existing).
+		Select("name", "description", "template", "parameters", "tools", "llm_config_id").
+		Updates(updates).Error
+}
+
+// DeleteTemplate soft deletes a task template
+func (s *TaskTemplateService) DeleteTemplate(id uint) error {
+	template, err := s.GetTemplate(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(template).Update("is_active", false).Error
+}
+
+// RenderTemplate loads a task template and renders it with params
+//
+// Parameters:
+//   - id: ID of the task template
+//   - params: Parameter values by name
+//
+// Returns:
+//   - *models.TaskTemplateModel: The loaded template, also returned on render errors
+//   - string: Rendered task text
+//   - error: models.ErrTaskTemplateNotFound or *models.TemplateRenderError
+func (s *TaskTemplateService) RenderTemplate(id uint, params map[string]any) (*models.TaskTemplateModel, string, error) {
+	template, err := s.GetTemplate(id)
+	if err != nil {
+		return nil, "", err
+	}
+	task, err := template.Render(params)
+	if err != nil {
+		return template, "", err
+	}
+	return template, task, nil
+}
+
+// validate checks the template and that its LLM config exists
+func (s *TaskTemplateService) validate(template *models.TaskTemplateModel) error {
+	if err := template.Validate(); err != nil {
+		return err
+	}
+	if template.LLMConfigID == nil {
+		return nil
+	}
+
+	var count int64
+	err := s.db.Model(&models.LLMConfigModel{}).Where("id = ? AND is_active = ?", *template.LLMConfigID, true).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: llm_config_id=%d", models.ErrLLMConfigNotFound, *template.LLMConfigID)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedTaskTemplate stores a template with a required and an optional parameter
+func seedTaskTemplate(t *testing.T, service *TaskTemplateService) *models.TaskTemplateModel {
+	template := &models.TaskTemplateModel{
+		Name:        "域名侦察",
+		Description: "收集域名的子域名",
+		Template:    "收集{domain}的子域名，深度{depth}",
+	}
+	require.NoError(t, template.SetParameters([]models.TaskTemplateParam{
+		{Name: "domain", Required: true},
+		{Name: "depth", Type: models.TemplateParamTypeInteger, Default: 2},
+	}))
+	require.NoError(t, template.SetTools([]models.MCPToolConfig{{Server: "fetch", Name: "fetch"}}))
+	require.NoError(t, service.CreateTemplate(template))
+	return template
+}
+
+func TestTaskTemplateService_CRUD(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewTaskTemplateService()
+	template := seedTaskTemplate(t, service)
+	assert.NotZero(t, template.ID)
+
+	// 名称重复
+	duplicate := &models.TaskTemplateModel{Name: template.Name, Template: "x"}
+	assert.ErrorIs(t, service.CreateTemplate(duplicate), models.ErrTaskTemplateNameExists)
+
+	byName, err := service.GetTemplateByName("域名侦察")
+	require.NoError(t, err)
+	assert.Equal(t, template.ID, byName.ID)
+	tools, err := byName.GetTools()
+	require.NoError(t, err)
+	assert.Equal(t, []models.MCPToolConfig{{Server: "fetch", Name: "fetch"}}, tools)
+
+	// 更新时清空工具列表
+	updates := &models.TaskTemplateModel{Name: "域名侦察v2", Template: "侦察{domain}"}
+	require.NoError(t, updates.SetParameters([]models.TaskTemplateParam{{Name: "domain", Required: true}}))
+	require.NoError(t, updates.SetTools(nil))
+	require.NoError(t, service.UpdateTemplate(template.ID, updates))
+	updated, err := service.GetTemplate(template.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "域名侦察v2", updated.Name)
+	tools, err = updated.GetTools()
+	require.NoError(t, err)
+	assert.Empty(t, tools)
+
+	templates, err := service.ListTemplates()
+	require.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	require.NoError(t, service.DeleteTemplate(template.ID))
+	_, err = service.GetTemplate(template.ID)
+	assert.ErrorIs(t, err, models.ErrTaskTemplateNotFound)
+	assert.ErrorIs(t, service.DeleteTemplate(template.ID), models.ErrTaskTemplateNotFound)
+}
+
+func TestTaskTemplateService_RenderTemplate(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewTaskTemplateService()
+	template := seedTaskTemplate(t, service)
+
+	_, task, err := service.RenderTemplate(template.ID, map[string]any{"domain": "example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "收集example.com的子域名，深度2", task)
+
+	_, _, err = service.RenderTemplate(template.ID, map[string]any{"target": "example.com"})
+	assert.ErrorIs(t, err, models.ErrTaskTemplateParamsInvalid)
+	assert.Contains(t, err.Error(), "缺少参数 domain")
+	assert.Contains(t, err.Error(), "多余参数 target")
+
+	_, _, err = service.RenderTemplate(template.ID+100, nil)
+	assert.ErrorIs(t, err, models.ErrTaskTemplateNotFound)
+}
+
+func TestTaskTemplateService_LLMConfigMustExist(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	missing := uint(9999)
+	template := &models.TaskTemplateModel{Name: "t", Template: "x", LLMConfigID: &missing}
+	assert.ErrorIs(t, NewTaskTemplateService().CreateTemplate(template), models.ErrLLMConfigNotFound)
+}
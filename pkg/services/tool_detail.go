@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ServerToolLister lists the tools of the MCP server name
+type ServerToolLister func(ctx context.Context, name string, server *mcphost.ServerConfig) ([]mcphost.ToolDetail, error)
+
+// ListServerTools connects to the server name through the default connection
+// pool and lists its tools. A hub with this single server is used, so every
+// returned tool belongs to it.
+func ListServerTools(ctx context.Context, name string, server *mcphost.ServerConfig) ([]*schema.ToolInfo, error) {
+	settings := &mcphost.MCPSettings{
+		MCPServers: map[string]*mcphost.ServerConfig{name: server},
+	}
+
+	pool := mcppool.Default()
+	hub, err := pool.GetHub(ctx, settings)
+	if err != nil {
+		return nil, fmt.Errorf("连接MCP服务器失败: %w", err)
+	}
+	defer pool.ReleaseHub(settings)
+
+	toolsMap, err := hub.GetToolsMap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取工具列表失败: %w", err)
+	}
+	tools := make([]*schema.ToolInfo, 0, len(toolsMap))
+	for _, info := range toolsMap {
+		tools = append(tools, info)
+	}
+	return tools, nil
+}
+
+// ListServerToolDetails connects to the server name through the default
+// connection pool and lists its tools with mcphost.MCPHub.GetToolsDetailed
+func ListServerToolDetails(ctx context.Context, name string, server *mcphost.ServerConfig) ([]mcphost.ToolDetail, error) {
+	settings := &mcphost.MCPSettings{
+		MCPServers: map[string]*mcphost.ServerConfig{name: server},
+	}
+
+	pool := mcppool.Default()
+	hub, err := pool.GetHub(ctx, settings)
+	if err != nil {
+		return nil, fmt.Errorf("连接MCP服务器失败: %w", err)
+	}
+	defer pool.ReleaseHub(settings)
+
+	details, err := hub.GetToolsDetailed(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取工具列表失败: %w", err)
+	}
+	return details, nil
+}
+
+// ListToolDetails lists the tools of every server separately, so that the
+// server of each tool is known from the configuration instead of being parsed
+// from its tool key. The tools are ordered by server and name.
+//
+// Parameters:
+//   - ctx: Context for the connections
+//   - servers: MCP servers by name
+//   - lister: Lists the tools of one server, nil uses ListServerToolDetails
+//
+// Returns:
+//   - []mcphost.ToolDetail: Tools of all servers
+//   - error: Error of the first server, in name order, that could not be listed
+func ListToolDetails(ctx context.Context, servers map[string]*mcphost.ServerConfig, lister ServerToolLister) ([]mcphost.ToolDetail, error) {
+	if lister == nil {
+		lister = ListServerToolDetails
+	}
+
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var details []mcphost.ToolDetail
+	for _, name := range names {
+		tools, err := lister(ctx, name, servers[name])
+		if err != nil {
+			return nil, fmt.Errorf("MCP服务器 %s: %w", name, err)
+		}
+		details = append(details, tools...)
+	}
+	return details, nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListToolDetailsServerNamesWithUnderscores(t *testing.T) {
+	// 两个服务器的工具键都是 a_b_b_c，只能从配置中确定工具所属的服务器
+	servers := map[string]*mcphost.ServerConfig{
+		"a_b": {URL: "http://127.0.0.1:1/a_b"},
+		"a":   {URL: "http://127.0.0.1:1/a"},
+	}
+	lister := func(ctx context.Context, name string, server *mcphost.ServerConfig) ([]mcphost.ToolDetail, error) {
+		assert.Same(t, servers[name], server)
+		return []mcphost.ToolDetail{{Server: name, Name: "b_c", Description: "tool of " + name}}, nil
+	}
+
+	details, err := ListToolDetails(context.Background(), servers, lister)
+	require.NoError(t, err)
+	assert.Equal(t, []mcphost.ToolDetail{
+		{Server: "a", Name: "b_c", Description: "tool of a"},
+		{Server: "a_b", Name: "b_c", Description: "tool of a_b"},
+	}, details)
+}
+
+func TestListToolDetailsError(t *testing.T) {
+	servers := map[string]*mcphost.ServerConfig{"broken": {Command: "/nonexistent"}}
+	_, err := ListToolDetails(context.Background(), servers, func(ctx context.Context, name string, server *mcphost.ServerConfig) ([]mcphost.ToolDetail, error) {
+		return nil, errors.New("连接失败")
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MCP服务器 broken")
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+)
+
+const (
+	// DefaultParameterDepth is the default number of nesting levels FlattenToolParameters expands
+	DefaultParameterDepth = 3
+
+	// MaxParameterDepth limits the nesting levels a client may request
+	MaxParameterDepth = 10
+)
+
+// JSON Schema types of tool parameters
+const (
+	paramTypeString  = "string"
+	paramTypeNumber  = "number"
+	paramTypeInteger = "integer"
+	paramTypeBoolean = "boolean"
+	paramTypeArray   = "array"
+	paramTypeObject  = "object"
+	paramTypeNull    = "null"
+)
+
+// FlattenToolParameters turns the input schema of a tool into a flat list of
+// form fields, so that clients can render a form without implementing JSON
+// Schema. Properties are listed in name order. Nested objects are expanded
+// into dotted paths up to maxDepth levels, deeper objects are listed as a
+// single "object" field. Arrays report the type of primitive items. Schemas
+// that cannot be represented as a single field, such as $ref, allOf or a
+// oneOf/anyOf with several alternatives, degrade to "object"; a oneOf/anyOf
+// whose only other alternative is null is treated as that alternative.
+//
+// Parameters:
+//   - inputSchema: JSON Schema of the tool input, usually an object schema
+//   - maxDepth: Number of object levels to expand, values below 1 expand only the top level
+//
+// Returns:
+//   - []models.ToolParameter: Form fields, never nil
+func FlattenToolParameters(inputSchema map[string]any, maxDepth int) []models.ToolParameter {
+	if maxDepth < 1 {
+		maxDepth = 1
+	}
+	params := []models.ToolParameter{}
+	flattenObject(inputSchema, "", true, 1, maxDepth, &params)
+	return params
+}
+
+// flattenObject appends the properties of an object schema to params
+func flattenObject(objectSchema map[string]any, prefix string, parentRequired bool, depth int, maxDepth int, params *[]models.ToolParameter) {
+	properties, _ := objectSchema["properties"].(map[string]any)
+	required := make(map[string]bool)
+	if list, ok := objectSchema["required"].([]any); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propSchema, ok := properties[name].(map[string]any)
+		if !ok {
+			// 布尔值等无法解析的模式按任意对象处理
+			propSchema = map[string]any{}
+		}
+		propSchema = resolveSingleAlternative(propSchema)
+		path := prefix + name
+		isRequired := parentRequired && required[name]
+
+		paramType := schemaType(propSchema)
+		if paramType == paramTypeObject && depth < maxDepth {
+			if nested, ok := propSchema["properties"].(map[string]any); ok && len(nested) > 0 {
+				flattenObject(propSchema, path+".", isRequired, depth+1, maxDepth, params)
+				continue
+			}
+		}
+
+		param := models.ToolParameter{
+			Name:        path,
+			Type:        paramType,
+			Description: schemaDescription(propSchema),
+			Required:    isRequired,
+			Enum:        schemaEnum(propSchema),
+			Default:     propSchema["default"],
+		}
+		if paramType == paramTypeArray {
+			if items, ok := propSchema["items"].(map[string]any); ok {
+				items = resolveSingleAlternative(items)
+				param.ItemType = schemaType(items)
+				if param.Enum == nil {
+					param.Enum = schemaEnum(items)
+				}
+			} else {
+				param.ItemType = paramTypeObject
+			}
+		}
+		*params = append(*params, param)
+	}
+}
+
+// resolveSingleAlternative returns the only non-null alternative of a oneOf
+// or anyOf schema, as generated for optional values, and s itself otherwise.
+// The description and default of s are kept.
+func resolveSingleAlternative(s map[string]any) map[string]any {
+	if _, ok := s["type"]; ok {
+		return s
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		alternatives, ok := s[keyword].([]any)
+		if !ok {
+			continue
+		}
+		var only map[string]any
+		for _, alternative := range alternatives {
+			alt, ok := alternative.(map[string]any)
+			if !ok {
+				return s
+			}
+			if alt["type"] == paramTypeNull {
+				continue
+			}
+			if only != nil {
+				return s
+			}
+			only = alt
+		}
+		if only == nil {
+			return s
+		}
+		resolved := make(map[string]any, len(only)+2)
+		for k, v := range only {
+			resolved[k] = v
+		}
+		for _, k := range []string{"description", "title", "default"} {
+			if v, ok := s[k]; ok {
+				resolved[k] = v
+			}
+		}
+		return resolved
+	}
+	return s
+}
+
+// schemaType returns the type of a property schema, "object" if it cannot be determined
+func schemaType(s map[string]any) string {
+	if _, ok := s["$ref"]; ok {
+		return paramTypeObject
+	}
+	switch t := s["type"].(type) {
+	case string:
+		if isParamType(t) {
+			return t
+		}
+	case []any:
+		// ["string", "null"] 取第一个非null的类型
+		for _, item := range t {
+			if name, ok := item.(string); ok && name != paramTypeNull && isParamType(name) {
+				return name
+			}
+		}
+	case nil:
+		// 没有type时根据其他关键字推断
+		if _, ok := s["properties"]; ok {
+			return paramTypeObject
+		}
+		if _, ok := s["items"]; ok {
+			return paramTypeArray
+		}
+		if enum := schemaEnum(s); len(enum) > 0 {
+			if _, ok := enum[0].(string); ok {
+				return paramTypeString
+			}
+		}
+	}
+	return paramTypeObject
+}
+
+// isParamType reports whether t is a JSON Schema type usable as a form field
+func isParamType(t string) bool {
+	switch t {
+	case paramTypeString, paramTypeNumber, paramTypeInteger, paramTypeBoolean, paramTypeArray, paramTypeObject:
+		return true
+	}
+	return false
+}
+
+// schemaDescription returns the description of a schema, its title if it has none
+func schemaDescription(s map[string]any) string {
+	if description, ok := s["description"].(string); ok && description != "" {
+		return description
+	}
+	title, _ := s["title"].(string)
+	return title
+}
+
+// schemaEnum returns the allowed values of a schema from enum or const
+func schemaEnum(s map[string]any) []any {
+	if enum, ok := s["enum"].([]any); ok && len(enum) > 0 {
+		return enum
+	}
+	if value, ok := s["const"]; ok {
+		return []any{value}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseTestSchema parses a JSON Schema literal of a test
+func parseTestSchema(t *testing.T, text string) map[string]any {
+	t.Helper()
+	var s map[string]any
+	require.NoError(t, json.Unmarshal([]byte(text), &s))
+	return s
+}
+
+func TestFlattenToolParametersPrimitives(t *testing.T) {
+	inputSchema := parseTestSchema(t, `{
+		"type": "object",
+		"required": ["query"],
+		"properties": {
+			"query": {"type": "string", "description": "搜索关键词"},
+			"size": {"type": "integer", "default": 10, "title": "结果数量"},
+			"ratio": {"type": "number"},
+			"full": {"type": "boolean", "default": false},
+			"mode": {"type": "string", "enum": ["fast", "accurate"], "default": "fast"},
+			"version": {"const": "v1"}
+		}
+	}`)
+
+	params := FlattenToolParameters(inputSchema, DefaultParameterDepth)
+	assert.Equal(t, []models.ToolParameter{
+		{Name: "full", Type: "boolean", Default: false},
+		{Name: "mode", Type: "string", Enum: []any{"fast", "accurate"}, Default: "fast"},
+		{Name: "query", Type: "string", Description: "搜索关键词", Required: true},
+		{Name: "ratio", Type: "number"},
+		{Name: "size", Type: "integer", Description: "结果数量", Default: float64(10)},
+		{Name: "version", Type: "string", Enum: []any{"v1"}},
+	}, params)
+}
+
+func TestFlattenToolParametersNestedObjects(t *testing.T) {
+	inputSchema := parseTestSchema(t, `{
+		"type": "object",
+		"required": ["options"],
+		"properties": {
+			"options": {
+				"type": "object",
+				"required": ["limit", "filter"],
+				"properties": {
+					"limit": {"type": "integer"},
+					"sort": {"type": "string"},
+					"filter": {
+						"type": "object",
+						"required": ["field"],
+						"properties": {
+							"field": {"type": "string"},
+							"range": {"type": "object", "properties": {"from": {"type": "integer"}}}
+						}
+					}
+				}
+			},
+			"meta": {
+				"type": "object",
+				"required": ["owner"],
+				"properties": {"owner": {"type": "string"}}
+			},
+			"extra": {"type": "object", "description": "任意附加字段"}
+		}
+	}`)
+
+	// 默认展开三层，更深的对象作为整体
+	params := FlattenToolParameters(inputSchema, DefaultParameterDepth)
+	assert.Equal(t, []models.ToolParameter{
+		{Name: "extra", Type: "object", Description: "任意附加字段"},
+		{Name: "meta.owner", Type: "string"},
+		{Name: "options.filter.field", Type: "string", Required: true},
+		{Name: "options.filter.range", Type: "object"},
+		{Name: "options.limit", Type: "integer", Required: true},
+		{Name: "options.sort", Type: "string"},
+	}, params)
+
+	// 可选对象中的必填字段不是必填的
+	assert.False(t, params[1].Required)
+
+	// 只展开顶层时嵌套对象作为整体
+	params = FlattenToolParameters(inputSchema, 1)
+	var names []string
+	for _, param := range params {
+		names = append(names, param.Name+":"+param.Type)
+	}
+	assert.Equal(t, []string{"extra:object", "meta:object", "options:object"}, names)
+	assert.True(t, params[2].Required)
+	assert.Equal(t, params, FlattenToolParameters(inputSchema, 0))
+}
+
+func TestFlattenToolParametersArrays(t *testing.T) {
+	inputSchema := parseTestSchema(t, `{
+		"type": "object",
+		"properties": {
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"levels": {"type": "array", "items": {"type": "string", "enum": ["low", "high"]}},
+			"ids": {"items": {"type": "integer"}},
+			"rows": {"type": "array", "items": {"type": "object", "properties": {"a": {"type": "string"}}}},
+			"any": {"type": "array"}
+		}
+	}`)
+
+	params := FlattenToolParameters(inputSchema, DefaultParameterDepth)
+	assert.Equal(t, []models.ToolParameter{
+		{Name: "any", Type: "array", ItemType: "object"},
+		{Name: "ids", Type: "array", ItemType: "integer"},
+		{Name: "levels", Type: "array", ItemType: "string", Enum: []any{"low", "high"}},
+		{Name: "rows", Type: "array", ItemType: "object"},
+		{Name: "tags", Type: "array", ItemType: "string"},
+	}, params)
+}
+
+func TestFlattenToolParametersDegradesGracefully(t *testing.T) {
+	inputSchema := parseTestSchema(t, `{
+		"type": "object",
+		"required": ["target", "optional_name"],
+		"properties": {
+			"target": {"$ref": "#/$defs/Target"},
+			"choice": {"oneOf": [{"type": "string"}, {"type": "integer"}]},
+			"combined": {"allOf": [{"type": "object"}, {"required": ["a"]}]},
+			"optional_name": {"anyOf": [{"type": "string"}, {"type": "null"}], "description": "可选名称", "default": null},
+			"nullable": {"type": ["null", "integer"]},
+			"weird": {"type": "date"},
+			"broken": true
+		},
+		"$defs": {"Target": {"type": "object", "properties": {"ip": {"type": "string"}}}}
+	}`)
+
+	params := FlattenToolParameters(inputSchema, DefaultParameterDepth)
+	assert.Equal(t, []models.ToolParameter{
+		{Name: "broken", Type: "object"},
+		{Name: "choice", Type: "object"},
+		{Name: "combined", Type: "object"},
+		{Name: "nullable", Type: "integer"},
+		{Name: "optional_name", Type: "string", Description: "可选名称", Required: true},
+		{Name: "target", Type: "object", Required: true},
+		{Name: "weird", Type: "object"},
+	}, params)
+}
+
+func TestFlattenToolParametersEmptySchemas(t *testing.T) {
+	assert.Equal(t, []models.ToolParameter{}, FlattenToolParameters(nil, DefaultParameterDepth))
+	assert.Equal(t, []models.ToolParameter{}, FlattenToolParameters(map[string]any{"type": "object"}, DefaultParameterDepth))
+}
+
+func TestFlattenToolInputSchema(t *testing.T) {
+	info := &schema.ToolInfo{
+		Name: "search",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"query": {Type: schema.String, Desc: "搜索关键词", Required: true},
+			"mode":  {Type: schema.String, Enum: []string{"fast", "accurate"}},
+			"options": {Type: schema.Object, SubParams: map[string]*schema.ParameterInfo{
+				"limit": {Type: schema.Integer, Required: true},
+			}},
+		}),
+	}
+	inputSchema := mcphost.ToolInputSchema(info)
+	require.NotNil(t, inputSchema)
+	assert.Equal(t, "object", inputSchema["type"])
+
+	params := FlattenToolParameters(inputSchema, DefaultParameterDepth)
+	assert.Equal(t, []models.ToolParameter{
+		{Name: "mode", Type: "string", Enum: []any{"fast", "accurate"}},
+		{Name: "options.limit", Type: "integer"},
+		{Name: "query", Type: "string", Description: "搜索关键词", Required: true},
+	}, params)
+}
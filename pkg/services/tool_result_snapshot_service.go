@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/resultdiff"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ToolResultSnapshotService keeps the latest result of tool calls in the
+// database. It implements resultdiff.Store for the tools of web tasks.
+type ToolResultSnapshotService struct {
+	db *gorm.DB
+}
+
+var _ resultdiff.Store = (*ToolResultSnapshotService)(nil)
+
+// NewToolResultSnapshotService creates a new tool result snapshot service instance
+func NewToolResultSnapshotService() *ToolResultSnapshotService {
+	return &ToolResultSnapshotService{
+		db: database.GetDB(),
+	}
+}
+
+// PreviousResult returns the result saved for callKey, false if there is none
+func (s *ToolResultSnapshotService) PreviousResult(callKey string) (string, bool, error) {
+	var snapshot models.ToolResultSnapshotModel
+	err := s.db.Where("call_key = ?", callKey).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return snapshot.Result, true, nil
+}
+
+// SaveResult replaces the result saved for callKey, creating the row if needed
+func (s *ToolResultSnapshotService) SaveResult(callKey string, toolKey string, result string) error {
+	snapshot := &models.ToolResultSnapshotModel{
+		CallKey: callKey,
+		ToolKey: toolKey,
+		Result:  result,
+	}
+	// 使用 ON CONFLICT 覆盖，并行的相同调用不会因唯一索引失败
+	return database.RetryOnBusy(func() error {
+		return s.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "call_key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"result":     result,
+				"updated_at": time.Now(),
+			}),
+		}).Create(snapshot).Error
+	})
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/resultdiff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolResultSnapshotService(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewToolResultSnapshotService()
+	key := resultdiff.Key("fofa_search", `{"query":"port=80"}`)
+
+	_, found, err := service.PreviousResult(key)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, service.SaveResult(key, "fofa_search", "第一次"))
+	require.NoError(t, service.SaveResult(key, "fofa_search", "第二次"))
+	require.NoError(t, service.SaveResult(resultdiff.Key("fofa_host", "{}"), "fofa_host", "主机"))
+
+	previous, found, err := service.PreviousResult(key)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "第二次", previous)
+
+	// 其他工具的结果互不影响
+	previous, found, err = service.PreviousResult(resultdiff.Key("fofa_host", "{}"))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "主机", previous)
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"gorm.io/gorm"
+)
+
+const (
+	// toolSyncBatchSize is the maximum number of changes applied in one transaction
+	toolSyncBatchSize = 100
+
+	// toolSyncBatchDuration bounds how long one transaction holds the sqlite
+	// write lock: a batch is committed early once it has run this long
+	toolSyncBatchDuration = 200 * time.Millisecond
+
+	// toolSyncLookupSize is the number of tool keys looked up in one query
+	toolSyncLookupSize = 500
+)
+
+// SyncReport is the difference between the tools a server lists and the
+// tools stored before a sync, by tool key
+type SyncReport struct {
+	Added     []string `json:"added"`     // 新增或重新出现的工具
+	Updated   []string `json:"updated"`   // 名称、描述或输入模式变化的工具
+	Removed   []string `json:"removed"`   // 服务器不再提供、已软删除的工具
+	Unchanged int      `json:"unchanged"` // 未变化的工具数量
+}
+
+// Total returns the number of tools the server lists
+func (r *SyncReport) Total() int {
+	return len(r.Added) + len(r.Updated) + r.Unchanged
+}
+
+// String summarizes the report for logs
+func (r *SyncReport) String() string {
+	return fmt.Sprintf("新增%d个，更新%d个，移除%d个，未变化%d个", len(r.Added), len(r.Updated), len(r.Removed), r.Unchanged)
+}
+
+// syncProgressKey is the context key of the handler receiving sync progress
+type syncProgressKey struct{}
+
+// WithSyncProgress returns a context in which SyncToolsForServer reports
+// after every committed batch how many of the changes of the sync are applied
+func WithSyncProgress(ctx context.Context, handler func(applied, total int)) context.Context {
+	return context.WithValue(ctx, syncProgressKey{}, handler)
+}
+
+// reportSyncProgress sends the progress of a sync to the handler of ctx, if any
+func reportSyncProgress(ctx context.Context, applied, total int) {
+	if handler, ok := ctx.Value(syncProgressKey{}).(func(applied, total int)); ok && handler != nil {
+		handler(applied, total)
+	}
+}
+
+// Kinds of toolChange
+const (
+	toolChangeAdd    = iota // 创建新的工具记录
+	toolChangeRevive        // 恢复已删除或属于其他服务器的同名记录
+	toolChangeUpdate        // 更新名称、描述和输入模式
+	toolChangeRemove        // 软删除
+)
+
+// toolChange is one row change of a sync
+type toolChange struct {
+	kind int
+	tool models.MCPToolModel // 期望的工具状态，删除时为现有记录
+}
+
+// applyServerTools makes the stored tools of a server match toolsMap. Only
+// the differences are written, in batches of short transactions, so a sync
+// of a server with hundreds of tools never holds the write lock for long.
+// Unchanged tools keep all of their columns, changed tools keep whether they
+// are active, their result filter, output format and localized descriptions.
+func (s *MCPToolService) applyServerTools(ctx context.Context, serverConfig *models.MCPServerConfigModel, toolsMap map[string]*schema.ToolInfo) (*SyncReport, error) {
+	existing, err := s.existingServerTools(serverConfig.ID, toolsMap)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SyncReport{Added: []string{}, Updated: []string{}, Removed: []string{}}
+	var changes []toolChange
+	now := time.Now()
+	keys := make([]string, 0, len(toolsMap))
+	for toolKey := range toolsMap {
+		keys = append(keys, toolKey)
+	}
+	sort.Strings(keys)
+	for _, toolKey := range keys {
+		desired := listedTool(serverConfig, toolKey, toolsMap[toolKey])
+		desired.LastSyncAt = &now
+		current, ok := existing[toolKey]
+		switch {
+		case !ok:
+			changes = append(changes, toolChange{kind: toolChangeAdd, tool: desired})
+			report.Added = append(report.Added, toolKey)
+		case current.DeletedAt.Valid || current.ServerID != serverConfig.ID:
+			desired.ID = current.ID
+			changes = append(changes, toolChange{kind: toolChangeRevive, tool: desired})
+			report.Added = append(report.Added, toolKey)
+		case current.Name != desired.Name || current.Description != desired.Description || current.InputSchema != desired.InputSchema:
+			desired.ID = current.ID
+			changes = append(changes, toolChange{kind: toolChangeUpdate, tool: desired})
+			report.Updated = append(report.Updated, toolKey)
+		default:
+			report.Unchanged++
+		}
+	}
+
+	var removed []string
+	for toolKey, current := range existing {
+		if _, ok := toolsMap[toolKey]; !ok && !current.DeletedAt.Valid && current.ServerID == serverConfig.ID {
+			removed = append(removed, toolKey)
+		}
+	}
+	sort.Strings(removed)
+	for _, toolKey := range removed {
+		changes = append(changes, toolChange{kind: toolChangeRemove, tool: *existing[toolKey]})
+		report.Removed = append(report.Removed, toolKey)
+	}
+
+	for applied := 0; applied < len(changes); {
+		var committed int
+		err := database.RetryOnBusy(func() error {
+			var err error
+			committed, err = s.applyToolChanges(changes[applied:])
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		applied += committed
+		reportSyncProgress(ctx, applied, len(changes))
+	}
+
+	// 未变化的工具只记录同步时间
+	if err := database.RetryOnBusy(func() error {
+		return s.db.Model(&models.MCPToolModel{}).Where("server_id = ?", serverConfig.ID).UpdateColumn("last_sync_at", now).Error
+	}); err != nil {
+		return nil, fmt.Errorf("更新工具同步时间失败: %w", err)
+	}
+	return report, nil
+}
+
+// existingServerTools returns the stored tools of a server and the stored
+// tools with the keys of toolsMap, including deleted ones, by tool key
+func (s *MCPToolService) existingServerTools(serverID uint, toolsMap map[string]*schema.ToolInfo) (map[string]*models.MCPToolModel, error) {
+	var rows []models.MCPToolModel
+	if err := s.db.Unscoped().Where("server_id = ?", serverID).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("读取现有工具失败: %w", err)
+	}
+	existing := make(map[string]*models.MCPToolModel, len(rows))
+	for i := range rows {
+		existing[rows[i].ToolKey] = &rows[i]
+	}
+
+	// 工具键全局唯一，其他服务器（例如已删除的同名服务器）留下的记录也要复用
+	var missing []string
+	for toolKey := range toolsMap {
+		if _, ok := existing[toolKey]; !ok {
+			missing = append(missing, toolKey)
+		}
+	}
+	for start := 0; start < len(missing); start += toolSyncLookupSize {
+		end := min(start+toolSyncLookupSize, len(missing))
+		var others []models.MCPToolModel
+		if err := s.db.Unscoped().Where("tool_key IN ?", missing[start:end]).Find(&others).Error; err != nil {
+			return nil, fmt.Errorf("读取现有工具失败: %w", err)
+		}
+		for i := range others {
+			existing[others[i].ToolKey] = &others[i]
+		}
+	}
+	return existing, nil
+}
+
+// applyToolChanges applies changes from the start in one transaction, at
+// most toolSyncBatchSize of them and stopping after toolSyncBatchDuration
+//
+// Returns:
+//   - int: Number of changes committed
+//   - error: Error of the transaction, nothing is committed then
+func (s *MCPToolService) applyToolChanges(changes []toolChange) (int, error) {
+	start := time.Now()
+	count := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			if count >= toolSyncBatchSize || (count > 0 && time.Since(start) >= toolSyncBatchDuration) {
+				break
+			}
+			if err := applyToolChange(tx, change); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// applyToolChange writes one change in tx
+func applyToolChange(tx *gorm.DB, change toolChange) error {
+	tool := change.tool
+	switch change.kind {
+	case toolChangeAdd:
+		if err := tx.Create(&tool).Error; err != nil {
+			return fmt.Errorf("创建工具 %s 失败: %w", tool.ToolKey, err)
+		}
+	case toolChangeRevive, toolChangeUpdate:
+		updates := map[string]interface{}{
+			"name":         tool.Name,
+			"description":  tool.Description,
+			"input_schema": tool.InputSchema,
+			"last_sync_at": tool.LastSyncAt,
+		}
+		if change.kind == toolChangeRevive {
+			updates["server_id"] = tool.ServerID
+			updates["is_active"] = true
+			updates["deleted_at"] = nil
+		}
+		if err := tx.Unscoped().Model(&models.MCPToolModel{}).Where("id = ?", tool.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新工具 %s 失败: %w", tool.ToolKey, err)
+		}
+	case toolChangeRemove:
+		if err := tx.Delete(&models.MCPToolModel{}, tool.ID).Error; err != nil {
+			return fmt.Errorf("删除工具 %s 失败: %w", tool.ToolKey, err)
+		}
+	}
+	return nil
+}
+
+// listedTool returns the stored form of a tool listed by a server
+func listedTool(serverConfig *models.MCPServerConfigModel, toolKey string, toolInfo *schema.ToolInfo) models.MCPToolModel {
+	// 解析工具键获取工具名称
+	toolName := toolInfo.Name
+	if toolName == "" {
+		// 如果工具名称为空，从工具键中提取
+		if len(toolKey) > len(serverConfig.Name)+1 {
+			toolName = toolKey[len(serverConfig.Name)+1:]
+		} else {
+			toolName = toolKey
+		}
+	}
+
+	tool := models.MCPToolModel{
+		Name:        toolName,
+		Description: toolInfo.Desc,
+		ServerID:    serverConfig.ID,
+		ToolKey:     toolKey,
+		IsActive:    true,
+	}
+
+	// 设置输入模式（如果有的话）
+	if toolInfo.ParamsOneOf != nil {
+		// 将OpenAPI模式转换为简单的map格式存储，转换失败时使用基本的对象模式
+		schemaMap := map[string]interface{}{"type": "object"}
+		if openAPISchema, err := toolInfo.ParamsOneOf.ToOpenAPIV3(); err == nil && openAPISchema != nil {
+			schemaMap["type"] = openAPISchema.Type
+			if openAPISchema.Properties != nil {
+				schemaMap["properties"] = openAPISchema.Properties
+			}
+			if openAPISchema.Required != nil {
+				schemaMap["required"] = openAPISchema.Required
+			}
+		}
+		if err := tool.SetInputSchema(schemaMap); err != nil {
+			log.Printf("设置工具 %s 的输入模式失败: %v", toolKey, err)
+		}
+	}
+	return tool
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolListing 模拟服务器列出的工具，键为工具键
+func toolListing(server *models.MCPServerConfigModel, descriptions map[string]string) map[string]*schema.ToolInfo {
+	listing := make(map[string]*schema.ToolInfo, len(descriptions))
+	for name, description := range descriptions {
+		listing[models.GenerateToolKey(server.Name, name)] = &schema.ToolInfo{
+			Name: name,
+			Desc: description,
+			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+				"url": {Type: schema.String, Desc: "目标地址", Required: true},
+			}),
+		}
+	}
+	return listing
+}
+
+// storedTool 读取工具记录，包括已软删除的记录
+func storedTool(t *testing.T, toolKey string) models.MCPToolModel {
+	t.Helper()
+	var tool models.MCPToolModel
+	require.NoError(t, database.GetDB().Unscoped().Where("tool_key = ?", toolKey).First(&tool).Error)
+	return tool
+}
+
+func TestMCPToolService_ApplyServerToolsDiff(t *testing.T) {
+	setupMCPToolTestDB(t)
+	defer teardownMCPToolTestDB(t)
+
+	service := NewMCPToolService()
+	server := createTestMCPServer(t)
+	ctx := context.Background()
+
+	// 旧快照：首次同步四个工具
+	report, err := service.applyServerTools(ctx, server, toolListing(server, map[string]string{
+		"keep":     "保持不变",
+		"describe": "旧描述",
+		"disabled": "已被用户停用",
+		"drop":     "将被移除",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, &SyncReport{
+		Added:   []string{"test-server_describe", "test-server_disabled", "test-server_drop", "test-server_keep"},
+		Updated: []string{},
+		Removed: []string{},
+	}, report)
+
+	// 用户的设置：本地化描述、结果过滤、停用工具
+	db := database.GetDB()
+	require.NoError(t, db.Model(&models.MCPToolModel{}).Where("tool_key = ?", "test-server_keep").
+		Updates(map[string]interface{}{"localized_descriptions": `{"en":"Keep"}`, "result_filter": ".items"}).Error)
+	require.NoError(t, db.Model(&models.MCPToolModel{}).Where("tool_key = ?", "test-server_describe").
+		Update("localized_descriptions", `{"en":"Describe"}`).Error)
+	require.NoError(t, db.Model(&models.MCPToolModel{}).Where("tool_key = ?", "test-server_disabled").
+		Update("is_active", false).Error)
+
+	// 之前同步时被移除的工具
+	back := &models.MCPToolModel{Name: "back", ServerID: server.ID, ToolKey: "test-server_back", IsActive: true}
+	require.NoError(t, db.Create(back).Error)
+	require.NoError(t, db.Delete(back).Error)
+
+	// 已删除的同名服务器留下的工具
+	oldServer := &models.MCPServerConfigModel{Name: "old-server", Command: "uvx", IsActive: true}
+	require.NoError(t, oldServer.SetArgs([]string{"test-mcp-server"}))
+	require.NoError(t, db.Create(oldServer).Error)
+	moved := &models.MCPToolModel{Name: "moved", ServerID: oldServer.ID, ToolKey: "test-server_moved"}
+	require.NoError(t, db.Create(moved).Error)
+	keep := storedTool(t, "test-server_keep")
+
+	// 服务器的新工具列表
+	report, err = service.applyServerTools(ctx, server, toolListing(server, map[string]string{
+		"keep":     "保持不变",
+		"describe": "新描述",
+		"disabled": "已被用户停用",
+		"back":     "重新出现",
+		"moved":    "换了服务器",
+		"fresh":    "新工具",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, &SyncReport{
+		Added:     []string{"test-server_back", "test-server_fresh", "test-server_moved"},
+		Updated:   []string{"test-server_describe"},
+		Removed:   []string{"test-server_drop"},
+		Unchanged: 2,
+	}, report)
+	assert.Equal(t, 6, report.Total())
+	assert.Equal(t, "新增3个，更新1个，移除1个，未变化2个", report.String())
+
+	// 未变化的工具保留所有设置
+	tool := storedTool(t, "test-server_keep")
+	assert.Equal(t, keep.ID, tool.ID)
+	assert.True(t, tool.IsActive)
+	assert.Equal(t, `{"en":"Keep"}`, tool.LocalizedDescriptions)
+	assert.Equal(t, ".items", tool.ResultFilter)
+	assert.Equal(t, keep.InputSchema, tool.InputSchema)
+	assert.Contains(t, tool.InputSchema, `"url"`)
+	require.NotNil(t, tool.LastSyncAt)
+	assert.True(t, tool.LastSyncAt.After(*keep.LastSyncAt))
+
+	// 描述变化的工具更新描述，保留本地化描述
+	tool = storedTool(t, "test-server_describe")
+	assert.Equal(t, "新描述", tool.Description)
+	assert.Equal(t, `{"en":"Describe"}`, tool.LocalizedDescriptions)
+	assert.True(t, tool.IsActive)
+
+	// 停用的工具保持停用
+	tool = storedTool(t, "test-server_disabled")
+	assert.False(t, tool.IsActive)
+	assert.False(t, tool.DeletedAt.Valid)
+
+	// 移除的工具被软删除
+	tool = storedTool(t, "test-server_drop")
+	assert.True(t, tool.DeletedAt.Valid)
+	_, err = service.GetToolByKey("test-server_drop")
+	assert.ErrorIs(t, err, models.ErrMCPToolNotFound)
+
+	// 重新出现的工具恢复原记录
+	tool = storedTool(t, "test-server_back")
+	assert.Equal(t, back.ID, tool.ID)
+	assert.False(t, tool.DeletedAt.Valid)
+	assert.True(t, tool.IsActive)
+	assert.Equal(t, "重新出现", tool.Description)
+
+	// 其他服务器留下的记录归属当前服务器
+	tool = storedTool(t, "test-server_moved")
+	assert.Equal(t, moved.ID, tool.ID)
+	assert.Equal(t, server.ID, tool.ServerID)
+	assert.True(t, tool.IsActive)
+
+	tool = storedTool(t, "test-server_fresh")
+	assert.True(t, tool.IsActive)
+	assert.Equal(t, "新工具", tool.Description)
+
+	tools, err := service.GetToolsByServerID(server.ID)
+	require.NoError(t, err)
+	assert.Len(t, tools, 5)
+
+	// 再次同步相同的列表没有变化
+	report, err = service.applyServerTools(ctx, server, toolListing(server, map[string]string{
+		"keep":     "保持不变",
+		"describe": "新描述",
+		"disabled": "已被用户停用",
+		"back":     "重新出现",
+		"moved":    "换了服务器",
+		"fresh":    "新工具",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, &SyncReport{Added: []string{}, Updated: []string{}, Removed: []string{}, Unchanged: 6}, report)
+}
+
+func TestMCPToolService_ApplyServerToolsBatches(t *testing.T) {
+	setupMCPToolTestDB(t)
+	defer teardownMCPToolTestDB(t)
+
+	service := NewMCPToolService()
+	server := createTestMCPServer(t)
+
+	descriptions := make(map[string]string)
+	for i := 0; i < 250; i++ {
+		descriptions[fmt.Sprintf("tool%03d", i)] = fmt.Sprintf("工具%d", i)
+	}
+
+	// 每批写入后报告进度，每批不超过toolSyncBatchSize个变化
+	var progress [][2]int
+	ctx := WithSyncProgress(context.Background(), func(applied, total int) {
+		progress = append(progress, [2]int{applied, total})
+	})
+	report, err := service.applyServerTools(ctx, server, toolListing(server, descriptions))
+	require.NoError(t, err)
+	assert.Len(t, report.Added, 250)
+
+	require.GreaterOrEqual(t, len(progress), 3)
+	previous := 0
+	for _, p := range progress {
+		assert.Equal(t, 250, p[1])
+		assert.Greater(t, p[0], previous)
+		assert.LessOrEqual(t, p[0]-previous, toolSyncBatchSize)
+		previous = p[0]
+	}
+	assert.Equal(t, 250, previous)
+
+	tools, err := service.GetToolsByServerID(server.ID)
+	require.NoError(t, err)
+	assert.Len(t, tools, 250)
+
+	// 没有变化时不写入工具，也不报告进度
+	progress = nil
+	report, err = service.applyServerTools(ctx, server, toolListing(server, descriptions))
+	require.NoError(t, err)
+	assert.Equal(t, 250, report.Unchanged)
+	assert.Empty(t, progress)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultUsageQueueSize is the number of pending usage records buffered by ToolUsageRecorder
+	defaultUsageQueueSize = 1024
+)
+
+// ToolUsageService provides business logic for tool usage statistics
+type ToolUsageService struct {
+	db *gorm.DB
+}
+
+// NewToolUsageService creates a new tool usage service instance
+func NewToolUsageService() *ToolUsageService {
+	return &ToolUsageService{
+		db: database.GetDB(),
+	}
+}
+
+// RecordUsage increments the counters of a tool atomically, creating the row if needed
+func (s *ToolUsageService) RecordUsage(toolKey string, duration time.Duration, failed bool) error {
+	now := time.Now()
+	var errorCount int64
+	if failed {
+		errorCount = 1
+	}
+
+	usage := &models.ToolUsageModel{
+		ToolKey:         toolKey,
+		Calls:           1,
+		Errors:          errorCount,
+		TotalDurationMs: duration.Milliseconds(),
+		LastUsedAt:      &now,
+	}
+
+	// 使用 ON CONFLICT 在数据库中原子递增，避免读-改-写丢失更新
+	return database.RetryOnBusy(func() error {
+		return s.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "tool_key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"calls":             gorm.Expr("calls + 1"),
+				"errors":            gorm.Expr("errors + ?", errorCount),
+				"total_duration_ms": gorm.Expr("total_duration_ms + ?", duration.Milliseconds()),
+				"last_used_at":      now,
+				"updated_at":        now,
+			}),
+		}).Create(usage).Error
+	})
+}
+
+// GetUsage returns the usage statistics of a tool, nil if it has never been used
+func (s *ToolUsageService) GetUsage(toolKey string) (*models.ToolUsageModel, error) {
+	var usage models.ToolUsageModel
+	err := s.db.Where("tool_key = ?", toolKey).First(&usage).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// GetUsageMap returns the usage statistics of all tools keyed by tool_key
+func (s *ToolUsageService) GetUsageMap() (map[string]models.ToolUsageModel, error) {
+	var usages []models.ToolUsageModel
+	if err := s.db.Find(&usages).Error; err != nil {
+		return nil, err
+	}
+
+	usageMap := make(map[string]models.ToolUsageModel, len(usages))
+	for _, usage := range usages {
+		usageMap[usage.ToolKey] = usage
+	}
+	return usageMap, nil
+}
+
+// TopByCalls returns the limit most called tools
+func (s *ToolUsageService) TopByCalls(limit int) ([]models.ToolUsageModel, error) {
+	var usages []models.ToolUsageModel
+	err := s.db.Order("calls DESC, last_used_at DESC").Limit(limit).Find(&usages).Error
+	return usages, err
+}
+
+// TopByErrorRate returns the limit tools with the highest error rate.
+// Tools with fewer than minCalls calls are ignored to avoid noisy rates.
+func (s *ToolUsageService) TopByErrorRate(limit int, minCalls int64) ([]models.ToolUsageModel, error) {
+	var usages []models.ToolUsageModel
+	err := s.db.Where("calls >= ? AND errors > 0", minCalls).
+		Order("CAST(errors AS REAL) / calls DESC, calls DESC").
+		Limit(limit).Find(&usages).Error
+	return usages, err
+}
+
+// toolUsageRecord is a single pending usage update
+type toolUsageRecord struct {
+	toolKey  string
+	duration time.Duration
+	failed   bool
+}
+
+// ToolUsageRecorder writes tool usage asynchronously so that recording never
+// slows down the agent. A single worker applies the updates in order.
+type ToolUsageRecorder struct {
+	service *ToolUsageService
+	queue   chan toolUsageRecord
+	done    chan struct{}
+	mutex   sync.RWMutex
+	closed  bool
+}
+
+// NewToolUsageRecorder creates a recorder and starts its worker
+func NewToolUsageRecorder(service *ToolUsageService) *ToolUsageRecorder {
+	r := &ToolUsageRecorder{
+		service: service,
+		queue:   make(chan toolUsageRecord, defaultUsageQueueSize),
+		done:    make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues a usage update. It never blocks; updates are dropped and logged when the queue is full.
+func (r *ToolUsageRecorder) Record(toolKey string, duration time.Duration, failed bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.closed {
+		return
+	}
+
+	select {
+	case r.queue <- toolUsageRecord{toolKey: toolKey, duration: duration, failed: failed}:
+	default:
+		log.Printf("工具使用统计队列已满，丢弃记录: %s", toolKey)
+	}
+}
+
+// Close stops accepting records and waits until all queued records are written
+func (r *ToolUsageRecorder) Close() {
+	r.mutex.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mutex.Unlock()
+	<-r.done
+}
+
+// run applies queued updates until the queue is closed
+func (r *ToolUsageRecorder) run() {
+	defer close(r.done)
+	for record := range r.queue {
+		if err := r.service.RecordUsage(record.toolKey, record.duration, record.failed); err != nil {
+			log.Printf("记录工具使用统计失败 %s: %v", record.toolKey, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolUsageService_RecordUsage(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewToolUsageService()
+
+	usage, err := service.GetUsage("fetch_fetch")
+	require.NoError(t, err)
+	assert.Nil(t, usage)
+
+	require.NoError(t, service.RecordUsage("fetch_fetch", 100*time.Millisecond, false))
+	require.NoError(t, service.RecordUsage("fetch_fetch", 300*time.Millisecond, true))
+
+	usage, err = service.GetUsage("fetch_fetch")
+	require.NoError(t, err)
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(2), usage.Calls)
+	assert.Equal(t, int64(1), usage.Errors)
+	assert.Equal(t, int64(400), usage.TotalDurationMs)
+	assert.Equal(t, int64(200), usage.AvgDurationMs())
+	assert.InDelta(t, 0.5, usage.ErrorRate(), 0.0001)
+	assert.NotNil(t, usage.LastUsedAt)
+}
+
+func TestToolUsageRecorder_ConcurrentRecords(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewToolUsageService()
+	recorder := NewToolUsageRecorder(service)
+
+	const workers = 20
+	const perWorker = 25
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				recorder.Record("inner_search", time.Millisecond, j%5 == 0)
+			}
+		}(i)
+	}
+	wg.Wait()
+	recorder.Close()
+
+	usage, err := service.GetUsage("inner_search")
+	require.NoError(t, err)
+	require.NotNil(t, usage)
+	// 所有并发记录都不应丢失
+	assert.Equal(t, int64(workers*perWorker), usage.Calls)
+	assert.Equal(t, int64(workers*perWorker/5), usage.Errors)
+
+	// 关闭后记录被忽略而不是panic
+	recorder.Record("inner_search", time.Millisecond, false)
+}
+
+func TestToolUsageService_TopLists(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB(t)
+
+	service := NewToolUsageService()
+	record := func(key string, calls, errors int) {
+		for i := 0; i < calls; i++ {
+			require.NoError(t, service.RecordUsage(key, time.Millisecond, i < errors))
+		}
+	}
+	record("a_popular", 10, 1)
+	record("b_flaky", 4, 3)
+	record("c_rare", 1, 1)
+
+	top, err := service.TopByCalls(2)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	assert.Equal(t, "a_popular", top[0].ToolKey)
+	assert.Equal(t, "b_flaky", top[1].ToolKey)
+
+	// c_rare 调用次数不足，不参与错误率排名
+	flaky, err := service.TopByErrorRate(10, 3)
+	require.NoError(t, err)
+	require.Len(t, flaky, 2)
+	assert.Equal(t, "b_flaky", flaky[0].ToolKey)
+	assert.Equal(t, "a_popular", flaky[1].ToolKey)
+
+	usageMap, err := service.GetUsageMap()
+	require.NoError(t, err)
+	assert.Len(t, usageMap, 3)
+}
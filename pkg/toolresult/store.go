@@ -0,0 +1,153 @@
+// Package toolresult keeps the full text of long tool results of a task, so
+// the conversation can carry a summary while the agent reads the original
+// text on demand through the expand_result tool.
+package toolresult
+
+import (
+	"fmt"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxResultSize is the number of bytes kept per result
+	DefaultMaxResultSize = 1 << 20
+
+	// DefaultMaxTotalSize is the number of bytes kept per task, the oldest results are dropped first
+	DefaultMaxTotalSize = 8 << 20
+
+	// resultIDPrefix prefixes the sequential IDs of stored results
+	resultIDPrefix = "result_"
+
+	errMsgResultNotFound = "结果 %s 不存在或已被清理"
+)
+
+// Result is a tool result kept by a Store
+type Result struct {
+	ID       string // 结果ID，在任务内唯一
+	ToolName string // 产生结果的工具
+	Text     string // 完整内容，超过单个结果上限时已截断
+	Length   int    // Text的字符数
+}
+
+// Store keeps tool results of one task in memory. It is safe for concurrent use,
+// since tools may run in parallel.
+type Store struct {
+	maxResultSize int
+	maxTotalSize  int
+
+	mu        sync.Mutex
+	nextID    int
+	totalSize int
+	order     []string // 按保存顺序排列的ID，用于淘汰最早的结果
+	results   map[string]*Result
+}
+
+// NewStore creates an empty store.
+//
+// Parameters:
+//   - maxResultSize: Maximum bytes kept per result, DefaultMaxResultSize if <= 0
+//   - maxTotalSize: Maximum bytes kept in total, DefaultMaxTotalSize if <= 0
+//
+// Returns:
+//   - *Store: Empty store
+func NewStore(maxResultSize, maxTotalSize int) *Store {
+	if maxResultSize <= 0 {
+		maxResultSize = DefaultMaxResultSize
+	}
+	if maxTotalSize <= 0 {
+		maxTotalSize = DefaultMaxTotalSize
+	}
+	if maxResultSize > maxTotalSize {
+		maxResultSize = maxTotalSize
+	}
+	return &Store{
+		maxResultSize: maxResultSize,
+		maxTotalSize:  maxTotalSize,
+		results:       make(map[string]*Result),
+	}
+}
+
+// Save stores text and returns the stored result. Text longer than the result
+// size cap is cut at a character boundary; the oldest results are dropped when
+// the total size cap is exceeded.
+//
+// Parameters:
+//   - toolName: Name of the tool that produced text
+//   - text: Full tool result
+//
+// Returns:
+//   - *Result: Stored result with its ID
+func (s *Store) Save(toolName, text string) *Result {
+	if len(text) > s.maxResultSize {
+		text = cutBytes(text, s.maxResultSize)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	result := &Result{
+		ID:       fmt.Sprintf("%s%d", resultIDPrefix, s.nextID),
+		ToolName: toolName,
+		Text:     text,
+		Length:   utf8.RuneCountInString(text),
+	}
+	for len(s.order) > 0 && s.totalSize+len(text) > s.maxTotalSize {
+		oldest := s.results[s.order[0]]
+		s.totalSize -= len(oldest.Text)
+		delete(s.results, oldest.ID)
+		s.order = s.order[1:]
+	}
+	s.results[result.ID] = result
+	s.order = append(s.order, result.ID)
+	s.totalSize += len(text)
+	return result
+}
+
+// Get returns a stored result
+func (s *Store) Get(id string) (*Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[id]
+	return result, ok
+}
+
+// Expand returns length characters of a stored result starting at character start.
+//
+// Parameters:
+//   - id: ID of the result
+//   - start: Character offset, negative values are treated as 0
+//   - length: Number of characters to return
+//
+// Returns:
+//   - string: Requested part of the result, empty if start is past the end
+//   - int: Total number of characters of the result
+//   - error: Error if the result does not exist or was dropped
+func (s *Store) Expand(id string, start, length int) (string, int, error) {
+	result, ok := s.Get(id)
+	if !ok {
+		return "", 0, fmt.Errorf(errMsgResultNotFound, id)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start >= result.Length || length <= 0 {
+		return "", result.Length, nil
+	}
+
+	runes := []rune(result.Text)
+	end := start + length
+	if end > len(runes) {
+		end = len(runes)
+	}
+	return string(runes[start:end]), result.Length, nil
+}
+
+// cutBytes cuts s to at most n bytes without splitting a UTF-8 character
+func cutBytes(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,75 @@
+package toolresult
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreSaveAndExpand(t *testing.T) {
+	store := NewStore(0, 0)
+	result := store.Save("scan", "你好，世界！hello")
+	assert.Equal(t, "result_1", result.ID)
+	assert.Equal(t, 11, result.Length)
+
+	text, total, err := store.Expand(result.ID, 3, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "世界！", text)
+	assert.Equal(t, 11, total)
+
+	// 超出范围时截断到末尾
+	text, _, err = store.Expand(result.ID, 8, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "llo", text)
+	text, _, err = store.Expand(result.ID, 20, 5)
+	require.NoError(t, err)
+	assert.Empty(t, text)
+
+	_, _, err = store.Expand("result_9", 0, 10)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "不存在或已被清理")
+}
+
+func TestStoreSizeCaps(t *testing.T) {
+	store := NewStore(6, 10)
+
+	// 单个结果超过上限时按字符边界截断
+	first := store.Save("a", "你好世界")
+	assert.Equal(t, "你好", first.Text)
+
+	// 总大小超过上限时淘汰最早的结果
+	second := store.Save("b", "abcd")
+	third := store.Save("c", "efgh")
+	_, ok := store.Get(first.ID)
+	assert.False(t, ok)
+	_, ok = store.Get(second.ID)
+	assert.True(t, ok)
+	_, ok = store.Get(third.ID)
+	assert.True(t, ok)
+}
+
+func TestExpandTool(t *testing.T) {
+	store := NewStore(0, 0)
+	result := store.Save("scan", strings.Repeat("x", DefaultExpandLength+10))
+	expand := NewExpandTool(store)
+
+	info, err := expand.Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, ExpandToolName, info.Name)
+
+	text, err := expand.InvokableRun(context.Background(), `{"result_id":"`+result.ID+`"}`)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", DefaultExpandLength)+"\n...[还有 10 个字符，可以使用 start=2000 继续读取]", text)
+
+	text, err = expand.InvokableRun(context.Background(), `{"result_id":"`+result.ID+`","start":2005}`)
+	require.NoError(t, err)
+	assert.Equal(t, "xxxxx", text)
+
+	_, err = expand.InvokableRun(context.Background(), `{"start":1}`)
+	require.Error(t, err)
+	_, err = expand.InvokableRun(context.Background(), `not json`)
+	require.Error(t, err)
+}
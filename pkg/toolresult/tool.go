@@ -0,0 +1,104 @@
+package toolresult
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// ExpandToolName is the name of the inner tool that reads stored tool results
+	ExpandToolName = "expand_result"
+
+	// DefaultExpandLength is the number of characters returned when length is not given
+	DefaultExpandLength = 2000
+
+	// MaxExpandLength is the maximum number of characters returned per call
+	MaxExpandLength = 8000
+
+	// remainingNotice is appended when the returned part does not reach the end of the result
+	remainingNotice = "\n...[还有 %d 个字符，可以使用 start=%d 继续读取]"
+)
+
+// expandToolArgs holds the arguments of expand_result
+type expandToolArgs struct {
+	ResultID string `json:"result_id"`
+	Start    int    `json:"start"`
+	Length   int    `json:"length"`
+}
+
+// expandTool implements tool.InvokableTool for reading results of one Store
+type expandTool struct {
+	store *Store
+}
+
+// NewExpandTool creates the expand_result tool reading results of store.
+//
+// Parameters:
+//   - store: Result store of the current task
+//
+// Returns:
+//   - tool.InvokableTool: Tool ready to be passed to the agent
+func NewExpandTool(store *Store) tool.InvokableTool {
+	return &expandTool{store: store}
+}
+
+// Info implements tool.BaseTool
+func (t *expandTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: ExpandToolName,
+		Desc: fmt.Sprintf("read the full text of a long tool result that was replaced by a summary, at most %d characters are returned per call", MaxExpandLength),
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"result_id": {
+				Type:     schema.String,
+				Desc:     "result id given with the summary",
+				Required: true,
+			},
+			"start": {
+				Type: schema.Integer,
+				Desc: "character offset to start reading from, default 0",
+			},
+			"length": {
+				Type: schema.Integer,
+				Desc: fmt.Sprintf("number of characters to read, default %d", DefaultExpandLength),
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun implements tool.InvokableTool
+func (t *expandTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args expandToolArgs
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+	if strings.TrimSpace(args.ResultID) == "" {
+		return "", errors.New("result_id不能为空")
+	}
+
+	length := args.Length
+	if length <= 0 {
+		length = DefaultExpandLength
+	}
+	if length > MaxExpandLength {
+		length = MaxExpandLength
+	}
+
+	text, total, err := t.store.Expand(strings.TrimSpace(args.ResultID), args.Start, length)
+	if err != nil {
+		return "", err
+	}
+	start := args.Start
+	if start < 0 {
+		start = 0
+	}
+	if end := start + len([]rune(text)); end < total {
+		text += fmt.Sprintf(remainingNotice, total-end, end)
+	}
+	return text, nil
+}
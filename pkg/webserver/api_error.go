@@ -0,0 +1,173 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+)
+
+// Error codes of the standard error envelope
+const (
+	errCodeValidationFailed = "validation_failed"
+	errCodeBadRequest       = "bad_request"
+	errCodeNotFound         = "not_found"
+	errCodeConflict         = "conflict"
+	errCodeTooLarge         = "too_large"
+	errCodeUnavailable      = "unavailable"
+	errCodeNotImplemented   = "not_implemented"
+	errCodeConnectionFailed = "connection_failed"
+	errCodeInternal         = "internal_error"
+)
+
+// APIError is the error of the standard error envelope returned by all /api handlers
+type APIError struct {
+	Code    string              `json:"code"`             // 机器可读的错误码
+	Message string              `json:"message"`          // 面向用户的错误描述
+	Fields  []config.FieldError `json:"fields,omitempty"` // 验证失败的字段
+}
+
+// ErrorResponse is the standard error envelope:
+//
+//	{"success":false,"error":{"code":"validation_failed","fields":[{"field":"llm.base_url","message":"..."}]}}
+type ErrorResponse struct {
+	Success bool      `json:"success"`
+	Error   *APIError `json:"error"`
+}
+
+// modelError maps an error of the model layer to the response it produces
+type modelError struct {
+	err    error
+	status int
+	code   string
+	field  string // 对应的请求字段，仅用于验证错误
+}
+
+// modelErrors lists the model layer errors with a dedicated error code
+var modelErrors = []modelError{
+	{models.ErrLLMConfigNotFound, http.StatusNotFound, "llm_config_not_found", ""},
+	{models.ErrLLMConfigNameExists, http.StatusConflict, "llm_config_name_exists", "name"},
+	{models.ErrLLMConfigNameEmpty, http.StatusBadRequest, errCodeValidationFailed, "name"},
+	{models.ErrLLMConfigTypeEmpty, http.StatusBadRequest, errCodeValidationFailed, "type"},
+	{models.ErrLLMConfigTypeInvalid, http.StatusBadRequest, errCodeValidationFailed, "type"},
+	{models.ErrLLMConfigBaseURLEmpty, http.StatusBadRequest, errCodeValidationFailed, "base_url"},
+	{models.ErrLLMConfigModelEmpty, http.StatusBadRequest, errCodeValidationFailed, "model"},
+	{models.ErrLLMConfigAPIKeyEmpty, http.StatusBadRequest, errCodeValidationFailed, "api_key"},
+	{models.ErrLLMConfigDefaultMaxStepInvalid, http.StatusBadRequest, errCodeValidationFailed, "default_max_step"},
+	{models.ErrLLMConfigDefaultPromptNotFound, http.StatusBadRequest, errCodeValidationFailed, "default_system_prompt_id"},
+
+	{models.ErrMCPServerConfigNotFound, http.StatusNotFound, "mcp_server_config_not_found", ""},
+	{models.ErrMCPServerConfigNameExists, http.StatusConflict, "mcp_server_config_name_exists", "name"},
+	{models.ErrMCPServerConfigNameEmpty, http.StatusBadRequest, errCodeValidationFailed, "name"},
+	{models.ErrMCPServerConfigCommandEmpty, http.StatusBadRequest, errCodeValidationFailed, "command"},
+	{models.ErrMCPServerConfigURLEmpty, http.StatusBadRequest, errCodeValidationFailed, "url"},
+	{models.ErrMCPServerConfigInvalidTransportType, http.StatusBadRequest, errCodeValidationFailed, "transport_type"},
+	{models.ErrMCPServerConfigNamePrefixInvalid, http.StatusBadRequest, errCodeValidationFailed, "name_prefix"},
+	{models.ErrMCPServerConfigHeaderInvalid, http.StatusBadRequest, errCodeValidationFailed, "headers"},
+	{models.ErrMCPServerConfigHeaderRedacted, http.StatusBadRequest, errCodeValidationFailed, "headers"},
+	{models.ErrMCPServerConfigEnvRedacted, http.StatusBadRequest, errCodeValidationFailed, "env"},
+	{models.ErrMCPServerConfigTimeoutTooSmall, http.StatusBadRequest, errCodeValidationFailed, "timeout"},
+	{models.ErrMCPServerConfigTimeoutNegative, http.StatusBadRequest, errCodeValidationFailed, "timeout"},
+
+	{models.ErrMCPToolNotFound, http.StatusNotFound, "mcp_tool_not_found", ""},
+	{models.ErrMCPToolResultFilterInvalid, http.StatusBadRequest, errCodeValidationFailed, "result_filter"},
+	{models.ErrMCPToolOutputFormatInvalid, http.StatusBadRequest, errCodeValidationFailed, "output_format"},
+	{models.ErrMCPToolDescriptionLanguageEmpty, http.StatusBadRequest, errCodeValidationFailed, "descriptions"},
+
+	{models.ErrSystemPromptNotFound, http.StatusNotFound, "system_prompt_not_found", ""},
+	{models.ErrSystemPromptNameExists, http.StatusConflict, "system_prompt_name_exists", "name"},
+	{models.ErrSystemPromptNameEmpty, http.StatusBadRequest, errCodeValidationFailed, "name"},
+	{models.ErrSystemPromptContentEmpty, http.StatusBadRequest, errCodeValidationFailed, "content"},
+
+	{models.ErrAppConfigNotFound, http.StatusNotFound, "app_config_not_found", ""},
+	{models.ErrAppConfigNameExists, http.StatusConflict, "app_config_name_exists", "name"},
+	{models.ErrAppConfigNameEmpty, http.StatusBadRequest, errCodeValidationFailed, "name"},
+	{models.ErrAppConfigMaxStepInvalid, http.StatusBadRequest, errCodeValidationFailed, "max_step"},
+	{models.ErrAppConfigGuardPromptTooLong, http.StatusBadRequest, errCodeValidationFailed, "guard_prompt"},
+
+	{models.ErrTaskTemplateNotFound, http.StatusNotFound, "task_template_not_found", ""},
+	{models.ErrTaskTemplateNameExists, http.StatusConflict, "task_template_name_exists", "name"},
+	{models.ErrTaskTemplateNameEmpty, http.StatusBadRequest, errCodeValidationFailed, "name"},
+	{models.ErrTaskTemplateContentEmpty, http.StatusBadRequest, errCodeValidationFailed, "template"},
+	{models.ErrTaskTemplateParamInvalid, http.StatusBadRequest, errCodeValidationFailed, "parameters"},
+	{models.ErrTaskTemplateToolsInvalid, http.StatusBadRequest, errCodeValidationFailed, "tools"},
+	{models.ErrTaskTemplateParamsInvalid, http.StatusBadRequest, errCodeValidationFailed, "params"},
+
+	{models.ErrCredentialNotFound, http.StatusNotFound, "credential_not_found", ""},
+	{models.ErrCredentialNameExists, http.StatusConflict, "credential_name_exists", "name"},
+	{models.ErrCredentialNameEmpty, http.StatusBadRequest, errCodeValidationFailed, "name"},
+	{models.ErrCredentialNameInvalid, http.StatusBadRequest, errCodeValidationFailed, "name"},
+	{models.ErrCredentialValueEmpty, http.StatusBadRequest, errCodeValidationFailed, "value"},
+
+	{models.ErrArtifactNotFound, http.StatusNotFound, "artifact_not_found", ""},
+	{models.ErrTaskHistoryNotFound, http.StatusNotFound, "task_history_not_found", ""},
+	{models.ErrTaskHistoryRunning, http.StatusConflict, "task_history_running", ""},
+
+	{models.ErrListSortInvalid, http.StatusBadRequest, errCodeValidationFailed, "sort"},
+}
+
+// errorCodeForStatus returns the generic error code of an HTTP status
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return errCodeBadRequest
+	case http.StatusNotFound:
+		return errCodeNotFound
+	case http.StatusConflict:
+		return errCodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return errCodeTooLarge
+	case http.StatusServiceUnavailable:
+		return errCodeUnavailable
+	case http.StatusNotImplemented:
+		return errCodeNotImplemented
+	case http.StatusUnprocessableEntity:
+		return errCodeConnectionFailed
+	}
+	if status >= http.StatusInternalServerError {
+		return errCodeInternal
+	}
+	return errCodeBadRequest
+}
+
+// writeAPIError writes apiErr in the standard error envelope
+func writeAPIError(w http.ResponseWriter, status int, apiErr *APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Success: false, Error: apiErr})
+}
+
+// writeError replaces http.Error for /api handlers, the error code is derived from status
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeAPIError(w, status, &APIError{Code: errorCodeForStatus(status), Message: message})
+}
+
+// writeValidationError writes a validation_failed error listing every invalid field
+func writeValidationError(w http.ResponseWriter, message string, fields config.FieldErrors) {
+	writeAPIError(w, http.StatusBadRequest, &APIError{
+		Code:    errCodeValidationFailed,
+		Message: message,
+		Fields:  fields,
+	})
+}
+
+// writeModelError writes an error returned by the model or service layer.
+// Known model errors get their own status and code, and validation errors
+// name the invalid field. Other errors are written with message and status.
+func writeModelError(w http.ResponseWriter, err error, message string, status int) {
+	for _, known := range modelErrors {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+		apiErr := &APIError{Code: known.code, Message: err.Error()}
+		if known.code == errCodeValidationFailed {
+			apiErr.Fields = []config.FieldError{{Field: known.field, Message: known.err.Error()}}
+		}
+		writeAPIError(w, known.status, apiErr)
+		return
+	}
+	writeError(w, message, status)
+}
@@ -0,0 +1,119 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeErrorResponse 校验响应是标准错误格式并返回其中的错误
+func decodeErrorResponse(t *testing.T, w *httptest.ResponseRecorder, status int) *APIError {
+	t.Helper()
+	assert.Equal(t, status, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	assert.False(t, resp.Success)
+	require.NotNil(t, resp.Error)
+	assert.NotEmpty(t, resp.Error.Message)
+	return resp.Error
+}
+
+// fieldNames 返回错误中的字段路径
+func fieldNames(apiErr *APIError) []string {
+	names := make([]string, 0, len(apiErr.Fields))
+	for _, field := range apiErr.Fields {
+		names = append(names, field.Field)
+	}
+	return names
+}
+
+func TestErrorEnvelopeInvalidJSON(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/task", strings.NewReader("{")))
+	apiErr := decodeErrorResponse(t, w, http.StatusBadRequest)
+	assert.Equal(t, "bad_request", apiErr.Code)
+	assert.Equal(t, "解析任务数据失败", apiErr.Message)
+	assert.Empty(t, apiErr.Fields)
+}
+
+func TestErrorEnvelopeTaskConfigFields(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	// 完整配置中的所有无效字段都会返回，而不只是第一个
+	w := postJSON(t, server, "/api/task", map[string]any{
+		"task": "测试任务",
+		"config": map[string]any{
+			"mcp":          map[string]any{"merge_strategy": "unknown"},
+			"llm":          map[string]any{"type": "openai"},
+			"max_step":     0,
+			"language":     "fr",
+			"task_timeout": -1,
+		},
+	})
+	apiErr := decodeErrorResponse(t, w, http.StatusBadRequest)
+	assert.Equal(t, "validation_failed", apiErr.Code)
+	assert.Contains(t, apiErr.Message, "配置验证失败")
+	assert.Equal(t, []string{"mcp.merge_strategy", "llm.base_url", "llm.model", "max_step", "language", "task_timeout"}, fieldNames(apiErr))
+	assert.Equal(t, "LLM BaseURL不能为空", apiErr.Fields[1].Message)
+}
+
+func TestErrorEnvelopeTaskTools(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	w := postJSON(t, server, "/api/task", map[string]any{
+		"task":  "测试任务",
+		"tools": []map[string]string{{"server": "inner", "name": "search"}, {"server": "fetch", "name": ""}, {"server": "a", "name": "b:c"}},
+	})
+	apiErr := decodeErrorResponse(t, w, http.StatusBadRequest)
+	assert.Equal(t, "validation_failed", apiErr.Code)
+	assert.Equal(t, []string{"tools[1]", "tools[2]"}, fieldNames(apiErr))
+}
+
+func TestErrorEnvelopeLLMTest(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	w := postJSON(t, server, "/api/llm/test", map[string]any{})
+	apiErr := decodeErrorResponse(t, w, http.StatusBadRequest)
+	assert.Equal(t, "validation_failed", apiErr.Code)
+	assert.Equal(t, []string{"type", "base_url", "model"}, fieldNames(apiErr))
+}
+
+func TestErrorEnvelopeModelErrors(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	llmConfig := map[string]any{"name": "网关", "type": "openai", "base_url": "https://llm.internal/v1", "model": "qwen", "api_key": "sk-test"}
+	w := postJSON(t, server, "/api/llm/configs", llmConfig)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 名称重复
+	w = postJSON(t, server, "/api/llm/configs", llmConfig)
+	apiErr := decodeErrorResponse(t, w, http.StatusConflict)
+	assert.Equal(t, "llm_config_name_exists", apiErr.Code)
+	assert.Equal(t, "LLM配置名称已存在", apiErr.Message)
+
+	// 模型层的验证错误带有字段
+	w = postJSON(t, server, "/api/llm/configs", map[string]any{"type": "openai"})
+	apiErr = decodeErrorResponse(t, w, http.StatusBadRequest)
+	assert.Equal(t, "validation_failed", apiErr.Code)
+	assert.Equal(t, []string{"name"}, fieldNames(apiErr))
+
+	// 不存在的配置
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/llm/configs/9999", nil))
+	apiErr = decodeErrorResponse(t, w, http.StatusNotFound)
+	assert.Equal(t, "llm_config_not_found", apiErr.Code)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/system-prompts/9999", nil))
+	apiErr = decodeErrorResponse(t, w, http.StatusNotFound)
+	assert.Equal(t, "system_prompt_not_found", apiErr.Code)
+}
@@ -0,0 +1,173 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/artifact"
+	"github.com/LubyRuffy/mcpagent/pkg/attachment"
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/gorilla/mux"
+)
+
+// ArtifactInfo describes a tool artifact in API responses
+type ArtifactInfo struct {
+	ID        string    `json:"id"`
+	ToolName  string    `json:"tool_name,omitempty"`
+	MimeType  string    `json:"mime_type,omitempty"`
+	Size      int64     `json:"size"`
+	URL       string    `json:"url"` // 下载地址
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// artifactURL returns the download URL of an artifact
+func artifactURL(taskID, id string) string {
+	return fmt.Sprintf("/api/task/%s/artifacts/%s", taskID, id)
+}
+
+// taskArtifactDir returns the artifact directory of a task
+func (s *Server) taskArtifactDir(taskID string) string {
+	return filepath.Join(s.artifactDir, taskID)
+}
+
+// newArtifactStore creates the artifact store of a task.
+// Metadata is recorded in the database when one is available.
+func (s *Server) newArtifactStore(taskID string, cfg *config.ArtifactConfig) *artifact.Store {
+	var recorder artifact.Recorder
+	if s.db != nil {
+		recorder = s.artifactService
+	}
+	return artifact.NewStore(s.taskArtifactDir(taskID), taskID, cfg.MaxSize, recorder)
+}
+
+// scheduleArtifactCleanup removes the artifacts of a task and their metadata after retention
+func (s *Server) scheduleArtifactCleanup(taskID string, retention time.Duration) {
+	time.AfterFunc(retention, func() {
+		if err := os.RemoveAll(s.taskArtifactDir(taskID)); err != nil {
+			log.Printf("清理产物目录失败 %s: %v", taskID, err)
+		}
+		if s.db != nil {
+			if err := s.artifactService.DeleteTaskArtifacts(taskID); err != nil {
+				log.Printf("删除任务 %s 的产物记录失败: %v", taskID, err)
+			}
+		}
+	})
+}
+
+// handleListArtifacts handles GET /api/task/{taskId}/artifacts
+// It lists the artifacts of a task with their download URLs.
+func (s *Server) handleListArtifacts(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["taskId"]
+	if err := attachment.ValidateName(taskID); err != nil {
+		writeError(w, "任务ID无效", http.StatusBadRequest)
+		return
+	}
+
+	items := []ArtifactInfo{}
+	if s.db != nil {
+		records, err := s.artifactService.ListTaskArtifacts(taskID)
+		if err != nil {
+			writeError(w, fmt.Sprintf("获取产物列表失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for _, record := range records {
+			items = append(items, ArtifactInfo{
+				ID:        record.ID,
+				ToolName:  record.ToolName,
+				MimeType:  record.MimeType,
+				Size:      record.Size,
+				URL:       artifactURL(taskID, record.ID),
+				CreatedAt: record.CreatedAt,
+			})
+		}
+	} else {
+		// 没有数据库时直接列出产物目录
+		entries, err := os.ReadDir(s.taskArtifactDir(taskID))
+		if err != nil && !os.IsNotExist(err) {
+			writeError(w, fmt.Sprintf("获取产物列表失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || artifact.ValidateID(entry.Name()) != nil {
+				continue
+			}
+			items = append(items, ArtifactInfo{
+				ID:        entry.Name(),
+				Size:      info.Size(),
+				URL:       artifactURL(taskID, entry.Name()),
+				CreatedAt: info.ModTime(),
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    items,
+	})
+}
+
+// handleGetArtifact handles GET /api/task/{taskId}/artifacts/{id}
+// It serves the content of an artifact with the MIME type reported by the tool.
+// Without a database the type is detected from the content.
+func (s *Server) handleGetArtifact(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID := vars["taskId"]
+	id := vars["id"]
+
+	if err := artifact.ValidateID(id); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := attachment.ValidateName(taskID); err != nil {
+		writeError(w, "任务ID无效", http.StatusBadRequest)
+		return
+	}
+
+	path, err := artifact.Path(s.taskArtifactDir(taskID), id)
+	if err != nil {
+		if errors.Is(err, artifact.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 优先使用数据库中记录的内容类型
+	mimeType := ""
+	if s.db != nil {
+		record, err := s.artifactService.GetArtifact(taskID, id)
+		if err != nil {
+			writeModelError(w, err, fmt.Sprintf("获取产物失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		mimeType = record.MimeType
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		writeError(w, fmt.Sprintf("读取产物失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, fmt.Sprintf("读取产物失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if mimeType != "" {
+		w.Header().Set("Content-Type", mimeType)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", id))
+	http.ServeContent(w, r, id, info.ModTime(), f)
+}
@@ -0,0 +1,79 @@
+package webserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/artifact"
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// imageToolCaller is a fake MCP client whose tool returns a PNG image
+type imageToolCaller struct {
+	data []byte
+}
+
+func (c *imageToolCaller) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return &mcp.CallToolResult{Content: []mcp.Content{
+		mcp.NewImageContent(base64.StdEncoding.EncodeToString(c.data), "image/png"),
+	}}, nil
+}
+
+// screenshotTool provides the tool info of the fake image tool
+type screenshotTool struct{}
+
+func (screenshotTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "screenshot"}, nil
+}
+
+func TestArtifactDownload(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.artifactDir = t.TempDir()
+
+	// 通过任务的产物存储调用返回图片的工具
+	pngData := []byte("\x89PNG\r\n\x1a\nfake image")
+	store := server.newArtifactStore("task_1", &config.ArtifactConfig{})
+	out, err := artifact.WrapTool(screenshotTool{}, &imageToolCaller{data: pngData}, "screenshot", store).
+		InvokableRun(context.Background(), `{}`)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(out, artifact.URIScheme), out)
+	id := strings.Fields(strings.TrimPrefix(out, artifact.URIScheme))[0]
+
+	// 下载产物
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/task/task_1/artifacts/"+id, nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Equal(t, pngData, rr.Body.Bytes())
+
+	// 产物列表包含下载地址
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/task/task_1/artifacts", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp struct {
+		Data []ArtifactInfo `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, "/api/task/task_1/artifacts/"+id, resp.Data[0].URL)
+	assert.Equal(t, "screenshot", resp.Data[0].ToolName)
+	assert.Equal(t, int64(len(pngData)), resp.Data[0].Size)
+
+	// 其他任务不能访问该产物，无效的ID被拒绝
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/task/task_2/artifacts/"+id, nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	server.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/task/task_1/artifacts/secret.txt", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,164 @@
+package webserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/attachment"
+)
+
+const (
+	// uploadRetention is how long uploaded attachments wait for a task to claim them
+	uploadRetention = time.Hour
+
+	// maxUploadMemory is the memory used for parsing multipart uploads before spilling to disk
+	maxUploadMemory = 32 << 20
+)
+
+// TaskAttachment describes a file attached to a task request.
+// Either ID (returned by POST /api/task/upload) or Name and Content must be set.
+type TaskAttachment struct {
+	ID      string `json:"id,omitempty"`      // 上传接口返回的附件ID
+	Name    string `json:"name,omitempty"`    // 附件名称
+	Content string `json:"content,omitempty"` // base64编码的附件内容
+}
+
+// uploadDir returns the directory of an upload batch
+func (s *Server) uploadDir(id string) string {
+	return filepath.Join(s.attachmentDir, "uploads", id)
+}
+
+// taskAttachmentDir returns the attachment directory of a task
+func (s *Server) taskAttachmentDir(taskID string) string {
+	return filepath.Join(s.attachmentDir, "tasks", taskID)
+}
+
+// handleUploadAttachment handles POST /api/task/upload
+// It accepts one or more multipart "file" fields and returns an attachment ID
+// that can be referenced from TaskRequest.Attachments.
+func (s *Server) handleUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, attachment.MaxFileSize*4)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		writeError(w, fmt.Sprintf("解析上传数据失败: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		writeError(w, "未找到上传的文件", http.StatusBadRequest)
+		return
+	}
+
+	id := fmt.Sprintf("upload_%d", time.Now().UnixNano())
+	dir := s.uploadDir(id)
+
+	var names []string
+	for _, fh := range files {
+		if err := attachment.ValidateName(fh.Filename); err != nil {
+			os.RemoveAll(dir)
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			os.RemoveAll(dir)
+			writeError(w, fmt.Sprintf("读取上传文件失败: %v", err), http.StatusBadRequest)
+			return
+		}
+		err = attachment.SaveFrom(dir, fh.Filename, f)
+		f.Close()
+		if err != nil {
+			os.RemoveAll(dir)
+			status := http.StatusInternalServerError
+			if errors.Is(err, attachment.ErrFileTooLarge) || errors.Is(err, attachment.ErrInvalidName) {
+				status = http.StatusBadRequest
+			}
+			writeError(w, err.Error(), status)
+			return
+		}
+		names = append(names, fh.Filename)
+	}
+
+	// 未被任务使用的上传文件在保留时间后清理
+	attachment.ScheduleCleanup(dir, uploadRetention)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "附件上传成功",
+		"data": map[string]interface{}{
+			"id":    id,
+			"files": names,
+		},
+	})
+}
+
+// prepareTaskAttachments stores the attachments of a task in its own directory.
+//
+// Parameters:
+//   - taskID: ID of the task the attachments belong to
+//   - attachments: Attachments from the task request
+//
+// Returns:
+//   - string: Task attachment directory, empty if the task has no attachments
+//   - error: Error if an attachment is invalid; nothing is left on disk in that case
+func (s *Server) prepareTaskAttachments(taskID string, attachments []TaskAttachment) (string, error) {
+	if len(attachments) == 0 {
+		return "", nil
+	}
+
+	dir := s.taskAttachmentDir(taskID)
+	if err := s.storeTaskAttachments(dir, attachments); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// storeTaskAttachments moves uploaded attachments and decodes inline ones into dir
+func (s *Server) storeTaskAttachments(dir string, attachments []TaskAttachment) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建附件目录失败: %w", err)
+	}
+
+	for _, att := range attachments {
+		if att.ID != "" {
+			if err := attachment.ValidateName(att.ID); err != nil {
+				return fmt.Errorf("附件ID无效: %s", att.ID)
+			}
+			uploadDir := s.uploadDir(att.ID)
+			names, err := attachment.List(uploadDir)
+			if err != nil {
+				return fmt.Errorf("读取上传附件失败: %w", err)
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("附件ID不存在: %s", att.ID)
+			}
+			for _, name := range names {
+				if err := os.Rename(filepath.Join(uploadDir, name), filepath.Join(dir, name)); err != nil {
+					return fmt.Errorf("移动上传附件失败: %w", err)
+				}
+			}
+			if err := os.Remove(uploadDir); err != nil {
+				log.Printf("删除上传目录失败 %s: %v", uploadDir, err)
+			}
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(att.Content)
+		if err != nil {
+			return fmt.Errorf("附件 %s 的内容不是有效的base64: %w", att.Name, err)
+		}
+		if err := attachment.Save(dir, att.Name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
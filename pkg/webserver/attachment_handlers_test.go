@@ -0,0 +1,118 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/attachment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUploadRequest 构造multipart上传请求
+func newUploadRequest(t *testing.T, files map[string]string) *http.Request {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for name, content := range files {
+		part, err := writer.CreateFormFile("file", name)
+		require.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/api/task/upload", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestHandleUploadAttachment(t *testing.T) {
+	server := NewServer(":8080")
+	server.attachmentDir = t.TempDir()
+
+	w := httptest.NewRecorder()
+	server.handleUploadAttachment(w, newUploadRequest(t, map[string]string{"targets.txt": "1.1.1.1"}))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			ID    string   `json:"id"`
+			Files []string `json:"files"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, []string{"targets.txt"}, resp.Data.Files)
+
+	// 任务引用上传ID时附件移动到任务目录
+	dir, err := server.prepareTaskAttachments("task_1", []TaskAttachment{{ID: resp.Data.ID}})
+	require.NoError(t, err)
+	content, err := attachment.Read(dir, "targets.txt", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1", content)
+	_, err = os.Stat(server.uploadDir(resp.Data.ID))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHandleUploadAttachmentRejectsTraversal(t *testing.T) {
+	server := NewServer(":8080")
+	server.attachmentDir = t.TempDir()
+
+	w := httptest.NewRecorder()
+	server.handleUploadAttachment(w, newUploadRequest(t, map[string]string{"..": "x"}))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// multipart会把带路径的文件名截断为基础名称，文件只能落在上传目录内
+	w = httptest.NewRecorder()
+	server.handleUploadAttachment(w, newUploadRequest(t, map[string]string{"../../evil.txt": "x"}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err := os.Stat(filepath.Join(filepath.Dir(server.attachmentDir), "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(server.attachmentDir, "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPrepareTaskAttachmentsInline(t *testing.T) {
+	server := NewServer(":8080")
+	server.attachmentDir = t.TempDir()
+
+	dir, err := server.prepareTaskAttachments("task_2", []TaskAttachment{{
+		Name:    "log.txt",
+		Content: base64.StdEncoding.EncodeToString([]byte("error at line 1")),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, server.taskAttachmentDir("task_2"), dir)
+	assert.Equal(t, "log.txt", attachment.Placeholder(dir))
+
+	// 无附件时不创建目录
+	dir, err = server.prepareTaskAttachments("task_3", nil)
+	require.NoError(t, err)
+	assert.Empty(t, dir)
+}
+
+func TestPrepareTaskAttachmentsInvalid(t *testing.T) {
+	server := NewServer(":8080")
+	server.attachmentDir = t.TempDir()
+
+	cases := [][]TaskAttachment{
+		{{Name: "../x.txt", Content: base64.StdEncoding.EncodeToString([]byte("x"))}},
+		{{Name: "x.txt", Content: "!!!not-base64"}},
+		{{ID: "../uploads"}},
+		{{ID: "upload_missing"}},
+	}
+	for i, atts := range cases {
+		_, err := server.prepareTaskAttachments("task_bad", atts)
+		assert.Error(t, err, "case %d", i)
+		// 失败时任务目录被清理
+		_, statErr := os.Stat(server.taskAttachmentDir("task_bad"))
+		assert.True(t, os.IsNotExist(statErr), "case %d", i)
+	}
+}
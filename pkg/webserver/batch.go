@@ -0,0 +1,363 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// batchItemPlaceholder is replaced by the item in the task template of a batch
+	batchItemPlaceholder = "{item}"
+
+	// maxBatchItems limits the number of tasks of one batch
+	maxBatchItems = 500
+
+	// defaultBatchConcurrency is the number of tasks of a batch running at the same time
+	defaultBatchConcurrency = 4
+
+	// maxBatchConcurrency limits the concurrency a batch can request
+	maxBatchConcurrency = 16
+
+	// batchRetention is how long finished batches can still be queried
+	batchRetention = time.Hour
+)
+
+// Status values of a task in a batch, besides the final task statuses
+const (
+	batchTaskQueued   = "queued"
+	batchTaskRunning  = "running"
+	batchTaskCanceled = "canceled"
+)
+
+// Status values of a batch
+const (
+	batchRunning   = "running"
+	batchCompleted = "completed"
+	batchCanceled  = "canceled"
+)
+
+// BatchTaskRequest represents a request to run the same task for several items.
+// The configuration fields of TaskRequest are shared by all tasks, its Task is not used.
+type BatchTaskRequest struct {
+	TaskRequest
+	TaskTemplate string   `json:"task_template"`         // 任务模板，{item} 替换为每个条目
+	Items        []string `json:"items"`                 // 条目列表，每个条目创建一个任务
+	Concurrency  int      `json:"concurrency,omitempty"` // 同时执行的任务数，0表示默认值
+}
+
+// BatchTask is the state of one task of a batch
+type BatchTask struct {
+	TaskID    string `json:"task_id"`
+	Item      string `json:"item"`
+	Status    string `json:"status"` // queued、running、completed、error、timeout 或 canceled
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// Batch is a snapshot of a batch of tasks
+type Batch struct {
+	ID         string         `json:"id"`
+	Status     string         `json:"status"` // running、completed 或 canceled
+	Tasks      []BatchTask    `json:"tasks"`
+	Counts     map[string]int `json:"counts"` // 各状态的任务数量
+	CreatedAt  time.Time      `json:"created_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+// taskBatch tracks a running batch
+type taskBatch struct {
+	mu          sync.Mutex
+	batch       Batch
+	tasks       []string // 每个条目的任务描述
+	concurrency int      // 同时执行的任务数
+	cancel      context.CancelFunc
+}
+
+// snapshot returns a copy of the batch state
+func (b *taskBatch) snapshot() Batch {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	batch := b.batch
+	batch.Tasks = append([]BatchTask(nil), b.batch.Tasks...)
+	batch.Counts = make(map[string]int)
+	for _, task := range batch.Tasks {
+		batch.Counts[task.Status]++
+	}
+	return batch
+}
+
+// update changes the state of the task at index
+func (b *taskBatch) update(index int, status, result string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	task := &b.batch.Tasks[index]
+	task.Status = status
+	task.Result = result
+	if err != nil {
+		task.Error = err.Error()
+		task.ErrorCode = mcpagent.ErrorCode(err)
+	}
+}
+
+// finish marks the batch as completed, or as canceled when ctx was canceled
+func (b *taskBatch) finish(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.batch.Status = batchCompleted
+	if ctx.Err() != nil {
+		b.batch.Status = batchCanceled
+	}
+	b.batch.FinishedAt = &now
+}
+
+// batchOfTask returns the ID of the batch taskID belongs to, or an empty string
+func (s *Server) batchOfTask(taskID string) string {
+	s.batchesMu.Lock()
+	defer s.batchesMu.Unlock()
+	return s.batchTasks[taskID]
+}
+
+// validateBatchRequest checks the batch specific fields of req
+func validateBatchRequest(req *BatchTaskRequest) error {
+	if !strings.Contains(req.TaskTemplate, batchItemPlaceholder) {
+		return fmt.Errorf("任务模板必须包含 %s 占位符", batchItemPlaceholder)
+	}
+	if len(req.Items) == 0 {
+		return errors.New("条目列表不能为空")
+	}
+	if len(req.Items) > maxBatchItems {
+		return fmt.Errorf("条目数量不能超过 %d", maxBatchItems)
+	}
+	for i, item := range req.Items {
+		if strings.TrimSpace(item) == "" {
+			return fmt.Errorf("第 %d 个条目为空", i+1)
+		}
+	}
+	if req.Concurrency < 0 || req.Concurrency > maxBatchConcurrency {
+		return fmt.Errorf("并发数必须在 0 到 %d 之间", maxBatchConcurrency)
+	}
+	if len(req.Attachments) > 0 {
+		return errors.New("批量任务不支持附件")
+	}
+	return nil
+}
+
+// startBatch creates one task per item and runs them in the background
+func (s *Server) startBatch(req *BatchTaskRequest, taskConfig *config.Config) *taskBatch {
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &taskBatch{
+		batch: Batch{
+			ID:        fmt.Sprintf("batch_%d", now.UnixNano()),
+			Status:    batchRunning,
+			Tasks:     make([]BatchTask, len(req.Items)),
+			CreatedAt: now,
+		},
+		tasks:  make([]string, len(req.Items)),
+		cancel: cancel,
+	}
+	for i, item := range req.Items {
+		b.batch.Tasks[i] = BatchTask{
+			TaskID: fmt.Sprintf("task_%d_%d", now.UnixNano(), i),
+			Item:   item,
+			Status: batchTaskQueued,
+		}
+		b.tasks[i] = strings.ReplaceAll(req.TaskTemplate, batchItemPlaceholder, item)
+	}
+
+	s.batchesMu.Lock()
+	s.batches[b.batch.ID] = b
+	for _, task := range b.batch.Tasks {
+		s.batchTasks[task.TaskID] = b.batch.ID
+	}
+	s.batchesMu.Unlock()
+	for _, task := range b.batch.Tasks {
+		s.status.taskQueued(task.TaskID, b.batch.ID)
+	}
+
+	b.concurrency = req.Concurrency
+	if b.concurrency == 0 {
+		b.concurrency = defaultBatchConcurrency
+	}
+	go s.runBatch(ctx, b, taskConfig, b.concurrency)
+	return b
+}
+
+// runBatch executes the tasks of a batch with a bounded worker pool in item order.
+// A failed task does not stop the batch, canceling ctx skips the queued tasks
+// and cancels the running ones.
+func (s *Server) runBatch(ctx context.Context, b *taskBatch, taskConfig *config.Config, concurrency int) {
+	defer b.cancel()
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(b.tasks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				s.runBatchTask(ctx, b, i, taskConfig)
+			}
+		}()
+	}
+	for i := range b.tasks {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	b.finish(ctx)
+	snapshot := b.snapshot()
+	s.broadcastBatchStatus(snapshot)
+	log.Printf("批量任务 %s 结束，状态: %s，各状态任务数: %v", snapshot.ID, snapshot.Status, snapshot.Counts)
+
+	time.AfterFunc(batchRetention, func() {
+		s.batchesMu.Lock()
+		delete(s.batches, snapshot.ID)
+		for _, task := range snapshot.Tasks {
+			delete(s.batchTasks, task.TaskID)
+		}
+		s.batchesMu.Unlock()
+	})
+}
+
+// runBatchTask executes the task at index of a batch
+func (s *Server) runBatchTask(ctx context.Context, b *taskBatch, index int, taskConfig *config.Config) {
+	taskID := b.batch.Tasks[index].TaskID
+	if ctx.Err() != nil {
+		b.update(index, batchTaskCanceled, "", nil)
+		s.status.taskSkipped(taskID)
+		s.broadcastToTask(taskID, SSEMessage{
+			Type: MessageTypeStatus,
+			Data: TaskStatus{ID: taskID, Status: batchTaskCanceled, ErrorCode: mcpagent.ErrorCodeCanceled},
+		})
+		return
+	}
+
+	// 每个任务使用独立的产物存储
+	cfg := *taskConfig
+	cfg.Artifacts.Store = s.newArtifactStore(taskID, &cfg.Artifacts)
+
+	b.update(index, batchTaskRunning, "", nil)
+	s.announceTask(taskID, "", &cfg, b.tasks[index])
+	s.broadcastBatchStatus(b.snapshot())
+	s.broadcastQueueUpdate(b)
+
+	status, result, err := s.runTask(ctx, taskID, &cfg, nil, b.tasks[index])
+	if err != nil {
+		log.Printf("批量任务 %s 中的任务 %s 失败: %v", b.batch.ID, taskID, err)
+	}
+	b.update(index, status, result, err)
+	s.broadcastBatchStatus(b.snapshot())
+}
+
+// broadcastBatchStatus sends the state of a batch to the clients subscribed to it
+func (s *Server) broadcastBatchStatus(batch Batch) {
+	msg := SSEMessage{Type: MessageTypeBatchStatus, Data: batch}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, notifier := range s.clients {
+		if notifier.batchID != batch.ID {
+			continue
+		}
+		notifier.deliver(msg)
+	}
+}
+
+// handleCreateBatch handles POST /api/tasks/batch
+// 使用共享的配置为每个条目创建一个任务，立即返回批量任务ID和任务ID列表。
+// 任务按条目顺序排队执行，单个任务失败不影响其他任务。
+func (s *Server) handleCreateBatch(w http.ResponseWriter, r *http.Request) {
+	var req BatchTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析批量任务数据失败", http.StatusBadRequest)
+		return
+	}
+	if err := validateBatchRequest(&req); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	taskConfig, err := s.resolveTaskConfig(&req.TaskRequest)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errs := taskConfig.ValidateDetailed(); len(errs) > 0 {
+		writeValidationError(w, fmt.Sprintf("配置验证失败: %v", errs.First()), errs)
+		return
+	}
+
+	b := s.startBatch(&req, taskConfig)
+	snapshot := b.snapshot()
+	taskIDs := make([]string, len(snapshot.Tasks))
+	for i, task := range snapshot.Tasks {
+		taskIDs[i] = task.TaskID
+	}
+	log.Printf("创建批量任务 %s，共 %d 个任务", snapshot.ID, len(taskIDs))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  fmt.Sprintf("已创建 %d 个任务", len(taskIDs)),
+		"batch_id": snapshot.ID,
+		"task_ids": taskIDs,
+	})
+}
+
+// lookupBatch returns the batch of the request, writing 404 when it does not exist
+func (s *Server) lookupBatch(w http.ResponseWriter, r *http.Request) (*taskBatch, bool) {
+	s.batchesMu.Lock()
+	b, ok := s.batches[mux.Vars(r)["batchId"]]
+	s.batchesMu.Unlock()
+	if !ok {
+		writeError(w, "批量任务不存在", http.StatusNotFound)
+	}
+	return b, ok
+}
+
+// handleGetBatch handles GET /api/batches/{batchId}
+func (s *Server) handleGetBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := s.lookupBatch(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    b.snapshot(),
+	})
+}
+
+// handleCancelBatch handles POST /api/batches/{batchId}/cancel
+// 取消排队中和正在执行的所有任务，已结束的任务不受影响
+func (s *Server) handleCancelBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := s.lookupBatch(w, r)
+	if !ok {
+		return
+	}
+	b.cancel()
+	log.Printf("已取消批量任务: %s", b.batch.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "批量任务取消请求已发送",
+	})
+}
@@ -0,0 +1,210 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchResponse is the body of POST /api/tasks/batch
+type batchResponse struct {
+	BatchID string   `json:"batch_id"`
+	TaskIDs []string `json:"task_ids"`
+}
+
+func createTestBatch(t *testing.T, server *Server, req BatchTaskRequest) batchResponse {
+	t.Helper()
+	w := postJSON(t, server, "/api/tasks/batch", req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var resp batchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotEmpty(t, resp.BatchID)
+	return resp
+}
+
+func getTestBatch(t *testing.T, server *Server, batchID string) Batch {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/batches/"+batchID, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Data Batch `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Data
+}
+
+// waitBatchFinished waits until the batch is no longer running
+func waitBatchFinished(t *testing.T, server *Server, batchID string) Batch {
+	t.Helper()
+	var batch Batch
+	require.Eventually(t, func() bool {
+		batch = getTestBatch(t, server, batchID)
+		return batch.Status != batchRunning
+	}, 2*time.Second, 10*time.Millisecond)
+	return batch
+}
+
+func TestBatchTasks(t *testing.T) {
+	server := setupTaskTestServer(t)
+	ready := make(chan struct{})
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		<-ready
+		if strings.Contains(task, "bad.example") {
+			return errors.New("查询失败")
+		}
+		notify.OnResult("结果: " + task)
+		return nil
+	}
+
+	resp := createTestBatch(t, server, BatchTaskRequest{
+		TaskTemplate: "分析域名 {item}",
+		Items:        []string{"a.example", "bad.example", "c.example"},
+	})
+	require.Len(t, resp.TaskIDs, 3)
+
+	// 订阅批量任务的客户端，以及订阅其他批量任务的客户端
+	w := httptest.NewRecorder()
+	observer := &SSENotifier{writer: w, batchID: resp.BatchID}
+	other := httptest.NewRecorder()
+	server.mutex.Lock()
+	server.clients["batch_observer"] = observer
+	server.clients["other_observer"] = &SSENotifier{writer: other, batchID: "batch_other"}
+	server.mutex.Unlock()
+	close(ready)
+
+	batch := waitBatchFinished(t, server, resp.BatchID)
+	assert.Equal(t, batchCompleted, batch.Status)
+	require.Len(t, batch.Tasks, 3)
+	for i, task := range batch.Tasks {
+		assert.Equal(t, resp.TaskIDs[i], task.TaskID)
+	}
+
+	// 单个任务失败不影响其他任务
+	assert.Equal(t, "completed", batch.Tasks[0].Status)
+	assert.Equal(t, "结果: 分析域名 a.example", batch.Tasks[0].Result)
+	assert.Equal(t, "error", batch.Tasks[1].Status)
+	assert.Equal(t, "查询失败", batch.Tasks[1].Error)
+	assert.Equal(t, "completed", batch.Tasks[2].Status)
+	assert.Equal(t, map[string]int{"completed": 2, "error": 1}, batch.Counts)
+	assert.NotNil(t, batch.FinishedAt)
+
+	// 每个任务都有历史记录
+	record := getTaskHistory(t, server, resp.TaskIDs[2]).Task
+	assert.Equal(t, "分析域名 c.example", record.Task)
+	assert.Equal(t, models.TaskStatusCompleted, record.Status)
+
+	// 订阅者收到批量任务状态和带任务ID的任务消息
+	require.Eventually(t, func() bool {
+		observer.mutex.Lock()
+		defer observer.mutex.Unlock()
+		body := w.Body.String()
+		return strings.Contains(body, `"type":"batch_status"`) &&
+			strings.Contains(body, `"status":"completed","tasks"`) &&
+			strings.Contains(body, `"task_id":"`+resp.TaskIDs[0]+`"`)
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Empty(t, other.Body.String())
+}
+
+func TestBatchConcurrency(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	resp := createTestBatch(t, server, BatchTaskRequest{
+		TaskTemplate: "{item}",
+		Items:        []string{"1", "2", "3", "4", "5"},
+		Concurrency:  2,
+	})
+	batch := waitBatchFinished(t, server, resp.BatchID)
+	assert.Equal(t, 5, batch.Counts["completed"])
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, maxRunning)
+}
+
+func TestCancelBatch(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	started := make(chan string, 3)
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		started <- task
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	resp := createTestBatch(t, server, BatchTaskRequest{
+		TaskTemplate: "{item}",
+		Items:        []string{"1", "2", "3"},
+		Concurrency:  1,
+	})
+	assert.Equal(t, "1", <-started)
+	assert.Equal(t, batchTaskRunning, getTestBatch(t, server, resp.BatchID).Tasks[0].Status)
+
+	w := postJSON(t, server, "/api/batches/"+resp.BatchID+"/cancel", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 正在执行和排队中的任务都被取消
+	batch := waitBatchFinished(t, server, resp.BatchID)
+	assert.Equal(t, batchCanceled, batch.Status)
+	assert.Equal(t, map[string]int{batchTaskCanceled: 3}, batch.Counts)
+	assert.Equal(t, mcpagent.ErrorCodeCanceled, batch.Tasks[0].ErrorCode)
+	assert.Empty(t, started, "排队中的任务不会执行")
+	assert.Equal(t, models.TaskStatusCanceled, getTaskHistory(t, server, resp.TaskIDs[0]).Task.Status)
+}
+
+func TestBatchValidation(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.agentRunner = (&recordingRunner{}).run
+
+	tests := []struct {
+		name string
+		req  BatchTaskRequest
+	}{
+		{name: "模板缺少占位符", req: BatchTaskRequest{TaskTemplate: "分析域名", Items: []string{"a.example"}}},
+		{name: "没有条目", req: BatchTaskRequest{TaskTemplate: "{item}"}},
+		{name: "空条目", req: BatchTaskRequest{TaskTemplate: "{item}", Items: []string{"a.example", " "}}},
+		{name: "并发数过大", req: BatchTaskRequest{TaskTemplate: "{item}", Items: []string{"a.example"}, Concurrency: maxBatchConcurrency + 1}},
+		{name: "不存在的LLM配置", req: BatchTaskRequest{TaskRequest: TaskRequest{LLMConfigID: uintPtr(9999)}, TaskTemplate: "{item}", Items: []string{"a.example"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(t, server, "/api/tasks/batch", tt.req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		})
+	}
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/batches/batch_unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = postJSON(t, server, "/api/batches/batch_unknown/cancel", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
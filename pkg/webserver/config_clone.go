@@ -0,0 +1,66 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// cloneID parses the ID of the record to clone from the request path
+func cloneID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// writeCloneResponse writes the cloned record
+func writeCloneResponse(w http.ResponseWriter, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+		"data":    data,
+	})
+}
+
+// handleCloneLLMConfig handles POST /api/llm/configs/{id}/clone
+func (s *Server) handleCloneLLMConfig(w http.ResponseWriter, r *http.Request) {
+	id, ok := cloneID(w, r)
+	if !ok {
+		return
+	}
+
+	clone, err := s.llmConfigService.CloneConfig(id)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("复制LLM配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeCloneResponse(w, "LLM配置复制成功", clone)
+}
+
+// handleCloneMCPServerConfig handles POST /api/mcp/servers/{id}/clone
+// Like creation, the tools of the copy are synchronized asynchronously.
+func (s *Server) handleCloneMCPServerConfig(w http.ResponseWriter, r *http.Request) {
+	id, ok := cloneID(w, r)
+	if !ok {
+		return
+	}
+
+	clone, err := s.mcpServerConfigService.CloneConfig(id)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("复制MCP服务器配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	go s.syncNewServerTools(clone)
+
+	writeCloneResponse(w, "MCP服务器配置复制成功", redactedServerConfig(clone))
+}
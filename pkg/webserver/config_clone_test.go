@@ -0,0 +1,50 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCloneLLMConfig(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	original, err := server.llmConfigService.GetDefaultConfig()
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/llm/configs/%d/clone", original.ID), nil)
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var resp struct {
+		Success bool                  `json:"success"`
+		Data    models.LLMConfigModel `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, original.Name+" (copy)", resp.Data.Name)
+	assert.Equal(t, original.Model, resp.Data.Model)
+	assert.False(t, resp.Data.IsDefault)
+
+	// 默认配置保持不变
+	defaultConfig, err := server.llmConfigService.GetDefaultConfig()
+	require.NoError(t, err)
+	assert.Equal(t, original.ID, defaultConfig.ID)
+}
+
+func TestHandleCloneNotFound(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	for _, path := range []string{"/api/llm/configs/9999/clone", "/api/mcp/servers/9999/clone", "/api/system-prompts/9999/clone"} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
@@ -0,0 +1,79 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+)
+
+// effectiveConfigResponse is the response of GET /api/config/effective
+type effectiveConfigResponse struct {
+	Config  *config.Config       `json:"config"`            // 任务将使用的配置，密钥已隐藏
+	Sources []config.FieldSource `json:"sources,omitempty"` // explain=true时每个字段的来源
+}
+
+// effectiveConfigRequest builds the task request of GET /api/config/effective
+// from the query parameters llm_config_id, system_prompt_id and max_step,
+// which override the stored configuration like the fields of a task request
+func effectiveConfigRequest(r *http.Request) (*TaskRequest, error) {
+	query := r.URL.Query()
+	taskReq := &TaskRequest{}
+	for _, param := range []struct {
+		name   string
+		target **uint
+	}{
+		{"llm_config_id", &taskReq.LLMConfigID},
+		{"system_prompt_id", &taskReq.SystemPromptID},
+	} {
+		v := query.Get(param.name)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s无效: %s", param.name, v)
+		}
+		value := uint(id)
+		*param.target = &value
+	}
+	if v := query.Get("max_step"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("max_step无效: %s", v)
+		}
+		taskReq.MaxStep = n
+	}
+	return taskReq, nil
+}
+
+// handleGetEffectiveConfig handles GET /api/config/effective and returns the
+// configuration a task with the overrides of the query would use. With
+// explain=true the response also lists which layer supplied each field.
+func (s *Server) handleGetEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	taskReq, err := effectiveConfigRequest(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg, sources, err := s.resolveTaskConfigWithSources(taskReq)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := effectiveConfigResponse{Config: cfg}
+	if r.URL.Query().Get("explain") == "true" {
+		response.Sources, err = sources.Explain(cfg)
+		if err != nil {
+			writeError(w, fmt.Sprintf("生成配置来源失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	cfg.Redact()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,107 @@
+package webserver
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+)
+
+// markdownListMinItems is the number of list items that make text markdown,
+// a single line starting with "- " is as likely a plain sentence
+const markdownListMinItems = 2
+
+// classifyContent guesses the format the web UI should render content in.
+// Text is json when it is a JSON object or array, markdown when it contains
+// a fenced code block, an ATX heading, a table row or a list of several
+// items, and plain otherwise. The checks are single passes over the text, so
+// the classifier is cheap enough to run on every streamed event.
+func classifyContent(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return models.ContentFormatPlain
+	}
+	// 只有以括号开头时才尝试解析，"{name} 已完成" 这类文本不是JSON
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return models.ContentFormatJSON
+	}
+
+	listItems := 0
+	for _, line := range strings.Split(trimmed, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "```"), strings.HasPrefix(line, "~~~"):
+			return models.ContentFormatMarkdown
+		case isMarkdownHeading(line):
+			return models.ContentFormatMarkdown
+		case strings.HasPrefix(line, "|") && strings.HasSuffix(line, "|") && len(line) > 1:
+			return models.ContentFormatMarkdown
+		case isMarkdownListItem(line):
+			listItems++
+			if listItems >= markdownListMinItems {
+				return models.ContentFormatMarkdown
+			}
+		}
+	}
+	return models.ContentFormatPlain
+}
+
+// isMarkdownHeading reports whether line is an ATX heading such as "## 结论",
+// "#hashtag" without the space is not
+func isMarkdownHeading(line string) bool {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	return level >= 1 && level <= 6 && level < len(line) && line[level] == ' '
+}
+
+// isMarkdownListItem reports whether line starts a bullet or ordered list item
+func isMarkdownListItem(line string) bool {
+	if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") || strings.HasPrefix(line, "+ ") {
+		return true
+	}
+	digits := 0
+	for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	return digits > 0 && strings.HasPrefix(line[digits:], ". ")
+}
+
+// toolOutputFormats maps tool names, original or as presented to the LLM, to
+// the output format declared for the tool in the database
+type toolOutputFormats map[string]string
+
+// of returns the declared format of toolName, empty if it declares none
+func (f toolOutputFormats) of(toolName string) string {
+	return f[toolName]
+}
+
+// toolOutputFormats returns the output formats declared for the tools of cfg.
+// Tools that are not configured explicitly declare no format.
+func (s *Server) toolOutputFormats(cfg *config.Config) toolOutputFormats {
+	if s.db == nil || s.mcpToolService == nil || len(cfg.MCP.Tools) == 0 {
+		return nil
+	}
+	formats, err := s.mcpToolService.OutputFormats()
+	if err != nil {
+		log.Printf("获取工具结果格式失败: %v", err)
+		return nil
+	}
+	if len(formats) == 0 {
+		return nil
+	}
+
+	byName := make(toolOutputFormats)
+	for _, t := range cfg.MCP.Tools {
+		format, ok := formats[models.GenerateToolKey(t.Server, t.Name)]
+		if !ok {
+			continue
+		}
+		byName[t.Name] = format
+		byName[cfg.MCP.PresentedToolName(t.Server, t.Name)] = format
+	}
+	return byName
+}
@@ -0,0 +1,86 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"空文本", "", models.ContentFormatPlain},
+		{"空白", "  \n ", models.ContentFormatPlain},
+		{"普通句子", "我需要先搜索相关资料，然后整理结论。", models.ContentFormatPlain},
+		{"JSON对象", `{"ip": "1.1.1.1", "port": 443}`, models.ContentFormatJSON},
+		{"带空白的JSON数组", "\n  [1, 2, 3]\n", models.ContentFormatJSON},
+		{"大括号开头的文本", "{name} 已完成搜索", models.ContentFormatPlain},
+		{"不完整的JSON", `{"ip": "1.1.1.1",`, models.ContentFormatPlain},
+		{"方括号开头的日志", "[INFO] 开始搜索", models.ContentFormatPlain},
+		{"JSON字符串", `"只是一个字符串"`, models.ContentFormatPlain},
+		{"代码块", "执行以下命令：\n```bash\nls -la\n```", models.ContentFormatMarkdown},
+		{"波浪线代码块", "~~~\ncode\n~~~", models.ContentFormatMarkdown},
+		{"标题", "## 结论\n端口已开放", models.ContentFormatMarkdown},
+		{"井号标签", "#golang 是一种编程语言", models.ContentFormatPlain},
+		{"七级井号", "####### 不是标题", models.ContentFormatPlain},
+		{"无序列表", "发现以下问题：\n- 端口开放\n- 证书过期", models.ContentFormatMarkdown},
+		{"有序列表", "1. 搜索\n2. 整理", models.ContentFormatMarkdown},
+		{"单个连字符开头", "- 我认为这个结果是对的", models.ContentFormatPlain},
+		{"减法算式", "结果是 5 - 3 = 2", models.ContentFormatPlain},
+		{"表格", "| IP | 端口 |\n| --- | --- |\n| 1.1.1.1 | 443 |", models.ContentFormatMarkdown},
+		{"单个竖线", "|", models.ContentFormatPlain},
+		{"版本号", "版本 1.2 已发布", models.ContentFormatPlain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, classifyContent(tt.content))
+		})
+	}
+}
+
+func TestNotifierContentFormat(t *testing.T) {
+	server := NewServer(":8080")
+	taskID := "task_format"
+	notifier := &BroadcastNotifier{server: server, taskID: taskID, toolFormats: toolOutputFormats{"fofa_search": models.ContentFormatMarkdown}}
+
+	notifier.OnThinking("## 计划\n先搜索")
+	notifier.OnMessage("正在搜索")
+	notifier.OnToolResult("call_1", "fofa_search", `{"results": []}`, nil, 0)
+	notifier.OnToolResult("call_2", "fetch", `{"status": 200}`, nil, 0)
+	notifier.OnToolResult("call_3", "fofa_search", "", errors.New("连接失败"), 0)
+	notifier.OnResult("```json\n{}\n```")
+
+	events, _, _ := server.events.get(taskID).since(0)
+	formats := make([]string, len(events))
+	for i, event := range events {
+		formats[i] = event.ContentFormat
+	}
+	// 工具声明的格式优先于按内容判断，失败的工具调用没有格式
+	assert.Equal(t, []string{
+		models.ContentFormatMarkdown,
+		models.ContentFormatPlain,
+		models.ContentFormatMarkdown,
+		models.ContentFormatJSON,
+		"",
+		models.ContentFormatMarkdown,
+	}, formats)
+
+	w := httptest.NewRecorder()
+	client := &SSENotifier{writer: w, taskID: "test-task"}
+	client.OnThinking(`["a", "b"]`)
+	data := strings.TrimPrefix(strings.TrimSpace(w.Body.String()), "data: ")
+	var msg struct {
+		Data NotifyEvent `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(data), &msg))
+	assert.Equal(t, models.ContentFormatJSON, msg.Data.ContentFormat)
+}
@@ -0,0 +1,151 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+// CredentialRequest is the body of creating or updating a credential
+type CredentialRequest struct {
+	Name        string `json:"name"`        // 凭据名称，以{{credential:NAME}}引用
+	Value       string `json:"value"`       // 凭据值，更新时为空表示保留原值
+	Description string `json:"description"` // 凭据描述
+}
+
+// toModel converts the request to a credential model
+func (req *CredentialRequest) toModel() *models.CredentialModel {
+	return &models.CredentialModel{
+		Name:        req.Name,
+		Value:       req.Value,
+		Description: req.Description,
+	}
+}
+
+// handleListCredentials 列出所有凭据，只返回名称和掩码后的值
+func (s *Server) handleListCredentials(w http.ResponseWriter, r *http.Request) {
+	credentials, err := s.credentialService.ListCredentials()
+	if err != nil {
+		writeError(w, "获取凭据列表失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(credentials)
+}
+
+// handleCreateCredential 创建新的凭据
+func (s *Server) handleCreateCredential(w http.ResponseWriter, r *http.Request) {
+	var req CredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	credential := req.toModel()
+	if err := s.credentialService.CreateCredential(credential); err != nil {
+		writeModelError(w, err, "创建凭据失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(credential)
+}
+
+// handleGetCredential 获取特定的凭据，只返回掩码后的值
+func (s *Server) handleGetCredential(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的ID", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := s.credentialService.GetCredential(uint(id))
+	if err != nil {
+		writeModelError(w, err, "获取凭据失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(credential)
+}
+
+// handleUpdateCredential 更新凭据
+func (s *Server) handleUpdateCredential(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的ID", http.StatusBadRequest)
+		return
+	}
+
+	var req CredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.credentialService.UpdateCredential(uint(id), req.toModel()); err != nil {
+		writeModelError(w, err, "更新凭据失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	credential, err := s.credentialService.GetCredential(uint(id))
+	if err != nil {
+		writeError(w, "获取更新后的凭据失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(credential)
+}
+
+// handleDeleteCredential 删除凭据
+func (s *Server) handleDeleteCredential(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.credentialService.DeleteCredential(uint(id)); err != nil {
+		writeModelError(w, err, "删除凭据失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkCredentialReferences writes a validation error listing every
+// credential referenced by server as "credentials.<name>" that does not exist
+//
+// Returns:
+//   - bool: true if all referenced credentials exist and the request may continue
+func (s *Server) checkCredentialReferences(w http.ResponseWriter, server *models.MCPServerConfigModel) bool {
+	if s.db == nil {
+		return true
+	}
+	missing, err := s.credentialService.MissingReferences(server)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if len(missing) == 0 {
+		return true
+	}
+
+	fields := make(config.FieldErrors, len(missing))
+	for i, name := range missing {
+		fields[i] = config.FieldError{Field: "credentials." + name, Message: models.ErrCredentialNotFound.Error()}
+	}
+	writeValidationError(w, fmt.Sprintf("引用的凭据不存在: %v", missing), fields)
+	return false
+}
@@ -0,0 +1,96 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialHandlers(t *testing.T) {
+	server := setupTaskTestServer(t)
+	secret := "sk-live-1234567890abcd"
+
+	w := postJSON(t, server, "/api/credentials", CredentialRequest{Name: "shodan", Value: secret, Description: "Shodan API Key"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), secret)
+	var created models.CredentialModel
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// 列表和详情只返回名称和掩码后的值
+	for _, path := range []string{"/api/credentials", "/api/credentials/" + strconv.FormatUint(uint64(created.ID), 10)} {
+		w = httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), secret)
+		assert.Contains(t, w.Body.String(), `"masked_value":"******abcd"`)
+		assert.Contains(t, w.Body.String(), `"name":"shodan"`)
+	}
+
+	w = postJSON(t, server, "/api/credentials", CredentialRequest{Name: "shodan", Value: "x"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = postJSON(t, server, "/api/credentials", CredentialRequest{Name: "bad name", Value: "x"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 更新时不提供值保留原值
+	req := httptest.NewRequest("PUT", "/api/credentials/"+strconv.FormatUint(uint64(created.ID), 10), strings.NewReader(`{"name":"shodan","description":"新描述"}`))
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), secret)
+	value, err := server.credentialService.Lookup("shodan")
+	require.NoError(t, err)
+	assert.Equal(t, secret, value)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/credentials/"+strconv.FormatUint(uint64(created.ID), 10), nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, err = server.credentialService.Lookup("shodan")
+	assert.ErrorIs(t, err, models.ErrCredentialNotFound)
+}
+
+func TestMCPServerConfigCredentialReferences(t *testing.T) {
+	server := setupTaskTestServer(t)
+	require.NoError(t, server.credentialService.CreateCredential(&models.CredentialModel{Name: "shodan", Value: "s3cret"}))
+
+	// 引用不存在的凭据时返回验证错误
+	w := postJSON(t, server, "/api/mcp/servers", CreateMCPServerConfigRequest{
+		Name:    "scanner",
+		Command: "scanner",
+		Args:    []string{"--key", "{{credential:shodan}}"},
+		Env:     map[string]string{"FOFA_KEY": "{{credential:fofa}}"},
+	})
+	apiErr := decodeErrorResponse(t, w, http.StatusBadRequest)
+	assert.Equal(t, errCodeValidationFailed, apiErr.Code)
+	assert.Equal(t, []string{"credentials.fofa"}, fieldNames(apiErr))
+
+	// 保存的配置和接口响应中只有引用
+	w = postJSON(t, server, "/api/mcp/servers", CreateMCPServerConfigRequest{
+		Name:    "scanner",
+		Command: "scanner",
+		Args:    []string{"--key", "{{credential:shodan}}"},
+		Env:     map[string]string{"SHODAN_KEY": "{{credential:shodan}}"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "s3cret")
+
+	// 任务在连接服务器时通过Credentials解析引用
+	runner := &configRunner{}
+	server.agentRunner = runner.run
+	startTestTask(t, server, "/api/task", TaskRequest{Task: "扫描"})
+	require.Len(t, runner.configs, 1)
+	cfg := runner.configs[0]
+	require.Contains(t, cfg.MCP.MCPServers, "scanner")
+	assert.Equal(t, "{{credential:shodan}}", cfg.MCP.MCPServers["scanner"].Env["SHODAN_KEY"])
+	require.NotNil(t, cfg.MCP.Credentials)
+	resolved, err := models.ResolveServerCredentials("scanner", *cfg.MCP.MCPServers["scanner"], cfg.MCP.Credentials)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--key", "s3cret"}, resolved.Args)
+	assert.Equal(t, "s3cret", resolved.Env["SHODAN_KEY"])
+}
@@ -0,0 +1,205 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// defaultHealthHistoryHours is the default time window of the health history endpoint
+	defaultHealthHistoryHours = 24
+
+	// maxHealthHistoryHours limits the time window of the health history endpoint
+	maxHealthHistoryHours = 24 * 30
+)
+
+// HealthCheckConfig configures the periodic health check of MCP servers
+type HealthCheckConfig struct {
+	Interval         time.Duration // 检查间隔，<=0时禁用健康检查
+	Timeout          time.Duration // 单个服务器的检查超时
+	Retention        time.Duration // 健康记录的保留时间
+	FailureThreshold int           // 连续失败达到该次数时发送告警
+}
+
+// DefaultHealthCheckConfig returns the default health check configuration
+func DefaultHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		Interval:         5 * time.Minute,
+		Timeout:          30 * time.Second,
+		Retention:        7 * 24 * time.Hour,
+		FailureThreshold: 3,
+	}
+}
+
+// ServerAlert is broadcast to all clients when a server keeps failing health checks
+type ServerAlert struct {
+	ServerID            uint   `json:"server_id"`
+	ServerName          string `json:"server_name"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Error               string `json:"error"`
+	Timestamp           int64  `json:"timestamp"`
+}
+
+// MCPServerConfigResponse is an MCP server configuration with its health summary
+type MCPServerConfigResponse struct {
+	models.MCPServerConfigModel
+	ConsecutiveFailures int                 `json:"consecutive_failures"`  // 最近连续健康检查失败次数
+	ServerInfo          *mcppool.ServerInfo `json:"server_info,omitempty"` // 缓存的服务器初始化信息，尚未连接时为空
+}
+
+// serverProbe checks whether an MCP server is reachable
+type serverProbe func(ctx context.Context, server *models.MCPServerConfigModel) error
+
+// probeMCPServer lists the tools of a server through the connection pool
+func probeMCPServer(ctx context.Context, server *models.MCPServerConfigModel) error {
+	_, err := listMCPServerTools(ctx, server)
+	return err
+}
+
+// SetHealthCheckConfig sets the health check configuration, it must be called before Start
+func (s *Server) SetHealthCheckConfig(cfg HealthCheckConfig) {
+	s.healthConfig = cfg
+}
+
+// runHealthChecker checks all servers periodically until the server shuts down
+func (s *Server) runHealthChecker(shutdown <-chan struct{}) {
+	log.Printf("MCP服务器健康检查已启动，间隔: %v", s.healthConfig.Interval)
+
+	ticker := time.NewTicker(s.healthConfig.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.checkServersHealth(context.Background())
+
+		select {
+		case <-shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkServersHealth checks every enabled MCP server once, stores the results,
+// alerts clients about servers crossing the failure threshold and prunes old records.
+func (s *Server) checkServersHealth(ctx context.Context) {
+	servers, err := s.mcpServerConfigService.ListConfigs()
+	if err != nil {
+		log.Printf("获取MCP服务器配置失败: %v", err)
+		return
+	}
+
+	probe := s.healthProbe
+	if probe == nil {
+		probe = probeMCPServer
+	}
+
+	for i := range servers {
+		server := &servers[i]
+		if server.Disabled {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, s.healthConfig.Timeout)
+		start := time.Now()
+		checkErr := probe(checkCtx, server)
+		cancel()
+
+		if _, err := s.healthService.RecordCheck(server.ID, time.Since(start), checkErr); err != nil {
+			log.Printf("保存服务器健康检查结果失败 %s: %v", server.Name, err)
+			continue
+		}
+		if checkErr == nil {
+			// 服务器可用时记录其协商的协议版本，结果由连接池缓存
+			infoCtx, cancel := context.WithTimeout(ctx, s.healthConfig.Timeout)
+			if _, err := s.recordServerInfo(infoCtx, server); err != nil {
+				log.Printf("获取MCP服务器信息失败 %s: %v", server.Name, err)
+			}
+			cancel()
+			continue
+		}
+
+		failures, err := s.healthService.ConsecutiveFailures(server.ID)
+		if err != nil {
+			log.Printf("获取服务器连续失败次数失败 %s: %v", server.Name, err)
+			continue
+		}
+		log.Printf("MCP服务器健康检查失败 %s (连续%d次): %v", server.Name, failures, checkErr)
+
+		// 只在刚达到阈值时告警，避免每次检查都重复发送
+		if failures == s.healthConfig.FailureThreshold {
+			s.broadcast(SSEMessage{
+				Type: MessageTypeServerAlert,
+				Data: ServerAlert{
+					ServerID:            server.ID,
+					ServerName:          server.Name,
+					ConsecutiveFailures: failures,
+					Error:               checkErr.Error(),
+					Timestamp:           time.Now().UnixMilli(),
+				},
+			})
+		}
+	}
+
+	if s.healthConfig.Retention > 0 {
+		if _, err := s.healthService.Prune(time.Now().Add(-s.healthConfig.Retention)); err != nil {
+			log.Printf("清理过期健康检查记录失败: %v", err)
+		}
+	}
+}
+
+// handleGetMCPServerHealth handles GET /api/mcp/servers/{id}/health
+// The time window is set with ?hours= and defaults to 24 hours.
+func (s *Server) handleGetMCPServerHealth(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
+		return
+	}
+
+	hours := defaultHealthHistoryHours
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxHealthHistoryHours {
+			writeError(w, "无效的hours参数", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+
+	server, err := s.mcpServerConfigService.GetConfig(uint(id))
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取MCP服务器配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	history, err := s.healthService.GetHistory(uint(id), time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		writeError(w, fmt.Sprintf("获取服务器健康记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	failures, err := s.healthService.ConsecutiveFailures(uint(id))
+	if err != nil {
+		writeError(w, fmt.Sprintf("获取服务器健康记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"server_id":            id,
+			"consecutive_failures": failures,
+			"history":              history,
+			"server_info":          s.mcpPool.CachedServerInfo(server.Name),
+		},
+	})
+}
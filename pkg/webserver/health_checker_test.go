@@ -0,0 +1,140 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flappingProbe 按预设顺序返回成功或失败的健康检查
+type flappingProbe struct {
+	results []error
+	calls   int
+}
+
+func (p *flappingProbe) probe(ctx context.Context, server *models.MCPServerConfigModel) error {
+	err := p.results[p.calls%len(p.results)]
+	p.calls++
+	return err
+}
+
+// setupHealthTestServer creates a server with a single enabled MCP server
+func setupHealthTestServer(t *testing.T, probe *flappingProbe) (*Server, *models.MCPServerConfigModel) {
+	server := setupTaskTestServer(t)
+
+	// 只保留一个启用的服务器，便于统计
+	servers, err := server.mcpServerConfigService.ListConfigs()
+	require.NoError(t, err)
+	for _, s := range servers {
+		require.NoError(t, server.mcpServerConfigService.DeleteConfig(s.ID))
+	}
+	target := &models.MCPServerConfigModel{Name: "flaky", TransportType: "sse", URL: "http://127.0.0.1:1/sse"}
+	require.NoError(t, server.mcpServerConfigService.CreateConfig(target))
+
+	server.healthProbe = probe.probe
+	server.healthConfig.FailureThreshold = 2
+	return server, target
+}
+
+func TestCheckServersHealthAlertsAndRecovers(t *testing.T) {
+	down := errors.New("502 Bad Gateway")
+	probe := &flappingProbe{results: []error{down, down, down, nil, down}}
+	server, target := setupHealthTestServer(t, probe)
+
+	w := httptest.NewRecorder()
+	client := &SSENotifier{writer: w, taskID: "observer"}
+	server.clients["observer"] = client
+	alerts := func() int {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return strings.Count(w.Body.String(), `"server_alert"`)
+	}
+
+	failures := func() int {
+		n, err := server.healthService.ConsecutiveFailures(target.ID)
+		require.NoError(t, err)
+		return n
+	}
+
+	ctx := context.Background()
+	server.checkServersHealth(ctx)
+	assert.Equal(t, 1, failures())
+	server.checkServersHealth(ctx)
+	assert.Equal(t, 2, failures())
+	assert.Eventually(t, func() bool { return alerts() == 1 }, time.Second, 10*time.Millisecond)
+
+	// 超过阈值后不重复告警
+	server.checkServersHealth(ctx)
+	assert.Equal(t, 3, failures())
+
+	// 恢复后重新计数
+	server.checkServersHealth(ctx)
+	assert.Equal(t, 0, failures())
+	server.checkServersHealth(ctx)
+	assert.Equal(t, 1, failures())
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, alerts())
+	assert.Contains(t, w.Body.String(), "502 Bad Gateway")
+}
+
+func TestHandleGetMCPServerHealth(t *testing.T) {
+	probe := &flappingProbe{results: []error{nil, errors.New("connection refused")}}
+	server, target := setupHealthTestServer(t, probe)
+	server.checkServersHealth(context.Background())
+	server.checkServersHealth(context.Background())
+
+	req := httptest.NewRequest("GET", "/api/mcp/servers/1/health?hours=24", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": strconv.FormatUint(uint64(target.ID), 10)})
+	w := httptest.NewRecorder()
+	server.handleGetMCPServerHealth(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			ConsecutiveFailures int                           `json:"consecutive_failures"`
+			History             []models.MCPServerHealthModel `json:"history"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, 1, resp.Data.ConsecutiveFailures)
+	require.Len(t, resp.Data.History, 2)
+	assert.True(t, resp.Data.History[0].OK)
+	assert.Equal(t, "connection refused", resp.Data.History[1].Error)
+
+	// 服务器列表带有连续失败次数
+	w = httptest.NewRecorder()
+	server.handleListMCPServerConfigs(w, httptest.NewRequest("GET", "/api/mcp/servers", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data []MCPServerConfigResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "flaky", list.Data[0].Name)
+	assert.Equal(t, 1, list.Data[0].ConsecutiveFailures)
+
+	// 参数错误和不存在的服务器
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/api/mcp/servers/1/health?hours=-1", nil), map[string]string{"id": strconv.FormatUint(uint64(target.ID), 10)})
+	w = httptest.NewRecorder()
+	server.handleGetMCPServerHealth(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/api/mcp/servers/999/health", nil), map[string]string{"id": "999"})
+	w = httptest.NewRecorder()
+	server.handleGetMCPServerHealth(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,44 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LubyRuffy/mcpagent/pkg/services"
+)
+
+// parseListQuery reads the ?page=, ?page_size=, ?sort= and ?q= parameters of a list request
+//
+// Parameters:
+//   - r: List request
+//
+// Returns:
+//   - services.ListQuery: Query of the request, the zero value without parameters
+//   - bool: Whether any list parameter is set
+//   - error: Error describing an invalid page or page size
+func parseListQuery(r *http.Request) (services.ListQuery, bool, error) {
+	values := r.URL.Query()
+	query := services.ListQuery{Sort: values.Get("sort"), Q: values.Get("q")}
+	set := query.Sort != "" || query.Q != ""
+
+	for _, p := range []struct {
+		name string
+		dest *int
+	}{
+		{"page", &query.Page},
+		{"page_size", &query.PageSize},
+	} {
+		v := values.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return services.ListQuery{}, false, fmt.Errorf("无效的%s参数，应为正整数", p.name)
+		}
+		*p.dest = n
+		set = true
+	}
+	return query, set, nil
+}
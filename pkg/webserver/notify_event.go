@@ -0,0 +1,138 @@
+package webserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+)
+
+// DefaultToolResultEventLimit is the default maximum size in bytes of the
+// result of a tool_result event, see Server.SetToolResultEventLimit
+const DefaultToolResultEventLimit = 32 * 1024
+
+// toolResultTruncated is appended to the results of tool_result events cut at the limit
+const toolResultTruncated = "\n...[内容已截断]"
+
+// SetToolResultEventLimit sets the maximum size in bytes of the tool results
+// sent to the browser in tool_result events, longer results are truncated.
+// Values not above zero send whole results. The agent always receives the
+// whole result. It must be called before Start.
+func (s *Server) SetToolResultEventLimit(limit int) {
+	s.toolResultEventLimit = limit
+}
+
+// eventSequence numbers the notify events of one task. The sequence number is
+// the authoritative order of the events, Timestamp is only informational
+// since the events of a task are created in several goroutines.
+//
+// eventSequence is not safe for concurrent use, callers serialize stamp with
+// the lock that also orders the delivery of the events.
+type eventSequence struct {
+	last int64
+}
+
+// stamp assigns the next sequence number, an ID unique within the server and
+// the current time to event.
+//
+// Parameters:
+//   - taskID: Task the event belongs to
+//   - event: Event created by one of the newXxxEvent helpers
+//
+// Returns:
+//   - NotifyEvent: event with Seq, ID and Timestamp set
+func (q *eventSequence) stamp(taskID string, event NotifyEvent) NotifyEvent {
+	q.last++
+	event.Seq = q.last
+	// 任务ID唯一，加上任务内序号后事件ID不会重复
+	event.ID = fmt.Sprintf("%s-%d", taskID, q.last)
+	event.Timestamp = time.Now().UnixMilli()
+	return event
+}
+
+// newContentEvent creates a message, thinking, result or plan event
+func newContentEvent(eventType string, content string) NotifyEvent {
+	return NotifyEvent{Type: eventType, Content: content, ContentFormat: classifyContent(content)}
+}
+
+// newResultEvent creates the result event, verification is nil without agent.verify_result
+func newResultEvent(result string, verification *mcpagent.Verification) NotifyEvent {
+	event := newContentEvent(EventTypeResult, result)
+	event.Verification = verification
+	return event
+}
+
+// newResultChunkEvent creates the event carrying a chunk of the final answer
+func newResultChunkEvent(chunk string) NotifyEvent {
+	return NotifyEvent{Type: EventTypeResultChunk, Content: chunk}
+}
+
+// newErrorEvent creates an error event with its stable error code
+func newErrorEvent(message string, code string) NotifyEvent {
+	return NotifyEvent{Type: EventTypeError, Error: message, ErrorCode: code}
+}
+
+// newPlanEvent creates the event carrying the execution plan of a task
+func newPlanEvent(plan string) NotifyEvent {
+	return newContentEvent(EventTypePlan, plan)
+}
+
+// newPlanApprovalEvent creates the event asking the clients of a task to approve its execution plan
+func newPlanApprovalEvent(plan string) NotifyEvent {
+	return newContentEvent(EventTypePlanApproval, plan)
+}
+
+// newThinkingEvent creates a thinking event related to a tool call
+func newThinkingEvent(msg string, relatedCallID string) NotifyEvent {
+	event := newContentEvent(EventTypeThinking, msg)
+	event.RelatedCallID = relatedCallID
+	return event
+}
+
+// newToolCallEvent creates a tool call event, callID is empty for notifiers without call IDs
+func newToolCallEvent(callID string, toolName string, params any) NotifyEvent {
+	return NotifyEvent{
+		Type:       EventTypeToolCall,
+		ToolName:   toolName,
+		Parameters: mcpagent.CanonicalizeArguments(params),
+		Status:     "calling",
+		CallID:     callID,
+	}
+}
+
+// newToolResultEvent creates the result event of a tool call. format is the
+// output format declared for the tool, the format of result is guessed
+// without one.
+func newToolResultEvent(callID string, toolName string, result string, err error, format string) NotifyEvent {
+	event := NotifyEvent{
+		Type:     EventTypeToolResult,
+		ToolName: toolName,
+		Status:   "success",
+		Result:   result,
+		CallID:   callID,
+	}
+	if err != nil {
+		event.Status = "error"
+		event.Result = nil
+		event.Error = err.Error()
+		event.ErrorCode = mcpagent.ErrorCodeToolFailed
+		return event
+	}
+	event.ContentFormat = format
+	if event.ContentFormat == "" {
+		event.ContentFormat = classifyContent(result)
+	}
+	return event
+}
+
+// newToolCallResultEvent creates the result event of a tool call with its
+// duration. Results longer than limit bytes are truncated, limit not above
+// zero keeps the whole result.
+func newToolCallResultEvent(result mcpagent.ToolCallResult, format string, limit int) NotifyEvent {
+	event := newToolResultEvent(result.CallID, result.ToolName, result.Result, result.Err, format)
+	event.DurationMs = result.Duration.Milliseconds()
+	if text, ok := event.Result.(string); ok && limit > 0 && len(text) > limit {
+		event.Result = mcpagent.TruncateUTF8(text, limit) + toolResultTruncated
+	}
+	return event
+}
@@ -0,0 +1,141 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcastNotifierConcurrentEvents(t *testing.T) {
+	server := NewServer(":8080")
+	taskID := "task_concurrent"
+	notifier := &BroadcastNotifier{server: server, taskID: taskID}
+
+	const workers, perWorker = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				switch j % 3 {
+				case 0:
+					notifier.OnMessage("消息")
+				case 1:
+					notifier.OnToolCallWithID("call", "search", map[string]any{"q": j})
+				default:
+					notifier.OnToolResult("call", "search", "结果", nil, 0)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 缓存中的事件按序号严格递增，ID互不相同
+	events, _, _ := server.events.get(taskID).since(0)
+	require.Len(t, events, workers*perWorker)
+	ids := make(map[string]bool, len(events))
+	for i, event := range events {
+		assert.Equal(t, int64(i+1), event.Seq)
+		assert.False(t, ids[event.ID], "事件ID重复: %s", event.ID)
+		ids[event.ID] = true
+		assert.NotZero(t, event.Timestamp)
+	}
+
+	// 其他任务的序号单独计算，ID也不会与本任务重复
+	(&BroadcastNotifier{server: server, taskID: "task_other"}).OnMessage("消息")
+	other, _, _ := server.events.get("task_other").since(0)
+	require.Len(t, other, 1)
+	assert.Equal(t, int64(1), other[0].Seq)
+	assert.False(t, ids[other[0].ID])
+}
+
+func TestSSENotifierConcurrentEvents(t *testing.T) {
+	w := httptest.NewRecorder()
+	notifier := &SSENotifier{writer: w, taskID: "test-task"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				notifier.OnThinking("思考")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 直接写入连接的事件按写入顺序编号
+	var last int64
+	ids := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+		var msg struct {
+			Data NotifyEvent `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg))
+		assert.Greater(t, msg.Data.Seq, last)
+		last = msg.Data.Seq
+		assert.False(t, ids[msg.Data.ID], "事件ID重复: %s", msg.Data.ID)
+		ids[msg.Data.ID] = true
+	}
+	assert.Equal(t, int64(200), last)
+}
+
+func TestSSEConnectServerTime(t *testing.T) {
+	server := NewServer(":8080")
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events?taskId=task_clock", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	before := time.Now().UnixMilli()
+	done := make(chan struct{})
+	go func() {
+		server.handleSSE(w, req)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	var msg struct {
+		Type string `json:"type"`
+		Data struct {
+			Connected  bool  `json:"connected"`
+			ServerTime int64 `json:"server_time"`
+		} `json:"data"`
+	}
+	body := strings.TrimSpace(strings.TrimPrefix(w.Body.String(), "data: "))
+	require.NoError(t, json.Unmarshal([]byte(body), &msg))
+	assert.True(t, msg.Data.Connected)
+	assert.GreaterOrEqual(t, msg.Data.ServerTime, before)
+	assert.LessOrEqual(t, msg.Data.ServerTime, time.Now().UnixMilli())
+}
+
+func TestBroadcastNotifierResultVerification(t *testing.T) {
+	server := NewServer(":8080")
+	taskID := "task_verified"
+	notifier := &BroadcastNotifier{server: server, taskID: taskID}
+
+	notifier.OnVerification(mcpagent.Verification{Supported: []string{"端口80开放"}, Unsupported: []string{"使用了nginx"}})
+	notifier.OnResult("端口80开放，使用了nginx")
+	notifier.OnResult("没有校验的结果")
+
+	// 校验结果只附加到紧随其后的result事件
+	events, _, _ := server.events.get(taskID).since(0)
+	require.Len(t, events, 2)
+	require.NotNil(t, events[0].Verification)
+	assert.Equal(t, []string{"使用了nginx"}, events[0].Verification.Unsupported)
+	assert.Nil(t, events[1].Verification)
+
+	data, err := json.Marshal(events[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"verification":{"supported":["端口80开放"],"unsupported":["使用了nginx"]}`)
+}
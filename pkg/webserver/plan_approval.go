@@ -0,0 +1,95 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/gorilla/mux"
+)
+
+// errPlanNotPending is returned when a decision is posted for a task that does not wait for one
+var errPlanNotPending = errors.New("任务没有等待批准的执行计划")
+
+// planApprovals holds the tasks with plan_approval that wait for the user to
+// approve their execution plan through POST /api/task/{taskId}/plan
+type planApprovals struct {
+	mutex   sync.Mutex
+	pending map[string]chan mcpagent.PlanDecision
+}
+
+func newPlanApprovals() *planApprovals {
+	return &planApprovals{pending: make(map[string]chan mcpagent.PlanDecision)}
+}
+
+// wait registers the task as waiting and blocks until decide is called for it or ctx is done
+func (p *planApprovals) wait(ctx context.Context, taskID string) (mcpagent.PlanDecision, error) {
+	decisions := make(chan mcpagent.PlanDecision, 1)
+	p.mutex.Lock()
+	p.pending[taskID] = decisions
+	p.mutex.Unlock()
+	defer func() {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		if p.pending[taskID] == decisions {
+			delete(p.pending, taskID)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return mcpagent.PlanDecision{}, ctx.Err()
+	case decision := <-decisions:
+		return decision, nil
+	}
+}
+
+// decide delivers the decision to the waiting task
+//
+// Returns:
+//   - error: errPlanNotPending if the task does not wait for a decision
+func (p *planApprovals) decide(taskID string, decision mcpagent.PlanDecision) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	decisions, ok := p.pending[taskID]
+	if !ok {
+		return errPlanNotPending
+	}
+	delete(p.pending, taskID)
+	decisions <- decision
+	return nil
+}
+
+// planApprover returns the approver of a task, which sends a plan_approval
+// event to the clients of the task and waits for their decision
+func (s *Server) planApprover(taskID string) mcpagent.PlanApprover {
+	return func(ctx context.Context, plan string) (mcpagent.PlanDecision, error) {
+		s.broadcastToTask(taskID, SSEMessage{Type: MessageTypeNotify, Data: newPlanApprovalEvent(plan)})
+		return s.planApprovals.wait(ctx, taskID)
+	}
+}
+
+// handleDecidePlan handles POST /api/task/{taskId}/plan
+// 批准或拒绝等待中的执行计划，请求体为{"approved": true, "feedback": "..."}
+func (s *Server) handleDecidePlan(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["taskId"]
+
+	var decision mcpagent.PlanDecision
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+		writeError(w, "解析批准数据失败", http.StatusBadRequest)
+		return
+	}
+	if err := s.planApprovals.decide(taskID, decision); err != nil {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    decision,
+	})
+}
@@ -0,0 +1,84 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanApproval(t *testing.T) {
+	server := setupTaskTestServer(t)
+	decisions := make(chan mcpagent.PlanDecision, 1)
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		approver := mcpagent.PlanApproverFromContext(ctx)
+		if approver == nil {
+			return errors.New("没有批准方式")
+		}
+		decision, err := approver(ctx, "1. 扫描目标")
+		if err != nil {
+			return err
+		}
+		decisions <- decision
+		return nil
+	}
+
+	// 没有等待批准的任务时返回404
+	w := postJSON(t, server, "/api/task/task_unknown/plan", mcpagent.PlanDecision{Approved: true})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	enabled := true
+	w = postJSON(t, server, "/api/task", TaskRequest{Task: "扫描目标", PlanMode: &enabled, PlanApproval: &enabled})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	// 任务推送plan_approval事件后等待批准
+	require.Eventually(t, func() bool {
+		events := server.events.get(resp.TaskID)
+		if events == nil {
+			return false
+		}
+		buffered, _, _ := events.since(0)
+		for _, event := range buffered {
+			if event.Type == EventTypePlanApproval {
+				return event.Content == "1. 扫描目标"
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		w = postJSON(t, server, "/api/task/"+resp.TaskID+"/plan", mcpagent.PlanDecision{Approved: true, Feedback: "只扫描80端口"})
+		return w.Code == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond)
+
+	select {
+	case decision := <-decisions:
+		assert.Equal(t, mcpagent.PlanDecision{Approved: true, Feedback: "只扫描80端口"}, decision)
+	case <-time.After(2 * time.Second):
+		t.Fatal("任务没有收到批准结果")
+	}
+
+	// 已经处理的计划不能再次批准
+	w = postJSON(t, server, "/api/task/"+resp.TaskID+"/plan", mcpagent.PlanDecision{})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPlanApprovalsWaitCanceled(t *testing.T) {
+	approvals := newPlanApprovals()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := approvals.wait(ctx, "task_1")
+	require.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, approvals.decide("task_1", mcpagent.PlanDecision{}), errPlanNotPending, "取消后不再等待")
+}
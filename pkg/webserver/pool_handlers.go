@@ -0,0 +1,51 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
+	"github.com/gorilla/mux"
+)
+
+// handleGetMCPPool handles GET /api/mcp/pool
+// 返回连接池中的MCP服务器连接及其健康状态
+func (s *Server) handleGetMCPPool(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	entries := s.mcpPool.Snapshot(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    entries,
+	})
+}
+
+// handleCloseMCPPoolEntry handles DELETE /api/mcp/pool/{key}
+// 强制关闭指定的连接，不管是否仍有调用在使用
+func (s *Server) handleCloseMCPPoolEntry(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	if err := s.mcpPool.ForceClose(key); err != nil {
+		if errors.Is(err, mcppool.ErrEntryNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, fmt.Sprintf("关闭连接失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("已强制关闭MCP连接: %s", key)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "连接已关闭",
+	})
+}
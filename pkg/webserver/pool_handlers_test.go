@@ -0,0 +1,62 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getMCPPool(t *testing.T, server *Server) []mcppool.EntrySnapshot {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/mcp/pool", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Success bool                    `json:"success"`
+		Data    []mcppool.EntrySnapshot `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.True(t, body.Success)
+	return body.Data
+}
+
+func TestMCPPoolHandlers(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.mcpPool = mcppool.New(func(ctx context.Context, hub *mcphost.MCPHub) error { return nil })
+	t.Cleanup(func() { server.mcpPool.Shutdown() })
+
+	// mcphost跳过名为inner的服务器，这些连接不会启动任何进程
+	for _, command := range []string{"a", "b"} {
+		_, err := server.mcpPool.GetHub(context.Background(), &mcphost.MCPSettings{
+			MCPServers: map[string]*mcphost.ServerConfig{"inner": {Command: command}},
+		})
+		require.NoError(t, err)
+	}
+
+	entries := getMCPPool(t, server)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, []string{"inner"}, e.Servers)
+		assert.Equal(t, 1, e.RefCount)
+		assert.True(t, e.Healthy)
+	}
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/mcp/pool/"+entries[0].Key, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	remaining := getMCPPool(t, server)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, entries[1].Key, remaining[0].Key)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/mcp/pool/"+entries[0].Key, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
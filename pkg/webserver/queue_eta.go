@@ -0,0 +1,91 @@
+package webserver
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// taskDurationCapacity is the number of recent task durations the ETA of queued tasks is based on
+	taskDurationCapacity = 50
+
+	// DefaultETAPercentile is the percentile of the recent task durations
+	// above which tasks are ignored when estimating the start of queued tasks
+	DefaultETAPercentile = 0.9
+)
+
+// SetETAPercentile sets the percentile of the recent task durations above
+// which tasks are ignored by the ETA of queued tasks, values outside (0, 1)
+// keep all tasks. It must be called before Start.
+func (s *Server) SetETAPercentile(percentile float64) {
+	s.status.etaPercentile = percentile
+}
+
+// recordDuration adds the duration of a finished task to the moving average
+func (c *statusCollector) recordDuration(d time.Duration) {
+	c.durationsMu.Lock()
+	defer c.durationsMu.Unlock()
+	c.durations[c.durationsNext] = d
+	c.durationsNext = (c.durationsNext + 1) % taskDurationCapacity
+	if c.durationsLen < taskDurationCapacity {
+		c.durationsLen++
+	}
+}
+
+// averageDuration returns the mean duration of the recent tasks, ignoring
+// the tasks above etaPercentile so that a few very long tasks do not inflate
+// the estimate. It returns false when no task finished yet.
+func (c *statusCollector) averageDuration() (time.Duration, bool) {
+	c.durationsMu.Lock()
+	durations := append([]time.Duration(nil), c.durations[:c.durationsLen]...)
+	c.durationsMu.Unlock()
+	if len(durations) == 0 {
+		return 0, false
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	if c.etaPercentile > 0 && c.etaPercentile < 1 {
+		// 按最近秩法计算百分位，至少保留一个任务
+		keep := int(math.Ceil(c.etaPercentile * float64(len(durations))))
+		durations = durations[:max(keep, 1)]
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations)), true
+}
+
+// queueStatuses returns the status of the queued tasks of a batch in the
+// order they will start. Position 1 starts next; the ETA assumes each worker
+// of the batch runs one task of average duration at a time and is omitted
+// without task history.
+func (s *Server) queueStatuses(b *taskBatch) []TaskStatus {
+	average, ok := s.status.averageDuration()
+	snapshot := b.snapshot()
+
+	var statuses []TaskStatus
+	for _, task := range snapshot.Tasks {
+		if task.Status != batchTaskQueued {
+			continue
+		}
+		position := len(statuses) + 1
+		status := TaskStatus{ID: task.TaskID, Status: batchTaskQueued, Position: &position}
+		if ok {
+			rounds := (position-1)/max(b.concurrency, 1) + 1
+			eta := int(math.Round((time.Duration(rounds) * average).Seconds()))
+			status.ETASeconds = &eta
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// broadcastQueueUpdate sends a queue_update event with the position and ETA
+// to every queued task of a batch, called whenever a task of the batch starts
+func (s *Server) broadcastQueueUpdate(b *taskBatch) {
+	for _, status := range s.queueStatuses(b) {
+		s.broadcastToTask(status.ID, SSEMessage{Type: MessageTypeQueueUpdate, Data: status})
+	}
+}
@@ -0,0 +1,135 @@
+package webserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAverageDurationIgnoresOutliers(t *testing.T) {
+	c := newStatusCollector()
+	_, ok := c.averageDuration()
+	assert.False(t, ok, "没有历史任务时不估计")
+
+	for _, d := range []time.Duration{10, 20, 30, 40, 1000} {
+		c.recordDuration(d * time.Second)
+	}
+	c.etaPercentile = 0.8
+	average, ok := c.averageDuration()
+	require.True(t, ok)
+	assert.Equal(t, 25*time.Second, average, "忽略超过80百分位的任务")
+
+	c.etaPercentile = 1
+	average, _ = c.averageDuration()
+	assert.Equal(t, 220*time.Second, average)
+
+	// 只保留最近的任务
+	for i := 0; i < taskDurationCapacity; i++ {
+		c.recordDuration(time.Second)
+	}
+	average, _ = c.averageDuration()
+	assert.Equal(t, time.Second, average)
+}
+
+// queueUpdates returns the last queue_update status of each task in an SSE stream
+func queueUpdates(body string) map[string]TaskStatus {
+	updates := make(map[string]TaskStatus)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var msg struct {
+			Type string     `json:"type"`
+			Data TaskStatus `json:"data"`
+		}
+		if json.Unmarshal([]byte(data), &msg) == nil && msg.Type == "queue_update" {
+			updates[msg.Data.ID] = msg.Data
+		}
+	}
+	return updates
+}
+
+func TestBatchQueueUpdates(t *testing.T) {
+	server := setupTaskTestServer(t)
+	var mu sync.Mutex
+	release := make(map[string]chan struct{})
+	gate := func(task string) chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if release[task] == nil {
+			release[task] = make(chan struct{})
+		}
+		return release[task]
+	}
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		<-gate(task)
+		notify.OnResult("完成")
+		return nil
+	}
+	for i := 0; i < 4; i++ {
+		server.status.recordDuration(10 * time.Second)
+	}
+
+	resp := createTestBatch(t, server, BatchTaskRequest{
+		TaskTemplate: "任务 {item}",
+		Items:        []string{"a", "b", "c"},
+		Concurrency:  1,
+	})
+	server.batchesMu.Lock()
+	b := server.batches[resp.BatchID]
+	server.batchesMu.Unlock()
+
+	// 第一个任务执行中，其余任务按顺序排队
+	var statuses []TaskStatus
+	require.Eventually(t, func() bool {
+		statuses = server.queueStatuses(b)
+		return len(statuses) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, resp.TaskIDs[1], statuses[0].ID)
+	assert.Equal(t, 1, *statuses[0].Position)
+	assert.Equal(t, 10, *statuses[0].ETASeconds)
+	assert.Equal(t, resp.TaskIDs[2], statuses[1].ID)
+	assert.Equal(t, 2, *statuses[1].Position)
+	assert.Equal(t, 20, *statuses[1].ETASeconds)
+
+	w := httptest.NewRecorder()
+	observer := &SSENotifier{writer: w, batchID: resp.BatchID}
+	server.mutex.Lock()
+	server.clients["queue_observer"] = observer
+	server.mutex.Unlock()
+	body := func() string {
+		observer.mutex.Lock()
+		defer observer.mutex.Unlock()
+		return w.Body.String()
+	}
+
+	// 第一个任务完成后，剩余的任务收到新的位置
+	close(gate("任务 a"))
+	require.Eventually(t, func() bool {
+		update, ok := queueUpdates(body())[resp.TaskIDs[2]]
+		return ok && *update.Position == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	update := queueUpdates(body())[resp.TaskIDs[2]]
+	assert.Equal(t, batchTaskQueued, update.Status)
+	require.NotNil(t, update.ETASeconds)
+	assert.Equal(t, 8, *update.ETASeconds, "第一个任务的执行时间计入平均值")
+	assert.NotContains(t, queueUpdates(body()), resp.TaskIDs[1], "开始执行的任务不再收到排队消息")
+
+	close(gate("任务 b"))
+	close(gate("任务 c"))
+	waitBatchFinished(t, server, resp.BatchID)
+	assert.Empty(t, server.queueStatuses(b))
+}
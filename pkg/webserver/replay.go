@@ -0,0 +1,40 @@
+package webserver
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+)
+
+// SetReplayDir lets task configs record into and play back the replay files
+// of dir, replay.record_path and replay.play_path are relative to it. It
+// must be called before Start. Without a directory the replay settings of
+// task configs are ignored, so that API clients cannot choose the files the
+// server reads and writes.
+func (s *Server) SetReplayDir(dir string) {
+	s.replayDir = dir
+}
+
+// confineReplay resolves the replay files of a task inside the replay
+// directory, or clears the replay settings when the server has none
+func (s *Server) confineReplay(taskID string, cfg *config.Config) {
+	if cfg.Replay.RecordPath == "" && cfg.Replay.PlayPath == "" {
+		return
+	}
+	if s.replayDir == "" {
+		log.Printf("任务 %s 的replay配置已忽略，未通过 -replay-dir 指定回放目录", taskID)
+		cfg.Replay = config.ReplayConfig{}
+		return
+	}
+	cfg.Replay.RecordPath = confinedReplayPath(s.replayDir, cfg.Replay.RecordPath)
+	cfg.Replay.PlayPath = confinedReplayPath(s.replayDir, cfg.Replay.PlayPath)
+}
+
+// confinedReplayPath returns name inside dir, ".." cannot leave the directory
+func confinedReplayPath(dir, name string) string {
+	if name == "" {
+		return ""
+	}
+	return filepath.Join(dir, filepath.Clean(string(filepath.Separator)+name))
+}
@@ -0,0 +1,93 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replayTaskConfig returns a task config playing back a recording of testdata/replay
+func replayTaskConfig(playPath string) *config.Config {
+	cfg := config.NewDefaultConfig()
+	cfg.MCP.ConfigFile = "mcpservers.json"
+	cfg.MCP.Tools = []config.MCPToolConfig{{Server: "web", Name: "fetch_page"}}
+	cfg.SystemPrompt = "你是测试助手。"
+	cfg.MaxStep = 5
+	cfg.Replay = config.ReplayConfig{PlayPath: playPath}
+	return cfg
+}
+
+// TestReplayTaskEndToEnd replays a recorded session through /api/task and
+// checks the events sent to the clients of the task
+func TestReplayTaskEndToEnd(t *testing.T) {
+	server := setupTaskTestServer(t)
+	// 回放录制的会话不访问大模型和MCP服务器，执行真实的任务
+	server.agentRunner = runAgent
+	server.SetReplayDir(filepath.Join("testdata", "replay"))
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	taskID := startTestTask(t, server, "/api/task", TaskRequest{
+		Task:   "总结 https://example.com",
+		Config: replayTaskConfig("summarize_page.json"),
+	})
+	record := getTaskHistory(t, server, taskID).Task
+	require.Equal(t, "completed", record.Status, record.Error)
+	assert.Equal(t, "这是一个用于文档示例的网页。", record.Result)
+
+	resp, err := http.Get(ts.URL + "/api/task/" + taskID + "/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	events := readNDJSONEvents(t, resp)
+
+	var types []string
+	for i, event := range events {
+		types = append(types, event.Type)
+		assert.Equal(t, int64(i+1), event.Seq)
+	}
+	// 最终回答先逐段推送，再推送完整的结果
+	assert.Equal(t, []string{"tool_call", "tool_result", "result_chunk", "result"}, types)
+	assert.Equal(t, "fetch_page", events[0].ToolName)
+	assert.Equal(t, "call_1", events[0].CallID)
+	assert.Equal(t, "call_1", events[1].CallID)
+	assert.Equal(t, "success", events[1].Status)
+	assert.Equal(t, "Example Domain: This domain is for use in illustrative examples.", events[1].Result)
+	assert.Equal(t, "这是一个用于文档示例的网页。", events[2].Content)
+	assert.Equal(t, "这是一个用于文档示例的网页。", events[3].Content)
+}
+
+// TestReplayTaskStrictMismatch checks that a task whose messages differ from
+// the recording fails instead of receiving unrelated responses
+func TestReplayTaskStrictMismatch(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.agentRunner = runAgent
+	server.SetReplayDir(filepath.Join("testdata", "replay"))
+
+	cfg := replayTaskConfig("summarize_page.json")
+	cfg.SystemPrompt = "你是另一个助手。"
+	taskID := startTestTask(t, server, "/api/task", TaskRequest{Task: "总结 https://example.com", Config: cfg})
+	record := getTaskHistory(t, server, taskID).Task
+	assert.Equal(t, "error", record.Status)
+	assert.Contains(t, record.Error, "回放请求与录制内容不一致")
+}
+
+func TestConfineReplay(t *testing.T) {
+	server := NewServer(":8080")
+	cfg := replayTaskConfig("summarize_page.json")
+
+	// 未指定回放目录时忽略任务的replay配置
+	server.confineReplay("task_1", cfg)
+	assert.Equal(t, config.ReplayConfig{}, cfg.Replay)
+
+	server.SetReplayDir("/srv/replay")
+	cfg.Replay = config.ReplayConfig{RecordPath: "../../etc/session.json", Match: "fuzzy"}
+	server.confineReplay("task_2", cfg)
+	assert.Equal(t, filepath.Join("/srv/replay", "etc", "session.json"), cfg.Replay.RecordPath)
+	assert.Empty(t, cfg.Replay.PlayPath)
+	assert.Equal(t, "fuzzy", cfg.Replay.Match)
+}
@@ -19,19 +19,30 @@ package webserver
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/LubyRuffy/einomcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/attachment"
+	"github.com/LubyRuffy/mcpagent/pkg/audit"
 	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/LubyRuffy/mcpagent/pkg/llmcache"
 	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
 	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/notes"
+	"github.com/LubyRuffy/mcpagent/pkg/resultdiff"
 	"github.com/LubyRuffy/mcpagent/pkg/services"
+	"github.com/LubyRuffy/mcpagent/pkg/webui"
+	"github.com/cloudwego/eino/schema"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"gorm.io/gorm"
@@ -39,47 +50,102 @@ import (
 
 // SSEMessage represents a message sent over Server-Sent Events
 type SSEMessage struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Type   string      `json:"type"` // 消息类型，见MessageType常量
+	Data   interface{} `json:"data"`
+	TaskID string      `json:"task_id,omitempty"` // 任务消息所属的任务，订阅批量任务的客户端据此区分
+
+	SchemaVersion int `json:"schema_version"` // 消息格式的版本，发送时设置，见SSESchemaVersion
 }
 
 // TaskRequest represents a task execution request
 type TaskRequest struct {
 	Task   string         `json:"task"`
-	Config *config.Config `json:"config"` // 必需的配置，由前端页面提供
+	Config *config.Config `json:"config,omitempty"` // 可选的完整配置，未提供时使用数据库中的默认配置
+
+	// 轻量级覆盖项，在服务端基于默认配置（或Config）解析
+	LLMConfigID         *uint                  `json:"llm_config_id,omitempty"`          // 引用已保存的LLM配置
+	SystemPromptID      *uint                  `json:"system_prompt_id,omitempty"`       // 引用已保存的系统提示词
+	MaxStep             int                    `json:"max_step,omitempty"`               // 最大步数，0表示不覆盖
+	MaxDuration         int                    `json:"max_duration,omitempty"`           // 任务的时间预算（秒），与max_step先达到者为准，0表示不覆盖
+	Tools               []config.MCPToolConfig `json:"tools,omitempty"`                  // 使用的工具列表
+	PlaceHolders        map[string]any         `json:"placeholders,omitempty"`           // 额外的占位符，与默认占位符合并
+	OutputSchema        json.RawMessage        `json:"output_schema,omitempty"`          // 最终输出需满足的JSON Schema，可以是对象或JSON文本
+	PlanMode            *bool                  `json:"plan_mode,omitempty"`              // 是否先生成并推送执行计划，nil表示不覆盖
+	PlanApproval        *bool                  `json:"plan_approval,omitempty"`          // 是否等待批准执行计划后再执行，nil表示不覆盖
+	Instructions        string                 `json:"instructions,omitempty"`           // 本次任务的说明，作为系统提示词之后的第二条系统消息
+	FirstTool           string                 `json:"first_tool,omitempty"`             // 第一步必须调用的工具
+	AllowedToolsPerStep int                    `json:"allowed_tools_per_step,omitempty"` // 每一步最多调用的不同工具数，0表示不覆盖
+	EnableNotes         *bool                  `json:"enable_notes,omitempty"`           // 是否启用笔记工具，nil表示不覆盖
+	RequireTools        string                 `json:"require_tools,omitempty"`          // 没有工具时的检查方式：auto、always 或 never
+	ToolsAutoSelect     *bool                  `json:"tools_auto_select,omitempty"`      // 没有选择工具时是否按任务内容自动选择工具，nil表示不覆盖
+
+	// 任务模板，与Task二选一
+	TemplateID *uint          `json:"template_id,omitempty"` // 引用已保存的任务模板
+	Params     map[string]any `json:"params,omitempty"`      // 模板参数
+
+	Attachments []TaskAttachment `json:"attachments,omitempty"` // 任务附件
+
+	DryRun bool `json:"dry_run,omitempty"` // 只返回任务将使用的配置和自动选择的工具，不执行任务
 }
 
 // MCPToolsRequest represents a request to get tools from MCP servers
 type MCPToolsRequest struct {
-	MCPServers map[string]*einomcphost.ServerConfig `json:"mcp_servers"`
+	MCPServers    map[string]*mcphost.ServerConfig `json:"mcp_servers"`
+	ConfigFile    string                           `json:"config_file,omitempty"`    // MCP服务器配置文件路径，与mcp_servers合并
+	MergeStrategy string                           `json:"merge_strategy,omitempty"` // 合并策略：inline_only、file_only 或 merge（默认）
 }
 
 // MCPToolInfo represents information about an MCP tool
 type MCPToolInfo struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Server      string `json:"server"`
+	Name          string     `json:"name"`
+	PresentedName string     `json:"presented_name"` // 向大模型展示的工具名称
+	Description   string     `json:"description"`
+	Server        string     `json:"server"`
+	UsageCount    int64      `json:"usage_count"`             // 调用次数
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`  // 最后调用时间
+	Source        string     `json:"source,omitempty"`        // 工具来源：live（实时获取）或 cache（数据库缓存）
+	Stale         bool       `json:"stale"`                   // 缓存是否已过期
+	ResultFilter  string     `json:"result_filter,omitempty"` // 应用于JSON结果的jq表达式
+	OutputFormat  string     `json:"output_format,omitempty"` // 声明的结果格式：plain、markdown 或 json
+
+	LocalizedDescriptions map[string]string `json:"localized_descriptions,omitempty"` // 按语言的工具描述
+
+	InputSchema map[string]any         `json:"input_schema,omitempty"` // 输入参数的原始JSON Schema
+	Parameters  []models.ToolParameter `json:"parameters"`             // 由输入模式展开的表单字段
 }
 
 // MCPToolsResponse represents the response containing MCP tools
 type MCPToolsResponse struct {
-	Success bool          `json:"success"`
-	Message string        `json:"message,omitempty"`
-	Tools   []MCPToolInfo `json:"tools,omitempty"`
-	Error   string        `json:"error,omitempty"`
+	Success  bool               `json:"success"`
+	Message  string             `json:"message,omitempty"`
+	Tools    []MCPToolInfo      `json:"tools,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Errors   []ServerToolsError `json:"errors,omitempty"`   // 获取失败的服务器
+	Warnings []string           `json:"warnings,omitempty"` // 合并服务器配置时的警告
+
+	Pagination *services.Pagination `json:"pagination,omitempty"` // 分页信息，仅缓存工具列表返回
+	Report     *services.SyncReport `json:"report,omitempty"`     // 同步单个服务器时的工具变化
 }
 
 // NotifyEvent represents different types of notification events
 type NotifyEvent struct {
-	Type       string      `json:"type"`
-	Timestamp  int64       `json:"timestamp"`
-	ID         string      `json:"id"`
-	Content    string      `json:"content,omitempty"`
-	ToolName   string      `json:"tool_name,omitempty"`
-	Parameters interface{} `json:"parameters,omitempty"`
-	Status     string      `json:"status,omitempty"`
-	Result     interface{} `json:"result,omitempty"`
-	Error      string      `json:"error,omitempty"`
+	Type          string      `json:"type"` // 事件类型，见EventType常量
+	Timestamp     int64       `json:"timestamp"`
+	ID            string      `json:"id"`
+	Content       string      `json:"content,omitempty"`
+	ToolName      string      `json:"tool_name,omitempty"`
+	Parameters    interface{} `json:"parameters,omitempty"`
+	Status        string      `json:"status,omitempty"`
+	Result        interface{} `json:"result,omitempty"`
+	Error         string      `json:"error,omitempty"`
+	ErrorCode     string      `json:"error_code,omitempty"`      // 稳定的错误码，前端据此本地化错误信息
+	CallID        string      `json:"call_id,omitempty"`         // tool_call与tool_result事件共享同一个调用ID
+	RelatedCallID string      `json:"related_call_id,omitempty"` // thinking事件之后发起的工具调用ID
+	Seq           int64       `json:"seq,omitempty"`             // 任务内递增的事件序号，从1开始
+	ContentFormat string      `json:"content_format,omitempty"`  // content或result的格式：plain、markdown 或 json
+	DurationMs    int64       `json:"duration_ms,omitempty"`     // tool_result事件：工具调用的耗时（毫秒）
+
+	Verification *mcpagent.Verification `json:"verification,omitempty"` // result事件：agent.verify_result对结果的校验
 }
 
 // TaskStatus represents the current task execution status
@@ -89,19 +155,48 @@ type TaskStatus struct {
 	Progress    *int   `json:"progress,omitempty"`
 	CurrentStep string `json:"current_step,omitempty"`
 	TotalSteps  *int   `json:"total_steps,omitempty"`
+	ErrorCode   string `json:"error_code,omitempty"`   // 任务失败时的错误码
+	OutputValid *bool  `json:"output_valid,omitempty"` // 配置了output_schema时，最终输出是否通过校验
+	Model       string `json:"model,omitempty"`        // 产生最终结果的模型，配置了备用模型时可能不是主模型
+	Position    *int   `json:"position,omitempty"`     // 排队中的任务在队列中的位置，1表示下一个执行
+	ETASeconds  *int   `json:"eta_seconds,omitempty"`  // 排队中的任务预计多少秒后开始执行，没有历史任务时为空
+
+	MissingTools []config.MissingTool `json:"missing_tools,omitempty"` // missing_tool_policy为warn时跳过的不存在的工具
 }
 
 // SSENotifier implements the mcpagent.Notify interface for Server-Sent Events communication
 type SSENotifier struct {
-	writer http.ResponseWriter
-	mutex  sync.Mutex
-	taskID string
+	writer  http.ResponseWriter
+	mutex   sync.Mutex
+	taskID  string
+	batchID string        // 订阅的批量任务，接收其中所有任务的消息
+	events  eventSequence // 直接发送给该客户端的事件序号
+
+	schemaVersion int // 客户端通过?schema=请求的消息格式版本，0表示SSESchemaVersion
+
+	verification *mcpagent.Verification // 附加到下一个result事件的校验结果
+	toolFormats  toolOutputFormats      // 工具声明的结果格式，tool_result事件据此代替按内容判断
+	resultLimit  int                    // tool_result事件中结果的最大字节数，0表示不截断
+
+	closed bool // 连接已断开，不再写入writer，由mutex保护
+
+	queueMu  sync.Mutex
+	queue    []SSEMessage // 等待发送的广播消息，按广播的顺序发送
+	draining bool         // 是否有goroutine正在发送queue中的消息
 }
 
 // BroadcastNotifier implements the mcpagent.Notify interface for broadcasting to all SSE clients
 type BroadcastNotifier struct {
 	server *Server
 	taskID string
+
+	resultMu sync.Mutex
+	result   string               // 最后一次OnResult的内容，保存到任务历史
+	model    string               // 产生最终结果的模型
+	missing  []config.MissingTool // 任务跳过的不存在的工具
+
+	verification *mcpagent.Verification // 附加到下一个result事件的校验结果
+	toolFormats  toolOutputFormats      // 工具声明的结果格式，tool_result事件据此代替按内容判断
 }
 
 // Server represents the web server instance
@@ -109,6 +204,7 @@ type Server struct {
 	addr                   string
 	router                 *mux.Router
 	clients                map[string]*SSENotifier
+	runningTasks           map[string]context.CancelFunc // 运行中任务的取消函数，按任务ID索引，由mutex保护
 	mutex                  sync.RWMutex
 	config                 *config.Config
 	db                     *gorm.DB // 数据库连接
@@ -116,9 +212,47 @@ type Server struct {
 	mcpServerConfigService *services.MCPServerConfigService
 	mcpToolService         *services.MCPToolService
 	systemPromptService    *services.SystemPromptService
+	taskTemplateService    *services.TaskTemplateService
+	credentialService      *services.CredentialService
 	appConfigService       *services.AppConfigService
-	shutdown               chan struct{} // 用于通知关闭的通道
-	httpServer             *http.Server  // HTTP服务器实例
+	toolUsageService       *services.ToolUsageService
+	toolUsageRecorder      *services.ToolUsageRecorder // 异步记录工具调用统计，无数据库时为nil
+	healthService          *services.MCPServerHealthService
+	healthConfig           HealthCheckConfig         // MCP服务器健康检查配置
+	healthProbe            serverProbe               // 健康检查方法，为nil时使用probeMCPServer
+	toolLister             serverToolLister          // 获取服务器工具列表的方法，为nil时使用listMCPServerTools
+	serverToolLister       services.ServerToolLister // POST /api/mcp/tools获取服务器工具列表的方法，为nil时使用services.ListServerToolDetails
+	toolInvoker            serverToolInvoker         // 调用服务器工具的方法，为nil时使用invokeMCPServerTool
+	shutdown               chan struct{}             // 用于通知关闭的通道
+	httpServer             *http.Server              // HTTP服务器实例
+	attachmentDir          string                    // 任务附件存储目录
+	artifactService        *services.ArtifactService
+	taskHistoryService     *services.TaskHistoryService
+	toolResultService      *services.ToolResultSnapshotService
+	mcpPool                *mcppool.Pool           // 共享的MCP服务器连接
+	agentRunner            agentRunner             // 执行任务的方法，为nil时使用runAgent
+	artifactDir            string                  // 工具产物存储目录
+	toolSyncer             serverToolSyncer        // 同步单个服务器工具的方法，为nil时使用syncServerTools
+	toolSyncTimeout        time.Duration           // 单个服务器的工具同步超时时间
+	syncJobs               map[string]*toolSyncJob // 工具同步任务，按任务ID索引
+	syncJobsMu             sync.Mutex
+	batches                map[string]*taskBatch // 批量任务，按批量任务ID索引
+	batchTasks             map[string]string     // 任务ID到所属批量任务ID的映射
+	batchesMu              sync.Mutex
+	auditLogger            *audit.Logger         // 记录所有任务事件的审计日志，为nil时不记录
+	modelCache             *llmcache.Cache       // 任务间共享的大模型实例，为nil时每个任务创建新模型
+	status                 *statusCollector      // 任务和错误的统计，用于GET /api/status
+	checkpointInterval     int                   // 任务检查点之间的模型调用次数，0表示不保存检查点
+	events                 *taskEventLog         // 任务通知事件的缓存，用于NDJSON事件流
+	tasks                  *taskRegistry         // 运行中和最近结束的任务的状态
+	toolResultEventLimit   int                   // tool_result事件中结果的最大字节数，0表示不截断
+	notes                  *taskNoteStores       // 运行中和最近结束的任务的笔记
+	planApprovals          *planApprovals        // 等待用户批准执行计划的任务
+	staticHandler          http.Handler          // 前端页面，默认使用嵌入的资源
+	replayDir              string                // 任务录制和回放文件所在的目录，为空时忽略任务的replay配置
+	descriptionTranslator  descriptionTranslator // 翻译工具描述的方法，为nil时使用translateWithLLM
+	translateInterval      time.Duration         // 两次翻译请求之间的最短间隔
+	translateMu            sync.Mutex            // 保证同一时间只进行一次工具描述翻译
 }
 
 // NewServer creates a new web server instance
@@ -127,19 +261,58 @@ func NewServer(addr string) *Server {
 		addr:                   addr,
 		router:                 mux.NewRouter(),
 		clients:                make(map[string]*SSENotifier),
+		runningTasks:           make(map[string]context.CancelFunc),
 		config:                 config.NewDefaultConfig(), // 初始化默认配置
+		db:                     database.GetDB(),
 		llmConfigService:       services.NewLLMConfigService(),
 		mcpServerConfigService: services.NewMCPServerConfigService(),
 		mcpToolService:         services.NewMCPToolService(),
 		systemPromptService:    services.NewSystemPromptService(),
+		taskTemplateService:    services.NewTaskTemplateService(),
+		credentialService:      services.NewCredentialService(),
 		appConfigService:       services.NewAppConfigService(),
+		toolUsageService:       services.NewToolUsageService(),
+		healthService:          services.NewMCPServerHealthService(),
+		healthConfig:           DefaultHealthCheckConfig(),
 		shutdown:               make(chan struct{}), // 初始化关闭通道
+		attachmentDir:          filepath.Join(os.TempDir(), "mcpagent", "attachments"),
+		artifactService:        services.NewArtifactService(),
+		taskHistoryService:     services.NewTaskHistoryService(),
+		toolResultService:      services.NewToolResultSnapshotService(),
+		mcpPool:                mcppool.Default(),
+		artifactDir:            filepath.Join(os.TempDir(), "mcpagent", "artifacts"),
+		toolSyncTimeout:        defaultToolSyncTimeout,
+		translateInterval:      defaultTranslateInterval,
+		syncJobs:               make(map[string]*toolSyncJob),
+		batches:                make(map[string]*taskBatch),
+		batchTasks:             make(map[string]string),
+		modelCache:             llmcache.New(llmcache.DefaultCapacity),
+		status:                 newStatusCollector(),
+		checkpointInterval:     DefaultCheckpointInterval,
+		events:                 newTaskEventLog(),
+		tasks:                  newTaskRegistry(DefaultTaskRetention),
+		toolResultEventLimit:   DefaultToolResultEventLimit,
+		notes:                  newTaskNoteStores(),
+		planApprovals:          newPlanApprovals(),
+		staticHandler:          webui.Handler(webui.Assets()),
+	}
+
+	if server.db != nil {
+		server.toolUsageRecorder = services.NewToolUsageRecorder(server.toolUsageService)
 	}
 
 	server.setupRoutes()
 	return server
 }
 
+// SetAuditLogger sets the audit log the events of every task are written to,
+// it must be called before Start and is closed when the server shuts down.
+// The audit settings of task configs are ignored, so that API clients cannot
+// choose the files the server writes to.
+func (s *Server) SetAuditLogger(logger *audit.Logger) {
+	s.auditLogger = logger
+}
+
 // setupRoutes configures all HTTP routes
 func (s *Server) setupRoutes() {
 	// SSE endpoint
@@ -147,44 +320,117 @@ func (s *Server) setupRoutes() {
 
 	// API endpoints
 	api := s.router.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/config", s.handleGetConfig).Methods("GET")
-	api.HandleFunc("/config", s.handleUpdateConfig).Methods("POST")
+	api.HandleFunc("/config", s.requireDatabase(s.handleGetConfig)).Methods("GET")
+	api.HandleFunc("/config", s.requireDatabase(s.handleUpdateConfig)).Methods("POST")
+	api.HandleFunc("/config/schema", s.handleGetConfigSchema).Methods("GET")
+	api.HandleFunc("/config/effective", s.requireDatabase(s.handleGetEffectiveConfig)).Methods("GET")
+	api.HandleFunc("/status", s.handleGetStatus).Methods("GET")
 	api.HandleFunc("/task", s.handleExecuteTask).Methods("POST")
+	api.HandleFunc("/task/upload", s.handleUploadAttachment).Methods("POST")
+	api.HandleFunc("/task/{taskId}", s.handleGetTask).Methods("GET")
 	api.HandleFunc("/task/{taskId}/cancel", s.handleCancelTask).Methods("POST")
+	api.HandleFunc("/task/{taskId}/continue", s.handleContinueTask).Methods("POST")
+	api.HandleFunc("/task/{taskId}/resume", s.handleResumeTask).Methods("POST")
+	api.HandleFunc("/task/{taskId}/events", s.handleTaskEvents).Methods("GET")
+	api.HandleFunc("/task/{taskId}/artifacts", s.handleListArtifacts).Methods("GET")
+	api.HandleFunc("/task/{taskId}/notes", s.handleGetTaskNotes).Methods("GET")
+	api.HandleFunc("/task/{taskId}/plan", s.handleDecidePlan).Methods("POST")
+	api.HandleFunc("/task/{taskId}/artifacts/{id}", s.handleGetArtifact).Methods("GET")
+	api.HandleFunc("/tasks", s.handleListTasks).Methods("GET")
+	api.HandleFunc("/tasks/running", s.handleListRunningTasks).Methods("GET")
+	api.HandleFunc("/tasks/batch", s.handleCreateBatch).Methods("POST")
+	api.HandleFunc("/tasks/history", s.handleListTaskHistory).Methods("GET")
+	api.HandleFunc("/tasks/history/{id:[0-9]+}", s.handleDeleteTaskHistory).Methods("DELETE")
+	api.HandleFunc("/tasks/{taskId}", s.handleGetTaskHistory).Methods("GET")
+	api.HandleFunc("/tasks/{taskId}/config", s.handleGetTaskConfig).Methods("GET")
+	api.HandleFunc("/tasks/{taskId}/rerun", s.handleRerunTask).Methods("POST")
+	api.HandleFunc("/batches/{batchId}", s.handleGetBatch).Methods("GET")
+	api.HandleFunc("/batches/{batchId}/cancel", s.handleCancelBatch).Methods("POST")
 	api.HandleFunc("/llm/test", s.handleTestLLMConnection).Methods("POST")
 
+	// 以下管理API需要数据库，无数据库模式下返回501
 	// LLM配置管理API
-	api.HandleFunc("/llm/configs", s.handleListLLMConfigs).Methods("GET")
-	api.HandleFunc("/llm/configs", s.handleCreateLLMConfig).Methods("POST")
-	api.HandleFunc("/llm/configs/{id:[0-9]+}", s.handleGetLLMConfig).Methods("GET")
-	api.HandleFunc("/llm/configs/{id:[0-9]+}", s.handleUpdateLLMConfig).Methods("PUT")
-	api.HandleFunc("/llm/configs/{id:[0-9]+}", s.handleDeleteLLMConfig).Methods("DELETE")
-	api.HandleFunc("/llm/configs/{id:[0-9]+}/default", s.handleSetDefaultLLMConfig).Methods("POST")
+	api.HandleFunc("/llm/configs", s.requireDatabase(s.handleListLLMConfigs)).Methods("GET")
+	api.HandleFunc("/llm/configs", s.requireDatabase(s.handleCreateLLMConfig)).Methods("POST")
+	api.HandleFunc("/llm/configs/{id:[0-9]+}", s.requireDatabase(s.handleGetLLMConfig)).Methods("GET")
+	api.HandleFunc("/llm/configs/{id:[0-9]+}", s.requireDatabase(s.handleUpdateLLMConfig)).Methods("PUT")
+	api.HandleFunc("/llm/configs/{id:[0-9]+}", s.requireDatabase(s.handleDeleteLLMConfig)).Methods("DELETE")
+	api.HandleFunc("/llm/configs/{id:[0-9]+}/default", s.requireDatabase(s.handleSetDefaultLLMConfig)).Methods("POST")
+	api.HandleFunc("/llm/configs/{id:[0-9]+}/clone", s.requireDatabase(s.handleCloneLLMConfig)).Methods("POST")
 
 	// 系统提示词配置管理API
-	api.HandleFunc("/system-prompts", s.handleListSystemPrompts).Methods("GET")
-	api.HandleFunc("/system-prompts", s.handleCreateSystemPrompt).Methods("POST")
-	api.HandleFunc("/system-prompts/{id:[0-9]+}", s.handleGetSystemPrompt).Methods("GET")
-	api.HandleFunc("/system-prompts/{id:[0-9]+}", s.handleUpdateSystemPrompt).Methods("PUT")
-	api.HandleFunc("/system-prompts/{id:[0-9]+}", s.handleDeleteSystemPrompt).Methods("DELETE")
-	api.HandleFunc("/system-prompts/{id:[0-9]+}/default", s.handleSetDefaultSystemPrompt).Methods("POST")
+	api.HandleFunc("/system-prompts", s.requireDatabase(s.handleListSystemPrompts)).Methods("GET")
+	api.HandleFunc("/system-prompts", s.requireDatabase(s.handleCreateSystemPrompt)).Methods("POST")
+	api.HandleFunc("/system-prompts/{id:[0-9]+}", s.requireDatabase(s.handleGetSystemPrompt)).Methods("GET")
+	api.HandleFunc("/system-prompts/{id:[0-9]+}", s.requireDatabase(s.handleUpdateSystemPrompt)).Methods("PUT")
+	api.HandleFunc("/system-prompts/{id:[0-9]+}", s.requireDatabase(s.handleDeleteSystemPrompt)).Methods("DELETE")
+	api.HandleFunc("/system-prompts/{id:[0-9]+}/default", s.requireDatabase(s.handleSetDefaultSystemPrompt)).Methods("POST")
+	api.HandleFunc("/system-prompts/{id:[0-9]+}/clone", s.requireDatabase(s.handleCloneSystemPrompt)).Methods("POST")
+
+	// 任务模板管理API
+	api.HandleFunc("/task-templates", s.requireDatabase(s.handleListTaskTemplates)).Methods("GET")
+	api.HandleFunc("/task-templates", s.requireDatabase(s.handleCreateTaskTemplate)).Methods("POST")
+	api.HandleFunc("/task-templates/{id:[0-9]+}", s.requireDatabase(s.handleGetTaskTemplate)).Methods("GET")
+	api.HandleFunc("/task-templates/{id:[0-9]+}", s.requireDatabase(s.handleUpdateTaskTemplate)).Methods("PUT")
+	api.HandleFunc("/task-templates/{id:[0-9]+}", s.requireDatabase(s.handleDeleteTaskTemplate)).Methods("DELETE")
+
+	// 凭据管理API，凭据值只写不读
+	api.HandleFunc("/credentials", s.requireDatabase(s.handleListCredentials)).Methods("GET")
+	api.HandleFunc("/credentials", s.requireDatabase(s.handleCreateCredential)).Methods("POST")
+	api.HandleFunc("/credentials/{id:[0-9]+}", s.requireDatabase(s.handleGetCredential)).Methods("GET")
+	api.HandleFunc("/credentials/{id:[0-9]+}", s.requireDatabase(s.handleUpdateCredential)).Methods("PUT")
+	api.HandleFunc("/credentials/{id:[0-9]+}", s.requireDatabase(s.handleDeleteCredential)).Methods("DELETE")
 
 	// MCP服务器配置管理API
-	api.HandleFunc("/mcp/servers", s.handleListMCPServerConfigs).Methods("GET")
-	api.HandleFunc("/mcp/servers", s.handleCreateMCPServerConfig).Methods("POST")
-	api.HandleFunc("/mcp/servers/{id:[0-9]+}", s.handleGetMCPServerConfig).Methods("GET")
-	api.HandleFunc("/mcp/servers/{id:[0-9]+}", s.handleUpdateMCPServerConfig).Methods("PUT")
-	api.HandleFunc("/mcp/servers/{id:[0-9]+}", s.handleDeleteMCPServerConfig).Methods("DELETE")
+	api.HandleFunc("/mcp/servers", s.requireDatabase(s.handleListMCPServerConfigs)).Methods("GET")
+	api.HandleFunc("/mcp/servers", s.requireDatabase(s.handleCreateMCPServerConfig)).Methods("POST")
+	api.HandleFunc("/mcp/servers/export", s.requireDatabase(s.handleExportMCPServerConfigs)).Methods("GET")
+	api.HandleFunc("/mcp/servers/import", s.requireDatabase(s.handleImportMCPServerConfigs)).Methods("POST")
+	api.HandleFunc("/mcp/servers/{id:[0-9]+}", s.requireDatabase(s.handleGetMCPServerConfig)).Methods("GET")
+	api.HandleFunc("/mcp/servers/{id:[0-9]+}", s.requireDatabase(s.handleUpdateMCPServerConfig)).Methods("PUT")
+	api.HandleFunc("/mcp/servers/{id:[0-9]+}", s.requireDatabase(s.handleDeleteMCPServerConfig)).Methods("DELETE")
+	api.HandleFunc("/mcp/servers/{id:[0-9]+}/health", s.requireDatabase(s.handleGetMCPServerHealth)).Methods("GET")
+	api.HandleFunc("/mcp/servers/{id:[0-9]+}/info", s.requireDatabase(s.handleGetMCPServerInfo)).Methods("GET")
+	api.HandleFunc("/mcp/servers/{id:[0-9]+}/clone", s.requireDatabase(s.handleCloneMCPServerConfig)).Methods("POST")
+	api.HandleFunc("/mcp/servers/{id:[0-9]+}/export", s.requireDatabase(s.handleExportMCPServerConfig)).Methods("GET")
 
 	// MCP工具管理API
 	api.HandleFunc("/mcp/tools", s.handleGetMCPTools).Methods("POST")
-	api.HandleFunc("/mcp/tools/configured", s.handleGetMCPToolsFromDB).Methods("GET")
-	api.HandleFunc("/mcp/tools/cached", s.handleGetMCPToolsFromDB).Methods("GET") // 重新添加cached端点
-	api.HandleFunc("/mcp/tools/sync", s.handleSyncMCPTools).Methods("POST")
-	api.HandleFunc("/mcp/tools/sync/{id:[0-9]+}", s.handleSyncMCPToolsForServer).Methods("POST")
+	api.HandleFunc("/mcp/tools/configured", s.requireDatabase(s.handleGetMCPToolsFromDB)).Methods("GET")
+	api.HandleFunc("/mcp/tools/cached", s.requireDatabase(s.handleGetCachedMCPTools)).Methods("GET")
+	api.HandleFunc("/mcp/tools/invoke", s.requireDatabase(s.handleInvokeMCPTool)).Methods("POST")
+	api.HandleFunc("/mcp/tools/stats", s.requireDatabase(s.handleGetToolUsageStats)).Methods("GET")
+	api.HandleFunc("/mcp/tools/sync", s.requireDatabase(s.handleSyncMCPTools)).Methods("POST")
+	api.HandleFunc("/mcp/tools/translate", s.requireDatabase(s.handleTranslateToolDescriptions)).Methods("POST")
+	api.HandleFunc("/mcp/tools/sync/status/{jobId}", s.requireDatabase(s.handleGetToolSyncStatus)).Methods("GET")
+	api.HandleFunc("/mcp/tools/sync/{id:[0-9]+}", s.requireDatabase(s.handleSyncMCPToolsForServer)).Methods("POST")
+	api.HandleFunc("/mcp/tools/{toolKey}/result-filter", s.requireDatabase(s.handleSetToolResultFilter)).Methods("PUT")
+	api.HandleFunc("/mcp/tools/{toolKey}/output-format", s.requireDatabase(s.handleSetToolOutputFormat)).Methods("PUT")
+	api.HandleFunc("/mcp/tools/{toolKey}/descriptions", s.requireDatabase(s.handleSetToolDescriptions)).Methods("PUT")
+
+	// MCP连接池管理API
+	api.HandleFunc("/mcp/pool", s.handleGetMCPPool).Methods("GET")
+	api.HandleFunc("/mcp/pool/{key}", s.handleCloseMCPPoolEntry).Methods("DELETE")
+
+	// Frontend, embedded unless overridden by SetStaticDir
+	s.router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.staticHandler.ServeHTTP(w, r)
+	})
+}
 
-	// Static files (for production)
-	s.router.PathPrefix("/").Handler(http.FileServer(http.Dir("./web/dist/")))
+// SetStaticDir serves the frontend from dir instead of the embedded assets,
+// it is used during frontend development and must be called before Start.
+// An empty dir keeps the embedded assets.
+func (s *Server) SetStaticDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	assets, err := webui.DirFS(dir)
+	if err != nil {
+		return err
+	}
+	s.staticHandler = webui.Handler(assets)
+	return nil
 }
 
 // Start starts the web server
@@ -215,6 +461,14 @@ func (s *Server) Start() error {
 	// 启动清理协程
 	go s.cleanupOnShutdown()
 
+	// 上次运行时未结束的任务已经中断
+	s.markInterruptedTasks()
+
+	// 启动MCP服务器健康检查
+	if s.db != nil && s.healthConfig.Interval > 0 {
+		go s.runHealthChecker(s.shutdown)
+	}
+
 	// 使用新的HTTP服务器启动
 	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return err
@@ -240,18 +494,34 @@ func (s *Server) cleanupOnShutdown() {
 	<-s.shutdown
 
 	// 关闭所有MCP连接
-	pool := einomcphost.GetConnectionPool()
-	if errs := pool.Shutdown(); len(errs) > 0 {
+	if errs := s.mcpPool.Shutdown(); len(errs) > 0 {
 		for _, err := range errs {
 			log.Printf("关闭MCP连接时出错: %v", err)
 		}
 	}
 
+	// 写入尚未保存的工具调用统计
+	if s.toolUsageRecorder != nil {
+		s.toolUsageRecorder.Close()
+	}
+
+	// 写入尚未保存的审计事件
+	if err := s.auditLogger.Close(); err != nil {
+		log.Printf("关闭审计日志失败: %v", err)
+	}
+
 	log.Printf("服务器资源清理完成")
 }
 
 // handleSSE handles Server-Sent Events connections
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	// ?schema=按旧版本的格式发送消息，旧的客户端在升级后可以继续使用
+	schemaVersion, err := parseSchemaVersion(r.URL.Query().Get("schema"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -259,40 +529,55 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
 
-	// 获取任务ID参数
+	// 获取任务ID参数，batchId订阅批量任务中所有任务的消息
 	taskID := r.URL.Query().Get("taskId")
-	if taskID == "" {
+	batchID := r.URL.Query().Get("batchId")
+	if taskID == "" && batchID == "" {
 		taskID = fmt.Sprintf("task_%d", time.Now().UnixNano())
 	}
 
 	// Create notifier
 	clientID := fmt.Sprintf("client_%d", time.Now().UnixNano())
 	notifier := &SSENotifier{
-		writer: w,
-		taskID: taskID,
+		writer:        w,
+		taskID:        taskID,
+		batchID:       batchID,
+		schemaVersion: schemaVersion,
+		resultLimit:   s.toolResultEventLimit,
 	}
 
-	s.mutex.Lock()
-	s.clients[clientID] = notifier
-	s.mutex.Unlock()
-
-	log.Printf("SSE客户端连接: %s, 任务ID: %s", r.RemoteAddr, taskID)
+	log.Printf("SSE客户端连接: %s, 任务ID: %s, 批量任务ID: %s", r.RemoteAddr, taskID, batchID)
 
-	// Send connection confirmation
-	s.sendSSEMessage(w, SSEMessage{
-		Type: "status",
+	// 先发送连接确认再登记客户端，任务消息总是在连接确认之后
+	notifier.mutex.Lock()
+	notifier.send(SSEMessage{
+		Type: MessageTypeStatus,
 		Data: map[string]interface{}{
-			"connected": true,
-			"message":   "SSE连接成功",
-			"task_id":   taskID,
+			"connected":      true,
+			"message":        "SSE连接成功",
+			"task_id":        taskID,
+			"batch_id":       batchID,
+			"server_time":    time.Now().UnixMilli(), // 客户端据此计算与服务器的时钟偏差
+			"schema_version": notifier.version(),
 		},
 	})
+	notifier.mutex.Unlock()
+
+	s.mutex.Lock()
+	s.clients[clientID] = notifier
+	s.mutex.Unlock()
+	s.status.clientConnected()
 
 	// Handle connection cleanup
 	defer func() {
 		s.mutex.Lock()
 		delete(s.clients, clientID)
 		s.mutex.Unlock()
+		// 等待正在写入的消息，处理函数返回后不能再使用writer
+		notifier.mutex.Lock()
+		notifier.closed = true
+		notifier.mutex.Unlock()
+		s.status.clientDisconnected()
 		log.Printf("SSE客户端断开: %s, 任务ID: %s", r.RemoteAddr, taskID)
 	}()
 
@@ -302,48 +587,88 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	<-ctx.Done()
 }
 
-// sendSSEMessage sends a message via Server-Sent Events
-func (s *Server) sendSSEMessage(w http.ResponseWriter, msg SSEMessage) {
-	data, err := json.Marshal(msg)
+// version returns the version of the SSE format sent to the client
+func (s *SSENotifier) version() int {
+	if s.schemaVersion == 0 {
+		return SSESchemaVersion
+	}
+	return s.schemaVersion
+}
+
+// send writes msg to the client in the version of the SSE format the client
+// requested, messages the version does not know are skipped. Nothing is
+// written after the client disconnected. The caller holds s.mutex.
+func (s *SSENotifier) send(msg SSEMessage) {
+	if s.closed {
+		return
+	}
+	data, ok, err := encodeSSEMessage(msg, s.version())
 	if err != nil {
 		log.Printf("序列化SSE消息失败: %v", err)
 		return
 	}
+	if !ok {
+		return
+	}
 
-	fmt.Fprintf(w, "data: %s\n\n", data)
+	fmt.Fprintf(s.writer, "data: %s\n\n", data)
 
-	if flusher, ok := w.(http.Flusher); ok {
+	if flusher, ok := s.writer.(http.Flusher); ok {
 		flusher.Flush()
 	}
 }
 
+// deliver queues a broadcast message for the client without waiting for it
+// to be written. Messages are written in the order they are delivered.
+func (s *SSENotifier) deliver(msg SSEMessage) {
+	s.queueMu.Lock()
+	s.queue = append(s.queue, msg)
+	draining := s.draining
+	s.draining = true
+	s.queueMu.Unlock()
+	if !draining {
+		go s.drain()
+	}
+}
+
+// drain writes the queued messages until the queue is empty
+func (s *SSENotifier) drain() {
+	for {
+		s.queueMu.Lock()
+		if len(s.queue) == 0 {
+			s.draining = false
+			s.queueMu.Unlock()
+			return
+		}
+		msg := s.queue[0]
+		s.queue = s.queue[1:]
+		s.queueMu.Unlock()
+
+		s.mutex.Lock()
+		s.send(msg)
+		s.mutex.Unlock()
+	}
+}
+
 // broadcast sends a message to all connected SSE clients
 func (s *Server) broadcast(msg SSEMessage) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	for _, notifier := range s.clients {
-		go func(n *SSENotifier) {
-			n.mutex.Lock()
-			defer n.mutex.Unlock()
-
-			data, err := json.Marshal(msg)
-			if err != nil {
-				log.Printf("序列化广播消息失败: %v", err)
-				return
-			}
-
-			fmt.Fprintf(n.writer, "data: %s\n\n", data)
-
-			if flusher, ok := n.writer.(http.Flusher); ok {
-				flusher.Flush()
-			}
-		}(notifier)
+		notifier.deliver(msg)
 	}
 }
 
 // broadcastToTask sends a message to SSE clients connected for a specific task
+// and to the clients of the batch the task belongs to
 func (s *Server) broadcastToTask(taskID string, msg SSEMessage) {
+	msg.TaskID = taskID
+	msg = s.events.record(taskID, msg)
+	batchID := s.batchOfTask(taskID)
+	s.status.observe(taskID, msg)
+	s.tasks.observe(taskID, msg)
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
@@ -351,28 +676,10 @@ func (s *Server) broadcastToTask(taskID string, msg SSEMessage) {
 
 	sentCount := 0
 	for clientID, notifier := range s.clients {
-		if notifier.taskID == taskID {
+		if notifier.taskID == taskID || (batchID != "" && notifier.batchID == batchID) {
 			log.Printf("找到匹配的客户端: %s 对应任务: %s", clientID, taskID)
 			sentCount++
-
-			go func(n *SSENotifier) {
-				n.mutex.Lock()
-				defer n.mutex.Unlock()
-
-				data, err := json.Marshal(msg)
-				if err != nil {
-					log.Printf("序列化任务消息失败: %v", err)
-					return
-				}
-
-				fmt.Fprintf(n.writer, "data: %s\n\n", data)
-
-				if flusher, ok := n.writer.(http.Flusher); ok {
-					flusher.Flush()
-				}
-
-				log.Printf("已向客户端发送任务消息: %s", taskID)
-			}(notifier)
+			notifier.deliver(msg)
 		}
 	}
 
@@ -386,54 +693,70 @@ func (s *Server) broadcastToTask(taskID string, msg SSEMessage) {
 
 // OnMessage sends a message notification during execution
 func (s *SSENotifier) OnMessage(msg string) {
-	s.sendNotifyEvent(NotifyEvent{
-		Type:      "message",
-		Timestamp: time.Now().UnixMilli(),
-		ID:        fmt.Sprintf("msg_%d", time.Now().UnixNano()),
-		Content:   msg,
-	})
+	s.sendNotifyEvent(newContentEvent(EventTypeMessage, msg))
 }
 
 // OnThinking sends a thinking notification during execution
 func (s *SSENotifier) OnThinking(msg string) {
-	s.sendNotifyEvent(NotifyEvent{
-		Type:      "thinking",
-		Timestamp: time.Now().UnixMilli(),
-		ID:        fmt.Sprintf("think_%d", time.Now().UnixNano()),
-		Content:   msg,
-	})
+	s.sendNotifyEvent(newContentEvent(EventTypeThinking, msg))
 }
 
 // OnToolCall sends a tool call notification during execution
 func (s *SSENotifier) OnToolCall(toolName string, params interface{}) {
-	s.sendNotifyEvent(NotifyEvent{
-		Type:       "tool_call",
-		Timestamp:  time.Now().UnixMilli(),
-		ID:         fmt.Sprintf("tool_%d", time.Now().UnixNano()),
-		ToolName:   toolName,
-		Parameters: params,
-		Status:     "calling",
-	})
+	s.sendNotifyEvent(newToolCallEvent("", toolName, params))
 }
 
 // OnResult sends a result notification when the agent completes successfully
 func (s *SSENotifier) OnResult(msg string) {
-	s.sendNotifyEvent(NotifyEvent{
-		Type:      "result",
-		Timestamp: time.Now().UnixMilli(),
-		ID:        fmt.Sprintf("result_%d", time.Now().UnixNano()),
-		Content:   msg,
-	})
+	s.mutex.Lock()
+	verification := s.verification
+	s.verification = nil
+	s.mutex.Unlock()
+
+	s.sendNotifyEvent(newResultEvent(msg, verification))
+}
+
+// OnStreamResult sends a chunk of the final answer while it is generated
+func (s *SSENotifier) OnStreamResult(chunk string) {
+	s.sendNotifyEvent(newResultChunkEvent(chunk))
+}
+
+// OnVerification records the verdict of agent.verify_result for the result sent next
+func (s *SSENotifier) OnVerification(verification mcpagent.Verification) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.verification = &verification
 }
 
 // OnError sends an error notification when something goes wrong
 func (s *SSENotifier) OnError(err error) {
-	s.sendNotifyEvent(NotifyEvent{
-		Type:      "error",
-		Timestamp: time.Now().UnixMilli(),
-		ID:        fmt.Sprintf("error_%d", time.Now().UnixNano()),
-		Error:     err.Error(),
-	})
+	s.sendNotifyEvent(newErrorEvent(err.Error(), mcpagent.ErrorCode(err)))
+}
+
+// OnPlan sends the execution plan generated in plan mode
+func (s *SSENotifier) OnPlan(plan string) {
+	s.sendNotifyEvent(newPlanEvent(plan))
+}
+
+// OnThinkingWithCall sends a thinking notification linked to the following tool call
+func (s *SSENotifier) OnThinkingWithCall(msg string, relatedCallID string) {
+	s.sendNotifyEvent(newThinkingEvent(msg, relatedCallID))
+}
+
+// OnToolCallWithID sends a tool call notification carrying its call ID
+func (s *SSENotifier) OnToolCallWithID(callID string, toolName string, params any) {
+	s.sendNotifyEvent(newToolCallEvent(callID, toolName, params))
+}
+
+// OnToolCallResult sends the result of a tool call with its duration
+func (s *SSENotifier) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	s.OnToolResult("", toolName, result, err, duration)
+}
+
+// OnToolResult sends the result of a tool call carrying its call ID
+func (s *SSENotifier) OnToolResult(callID string, toolName string, result string, err error, duration time.Duration) {
+	toolResult := mcpagent.ToolCallResult{CallID: callID, ToolName: toolName, Result: result, Err: err, Duration: duration}
+	s.sendNotifyEvent(newToolCallResultEvent(toolResult, s.toolFormats.of(toolName), s.resultLimit))
 }
 
 // sendNotifyEvent sends a notification event via SSE
@@ -441,22 +764,10 @@ func (s *SSENotifier) sendNotifyEvent(event NotifyEvent) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	msg := SSEMessage{
-		Type: "notify",
-		Data: event,
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("序列化通知事件失败: %v", err)
-		return
-	}
-
-	fmt.Fprintf(s.writer, "data: %s\n\n", data)
-
-	if flusher, ok := s.writer.(http.Flusher); ok {
-		flusher.Flush()
-	}
+	s.send(SSEMessage{
+		Type: MessageTypeNotify,
+		Data: s.events.stamp(s.taskID, event),
+	})
 }
 
 // HTTP API handlers
@@ -481,17 +792,23 @@ func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(s.config)
 }
 
+// handleGetConfigSchema handles GET /api/config/schema and returns the JSON Schema of configuration files
+func (s *Server) handleGetConfigSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(config.ConfigSchema())
+}
+
 // handleUpdateConfig handles POST /api/config
 func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	var newConfig config.Config
 
 	if err := json.NewDecoder(r.Body).Decode(&newConfig); err != nil {
-		http.Error(w, "解析配置数据失败", http.StatusBadRequest)
+		writeError(w, "解析配置数据失败", http.StatusBadRequest)
 		return
 	}
 
-	if err := newConfig.Validate(); err != nil {
-		http.Error(w, fmt.Sprintf("配置验证失败: %v", err), http.StatusBadRequest)
+	if errs := newConfig.ValidateDetailed(); len(errs) > 0 {
+		writeValidationError(w, fmt.Sprintf("配置验证失败: %v", errs.First()), errs)
 		return
 	}
 
@@ -512,7 +829,7 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 			}
 		} else {
 			log.Printf("获取默认配置失败: %v", err)
-			http.Error(w, fmt.Sprintf("保存配置到数据库失败: %v", err), http.StatusInternalServerError)
+			writeError(w, fmt.Sprintf("保存配置到数据库失败: %v", err), http.StatusInternalServerError)
 			return
 		}
 	}
@@ -520,7 +837,7 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	// 将新配置应用到数据库模型
 	if err := s.appConfigService.LoadFromConfig(s.config, dbConfig); err != nil {
 		log.Printf("加载配置到数据库模型失败: %v", err)
-		http.Error(w, fmt.Sprintf("保存配置到数据库失败: %v", err), http.StatusInternalServerError)
+		writeError(w, fmt.Sprintf("保存配置到数据库失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -534,7 +851,7 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 
 	if saveErr != nil {
 		log.Printf("保存配置到数据库失败: %v", saveErr)
-		http.Error(w, fmt.Sprintf("保存配置到数据库失败: %v", saveErr), http.StatusInternalServerError)
+		writeError(w, fmt.Sprintf("保存配置到数据库失败: %v", saveErr), http.StatusInternalServerError)
 		return
 	}
 
@@ -550,35 +867,100 @@ func (s *Server) handleExecuteTask(w http.ResponseWriter, r *http.Request) {
 	var taskReq TaskRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&taskReq); err != nil {
-		http.Error(w, "解析任务数据失败", http.StatusBadRequest)
+		var specErr *config.ToolSpecError
+		if errors.As(err, &specErr) {
+			writeError(w, "解析任务数据失败: "+specErr.Error(), http.StatusBadRequest)
+			return
+		}
+		writeError(w, "解析任务数据失败", http.StatusBadRequest)
+		return
+	}
+
+	// 使用任务模板时渲染任务内容，并应用模板中的工具和LLM配置
+	if err := s.applyTaskTemplate(&taskReq); err != nil {
+		var renderErr *models.TemplateRenderError
+		if errors.As(err, &renderErr) {
+			writeValidationError(w, renderErr.Error(), templateRenderFieldErrors(renderErr))
+			return
+		}
+		writeModelError(w, err, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	if taskReq.Task == "" {
-		http.Error(w, "任务描述不能为空", http.StatusBadRequest)
+		writeError(w, "任务描述不能为空", http.StatusBadRequest)
 		return
 	}
 
-	// 配置必须由前端提供
-	if taskReq.Config == nil {
-		http.Error(w, "配置信息不能为空，必须由前端页面提供", http.StatusBadRequest)
+	// 请求中的工具列表在合并配置之前检查，错误信息指向请求中的位置
+	if errs := config.ToolFieldErrors("tools", taskReq.Tools); len(errs) > 0 {
+		writeValidationError(w, fmt.Sprintf("工具列表无效: %v", errs), errs)
 		return
 	}
 
-	// 所有工具必须都包含服务器名称和工具名称
+	// 解析配置：完整配置或数据库默认配置，再应用覆盖项
+	taskConfig, err := s.resolveTaskConfig(&taskReq)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// 验证配置
-	if err := taskReq.Config.Validate(); err != nil {
-		http.Error(w, fmt.Sprintf("配置验证失败: %v", err), http.StatusBadRequest)
+	if errs := taskConfig.ValidateDetailed(); len(errs) > 0 {
+		writeValidationError(w, fmt.Sprintf("配置验证失败: %v", errs.First()), errs)
+		return
+	}
+
+	if taskReq.DryRun {
+		s.handleTaskDryRun(w, r, taskConfig, taskReq.Task)
 		return
 	}
 
 	taskID := fmt.Sprintf("task_%d", time.Now().UnixNano())
 	log.Printf("创建新任务ID: %s", taskID)
 
+	// 保存任务附件
+	attachmentDir, err := s.prepareTaskAttachments(taskID, taskReq.Attachments)
+	if err != nil {
+		writeError(w, fmt.Sprintf("处理附件失败: %v", err), http.StatusBadRequest)
+		return
+	}
+	taskConfig.Attachments.Dir = attachmentDir
+	taskConfig.Artifacts.Store = s.newArtifactStore(taskID, &taskConfig.Artifacts)
+
+	s.startTask(taskID, "", taskConfig, nil, taskReq.Task)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "任务已开始执行",
+		"task_id": taskID,
+	})
+}
+
+// startTask records a task in the history and executes it in the background.
+// Events are sent to the SSE clients of the task.
+//
+// Parameters:
+//   - taskID: ID of the new task
+//   - parentTaskID: ID of the continued task, empty for a new conversation
+//   - taskConfig: Validated configuration with attachments and artifacts prepared
+//   - history: Earlier messages of a continued conversation
+//   - task: Task description or follow-up instruction
+func (s *Server) startTask(taskID, parentTaskID string, taskConfig *config.Config, history []*schema.Message, task string) {
+	s.announceTask(taskID, parentTaskID, taskConfig, task)
+	go s.runTask(context.Background(), taskID, taskConfig, history, task)
+}
+
+// announceTask records a task in the history and tells its SSE clients that it is running
+func (s *Server) announceTask(taskID, parentTaskID string, taskConfig *config.Config, task string) {
+	s.recordTaskStart(taskID, parentTaskID, taskConfig, task)
+	s.status.taskStarted(taskID, task)
+	s.tasks.started(taskID, task)
+
 	// Broadcast task start status to task-specific SSE clients
 	s.broadcastToTask(taskID, SSEMessage{
-		Type: "status",
+		Type: MessageTypeStatus,
 		Data: TaskStatus{
 			ID:          taskID,
 			Status:      "running",
@@ -586,71 +968,184 @@ func (s *Server) handleExecuteTask(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 	log.Printf("已广播任务开始状态: %s, status: running", taskID)
+}
 
-	// Execute task in background with task-specific notifier
-	go func() {
-		ctx := context.Background()
+// runTask executes an announced task until it finishes or ctx is canceled,
+// then records the outcome and sends the final status to the SSE clients.
+// A panic of the task fails it with a *mcpagent.PanicError instead of
+// crashing the server.
+//
+// Returns:
+//   - string: Final status of the task
+//   - string: Last result of the task
+//   - error: Error the task failed with
+func (s *Server) runTask(ctx context.Context, taskID string, taskConfig *config.Config, history []*schema.Message, task string) (status string, result string, err error) {
+	defer func() {
+		// 记录结果和推送状态时发生的panic，任务已无法正常结束
+		if recovered := recover(); recovered != nil {
+			status, err = "error", mcpagent.RecoverPanic("任务"+taskID, recovered)
+		}
+	}()
+	ctx, untrack := s.trackTask(ctx, taskID)
+	defer untrack()
+
+	// Create a task-specific notifier that sends only to clients for this task
+	notifier := &BroadcastNotifier{server: s, taskID: taskID, toolFormats: s.toolOutputFormats(taskConfig)}
+	// 额外的通知在最终状态推送之后关闭，关闭时等待Webhook发送完队列中的事件
+	sinks := s.notificationSinks(taskID)
+	defer sinks.Close()
+	notify := sinks.Attach(notifier)
+	var audited mcpagent.AuditedNotify
+	if s.auditLogger != nil {
+		audited = mcpagent.NewAuditNotify(notify, s.auditLogger, taskID)
+		audited.OnTaskStart(task)
+		notify = audited
+	}
+
+	runner := s.agentRunner
+	if runner == nil {
+		runner = runAgent
+	}
+	if s.modelCache != nil {
+		ctx = config.WithModelCache(ctx, s.modelCache)
+	}
+	if s.db != nil && taskConfig.MCP.Credentials == nil {
+		// 服务器配置中的凭据引用在连接MCP服务器时解析
+		taskConfig.MCP.Credentials = s.credentialService.Lookup
+	}
+	if s.db != nil {
+		// diff_against_previous的上次结果保存在数据库中，而不是服务器的缓存目录
+		ctx = resultdiff.WithStore(ctx, s.toolResultService)
+		// 没有选择工具而被agent.require_tools拒绝的任务推荐缓存的工具
+		ctx = mcpagent.WithToolCatalog(ctx, s.cachedToolCatalog)
+	}
+	noteStore := s.notes.start(taskID, taskConfig)
+	if noteStore != nil {
+		// 任务运行时可以通过GET /api/task/{taskId}/notes读取笔记
+		ctx = notes.WithStore(ctx, noteStore)
+	}
+	if taskConfig.PlanMode && taskConfig.PlanApproval {
+		// 执行计划通过plan_approval事件推送，等待POST /api/task/{taskId}/plan批准
+		ctx = mcpagent.WithPlanApprover(ctx, s.planApprover(taskID))
+	}
+	// 没有选择工具时按任务内容选择缓存的工具
+	taskConfig, _ = s.autoSelectTools(ctx, taskConfig, task, notify)
+	s.confineReplay(taskID, taskConfig)
+	err = runRecovered(taskID, func() error {
+		return runner(ctx, taskConfig, history, task, notify)
+	})
+	// 任务已经结束，不能再取消
+	untrack()
+	if audited != nil {
+		audited.OnTaskEnd(err)
+	}
+	attachment.ScheduleCleanup(taskConfig.Attachments.Dir, taskConfig.Attachments.Retention())
+	s.scheduleArtifactCleanup(taskID, taskConfig.Artifacts.Retention())
+
+	status = taskResultStatus(err)
+	if status == "canceled" {
+		err = fmt.Errorf("任务已被用户中断: %w", err)
+	}
+	// 超时错误已经由mcpagent.Run通知
+	if err != nil && status != "timeout" {
+		notifier.OnError(err)
+		sinks.OnError(err)
+	}
+	result = notifier.finalResult()
+	answerModel := notifier.answerModel()
+	if noteStore != nil {
+		// 笔记在任务结束之前保存，读到结束状态的客户端也能读到笔记
+		s.recordTaskNotes(taskID, noteStore)
+		s.notes.finish(taskID, noteStore, taskEventRetention)
+	}
+	s.recordTaskFinish(taskID, status, result, answerModel, err)
+	s.status.taskFinished(taskID, status, err)
+
+	finalStatus := TaskStatus{
+		ID:           taskID,
+		Status:       status,
+		ErrorCode:    mcpagent.ErrorCode(err),
+		Model:        answerModel,
+		MissingTools: notifier.missingTools(),
+	}
+	if taskConfig.HasOutputSchema() {
+		valid := err == nil
+		finalStatus.OutputValid = &valid
+	}
+	s.broadcastToTask(taskID, SSEMessage{Type: MessageTypeStatus, Data: finalStatus})
+	return status, result, err
+}
 
-		// Create a task-specific notifier that sends only to clients for this task
-		notifier := &BroadcastNotifier{server: s, taskID: taskID}
+// trackTask returns a copy of ctx that handleCancelTask can cancel until the
+// returned function is called. Calling the function again has no effect.
+func (s *Server) trackTask(ctx context.Context, taskID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mutex.Lock()
+	if s.runningTasks == nil {
+		s.runningTasks = make(map[string]context.CancelFunc)
+	}
+	s.runningTasks[taskID] = cancel
+	s.mutex.Unlock()
+	return ctx, func() {
+		s.mutex.Lock()
+		delete(s.runningTasks, taskID)
+		s.mutex.Unlock()
+		cancel()
+	}
+}
 
-		// 使用前端提供的配置
-		err := mcpagent.Run(ctx, taskReq.Config, taskReq.Task, notifier)
+// cancelTask cancels a running task and reports whether it was running
+func (s *Server) cancelTask(taskID string) bool {
+	s.mutex.RLock()
+	cancel, ok := s.runningTasks[taskID]
+	s.mutex.RUnlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
 
-		status := "completed"
-		if err != nil {
-			status = "error"
-			notifier.OnError(err)
+// runRecovered calls run and converts a panic into the returned error
+func runRecovered(taskID string, run func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = mcpagent.RecoverPanic("任务"+taskID, recovered)
 		}
-
-		s.broadcastToTask(taskID, SSEMessage{
-			Type: "status",
-			Data: TaskStatus{
-				ID:     taskID,
-				Status: status,
-			},
-		})
 	}()
+	return run()
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "任务已开始执行",
-		"task_id": taskID,
-	})
+// taskResultStatus returns the final status of a task that finished with err
+func taskResultStatus(err error) string {
+	switch {
+	case err == nil:
+		return "completed"
+	case errors.Is(err, mcpagent.ErrTaskTimeout), errors.Is(err, mcpagent.ErrMaxDurationExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
 }
 
 // handleCancelTask handles POST /api/task/{taskId}/cancel
+// 取消运行中任务的上下文，正在进行的大模型请求和工具调用随之中止，
+// 任务结束时推送canceled状态。任务不存在或已经结束时返回404。
 func (s *Server) handleCancelTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["taskId"]
 
 	if taskID == "" {
-		http.Error(w, "任务ID不能为空", http.StatusBadRequest)
+		writeError(w, "任务ID不能为空", http.StatusBadRequest)
 		return
 	}
 
 	log.Printf("收到取消任务请求: %s", taskID)
-
-	// 向任务发送取消状态
-	s.broadcastToTask(taskID, SSEMessage{
-		Type: "status",
-		Data: TaskStatus{
-			ID:     taskID,
-			Status: "error", // 设置为error状态，这将触发客户端断开SSE连接
-		},
-	})
-
-	// 向任务发送通知消息
-	s.broadcastToTask(taskID, SSEMessage{
-		Type: "notify",
-		Data: NotifyEvent{
-			Type:      "error",
-			Timestamp: time.Now().UnixMilli(),
-			ID:        fmt.Sprintf("err_%d", time.Now().UnixNano()),
-			Error:     "任务已被用户中断",
-		},
-	})
+	if !s.cancelTask(taskID) {
+		writeError(w, "任务不存在或已经结束", http.StatusNotFound)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -663,86 +1158,135 @@ func (s *Server) handleCancelTask(w http.ResponseWriter, r *http.Request) {
 
 // OnMessage sends a message notification to task-specific connected clients
 func (b *BroadcastNotifier) OnMessage(msg string) {
-	b.server.broadcastToTask(b.taskID, SSEMessage{
-		Type: "notify",
-		Data: NotifyEvent{
-			Type:      "message",
-			Timestamp: time.Now().UnixMilli(),
-			ID:        fmt.Sprintf("msg_%d", time.Now().UnixNano()),
-			Content:   msg,
-		},
-	})
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newContentEvent(EventTypeMessage, msg)})
 }
 
 // OnThinking sends a thinking notification to task-specific connected clients
 func (b *BroadcastNotifier) OnThinking(msg string) {
-	b.server.broadcastToTask(b.taskID, SSEMessage{
-		Type: "notify",
-		Data: NotifyEvent{
-			Type:      "thinking",
-			Timestamp: time.Now().UnixMilli(),
-			ID:        fmt.Sprintf("think_%d", time.Now().UnixNano()),
-			Content:   msg,
-		},
-	})
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newContentEvent(EventTypeThinking, msg)})
 }
 
 // OnToolCall sends a tool call notification to task-specific connected clients
 func (b *BroadcastNotifier) OnToolCall(toolName string, params interface{}) {
-	b.server.broadcastToTask(b.taskID, SSEMessage{
-		Type: "notify",
-		Data: NotifyEvent{
-			Type:       "tool_call",
-			Timestamp:  time.Now().UnixMilli(),
-			ID:         fmt.Sprintf("tool_%d", time.Now().UnixNano()),
-			ToolName:   toolName,
-			Parameters: params,
-			Status:     "calling",
-		},
-	})
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newToolCallEvent("", toolName, params)})
 }
 
 // OnResult sends a result notification to task-specific connected clients
 func (b *BroadcastNotifier) OnResult(msg string) {
-	b.server.broadcastToTask(b.taskID, SSEMessage{
-		Type: "notify",
-		Data: NotifyEvent{
-			Type:      "result",
-			Timestamp: time.Now().UnixMilli(),
-			ID:        fmt.Sprintf("result_%d", time.Now().UnixNano()),
-			Content:   msg,
-		},
-	})
+	b.resultMu.Lock()
+	b.result = msg
+	verification := b.verification
+	b.verification = nil
+	b.resultMu.Unlock()
+
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newResultEvent(msg, verification)})
+}
+
+// OnStreamResult sends a chunk of the final answer to task-specific connected clients
+func (b *BroadcastNotifier) OnStreamResult(chunk string) {
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newResultChunkEvent(chunk)})
+}
+
+// OnVerification records the verdict of agent.verify_result for the result sent next
+func (b *BroadcastNotifier) OnVerification(verification mcpagent.Verification) {
+	b.resultMu.Lock()
+	defer b.resultMu.Unlock()
+	b.verification = &verification
+}
+
+// finalResult returns the last result sent by the task
+func (b *BroadcastNotifier) finalResult() string {
+	b.resultMu.Lock()
+	defer b.resultMu.Unlock()
+	return b.result
+}
+
+// OnAnswerModel records the model that produced the final result
+func (b *BroadcastNotifier) OnAnswerModel(model string) {
+	b.resultMu.Lock()
+	defer b.resultMu.Unlock()
+	b.model = model
+}
+
+// answerModel returns the model that produced the final result, empty if the task failed
+func (b *BroadcastNotifier) answerModel() string {
+	b.resultMu.Lock()
+	defer b.resultMu.Unlock()
+	return b.model
+}
+
+// OnMissingTools records the requested tools the task runs without
+func (b *BroadcastNotifier) OnMissingTools(tools []config.MissingTool) {
+	b.resultMu.Lock()
+	defer b.resultMu.Unlock()
+	b.missing = tools
+}
+
+// missingTools returns the requested tools the task ran without
+func (b *BroadcastNotifier) missingTools() []config.MissingTool {
+	b.resultMu.Lock()
+	defer b.resultMu.Unlock()
+	return b.missing
 }
 
 // OnError sends an error notification to task-specific connected clients
 func (b *BroadcastNotifier) OnError(err error) {
-	b.server.broadcastToTask(b.taskID, SSEMessage{
-		Type: "notify",
-		Data: NotifyEvent{
-			Type:      "error",
-			Timestamp: time.Now().UnixMilli(),
-			ID:        fmt.Sprintf("error_%d", time.Now().UnixNano()),
-			Error:     err.Error(),
-		},
-	})
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newErrorEvent(err.Error(), mcpagent.ErrorCode(err))})
+}
+
+// OnPlan sends the execution plan generated in plan mode to task-specific connected clients
+func (b *BroadcastNotifier) OnPlan(plan string) {
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newPlanEvent(plan)})
+}
+
+// OnThinkingWithCall sends a thinking notification linked to the following tool call
+func (b *BroadcastNotifier) OnThinkingWithCall(msg string, relatedCallID string) {
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newThinkingEvent(msg, relatedCallID)})
+}
+
+// OnToolCallWithID sends a tool call notification carrying its call ID
+func (b *BroadcastNotifier) OnToolCallWithID(callID string, toolName string, params any) {
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: newToolCallEvent(callID, toolName, params)})
+}
+
+// OnToolCallResult sends the result of a tool call with its duration to task-specific connected clients
+func (b *BroadcastNotifier) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+	b.OnToolResult("", toolName, result, err, duration)
+}
+
+// OnToolResult sends the result of a tool call carrying its call ID to task-specific connected clients
+func (b *BroadcastNotifier) OnToolResult(callID string, toolName string, result string, err error, duration time.Duration) {
+	toolResult := mcpagent.ToolCallResult{CallID: callID, ToolName: toolName, Result: result, Err: err, Duration: duration}
+	event := newToolCallResultEvent(toolResult, b.toolFormats.of(toolName), b.server.toolResultEventLimit)
+	b.server.broadcastToTask(b.taskID, SSEMessage{Type: MessageTypeNotify, Data: event})
+}
+
+// OnToolUsage records tool usage statistics asynchronously
+func (b *BroadcastNotifier) OnToolUsage(usage mcpagent.ToolUsage) {
+	if b.server.toolUsageRecorder != nil {
+		b.server.toolUsageRecorder.Record(usage.ToolKey, usage.Duration, usage.Err != nil)
+	}
 }
 
 // handleTestLLMConnection handles POST /api/llm/test
+// 带 ?with_tools=true 时额外发送一次带测试工具的请求，检查模型是否支持工具调用
 func (s *Server) handleTestLLMConnection(w http.ResponseWriter, r *http.Request) {
 	var llmConfig config.LLMConfig
 
 	if err := json.NewDecoder(r.Body).Decode(&llmConfig); err != nil {
-		http.Error(w, "解析LLM配置数据失败", http.StatusBadRequest)
+		writeError(w, "解析LLM配置数据失败", http.StatusBadRequest)
 		return
 	}
 
 	// 验证LLM配置
-	if err := llmConfig.Validate(); err != nil {
-		http.Error(w, fmt.Sprintf("LLM配置验证失败: %v", err), http.StatusBadRequest)
+	if errs := llmConfig.ValidateDetailed(); len(errs) > 0 {
+		writeValidationError(w, fmt.Sprintf("LLM配置验证失败: %v", errs.First()), errs)
 		return
 	}
 
+	// 返回实际使用的BaseURL，便于发现填写错误的地址
+	baseURL, warnings, _ := llmConfig.NormalizeBaseURL()
+
 	// 创建临时配置用于测试
 	testConfig := &config.Config{
 		LLM: llmConfig,
@@ -757,40 +1301,76 @@ func (s *Server) handleTestLLMConnection(w http.ResponseWriter, r *http.Request)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "LLM连接测试失败",
-			"error":   err.Error(),
+			"success":  false,
+			"message":  "LLM连接测试失败",
+			"error":    err.Error(),
+			"base_url": baseURL,
+			"warnings": warnings,
+		})
+		return
+	}
+
+	if r.URL.Query().Get("with_tools") != "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"message":  "LLM连接测试成功",
+			"base_url": baseURL,
+			"warnings": warnings,
 		})
 		return
 	}
 
-	// 尝试发送一个简单的测试消息
-	if model != nil {
-		// 这里可以添加更详细的测试逻辑，比如发送一个简单的消息
-		// 但为了避免复杂性，我们只检查模型是否能成功创建
+	if err := mcpagent.ProbeToolSupport(ctx, testConfig, model, nil, true); err != nil {
+		response := map[string]interface{}{
+			"success":  false,
+			"message":  "LLM连接测试失败",
+			"error":    err.Error(),
+			"base_url": baseURL,
+			"warnings": warnings,
+		}
+		if errors.Is(err, mcpagent.ErrModelNoToolSupport) {
+			response["message"] = "模型不支持工具调用"
+			response["tool_support"] = false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "LLM连接测试成功",
+		"success":      true,
+		"message":      "LLM连接测试成功，模型支持工具调用",
+		"tool_support": true,
+		"base_url":     baseURL,
+		"warnings":     warnings,
 	})
 }
 
 // LLM配置管理API处理函数
 
 // handleListLLMConfigs handles GET /api/llm/configs
+// 支持 ?page=、?page_size=、?sort= 和 ?q= 参数，不带参数时按名称返回全部配置
 func (s *Server) handleListLLMConfigs(w http.ResponseWriter, r *http.Request) {
-	configs, err := s.llmConfigService.ListConfigs()
+	query, _, err := parseListQuery(r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取LLM配置列表失败: %v", err), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	configs, pagination, err := s.llmConfigService.QueryConfigs(query)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取LLM配置列表失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    configs,
+		"success":    true,
+		"data":       configs,
+		"pagination": pagination,
 	})
 }
 
@@ -799,16 +1379,12 @@ func (s *Server) handleCreateLLMConfig(w http.ResponseWriter, r *http.Request) {
 	var config models.LLMConfigModel
 
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		http.Error(w, "解析LLM配置数据失败", http.StatusBadRequest)
+		writeError(w, "解析LLM配置数据失败", http.StatusBadRequest)
 		return
 	}
 
 	if err := s.llmConfigService.CreateConfig(&config); err != nil {
-		if err == models.ErrLLMConfigNameExists {
-			http.Error(w, err.Error(), http.StatusConflict)
-		} else {
-			http.Error(w, fmt.Sprintf("创建LLM配置失败: %v", err), http.StatusBadRequest)
-		}
+		writeModelError(w, err, fmt.Sprintf("创建LLM配置失败: %v", err), http.StatusBadRequest)
 		return
 	}
 
@@ -825,17 +1401,13 @@ func (s *Server) handleGetLLMConfig(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的配置ID", http.StatusBadRequest)
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
 		return
 	}
 
 	config, err := s.llmConfigService.GetConfig(uint(id))
 	if err != nil {
-		if err == models.ErrLLMConfigNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("获取LLM配置失败: %v", err), http.StatusInternalServerError)
-		}
+		writeModelError(w, err, fmt.Sprintf("获取LLM配置失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -851,26 +1423,22 @@ func (s *Server) handleUpdateLLMConfig(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的配置ID", http.StatusBadRequest)
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
 		return
 	}
 
 	var updates models.LLMConfigModel
 	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-		http.Error(w, "解析LLM配置数据失败", http.StatusBadRequest)
+		writeError(w, "解析LLM配置数据失败", http.StatusBadRequest)
 		return
 	}
 
+	previous, _ := s.llmConfigService.GetConfig(uint(id))
 	if err := s.llmConfigService.UpdateConfig(uint(id), &updates); err != nil {
-		if err == models.ErrLLMConfigNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if err == models.ErrLLMConfigNameExists {
-			http.Error(w, err.Error(), http.StatusConflict)
-		} else {
-			http.Error(w, fmt.Sprintf("更新LLM配置失败: %v", err), http.StatusBadRequest)
-		}
+		writeModelError(w, err, fmt.Sprintf("更新LLM配置失败: %v", err), http.StatusBadRequest)
 		return
 	}
+	s.invalidateModels(previous)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -884,18 +1452,16 @@ func (s *Server) handleDeleteLLMConfig(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的配置ID", http.StatusBadRequest)
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
 		return
 	}
 
+	previous, _ := s.llmConfigService.GetConfig(uint(id))
 	if err := s.llmConfigService.DeleteConfig(uint(id)); err != nil {
-		if err == models.ErrLLMConfigNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("删除LLM配置失败: %v", err), http.StatusBadRequest)
-		}
+		writeModelError(w, err, fmt.Sprintf("删除LLM配置失败: %v", err), http.StatusBadRequest)
 		return
 	}
+	s.invalidateModels(previous)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -904,21 +1470,26 @@ func (s *Server) handleDeleteLLMConfig(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// invalidateModels removes the cached models created from a stored LLM
+// configuration that was updated or deleted
+func (s *Server) invalidateModels(previous *models.LLMConfigModel) {
+	if s.modelCache == nil || previous == nil {
+		return
+	}
+	s.modelCache.InvalidateLLM(services.LLMConfigToConfig(previous))
+}
+
 // handleSetDefaultLLMConfig handles POST /api/llm/configs/{id}/default
 func (s *Server) handleSetDefaultLLMConfig(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的配置ID", http.StatusBadRequest)
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
 		return
 	}
 
 	if err := s.llmConfigService.SetDefaultConfig(uint(id)); err != nil {
-		if err == models.ErrLLMConfigNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("设置默认LLM配置失败: %v", err), http.StatusInternalServerError)
-		}
+		writeModelError(w, err, fmt.Sprintf("设置默认LLM配置失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -930,49 +1501,49 @@ func (s *Server) handleSetDefaultLLMConfig(w http.ResponseWriter, r *http.Reques
 }
 
 // handleGetMCPTools handles POST /api/mcp/tools
+// Nested parameters are expanded up to ?param_depth= levels.
 func (s *Server) handleGetMCPTools(w http.ResponseWriter, r *http.Request) {
-	var req MCPToolsRequest
+	depth, err := parameterDepth(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	var req MCPToolsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "解析MCP服务器配置数据失败", http.StatusBadRequest)
+		writeError(w, "解析MCP服务器配置数据失败", http.StatusBadRequest)
 		return
 	}
 
-	// 创建MCPSettings
-	settings := &einomcphost.MCPSettings{
-		MCPServers: req.MCPServers,
+	// 按合并策略合并配置文件和请求中的服务器
+	mcpConfig := config.MCPConfig{
+		ConfigFile:    req.ConfigFile,
+		MCPServers:    req.MCPServers,
+		MergeStrategy: req.MergeStrategy,
 	}
-
-	// 使用连接池获取MCP服务器连接
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// 获取连接池
-	pool := einomcphost.GetConnectionPool()
-
-	// 获取或创建连接
-	hub, err := pool.GetHub(ctx, settings)
+	servers, warnings, err := mcpConfig.ResolveServers()
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(MCPToolsResponse{
 			Success: false,
-			Message: "连接MCP服务器失败",
+			Message: "读取MCP服务器配置失败",
 			Error:   err.Error(),
 		})
 		return
 	}
-	// 注意：不再直接调用hub.CloseServers()，而是在使用完后释放引用
-	defer pool.ReleaseHub(settings)
+	for _, warning := range warnings {
+		log.Printf("警告: %s", warning)
+	}
 
-	// 获取所有可用工具，并记录每个工具来自哪个服务器
-	var tools []MCPToolInfo
+	// 逐个服务器获取工具，服务器名称来自配置而不是从工具键中解析
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	// 获取工具映射
-	toolsMap, err := hub.GetToolsMap(ctx)
+	details, err := services.ListToolDetails(ctx, servers, s.serverToolLister)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(MCPToolsResponse{
 			Success: false,
 			Message: "获取工具列表失败",
@@ -981,164 +1552,62 @@ func (s *Server) handleGetMCPTools(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 处理工具信息
-	for toolKey, toolInfo := range toolsMap {
-		// 从工具键中提取服务器名称
-		parts := strings.SplitN(toolKey, "_", 2)
-		serverName := parts[0]
-
-		tools = append(tools, MCPToolInfo{
-			Name:        toolInfo.Name,
-			Description: toolInfo.Desc,
-			Server:      serverName,
-		})
+	tools := make([]MCPToolInfo, 0, len(details))
+	for _, detail := range details {
+		tools = append(tools, detailToolInfo(detail, depth))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(MCPToolsResponse{
-		Success: true,
-		Message: fmt.Sprintf("成功获取 %d 个工具", len(tools)),
-		Tools:   tools,
+		Success:  true,
+		Message:  fmt.Sprintf("成功获取 %d 个工具", len(tools)),
+		Tools:    tools,
+		Warnings: warnings,
 	})
 }
 
-// handleGetMCPToolsFromDB handles GET /api/mcp/tools/configured
-func (s *Server) handleGetMCPToolsFromDB(w http.ResponseWriter, r *http.Request) {
-	// 获取数据库中的所有工具，包括内置工具
-	toolsInfo, err := s.mcpToolService.GetToolsInfo()
+// MCP服务器配置管理API处理函数
+
+// handleListMCPServerConfigs handles GET /api/mcp/servers
+// 支持 ?page=、?page_size=、?sort= 和 ?q= 参数，不带参数时按名称返回全部配置
+func (s *Server) handleListMCPServerConfigs(w http.ResponseWriter, r *http.Request) {
+	query, _, err := parseListQuery(r)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(MCPToolsResponse{
-			Success: false,
-			Message: "获取工具列表失败",
-			Error:   err.Error(),
-		})
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 转换为API响应格式
-	var tools []MCPToolInfo
-	for _, toolInfo := range toolsInfo {
-		tools = append(tools, MCPToolInfo{
-			Name:        toolInfo.Name,
-			Description: toolInfo.Description,
-			Server:      toolInfo.Server,
-		})
+	configs, pagination, err := s.mcpServerConfigService.QueryConfigs(query)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取MCP服务器配置列表失败: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	// 如果没有工具，还需要连接服务器获取
-	if len(tools) == 0 {
-		// 从数据库获取所有活跃的MCP服务器配置
-		configs, err := s.mcpServerConfigService.GetAllActiveConfigs()
-		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(MCPToolsResponse{
-				Success: false,
-				Message: "获取MCP服务器配置失败",
-				Error:   err.Error(),
-			})
-			return
-		}
-
-		if len(configs) == 0 {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(MCPToolsResponse{
-				Success: true,
-				Message: "没有找到活跃的MCP服务器配置或工具",
-				Tools:   []MCPToolInfo{},
-			})
-			return
-		}
-
-		// 将数据库配置转换为mcphost.ServerConfig格式
-		mcpServers := make(map[string]*einomcphost.ServerConfig)
-		for name, config := range configs {
-			serverConfig, err := config.ToServerConfig()
-			if err != nil {
-				log.Printf("转换服务器配置失败 %s: %v", name, err)
-				continue
-			}
-			mcpServers[name] = &serverConfig
-		}
-
-		// 创建MCPSettings
-		settings := &einomcphost.MCPSettings{
-			MCPServers: mcpServers,
-		}
-
-		// 使用连接池获取MCP服务器连接
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		// 获取连接池
-		pool := einomcphost.GetConnectionPool()
-
-		// 获取或创建连接
-		hub, err := pool.GetHub(ctx, settings)
-		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(MCPToolsResponse{
-				Success: false,
-				Message: "连接MCP服务器失败",
-				Error:   err.Error(),
-			})
-			return
-		}
-		// 注意：不再直接调用hub.CloseServers()，而是在使用完后释放引用
-		defer pool.ReleaseHub(settings)
-
-		// 获取工具映射
-		toolsMap, err := hub.GetToolsMap(ctx)
-		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(MCPToolsResponse{
-				Success: false,
-				Message: "获取工具列表失败",
-				Error:   err.Error(),
-			})
-			return
-		}
-
-		// 处理工具信息
-		for toolKey, toolInfo := range toolsMap {
-			// 从工具键中提取服务器名称
-			parts := strings.SplitN(toolKey, "_", 2)
-			serverName := parts[0]
-
-			tools = append(tools, MCPToolInfo{
-				Name:        toolInfo.Name,
-				Description: toolInfo.Desc,
-				Server:      serverName,
-			})
-		}
+	serverIDs := make([]uint, 0, len(configs))
+	for _, c := range configs {
+		serverIDs = append(serverIDs, c.ID)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(MCPToolsResponse{
-		Success: true,
-		Message: fmt.Sprintf("成功获取 %d 个工具", len(tools)),
-		Tools:   tools,
-	})
-}
-
-// MCP服务器配置管理API处理函数
-
-// handleListMCPServerConfigs handles GET /api/mcp/servers
-func (s *Server) handleListMCPServerConfigs(w http.ResponseWriter, r *http.Request) {
-	configs, err := s.mcpServerConfigService.ListConfigs()
+	failures, err := s.healthService.ConsecutiveFailuresMap(serverIDs)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取MCP服务器配置列表失败: %v", err), http.StatusInternalServerError)
-		return
+		// 健康记录只是附加信息，获取失败不影响列表
+		log.Printf("获取服务器健康记录失败: %v", err)
+	}
+
+	data := make([]MCPServerConfigResponse, 0, len(configs))
+	for _, c := range configs {
+		c.RedactHeaders()
+		data = append(data, MCPServerConfigResponse{
+			MCPServerConfigModel: c,
+			ConsecutiveFailures:  failures[c.ID],
+			ServerInfo:           s.mcpPool.CachedServerInfo(c.Name),
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    configs,
+		"success":    true,
+		"data":       data,
+		"pagination": pagination,
 	})
 }
 
@@ -1155,7 +1624,12 @@ type CreateMCPServerConfigRequest struct {
 	URL     string   `json:"url"`
 	Headers []string `json:"headers"`
 	// Common fields
-	Disabled bool `json:"disabled"`
+	NamePrefix     string `json:"name_prefix"`     // 向大模型展示的工具名前缀
+	MaxConcurrency int    `json:"max_concurrency"` // 同时执行的工具调用数，0表示按传输方式使用默认值
+	Disabled       bool   `json:"disabled"`
+	// Connection test
+	TestConnection bool `json:"test_connection"` // 保存前连接服务器测试，同 ?test=true
+	SaveAnyway     bool `json:"save_anyway"`     // 连接测试失败时仍然保存，失败原因记录在last_test_error中
 }
 
 // handleCreateMCPServerConfig handles POST /api/mcp/servers
@@ -1163,7 +1637,7 @@ func (s *Server) handleCreateMCPServerConfig(w http.ResponseWriter, r *http.Requ
 	var req CreateMCPServerConfigRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "解析MCP服务器配置数据失败", http.StatusBadRequest)
+		writeError(w, "解析MCP服务器配置数据失败", http.StatusBadRequest)
 		return
 	}
 
@@ -1175,101 +1649,119 @@ func (s *Server) handleCreateMCPServerConfig(w http.ResponseWriter, r *http.Requ
 
 	// 创建数据库模型
 	config := &models.MCPServerConfigModel{
-		Name:          req.Name,
-		Description:   req.Description,
-		TransportType: transportType,
-		Command:       req.Command,
-		URL:           req.URL,
-		Disabled:      req.Disabled,
-		IsActive:      true,
+		Name:           req.Name,
+		Description:    req.Description,
+		TransportType:  transportType,
+		Command:        req.Command,
+		URL:            req.URL,
+		NamePrefix:     req.NamePrefix,
+		MaxConcurrency: req.MaxConcurrency,
+		Disabled:       req.Disabled,
+		IsActive:       true,
 	}
 
 	// 根据传输类型设置相应字段
 	switch transportType {
 	case "stdio":
 		if err := config.SetArgs(req.Args); err != nil {
-			http.Error(w, fmt.Sprintf("设置参数失败: %v", err), http.StatusBadRequest)
+			writeError(w, fmt.Sprintf("设置参数失败: %v", err), http.StatusBadRequest)
 			return
 		}
 
 		if err := config.SetEnv(req.Env); err != nil {
-			http.Error(w, fmt.Sprintf("设置环境变量失败: %v", err), http.StatusBadRequest)
+			writeError(w, fmt.Sprintf("设置环境变量失败: %v", err), http.StatusBadRequest)
 			return
 		}
 	case "sse", "http":
 		if err := config.SetHeaders(req.Headers); err != nil {
-			http.Error(w, fmt.Sprintf("设置HTTP头部失败: %v", err), http.StatusBadRequest)
+			writeError(w, fmt.Sprintf("设置HTTP头部失败: %v", err), http.StatusBadRequest)
 			return
 		}
 	}
 
+	// 与创建MCP连接时使用相同的校验，避免保存无法连接的配置
+	if _, err := config.ToServerConfig(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.checkCredentialReferences(w, config) {
+		return
+	}
+	testError, ok := s.checkServerConnection(w, r, &req, config)
+	if !ok {
+		return
+	}
+	config.LastTestError = testError
+
 	if err := s.mcpServerConfigService.CreateConfig(config); err != nil {
-		if err == models.ErrMCPServerConfigNameExists {
-			http.Error(w, err.Error(), http.StatusConflict)
-		} else {
-			http.Error(w, fmt.Sprintf("创建MCP服务器配置失败: %v", err), http.StatusBadRequest)
-		}
+		writeModelError(w, err, fmt.Sprintf("创建MCP服务器配置失败: %v", err), http.StatusBadRequest)
 		return
 	}
 
 	// 异步同步工具，不阻塞响应
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		if err := s.mcpToolService.SyncToolsForServer(ctx, config); err != nil {
-			log.Printf("创建服务器后同步工具失败 %s: %v", config.Name, err)
-		} else {
-			log.Printf("成功为新创建的服务器 %s 同步工具", config.Name)
-		}
-	}()
+	go s.syncNewServerTools(config)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"message": "MCP服务器配置创建成功",
-		"data":    config,
+		"data":    redactedServerConfig(config),
 	})
 }
 
+// syncNewServerTools synchronizes the tools of a newly created server
+func (s *Server) syncNewServerTools(config *models.MCPServerConfigModel) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if report, err := s.mcpToolService.SyncToolsForServer(ctx, config); err != nil {
+		log.Printf("创建服务器后同步工具失败 %s: %v", config.Name, err)
+	} else {
+		log.Printf("成功为新创建的服务器 %s 同步工具: %s", config.Name, report)
+	}
+}
+
 // handleGetMCPServerConfig handles GET /api/mcp/servers/{id}
 func (s *Server) handleGetMCPServerConfig(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的配置ID", http.StatusBadRequest)
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
 		return
 	}
 
 	config, err := s.mcpServerConfigService.GetConfig(uint(id))
 	if err != nil {
-		if err == models.ErrMCPServerConfigNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("获取MCP服务器配置失败: %v", err), http.StatusInternalServerError)
-		}
+		writeModelError(w, err, fmt.Sprintf("获取MCP服务器配置失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"data":    config,
+		"data":    redactedServerConfig(config),
 	})
 }
 
+// redactedServerConfig returns a copy of config with the header values hidden
+func redactedServerConfig(config *models.MCPServerConfigModel) *models.MCPServerConfigModel {
+	redacted := *config
+	redacted.RedactHeaders()
+	return &redacted
+}
+
 // handleUpdateMCPServerConfig handles PUT /api/mcp/servers/{id}
 func (s *Server) handleUpdateMCPServerConfig(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的配置ID", http.StatusBadRequest)
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
 		return
 	}
 
 	var req CreateMCPServerConfigRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "解析MCP服务器配置数据失败", http.StatusBadRequest)
+		writeError(w, "解析MCP服务器配置数据失败", http.StatusBadRequest)
 		return
 	}
 
@@ -1281,54 +1773,83 @@ func (s *Server) handleUpdateMCPServerConfig(w http.ResponseWriter, r *http.Requ
 
 	// 创建更新模型
 	updates := &models.MCPServerConfigModel{
-		Name:          req.Name,
-		Description:   req.Description,
-		TransportType: transportType,
-		Command:       req.Command,
-		URL:           req.URL,
-		Disabled:      req.Disabled,
-		IsActive:      true,
+		Name:           req.Name,
+		Description:    req.Description,
+		TransportType:  transportType,
+		Command:        req.Command,
+		URL:            req.URL,
+		NamePrefix:     req.NamePrefix,
+		MaxConcurrency: req.MaxConcurrency,
+		Disabled:       req.Disabled,
+		IsActive:       true,
 	}
 
 	// 根据传输类型设置相应字段
 	switch transportType {
 	case "stdio":
 		if err := updates.SetArgs(req.Args); err != nil {
-			http.Error(w, fmt.Sprintf("设置参数失败: %v", err), http.StatusBadRequest)
+			writeError(w, fmt.Sprintf("设置参数失败: %v", err), http.StatusBadRequest)
 			return
 		}
 
 		if err := updates.SetEnv(req.Env); err != nil {
-			http.Error(w, fmt.Sprintf("设置环境变量失败: %v", err), http.StatusBadRequest)
+			writeError(w, fmt.Sprintf("设置环境变量失败: %v", err), http.StatusBadRequest)
 			return
 		}
 	case "sse", "http":
 		if err := updates.SetHeaders(req.Headers); err != nil {
-			http.Error(w, fmt.Sprintf("设置HTTP头部失败: %v", err), http.StatusBadRequest)
+			writeError(w, fmt.Sprintf("设置HTTP头部失败: %v", err), http.StatusBadRequest)
 			return
 		}
 	}
 
-	if err := s.mcpServerConfigService.UpdateConfig(uint(id), updates); err != nil {
-		if err == models.ErrMCPServerConfigNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else if err == models.ErrMCPServerConfigNameExists {
-			http.Error(w, err.Error(), http.StatusConflict)
-		} else {
-			http.Error(w, fmt.Sprintf("更新MCP服务器配置失败: %v", err), http.StatusBadRequest)
+	// 与创建MCP连接时使用相同的校验，避免保存无法连接的配置
+	if _, err := updates.ToServerConfig(); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.checkCredentialReferences(w, updates) {
+		return
+	}
+
+	existing, _ := s.mcpServerConfigService.GetConfig(uint(id))
+	// 以隐藏形式发回的HTTP头部使用保存的值测试连接
+	tested := *updates
+	if existing != nil && wantsConnectionTest(r, &req) {
+		if err := tested.RestoreRedactedHeaders(existing); err != nil {
+			writeModelError(w, err, fmt.Sprintf("更新MCP服务器配置失败: %v", err), http.StatusBadRequest)
+			return
 		}
+	}
+	testError, ok := s.checkServerConnection(w, r, &req, &tested)
+	if !ok {
 		return
 	}
 
+	if err := s.mcpServerConfigService.UpdateConfig(uint(id), updates); err != nil {
+		writeModelError(w, err, fmt.Sprintf("更新MCP服务器配置失败: %v", err), http.StatusBadRequest)
+		return
+	}
+	if wantsConnectionTest(r, &req) {
+		if err := s.mcpServerConfigService.UpdateLastTestError(uint(id), testError); err != nil {
+			log.Printf("保存服务器 %s 的连接测试结果失败: %v", updates.Name, err)
+		}
+	}
+	// 服务器定义已变化，缓存的服务器信息不再有效
+	if existing != nil {
+		s.mcpPool.ForgetServerInfo(existing.Name)
+	}
+	s.mcpPool.ForgetServerInfo(updates.Name)
+
 	// 异步同步工具，不阻塞响应
 	go func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		if err := s.mcpToolService.SyncToolsForServer(ctx, updates); err != nil {
+		if report, err := s.mcpToolService.SyncToolsForServer(ctx, updates); err != nil {
 			log.Printf("更新服务器后同步工具失败 %s: %v", updates.Name, err)
 		} else {
-			log.Printf("成功为更新后的服务器 %s 同步工具", updates.Name)
+			log.Printf("成功为更新后的服务器 %s 同步工具: %s", updates.Name, report)
 		}
 	}()
 
@@ -1344,7 +1865,7 @@ func (s *Server) handleDeleteMCPServerConfig(w http.ResponseWriter, r *http.Requ
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的配置ID", http.StatusBadRequest)
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
 		return
 	}
 
@@ -1354,14 +1875,14 @@ func (s *Server) handleDeleteMCPServerConfig(w http.ResponseWriter, r *http.Requ
 		// 不阻塞服务器删除，继续执行
 	}
 
+	existing, _ := s.mcpServerConfigService.GetConfig(uint(id))
 	if err := s.mcpServerConfigService.DeleteConfig(uint(id)); err != nil {
-		if err == models.ErrMCPServerConfigNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("删除MCP服务器配置失败: %v", err), http.StatusBadRequest)
-		}
+		writeModelError(w, err, fmt.Sprintf("删除MCP服务器配置失败: %v", err), http.StatusBadRequest)
 		return
 	}
+	if existing != nil {
+		s.mcpPool.ForgetServerInfo(existing.Name)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1370,91 +1891,20 @@ func (s *Server) handleDeleteMCPServerConfig(w http.ResponseWriter, r *http.Requ
 	})
 }
 
-// handleSyncMCPTools handles POST /api/mcp/tools/sync
-// 同步所有活跃服务器的工具到数据库
-func (s *Server) handleSyncMCPTools(w http.ResponseWriter, r *http.Request) {
-	// 获取所有活跃的MCP服务器配置
-	configs, err := s.mcpServerConfigService.GetAllActiveConfigs()
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(MCPToolsResponse{
-			Success: false,
-			Message: "获取MCP服务器配置失败",
-			Error:   err.Error(),
-		})
-		return
-	}
-
-	if len(configs) == 0 {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(MCPToolsResponse{
-			Success: true,
-			Message: "没有找到活跃的MCP服务器配置",
-			Tools:   []MCPToolInfo{},
-		})
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	var totalTools int
-	var errors []string
-
-	// 同步每个服务器的工具
-	for _, config := range configs {
-		err := s.mcpToolService.SyncToolsForServer(ctx, &config)
-		if err != nil {
-			errorMsg := fmt.Sprintf("同步服务器 %s 失败: %v", config.Name, err)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
-			continue
-		}
-
-		// 获取该服务器的工具数量
-		tools, err := s.mcpToolService.GetToolsByServerID(config.ID)
-		if err == nil {
-			totalTools += len(tools)
-		}
-	}
-
-	message := fmt.Sprintf("同步完成，共获取 %d 个工具", totalTools)
-	if len(errors) > 0 {
-		message += fmt.Sprintf("，%d 个服务器同步失败", len(errors))
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	response := MCPToolsResponse{
-		Success: len(errors) < len(configs), // 如果至少有一个服务器同步成功，就算成功
-		Message: message,
-	}
-
-	if len(errors) > 0 {
-		response.Error = fmt.Sprintf("部分服务器同步失败: %s", errors[0])
-	}
-
-	json.NewEncoder(w).Encode(response)
-}
-
 // handleSyncMCPToolsForServer handles POST /api/mcp/tools/sync/{id}
 // 同步指定服务器的工具到数据库
 func (s *Server) handleSyncMCPToolsForServer(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的服务器ID", http.StatusBadRequest)
+		writeError(w, "无效的服务器ID", http.StatusBadRequest)
 		return
 	}
 
 	// 获取服务器配置
 	config, err := s.mcpServerConfigService.GetConfig(uint(id))
 	if err != nil {
-		if err == models.ErrMCPServerConfigNotFound {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("获取MCP服务器配置失败: %v", err), http.StatusInternalServerError)
-		}
+		writeModelError(w, err, fmt.Sprintf("获取MCP服务器配置失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
@@ -1462,7 +1912,7 @@ func (s *Server) handleSyncMCPToolsForServer(w http.ResponseWriter, r *http.Requ
 	defer cancel()
 
 	// 同步工具
-	err = s.mcpToolService.SyncToolsForServer(ctx, config)
+	report, err := s.mcpToolService.SyncToolsForServer(ctx, config)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
@@ -1474,15 +1924,10 @@ func (s *Server) handleSyncMCPToolsForServer(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// 获取同步后的工具数量
-	tools, err := s.mcpToolService.GetToolsByServerID(config.ID)
-	if err != nil {
-		log.Printf("获取服务器 %s 的工具数量失败: %v", config.Name, err)
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(MCPToolsResponse{
 		Success: true,
-		Message: fmt.Sprintf("成功同步服务器 %s 的 %d 个工具", config.Name, len(tools)),
+		Message: fmt.Sprintf("成功同步服务器 %s 的 %d 个工具", config.Name, report.Total()),
+		Report:  report,
 	})
 }
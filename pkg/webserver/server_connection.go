@@ -0,0 +1,59 @@
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+)
+
+// connectionTestTimeout is how long the connection test of a server definition may take
+const connectionTestTimeout = 10 * time.Second
+
+// wantsConnectionTest reports whether a create or update request asks to
+// connect to the server before saving, with ?test=true or "test_connection": true
+func wantsConnectionTest(r *http.Request, req *CreateMCPServerConfigRequest) bool {
+	if req.TestConnection {
+		return true
+	}
+	test, err := strconv.ParseBool(r.URL.Query().Get("test"))
+	return err == nil && test
+}
+
+// testServerConnection connects to server once and lists its tools within connectionTestTimeout
+func (s *Server) testServerConnection(ctx context.Context, server *models.MCPServerConfigModel) error {
+	probe := s.healthProbe
+	if probe == nil {
+		probe = probeMCPServer
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectionTestTimeout)
+	defer cancel()
+	return probe(ctx, server)
+}
+
+// checkServerConnection runs the connection test a request asks for. A
+// failed test is written as 422 and false is returned, unless the request
+// sets save_anyway: then the error is returned to be stored as last_test_error.
+//
+// Returns:
+//   - string: Error of the failed test to store, empty if the test passed or was not requested
+//   - bool: Whether the server may be saved
+func (s *Server) checkServerConnection(w http.ResponseWriter, r *http.Request, req *CreateMCPServerConfigRequest, server *models.MCPServerConfigModel) (string, bool) {
+	if !wantsConnectionTest(r, req) {
+		return "", true
+	}
+	err := s.testServerConnection(r.Context(), server)
+	if err == nil {
+		return "", true
+	}
+	if req.SaveAnyway {
+		log.Printf("MCP服务器 %s 连接测试失败，仍然保存: %v", server.Name, err)
+		return err.Error(), true
+	}
+	writeError(w, fmt.Sprintf("MCP服务器 %s 连接测试失败: %v", server.Name, err), http.StatusUnprocessableEntity)
+	return "", false
+}
@@ -0,0 +1,126 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bogusServerRequest 返回启动命令不存在的stdio服务器
+func bogusServerRequest(name string) CreateMCPServerConfigRequest {
+	return CreateMCPServerConfigRequest{Name: name, TransportType: "stdio", Command: "mcpagent-command-that-does-not-exist"}
+}
+
+func TestCreateMCPServerConnectionTest(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	t.Run("连接失败时不保存", func(t *testing.T) {
+		w := postJSON(t, server, "/api/mcp/servers?test=true", bogusServerRequest("bogus"))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "MCP服务器 bogus 连接测试失败")
+		assert.Contains(t, w.Body.String(), errCodeConnectionFailed)
+		_, err := server.mcpServerConfigService.GetConfigByName("bogus")
+		assert.ErrorIs(t, err, models.ErrMCPServerConfigNotFound)
+
+		// 请求体中的test_connection与查询参数等效
+		req := bogusServerRequest("bogus")
+		req.TestConnection = true
+		w = postJSON(t, server, "/api/mcp/servers", req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("仍然保存时记录错误", func(t *testing.T) {
+		req := bogusServerRequest("bogus-saved")
+		req.TestConnection = true
+		req.SaveAnyway = true
+		w := postJSON(t, server, "/api/mcp/servers", req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		stored, err := server.mcpServerConfigService.GetConfigByName("bogus-saved")
+		require.NoError(t, err)
+		assert.NotEmpty(t, stored.LastTestError)
+
+		// 后台同步工具失败的原因记录在last_sync_error中，列表接口可以展示
+		require.Eventually(t, func() bool {
+			stored, err := server.mcpServerConfigService.GetConfig(stored.ID)
+			return err == nil && stored.LastSyncError != ""
+		}, 10*time.Second, 20*time.Millisecond)
+
+		w = httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/mcp/servers", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var list struct {
+			Data []MCPServerConfigResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		var listed *MCPServerConfigResponse
+		for i := range list.Data {
+			if list.Data[i].Name == "bogus-saved" {
+				listed = &list.Data[i]
+			}
+		}
+		require.NotNil(t, listed)
+		assert.Equal(t, stored.LastTestError, listed.LastTestError)
+		assert.NotEmpty(t, listed.LastSyncError)
+	})
+
+	t.Run("不测试时直接保存", func(t *testing.T) {
+		w := postJSON(t, server, "/api/mcp/servers", bogusServerRequest("bogus-untested"))
+		require.Equal(t, http.StatusOK, w.Code)
+		stored, err := server.mcpServerConfigService.GetConfigByName("bogus-untested")
+		require.NoError(t, err)
+		assert.Empty(t, stored.LastTestError)
+	})
+}
+
+func TestUpdateMCPServerConnectionTest(t *testing.T) {
+	server := setupTaskTestServer(t)
+	var probeErr error
+	server.healthProbe = func(ctx context.Context, s *models.MCPServerConfigModel) error {
+		return probeErr
+	}
+
+	stored := &models.MCPServerConfigModel{Name: "hosted", TransportType: "sse", URL: "http://127.0.0.1:1/sse"}
+	require.NoError(t, server.mcpServerConfigService.CreateConfig(stored))
+	put := func(req CreateMCPServerConfigRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(req)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("PUT", fmt.Sprintf("/api/mcp/servers/%d?test=true", stored.ID), bytes.NewReader(body)))
+		return w
+	}
+
+	// 测试失败时不修改保存的配置
+	probeErr = errors.New("连接被拒绝")
+	w := put(CreateMCPServerConfigRequest{Name: "hosted", TransportType: "sse", URL: "http://127.0.0.1:2/sse"})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "连接被拒绝")
+	unchanged, err := server.mcpServerConfigService.GetConfig(stored.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:1/sse", unchanged.URL)
+
+	w = put(CreateMCPServerConfigRequest{Name: "hosted", TransportType: "sse", URL: "http://127.0.0.1:2/sse", SaveAnyway: true})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	updated, err := server.mcpServerConfigService.GetConfig(stored.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:2/sse", updated.URL)
+	assert.Equal(t, "连接被拒绝", updated.LastTestError)
+
+	// 测试通过后清除上次的错误
+	probeErr = nil
+	w = put(CreateMCPServerConfigRequest{Name: "hosted", TransportType: "sse", URL: "http://127.0.0.1:3/sse"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	updated, err = server.mcpServerConfigService.GetConfig(stored.ID)
+	require.NoError(t, err)
+	assert.Empty(t, updated.LastTestError)
+}
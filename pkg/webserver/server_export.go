@@ -0,0 +1,165 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+// exportedServerConfig converts server to its mcpservers.json entry. Env
+// values are redacted unless includeSecrets, credential references are kept
+// either way. Description, name prefix, concurrency and headers have no
+// mcpservers.json equivalent and are not exported.
+func exportedServerConfig(server models.MCPServerConfigModel, includeSecrets bool) (*mcphost.ServerConfig, error) {
+	if !includeSecrets {
+		server.RedactEnv()
+	}
+	serverConfig, err := server.ToServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &serverConfig, nil
+}
+
+// writeMCPSettings writes settings as an indented mcpservers.json document,
+// ready to be copied into the file used by the CLI
+func writeMCPSettings(w http.ResponseWriter, settings *mcphost.MCPSettings) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(settings)
+}
+
+// handleExportMCPServerConfig handles GET /api/mcp/servers/{id}/export
+// 返回只包含该服务器的mcpservers.json文档，环境变量的值默认隐藏，?include_secrets=true 时返回原值
+func (s *Server) handleExportMCPServerConfig(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
+		return
+	}
+
+	server, err := s.mcpServerConfigService.GetConfig(uint(id))
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取MCP服务器配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	serverConfig, err := exportedServerConfig(*server, r.URL.Query().Get("include_secrets") == "true")
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("导出MCP服务器配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeMCPSettings(w, &mcphost.MCPSettings{
+		MCPServers: map[string]*mcphost.ServerConfig{server.Name: serverConfig},
+	})
+}
+
+// handleExportMCPServerConfigs handles GET /api/mcp/servers/export
+// 返回包含所有服务器的mcpservers.json文档，禁用的服务器带有disabled，参数同单个服务器的导出
+func (s *Server) handleExportMCPServerConfigs(w http.ResponseWriter, r *http.Request) {
+	servers, err := s.mcpServerConfigService.ListConfigs()
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取MCP服务器配置列表失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	includeSecrets := r.URL.Query().Get("include_secrets") == "true"
+	settings := &mcphost.MCPSettings{MCPServers: make(map[string]*mcphost.ServerConfig, len(servers))}
+	for _, server := range servers {
+		serverConfig, err := exportedServerConfig(server, includeSecrets)
+		if err != nil {
+			writeModelError(w, err, fmt.Sprintf("导出MCP服务器配置失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		settings.MCPServers[server.Name] = serverConfig
+	}
+
+	writeMCPSettings(w, settings)
+}
+
+// handleImportMCPServerConfigs handles POST /api/mcp/servers/import
+// 请求体为mcpservers.json文档，按名称创建或更新服务器；?dry_run=true 时只返回将要执行的变更。
+// 导出时隐藏的环境变量值保留已保存的值，任一服务器无效时不导入任何服务器。
+func (s *Server) handleImportMCPServerConfigs(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, "读取请求失败", http.StatusBadRequest)
+		return
+	}
+	// 与命令行加载配置文件时使用相同的解析和校验
+	settings, err := mcphost.LoadSettingsFromString(string(body))
+	if err != nil {
+		writeError(w, fmt.Sprintf("解析mcpservers.json失败: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(settings.MCPServers) == 0 {
+		writeError(w, "mcpservers.json中没有MCP服务器", http.StatusBadRequest)
+		return
+	}
+
+	names := make([]string, 0, len(settings.MCPServers))
+	for name := range settings.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var warnings []string
+	configs := make([]models.MCPServerConfigModel, len(names))
+	for i, name := range names {
+		serverConfig := settings.MCPServers[name]
+		if err := configs[i].FromServerConfig(name, "", *serverConfig); err != nil {
+			writeError(w, fmt.Sprintf("MCP服务器 %s 配置无效: %v", name, err), http.StatusBadRequest)
+			return
+		}
+		if _, err := configs[i].ToServerConfig(); err != nil {
+			writeModelError(w, err, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !s.checkCredentialReferences(w, &configs[i]) {
+			return
+		}
+		if serverConfig.Timeout > 0 || len(serverConfig.AutoApprove) > 0 {
+			warnings = append(warnings, fmt.Sprintf("MCP服务器 %s 的timeout和autoApprove不会被导入", name))
+		}
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	result, err := s.mcpServerConfigService.ImportConfigs(configs, dryRun)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("导入MCP服务器配置失败: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if !dryRun {
+		changed := make(map[string]bool, len(result.Created)+len(result.Updated))
+		for _, name := range append(append([]string{}, result.Created...), result.Updated...) {
+			changed[name] = true
+		}
+		for i := range configs {
+			if !changed[configs[i].Name] {
+				continue
+			}
+			s.mcpPool.ForgetServerInfo(configs[i].Name)
+			// 异步同步工具，不阻塞响应
+			go s.syncNewServerTools(&configs[i])
+		}
+		log.Printf("导入MCP服务器配置：新建%d个，更新%d个，未变化%d个", len(result.Created), len(result.Updated), len(result.Unchanged))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  fmt.Sprintf("新建%d个，更新%d个，未变化%d个MCP服务器配置", len(result.Created), len(result.Updated), len(result.Unchanged)),
+		"data":     result,
+		"warnings": warnings,
+	})
+}
@@ -0,0 +1,198 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getExport returns the mcpservers.json document of an export endpoint
+func getExport(t *testing.T, server *Server, path string) (string, *mcphost.MCPSettings) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	settings, err := mcphost.LoadSettingsFromString(w.Body.String())
+	require.NoError(t, err)
+	return w.Body.String(), settings
+}
+
+// postImport posts an mcpservers.json document to the import endpoint
+func postImport(server *Server, query, document string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/mcp/servers/import"+query, strings.NewReader(document)))
+	return w
+}
+
+// decodeImportResult returns the data of an import response
+func decodeImportResult(t *testing.T, w *httptest.ResponseRecorder) services.MCPServerImportResult {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Success bool                           `json:"success"`
+		Data    services.MCPServerImportResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	return resp.Data
+}
+
+func createExportTestServers(t *testing.T, server *Server) uint {
+	t.Helper()
+	w := postJSON(t, server, "/api/mcp/servers", CreateMCPServerConfigRequest{
+		Name:    "fofa",
+		Command: "uvx",
+		Args:    []string{"fofa-mcp"},
+		Env:     map[string]string{"FOFA_KEY": "s3cret", "FOFA_MODE": "fast"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data models.MCPServerConfigModel `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	w = postJSON(t, server, "/api/mcp/servers", CreateMCPServerConfigRequest{
+		Name:          "remote",
+		TransportType: "sse",
+		URL:           "http://127.0.0.1:9/sse",
+		Disabled:      true,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	return resp.Data.ID
+}
+
+func TestHandleExportMCPServerConfig(t *testing.T) {
+	server := setupTaskTestServer(t)
+	id := createExportTestServers(t, server)
+
+	// 默认隐藏环境变量的值
+	body, settings := getExport(t, server, fmt.Sprintf("/api/mcp/servers/%d/export", id))
+	assert.NotContains(t, body, "s3cret")
+	require.Len(t, settings.MCPServers, 1)
+	fofa := settings.MCPServers["fofa"]
+	require.NotNil(t, fofa)
+	assert.Equal(t, "uvx", fofa.Command)
+	assert.Equal(t, []string{"fofa-mcp"}, fofa.Args)
+	assert.Equal(t, map[string]string{"FOFA_KEY": models.RedactedHeaderValue, "FOFA_MODE": models.RedactedHeaderValue}, fofa.Env)
+
+	_, settings = getExport(t, server, fmt.Sprintf("/api/mcp/servers/%d/export?include_secrets=true", id))
+	assert.Equal(t, "s3cret", settings.MCPServers["fofa"].Env["FOFA_KEY"])
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/mcp/servers/9999/export", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestMCPServerExportImportRoundTrip(t *testing.T) {
+	var document string
+	var exported *mcphost.MCPSettings
+	t.Run("导出", func(t *testing.T) {
+		server := setupTaskTestServer(t)
+		createExportTestServers(t, server)
+
+		document, exported = getExport(t, server, "/api/mcp/servers/export?include_secrets=true")
+		assert.Contains(t, exported.MCPServers, "fetch")
+		assert.Contains(t, exported.MCPServers, "fofa")
+		require.Contains(t, exported.MCPServers, "remote")
+		assert.True(t, exported.MCPServers["remote"].Disabled)
+	})
+	require.NotEmpty(t, document)
+
+	server := setupTaskTestServer(t)
+	before, err := server.mcpServerConfigService.ListConfigs()
+	require.NoError(t, err)
+
+	// dry_run只报告变更，不写入数据库
+	result := decodeImportResult(t, postImport(server, "?dry_run=true", document))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"fofa", "remote"}, result.Created)
+	assert.Empty(t, result.Updated)
+	assert.Contains(t, result.Unchanged, "fetch")
+	after, err := server.mcpServerConfigService.ListConfigs()
+	require.NoError(t, err)
+	assert.Len(t, after, len(before))
+
+	result = decodeImportResult(t, postImport(server, "", document))
+	assert.False(t, result.DryRun)
+	assert.Equal(t, []string{"fofa", "remote"}, result.Created)
+
+	// 导入后的配置与导出时相同
+	for name, expected := range exported.MCPServers {
+		stored, err := server.mcpServerConfigService.GetConfigByName(name)
+		require.NoError(t, err, name)
+		serverConfig, err := stored.ToServerConfig()
+		require.NoError(t, err, name)
+		assert.Equal(t, *expected, serverConfig, name)
+	}
+
+	// 再次导入时没有变化
+	result = decodeImportResult(t, postImport(server, "", document))
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Updated)
+	assert.Len(t, result.Unchanged, len(exported.MCPServers))
+}
+
+func TestHandleImportMCPServerConfigsUpdate(t *testing.T) {
+	server := setupTaskTestServer(t)
+	id := createExportTestServers(t, server)
+	require.NoError(t, server.mcpServerConfigService.UpdateConfig(id, &models.MCPServerConfigModel{
+		Name: "fofa", Description: "FOFA搜索", Command: "uvx", Args: `["fofa-mcp"]`, Env: `{"FOFA_KEY":"s3cret","FOFA_MODE":"fast"}`, NamePrefix: "fofa",
+	}))
+
+	// 发回隐藏的值时保留原值
+	_, settings := getExport(t, server, fmt.Sprintf("/api/mcp/servers/%d/export", id))
+	settings.MCPServers["fofa"].Args = []string{"fofa-mcp", "--verbose"}
+	settings.MCPServers["fofa"].Disabled = true
+	data, err := json.Marshal(settings)
+	require.NoError(t, err)
+	result := decodeImportResult(t, postImport(server, "", string(data)))
+	assert.Equal(t, []string{"fofa"}, result.Updated)
+
+	stored, err := server.mcpServerConfigService.GetConfig(id)
+	require.NoError(t, err)
+	serverConfig, err := stored.ToServerConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fofa-mcp", "--verbose"}, serverConfig.Args)
+	assert.Equal(t, map[string]string{"FOFA_KEY": "s3cret", "FOFA_MODE": "fast"}, serverConfig.Env)
+	assert.True(t, stored.Disabled)
+	// mcpservers.json中没有的字段保持不变
+	assert.Equal(t, "FOFA搜索", stored.Description)
+	assert.Equal(t, "fofa", stored.NamePrefix)
+}
+
+func TestHandleImportMCPServerConfigsInvalid(t *testing.T) {
+	server := setupTaskTestServer(t)
+	before, err := server.mcpServerConfigService.ListConfigs()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{"无效JSON", `{"mcpServers":`},
+		{"没有服务器", `{"mcpServers":{}}`},
+		{"缺少命令", `{"mcpServers":{"bad":{"transportType":"stdio"}}}`},
+		{"不支持的传输类型", `{"mcpServers":{"bad":{"transportType":"ws","url":"ws://localhost"}}}`},
+		{"新服务器使用隐藏的值", `{"mcpServers":{"a-ok":{"command":"uvx"},"new":{"command":"uvx","env":{"KEY":"******"}}}}`},
+		{"引用不存在的凭据", `{"mcpServers":{"new":{"command":"uvx","env":{"KEY":"{{credential:missing}}"}}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decodeErrorResponse(t, postImport(server, "", tt.document), http.StatusBadRequest)
+		})
+	}
+
+	// 任一服务器无效时不导入任何服务器
+	after, err := server.mcpServerConfigService.ListConfigs()
+	require.NoError(t, err)
+	assert.Len(t, after, len(before))
+}
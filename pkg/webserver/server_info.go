@@ -0,0 +1,74 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
+	"github.com/gorilla/mux"
+)
+
+// getServerInfo returns the ServerInfo of server through the cache of the connection pool
+func (s *Server) getServerInfo(ctx context.Context, server *models.MCPServerConfigModel) (*mcppool.ServerInfo, error) {
+	serverConfig, err := services.ResolveServerConfig(server)
+	if err != nil {
+		return nil, fmt.Errorf("转换服务器配置失败: %w", err)
+	}
+	return s.mcpPool.GetServerInfo(ctx, server.Name, &serverConfig)
+}
+
+// recordServerInfo gets the ServerInfo of server and stores the negotiated
+// protocol version when it changed
+func (s *Server) recordServerInfo(ctx context.Context, server *models.MCPServerConfigModel) (*mcppool.ServerInfo, error) {
+	info, err := s.getServerInfo(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	if info.ProtocolVersion != server.LastSeenVersion {
+		if err := s.mcpServerConfigService.UpdateLastSeenVersion(server.ID, info.ProtocolVersion); err != nil {
+			log.Printf("保存服务器协议版本失败 %s: %v", server.Name, err)
+		} else {
+			server.LastSeenVersion = info.ProtocolVersion
+		}
+	}
+	return info, nil
+}
+
+// handleGetMCPServerInfo handles GET /api/mcp/servers/{id}/info
+// 返回服务器的名称、版本、协商的协议版本和声明的能力，?refresh=true 时重新连接服务器
+func (s *Server) handleGetMCPServerInfo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的配置ID", http.StatusBadRequest)
+		return
+	}
+
+	server, err := s.mcpServerConfigService.GetConfig(uint(id))
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取MCP服务器配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("refresh") == "true" {
+		s.mcpPool.ForgetServerInfo(server.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.healthConfig.Timeout)
+	defer cancel()
+	info, err := s.recordServerInfo(ctx, server)
+	if err != nil {
+		writeError(w, fmt.Sprintf("获取MCP服务器信息失败: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    info,
+	})
+}
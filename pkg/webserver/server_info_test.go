@@ -0,0 +1,79 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/internal/testmcp"
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/gorilla/mux"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandleGetMCPServerInfo 连接真实的MCP stdio进程获取服务器信息，-short时跳过
+func TestHandleGetMCPServerInfo(t *testing.T) {
+	binary := testmcp.Build(t)
+	server := setupTaskTestServer(t)
+	server.mcpPool = mcppool.New(nil)
+	t.Cleanup(func() { server.mcpPool.Shutdown() })
+
+	fake := testmcp.Server(binary, testmcp.Options{})
+	args, err := json.Marshal(fake.Args)
+	require.NoError(t, err)
+	target := &models.MCPServerConfigModel{Name: "fake-info", TransportType: "stdio", Command: fake.Command, Args: string(args)}
+	require.NoError(t, server.mcpServerConfigService.CreateConfig(target))
+	id := strconv.FormatUint(uint64(target.ID), 10)
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/mcp/servers/"+id+"/info", nil), map[string]string{"id": id})
+	w := httptest.NewRecorder()
+	server.handleGetMCPServerInfo(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Success bool               `json:"success"`
+		Data    mcppool.ServerInfo `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, "fakeserver", resp.Data.Name)
+	assert.Equal(t, "1.0.0", resp.Data.Version)
+	assert.Equal(t, mcp.LATEST_PROTOCOL_VERSION, resp.Data.ProtocolVersion)
+	assert.True(t, resp.Data.Latest)
+	assert.NotNil(t, resp.Data.Capabilities.Tools)
+
+	// 协商的协议版本保存到配置中
+	stored, err := server.mcpServerConfigService.GetConfig(target.ID)
+	require.NoError(t, err)
+	assert.Equal(t, mcp.LATEST_PROTOCOL_VERSION, stored.LastSeenVersion)
+
+	// 服务器列表带有缓存的服务器信息
+	w = httptest.NewRecorder()
+	server.handleListMCPServerConfigs(w, httptest.NewRequest("GET", "/api/mcp/servers?q=fake-info", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data []MCPServerConfigResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	require.NotNil(t, list.Data[0].ServerInfo)
+	assert.Equal(t, "fakeserver", list.Data[0].ServerInfo.Name)
+	assert.Equal(t, mcp.LATEST_PROTOCOL_VERSION, list.Data[0].LastSeenVersion)
+
+	// 删除配置后清除缓存
+	req = mux.SetURLVars(httptest.NewRequest("DELETE", "/api/mcp/servers/"+id, nil), map[string]string{"id": id})
+	w = httptest.NewRecorder()
+	server.handleDeleteMCPServerConfig(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, server.mcpPool.CachedServerInfo(target.Name))
+
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/api/mcp/servers/999/info", nil), map[string]string{"id": "999"})
+	w = httptest.NewRecorder()
+	server.handleGetMCPServerInfo(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
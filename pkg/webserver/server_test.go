@@ -2,15 +2,25 @@ package webserver
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/LubyRuffy/einomcphost"
 	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -120,6 +130,8 @@ func TestTaskEndpointEmptyTask(t *testing.T) {
 // TestTaskEndpointWithConfig tests the task execution endpoint with custom config
 func TestTaskEndpointWithConfig(t *testing.T) {
 	server := NewServer(":8080")
+	runner := &configRunner{}
+	server.agentRunner = runner.run
 
 	// Test task request with custom config
 	customConfig := &config.Config{
@@ -157,6 +169,14 @@ func TestTaskEndpointWithConfig(t *testing.T) {
 
 	// Should return 200 for valid request with config
 	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 执行器收到请求中的配置，不会连接mcpservers.json中的服务器
+	require.Eventually(t, func() bool {
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		return len(runner.configs) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "自定义系统提示词", runner.configs[0].SystemPrompt)
 }
 
 // TestTaskEndpointInvalidConfig tests the task execution endpoint with invalid config
@@ -249,6 +269,119 @@ func TestSSENotifier(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "error")
 }
 
+// TestSSENotifierTimeline tests that call IDs are carried in the notify events
+func TestSSENotifierTimeline(t *testing.T) {
+	w := httptest.NewRecorder()
+	notifier := &SSENotifier{writer: w, taskID: "test-task"}
+	var _ mcpagent.TimelineNotify = notifier
+	var _ mcpagent.TimelineNotify = &BroadcastNotifier{}
+
+	decode := func() NotifyEvent {
+		var msg struct {
+			Type string      `json:"type"`
+			Data NotifyEvent `json:"data"`
+		}
+		body := strings.TrimSpace(strings.TrimPrefix(w.Body.String(), "data: "))
+		require.NoError(t, json.Unmarshal([]byte(body), &msg))
+		w.Body.Reset()
+		return msg.Data
+	}
+
+	notifier.OnThinkingWithCall("先搜索", "call_1")
+	event := decode()
+	assert.Equal(t, "thinking", event.Type)
+	assert.Equal(t, "call_1", event.RelatedCallID)
+
+	notifier.OnToolCallWithID("call_1", "search", map[string]interface{}{"q": "mcp"})
+	event = decode()
+	assert.Equal(t, "tool_call", event.Type)
+	assert.Equal(t, "call_1", event.CallID)
+
+	notifier.OnToolResult("call_1", "search", "结果", nil, 0)
+	event = decode()
+	assert.Equal(t, "tool_result", event.Type)
+	assert.Equal(t, "call_1", event.CallID)
+	assert.Equal(t, "success", event.Status)
+	assert.Equal(t, "结果", event.Result)
+
+	notifier.OnToolResult("call_2", "fetch", "", errors.New("timeout"), 0)
+	event = decode()
+	assert.Equal(t, "call_2", event.CallID)
+	assert.Equal(t, "error", event.Status)
+	assert.Equal(t, "timeout", event.Error)
+	assert.Nil(t, event.Result)
+}
+
+// TestSSENotifierToolCallResult tests that tool_result events carry the
+// duration of the call and that long results are truncated
+func TestSSENotifierToolCallResult(t *testing.T) {
+	w := httptest.NewRecorder()
+	notifier := &SSENotifier{writer: w, taskID: "test-task", resultLimit: 10}
+	var _ mcpagent.TimelineNotify = notifier
+	var _ mcpagent.TimelineNotify = &BroadcastNotifier{}
+
+	decode := func() NotifyEvent {
+		var msg struct {
+			Data NotifyEvent `json:"data"`
+		}
+		body := strings.TrimSpace(strings.TrimPrefix(w.Body.String(), "data: "))
+		require.NoError(t, json.Unmarshal([]byte(body), &msg))
+		w.Body.Reset()
+		return msg.Data
+	}
+
+	notifier.OnToolResult("call_1", "search", "短结果", nil, 1500*time.Millisecond)
+	event := decode()
+	assert.Equal(t, "tool_result", event.Type)
+	assert.Equal(t, "短结果", event.Result)
+	assert.Equal(t, int64(1500), event.DurationMs)
+
+	// 按字节截断，不拆分字符
+	notifier.OnToolResult("call_2", "search", "一二三四五六", nil, 0)
+	event = decode()
+	assert.Equal(t, "一二三"+toolResultTruncated, event.Result)
+	assert.Zero(t, event.DurationMs)
+
+	notifier.OnToolResult("call_3", "fetch", "", errors.New("timeout"), time.Second)
+	event = decode()
+	assert.Equal(t, "error", event.Status)
+	assert.Equal(t, "timeout", event.Error)
+	assert.Equal(t, int64(1000), event.DurationMs)
+
+	// 不限制时发送完整结果
+	notifier.resultLimit = 0
+	notifier.OnToolResult("call_4", "search", "一二三四五六", nil, 0)
+	assert.Equal(t, "一二三四五六", decode().Result)
+
+	// 不带调用ID的结果
+	notifier.OnToolCallResult("fetch", "网页", nil, 2*time.Second)
+	event = decode()
+	assert.Equal(t, "tool_result", event.Type)
+	assert.Empty(t, event.CallID)
+	assert.Equal(t, "网页", event.Result)
+	assert.Equal(t, int64(2000), event.DurationMs)
+}
+
+// TestSSENotifierPlan tests that the plan of plan mode is sent as a plan event
+func TestSSENotifierPlan(t *testing.T) {
+	w := httptest.NewRecorder()
+	notifier := &SSENotifier{writer: w, taskID: "test-task"}
+	var _ mcpagent.PlanNotify = notifier
+	var _ mcpagent.PlanNotify = &BroadcastNotifier{}
+
+	notifier.OnPlan("1. 搜索\n2. 总结")
+
+	var msg struct {
+		Type string      `json:"type"`
+		Data NotifyEvent `json:"data"`
+	}
+	body := strings.TrimSpace(strings.TrimPrefix(w.Body.String(), "data: "))
+	require.NoError(t, json.Unmarshal([]byte(body), &msg))
+	assert.Equal(t, "notify", msg.Type)
+	assert.Equal(t, "plan", msg.Data.Type)
+	assert.Equal(t, "1. 搜索\n2. 总结", msg.Data.Content)
+}
+
 // TestBroadcastNotifier tests the broadcast notifier implementation
 func TestBroadcastNotifier(t *testing.T) {
 	server := NewServer(":8080")
@@ -386,6 +519,45 @@ func TestHandleTestLLMConnectionInvalidJSON(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "解析LLM配置数据失败")
 }
 
+// TestHandleTestLLMConnectionWithTools tests the tool calling probe of the LLM test endpoint
+func TestHandleTestLLMConnectionWithTools(t *testing.T) {
+	requests := 0
+	var path string
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"registry.ollama.ai/library/gemma:2b does not support tools","type":"api_error"}}`))
+	}))
+	defer llm.Close()
+
+	server := NewServer(":8080")
+	body, err := json.Marshal(config.LLMConfig{Type: config.LLMProviderOpenAI, BaseURL: llm.URL, Model: "gemma:2b", APIKey: "key"})
+	require.NoError(t, err)
+
+	// 不带with_tools时只检查模型能否创建
+	w := httptest.NewRecorder()
+	server.handleTestLLMConnection(w, httptest.NewRequest("POST", "/api/llm/test", strings.NewReader(string(body))))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, requests)
+
+	w = httptest.NewRecorder()
+	server.handleTestLLMConnection(w, httptest.NewRequest("POST", "/api/llm/test?with_tools=true", strings.NewReader(string(body))))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "模型不支持工具调用", response["message"])
+	assert.Equal(t, false, response["tool_support"])
+	assert.Contains(t, response["error"], "请选择支持工具调用的模型")
+	assert.Equal(t, 1, requests)
+
+	// 缺少/v1的BaseURL被自动补全，响应中返回实际使用的地址
+	assert.Equal(t, llm.URL+"/v1", response["base_url"])
+	assert.Len(t, response["warnings"], 1)
+	assert.Equal(t, "/v1/chat/completions", path)
+}
+
 // TestHandleGetConfig tests the GET /api/config endpoint
 func TestHandleGetConfig(t *testing.T) {
 	server := NewServer(":8080")
@@ -501,6 +673,34 @@ func TestHandleUpdateConfigInvalidConfig(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "配置验证失败")
 }
 
+func TestHandleGetMCPToolsServerNamesWithUnderscores(t *testing.T) {
+	server := &Server{}
+	server.serverToolLister = func(ctx context.Context, name string, cfg *mcphost.ServerConfig) ([]mcphost.ToolDetail, error) {
+		return []mcphost.ToolDetail{{Server: name, Name: "b_c", Description: "tool of " + name}}, nil
+	}
+
+	body, err := json.Marshal(MCPToolsRequest{
+		MCPServers: map[string]*mcphost.ServerConfig{
+			"a_b": {URL: "http://127.0.0.1:1/a_b"},
+			"a":   {URL: "http://127.0.0.1:1/a"},
+		},
+		MergeStrategy: config.MergeStrategyInlineOnly,
+	})
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	server.handleGetMCPTools(rr, httptest.NewRequest("POST", "/api/mcp/tools", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// 工具键相同的两个工具分别属于各自的服务器
+	var response MCPToolsResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Tools, 2)
+	assert.Equal(t, "a", response.Tools[0].Server)
+	assert.Equal(t, "tool of a", response.Tools[0].Description)
+	assert.Equal(t, "a_b", response.Tools[1].Server)
+	assert.Equal(t, "b_c", response.Tools[1].Name)
+}
+
 func TestHandleGetMCPTools(t *testing.T) {
 	// 创建测试服务器
 	server := &Server{}
@@ -514,7 +714,7 @@ func TestHandleGetMCPTools(t *testing.T) {
 		{
 			name: "空的MCP服务器配置",
 			requestBody: MCPToolsRequest{
-				MCPServers: map[string]*einomcphost.ServerConfig{},
+				MCPServers: map[string]*mcphost.ServerConfig{},
 			},
 			expectedStatus: http.StatusOK,
 			expectSuccess:  true,
@@ -522,7 +722,7 @@ func TestHandleGetMCPTools(t *testing.T) {
 		{
 			name: "无效的MCP服务器配置",
 			requestBody: MCPToolsRequest{
-				MCPServers: map[string]*einomcphost.ServerConfig{
+				MCPServers: map[string]*mcphost.ServerConfig{
 					"invalid_server": {
 						Command: "invalid_command_that_does_not_exist",
 						Args:    []string{"--invalid"},
@@ -533,6 +733,34 @@ func TestHandleGetMCPTools(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectSuccess:  false,
 		},
+		{
+			name: "只使用mcp_servers时忽略配置文件",
+			requestBody: MCPToolsRequest{
+				MCPServers:    map[string]*mcphost.ServerConfig{},
+				ConfigFile:    "not_exist_mcpservers.json",
+				MergeStrategy: config.MergeStrategyInlineOnly,
+			},
+			expectedStatus: http.StatusOK,
+			expectSuccess:  true,
+		},
+		{
+			name: "只使用不存在的配置文件",
+			requestBody: MCPToolsRequest{
+				ConfigFile:    "not_exist_mcpservers.json",
+				MergeStrategy: config.MergeStrategyFileOnly,
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectSuccess:  false,
+		},
+		{
+			name: "无效的合并策略",
+			requestBody: MCPToolsRequest{
+				MCPServers:    map[string]*mcphost.ServerConfig{},
+				MergeStrategy: "unknown",
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectSuccess:  false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -646,3 +874,166 @@ func TestMCPToolsResponseStructure(t *testing.T) {
 		assert.Equal(t, response.Tools[0].Server, decoded.Tools[0].Server)
 	}
 }
+
+// TestTaskResultStatus tests the final task status derived from the task error
+func TestTaskResultStatus(t *testing.T) {
+	assert.Equal(t, "completed", taskResultStatus(nil))
+	assert.Equal(t, "error", taskResultStatus(errors.New("执行失败")))
+	assert.Equal(t, "timeout", taskResultStatus(fmt.Errorf("%w: 模型请求被取消", mcpagent.ErrTaskTimeout)))
+	assert.Equal(t, "timeout", taskResultStatus(fmt.Errorf("%w（1m40s）", mcpagent.ErrMaxDurationExceeded)))
+}
+
+func TestNotifyEventErrorCode(t *testing.T) {
+	event := newToolResultEvent("call_1", "search", "", errors.New("连接失败"), "")
+	assert.Equal(t, "error", event.Status)
+	assert.Equal(t, mcpagent.ErrorCodeToolFailed, event.ErrorCode)
+
+	event = newToolResultEvent("call_1", "search", "ok", nil, "")
+	assert.Empty(t, event.ErrorCode)
+}
+
+func TestMCPServerConfigHeadersRedacted(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	stored := &models.MCPServerConfigModel{Name: "hosted", TransportType: "sse", URL: "http://127.0.0.1:1/sse"}
+	require.NoError(t, stored.SetHeaders([]string{"Authorization: Bearer secret-token"}))
+	require.NoError(t, server.mcpServerConfigService.CreateConfig(stored))
+
+	// 列表和详情接口都不返回头部的值
+	for _, path := range []string{"/api/mcp/servers", fmt.Sprintf("/api/mcp/servers/%d", stored.ID)} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "secret-token", path)
+		assert.Contains(t, w.Body.String(), "Authorization: ******", path)
+	}
+
+	// 以隐藏形式发回头部时保留原值
+	updates := &models.MCPServerConfigModel{Name: "hosted", TransportType: "sse", URL: "http://127.0.0.1:2/sse"}
+	require.NoError(t, updates.SetHeaders([]string{"Authorization: " + models.RedactedHeaderValue}))
+	require.NoError(t, server.mcpServerConfigService.UpdateConfig(stored.ID, updates))
+
+	updated, err := server.mcpServerConfigService.GetConfig(stored.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:2/sse", updated.URL)
+	headers, err := updated.GetHeadersMap()
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer secret-token", headers["Authorization"])
+}
+
+func TestMCPServerConfigHandlersRejectInvalidConfig(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	stored := &models.MCPServerConfigModel{Name: "hosted", TransportType: "sse", URL: "http://127.0.0.1:1/sse"}
+	require.NoError(t, server.mcpServerConfigService.CreateConfig(stored))
+
+	// 只有空白字符的URL无法连接，不能保存
+	invalid := CreateMCPServerConfigRequest{Name: "blank-url", TransportType: "sse", URL: "   "}
+	w := postJSON(t, server, "/api/mcp/servers", invalid)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "MCP服务器 blank-url 配置无效")
+	_, err := server.mcpServerConfigService.GetConfigByName("blank-url")
+	assert.Error(t, err)
+
+	body, err := json.Marshal(CreateMCPServerConfigRequest{Name: "hosted", TransportType: "stdio", Command: " "})
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("PUT", fmt.Sprintf("/api/mcp/servers/%d", stored.ID), bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "MCP服务器启动命令不能为空")
+
+	unchanged, err := server.mcpServerConfigService.GetConfig(stored.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "sse", unchanged.TransportType)
+	assert.Equal(t, "http://127.0.0.1:1/sse", unchanged.URL)
+}
+
+func TestHandleGetConfigSchema(t *testing.T) {
+	server := NewServer(":8080")
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/config/schema", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var schema struct {
+		Type       string `json:"type"`
+		Properties map[string]struct {
+			Properties map[string]struct {
+				Enum []string `json:"enum"`
+			} `json:"properties"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, []string{config.LLMProviderOpenAI, config.LLMProviderOllama}, schema.Properties["llm"].Properties["type"].Enum)
+	assert.Contains(t, schema.Properties["mcp"].Properties, "mcp_servers")
+}
+
+func TestTasksShareCachedModels(t *testing.T) {
+	server := setupTaskTestServer(t)
+	var mu sync.Mutex
+	var taskModels []model.ToolCallingChatModel
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		chatModel, err := cfg.GetModel(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		taskModels = append(taskModels, chatModel)
+		mu.Unlock()
+		return nil
+	}
+
+	startTestTask(t, server, "/api/task", TaskRequest{Task: "第一个任务"})
+	startTestTask(t, server, "/api/task", TaskRequest{Task: "第二个任务"})
+	require.Len(t, taskModels, 2)
+	assert.Same(t, taskModels[0], taskModels[1], "相同配置的任务应该共享模型实例")
+
+	// 通过API修改API密钥后，缓存的模型失效
+	llmConfig, err := server.llmConfigService.GetDefaultConfig()
+	require.NoError(t, err)
+	updated := *llmConfig
+	updated.APIKey = "rotated-key"
+	data, err := json.Marshal(updated)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("PUT", fmt.Sprintf("/api/llm/configs/%d", llmConfig.ID), bytes.NewReader(data)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Zero(t, server.modelCache.Stats().Size)
+
+	startTestTask(t, server, "/api/task", TaskRequest{Task: "第三个任务"})
+	require.Len(t, taskModels, 3)
+	assert.NotSame(t, taskModels[0], taskModels[2], "修改API密钥后应该创建新的模型实例")
+	assert.Equal(t, uint64(1), server.modelCache.Stats().Hits)
+}
+
+// TestServerStaticDir tests that -static-dir overrides the embedded frontend
+// without shadowing the API routes
+func TestServerStaticDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>dev</html>"), 0o644))
+
+	server := NewServer(":8080")
+	assert.Error(t, server.SetStaticDir(filepath.Join(dir, "missing")))
+	require.NoError(t, server.SetStaticDir(dir))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := serve("/tasks/123")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<html>dev</html>", w.Body.String())
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+
+	w = serve("/api/config/schema")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	w = serve("/api/unknown")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotContains(t, w.Body.String(), "<html>dev</html>")
+}
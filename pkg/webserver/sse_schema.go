@@ -0,0 +1,197 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// SSESchemaVersion is the version of the format of the SSE messages sent by
+// /events. It is part of every SSEMessage and of the connection message, and
+// is increased whenever a message type, an event type or a field is added, so
+// consumers can tell which format they receive. Clients written for an older
+// version request it with /events?schema=N and receive only the message
+// types, event types and fields that version had.
+//
+// Versions:
+//   - 1: status and notify messages; message, thinking, tool_call, result and
+//     error events with type, timestamp, id, content, tool_name, parameters,
+//     status, result and error
+//   - 2: adds task_id to messages; the batch_status, queue_update,
+//     server_alert and sync_progress messages; the tool_result and plan
+//     events; the error_code, call_id, related_call_id, seq, content_format
+//     and verification fields of events and the fields of task statuses
+//     after total_steps
+//   - 3: adds the result_chunk event
+//   - 4: adds the duration_ms field of tool_result events
+//   - 5: adds the plan_approval event
+const SSESchemaVersion = 5
+
+// Message types of SSEMessage.Type
+const (
+	MessageTypeStatus       = "status"        // 任务状态，包括连接成功的消息
+	MessageTypeNotify       = "notify"        // 任务的通知事件，Data为NotifyEvent
+	MessageTypeBatchStatus  = "batch_status"  // 批量任务的汇总状态
+	MessageTypeQueueUpdate  = "queue_update"  // 排队任务的位置和预计开始时间
+	MessageTypeServerAlert  = "server_alert"  // MCP服务器健康状态变化
+	MessageTypeSyncProgress = "sync_progress" // 工具同步进度
+)
+
+// Event types of NotifyEvent.Type
+const (
+	EventTypeMessage      = "message"       // 执行过程中的消息
+	EventTypeThinking     = "thinking"      // 模型的思考过程
+	EventTypeToolCall     = "tool_call"     // 工具调用
+	EventTypeToolResult   = "tool_result"   // 工具调用的结果
+	EventTypeResult       = "result"        // 任务的最终结果
+	EventTypeResultChunk  = "result_chunk"  // 最终回答生成过程中的片段，最后仍发送完整的result
+	EventTypePlan         = "plan"          // 计划模式生成的执行计划
+	EventTypePlanApproval = "plan_approval" // 等待用户批准执行计划，content为计划
+	EventTypeError        = "error"         // 任务错误
+)
+
+const errMsgSchemaVersionInvalid = "schema必须是1到%d之间的整数"
+
+// sseSchema lists the message types, event types and fields of an older
+// version of the SSE format. Fields are the JSON keys kept in the data of
+// status and notify messages.
+type sseSchema struct {
+	messageTypes     map[string]bool
+	eventTypes       map[string]bool
+	messageFields    map[string]bool // SSEMessage的字段
+	eventFields      map[string]bool // NotifyEvent的字段
+	statusFields     map[string]bool // TaskStatus的字段
+	connectionFields map[string]bool // 连接成功消息的字段
+}
+
+// sseSchemas holds the older versions of the SSE format by version
+var sseSchemas = map[int]sseSchema{
+	1: {
+		messageTypes:     stringSet(MessageTypeStatus, MessageTypeNotify),
+		eventTypes:       stringSet(EventTypeMessage, EventTypeThinking, EventTypeToolCall, EventTypeResult, EventTypeError),
+		messageFields:    stringSet("type", "data", "schema_version"),
+		eventFields:      stringSet("type", "timestamp", "id", "content", "tool_name", "parameters", "status", "result", "error"),
+		statusFields:     stringSet("id", "status", "progress", "current_step", "total_steps"),
+		connectionFields: stringSet("connected", "message", "task_id"),
+	},
+	2: {
+		messageTypes: stringSet(MessageTypeStatus, MessageTypeNotify, MessageTypeBatchStatus, MessageTypeQueueUpdate,
+			MessageTypeServerAlert, MessageTypeSyncProgress),
+		eventTypes: stringSet(EventTypeMessage, EventTypeThinking, EventTypeToolCall, EventTypeToolResult, EventTypeResult,
+			EventTypePlan, EventTypeError),
+		messageFields: stringSet("type", "data", "task_id", "schema_version"),
+		eventFields: stringSet("type", "timestamp", "id", "content", "tool_name", "parameters", "status", "result", "error",
+			"error_code", "call_id", "related_call_id", "seq", "content_format", "verification"),
+		statusFields: stringSet("id", "status", "progress", "current_step", "total_steps", "error_code", "output_valid",
+			"model", "position", "eta_seconds", "missing_tools"),
+		connectionFields: stringSet("connected", "message", "task_id", "batch_id", "server_time", "schema_version"),
+	},
+	3: {
+		messageTypes: stringSet(MessageTypeStatus, MessageTypeNotify, MessageTypeBatchStatus, MessageTypeQueueUpdate,
+			MessageTypeServerAlert, MessageTypeSyncProgress),
+		eventTypes: stringSet(EventTypeMessage, EventTypeThinking, EventTypeToolCall, EventTypeToolResult, EventTypeResult,
+			EventTypeResultChunk, EventTypePlan, EventTypeError),
+		messageFields: stringSet("type", "data", "task_id", "schema_version"),
+		eventFields: stringSet("type", "timestamp", "id", "content", "tool_name", "parameters", "status", "result", "error",
+			"error_code", "call_id", "related_call_id", "seq", "content_format", "verification"),
+		statusFields: stringSet("id", "status", "progress", "current_step", "total_steps", "error_code", "output_valid",
+			"model", "position", "eta_seconds", "missing_tools"),
+		connectionFields: stringSet("connected", "message", "task_id", "batch_id", "server_time", "schema_version"),
+	},
+	4: {
+		messageTypes: stringSet(MessageTypeStatus, MessageTypeNotify, MessageTypeBatchStatus, MessageTypeQueueUpdate,
+			MessageTypeServerAlert, MessageTypeSyncProgress),
+		eventTypes: stringSet(EventTypeMessage, EventTypeThinking, EventTypeToolCall, EventTypeToolResult, EventTypeResult,
+			EventTypeResultChunk, EventTypePlan, EventTypeError),
+		messageFields: stringSet("type", "data", "task_id", "schema_version"),
+		eventFields: stringSet("type", "timestamp", "id", "content", "tool_name", "parameters", "status", "result", "error",
+			"error_code", "call_id", "related_call_id", "seq", "content_format", "verification", "duration_ms"),
+		statusFields: stringSet("id", "status", "progress", "current_step", "total_steps", "error_code", "output_valid",
+			"model", "position", "eta_seconds", "missing_tools"),
+		connectionFields: stringSet("connected", "message", "task_id", "batch_id", "server_time", "schema_version"),
+	},
+}
+
+// stringSet returns a set of values
+func stringSet(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// parseSchemaVersion parses the schema query parameter of /events, an empty value means SSESchemaVersion
+func parseSchemaVersion(value string) (int, error) {
+	if value == "" {
+		return SSESchemaVersion, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 || version > SSESchemaVersion {
+		return 0, fmt.Errorf(errMsgSchemaVersionInvalid, SSESchemaVersion)
+	}
+	return version, nil
+}
+
+// encodeSSEMessage returns the JSON of msg in the given version of the SSE
+// format. Messages and events the version does not know are not sent.
+//
+// Returns:
+//   - []byte: JSON of the message
+//   - bool: False if the message is not sent in this version
+//   - error: Error encoding the message
+func encodeSSEMessage(msg SSEMessage, version int) ([]byte, bool, error) {
+	schema, older := sseSchemas[version]
+	if !older {
+		msg.SchemaVersion = SSESchemaVersion
+		data, err := json.Marshal(msg)
+		return data, true, err
+	}
+	msg.SchemaVersion = version
+	if !schema.messageTypes[msg.Type] {
+		return nil, false, nil
+	}
+
+	var fields map[string]bool
+	switch data := msg.Data.(type) {
+	case NotifyEvent:
+		if !schema.eventTypes[data.Type] {
+			return nil, false, nil
+		}
+		fields = schema.eventFields
+	case TaskStatus:
+		fields = schema.statusFields
+	case map[string]interface{}:
+		fields = schema.connectionFields
+	}
+
+	envelope, err := keepFields(msg, schema.messageFields)
+	if err != nil {
+		return nil, false, err
+	}
+	if fields != nil {
+		if envelope["data"], err = keepFields(msg.Data, fields); err != nil {
+			return nil, false, err
+		}
+	}
+	data, err := json.Marshal(envelope)
+	return data, true, err
+}
+
+// keepFields returns the JSON object of value without the keys not in fields
+func keepFields(value interface{}, fields map[string]bool) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	for key := range object {
+		if !fields[key] {
+			delete(object, key)
+		}
+	}
+	return object, nil
+}
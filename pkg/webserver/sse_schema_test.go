@@ -0,0 +1,192 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeSSEMessages 解析写入的SSE消息
+func decodeSSEMessages(t *testing.T, body string) []map[string]interface{} {
+	t.Helper()
+	var messages []map[string]interface{}
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg))
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// TestSSETypesFromConstants 检查代码中的消息类型和事件类型都使用常量，而不是字符串字面量
+func TestSSETypesFromConstants(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+
+	fset := token.NewFileSet()
+	checked := 0
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		parsed, err := parser.ParseFile(fset, file, nil, 0)
+		require.NoError(t, err)
+		ast.Inspect(parsed, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.CompositeLit:
+				name, ok := n.Type.(*ast.Ident)
+				if !ok || (name.Name != "SSEMessage" && name.Name != "NotifyEvent") {
+					return true
+				}
+				for _, element := range n.Elts {
+					field, ok := element.(*ast.KeyValueExpr)
+					if !ok || field.Key.(*ast.Ident).Name != "Type" {
+						continue
+					}
+					checked++
+					_, literal := field.Value.(*ast.BasicLit)
+					assert.False(t, literal, "%s: %s的Type应使用常量", fset.Position(field.Pos()), name.Name)
+				}
+			case *ast.CallExpr:
+				if fn, ok := n.Fun.(*ast.Ident); ok && fn.Name == "newContentEvent" && len(n.Args) > 0 {
+					checked++
+					_, literal := n.Args[0].(*ast.BasicLit)
+					assert.False(t, literal, "%s: 事件类型应使用常量", fset.Position(n.Pos()))
+				}
+			}
+			return true
+		})
+	}
+	assert.Greater(t, checked, 10)
+}
+
+func TestSSESchemaCompatibility(t *testing.T) {
+	// 当前版本包含所有字段和事件
+	w := httptest.NewRecorder()
+	current := &SSENotifier{writer: w, taskID: "test-task"}
+	current.OnToolCallWithID("call_1", "search", map[string]interface{}{"q": "mcp"})
+	current.OnToolResult("call_1", "search", "结果", nil, 0)
+	messages := decodeSSEMessages(t, w.Body.String())
+	require.Len(t, messages, 2)
+	assert.Equal(t, float64(SSESchemaVersion), messages[0]["schema_version"])
+	assert.Equal(t, "call_1", messages[0]["data"].(map[string]interface{})["call_id"])
+
+	// schema=1 去掉之后增加的字段和事件类型
+	w = httptest.NewRecorder()
+	legacy := &SSENotifier{writer: w, taskID: "test-task", schemaVersion: 1}
+	legacy.OnToolCallWithID("call_1", "search", map[string]interface{}{"q": "mcp"})
+	legacy.OnToolResult("call_1", "search", "结果", nil, 0)
+	legacy.OnPlan("1. 搜索")
+	legacy.OnResult("完成")
+	messages = decodeSSEMessages(t, w.Body.String())
+	require.Len(t, messages, 2)
+	assert.Equal(t, float64(1), messages[0]["schema_version"])
+	event := messages[0]["data"].(map[string]interface{})
+	assert.Equal(t, EventTypeToolCall, event["type"])
+	assert.Equal(t, "search", event["tool_name"])
+	assert.NotContains(t, event, "call_id")
+	assert.NotContains(t, event, "seq")
+	event = messages[1]["data"].(map[string]interface{})
+	assert.Equal(t, EventTypeResult, event["type"])
+	assert.NotContains(t, event, "content_format")
+
+	// schema=2 不发送回答片段，其他事件和字段不变
+	w = httptest.NewRecorder()
+	legacy = &SSENotifier{writer: w, taskID: "test-task", schemaVersion: 2}
+	legacy.OnStreamResult("完")
+	legacy.OnToolCallWithID("call_1", "search", map[string]interface{}{"q": "mcp"})
+	legacy.OnResult("完成")
+	messages = decodeSSEMessages(t, w.Body.String())
+	require.Len(t, messages, 2)
+	assert.Equal(t, float64(2), messages[0]["schema_version"])
+	event = messages[0]["data"].(map[string]interface{})
+	assert.Equal(t, EventTypeToolCall, event["type"])
+	assert.Equal(t, "call_1", event["call_id"])
+	assert.Equal(t, EventTypeResult, messages[1]["data"].(map[string]interface{})["type"])
+
+	// schema=3 的tool_result事件不包含耗时
+	w = httptest.NewRecorder()
+	legacy = &SSENotifier{writer: w, taskID: "test-task", schemaVersion: 3}
+	legacy.OnToolResult("call_1", "search", "结果", nil, 2*time.Second)
+	messages = decodeSSEMessages(t, w.Body.String())
+	require.Len(t, messages, 1)
+	event = messages[0]["data"].(map[string]interface{})
+	assert.Equal(t, EventTypeToolResult, event["type"])
+	assert.Equal(t, "结果", event["result"])
+	assert.NotContains(t, event, "duration_ms")
+
+	// schema=4 不发送等待批准执行计划的事件
+	w = httptest.NewRecorder()
+	legacy = &SSENotifier{writer: w, taskID: "test-task", schemaVersion: 4}
+	legacy.sendNotifyEvent(newPlanApprovalEvent("1. 搜索"))
+	legacy.OnToolResult("call_1", "search", "结果", nil, 2*time.Second)
+	messages = decodeSSEMessages(t, w.Body.String())
+	require.Len(t, messages, 1)
+	event = messages[0]["data"].(map[string]interface{})
+	assert.Equal(t, EventTypeToolResult, event["type"])
+	assert.Equal(t, float64(2000), event["duration_ms"])
+
+	// 任务状态和批量任务消息
+	server := NewServer(":0")
+	w = httptest.NewRecorder()
+	legacy = &SSENotifier{writer: w, taskID: "task_legacy", batchID: "batch_legacy", schemaVersion: 1}
+	server.clients["legacy"] = legacy
+	server.broadcastToTask("task_legacy", SSEMessage{Type: MessageTypeStatus, Data: TaskStatus{ID: "task_legacy", Status: "error", ErrorCode: "internal_error"}})
+	server.broadcastBatchStatus(Batch{ID: "batch_legacy"})
+	require.Eventually(t, func() bool {
+		legacy.mutex.Lock()
+		defer legacy.mutex.Unlock()
+		return strings.Contains(w.Body.String(), "task_legacy")
+	}, 2*time.Second, 10*time.Millisecond)
+	legacy.mutex.Lock()
+	messages = decodeSSEMessages(t, w.Body.String())
+	legacy.mutex.Unlock()
+	require.Len(t, messages, 1)
+	assert.NotContains(t, messages[0], "task_id")
+	status := messages[0]["data"].(map[string]interface{})
+	assert.Equal(t, "error", status["status"])
+	assert.NotContains(t, status, "error_code")
+}
+
+func TestSSESchemaQuery(t *testing.T) {
+	server := NewServer(":0")
+
+	// 连接成功的消息包含格式版本
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	server.handleSSE(w, httptest.NewRequest("GET", "/events?taskId=task_1", nil).WithContext(ctx))
+	messages := decodeSSEMessages(t, w.Body.String())
+	require.Len(t, messages, 1)
+	assert.Equal(t, float64(SSESchemaVersion), messages[0]["schema_version"])
+	assert.Equal(t, float64(SSESchemaVersion), messages[0]["data"].(map[string]interface{})["schema_version"])
+
+	w = httptest.NewRecorder()
+	server.handleSSE(w, httptest.NewRequest("GET", "/events?taskId=task_1&schema=1", nil).WithContext(ctx))
+	messages = decodeSSEMessages(t, w.Body.String())
+	require.Len(t, messages, 1)
+	assert.Equal(t, float64(1), messages[0]["schema_version"])
+	assert.NotContains(t, messages[0]["data"], "server_time")
+
+	// 不支持的版本返回400
+	for _, schema := range []string{"0", strconv.Itoa(SSESchemaVersion + 1), "v1"} {
+		w = httptest.NewRecorder()
+		server.handleSSE(w, httptest.NewRequest("GET", "/events?schema="+schema, nil).WithContext(ctx))
+		assert.Equal(t, http.StatusBadRequest, w.Code, schema)
+	}
+}
@@ -0,0 +1,27 @@
+package webserver
+
+import (
+	"net/http"
+)
+
+// errMsgStateless is returned by the endpoints that need the database when
+// the server runs without one
+const errMsgStateless = "服务器以无数据库模式运行（-no-db），不支持该接口，请在任务请求中通过config提供完整配置"
+
+// Stateless reports whether the server runs without a database. Tasks then
+// need a full config in the request and the management endpoints are disabled.
+func (s *Server) Stateless() bool {
+	return s.db == nil
+}
+
+// requireDatabase wraps a handler of stored settings, it returns 501 when the
+// server runs without a database instead of calling services without one
+func (s *Server) requireDatabase(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Stateless() {
+			writeError(w, errMsgStateless, http.StatusNotImplemented)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,80 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupStatelessServer creates a server without a database, like mcpagent-web -no-db
+func setupStatelessServer(t *testing.T) *Server {
+	t.Helper()
+	previous := database.DB
+	database.DB = nil
+	t.Cleanup(func() { database.DB = previous })
+	server := NewServer(":8080")
+	require.True(t, server.Stateless())
+	return server
+}
+
+func TestStatelessServerRunsTasks(t *testing.T) {
+	server := setupStatelessServer(t)
+	runner := &configRunner{}
+	server.agentRunner = runner.run
+
+	cfg := config.NewDefaultConfig()
+	w := postJSON(t, server, "/api/task", TaskRequest{Task: "查询IP", Config: cfg})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Eventually(t, func() bool {
+		return getStatus(t, server).TasksCompleted == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	runner.mu.Lock()
+	require.Len(t, runner.configs, 1)
+	assert.Equal(t, cfg.LLM.Model, runner.configs[0].LLM.Model)
+	runner.mu.Unlock()
+
+	status := getStatus(t, server)
+	assert.False(t, status.Database.Healthy)
+
+	// 没有config时无法从数据库加载配置
+	w = postJSON(t, server, "/api/task", TaskRequest{Task: "查询IP"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 请求中提供的MCP服务器仍然可以列出工具
+	w = postJSON(t, server, "/api/mcp/tools", MCPToolsRequest{})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestStatelessServerDisablesManagement(t *testing.T) {
+	server := setupStatelessServer(t)
+
+	for _, route := range []struct{ method, path string }{
+		{"GET", "/api/config"},
+		{"GET", "/api/llm/configs"},
+		{"POST", "/api/llm/configs"},
+		{"GET", "/api/system-prompts"},
+		{"GET", "/api/task-templates"},
+		{"GET", "/api/credentials"},
+		{"GET", "/api/mcp/servers"},
+		{"DELETE", "/api/mcp/servers/1"},
+		{"GET", "/api/mcp/tools/configured"},
+		{"POST", "/api/mcp/tools/invoke"},
+	} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest(route.method, route.path, nil))
+		require.Equal(t, http.StatusNotImplemented, w.Code, "%s %s", route.method, route.path)
+
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.False(t, resp.Success)
+		assert.Equal(t, errCodeNotImplemented, resp.Error.Code)
+		assert.Contains(t, resp.Error.Message, "-no-db")
+	}
+}
@@ -0,0 +1,256 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+)
+
+const (
+	// statusErrorCapacity is the number of recent task errors kept for GET /api/status
+	statusErrorCapacity = 20
+
+	// databasePingTimeout limits the database health check of GET /api/status
+	databasePingTimeout = 2 * time.Second
+)
+
+// RunningTaskStatus describes a task being executed
+type RunningTaskStatus struct {
+	TaskID      string    `json:"task_id"`
+	Task        string    `json:"task"`
+	StartedAt   time.Time `json:"started_at"`
+	Step        int64     `json:"step"`                   // 已发起的工具调用数
+	CurrentStep string    `json:"current_step,omitempty"` // 最近一次的活动
+}
+
+// QueuedTaskStatus describes a task of a batch waiting for a worker
+type QueuedTaskStatus struct {
+	TaskID   string    `json:"task_id"`
+	BatchID  string    `json:"batch_id"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// TaskErrorStatus is an error a task failed with
+type TaskErrorStatus struct {
+	TaskID    string    `json:"task_id"`
+	Status    string    `json:"status"` // error 或 timeout
+	Error     string    `json:"error"`
+	ErrorCode string    `json:"error_code,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// DatabaseStatus is the result of the database health check
+type DatabaseStatus struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ServerStatus is the data of GET /api/status
+type ServerStatus struct {
+	StartedAt      time.Time           `json:"started_at"`
+	RunningTasks   []RunningTaskStatus `json:"running_tasks"`
+	QueuedTasks    []QueuedTaskStatus  `json:"queued_tasks"`
+	RecentErrors   []TaskErrorStatus   `json:"recent_errors"` // 最近的任务错误，最新的在前
+	TasksStarted   int64               `json:"tasks_started"`
+	TasksCompleted int64               `json:"tasks_completed"`
+	TasksFailed    int64               `json:"tasks_failed"`
+	SSEClients     int64               `json:"sse_clients"`
+	PoolSize       int                 `json:"pool_size"` // MCP连接池中的连接数
+	Database       DatabaseStatus      `json:"database"`
+}
+
+// runningTask is a task tracked by the status collector
+type runningTask struct {
+	taskID    string
+	task      string
+	startedAt time.Time
+	step      atomic.Int64
+	current   atomic.Value // string
+}
+
+// statusCollector tracks the tasks of the server for GET /api/status.
+// It is updated from the task lifecycle and every broadcast, so it only uses
+// sync.Map and atomic counters; the error ring has its own small mutex.
+type statusCollector struct {
+	startedAt time.Time
+	running   sync.Map // 任务ID -> *runningTask
+	queued    sync.Map // 任务ID -> QueuedTaskStatus
+
+	started   atomic.Int64
+	completed atomic.Int64
+	failed    atomic.Int64
+	clients   atomic.Int64
+
+	errorsMu   sync.Mutex
+	errors     [statusErrorCapacity]TaskErrorStatus
+	errorsNext int // 下一个错误的写入位置
+	errorsLen  int
+
+	durationsMu   sync.Mutex
+	durations     [taskDurationCapacity]time.Duration // 最近结束的任务的执行时间
+	durationsNext int
+	durationsLen  int
+	etaPercentile float64 // 计算平均执行时间时忽略超过该百分位的任务
+}
+
+// newStatusCollector creates a collector without tasks
+func newStatusCollector() *statusCollector {
+	return &statusCollector{startedAt: time.Now(), etaPercentile: DefaultETAPercentile}
+}
+
+// taskQueued records a task of a batch waiting for a worker
+func (c *statusCollector) taskQueued(taskID, batchID string) {
+	c.queued.Store(taskID, QueuedTaskStatus{TaskID: taskID, BatchID: batchID, QueuedAt: time.Now()})
+}
+
+// taskStarted records that a task is running, it is no longer queued
+func (c *statusCollector) taskStarted(taskID, task string) {
+	c.queued.Delete(taskID)
+	c.running.Store(taskID, &runningTask{taskID: taskID, task: task, startedAt: time.Now()})
+	c.started.Add(1)
+}
+
+// taskSkipped removes a queued task that will not run
+func (c *statusCollector) taskSkipped(taskID string) {
+	c.queued.Delete(taskID)
+}
+
+// taskFinished removes a running task and records its error unless it
+// completed or was canceled
+func (c *statusCollector) taskFinished(taskID, status string, err error) {
+	if value, ok := c.running.LoadAndDelete(taskID); ok && status != "canceled" {
+		c.recordDuration(time.Since(value.(*runningTask).startedAt))
+	}
+	if err == nil {
+		c.completed.Add(1)
+		return
+	}
+	if status == "canceled" {
+		return
+	}
+	c.failed.Add(1)
+
+	c.errorsMu.Lock()
+	defer c.errorsMu.Unlock()
+	c.errors[c.errorsNext] = TaskErrorStatus{
+		TaskID:    taskID,
+		Status:    status,
+		Error:     err.Error(),
+		ErrorCode: mcpagent.ErrorCode(err),
+		Time:      time.Now(),
+	}
+	c.errorsNext = (c.errorsNext + 1) % statusErrorCapacity
+	if c.errorsLen < statusErrorCapacity {
+		c.errorsLen++
+	}
+}
+
+// observe updates the current step of a running task from a message sent to its clients
+func (c *statusCollector) observe(taskID string, msg SSEMessage) {
+	event, ok := msg.Data.(NotifyEvent)
+	if !ok {
+		return
+	}
+	value, ok := c.running.Load(taskID)
+	if !ok {
+		return
+	}
+	task := value.(*runningTask)
+	switch event.Type {
+	case EventTypeToolCall:
+		task.step.Add(1)
+		task.current.Store("调用工具 " + event.ToolName)
+	case EventTypeThinking:
+		task.current.Store("思考中")
+	case EventTypePlan:
+		task.current.Store("生成执行计划")
+	case EventTypeResult:
+		task.current.Store("生成最终结果")
+	}
+}
+
+// clientConnected and clientDisconnected count the connected SSE clients
+func (c *statusCollector) clientConnected()    { c.clients.Add(1) }
+func (c *statusCollector) clientDisconnected() { c.clients.Add(-1) }
+
+// snapshot returns the tasks, counters and recent errors, without the pool and database status
+func (c *statusCollector) snapshot() ServerStatus {
+	status := ServerStatus{
+		StartedAt:      c.startedAt,
+		RunningTasks:   []RunningTaskStatus{},
+		QueuedTasks:    []QueuedTaskStatus{},
+		TasksStarted:   c.started.Load(),
+		TasksCompleted: c.completed.Load(),
+		TasksFailed:    c.failed.Load(),
+		SSEClients:     c.clients.Load(),
+	}
+
+	c.running.Range(func(_, value any) bool {
+		task := value.(*runningTask)
+		current, _ := task.current.Load().(string)
+		status.RunningTasks = append(status.RunningTasks, RunningTaskStatus{
+			TaskID:      task.taskID,
+			Task:        task.task,
+			StartedAt:   task.startedAt,
+			Step:        task.step.Load(),
+			CurrentStep: current,
+		})
+		return true
+	})
+	sort.Slice(status.RunningTasks, func(i, j int) bool {
+		return status.RunningTasks[i].StartedAt.Before(status.RunningTasks[j].StartedAt)
+	})
+
+	c.queued.Range(func(_, value any) bool {
+		status.QueuedTasks = append(status.QueuedTasks, value.(QueuedTaskStatus))
+		return true
+	})
+	sort.Slice(status.QueuedTasks, func(i, j int) bool {
+		return status.QueuedTasks[i].QueuedAt.Before(status.QueuedTasks[j].QueuedAt)
+	})
+
+	c.errorsMu.Lock()
+	status.RecentErrors = make([]TaskErrorStatus, 0, c.errorsLen)
+	for i := 1; i <= c.errorsLen; i++ {
+		status.RecentErrors = append(status.RecentErrors, c.errors[(c.errorsNext-i+statusErrorCapacity)%statusErrorCapacity])
+	}
+	c.errorsMu.Unlock()
+	return status
+}
+
+// databaseStatus pings the database
+func (s *Server) databaseStatus(ctx context.Context) DatabaseStatus {
+	if s.db == nil {
+		return DatabaseStatus{Error: "数据库未初始化"}
+	}
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return DatabaseStatus{Error: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, databasePingTimeout)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return DatabaseStatus{Error: err.Error()}
+	}
+	return DatabaseStatus{Healthy: true}
+}
+
+// handleGetStatus handles GET /api/status
+// 返回正在执行和排队的任务、最近的任务错误、SSE客户端数、连接池大小和数据库状态
+func (s *Server) handleGetStatus(w http.ResponseWriter, r *http.Request) {
+	status := s.status.snapshot()
+	status.PoolSize = s.mcpPool.Len()
+	status.Database = s.databaseStatus(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    status,
+	})
+}
@@ -0,0 +1,103 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getStatus returns the data of GET /api/status
+func getStatus(t *testing.T, server *Server) ServerStatus {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/status", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Data ServerStatus `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Data
+}
+
+func TestStatusTracksRunningAndFailedTasks(t *testing.T) {
+	server := setupTaskTestServer(t)
+	release := make(chan struct{})
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		notify.OnToolCall("search", map[string]any{"query": task})
+		<-release
+		return errors.New("搜索服务不可用")
+	}
+
+	w := postJSON(t, server, "/api/task", TaskRequest{Task: "查询IP"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	// 执行中的任务出现在running_tasks中
+	var status ServerStatus
+	require.Eventually(t, func() bool {
+		status = getStatus(t, server)
+		return len(status.RunningTasks) == 1 && status.RunningTasks[0].Step == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, resp.TaskID, status.RunningTasks[0].TaskID)
+	assert.Equal(t, "查询IP", status.RunningTasks[0].Task)
+	assert.Equal(t, "调用工具 search", status.RunningTasks[0].CurrentStep)
+	assert.False(t, status.RunningTasks[0].StartedAt.IsZero())
+	assert.Empty(t, status.RecentErrors)
+	assert.True(t, status.Database.Healthy)
+
+	// 任务失败后从running_tasks中移除，并记录错误
+	close(release)
+	require.Eventually(t, func() bool {
+		status = getStatus(t, server)
+		return len(status.RunningTasks) == 0 && len(status.RecentErrors) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, resp.TaskID, status.RecentErrors[0].TaskID)
+	assert.Equal(t, "error", status.RecentErrors[0].Status)
+	assert.Equal(t, "搜索服务不可用", status.RecentErrors[0].Error)
+	assert.Equal(t, int64(1), status.TasksStarted)
+	assert.Equal(t, int64(1), status.TasksFailed)
+	assert.Zero(t, status.TasksCompleted)
+}
+
+func TestStatusCollectorKeepsRecentErrors(t *testing.T) {
+	c := newStatusCollector()
+	c.taskQueued("task_q", "batch_1")
+	assert.Len(t, c.snapshot().QueuedTasks, 1)
+	c.taskStarted("task_q", "排队的任务")
+	status := c.snapshot()
+	assert.Empty(t, status.QueuedTasks)
+	require.Len(t, status.RunningTasks, 1)
+	c.taskFinished("task_q", "canceled", context.Canceled)
+
+	for i := 0; i < statusErrorCapacity+5; i++ {
+		taskID := fmt.Sprintf("task_%d", i)
+		c.taskStarted(taskID, "任务")
+		c.taskFinished(taskID, "error", fmt.Errorf("错误 %d", i))
+	}
+	c.taskStarted("task_ok", "任务")
+	c.taskFinished("task_ok", "completed", nil)
+
+	status = c.snapshot()
+	assert.Empty(t, status.RunningTasks)
+	require.Len(t, status.RecentErrors, statusErrorCapacity)
+	// 最新的错误在前，取消的任务不算错误
+	assert.Equal(t, fmt.Sprintf("错误 %d", statusErrorCapacity+4), status.RecentErrors[0].Error)
+	assert.Equal(t, "错误 5", status.RecentErrors[statusErrorCapacity-1].Error)
+	assert.Equal(t, int64(statusErrorCapacity+5), status.TasksFailed)
+	assert.Equal(t, int64(1), status.TasksCompleted)
+	assert.Equal(t, int64(statusErrorCapacity+7), status.TasksStarted)
+}
@@ -18,23 +18,39 @@ type CreateSystemPromptRequest struct {
 	IsDefault    bool     `json:"is_default"`
 }
 
-// handleListSystemPrompts 列出所有系统提示词配置
+// handleListSystemPrompts 列出系统提示词配置，按名称排序
+// 不带参数时返回提示词数组；带 ?page=、?page_size=、?sort= 或 ?q= 参数时
+// 返回包含 data 和 pagination 的响应
 func (s *Server) handleListSystemPrompts(w http.ResponseWriter, r *http.Request) {
-	prompts, err := s.systemPromptService.ListPrompts()
+	query, paged, err := parseListQuery(r)
 	if err != nil {
-		http.Error(w, "获取系统提示词配置列表失败: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prompts, pagination, err := s.systemPromptService.QueryPrompts(query)
+	if err != nil {
+		writeModelError(w, err, "获取系统提示词配置列表失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(prompts)
+	if !paged {
+		json.NewEncoder(w).Encode(prompts)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       prompts,
+		"pagination": pagination,
+	})
 }
 
 // handleCreateSystemPrompt 创建新的系统提示词配置
 func (s *Server) handleCreateSystemPrompt(w http.ResponseWriter, r *http.Request) {
 	var req CreateSystemPromptRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -49,13 +65,13 @@ func (s *Server) handleCreateSystemPrompt(w http.ResponseWriter, r *http.Request
 
 	// 设置占位符
 	if err := prompt.SetPlaceholdersFromStringSlice(req.Placeholders); err != nil {
-		http.Error(w, "设置占位符失败: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, "设置占位符失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 保存到数据库
 	if err := s.systemPromptService.CreatePrompt(prompt); err != nil {
-		http.Error(w, "创建系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, "创建系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -69,17 +85,13 @@ func (s *Server) handleGetSystemPrompt(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的ID", http.StatusBadRequest)
+		writeError(w, "无效的ID", http.StatusBadRequest)
 		return
 	}
 
 	prompt, err := s.systemPromptService.GetPrompt(uint(id))
 	if err != nil {
-		if err == models.ErrSystemPromptNotFound {
-			http.Error(w, "系统提示词配置不存在", http.StatusNotFound)
-		} else {
-			http.Error(w, "获取系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
-		}
+		writeModelError(w, err, "获取系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -92,13 +104,13 @@ func (s *Server) handleUpdateSystemPrompt(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的ID", http.StatusBadRequest)
+		writeError(w, "无效的ID", http.StatusBadRequest)
 		return
 	}
 
 	var req CreateSystemPromptRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -112,26 +124,20 @@ func (s *Server) handleUpdateSystemPrompt(w http.ResponseWriter, r *http.Request
 
 	// 设置占位符
 	if err := updates.SetPlaceholdersFromStringSlice(req.Placeholders); err != nil {
-		http.Error(w, "设置占位符失败: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, "设置占位符失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 更新数据库
 	if err := s.systemPromptService.UpdatePrompt(uint(id), updates); err != nil {
-		if err == models.ErrSystemPromptNotFound {
-			http.Error(w, "系统提示词配置不存在", http.StatusNotFound)
-		} else if err == models.ErrSystemPromptNameExists {
-			http.Error(w, "系统提示词名称已存在", http.StatusConflict)
-		} else {
-			http.Error(w, "更新系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
-		}
+		writeModelError(w, err, "更新系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// 获取更新后的配置
 	prompt, err := s.systemPromptService.GetPrompt(uint(id))
 	if err != nil {
-		http.Error(w, "获取更新后的系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, "获取更新后的系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -144,16 +150,12 @@ func (s *Server) handleDeleteSystemPrompt(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的ID", http.StatusBadRequest)
+		writeError(w, "无效的ID", http.StatusBadRequest)
 		return
 	}
 
 	if err := s.systemPromptService.DeletePrompt(uint(id)); err != nil {
-		if err == models.ErrSystemPromptNotFound {
-			http.Error(w, "系统提示词配置不存在", http.StatusNotFound)
-		} else {
-			http.Error(w, "删除系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
-		}
+		writeModelError(w, err, "删除系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -165,18 +167,34 @@ func (s *Server) handleSetDefaultSystemPrompt(w http.ResponseWriter, r *http.Req
 	vars := mux.Vars(r)
 	id, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
-		http.Error(w, "无效的ID", http.StatusBadRequest)
+		writeError(w, "无效的ID", http.StatusBadRequest)
 		return
 	}
 
 	if err := s.systemPromptService.SetDefaultPrompt(uint(id)); err != nil {
-		if err == models.ErrSystemPromptNotFound {
-			http.Error(w, "系统提示词配置不存在", http.StatusNotFound)
-		} else {
-			http.Error(w, "设置默认系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
-		}
+		writeModelError(w, err, "设置默认系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handleCloneSystemPrompt 复制系统提示词配置，副本使用新的名称且不是默认配置
+func (s *Server) handleCloneSystemPrompt(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的ID", http.StatusBadRequest)
+		return
+	}
+
+	prompt, err := s.systemPromptService.ClonePrompt(uint(id))
+	if err != nil {
+		writeModelError(w, err, "复制系统提示词配置失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(prompt)
+}
@@ -0,0 +1,70 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+)
+
+// dryRunResponse is the response of POST /api/task with dry_run
+type dryRunResponse struct {
+	Success  bool                     `json:"success"`
+	DryRun   bool                     `json:"dry_run"`
+	Config   *config.Config           `json:"config"`   // 任务将使用的配置，密钥已隐藏
+	Tools    []mcpagent.ToolSelection `json:"tools"`    // tools_auto_select选择的工具及原因
+	Messages []string                 `json:"messages"` // 执行任务时会推送的选择说明
+}
+
+// dryRunNotify collects the messages sent while a dry run selects tools
+type dryRunNotify struct {
+	messages []string
+}
+
+func (n *dryRunNotify) OnMessage(msg string)                   { n.messages = append(n.messages, msg) }
+func (n *dryRunNotify) OnThinking(msg string)                  {}
+func (n *dryRunNotify) OnToolCall(toolName string, params any) {}
+func (n *dryRunNotify) OnResult(msg string)                    {}
+func (n *dryRunNotify) OnError(err error)                      {}
+
+func (n *dryRunNotify) OnToolCallResult(toolName string, result string, err error, duration time.Duration) {
+}
+
+// autoSelectTools chooses the tools of a task with tools_auto_select from
+// the tools cached in the database, see mcpagent.AutoSelectTools. The result
+// filters and descriptions stored with the selected tools are applied.
+func (s *Server) autoSelectTools(ctx context.Context, cfg *config.Config, task string, notify mcpagent.Notify) (*config.Config, []mcpagent.ToolSelection) {
+	if !cfg.MCP.AutoSelectsTools() {
+		return cfg, nil
+	}
+	if s.db != nil {
+		ctx = mcpagent.WithToolCatalog(ctx, s.cachedToolCatalog)
+	}
+	selected, selections := mcpagent.AutoSelectTools(ctx, cfg, task, notify)
+	return s.withStoredDescriptions(s.withStoredResultFilters(selected)), selections
+}
+
+// handleTaskDryRun answers a task request with dry_run: the configuration
+// the task would use and the tools tools_auto_select would choose are
+// returned, the task is not started. To veto the selection the task is sent
+// again with the wanted tools.
+func (s *Server) handleTaskDryRun(w http.ResponseWriter, r *http.Request, cfg *config.Config, task string) {
+	notify := &dryRunNotify{messages: []string{}}
+	selected, selections := s.autoSelectTools(r.Context(), cfg, task, notify)
+	if selections == nil {
+		selections = []mcpagent.ToolSelection{}
+	}
+	selected.Redact()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dryRunResponse{
+		Success:  true,
+		DryRun:   true,
+		Config:   selected,
+		Tools:    selections,
+		Messages: notify.messages,
+	})
+}
@@ -0,0 +1,105 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAutoSelectServer 创建工具表中缓存了fetch和ddg-search服务器工具的服务器
+func setupAutoSelectServer(t *testing.T) *Server {
+	server := setupTaskTestServer(t)
+	for _, tool := range []struct {
+		server, name, description string
+	}{
+		{"fetch", "fetch", "Fetches a URL from the internet and extracts its contents as markdown"},
+		{"ddg-search", "search", "Search DuckDuckGo and return formatted results"},
+		{"ddg-search", "fetch_content", "Fetch and parse content from a webpage URL"},
+	} {
+		serverConfig, err := server.mcpServerConfigService.GetConfigByName(tool.server)
+		require.NoError(t, err)
+		require.NoError(t, server.mcpToolService.CreateTool(&models.MCPToolModel{
+			Name:        tool.name,
+			Description: tool.description,
+			ServerID:    serverConfig.ID,
+			ToolKey:     models.GenerateToolKey(tool.server, tool.name),
+			IsActive:    true,
+		}))
+	}
+	return server
+}
+
+func TestTaskDryRunAutoSelect(t *testing.T) {
+	server := setupAutoSelectServer(t)
+	enabled := true
+
+	dryRun := func(task string) dryRunResponse {
+		t.Helper()
+		w := postJSON(t, server, "/api/task", TaskRequest{Task: task, ToolsAutoSelect: &enabled, DryRun: true})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp dryRunResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.DryRun)
+		return resp
+	}
+
+	// 只返回选择的工具和配置，不执行任务
+	resp := dryRun("search duckduckgo for the latest Go release notes")
+	require.NotEmpty(t, resp.Tools)
+	assert.Equal(t, "ddg-search:search", resp.Tools[0].String())
+	assert.Contains(t, resp.Tools[0].Keywords, "duckduckgo")
+	assert.Equal(t, "ddg-search", resp.Config.MCP.Tools[0].Server)
+	assert.Contains(t, resp.Config.MCP.MCPServers, "ddg-search")
+	require.Len(t, resp.Messages, 1)
+	assert.Contains(t, resp.Messages[0], "ddg-search:search")
+	assert.Equal(t, config.RedactedValue, resp.Config.LLM.APIKey)
+
+	resp = dryRun("fetch the URL https://example.com and extract the markdown")
+	require.NotEmpty(t, resp.Tools)
+	assert.Equal(t, "fetch:fetch", resp.Tools[0].String())
+
+	// 无关的任务不附加工具
+	resp = dryRun("写一首关于春天的诗")
+	assert.Empty(t, resp.Tools)
+	assert.True(t, resp.Config.MCP.NoToolsRequested())
+
+	records, err := server.taskHistoryService.ListTasks("", 10)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestTaskAutoSelectConnectsSelectedServers(t *testing.T) {
+	server := setupAutoSelectServer(t)
+	var mu sync.Mutex
+	var used *config.Config
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		mu.Lock()
+		used = cfg
+		mu.Unlock()
+		notify.OnResult("完成")
+		return nil
+	}
+
+	enabled := true
+	startTestTask(t, server, "/api/task", TaskRequest{Task: "search duckduckgo for the latest Go release notes", ToolsAutoSelect: &enabled})
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotNil(t, used)
+	require.NotEmpty(t, used.MCP.Tools)
+	assert.Equal(t, config.MCPToolConfig{Server: "ddg-search", Name: "search"}, used.MCP.Tools[0])
+	// 只连接提供所选工具的服务器
+	assert.Contains(t, used.MCP.MCPServers, "ddg-search")
+	for name := range used.MCP.MCPServers {
+		assert.Contains(t, []string{"ddg-search", "fetch"}, name)
+	}
+	assert.False(t, used.MCP.AutoSelectsTools())
+}
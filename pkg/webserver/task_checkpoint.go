@@ -0,0 +1,197 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// DefaultCheckpointInterval is the default number of model calls between task checkpoints
+	DefaultCheckpointInterval = 5
+
+	// maxListedTasks is the number of tasks returned by GET /api/tasks
+	maxListedTasks = 100
+
+	// interruptedTaskReason is the error of the tasks interrupted by a restart
+	interruptedTaskReason = "Web服务器停止时任务仍在执行"
+)
+
+// TaskListItem is a task returned by GET /api/tasks with its last checkpoint
+type TaskListItem struct {
+	models.TaskHistoryModel
+	Checkpoint *mcpagent.Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// SetCheckpointInterval sets the number of model calls between the
+// checkpoints of a task, 0 disables checkpoints. It must be called before Start.
+func (s *Server) SetCheckpointInterval(interval int) {
+	s.checkpointInterval = interval
+}
+
+// CheckpointInterval implements mcpagent.CheckpointNotify, checkpoints need the task history
+func (n *BroadcastNotifier) CheckpointInterval() int {
+	if n.server.db == nil {
+		return 0
+	}
+	return n.server.checkpointInterval
+}
+
+// OnCheckpoint implements mcpagent.CheckpointNotify by storing the checkpoint with the task record
+func (n *BroadcastNotifier) OnCheckpoint(checkpoint mcpagent.Checkpoint) {
+	data, err := json.Marshal(checkpoint)
+	if err == nil {
+		err = n.server.taskHistoryService.SaveCheckpoint(n.taskID, checkpoint.Step, string(data))
+	}
+	if err != nil {
+		log.Printf("保存任务检查点失败 %s: %v", n.taskID, err)
+	}
+}
+
+// markInterruptedTasks marks the tasks left running by an earlier process as interrupted
+func (s *Server) markInterruptedTasks() {
+	if s.db == nil {
+		return
+	}
+	count, err := s.taskHistoryService.MarkInterrupted(interruptedTaskReason)
+	if err != nil {
+		log.Printf("标记中断的任务失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("%d 个任务在上次运行时被中断，可通过 POST /api/task/{taskId}/resume 恢复", count)
+	}
+}
+
+// taskCheckpoint decodes the last checkpoint of a task, nil when it has none
+func taskCheckpoint(record *models.TaskHistoryModel) (*mcpagent.Checkpoint, error) {
+	if record.Checkpoint == "" {
+		return nil, nil
+	}
+	var checkpoint mcpagent.Checkpoint
+	if err := json.Unmarshal([]byte(record.Checkpoint), &checkpoint); err != nil {
+		return nil, fmt.Errorf("解析任务检查点失败: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+// handleListTasks handles GET /api/tasks
+// 返回数据库中最近的任务，?status= 只返回该状态的任务，例如 interrupted；每个任务附带最近一次检查点。
+// 内存中运行中和最近结束的任务由 GET /api/tasks/running 返回。
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, "数据库不可用", http.StatusServiceUnavailable)
+		return
+	}
+
+	records, err := s.taskHistoryService.ListTasks(r.URL.Query().Get("status"), maxListedTasks)
+	if err != nil {
+		writeError(w, fmt.Sprintf("获取任务列表失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]TaskListItem, 0, len(records))
+	for i := range records {
+		item := TaskListItem{TaskHistoryModel: records[i]}
+		if item.Checkpoint, err = taskCheckpoint(&records[i]); err != nil {
+			log.Printf("任务 %s: %v", records[i].TaskID, err)
+		}
+		items = append(items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    items,
+	})
+}
+
+// handleResumeTask handles POST /api/task/{taskId}/resume
+// 从被中断任务的最近一次检查点重建对话并继续执行，新任务通过parent_task_id关联原任务。
+// 没有检查点的任务从头重新执行。
+func (s *Server) handleResumeTask(w http.ResponseWriter, r *http.Request) {
+	parentID := mux.Vars(r)["taskId"]
+	if s.db == nil {
+		writeError(w, "数据库不可用，无法恢复任务", http.StatusServiceUnavailable)
+		return
+	}
+
+	chain, err := s.taskHistoryService.GetChain(parentID)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取任务记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	interrupted := chain[len(chain)-1]
+	if interrupted.Status != models.TaskStatusInterrupted {
+		writeError(w, "只能恢复被中断的任务", http.StatusConflict)
+		return
+	}
+
+	taskConfig, err := s.continuationConfig(&interrupted)
+	if err != nil {
+		var missing *missingServersError
+		if errors.As(err, &missing) {
+			writeError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errs := taskConfig.ValidateDetailed(); len(errs) > 0 {
+		writeValidationError(w, fmt.Sprintf("配置验证失败: %v", errs.First()), errs)
+		return
+	}
+
+	history, task, err := resumeConversation(chain, mcpagent.MessagesFor(taskConfig.EffectiveLanguage()))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	taskID := fmt.Sprintf("task_%d", time.Now().UnixNano())
+	log.Printf("恢复任务 %s（检查点步骤 %d），新任务ID: %s", parentID, interrupted.CheckpointStep, taskID)
+	taskConfig.Artifacts.Store = s.newArtifactStore(taskID, &taskConfig.Artifacts)
+
+	s.startTask(taskID, parentID, taskConfig, history, task)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":        true,
+		"message":        "任务已开始执行",
+		"task_id":        taskID,
+		"parent_task_id": parentID,
+	})
+}
+
+// resumeConversation returns the history and task resuming the last task of chain.
+// With a checkpoint the conversation continues after its messages, including
+// the tool calls made so far; without one the task is run again after the
+// earlier turns of the conversation.
+//
+// Parameters:
+//   - chain: Interrupted task and the tasks it continues, the interrupted task last
+//   - messages: Catalog providing the resume instruction
+//
+// Returns:
+//   - []*schema.Message: History passed to the agent
+//   - string: Task of the resumed run
+//   - error: Error if the checkpoint cannot be decoded
+func resumeConversation(chain []models.TaskHistoryModel, messages *mcpagent.Messages) ([]*schema.Message, string, error) {
+	interrupted := chain[len(chain)-1]
+	checkpoint, err := taskCheckpoint(&interrupted)
+	if err != nil {
+		return nil, "", err
+	}
+	if checkpoint == nil || len(checkpoint.Messages) == 0 {
+		return continuationHistory(chain[:len(chain)-1]), interrupted.Task, nil
+	}
+	return checkpoint.Messages, messages.ResumeInstruction, nil
+}
@@ -0,0 +1,100 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkpointMessages is the conversation saved by checkpointingRunner
+var checkpointMessages = []*schema.Message{
+	schema.UserMessage("扫描example.com"),
+	schema.AssistantMessage("", []schema.ToolCall{{ID: "call_1", Function: schema.FunctionCall{Name: "port_scan", Arguments: `{"host":"example.com"}`}}}),
+	schema.ToolMessage("80/tcp open", "call_1"),
+}
+
+// checkpointingRunner saves a checkpoint before finishing, the first run is later marked as interrupted
+func checkpointingRunner(runner *recordingRunner) agentRunner {
+	return func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		if len(history) == 0 {
+			notify.(mcpagent.CheckpointNotify).OnCheckpoint(mcpagent.Checkpoint{Step: 5, Messages: checkpointMessages})
+		}
+		return runner.run(ctx, cfg, history, task, notify)
+	}
+}
+
+// interruptTask stores a task as still running, as if the server stopped during it
+func interruptTask(t *testing.T, server *Server, taskID string) {
+	require.NoError(t, server.db.Model(&models.TaskHistoryModel{}).Where("task_id = ?", taskID).
+		Updates(map[string]interface{}{"status": models.TaskStatusRunning, "result": ""}).Error)
+	server.markInterruptedTasks()
+}
+
+func TestResumeInterruptedTask(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := &recordingRunner{}
+	server.agentRunner = checkpointingRunner(runner)
+
+	taskID := startTestTask(t, server, "/api/task", TaskRequest{Task: "扫描example.com"})
+	interruptTask(t, server, taskID)
+
+	// 中断的任务及其检查点
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks?status=interrupted", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list struct {
+		Data []TaskListItem `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, taskID, list.Data[0].TaskID)
+	assert.Equal(t, models.TaskStatusInterrupted, list.Data[0].Status)
+	assert.Equal(t, 5, list.Data[0].CheckpointStep)
+	require.NotNil(t, list.Data[0].Checkpoint)
+	assert.Len(t, list.Data[0].Checkpoint.Messages, 3)
+
+	// 恢复的任务从检查点继续，包含已经完成的工具调用
+	resumedID := startTestTask(t, server, "/api/task/"+taskID+"/resume", struct{}{})
+	require.Len(t, runner.histories, 2)
+	history := runner.histories[1]
+	require.Len(t, history, 3)
+	assert.Equal(t, schema.User, history[0].Role)
+	assert.Equal(t, "扫描example.com", history[0].Content)
+	assert.Equal(t, "call_1", history[1].ToolCalls[0].ID)
+	assert.Equal(t, schema.Tool, history[2].Role)
+	assert.Equal(t, "80/tcp open", history[2].Content)
+
+	resumed := getTaskHistory(t, server, resumedID)
+	assert.Equal(t, taskID, resumed.Task.ParentTaskID)
+	assert.Equal(t, models.TaskStatusCompleted, resumed.Task.Status)
+	assert.Equal(t, "回答: "+mcpagent.MessagesFor("").ResumeInstruction, resumed.Task.Result)
+
+	// 只能恢复被中断的任务
+	w = postJSON(t, server, "/api/task/"+resumedID+"/resume", struct{}{})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = postJSON(t, server, "/api/task/task_unknown/resume", struct{}{})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResumeInterruptedTaskWithoutCheckpoint(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := &recordingRunner{}
+	server.agentRunner = runner.run
+
+	taskID := startTestTask(t, server, "/api/task", TaskRequest{Task: "扫描example.com"})
+	interruptTask(t, server, taskID)
+
+	// 没有检查点时重新执行原任务
+	startTestTask(t, server, "/api/task/"+taskID+"/resume", struct{}{})
+	require.Len(t, runner.histories, 2)
+	assert.Empty(t, runner.histories[1])
+}
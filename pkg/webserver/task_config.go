@@ -0,0 +1,417 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
+)
+
+// errDatabaseUnavailable is returned when a task has to be resolved from stored
+// settings but the server was started without a database.
+var errDatabaseUnavailable = errors.New("配置信息不能为空：未提供config且数据库不可用")
+
+// hasOverrides reports whether the request carries any lightweight override
+func (r *TaskRequest) hasOverrides() bool {
+	return r.LLMConfigID != nil || r.SystemPromptID != nil || r.MaxStep > 0 || r.MaxDuration > 0 ||
+		len(r.Tools) > 0 || len(r.PlaceHolders) > 0 || len(r.OutputSchema) > 0 || r.PlanMode != nil || r.PlanApproval != nil ||
+		r.Instructions != "" || r.FirstTool != "" || r.AllowedToolsPerStep > 0 || r.EnableNotes != nil ||
+		r.RequireTools != "" || r.ToolsAutoSelect != nil
+}
+
+// applyTaskTemplate renders the task template referenced by the request into
+// its Task. The tools and the LLM config of the template are used unless the
+// request sets its own.
+//
+// Parameters:
+//   - taskReq: Decoded task request, modified in place
+//
+// Returns:
+//   - error: *models.TemplateRenderError for invalid params, models.ErrTaskTemplateNotFound
+//     for an unknown template, or an error for a malformed request
+func (s *Server) applyTaskTemplate(taskReq *TaskRequest) error {
+	if taskReq.TemplateID == nil {
+		if len(taskReq.Params) > 0 {
+			return errors.New("params仅能与template_id一起使用")
+		}
+		return nil
+	}
+	if taskReq.Task != "" {
+		return errors.New("task和template_id不能同时提供")
+	}
+	if s.db == nil {
+		return errDatabaseUnavailable
+	}
+
+	template, task, err := s.taskTemplateService.RenderTemplate(*taskReq.TemplateID, taskReq.Params)
+	if err != nil {
+		return err
+	}
+	taskReq.Task = task
+
+	if taskReq.LLMConfigID == nil {
+		taskReq.LLMConfigID = template.LLMConfigID
+	}
+	if len(taskReq.Tools) == 0 {
+		tools, err := template.GetTools()
+		if err != nil {
+			return fmt.Errorf("%w: %v", models.ErrTaskTemplateToolsInvalid, err)
+		}
+		for _, tool := range tools {
+			taskReq.Tools = append(taskReq.Tools, config.MCPToolConfig{Server: tool.Server, Name: tool.Name})
+		}
+	}
+	return nil
+}
+
+// resolveTaskConfig builds the complete configuration for a task request.
+//
+// If the request carries a full Config it is used as the base, otherwise the base
+// is assembled from the database: the default app config, the default LLM config
+// and all active MCP servers, with the default system prompt and max step of the
+// selected LLM config in place of those of the app config. The lightweight
+// overrides of the request are then applied on top, so the frontend never has
+// to handle API keys. The guard prompt always comes from the server, a request
+// cannot replace or remove it.
+//
+// Parameters:
+//   - taskReq: Decoded task request
+//
+// Returns:
+//   - *config.Config: Resolved configuration, not yet validated
+//   - error: Error if a referenced ID does not exist or the database is unavailable
+func (s *Server) resolveTaskConfig(taskReq *TaskRequest) (*config.Config, error) {
+	cfg, _, err := s.resolveTaskConfigWithSources(taskReq)
+	return cfg, err
+}
+
+// resolveTaskConfigWithSources resolves the configuration of a task like
+// resolveTaskConfig and records which layer supplied each field: the request,
+// the database or the defaults.
+func (s *Server) resolveTaskConfigWithSources(taskReq *TaskRequest) (*config.Config, config.ConfigSource, error) {
+	var cfg *config.Config
+	sources := config.ConfigSource{}
+	if taskReq.Config != nil {
+		// 兼容旧版本：前端直接提供完整配置
+		copied := *taskReq.Config
+		cfg = &copied
+		for _, field := range config.ConfigFields() {
+			sources.Set(field, config.SourceRequest)
+		}
+	} else {
+		if s.db == nil {
+			return nil, nil, errDatabaseUnavailable
+		}
+		base, err := s.loadDefaultTaskConfig(sources)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg = base
+	}
+	if err := s.applyGuardPrompt(cfg, sources); err != nil {
+		return nil, nil, err
+	}
+	if taskReq.Config == nil {
+		if err := s.applyLLMConfigDefaults(cfg, sources, taskReq.LLMConfigID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if !taskReq.hasOverrides() {
+		return s.withStoredDescriptions(s.withStoredResultFilters(cfg)), sources, nil
+	}
+	if s.db == nil && (taskReq.LLMConfigID != nil || taskReq.SystemPromptID != nil) {
+		return nil, nil, errDatabaseUnavailable
+	}
+
+	if taskReq.LLMConfigID != nil {
+		llmConfig, err := s.llmConfigService.GetConfig(*taskReq.LLMConfigID)
+		if err != nil {
+			if errors.Is(err, models.ErrLLMConfigNotFound) {
+				return nil, nil, fmt.Errorf("llm_config_id=%d 对应的LLM配置不存在", *taskReq.LLMConfigID)
+			}
+			return nil, nil, fmt.Errorf("获取LLM配置失败: %w", err)
+		}
+		config.SetField(sources, "llm", config.SourceRequest, &cfg.LLM, services.LLMConfigToConfig(llmConfig))
+	}
+
+	if taskReq.SystemPromptID != nil {
+		prompt, err := s.systemPromptService.GetPrompt(*taskReq.SystemPromptID)
+		if err != nil {
+			if errors.Is(err, models.ErrSystemPromptNotFound) {
+				return nil, nil, fmt.Errorf("system_prompt_id=%d 对应的系统提示词不存在", *taskReq.SystemPromptID)
+			}
+			return nil, nil, fmt.Errorf("获取系统提示词失败: %w", err)
+		}
+		config.SetField(sources, "system_prompt", config.SourceRequest, &cfg.SystemPrompt, prompt.Content)
+	}
+
+	if taskReq.MaxStep > 0 {
+		config.SetField(sources, "max_step", config.SourceRequest, &cfg.MaxStep, taskReq.MaxStep)
+	}
+
+	if taskReq.MaxDuration > 0 {
+		config.SetField(sources, "max_duration", config.SourceRequest, &cfg.MaxDuration, taskReq.MaxDuration)
+	}
+
+	if len(taskReq.Tools) > 0 {
+		config.SetField(sources, "mcp.tools", config.SourceRequest, &cfg.MCP.Tools, taskReq.Tools)
+	}
+
+	if len(taskReq.PlaceHolders) > 0 {
+		// 复制占位符，避免修改请求或默认配置中的map
+		placeHolders := make(map[string]any, len(cfg.PlaceHolders)+len(taskReq.PlaceHolders))
+		for k, v := range cfg.PlaceHolders {
+			placeHolders[k] = v
+		}
+		for k, v := range taskReq.PlaceHolders {
+			placeHolders[k] = v
+		}
+		config.SetField(sources, "placeholders", config.SourceRequest, &cfg.PlaceHolders, placeHolders)
+	}
+
+	if len(taskReq.OutputSchema) > 0 {
+		outputSchema, err := decodeOutputSchema(taskReq.OutputSchema)
+		if err != nil {
+			return nil, nil, err
+		}
+		config.SetField(sources, "output_schema", config.SourceRequest, &cfg.OutputSchema, outputSchema)
+	}
+
+	if taskReq.PlanMode != nil {
+		config.SetField(sources, "plan_mode", config.SourceRequest, &cfg.PlanMode, *taskReq.PlanMode)
+	}
+	if taskReq.PlanApproval != nil {
+		config.SetField(sources, "plan_approval", config.SourceRequest, &cfg.PlanApproval, *taskReq.PlanApproval)
+	}
+
+	if taskReq.Instructions != "" {
+		config.SetField(sources, "instructions", config.SourceRequest, &cfg.Instructions, taskReq.Instructions)
+	}
+
+	if taskReq.FirstTool != "" {
+		config.SetField(sources, "agent", config.SourceRequest, &cfg.Agent.FirstTool, taskReq.FirstTool)
+	}
+	if taskReq.AllowedToolsPerStep > 0 {
+		config.SetField(sources, "agent", config.SourceRequest, &cfg.Agent.AllowedToolsPerStep, taskReq.AllowedToolsPerStep)
+	}
+	if taskReq.EnableNotes != nil {
+		config.SetField(sources, "agent", config.SourceRequest, &cfg.Agent.EnableNotes, *taskReq.EnableNotes)
+	}
+	if taskReq.RequireTools != "" {
+		config.SetField(sources, "agent", config.SourceRequest, &cfg.Agent.RequireTools, taskReq.RequireTools)
+	}
+	if taskReq.ToolsAutoSelect != nil {
+		config.SetField(sources, "mcp.tools_auto_select", config.SourceRequest, &cfg.MCP.ToolsAutoSelect, *taskReq.ToolsAutoSelect)
+	}
+
+	return s.withStoredDescriptions(s.withStoredResultFilters(cfg)), sources, nil
+}
+
+// applyLLMConfigDefaults sets the default system prompt and max step of the
+// LLM config the task uses on cfg: the one with id, or the default LLM config
+// if id is nil. An unknown id is reported when the LLM config is applied.
+// The applied fields are recorded in sources.
+func (s *Server) applyLLMConfigDefaults(cfg *config.Config, sources config.ConfigSource, id *uint) error {
+	var llmConfig *models.LLMConfigModel
+	var err error
+	if id != nil {
+		llmConfig, err = s.llmConfigService.GetConfig(*id)
+	} else {
+		llmConfig, err = s.llmConfigService.GetDefaultConfig()
+	}
+	if errors.Is(err, models.ErrLLMConfigNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("获取LLM配置失败: %w", err)
+	}
+
+	if llmConfig.DefaultSystemPromptID != nil {
+		prompt, err := s.systemPromptService.GetPrompt(*llmConfig.DefaultSystemPromptID)
+		switch {
+		case errors.Is(err, models.ErrSystemPromptNotFound):
+			// 删除提示词时会清空引用，这里只在数据被直接修改时出现
+			log.Printf("LLM配置 %s 的默认系统提示词 %d 不存在，使用全局默认提示词", llmConfig.Name, *llmConfig.DefaultSystemPromptID)
+		case err != nil:
+			return fmt.Errorf("获取系统提示词失败: %w", err)
+		default:
+			config.SetField(sources, "system_prompt", config.SourceDatabase, &cfg.SystemPrompt, prompt.Content)
+		}
+	}
+	if llmConfig.DefaultMaxStep != nil {
+		config.SetField(sources, "max_step", config.SourceDatabase, &cfg.MaxStep, *llmConfig.DefaultMaxStep)
+	}
+	return nil
+}
+
+// applyGuardPrompt sets the guard prompt of the server on cfg, replacing the
+// one of a request. It is stored with the default app config, or with the
+// in-memory configuration when the server runs without a database. The
+// source of the guard prompt is recorded in sources, which may be nil.
+func (s *Server) applyGuardPrompt(cfg *config.Config, sources config.ConfigSource) error {
+	config.SetField(sources, "guard_prompt", config.SourceFile, &cfg.GuardPrompt, s.config.GuardPrompt)
+	if s.db == nil {
+		return nil
+	}
+	appConfig, err := s.appConfigService.GetDefaultConfig()
+	if err != nil {
+		if errors.Is(err, models.ErrAppConfigNotFound) {
+			return nil
+		}
+		return fmt.Errorf("获取安全约束提示词失败: %w", err)
+	}
+	config.SetField(sources, "guard_prompt", config.SourceDatabase, &cfg.GuardPrompt, appConfig.GuardPrompt)
+	return nil
+}
+
+// notificationSinks creates the notification sinks of the server for the task
+// taskID. They are stored with the default app config, or with the in-memory
+// configuration when the server runs without a database. The notifications of
+// a task request are ignored like its audit settings, so a request cannot make
+// the server post to other URLs or write other files.
+func (s *Server) notificationSinks(taskID string) mcpagent.NotificationSinks {
+	notifications := s.config.Notifications
+	if s.db != nil {
+		stored, err := s.appConfigService.GetNotificationsConfig()
+		if err != nil {
+			log.Printf("获取通知配置失败: %v", err)
+			return nil
+		}
+		notifications = stored
+	}
+	return mcpagent.NewNotificationSinks(notifications, taskID)
+}
+
+// withStoredResultFilters sets the result filters stored with the tools in
+// the database on the tools of cfg that configure none
+func (s *Server) withStoredResultFilters(cfg *config.Config) *config.Config {
+	if s.db == nil || s.mcpToolService == nil || len(cfg.MCP.Tools) == 0 {
+		return cfg
+	}
+	filters, err := s.mcpToolService.ResultFilters()
+	if err != nil {
+		log.Printf("获取工具结果过滤表达式失败: %v", err)
+		return cfg
+	}
+	if len(filters) == 0 {
+		return cfg
+	}
+
+	// 复制工具列表，避免修改请求或默认配置
+	tools := make([]config.MCPToolConfig, len(cfg.MCP.Tools))
+	copy(tools, cfg.MCP.Tools)
+	for i := range tools {
+		if tools[i].ResultFilter == "" {
+			tools[i].ResultFilter = filters[models.GenerateToolKey(tools[i].Server, tools[i].Name)]
+		}
+	}
+	cfg.MCP.Tools = tools
+	return cfg
+}
+
+// withStoredDescriptions sets the localized descriptions stored with the
+// tools in the database on the tools of cfg that configure none
+func (s *Server) withStoredDescriptions(cfg *config.Config) *config.Config {
+	if s.db == nil || s.mcpToolService == nil || len(cfg.MCP.Tools) == 0 {
+		return cfg
+	}
+	descriptions, err := s.mcpToolService.LocalizedDescriptions()
+	if err != nil {
+		log.Printf("获取工具本地化描述失败: %v", err)
+		return cfg
+	}
+	if len(descriptions) == 0 {
+		return cfg
+	}
+
+	// 复制工具列表，避免修改请求或默认配置
+	tools := make([]config.MCPToolConfig, len(cfg.MCP.Tools))
+	copy(tools, cfg.MCP.Tools)
+	for i := range tools {
+		if len(tools[i].Descriptions) == 0 {
+			tools[i].Descriptions = descriptions[models.GenerateToolKey(tools[i].Server, tools[i].Name)]
+		}
+	}
+	cfg.MCP.Tools = tools
+	return cfg
+}
+
+// decodeOutputSchema returns the JSON text of an output_schema given either as
+// a JSON object or as a string containing the schema
+func decodeOutputSchema(raw json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, raw); err != nil {
+		return "", fmt.Errorf("output_schema无效: %w", err)
+	}
+	return compacted.String(), nil
+}
+
+// loadDefaultTaskConfig assembles a configuration from the settings stored in
+// the database, the fields read from the database are recorded in sources,
+// which may be nil
+func (s *Server) loadDefaultTaskConfig(sources config.ConfigSource) (*config.Config, error) {
+	cfg := config.NewDefaultConfig()
+
+	appConfig, err := s.appConfigService.GetDefaultConfig()
+	if err == nil {
+		if err := s.appConfigService.SaveToConfig(appConfig, cfg); err != nil {
+			return nil, fmt.Errorf("应用默认配置失败: %w", err)
+		}
+		for _, field := range services.AppConfigFields {
+			sources.Set(field, config.SourceDatabase)
+		}
+	} else if !errors.Is(err, models.ErrAppConfigNotFound) {
+		return nil, fmt.Errorf("获取默认配置失败: %w", err)
+	}
+
+	llmConfig, err := s.llmConfigService.GetDefaultConfig()
+	if err == nil {
+		config.SetField(sources, "llm", config.SourceDatabase, &cfg.LLM, services.LLMConfigToConfig(llmConfig))
+	} else if !errors.Is(err, models.ErrLLMConfigNotFound) {
+		return nil, fmt.Errorf("获取默认LLM配置失败: %w", err)
+	}
+
+	serverConfigs, err := s.mcpServerConfigService.GetAllActiveConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("获取MCP服务器配置失败: %w", err)
+	}
+	mcpServers := make(map[string]*mcphost.ServerConfig)
+	namePrefixes := make(map[string]string)
+	maxConcurrency := make(map[string]int)
+	for name, serverConfig := range serverConfigs {
+		// 内置服务器不是真实的MCP服务器
+		if name == config.InnerServerName {
+			continue
+		}
+		if serverConfig.NamePrefix != "" {
+			namePrefixes[name] = serverConfig.NamePrefix
+		}
+		if serverConfig.MaxConcurrency != 0 {
+			maxConcurrency[name] = serverConfig.MaxConcurrency
+		}
+		sc, err := serverConfig.ToServerConfig()
+		if err != nil {
+			log.Printf("转换服务器配置失败 %s: %v", name, err)
+			continue
+		}
+		mcpServers[name] = &sc
+	}
+	config.SetField(sources, "mcp.mcp_servers", config.SourceDatabase, &cfg.MCP.MCPServers, mcpServers)
+	config.SetField(sources, "mcp.name_prefixes", config.SourceDatabase, &cfg.MCP.NamePrefixes, namePrefixes)
+	config.SetField(sources, "mcp.max_concurrency", config.SourceDatabase, &cfg.MCP.MaxConcurrency, maxConcurrency)
+
+	return cfg, nil
+}
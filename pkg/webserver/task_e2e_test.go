@@ -0,0 +1,339 @@
+package webserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedRunner 按脚本发送通知的任务执行器，记录收到的配置和任务。
+// 关闭release之前不开始执行，测试可以先连接SSE再让任务运行。
+type scriptedRunner struct {
+	release chan struct{}
+	script  func(notify mcpagent.Notify) error
+
+	mu      sync.Mutex
+	configs []*config.Config
+	tasks   []string
+}
+
+func newScriptedRunner(script func(notify mcpagent.Notify) error) *scriptedRunner {
+	return &scriptedRunner{release: make(chan struct{}), script: script}
+}
+
+func (r *scriptedRunner) run(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+	r.mu.Lock()
+	r.configs = append(r.configs, cfg)
+	r.tasks = append(r.tasks, task)
+	r.mu.Unlock()
+
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return r.script(notify)
+}
+
+// received 返回执行器收到的配置和任务
+func (r *scriptedRunner) received() ([]*config.Config, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*config.Config(nil), r.configs...), append([]string(nil), r.tasks...)
+}
+
+// sseTestMessage SSE客户端收到的一条消息
+type sseTestMessage struct {
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+	TaskID string          `json:"task_id"`
+}
+
+// status 解析状态消息
+func (m sseTestMessage) status(t *testing.T) TaskStatus {
+	t.Helper()
+	require.Equal(t, MessageTypeStatus, m.Type, string(m.Data))
+	var status TaskStatus
+	require.NoError(t, json.Unmarshal(m.Data, &status))
+	return status
+}
+
+// event 解析通知事件
+func (m sseTestMessage) event(t *testing.T) NotifyEvent {
+	t.Helper()
+	require.Equal(t, MessageTypeNotify, m.Type, string(m.Data))
+	var event NotifyEvent
+	require.NoError(t, json.Unmarshal(m.Data, &event))
+	return event
+}
+
+// sseTestClient 通过HTTP连接/events的客户端
+type sseTestClient struct {
+	messages chan sseTestMessage
+}
+
+// connectTaskSSE 连接任务的/events，读到连接确认后返回
+func connectTaskSSE(t *testing.T, ts *httptest.Server, taskID string) *sseTestClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events?taskId="+taskID, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	t.Cleanup(func() {
+		cancel()
+		resp.Body.Close()
+	})
+
+	client := &sseTestClient{messages: make(chan sseTestMessage, 100)}
+	go func() {
+		defer close(client.messages)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var msg sseTestMessage
+			if json.Unmarshal([]byte(line), &msg) == nil {
+				client.messages <- msg
+			}
+		}
+	}()
+
+	var connected struct {
+		Connected bool   `json:"connected"`
+		TaskID    string `json:"task_id"`
+	}
+	msg := client.next(t)
+	require.Equal(t, MessageTypeStatus, msg.Type)
+	require.NoError(t, json.Unmarshal(msg.Data, &connected))
+	require.True(t, connected.Connected)
+	require.Equal(t, taskID, connected.TaskID)
+	return client
+}
+
+// next 返回下一条消息，超时则测试失败
+func (c *sseTestClient) next(t *testing.T) sseTestMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		require.True(t, ok, "SSE连接已关闭")
+		return msg
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "等待SSE消息超时")
+		return sseTestMessage{}
+	}
+}
+
+// untilFinished 返回任务结束之前收到的所有消息，最后一条为任务的最终状态
+func (c *sseTestClient) untilFinished(t *testing.T) []sseTestMessage {
+	t.Helper()
+	var messages []sseTestMessage
+	for {
+		msg := c.next(t)
+		messages = append(messages, msg)
+		if msg.Type == MessageTypeStatus && isTerminalTaskStatus(msg.status(t).Status) {
+			return messages
+		}
+	}
+}
+
+// postTask 通过HTTP提交任务，返回任务ID
+func postTask(t *testing.T, ts *httptest.Server, body string) string {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/api/task", "application/json", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Success bool   `json:"success"`
+		TaskID  string `json:"task_id"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.True(t, result.Success)
+	require.NotEmpty(t, result.TaskID)
+	return result.TaskID
+}
+
+func TestTaskSSEEndToEnd(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := newScriptedRunner(func(notify mcpagent.Notify) error {
+		notify.OnMessage("开始分析")
+		notify.OnThinking("需要先搜索")
+		notify.OnToolCall("search", map[string]any{"query": "example.com"})
+		notify.OnResult("example.com 使用了nginx")
+		return nil
+	})
+	server.agentRunner = runner.run
+	ts := httptest.NewServer(server.router)
+	t.Cleanup(ts.Close)
+
+	taskID := postTask(t, ts, `{"task":"分析example.com","max_step":3,"tools":["inner:search"]}`)
+	client := connectTaskSSE(t, ts, taskID)
+	close(runner.release)
+	messages := client.untilFinished(t)
+
+	// 事件按发送的顺序到达，最后是完成状态
+	require.Len(t, messages, 5)
+	expected := []struct{ eventType, content string }{
+		{EventTypeMessage, "开始分析"},
+		{EventTypeThinking, "需要先搜索"},
+		{EventTypeToolCall, ""},
+		{EventTypeResult, "example.com 使用了nginx"},
+	}
+	for i, want := range expected {
+		event := messages[i].event(t)
+		assert.Equal(t, taskID, messages[i].TaskID)
+		assert.Equal(t, want.eventType, event.Type)
+		assert.Equal(t, int64(i+1), event.Seq)
+		if want.content != "" {
+			assert.Equal(t, want.content, event.Content)
+		}
+	}
+	assert.Equal(t, "search", messages[2].event(t).ToolName)
+	final := messages[4].status(t)
+	assert.Equal(t, taskID, final.ID)
+	assert.Equal(t, "completed", final.Status)
+	assert.Empty(t, final.ErrorCode)
+
+	// 执行器收到经过校验、合并了请求覆盖项的配置
+	configs, tasks := runner.received()
+	require.Len(t, configs, 1)
+	assert.Equal(t, []string{"分析example.com"}, tasks)
+	assert.Equal(t, 3, configs[0].MaxStep)
+	assert.Equal(t, []config.MCPToolConfig{{Server: config.InnerServerName, Name: "search"}}, configs[0].MCP.Tools)
+	assert.Equal(t, "qwen3:14b", configs[0].LLM.Model)
+	assert.NoError(t, configs[0].Validate())
+
+	// 历史记录中的状态和结果与推送的一致
+	record := getTaskHistory(t, server, taskID).Task
+	assert.Equal(t, "completed", record.Status)
+	assert.Equal(t, "example.com 使用了nginx", record.Result)
+}
+
+func TestTaskSSEStreamsResultChunks(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := newScriptedRunner(func(notify mcpagent.Notify) error {
+		streamNotify, ok := notify.(mcpagent.StreamingNotify)
+		require.True(t, ok, "任务的通知处理器应支持流式输出")
+		streamNotify.OnStreamResult("example.com ")
+		streamNotify.OnStreamResult("使用了nginx")
+		notify.OnResult("example.com 使用了nginx")
+		return nil
+	})
+	server.agentRunner = runner.run
+	ts := httptest.NewServer(server.router)
+	t.Cleanup(ts.Close)
+
+	taskID := postTask(t, ts, `{"task":"分析example.com"}`)
+	client := connectTaskSSE(t, ts, taskID)
+	close(runner.release)
+	messages := client.untilFinished(t)
+
+	// 回答片段按顺序到达，最后仍发送完整的结果
+	require.Len(t, messages, 4)
+	for i, chunk := range []string{"example.com ", "使用了nginx"} {
+		event := messages[i].event(t)
+		assert.Equal(t, EventTypeResultChunk, event.Type)
+		assert.Equal(t, chunk, event.Content)
+	}
+	assert.Equal(t, "example.com 使用了nginx", messages[2].event(t).Content)
+	assert.Equal(t, "completed", messages[3].status(t).Status)
+	assert.Equal(t, "example.com 使用了nginx", getTaskHistory(t, server, taskID).Task.Result)
+}
+
+func TestTaskSSEEndToEndFailure(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := newScriptedRunner(func(notify mcpagent.Notify) error {
+		notify.OnMessage("开始分析")
+		return errors.New("大模型不可用")
+	})
+	server.agentRunner = runner.run
+	ts := httptest.NewServer(server.router)
+	t.Cleanup(ts.Close)
+
+	taskID := postTask(t, ts, `{"task":"分析example.com"}`)
+	client := connectTaskSSE(t, ts, taskID)
+	close(runner.release)
+	messages := client.untilFinished(t)
+
+	// 失败的任务先推送错误事件，再推送error状态
+	require.Len(t, messages, 3)
+	assert.Equal(t, EventTypeMessage, messages[0].event(t).Type)
+	errorEvent := messages[1].event(t)
+	assert.Equal(t, EventTypeError, errorEvent.Type)
+	assert.Contains(t, errorEvent.Error, "大模型不可用")
+	final := messages[2].status(t)
+	assert.Equal(t, "error", final.Status)
+	assert.Equal(t, mcpagent.ErrorCode(errors.New("大模型不可用")), final.ErrorCode)
+	assert.Equal(t, "error", getTaskHistory(t, server, taskID).Task.Status)
+}
+
+func TestTaskEndToEndRejectedRequestNotRun(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := newScriptedRunner(func(notify mcpagent.Notify) error { return nil })
+	server.agentRunner = runner.run
+	close(runner.release)
+
+	// 校验失败的请求不会执行任务
+	w := postJSON(t, server, "/api/task", map[string]any{"task": "测试任务", "tools": []string{"fetch:"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = postJSON(t, server, "/api/task", map[string]any{"task": "测试任务", "llm_config_id": 9999})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	configs, _ := runner.received()
+	assert.Empty(t, configs)
+}
+
+func TestTaskCancelEndToEnd(t *testing.T) {
+	server := setupTaskTestServer(t)
+	// 不关闭release，任务一直运行到被取消
+	runner := newScriptedRunner(func(notify mcpagent.Notify) error { return nil })
+	server.agentRunner = runner.run
+	ts := httptest.NewServer(server.router)
+	t.Cleanup(ts.Close)
+
+	taskID := postTask(t, ts, `{"task":"分析example.com"}`)
+	client := connectTaskSSE(t, ts, taskID)
+	require.Eventually(t, func() bool {
+		configs, _ := runner.received()
+		return len(configs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	w := postJSON(t, server, "/api/task/"+taskID+"/cancel", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	messages := client.untilFinished(t)
+
+	// 执行器的上下文被取消，任务以canceled状态结束而不是error
+	require.Len(t, messages, 2)
+	errorEvent := messages[0].event(t)
+	assert.Contains(t, errorEvent.Error, "任务已被用户中断")
+	assert.Equal(t, mcpagent.ErrorCodeCanceled, errorEvent.ErrorCode)
+	final := messages[1].status(t)
+	assert.Equal(t, "canceled", final.Status)
+	assert.Equal(t, mcpagent.ErrorCodeCanceled, final.ErrorCode)
+	assert.Equal(t, "canceled", getTaskHistory(t, server, taskID).Task.Status)
+
+	// 已经结束和不存在的任务不能取消
+	w = postJSON(t, server, "/api/task/"+taskID+"/cancel", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = postJSON(t, server, "/api/task/task_unknown/cancel", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,191 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// taskEventCapacity is the number of events buffered per task, older events are dropped
+	taskEventCapacity = 1000
+
+	// taskEventRetention is how long the events of a finished task stay readable
+	taskEventRetention = 5 * time.Minute
+
+	// ndjsonContentType is the content type of GET /api/task/{taskId}/events
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// taskEvents buffers the notify events of one task and wakes up their readers
+type taskEvents struct {
+	mutex   sync.Mutex
+	events  []NotifyEvent // 按序号递增排列，最多taskEventCapacity个
+	seq     eventSequence
+	done    bool          // 任务已进入终止状态
+	changed chan struct{} // 有新事件或任务结束时关闭并替换
+}
+
+// since returns the buffered events with a sequence number of at least from,
+// whether the task is finished, and a channel closed on the next change
+func (e *taskEvents) since(from int64) ([]NotifyEvent, bool, <-chan struct{}) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	start := len(e.events)
+	for i, event := range e.events {
+		if event.Seq >= from {
+			start = i
+			break
+		}
+	}
+	events := make([]NotifyEvent, len(e.events)-start)
+	copy(events, e.events[start:])
+	return events, e.done, e.changed
+}
+
+// taskEventLog holds the event buffers of running and recently finished tasks
+type taskEventLog struct {
+	mutex sync.Mutex
+	tasks map[string]*taskEvents
+}
+
+func newTaskEventLog() *taskEventLog {
+	return &taskEventLog{tasks: make(map[string]*taskEvents)}
+}
+
+// get returns the buffer of a task, nil if the task has none
+func (l *taskEventLog) get(taskID string) *taskEvents {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.tasks[taskID]
+}
+
+// record buffers a message sent to the clients of a task. Notify events are
+// stamped with their sequence number and ID in the order they are recorded,
+// a terminal status ends the task. Events sent after the end are stamped but
+// no longer buffered.
+//
+// Returns:
+//   - SSEMessage: msg with its notify event stamped
+func (l *taskEventLog) record(taskID string, msg SSEMessage) SSEMessage {
+	event, isEvent := msg.Data.(NotifyEvent)
+	status, isStatus := msg.Data.(TaskStatus)
+	if !isEvent && !isStatus {
+		return msg
+	}
+
+	l.mutex.Lock()
+	events, ok := l.tasks[taskID]
+	if !ok {
+		events = &taskEvents{changed: make(chan struct{})}
+		l.tasks[taskID] = events
+	}
+	l.mutex.Unlock()
+
+	events.mutex.Lock()
+	defer events.mutex.Unlock()
+	if isEvent {
+		event = events.seq.stamp(taskID, event)
+		msg.Data = event
+	}
+	if events.done {
+		return msg
+	}
+	if isEvent {
+		if len(events.events) == taskEventCapacity {
+			events.events = append(events.events[:0], events.events[1:]...)
+		}
+		events.events = append(events.events, event)
+	} else if isTerminalTaskStatus(status.Status) {
+		events.done = true
+		time.AfterFunc(taskEventRetention, func() { l.remove(taskID, events) })
+	} else {
+		return msg
+	}
+	close(events.changed)
+	events.changed = make(chan struct{})
+	return msg
+}
+
+// remove drops the buffer of a task unless it was replaced
+func (l *taskEventLog) remove(taskID string, events *taskEvents) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.tasks[taskID] == events {
+		delete(l.tasks, taskID)
+	}
+}
+
+// isTerminalTaskStatus reports whether a task with status has finished
+func isTerminalTaskStatus(status string) bool {
+	switch status {
+	case "completed", "error", "timeout", "canceled":
+		return true
+	default:
+		return false
+	}
+}
+
+// handleTaskEvents handles GET /api/task/{taskId}/events?format=ndjson
+// 以换行分隔的JSON（NDJSON）流式返回任务的通知事件，每行一个NotifyEvent，
+// 任务进入终止状态后关闭连接。?from_seq= 从缓存中指定序号的事件开始。
+func (s *Server) handleTaskEvents(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["taskId"]
+	if format := r.URL.Query().Get("format"); format != "" && format != "ndjson" {
+		writeError(w, fmt.Sprintf("不支持的事件格式: %s，可选值为 ndjson", format), http.StatusBadRequest)
+		return
+	}
+	var from int64
+	if value := r.URL.Query().Get("from_seq"); value != "" {
+		seq, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seq < 0 {
+			writeError(w, "from_seq必须是非负整数", http.StatusBadRequest)
+			return
+		}
+		from = seq
+	}
+
+	events := s.events.get(taskID)
+	if events == nil {
+		writeError(w, "任务不存在或事件已过期", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	ctx := r.Context()
+	for {
+		batch, done, changed := events.since(from)
+		for _, event := range batch {
+			// Encode在每个对象后写入换行符
+			if err := encoder.Encode(event); err != nil {
+				log.Printf("发送任务事件失败 %s: %v", taskID, err)
+				return
+			}
+			from = event.Seq + 1
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
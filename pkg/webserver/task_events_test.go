@@ -0,0 +1,113 @@
+package webserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readNDJSONEvents reads the events of a NDJSON response until the server closes it
+func readNDJSONEvents(t *testing.T, resp *http.Response) []NotifyEvent {
+	t.Helper()
+	var events []NotifyEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event NotifyEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), "每行都是完整的JSON对象: %s", scanner.Text())
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestHandleTaskEventsNDJSON(t *testing.T) {
+	server := setupTaskTestServer(t)
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	taskID := "task_ndjson"
+	server.broadcastToTask(taskID, SSEMessage{Type: "status", Data: TaskStatus{ID: taskID, Status: "running"}})
+	notifier := &BroadcastNotifier{server: server, taskID: taskID}
+	notifier.OnMessage("开始")
+
+	resp, err := http.Get(ts.URL + "/api/task/" + taskID + "/events?format=ndjson")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ndjsonContentType, resp.Header.Get("Content-Type"))
+
+	// 连接后产生的事件继续推送，任务结束后连接关闭
+	done := make(chan []NotifyEvent)
+	go func() { done <- readNDJSONEvents(t, resp) }()
+	notifier.OnToolCall("fetch", map[string]any{"url": "https://example.com"})
+	notifier.OnResult("完成")
+	server.broadcastToTask(taskID, SSEMessage{Type: "status", Data: TaskStatus{ID: taskID, Status: "completed"}})
+
+	var events []NotifyEvent
+	select {
+	case events = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("任务结束后事件流没有关闭")
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, []string{"message", "tool_call", "result"}, []string{events[0].Type, events[1].Type, events[2].Type})
+	for i, event := range events {
+		assert.Equal(t, int64(i+1), event.Seq)
+	}
+
+	// 从指定序号开始读取已结束任务的缓存事件
+	resp, err = http.Get(ts.URL + "/api/task/" + taskID + "/events?from_seq=2")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	events = readNDJSONEvents(t, resp)
+	require.Len(t, events, 2)
+	assert.Equal(t, int64(2), events[0].Seq)
+	assert.Equal(t, "完成", events[1].Content)
+}
+
+func TestHandleTaskEventsClientDisconnect(t *testing.T) {
+	server := setupTaskTestServer(t)
+	taskID := "task_running"
+	server.broadcastToTask(taskID, SSEMessage{Type: "status", Data: TaskStatus{ID: taskID, Status: "running"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/api/task/"+taskID+"/events", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		server.router.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("客户端断开后处理器没有返回")
+	}
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandleTaskEventsErrors(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.broadcastToTask("task_known", SSEMessage{Type: "status", Data: TaskStatus{ID: "task_known", Status: "running"}})
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/task/task_unknown/events", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/task/task_known/events?format=sse", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/task/task_known/events?from_seq=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
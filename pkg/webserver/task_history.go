@@ -0,0 +1,314 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gorilla/mux"
+)
+
+// agentRunner executes a task, after the messages of history when the task continues a conversation
+type agentRunner func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error
+
+// runAgent executes a task with mcpagent
+func runAgent(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+	if len(history) == 0 {
+		return mcpagent.Run(ctx, cfg, task, notify)
+	}
+	return mcpagent.RunWithHistory(ctx, cfg, history, task, notify)
+}
+
+// errTaskWithoutConfig is returned for tasks recorded without a configuration snapshot
+var errTaskWithoutConfig = errors.New("任务没有保存配置")
+
+// ContinueTaskRequest represents a request to continue a finished task
+type ContinueTaskRequest struct {
+	Instruction string `json:"instruction"` // 追加的指令
+}
+
+// recordTaskStart stores a started task with a snapshot of its configuration.
+// Failures are only logged, the task runs without history.
+func (s *Server) recordTaskStart(taskID, parentTaskID string, taskConfig *config.Config, task string) {
+	if s.db == nil {
+		return
+	}
+
+	record := &models.TaskHistoryModel{TaskID: taskID, ParentTaskID: parentTaskID, Task: task}
+	snapshot, err := json.Marshal(taskConfig)
+	if err == nil {
+		err = record.SetConfig(snapshot)
+	}
+	if err == nil {
+		err = s.taskHistoryService.CreateTask(record)
+	}
+	if err != nil {
+		log.Printf("保存任务记录失败 %s: %v", taskID, err)
+	}
+}
+
+// recordTaskFinish stores the outcome of a task
+func (s *Server) recordTaskFinish(taskID, status, result, model string, taskErr error) {
+	if s.db == nil {
+		return
+	}
+	if err := s.taskHistoryService.FinishTask(taskID, status, result, model, taskErr, mcpagent.ErrorCode(taskErr)); err != nil {
+		log.Printf("更新任务记录失败 %s: %v", taskID, err)
+	}
+}
+
+// handleContinueTask handles POST /api/task/{taskId}/continue
+// 以已结束任务的对话为上下文执行追加指令，新任务通过parent_task_id关联原任务
+func (s *Server) handleContinueTask(w http.ResponseWriter, r *http.Request) {
+	parentID := mux.Vars(r)["taskId"]
+
+	var req ContinueTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求数据失败", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Instruction) == "" {
+		writeError(w, "追加指令不能为空", http.StatusBadRequest)
+		return
+	}
+	if s.db == nil {
+		writeError(w, "数据库不可用，无法继续任务", http.StatusServiceUnavailable)
+		return
+	}
+
+	chain, err := s.taskHistoryService.GetChain(parentID)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取任务记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	parent := chain[len(chain)-1]
+	if !parent.IsFinished() {
+		writeError(w, "任务仍在执行，结束后才能继续", http.StatusConflict)
+		return
+	}
+
+	taskConfig, err := s.continuationConfig(&parent)
+	if err != nil {
+		var missing *missingServersError
+		if errors.As(err, &missing) {
+			writeError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errs := taskConfig.ValidateDetailed(); len(errs) > 0 {
+		writeValidationError(w, fmt.Sprintf("配置验证失败: %v", errs.First()), errs)
+		return
+	}
+
+	taskID := fmt.Sprintf("task_%d", time.Now().UnixNano())
+	log.Printf("继续任务 %s，新任务ID: %s", parentID, taskID)
+	taskConfig.Artifacts.Store = s.newArtifactStore(taskID, &taskConfig.Artifacts)
+
+	s.startTask(taskID, parentID, taskConfig, continuationHistory(chain), req.Instruction)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":        true,
+		"message":        "任务已开始执行",
+		"task_id":        taskID,
+		"parent_task_id": parentID,
+	})
+}
+
+// missingServersError is returned when a continued task used MCP servers that were deleted or disabled
+type missingServersError struct {
+	names []string
+}
+
+func (e *missingServersError) Error() string {
+	return "任务使用的MCP服务器已不存在: " + strings.Join(e.names, ", ")
+}
+
+// continuationConfig restores the configuration of a task for its continuation.
+// MCP servers and the guard prompt are reloaded from the database so changes
+// since the task ran apply.
+//
+// Returns:
+//   - *config.Config: Configuration of the continuation, not yet validated
+//   - error: *missingServersError if a server used by the task no longer exists
+func (s *Server) continuationConfig(record *models.TaskHistoryModel) (*config.Config, error) {
+	cfg, err := decodeConfigSnapshot(record)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := s.mcpServerConfigService.GetAllActiveConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("获取MCP服务器配置失败: %w", err)
+	}
+
+	var missing []string
+	servers := make(map[string]*mcphost.ServerConfig, len(cfg.MCP.MCPServers))
+	for name := range cfg.MCP.MCPServers {
+		current, ok := active[name]
+		if !ok {
+			if taskUsesServer(cfg, name) {
+				missing = append(missing, name)
+			}
+			continue
+		}
+		sc, err := current.ToServerConfig()
+		if err != nil {
+			return nil, fmt.Errorf("转换服务器配置失败 %s: %w", name, err)
+		}
+		servers[name] = &sc
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, &missingServersError{names: missing}
+	}
+	cfg.MCP.MCPServers = servers
+	if err := s.applyGuardPrompt(cfg, nil); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decodeConfigSnapshot decodes the configuration a task was started with, including its secrets
+func decodeConfigSnapshot(record *models.TaskHistoryModel) (*config.Config, error) {
+	snapshot, err := record.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshot) == 0 {
+		return nil, errTaskWithoutConfig
+	}
+	cfg := config.NewDefaultConfig()
+	if err := json.Unmarshal(snapshot, cfg); err != nil {
+		return nil, fmt.Errorf("解析任务配置失败: %w", err)
+	}
+	return cfg, nil
+}
+
+// taskUsesServer reports whether the tools of cfg include a tool of server.
+// A configuration without tool selection uses every server.
+func taskUsesServer(cfg *config.Config, server string) bool {
+	if len(cfg.MCP.Tools) == 0 {
+		return true
+	}
+	for _, t := range cfg.MCP.Tools {
+		if t.Server == server {
+			return true
+		}
+	}
+	return false
+}
+
+// continuationHistory turns the tasks of a conversation into user and assistant messages
+func continuationHistory(chain []models.TaskHistoryModel) []*schema.Message {
+	history := make([]*schema.Message, 0, len(chain)*2)
+	for _, record := range chain {
+		answer := record.Result
+		if answer == "" && record.Error != "" {
+			answer = "（任务执行失败：" + record.Error + "）"
+		}
+		if answer == "" {
+			answer = "（没有结果）"
+		}
+		history = append(history, schema.UserMessage(record.Task), schema.AssistantMessage(answer, nil))
+	}
+	return history
+}
+
+// handleListTaskHistory handles GET /api/tasks/history
+// 分页返回任务记录，最近开始的任务在前。支持 page、page_size（默认第1页，每页20条）、
+// status 和按任务描述过滤的 q。
+func (s *Server) handleListTaskHistory(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, "数据库不可用", http.StatusServiceUnavailable)
+		return
+	}
+	query, _, err := parseListQuery(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, pagination, err := s.taskHistoryService.ListHistory(r.URL.Query().Get("status"), query)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取任务记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       records,
+		"pagination": pagination,
+	})
+}
+
+// handleDeleteTaskHistory handles DELETE /api/tasks/history/{id}
+// 删除已结束任务的记录，运行中的任务返回409
+func (s *Server) handleDeleteTaskHistory(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeError(w, "数据库不可用", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的任务记录ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.taskHistoryService.DeleteHistory(uint(id)); err != nil {
+		writeModelError(w, err, fmt.Sprintf("删除任务记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "任务记录已删除",
+	})
+}
+
+// handleGetTaskHistory handles GET /api/tasks/{taskId}
+// 返回任务记录、它所继续的任务链（从第一个任务开始）以及继续它的任务
+func (s *Server) handleGetTaskHistory(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["taskId"]
+	if s.db == nil {
+		writeError(w, "数据库不可用", http.StatusServiceUnavailable)
+		return
+	}
+
+	chain, err := s.taskHistoryService.GetChain(taskID)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取任务记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	continuations, err := s.taskHistoryService.ListContinuations(taskID)
+	if err != nil {
+		writeError(w, fmt.Sprintf("获取任务记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"task":          chain[len(chain)-1],
+			"chain":         chain,
+			"continuations": continuations,
+		},
+	})
+}
@@ -0,0 +1,284 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/audit"
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingRunner 直接返回结果的任务执行器，记录每次收到的历史消息
+type recordingRunner struct {
+	mu        sync.Mutex
+	histories [][]*schema.Message
+}
+
+func (r *recordingRunner) run(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+	r.mu.Lock()
+	r.histories = append(r.histories, history)
+	r.mu.Unlock()
+	notify.OnResult("回答: " + task)
+	return nil
+}
+
+func postJSON(t *testing.T, server *Server, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(data)))
+	return w
+}
+
+// taskHistoryResponse is the data of GET /api/tasks/{taskId}
+type taskHistoryResponse struct {
+	Task          models.TaskHistoryModel   `json:"task"`
+	Chain         []models.TaskHistoryModel `json:"chain"`
+	Continuations []models.TaskHistoryModel `json:"continuations"`
+}
+
+func getTaskHistory(t *testing.T, server *Server, taskID string) taskHistoryResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/"+taskID, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Data taskHistoryResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Data
+}
+
+// startTestTask posts path and waits until the returned task is finished
+func startTestTask(t *testing.T, server *Server, path string, body any) string {
+	t.Helper()
+	w := postJSON(t, server, path, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Eventually(t, func() bool {
+		record := getTaskHistory(t, server, resp.TaskID).Task
+		return record.IsFinished()
+	}, 2*time.Second, 10*time.Millisecond)
+	return resp.TaskID
+}
+
+func TestContinueTask(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := &recordingRunner{}
+	server.agentRunner = runner.run
+
+	firstID := startTestTask(t, server, "/api/task", TaskRequest{Task: "查询这两个IP"})
+	secondID := startTestTask(t, server, "/api/task/"+firstID+"/continue", ContinueTaskRequest{Instruction: "好的，再深入分析第二个IP"})
+
+	// 继续的任务以原任务和结果作为上下文
+	require.Len(t, runner.histories, 2)
+	assert.Empty(t, runner.histories[0])
+	history := runner.histories[1]
+	require.Len(t, history, 2)
+	assert.Equal(t, schema.User, history[0].Role)
+	assert.Equal(t, "查询这两个IP", history[0].Content)
+	assert.Equal(t, schema.Assistant, history[1].Role)
+	assert.Equal(t, "回答: 查询这两个IP", history[1].Content)
+
+	// 任务链
+	second := getTaskHistory(t, server, secondID)
+	assert.Equal(t, firstID, second.Task.ParentTaskID)
+	assert.Equal(t, models.TaskStatusCompleted, second.Task.Status)
+	assert.Equal(t, "回答: 好的，再深入分析第二个IP", second.Task.Result)
+	require.Len(t, second.Chain, 2)
+	assert.Equal(t, firstID, second.Chain[0].TaskID)
+	assert.Equal(t, secondID, second.Chain[1].TaskID)
+
+	first := getTaskHistory(t, server, firstID)
+	require.Len(t, first.Continuations, 1)
+	assert.Equal(t, secondID, first.Continuations[0].TaskID)
+
+	// 继续第二个任务时包含完整的对话
+	startTestTask(t, server, "/api/task/"+secondID+"/continue", ContinueTaskRequest{Instruction: "总结一下"})
+	assert.Len(t, runner.histories[2], 4)
+}
+
+func TestContinueTaskErrors(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.agentRunner = (&recordingRunner{}).run
+	taskID := startTestTask(t, server, "/api/task", TaskRequest{Task: "查询这两个IP"})
+
+	w := postJSON(t, server, "/api/task/"+taskID+"/continue", ContinueTaskRequest{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(t, server, "/api/task/task_unknown/continue", ContinueTaskRequest{Instruction: "继续"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 仍在执行的任务不能继续
+	require.NoError(t, server.taskHistoryService.CreateTask(&models.TaskHistoryModel{TaskID: "task_running", Task: "x"}))
+	w = postJSON(t, server, "/api/task/task_running/continue", ContinueTaskRequest{Instruction: "继续"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// 任务使用的服务器被删除后返回409
+	servers, err := server.mcpServerConfigService.GetAllActiveConfigs()
+	require.NoError(t, err)
+	var deleted string
+	for name, s := range servers {
+		if name != config.InnerServerName {
+			deleted = name
+			require.NoError(t, server.mcpServerConfigService.DeleteConfig(s.ID))
+			break
+		}
+	}
+	require.NotEmpty(t, deleted, "种子数据中应有MCP服务器")
+	w = postJSON(t, server, "/api/task/"+taskID+"/continue", ContinueTaskRequest{Instruction: "继续"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), deleted)
+}
+
+func TestTaskPanicFailsTask(t *testing.T) {
+	server := setupTaskTestServer(t)
+	before := mcpagent.RecoveredPanics()
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		var notifier mcpagent.Notify
+		notifier.OnMessage("nil pointer")
+		return nil
+	}
+
+	// panic的任务以错误结束，错误中带有与日志关联的错误ID
+	taskID := startTestTask(t, server, "/api/task", TaskRequest{Task: "查询这两个IP"})
+	record := getTaskHistory(t, server, taskID).Task
+	assert.Equal(t, models.TaskStatusError, record.Status)
+	assert.Regexp(t, `任务task_\S+发生内部错误（错误ID: panic_\d+_\d+）`, record.Error)
+	assert.Equal(t, before+1, mcpagent.RecoveredPanics())
+
+	// 服务器继续处理新的任务
+	server.agentRunner = (&recordingRunner{}).run
+	taskID = startTestTask(t, server, "/api/task", TaskRequest{Task: "再试一次"})
+	assert.Equal(t, models.TaskStatusCompleted, getTaskHistory(t, server, taskID).Task.Status)
+}
+
+func TestTaskAuditLog(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.agentRunner = (&recordingRunner{}).run
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := audit.New(audit.Options{Path: path})
+	require.NoError(t, err)
+	server.SetAuditLogger(logger)
+
+	// 审计日志不影响保存到任务历史的结果
+	taskID := startTestTask(t, server, "/api/task", TaskRequest{Task: "查询这两个IP"})
+	assert.Equal(t, "回答: 查询这两个IP", getTaskHistory(t, server, taskID).Task.Result)
+	require.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var types []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event audit.Event
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		assert.Equal(t, taskID, event.TaskID)
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{audit.EventTaskStart, audit.EventResult, audit.EventTaskEnd}, types)
+}
+
+func TestTaskRecordsAnswerModel(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		notify.OnResult("回答: " + task)
+		notify.(mcpagent.ModelNotify).OnAnswerModel("openai/backup")
+		return nil
+	}
+	logger, err := audit.New(audit.Options{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	require.NoError(t, err)
+	defer logger.Close()
+	server.SetAuditLogger(logger)
+
+	// 审计包装后仍然记录产生结果的备用模型
+	taskID := startTestTask(t, server, "/api/task", TaskRequest{Task: "查询这两个IP"})
+	assert.Equal(t, "openai/backup", getTaskHistory(t, server, taskID).Task.Model)
+}
+
+func TestTaskStatusListsMissingTools(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		notify.(mcpagent.MissingToolsNotify).OnMissingTools([]config.MissingTool{{Server: "fofa", Name: "host"}})
+		notify.OnResult("完成")
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	client := &SSENotifier{writer: w, taskID: "task_missing"}
+	server.clients["observer"] = client
+
+	status, _, err := server.runTask(context.Background(), "task_missing", &config.Config{}, nil, "查询")
+	require.NoError(t, err)
+	assert.Equal(t, "completed", status)
+
+	// 任务结束时的状态列出跳过的工具
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return strings.Contains(w.Body.String(), `"missing_tools":[{"server":"fofa","name":"host"}]`)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTaskHistoryListAndDelete(t *testing.T) {
+	server := setupTaskTestServer(t)
+	first := startTestTask(t, server, "/api/task", map[string]any{"task": "第一个任务"})
+	second := startTestTask(t, server, "/api/task", map[string]any{"task": "第二个任务"})
+
+	list := func(query string) ([]models.TaskHistoryModel, map[string]int) {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/history"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body struct {
+			Data       []models.TaskHistoryModel `json:"data"`
+			Pagination map[string]int            `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data, body.Pagination
+	}
+
+	records, pagination := list("?page=1&page_size=1")
+	require.Len(t, records, 1)
+	assert.Equal(t, second, records[0].TaskID)
+	assert.True(t, records[0].IsFinished())
+	assert.Equal(t, map[string]int{"total": 2, "page": 1, "page_size": 1}, pagination)
+
+	// 无效的分页参数
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/history?page=0", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 删除后不再出现在列表中
+	records, _ = list("")
+	require.Len(t, records, 2)
+	assert.Equal(t, first, records[1].TaskID)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", fmt.Sprintf("/api/tasks/history/%d", records[1].ID), nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	records, pagination = list("")
+	require.Len(t, records, 1)
+	assert.Equal(t, second, records[0].TaskID)
+	assert.Equal(t, 1, pagination["total"])
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/tasks/history/9999", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
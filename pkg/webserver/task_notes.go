@@ -0,0 +1,111 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/notes"
+	"github.com/gorilla/mux"
+)
+
+// taskNoteStores holds the note stores of running and recently finished
+// tasks with agent.enable_notes, so GET /api/task/{taskId}/notes can read
+// the notes while the task runs. Finished tasks are read from the task
+// history, or from here for taskEventRetention without a database.
+type taskNoteStores struct {
+	mutex sync.Mutex
+	tasks map[string]*notes.Store
+}
+
+func newTaskNoteStores() *taskNoteStores {
+	return &taskNoteStores{tasks: make(map[string]*notes.Store)}
+}
+
+// start creates the note store of a task when cfg enables agent.enable_notes
+//
+// Returns:
+//   - *notes.Store: Store of the task, nil if the task keeps no notes
+func (n *taskNoteStores) start(taskID string, cfg *config.Config) *notes.Store {
+	if !cfg.Agent.EnableNotes {
+		return nil
+	}
+	store, err := notes.NewStore(cfg.Agent.MaxNotesSize)
+	if err != nil {
+		log.Printf("创建任务 %s 的笔记存储失败，使用默认大小上限: %v", taskID, err)
+		store, _ = notes.NewStore(0)
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.tasks[taskID] = store
+	return store
+}
+
+// get returns the note store of a task, nil if the task has none
+func (n *taskNoteStores) get(taskID string) *notes.Store {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.tasks[taskID]
+}
+
+// finish drops the note store of a task after retention unless it was replaced
+func (n *taskNoteStores) finish(taskID string, store *notes.Store, retention time.Duration) {
+	time.AfterFunc(retention, func() {
+		n.mutex.Lock()
+		defer n.mutex.Unlock()
+		if n.tasks[taskID] == store {
+			delete(n.tasks, taskID)
+		}
+	})
+}
+
+// recordTaskNotes stores the notes of a finished task in its task history
+func (s *Server) recordTaskNotes(taskID string, store *notes.Store) {
+	if s.db == nil || store == nil {
+		return
+	}
+	data, err := json.Marshal(store.List())
+	if err != nil {
+		log.Printf("序列化任务笔记失败 %s: %v", taskID, err)
+		return
+	}
+	if err := s.taskHistoryService.SaveNotes(taskID, string(data)); err != nil {
+		log.Printf("保存任务笔记失败 %s: %v", taskID, err)
+	}
+}
+
+// handleGetTaskNotes handles GET /api/task/{taskId}/notes
+// 返回任务通过save_note保存的笔记，运行中的任务返回当前的笔记
+func (s *Server) handleGetTaskNotes(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["taskId"]
+
+	saved := []notes.Note{}
+	if store := s.notes.get(taskID); store != nil {
+		saved = store.List()
+	} else if s.db != nil {
+		record, err := s.taskHistoryService.GetTask(taskID)
+		if err != nil {
+			writeModelError(w, err, fmt.Sprintf("获取任务记录失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if record.Notes != "" {
+			if err := json.Unmarshal([]byte(record.Notes), &saved); err != nil {
+				writeError(w, fmt.Sprintf("解析任务笔记失败: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+	} else {
+		writeError(w, "任务不存在或笔记已过期", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    saved,
+	})
+}
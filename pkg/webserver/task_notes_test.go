@@ -0,0 +1,88 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/notes"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getTaskNotes 请求GET /api/task/{taskId}/notes
+func getTaskNotes(t *testing.T, server *Server, taskID string) (int, []notes.Note) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/task/"+taskID+"/notes", nil))
+	var body struct {
+		Data []notes.Note `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	}
+	return w.Code, body.Data
+}
+
+func TestTaskNotes(t *testing.T) {
+	server := setupTaskTestServer(t)
+	saved := make(chan struct{})
+	release := make(chan struct{})
+	server.agentRunner = func(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+		store := notes.FromContext(ctx)
+		if !cfg.Agent.EnableNotes || store == nil {
+			return nil
+		}
+		if _, err := store.Save("target", "1.1.1.1"); err != nil {
+			return err
+		}
+		close(saved)
+		<-release
+		return nil
+	}
+
+	enabled := true
+	w := postJSON(t, server, "/api/task", TaskRequest{Task: "扫描目标", EnableNotes: &enabled})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		TaskID string `json:"task_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	// 运行中的任务返回当前的笔记
+	select {
+	case <-saved:
+	case <-time.After(2 * time.Second):
+		t.Fatal("任务没有保存笔记")
+	}
+	code, live := getTaskNotes(t, server, resp.TaskID)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, live, 1)
+	assert.Equal(t, "target", live[0].Key)
+
+	// 结束后从任务记录中读取
+	close(release)
+	require.Eventually(t, func() bool {
+		record := getTaskHistory(t, server, resp.TaskID).Task
+		return record.IsFinished()
+	}, 2*time.Second, 10*time.Millisecond)
+	server.notes = newTaskNoteStores()
+	code, stored := getTaskNotes(t, server, resp.TaskID)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "1.1.1.1", stored[0].Content)
+
+	// 未启用笔记的任务没有笔记，不存在的任务返回404
+	plainID := startTestTask(t, server, "/api/task", TaskRequest{Task: "普通任务"})
+	code, none := getTaskNotes(t, server, plainID)
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, none)
+	code, _ = getTaskNotes(t, server, "task_missing")
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,140 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultTaskRetention is how long GET /api/task/{taskId} returns a finished task
+const DefaultTaskRetention = time.Hour
+
+// TaskState is the state of a running or recently finished task, kept in
+// memory so that a reloaded page can find the tasks it started
+type TaskState struct {
+	TaskStatus
+	Task             string       `json:"task"` // 任务描述
+	StartedAt        time.Time    `json:"started_at"`
+	FinishedAt       *time.Time   `json:"finished_at,omitempty"`
+	LastNotification *NotifyEvent `json:"last_notification,omitempty"` // 最近一次推送的通知事件
+}
+
+// taskRegistry holds the states of running and recently finished tasks. It
+// is updated from every message sent to the clients of a task, finished
+// tasks are removed after retention.
+type taskRegistry struct {
+	mutex     sync.Mutex
+	tasks     map[string]*TaskState
+	retention time.Duration
+}
+
+func newTaskRegistry(retention time.Duration) *taskRegistry {
+	return &taskRegistry{tasks: make(map[string]*TaskState), retention: retention}
+}
+
+// SetTaskRetention sets how long finished tasks stay listed by GET /api/tasks/running
+// and GET /api/task/{taskId}, values not above zero use DefaultTaskRetention.
+// It must be called before Start.
+func (s *Server) SetTaskRetention(retention time.Duration) {
+	if retention <= 0 {
+		retention = DefaultTaskRetention
+	}
+	s.tasks.retention = retention
+}
+
+// started records a task that starts running
+func (r *taskRegistry) started(taskID, task string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tasks[taskID] = &TaskState{
+		TaskStatus: TaskStatus{ID: taskID, Status: "running"},
+		Task:       task,
+		StartedAt:  time.Now(),
+	}
+}
+
+// observe updates the state of a task from a message sent to its clients.
+// Messages of tasks that were not started are ignored.
+func (r *taskRegistry) observe(taskID string, msg SSEMessage) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	state, ok := r.tasks[taskID]
+	if !ok || state.FinishedAt != nil {
+		return
+	}
+	switch data := msg.Data.(type) {
+	case NotifyEvent:
+		state.LastNotification = &data
+	case TaskStatus:
+		state.TaskStatus = data
+		state.ID = taskID
+		if isTerminalTaskStatus(data.Status) {
+			now := time.Now()
+			state.FinishedAt = &now
+			time.AfterFunc(r.retention, func() { r.remove(taskID, state) })
+		}
+	}
+}
+
+// remove drops the state of a task unless it was replaced
+func (r *taskRegistry) remove(taskID string, state *TaskState) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.tasks[taskID] == state {
+		delete(r.tasks, taskID)
+	}
+}
+
+// get returns a copy of the state of a task
+func (r *taskRegistry) get(taskID string) (TaskState, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	state, ok := r.tasks[taskID]
+	if !ok {
+		return TaskState{}, false
+	}
+	return *state, true
+}
+
+// list returns copies of the states of all tasks, the most recently started first
+func (r *taskRegistry) list() []TaskState {
+	r.mutex.Lock()
+	states := make([]TaskState, 0, len(r.tasks))
+	for _, state := range r.tasks {
+		states = append(states, *state)
+	}
+	r.mutex.Unlock()
+	sort.Slice(states, func(i, j int) bool { return states[i].StartedAt.After(states[j].StartedAt) })
+	return states
+}
+
+// handleListRunningTasks handles GET /api/tasks/running
+// 返回内存中运行中和最近结束的任务的状态，不需要数据库。
+func (s *Server) handleListRunningTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    s.tasks.list(),
+	})
+}
+
+// handleGetTask handles GET /api/task/{taskId}
+// 返回运行中或最近结束的任务的状态，结束超过保留时间的任务请通过
+// GET /api/tasks/{taskId} 查询历史记录。
+func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.tasks.get(mux.Vars(r)["taskId"])
+	if !ok {
+		writeError(w, "任务不存在或已过期", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    state,
+	})
+}
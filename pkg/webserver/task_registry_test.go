@@ -0,0 +1,112 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getLiveTask 通过GET /api/task/{taskId}读取任务状态
+func getLiveTask(t *testing.T, server *Server, taskID string) (TaskState, int) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/task/"+taskID, nil))
+	var body struct {
+		Data TaskState `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	}
+	return body.Data, w.Code
+}
+
+func TestTaskRegistryEndpoints(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := newScriptedRunner(func(notify mcpagent.Notify) error {
+		notify.OnResult("example.com 使用了nginx")
+		return nil
+	})
+	server.agentRunner = runner.run
+	ts := httptest.NewServer(server.router)
+	t.Cleanup(ts.Close)
+
+	start := time.Now()
+	taskID := postTask(t, ts, `{"task":"分析example.com"}`)
+
+	// 运行中的任务
+	state, code := getLiveTask(t, server, taskID)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, taskID, state.ID)
+	assert.Equal(t, "running", state.Status)
+	assert.Equal(t, "分析example.com", state.Task)
+	assert.WithinDuration(t, start, state.StartedAt, 5*time.Second)
+	assert.Nil(t, state.FinishedAt)
+	assert.Nil(t, state.LastNotification)
+
+	close(runner.release)
+	require.Eventually(t, func() bool {
+		state, _ := getLiveTask(t, server, taskID)
+		return state.Status == "completed"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 结束的任务保留最终状态和最近一次通知
+	state, _ = getLiveTask(t, server, taskID)
+	require.NotNil(t, state.FinishedAt)
+	require.NotNil(t, state.LastNotification)
+	assert.Equal(t, EventTypeResult, state.LastNotification.Type)
+	assert.Equal(t, "example.com 使用了nginx", state.LastNotification.Content)
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/running", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data []TaskState `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, taskID, list.Data[0].ID)
+	assert.Equal(t, "completed", list.Data[0].Status)
+
+	// 没有数据库时任务历史列表不可用，运行中的任务只由 /api/tasks/running 返回
+	server.db = nil
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/running", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, code = getLiveTask(t, server, "task_unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestTaskRegistryPrunesFinishedTasks(t *testing.T) {
+	registry := newTaskRegistry(20 * time.Millisecond)
+	registry.started("task_a", "任务A")
+	registry.started("task_b", "任务B")
+	registry.observe("task_a", SSEMessage{Type: MessageTypeStatus, Data: TaskStatus{ID: "task_a", Status: "error", ErrorCode: mcpagent.ErrorCodeToolFailed}})
+	// 未开始的任务的消息被忽略
+	registry.observe("task_c", SSEMessage{Type: MessageTypeStatus, Data: TaskStatus{ID: "task_c", Status: "running"}})
+
+	state, ok := registry.get("task_a")
+	require.True(t, ok)
+	assert.Equal(t, "error", state.Status)
+	assert.Equal(t, mcpagent.ErrorCodeToolFailed, state.ErrorCode)
+	assert.Len(t, registry.list(), 2)
+
+	// 结束的任务超过保留时间后被移除，运行中的任务保留
+	assert.Eventually(t, func() bool {
+		_, ok := registry.get("task_a")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+	_, ok = registry.get("task_b")
+	assert.True(t, ok)
+	_, ok = registry.get("task_c")
+	assert.False(t, ok)
+}
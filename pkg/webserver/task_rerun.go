@@ -0,0 +1,169 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+// redactedConfigSnapshot returns the configuration a task was started with,
+// secrets replaced by config.RedactedValue. Object keys are sorted so that
+// the snapshots of two tasks can be compared with a text diff.
+func redactedConfigSnapshot(record *models.TaskHistoryModel) (json.RawMessage, error) {
+	cfg, err := decodeConfigSnapshot(record)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Redact()
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("序列化任务配置失败: %w", err)
+	}
+	// 解码为map后重新编码，所有层级的键都按字母顺序排列
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("序列化任务配置失败: %w", err)
+	}
+	data, err = json.MarshalIndent(normalized, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化任务配置失败: %w", err)
+	}
+	return data, nil
+}
+
+// handleGetTaskConfig handles GET /api/tasks/{taskId}/config
+// 返回任务开始时实际使用的配置（合并默认配置、数据库配置和请求覆盖项之后），密钥已脱敏，键按字母顺序排列
+func (s *Server) handleGetTaskConfig(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["taskId"]
+	if s.db == nil {
+		writeError(w, "数据库不可用", http.StatusServiceUnavailable)
+		return
+	}
+
+	record, err := s.taskHistoryService.GetTask(taskID)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取任务记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	snapshot, err := redactedConfigSnapshot(record)
+	if err != nil {
+		if errors.Is(err, errTaskWithoutConfig) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    snapshot,
+	})
+}
+
+// handleRerunTask handles POST /api/tasks/{taskId}/rerun
+// 使用任务保存的配置快照重新执行同一任务，只有安全约束提示词使用当前值。
+// 快照引用的MCP服务器或工具已不存在时仍然执行，并在响应中返回警告。
+func (s *Server) handleRerunTask(w http.ResponseWriter, r *http.Request) {
+	sourceID := mux.Vars(r)["taskId"]
+	if s.db == nil {
+		writeError(w, "数据库不可用，无法重新执行任务", http.StatusServiceUnavailable)
+		return
+	}
+
+	record, err := s.taskHistoryService.GetTask(sourceID)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取任务记录失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	taskConfig, err := decodeConfigSnapshot(record)
+	if err != nil {
+		if errors.Is(err, errTaskWithoutConfig) {
+			writeError(w, err.Error()+"，无法重新执行", http.StatusConflict)
+			return
+		}
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.applyGuardPrompt(taskConfig, nil); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errs := taskConfig.ValidateDetailed(); len(errs) > 0 {
+		writeValidationError(w, fmt.Sprintf("配置验证失败: %v", errs.First()), errs)
+		return
+	}
+	warnings, err := s.rerunWarnings(taskConfig)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	taskID := fmt.Sprintf("task_%d", time.Now().UnixNano())
+	log.Printf("重新执行任务 %s，新任务ID: %s", sourceID, taskID)
+	for _, warning := range warnings {
+		log.Printf("警告: %s", warning)
+	}
+	taskConfig.Artifacts.Store = s.newArtifactStore(taskID, &taskConfig.Artifacts)
+
+	s.startTask(taskID, "", taskConfig, nil, record.Task)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "任务已开始执行",
+		"task_id":  taskID,
+		"rerun_of": sourceID,
+		"warnings": warnings,
+	})
+}
+
+// rerunWarnings lists the MCP servers and tools of cfg that no longer exist.
+// Tools are only checked against the tool cache of servers that still exist.
+func (s *Server) rerunWarnings(cfg *config.Config) ([]string, error) {
+	active, err := s.mcpServerConfigService.GetAllActiveConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("获取MCP服务器配置失败: %w", err)
+	}
+
+	warnings := []string{}
+	names := make([]string, 0, len(cfg.MCP.MCPServers))
+	for name := range cfg.MCP.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := active[name]; !ok {
+			warnings = append(warnings, fmt.Sprintf("MCP服务器 %s 已不存在，使用任务保存的服务器配置", name))
+		}
+	}
+
+	for _, t := range cfg.MCP.Tools {
+		if t.Server == config.InnerServerName || t.Server == "" {
+			continue
+		}
+		if _, ok := active[t.Server]; !ok {
+			if _, inSnapshot := cfg.MCP.MCPServers[t.Server]; !inSnapshot {
+				warnings = append(warnings, fmt.Sprintf("工具 %s 所在的MCP服务器 %s 已不存在", t.Name, t.Server))
+			}
+			continue
+		}
+		_, err := s.mcpToolService.GetToolByKey(models.GenerateToolKey(t.Server, t.Name))
+		if errors.Is(err, models.ErrMCPToolNotFound) {
+			warnings = append(warnings, fmt.Sprintf("MCP服务器 %s 已不再提供工具 %s", t.Server, t.Name))
+		} else if err != nil {
+			return nil, fmt.Errorf("获取MCP工具失败: %w", err)
+		}
+	}
+	return warnings, nil
+}
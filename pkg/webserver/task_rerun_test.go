@@ -0,0 +1,130 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configRunner records the configurations tasks are run with
+type configRunner struct {
+	mu      sync.Mutex
+	configs []*config.Config
+}
+
+func (r *configRunner) run(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+	r.mu.Lock()
+	r.configs = append(r.configs, cfg)
+	r.mu.Unlock()
+	notify.OnResult("回答: " + task)
+	return nil
+}
+
+// secretTaskRequest returns a task using a secret API key and a server that is not configured
+func secretTaskRequest() TaskRequest {
+	cfg := config.NewDefaultConfig()
+	cfg.LLM.APIKey = "sk-secret"
+	cfg.MCP.MCPServers["gone"] = &mcphost.ServerConfig{Command: "gone", Env: map[string]string{"TOKEN": "env-secret"}}
+	cfg.MCP.Tools = []config.MCPToolConfig{{Server: "gone", Name: "scan"}}
+	return TaskRequest{Task: "扫描目标", Config: cfg}
+}
+
+func TestHandleGetTaskConfig(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.agentRunner = (&configRunner{}).run
+	taskID := startTestTask(t, server, "/api/task", secretTaskRequest())
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/"+taskID+"/config", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	body := w.Body.String()
+	assert.NotContains(t, body, "sk-secret")
+	assert.NotContains(t, body, "env-secret")
+
+	var resp struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	var snapshot config.Config
+	require.NoError(t, json.Unmarshal(resp.Data, &snapshot))
+	assert.Equal(t, config.RedactedValue, snapshot.LLM.APIKey)
+	assert.Equal(t, config.RedactedValue, snapshot.MCP.MCPServers["gone"].Env["TOKEN"])
+	assert.Equal(t, []config.MCPToolConfig{{Server: "gone", Name: "scan"}}, snapshot.MCP.Tools)
+
+	// 键按字母顺序排列，两次获取的结果完全一致
+	var top map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(resp.Data, &top))
+	keys := make([]string, 0, len(top))
+	for key := range top {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	positions := make([]int, len(keys))
+	for i, key := range keys {
+		positions[i] = strings.Index(string(resp.Data), `"`+key+`":`)
+	}
+	assert.True(t, sort.IntsAreSorted(positions), "顶层键未排序: %v", keys)
+
+	again := httptest.NewRecorder()
+	server.router.ServeHTTP(again, httptest.NewRequest("GET", "/api/tasks/"+taskID+"/config", nil))
+	assert.Equal(t, body, again.Body.String())
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tasks/task_unknown/config", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleRerunTask(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := &configRunner{}
+	server.agentRunner = runner.run
+	sourceID := startTestTask(t, server, "/api/task", secretTaskRequest())
+
+	w := postJSON(t, server, "/api/tasks/"+sourceID+"/rerun", struct{}{})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		TaskID   string   `json:"task_id"`
+		RerunOf  string   `json:"rerun_of"`
+		Warnings []string `json:"warnings"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, sourceID, resp.RerunOf)
+	assert.NotEqual(t, sourceID, resp.TaskID)
+	require.Len(t, resp.Warnings, 1)
+	assert.Contains(t, resp.Warnings[0], "gone")
+
+	require.Eventually(t, func() bool {
+		record := getTaskHistory(t, server, resp.TaskID).Task
+		return record.IsFinished()
+	}, 2*time.Second, 10*time.Millisecond)
+	rerun := getTaskHistory(t, server, resp.TaskID)
+	assert.Equal(t, "扫描目标", rerun.Task.Task)
+	assert.Equal(t, "回答: 扫描目标", rerun.Task.Result)
+
+	// 重新执行使用未脱敏的原始配置
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	require.Len(t, runner.configs, 2)
+	rerunConfig := runner.configs[1]
+	assert.Equal(t, "sk-secret", rerunConfig.LLM.APIKey)
+	assert.Equal(t, "env-secret", rerunConfig.MCP.MCPServers["gone"].Env["TOKEN"])
+	assert.Equal(t, runner.configs[0].MCP.Tools, rerunConfig.MCP.Tools)
+	assert.Equal(t, runner.configs[0].SystemPrompt, rerunConfig.SystemPrompt)
+
+	w = postJSON(t, server, "/api/tasks/task_unknown/rerun", struct{}{})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,177 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+// TaskTemplateRequest is the body of creating or updating a task template
+type TaskTemplateRequest struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Template    string                     `json:"template"`                // 任务内容，参数以{name}引用
+	Parameters  []models.TaskTemplateParam `json:"parameters"`              // 声明的参数
+	Tools       []config.MCPToolConfig     `json:"tools"`                   // 默认使用的工具列表
+	LLMConfigID *uint                      `json:"llm_config_id,omitempty"` // 默认使用的LLM配置
+}
+
+// toModel validates the tools of the request and converts it to a task template model
+func (req *TaskTemplateRequest) toModel() (*models.TaskTemplateModel, config.FieldErrors, error) {
+	if fieldErrs := config.ToolFieldErrors("tools", req.Tools); len(fieldErrs) > 0 {
+		return nil, fieldErrs, nil
+	}
+
+	template := &models.TaskTemplateModel{
+		Name:        req.Name,
+		Description: req.Description,
+		Template:    req.Template,
+		LLMConfigID: req.LLMConfigID,
+	}
+	if err := template.SetParameters(req.Parameters); err != nil {
+		return nil, nil, err
+	}
+	tools := make([]models.MCPToolConfig, 0, len(req.Tools))
+	for _, tool := range req.Tools {
+		tools = append(tools, models.MCPToolConfig{Server: tool.Server, Name: tool.Name})
+	}
+	if err := template.SetTools(tools); err != nil {
+		return nil, nil, err
+	}
+	return template, nil, nil
+}
+
+// handleListTaskTemplates 列出所有任务模板
+func (s *Server) handleListTaskTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.taskTemplateService.ListTemplates()
+	if err != nil {
+		writeError(w, "获取任务模板列表失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// handleCreateTaskTemplate 创建新的任务模板
+func (s *Server) handleCreateTaskTemplate(w http.ResponseWriter, r *http.Request) {
+	var req TaskTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	template, fieldErrs, err := req.toModel()
+	if len(fieldErrs) > 0 {
+		writeValidationError(w, "工具列表无效", fieldErrs)
+		return
+	}
+	if err != nil {
+		writeError(w, "解析任务模板失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.taskTemplateService.CreateTemplate(template); err != nil {
+		writeModelError(w, err, "创建任务模板失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+// handleGetTaskTemplate 获取特定的任务模板
+func (s *Server) handleGetTaskTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的ID", http.StatusBadRequest)
+		return
+	}
+
+	template, err := s.taskTemplateService.GetTemplate(uint(id))
+	if err != nil {
+		writeModelError(w, err, "获取任务模板失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// handleUpdateTaskTemplate 更新任务模板
+func (s *Server) handleUpdateTaskTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的ID", http.StatusBadRequest)
+		return
+	}
+
+	var req TaskTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updates, fieldErrs, err := req.toModel()
+	if len(fieldErrs) > 0 {
+		writeValidationError(w, "工具列表无效", fieldErrs)
+		return
+	}
+	if err != nil {
+		writeError(w, "解析任务模板失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.taskTemplateService.UpdateTemplate(uint(id), updates); err != nil {
+		writeModelError(w, err, "更新任务模板失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	template, err := s.taskTemplateService.GetTemplate(uint(id))
+	if err != nil {
+		writeError(w, "获取更新后的任务模板失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// handleDeleteTaskTemplate 删除任务模板
+func (s *Server) handleDeleteTaskTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		writeError(w, "无效的ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.taskTemplateService.DeleteTemplate(uint(id)); err != nil {
+		writeModelError(w, err, "删除任务模板失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// templateRenderFieldErrors reports every problem of a render error as a "params.<name>" field
+func templateRenderFieldErrors(renderErr *models.TemplateRenderError) config.FieldErrors {
+	var fields config.FieldErrors
+	for _, name := range renderErr.Missing {
+		fields = append(fields, config.FieldError{Field: "params." + name, Message: "缺少必填参数"})
+	}
+	for _, name := range renderErr.Extra {
+		fields = append(fields, config.FieldError{Field: "params." + name, Message: "模板未声明该参数"})
+	}
+	for _, name := range renderErr.InvalidNames() {
+		fields = append(fields, config.FieldError{Field: "params." + name, Message: renderErr.Invalid[name]})
+	}
+	return fields
+}
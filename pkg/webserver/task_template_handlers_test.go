@@ -0,0 +1,142 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configRecordingRunner records the configuration of every task it runs
+type configRecordingRunner struct {
+	mu      sync.Mutex
+	configs []*config.Config
+}
+
+func (r *configRecordingRunner) run(ctx context.Context, cfg *config.Config, history []*schema.Message, task string, notify mcpagent.Notify) error {
+	r.mu.Lock()
+	r.configs = append(r.configs, cfg)
+	r.mu.Unlock()
+	notify.OnResult("回答: " + task)
+	return nil
+}
+
+// createTestTaskTemplate creates the template used by the handler tests
+func createTestTaskTemplate(t *testing.T, server *Server) models.TaskTemplateModel {
+	w := postJSON(t, server, "/api/task-templates", TaskTemplateRequest{
+		Name:     "域名侦察",
+		Template: "收集{domain}的子域名，深度{depth}",
+		Parameters: []models.TaskTemplateParam{
+			{Name: "domain", Required: true},
+			{Name: "depth", Type: models.TemplateParamTypeInteger, Default: 2},
+		},
+		Tools: []config.MCPToolConfig{{Server: "fetch", Name: "fetch"}},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var template models.TaskTemplateModel
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &template))
+	return template
+}
+
+func TestTaskTemplateHandlers(t *testing.T) {
+	server := setupTaskTestServer(t)
+	template := createTestTaskTemplate(t, server)
+	assert.JSONEq(t, `[{"server":"fetch","name":"fetch"}]`, string(template.Tools))
+
+	// 名称重复
+	w := postJSON(t, server, "/api/task-templates", TaskTemplateRequest{Name: "域名侦察", Template: "x"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// 参数声明无效
+	w = postJSON(t, server, "/api/task-templates", TaskTemplateRequest{
+		Name:       "无效模板",
+		Template:   "{a}",
+		Parameters: []models.TaskTemplateParam{{Name: "a", Type: "date"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"parameters"`)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/task-templates", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var templates []models.TaskTemplateModel
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &templates))
+	require.Len(t, templates, 1)
+
+	body := `{"name":"域名侦察","template":"侦察{domain}","parameters":[{"name":"domain","required":true}]}`
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/task-templates/1", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "侦察{domain}")
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/task-templates/1", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/task-templates/1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "task_template_not_found")
+}
+
+func TestExecuteTaskWithTemplate(t *testing.T) {
+	server := setupTaskTestServer(t)
+	runner := &configRecordingRunner{}
+	server.agentRunner = runner.run
+	template := createTestTaskTemplate(t, server)
+
+	taskID := startTestTask(t, server, "/api/task", map[string]any{
+		"template_id": template.ID,
+		"params":      map[string]any{"domain": "example.com"},
+	})
+	record := getTaskHistory(t, server, taskID).Task
+	assert.Equal(t, "收集example.com的子域名，深度2", record.Task)
+
+	// 模板中的工具列表作为任务的工具
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	require.Len(t, runner.configs, 1)
+	assert.Equal(t, []config.MCPToolConfig{{Server: "fetch", Name: "fetch"}}, runner.configs[0].MCP.Tools)
+}
+
+func TestExecuteTaskWithTemplateErrors(t *testing.T) {
+	server := setupTaskTestServer(t)
+	server.agentRunner = (&recordingRunner{}).run
+	template := createTestTaskTemplate(t, server)
+
+	w := postJSON(t, server, "/api/task", map[string]any{
+		"template_id": template.ID,
+		"params":      map[string]any{"depth": "deep", "target": "example.com"},
+	})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, errCodeValidationFailed, resp.Error.Code)
+	assert.Contains(t, resp.Error.Message, "缺少参数 domain")
+	assert.Contains(t, resp.Error.Message, "多余参数 target")
+	var fields []string
+	for _, field := range resp.Error.Fields {
+		fields = append(fields, field.Field)
+	}
+	assert.Equal(t, []string{"params.domain", "params.target", "params.depth"}, fields)
+
+	w = postJSON(t, server, "/api/task", map[string]any{"template_id": 999})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = postJSON(t, server, "/api/task", map[string]any{"template_id": template.ID, "task": "x"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(t, server, "/api/task", map[string]any{"task": "x", "params": map[string]any{"a": 1}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,341 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/mcppool"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
+)
+
+const (
+	// invokeFileMaxSize is the maximum size of a file uploaded with a tool invocation
+	invokeFileMaxSize = 4 * 1024 * 1024
+
+	// invokeRequestMaxSize is the maximum size of a whole tool invocation request
+	invokeRequestMaxSize = 32 * 1024 * 1024
+
+	// invokeRequestPart is the multipart field holding the JSON request
+	invokeRequestPart = "request"
+
+	// filePlaceholderPrefix marks an argument replaced by the content of an uploaded file
+	filePlaceholderPrefix = "@file:"
+
+	// toolInvokeTimeout limits a single tool invocation
+	toolInvokeTimeout = 2 * time.Minute
+)
+
+// filePartNamePattern restricts the names referenced by file placeholders
+var filePartNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// errInvokeFileTooLarge is returned for an uploaded file larger than invokeFileMaxSize
+var errInvokeFileTooLarge = fmt.Errorf("上传文件大小超过限制（%d字节）", invokeFileMaxSize)
+
+// ToolInvokeRequest is the body of POST /api/mcp/tools/invoke.
+// With multipart/form-data it is sent in the "request" part, and string
+// arguments of the form "@file:<part>" are replaced by the file uploaded in that part.
+type ToolInvokeRequest struct {
+	Server    string                 `json:"server"`    // MCP服务器名称
+	Tool      string                 `json:"tool"`      // 工具名称，不带服务器前缀
+	Arguments map[string]interface{} `json:"arguments"` // 工具参数
+}
+
+// ToolInvokeResult is the data of a successful tool invocation
+type ToolInvokeResult struct {
+	Server     string `json:"server"`
+	Tool       string `json:"tool"`
+	Result     string `json:"result"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// uploadedFile is a file part of a multipart tool invocation
+type uploadedFile struct {
+	contentType string
+	data        []byte
+}
+
+// serverToolInvoker invokes a tool of one MCP server
+type serverToolInvoker func(ctx context.Context, server *models.MCPServerConfigModel, tool string, arguments map[string]interface{}) (string, error)
+
+// invokeMCPServerTool connects to a server through the connection pool and invokes one of its tools
+func invokeMCPServerTool(ctx context.Context, server *models.MCPServerConfigModel, tool string, arguments map[string]interface{}) (string, error) {
+	serverConfig, err := services.ResolveServerConfig(server)
+	if err != nil {
+		return "", fmt.Errorf("转换服务器配置失败: %w", err)
+	}
+
+	settings := &mcphost.MCPSettings{
+		MCPServers: map[string]*mcphost.ServerConfig{
+			server.Name: &serverConfig,
+		},
+	}
+
+	pool := mcppool.Default()
+	hub, err := pool.GetHub(ctx, settings)
+	if err != nil {
+		return "", fmt.Errorf("连接MCP服务器失败: %w", err)
+	}
+	defer pool.ReleaseHub(settings)
+
+	// mcphost以 服务器名称_工具名称 注册工具
+	return hub.InvokeTool(ctx, server.Name+"_"+tool, arguments)
+}
+
+// handleInvokeMCPTool handles POST /api/mcp/tools/invoke
+// 直接调用某个MCP服务器的工具。请求可以是JSON，也可以是multipart/form-data：
+// "request" 部分为JSON请求，其他部分为上传的文件，参数中的 "@file:<部分名称>"
+// 会被替换为对应文件的内容。二进制文件以base64编码，并附带content_type。
+func (s *Server) handleInvokeMCPTool(w http.ResponseWriter, r *http.Request) {
+	req, files, err := readToolInvokeRequest(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeError(w, fmt.Sprintf("请求大小超过限制（%d字节）", invokeRequestMaxSize), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, errInvokeFileTooLarge):
+			writeError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			writeError(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	var fields config.FieldErrors
+	if strings.TrimSpace(req.Server) == "" {
+		fields = append(fields, config.FieldError{Field: "server", Message: "服务器名称不能为空"})
+	}
+	if strings.TrimSpace(req.Tool) == "" {
+		fields = append(fields, config.FieldError{Field: "tool", Message: "工具名称不能为空"})
+	}
+	arguments, argErrs := substituteFilePlaceholders(req.Arguments, files)
+	fields = append(fields, argErrs...)
+	if len(fields) > 0 {
+		writeValidationError(w, "工具调用请求无效", fields)
+		return
+	}
+
+	server, err := s.mcpServerConfigService.GetConfigByName(req.Server)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取MCP服务器配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if server.Disabled {
+		writeError(w, fmt.Sprintf("MCP服务器 %s 已禁用", server.Name), http.StatusBadRequest)
+		return
+	}
+
+	invoker := s.toolInvoker
+	if invoker == nil {
+		invoker = invokeMCPServerTool
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), toolInvokeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := invoker(ctx, server, req.Tool, arguments)
+	if err != nil {
+		log.Printf("调用工具 %s/%s 失败: %v", server.Name, req.Tool, err)
+		writeError(w, fmt.Sprintf("调用工具失败: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": ToolInvokeResult{
+			Server:     server.Name,
+			Tool:       req.Tool,
+			Result:     result,
+			DurationMs: time.Since(start).Milliseconds(),
+		},
+	})
+}
+
+// readToolInvokeRequest decodes a JSON or multipart tool invocation request.
+//
+// Parameters:
+//   - w: Response writer, used to limit the request size
+//   - r: Request to read
+//
+// Returns:
+//   - ToolInvokeRequest: Decoded request
+//   - map[string]uploadedFile: Uploaded files by part name, nil for JSON requests
+//   - error: Error if the request cannot be decoded
+func readToolInvokeRequest(w http.ResponseWriter, r *http.Request) (ToolInvokeRequest, map[string]uploadedFile, error) {
+	var req ToolInvokeRequest
+	r.Body = http.MaxBytesReader(w, r.Body, invokeRequestMaxSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, nil, fmt.Errorf("解析请求失败: %w", err)
+		}
+		return req, nil, nil
+	}
+
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		return req, nil, fmt.Errorf("解析上传数据失败: %w", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	values := r.MultipartForm.Value[invokeRequestPart]
+	if len(values) == 0 {
+		// 请求也可以作为文件部分上传
+		if fhs := r.MultipartForm.File[invokeRequestPart]; len(fhs) == 1 {
+			data, err := readFilePart(fhs[0])
+			if err != nil {
+				return req, nil, err
+			}
+			values = []string{string(data)}
+		}
+	}
+	if len(values) != 1 {
+		return req, nil, fmt.Errorf("multipart请求必须包含一个 %s 部分", invokeRequestPart)
+	}
+	if err := json.Unmarshal([]byte(values[0]), &req); err != nil {
+		return req, nil, fmt.Errorf("解析 %s 部分失败: %w", invokeRequestPart, err)
+	}
+
+	files := make(map[string]uploadedFile)
+	for name, fhs := range r.MultipartForm.File {
+		if name == invokeRequestPart {
+			continue
+		}
+		if len(fhs) != 1 {
+			return req, nil, fmt.Errorf("文件部分 %s 只能包含一个文件", name)
+		}
+		data, err := readFilePart(fhs[0])
+		if err != nil {
+			return req, nil, err
+		}
+		contentType := fhs[0].Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			contentType = http.DetectContentType(data)
+		}
+		files[name] = uploadedFile{contentType: contentType, data: data}
+	}
+	return req, files, nil
+}
+
+// readFilePart reads an uploaded part of at most invokeFileMaxSize bytes
+func readFilePart(fh *multipart.FileHeader) ([]byte, error) {
+	if fh.Size > invokeFileMaxSize {
+		return nil, fmt.Errorf("%w: %s", errInvokeFileTooLarge, fh.Filename)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, invokeFileMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	if len(data) > invokeFileMaxSize {
+		return nil, fmt.Errorf("%w: %s", errInvokeFileTooLarge, fh.Filename)
+	}
+	return data, nil
+}
+
+// substituteFilePlaceholders replaces the "@file:<part>" strings of arguments
+// with the content of the uploaded files. Text files become strings; binary
+// files become {"content": base64, "encoding": "base64", "content_type": ...}.
+// Every uploaded file must be referenced at least once.
+//
+// Parameters:
+//   - arguments: Tool arguments, not modified
+//   - files: Uploaded files by part name
+//
+// Returns:
+//   - map[string]interface{}: Arguments with the placeholders replaced
+//   - config.FieldErrors: Malformed placeholders, missing and unreferenced files
+func substituteFilePlaceholders(arguments map[string]interface{}, files map[string]uploadedFile) (map[string]interface{}, config.FieldErrors) {
+	if arguments == nil {
+		arguments = map[string]interface{}{}
+	}
+
+	var fields config.FieldErrors
+	used := make(map[string]bool)
+
+	var substitute func(path string, value interface{}) interface{}
+	substitute = func(path string, value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			if !strings.HasPrefix(v, filePlaceholderPrefix) {
+				return v
+			}
+			name := strings.TrimPrefix(v, filePlaceholderPrefix)
+			if !filePartNamePattern.MatchString(name) {
+				fields = append(fields, config.FieldError{Field: path, Message: fmt.Sprintf("无效的文件引用: %q", v)})
+				return v
+			}
+			file, ok := files[name]
+			if !ok {
+				fields = append(fields, config.FieldError{Field: path, Message: fmt.Sprintf("引用的文件不存在: %s", name)})
+				return v
+			}
+			used[name] = true
+			return file.argumentValue()
+		case map[string]interface{}:
+			result := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				result[key] = substitute(path+"."+key, item)
+			}
+			return result
+		case []interface{}:
+			result := make([]interface{}, len(v))
+			for i, item := range v {
+				result[i] = substitute(path+"["+strconv.Itoa(i)+"]", item)
+			}
+			return result
+		default:
+			return v
+		}
+	}
+
+	result := substitute("arguments", arguments).(map[string]interface{})
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+
+	var unused []string
+	for name := range files {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		fields = append(fields, config.FieldError{Field: name, Message: "上传的文件未被任何参数引用"})
+	}
+	return result, fields
+}
+
+// argumentValue returns the value substituted for a placeholder of the file
+func (f uploadedFile) argumentValue() interface{} {
+	if utf8.Valid(f.data) && !bytes.ContainsRune(f.data, 0) {
+		return string(f.data)
+	}
+	return map[string]interface{}{
+		"content":      base64.StdEncoding.EncodeToString(f.data),
+		"encoding":     "base64",
+		"content_type": f.contentType,
+	}
+}
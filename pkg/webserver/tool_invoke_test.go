@@ -0,0 +1,144 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invokePart is a file part of a multipart tool invocation
+type invokePart struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// newInvokeRequest builds a multipart POST /api/mcp/tools/invoke request
+func newInvokeRequest(t *testing.T, request string, parts ...invokePart) *http.Request {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	require.NoError(t, writer.WriteField(invokeRequestPart, request))
+	for _, p := range parts {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="`+p.name+`"; filename="`+p.name+`.bin"`)
+		if p.contentType != "" {
+			header.Set("Content-Type", p.contentType)
+		}
+		part, err := writer.CreatePart(header)
+		require.NoError(t, err)
+		_, err = part.Write(p.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/api/mcp/tools/invoke", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// setupToolInvokeServer creates a server with the "scanner" MCP server and
+// returns the arguments its tools are invoked with
+func setupToolInvokeServer(t *testing.T) (*Server, *map[string]interface{}) {
+	server := setupTaskTestServer(t)
+	require.NoError(t, server.mcpServerConfigService.CreateConfig(&models.MCPServerConfigModel{
+		Name: "scanner", TransportType: "sse", URL: "http://127.0.0.1:1/sse",
+	}))
+
+	var received map[string]interface{}
+	server.toolInvoker = func(ctx context.Context, s *models.MCPServerConfigModel, tool string, arguments map[string]interface{}) (string, error) {
+		received = arguments
+		return s.Name + "/" + tool + " ok", nil
+	}
+	return server, &received
+}
+
+func TestHandleInvokeMCPToolSubstitutesFiles(t *testing.T) {
+	server, received := setupToolInvokeServer(t)
+
+	request := `{"server":"scanner","tool":"analyze","arguments":{"summary":"@file:pcap","options":{"raw":["@file:response"]},"limit":10}}`
+	w := httptest.NewRecorder()
+	server.handleInvokeMCPTool(w, newInvokeRequest(t, request,
+		invokePart{name: "pcap", contentType: "text/plain", data: []byte("10.0.0.1 -> 10.0.0.2 tcp/443")},
+		invokePart{name: "response", data: []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}},
+	))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Success bool             `json:"success"`
+		Data    ToolInvokeResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, "scanner/analyze ok", resp.Data.Result)
+
+	assert.Equal(t, "10.0.0.1 -> 10.0.0.2 tcp/443", (*received)["summary"])
+	assert.Equal(t, float64(10), (*received)["limit"])
+	raw := (*received)["options"].(map[string]interface{})["raw"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"content":      "iVBORwD/",
+		"encoding":     "base64",
+		"content_type": "application/octet-stream",
+	}, raw[0])
+}
+
+func TestHandleInvokeMCPToolJSON(t *testing.T) {
+	server, received := setupToolInvokeServer(t)
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(`{"server":"scanner","tool":"ping","arguments":{"host":"@example"}}`)
+	server.handleInvokeMCPTool(w, httptest.NewRequest("POST", "/api/mcp/tools/invoke", body))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, map[string]interface{}{"host": "@example"}, *received)
+}
+
+func TestHandleInvokeMCPToolRejectsLargeFile(t *testing.T) {
+	server, received := setupToolInvokeServer(t)
+
+	w := httptest.NewRecorder()
+	server.handleInvokeMCPTool(w, newInvokeRequest(t, `{"server":"scanner","tool":"analyze","arguments":{"summary":"@file:pcap"}}`,
+		invokePart{name: "pcap", data: bytes.Repeat([]byte("a"), invokeFileMaxSize+1)},
+	))
+	apiErr := decodeErrorResponse(t, w, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, errCodeTooLarge, apiErr.Code)
+	assert.Nil(t, *received)
+}
+
+func TestHandleInvokeMCPToolRejectsBadPlaceholders(t *testing.T) {
+	server, received := setupToolInvokeServer(t)
+
+	request := `{"server":"scanner","tool":"analyze","arguments":{"a":"@file:","b":["@file:../etc/passwd"],"c":"@file:missing","d":"@file:pcap"}}`
+	w := httptest.NewRecorder()
+	server.handleInvokeMCPTool(w, newInvokeRequest(t, request,
+		invokePart{name: "pcap", data: []byte("x")},
+		invokePart{name: "unused", data: []byte("y")},
+	))
+	apiErr := decodeErrorResponse(t, w, http.StatusBadRequest)
+	assert.Equal(t, errCodeValidationFailed, apiErr.Code)
+
+	fields := make([]string, len(apiErr.Fields))
+	for i, f := range apiErr.Fields {
+		fields[i] = f.Field
+	}
+	assert.Equal(t, []string{"arguments.a", "arguments.b[0]", "arguments.c", "unused"}, fields)
+	assert.Contains(t, apiErr.Fields[2].Message, "missing")
+	assert.Nil(t, *received)
+}
+
+func TestHandleInvokeMCPToolUnknownServer(t *testing.T) {
+	server, _ := setupToolInvokeServer(t)
+
+	w := httptest.NewRecorder()
+	server.handleInvokeMCPTool(w, newInvokeRequest(t, `{"server":"nope","tool":"analyze"}`))
+	apiErr := decodeErrorResponse(t, w, http.StatusNotFound)
+	assert.Equal(t, "mcp_server_config_not_found", apiErr.Code)
+}
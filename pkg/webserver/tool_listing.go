@@ -0,0 +1,460 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/LubyRuffy/mcpagent/pkg/mcphost"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// liveToolsBudget is the time a single server gets to list its tools before the cache is used
+	liveToolsBudget = 10 * time.Second
+
+	// staleToolsAfter is the age after which cached tools are marked as stale
+	staleToolsAfter = 24 * time.Hour
+
+	// toolSourceLive marks tools listed from a running server
+	toolSourceLive = "live"
+
+	// toolSourceCache marks tools served from the database cache
+	toolSourceCache = "cache"
+)
+
+// ServerToolsError describes a server whose tools could not be listed live
+type ServerToolsError struct {
+	Server string `json:"server"`
+	Error  string `json:"error"`
+}
+
+// serverToolLister lists the tools of one MCP server
+type serverToolLister func(ctx context.Context, server *models.MCPServerConfigModel) ([]*schema.ToolInfo, error)
+
+// listMCPServerTools connects to a server through the connection pool and lists its tools
+func listMCPServerTools(ctx context.Context, server *models.MCPServerConfigModel) ([]*schema.ToolInfo, error) {
+	serverConfig, err := services.ResolveServerConfig(server)
+	if err != nil {
+		return nil, fmt.Errorf("转换服务器配置失败: %w", err)
+	}
+
+	return services.ListServerTools(ctx, server.Name, &serverConfig)
+}
+
+// liveToolsResult is the outcome of listing the tools of one server
+type liveToolsResult struct {
+	tools []*schema.ToolInfo
+	err   error
+}
+
+// listLiveTools lists the tools of all servers concurrently, each within liveToolsBudget
+func (s *Server) listLiveTools(ctx context.Context, servers map[string]models.MCPServerConfigModel) map[string]liveToolsResult {
+	lister := s.toolLister
+	if lister == nil {
+		lister = listMCPServerTools
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]liveToolsResult, len(servers))
+	for name, server := range servers {
+		wg.Add(1)
+		go func(name string, server models.MCPServerConfigModel) {
+			defer wg.Done()
+			serverCtx, cancel := context.WithTimeout(ctx, liveToolsBudget)
+			defer cancel()
+
+			tools, err := lister(serverCtx, &server)
+			mu.Lock()
+			results[name] = liveToolsResult{tools: tools, err: err}
+			mu.Unlock()
+		}(name, server)
+	}
+	wg.Wait()
+	return results
+}
+
+// cachedToolInfo converts a cached tool to the API response format
+func cachedToolInfo(info models.MCPToolInfo, now time.Time, depth int) MCPToolInfo {
+	return withParameters(MCPToolInfo{
+		Name:         info.Name,
+		Description:  info.Description,
+		Server:       info.Server,
+		UsageCount:   info.UsageCount,
+		LastUsedAt:   info.LastUsedAt,
+		Source:       toolSourceCache,
+		Stale:        info.LastSyncAt == nil || now.Sub(*info.LastSyncAt) > staleToolsAfter,
+		ResultFilter: info.ResultFilter,
+		OutputFormat: info.OutputFormat,
+
+		LocalizedDescriptions: info.LocalizedDescriptions,
+	}, info.InputSchema, depth)
+}
+
+// detailToolInfo converts a tool listed from a server to the API response format
+func detailToolInfo(detail mcphost.ToolDetail, depth int) MCPToolInfo {
+	return withParameters(MCPToolInfo{
+		Name:        detail.Name,
+		Description: detail.Description,
+		Server:      detail.Server,
+	}, detail.InputSchema, depth)
+}
+
+// withParameters sets the input schema of a tool and the form fields flattened from it
+func withParameters(info MCPToolInfo, inputSchema map[string]any, depth int) MCPToolInfo {
+	info.InputSchema = inputSchema
+	info.Parameters = services.FlattenToolParameters(inputSchema, depth)
+	return info
+}
+
+// parameterDepth returns the ?param_depth= of a tool listing request, the
+// number of nested object levels expanded into parameters
+func parameterDepth(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("param_depth")
+	if v == "" {
+		return services.DefaultParameterDepth, nil
+	}
+	depth, err := strconv.Atoi(v)
+	if err != nil || depth < 1 || depth > services.MaxParameterDepth {
+		return 0, fmt.Errorf("无效的param_depth参数，应为1到%d之间的整数", services.MaxParameterDepth)
+	}
+	return depth, nil
+}
+
+// toolNamePresenter returns the MCP configuration that decides the tool names presented to the LLM
+func (s *Server) toolNamePresenter(servers map[string]models.MCPServerConfigModel) *config.MCPConfig {
+	presenter := &config.MCPConfig{NamePrefixes: make(map[string]string)}
+	for name, server := range servers {
+		if server.NamePrefix != "" {
+			presenter.NamePrefixes[name] = server.NamePrefix
+		}
+	}
+
+	appConfig, err := s.appConfigService.GetDefaultConfig()
+	if err != nil {
+		if !errors.Is(err, models.ErrAppConfigNotFound) {
+			log.Printf("获取默认配置失败: %v", err)
+		}
+		return presenter
+	}
+	if mcpConfig, err := appConfig.GetMCPConfig(); err == nil {
+		presenter.PrefixToolNames = mcpConfig.PrefixToolNames
+	}
+	return presenter
+}
+
+// handleGetMCPToolsFromDB handles GET /api/mcp/tools/configured
+// Tools of every active server are listed live; servers that cannot be reached
+// fall back to the tools cached in the database, and are reported in errors.
+// Nested parameters are expanded up to ?param_depth= levels.
+func (s *Server) handleGetMCPToolsFromDB(w http.ResponseWriter, r *http.Request) {
+	depth, err := parameterDepth(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 获取数据库中的所有工具，包括内置工具
+	cachedTools, err := s.mcpToolService.GetToolsInfo()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MCPToolsResponse{
+			Success: false,
+			Message: "获取工具列表失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// 从数据库获取所有活跃的MCP服务器配置
+	servers, err := s.mcpServerConfigService.GetAllActiveConfigs()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MCPToolsResponse{
+			Success: false,
+			Message: "获取MCP服务器配置失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+	// 内置工具不需要连接服务器
+	delete(servers, config.InnerServerName)
+
+	usageMap, err := s.toolUsageService.GetUsageMap()
+	if err != nil {
+		log.Printf("获取工具调用统计失败: %v", err)
+	}
+
+	now := time.Now()
+	cachedByServer := make(map[string][]models.MCPToolInfo)
+	resultFilters := make(map[string]string)
+	outputFormats := make(map[string]string)
+	descriptions := make(map[string]map[string]string)
+	for _, info := range cachedTools {
+		cachedByServer[info.Server] = append(cachedByServer[info.Server], info)
+		if info.ResultFilter != "" {
+			resultFilters[info.ToolKey] = info.ResultFilter
+		}
+		if info.OutputFormat != "" {
+			outputFormats[info.ToolKey] = info.OutputFormat
+		}
+		if len(info.LocalizedDescriptions) > 0 {
+			descriptions[info.ToolKey] = info.LocalizedDescriptions
+		}
+	}
+
+	tools := make([]MCPToolInfo, 0, len(cachedTools))
+	for _, info := range cachedByServer[config.InnerServerName] {
+		toolInfo := cachedToolInfo(info, now, depth)
+		// 内置工具随程序发布，缓存始终是最新的
+		toolInfo.Source = toolSourceLive
+		toolInfo.Stale = false
+		tools = append(tools, toolInfo)
+	}
+
+	// 无效的服务器配置不尝试连接，与连接失败一样使用缓存并报告错误
+	results := make(map[string]liveToolsResult, len(servers))
+	validServers := make(map[string]models.MCPServerConfigModel, len(servers))
+	for name, server := range servers {
+		if _, err := server.ToServerConfig(); err != nil {
+			results[name] = liveToolsResult{err: err}
+			continue
+		}
+		validServers[name] = server
+	}
+	for name, result := range s.listLiveTools(r.Context(), validServers) {
+		results[name] = result
+	}
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []ServerToolsError
+	for _, name := range names {
+		result := results[name]
+		if result.err != nil {
+			log.Printf("实时获取服务器 %s 的工具失败，使用缓存: %v", name, result.err)
+			errs = append(errs, ServerToolsError{Server: name, Error: result.err.Error()})
+			for _, info := range cachedByServer[name] {
+				tools = append(tools, cachedToolInfo(info, now, depth))
+			}
+			continue
+		}
+
+		for _, detail := range mcphost.NewToolDetails(name, result.tools) {
+			info := detailToolInfo(detail, depth)
+			info.Source = toolSourceLive
+			toolKey := models.GenerateToolKey(detail.Server, detail.Name)
+			if usage, ok := usageMap[toolKey]; ok {
+				info.UsageCount = usage.Calls
+				info.LastUsedAt = usage.LastUsedAt
+			}
+			info.ResultFilter = resultFilters[toolKey]
+			info.OutputFormat = outputFormats[toolKey]
+			info.LocalizedDescriptions = descriptions[toolKey]
+			tools = append(tools, info)
+		}
+	}
+
+	presenter := s.toolNamePresenter(servers)
+	for i := range tools {
+		tools[i].PresentedName = presenter.PresentedToolName(tools[i].Server, tools[i].Name)
+	}
+
+	sort.SliceStable(tools, func(i, j int) bool {
+		if tools[i].Server != tools[j].Server {
+			return tools[i].Server < tools[j].Server
+		}
+		return tools[i].Name < tools[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(tools) == 0 && len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(MCPToolsResponse{
+			Success: false,
+			Message: "连接MCP服务器失败且没有缓存的工具",
+			Error:   errs[0].Error,
+			Errors:  errs,
+		})
+		return
+	}
+
+	message := fmt.Sprintf("成功获取 %d 个工具", len(tools))
+	if len(errs) > 0 {
+		message = fmt.Sprintf("成功获取 %d 个工具，%d 个服务器使用缓存", len(tools), len(errs))
+	}
+	json.NewEncoder(w).Encode(MCPToolsResponse{
+		Success: true,
+		Message: message,
+		Tools:   tools,
+		Errors:  errs,
+	})
+}
+
+// handleGetCachedMCPTools handles GET /api/mcp/tools/cached
+// Only the tools cached in the database are listed, no server is contacted.
+// Supports ?page=, ?page_size=, ?sort=, ?q= and ?param_depth=.
+func (s *Server) handleGetCachedMCPTools(w http.ResponseWriter, r *http.Request) {
+	depth, err := parameterDepth(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query, _, err := parseListQuery(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cachedTools, pagination, err := s.mcpToolService.QueryToolsInfo(query)
+	if err != nil {
+		writeModelError(w, err, fmt.Sprintf("获取工具列表失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	servers, err := s.mcpServerConfigService.GetAllActiveConfigs()
+	if err != nil {
+		log.Printf("获取MCP服务器配置失败: %v", err)
+	}
+	presenter := s.toolNamePresenter(servers)
+
+	now := time.Now()
+	tools := make([]MCPToolInfo, 0, len(cachedTools))
+	for _, info := range cachedTools {
+		toolInfo := cachedToolInfo(info, now, depth)
+		if info.Server == config.InnerServerName {
+			toolInfo.Stale = false
+		}
+		toolInfo.PresentedName = presenter.PresentedToolName(toolInfo.Server, toolInfo.Name)
+		tools = append(tools, toolInfo)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MCPToolsResponse{
+		Success:    true,
+		Message:    fmt.Sprintf("成功获取 %d 个缓存的工具", len(tools)),
+		Tools:      tools,
+		Pagination: &pagination,
+	})
+}
+
+// toolResultFilterRequest is the body of PUT /api/mcp/tools/{toolKey}/result-filter
+type toolResultFilterRequest struct {
+	ResultFilter string `json:"result_filter"` // jq表达式，为空时移除过滤
+}
+
+// handleSetToolResultFilter handles PUT /api/mcp/tools/{toolKey}/result-filter
+// 设置工具的结果过滤表达式，之后启动的任务使用新的表达式
+func (s *Server) handleSetToolResultFilter(w http.ResponseWriter, r *http.Request) {
+	toolKey := mux.Vars(r)["toolKey"]
+
+	var req toolResultFilterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.mcpToolService.SetResultFilter(toolKey, req.ResultFilter); err != nil {
+		writeModelError(w, err, fmt.Sprintf("设置工具结果过滤表达式失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("已更新工具 %s 的结果过滤表达式", toolKey),
+	})
+}
+
+// toolOutputFormatRequest is the body of PUT /api/mcp/tools/{toolKey}/output-format
+type toolOutputFormatRequest struct {
+	OutputFormat string `json:"output_format"` // plain、markdown 或 json，为空时按内容判断
+}
+
+// handleSetToolOutputFormat handles PUT /api/mcp/tools/{toolKey}/output-format
+// 声明工具结果的格式，之后启动的任务的tool_result事件使用该格式作为content_format
+func (s *Server) handleSetToolOutputFormat(w http.ResponseWriter, r *http.Request) {
+	toolKey := mux.Vars(r)["toolKey"]
+
+	var req toolOutputFormatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.mcpToolService.SetOutputFormat(toolKey, req.OutputFormat); err != nil {
+		writeModelError(w, err, fmt.Sprintf("设置工具结果格式失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("已更新工具 %s 的结果格式", toolKey),
+	})
+}
+
+// toolDescriptionsRequest is the body of PUT /api/mcp/tools/{toolKey}/descriptions
+type toolDescriptionsRequest struct {
+	Descriptions map[string]string `json:"descriptions"` // 按语言的工具描述，如 {"zh": "..."}，未包含或为空的语言被移除
+}
+
+// handleSetToolDescriptions handles PUT /api/mcp/tools/{toolKey}/descriptions
+// 设置工具按语言的描述，之后启动的任务按 tool_description_language 使用对应描述
+func (s *Server) handleSetToolDescriptions(w http.ResponseWriter, r *http.Request) {
+	toolKey := mux.Vars(r)["toolKey"]
+
+	var req toolDescriptionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.mcpToolService.SetLocalizedDescriptions(toolKey, req.Descriptions); err != nil {
+		writeModelError(w, err, fmt.Sprintf("设置工具描述失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("已更新工具 %s 的描述", toolKey),
+	})
+}
+
+// cachedToolCatalog returns the tools cached in the database, implementing
+// mcpagent.ToolCatalog for the suggestions of agent.require_tools and the
+// candidates of tools_auto_select
+func (s *Server) cachedToolCatalog(ctx context.Context) ([]mcpagent.ToolSuggestion, error) {
+	tools, err := s.mcpToolService.GetAllActiveTools()
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]mcpagent.ToolSuggestion, 0, len(tools))
+	for _, tool := range tools {
+		descriptions, err := tool.GetLocalizedDescriptions()
+		if err != nil {
+			log.Printf("解析工具 %s 的本地化描述失败: %v", tool.ToolKey, err)
+		}
+		suggestions = append(suggestions, mcpagent.ToolSuggestion{Server: tool.Server.Name, Name: tool.Name, Description: tool.Description, Descriptions: descriptions})
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,329 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupToolListingServer creates a server with one working and one broken MCP server
+func setupToolListingServer(t *testing.T) *Server {
+	server := setupTaskTestServer(t)
+
+	servers, err := server.mcpServerConfigService.ListConfigs()
+	require.NoError(t, err)
+	for _, s := range servers {
+		require.NoError(t, server.mcpServerConfigService.DeleteConfig(s.ID))
+	}
+
+	working := &models.MCPServerConfigModel{Name: "working", TransportType: "sse", URL: "http://127.0.0.1:1/sse"}
+	require.NoError(t, server.mcpServerConfigService.CreateConfig(working))
+	broken := &models.MCPServerConfigModel{Name: "broken", TransportType: "stdio", Command: "/nonexistent/mcp-server"}
+	require.NoError(t, server.mcpServerConfigService.CreateConfig(broken))
+
+	// broken服务器在缓存中有一个很久以前同步的工具
+	cached := &models.MCPToolModel{Name: "lookup", ServerID: broken.ID, ToolKey: "broken_lookup", IsActive: true}
+	require.NoError(t, server.mcpToolService.CreateTool(cached))
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, server.db.Model(cached).Update("last_sync_at", old).Error)
+
+	server.toolLister = func(ctx context.Context, s *models.MCPServerConfigModel) ([]*schema.ToolInfo, error) {
+		if s.Name == "working" {
+			return []*schema.ToolInfo{{Name: "search", Desc: "search the web"}}, nil
+		}
+		return nil, errors.New("exec: no such file or directory")
+	}
+	return server
+}
+
+func TestHandleGetMCPToolsFromDBFallsBackToCache(t *testing.T) {
+	server := setupToolListingServer(t)
+
+	w := httptest.NewRecorder()
+	server.handleGetMCPToolsFromDB(w, httptest.NewRequest("GET", "/api/mcp/tools/configured", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp MCPToolsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+
+	require.Len(t, resp.Tools, 2)
+	assert.Equal(t, MCPToolInfo{Name: "lookup", PresentedName: "lookup", Server: "broken", Source: toolSourceCache, Stale: true, Parameters: []models.ToolParameter{}}, resp.Tools[0])
+	assert.Equal(t, MCPToolInfo{Name: "search", PresentedName: "search", Description: "search the web", Server: "working", Source: toolSourceLive, Parameters: []models.ToolParameter{}}, resp.Tools[1])
+
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "broken", resp.Errors[0].Server)
+	assert.Contains(t, resp.Errors[0].Error, "no such file")
+}
+
+func TestHandleGetMCPToolsFromDBParameters(t *testing.T) {
+	server := setupToolListingServer(t)
+	cached, err := server.mcpToolService.GetToolByKey("broken_lookup")
+	require.NoError(t, err)
+	require.NoError(t, cached.SetInputSchema(map[string]any{
+		"type":     "object",
+		"required": []any{"ip"},
+		"properties": map[string]any{
+			"ip":      map[string]any{"type": "string", "description": "IP地址"},
+			"options": map[string]any{"type": "object", "properties": map[string]any{"ports": map[string]any{"type": "array", "items": map[string]any{"type": "integer"}}}},
+		},
+	}))
+	require.NoError(t, server.db.Model(cached).Update("input_schema", cached.InputSchema).Error)
+	server.toolLister = func(ctx context.Context, s *models.MCPServerConfigModel) ([]*schema.ToolInfo, error) {
+		if s.Name == "working" {
+			return []*schema.ToolInfo{{
+				Name: "search",
+				ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+					"mode": {Type: schema.String, Enum: []string{"fast", "accurate"}, Required: true},
+				}),
+			}}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	get := func(path string) MCPToolsResponse {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp MCPToolsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Tools, 2)
+		return resp
+	}
+
+	// 缓存和实时获取的工具都带有原始模式和展开的参数
+	resp := get("/api/mcp/tools/configured")
+	assert.Equal(t, "object", resp.Tools[0].InputSchema["type"])
+	assert.Equal(t, []models.ToolParameter{
+		{Name: "ip", Type: "string", Description: "IP地址", Required: true},
+		{Name: "options.ports", Type: "array", ItemType: "integer"},
+	}, resp.Tools[0].Parameters)
+	assert.NotNil(t, resp.Tools[1].InputSchema)
+	assert.Equal(t, []models.ToolParameter{
+		{Name: "mode", Type: "string", Required: true, Enum: []any{"fast", "accurate"}},
+	}, resp.Tools[1].Parameters)
+
+	resp = get("/api/mcp/tools/configured?param_depth=1")
+	require.Len(t, resp.Tools[0].Parameters, 2)
+	assert.Equal(t, models.ToolParameter{Name: "options", Type: "object"}, resp.Tools[0].Parameters[1])
+
+	for _, depth := range []string{"0", "abc", "11"} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/mcp/tools/configured?param_depth="+depth, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, depth)
+	}
+}
+
+func TestHandleGetMCPToolsFromDBAllServersDown(t *testing.T) {
+	server := setupToolListingServer(t)
+	server.toolLister = func(ctx context.Context, s *models.MCPServerConfigModel) ([]*schema.ToolInfo, error) {
+		return nil, errors.New("connection refused")
+	}
+	cached, err := server.mcpToolService.GetToolByKey("broken_lookup")
+	require.NoError(t, err)
+	require.NoError(t, server.mcpToolService.DeleteTool(cached.ID))
+
+	w := httptest.NewRecorder()
+	server.handleGetMCPToolsFromDB(w, httptest.NewRequest("GET", "/api/mcp/tools/configured", nil))
+
+	var resp MCPToolsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, resp.Success)
+	assert.Len(t, resp.Errors, 2)
+}
+
+func TestHandleGetMCPToolsFromDBPresentedNames(t *testing.T) {
+	server := setupToolListingServer(t)
+
+	servers, err := server.mcpServerConfigService.GetAllActiveConfigs()
+	require.NoError(t, err)
+	working := servers["working"]
+	working.NamePrefix = "web"
+	require.NoError(t, server.mcpServerConfigService.UpdateConfig(working.ID, &working))
+
+	w := httptest.NewRecorder()
+	server.handleGetMCPToolsFromDB(w, httptest.NewRequest("GET", "/api/mcp/tools/configured", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp MCPToolsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tools, 2)
+	assert.Equal(t, "lookup", resp.Tools[0].PresentedName)
+	assert.Equal(t, "search", resp.Tools[1].Name)
+	assert.Equal(t, "web__search", resp.Tools[1].PresentedName)
+}
+
+func TestHandleGetMCPToolsFromDBSkipsInvalidServer(t *testing.T) {
+	server := setupToolListingServer(t)
+
+	// 直接修改数据库得到的无效配置：sse服务器没有URL
+	servers, err := server.mcpServerConfigService.GetAllActiveConfigs()
+	require.NoError(t, err)
+	working := servers["working"]
+	require.NoError(t, server.db.Model(&working).Update("url", "").Error)
+
+	var listed []string
+	server.toolLister = func(ctx context.Context, s *models.MCPServerConfigModel) ([]*schema.ToolInfo, error) {
+		listed = append(listed, s.Name)
+		return []*schema.ToolInfo{{Name: "lookup"}}, nil
+	}
+
+	w := httptest.NewRecorder()
+	server.handleGetMCPToolsFromDB(w, httptest.NewRequest("GET", "/api/mcp/tools/configured", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp MCPToolsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, []string{"broken"}, listed, "无效的服务器不应尝试连接")
+
+	require.Len(t, resp.Tools, 1)
+	assert.Equal(t, "broken", resp.Tools[0].Server)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "working", resp.Errors[0].Server)
+	assert.Contains(t, resp.Errors[0].Error, "MCP服务器 working 配置无效")
+}
+
+func TestHandleSetToolResultFilter(t *testing.T) {
+	server := setupToolListingServer(t)
+
+	put := func(toolKey string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/mcp/tools/"+toolKey+"/result-filter", strings.NewReader(body)))
+		return w
+	}
+
+	w := put("broken_lookup", `{"result_filter":".results[] | {ip, port}"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 工具列表返回已设置的表达式
+	w = httptest.NewRecorder()
+	server.handleGetMCPToolsFromDB(w, httptest.NewRequest("GET", "/api/mcp/tools/configured", nil))
+	var resp MCPToolsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tools, 2)
+	assert.Equal(t, ".results[] | {ip, port}", resp.Tools[0].ResultFilter)
+
+	// 任务的工具列表使用数据库中的表达式，请求中设置的表达式优先
+	cfg, err := server.resolveTaskConfig(&TaskRequest{Task: "测试任务", Tools: []config.MCPToolConfig{
+		{Server: "broken", Name: "lookup"},
+		{Server: "working", Name: "search", ResultFilter: ".items"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, ".results[] | {ip, port}", cfg.MCP.Tools[0].ResultFilter)
+	assert.Equal(t, ".items", cfg.MCP.Tools[1].ResultFilter)
+
+	w = put("broken_lookup", `{"result_filter":".results[] | {"}`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var errResp ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, errCodeValidationFailed, errResp.Error.Code)
+	require.Len(t, errResp.Error.Fields, 1)
+	assert.Equal(t, "result_filter", errResp.Error.Fields[0].Field)
+
+	w = put("broken_missing", `{"result_filter":"."}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandleSetToolOutputFormat(t *testing.T) {
+	server := setupToolListingServer(t)
+
+	put := func(toolKey string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/mcp/tools/"+toolKey+"/output-format", strings.NewReader(body)))
+		return w
+	}
+
+	w := put("broken_lookup", `{"output_format":"markdown"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 工具列表返回声明的格式
+	w = httptest.NewRecorder()
+	server.handleGetMCPToolsFromDB(w, httptest.NewRequest("GET", "/api/mcp/tools/configured", nil))
+	var resp MCPToolsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tools, 2)
+	assert.Equal(t, models.ContentFormatMarkdown, resp.Tools[0].OutputFormat)
+
+	// 任务按工具展示给大模型的名称查找声明的格式
+	cfg, err := server.resolveTaskConfig(&TaskRequest{Task: "测试任务", Tools: []config.MCPToolConfig{
+		{Server: "broken", Name: "lookup"},
+		{Server: "working", Name: "search"},
+	}})
+	require.NoError(t, err)
+	cfg.MCP.PrefixToolNames = true
+	formats := server.toolOutputFormats(cfg)
+	assert.Equal(t, models.ContentFormatMarkdown, formats.of(cfg.MCP.PresentedToolName("broken", "lookup")))
+	assert.Equal(t, models.ContentFormatMarkdown, formats.of("lookup"))
+	assert.Empty(t, formats.of(cfg.MCP.PresentedToolName("working", "search")))
+
+	apiErr := decodeErrorResponse(t, put("broken_lookup", `{"output_format":"html"}`), http.StatusBadRequest)
+	assert.Equal(t, []string{"output_format"}, fieldNames(apiErr))
+
+	w = put("broken_missing", `{"output_format":"json"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 空格式移除声明
+	w = put("broken_lookup", `{"output_format":""}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, server.toolOutputFormats(cfg))
+}
+
+func TestHandleGetCachedMCPToolsPaginates(t *testing.T) {
+	server := setupToolListingServer(t)
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	// 只返回缓存的工具，不连接服务器
+	server.toolLister = func(ctx context.Context, s *models.MCPServerConfigModel) ([]*schema.ToolInfo, error) {
+		t.Errorf("缓存工具列表不应连接服务器 %s", s.Name)
+		return nil, nil
+	}
+	w := get("/api/mcp/tools/cached?q=lookup&page=1&page_size=5")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp MCPToolsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tools, 1)
+	assert.Equal(t, "broken", resp.Tools[0].Server)
+	assert.Equal(t, toolSourceCache, resp.Tools[0].Source)
+	assert.True(t, resp.Tools[0].Stale)
+	require.NotNil(t, resp.Pagination)
+	assert.Equal(t, int64(1), resp.Pagination.Total)
+	assert.Equal(t, 5, resp.Pagination.PageSize)
+
+	apiErr := decodeErrorResponse(t, get("/api/mcp/tools/cached?sort=server"), http.StatusBadRequest)
+	assert.Equal(t, errCodeValidationFailed, apiErr.Code)
+	require.Len(t, apiErr.Fields, 1)
+	assert.Equal(t, "sort", apiErr.Fields[0].Field)
+
+	w = get("/api/mcp/servers?page_size=abc")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = get("/api/mcp/servers?sort=-name&page_size=1")
+	require.Equal(t, http.StatusOK, w.Code)
+	var servers struct {
+		Data       []MCPServerConfigResponse `json:"data"`
+		Pagination struct {
+			Total int64 `json:"total"`
+		} `json:"pagination"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &servers))
+	require.Len(t, servers.Data, 1)
+	assert.Equal(t, "working", servers.Data[0].Name)
+	assert.Equal(t, int64(2), servers.Pagination.Total)
+}
@@ -0,0 +1,79 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+)
+
+const (
+	// defaultStatsLimit is the default number of tools returned by the stats endpoint
+	defaultStatsLimit = 10
+
+	// minCallsForErrorRate is the minimum number of calls before a tool is ranked by error rate
+	minCallsForErrorRate = 3
+)
+
+// ToolUsageStat represents the usage statistics of one tool in API responses
+type ToolUsageStat struct {
+	ToolKey       string     `json:"tool_key"`
+	Calls         int64      `json:"calls"`
+	Errors        int64      `json:"errors"`
+	ErrorRate     float64    `json:"error_rate"`
+	AvgDurationMs int64      `json:"avg_duration_ms"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+}
+
+// toToolUsageStats converts usage models to API response items
+func toToolUsageStats(usages []models.ToolUsageModel) []ToolUsageStat {
+	stats := make([]ToolUsageStat, 0, len(usages))
+	for _, usage := range usages {
+		stats = append(stats, ToolUsageStat{
+			ToolKey:       usage.ToolKey,
+			Calls:         usage.Calls,
+			Errors:        usage.Errors,
+			ErrorRate:     usage.ErrorRate(),
+			AvgDurationMs: usage.AvgDurationMs(),
+			LastUsedAt:    usage.LastUsedAt,
+		})
+	}
+	return stats
+}
+
+// handleGetToolUsageStats handles GET /api/mcp/tools/stats
+// It returns the top-N tools by calls and by error rate, N is set with ?limit=.
+func (s *Server) handleGetToolUsageStats(w http.ResponseWriter, r *http.Request) {
+	limit := defaultStatsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, "无效的limit参数", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	topByCalls, err := s.toolUsageService.TopByCalls(limit)
+	if err != nil {
+		writeError(w, fmt.Sprintf("获取工具调用统计失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	topByErrorRate, err := s.toolUsageService.TopByErrorRate(limit, minCallsForErrorRate)
+	if err != nil {
+		writeError(w, fmt.Sprintf("获取工具调用统计失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"top_by_calls":      toToolUsageStats(topByCalls),
+			"top_by_error_rate": toToolUsageStats(topByErrorRate),
+		},
+	})
+}
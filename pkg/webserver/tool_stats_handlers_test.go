@@ -0,0 +1,53 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/mcpagent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleGetToolUsageStats(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	// 通过通知器异步记录调用
+	notifier := &BroadcastNotifier{server: server, taskID: "task_stats"}
+	for i := 0; i < 5; i++ {
+		notifier.OnToolUsage(mcpagent.ToolUsage{ToolKey: "fetch_fetch", Duration: time.Millisecond})
+	}
+	for i := 0; i < 3; i++ {
+		notifier.OnToolUsage(mcpagent.ToolUsage{ToolKey: "inner_search", Err: assert.AnError})
+	}
+	server.toolUsageRecorder.Close()
+
+	req := httptest.NewRequest("GET", "/api/mcp/tools/stats?limit=5", nil)
+	w := httptest.NewRecorder()
+	server.handleGetToolUsageStats(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Success bool `json:"success"`
+		Data    struct {
+			TopByCalls     []ToolUsageStat `json:"top_by_calls"`
+			TopByErrorRate []ToolUsageStat `json:"top_by_error_rate"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	require.Len(t, resp.Data.TopByCalls, 2)
+	assert.Equal(t, "fetch_fetch", resp.Data.TopByCalls[0].ToolKey)
+	assert.Equal(t, int64(5), resp.Data.TopByCalls[0].Calls)
+	require.Len(t, resp.Data.TopByErrorRate, 1)
+	assert.Equal(t, "inner_search", resp.Data.TopByErrorRate[0].ToolKey)
+	assert.Equal(t, 1.0, resp.Data.TopByErrorRate[0].ErrorRate)
+
+	// 无效的limit参数
+	w = httptest.NewRecorder()
+	server.handleGetToolUsageStats(w, httptest.NewRequest("GET", "/api/mcp/tools/stats?limit=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,303 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// toolSyncWorkers limits how many servers are synchronized at the same time
+	toolSyncWorkers = 4
+
+	// defaultToolSyncTimeout is the time a single server gets to synchronize its tools
+	defaultToolSyncTimeout = 30 * time.Second
+
+	// toolSyncJobRetention is how long finished sync jobs can still be polled
+	toolSyncJobRetention = time.Hour
+)
+
+// Status values of a server in a sync job
+const (
+	syncStatusPending = "pending"
+	syncStatusRunning = "running"
+	syncStatusSuccess = "success"
+	syncStatusFailed  = "failed"
+)
+
+// Status values of a sync job
+const (
+	syncJobRunning   = "running"
+	syncJobCompleted = "completed"
+)
+
+// SyncProgress is the sync state of one server. It is broadcast to all
+// clients as a "sync_progress" message whenever the state changes.
+type SyncProgress struct {
+	JobID      string               `json:"job_id"`
+	ServerID   uint                 `json:"server_id"`
+	ServerName string               `json:"server_name"`
+	Status     string               `json:"status"` // pending、running、success 或 failed
+	ToolCount  int                  `json:"tool_count"`
+	Applied    int                  `json:"applied,omitempty"` // 已写入的工具变化数量
+	Changes    int                  `json:"changes,omitempty"` // 本次同步的工具变化总数
+	Report     *services.SyncReport `json:"report,omitempty"`  // 同步成功后的工具变化
+	Error      string               `json:"error,omitempty"`
+	Timestamp  int64                `json:"timestamp"`
+}
+
+// SyncJob is a snapshot of an asynchronous tool synchronization
+type SyncJob struct {
+	ID         string         `json:"id"`
+	Status     string         `json:"status"` // running 或 completed
+	Servers    []SyncProgress `json:"servers"`
+	TotalTools int            `json:"total_tools"`
+	Failed     int            `json:"failed"` // 同步失败的服务器数量
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+// toolSyncJob tracks a running synchronization
+type toolSyncJob struct {
+	mu  sync.Mutex
+	job SyncJob
+}
+
+// snapshot returns a copy of the job state
+func (j *toolSyncJob) snapshot() SyncJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job := j.job
+	job.Servers = append([]SyncProgress(nil), j.job.Servers...)
+	return job
+}
+
+// update changes the state of the server at index and returns the new state
+func (j *toolSyncJob) update(index int, status string, report *services.SyncReport, err error) SyncProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	progress := &j.job.Servers[index]
+	progress.Status = status
+	progress.Report = report
+	if report != nil {
+		progress.ToolCount = report.Total()
+	}
+	progress.Timestamp = time.Now().UnixMilli()
+	if err != nil {
+		progress.Error = err.Error()
+	}
+	return *progress
+}
+
+// applied records how many changes of the server at index are written and returns the new state
+func (j *toolSyncJob) applied(index, applied, changes int) SyncProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	progress := &j.job.Servers[index]
+	progress.Applied = applied
+	progress.Changes = changes
+	progress.Timestamp = time.Now().UnixMilli()
+	return *progress
+}
+
+// finish marks the job as completed and summarizes the results
+func (j *toolSyncJob) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.job.Status = syncJobCompleted
+	j.job.FinishedAt = &now
+	for _, progress := range j.job.Servers {
+		if progress.Status == syncStatusFailed {
+			j.job.Failed++
+		}
+		j.job.TotalTools += progress.ToolCount
+	}
+}
+
+// serverToolSyncer synchronizes the tools of one server and returns what changed
+type serverToolSyncer func(ctx context.Context, server *models.MCPServerConfigModel) (*services.SyncReport, error)
+
+// syncServerTools stores the tools of a server in the database
+func (s *Server) syncServerTools(ctx context.Context, server *models.MCPServerConfigModel) (*services.SyncReport, error) {
+	return s.mcpToolService.SyncToolsForServer(ctx, server)
+}
+
+// startToolSync creates a sync job for servers and runs it in the background
+func (s *Server) startToolSync(servers []models.MCPServerConfigModel) *toolSyncJob {
+	job := &toolSyncJob{job: SyncJob{
+		ID:        fmt.Sprintf("sync_%d", time.Now().UnixNano()),
+		Status:    syncJobRunning,
+		Servers:   make([]SyncProgress, len(servers)),
+		StartedAt: time.Now(),
+	}}
+	for i, server := range servers {
+		job.job.Servers[i] = SyncProgress{
+			JobID:      job.job.ID,
+			ServerID:   server.ID,
+			ServerName: server.Name,
+			Status:     syncStatusPending,
+			Timestamp:  time.Now().UnixMilli(),
+		}
+	}
+
+	s.syncJobsMu.Lock()
+	s.syncJobs[job.job.ID] = job
+	s.syncJobsMu.Unlock()
+
+	go s.runToolSync(job, servers)
+	return job
+}
+
+// runToolSync synchronizes the servers of a job with a bounded worker pool.
+// Every server has its own timeout, so slow servers do not delay the others.
+func (s *Server) runToolSync(job *toolSyncJob, servers []models.MCPServerConfigModel) {
+	syncer := s.toolSyncer
+	if syncer == nil {
+		syncer = s.syncServerTools
+	}
+	timeout := s.toolSyncTimeout
+	if timeout <= 0 {
+		timeout = defaultToolSyncTimeout
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < toolSyncWorkers && w < len(servers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				s.broadcastSyncProgress(job.update(i, syncStatusRunning, nil, nil))
+				ctx := services.WithSyncProgress(context.Background(), func(applied, changes int) {
+					s.broadcastSyncProgress(job.applied(i, applied, changes))
+				})
+				report, err := syncWithTimeout(ctx, syncer, &servers[i], timeout)
+				if err != nil {
+					log.Printf("同步服务器 %s 失败: %v", servers[i].Name, err)
+					s.broadcastSyncProgress(job.update(i, syncStatusFailed, nil, err))
+					continue
+				}
+				s.broadcastSyncProgress(job.update(i, syncStatusSuccess, report, nil))
+			}
+		}()
+	}
+	for i := range servers {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	job.finish()
+	snapshot := job.snapshot()
+	log.Printf("工具同步任务 %s 完成，共获取 %d 个工具，%d 个服务器同步失败", snapshot.ID, snapshot.TotalTools, snapshot.Failed)
+
+	time.AfterFunc(toolSyncJobRetention, func() {
+		s.syncJobsMu.Lock()
+		delete(s.syncJobs, snapshot.ID)
+		s.syncJobsMu.Unlock()
+	})
+}
+
+// syncWithTimeout runs syncer within timeout. The result of a syncer that
+// ignores the context is discarded once the timeout has passed.
+func syncWithTimeout(ctx context.Context, syncer serverToolSyncer, server *models.MCPServerConfigModel, timeout time.Duration) (*services.SyncReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		report *services.SyncReport
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		report, err := syncer(ctx, server)
+		done <- result{report: report, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.report, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("同步超时（%v）: %w", timeout, ctx.Err())
+	}
+}
+
+// broadcastSyncProgress sends the sync state of a server to all clients
+func (s *Server) broadcastSyncProgress(progress SyncProgress) {
+	s.broadcast(SSEMessage{Type: MessageTypeSyncProgress, Data: progress})
+}
+
+// handleSyncMCPTools handles POST /api/mcp/tools/sync
+// 在后台并发同步所有活跃服务器的工具，立即返回同步任务ID。
+// 每个服务器的进度通过SSE的sync_progress消息推送，也可以通过
+// GET /api/mcp/tools/sync/status/{jobId} 查询。
+func (s *Server) handleSyncMCPTools(w http.ResponseWriter, r *http.Request) {
+	// 获取所有活跃的MCP服务器配置
+	configs, err := s.mcpServerConfigService.GetAllActiveConfigs()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(MCPToolsResponse{
+			Success: false,
+			Message: "获取MCP服务器配置失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if len(configs) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPToolsResponse{
+			Success: true,
+			Message: "没有找到活跃的MCP服务器配置",
+			Tools:   []MCPToolInfo{},
+		})
+		return
+	}
+
+	servers := make([]models.MCPServerConfigModel, 0, len(configs))
+	for _, config := range configs {
+		servers = append(servers, config)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+
+	job := s.startToolSync(servers)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("开始同步 %d 个服务器的工具", len(servers)),
+		"job_id":  job.job.ID,
+		"data":    job.snapshot(),
+	})
+}
+
+// handleGetToolSyncStatus handles GET /api/mcp/tools/sync/status/{jobId}
+func (s *Server) handleGetToolSyncStatus(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobId"]
+
+	s.syncJobsMu.Lock()
+	job, ok := s.syncJobs[jobID]
+	s.syncJobsMu.Unlock()
+	if !ok {
+		writeError(w, "同步任务不存在", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    job.snapshot(),
+	})
+}
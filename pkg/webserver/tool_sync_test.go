@@ -0,0 +1,93 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/LubyRuffy/mcpagent/pkg/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncMCPToolsConcurrentWithTimeout(t *testing.T) {
+	server := setupTaskTestServer(t)
+
+	servers, err := server.mcpServerConfigService.ListConfigs()
+	require.NoError(t, err)
+	for _, s := range servers {
+		require.NoError(t, server.mcpServerConfigService.DeleteConfig(s.ID))
+	}
+	for _, name := range []string{"fast", "slow"} {
+		require.NoError(t, server.mcpServerConfigService.CreateConfig(
+			&models.MCPServerConfigModel{Name: name, TransportType: "sse", URL: "http://127.0.0.1:1/" + name}))
+	}
+
+	// slow服务器一直阻塞到超时
+	server.toolSyncTimeout = 100 * time.Millisecond
+	server.toolSyncer = func(ctx context.Context, s *models.MCPServerConfigModel) (*services.SyncReport, error) {
+		if s.Name == "fast" {
+			return &services.SyncReport{Added: []string{"fast_a"}, Updated: []string{"fast_b"}, Unchanged: 1}, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	w := httptest.NewRecorder()
+	client := &SSENotifier{writer: w, taskID: "observer"}
+	server.clients["observer"] = client
+
+	// 立即返回同步任务ID
+	start := time.Now()
+	resp := httptest.NewRecorder()
+	server.router.ServeHTTP(resp, httptest.NewRequest("POST", "/api/mcp/tools/sync", nil))
+	require.Equal(t, http.StatusAccepted, resp.Code, resp.Body.String())
+	assert.Less(t, time.Since(start), server.toolSyncTimeout)
+	var accepted struct {
+		JobID string `json:"job_id"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &accepted))
+	require.NotEmpty(t, accepted.JobID)
+
+	status := func() SyncJob {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/mcp/tools/sync/status/"+accepted.JobID, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Data SyncJob `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data
+	}
+	require.Eventually(t, func() bool { return status().Status == syncJobCompleted }, 2*time.Second, 10*time.Millisecond)
+
+	job := status()
+	assert.Equal(t, 3, job.TotalTools)
+	assert.Equal(t, 1, job.Failed)
+	require.Len(t, job.Servers, 2)
+	assert.Equal(t, "fast", job.Servers[0].ServerName)
+	assert.Equal(t, syncStatusSuccess, job.Servers[0].Status)
+	assert.Equal(t, 3, job.Servers[0].ToolCount)
+	assert.Equal(t, &services.SyncReport{Added: []string{"fast_a"}, Updated: []string{"fast_b"}, Unchanged: 1}, job.Servers[0].Report)
+	assert.Equal(t, "slow", job.Servers[1].ServerName)
+	assert.Equal(t, syncStatusFailed, job.Servers[1].Status)
+	assert.Contains(t, job.Servers[1].Error, "同步超时")
+	assert.Nil(t, job.Servers[1].Report)
+
+	// 每个服务器都推送了进度
+	assert.Eventually(t, func() bool {
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		return strings.Count(w.Body.String(), `"sync_progress"`) == 4
+	}, time.Second, 10*time.Millisecond)
+
+	// 未知的任务ID
+	notFound := httptest.NewRecorder()
+	server.router.ServeHTTP(notFound, httptest.NewRequest("GET", "/api/mcp/tools/sync/status/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, notFound.Code)
+}
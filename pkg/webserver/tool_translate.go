@@ -0,0 +1,210 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// defaultTranslateLimit is the number of tools translated by one request
+	// when the request sets no limit
+	defaultTranslateLimit = 20
+	// maxTranslateLimit is the largest number of tools translated by one request
+	maxTranslateLimit = 100
+	// defaultTranslateInterval is the minimum time between two translation
+	// requests to the model
+	defaultTranslateInterval = time.Second
+	// translateRequestTimeout is the time a single translation may take
+	translateRequestTimeout = 2 * time.Minute
+)
+
+// languageNames are the names of common languages used in the translation prompt
+var languageNames = map[string]string{
+	"zh": "简体中文",
+	"en": "English",
+	"ja": "日本語",
+}
+
+// thinkTagPattern matches the reasoning some models put before the answer
+var thinkTagPattern = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// descriptionTranslator translates a tool description into language
+type descriptionTranslator func(ctx context.Context, description string, language string) (string, error)
+
+// toolTranslateRequest is the body of POST /api/mcp/tools/translate
+type toolTranslateRequest struct {
+	Language string `json:"language"` // 目标语言，如 zh
+	Limit    int    `json:"limit"`    // 本次最多翻译的工具数，0表示默认20个
+}
+
+// ToolTranslateFailure is a tool whose description could not be translated
+type ToolTranslateFailure struct {
+	ToolKey string `json:"tool_key"`
+	Error   string `json:"error"`
+}
+
+// ToolTranslateResult summarizes a translation request
+type ToolTranslateResult struct {
+	Language   string                 `json:"language"`
+	Translated int                    `json:"translated"`       // 本次翻译的工具数
+	Failed     []ToolTranslateFailure `json:"failed,omitempty"` // 翻译失败的工具，再次请求时重试
+	Remaining  int                    `json:"remaining"`        // 仍没有该语言描述的工具数
+}
+
+// translateWithLLM translates a description with the model of the default task configuration
+func (s *Server) translateWithLLM(ctx context.Context, description string, language string) (string, error) {
+	cfg, err := s.loadDefaultTaskConfig(nil)
+	if err != nil {
+		return "", err
+	}
+	chatModel, err := cfg.GetModel(ctx)
+	if err != nil {
+		return "", fmt.Errorf("创建大模型失败: %w", err)
+	}
+
+	name := languageNames[language]
+	if name == "" {
+		name = language
+	}
+	output, err := chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf("你是翻译助手。将用户提供的MCP工具描述翻译为%s，工具名、参数名、代码和URL保持原文，只输出译文。", name)),
+		schema.UserMessage(description),
+	})
+	if err != nil {
+		return "", err
+	}
+	translated := strings.TrimSpace(thinkTagPattern.ReplaceAllString(output.Content, ""))
+	if translated == "" {
+		return "", errors.New("大模型返回的译文为空")
+	}
+	return translated, nil
+}
+
+// untranslatedTools returns the active tools with a description but no
+// localized description in language, ordered by tool key
+func (s *Server) untranslatedTools(language string) ([]models.MCPToolModel, error) {
+	tools, err := s.mcpToolService.GetAllActiveTools()
+	if err != nil {
+		return nil, err
+	}
+	var missing []models.MCPToolModel
+	for _, tool := range tools {
+		if strings.TrimSpace(tool.Description) == "" {
+			continue
+		}
+		descriptions, err := tool.GetLocalizedDescriptions()
+		if err != nil {
+			log.Printf("解析工具 %s 的本地化描述失败: %v", tool.ToolKey, err)
+		}
+		if descriptions[language] == "" {
+			missing = append(missing, tool)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].ToolKey < missing[j].ToolKey })
+	return missing, nil
+}
+
+// translateToolDescriptions translates the descriptions of up to limit tools
+// missing language. Every translation is stored when it is done, so that an
+// interrupted request loses no work and the next request continues with the
+// remaining tools.
+func (s *Server) translateToolDescriptions(ctx context.Context, language string, limit int) (ToolTranslateResult, error) {
+	result := ToolTranslateResult{Language: language}
+	tools, err := s.untranslatedTools(language)
+	if err != nil {
+		return result, err
+	}
+	translator := s.descriptionTranslator
+	if translator == nil {
+		translator = s.translateWithLLM
+	}
+
+	for i, tool := range tools {
+		if i >= limit {
+			break
+		}
+		// 限制请求频率，避免占满本地模型或触发服务商限流
+		if i > 0 && s.translateInterval > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(s.translateInterval):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		translateCtx, cancel := context.WithTimeout(ctx, translateRequestTimeout)
+		translated, err := translator(translateCtx, tool.Description, language)
+		cancel()
+		if err == nil {
+			err = s.mcpToolService.SetLocalizedDescription(tool.ToolKey, language, translated)
+		}
+		if err != nil {
+			log.Printf("翻译工具 %s 的描述失败: %v", tool.ToolKey, err)
+			result.Failed = append(result.Failed, ToolTranslateFailure{ToolKey: tool.ToolKey, Error: err.Error()})
+			continue
+		}
+		result.Translated++
+	}
+
+	remaining, err := s.untranslatedTools(language)
+	if err != nil {
+		return result, err
+	}
+	result.Remaining = len(remaining)
+	return result, nil
+}
+
+// handleTranslateToolDescriptions handles POST /api/mcp/tools/translate
+// 使用默认配置的大模型为缺少指定语言描述的工具生成描述。每次请求最多翻译
+// limit 个工具，已完成的翻译立即保存，重复请求即可继续翻译剩余的工具。
+func (s *Server) handleTranslateToolDescriptions(w http.ResponseWriter, r *http.Request) {
+	var req toolTranslateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "解析请求失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Language = strings.TrimSpace(req.Language)
+	if req.Language == "" {
+		writeModelError(w, models.ErrMCPToolDescriptionLanguageEmpty, models.ErrMCPToolDescriptionLanguageEmpty.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultTranslateLimit
+	}
+	if req.Limit > maxTranslateLimit {
+		req.Limit = maxTranslateLimit
+	}
+
+	// 同一时间只进行一次翻译，避免重复翻译同一个工具
+	if !s.translateMu.TryLock() {
+		writeError(w, "已有工具描述翻译正在进行，请稍后再试", http.StatusConflict)
+		return
+	}
+	defer s.translateMu.Unlock()
+
+	result, err := s.translateToolDescriptions(r.Context(), req.Language, req.Limit)
+	if err != nil {
+		writeError(w, fmt.Sprintf("翻译工具描述失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("已翻译 %d 个工具的描述，剩余 %d 个", result.Translated, result.Remaining),
+		"data":    result,
+	})
+}
@@ -0,0 +1,129 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LubyRuffy/mcpagent/pkg/config"
+	"github.com/LubyRuffy/mcpagent/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSetToolDescriptions(t *testing.T) {
+	server := setupToolListingServer(t)
+
+	put := func(toolKey string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("PUT", "/api/mcp/tools/"+toolKey+"/descriptions", strings.NewReader(body)))
+		return w
+	}
+
+	w := put("broken_lookup", `{"descriptions":{"zh":" 查询资产 ","en":""}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 工具列表返回已设置的描述，空描述被移除
+	w = httptest.NewRecorder()
+	server.handleGetMCPToolsFromDB(w, httptest.NewRequest("GET", "/api/mcp/tools/configured", nil))
+	var resp MCPToolsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Tools, 2)
+	assert.Equal(t, map[string]string{"zh": "查询资产"}, resp.Tools[0].LocalizedDescriptions)
+
+	// 任务的工具列表使用数据库中的描述，请求中设置的描述优先
+	cfg, err := server.resolveTaskConfig(&TaskRequest{Task: "测试任务", Tools: []config.MCPToolConfig{
+		{Server: "broken", Name: "lookup"},
+		{Server: "working", Name: "search", Descriptions: map[string]string{"zh": "搜索网页"}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "查询资产", cfg.MCP.Tools[0].Descriptions["zh"])
+	assert.Equal(t, map[string]string{"zh": "搜索网页"}, cfg.MCP.Tools[1].Descriptions)
+
+	apiErr := decodeErrorResponse(t, put("broken_lookup", `{"descriptions":{" ":"描述"}}`), http.StatusBadRequest)
+	assert.Equal(t, errCodeValidationFailed, apiErr.Code)
+	require.Len(t, apiErr.Fields, 1)
+	assert.Equal(t, "descriptions", apiErr.Fields[0].Field)
+
+	w = put("broken_missing", `{"descriptions":{"zh":"描述"}}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 设置单个语言的描述时保留其他语言的描述
+	require.NoError(t, server.mcpToolService.SetLocalizedDescription("broken_lookup", "en", "Look up assets"))
+	descriptions, err := server.mcpToolService.LocalizedDescriptions()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"zh": "查询资产", "en": "Look up assets"}, descriptions["broken_lookup"])
+}
+
+func TestHandleTranslateToolDescriptions(t *testing.T) {
+	server := setupToolListingServer(t)
+	server.translateInterval = 0
+
+	servers, err := server.mcpServerConfigService.GetAllActiveConfigs()
+	require.NoError(t, err)
+	working := servers["working"]
+	for _, tool := range []*models.MCPToolModel{
+		{Name: "fetch", Description: "Fetch a web page", ServerID: working.ID, ToolKey: "working_fetch", IsActive: true},
+		{Name: "search", Description: "Search the web", ServerID: working.ID, ToolKey: "working_search", IsActive: true},
+		{Name: "unstable", Description: "Always fails", ServerID: working.ID, ToolKey: "working_unstable", IsActive: true},
+	} {
+		require.NoError(t, server.mcpToolService.CreateTool(tool))
+	}
+	require.NoError(t, server.mcpToolService.SetLocalizedDescription("working_search", "zh", "搜索网页"))
+
+	var translated []string
+	server.descriptionTranslator = func(ctx context.Context, description string, language string) (string, error) {
+		assert.Equal(t, "zh", language)
+		if description == "Always fails" {
+			return "", errors.New("模型不可用")
+		}
+		translated = append(translated, description)
+		return "译文：" + description, nil
+	}
+
+	post := func(body string) (*httptest.ResponseRecorder, ToolTranslateResult) {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/mcp/tools/translate", strings.NewReader(body)))
+		var resp struct {
+			Data ToolTranslateResult `json:"data"`
+		}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w, resp.Data
+	}
+
+	// 每次请求最多翻译limit个工具，按工具键的顺序；没有描述或已有译文的工具跳过
+	w, result := post(`{"language":"zh","limit":1}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, result.Translated)
+	assert.Empty(t, result.Failed)
+	assert.Equal(t, 1, result.Remaining)
+
+	// 再次请求继续翻译剩余的工具，失败的工具报告后可以重试
+	w, result = post(`{"language":"zh"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 0, result.Translated)
+	require.Len(t, result.Failed, 1)
+	assert.Equal(t, "working_unstable", result.Failed[0].ToolKey)
+	assert.Equal(t, 1, result.Remaining)
+	assert.Equal(t, []string{"Fetch a web page"}, translated)
+
+	descriptions, err := server.mcpToolService.LocalizedDescriptions()
+	require.NoError(t, err)
+	assert.Equal(t, "译文：Fetch a web page", descriptions["working_fetch"]["zh"])
+	assert.Equal(t, "搜索网页", descriptions["working_search"]["zh"])
+
+	w, _ = post(`{"language":" "}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 已有翻译在进行时拒绝新的请求
+	server.translateMu.Lock()
+	w, _ = post(`{"language":"zh"}`)
+	server.translateMu.Unlock()
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,162 @@
+// Package webui embeds the built web frontend so that mcpagent-web runs as a
+// single binary. scripts/build-web.sh copies the output of the frontend build
+// into the dist directory before compiling the server; without it only a
+// placeholder is embedded and the handler asks for the frontend to be built.
+package webui
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// indexFile is the entry point of the single page application
+	indexFile = "index.html"
+
+	// cacheImmutable is sent for assets whose name contains a content hash
+	cacheImmutable = "public, max-age=31536000, immutable"
+
+	// cacheRevalidate is sent for index.html and the other assets that keep
+	// their names across builds
+	cacheRevalidate = "no-cache"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// hashedAsset matches the file names produced by the frontend build for
+// content hashed assets, such as index-BXk3a9Zq.js
+var hashedAsset = regexp.MustCompile(`[.-][A-Za-z0-9_-]{8,}\.[A-Za-z0-9]+$`)
+
+// Assets returns the embedded frontend with index.html at its root
+func Assets() fs.FS {
+	assets, err := fs.Sub(dist, "dist")
+	if err != nil {
+		// dist 是固定的合法路径，不会出错
+		panic(err)
+	}
+	return assets
+}
+
+// DirFS returns the frontend in dir, it overrides the embedded assets during
+// frontend development
+//
+// Parameters:
+//   - dir: Directory containing the frontend build, such as web/dist
+//
+// Returns:
+//   - fs.FS: Files of dir
+//   - error: Error if dir is not a directory
+func DirFS(dir string) (fs.FS, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("静态文件目录不可用: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("静态文件目录不可用: %s 不是目录", dir)
+	}
+	return os.DirFS(dir), nil
+}
+
+// Handler serves the frontend in assets. Unknown paths outside /api/ get
+// index.html so that the client side router handles them, missing API
+// endpoints and assets get 404.
+//
+// Parameters:
+//   - assets: Frontend files with index.html at the root, see Assets and DirFS
+//
+// Returns:
+//   - http.Handler: Handler for every path not routed to the API
+func Handler(assets fs.FS) http.Handler {
+	return &handler{assets: assets}
+}
+
+// handler implements Handler
+type handler struct {
+	assets fs.FS
+}
+
+// ServeHTTP serves the requested file or falls back to index.html
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = indexFile
+	}
+	if h.serveFile(w, r, name) {
+		return
+	}
+
+	// API和资源文件不回退到index.html，避免以HTML响应脚本或接口请求
+	if name == "api" || strings.HasPrefix(name, "api/") || strings.HasPrefix(name, "assets/") {
+		http.NotFound(w, r)
+		return
+	}
+	if !h.serveFile(w, r, indexFile) {
+		http.Error(w, "前端资源未构建，请执行 scripts/build-web.sh 或使用 -static-dir 指定前端构建目录", http.StatusNotFound)
+	}
+}
+
+// serveFile writes the regular file name of the assets with its content type
+// and cache headers
+//
+// Returns:
+//   - bool: false if there is no such file and nothing was written
+func (h *handler) serveFile(w http.ResponseWriter, r *http.Request, name string) bool {
+	f, err := h.assets.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return false
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Cache-Control", cacheControl(name))
+
+	// 嵌入的文件修改时间为零值，ServeContent此时不发送Last-Modified
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return true
+}
+
+// cacheControl returns the Cache-Control header of the asset name
+func cacheControl(name string) string {
+	if name != indexFile && hashedAsset.MatchString(path.Base(name)) {
+		return cacheImmutable
+	}
+	return cacheRevalidate
+}
+
+// HasIndex reports whether assets contain the entry point of the frontend,
+// it is false for the placeholder embedded when the frontend was not built
+func HasIndex(assets fs.FS) bool {
+	info, err := fs.Stat(assets, indexFile)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,109 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAssets returns a frontend build like the one produced by vite
+func testAssets() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":                {Data: []byte("<html>app</html>")},
+		"robots.txt":                {Data: []byte("User-agent: *")},
+		"assets/index-BXk3a9Zq.js":  {Data: []byte("console.log(1)")},
+		"assets/index-C2dFe81a.css": {Data: []byte("body{}")},
+	}
+}
+
+func serve(h http.Handler, method string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestHandler_ServesAssets(t *testing.T) {
+	h := Handler(testAssets())
+
+	tests := []struct {
+		path        string
+		contentType string
+		cache       string
+		body        string
+	}{
+		{"/", "text/html; charset=utf-8", "no-cache", "<html>app</html>"},
+		{"/index.html", "text/html; charset=utf-8", "no-cache", "<html>app</html>"},
+		{"/assets/index-BXk3a9Zq.js", "text/javascript; charset=utf-8", cacheImmutable, "console.log(1)"},
+		{"/assets/index-C2dFe81a.css", "text/css; charset=utf-8", cacheImmutable, "body{}"},
+		{"/robots.txt", "text/plain; charset=utf-8", "no-cache", "User-agent: *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := serve(h, http.MethodGet, tt.path)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.cache, w.Header().Get("Cache-Control"))
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_SPAFallback(t *testing.T) {
+	h := Handler(testAssets())
+
+	for _, path := range []string{"/tasks", "/tasks/123/history", "/settings?tab=llm"} {
+		w := serve(h, http.MethodGet, path)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "<html>app</html>", w.Body.String(), path)
+		assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"), path)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"), path)
+	}
+
+	// API和缺失的资源文件不回退到index.html
+	for _, path := range []string{"/api", "/api/unknown", "/assets/index-old12345.js"} {
+		w := serve(h, http.MethodGet, path)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "<html>app</html>", path)
+	}
+
+	w := serve(h, http.MethodPost, "/tasks")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestHandler_WithoutIndex(t *testing.T) {
+	h := Handler(fstest.MapFS{".gitkeep": {}})
+
+	w := serve(h, http.MethodGet, "/")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "-static-dir")
+	assert.False(t, HasIndex(fstest.MapFS{".gitkeep": {}}))
+	assert.True(t, HasIndex(testAssets()))
+}
+
+func TestDirFS(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>dev</html>"), 0o644))
+
+	assets, err := DirFS(dir)
+	require.NoError(t, err)
+	w := serve(Handler(assets), http.MethodGet, "/some/route")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<html>dev</html>", w.Body.String())
+
+	_, err = DirFS(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+	_, err = DirFS(filepath.Join(dir, "index.html"))
+	assert.Error(t, err)
+}
+
+func TestAssets(t *testing.T) {
+	// 未构建前端时只嵌入占位文件，构建后包含index.html
+	_, err := Assets().Open(".gitkeep")
+	assert.NoError(t, err)
+}
@@ -85,9 +85,11 @@ fi
 
 print_success "前端构建完成"
 
-# 复制前端构建产物
-print_info "复制前端文件..."
-cp -r "$WEB_DIR/dist" "$BUILD_DIR/web"
+# 复制前端构建产物，编译时嵌入到后端二进制文件中
+print_info "嵌入前端文件..."
+WEBUI_DIST="$PROJECT_ROOT/pkg/webui/dist"
+find "$WEBUI_DIST" -mindepth 1 ! -name .gitkeep -exec rm -rf {} +
+cp -r "$WEB_DIR/dist/." "$WEBUI_DIST/"
 
 # 构建后端
 print_info "构建后端应用..."
@@ -162,14 +164,13 @@ print_info "创建部署说明..."
 cat > "$BUILD_DIR/README.md" << 'EOF'
 # MCP Agent Web UI - 部署包
 
-这是MCP Agent Web UI的完整部署包，包含前端和后端的所有文件。
+这是MCP Agent Web UI的完整部署包，前端页面已嵌入后端可执行文件中。
 
 ## 文件说明
 
 - `mcpagent-web` - Linux版本的后端可执行文件
 - `mcpagent-web-darwin` - macOS版本的后端可执行文件  
 - `mcpagent-web.exe` - Windows版本的后端可执行文件
-- `web/` - 前端静态文件目录
 - `config_public.yaml` - 配置文件示例
 - `mcp_servers.json` - MCP服务器配置文件
 - `start.sh` - Linux/macOS启动脚本
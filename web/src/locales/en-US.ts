@@ -179,6 +179,9 @@ export default {
       proxy: 'Network Proxy',
       logLevel: 'Log Level',
       maxStep: 'Max Steps',
+      agentLanguage: 'Agent Language',
+      fofaEmail: 'FOFA Email',
+      fofaKey: 'FOFA API Key',
       advanced: {
         title: 'Advanced Settings',
         requestTimeout: 'Request Timeout',
@@ -256,7 +259,16 @@ export default {
     fileTooLarge: 'File size cannot exceed {size}',
     stopTaskFailed: 'Failed to stop task: {message}',
     unknown: 'Unknown error',
-    clearChatHistoryFailed: 'Failed to clear chat history'
+    clearChatHistoryFailed: 'Failed to clear chat history',
+    codes: {
+      task_timeout: 'Task timed out',
+      canceled: 'Task canceled',
+      agent_closed: 'Agent closed',
+      max_steps_exceeded: 'Maximum steps reached',
+      tool_failed: 'Tool call failed',
+      output_invalid: 'Final output does not match the JSON Schema',
+      internal_error: 'Task failed'
+    }
   },
 
   // Success messages
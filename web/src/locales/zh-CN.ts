@@ -179,6 +179,9 @@ export default {
       proxy: '网络代理',
       logLevel: '日志级别',
       maxStep: '最大步数',
+      agentLanguage: '智能体语言',
+      fofaEmail: 'FOFA邮箱',
+      fofaKey: 'FOFA API Key',
       advanced: {
         title: '高级设置',
         requestTimeout: '请求超时',
@@ -256,7 +259,16 @@ export default {
     fileTooLarge: '文件大小不能超过{size}',
     stopTaskFailed: '停止任务失败: {message}',
     unknown: '未知错误',
-    clearChatHistoryFailed: '清空聊天记录失败'
+    clearChatHistoryFailed: '清空聊天记录失败',
+    codes: {
+      task_timeout: '任务执行超时',
+      canceled: '任务已取消',
+      agent_closed: '智能体已关闭',
+      max_steps_exceeded: '已达到最大步骤数',
+      tool_failed: '工具调用失败',
+      output_invalid: '最终输出不符合JSON Schema',
+      internal_error: '任务执行失败'
+    }
   },
 
   // 成功消息
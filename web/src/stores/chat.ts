@@ -68,7 +68,7 @@ export const useChatStore = defineStore('chat', () => {
       console.log('【聊天】更新后的任务运行状态:', isTaskRunning.value, '状态:', status.status)
       
       // 如果任务已完成或出错，确保重置typing状态
-      if (status.status === 'completed' || status.status === 'error') {
+      if (status.status === 'completed' || status.status === 'error' || status.status === 'timeout') {
         isTyping.value = false
       }
     })
@@ -198,6 +198,13 @@ export const useChatStore = defineStore('chat', () => {
       return
     }
 
+    // 回答片段直接追加到内容，不作为单独的事件显示
+    if (event.type === 'result_chunk') {
+      lastMsg.content += event.content
+      scrollIfEnabled()
+      return
+    }
+
     // 添加事件到最后一条助手消息
     lastMsg.events = lastMsg.events || []
     lastMsg.events.push(event)
@@ -212,6 +219,19 @@ export const useChatStore = defineStore('chat', () => {
       case 'tool_call':
         // 工具调用事件
         break
+      case 'tool_result': {
+        // 根据call_id更新对应的工具调用状态
+        const call = lastMsg.events.find(
+          (e) => e.type === 'tool_call' && e.call_id === event.call_id
+        )
+        if (call && call.type === 'tool_call') {
+          call.status = event.status
+          call.result = event.result
+          call.error = event.error
+          call.duration_ms = event.duration_ms
+        }
+        break
+      }
       case 'result':
         lastMsg.content = event.content
         isTyping.value = false
@@ -222,7 +242,11 @@ export const useChatStore = defineStore('chat', () => {
         break
     }
 
-    // 自动滚动
+    scrollIfEnabled()
+  }
+
+  // 自动滚动
+  const scrollIfEnabled = () => {
     if (config.value.autoScroll) {
       nextTick(() => {
         scrollToBottom()
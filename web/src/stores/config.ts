@@ -21,11 +21,15 @@ export const useConfigStore = defineStore('config', () => {
     },
     system_prompt: '你是精通互联网的信息收集专家，需要帮助用户进行信息收集，当前时间是：{date}。',
     max_step: 20,
-    placeholders: {}
+    language: 'zh',
+    placeholders: {},
+    integrations: {
+      fofa: { email: '', key: '' }
+    }
   })
 
   const availableModels = ref<string[]>([])
-  const availableTools = ref<Array<{ name: string; description: string; server: string }>>([])
+  const availableTools = ref<Array<{ name: string; presented_name?: string; description: string; server: string }>>([])
   const llmConfigs = ref<LLMConfigModel[]>([])
   const currentLLMConfigId = ref<number | null>(null)
   const mcpServerConfigs = ref<MCPServerConfigModel[]>([])
@@ -253,7 +257,7 @@ export const useConfigStore = defineStore('config', () => {
     config.value.mcp.tools = getToolConfigsFromNames(toolNames);
   }
 
-  const updateAvailableTools = (tools: Array<{ name: string; description: string; server: string }>) => {
+  const updateAvailableTools = (tools: Array<{ name: string; presented_name?: string; description: string; server: string }>) => {
     availableTools.value = tools
   }
 
@@ -503,6 +507,16 @@ export const useConfigStore = defineStore('config', () => {
     }
   }
 
+  const cloneLLMConfig = async (id: number) => {
+    try {
+      await llmApi.cloneConfig(id)
+      await loadLLMConfigs(true)
+    } catch (error) {
+      console.error('复制LLM配置失败:', error)
+      throw error
+    }
+  }
+
   const setDefaultLLMConfig = async (id: number) => {
     try {
       await llmApi.setDefaultConfig(id)
@@ -645,6 +659,16 @@ export const useConfigStore = defineStore('config', () => {
     }
   }
 
+  const cloneMCPServerConfig = async (id: number) => {
+    try {
+      await mcpApi.cloneServerConfig(id)
+      await loadMCPServerConfigs(true)
+    } catch (error) {
+      console.error('复制MCP服务器配置失败:', error)
+      throw error
+    }
+  }
+
   const loadToolsFromDatabase = async () => {
     try {
       console.log('【配置】loadToolsFromDatabase开始执行', new Date().toISOString())
@@ -918,6 +942,16 @@ export const useConfigStore = defineStore('config', () => {
     }
   }
 
+  const cloneSystemPrompt = async (id: number) => {
+    try {
+      await systemPromptApi.clonePrompt(id)
+      await loadSystemPrompts(true)
+    } catch (error) {
+      console.error('复制SystemPrompt配置失败:', error)
+      throw error
+    }
+  }
+
   const setDefaultSystemPrompt = async (id: number) => {
     try {
       await systemPromptApi.setDefaultPrompt(id)
@@ -1008,12 +1042,14 @@ export const useConfigStore = defineStore('config', () => {
     updateLLMConfigById,
     deleteLLMConfig,
     setDefaultLLMConfig,
+    cloneLLMConfig,
     selectLLMConfig,
     syncLLMConfigToApp,
     loadMCPServerConfigs,
     createMCPServerConfig,
     updateMCPServerConfigById,
     deleteMCPServerConfig,
+    cloneMCPServerConfig,
     loadToolsFromDatabase,
     buildMCPConfigFromDatabase,
     loadSystemPrompts,
@@ -1021,6 +1057,7 @@ export const useConfigStore = defineStore('config', () => {
     updateSystemPromptById,
     deleteSystemPrompt,
     setDefaultSystemPrompt,
+    cloneSystemPrompt,
     selectSystemPrompt,
     syncSystemPromptToApp
   }
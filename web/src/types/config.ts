@@ -53,6 +53,7 @@ export interface MCPServerConfigModel {
   url: string
   headers: string // JSON格式存储的HTTP头部
   // Common fields
+  name_prefix?: string // 向大模型展示的工具名前缀
   disabled: boolean
   is_active: boolean
   created_at: string
@@ -72,6 +73,7 @@ export interface CreateMCPServerConfigForm {
   url: string
   headers: string[]
   // Common fields
+  name_prefix?: string
   disabled?: boolean
 }
 
@@ -95,6 +97,7 @@ export interface MCPConfig {
   config_file?: string
   mcp_servers?: Record<string, MCPServer>
   tools: MCPToolConfig[]
+  prefix_tool_names?: boolean // 是否以"<服务器>__<工具>"的名称向大模型展示MCP工具
 }
 
 export interface ProxyConfig {
@@ -140,7 +143,19 @@ export interface AppConfig {
   llm: LLMConfig
   system_prompt: string
   max_step: number
+  language?: 'zh' | 'en'
   placeholders: Record<string, any>
+  integrations?: IntegrationsConfig
+}
+
+// 第三方服务集成配置
+export interface IntegrationsConfig {
+  fofa: {
+    email: string
+    key: string
+    base_url?: string
+    max_size?: number
+  }
 }
 
 export interface ConfigState {
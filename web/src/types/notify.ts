@@ -1,11 +1,12 @@
 // 通知事件类型定义，对应Go后端的Notify接口
 
-export type NotifyEventType = 'message' | 'thinking' | 'tool_call' | 'result' | 'error'
+export type NotifyEventType = 'message' | 'thinking' | 'tool_call' | 'tool_result' | 'result_chunk' | 'result' | 'error'
 
 export interface BaseNotifyEvent {
   type: NotifyEventType
-  timestamp: number
+  timestamp: number // 服务器发送时间，仅供显示
   id: string
+  seq?: number // 任务内递增的事件序号，事件顺序以此为准
 }
 
 export interface MessageEvent extends BaseNotifyEvent {
@@ -16,6 +17,7 @@ export interface MessageEvent extends BaseNotifyEvent {
 export interface ThinkingEvent extends BaseNotifyEvent {
   type: 'thinking'
   content: string
+  related_call_id?: string // 思考之后发起的工具调用ID
 }
 
 export interface ToolCallEvent extends BaseNotifyEvent {
@@ -25,6 +27,25 @@ export interface ToolCallEvent extends BaseNotifyEvent {
   status?: 'calling' | 'success' | 'error'
   result?: any
   error?: string
+  call_id?: string
+  duration_ms?: number // 工具调用的耗时（毫秒），收到tool_result后设置
+}
+
+export interface ToolResultEvent extends BaseNotifyEvent {
+  type: 'tool_result'
+  tool_name: string
+  call_id: string
+  status: 'success' | 'error'
+  result?: string // 超过服务器-tool-result-limit的结果被截断
+  error?: string
+  error_code?: ErrorCode
+  duration_ms?: number // 工具调用的耗时（毫秒）
+}
+
+// 最终回答生成过程中的片段，之后仍会收到完整的result
+export interface ResultChunkEvent extends BaseNotifyEvent {
+  type: 'result_chunk'
+  content: string
 }
 
 export interface ResultEvent extends BaseNotifyEvent {
@@ -35,10 +56,21 @@ export interface ResultEvent extends BaseNotifyEvent {
 export interface ErrorEvent extends BaseNotifyEvent {
   type: 'error'
   error: string
+  error_code?: ErrorCode
   details?: any
 }
 
-export type NotifyEvent = MessageEvent | ThinkingEvent | ToolCallEvent | ResultEvent | ErrorEvent
+// 服务端返回的稳定错误码，前端据此本地化错误信息
+export type ErrorCode =
+  | 'task_timeout'
+  | 'canceled'
+  | 'agent_closed'
+  | 'max_steps_exceeded'
+  | 'tool_failed'
+  | 'output_invalid'
+  | 'internal_error'
+
+export type NotifyEvent = MessageEvent | ThinkingEvent | ToolCallEvent | ToolResultEvent | ResultChunkEvent | ResultEvent | ErrorEvent
 
 // SSE消息类型
 export interface SSEMessage {
@@ -64,10 +96,12 @@ export interface ChatMessage {
 // 任务执行状态
 export interface TaskStatus {
   id: string
-  status: 'pending' | 'running' | 'completed' | 'error'
+  status: 'pending' | 'running' | 'completed' | 'error' | 'timeout'
   progress?: number
   current_step?: string
   total_steps?: number
+  error_code?: ErrorCode
+  output_valid?: boolean // 配置了output_schema时，最终输出是否通过校验
 }
 
 // 用户输入
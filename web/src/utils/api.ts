@@ -13,7 +13,42 @@ export interface ApiResponse<T = any> {
 
 // 扩展API响应类型，添加工具专用的响应类型
 export interface ToolsApiResponse extends ApiResponse {
-  tools?: Array<{ name: string; description: string; server: string }>;
+  tools?: Array<{ name: string; presented_name?: string; description: string; server: string }>;
+}
+
+// 标准错误响应中验证失败的字段
+export interface FieldError {
+  field: string
+  message: string
+}
+
+// 标准错误响应：{"success":false,"error":{"code":"...","message":"...","fields":[...]}}
+export class ApiError extends Error {
+  code: string
+  status: number
+  fields: FieldError[]
+
+  constructor(message: string, code: string, status: number, fields: FieldError[] = []) {
+    super(message)
+    this.name = 'ApiError'
+    this.code = code
+    this.status = status
+    this.fields = fields
+  }
+}
+
+// 从失败的响应中解析错误，兼容非JSON的错误内容
+export async function readApiError(response: Response): Promise<ApiError> {
+  const text = await response.text()
+  try {
+    const body = JSON.parse(text)
+    if (body && body.error && typeof body.error === 'object') {
+      return new ApiError(body.error.message || `HTTP ${response.status}`, body.error.code || '', response.status, body.error.fields || [])
+    }
+  } catch {
+    // 不是JSON，直接使用文本内容
+  }
+  return new ApiError(text || `HTTP ${response.status}`, '', response.status)
 }
 
 // HTTP请求工具函数
@@ -31,8 +66,7 @@ async function request<T = any>(
     })
 
     if (!response.ok) {
-      const errorText = await response.text()
-      throw new Error(errorText || `HTTP ${response.status}`)
+      throw await readApiError(response)
     }
 
     const data = await response.json()
@@ -112,6 +146,13 @@ export const llmApi = {
       method: 'POST',
     })
   },
+
+  // 复制LLM配置
+  async cloneConfig(id: number): Promise<ApiResponse<LLMConfigModel>> {
+    return request(`/llm/configs/${id}/clone`, {
+      method: 'POST',
+    })
+  },
 }
 
 // 任务相关API
@@ -179,6 +220,13 @@ export const mcpApi = {
       method: 'DELETE',
     })
   },
+
+  // 复制MCP服务器配置
+  async cloneServerConfig(id: number): Promise<ApiResponse<MCPServerConfigModel>> {
+    return request(`/mcp/servers/${id}/clone`, {
+      method: 'POST',
+    })
+  },
 }
 
 // SystemPrompt相关API
@@ -222,6 +270,13 @@ export const systemPromptApi = {
       method: 'POST',
     })
   },
+
+  // 复制SystemPrompt配置
+  async clonePrompt(id: number): Promise<SystemPromptModel> {
+    return request(`/system-prompts/${id}/clone`, {
+      method: 'POST',
+    }) as Promise<any>
+  },
 }
 
 // 导出所有API
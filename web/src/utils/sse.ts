@@ -1,4 +1,5 @@
 import type { NotifyEvent, TaskStatus, SSEMessage } from '@/types/notify'
+import { readApiError } from './api'
 
 export class SSEManager {
   private eventSource: EventSource | null = null
@@ -112,9 +113,9 @@ export class SSEManager {
       })
 
       if (!response.ok) {
-        const errorText = await response.text()
-        console.error('【SSE】任务请求失败:', response.status, errorText)
-        throw new Error(`发送任务失败: ${errorText}`)
+        const apiError = await readApiError(response)
+        console.error('【SSE】任务请求失败:', response.status, apiError.code, apiError.fields)
+        throw new Error(`发送任务失败: ${apiError.message}`)
       }
 
       const result = await response.json()
@@ -168,7 +169,7 @@ export class SSEManager {
           this.onTaskStatusCallback?.(status)
 
           // 如果任务完成或出错，自动断开连接
-          if (status.status === 'completed' || status.status === 'error') {
+          if (status.status === 'completed' || status.status === 'error' || status.status === 'timeout') {
             console.log(`【SSE】任务 ${status.id} 已完成或出错，状态: ${status.status}，准备断开连接`)
             setTimeout(() => this.disconnect(), 1000) // 延迟1秒断开，确保最后的消息都收到
           }
@@ -210,9 +211,9 @@ export class SSEManager {
       })
 
       if (!response.ok) {
-        const errorText = await response.text()
-        console.error('【SSE】停止任务请求失败:', response.status, errorText)
-        throw new Error(`停止任务失败: ${errorText}`)
+        const apiError = await readApiError(response)
+        console.error('【SSE】停止任务请求失败:', response.status, apiError.code, apiError.fields)
+        throw new Error(`停止任务失败: ${apiError.message}`)
       }
 
       const result = await response.json()